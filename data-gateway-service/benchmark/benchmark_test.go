@@ -16,6 +16,7 @@ import (
 	"go.uber.org/zap"
 
 	"go-data-gateway/internal/cache"
+	"go-data-gateway/internal/config"
 	"go-data-gateway/internal/datasource"
 	v1 "go-data-gateway/internal/handlers/v1"
 	custommw "go-data-gateway/internal/middleware/chi"
@@ -57,15 +58,16 @@ func setupTestServer(b *testing.B, cfg BenchmarkConfig) *httptest.Server {
 	}
 
 	// Create router
+	pagination := config.DefaultPagination()
 	r := chi.NewRouter()
 	r.Use(middleware.RequestID)
 	r.Use(custommw.Logger(logger))
 	r.Use(middleware.Recoverer)
 
 	// Create handlers
-	queryHandler := v1.NewQueryHandler(dataSources, logger)
-	batchHandler := v1.NewBatchHandler(dataSources, logger)
-	streamHandler := v1.NewStreamHandler(dataSources, logger)
+	queryHandler := v1.NewQueryHandler(dataSources, pagination.Query, nil, false, logger)
+	batchHandler := v1.NewBatchHandler(dataSources, nil, logger)
+	streamHandler := v1.NewStreamHandler(dataSources, pagination.Stream, logger)
 
 	// Register routes
 	r.Post("/api/v1/query", queryHandler.Execute)
@@ -0,0 +1,130 @@
+// Command doctor checks a gateway environment before the server is started
+// in it: the configuration, Redis, Dremio's REST API, every data source and
+// a one-row read of every whitelisted table. It exits 1 when a check failed.
+//
+//	go run ./cmd/doctor [-format text|json] [-timeout 15s] [-no-color]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/joho/godotenv"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/cache"
+	"go-data-gateway/internal/clients"
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/doctor"
+	"go-data-gateway/internal/policy"
+)
+
+func main() {
+	format := flag.String("format", "text", "Report format: text or json")
+	timeout := flag.Duration("timeout", 15*time.Second, "Time each check may take")
+	noColor := flag.Bool("no-color", false, "Write the text report without colors")
+	verbose := flag.Bool("verbose", false, "Log what the clients do while checking")
+	flag.Parse()
+
+	_ = godotenv.Load()
+	logger := zap.NewNop()
+	if *verbose {
+		logger, _ = zap.NewDevelopment()
+	}
+
+	cfg := config.Load()
+	checks := []doctor.Check{doctor.KeysCheck(cfg)}
+	checks = append(checks, doctor.RedisCheck(cfg.Redis.Host, func(ctx context.Context) error {
+		redisCache, err := cache.NewRedisCacheFromConfig(cfg.Redis, logger)
+		if err != nil {
+			return err
+		}
+		return redisCache.Close()
+	}))
+
+	credentials, credentialsErr := dremioCredentials(cfg)
+	checks = append(checks, doctor.DremioRESTCheck(cfg.Dremio.Host, func() (doctor.Pinger, error) {
+		if credentialsErr != nil {
+			return nil, credentialsErr
+		}
+		restConfig := cfg.Dremio
+		restConfig.Port = cfg.Dremio.RESTPort
+		return clients.NewDremioClientWithCredentials(restConfig, credentials, logger)
+	}))
+
+	// The whitelist is read when the checks are built, so POLICY_FILE is
+	// loaded first
+	policyErr := loadPolicy(cfg, logger)
+	checks = append(checks, doctor.Check{Name: "policy", Run: func(ctx context.Context) error { return policyErr }})
+	sourceChecks, closeSources := doctor.SourceChecks(dataSources(cfg, credentials, logger), config.ActiveSecurityConfig())
+	checks = append(checks, sourceChecks...)
+
+	report := doctor.Run(context.Background(), checks, *timeout)
+	closeSources()
+	var err error
+	if *format == "json" {
+		err = report.WriteJSON(os.Stdout)
+	} else {
+		err = report.WriteText(os.Stdout, !*noColor && isTerminal(os.Stdout))
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	if !report.OK() {
+		os.Exit(1)
+	}
+}
+
+// loadPolicy activates POLICY_FILE, as the server does at startup, and
+// returns the outcome of the policy check
+func loadPolicy(cfg *config.Config, logger *zap.Logger) error {
+	if cfg.PolicyFile == "" {
+		return doctor.Skip("POLICY_FILE is not set; the compiled-in whitelist applies")
+	}
+	if err := policy.NewWatcher(cfg.PolicyFile, cfg.PolicyPollInterval, logger).Load(); err != nil {
+		return doctor.Fail(err, "Fix POLICY_FILE; the server refuses to start with an invalid policy")
+	}
+	return nil
+}
+
+// dremioCredentials reads the Dremio service account's credentials the way
+// the server does
+func dremioCredentials(cfg *config.Config) (*clients.Credentials, error) {
+	if cfg.Dremio.CredentialsFile == "" {
+		return clients.NewCredentials(clients.DremioCredentials{
+			Username: cfg.Dremio.Username,
+			Password: cfg.Dremio.Password,
+			Token:    cfg.Dremio.Token,
+		}), nil
+	}
+	creds, err := clients.ReadCredentialsFile(cfg.Dremio.CredentialsFile)
+	if err != nil {
+		return nil, err
+	}
+	return clients.NewCredentials(creds), nil
+}
+
+// dataSources declares the default tenant's data sources, built by the
+// server's factory and uncached so every check reaches the source
+func dataSources(cfg *config.Config, credentials *clients.Credentials, logger *zap.Logger) []doctor.Source {
+	factory := datasource.NewFactory(datasource.Dependencies{Logger: logger, DremioCredentials: credentials})
+	sources := make([]doctor.Source, 0, len(cfg.DataSources))
+	for _, declared := range cfg.DataSources {
+		s := doctor.Source{Name: declared.Name, Open: func() (datasource.DataSource, error) { return factory.Create(declared) }}
+		if driver, ok := datasource.LookupDriver(declared.Type); ok {
+			s.Type = driver.Type
+		}
+		sources = append(sources, s)
+	}
+	return sources
+}
+
+// isTerminal reports whether f is a terminal, where colors are readable
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -3,23 +3,49 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"sort"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/joho/godotenv"
+	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 
+	"go-data-gateway/internal/auth"
+	"go-data-gateway/internal/bqcost"
+	"go-data-gateway/internal/buildinfo"
 	"go-data-gateway/internal/cache"
 	"go-data-gateway/internal/clients"
+	"go-data-gateway/internal/coalesce"
 	"go-data-gateway/internal/config"
 	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/export"
 	v1 "go-data-gateway/internal/handlers/v1"
+	"go-data-gateway/internal/inflight"
+	"go-data-gateway/internal/jsonrows"
+	"go-data-gateway/internal/killswitch"
+	"go-data-gateway/internal/lock"
+	"go-data-gateway/internal/logging"
+	"go-data-gateway/internal/metrics"
 	custommw "go-data-gateway/internal/middleware/chi"
+	"go-data-gateway/internal/mirror"
+	"go-data-gateway/internal/numeric"
+	"go-data-gateway/internal/policy"
+	"go-data-gateway/internal/sentry"
+	"go-data-gateway/internal/shedding"
+	"go-data-gateway/internal/sheets"
+	"go-data-gateway/internal/snapshot"
+	"go-data-gateway/internal/spill"
+	"go-data-gateway/internal/streamquota"
+	"go-data-gateway/internal/tenant"
 )
 
 func main() {
@@ -37,9 +63,41 @@ func main() {
 
 	// Load configuration
 	cfg := config.Load()
+	logger = logger.WithOptions(logging.Redaction(cfg.LogRedactSQL)).
+		With(zap.String("gateway_version", buildinfo.Version))
+	jsonrows.SetEnabled(cfg.JSONFastEncoding)
+	numericMode, err := numeric.ParseMode(cfg.NumericAsString)
+	if err != nil {
+		logger.Fatal("Invalid numeric configuration", zap.Error(err))
+	}
+	numeric.SetMode(numericMode)
+	build := buildinfo.Get()
+	fingerprint := cfg.Fingerprint()
 	logger.Info("Configuration loaded",
 		zap.String("port", cfg.Port),
-		zap.String("env", cfg.Environment))
+		zap.String("env", cfg.Environment),
+		zap.String("commit", build.Commit),
+		zap.String("build_time", build.BuildTime),
+		zap.String("config_fingerprint", fingerprint))
+
+	// CA files of the Redis and BigQuery connections
+	if err := cfg.CheckCertificates(); err != nil {
+		logger.Fatal("Invalid TLS configuration", zap.Error(err))
+	}
+	corsMiddleware, err := custommw.CORS(cfg.CORS)
+	if err != nil {
+		logger.Fatal("Invalid CORS configuration", zap.Error(err))
+	}
+
+	// Table whitelists and cache TTLs, reloaded when POLICY_FILE changes
+	policyWatcher, err := initializePolicy(cfg, logger)
+	if err != nil {
+		logger.Fatal("Invalid policy file", zap.Error(err))
+	}
+	if policyWatcher != nil {
+		policyWatcher.Start()
+		defer policyWatcher.Stop()
+	}
 
 	// Initialize cache
 	cacheService := initializeCache(cfg, logger)
@@ -47,9 +105,170 @@ func main() {
 		defer cacheService.Close()
 	}
 
-	// Initialize data sources with caching
-	dataSources := initializeDataSources(cfg, logger, cacheService)
-	defer closeDataSources(dataSources)
+	// Bypasses the result cache while Redis is slow or failing
+	cacheBreaker := initializeCacheBreaker(cfg, cacheService, logger)
+	defer cacheBreaker.Close()
+
+	// Upstream jobs cancelled because their request ended
+	cancelMetrics := metrics.NewCancelCounter()
+
+	// Bytes and slot time of BigQuery jobs by API key
+	bigQueryUsage := metrics.NewBigQueryUsageCounter()
+
+	// Dremio service account credentials, shared by the REST client and the
+	// sources with shared_credentials and rotated without a restart
+	dremioCredentials, err := initializeDremioCredentials(cfg)
+	if err != nil {
+		logger.Fatal("Invalid Dremio credentials file", zap.Error(err))
+	}
+
+	// Dremio REST client for the admin endpoints, column validation and job
+	// id lookups
+	dremioREST := initializeDremioREST(cfg, dremioCredentials, logger)
+	if dremioREST != nil {
+		dremioREST.SetJobCancels(cancelMetrics)
+	}
+
+	// Queries mirrored to shadow data sources by outcome
+	shadowMetrics := metrics.NewShadowCounter()
+	fallbackMetrics := metrics.NewFallbackCounter()
+
+	// Cached results whose schema drifted, by data source
+	driftMetrics := metrics.NewSchemaDriftCounter()
+
+	// Fresh results the cache failed to store, by data source
+	cacheWriteMetrics := metrics.NewCacheWriteCounter()
+
+	// Lookups by id answered from or stored as not-found markers, by table
+	notFoundMetrics := metrics.NewNotFoundCounter()
+
+	// Query latency histograms by data source and endpoint
+	latencies := metrics.NewQueryLatencies()
+
+	// Initialize per-tenant data sources with caching
+	sources := sourceDeps{
+		Dependencies: datasource.Dependencies{
+			Logger:            logger,
+			Fallbacks:         fallbackMetrics,
+			JobCancels:        cancelMetrics,
+			BigQueryUsage:     bigQueryUsage,
+			DremioCredentials: dremioCredentials,
+		},
+		Cache:       cacheService,
+		Breaker:     cacheBreaker,
+		Shadows:     shadowMetrics,
+		SchemaDrift: driftMetrics,
+		CacheWrites: cacheWriteMetrics,
+		Latencies:   latencies,
+	}
+	if dremioREST != nil {
+		sources.DremioJobs = dremioREST
+	}
+	tenants, err := initializeTenants(cfg, sources)
+	if err != nil {
+		logger.Fatal("Invalid tenant configuration", zap.Error(err))
+	}
+	defer closeTenants(tenants)
+	dataSources := tenants.Sources()
+
+	// Credentials rotated through the admin API or DREMIO_CREDENTIALS_FILE
+	rotateCredentials := rotateDremioCredentials(tenants, dremioCredentials, dremioREST, logger)
+	if watcher := initializeCredentialsWatcher(cfg, dremioCredentials, rotateCredentials, logger); watcher != nil {
+		watcher.Start()
+		defer watcher.Stop()
+	}
+
+	// Initialize API key store (env keys plus optional Redis-managed keys)
+	keyStore := initializeKeyStore(cfg, logger)
+	defer keyStore.Close()
+
+	// Scheduled tasks run on the replica holding their lock
+	locks := initializeLocks(cfg, cacheService, logger)
+
+	// Initialize scheduled exports
+	exports, err := initializeExports(cfg, tenants, logger)
+	if err != nil {
+		logger.Fatal("Invalid export configuration", zap.Error(err))
+	}
+	exports.SetLockRunner(locks)
+	exports.Start()
+	defer exports.Stop()
+
+	// BigQuery spend exported on /metrics, collected by one replica
+	costCollector := initializeCostMetrics(cfg, cacheService, locks, logger)
+	costCollector.Start()
+	defer costCollector.Stop()
+
+	// Token buckets of the API's clients
+	rateLimits := custommw.NewRateLimitStore(cfg.RateLimiting)
+	rateLimits.Start()
+	defer rateLimits.Stop()
+
+	// Snapshots compared by POST /api/v1/diff
+	snapshots, err := initializeSnapshots(cfg, cacheService, logger)
+	if err != nil {
+		logger.Fatal("Invalid diff snapshot configuration", zap.Error(err))
+	}
+
+	// Query counts by data source and whitelisted attribution labels
+	queryMetrics := metrics.NewQueryCounter(cfg.QueryMetricLabels)
+
+	// Requests to monitoring endpoints by path and status
+	endpointMetrics := metrics.NewEndpointCounter()
+
+	// Recovered handler panics by route, also reported to Sentry if configured
+	panicMetrics := metrics.NewPanicCounter()
+	panicReporter, err := sentry.New(cfg.SentryDSN, cfg.Environment)
+	if err != nil {
+		logger.Fatal("Invalid Sentry configuration", zap.Error(err))
+	}
+
+	// Networks that read /cache/stats without an API key
+	cacheStatsNetworks, err := cfg.CacheStats.Networks()
+	if err != nil {
+		logger.Fatal("Invalid cache stats configuration", zap.Error(err))
+	}
+
+	// Load shedding of low-priority API requests under overload
+	shedder := shedding.New(cfg.LoadShedding, logger)
+
+	// Concurrent identical GETs to list/detail endpoints share one execution
+	var coalescer *coalesce.Group
+	if cfg.CoalesceRequests {
+		coalescer = coalesce.New()
+	}
+
+	// Tender sort and filter columns are checked against the Dremio catalog,
+	// fetched in the background so an outage does not block startup
+	columnCatalog := initializeColumnCatalog(cfg, dremioREST, logger)
+	if columnCatalog != nil {
+		columnCatalog.Start()
+		defer columnCatalog.Stop()
+	}
+
+	// Concurrent streams per API key
+	streamQuota := initializeStreamQuota(cfg, cacheService, logger)
+
+	// Routes and data sources switched off by the policy or the admin API
+	killSwitch := initializeKillSwitch(cfg, cacheService, logger)
+	killSwitch.Start()
+	defer killSwitch.Stop()
+	tenants.SetKillSwitch(killSwitch)
+
+	// Large streams can be spilled to disk and downloaded from there
+	spills := initializeSpill(cfg, logger)
+	if spills != nil {
+		spills.Start()
+		defer spills.Stop()
+	}
+
+	// Sampled read requests replayed on a shadow target and compared
+	mirrorer, err := initializeMirror(cfg, dataSources, logger)
+	if err != nil {
+		logger.Fatal("Invalid mirror configuration", zap.Error(err))
+	}
+	mirrorer.Start()
+	defer mirrorer.Stop()
 
 	// Create router with Chi
 	r := chi.NewRouter()
@@ -57,33 +276,84 @@ func main() {
 	// Global middleware
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
+	r.Use(custommw.GatewayVersion(buildinfo.Version))
 	r.Use(custommw.Logger(logger))
-	r.Use(middleware.Recoverer)
-	r.Use(custommw.CORS())
+	r.Use(custommw.Recover(logger, panicMetrics, panicReporter))
+	r.Use(corsMiddleware)
 	r.Use(middleware.Compress(5))
 
 	// Health endpoints (no auth)
-	r.Get("/health", healthCheck)
-	r.Get("/ready", readyCheck(dataSources))
+	r.Get("/health", healthCheck(shedder, killSwitch, tenants, fingerprint))
+	r.Get("/ready", readyCheck(tenants))
 
 	// Metrics endpoint
-	r.Handle("/metrics", custommw.PrometheusHandler())
+	r.Handle("/metrics", custommw.PrometheusHandler([]custommw.PrometheusCollector{
+		queryMetrics, latencies, endpointMetrics, panicMetrics, shadowMetrics, fallbackMetrics,
+		cancelMetrics, driftMetrics, cacheWriteMetrics, notFoundMetrics, bigQueryUsage,
+		shedder, coalescer, mirrorer, cacheBreaker, costCollector, rateLimits,
+	}))
 
-	// Cache stats endpoint (no auth for monitoring)
-	r.Get("/cache/stats", getCacheStats(cacheService, dataSources))
+	// Cache stats show query fingerprints and data source metrics: internal
+	// networks or API keys with the metrics:read scope only
+	r.With(custommw.CountEndpoint(endpointMetrics, "/cache/stats"),
+		custommw.NetworkOrScope(keyStore, auth.ScopeMetricsRead, cacheStatsNetworks)).
+		Get("/cache/stats", getCacheStats(cacheService, cacheBreaker, tenants, latencies))
+	if cfg.CacheStats.PublicSummary {
+		r.With(custommw.CountEndpoint(endpointMetrics, "/cache/stats/summary")).
+			Get("/cache/stats/summary", getCacheStatsSummary(cacheService))
+	}
 
 	// API v1 routes
 	r.Route("/api/v1", func(r chi.Router) {
-		// API middleware
-		r.Use(custommw.APIKeyAuth(cfg.APIKeys))
-		r.Use(custommw.RateLimiter(cfg.RateLimit))
+		// API middleware; shedding runs first so rejected requests stay cheap
+		r.Use(custommw.LoadShedding(shedder))
+		r.Use(custommw.APIKeyAuth(keyStore))
+		r.Use(custommw.KillSwitch(killSwitch))
+		r.Use(custommw.TenantResolver(tenants))
+		r.Use(custommw.NessieBranch(cfg.Dremio.Nessie))
+		r.Use(custommw.RateLimiter(rateLimits, cfg.RateLimit))
 		r.Use(middleware.Timeout(30 * time.Second))
+		r.Use(custommw.CacheControl(config.NoStore)) // Cacheable GET groups override below
+		r.Use(custommw.QueryEndpoint)
+		r.Use(custommw.Mirror(mirrorer))
+
+		// Queries, batches and streams running on this replica
+		inflightOps := inflight.NewRegistry()
 
 		// Create handlers
-		queryHandler := v1.NewQueryHandler(dataSources, logger)
-		tenderHandler := v1.NewTenderHandler(dataSources["DATAWAREHOUSE"], logger)
-		batchHandler := v1.NewBatchHandler(dataSources, logger)
-		streamHandler := v1.NewStreamHandler(dataSources, logger)
+		queryHandler := v1.NewQueryHandler(dataSources, cfg.Pagination.Query, queryMetrics, cfg.Dremio.ExposeJobIDs, logger)
+		tenderHandler := v1.NewTenderHandler(dataSources["DATAWAREHOUSE"], cfg.Pagination.Tender, columnCatalog, logger)
+		tenderHandler.SetKeywordSearch(cfg.Search.Tender)
+		tenderHandler.SetFilters(cfg.TenderFilters)
+		tenderHandler.SetTiebreakers(cfg.Pagination.Tiebreakers)
+		tenderHandler.SetRelations(cfg.Relations)
+		tenderHandler.SetBulk(cfg.Bulk, cacheService)
+		tenderHandler.SetTimeTravel(cfg.Dremio.TimeTravel)
+		tenderHandler.SetCountOnly(cfg.CountCacheTTL)
+		tenderHandler.SetNotFoundCache(cfg.NotFoundCacheTTL, cacheService, notFoundMetrics)
+		batchHandler := v1.NewBatchHandler(dataSources, queryMetrics, logger)
+		streamHandler := v1.NewStreamHandler(dataSources, cfg.Pagination.Stream, logger)
+		queryHandler.SetAutoLimit(cfg.AutoLimit.Limit)
+		queryHandler.SetStreaming(cfg.QueryStream)
+		queryHandler.SetEngineOptions(cfg.Dremio.SessionOptions)
+		queryHandler.SetQueryCeilings(cfg.QueryCeilings)
+		queryHandler.SetTimeTravel(cfg.Dremio.TimeTravel)
+		batchHandler.SetAutoLimit(cfg.AutoLimit.Limit)
+		streamHandler.SetAutoLimit(cfg.AutoLimit.Limit)
+		streamHandler.SetTiebreakers(cfg.Pagination.Tiebreakers)
+		if spills != nil {
+			streamHandler.SetSpill(spills)
+		}
+		tableHandler := v1.NewTableHandler(dataSources, cfg.Pagination.Tables, config.ActiveSecurityConfig, logger)
+		tableHandler.SetTiebreakers(cfg.Pagination.Tiebreakers)
+		tableHandler.SetTimeTravel(cfg.Dremio.TimeTravel)
+		adminDremioHandler := initializeDremioAdmin(dremioREST, logger)
+		diffHandler := v1.NewDiffHandler(dataSources, snapshots, cfg.Diff, config.ActiveSecurityConfig, logger)
+		diffHandler.SetPostProcess(cfg.PostProcess)
+		timeseriesHandler := v1.NewTimeseriesHandler(dataSources["DATAWAREHOUSE"], dataSources["BIGQUERY"], cfg.TimeseriesMaxSpan, logger)
+		graphqlHandler := v1.NewGraphQLHandler(dataSources["DATAWAREHOUSE"], dataSources["BIGQUERY"], cfg.Pagination, config.ActiveSecurityConfig, logger)
+		graphqlHandler.SetTiebreakers(cfg.Pagination.Tiebreakers)
+		graphqlHandler.SetRelations(cfg.Relations)
 
 		// Create BigQuery client for RUP handler and cost estimator
 		var rupHandler *v1.RUPHandler
@@ -93,18 +363,46 @@ func main() {
 			if err != nil {
 				logger.Warn("BigQuery client initialization failed", zap.Error(err))
 			} else {
-				rupHandler = v1.NewRUPHandler(bigQueryClient, logger)
-				costEstimator = clients.NewQueryCostEstimator(bigQueryClient.GetClient(), cfg.BigQuery.ProjectID, logger)
+				bigQueryClient.SetJobCancels(cancelMetrics)
+				rupHandler = v1.NewRUPHandler(bigQueryClient, cfg.Pagination.RUP, logger)
+				rupHandler.SetKeywordSearch(cfg.Search.RUP)
+				rupHandler.SetTiebreakers(cfg.Pagination.Tiebreakers)
+				rupHandler.SetBulk(cfg.Bulk, cacheService)
+				costEstimator = clients.NewQueryCostEstimator(bigQueryClient.GetClient(), cfg.BigQuery.ProjectID, cfg.BigQuery.Location, logger)
+				rupHandler.SetCountOnly(cfg.CountCacheTTL, cacheService, costEstimator)
+				rupHandler.SetNotFoundCache(cfg.NotFoundCacheTTL, cacheService, notFoundMetrics)
 				logger.Info("BigQuery client initialized for RUP handler and cost estimation")
 			}
 		}
+		tableHandler.SetProfile(cfg.Profile, costEstimator)
+		tableHandler.SetSample(cfg.Sample)
+		queryHandler.SetCountOnly(cfg.CountCacheTTL, costEstimator)
 
 		// Query endpoints
-		r.Post("/query", queryHandler.Execute)
-		r.Post("/batch", batchHandler.Execute)
-		r.Post("/batch/stream", batchHandler.Stream)
-		r.Post("/stream", streamHandler.Stream)
-		r.Post("/stream/sse", streamHandler.StreamSSE)
+		r.With(custommw.Inflight(inflightOps, inflight.KindQuery)).Post("/query", queryHandler.Execute)
+		r.With(custommw.Inflight(inflightOps, inflight.KindBatch)).Post("/batch", batchHandler.Execute)
+		r.With(custommw.StreamQuota(streamQuota), custommw.Inflight(inflightOps, inflight.KindBatch)).
+			Post("/batch/stream", batchHandler.Stream)
+		r.With(custommw.StreamQuota(streamQuota), custommw.Inflight(inflightOps, inflight.KindStream)).
+			Post("/stream", streamHandler.Stream)
+		r.With(custommw.StreamQuota(streamQuota), custommw.Inflight(inflightOps, inflight.KindStream)).
+			Post("/stream/sse", streamHandler.StreamSSE)
+		r.Get("/stream/spills/{id}", streamHandler.Download)
+		r.Post("/diff", diffHandler.Diff)
+		if sheetsHandler := initializeSheets(cfg, dataSources, logger); sheetsHandler != nil {
+			r.Post("/export/sheets", sheetsHandler.Export)
+		}
+
+		// Data sources and their query defaults
+		r.Get("/sources", v1.NewSourcesHandler(dataSources, cfg.QueryCeilings).List)
+
+		// Table browsing over GetData
+		r.With(custommw.CacheControl(cfg.CacheHeaders.Tables), custommw.Coalesce(coalescer)).
+			Get("/sources/{source}/tables/{table}/rows", tableHandler.Rows)
+		r.With(custommw.CacheControl(cfg.CacheHeaders.Tables), custommw.Coalesce(coalescer)).
+			Get("/sources/{source}/tables/{table}/profile", tableHandler.Profile)
+		r.With(custommw.CacheControl(cfg.CacheHeaders.Tables), custommw.Coalesce(coalescer)).
+			Get("/sources/{source}/tables/{table}/sample", tableHandler.Sample)
 
 		// Cost estimation endpoint (BigQuery only)
 		if costEstimator != nil {
@@ -130,20 +428,105 @@ func main() {
 
 		// Tender endpoints (Dremio)
 		r.Route("/tender", func(r chi.Router) {
+			r.Use(custommw.CacheControl(cfg.CacheHeaders.Tender))
+			r.Use(custommw.Coalesce(coalescer)) // GETs only
 			r.Get("/", tenderHandler.List)
+			r.Get("/timeseries", timeseriesHandler.Tender)
+			r.Get("/statuses", tenderHandler.Statuses)
 			r.Get("/{id}", tenderHandler.GetByID)
 			r.Post("/search", tenderHandler.Search)
+			r.Post("/bulk", tenderHandler.Bulk)
 		})
 
 		// RUP endpoints (BigQuery)
 		if rupHandler != nil {
 			r.Route("/rup", func(r chi.Router) {
+				r.Use(custommw.CacheControl(cfg.CacheHeaders.RUP))
+				r.Use(custommw.Coalesce(coalescer)) // GETs only
 				r.Get("/", rupHandler.List)
+				r.Get("/timeseries", timeseriesHandler.RUP)
 				r.Get("/{id}", rupHandler.GetByID)
 				r.Post("/search", rupHandler.Search)
+				r.Post("/bulk", rupHandler.Bulk)
 			})
 		}
 
+		// GraphQL queries of the tender and RUP tables
+		r.Post("/graphql", graphqlHandler.Query)
+
+		// Admin endpoints
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(custommw.RequireScope(auth.ScopeAdmin))
+
+			adminKeyHandler := v1.NewAdminKeyHandler(keyStore, logger)
+			adminKeyHandler.SetNessie(cfg.Dremio.Nessie)
+			r.Get("/keys", adminKeyHandler.List)
+			r.Post("/keys", adminKeyHandler.Create)
+			r.Delete("/keys/{id}", adminKeyHandler.Revoke)
+
+			adminExportHandler := v1.NewAdminExportHandler(exports, logger)
+			r.Get("/exports", adminExportHandler.List)
+			r.Post("/exports", adminExportHandler.Trigger)
+			r.Get("/exports/{name}/runs", adminExportHandler.Runs)
+			r.Get("/exports/{name}/runs/{runID}", adminExportHandler.Run)
+
+			adminSheddingHandler := v1.NewAdminSheddingHandler(shedder, logger)
+			r.Get("/shedding", adminSheddingHandler.Get)
+			r.Put("/shedding", adminSheddingHandler.SetMode)
+
+			adminLatencyHandler := v1.NewAdminLatencyHandler(latencies, tenants, logger)
+			r.Get("/latency", adminLatencyHandler.Get)
+			r.Post("/latency/reset", adminLatencyHandler.Reset)
+
+			adminPolicyHandler := v1.NewAdminPolicyHandler(policyWatcher, logger)
+			r.Get("/policy", adminPolicyHandler.Get)
+
+			adminInfoHandler := v1.NewAdminInfoHandler(cfg, logger)
+			adminInfoHandler.SetKillSwitch(killSwitch)
+			r.Get("/info", adminInfoHandler.Get)
+
+			adminFlagHandler := v1.NewAdminFlagHandler(killSwitch, dataSources, logger)
+			r.Get("/flags", adminFlagHandler.Get)
+			r.Put("/flags", adminFlagHandler.Set)
+			r.Delete("/flags", adminFlagHandler.Clear)
+
+			adminLockHandler := v1.NewAdminLockHandler(locks.Locker(), cfg.Locks.Owner, logger)
+			r.Get("/locks", adminLockHandler.List)
+
+			adminMirrorHandler := v1.NewAdminMirrorHandler(mirrorer, logger)
+			r.Get("/mirror/mismatches", adminMirrorHandler.Mismatches)
+
+			adminBigQueryCostsHandler := v1.NewAdminBigQueryCostsHandler(costCollector, logger)
+			r.Get("/bigquery/costs", adminBigQueryCostsHandler.Get)
+
+			adminStreamHandler := v1.NewAdminStreamHandler(streamQuota, logger)
+			r.Get("/streams", adminStreamHandler.List)
+
+			adminInflightHandler := v1.NewAdminInflightHandler(inflightOps, cfg.LogRedactSQL, logger)
+			r.Get("/inflight", adminInflightHandler.List)
+
+			adminCacheHandler := v1.NewAdminCacheHandler(dataSources, cacheService, logger)
+			adminCacheHandler.SetAutoLimit(cfg.AutoLimit.Limit)
+			adminCacheHandler.SetTiebreakers(cfg.Pagination.Tiebreakers)
+			adminCacheHandler.SetNotFound(notFoundMetrics)
+			r.Post("/cache/inspect", adminCacheHandler.Inspect)
+			r.Delete("/cache/not-found", adminCacheHandler.InvalidateNotFound)
+
+			adminSnapshotHandler := v1.NewAdminSnapshotHandler(snapshots, logger)
+			r.Get("/snapshots", adminSnapshotHandler.List)
+			r.Delete("/snapshots/{tenant}/{label}", adminSnapshotHandler.Delete)
+
+			adminDremioCredentialsHandler := v1.NewAdminDremioCredentialsHandler(rotateCredentials, logger)
+			r.Post("/dremio/credentials", adminDremioCredentialsHandler.Rotate)
+
+			if adminDremioHandler != nil {
+				r.Get("/dremio/reflections", adminDremioHandler.Reflections)
+				r.Get("/dremio/jobs", adminDremioHandler.Jobs)
+				r.Get("/dremio/catalog", adminDremioHandler.Catalog)
+				r.Get("/dremio/catalog/*", adminDremioHandler.Catalog)
+			}
+		})
+
 		// Add more resource endpoints here
 	})
 
@@ -197,90 +580,515 @@ func initializeCache(cfg *config.Config, logger *zap.Logger) cache.Cache {
 	return cacheService
 }
 
-// initializeDataSources creates all configured data sources with caching
-func initializeDataSources(cfg *config.Config, logger *zap.Logger, cacheService cache.Cache) map[string]datasource.DataSource {
-	sources := make(map[string]datasource.DataSource)
-
-	// Initialize Dremio client
-	if cfg.Dremio.Host != "" {
-		// Arrow Flight SQL is now working with Apache Arrow Go v18!
-		useArrowFlight := true
-		if useArrowFlight { // Arrow Flight SQL on port 32010
-			// Arrow Flight SQL configuration (port 32010)
-			arrowConfig := &datasource.DremioConfig{
-				Host:     cfg.Dremio.Host,
-				Port:     32010, // Arrow Flight SQL port
-				Username: cfg.Dremio.Username,
-				Password: cfg.Dremio.Password,
-				UseTLS:   false,
-				Project:  "nessie_iceberg",
-			}
+// initializeCacheBreaker creates the breaker of the result cache, or returns
+// nil when it is disabled or there is no Redis to bypass
+func initializeCacheBreaker(cfg *config.Config, cacheService cache.Cache, logger *zap.Logger) *cache.Breaker {
+	if _, noop := cacheService.(*cache.NoOpCache); noop || !cfg.CacheBreaker.Enabled {
+		return nil
+	}
+	return cache.NewBreaker(cfg.CacheBreaker, cacheService, logger.Named("cache-breaker"))
+}
+
+// initializeCostMetrics creates the collector of BigQuery spend, or returns
+// nil when it is disabled or BigQuery is not configured. Its report queries
+// are labeled as the gateway's own, which leaves them out of the reports.
+func initializeCostMetrics(cfg *config.Config, cacheService cache.Cache, locks *lock.Runner, logger *zap.Logger) *bqcost.Collector {
+	if !cfg.CostMetrics.Enabled || cfg.BigQuery.ProjectID == "" {
+		return nil
+	}
+	bigQueryClient, err := clients.NewBigQueryClient(cfg.BigQuery, logger)
+	if err != nil {
+		logger.Warn("BigQuery cost metrics disabled: client initialization failed", zap.Error(err))
+		return nil
+	}
+	estimator := clients.NewQueryCostEstimator(bigQueryClient.GetClient(), cfg.BigQuery.ProjectID, cfg.BigQuery.Location, logger)
+	estimator.SetJobLabels(map[string]string{clients.LabelTask: bqcost.TaskLabel})
+
+	logger.Info("BigQuery cost metrics enabled",
+		zap.Duration("interval", cfg.CostMetrics.Interval),
+		zap.Int("report_days", cfg.CostMetrics.ReportDays))
+	return bqcost.NewCollector(estimator, cfg.CostMetrics, cacheService, locks, logger.Named("bqcost"))
+}
+
+// initializeStreamQuota creates the per-key stream limiter, counting streams
+// in the cache's Redis when there is one; it returns nil when the limit is
+// disabled
+func initializeStreamQuota(cfg *config.Config, cacheService cache.Cache, logger *zap.Logger) *streamquota.Limiter {
+	if cfg.StreamQuota.MaxPerKey <= 0 {
+		logger.Info("Stream quota disabled")
+		return nil
+	}
+
+	var store streamquota.Store = streamquota.NewMemoryStore()
+	if redisCache, ok := cacheService.(*cache.RedisCache); ok {
+		store = streamquota.NewRedisStore(redisCache.Client())
+	}
+	logger.Info("Stream quota enabled", zap.Int("max_per_key", cfg.StreamQuota.MaxPerKey))
+	return streamquota.NewLimiter(store, cfg.StreamQuota.MaxPerKey, cfg.StreamQuota.TTL, cfg.Locks.Owner, logger.Named("streamquota"))
+}
+
+// initializeKillSwitch creates the switch of routes and data sources,
+// sharing the overrides of the admin API in the cache's Redis when there is
+// one. The policy's flags apply at once, the overrides once loaded.
+func initializeKillSwitch(cfg *config.Config, cacheService cache.Cache, logger *zap.Logger) *killswitch.Switch {
+	var store killswitch.Store = killswitch.NewMemoryStore()
+	if redisCache, ok := cacheService.(*cache.RedisCache); ok {
+		store = killswitch.NewRedisStore(redisCache.Client())
+	}
+	sw := killswitch.New(store, func() config.FlagPolicy { return config.ActivePolicy().Flags },
+		cfg.KillSwitch.RefreshInterval, logger.Named("killswitch"))
 
-			// Configure connection pool for Arrow Flight
-			poolConfig := &datasource.PoolConfig{
-				MaxConnections:      10,
-				MinConnections:      2,
-				MaxIdleTime:         30 * time.Minute,
-				ConnectionTimeout:   10 * time.Second,
-				HealthCheckInterval: 1 * time.Minute,
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := sw.Refresh(ctx); err != nil {
+		logger.Warn("Failed to load flags, starting with the policy's only", zap.Error(err))
+	}
+	return sw
+}
+
+// initializeSpill creates the store of spilled stream results; it returns nil
+// when spilling is disabled or its directory cannot be used
+func initializeSpill(cfg *config.Config, logger *zap.Logger) *spill.Store {
+	if !cfg.Spill.Enabled {
+		return nil
+	}
+
+	store, err := spill.NewStore(cfg.Spill, logger.Named("spill"))
+	if err != nil {
+		logger.Warn("Stream spill disabled", zap.String("dir", cfg.Spill.Dir), zap.Error(err))
+		return nil
+	}
+	logger.Info("Stream spill enabled",
+		zap.String("dir", cfg.Spill.Dir),
+		zap.Int64("max_bytes", cfg.Spill.MaxBytes),
+		zap.Duration("retention", cfg.Spill.Retention))
+	return store
+}
+
+// initializeMirror creates the mirror of MIRROR_ROUTES; it returns nil when
+// no route is mirrored
+func initializeMirror(cfg *config.Config, dataSources map[string]datasource.DataSource, logger *zap.Logger) (*mirror.Mirror, error) {
+	for from, to := range cfg.Mirror.Sources {
+		for _, name := range []string{from, to} {
+			if _, ok := dataSources[name]; !ok {
+				return nil, fmt.Errorf("MIRROR_SOURCES names unknown data source %s", name)
 			}
+		}
+	}
 
-			arrowClient, err := datasource.NewDremioArrowClientWithPool(arrowConfig, poolConfig, logger)
+	mirrorer, err := mirror.New(cfg.Mirror, logger.Named("mirror"))
+	if err != nil || mirrorer == nil {
+		return nil, err
+	}
+	logger.Info("Request mirroring enabled",
+		zap.String("target", mirrorer.State().Target),
+		zap.Any("routes", cfg.Mirror.Routes))
+	return mirrorer, nil
+}
+
+// initializeLocks creates the runner of scheduled tasks, locking through the
+// cache's Redis client. Without Redis every replica runs every task.
+func initializeLocks(cfg *config.Config, cacheService cache.Cache, logger *zap.Logger) *lock.Runner {
+	var locker *lock.RedisLocker
+	if redisCache, ok := cacheService.(*cache.RedisCache); ok {
+		locker = lock.NewRedisLocker(redisCache.Client(), cfg.Locks.Owner)
+		logger.Info("Distributed locks enabled", zap.String("owner", cfg.Locks.Owner))
+	}
+	return lock.NewRunner(locker, cfg.Locks.TTL, logger.Named("lock"))
+}
+
+// initializeSnapshots creates the diff snapshot store: GCS when a path is
+// configured, otherwise the cache. Without Redis snapshots are kept in memory
+// and lost on restart.
+func initializeSnapshots(cfg *config.Config, cacheService cache.Cache, logger *zap.Logger) (snapshot.Store, error) {
+	if cfg.Diff.GCSPath != "" {
+		store, err := snapshot.NewGCSStore(context.Background(), cfg.Diff.GCSPath, cfg.Exports.GCSCredentials)
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("Diff snapshots stored in GCS", zap.String("path", cfg.Diff.GCSPath))
+		return store, nil
+	}
+
+	if _, ok := cacheService.(*cache.NoOpCache); ok {
+		logger.Warn("Redis not configured, diff snapshots are kept in memory and lost on restart")
+		return snapshot.NewCacheStore(cache.NewMemoryCache(), cfg.Diff.SnapshotTTL), nil
+	}
+	return snapshot.NewCacheStore(cacheService, cfg.Diff.SnapshotTTL), nil
+}
+
+// initializeKeyStore creates the API key store, backed by Redis when enabled
+func initializeKeyStore(cfg *config.Config, logger *zap.Logger) *auth.KeyStore {
+	var backend auth.Backend
+	if cfg.KeyStoreEnabled {
+		if cfg.Redis.Host != "" {
+			// The certificates were checked at startup
+			opts, _ := cache.RedisOptions(cfg.Redis)
+			backend = auth.NewRedisBackend(redis.NewClient(opts))
+		} else {
+			logger.Warn("API key store enabled without Redis, managed keys will not be shared across replicas")
+			backend = auth.NewMemoryBackend()
+		}
+	}
+
+	store := auth.NewKeyStore(cfg.APIKeys, cfg.AdminKeys, backend, cfg.KeyStoreRefresh, logger)
+	store.AddEnvKeys(cfg.MetricsKeys, []string{auth.ScopeMetricsRead}, nil)
+	for _, t := range cfg.Tenants {
+		store.AddEnvKeys(t.APIKeys, nil, []string{t.ID})
+	}
+	if err := store.Start(context.Background()); err != nil {
+		logger.Warn("Failed to load stored API keys, only environment keys are active", zap.Error(err))
+	}
+
+	return store
+}
+
+// initializeExports creates the export scheduler with an uploader for each
+// destination scheme in use
+func initializeExports(cfg *config.Config, tenants *tenant.Registry, logger *zap.Logger) (*export.Scheduler, error) {
+	uploaders := make(map[string]export.Uploader)
+	for _, job := range cfg.Exports.Jobs {
+		switch {
+		case strings.HasPrefix(job.Destination, "gs://") && uploaders["gs"] == nil:
+			gcs, err := export.NewGCSUploader(context.Background(), cfg.Exports.GCSCredentials)
 			if err != nil {
-				logger.Warn("Arrow Flight SQL initialization failed", zap.Error(err))
-			} else {
-				// Wrap with caching
-				sources["DATAWAREHOUSE"] = cache.NewCachedDataSource(arrowClient, cacheService, logger)
-				logger.Info("Dremio Arrow Flight SQL client initialized with connection pool and caching",
-					zap.Int("max_connections", poolConfig.MaxConnections))
+				return nil, err
 			}
-		} else {
-			// Use REST client (default)
-			dremioClient, err := datasource.NewDremioRESTClient(
-				cfg.Dremio.Host,
-				cfg.Dremio.Port,
-				cfg.Dremio.Username,
-				cfg.Dremio.Password,
-				logger,
-			)
+			uploaders["gs"] = gcs
+		case strings.HasPrefix(job.Destination, "s3://") && uploaders["s3"] == nil:
+			s3, err := export.NewS3Uploader(cfg.Exports.S3)
 			if err != nil {
-				logger.Warn("Dremio REST client initialization failed", zap.Error(err))
-			} else {
-				// Wrap with caching
-				sources["DATAWAREHOUSE"] = cache.NewCachedDataSource(dremioClient, cacheService, logger)
-				logger.Info("Dremio REST client initialized with caching")
+				return nil, err
 			}
+			uploaders["s3"] = s3
 		}
 	}
 
-	// Initialize BigQuery client
-	if cfg.BigQuery.ProjectID != "" {
-		bigQueryWrapper, err := datasource.NewBigQueryWrapper(cfg.BigQuery, logger)
-		if err != nil {
-			logger.Warn("BigQuery client initialization failed", zap.Error(err))
-		} else {
-			// Wrap with caching
-			sources["BIGQUERY"] = cache.NewCachedDataSource(bigQueryWrapper, cacheService, logger)
-			logger.Info("BigQuery client initialized with caching", zap.String("project", cfg.BigQuery.ProjectID))
+	scheduler, err := export.NewScheduler(cfg.Exports, tenants.Default().ID, tenants.Source, uploaders, logger.Named("export"))
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.Exports.Jobs) > 0 {
+		logger.Info("Scheduled exports configured", zap.Int("exports", len(cfg.Exports.Jobs)))
+	}
+	return scheduler, nil
+}
+
+// initializeSheets creates the Google Sheets export handler; it returns nil
+// when the export is disabled or the Sheets client cannot be created
+func initializeSheets(cfg *config.Config, dataSources map[string]datasource.DataSource, logger *zap.Logger) *v1.SheetsHandler {
+	if !cfg.Sheets.Enabled {
+		return nil
+	}
+
+	client, err := sheets.NewGoogleClient(context.Background(), cfg.BigQuery.Credentials)
+	if err != nil {
+		logger.Warn("Google Sheets client initialization failed, Sheets export disabled", zap.Error(err))
+		return nil
+	}
+	logger.Info("Google Sheets export enabled", zap.Int("max_rows", cfg.Sheets.MaxRows))
+	return v1.NewSheetsHandler(dataSources, client, cfg.Sheets, config.ActiveSecurityConfig, logger)
+}
+
+// initializePolicy activates the policy of POLICY_FILE; it returns nil,
+// keeping the compiled-in policy, when none is configured
+func initializePolicy(cfg *config.Config, logger *zap.Logger) (*policy.Watcher, error) {
+	if cfg.PolicyFile == "" {
+		return nil, nil
+	}
+
+	watcher := policy.NewWatcher(cfg.PolicyFile, cfg.PolicyPollInterval, logger.Named("policy"))
+	if err := watcher.Load(); err != nil {
+		return nil, err
+	}
+	return watcher, nil
+}
+
+// initializeDremioCredentials holds the Dremio service account's
+// credentials: those of DREMIO_CREDENTIALS_FILE when set, of DREMIO_USERNAME
+// and DREMIO_PASSWORD or DREMIO_TOKEN otherwise
+func initializeDremioCredentials(cfg *config.Config) (*clients.Credentials, error) {
+	if cfg.Dremio.CredentialsFile == "" {
+		return clients.NewCredentials(clients.DremioCredentials{
+			Username: cfg.Dremio.Username,
+			Password: cfg.Dremio.Password,
+			Token:    cfg.Dremio.Token,
+		}), nil
+	}
+
+	creds, err := clients.ReadCredentialsFile(cfg.Dremio.CredentialsFile)
+	if err != nil {
+		return nil, err
+	}
+	return clients.NewCredentials(creds), nil
+}
+
+// initializeCredentialsWatcher rotates the Dremio credentials when
+// DREMIO_CREDENTIALS_FILE changes; it returns nil when none is configured
+func initializeCredentialsWatcher(cfg *config.Config, account *clients.Credentials, rotate v1.CredentialRotation, logger *zap.Logger) *clients.CredentialsWatcher {
+	if cfg.Dremio.CredentialsFile == "" {
+		return nil
+	}
+
+	initial, _ := account.Get()
+	return clients.NewCredentialsWatcher(cfg.Dremio.CredentialsFile, cfg.Dremio.CredentialsPollInterval, initial,
+		func(ctx context.Context, creds clients.DremioCredentials) error {
+			_, err := rotate(ctx, creds)
+			return err
+		}, logger.Named("dremio-credentials"))
+}
+
+// rotateDremioCredentials rotates the Dremio service account's credentials:
+// the REST client checks them first, so that ones Dremio refuses change
+// nothing, then every tenant's sources sharing them replace their
+// connections
+func rotateDremioCredentials(tenants *tenant.Registry, account *clients.Credentials, dremioREST *clients.DremioClient, logger *zap.Logger) v1.CredentialRotation {
+	return func(ctx context.Context, creds clients.DremioCredentials) ([]string, error) {
+		if err := creds.Validate(); err != nil {
+			return nil, err
+		}
+		if dremioREST != nil {
+			if err := dremioREST.RotateCredentials(ctx, creds); err != nil {
+				return nil, err
+			}
+		}
+
+		var (
+			rotated []string
+			errs    []error
+		)
+		for _, t := range tenants.Tenants() {
+			sources := tenants.TenantSources(t.ID)
+			names := make([]string, 0, len(sources))
+			for name := range sources {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			for _, name := range names {
+				ok, err := datasource.RotateCredentials(ctx, sources[name], account, creds)
+				if err != nil {
+					logger.Error("Data source credential rotation failed",
+						zap.String("tenant", t.ID),
+						zap.String("source", name),
+						zap.Error(err))
+					errs = append(errs, fmt.Errorf("%s/%s: %w", t.ID, name, err))
+					continue
+				}
+				if ok {
+					rotated = append(rotated, t.ID+"/"+name)
+				}
+			}
+		}
+		if len(errs) > 0 {
+			return rotated, errors.Join(errs...)
+		}
+		// Sources still initializing connect with the rotated credentials
+		account.Set(creds)
+		return rotated, nil
+	}
+}
+
+// initializeDremioREST creates the Dremio REST client behind the admin
+// reflection and job endpoints and the tender column catalog, authenticating
+// with the service account's credentials; it returns nil when Dremio is
+// unavailable
+func initializeDremioREST(cfg *config.Config, account *clients.Credentials, logger *zap.Logger) *clients.DremioClient {
+	if cfg.Dremio.Host == "" {
+		return nil
+	}
+
+	restConfig := cfg.Dremio
+	restConfig.Port = cfg.Dremio.RESTPort
+	client, err := clients.NewDremioClientWithCredentials(restConfig, account, logger)
+	if err != nil {
+		logger.Warn("Dremio REST client initialization failed, admin Dremio endpoints, column validation and job lookups disabled", zap.Error(err))
+		return nil
+	}
+	return client
+}
+
+// initializeDremioAdmin creates the admin Dremio handler; it returns nil
+// without a REST client
+func initializeDremioAdmin(client *clients.DremioClient, logger *zap.Logger) *v1.AdminDremioHandler {
+	if client == nil {
+		return nil
+	}
+
+	// Tables added by a later policy reload are reported after a restart
+	tables := config.ActiveSecurityConfig().AllowedDremioTables
+	return v1.NewAdminDremioHandler(client, tables, logger)
+}
+
+// initializeColumnCatalog validates tender columns against the Dremio
+// catalog; it returns nil, accepting any column, without a REST client
+func initializeColumnCatalog(cfg *config.Config, client *clients.DremioClient, logger *zap.Logger) *v1.ColumnCatalog {
+	if client == nil {
+		return nil
+	}
+
+	// Tables added by a later policy reload are validated after a restart
+	tables := config.ActiveSecurityConfig().AllowedDremioTables
+	return v1.NewColumnCatalog(client, tables, cfg.SchemaRefresh, logger)
+}
+
+// sourceDeps are what the tenants' data sources are built with: the
+// factory's dependencies and the cache wrapping every source
+type sourceDeps struct {
+	datasource.Dependencies
+
+	Cache       cache.Cache
+	Breaker     *cache.Breaker
+	Shadows     *metrics.ShadowCounter      // Queries mirrored to shadow sources
+	SchemaDrift *metrics.SchemaDriftCounter // Cached results whose schema drifted
+	CacheWrites *metrics.CacheWriteCounter  // Fresh results the cache failed to store
+	Latencies   *metrics.QueryLatencies
+}
+
+// initializeTenants builds the tenant registry with each tenant's data
+// sources. A source that fails to initialize, e.g. because Dremio is briefly
+// unreachable, is registered as pending and retried in the background.
+func initializeTenants(cfg *config.Config, deps sourceDeps) (*tenant.Registry, error) {
+	if err := datasource.CheckDataSources(cfg.DataSources); err != nil {
+		return nil, err
+	}
+	registry, err := tenant.NewRegistry(cfg.Tenants, cfg.DefaultTenant)
+	if err != nil {
+		return nil, err
+	}
+	for _, declared := range cfg.DataSources {
+		registry.SetDefaults(declared.Name, declared.Defaults)
+	}
+
+	for _, t := range registry.Tenants() {
+		tenantDeps := deps
+		tenantDeps.Logger = deps.Logger.With(zap.String("tenant", t.ID))
+		tenantLogger := tenantDeps.Logger
+		for name, source := range configureDataSources(cfg, t, registry, tenantDeps) {
+			instance, err := source.init()
+			if err != nil {
+				tenantLogger.Warn("Data source initialization failed, retrying in background",
+					zap.String("source", name),
+					zap.Error(err))
+				registry.RegisterPending(t.ID, name, source.sourceType, err, source.init, tenantLogger)
+				continue
+			}
+			registry.Register(t.ID, name, instance)
+		}
+	}
+
+	return registry, nil
+}
+
+// dataSourceInit constructs one of a tenant's configured data sources
+type dataSourceInit struct {
+	sourceType datasource.DataSourceType
+	init       tenant.Initializer
+}
+
+// configureDataSources returns constructors for a tenant's declared data
+// sources with caching; tenant overrides replace the Dremio project and
+// BigQuery project/dataset/location of the sources of those types.
+// deps.DremioJobs, when set, looks up the job ids of Arrow Flight queries for
+// sources with job_lookup enabled (DREMIO_JOB_LOOKUP for the default source);
+// deps.DremioCredentials are those of the sources with shared_credentials.
+func configureDataSources(cfg *config.Config, t *tenant.Tenant, registry *tenant.Registry, deps sourceDeps) map[string]dataSourceInit {
+	logger := deps.Logger
+	factory := datasource.NewFactory(deps.Dependencies)
+
+	sources := make(map[string]dataSourceInit, len(cfg.DataSources))
+	for _, declared := range cfg.DataSources {
+		driver, ok := datasource.LookupDriver(declared.Type)
+		if !ok {
+			continue // Rejected by CheckDataSources at startup
+		}
+		sourceConfig := tenantDataSource(declared, t)
+		namespace := sourceNamespace(t, declared, driver.Type)
+		sources[declared.Name] = dataSourceInit{
+			sourceType: driver.Type,
+			init: func() (datasource.DataSource, error) {
+				source, err := factory.Create(sourceConfig)
+				if err != nil {
+					return nil, err
+				}
+				// Only cache misses reach the primary, so only they are mirrored
+				if sourceConfig.Shadow != "" && sourceConfig.ShadowPercent > 0 {
+					secondary := sourceConfig.Shadow
+					source = datasource.NewShadowDataSource(source,
+						func() (datasource.DataSource, bool) { return registry.Source(t.ID, secondary) },
+						datasource.ShadowConfig{
+							Primary:   sourceConfig.Name,
+							Secondary: secondary,
+							Percent:   sourceConfig.ShadowPercent,
+						}, deps.Shadows, logger)
+				}
+				cached := cache.NewNamespacedCachedDataSource(source, deps.Cache, namespace, logger)
+				cached.SetLatencies(deps.Latencies, sourceConfig.Name)
+				cached.SetSchemaDrift(deps.SchemaDrift)
+				cached.SetCacheWrites(deps.CacheWrites)
+				cached.SetBreaker(deps.Breaker)
+				return cached, nil
+			},
 		}
 	}
 
 	return sources
 }
 
-// closeDataSources closes all data source connections
-func closeDataSources(sources map[string]datasource.DataSource) {
-	for name, source := range sources {
-		if err := source.Close(); err != nil {
-			zap.L().Error("Failed to close data source",
-				zap.String("name", name),
-				zap.Error(err))
+// sourceNamespace returns the cache namespace of a tenant's source. A source
+// named after its type, such as DATAWAREHOUSE, keeps the tenant namespace and
+// with it the entries cached before sources were declared by name; others
+// get their own so that two sources of a type never share entries.
+func sourceNamespace(t *tenant.Tenant, declared config.DataSourceConfig, sourceType datasource.DataSourceType) string {
+	own := declared.CacheNamespace
+	if own == "" && declared.Name != string(sourceType) {
+		own = declared.Name
+	}
+	switch {
+	case own == "":
+		return t.CacheNamespace
+	case t.CacheNamespace == "":
+		return own
+	default:
+		return t.CacheNamespace + ":" + own
+	}
+}
+
+// tenantDataSource applies a tenant's project overrides to a declared source
+func tenantDataSource(declared config.DataSourceConfig, t *tenant.Tenant) config.DataSourceConfig {
+	overrides := map[string]string{}
+	switch declared.Type {
+	case config.SourceTypeDremioArrow:
+		overrides["project"] = t.DremioProject
+	case config.SourceTypeBigQuery:
+		overrides["project_id"] = t.BigQueryProject
+		overrides["dataset_id"] = t.BigQueryDataset
+		overrides["location"] = t.BigQueryLocation
+	}
+	for key, value := range overrides {
+		if value != "" {
+			declared = declared.WithSetting(key, value)
 		}
 	}
+	return declared
+}
+
+// closeTenants closes all tenants' data source connections
+func closeTenants(registry *tenant.Registry) {
+	if err := registry.Close(); err != nil {
+		zap.L().Error("Failed to close data sources", zap.Error(err))
+	}
 }
 
-// getCacheStats returns cache statistics
-func getCacheStats(cacheService cache.Cache, dataSources map[string]datasource.DataSource) http.HandlerFunc {
+// sourceStats are the /cache/stats metrics of one data source
+type sourceStats struct {
+	cache.Metrics
+	Pool map[string]interface{} `json:"pool,omitempty"` // Sources with a connection pool only
+}
+
+// getCacheStats returns cache statistics, the metrics of every tenant's data
+// sources and the query latency by data source and endpoint
+func getCacheStats(cacheService cache.Cache, cacheBreaker *cache.Breaker, tenants *tenant.Registry, latencies *metrics.QueryLatencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		stats := make(map[string]interface{})
 
@@ -291,50 +1099,96 @@ func getCacheStats(cacheService cache.Cache, dataSources map[string]datasource.D
 				stats["cache"] = cacheStats
 			}
 		}
+		stats["cache_breaker"] = cacheBreaker.State()
 
-		// Get metrics from each cached data source
-		sourceMetrics := make(map[string]interface{})
-		for name, source := range dataSources {
-			if cached, ok := source.(*cache.CachedDataSource); ok {
-				sourceMetrics[name] = cached.GetMetrics()
+		// Get metrics from each tenant's cached data sources
+		tenantMetrics := make(map[string]interface{})
+		for _, t := range tenants.Tenants() {
+			sourceMetrics := make(map[string]interface{})
+			for name, source := range tenants.TenantSources(t.ID) {
+				if cached, ok := source.(*cache.CachedDataSource); ok {
+					sourceMetrics[name] = sourceStats{
+						Metrics: cached.GetMetrics(),
+						Pool:    datasource.PoolMetrics(cached),
+					}
+				}
 			}
+			tenantMetrics[t.ID] = sourceMetrics
 		}
-		stats["sources"] = sourceMetrics
+		stats["tenants"] = tenantMetrics
+		stats["latency"] = latencies.Summaries()
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(stats)
 	}
 }
 
-// healthCheck returns service health status
-func healthCheck(w http.ResponseWriter, r *http.Request) {
-	response := map[string]string{
-		"status":  "healthy",
-		"service": "go-data-gateway",
-		"version": "2.0.0", // Chi + Arrow version
-	}
+// getCacheStatsSummary returns the cache hit rate and connection status only,
+// for dashboards that cannot send an API key
+func getCacheStatsSummary(cacheService cache.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		summary := map[string]interface{}{"connected": false}
+		if cacheService != nil {
+			stats, err := cacheService.Stats(r.Context())
+			summary["connected"] = err == nil
+			if hitRate, ok := stats["hit_rate"]; ok {
+				summary["hit_rate"] = hitRate
+			}
+		}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(summary)
+	}
 }
 
-// readyCheck checks if all data sources are ready
-func readyCheck(sources map[string]datasource.DataSource) http.HandlerFunc {
+// healthCheck returns service health status and the running build. With
+// ?deep=true it also runs a query on every data source and reports
+// "degraded" when one fails.
+func healthCheck(shedder *shedding.Shedder, killSwitch *killswitch.Switch, tenants *tenant.Registry, fingerprint string) http.HandlerFunc {
+	build := buildinfo.Get()
 	return func(w http.ResponseWriter, r *http.Request) {
-		ctx := r.Context()
-		checks := make(map[string]string)
+		response := map[string]interface{}{
+			"status":             "healthy",
+			"service":            "go-data-gateway",
+			"version":            build.Version,
+			"commit":             build.Commit,
+			"build_time":         build.BuildTime,
+			"uptime_seconds":     int64(buildinfo.Uptime().Seconds()),
+			"config_fingerprint": fingerprint,
+			"load_shedding":      shedder.State(),
+			"flags":              killSwitch.State(),
+		}
 
-		for name, source := range sources {
-			if err := source.TestConnection(ctx); err != nil {
-				checks[name] = "unhealthy: " + err.Error()
-			} else {
-				checks[name] = "healthy"
+		if r.URL.Query().Get("deep") == "true" {
+			health := tenants.DeepHealth(r.Context())
+			for _, checks := range health {
+				for _, status := range checks {
+					if strings.HasPrefix(status, "unhealthy") {
+						response["status"] = "degraded"
+					}
+				}
 			}
+			response["tenants"] = health
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// readyCheck reports each tenant's data sources as healthy, unhealthy,
+// initializing or not configured; status is "initializing" while a source
+// that failed at startup is being retried
+func readyCheck(tenants *tenant.Registry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := "ready"
+		if tenants.Initializing() {
+			status = "initializing"
 		}
 
 		response := map[string]interface{}{
-			"status": "ready",
-			"checks": checks,
+			"status":  status,
+			"tenants": tenants.Health(r.Context()),
 		}
 
 		w.Header().Set("Content-Type", "application/json")
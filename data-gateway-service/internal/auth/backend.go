@@ -0,0 +1,219 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Backend persists API keys shared by all gateway replicas
+type Backend interface {
+	// SaveKey stores (or replaces) a key record
+	SaveKey(ctx context.Context, key *APIKey) error
+	// DeleteKey removes a key, reporting whether it existed
+	DeleteKey(ctx context.Context, id string) (bool, error)
+	// LoadKeys returns all stored keys
+	LoadKeys(ctx context.Context) ([]*APIKey, error)
+	// TouchKey records the last time a key was used
+	TouchKey(ctx context.Context, id string, usedAt time.Time) error
+	// Publish notifies replicas that the key set changed
+	Publish(ctx context.Context) error
+	// Subscribe returns a channel signalled on every Publish
+	Subscribe(ctx context.Context) <-chan struct{}
+}
+
+const (
+	redisKeysHash      = "gateway:apikeys"
+	redisLastUsedHash  = "gateway:apikeys:last_used"
+	redisInvalidateChn = "gateway:apikeys:invalidate"
+)
+
+// RedisBackend stores key records as JSON in a Redis hash and uses pub/sub for invalidation
+type RedisBackend struct {
+	client *redis.Client
+}
+
+// NewRedisBackend creates a Redis-backed key store backend
+func NewRedisBackend(client *redis.Client) *RedisBackend {
+	return &RedisBackend{client: client}
+}
+
+// SaveKey stores a key record
+func (b *RedisBackend) SaveKey(ctx context.Context, key *APIKey) error {
+	data, err := json.Marshal(storedKey{APIKey: key, Hash: key.Hash})
+	if err != nil {
+		return err
+	}
+	return b.client.HSet(ctx, redisKeysHash, key.ID, data).Err()
+}
+
+// DeleteKey removes a key record and its usage data
+func (b *RedisBackend) DeleteKey(ctx context.Context, id string) (bool, error) {
+	removed, err := b.client.HDel(ctx, redisKeysHash, id).Result()
+	if err != nil {
+		return false, err
+	}
+	b.client.HDel(ctx, redisLastUsedHash, id)
+	return removed > 0, nil
+}
+
+// LoadKeys reads all key records along with their last-used timestamps
+func (b *RedisBackend) LoadKeys(ctx context.Context) ([]*APIKey, error) {
+	records, err := b.client.HGetAll(ctx, redisKeysHash).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	lastUsed, err := b.client.HGetAll(ctx, redisLastUsedHash).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]*APIKey, 0, len(records))
+	for id, raw := range records {
+		key, err := decodeStoredKey([]byte(raw))
+		if err != nil {
+			return nil, fmt.Errorf("corrupt api key record %s: %w", id, err)
+		}
+		if ts, ok := lastUsed[id]; ok {
+			if t, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+				key.LastUsedAt = &t
+			}
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// TouchKey records the last-used timestamp
+func (b *RedisBackend) TouchKey(ctx context.Context, id string, usedAt time.Time) error {
+	return b.client.HSet(ctx, redisLastUsedHash, id, usedAt.UTC().Format(time.RFC3339Nano)).Err()
+}
+
+// Publish broadcasts an invalidation message
+func (b *RedisBackend) Publish(ctx context.Context) error {
+	return b.client.Publish(ctx, redisInvalidateChn, "refresh").Err()
+}
+
+// Subscribe listens for invalidation messages until ctx is done
+func (b *RedisBackend) Subscribe(ctx context.Context) <-chan struct{} {
+	out := make(chan struct{}, 1)
+	pubsub := b.client.Subscribe(ctx, redisInvalidateChn)
+
+	go func() {
+		defer pubsub.Close()
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-messages:
+				if !ok {
+					return
+				}
+				select {
+				case out <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+
+	return out
+}
+
+// storedKey includes the hash, which APIKey hides from JSON responses
+type storedKey struct {
+	*APIKey
+	Hash string `json:"hash"`
+}
+
+func decodeStoredKey(data []byte) (*APIKey, error) {
+	record := storedKey{APIKey: &APIKey{}}
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+	record.APIKey.Hash = record.Hash
+	return record.APIKey, nil
+}
+
+// MemoryBackend keeps keys in process memory. It is used when Redis is not
+// configured (single replica) and in tests to simulate several replicas.
+type MemoryBackend struct {
+	mu          sync.Mutex
+	keys        map[string]*APIKey
+	subscribers []chan struct{}
+}
+
+// NewMemoryBackend creates an in-memory backend
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{keys: make(map[string]*APIKey)}
+}
+
+// SaveKey stores a key record
+func (b *MemoryBackend) SaveKey(ctx context.Context, key *APIKey) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	k := *key
+	b.keys[key.ID] = &k
+	return nil
+}
+
+// DeleteKey removes a key record
+func (b *MemoryBackend) DeleteKey(ctx context.Context, id string) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, ok := b.keys[id]
+	delete(b.keys, id)
+	return ok, nil
+}
+
+// LoadKeys returns copies of all stored keys
+func (b *MemoryBackend) LoadKeys(ctx context.Context) ([]*APIKey, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	keys := make([]*APIKey, 0, len(b.keys))
+	for _, key := range b.keys {
+		k := *key
+		keys = append(keys, &k)
+	}
+	return keys, nil
+}
+
+// TouchKey records the last-used timestamp
+func (b *MemoryBackend) TouchKey(ctx context.Context, id string, usedAt time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if key, ok := b.keys[id]; ok {
+		t := usedAt
+		key.LastUsedAt = &t
+	}
+	return nil
+}
+
+// Publish signals all subscribers
+func (b *MemoryBackend) Publish(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, ch := range b.subscribers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Subscribe registers a new subscriber
+func (b *MemoryBackend) Subscribe(ctx context.Context) <-chan struct{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ch := make(chan struct{}, 1)
+	b.subscribers = append(b.subscribers, ch)
+	return ch
+}
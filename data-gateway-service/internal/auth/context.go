@@ -0,0 +1,22 @@
+package auth
+
+import "context"
+
+type contextKey struct{}
+
+// WithKey stores the authenticated API key in the context
+func WithKey(ctx context.Context, key *APIKey) context.Context {
+	return context.WithValue(ctx, contextKey{}, key)
+}
+
+// KeyFromContext returns the authenticated API key, if any
+func KeyFromContext(ctx context.Context) (*APIKey, bool) {
+	key, ok := ctx.Value(contextKey{}).(*APIKey)
+	return key, ok && key != nil
+}
+
+// HasScope reports whether the request's API key carries the scope
+func HasScope(ctx context.Context, scope string) bool {
+	key, ok := KeyFromContext(ctx)
+	return ok && key.HasScope(scope)
+}
@@ -0,0 +1,402 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Well-known scopes
+const (
+	// ScopeAdmin grants access to the admin API and satisfies every other scope check
+	ScopeAdmin = "admin"
+	// ScopeQueryUnlimited runs raw SQL without a LIMIT as submitted, instead
+	// of applying QUERY_AUTO_LIMIT
+	ScopeQueryUnlimited = "query:unlimited"
+	// ScopeMetricsRead reads /cache/stats from outside the allowed networks
+	ScopeMetricsRead = "metrics:read"
+	// ScopeDebug sees the SQL the search and table endpoints build, with
+	// debug_sql and dry_run
+	ScopeDebug = "debug"
+)
+
+var (
+	ErrKeyNotFound     = errors.New("api key not found")
+	ErrKeyNotRevocable = errors.New("environment-configured keys cannot be revoked at runtime")
+)
+
+// APIKey describes a gateway API key. Only the SHA-256 hash of the secret is kept.
+type APIKey struct {
+	ID         string            `json:"id"`
+	Hash       string            `json:"-"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	Scopes     []string          `json:"scopes,omitempty"`
+	Tenants    []string          `json:"tenants,omitempty"`    // Allowed tenants, the first is the default
+	RateLimit  int               `json:"rate_limit,omitempty"` // Requests per second, 0 = gateway default
+	Branch     string            `json:"branch,omitempty"`     // Nessie branch or tag read by default, "" = the default branch
+	Source     string            `json:"source"`               // "env" or "store"
+	CreatedAt  time.Time         `json:"created_at"`
+	LastUsedAt *time.Time        `json:"last_used_at,omitempty"`
+}
+
+// AllowsTenant reports whether the key may act on behalf of the tenant.
+// Admin keys may select any tenant.
+func (k *APIKey) AllowsTenant(tenant string) bool {
+	if k.HasScope(ScopeAdmin) {
+		return true
+	}
+	for _, t := range k.Tenants {
+		if t == tenant {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope reports whether the key carries the scope (admin implies all scopes)
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateKeyRequest holds the attributes of a new key
+type CreateKeyRequest struct {
+	Labels    map[string]string `json:"labels,omitempty"`
+	Scopes    []string          `json:"scopes,omitempty"`
+	Tenants   []string          `json:"tenants,omitempty"`
+	RateLimit int               `json:"rate_limit,omitempty"`
+	Branch    string            `json:"branch,omitempty"`
+}
+
+// snapshot is the immutable lookup table swapped in on every refresh
+type snapshot struct {
+	byHash map[string]*APIKey
+	byID   map[string]*APIKey
+	stored []*APIKey
+}
+
+// KeyStore resolves API keys from an in-memory snapshot that combines the
+// environment bootstrap keys with keys persisted in the backend.
+type KeyStore struct {
+	backend  Backend
+	logger   *zap.Logger
+	interval time.Duration
+
+	mu      sync.Mutex // guards envKeys and snapshot swaps
+	envKeys []*APIKey
+
+	current atomic.Pointer[snapshot]
+
+	// lastUsed tracks usage in memory; touches are flushed to the backend asynchronously
+	lastUsed sync.Map // id -> time.Time
+	touches  chan string
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewKeyStore creates a key store. Env keys are always valid; adminKeys also get the admin scope.
+// backend may be nil, in which case only the environment keys are available.
+func NewKeyStore(envKeys, adminKeys []string, backend Backend, refreshInterval time.Duration, logger *zap.Logger) *KeyStore {
+	if refreshInterval <= 0 {
+		refreshInterval = 5 * time.Second
+	}
+
+	s := &KeyStore{
+		backend:  backend,
+		logger:   logger,
+		interval: refreshInterval,
+		touches:  make(chan string, 1024),
+		stop:     make(chan struct{}),
+	}
+
+	s.swap(nil)
+	s.AddEnvKeys(envKeys, nil, nil)
+	s.AddEnvKeys(adminKeys, []string{ScopeAdmin}, nil)
+	return s
+}
+
+// AddEnvKeys registers bootstrap keys with the given scopes and tenants.
+// It is meant to be called during startup, before Start.
+func (s *KeyStore) AddEnvKeys(plaintexts []string, scopes, tenants []string) {
+	s.mu.Lock()
+	now := time.Now()
+	for _, plaintext := range plaintexts {
+		if plaintext == "" {
+			continue
+		}
+		hash := HashKey(plaintext)
+		s.envKeys = append(s.envKeys, &APIKey{
+			ID:        "env-" + hash[:8],
+			Hash:      hash,
+			Scopes:    scopes,
+			Tenants:   tenants,
+			Source:    "env",
+			CreatedAt: now,
+		})
+	}
+	s.mu.Unlock()
+
+	s.swap(s.current.Load().stored)
+}
+
+// HashKey returns the hex SHA-256 hash used to store and look up keys
+func HashKey(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
+
+// swap installs a new snapshot made of env keys plus the given stored keys
+func (s *KeyStore) swap(stored []*APIKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snap := &snapshot{
+		byHash: make(map[string]*APIKey, len(s.envKeys)+len(stored)),
+		byID:   make(map[string]*APIKey, len(s.envKeys)+len(stored)),
+		stored: stored,
+	}
+	for _, key := range stored {
+		snap.byHash[key.Hash] = key
+		snap.byID[key.ID] = key
+	}
+	// Env keys win over stored keys with the same secret
+	for _, key := range s.envKeys {
+		snap.byHash[key.Hash] = key
+		snap.byID[key.ID] = key
+	}
+	s.current.Store(snap)
+}
+
+// Lookup validates a plaintext key against the current snapshot
+func (s *KeyStore) Lookup(plaintext string) (*APIKey, bool) {
+	if plaintext == "" {
+		return nil, false
+	}
+
+	key, ok := s.current.Load().byHash[HashKey(plaintext)]
+	if !ok {
+		return nil, false
+	}
+
+	s.lastUsed.Store(key.ID, time.Now())
+	if key.Source != "env" {
+		// Never block the request path on usage tracking
+		select {
+		case s.touches <- key.ID:
+		default:
+		}
+	}
+
+	return key, true
+}
+
+// Refresh reloads stored keys from the backend
+func (s *KeyStore) Refresh(ctx context.Context) error {
+	if s.backend == nil {
+		return nil
+	}
+
+	stored, err := s.backend.LoadKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load api keys: %w", err)
+	}
+
+	s.swap(stored)
+	return nil
+}
+
+// Start loads the initial snapshot and keeps it fresh via polling and invalidation messages
+func (s *KeyStore) Start(ctx context.Context) error {
+	if s.backend == nil {
+		return nil
+	}
+
+	if err := s.Refresh(ctx); err != nil {
+		return err
+	}
+
+	invalidations := s.backend.Subscribe(ctx)
+
+	s.wg.Add(2)
+	go s.refreshLoop(ctx, invalidations)
+	go s.touchLoop()
+
+	return nil
+}
+
+// refreshLoop refreshes on a fixed interval or immediately when invalidated
+func (s *KeyStore) refreshLoop(ctx context.Context, invalidations <-chan struct{}) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-invalidations:
+		}
+
+		if err := s.Refresh(ctx); err != nil {
+			s.logger.Warn("API key refresh failed, keeping previous snapshot", zap.Error(err))
+		}
+	}
+}
+
+// touchLoop persists last-used timestamps, coalescing bursts for the same key
+func (s *KeyStore) touchLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	pending := make(map[string]bool)
+	flush := func() {
+		for id := range pending {
+			if t, ok := s.lastUsed.Load(id); ok {
+				ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+				if err := s.backend.TouchKey(ctx, id, t.(time.Time)); err != nil {
+					s.logger.Debug("Failed to record api key usage", zap.String("key_id", id), zap.Error(err))
+				}
+				cancel()
+			}
+			delete(pending, id)
+		}
+	}
+
+	for {
+		select {
+		case <-s.stop:
+			flush()
+			return
+		case id := <-s.touches:
+			pending[id] = true
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// Create generates a new key, persists its hash and returns the plaintext exactly once
+func (s *KeyStore) Create(ctx context.Context, req CreateKeyRequest) (string, *APIKey, error) {
+	if s.backend == nil {
+		return "", nil, fmt.Errorf("api key store is not enabled")
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	plaintext := "gw_" + base64.RawURLEncoding.EncodeToString(secret)
+
+	idBytes := make([]byte, 8)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", nil, fmt.Errorf("failed to generate key id: %w", err)
+	}
+
+	key := &APIKey{
+		ID:        "key_" + hex.EncodeToString(idBytes),
+		Hash:      HashKey(plaintext),
+		Labels:    req.Labels,
+		Scopes:    req.Scopes,
+		Tenants:   req.Tenants,
+		RateLimit: req.RateLimit,
+		Branch:    req.Branch,
+		Source:    "store",
+		CreatedAt: time.Now().UTC(),
+	}
+
+	if err := s.backend.SaveKey(ctx, key); err != nil {
+		return "", nil, fmt.Errorf("failed to store api key: %w", err)
+	}
+	if err := s.backend.Publish(ctx); err != nil {
+		s.logger.Warn("Failed to publish api key invalidation", zap.Error(err))
+	}
+
+	// Make the key usable on this replica immediately
+	if err := s.Refresh(ctx); err != nil {
+		s.logger.Warn("API key refresh after create failed", zap.Error(err))
+	}
+
+	return plaintext, key, nil
+}
+
+// Revoke deletes a stored key and notifies other replicas
+func (s *KeyStore) Revoke(ctx context.Context, id string) error {
+	key, ok := s.current.Load().byID[id]
+	if ok && key.Source == "env" {
+		return ErrKeyNotRevocable
+	}
+	if s.backend == nil {
+		return ErrKeyNotFound
+	}
+
+	found, err := s.backend.DeleteKey(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke api key: %w", err)
+	}
+	if !found {
+		return ErrKeyNotFound
+	}
+
+	if err := s.backend.Publish(ctx); err != nil {
+		s.logger.Warn("Failed to publish api key invalidation", zap.Error(err))
+	}
+
+	return s.Refresh(ctx)
+}
+
+// List returns metadata for all known keys, sorted by creation time
+func (s *KeyStore) List(ctx context.Context) []*APIKey {
+	snap := s.current.Load()
+
+	keys := make([]*APIKey, 0, len(snap.byID))
+	for _, key := range snap.byID {
+		k := *key
+		if t, ok := s.lastUsed.Load(k.ID); ok {
+			used := t.(time.Time)
+			if k.LastUsedAt == nil || used.After(*k.LastUsedAt) {
+				k.LastUsedAt = &used
+			}
+		}
+		keys = append(keys, &k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].ID < keys[j].ID
+		}
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+
+	return keys
+}
+
+// Close stops background routines and flushes pending usage updates
+func (s *KeyStore) Close() error {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+	s.wg.Wait()
+	return nil
+}
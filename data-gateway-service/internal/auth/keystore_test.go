@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestKeyStore_EnvKeys(t *testing.T) {
+	store := NewKeyStore([]string{"env-key"}, []string{"admin-key"}, nil, 0, zap.NewNop())
+	defer store.Close()
+
+	key, ok := store.Lookup("env-key")
+	require.True(t, ok)
+	assert.Equal(t, "env", key.Source)
+	assert.False(t, key.HasScope(ScopeAdmin))
+
+	admin, ok := store.Lookup("admin-key")
+	require.True(t, ok)
+	assert.True(t, admin.HasScope(ScopeAdmin))
+	assert.True(t, admin.HasScope("anything"))
+
+	_, ok = store.Lookup("unknown")
+	assert.False(t, ok)
+
+	err := store.Revoke(context.Background(), key.ID)
+	assert.ErrorIs(t, err, ErrKeyNotRevocable)
+}
+
+func TestKeyStore_CreateAndRevokeAcrossReplicas(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	backend := NewMemoryBackend()
+	replicaA := NewKeyStore(nil, nil, backend, time.Hour, zap.NewNop())
+	replicaB := NewKeyStore(nil, nil, backend, time.Hour, zap.NewNop())
+	require.NoError(t, replicaA.Start(ctx))
+	require.NoError(t, replicaB.Start(ctx))
+	defer replicaA.Close()
+	defer replicaB.Close()
+
+	plaintext, created, err := replicaA.Create(ctx, CreateKeyRequest{
+		Labels:    map[string]string{"team": "analytics"},
+		Scopes:    []string{"read"},
+		RateLimit: 50,
+	})
+	require.NoError(t, err)
+	assert.NotEqual(t, plaintext, created.Hash)
+
+	// The creating replica sees the key immediately
+	key, ok := replicaA.Lookup(plaintext)
+	require.True(t, ok)
+	assert.Equal(t, 50, key.RateLimit)
+
+	// The other replica picks it up via invalidation, without a restart
+	assert.Eventually(t, func() bool {
+		_, ok := replicaB.Lookup(plaintext)
+		return ok
+	}, 2*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, replicaA.Revoke(ctx, created.ID))
+
+	_, ok = replicaA.Lookup(plaintext)
+	assert.False(t, ok)
+	assert.Eventually(t, func() bool {
+		_, ok := replicaB.Lookup(plaintext)
+		return !ok
+	}, 2*time.Second, 10*time.Millisecond)
+
+	assert.ErrorIs(t, replicaA.Revoke(ctx, created.ID), ErrKeyNotFound)
+}
+
+func TestKeyStore_ListTracksLastUsed(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store := NewKeyStore([]string{"env-key"}, nil, NewMemoryBackend(), time.Hour, zap.NewNop())
+	require.NoError(t, store.Start(ctx))
+	defer store.Close()
+
+	plaintext, created, err := store.Create(ctx, CreateKeyRequest{})
+	require.NoError(t, err)
+
+	for _, key := range store.List(ctx) {
+		assert.Nil(t, key.LastUsedAt)
+	}
+
+	_, ok := store.Lookup(plaintext)
+	require.True(t, ok)
+
+	keys := store.List(ctx)
+	require.Len(t, keys, 2)
+	for _, key := range keys {
+		if key.ID == created.ID {
+			require.NotNil(t, key.LastUsedAt)
+		} else {
+			assert.Nil(t, key.LastUsedAt)
+		}
+	}
+}
@@ -0,0 +1,320 @@
+// Package bqcost collects the gateway's BigQuery spend in the background
+// and exports it as Prometheus gauges, so a dashboard can show the spend
+// without anyone running INFORMATION_SCHEMA queries by hand.
+package bqcost
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/cache"
+	"go-data-gateway/internal/clients"
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/lock"
+)
+
+// TaskLabel is the value of the clients.LabelTask job label of the
+// collector's queries
+const TaskLabel = "cost_metrics"
+
+// Reporter reads the BigQuery spend reports; *clients.QueryCostEstimator in
+// production
+type Reporter interface {
+	GetMonthlyUsage(ctx context.Context) (float64, error)
+	GetCostReport(ctx context.Context, days int) (map[string]interface{}, error)
+	GetKeySpend(ctx context.Context) ([]clients.KeySpend, error)
+}
+
+// DailyCost is the spend of one day
+type DailyCost struct {
+	Date      string  `json:"date"`
+	Queries   int64   `json:"queries"`
+	GBScanned float64 `json:"gb_scanned"`
+	CostUSD   float64 `json:"cost_usd"`
+}
+
+// Report is one collection of the spend reports
+type Report struct {
+	CollectedAt      time.Time          `json:"collected_at"`
+	MonthlyGBScanned float64            `json:"monthly_gb_scanned"`
+	DailyCostUSD     float64            `json:"daily_cost_usd"` // Today so far, in UTC like BigQuery
+	TotalCostUSD     float64            `json:"total_cost_usd"` // Over the report's days
+	Days             []DailyCost        `json:"days"`           // Newest first
+	Keys             []clients.KeySpend `json:"keys"`           // This month, of the gateway's queries
+}
+
+// Status is the latest report and the health of the collector, served on
+// /api/v1/admin/bigquery/costs
+type Status struct {
+	Enabled     bool      `json:"enabled"`
+	Report      *Report   `json:"report"` // Nil until the first collection
+	Interval    string    `json:"interval"`
+	Failures    int64     `json:"failures_total"`
+	LastError   string    `json:"last_error,omitempty"`
+	LastErrorAt time.Time `json:"last_error_at,omitzero"`
+}
+
+// Collector runs the spend reports every interval and keeps the latest in
+// memory for /metrics. When clustered, the replica that acquires the
+// interval's lock runs the reports and shares the result through the cache;
+// the others load it from there at their next tick. A failed collection is
+// logged and counted and leaves the previous report in place. A nil
+// *Collector exports nothing.
+type Collector struct {
+	reporter Reporter
+	cfg      config.CostMetricsConfig
+	cache    cache.Cache
+	locks    *lock.Runner
+	logger   *zap.Logger
+	now      func() time.Time
+
+	mu          sync.Mutex
+	report      *Report
+	failures    int64
+	lastError   string
+	lastErrorAt time.Time
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// cacheKey holds the latest report shared between replicas
+var cacheKey = cache.GenerateKey("bigquery-costs")
+
+// NewCollector creates a collector of reporter's spend reports, shared
+// through c and elected by locks; either may be nil on a single replica
+func NewCollector(reporter Reporter, cfg config.CostMetricsConfig, c cache.Cache, locks *lock.Runner, logger *zap.Logger) *Collector {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 15 * time.Minute
+	}
+	if cfg.ReportDays <= 0 {
+		cfg.ReportDays = 30
+	}
+	if c == nil {
+		c = &cache.NoOpCache{}
+	}
+	collector := &Collector{
+		reporter: reporter,
+		cfg:      cfg,
+		cache:    c,
+		locks:    locks,
+		logger:   logger,
+		now:      time.Now,
+	}
+	collector.ctx, collector.cancel = context.WithCancel(context.Background())
+	return collector
+}
+
+// Start collects now and then at the start of each interval until Stop
+func (c *Collector) Start() {
+	if c == nil {
+		return
+	}
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.tick(c.now().Truncate(c.cfg.Interval))
+		for {
+			now := c.now()
+			next := now.Truncate(c.cfg.Interval).Add(c.cfg.Interval)
+			timer := time.NewTimer(next.Sub(now))
+			select {
+			case <-c.ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+				c.tick(next)
+			}
+		}
+	}()
+}
+
+// Stop ends the collection loop, cancelling a collection in progress
+func (c *Collector) Stop() {
+	if c == nil {
+		return
+	}
+	c.cancel()
+	c.wg.Wait()
+}
+
+// tick loads the report shared by the last leader, then collects the
+// interval starting at t if no report covers it yet and this replica
+// acquires its lock
+func (c *Collector) tick(t time.Time) {
+	c.load(c.ctx)
+	c.mu.Lock()
+	fresh := c.report != nil && !c.report.CollectedAt.Before(t)
+	c.mu.Unlock()
+	if fresh {
+		return
+	}
+
+	name := "bigquery-costs:" + t.UTC().Format("200601021504")
+	_, err := c.locks.Run(c.ctx, name, func(ctx context.Context, _ int64) error {
+		return c.collect(ctx)
+	})
+	if err != nil && c.ctx.Err() == nil {
+		c.fail(err)
+	}
+}
+
+// collect runs the spend reports and stores them, in memory and in the
+// cache. The reports run at most an interval, and a panic reading their rows
+// is returned as an error so it cannot end the loop.
+func (c *Collector) collect(ctx context.Context) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic collecting BigQuery costs: %v", r)
+		}
+	}()
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Interval)
+	defer cancel()
+
+	report := &Report{CollectedAt: c.now().UTC()}
+	if report.MonthlyGBScanned, err = c.reporter.GetMonthlyUsage(ctx); err != nil {
+		return err
+	}
+	costs, err := c.reporter.GetCostReport(ctx, c.cfg.ReportDays)
+	if err != nil {
+		return err
+	}
+	report.TotalCostUSD, _ = costs["total_cost_usd"].(float64)
+	report.Days = dailyCosts(costs["daily_costs"])
+	today := report.CollectedAt.Format("2006-01-02")
+	for _, day := range report.Days {
+		if day.Date == today {
+			report.DailyCostUSD = day.CostUSD
+		}
+	}
+	if report.Keys, err = c.reporter.GetKeySpend(ctx); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.report = report
+	c.mu.Unlock()
+
+	if data, err := json.Marshal(report); err == nil {
+		if err := c.cache.Set(ctx, cacheKey, data, 2*c.cfg.Interval); err != nil {
+			c.logger.Warn("Failed to share BigQuery costs through the cache", zap.Error(err))
+		}
+	}
+	c.logger.Debug("Collected BigQuery costs",
+		zap.Float64("monthly_gb_scanned", report.MonthlyGBScanned),
+		zap.Float64("daily_cost_usd", report.DailyCostUSD),
+		zap.Int("keys", len(report.Keys)))
+	return nil
+}
+
+// dailyCosts converts the daily_costs of GetCostReport
+func dailyCosts(value interface{}) []DailyCost {
+	rows, _ := value.([]map[string]interface{})
+	days := make([]DailyCost, 0, len(rows))
+	for _, row := range rows {
+		day := DailyCost{Date: fmt.Sprint(row["date"])}
+		day.Queries, _ = row["query_count"].(int64)
+		day.GBScanned, _ = row["gb_scanned"].(float64)
+		day.CostUSD, _ = row["cost_usd"].(float64)
+		days = append(days, day)
+	}
+	return days
+}
+
+// load replaces the report with a newer one shared through the cache
+func (c *Collector) load(ctx context.Context) {
+	data, err := c.cache.Get(ctx, cacheKey)
+	if err != nil {
+		if !errors.Is(err, cache.ErrCacheMiss) {
+			c.logger.Warn("Failed to load shared BigQuery costs", zap.Error(err))
+		}
+		return
+	}
+	var report Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		c.logger.Warn("Ignoring malformed shared BigQuery costs", zap.Error(err))
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.report == nil || report.CollectedAt.After(c.report.CollectedAt) {
+		c.report = &report
+	}
+}
+
+// fail records a failed collection
+func (c *Collector) fail(err error) {
+	c.logger.Error("Failed to collect BigQuery costs, keeping the previous report", zap.Error(err))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.failures++
+	c.lastError = err.Error()
+	c.lastErrorAt = c.now().UTC()
+}
+
+// Status returns the latest report and the collector's health
+func (c *Collector) Status() Status {
+	if c == nil {
+		return Status{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Status{
+		Enabled:     true,
+		Report:      c.report,
+		Interval:    c.cfg.Interval.String(),
+		Failures:    c.failures,
+		LastError:   c.lastError,
+		LastErrorAt: c.lastErrorAt,
+	}
+}
+
+// WritePrometheus writes the spend gauges of the latest report and the
+// collector's failure counter
+func (c *Collector) WritePrometheus(w io.Writer) {
+	if c == nil {
+		return
+	}
+	status := c.Status()
+
+	fmt.Fprintf(w, "# HELP gateway_bigquery_cost_collection_failures_total Failed collections of the BigQuery spend reports\n")
+	fmt.Fprintf(w, "# TYPE gateway_bigquery_cost_collection_failures_total counter\n")
+	fmt.Fprintf(w, "gateway_bigquery_cost_collection_failures_total %d\n", status.Failures)
+
+	report := status.Report
+	if report == nil {
+		return
+	}
+	fmt.Fprintf(w, "\n# HELP gateway_bigquery_cost_collected_timestamp_seconds When the BigQuery spend was last collected\n")
+	fmt.Fprintf(w, "# TYPE gateway_bigquery_cost_collected_timestamp_seconds gauge\n")
+	fmt.Fprintf(w, "gateway_bigquery_cost_collected_timestamp_seconds %d\n", report.CollectedAt.Unix())
+	fmt.Fprintf(w, "\n# HELP gateway_bigquery_monthly_gb_scanned GB billed by BigQuery queries this month\n")
+	fmt.Fprintf(w, "# TYPE gateway_bigquery_monthly_gb_scanned gauge\n")
+	fmt.Fprintf(w, "gateway_bigquery_monthly_gb_scanned %g\n", report.MonthlyGBScanned)
+	fmt.Fprintf(w, "\n# HELP gateway_bigquery_daily_cost_usd Estimated BigQuery spend today, in UTC, in USD\n")
+	fmt.Fprintf(w, "# TYPE gateway_bigquery_daily_cost_usd gauge\n")
+	fmt.Fprintf(w, "gateway_bigquery_daily_cost_usd %g\n", report.DailyCostUSD)
+	if len(report.Keys) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n# HELP gateway_bigquery_key_cost_usd Estimated BigQuery spend of the gateway's queries this month by API key, in USD\n")
+	fmt.Fprintf(w, "# TYPE gateway_bigquery_key_cost_usd gauge\n")
+	for _, key := range report.Keys {
+		fmt.Fprintf(w, "gateway_bigquery_key_cost_usd{api_key_id=%q} %g\n", key.APIKeyID, key.CostUSD)
+	}
+	fmt.Fprintf(w, "\n# HELP gateway_bigquery_key_bytes_billed Bytes billed for the gateway's queries this month by API key\n")
+	fmt.Fprintf(w, "# TYPE gateway_bigquery_key_bytes_billed gauge\n")
+	for _, key := range report.Keys {
+		fmt.Fprintf(w, "gateway_bigquery_key_bytes_billed{api_key_id=%q} %d\n", key.APIKeyID, key.BytesBilled)
+	}
+}
@@ -0,0 +1,130 @@
+package bqcost
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/cache"
+	"go-data-gateway/internal/clients"
+	"go-data-gateway/internal/config"
+)
+
+// fakeReporter returns fixed spend reports, or fails or panics when told to
+type fakeReporter struct {
+	err   error
+	panic bool
+	calls int
+}
+
+func (f *fakeReporter) GetMonthlyUsage(ctx context.Context) (float64, error) {
+	f.calls++
+	if f.panic {
+		var row []interface{}
+		_ = row[2].(int64)
+	}
+	return 1536.5, f.err
+}
+
+func (f *fakeReporter) GetCostReport(ctx context.Context, days int) (map[string]interface{}, error) {
+	return map[string]interface{}{
+		"period_days":    days,
+		"total_cost_usd": 12.5,
+		"daily_costs": []map[string]interface{}{
+			{"date": "2026-10-16", "query_count": int64(40), "gb_scanned": 820.0, "cost_usd": 4.0},
+			{"date": "2026-10-15", "query_count": int64(95), "gb_scanned": 1740.0, "cost_usd": 8.5},
+		},
+	}, nil
+}
+
+func (f *fakeReporter) GetKeySpend(ctx context.Context) ([]clients.KeySpend, error) {
+	return []clients.KeySpend{
+		{APIKeyID: "", Queries: 3, BytesBilled: 10 << 20, CostUSD: 0.0001},
+		{APIKeyID: "key-dashboard", Queries: 120, BytesBilled: 2 << 40, CostUSD: 12.5},
+	}, nil
+}
+
+var costsNow = time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)
+
+func newTestCollector(reporter Reporter, c cache.Cache) *Collector {
+	collector := NewCollector(reporter, config.CostMetricsConfig{Interval: 15 * time.Minute}, c, nil, zap.NewNop())
+	collector.now = func() time.Time { return costsNow }
+	return collector
+}
+
+func TestCollector_CollectsAndExports(t *testing.T) {
+	collector := newTestCollector(&fakeReporter{}, nil)
+	collector.tick(costsNow.Truncate(15 * time.Minute))
+
+	status := collector.Status()
+	require.NotNil(t, status.Report)
+	assert.True(t, status.Enabled)
+	assert.Equal(t, 1536.5, status.Report.MonthlyGBScanned)
+	assert.Equal(t, 4.0, status.Report.DailyCostUSD)
+	assert.Equal(t, 12.5, status.Report.TotalCostUSD)
+	assert.Equal(t, DailyCost{Date: "2026-10-15", Queries: 95, GBScanned: 1740, CostUSD: 8.5}, status.Report.Days[1])
+	assert.Len(t, status.Report.Keys, 2)
+
+	var out bytes.Buffer
+	collector.WritePrometheus(&out)
+	assert.Contains(t, out.String(), "gateway_bigquery_monthly_gb_scanned 1536.5\n")
+	assert.Contains(t, out.String(), "gateway_bigquery_daily_cost_usd 4\n")
+	assert.Contains(t, out.String(), `gateway_bigquery_key_cost_usd{api_key_id="key-dashboard"} 12.5`+"\n")
+	assert.Contains(t, out.String(), `gateway_bigquery_key_bytes_billed{api_key_id=""} 10485760`+"\n")
+	assert.Contains(t, out.String(), "gateway_bigquery_cost_collection_failures_total 0\n")
+}
+
+func TestCollector_FailuresKeepPreviousReport(t *testing.T) {
+	reporter := &fakeReporter{}
+	collector := newTestCollector(reporter, nil)
+	collector.tick(costsNow.Truncate(15 * time.Minute))
+	report := collector.Status().Report
+
+	// A failing report and a panic reading rows are counted, not fatal
+	reporter.err = errors.New("bigquery: access denied")
+	collector.tick(costsNow.Add(time.Hour))
+	reporter.err, reporter.panic = nil, true
+	collector.tick(costsNow.Add(2 * time.Hour))
+
+	status := collector.Status()
+	assert.Same(t, report, status.Report)
+	assert.EqualValues(t, 2, status.Failures)
+	assert.Contains(t, status.LastError, "panic collecting BigQuery costs")
+
+	var out bytes.Buffer
+	collector.WritePrometheus(&out)
+	assert.Contains(t, out.String(), "gateway_bigquery_cost_collection_failures_total 2\n")
+	assert.Contains(t, out.String(), "gateway_bigquery_monthly_gb_scanned 1536.5\n")
+}
+
+func TestCollector_SharesReportThroughCache(t *testing.T) {
+	shared := cache.NewMemoryCache()
+	leader := newTestCollector(&fakeReporter{}, shared)
+	leader.tick(costsNow.Truncate(15 * time.Minute))
+
+	// Another replica loads the leader's report rather than querying again
+	reporter := &fakeReporter{}
+	replica := newTestCollector(reporter, shared)
+	replica.tick(costsNow.Truncate(15 * time.Minute))
+
+	assert.Zero(t, reporter.calls)
+	require.NotNil(t, replica.Status().Report)
+	assert.Equal(t, leader.Status().Report.Keys, replica.Status().Report.Keys)
+}
+
+func TestCollector_Nil(t *testing.T) {
+	var collector *Collector
+	collector.Start()
+	collector.Stop()
+
+	var out bytes.Buffer
+	collector.WritePrometheus(&out)
+	assert.Empty(t, out.String())
+	assert.False(t, collector.Status().Enabled)
+}
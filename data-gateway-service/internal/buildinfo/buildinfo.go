@@ -0,0 +1,79 @@
+// Package buildinfo identifies the running gateway: the version, commit and
+// build time injected at link time, and how long the process has been up.
+//
+//	go build -ldflags "-X go-data-gateway/internal/buildinfo.Version=v2.3.0 \
+//	    -X go-data-gateway/internal/buildinfo.Commit=$(git rev-parse HEAD) \
+//	    -X go-data-gateway/internal/buildinfo.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// Set with -ldflags -X; a plain go build leaves them as below
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildTime = ""
+)
+
+// started approximates the process start: package variables are initialized
+// before main runs
+var started = time.Now()
+
+// Info describes the build of the running binary
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the build of the running binary. Without an injected commit,
+// the VCS revision Go stamps binaries built in a checkout with is used,
+// suffixed -dirty when the tree had local changes.
+func Get() Info {
+	info := Info{Version: Version, Commit: Commit, BuildTime: BuildTime, GoVersion: runtime.Version()}
+	if info.Commit == "" {
+		info.Commit = vcsRevision()
+	}
+	return info
+}
+
+// vcsRevision returns the commit go build stamped the binary with, or
+// unknown
+func vcsRevision() string {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	revision, modified := "", false
+	for _, setting := range bi.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	switch {
+	case revision == "":
+		return "unknown"
+	case modified:
+		return revision + "-dirty"
+	default:
+		return revision
+	}
+}
+
+// StartedAt returns when the process started
+func StartedAt() time.Time {
+	return started
+}
+
+// Uptime returns how long the process has been running
+func Uptime() time.Duration {
+	return time.Since(started)
+}
@@ -0,0 +1,36 @@
+package buildinfo
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGet_Injected(t *testing.T) {
+	defer func(version, commit, buildTime string) {
+		Version, Commit, BuildTime = version, commit, buildTime
+	}(Version, Commit, BuildTime)
+	Version, Commit, BuildTime = "v2.3.0", "0123abc", "2026-10-01T08:00:00Z"
+
+	assert.Equal(t, Info{
+		Version:   "v2.3.0",
+		Commit:    "0123abc",
+		BuildTime: "2026-10-01T08:00:00Z",
+		GoVersion: runtime.Version(),
+	}, Get())
+}
+
+func TestGet_Defaults(t *testing.T) {
+	// Test binaries carry no VCS stamp
+	info := Get()
+	assert.Equal(t, "dev", info.Version)
+	assert.Equal(t, "unknown", info.Commit)
+	assert.Empty(t, info.BuildTime)
+}
+
+func TestUptime(t *testing.T) {
+	assert.False(t, StartedAt().After(time.Now()))
+	assert.Positive(t, Uptime())
+}
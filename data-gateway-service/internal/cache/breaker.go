@@ -0,0 +1,327 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/config"
+)
+
+// Breaker states
+const (
+	BreakerDisabled = "disabled" // No breaker is configured
+	BreakerClosed   = "closed"   // The cache is used
+	BreakerOpen     = "open"     // The cache is bypassed until a probe finds it healthy
+)
+
+// Reasons a breaker opens
+const (
+	BreakerReasonLatency = "latency" // Recent p95 latency above the threshold
+	BreakerReasonErrors  = "errors"  // Recent share of failed calls above the threshold
+)
+
+// breakerSamples is the ring size of recent cache calls
+const breakerSamples = 256
+
+// breakerProbeKey is peeked by probes; a miss means the cache answered
+var breakerProbeKey = keyPrefix + "breaker-probe"
+
+// Breaker bypasses a cache that has become slow or unreliable. Cached data
+// sources report the latency and outcome of each cache call; when the recent
+// p95 latency or share of failures passes its threshold the breaker opens,
+// and lookups go straight to the sources while the cache is probed in the
+// background every cool-down until it answers within the latency threshold.
+// One breaker is shared by every source of a cache. A nil *Breaker never
+// opens.
+type Breaker struct {
+	cfg    config.CacheBreakerConfig
+	cache  Cache
+	logger *zap.Logger
+
+	mu       sync.Mutex
+	calls    [breakerSamples]breakerCall
+	next     int
+	count    int
+	open     bool
+	reason   string
+	openedAt time.Time
+	opened   int64 // Times opened
+	bypassed int64 // Lookups that skipped the cache
+	probes   int64 // Probes of the cache while open
+	now      func() time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+type breakerCall struct {
+	at      time.Time
+	latency time.Duration
+	failed  bool
+}
+
+// NewBreaker creates a closed breaker of cache
+func NewBreaker(cfg config.CacheBreakerConfig, cache Cache, logger *zap.Logger) *Breaker {
+	return &Breaker{
+		cfg:    cfg,
+		cache:  cache,
+		logger: logger,
+		now:    time.Now,
+		stop:   make(chan struct{}),
+	}
+}
+
+// Allow reports whether the cache may be used, counting a bypass when not
+func (b *Breaker) Allow() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.open {
+		b.bypassed++
+	}
+	return !b.open
+}
+
+// Closed reports whether the cache may be used, without counting a bypass
+func (b *Breaker) Closed() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return !b.open
+}
+
+// Record records a cache call that took latency and returned err; misses
+// are answers, not failures. Calls whose context ended are not judged, as
+// their caller gave up rather than the cache.
+func (b *Breaker) Record(ctx context.Context, latency time.Duration, err error) {
+	if b == nil || ctx.Err() != nil {
+		return
+	}
+	failed := err != nil && !errors.Is(err, ErrCacheMiss)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.open {
+		return
+	}
+	now := b.now()
+	b.calls[b.next] = breakerCall{at: now, latency: latency, failed: failed}
+	b.next = (b.next + 1) % breakerSamples
+	b.count = min(b.count+1, breakerSamples)
+
+	if reason := b.tripLocked(now); reason != "" {
+		b.openLocked(now, reason)
+	}
+}
+
+// tripLocked returns why the recent calls open the breaker, or "". b.mu
+// must be held.
+func (b *Breaker) tripLocked(now time.Time) string {
+	recent, failed := b.recentLocked(now)
+	n := len(recent)
+	if n == 0 || n < b.cfg.MinSamples {
+		return ""
+	}
+	if b.cfg.ErrorPercent > 0 && failed*100 >= b.cfg.ErrorPercent*n {
+		return BreakerReasonErrors
+	}
+	if b.cfg.Latency > 0 {
+		// The p95 is above the threshold when more calls are slower than it
+		// than fall after the p95 index
+		slow := 0
+		for _, latency := range recent {
+			if latency > b.cfg.Latency {
+				slow++
+			}
+		}
+		if slow >= n-p95Index(n) {
+			return BreakerReasonLatency
+		}
+	}
+	return ""
+}
+
+// recentLocked returns the latencies of the calls within the window and how
+// many of them failed. b.mu must be held.
+func (b *Breaker) recentLocked(now time.Time) ([]time.Duration, int) {
+	cutoff := now.Add(-b.cfg.Window)
+	recent := make([]time.Duration, 0, b.count)
+	failed := 0
+	for i := 0; i < b.count; i++ {
+		if call := b.calls[i]; !call.at.Before(cutoff) {
+			recent = append(recent, call.latency)
+			if call.failed {
+				failed++
+			}
+		}
+	}
+	return recent, failed
+}
+
+// p95Index is the index of the 95th percentile of n sorted samples
+func p95Index(n int) int {
+	return (n*95+99)/100 - 1
+}
+
+// openLocked opens the breaker and starts probing the cache. b.mu must be
+// held.
+func (b *Breaker) openLocked(now time.Time, reason string) {
+	recent, failed := b.recentLocked(now)
+	b.logger.Warn("Cache is slow or failing, bypassing it",
+		zap.String("reason", reason),
+		zap.Duration("p95", p95(recent)),
+		zap.Int("failed", failed),
+		zap.Int("samples", len(recent)),
+		zap.Duration("probe_every", b.cfg.CoolDown))
+
+	b.open = true
+	b.reason = reason
+	b.openedAt = now
+	b.opened++
+	b.count, b.next = 0, 0
+	go b.probe()
+}
+
+// probe checks the cache every cool-down until it answers within the
+// latency threshold, then closes the breaker
+func (b *Breaker) probe() {
+	timer := time.NewTimer(b.cfg.CoolDown)
+	defer timer.Stop()
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-timer.C:
+		}
+		if err := b.check(); err != nil {
+			b.logger.Debug("Cache probe failed, still bypassing it", zap.Error(err))
+			timer.Reset(b.cfg.CoolDown)
+			continue
+		}
+
+		b.mu.Lock()
+		b.open = false
+		b.reason = ""
+		bypassed := time.Since(b.openedAt)
+		b.mu.Unlock()
+		b.logger.Info("Cache is healthy again, using it", zap.Duration("bypassed_for", bypassed))
+		return
+	}
+}
+
+// check peeks at the probe key, which fails unless the cache answers within
+// the latency threshold
+func (b *Breaker) check() error {
+	timeout := b.cfg.Latency
+	if timeout <= 0 {
+		timeout = time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	b.mu.Lock()
+	b.probes++
+	b.mu.Unlock()
+
+	start := time.Now()
+	_, err := b.cache.Peek(ctx, breakerProbeKey)
+	if err != nil && !errors.Is(err, ErrCacheMiss) {
+		return err
+	}
+	if elapsed := time.Since(start); elapsed > timeout {
+		return fmt.Errorf("cache answered in %v", elapsed)
+	}
+	return nil
+}
+
+// Close stops probing the cache
+func (b *Breaker) Close() {
+	if b == nil {
+		return
+	}
+	b.stopOnce.Do(func() { close(b.stop) })
+}
+
+// BreakerState is the breaker's current state, reported on /cache/stats
+type BreakerState struct {
+	State         string    `json:"state"`
+	Reason        string    `json:"reason,omitempty"` // Why it is open
+	OpenedAt      time.Time `json:"opened_at,omitzero"`
+	P95Millis     int64     `json:"p95_ms"` // Of the recent calls while closed
+	ErrorPercent  int       `json:"error_percent"`
+	Samples       int       `json:"samples"`
+	OpenedTotal   int64     `json:"opened_total"`
+	BypassedTotal int64     `json:"bypassed_total"`
+	ProbesTotal   int64     `json:"probes_total"`
+}
+
+// State returns a snapshot of the breaker
+func (b *Breaker) State() BreakerState {
+	if b == nil {
+		return BreakerState{State: BreakerDisabled}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state := BreakerState{
+		State:         BreakerClosed,
+		OpenedTotal:   b.opened,
+		BypassedTotal: b.bypassed,
+		ProbesTotal:   b.probes,
+	}
+	if b.open {
+		state.State = BreakerOpen
+		state.Reason = b.reason
+		state.OpenedAt = b.openedAt
+	}
+	recent, failed := b.recentLocked(b.now())
+	state.Samples = len(recent)
+	state.P95Millis = p95(recent).Milliseconds()
+	if len(recent) > 0 {
+		state.ErrorPercent = failed * 100 / len(recent)
+	}
+	return state
+}
+
+// WritePrometheus writes the breaker gauge and counters
+func (b *Breaker) WritePrometheus(w io.Writer) {
+	if b == nil {
+		return
+	}
+	state := b.State()
+	open := 0
+	if state.State == BreakerOpen {
+		open = 1
+	}
+
+	fmt.Fprintf(w, "# HELP go_gateway_cache_breaker_open Whether the result cache is bypassed because it is slow or failing\n")
+	fmt.Fprintf(w, "# TYPE go_gateway_cache_breaker_open gauge\n")
+	fmt.Fprintf(w, "go_gateway_cache_breaker_open %d\n", open)
+	fmt.Fprintf(w, "\n# HELP go_gateway_cache_breaker_opened_total Times the result cache started being bypassed\n")
+	fmt.Fprintf(w, "# TYPE go_gateway_cache_breaker_opened_total counter\n")
+	fmt.Fprintf(w, "go_gateway_cache_breaker_opened_total %d\n", state.OpenedTotal)
+	fmt.Fprintf(w, "\n# HELP go_gateway_cache_bypassed_total Cache lookups skipped while the breaker was open\n")
+	fmt.Fprintf(w, "# TYPE go_gateway_cache_bypassed_total counter\n")
+	fmt.Fprintf(w, "go_gateway_cache_bypassed_total %d\n", state.BypassedTotal)
+}
+
+// p95 returns the 95th percentile of latencies
+func p95(latencies []time.Duration) time.Duration {
+	if len(latencies) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[p95Index(len(sorted))]
+}
@@ -0,0 +1,176 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/config"
+)
+
+// slowCache is a memory cache whose calls take delay, or fail with err when
+// it is set, like Redis during a failover
+type slowCache struct {
+	*MemoryCache
+	delay atomic.Int64
+	err   atomic.Pointer[error]
+	calls atomic.Int64
+}
+
+func newSlowCache() *slowCache {
+	return &slowCache{MemoryCache: NewMemoryCache()}
+}
+
+func (c *slowCache) wait() error {
+	c.calls.Add(1)
+	time.Sleep(time.Duration(c.delay.Load()))
+	if err := c.err.Load(); err != nil {
+		return *err
+	}
+	return nil
+}
+
+func (c *slowCache) Get(ctx context.Context, key string) ([]byte, error) {
+	if err := c.wait(); err != nil {
+		return nil, err
+	}
+	return c.MemoryCache.Get(ctx, key)
+}
+
+func (c *slowCache) Peek(ctx context.Context, key string) (*Entry, error) {
+	if err := c.wait(); err != nil {
+		return nil, err
+	}
+	return c.MemoryCache.Peek(ctx, key)
+}
+
+func (c *slowCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := c.wait(); err != nil {
+		return err
+	}
+	return c.MemoryCache.Set(ctx, key, value, ttl)
+}
+
+// testBreakerConfig trips after three slow or failed calls and probes every
+// 20ms
+var testBreakerConfig = config.CacheBreakerConfig{
+	Enabled:      true,
+	Latency:      20 * time.Millisecond,
+	ErrorPercent: 50,
+	MinSamples:   3,
+	Window:       time.Minute,
+	CoolDown:     20 * time.Millisecond,
+}
+
+func TestBreaker_BypassesSlowCache(t *testing.T) {
+	ctx := context.Background()
+	slow := newSlowCache()
+	slow.delay.Store(int64(40 * time.Millisecond))
+	breaker := NewBreaker(testBreakerConfig, slow, zap.NewNop())
+	defer breaker.Close()
+	upstream := &countingSource{value: "x"}
+	cached := NewCachedDataSource(upstream, slow, zap.NewNop())
+	cached.SetBreaker(breaker)
+
+	// A query reads the slow cache and writes its result and schema record:
+	// three slow calls trip it
+	_, err := cached.ExecuteQuery(ctx, "SELECT 1", nil)
+	require.NoError(t, err)
+	state := breaker.State()
+	assert.Equal(t, BreakerOpen, state.State)
+	assert.Equal(t, BreakerReasonLatency, state.Reason)
+	assert.Equal(t, int64(1), state.OpenedTotal)
+
+	// While open, queries go straight to the source without touching the
+	// cache, even those that it holds
+	calls := slow.calls.Load()
+	start := time.Now()
+	result, err := cached.ExecuteQuery(ctx, "SELECT 1", nil)
+	require.NoError(t, err)
+	assert.False(t, result.CacheHit)
+	assert.Less(t, time.Since(start), testBreakerConfig.Latency)
+	assert.Equal(t, 2, upstream.calls)
+	assert.Equal(t, int64(1), cached.GetMetrics().Bypassed)
+	assert.Equal(t, int64(1), breaker.State().BypassedTotal)
+
+	// The probes keep failing while the cache is slow
+	time.Sleep(3 * testBreakerConfig.CoolDown)
+	assert.Equal(t, BreakerOpen, breaker.State().State)
+	assert.Greater(t, slow.calls.Load(), calls)
+
+	// Once it is fast again a probe closes the breaker and the cache serves
+	slow.delay.Store(0)
+	require.Eventually(t, func() bool { return breaker.State().State == BreakerClosed },
+		time.Second, testBreakerConfig.CoolDown)
+	result, err = cached.ExecuteQuery(ctx, "SELECT 1", nil)
+	require.NoError(t, err)
+	assert.True(t, result.CacheHit)
+	assert.Equal(t, 2, upstream.calls)
+}
+
+func TestBreaker_OpensOnErrors(t *testing.T) {
+	ctx := context.Background()
+	failing := newSlowCache()
+	down := errors.New("connection refused")
+	failing.err.Store(&down)
+	breaker := NewBreaker(testBreakerConfig, failing, zap.NewNop())
+	defer breaker.Close()
+
+	// Misses are answers, not failures
+	for range 3 {
+		breaker.Record(ctx, time.Millisecond, ErrCacheMiss)
+	}
+	assert.Equal(t, BreakerClosed, breaker.State().State)
+
+	for range 3 {
+		breaker.Record(ctx, time.Millisecond, down)
+	}
+	state := breaker.State()
+	assert.Equal(t, BreakerOpen, state.State)
+	assert.Equal(t, BreakerReasonErrors, state.Reason)
+	assert.False(t, breaker.Allow())
+	assert.False(t, breaker.Closed())
+
+	// Calls of callers that gave up are not judged
+	fresh := NewBreaker(testBreakerConfig, failing, zap.NewNop())
+	defer fresh.Close()
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	for range 3 {
+		fresh.Record(cancelled, time.Second, context.Canceled)
+	}
+	assert.Equal(t, BreakerState{State: BreakerClosed}, fresh.State())
+}
+
+func TestBreaker_Disabled(t *testing.T) {
+	var breaker *Breaker
+	assert.True(t, breaker.Allow())
+	breaker.Record(context.Background(), time.Hour, errors.New("down"))
+	assert.Equal(t, BreakerState{State: BreakerDisabled}, breaker.State())
+
+	var out bytes.Buffer
+	breaker.WritePrometheus(&out)
+	assert.Empty(t, out.String())
+}
+
+func TestBreaker_WritePrometheus(t *testing.T) {
+	breaker := NewBreaker(testBreakerConfig, newSlowCache(), zap.NewNop())
+	defer breaker.Close()
+	for range 3 {
+		breaker.Record(context.Background(), time.Second, nil)
+	}
+	breaker.Allow()
+
+	var out bytes.Buffer
+	breaker.WritePrometheus(&out)
+	assert.Contains(t, out.String(), "go_gateway_cache_breaker_open 1\n")
+	assert.Contains(t, out.String(), "go_gateway_cache_breaker_opened_total 1\n")
+	assert.Contains(t, out.String(), "go_gateway_cache_bypassed_total 1\n")
+}
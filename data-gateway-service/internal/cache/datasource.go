@@ -0,0 +1,543 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/clients"
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/metrics"
+	"go-data-gateway/internal/numeric"
+	"go-data-gateway/internal/sqltext"
+)
+
+// Metrics tracks cache effectiveness for a single data source
+type Metrics struct {
+	Hits          int64                  `json:"hits"`
+	Misses        int64                  `json:"misses"`
+	Errors        int64                  `json:"errors"`
+	Bypassed      int64                  `json:"bypassed"`       // Lookups that skipped the cache while its breaker was open
+	WriteFailures int64                  `json:"write_failures"` // Fresh results the cache failed to store
+	HitRate       float64                `json:"hit_rate"`
+	QueryTime     metrics.LatencySummary `json:"query_time"` // Upstream latency of queries not served from cache
+}
+
+// Metadata keys added to results served from cache
+const (
+	MetaOriginalQueryTime = "original_query_time_ms" // Upstream execution time of the cached result
+	MetaCachedAt          = "cached_at"
+)
+
+// MetaCacheWrite is the metadata key of whether a fresh result was cached,
+// one of the CacheWrite values. Results served from cache do not carry it.
+const MetaCacheWrite = "cache_write"
+
+// Outcomes of caching a fresh result
+const (
+	CacheWriteOK      = "ok"
+	CacheWriteFailed  = "failed"  // The cache returned an error; other replicas may serve an older result
+	CacheWriteSkipped = "skipped" // Not attempted: the cache was skipped or bypassed, or the result is uncacheable
+)
+
+// CacheWrite returns the outcome of caching result, "" when it was served
+// from cache or its source is not cached
+func CacheWrite(result *datasource.QueryResult) string {
+	if result == nil {
+		return ""
+	}
+	outcome, _ := result.Metadata[MetaCacheWrite].(string)
+	return outcome
+}
+
+// cachedResult is the envelope stored in the cache
+type cachedResult struct {
+	Data      cachedRows                `json:"data"`
+	Count     int                       `json:"count"`
+	Source    datasource.DataSourceType `json:"source"`
+	QueryTime time.Duration             `json:"query_time_ns,omitempty"` // Upstream execution time
+	Metadata  map[string]interface{}    `json:"metadata,omitempty"`
+	CachedAt  time.Time                 `json:"cached_at"`
+}
+
+// result rebuilds a cache hit. QueryTime is the cache retrieval latency; the
+// upstream execution time and the time the entry was cached go in Metadata.
+func (e *cachedResult) result(retrieval time.Duration) *datasource.QueryResult {
+	metadata := make(map[string]interface{}, len(e.Metadata)+2)
+	for k, v := range e.Metadata {
+		metadata[k] = v
+	}
+	metadata[MetaOriginalQueryTime] = float64(e.QueryTime) / float64(time.Millisecond)
+	if !e.CachedAt.IsZero() {
+		metadata[MetaCachedAt] = e.CachedAt
+	}
+
+	return &datasource.QueryResult{
+		Data:      e.Data,
+		Count:     e.Count,
+		Source:    e.Source,
+		CacheHit:  true,
+		QueryTime: retrieval,
+		Metadata:  metadata,
+	}
+}
+
+// olderThan reports whether the entry exceeds the max age requested in opts.
+// Entries without a cached_at time are of unknown age and count as too old.
+func (e *cachedResult) olderThan(opts *datasource.QueryOptions) bool {
+	if opts == nil || opts.MaxAge <= 0 {
+		return false
+	}
+	return e.CachedAt.IsZero() || time.Since(e.CachedAt) > opts.MaxAge
+}
+
+// cachedRows are the rows of a cached result. They decode with numbers as
+// numeric.FromJSON gives them, so an int64 past 2^53 or a decimal sent as a
+// number comes back as it was stored rather than rounded to a float64.
+type cachedRows []map[string]interface{}
+
+func (r *cachedRows) UnmarshalJSON(data []byte) error {
+	var rows []map[string]interface{}
+	if err := numeric.Unmarshal(data, &rows); err != nil {
+		return err
+	}
+	*r = rows
+	return nil
+}
+
+// keyOptions holds the QueryOptions fields that change a query's result
+type keyOptions struct {
+	Limit      int                      `json:"limit,omitempty"`
+	Offset     int                      `json:"offset,omitempty"`
+	OrderBy    string                   `json:"order_by,omitempty"`
+	OrderDir   string                   `json:"order_dir,omitempty"`
+	Tiebreaker string                   `json:"tiebreaker,omitempty"`
+	Filters    map[string]interface{}   `json:"filters,omitempty"`
+	Parameters []interface{}            `json:"parameters,omitempty"`
+	Keywords   *datasource.KeywordMatch `json:"keywords,omitempty"`
+	After      *datasource.Keyset       `json:"after,omitempty"`
+	AsOf       time.Time                `json:"as_of,omitzero"`
+	AsOfBranch string                   `json:"as_of_branch,omitempty"`
+}
+
+// CachedDataSource wraps a DataSource with a read-through cache
+type CachedDataSource struct {
+	source    datasource.DataSource
+	cache     Cache
+	logger    *zap.Logger
+	namespace string
+
+	mu      sync.Mutex
+	metrics Metrics
+
+	queryTime *metrics.Histogram
+	latencies *metrics.QueryLatencies     // Shared by all sources, by name and endpoint
+	drift     *metrics.SchemaDriftCounter // Shared by all sources, by name
+	writes    *metrics.CacheWriteCounter  // Shared by all sources, by name
+	breaker   *Breaker                    // Shared by all sources of the cache
+	name      string
+}
+
+// NewCachedDataSource wraps source with cache
+func NewCachedDataSource(source datasource.DataSource, cache Cache, logger *zap.Logger) *CachedDataSource {
+	return NewNamespacedCachedDataSource(source, cache, "", logger)
+}
+
+// NewNamespacedCachedDataSource wraps source with cache, prefixing every key with
+// namespace so entries of different tenants never collide
+func NewNamespacedCachedDataSource(source datasource.DataSource, cache Cache, namespace string, logger *zap.Logger) *CachedDataSource {
+	if cache == nil {
+		cache = &NoOpCache{}
+	}
+	return &CachedDataSource{
+		source:    source,
+		cache:     cache,
+		logger:    logger,
+		namespace: namespace,
+		queryTime: metrics.NewHistogram(),
+	}
+}
+
+// SetLatencies also records the upstream latency of queries in latencies,
+// under the source name
+func (c *CachedDataSource) SetLatencies(latencies *metrics.QueryLatencies, name string) {
+	c.latencies = latencies
+	c.name = name
+}
+
+// SetSchemaDrift counts the results of this source whose schema drifted in
+// drift, under the source name set by SetLatencies
+func (c *CachedDataSource) SetSchemaDrift(drift *metrics.SchemaDriftCounter) {
+	c.drift = drift
+}
+
+// SetCacheWrites counts the failed cache writes of this source in writes,
+// under the source name set by SetLatencies
+func (c *CachedDataSource) SetCacheWrites(writes *metrics.CacheWriteCounter) {
+	c.writes = writes
+}
+
+// SetBreaker bypasses the cache while breaker is open, and reports the
+// latency and outcome of each cache call to it
+func (c *CachedDataSource) SetBreaker(breaker *Breaker) {
+	c.breaker = breaker
+}
+
+// Namespace returns the cache key namespace of this source
+func (c *CachedDataSource) Namespace() string {
+	return c.namespace
+}
+
+// Unwrap returns the underlying data source
+func (c *CachedDataSource) Unwrap() datasource.DataSource {
+	return c.source
+}
+
+// ExecuteQuery serves the query from cache or executes and caches it
+func (c *CachedDataSource) ExecuteQuery(ctx context.Context, query string, opts *datasource.QueryOptions) (*datasource.QueryResult, error) {
+	scope, normalized := c.queryScope(ctx, query)
+	key := GenerateKey(scope, c.source.GetType(), normalized, toKeyOptions(opts))
+	return c.readThrough(ctx, scope, key, "", opts, func() (*datasource.QueryResult, error) {
+		return c.source.ExecuteQuery(ctx, query, opts)
+	})
+}
+
+// GetData serves the table read from cache or executes and caches it
+func (c *CachedDataSource) GetData(ctx context.Context, table string, opts *datasource.QueryOptions) (*datasource.QueryResult, error) {
+	return c.readThrough(ctx, c.scope(ctx, "table", table), c.tableKey(ctx, table, opts), table, opts, func() (*datasource.QueryResult, error) {
+		return c.source.GetData(ctx, table, opts)
+	})
+}
+
+// PlanQuery plans the query on the underlying source, with the key its
+// result would be cached under
+func (c *CachedDataSource) PlanQuery(ctx context.Context, query string, opts *datasource.QueryOptions) (*datasource.QueryPlan, error) {
+	plan, err := datasource.PlanQuery(ctx, c.source, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	return withCacheKey(plan, c.queryKey(ctx, query, opts), opts), nil
+}
+
+// PlanTable plans the table read on the underlying source, with the key its
+// result would be cached under
+func (c *CachedDataSource) PlanTable(ctx context.Context, table string, opts *datasource.QueryOptions) (*datasource.QueryPlan, error) {
+	plan, err := datasource.PlanTable(ctx, c.source, table, opts)
+	if err != nil {
+		return nil, err
+	}
+	return withCacheKey(plan, c.tableKey(ctx, table, opts), opts), nil
+}
+
+// withCacheKey sets key on a copy of plan unless opts skip the cache
+func withCacheKey(plan *datasource.QueryPlan, key string, opts *datasource.QueryOptions) *datasource.QueryPlan {
+	if opts != nil && opts.SkipCache {
+		return plan
+	}
+	keyed := *plan
+	keyed.CacheKey = key
+	return &keyed
+}
+
+// queryKey keys query by its normalized text, so queries that differ only
+// in comments and layout share their entries
+func (c *CachedDataSource) queryKey(ctx context.Context, query string, opts *datasource.QueryOptions) string {
+	scope, normalized := c.queryScope(ctx, query)
+	return GenerateKey(scope, c.source.GetType(), normalized, toKeyOptions(opts))
+}
+
+// queryScope returns the scope of query and its normalized text. The entries
+// and schema record of a query share the scope of its normalized text, so a
+// schema drift drops them whatever the comments and layout of the query.
+func (c *CachedDataSource) queryScope(ctx context.Context, query string) (scope, normalized string) {
+	normalized = sqltext.Normalize(query, c.source.GetType().Dialect())
+	return c.scope(ctx, "query", normalized), normalized
+}
+
+func (c *CachedDataSource) tableKey(ctx context.Context, table string, opts *datasource.QueryOptions) string {
+	return GenerateKey(c.scope(ctx, "table", table), c.source.GetType(), table, toKeyOptions(opts))
+}
+
+// scope returns the key prefix shared by every page of a query or table
+// read, so the entries of a result set can be dropped together when its
+// schema drifts. Reads at another Nessie reference than the default branch
+// have a scope of their own, as their rows and schema may differ.
+func (c *CachedDataSource) scope(ctx context.Context, kind, target string) string {
+	id := string(c.source.GetType()) + "\x00" + target
+	if version := datasource.VersionCacheKey(ctx); version != "" {
+		id += "\x00" + version
+	}
+	sum := sha256.Sum256([]byte(id))
+	return c.keyPrefix(kind) + ":" + hex.EncodeToString(sum[:8])
+}
+
+// readThrough serves key, under the prefix scope, from cache or fetches and
+// caches it for the TTL the active policy gives table, empty for a query, and
+// the requested TTL. Fresh results carry their schema fingerprint and the
+// outcome of caching them; results whose rows mix value types in a column
+// are not cached, nor are empty ones when opts skip them. While the cache
+// breaker is open the cache is neither read nor written.
+func (c *CachedDataSource) readThrough(ctx context.Context, scope, key, table string, opts *datasource.QueryOptions, fetch func() (*datasource.QueryResult, error)) (*datasource.QueryResult, error) {
+	bypass := !c.breaker.Allow()
+	if bypass {
+		c.recordBypass()
+	}
+	if bypass || (opts != nil && opts.SkipCache) {
+		start := time.Now()
+		result, err := fetch()
+		if err != nil {
+			return nil, err
+		}
+		c.observeQueryTime(ctx, time.Since(start))
+		return withMetadata(result, MetaCacheWrite, CacheWriteSkipped), nil
+	}
+
+	lookup := time.Now()
+	data, err := c.cache.Get(ctx, key)
+	c.breaker.Record(ctx, time.Since(lookup), err)
+	switch {
+	case err == nil:
+		var entry cachedResult
+		jsonErr := json.Unmarshal(data, &entry)
+		switch {
+		case jsonErr != nil:
+			c.logger.Warn("Discarding undecodable cache entry", zap.String("key", key))
+		case entry.olderThan(opts):
+			c.logger.Debug("Cache entry older than the requested max age, refreshing",
+				zap.String("key", key),
+				zap.Time("cached_at", entry.CachedAt))
+		default:
+			c.recordHit()
+			return entry.result(time.Since(lookup)), nil
+		}
+	case !errors.Is(err, ErrCacheMiss):
+		c.recordError()
+		c.logger.Warn("Cache read failed, querying source", zap.Error(err))
+	}
+
+	start := time.Now()
+	result, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+	elapsed := time.Since(start)
+	c.recordMiss(ctx, elapsed)
+	if opts != nil && opts.SkipCacheEmpty && len(result.Data) == 0 {
+		return withMetadata(result, MetaCacheWrite, CacheWriteSkipped), nil
+	}
+
+	schema := datasource.ResultSchema(result.Data)
+	if mixed := datasource.MixedColumns(schema); len(mixed) > 0 {
+		c.drift.Record(c.name, metrics.DriftMixed)
+		c.logger.Warn("Not caching a result with mixed-type columns",
+			zap.String("source", c.name),
+			zap.String("key", key),
+			zap.Strings("columns", mixed))
+		return withMetadata(result, MetaCacheWrite, CacheWriteSkipped), nil
+	}
+	fingerprint := datasource.Fingerprint(schema)
+	if fingerprint != "" {
+		result = withMetadata(result, datasource.MetaSchemaFingerprint, fingerprint)
+	}
+
+	var requested time.Duration
+	if opts != nil {
+		requested = opts.CacheTTL
+	}
+	ttl := config.ActivePolicy().Cache.TTL(table, requested)
+	if fingerprint != "" {
+		c.checkSchema(ctx, scope, schema, fingerprint, ttl)
+	}
+
+	// Sources that do not time themselves are credited with the fetch time
+	queryTime := result.QueryTime
+	if queryTime <= 0 {
+		queryTime = elapsed
+	}
+	encoded, err := json.Marshal(cachedResult{
+		Data:      result.Data,
+		Count:     result.Count,
+		Source:    result.Source,
+		QueryTime: queryTime,
+		Metadata:  result.Metadata,
+		CachedAt:  time.Now().UTC(),
+	})
+	// The breaker counts failed writes towards opening, like failed reads
+	outcome := CacheWriteSkipped
+	if err == nil && c.breaker.Closed() {
+		write := time.Now()
+		err = c.cache.Set(ctx, key, encoded, ttl)
+		c.breaker.Record(ctx, time.Since(write), err)
+		outcome = CacheWriteOK
+	}
+	if err != nil {
+		outcome = CacheWriteFailed
+		c.recordWriteError()
+		c.logger.Warn("Cache write failed",
+			zap.String("source", c.name),
+			zap.String("key", key),
+			zap.Error(err))
+	}
+
+	return withMetadata(result, MetaCacheWrite, outcome), nil
+}
+
+// withMetadata returns a copy of result with value under key in its metadata
+func withMetadata(result *datasource.QueryResult, key string, value interface{}) *datasource.QueryResult {
+	stamped := *result
+	stamped.Metadata = make(map[string]interface{}, len(result.Metadata)+1)
+	for k, v := range result.Metadata {
+		stamped.Metadata[k] = v
+	}
+	stamped.Metadata[key] = value
+	return &stamped
+}
+
+func (c *CachedDataSource) keyPrefix(kind string) string {
+	if c.namespace == "" {
+		return kind
+	}
+	return c.namespace + ":" + kind
+}
+
+func toKeyOptions(opts *datasource.QueryOptions) keyOptions {
+	if opts == nil {
+		return keyOptions{}
+	}
+	return keyOptions{
+		Limit:      opts.Limit,
+		Offset:     opts.Offset,
+		OrderBy:    opts.OrderBy,
+		OrderDir:   opts.OrderDir,
+		Tiebreaker: opts.Tiebreaker,
+		Filters:    opts.Filters,
+		Parameters: opts.Parameters,
+		Keywords:   opts.Keywords,
+		After:      opts.After,
+		AsOf:       opts.AsOf,
+		AsOfBranch: opts.AsOfBranch,
+	}
+}
+
+func (c *CachedDataSource) recordHit() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.metrics.Hits++
+}
+
+func (c *CachedDataSource) recordMiss(ctx context.Context, queryTime time.Duration) {
+	c.mu.Lock()
+	c.metrics.Misses++
+	c.mu.Unlock()
+	c.observeQueryTime(ctx, queryTime)
+}
+
+func (c *CachedDataSource) observeQueryTime(ctx context.Context, queryTime time.Duration) {
+	c.queryTime.Observe(queryTime)
+	c.latencies.Observe(ctx, c.name, queryTime)
+}
+
+func (c *CachedDataSource) recordBypass() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.metrics.Bypassed++
+}
+
+func (c *CachedDataSource) recordError() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.metrics.Errors++
+}
+
+// recordWriteError counts a failed write among the errors, and separately so
+// callers can tell stale reads on other replicas are possible
+func (c *CachedDataSource) recordWriteError() {
+	c.mu.Lock()
+	c.metrics.Errors++
+	c.metrics.WriteFailures++
+	c.mu.Unlock()
+	c.writes.Record(c.name)
+}
+
+// GetMetrics returns a snapshot of the cache metrics
+func (c *CachedDataSource) GetMetrics() Metrics {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	m := c.metrics
+	m.HitRate = hitRate(m.Hits, m.Misses)
+	m.QueryTime = c.queryTime.Summary()
+	return m
+}
+
+// ResetLatency drops the recorded query times, keeping the hit counts
+func (c *CachedDataSource) ResetLatency() {
+	c.queryTime.Reset()
+}
+
+// ValidateQuery validates against the underlying source, bypassing the cache
+func (c *CachedDataSource) ValidateQuery(ctx context.Context, query string) error {
+	return datasource.ValidateQuery(ctx, c.source, query)
+}
+
+// DescribeTable describes the table on the underlying source
+func (c *CachedDataSource) DescribeTable(ctx context.Context, table string) ([]datasource.ColumnField, error) {
+	return datasource.DescribeTable(ctx, c.source, table)
+}
+
+// TableSize reports the size of the table on the underlying source
+func (c *CachedDataSource) TableSize(ctx context.Context, table string) (*datasource.TableStats, error) {
+	return datasource.TableSize(ctx, c.source, table)
+}
+
+// TestConnection checks the underlying source
+func (c *CachedDataSource) TestConnection(ctx context.Context) error {
+	return c.source.TestConnection(ctx)
+}
+
+// DeepCheck runs the underlying source's query-based check
+func (c *CachedDataSource) DeepCheck(ctx context.Context) error {
+	return datasource.DeepCheck(ctx, c.source)
+}
+
+// GetPoolMetrics returns the underlying source's pool metrics
+func (c *CachedDataSource) GetPoolMetrics() map[string]interface{} {
+	return datasource.PoolMetrics(c.source)
+}
+
+// ResetPoolLatency resets the underlying source's pool
+func (c *CachedDataSource) ResetPoolLatency() {
+	datasource.ResetPoolLatency(c.source)
+}
+
+// RotateCredentials rotates the Dremio credentials of the underlying source;
+// cached results stay valid
+func (c *CachedDataSource) RotateCredentials(ctx context.Context, account *clients.Credentials, creds clients.DremioCredentials) error {
+	ok, err := datasource.RotateCredentials(ctx, c.source, account, creds)
+	if !ok {
+		return datasource.ErrNoCredentials
+	}
+	return err
+}
+
+// Capacity returns the underlying source's concurrency hint
+func (c *CachedDataSource) Capacity(ctx context.Context) int {
+	return datasource.Capacity(ctx, c.source)
+}
+
+// GetType returns the underlying source type
+func (c *CachedDataSource) GetType() datasource.DataSourceType {
+	return c.source.GetType()
+}
+
+// Close closes the underlying source; the shared cache is closed by its owner
+func (c *CachedDataSource) Close() error {
+	return c.source.Close()
+}
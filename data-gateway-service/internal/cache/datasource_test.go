@@ -0,0 +1,711 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/metrics"
+)
+
+// countingSource returns a fixed row and counts upstream calls
+type countingSource struct {
+	value     string
+	calls     int
+	queryTime time.Duration
+	delay     time.Duration
+	metadata  map[string]interface{}
+}
+
+func (s *countingSource) ExecuteQuery(ctx context.Context, query string, opts *datasource.QueryOptions) (*datasource.QueryResult, error) {
+	s.calls++
+	time.Sleep(s.delay)
+	return &datasource.QueryResult{
+		Data:      []map[string]interface{}{{"value": s.value}},
+		Count:     1,
+		Source:    datasource.DataSourceDremio,
+		QueryTime: s.queryTime,
+		Metadata:  s.metadata,
+	}, nil
+}
+
+func (s *countingSource) GetData(ctx context.Context, table string, opts *datasource.QueryOptions) (*datasource.QueryResult, error) {
+	return s.ExecuteQuery(ctx, "SELECT * FROM "+table, opts)
+}
+
+func (s *countingSource) TestConnection(ctx context.Context) error { return nil }
+
+func (s *countingSource) GetType() datasource.DataSourceType { return datasource.DataSourceDremio }
+
+func (s *countingSource) Close() error { return nil }
+
+func TestCachedDataSource_ReadThrough(t *testing.T) {
+	ctx := context.Background()
+	upstream := &countingSource{value: "x"}
+	cached := NewCachedDataSource(upstream, NewMemoryCache(), zap.NewNop())
+
+	first, err := cached.ExecuteQuery(ctx, "SELECT 1", nil)
+	require.NoError(t, err)
+	assert.False(t, first.CacheHit)
+
+	second, err := cached.ExecuteQuery(ctx, "SELECT 1", nil)
+	require.NoError(t, err)
+	assert.True(t, second.CacheHit)
+	assert.Equal(t, first.Data, second.Data)
+	assert.Equal(t, 1, upstream.calls)
+
+	// Different pagination is a different entry
+	_, err = cached.ExecuteQuery(ctx, "SELECT 1", &datasource.QueryOptions{Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 2, upstream.calls)
+
+	metrics := cached.GetMetrics()
+	assert.Equal(t, int64(1), metrics.Hits)
+	assert.Equal(t, int64(2), metrics.Misses)
+}
+
+func TestCachedDataSource_KeysIgnoreComments(t *testing.T) {
+	ctx := context.Background()
+	upstream := &countingSource{value: "x"}
+	cached := NewCachedDataSource(upstream, NewMemoryCache(), zap.NewNop())
+
+	_, err := cached.ExecuteQuery(ctx, "-- dashboard xyz\nSELECT *\nFROM t", nil)
+	require.NoError(t, err)
+	hit, err := cached.ExecuteQuery(ctx, "/* dashboard abc */ SELECT * FROM t", nil)
+	require.NoError(t, err)
+	assert.True(t, hit.CacheHit)
+
+	// Comment markers inside literals are part of the query
+	other, err := cached.ExecuteQuery(ctx, "SELECT * FROM t WHERE note = '-- x'", nil)
+	require.NoError(t, err)
+	assert.False(t, other.CacheHit)
+	assert.Equal(t, 2, upstream.calls)
+}
+
+func TestCachedDataSource_QueryTimePercentiles(t *testing.T) {
+	upstream := &countingSource{value: "x", delay: 20 * time.Millisecond}
+	cached := NewCachedDataSource(upstream, NewMemoryCache(), zap.NewNop())
+	latencies := metrics.NewQueryLatencies()
+	cached.SetLatencies(latencies, "DATAWAREHOUSE")
+	ctx := metrics.WithEndpoint(context.Background(), func() string { return "/api/v1/tender" })
+
+	for _, query := range []string{"SELECT 1", "SELECT 2", "SELECT 1"} {
+		_, err := cached.ExecuteQuery(ctx, query, nil)
+		require.NoError(t, err)
+	}
+	_, err := cached.ExecuteQuery(ctx, "SELECT 3", &datasource.QueryOptions{SkipCache: true})
+	require.NoError(t, err)
+
+	// Hits are served from cache and are not upstream latency
+	queryTime := cached.GetMetrics().QueryTime
+	assert.Equal(t, int64(3), queryTime.Count)
+	assert.GreaterOrEqual(t, queryTime.P50, 19.0, "within the bucket of 20ms")
+	assert.Equal(t, int64(3), latencies.Summaries()["DATAWAREHOUSE"]["/api/v1/tender"].Count)
+
+	cached.ResetLatency()
+	assert.Equal(t, int64(0), cached.GetMetrics().QueryTime.Count)
+	assert.Equal(t, int64(1), cached.GetMetrics().Hits, "a latency reset keeps the hit counts")
+}
+
+func TestCachedDataSource_SkipCache(t *testing.T) {
+	ctx := context.Background()
+	upstream := &countingSource{value: "x"}
+	cached := NewCachedDataSource(upstream, NewMemoryCache(), zap.NewNop())
+
+	_, err := cached.ExecuteQuery(ctx, "SELECT 1", nil)
+	require.NoError(t, err)
+
+	upstream.value = "y"
+	fresh, err := cached.ExecuteQuery(ctx, "SELECT 1", &datasource.QueryOptions{SkipCache: true})
+	require.NoError(t, err)
+	assert.False(t, fresh.CacheHit)
+	assert.Equal(t, "y", fresh.Data[0]["value"])
+	assert.Equal(t, 2, upstream.calls)
+
+	// The skipped read leaves the cached entry as it was
+	again, err := cached.ExecuteQuery(ctx, "SELECT 1", nil)
+	require.NoError(t, err)
+	assert.True(t, again.CacheHit)
+	assert.Equal(t, "x", again.Data[0]["value"])
+}
+
+func TestCachedDataSource_MaxAge(t *testing.T) {
+	ctx := context.Background()
+	upstream := &countingSource{value: "new"}
+	store := NewMemoryCache()
+	cached := NewCachedDataSource(upstream, store, zap.NewNop())
+
+	// Uncached: executed whatever the max age, and cached with its time
+	first, err := cached.ExecuteQuery(ctx, "SELECT 1", &datasource.QueryOptions{MaxAge: time.Minute})
+	require.NoError(t, err)
+	assert.False(t, first.CacheHit)
+	assert.Equal(t, 1, upstream.calls)
+
+	// Fresh enough: served from cache
+	hit, err := cached.ExecuteQuery(ctx, "SELECT 1", &datasource.QueryOptions{MaxAge: time.Minute})
+	require.NoError(t, err)
+	assert.True(t, hit.CacheHit)
+	assert.Equal(t, 1, upstream.calls)
+
+	// Too old: an entry cached ten minutes ago is re-executed and replaced
+	key := cached.queryKey(ctx, "SELECT 1", nil)
+	stale, err := json.Marshal(cachedResult{
+		Data:     []map[string]interface{}{{"value": "old"}},
+		Count:    1,
+		CachedAt: time.Now().UTC().Add(-10 * time.Minute),
+	})
+	require.NoError(t, err)
+	require.NoError(t, store.Set(ctx, key, stale, time.Hour))
+
+	old, err := cached.ExecuteQuery(ctx, "SELECT 1", nil)
+	require.NoError(t, err)
+	assert.True(t, old.CacheHit, "without a max age any entry is served")
+	assert.Equal(t, "old", old.Data[0]["value"])
+
+	refreshed, err := cached.ExecuteQuery(ctx, "SELECT 1", &datasource.QueryOptions{MaxAge: time.Minute})
+	require.NoError(t, err)
+	assert.False(t, refreshed.CacheHit)
+	assert.Equal(t, "new", refreshed.Data[0]["value"])
+	assert.Equal(t, 2, upstream.calls)
+
+	again, err := cached.ExecuteQuery(ctx, "SELECT 1", &datasource.QueryOptions{MaxAge: time.Minute})
+	require.NoError(t, err)
+	assert.True(t, again.CacheHit)
+	assert.Equal(t, "new", again.Data[0]["value"])
+	assert.Less(t, time.Since(again.Metadata[MetaCachedAt].(time.Time)), time.Minute)
+	assert.Equal(t, 2, upstream.calls)
+
+	metrics := cached.GetMetrics()
+	assert.Equal(t, int64(3), metrics.Hits)
+	assert.Equal(t, int64(2), metrics.Misses)
+}
+
+func TestCachedDataSource_KeysByNessieReference(t *testing.T) {
+	nessie := config.NessieConfig{Source: "nessie_iceberg", Branches: []string{"main", "staging"}}
+	at := func(name string) context.Context {
+		ref, err := datasource.ResolveVersion(nessie, name)
+		require.NoError(t, err)
+		return datasource.WithVersion(context.Background(), ref)
+	}
+	upstream := &countingSource{value: "main"}
+	cached := NewCachedDataSource(upstream, NewMemoryCache(), zap.NewNop())
+
+	_, err := cached.GetData(at("main"), "tender_data", nil)
+	require.NoError(t, err)
+
+	// Staging rows are cached apart from main's
+	upstream.value = "staging"
+	staging, err := cached.GetData(at("staging"), "tender_data", nil)
+	require.NoError(t, err)
+	assert.False(t, staging.CacheHit)
+	assert.Equal(t, "staging", staging.Data[0]["value"])
+
+	onMain, err := cached.GetData(at("main"), "tender_data", nil)
+	require.NoError(t, err)
+	assert.True(t, onMain.CacheHit)
+	assert.Equal(t, "main", onMain.Data[0]["value"])
+	assert.Equal(t, 2, upstream.calls)
+
+	// The default branch keeps the keys of requests that select none
+	assert.Equal(t, cached.tableKey(context.Background(), "tender_data", nil), cached.tableKey(at(""), "tender_data", nil))
+	assert.NotEqual(t, cached.tableKey(at("main"), "tender_data", nil), cached.tableKey(at("staging"), "tender_data", nil))
+}
+
+func TestCachedDataSource_KeysByAsOf(t *testing.T) {
+	cached := NewCachedDataSource(&countingSource{}, NewMemoryCache(), zap.NewNop())
+	ctx := context.Background()
+	june := &datasource.QueryOptions{AsOf: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)}
+	may := &datasource.QueryOptions{AsOf: time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)}
+
+	assert.NotEqual(t, cached.tableKey(ctx, "tender_data", nil), cached.tableKey(ctx, "tender_data", june))
+	assert.NotEqual(t, cached.tableKey(ctx, "tender_data", may), cached.tableKey(ctx, "tender_data", june))
+	assert.NotEqual(t, cached.tableKey(ctx, "tender_data", june),
+		cached.tableKey(ctx, "tender_data", &datasource.QueryOptions{AsOf: june.AsOf, AsOfBranch: "staging"}))
+}
+
+func TestCachedDataSource_HitKeepsResultFields(t *testing.T) {
+	ctx := context.Background()
+	upstream := &countingSource{
+		value:     "x",
+		queryTime: 1500 * time.Millisecond,
+		metadata:  map[string]interface{}{"job_id": "job-1", "bytes_processed": float64(2048)},
+	}
+	cached := NewCachedDataSource(upstream, NewMemoryCache(), zap.NewNop())
+
+	before := time.Now().UTC()
+	first, err := cached.GetData(ctx, "tender_data", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1500*time.Millisecond, first.QueryTime)
+
+	hit, err := cached.GetData(ctx, "tender_data", nil)
+	require.NoError(t, err)
+	require.True(t, hit.CacheHit)
+	assert.Equal(t, first.Data, hit.Data)
+	assert.Equal(t, first.Count, hit.Count)
+	assert.Equal(t, first.Source, hit.Source)
+
+	// QueryTime is the cache latency; the upstream time moves to metadata
+	assert.True(t, hit.QueryTime < time.Second)
+	assert.Equal(t, "job-1", hit.Metadata["job_id"])
+	assert.Equal(t, float64(2048), hit.Metadata["bytes_processed"])
+	assert.Equal(t, float64(1500), hit.Metadata[MetaOriginalQueryTime])
+
+	cachedAt, ok := hit.Metadata[MetaCachedAt].(time.Time)
+	require.True(t, ok)
+	assert.False(t, cachedAt.Before(before.Truncate(time.Second)))
+
+	// The upstream result's metadata is not modified by the hit
+	assert.NotContains(t, upstream.metadata, MetaCachedAt)
+}
+
+func TestCachedDataSource_HitTimesUntimedSource(t *testing.T) {
+	ctx := context.Background()
+	cached := NewCachedDataSource(&countingSource{value: "x"}, NewMemoryCache(), zap.NewNop())
+
+	_, err := cached.ExecuteQuery(ctx, "SELECT 1", nil)
+	require.NoError(t, err)
+	hit, err := cached.ExecuteQuery(ctx, "SELECT 1", nil)
+	require.NoError(t, err)
+
+	// A source without its own timing is credited with the fetch time
+	original, ok := hit.Metadata[MetaOriginalQueryTime].(float64)
+	require.True(t, ok)
+	assert.True(t, original > 0)
+	assert.Contains(t, hit.Metadata, MetaCachedAt)
+}
+
+func TestCachedDataSource_NamespaceIsolation(t *testing.T) {
+	ctx := context.Background()
+	shared := NewMemoryCache()
+
+	upstreamA := &countingSource{value: "tenant-a"}
+	upstreamB := &countingSource{value: "tenant-b"}
+	tenantA := NewNamespacedCachedDataSource(upstreamA, shared, "a", zap.NewNop())
+	tenantB := NewNamespacedCachedDataSource(upstreamB, shared, "b", zap.NewNop())
+
+	query := "SELECT * FROM tender_data"
+
+	resultA, err := tenantA.ExecuteQuery(ctx, query, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "tenant-a", resultA.Data[0]["value"])
+
+	// Same query from the other tenant must not be served A's entry
+	resultB, err := tenantB.ExecuteQuery(ctx, query, nil)
+	require.NoError(t, err)
+	assert.False(t, resultB.CacheHit)
+	assert.Equal(t, "tenant-b", resultB.Data[0]["value"])
+
+	// Each tenant now hits its own entry
+	resultA, err = tenantA.ExecuteQuery(ctx, query, nil)
+	require.NoError(t, err)
+	assert.True(t, resultA.CacheHit)
+	assert.Equal(t, "tenant-a", resultA.Data[0]["value"])
+
+	// Flushing one namespace leaves the other intact
+	require.NoError(t, shared.DeletePattern(ctx, keyPrefix+"a:*"))
+
+	resultB, err = tenantB.ExecuteQuery(ctx, query, nil)
+	require.NoError(t, err)
+	assert.True(t, resultB.CacheHit)
+
+	resultA, err = tenantA.ExecuteQuery(ctx, query, nil)
+	require.NoError(t, err)
+	assert.False(t, resultA.CacheHit)
+
+	assert.Equal(t, 2, upstreamA.calls)
+	assert.Equal(t, 1, upstreamB.calls)
+}
+
+func TestGenerateKey_HidesQuery(t *testing.T) {
+	key := GenerateKey("query", "SELECT secret FROM t")
+	assert.NotContains(t, key, "secret")
+	assert.Equal(t, key, GenerateKey("query", "SELECT secret FROM t"))
+	assert.NotEqual(t, key, GenerateKey("query", "SELECT other FROM t"))
+}
+
+// keysCache records the keys written to it
+type keysCache struct {
+	*MemoryCache
+	keys []string
+}
+
+func (c *keysCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.keys = append(c.keys, key)
+	return c.MemoryCache.Set(ctx, key, value, ttl)
+}
+
+func TestCachedDataSource_KeysHideQuery(t *testing.T) {
+	ctx := datasource.WithVersion(context.Background(), datasource.VersionRef{Source: "nessie_iceberg", Type: "BRANCH", Name: "secret_branch", Default: "main"})
+	store := &keysCache{MemoryCache: NewMemoryCache()}
+	cached := NewNamespacedCachedDataSource(&countingSource{value: "x"}, store, "lkpp", zap.NewNop())
+
+	_, err := cached.ExecuteQuery(ctx, "SELECT secret_column FROM secret_table", nil)
+	require.NoError(t, err)
+	_, err = cached.GetData(ctx, "secret_schema.secret_table", &datasource.QueryOptions{Filters: map[string]interface{}{"secret_filter": "x"}})
+	require.NoError(t, err)
+
+	// Result and schema keys name only the namespace and kind
+	require.Len(t, store.keys, 4)
+	for _, key := range store.keys {
+		assert.NotContains(t, key, "secret")
+		assert.True(t, strings.HasPrefix(key, keyPrefix+"lkpp:"), key)
+	}
+}
+
+// ttlCache records the TTL of each write of a result
+type ttlCache struct {
+	*MemoryCache
+	ttls []time.Duration
+}
+
+func (c *ttlCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if !strings.HasSuffix(key, ":schema") {
+		c.ttls = append(c.ttls, ttl)
+	}
+	return c.MemoryCache.Set(ctx, key, value, ttl)
+}
+
+func TestCachedDataSource_PolicyTTL(t *testing.T) {
+	config.SetPolicy(&config.Policy{
+		Security: config.GetDefaultSecurityConfig(),
+		Cache: config.CacheTTLPolicy{
+			DefaultTTL: time.Minute,
+			MaxTTL:     time.Hour,
+			Tables:     map[string]time.Duration{"nessie_iceberg.tender_data": 10 * time.Second},
+		},
+	})
+	defer config.SetPolicy(nil)
+
+	ctx := context.Background()
+	store := &ttlCache{MemoryCache: NewMemoryCache()}
+	cached := NewCachedDataSource(&countingSource{value: "x"}, store, zap.NewNop())
+
+	_, err := cached.ExecuteQuery(ctx, "SELECT 1", nil)
+	require.NoError(t, err)
+	_, err = cached.ExecuteQuery(ctx, "SELECT 2", &datasource.QueryOptions{CacheTTL: 5 * time.Minute})
+	require.NoError(t, err)
+	_, err = cached.ExecuteQuery(ctx, "SELECT 3", &datasource.QueryOptions{CacheTTL: 24 * time.Hour})
+	require.NoError(t, err)
+	_, err = cached.GetData(ctx, "nessie_iceberg.tender_data", &datasource.QueryOptions{CacheTTL: 5 * time.Minute})
+	require.NoError(t, err)
+
+	assert.Equal(t, []time.Duration{time.Minute, 5 * time.Minute, time.Hour, 10 * time.Second}, store.ttls)
+}
+
+func TestInspect(t *testing.T) {
+	ctx := context.Background()
+	memory := NewMemoryCache()
+	cached := NewCachedDataSource(&countingSource{value: "x"}, memory, zap.NewNop())
+	plan, err := cached.PlanQuery(ctx, "SELECT 1", nil)
+	require.NoError(t, err)
+
+	inspection, err := Inspect(ctx, memory, plan.CacheKey, nil, 10)
+	require.NoError(t, err)
+	assert.Equal(t, Inspection{Key: plan.CacheKey}, inspection)
+
+	_, err = cached.ExecuteQuery(ctx, "SELECT 1", &datasource.QueryOptions{CacheTTL: time.Minute})
+	require.NoError(t, err)
+	inspection, err = Inspect(ctx, memory, plan.CacheKey, nil, 10)
+	require.NoError(t, err)
+	assert.True(t, inspection.Exists)
+	assert.True(t, inspection.WouldServe)
+	assert.Equal(t, []map[string]interface{}{{"value": "x"}}, inspection.Rows)
+	assert.Equal(t, datasource.DataSourceDremio, inspection.Source)
+	require.NotNil(t, inspection.TTLSeconds)
+	assert.Equal(t, int64(60), *inspection.TTLSeconds)
+	require.NotNil(t, inspection.AgeSeconds)
+
+	// Peeking counts neither a hit nor a miss
+	stats, err := memory.Stats(ctx)
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, stats["hits"])
+	assert.EqualValues(t, 1, stats["misses"])
+
+	inspection, err = Inspect(ctx, memory, plan.CacheKey, &datasource.QueryOptions{MaxAge: time.Nanosecond}, 0)
+	require.NoError(t, err)
+	assert.False(t, inspection.WouldServe)
+	assert.Empty(t, inspection.Rows)
+
+	require.NoError(t, memory.Set(ctx, "garbage", []byte("{"), 0))
+	inspection, err = Inspect(ctx, memory, "garbage", nil, 10)
+	require.NoError(t, err)
+	assert.True(t, inspection.Exists)
+	assert.True(t, inspection.Undecodable)
+	assert.Nil(t, inspection.TTLSeconds, "entries without a TTL do not expire")
+}
+
+// rowsSource returns the rows it holds, which a test may change between
+// executions
+type rowsSource struct {
+	countingSource
+	rows []map[string]interface{}
+}
+
+func (s *rowsSource) ExecuteQuery(ctx context.Context, query string, opts *datasource.QueryOptions) (*datasource.QueryResult, error) {
+	s.calls++
+	return &datasource.QueryResult{Data: s.rows, Count: len(s.rows), Source: datasource.DataSourceDremio}, nil
+}
+
+func (s *rowsSource) GetData(ctx context.Context, table string, opts *datasource.QueryOptions) (*datasource.QueryResult, error) {
+	return s.ExecuteQuery(ctx, "SELECT * FROM "+table, opts)
+}
+
+func TestCachedDataSource_SchemaDrift(t *testing.T) {
+	ctx := context.Background()
+	upstream := &rowsSource{rows: []map[string]interface{}{{"id": "a1", "nilai": 100}}}
+	cached := NewCachedDataSource(upstream, NewMemoryCache(), zap.NewNop())
+	drift := metrics.NewSchemaDriftCounter()
+	cached.SetLatencies(nil, "DATAWAREHOUSE")
+	cached.SetSchemaDrift(drift)
+
+	page1 := &datasource.QueryOptions{Limit: 10}
+	page2 := &datasource.QueryOptions{Limit: 10, Offset: 10}
+	first, err := cached.GetData(ctx, "tender", page1)
+	require.NoError(t, err)
+	_, err = cached.GetData(ctx, "tender", page2)
+	require.NoError(t, err)
+	fingerprint := datasource.SchemaFingerprint(first)
+	require.NotEmpty(t, fingerprint)
+
+	hit, err := cached.GetData(ctx, "tender", page2)
+	require.NoError(t, err)
+	assert.True(t, hit.CacheHit)
+	assert.Equal(t, fingerprint, datasource.SchemaFingerprint(hit), "a hit keeps the fingerprint of its execution")
+
+	// nilai turns into a string upstream; re-executing page 1 drops page 2
+	upstream.rows = []map[string]interface{}{{"id": "a1", "nilai": "100"}}
+	fresh, err := cached.GetData(ctx, "tender", &datasource.QueryOptions{Limit: 10, MaxAge: time.Nanosecond})
+	require.NoError(t, err)
+	assert.False(t, fresh.CacheHit)
+	assert.NotEqual(t, fingerprint, datasource.SchemaFingerprint(fresh))
+
+	calls := upstream.calls
+	second, err := cached.GetData(ctx, "tender", page2)
+	require.NoError(t, err)
+	assert.False(t, second.CacheHit, "entries of the old schema are dropped")
+	assert.Equal(t, calls+1, upstream.calls)
+	assert.Equal(t, datasource.SchemaFingerprint(fresh), datasource.SchemaFingerprint(second))
+
+	var buf bytes.Buffer
+	drift.WritePrometheus(&buf)
+	assert.Contains(t, buf.String(), `go_gateway_cache_schema_drift_total{source="DATAWAREHOUSE",kind="changed"} 1`)
+}
+
+func TestCachedDataSource_SchemaDriftOfCommentedQuery(t *testing.T) {
+	ctx := context.Background()
+	upstream := &rowsSource{rows: []map[string]interface{}{{"id": "a1", "nilai": 100}}}
+	cached := NewCachedDataSource(upstream, NewMemoryCache(), zap.NewNop())
+
+	query := "-- weekly report\nSELECT id,  nilai\n  FROM tender"
+	_, err := cached.ExecuteQuery(ctx, query, &datasource.QueryOptions{Limit: 10, Offset: 10})
+	require.NoError(t, err)
+
+	// nilai turns into a string upstream; re-executing page 1 drops page 2
+	upstream.rows = []map[string]interface{}{{"id": "a1", "nilai": "100"}}
+	_, err = cached.ExecuteQuery(ctx, query, &datasource.QueryOptions{Limit: 10})
+	require.NoError(t, err)
+
+	calls := upstream.calls
+	second, err := cached.ExecuteQuery(ctx, query, &datasource.QueryOptions{Limit: 10, Offset: 10})
+	require.NoError(t, err)
+	assert.False(t, second.CacheHit, "entries of the old schema are dropped")
+	assert.Equal(t, calls+1, upstream.calls)
+}
+
+func TestCachedDataSource_HitKeepsNumbersExact(t *testing.T) {
+	ctx := context.Background()
+	rows := []map[string]interface{}{{
+		"kode_rup":      int64(999999999999999999),
+		"nilai_pagu":    "123456789012345678.123456789",
+		"nilai_kontrak": json.Number("123456789012345678.123456789"),
+		"ratio":         0.1,
+		"items":         []interface{}{int64(123456789012345678)},
+	}}
+	upstream := &numbersSource{rowsSource: rowsSource{rows: rows}}
+	cached := NewCachedDataSource(upstream, NewMemoryCache(), zap.NewNop())
+
+	_, err := cached.ExecuteQuery(ctx, "SELECT * FROM rup", nil)
+	require.NoError(t, err)
+	hit, err := cached.ExecuteQuery(ctx, "SELECT * FROM rup", nil)
+	require.NoError(t, err)
+	require.True(t, hit.CacheHit)
+	assert.Equal(t, rows, hit.Data)
+	assert.Equal(t, []string{"nilai_pagu"}, datasource.StringNumbers(hit))
+}
+
+// numbersSource is rowsSource recording nilai_pagu as sent as strings
+type numbersSource struct {
+	rowsSource
+}
+
+func (s *numbersSource) ExecuteQuery(ctx context.Context, query string, opts *datasource.QueryOptions) (*datasource.QueryResult, error) {
+	result, err := s.rowsSource.ExecuteQuery(ctx, query, opts)
+	if err == nil {
+		result.Metadata = map[string]interface{}{datasource.MetaStringNumbers: []string{"nilai_pagu"}}
+	}
+	return result, err
+}
+
+func TestCachedDataSource_SchemaDriftIgnoresNullColumns(t *testing.T) {
+	ctx := context.Background()
+	upstream := &rowsSource{rows: []map[string]interface{}{{"id": "a1", "closed_at": nil}}}
+	cached := NewCachedDataSource(upstream, NewMemoryCache(), zap.NewNop())
+	drift := metrics.NewSchemaDriftCounter()
+	cached.SetSchemaDrift(drift)
+
+	_, err := cached.ExecuteQuery(ctx, "SELECT id, closed_at FROM tender", &datasource.QueryOptions{Limit: 1})
+	require.NoError(t, err)
+	upstream.rows = []map[string]interface{}{{"id": "a2", "closed_at": time.Now()}}
+	_, err = cached.ExecuteQuery(ctx, "SELECT id, closed_at FROM tender", &datasource.QueryOptions{Limit: 1, Offset: 1})
+	require.NoError(t, err)
+
+	hit, err := cached.ExecuteQuery(ctx, "SELECT id, closed_at FROM tender", &datasource.QueryOptions{Limit: 1})
+	require.NoError(t, err)
+	assert.True(t, hit.CacheHit, "a column NULL on one page is no drift")
+
+	var buf bytes.Buffer
+	drift.WritePrometheus(&buf)
+	assert.NotContains(t, buf.String(), "kind=")
+}
+
+func TestCachedDataSource_MixedTypesNotCached(t *testing.T) {
+	ctx := context.Background()
+	upstream := &rowsSource{rows: []map[string]interface{}{{"nilai": 100}, {"nilai": "n/a"}}}
+	cached := NewCachedDataSource(upstream, NewMemoryCache(), zap.NewNop())
+	drift := metrics.NewSchemaDriftCounter()
+	cached.SetLatencies(nil, "DATAWAREHOUSE")
+	cached.SetSchemaDrift(drift)
+
+	for i := 0; i < 2; i++ {
+		result, err := cached.ExecuteQuery(ctx, "SELECT nilai FROM tender", nil)
+		require.NoError(t, err)
+		assert.False(t, result.CacheHit)
+		assert.Len(t, result.Data, 2)
+	}
+	assert.Equal(t, 2, upstream.calls)
+
+	var buf bytes.Buffer
+	drift.WritePrometheus(&buf)
+	assert.Contains(t, buf.String(), `go_gateway_cache_schema_drift_total{source="DATAWAREHOUSE",kind="mixed"} 2`)
+}
+
+func TestSchemaDiff(t *testing.T) {
+	previous := []datasource.ColumnField{
+		{Name: "id", Type: config.ColumnString},
+		{Name: "nilai", Type: config.ColumnNumber},
+		{Name: "old", Type: config.ColumnString},
+		{Name: "tags", Type: config.ColumnString, Repeated: true},
+	}
+	current := []datasource.ColumnField{
+		{Name: "id", Type: ""},
+		{Name: "new", Type: config.ColumnBoolean},
+		{Name: "nilai", Type: config.ColumnString},
+		{Name: "tags", Type: config.ColumnString},
+	}
+	added, removed, changed := schemaDiff(previous, current)
+	assert.Equal(t, []string{"new"}, added)
+	assert.Equal(t, []string{"old"}, removed)
+	assert.Equal(t, []string{"nilai: number -> string", "tags: []string -> string"}, changed)
+}
+
+// oomCache reads like a memory cache but refuses every write, like Redis at
+// its maxmemory limit with the noeviction policy
+type oomCache struct {
+	*MemoryCache
+}
+
+func (c *oomCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return errors.New("OOM command not allowed when used memory > 'maxmemory'")
+}
+
+func TestCachedDataSource_CacheWriteOutcome(t *testing.T) {
+	ctx := context.Background()
+	cached := NewCachedDataSource(&countingSource{value: "x"}, NewMemoryCache(), zap.NewNop())
+
+	fresh, err := cached.ExecuteQuery(ctx, "SELECT 1", nil)
+	require.NoError(t, err)
+	assert.Equal(t, CacheWriteOK, CacheWrite(fresh))
+
+	hit, err := cached.ExecuteQuery(ctx, "SELECT 1", nil)
+	require.NoError(t, err)
+	assert.True(t, hit.CacheHit)
+	assert.Empty(t, CacheWrite(hit), "a hit was not written")
+
+	skipped, err := cached.ExecuteQuery(ctx, "SELECT 1", &datasource.QueryOptions{SkipCache: true})
+	require.NoError(t, err)
+	assert.Equal(t, CacheWriteSkipped, CacheWrite(skipped))
+}
+
+func TestCachedDataSource_SkipCacheEmpty(t *testing.T) {
+	ctx := context.Background()
+	upstream := &rowsSource{}
+	cached := NewCachedDataSource(upstream, NewMemoryCache(), zap.NewNop())
+	lookup := &datasource.QueryOptions{SkipCacheEmpty: true}
+
+	// Nothing found is not cached, so a row added later shows up at once
+	empty, err := cached.ExecuteQuery(ctx, "SELECT * FROM tender WHERE tender_id = 'T1'", lookup)
+	require.NoError(t, err)
+	assert.Equal(t, CacheWriteSkipped, CacheWrite(empty))
+
+	upstream.rows = []map[string]interface{}{{"tender_id": "T1"}}
+	found, err := cached.ExecuteQuery(ctx, "SELECT * FROM tender WHERE tender_id = 'T1'", lookup)
+	require.NoError(t, err)
+	assert.False(t, found.CacheHit)
+	assert.Equal(t, CacheWriteOK, CacheWrite(found))
+
+	hit, err := cached.ExecuteQuery(ctx, "SELECT * FROM tender WHERE tender_id = 'T1'", lookup)
+	require.NoError(t, err)
+	assert.True(t, hit.CacheHit)
+	assert.Equal(t, 2, upstream.calls)
+}
+
+func TestCachedDataSource_CacheWriteFailure(t *testing.T) {
+	ctx := context.Background()
+	upstream := &countingSource{value: "x"}
+	cached := NewCachedDataSource(upstream, &oomCache{NewMemoryCache()}, zap.NewNop())
+	writes := metrics.NewCacheWriteCounter()
+	cached.SetLatencies(nil, "DATAWAREHOUSE")
+	cached.SetCacheWrites(writes)
+
+	// Without probes the breaker stays open once tripped
+	breakerConfig := testBreakerConfig
+	breakerConfig.CoolDown = time.Hour
+	breaker := NewBreaker(breakerConfig, cached.cache, zap.NewNop())
+	defer breaker.Close()
+	cached.SetBreaker(breaker)
+
+	result, err := cached.ExecuteQuery(ctx, "SELECT 1", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "x", result.Data[0]["value"])
+	assert.Equal(t, CacheWriteFailed, CacheWrite(result))
+
+	m := cached.GetMetrics()
+	assert.Equal(t, int64(1), m.WriteFailures)
+	assert.Equal(t, int64(2), m.Errors, "the schema record failed to be written too")
+
+	var buf bytes.Buffer
+	writes.WritePrometheus(&buf)
+	assert.Contains(t, buf.String(), "# TYPE go_gateway_cache_write_failures_total counter")
+	assert.Contains(t, buf.String(), `go_gateway_cache_write_failures_total{source="DATAWAREHOUSE"} 1`)
+
+	// Failed writes open the breaker like failed reads; the cache is then
+	// bypassed and nothing is written
+	state := breaker.State()
+	assert.Equal(t, BreakerOpen, state.State)
+	assert.Equal(t, BreakerReasonErrors, state.Reason)
+
+	bypassed, err := cached.ExecuteQuery(ctx, "SELECT 1", nil)
+	require.NoError(t, err)
+	assert.Equal(t, CacheWriteSkipped, CacheWrite(bypassed))
+	assert.Equal(t, int64(1), cached.GetMetrics().WriteFailures)
+}
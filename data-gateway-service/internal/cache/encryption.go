@@ -0,0 +1,121 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// envelopeV1 starts every value sealed by a Cipher: the version, then the id
+// of the key, the nonce and the AES-GCM ciphertext
+var envelopeV1 = []byte("gwe1")
+
+const keyIDSize = 8
+
+var (
+	// ErrNotEncrypted is returned by Open for a value that is not sealed,
+	// such as an entry written before encryption was enabled
+	ErrNotEncrypted = errors.New("cache value is not encrypted")
+	// ErrUnknownKey is returned by Open for a value sealed with a key that is
+	// neither the current nor the previous one
+	ErrUnknownKey = errors.New("cache value is sealed with an unknown key")
+)
+
+// Cipher seals cache values with AES-256-GCM. Values are sealed with the
+// current key and opened with the current or the previous one, so a key can
+// be rotated while entries sealed with the old one expire. Each value is
+// bound to its cache key, so it cannot be served under another.
+type Cipher struct {
+	current  sealingKey
+	previous *sealingKey
+}
+
+type sealingKey struct {
+	id   []byte
+	aead cipher.AEAD
+}
+
+// NewCipher creates a cipher from base64-encoded 32-byte keys; previous may
+// be empty
+func NewCipher(current, previous string) (*Cipher, error) {
+	key, err := newSealingKey(current)
+	if err != nil {
+		return nil, fmt.Errorf("cache encryption key: %w", err)
+	}
+	c := &Cipher{current: key}
+	if previous != "" {
+		old, err := newSealingKey(previous)
+		if err != nil {
+			return nil, fmt.Errorf("previous cache encryption key: %w", err)
+		}
+		c.previous = &old
+	}
+	return c, nil
+}
+
+func newSealingKey(encoded string) (sealingKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return sealingKey{}, fmt.Errorf("not base64: %w", err)
+	}
+	if len(raw) != 32 {
+		return sealingKey{}, fmt.Errorf("must be 32 bytes, got %d", len(raw))
+	}
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return sealingKey{}, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return sealingKey{}, err
+	}
+	// The id names the key in envelopes without revealing it
+	sum := sha256.Sum256(append([]byte("gateway-cache-key\x00"), raw...))
+	return sealingKey{id: sum[:keyIDSize], aead: aead}, nil
+}
+
+// Seal encrypts value, stored under key, with the current key
+func (c *Cipher) Seal(key string, value []byte) ([]byte, error) {
+	aead := c.current.aead
+	header := len(envelopeV1) + keyIDSize
+	sealed := make([]byte, header+aead.NonceSize(), header+aead.NonceSize()+len(value)+aead.Overhead())
+	copy(sealed, envelopeV1)
+	copy(sealed[len(envelopeV1):], c.current.id)
+	nonce := sealed[header:]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(sealed, nonce, value, []byte(key)), nil
+}
+
+// Open decrypts a value Seal stored under key
+func (c *Cipher) Open(key string, sealed []byte) ([]byte, error) {
+	if !bytes.HasPrefix(sealed, envelopeV1) {
+		return nil, ErrNotEncrypted
+	}
+	rest := sealed[len(envelopeV1):]
+	if len(rest) < keyIDSize {
+		return nil, ErrNotEncrypted
+	}
+	id, rest := rest[:keyIDSize], rest[keyIDSize:]
+
+	var aead cipher.AEAD
+	switch {
+	case bytes.Equal(id, c.current.id):
+		aead = c.current.aead
+	case c.previous != nil && bytes.Equal(id, c.previous.id):
+		aead = c.previous.aead
+	default:
+		return nil, ErrUnknownKey
+	}
+	if len(rest) < aead.NonceSize() {
+		return nil, fmt.Errorf("cache value is truncated")
+	}
+	nonce, ciphertext := rest[:aead.NonceSize()], rest[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, []byte(key))
+}
@@ -0,0 +1,174 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/datasource"
+)
+
+func newTestKey(t testing.TB) string {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func newEncryptedRedis(t *testing.T, sealer *Cipher) (*RedisCache, *miniredis.Miniredis) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	c := NewRedisCache(client, zap.NewNop())
+	c.SetCipher(sealer)
+	return c, server
+}
+
+func TestCipher_SealOpen(t *testing.T) {
+	sealer, err := NewCipher(newTestKey(t), "")
+	require.NoError(t, err)
+
+	value := []byte(`{"data":[{"nama_paket":"Pengadaan Laptop"}]}`)
+	sealed, err := sealer.Seal("gateway:query:abc", value)
+	require.NoError(t, err)
+	assert.False(t, bytes.Contains(sealed, []byte("Pengadaan")))
+
+	opened, err := sealer.Open("gateway:query:abc", sealed)
+	require.NoError(t, err)
+	assert.Equal(t, value, opened)
+
+	// A value is bound to its key and to its bytes
+	_, err = sealer.Open("gateway:query:def", sealed)
+	assert.Error(t, err)
+	sealed[len(sealed)-1] ^= 1
+	_, err = sealer.Open("gateway:query:abc", sealed)
+	assert.Error(t, err)
+
+	_, err = sealer.Open("gateway:query:abc", value)
+	assert.ErrorIs(t, err, ErrNotEncrypted)
+
+	_, err = NewCipher("c2hvcnQ=", "")
+	assert.Error(t, err)
+	_, err = NewCipher(newTestKey(t), "not base64!")
+	assert.Error(t, err)
+}
+
+func TestCipher_Rotation(t *testing.T) {
+	oldKey, newKey := newTestKey(t), newTestKey(t)
+	before, err := NewCipher(oldKey, "")
+	require.NoError(t, err)
+	sealed, err := before.Seal("k", []byte("v"))
+	require.NoError(t, err)
+
+	// During the grace window the old key still reads
+	during, err := NewCipher(newKey, oldKey)
+	require.NoError(t, err)
+	opened, err := during.Open("k", sealed)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("v"), opened)
+
+	resealed, err := during.Seal("k", []byte("v"))
+	require.NoError(t, err)
+	_, err = before.Open("k", resealed)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+	// Once it is dropped, its values are unknown
+	after, err := NewCipher(newKey, "")
+	require.NoError(t, err)
+	_, err = after.Open("k", sealed)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+	opened, err = after.Open("k", resealed)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("v"), opened)
+}
+
+func TestRedisCache_Encrypted(t *testing.T) {
+	ctx := context.Background()
+	sealer, err := NewCipher(newTestKey(t), "")
+	require.NoError(t, err)
+	c, server := newEncryptedRedis(t, sealer)
+
+	value, _ := json.Marshal(cachedResult{
+		Data:   []map[string]interface{}{{"nama_paket": "Pengadaan Laptop"}},
+		Count:  1,
+		Source: datasource.DataSourceDremio,
+	})
+	key := GenerateKey("query", "SELECT nama_paket FROM tender_data")
+	require.NoError(t, c.Set(ctx, key, value, time.Minute))
+
+	// Redis holds ciphertext only
+	stored, err := server.Get(key)
+	require.NoError(t, err)
+	assert.NotContains(t, stored, "Pengadaan")
+
+	got, err := c.Get(ctx, key)
+	require.NoError(t, err)
+	assert.Equal(t, value, got)
+
+	inspection, err := Inspect(ctx, c, key, nil, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, inspection.Count)
+	assert.False(t, inspection.Undecodable)
+
+	// Plaintext written before encryption was enabled is a miss
+	require.NoError(t, server.Set("gateway:query:legacy", string(value)))
+	_, err = c.Get(ctx, "gateway:query:legacy")
+	assert.ErrorIs(t, err, ErrCacheMiss)
+	inspection, err = Inspect(ctx, c, "gateway:query:legacy", nil, 1)
+	require.NoError(t, err)
+	assert.False(t, inspection.Exists)
+
+	stats, err := c.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, true, stats["encrypted"])
+	assert.Equal(t, int64(2), stats["undecryptable"])
+	assert.Equal(t, int64(1), stats["misses"])
+}
+
+func benchmarkSizes() []int {
+	return []int{1 << 10, 64 << 10, 1 << 20}
+}
+
+func BenchmarkCipher_Seal(b *testing.B) {
+	sealer, err := NewCipher(newTestKey(b), "")
+	require.NoError(b, err)
+	for _, size := range benchmarkSizes() {
+		value := make([]byte, size)
+		b.Run(fmt.Sprintf("%dKB", size>>10), func(b *testing.B) {
+			b.SetBytes(int64(size))
+			for i := 0; i < b.N; i++ {
+				if _, err := sealer.Seal("gateway:query:abc", value); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkCipher_Open(b *testing.B) {
+	sealer, err := NewCipher(newTestKey(b), "")
+	require.NoError(b, err)
+	for _, size := range benchmarkSizes() {
+		sealed, err := sealer.Seal("gateway:query:abc", make([]byte, size))
+		require.NoError(b, err)
+		b.Run(fmt.Sprintf("%dKB", size>>10), func(b *testing.B) {
+			b.SetBytes(int64(size))
+			for i := 0; i < b.N; i++ {
+				if _, err := sealer.Open("gateway:query:abc", sealed); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
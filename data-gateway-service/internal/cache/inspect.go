@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"go-data-gateway/internal/datasource"
+)
+
+// Inspection describes what a cache key holds, for support tooling. It is
+// read with Peek, so inspecting counts neither a hit nor a miss.
+type Inspection struct {
+	Key    string `json:"key"`
+	Exists bool   `json:"exists"`
+
+	CachedAt   *time.Time `json:"cached_at,omitempty"`
+	AgeSeconds *int64     `json:"age_seconds,omitempty"`
+	TTLSeconds *int64     `json:"ttl_seconds,omitempty"` // Remaining; absent when the entry does not expire
+	SizeBytes  int        `json:"size_bytes,omitempty"`
+
+	// Undecodable entries are discarded and re-fetched by the next read
+	Undecodable bool `json:"undecodable,omitempty"`
+	// WouldServe reports whether the next read with the inspected options is
+	// served from this entry rather than re-executed
+	WouldServe bool                      `json:"would_serve"`
+	Source     datasource.DataSourceType `json:"source,omitempty"`
+	Count      int                       `json:"count,omitempty"`
+	Rows       []map[string]interface{}  `json:"rows,omitempty"` // The first rows of the cached result
+}
+
+// Inspect peeks at key in c: whether it exists, its age, remaining TTL and
+// size, and the first rows of the cached result. opts are those of the read
+// being previewed, for their max age.
+func Inspect(ctx context.Context, c Cache, key string, opts *datasource.QueryOptions, rows int) (Inspection, error) {
+	inspection := Inspection{Key: key}
+	entry, err := c.Peek(ctx, key)
+	if errors.Is(err, ErrCacheMiss) {
+		return inspection, nil
+	}
+	if err != nil {
+		return inspection, err
+	}
+
+	inspection.Exists = true
+	inspection.SizeBytes = len(entry.Value)
+	if entry.TTL > 0 {
+		ttl := int64(entry.TTL.Round(time.Second) / time.Second)
+		inspection.TTLSeconds = &ttl
+	}
+
+	var result cachedResult
+	if err := json.Unmarshal(entry.Value, &result); err != nil {
+		inspection.Undecodable = true
+		return inspection, nil
+	}
+	if !result.CachedAt.IsZero() {
+		cachedAt := result.CachedAt
+		age := int64(time.Since(cachedAt) / time.Second)
+		inspection.CachedAt, inspection.AgeSeconds = &cachedAt, &age
+	}
+	inspection.WouldServe = !result.olderThan(opts)
+	inspection.Source = result.Source
+	inspection.Count = result.Count
+	inspection.Rows = result.Data[:min(max(rows, 0), len(result.Data))]
+	return inspection, nil
+}
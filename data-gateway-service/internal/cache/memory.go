@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"context"
+	"path"
+	"sync"
+	"time"
+)
+
+// MemoryCache is an in-process Cache for single-replica deployments and tests
+type MemoryCache struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	hits    int64
+	misses  int64
+}
+
+type memoryEntry struct {
+	value     []byte
+	expiresAt time.Time // zero means no expiry
+}
+
+// NewMemoryCache creates an empty in-memory cache
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{entries: make(map[string]memoryEntry)}
+}
+
+// Get returns the cached value or ErrCacheMiss
+func (c *MemoryCache) Get(ctx context.Context, key string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if ok && !entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt) {
+		delete(c.entries, key)
+		ok = false
+	}
+	if !ok {
+		c.misses++
+		return nil, ErrCacheMiss
+	}
+
+	c.hits++
+	return append([]byte(nil), entry.value...), nil
+}
+
+// Peek returns a copy of the cached entry or ErrCacheMiss
+func (c *MemoryCache) Peek(ctx context.Context, key string) (*Entry, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, ErrCacheMiss
+	}
+	var ttl time.Duration
+	if !entry.expiresAt.IsZero() {
+		if ttl = time.Until(entry.expiresAt); ttl <= 0 {
+			return nil, ErrCacheMiss
+		}
+	}
+	return &Entry{Value: append([]byte(nil), entry.value...), TTL: ttl}, nil
+}
+
+// Set stores a copy of the value
+func (c *MemoryCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := memoryEntry{value: append([]byte(nil), value...)}
+	if ttl > 0 {
+		entry.expiresAt = time.Now().Add(ttl)
+	}
+	c.entries[key] = entry
+	return nil
+}
+
+// Delete removes the given keys
+func (c *MemoryCache) Delete(ctx context.Context, keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		delete(c.entries, key)
+	}
+	return nil
+}
+
+// DeletePattern removes keys matching a glob pattern
+func (c *MemoryCache) DeletePattern(ctx context.Context, pattern string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key := range c.entries {
+		if ok, _ := path.Match(pattern, key); ok {
+			delete(c.entries, key)
+		}
+	}
+	return nil
+}
+
+// Stats returns entry count and hit/miss counters
+func (c *MemoryCache) Stats(ctx context.Context) (map[string]interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return map[string]interface{}{
+		"type":     "memory",
+		"keys":     len(c.entries),
+		"hits":     c.hits,
+		"misses":   c.misses,
+		"hit_rate": hitRate(c.hits, c.misses),
+	}, nil
+}
+
+// Close is a no-op
+func (c *MemoryCache) Close() error {
+	return nil
+}
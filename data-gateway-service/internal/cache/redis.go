@@ -0,0 +1,248 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/config"
+)
+
+// RedisCache implements Cache on top of Redis. With a cipher, values are
+// encrypted before they reach Redis and decrypted as they are read; values
+// that cannot be decrypted are misses.
+type RedisCache struct {
+	client *redis.Client
+	cipher *Cipher
+	logger *zap.Logger
+
+	hits          atomic.Int64
+	misses        atomic.Int64
+	errors        atomic.Int64
+	undecryptable atomic.Int64
+}
+
+// NewRedisCache wraps an existing Redis client
+func NewRedisCache(client *redis.Client, logger *zap.Logger) *RedisCache {
+	return &RedisCache{
+		client: client,
+		logger: logger,
+	}
+}
+
+// NewRedisCacheFromConfig connects to Redis and verifies the connection
+func NewRedisCacheFromConfig(cfg config.RedisConfig, logger *zap.Logger) (*RedisCache, error) {
+	var sealer *Cipher
+	if cfg.EncryptionKey != "" {
+		var err error
+		if sealer, err = NewCipher(cfg.EncryptionKey, cfg.PreviousEncryptionKey); err != nil {
+			return nil, err
+		}
+	}
+
+	opts, err := RedisOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	client := redis.NewClient(opts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+
+	logger.Info("Redis cache connected",
+		zap.String("host", cfg.Host),
+		zap.Int("port", cfg.Port),
+		zap.Bool("tls", opts.TLSConfig != nil),
+		zap.Bool("encrypted", sealer != nil))
+
+	c := NewRedisCache(client, logger)
+	c.SetCipher(sealer)
+	return c, nil
+}
+
+// RedisOptions are the client options of cfg, with TLS when it is enabled
+func RedisOptions(cfg config.RedisConfig) (*redis.Options, error) {
+	tlsConfig, err := cfg.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	return &redis.Options{
+		Addr:         fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
+		Password:     cfg.Password,
+		DB:           cfg.DB,
+		DialTimeout:  5 * time.Second,
+		ReadTimeout:  3 * time.Second,
+		WriteTimeout: 3 * time.Second,
+		TLSConfig:    tlsConfig,
+	}, nil
+}
+
+// SetCipher encrypts the values written from now on with cipher, and reads
+// only values it can decrypt; nil stores values as they are
+func (c *RedisCache) SetCipher(cipher *Cipher) {
+	c.cipher = cipher
+}
+
+// Client returns the underlying Redis client
+func (c *RedisCache) Client() *redis.Client {
+	return c.client
+}
+
+// Get returns the cached value or ErrCacheMiss
+func (c *RedisCache) Get(ctx context.Context, key string) ([]byte, error) {
+	data, err := c.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		c.misses.Add(1)
+		return nil, ErrCacheMiss
+	}
+	if err != nil {
+		c.errors.Add(1)
+		return nil, err
+	}
+	if data, err = c.open(key, data); err != nil {
+		c.misses.Add(1)
+		return nil, ErrCacheMiss
+	}
+
+	c.hits.Add(1)
+	return data, nil
+}
+
+// Peek returns the cached entry or ErrCacheMiss, reading the value and its
+// TTL in one round trip. Like Get, it misses values it cannot decrypt.
+func (c *RedisCache) Peek(ctx context.Context, key string) (*Entry, error) {
+	var get *redis.StringCmd
+	var pttl *redis.DurationCmd
+	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		get = pipe.Get(ctx, key)
+		pttl = pipe.PTTL(ctx, key)
+		return nil
+	})
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrCacheMiss
+	}
+	if err != nil {
+		c.errors.Add(1)
+		return nil, err
+	}
+
+	data, err := get.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	if data, err = c.open(key, data); err != nil {
+		return nil, ErrCacheMiss
+	}
+	// PTTL is negative for a key without an expiry
+	ttl := max(pttl.Val(), 0)
+	return &Entry{Value: data, TTL: ttl}, nil
+}
+
+// Set stores a value with the given TTL
+func (c *RedisCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if c.cipher != nil {
+		sealed, err := c.cipher.Seal(key, value)
+		if err != nil {
+			c.errors.Add(1)
+			return err
+		}
+		value = sealed
+	}
+	if err := c.client.Set(ctx, key, value, ttl).Err(); err != nil {
+		c.errors.Add(1)
+		return err
+	}
+	return nil
+}
+
+// open decrypts a value read from key. Values written before encryption was
+// enabled, or sealed with a retired key, are logged and counted.
+func (c *RedisCache) open(key string, data []byte) ([]byte, error) {
+	if c.cipher == nil {
+		return data, nil
+	}
+	opened, err := c.cipher.Open(key, data)
+	if err != nil {
+		c.undecryptable.Add(1)
+		c.logger.Debug("Cache value not decrypted", zap.String("key", key), zap.Error(err))
+		return nil, err
+	}
+	return opened, nil
+}
+
+// Delete removes the given keys
+func (c *RedisCache) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return c.client.Del(ctx, keys...).Err()
+}
+
+// DeletePattern removes every key matching the pattern using SCAN, never KEYS
+func (c *RedisCache) DeletePattern(ctx context.Context, pattern string) error {
+	iter := c.client.Scan(ctx, 0, pattern, 500).Iterator()
+
+	batch := make([]string, 0, 500)
+	for iter.Next(ctx) {
+		batch = append(batch, iter.Val())
+		if len(batch) == cap(batch) {
+			if err := c.client.Del(ctx, batch...).Err(); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+
+	return c.Delete(ctx, batch...)
+}
+
+// Stats returns hit/miss counters and the Redis key count
+func (c *RedisCache) Stats(ctx context.Context) (map[string]interface{}, error) {
+	hits, misses := c.hits.Load(), c.misses.Load()
+
+	stats := map[string]interface{}{
+		"type":     "redis",
+		"hits":     hits,
+		"misses":   misses,
+		"errors":   c.errors.Load(),
+		"hit_rate": hitRate(hits, misses),
+
+		"encrypted":     c.cipher != nil,
+		"undecryptable": c.undecryptable.Load(),
+	}
+
+	keys, err := c.client.DBSize(ctx).Result()
+	if err != nil {
+		stats["connected"] = false
+		return stats, err
+	}
+	stats["connected"] = true
+	stats["keys"] = keys
+
+	return stats, nil
+}
+
+// Close closes the Redis connection
+func (c *RedisCache) Close() error {
+	return c.client.Close()
+}
+
+func hitRate(hits, misses int64) float64 {
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) / float64(hits+misses)
+}
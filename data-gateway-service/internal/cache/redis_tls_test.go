@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/config"
+)
+
+// newPrivateCA creates a CA, writes its certificate to a PEM file and
+// returns the file and the TLS config of a server certified by it for names
+func newPrivateCA(t *testing.T, names ...string) (string, *tls.Config) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	ca := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "gateway test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, ca, ca, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	ca, err = x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+
+	serverKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	server := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: names[0]},
+		DNSNames:     names,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	serverDER, err := x509.CreateCertificate(rand.Reader, server, ca, &serverKey.PublicKey, caKey)
+	require.NoError(t, err)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}), 0o600))
+	return caFile, &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{serverDER}, PrivateKey: serverKey}}}
+}
+
+// runTLSRedis starts miniredis behind TLS and returns the config reaching it
+func runTLSRedis(t *testing.T, serverTLS *tls.Config) config.RedisConfig {
+	server, err := miniredis.RunTLS(serverTLS)
+	require.NoError(t, err)
+	t.Cleanup(server.Close)
+
+	host, port, err := net.SplitHostPort(server.Addr())
+	require.NoError(t, err)
+	portNumber, err := strconv.Atoi(port)
+	require.NoError(t, err)
+	return config.RedisConfig{Host: host, Port: portNumber}
+}
+
+func TestRedisCache_TLS(t *testing.T) {
+	caFile, serverTLS := newPrivateCA(t, "redis.internal")
+	cfg := runTLSRedis(t, serverTLS)
+	cfg.TLS, cfg.CAFile, cfg.ServerName = true, caFile, "redis.internal"
+
+	c, err := NewRedisCacheFromConfig(cfg, zap.NewNop())
+	require.NoError(t, err)
+	defer c.Close()
+
+	ctx := context.Background()
+	require.NoError(t, c.Set(ctx, "gateway:query:tls", []byte("v"), time.Minute))
+	got, err := c.Get(ctx, "gateway:query:tls")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("v"), got)
+}
+
+func TestRedisCache_TLSRejected(t *testing.T) {
+	caFile, serverTLS := newPrivateCA(t, "redis.internal")
+	otherCA, _ := newPrivateCA(t, "redis.internal")
+	server := runTLSRedis(t, serverTLS)
+
+	tests := []struct {
+		name   string
+		mutate func(*config.RedisConfig)
+	}{
+		{"plaintext", func(c *config.RedisConfig) {}},
+		{"system roots only", func(c *config.RedisConfig) { c.TLS, c.ServerName = true, "redis.internal" }},
+		{"another CA", func(c *config.RedisConfig) { c.TLS, c.CAFile, c.ServerName = true, otherCA, "redis.internal" }},
+		{"wrong server name", func(c *config.RedisConfig) { c.TLS, c.CAFile, c.ServerName = true, caFile, "cache.internal" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := server
+			tt.mutate(&cfg)
+			_, err := NewRedisCacheFromConfig(cfg, zap.NewNop())
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestRedisOptions_InvalidCA(t *testing.T) {
+	_, err := RedisOptions(config.RedisConfig{Host: "redis", Port: 6379, TLS: true, CAFile: filepath.Join(t.TempDir(), "missing.pem")})
+	assert.ErrorContains(t, err, "Redis CA file")
+
+	opts, err := RedisOptions(config.RedisConfig{Host: "redis", Port: 6379})
+	require.NoError(t, err)
+	assert.Nil(t, opts.TLSConfig)
+	assert.Equal(t, "redis:6379", opts.Addr)
+}
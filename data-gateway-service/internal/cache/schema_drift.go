@@ -0,0 +1,101 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/metrics"
+)
+
+// schemaRecord is the schema of the latest fresh result of a scope, stored
+// next to its entries
+type schemaRecord struct {
+	Fingerprint string                   `json:"fingerprint"`
+	Columns     []datasource.ColumnField `json:"columns"`
+}
+
+// schemaKey is the key of the schema record of scope
+func schemaKey(scope string) string {
+	return keyPrefix + scope + ":schema"
+}
+
+// checkSchema compares the schema of a fresh result of scope with the one
+// recorded for it. When they differ, every entry of the scope is dropped, so
+// no page is served with the old columns next to a page with the new ones.
+// The record is then replaced, for ttl.
+func (c *CachedDataSource) checkSchema(ctx context.Context, scope string, schema []datasource.ColumnField, fingerprint string, ttl time.Duration) {
+	key := schemaKey(scope)
+	if entry, err := c.cache.Peek(ctx, key); err == nil {
+		var recorded schemaRecord
+		if json.Unmarshal(entry.Value, &recorded) == nil && recorded.Fingerprint != fingerprint {
+			added, removed, changed := schemaDiff(recorded.Columns, schema)
+			if len(added)+len(removed)+len(changed) > 0 {
+				c.drift.Record(c.name, metrics.DriftChanged)
+				c.logger.Warn("Schema drift, dropping cached results",
+					zap.String("source", c.name),
+					zap.String("scope", scope),
+					zap.String("cached_fingerprint", recorded.Fingerprint),
+					zap.String("fingerprint", fingerprint),
+					zap.Strings("added", added),
+					zap.Strings("removed", removed),
+					zap.Strings("changed", changed))
+				if err := c.cache.DeletePattern(ctx, keyPrefix+scope+":*"); err != nil {
+					c.recordError()
+					c.logger.Warn("Cache invalidation failed", zap.String("scope", scope), zap.Error(err))
+				}
+			}
+		}
+	}
+
+	encoded, err := json.Marshal(schemaRecord{Fingerprint: fingerprint, Columns: schema})
+	if err == nil {
+		write := time.Now()
+		err = c.cache.Set(ctx, key, encoded, ttl)
+		c.breaker.Record(ctx, time.Since(write), err)
+	}
+	if err != nil {
+		c.recordError()
+		c.logger.Warn("Cache write failed", zap.Error(err))
+	}
+}
+
+// schemaDiff returns the columns of current that are not in previous, those
+// of previous that are not in current, and those whose type changed, as
+// "name: old -> new". A column NULL in every row has no type and changes
+// into any.
+func schemaDiff(previous, current []datasource.ColumnField) (added, removed, changed []string) {
+	before := make(map[string]datasource.ColumnField, len(previous))
+	for _, column := range previous {
+		before[column.Name] = column
+	}
+	for _, column := range current {
+		old, ok := before[column.Name]
+		delete(before, column.Name)
+		switch {
+		case !ok:
+			added = append(added, column.Name)
+		case old.Type == "" || column.Type == "":
+		case old.Type != column.Type || old.Repeated != column.Repeated:
+			changed = append(changed, fmt.Sprintf("%s: %s -> %s", column.Name, columnType(old), columnType(column)))
+		}
+	}
+	for _, column := range previous {
+		if _, ok := before[column.Name]; ok {
+			removed = append(removed, column.Name)
+		}
+	}
+	return added, removed, changed
+}
+
+// columnType is the type of column as schemaDiff reports it
+func columnType(column datasource.ColumnField) string {
+	if column.Repeated {
+		return "[]" + column.Type
+	}
+	return column.Type
+}
@@ -2,7 +2,11 @@ package clients
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
@@ -10,48 +14,164 @@ import (
 	"github.com/patrickmn/go-cache"
 	"go.uber.org/zap"
 	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
 
+	"go-data-gateway/internal/colnames"
 	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/metrics"
+	"go-data-gateway/internal/numeric"
+	"go-data-gateway/internal/sqltext"
 )
 
+// cancelTimeout bounds the call cancelling a job whose request has ended
+const cancelTimeout = 10 * time.Second
+
+// queryCreator creates query jobs; *bigquery.Client in production
+type queryCreator interface {
+	Query(q string) *bigquery.Query
+}
+
+// newLocatedQuery creates a query job running in location; an empty location
+// leaves the choice to BigQuery
+func newLocatedQuery(jobs queryCreator, sqlQuery, location string) *bigquery.Query {
+	q := jobs.Query(sqlQuery)
+	q.Location = location
+	return q
+}
+
+// BigQuerier is the BigQuery work of the gateway's data source and handlers.
+// *BigQueryClient runs it on BigQuery; bqtest.Stub stands in for it in tests.
+type BigQuerier interface {
+	Query(ctx context.Context, sqlQuery string) ([]map[string]interface{}, error)
+	ExecuteLabeledQuery(ctx context.Context, query string, labels map[string]string) (interface{}, error)
+	DryRun(ctx context.Context, sqlQuery string) (int64, error)
+	TableSchema(ctx context.Context, table string) (bigquery.Schema, error)
+	TableSize(ctx context.Context, table string) (rows, bytes int64, err error)
+	TestConnection(ctx context.Context) error
+	TestQuery(ctx context.Context) error
+	SetJobCancels(counter *metrics.CancelCounter)
+	Close() error
+}
+
+var _ BigQuerier = (*BigQueryClient)(nil)
+
 // BigQueryClient handles connections to Google BigQuery
 type BigQueryClient struct {
 	client *bigquery.Client
+	jobs   queryCreator
 	config config.BigQueryConfig
 	cache  *cache.Cache
 	logger *zap.Logger
+
+	cancels *metrics.CancelCounter
 }
 
-// NewBigQueryClient creates a new BigQuery client
-func NewBigQueryClient(cfg config.BigQueryConfig, logger *zap.Logger) (*BigQueryClient, error) {
+// NewBigQueryClient creates a new BigQuery client. Extra client options (e.g. a
+// custom endpoint) are passed through to the BigQuery SDK after those of cfg.
+func NewBigQueryClient(cfg config.BigQueryConfig, logger *zap.Logger, opts ...option.ClientOption) (*BigQueryClient, error) {
 	ctx := context.Background()
 
+	configured, err := clientOptions(ctx, cfg, opts...)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create BigQuery client
-	client, err := bigquery.NewClient(ctx, cfg.ProjectID)
+	client, err := bigquery.NewClient(ctx, cfg.ProjectID, append(configured, opts...)...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create BigQuery client: %w", err)
 	}
+	client.Location = cfg.Location
 
 	return &BigQueryClient{
 		client: client,
+		jobs:   client,
 		config: cfg,
 		cache:  cache.New(5*time.Minute, 10*time.Minute),
 		logger: logger,
 	}, nil
 }
 
+// clientOptions are the SDK options of cfg: its endpoint, e.g. a Private
+// Service Connect endpoint, and an HTTP transport trusting its CA file,
+// authenticated as extra asks
+func clientOptions(ctx context.Context, cfg config.BigQueryConfig, extra ...option.ClientOption) ([]option.ClientOption, error) {
+	var opts []option.ClientOption
+	if cfg.Endpoint != "" {
+		endpoint, err := url.Parse(cfg.Endpoint)
+		if err != nil || endpoint.Scheme != "https" || endpoint.Host == "" {
+			return nil, fmt.Errorf("BigQuery endpoint %q must be an https URL", cfg.Endpoint)
+		}
+		opts = append(opts, option.WithEndpoint(cfg.Endpoint))
+	}
+
+	roots, err := cfg.RootCAs()
+	if err != nil || roots == nil {
+		return opts, err
+	}
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.TLSClientConfig = &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}
+	// The SDK does not authenticate a client it is given, so the transport does
+	transport, err := htransport.NewTransport(ctx, base, append([]option.ClientOption{option.WithScopes(bigquery.Scope)}, extra...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create BigQuery transport: %w", err)
+	}
+	return append(opts, option.WithHTTPClient(&http.Client{Transport: transport})), nil
+}
+
 // GetClient returns the underlying BigQuery client for advanced operations
 func (c *BigQueryClient) GetClient() *bigquery.Client {
 	return c.client
 }
 
+// SetJobCancels counts the jobs the client cancels because their request
+// ended in counter
+func (c *BigQueryClient) SetJobCancels(counter *metrics.CancelCounter) {
+	c.cancels = counter
+}
+
+// newQuery creates a query job in the configured location
+func (c *BigQueryClient) newQuery(sqlQuery string) *bigquery.Query {
+	return newLocatedQuery(c.jobs, sqlQuery, c.config.Location)
+}
+
+// CheckLocation verifies that the configured dataset exists and is in the
+// configured location. Without a location or dataset there is nothing to check.
+func (c *BigQueryClient) CheckLocation(ctx context.Context) error {
+	if c.config.Location == "" || c.config.DatasetID == "" || c.config.DatasetID == "your-dataset-id" {
+		return nil
+	}
+	metadata, err := c.client.Dataset(c.config.DatasetID).Metadata(ctx)
+	if err != nil {
+		return fmt.Errorf("BigQuery dataset %s.%s is not reachable: %w", c.config.ProjectID, c.config.DatasetID, err)
+	}
+	if !strings.EqualFold(metadata.Location, c.config.Location) {
+		return fmt.Errorf("BigQuery dataset %s.%s is in location %s, not the configured %s",
+			c.config.ProjectID, c.config.DatasetID, metadata.Location, c.config.Location)
+	}
+	return nil
+}
+
 // Query executes a SQL query against BigQuery
 func (c *BigQueryClient) Query(ctx context.Context, sqlQuery string) ([]map[string]interface{}, error) {
-	// Check cache first
+	result, err := c.query(ctx, sqlQuery, nil)
+	if err != nil {
+		return nil, err
+	}
+	return result.rows, nil
+}
+
+// query runs sqlQuery as a job carrying labels; labels are not part of the
+// cache key
+func (c *BigQueryClient) query(ctx context.Context, sqlQuery string, labels map[string]string) (*queryRows, error) {
+	// Check cache first; no job runs for a hit, so it has no statistics
 	cacheKey := fmt.Sprintf("bigquery:%s", sqlQuery)
 	if cached, found := c.cache.Get(cacheKey); found {
 		c.logger.Debug("Cache hit", zap.String("query", sqlQuery))
-		return cached.([]map[string]interface{}), nil
+		hit := *cached.(*queryRows)
+		hit.stats = nil
+		return &hit, nil
 	}
 
 	c.logger.Info("Executing BigQuery",
@@ -61,71 +181,146 @@ func (c *BigQueryClient) Query(ctx context.Context, sqlQuery string) ([]map[stri
 	start := time.Now()
 
 	// Create query
-	q := c.client.Query(sqlQuery)
+	q := c.newQuery(sqlQuery)
 	// Only set default dataset if configured and query doesn't contain fully qualified table names
 	if c.config.DatasetID != "" && c.config.DatasetID != "your-dataset-id" {
 		q.DefaultDatasetID = c.config.DatasetID
 	}
+	q.Labels = labels
 
 	// Run query
-	it, err := q.Read(ctx)
+	job, it, err := c.read(ctx, q)
 	if err != nil {
 		c.logger.Error("Query execution failed", zap.Error(err))
 		return nil, fmt.Errorf("query execution failed: %w", err)
 	}
 
 	// Collect results
-	var results []map[string]interface{}
-
-	for {
-		var row map[string]bigquery.Value
-		err := it.Next(&row)
-		if err == iterator.Done {
-			break
-		}
-		if err != nil {
-			c.logger.Error("Error reading row", zap.Error(err))
-			return nil, fmt.Errorf("error reading row: %w", err)
-		}
-
-		// Convert BigQuery values to standard map
-		result := make(map[string]interface{})
-		for k, v := range row {
-			result[k] = convertBigQueryValue(v)
-		}
-		results = append(results, result)
+	result, err := c.readRows(it)
+	if err != nil {
+		c.logger.Error("Error reading row", zap.Error(err))
+		return nil, fmt.Errorf("error reading row: %w", err)
 	}
+	result.stats = c.jobStats(ctx, job)
 
 	// Log performance metrics
 	c.logger.Info("BigQuery completed",
 		zap.Duration("duration", time.Since(start)),
-		zap.Int("rows", len(results)),
-		zap.Uint64("total_rows", it.TotalRows))
+		zap.Int("rows", len(result.rows)),
+		zap.Uint64("total_rows", it.TotalRows),
+		zap.String("job_id", result.stats.JobID),
+		zap.Int64("bytes_billed", result.stats.TotalBytesBilled),
+		zap.Bool("bigquery_cache_hit", result.stats.CacheHit))
 
 	// Cache results
-	c.cache.Set(cacheKey, results, cache.DefaultExpiration)
+	c.cache.Set(cacheKey, result, cache.DefaultExpiration)
+
+	return result, nil
+}
 
-	return results, nil
+// read runs q as a job and returns it with an iterator over its rows once
+// it has completed. The job is given ctx's deadline as its timeout, and a
+// job still running when ctx is done is cancelled: BigQuery keeps running,
+// and billing, a job whose client went away.
+func (c *BigQueryClient) read(ctx context.Context, q *bigquery.Query) (*bigquery.Job, *bigquery.RowIterator, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		if timeout := time.Until(deadline); timeout > 0 {
+			q.JobTimeout = timeout
+		}
+	}
+	job, err := q.Run(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	it, err := job.Read(ctx)
+	if err != nil && ctx.Err() != nil {
+		c.cancelJob(ctx, job)
+	}
+	return job, it, err
+}
+
+// cancelJob asks BigQuery to stop job, outliving ctx by up to cancelTimeout
+func (c *BigQueryClient) cancelJob(ctx context.Context, job *bigquery.Job) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cancelTimeout)
+	defer cancel()
+	if err := job.Cancel(ctx); err != nil {
+		c.logger.Warn("Failed to cancel BigQuery job", zap.String("job_id", job.ID()), zap.Error(err))
+		return
+	}
+	c.cancels.Record("bigquery")
+	c.logger.Info("Cancelled BigQuery job after its request ended", zap.String("job_id", job.ID()))
 }
 
 // ExecuteQuery provides a simpler interface for executing queries
 func (c *BigQueryClient) ExecuteQuery(ctx context.Context, query string) (interface{}, error) {
+	return c.ExecuteLabeledQuery(ctx, query, nil)
+}
+
+// ExecuteLabeledQuery is ExecuteQuery with job labels, used to attribute
+// BigQuery spend to the calling application
+func (c *BigQueryClient) ExecuteLabeledQuery(ctx context.Context, query string, labels map[string]string) (interface{}, error) {
 	// Validate query is read-only
 	if !isReadOnlySQL(query) {
 		return nil, fmt.Errorf("only SELECT queries are allowed")
 	}
 
-	results, err := c.Query(ctx, query)
+	result, err := c.query(ctx, query, labels)
 	if err != nil {
 		return nil, err
 	}
 
-	return results, nil
+	// Renamed columns, NUMERIC columns sent as strings and the job's
+	// statistics travel with the rows for the caller's metadata
+	if result.renamed == nil && result.stringNumbers == nil && result.stats == nil {
+		return result.rows, nil
+	}
+	labeled := map[string]interface{}{"data": result.rows}
+	if result.renamed != nil {
+		labeled[colnames.MetaKey] = result.renamed
+	}
+	if result.stringNumbers != nil {
+		labeled[numeric.MetaKey] = result.stringNumbers
+	}
+	if result.stats != nil {
+		labeled[JobStatsKey] = result.stats
+	}
+	return labeled, nil
+}
+
+// DryRun validates a query without running it and returns the number of
+// bytes it would process
+func (c *BigQueryClient) DryRun(ctx context.Context, sqlQuery string) (int64, error) {
+	if !isReadOnlySQL(sqlQuery) {
+		return 0, fmt.Errorf("only SELECT queries are allowed")
+	}
+
+	q := c.newQuery(sqlQuery)
+	if c.config.DatasetID != "" && c.config.DatasetID != "your-dataset-id" {
+		q.DefaultDatasetID = c.config.DatasetID
+	}
+	q.DryRun = true
+
+	job, err := q.Run(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	status := job.LastStatus()
+	if status == nil {
+		return 0, nil
+	}
+	if err := status.Err(); err != nil {
+		return 0, err
+	}
+	if status.Statistics == nil {
+		return 0, nil
+	}
+	return status.Statistics.TotalBytesProcessed, nil
 }
 
 // QueryWithParams executes a parameterized query
 func (c *BigQueryClient) QueryWithParams(ctx context.Context, sqlQuery string, params map[string]interface{}) ([]map[string]interface{}, error) {
-	q := c.client.Query(sqlQuery)
+	q := c.newQuery(sqlQuery)
 	q.DefaultDatasetID = c.config.DatasetID
 
 	// Add parameters
@@ -136,35 +331,85 @@ func (c *BigQueryClient) QueryWithParams(ctx context.Context, sqlQuery string, p
 		})
 	}
 
-	it, err := q.Read(ctx)
+	_, it, err := c.read(ctx, q)
 	if err != nil {
 		return nil, err
 	}
 
-	var results []map[string]interface{}
-	for {
-		var row map[string]bigquery.Value
-		err := it.Next(&row)
-		if err == iterator.Done {
-			break
-		}
-		if err != nil {
-			return nil, err
-		}
+	result, err := c.readRows(it)
+	if err != nil {
+		return nil, err
+	}
+	return result.rows, nil
+}
 
-		result := make(map[string]interface{})
-		for k, v := range row {
-			result[k] = convertBigQueryValue(v)
-		}
-		results = append(results, result)
+// TableSchema returns the schema of table, named dataset.table or
+// project.dataset.table; a bare table name is looked up in the default dataset
+func (c *BigQueryClient) TableSchema(ctx context.Context, table string) (bigquery.Schema, error) {
+	metadata, err := c.tableMetadata(ctx, table)
+	if err != nil {
+		return nil, err
 	}
+	return metadata.Schema, nil
+}
 
-	return results, nil
+// TableSize returns the rows and bytes of table from its metadata, named as
+// for TableSchema, without scanning it. Rows still in the streaming buffer
+// are not counted.
+func (c *BigQueryClient) TableSize(ctx context.Context, table string) (rows, bytes int64, err error) {
+	metadata, err := c.tableMetadata(ctx, table)
+	if err != nil {
+		return 0, 0, err
+	}
+	return int64(metadata.NumRows), metadata.NumBytes, nil
 }
 
-// TestConnection verifies the BigQuery connection
+// tableMetadata returns the cached metadata of table
+func (c *BigQueryClient) tableMetadata(ctx context.Context, table string) (*bigquery.TableMetadata, error) {
+	cacheKey := fmt.Sprintf("bigquery-table:%s", table)
+	if cached, found := c.cache.Get(cacheKey); found {
+		return cached.(*bigquery.TableMetadata), nil
+	}
+
+	project, dataset := c.config.ProjectID, c.config.DatasetID
+	parts := strings.Split(strings.Trim(table, "`"), ".")
+	switch len(parts) {
+	case 1:
+		table = parts[0]
+	case 2:
+		dataset, table = parts[0], parts[1]
+	case 3:
+		project, dataset, table = parts[0], parts[1], parts[2]
+	default:
+		return nil, fmt.Errorf("invalid table name %q", table)
+	}
+	if dataset == "" {
+		return nil, fmt.Errorf("table %q needs a dataset", table)
+	}
+
+	metadata, err := c.client.DatasetInProject(project, dataset).Table(table).Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.cache.Set(cacheKey, metadata, cache.DefaultExpiration)
+	return metadata, nil
+}
+
+// TestConnection verifies credentials and connectivity by listing at most one
+// dataset of the project; unlike a query it creates no job, so probes add no
+// billing or audit entries
 func (c *BigQueryClient) TestConnection(ctx context.Context) error {
-	query := c.client.Query("SELECT 1 as test")
+	it := c.client.Datasets(ctx)
+	it.PageInfo().MaxSize = 1
+	if _, err := it.Next(); err != nil && err != iterator.Done {
+		return err
+	}
+	return nil
+}
+
+// TestQuery runs SELECT 1 as a query job, checking job creation end to end
+func (c *BigQueryClient) TestQuery(ctx context.Context) error {
+	query := c.newQuery("SELECT 1 as test")
 	_, err := query.Read(ctx)
 	return err
 }
@@ -191,23 +436,29 @@ func convertBigQueryValue(v bigquery.Value) interface{} {
 			result[k] = convertBigQueryValue(item)
 		}
 		return result
+	case *big.Rat:
+		// NUMERIC within arrays and structs, exact; see numeric.Rat
+		return bigQueryDecimal(val, nil)
 	default:
 		// Return primitive types as-is
 		return val
 	}
 }
 
-// isReadOnlySQL validates that a SQL query is read-only
+// isReadOnlySQL validates that a SQL query is read-only. Forbidden keywords
+// are looked for in sql as submitted as well as without its comments, so a
+// comment read where BigQuery reads text can only reject a query.
 func isReadOnlySQL(sql string) bool {
-	sql = strings.ToUpper(strings.TrimSpace(sql))
+	normalized := strings.ToUpper(sqltext.Normalize(sql, sqltext.BigQuery))
+	submitted := strings.ToUpper(sql)
 
 	forbidden := []string{"INSERT", "UPDATE", "DELETE", "DROP", "CREATE", "ALTER", "TRUNCATE", "MERGE"}
 
 	for _, keyword := range forbidden {
-		if strings.Contains(sql, keyword) {
+		if strings.Contains(normalized, keyword) || strings.Contains(submitted, keyword) {
 			return false
 		}
 	}
 
-	return strings.HasPrefix(sql, "SELECT") || strings.HasPrefix(sql, "WITH")
+	return strings.HasPrefix(normalized, "SELECT") || strings.HasPrefix(normalized, "WITH")
 }
@@ -20,11 +20,22 @@ const (
 	MaxBytesPerQuery    = BytesPerTB * 10 // 10TB max per query (safety limit)
 )
 
+// LabelTask labels the jobs the gateway runs for itself, such as the cost
+// reports, rather than for a caller; usage reports leave them out
+const LabelTask = "gateway_task"
+
+// withoutTaskJobs is the INFORMATION_SCHEMA.JOBS condition that leaves out
+// the gateway's own jobs
+var withoutTaskJobs = fmt.Sprintf("NOT EXISTS (SELECT 1 FROM UNNEST(labels) WHERE key = '%s')", LabelTask)
+
 // QueryCostEstimator provides BigQuery query cost estimation
 type QueryCostEstimator struct {
 	client   *bigquery.Client
+	jobs     queryCreator
 	logger   *zap.Logger
 	project  string
+	location string // Region of the query jobs and of INFORMATION_SCHEMA.JOBS
+	labels   map[string]string // Job labels of the usage report queries
 	monthlyUsage float64 // Track monthly usage in GB
 }
 
@@ -41,13 +52,39 @@ type CostEstimate struct {
 	Timestamp          time.Time `json:"timestamp"`
 }
 
-// NewQueryCostEstimator creates a new cost estimator
-func NewQueryCostEstimator(client *bigquery.Client, projectID string, logger *zap.Logger) *QueryCostEstimator {
+// NewQueryCostEstimator creates a new cost estimator. Usage reports read the
+// job history of location, the US multi-region when it is empty.
+func NewQueryCostEstimator(client *bigquery.Client, projectID, location string, logger *zap.Logger) *QueryCostEstimator {
 	return &QueryCostEstimator{
-		client:  client,
-		logger:  logger,
-		project: projectID,
+		client:   client,
+		jobs:     client,
+		logger:   logger,
+		project:  projectID,
+		location: location,
+	}
+}
+
+// SetJobLabels labels the jobs of the usage reports, e.g. with LabelTask so
+// they are left out of the reports themselves
+func (e *QueryCostEstimator) SetJobLabels(labels map[string]string) {
+	e.labels = labels
+}
+
+// reportQuery creates the query job of a usage report
+func (e *QueryCostEstimator) reportQuery(query string) *bigquery.Query {
+	q := newLocatedQuery(e.jobs, query, e.location)
+	q.Labels = e.labels
+	return q
+}
+
+// jobsView is the INFORMATION_SCHEMA.JOBS view of the project's jobs in the
+// estimator's location
+func (e *QueryCostEstimator) jobsView() string {
+	region := "us"
+	if e.location != "" {
+		region = strings.ToLower(e.location)
 	}
+	return fmt.Sprintf("`%s`.`region-%s`.INFORMATION_SCHEMA.JOBS", e.project, region)
 }
 
 // EstimateQueryCost estimates the cost of a BigQuery query without running it
@@ -58,7 +95,7 @@ func (e *QueryCostEstimator) EstimateQueryCost(ctx context.Context, query string
 	}
 
 	// Create a dry run query to get statistics
-	q := e.client.Query(query)
+	q := newLocatedQuery(e.jobs, query, e.location)
 	q.DryRun = true // This makes BigQuery only estimate, not execute
 
 	job, err := q.Run(ctx)
@@ -190,15 +227,15 @@ func (e *QueryCostEstimator) GetMonthlyUsage(ctx context.Context) (float64, erro
 	query := fmt.Sprintf(`
 		SELECT
 			SUM(total_bytes_processed) as total_bytes
-		FROM %s.region-us.INFORMATION_SCHEMA.JOBS
+		FROM %s
 		WHERE
 			DATE(creation_time) >= DATE_TRUNC(CURRENT_DATE(), MONTH)
 			AND job_type = 'QUERY'
 			AND state = 'DONE'
-	`, "`"+e.project+"`")
+			AND %s
+	`, e.jobsView(), withoutTaskJobs)
 
-	q := e.client.Query(query)
-	it, err := q.Read(ctx)
+	it, err := e.reportQuery(query).Read(ctx)
 	if err != nil {
 		return 0, fmt.Errorf("failed to query monthly usage: %w", err)
 	}
@@ -324,17 +361,17 @@ func (e *QueryCostEstimator) GetCostReport(ctx context.Context, days int) (map[s
 			SUM(total_bytes_processed) as total_bytes,
 			SUM(total_bytes_billed) as total_bytes_billed,
 			AVG(total_slot_ms) as avg_slot_ms
-		FROM %s.region-us.INFORMATION_SCHEMA.JOBS
+		FROM %s
 		WHERE
 			DATE(creation_time) >= DATE_SUB(CURRENT_DATE(), INTERVAL %d DAY)
 			AND job_type = 'QUERY'
 			AND state = 'DONE'
+			AND %s
 		GROUP BY query_date
 		ORDER BY query_date DESC
-	`, "`"+e.project+"`", days)
+	`, e.jobsView(), days, withoutTaskJobs)
 
-	q := e.client.Query(query)
-	it, err := q.Read(ctx)
+	it, err := e.reportQuery(query).Read(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate cost report: %w", err)
 	}
@@ -376,4 +413,60 @@ func (e *QueryCostEstimator) GetCostReport(ctx context.Context, days int) (map[s
 		"daily_costs":    dailyCosts,
 		"avg_daily_cost": totalCost / float64(days),
 	}, nil
-}
\ No newline at end of file
+}
+// KeySpend is the BigQuery spend of the gateway's queries for one API key
+// this month, at list price without the free tier
+type KeySpend struct {
+	APIKeyID    string  `json:"api_key_id"` // Empty for queries without a key
+	Queries     int64   `json:"queries"`
+	BytesBilled int64   `json:"bytes_billed"`
+	CostUSD     float64 `json:"cost_usd"`
+}
+
+// GetKeySpend returns this month's spend of the gateway's queries by the
+// api_key_id job label they were attributed with
+func (e *QueryCostEstimator) GetKeySpend(ctx context.Context) ([]KeySpend, error) {
+	query := fmt.Sprintf(`
+		SELECT
+			IFNULL((SELECT value FROM UNNEST(labels) WHERE key = 'api_key_id'), '') as api_key_id,
+			COUNT(*) as query_count,
+			SUM(total_bytes_billed) as total_bytes_billed
+		FROM %s
+		WHERE
+			DATE(creation_time) >= DATE_TRUNC(CURRENT_DATE(), MONTH)
+			AND job_type = 'QUERY'
+			AND state = 'DONE'
+			AND EXISTS (SELECT 1 FROM UNNEST(labels) WHERE key = 'gateway' AND value = 'true')
+			AND %s
+		GROUP BY api_key_id
+		ORDER BY api_key_id
+	`, e.jobsView(), withoutTaskJobs)
+
+	it, err := e.reportQuery(query).Read(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query key spend: %w", err)
+	}
+
+	var spend []KeySpend
+	for {
+		var row []bigquery.Value
+		err := it.Next(&row)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key spend: %w", err)
+		}
+		if len(row) < 3 {
+			continue
+		}
+
+		key := KeySpend{}
+		key.APIKeyID, _ = row[0].(string)
+		key.Queries, _ = row[1].(int64)
+		key.BytesBilled, _ = row[2].(int64)
+		key.CostUSD = math.Round(float64(key.BytesBilled)/float64(BytesPerTB)*CostPerTB*10000) / 10000
+		spend = append(spend, key)
+	}
+	return spend, nil
+}
@@ -0,0 +1,43 @@
+package clients
+
+import (
+	"context"
+
+	"cloud.google.com/go/bigquery"
+	"go.uber.org/zap"
+)
+
+// JobStatsKey is the key of the *JobStats in the map results of
+// ExecuteLabeledQuery
+const JobStatsKey = "bigquery_job"
+
+// JobStats are the statistics of the BigQuery job that ran a query, to
+// reconcile the BigQuery bill with the gateway's traffic
+type JobStats struct {
+	JobID               string `json:"job_id"`
+	TotalBytesProcessed int64  `json:"total_bytes_processed"`
+	TotalBytesBilled    int64  `json:"total_bytes_billed"`
+	CacheHit            bool   `json:"cache_hit"` // Answered from BigQuery's own result cache, unbilled
+	SlotMillis          int64  `json:"slot_ms"`
+}
+
+// jobStats fetches the statistics of a completed job. Waiting for the rows
+// does not return them, so they cost one more call; when it fails, the job
+// id is still reported.
+func (c *BigQueryClient) jobStats(ctx context.Context, job *bigquery.Job) *JobStats {
+	stats := &JobStats{JobID: job.ID()}
+	status, err := job.Status(ctx)
+	if err != nil {
+		c.logger.Warn("Failed to read BigQuery job statistics", zap.String("job_id", stats.JobID), zap.Error(err))
+		return stats
+	}
+	if s := status.Statistics; s != nil {
+		stats.TotalBytesProcessed = s.TotalBytesProcessed
+		if q, ok := s.Details.(*bigquery.QueryStatistics); ok {
+			stats.TotalBytesBilled = q.TotalBytesBilled
+			stats.CacheHit = q.CacheHit
+			stats.SlotMillis = q.SlotMillis
+		}
+	}
+	return stats
+}
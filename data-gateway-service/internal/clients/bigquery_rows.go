@@ -0,0 +1,137 @@
+package clients
+
+import (
+	"math/big"
+	"slices"
+
+	"cloud.google.com/go/bigquery"
+	"go.uber.org/zap"
+	"google.golang.org/api/iterator"
+
+	"go-data-gateway/internal/colnames"
+	"go-data-gateway/internal/numeric"
+)
+
+// queryRows are the rows of a query keyed by column name, the columns
+// renamed because the result repeated their name, the NUMERIC columns sent
+// as strings, and the statistics of the job that read them
+type queryRows struct {
+	rows          []map[string]interface{}
+	renamed       map[string]string
+	stringNumbers []string
+	stats         *JobStats // nil when served from the client's cache
+}
+
+// readRows reads every row of it. Unlike the SDK's map loader, which keeps
+// the last of the columns sharing a name, every column is kept: repeats are
+// renamed with a numeric suffix and the renames logged.
+func (c *BigQueryClient) readRows(it *bigquery.RowIterator) (*queryRows, error) {
+	result := &queryRows{}
+	var names []string
+	for {
+		var values []bigquery.Value
+		err := it.Next(&values)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if names == nil {
+			names, result.renamed = uniqueNames(it.Schema)
+			if result.renamed != nil {
+				c.logger.Warn("Result has repeated column names, renaming the repeats",
+					zap.Any("renamed", result.renamed))
+			}
+			result.stringNumbers = stringNumbers(names, it.Schema)
+		}
+
+		row := make(map[string]interface{}, len(values))
+		for i, value := range values {
+			if r, ok := value.(*big.Rat); ok {
+				row[names[i]] = bigQueryDecimal(r, it.Schema[i])
+				continue
+			}
+			row[names[i]] = convertBigQueryValue(recordValue(value, it.Schema[i]))
+		}
+		result.rows = append(result.rows, row)
+	}
+	return result, nil
+}
+
+// uniqueNames returns the column names of schema with repeats renamed
+func uniqueNames(schema bigquery.Schema) ([]string, map[string]string) {
+	names := make([]string, len(schema))
+	for i, field := range schema {
+		names[i] = field.Name
+	}
+	return colnames.Unique(names, nil)
+}
+
+// decimalType returns the precision and scale of a NUMERIC or BIGNUMERIC
+// field, as declared or else its type's default; nil is a NUMERIC
+func decimalType(field *bigquery.FieldSchema) (precision, scale int) {
+	switch {
+	case field != nil && field.Precision > 0:
+		return int(field.Precision), int(field.Scale)
+	case field != nil && field.Type == bigquery.BigNumericFieldType:
+		return 76, 38
+	default:
+		return 38, 9
+	}
+}
+
+// bigQueryDecimal converts r, a value of field, with numeric.Rat
+func bigQueryDecimal(r *big.Rat, field *bigquery.FieldSchema) interface{} {
+	precision, scale := decimalType(field)
+	return numeric.Rat(r, precision, scale)
+}
+
+// stringNumbers returns the columns of schema, named names, whose NUMERIC
+// values are sent as strings, sorted
+func stringNumbers(names []string, schema bigquery.Schema) []string {
+	var columns []string
+	for i, field := range schema {
+		if field.Type != bigquery.NumericFieldType && field.Type != bigquery.BigNumericFieldType {
+			continue
+		}
+		if numeric.AsString(decimalType(field)) {
+			columns = append(columns, names[i])
+		}
+	}
+	slices.Sort(columns)
+	return columns
+}
+
+// recordValue turns the values of a RECORD column, which a []Value row holds
+// as []Value, into the maps the SDK's map loader gives them as
+func recordValue(value bigquery.Value, field *bigquery.FieldSchema) bigquery.Value {
+	if value == nil || field.Schema == nil {
+		return value
+	}
+	values, ok := value.([]bigquery.Value)
+	if !ok {
+		return value
+	}
+	if !field.Repeated {
+		return recordMap(values, field.Schema)
+	}
+	records := make([]bigquery.Value, len(values))
+	for i, record := range values {
+		if fields, ok := record.([]bigquery.Value); ok {
+			records[i] = recordMap(fields, field.Schema)
+		}
+	}
+	return records
+}
+
+// recordMap keys the values of one record by the names of schema
+func recordMap(values []bigquery.Value, schema bigquery.Schema) map[string]bigquery.Value {
+	record := make(map[string]bigquery.Value, len(schema))
+	for i, field := range schema {
+		if i < len(values) {
+			record[field.Name] = recordValue(values[i], field)
+		}
+	}
+	return record
+}
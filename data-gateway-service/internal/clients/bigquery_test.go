@@ -0,0 +1,352 @@
+package clients
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/api/option"
+
+	"go-data-gateway/internal/colnames"
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/metrics"
+	"go-data-gateway/internal/numeric"
+)
+
+// recordingJobs records the SQL of the query jobs it creates
+type recordingJobs struct {
+	sql []string
+}
+
+func (j *recordingJobs) Query(q string) *bigquery.Query {
+	query := &bigquery.Query{}
+	query.Q = q
+	j.sql = append(j.sql, q)
+	return query
+}
+
+func TestBigQueryClient_QueriesRunInConfiguredLocation(t *testing.T) {
+	jobs := &recordingJobs{}
+	client := &BigQueryClient{jobs: jobs, config: config.BigQueryConfig{ProjectID: "lkpp", Location: "asia-southeast2"}}
+
+	q := client.newQuery("SELECT 1")
+	assert.Equal(t, "asia-southeast2", q.Location)
+	assert.Equal(t, []string{"SELECT 1"}, jobs.sql)
+
+	client.config.Location = ""
+	assert.Empty(t, client.newQuery("SELECT 1").Location)
+}
+
+func TestQueryCostEstimator_ReadsJobsOfItsRegion(t *testing.T) {
+	estimator := NewQueryCostEstimator(nil, "lkpp", "asia-southeast2", zap.NewNop())
+	estimator.jobs = &recordingJobs{}
+
+	assert.Equal(t, "`lkpp`.`region-asia-southeast2`.INFORMATION_SCHEMA.JOBS", estimator.jobsView())
+	assert.Equal(t, "asia-southeast2", newLocatedQuery(estimator.jobs, "SELECT 1", estimator.location).Location)
+
+	estimator = NewQueryCostEstimator(nil, "lkpp", "US", zap.NewNop())
+	assert.Equal(t, "`lkpp`.`region-us`.INFORMATION_SCHEMA.JOBS", estimator.jobsView())
+
+	estimator = NewQueryCostEstimator(nil, "lkpp", "", zap.NewNop())
+	assert.Equal(t, "`lkpp`.`region-us`.INFORMATION_SCHEMA.JOBS", estimator.jobsView())
+}
+
+func TestQueryCostEstimator_LabelsReportQueries(t *testing.T) {
+	estimator := NewQueryCostEstimator(nil, "lkpp", "asia-southeast2", zap.NewNop())
+	estimator.jobs = &recordingJobs{}
+
+	assert.Empty(t, estimator.reportQuery("SELECT 1").Labels)
+
+	estimator.SetJobLabels(map[string]string{LabelTask: "cost_metrics"})
+	q := estimator.reportQuery("SELECT 1")
+	assert.Equal(t, map[string]string{LabelTask: "cost_metrics"}, q.Labels)
+	assert.Equal(t, "asia-southeast2", q.Location)
+	assert.Equal(t, "NOT EXISTS (SELECT 1 FROM UNNEST(labels) WHERE key = 'gateway_task')", withoutTaskJobs)
+}
+
+// fakeBigQueryJobs is a BigQuery API whose query jobs never complete; it
+// records the job timeout they were inserted with and the jobs cancelled
+type fakeBigQueryJobs struct {
+	mu           sync.Mutex
+	jobTimeoutMs string
+	cancelled    []string
+}
+
+func (f *fakeBigQueryJobs) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/cancel"):
+		parts := strings.Split(r.URL.Path, "/")
+		f.mu.Lock()
+		f.cancelled = append(f.cancelled, parts[len(parts)-2])
+		f.mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{})
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/jobs"):
+		var job struct {
+			JobReference map[string]string `json:"jobReference"`
+			Config       struct {
+				JobTimeoutMs string `json:"jobTimeoutMs"`
+			} `json:"configuration"`
+		}
+		json.NewDecoder(r.Body).Decode(&job)
+		f.mu.Lock()
+		f.jobTimeoutMs = job.Config.JobTimeoutMs
+		f.mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"jobReference":  job.JobReference,
+			"configuration": map[string]interface{}{"query": map[string]interface{}{"query": "SELECT 1"}},
+			"status":        map[string]interface{}{"state": "RUNNING"},
+		})
+	default: // getQueryResults
+		json.NewEncoder(w).Encode(map[string]interface{}{"jobComplete": false})
+	}
+}
+
+func TestBigQueryClient_CancelsJobWhenContextExpires(t *testing.T) {
+	fake := &fakeBigQueryJobs{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	client, err := NewBigQueryClient(config.BigQueryConfig{ProjectID: "test-project"}, zap.NewNop(),
+		option.WithEndpoint(srv.URL),
+		option.WithHTTPClient(srv.Client()),
+		option.WithoutAuthentication())
+	require.NoError(t, err)
+	defer client.Close()
+	counter := metrics.NewCancelCounter()
+	client.SetJobCancels(counter)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	_, err = client.Query(ctx, "SELECT 1")
+	require.Error(t, err)
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	require.Len(t, fake.cancelled, 1)
+	timeout, _ := strconv.Atoi(fake.jobTimeoutMs)
+	assert.Positive(t, timeout)
+	assert.LessOrEqual(t, timeout, 300)
+
+	var buf bytes.Buffer
+	counter.WritePrometheus(&buf)
+	assert.Contains(t, buf.String(), `go_gateway_upstream_jobs_cancelled_total{source="bigquery"} 1`)
+}
+
+func TestBigQueryClient_PrivateEndpoint(t *testing.T) {
+	fake := &fakeBigQueryJobs{}
+	srv := httptest.NewUnstartedServer(fake)
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0) // The rejected handshake
+	srv.StartTLS()
+	defer srv.Close()
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0o600))
+
+	query := func(cfg config.BigQueryConfig) error {
+		client, err := NewBigQueryClient(cfg, zap.NewNop(), option.WithoutAuthentication())
+		require.NoError(t, err)
+		defer client.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+		defer cancel()
+		_, err = client.Query(ctx, "SELECT 1")
+		return err
+	}
+
+	// The endpoint's certificate is only trusted with the CA file
+	err := query(config.BigQueryConfig{ProjectID: "test-project", Endpoint: srv.URL})
+	assert.ErrorContains(t, err, "certificate")
+	fake.mu.Lock()
+	assert.Empty(t, fake.jobTimeoutMs)
+	fake.mu.Unlock()
+
+	require.Error(t, query(config.BigQueryConfig{ProjectID: "test-project", Endpoint: srv.URL, CAFile: caFile}))
+	fake.mu.Lock()
+	assert.NotEmpty(t, fake.jobTimeoutMs, "the job reached the private endpoint")
+	fake.mu.Unlock()
+}
+
+func TestClientOptions(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := clientOptions(ctx, config.BigQueryConfig{})
+	require.NoError(t, err)
+	assert.Empty(t, opts)
+
+	opts, err = clientOptions(ctx, config.BigQueryConfig{Endpoint: "https://bigquery-psc.p.googleapis.com/bigquery/v2/"})
+	require.NoError(t, err)
+	assert.Len(t, opts, 1)
+
+	for _, endpoint := range []string{"http://bigquery.internal", "bigquery.internal:443", "https://"} {
+		_, err = clientOptions(ctx, config.BigQueryConfig{Endpoint: endpoint})
+		assert.ErrorContains(t, err, "must be an https URL", endpoint)
+	}
+
+	_, err = clientOptions(ctx, config.BigQueryConfig{CAFile: filepath.Join(t.TempDir(), "missing.pem")})
+	assert.ErrorContains(t, err, "BigQuery CA file")
+}
+
+// joinBigQueryJobs is a BigQuery API whose query jobs complete at once with
+// a JOIN result that repeats the id column, billed as 10 MiB
+type joinBigQueryJobs struct{}
+
+func (joinBigQueryJobs) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	done := map[string]interface{}{"state": "DONE"}
+	switch {
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/jobs"):
+		var job struct {
+			JobReference map[string]string `json:"jobReference"`
+		}
+		json.NewDecoder(r.Body).Decode(&job)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"jobReference":  job.JobReference,
+			"configuration": map[string]interface{}{"query": map[string]interface{}{"query": "SELECT"}},
+			"status":        done,
+		})
+	case strings.Contains(r.URL.Path, "/queries/"):
+		parts := strings.Split(r.URL.Path, "/")
+		field := func(name, kind string) map[string]interface{} {
+			return map[string]interface{}{"name": name, "type": kind}
+		}
+		lokasi := field("lokasi", "RECORD")
+		lokasi["fields"] = []interface{}{field("kota", "STRING")}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"jobComplete":  true,
+			"jobReference": map[string]string{"projectId": "test-project", "jobId": parts[len(parts)-1]},
+			"schema":       map[string]interface{}{"fields": []interface{}{field("id", "STRING"), field("id", "INTEGER"), lokasi}},
+			"totalRows":    "1",
+			"rows": []interface{}{map[string]interface{}{"f": []interface{}{
+				map[string]interface{}{"v": "K1"},
+				map[string]interface{}{"v": "7"},
+				map[string]interface{}{"v": map[string]interface{}{"f": []interface{}{map[string]interface{}{"v": "Bandung"}}}},
+			}}},
+		})
+	default: // jobs.get
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"configuration": map[string]interface{}{"query": map[string]interface{}{"query": "SELECT"}},
+			"status":        done,
+			"statistics": map[string]interface{}{
+				"totalBytesProcessed": "1048576",
+				"query":               map[string]interface{}{"totalBytesProcessed": "1048576", "totalBytesBilled": "10485760", "totalSlotMs": "1234"},
+			},
+		})
+	}
+}
+
+func TestBigQueryClient_DuplicateColumns(t *testing.T) {
+	srv := httptest.NewServer(joinBigQueryJobs{})
+	defer srv.Close()
+	client, err := NewBigQueryClient(config.BigQueryConfig{ProjectID: "test-project"}, zap.NewNop(),
+		option.WithEndpoint(srv.URL),
+		option.WithHTTPClient(srv.Client()),
+		option.WithoutAuthentication())
+	require.NoError(t, err)
+	defer client.Close()
+
+	// Both ids are kept; the repeat is renamed and reported with the rows,
+	// along with the statistics of the job
+	result, err := client.ExecuteLabeledQuery(context.Background(), "SELECT r.id, k.id, r.lokasi FROM rup r JOIN kro k USING (kd_kro)", nil)
+	require.NoError(t, err)
+	wantRows := []map[string]interface{}{{"id": "K1", "id_1": int64(7), "lokasi": map[string]interface{}{"kota": "Bandung"}}}
+	resultMap, ok := result.(map[string]interface{})
+	require.True(t, ok)
+	assert.Equal(t, wantRows, resultMap["data"])
+	assert.Equal(t, map[string]string{"id_1": "id"}, resultMap[colnames.MetaKey])
+	stats, ok := resultMap[JobStatsKey].(*JobStats)
+	require.True(t, ok)
+	assert.NotEmpty(t, stats.JobID)
+	assert.Equal(t, JobStats{JobID: stats.JobID, TotalBytesProcessed: 1 << 20, TotalBytesBilled: 10 << 20, SlotMillis: 1234}, *stats)
+
+	// Query returns the rows alone, from the cache
+	rows, err := client.Query(context.Background(), "SELECT r.id, k.id, r.lokasi FROM rup r JOIN kro k USING (kd_kro)")
+	require.NoError(t, err)
+	assert.Equal(t, wantRows, rows)
+}
+
+// numericBigQueryJobs is joinBigQueryJobs answering with NUMERIC, BIGNUMERIC
+// and parameterized NUMERIC columns
+type numericBigQueryJobs struct {
+	joinBigQueryJobs
+}
+
+func (j numericBigQueryJobs) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.Contains(r.URL.Path, "/queries/") {
+		j.joinBigQueryJobs.ServeHTTP(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	parts := strings.Split(r.URL.Path, "/")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"jobComplete":  true,
+		"jobReference": map[string]string{"projectId": "test-project", "jobId": parts[len(parts)-1]},
+		"schema": map[string]interface{}{"fields": []interface{}{
+			map[string]interface{}{"name": "nilai_pagu", "type": "NUMERIC"},
+			map[string]interface{}{"name": "total", "type": "BIGNUMERIC"},
+			map[string]interface{}{"name": "kode", "type": "NUMERIC", "precision": "18", "scale": "0"},
+			map[string]interface{}{"name": "nilai", "type": "NUMERIC", "precision": "15", "scale": "2"},
+		}},
+		"totalRows": "1",
+		"rows": []interface{}{map[string]interface{}{"f": []interface{}{
+			map[string]interface{}{"v": "123456789012345678.123456789"},
+			map[string]interface{}{"v": "1500000000000.25"},
+			map[string]interface{}{"v": "123456789012345678"},
+			map[string]interface{}{"v": "1500000000000.5"},
+		}}},
+	})
+}
+
+func TestBigQueryClient_NumericPrecision(t *testing.T) {
+	srv := httptest.NewServer(numericBigQueryJobs{})
+	defer srv.Close()
+	client, err := NewBigQueryClient(config.BigQueryConfig{ProjectID: "test-project"}, zap.NewNop(),
+		option.WithEndpoint(srv.URL),
+		option.WithHTTPClient(srv.Client()),
+		option.WithoutAuthentication())
+	require.NoError(t, err)
+	defer client.Close()
+
+	result, err := client.ExecuteLabeledQuery(context.Background(), "SELECT nilai_pagu, total, kode, nilai FROM rup", nil)
+	require.NoError(t, err)
+	resultMap, ok := result.(map[string]interface{})
+	require.True(t, ok)
+	assert.Equal(t, []map[string]interface{}{{
+		"nilai_pagu": "123456789012345678.123456789",
+		"total":      "1500000000000.25",
+		"kode":       int64(123456789012345678),
+		"nilai":      json.Number("1500000000000.5"),
+	}}, resultMap["data"])
+	assert.Equal(t, []string{"nilai_pagu", "total"}, resultMap[numeric.MetaKey])
+}
+
+func TestIsReadOnlySQL_QuotedCommentMarkers(t *testing.T) {
+	// BigQuery honours backslash escapes in every quote style, so these
+	// comment markers are quoted and the writes after them run
+	assert.False(t, isReadOnlySQL(`SELECT "a\" -- " AS x; DROP TABLE ds.t`))
+	assert.False(t, isReadOnlySQL("SELECT `a\\` -- ` FROM t; DELETE FROM ds.t WHERE true"))
+	assert.False(t, isReadOnlySQL(`SELECT 'a\' -- ' AS x; DROP TABLE ds.t`))
+
+	// Triple-quoted and raw literals end where BigQuery ends them, and a
+	// keyword in a comment rejects the query anyway
+	assert.False(t, isReadOnlySQL("SELECT '''x'y -- ''' ; DROP TABLE ds.t"))
+	assert.False(t, isReadOnlySQL("SELECT r'\\' , '-- ' ; DROP TABLE ds.t"))
+	assert.False(t, isReadOnlySQL(`SELECT "a\"b" AS x FROM t -- drop later`))
+
+	assert.True(t, isReadOnlySQL(`SELECT "a\"b" AS x FROM t -- latest only`))
+	assert.True(t, isReadOnlySQL("SELECT `p.d.t`, '''it's''', r'\\d+' FROM t /* weekly */"))
+}
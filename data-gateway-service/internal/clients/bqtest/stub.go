@@ -0,0 +1,276 @@
+// Package bqtest provides a stand-in for the gateway's BigQuery client, so
+// BigQuery data sources and handlers can be tested without a project.
+package bqtest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"google.golang.org/api/googleapi"
+
+	"go-data-gateway/internal/clients"
+	"go-data-gateway/internal/metrics"
+	"go-data-gateway/internal/sqltext"
+)
+
+// Query is a query the stub received with the job labels it carried
+type Query struct {
+	SQL    string
+	Labels map[string]string
+}
+
+// rule answers the queries containing fragment with rows or err
+type rule struct {
+	fragment string
+	rows     []map[string]interface{}
+	err      error
+}
+
+// Stub implements clients.BigQuerier in memory. A query is answered by the
+// first rule, in the order they were added, whose fragment it contains; a
+// query matching none returns no rows.
+type Stub struct {
+	mu      sync.Mutex
+	rules   []rule
+	schemas map[string]bigquery.Schema
+	sizes   map[string][2]int64
+	latency time.Duration
+	bytes   int64
+	stats   *clients.JobStats
+	jobs    int
+	down    error
+	queries []Query
+	dryRuns []string
+	closed  bool
+}
+
+var _ clients.BigQuerier = (*Stub)(nil)
+
+// NewStub returns a stub without rules or tables
+func NewStub() *Stub {
+	return &Stub{schemas: make(map[string]bigquery.Schema), sizes: make(map[string][2]int64)}
+}
+
+// Respond answers the queries containing fragment with rows
+func (s *Stub) Respond(fragment string, rows []map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules = append(s.rules, rule{fragment: fragment, rows: rows})
+}
+
+// Fail fails the queries and dry runs containing fragment with err; an empty
+// fragment fails them all
+func (s *Stub) Fail(fragment string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules = append(s.rules, rule{fragment: fragment, err: err})
+}
+
+// SetSchema sets the schema TableSchema returns for table
+func (s *Stub) SetSchema(table string, schema bigquery.Schema) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.schemas[table] = schema
+}
+
+// SetTableSize sets the rows and bytes TableSize returns for table
+func (s *Stub) SetTableSize(table string, rows, bytes int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sizes[table] = [2]int64{rows, bytes}
+}
+
+// SetLatency delays every query by d, or until its context is done
+func (s *Stub) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// SetBytesProcessed sets the bytes a dry run reports
+func (s *Stub) SetBytesProcessed(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bytes = n
+}
+
+// SetJobStats makes ExecuteLabeledQuery report stats as the statistics of
+// each job it runs, under a job id of its own
+func (s *Stub) SetJobStats(stats clients.JobStats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats = &stats
+}
+
+// SetDown makes the connection checks fail with err; nil brings them back
+func (s *Stub) SetDown(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.down = err
+}
+
+// Queries returns the queries run, in order
+func (s *Stub) Queries() []Query {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Query(nil), s.queries...)
+}
+
+// DryRuns returns the queries dry run, in order
+func (s *Stub) DryRuns() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.dryRuns...)
+}
+
+// Closed reports whether Close was called
+func (s *Stub) Closed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// Query runs sqlQuery by the stub's rules
+func (s *Stub) Query(ctx context.Context, sqlQuery string) ([]map[string]interface{}, error) {
+	return s.run(ctx, sqlQuery, nil)
+}
+
+// ExecuteLabeledQuery runs query by the stub's rules, recording its labels.
+// Like BigQueryClient it refuses anything but SELECT queries, and reports
+// the job's statistics with the rows once SetJobStats was called.
+func (s *Stub) ExecuteLabeledQuery(ctx context.Context, query string, labels map[string]string) (interface{}, error) {
+	if !isSelect(query) {
+		return nil, fmt.Errorf("only SELECT queries are allowed")
+	}
+	rows, err := s.run(ctx, query, labels)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stats == nil {
+		return rows, nil
+	}
+	s.jobs++
+	stats := *s.stats
+	stats.JobID = fmt.Sprintf("stub_job_%d", s.jobs)
+	return map[string]interface{}{"data": rows, clients.JobStatsKey: &stats}, nil
+}
+
+// DryRun reports the bytes set with SetBytesProcessed, or the error of a
+// failing rule
+func (s *Stub) DryRun(ctx context.Context, sqlQuery string) (int64, error) {
+	if !isSelect(sqlQuery) {
+		return 0, fmt.Errorf("only SELECT queries are allowed")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dryRuns = append(s.dryRuns, sqlQuery)
+	if r, ok := s.match(sqlQuery); ok && r.err != nil {
+		return 0, r.err
+	}
+	return s.bytes, nil
+}
+
+// TableSchema returns the schema set for table with SetSchema
+func (s *Stub) TableSchema(ctx context.Context, table string) (bigquery.Schema, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	schema, ok := s.schemas[strings.Trim(table, "`")]
+	if !ok {
+		return nil, &googleapi.Error{Code: http.StatusNotFound, Message: "Not found: Table " + table}
+	}
+	return schema, nil
+}
+
+// TableSize returns the size set for table with SetTableSize
+func (s *Stub) TableSize(ctx context.Context, table string) (rows, bytes int64, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	size, ok := s.sizes[strings.Trim(table, "`")]
+	if !ok {
+		return 0, 0, &googleapi.Error{Code: http.StatusNotFound, Message: "Not found: Table " + table}
+	}
+	return size[0], size[1], nil
+}
+
+// TestConnection fails with the error of SetDown
+func (s *Stub) TestConnection(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.down
+}
+
+// TestQuery fails with the error of SetDown
+func (s *Stub) TestQuery(ctx context.Context) error {
+	return s.TestConnection(ctx)
+}
+
+// SetJobCancels does nothing; the stub runs no jobs to cancel
+func (s *Stub) SetJobCancels(counter *metrics.CancelCounter) {}
+
+// Close marks the stub closed
+func (s *Stub) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+// run records a query and answers it after the latency
+func (s *Stub) run(ctx context.Context, sqlQuery string, labels map[string]string) ([]map[string]interface{}, error) {
+	s.mu.Lock()
+	s.queries = append(s.queries, Query{SQL: sqlQuery, Labels: labels})
+	latency := s.latency
+	r, ok := s.match(sqlQuery)
+	s.mu.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if !ok {
+		return []map[string]interface{}{}, nil
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	rows := make([]map[string]interface{}, len(r.rows))
+	for i, row := range r.rows {
+		rows[i] = make(map[string]interface{}, len(row))
+		for k, v := range row {
+			rows[i][k] = v
+		}
+	}
+	return rows, nil
+}
+
+// match returns the first rule matching sqlQuery; s.mu is held
+func (s *Stub) match(sqlQuery string) (rule, bool) {
+	for _, r := range s.rules {
+		if strings.Contains(sqlQuery, r.fragment) {
+			return r, true
+		}
+	}
+	return rule{}, false
+}
+
+// isSelect reports whether query reads only, checked as BigQueryClient does
+func isSelect(query string) bool {
+	upper, submitted := strings.ToUpper(sqltext.Normalize(query, sqltext.BigQuery)), strings.ToUpper(query)
+	for _, keyword := range []string{"INSERT", "UPDATE", "DELETE", "DROP", "CREATE", "ALTER", "TRUNCATE", "MERGE"} {
+		if strings.Contains(upper, keyword) || strings.Contains(submitted, keyword) {
+			return false
+		}
+	}
+	return strings.HasPrefix(upper, "SELECT") || strings.HasPrefix(upper, "WITH")
+}
@@ -0,0 +1,74 @@
+package clients
+
+import (
+	"errors"
+	"sync"
+)
+
+// DremioCredentials authenticate a Dremio service account: a username and
+// password, or a token
+type DremioCredentials struct {
+	Username string
+	Password string
+	Token    string
+}
+
+// Validate checks that c holds a username and password or a token, not both
+func (c DremioCredentials) Validate() error {
+	password := c.Username != "" || c.Password != ""
+	switch {
+	case password && c.Token != "":
+		return errors.New("a username and password or a token, not both")
+	case password && (c.Username == "" || c.Password == ""):
+		return errors.New("a username needs a password")
+	case !password && c.Token == "":
+		return errors.New("a username and password or a token is required")
+	}
+	return nil
+}
+
+// Credentials holds the current credentials of one Dremio service account.
+// The Arrow Flight pools and REST clients of the account share it, so that a
+// rotation reaches all of them, including those connected later. It is safe
+// for concurrent use.
+type Credentials struct {
+	mu      sync.RWMutex
+	current DremioCredentials
+	version uint64
+}
+
+// NewCredentials holds initial
+func NewCredentials(initial DremioCredentials) *Credentials {
+	return &Credentials{current: initial}
+}
+
+// Get returns the current credentials and their version, which changes with
+// every Set that changes them
+func (c *Credentials) Get() (DremioCredentials, uint64) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.current, c.version
+}
+
+// Version returns the version of the current credentials; a nil Credentials
+// is always at version 0
+func (c *Credentials) Version() uint64 {
+	if c == nil {
+		return 0
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.version
+}
+
+// Set replaces the credentials and returns their version. Setting the
+// current credentials again keeps their version.
+func (c *Credentials) Set(creds DremioCredentials) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if creds != c.current {
+		c.current = creds
+		c.version++
+	}
+	return c.version
+}
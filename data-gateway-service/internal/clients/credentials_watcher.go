@@ -0,0 +1,183 @@
+package clients
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/joho/godotenv"
+	"go.uber.org/zap"
+)
+
+// credentialsRotateTimeout bounds a rotation the watcher starts
+const credentialsRotateTimeout = time.Minute
+
+// ReadCredentialsFile reads DREMIO_USERNAME and DREMIO_PASSWORD, or
+// DREMIO_TOKEN, from an env file such as a mounted secret
+func ReadCredentialsFile(path string) (DremioCredentials, error) {
+	values, err := godotenv.Read(path)
+	if err != nil {
+		return DremioCredentials{}, fmt.Errorf("failed to read credentials file: %w", err)
+	}
+	creds := DremioCredentials{
+		Username: values["DREMIO_USERNAME"],
+		Password: values["DREMIO_PASSWORD"],
+		Token:    values["DREMIO_TOKEN"],
+	}
+	if err := creds.Validate(); err != nil {
+		return DremioCredentials{}, fmt.Errorf("credentials file: %w", err)
+	}
+	return creds, nil
+}
+
+// CredentialsWatcher rotates the Dremio credentials when the credentials file
+// changes: its modification time or size, which a secret update also
+// changes. A rotation that fails, e.g. because Dremio does not accept the
+// new password yet, is logged, reported by LastError and retried every
+// interval; the previous credentials stay active.
+type CredentialsWatcher struct {
+	path     string
+	interval time.Duration
+	rotate   func(ctx context.Context, creds DremioCredentials) error
+	logger   *zap.Logger
+
+	mu          sync.Mutex
+	modTime     time.Time
+	size        int64
+	active      DremioCredentials
+	lastErr     error
+	lastErrTime time.Time
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewCredentialsWatcher creates a watcher of path, checked every interval,
+// that passes changed credentials to rotate. initial are the credentials
+// in use, so an unchanged file rotates nothing.
+func NewCredentialsWatcher(path string, interval time.Duration, initial DremioCredentials, rotate func(ctx context.Context, creds DremioCredentials) error, logger *zap.Logger) *CredentialsWatcher {
+	w := &CredentialsWatcher{
+		path:     path,
+		interval: interval,
+		rotate:   rotate,
+		logger:   logger,
+		active:   initial,
+		stop:     make(chan struct{}),
+	}
+	if info, err := os.Stat(path); err == nil {
+		w.modTime, w.size = info.ModTime(), info.Size()
+	}
+	return w
+}
+
+// Load reads the file and rotates to its credentials if they differ from the
+// active ones
+func (w *CredentialsWatcher) Load() error {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return w.fail(fmt.Errorf("failed to stat credentials file: %w", err))
+	}
+	w.mu.Lock()
+	w.modTime, w.size = info.ModTime(), info.Size()
+	active := w.active
+	w.mu.Unlock()
+
+	creds, err := ReadCredentialsFile(w.path)
+	if err != nil {
+		return w.fail(err)
+	}
+	if creds == active {
+		w.clearError()
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), credentialsRotateTimeout)
+	defer cancel()
+	if err := w.rotate(ctx, creds); err != nil {
+		return w.fail(err)
+	}
+
+	w.mu.Lock()
+	w.active = creds
+	w.mu.Unlock()
+	w.clearError()
+	w.logger.Info("Dremio credentials rotated from file",
+		zap.String("path", w.path),
+		zap.String("user", creds.Username))
+	return nil
+}
+
+// Start checks the file every interval until Stop
+func (w *CredentialsWatcher) Start() {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+			}
+
+			if !w.changed() {
+				continue
+			}
+			if err := w.Load(); err != nil {
+				w.logger.Error("Dremio credential rotation failed, keeping the active credentials",
+					zap.String("path", w.path),
+					zap.Error(err))
+			}
+		}
+	}()
+}
+
+// Stop ends the polling loop
+func (w *CredentialsWatcher) Stop() {
+	close(w.stop)
+	w.wg.Wait()
+}
+
+// LastError returns when the last load failed and its error, or a nil error
+// if it succeeded
+func (w *CredentialsWatcher) LastError() (time.Time, error) {
+	if w == nil {
+		return time.Time{}, nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.lastErrTime, w.lastErr
+}
+
+// changed reports whether the file differs from the last one loaded, or the
+// last load failed and is due for a retry
+func (w *CredentialsWatcher) changed() bool {
+	info, err := os.Stat(w.path)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err != nil {
+		return w.lastErr == nil
+	}
+	return w.lastErr != nil || !info.ModTime().Equal(w.modTime) || info.Size() != w.size
+}
+
+func (w *CredentialsWatcher) fail(err error) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.lastErr = err
+	w.lastErrTime = time.Now().UTC()
+	return err
+}
+
+func (w *CredentialsWatcher) clearError() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.lastErr = nil
+	w.lastErrTime = time.Time{}
+}
@@ -0,0 +1,92 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func writeCredentials(t *testing.T, path, data string, modTime time.Time) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestCredentialsWatcher_Rotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dremio.env")
+	start := time.Now().Add(-time.Hour)
+	writeCredentials(t, path, "DREMIO_USERNAME=svc\nDREMIO_PASSWORD=old\n", start)
+
+	initial, err := ReadCredentialsFile(path)
+	require.NoError(t, err)
+
+	var (
+		mu      sync.Mutex
+		rotated []DremioCredentials
+		refuse  = true
+	)
+	rotate := func(ctx context.Context, creds DremioCredentials) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if refuse {
+			return errors.New("authentication failed with status: 401")
+		}
+		rotated = append(rotated, creds)
+		return nil
+	}
+	watcher := NewCredentialsWatcher(path, 10*time.Millisecond, initial, rotate, zap.NewNop())
+	watcher.Start()
+	defer watcher.Stop()
+
+	// A rotation Dremio refuses is retried until it succeeds
+	writeCredentials(t, path, "DREMIO_USERNAME=svc\nDREMIO_PASSWORD=new\n", start.Add(time.Minute))
+	require.Eventually(t, func() bool {
+		_, err := watcher.LastError()
+		return err != nil
+	}, 2*time.Second, 10*time.Millisecond)
+
+	mu.Lock()
+	refuse = false
+	mu.Unlock()
+	require.Eventually(t, func() bool {
+		_, err := watcher.LastError()
+		return err == nil
+	}, 2*time.Second, 10*time.Millisecond)
+
+	mu.Lock()
+	assert.Equal(t, []DremioCredentials{{Username: "svc", Password: "new"}}, rotated)
+	mu.Unlock()
+
+	// A file that is not valid keeps the active credentials
+	writeCredentials(t, path, "DREMIO_USERNAME=svc\n", start.Add(2*time.Minute))
+	require.Eventually(t, func() bool {
+		_, err := watcher.LastError()
+		return err != nil
+	}, 2*time.Second, 10*time.Millisecond)
+	mu.Lock()
+	assert.Len(t, rotated, 1)
+	mu.Unlock()
+}
+
+func TestReadCredentialsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dremio.env")
+	require.NoError(t, os.WriteFile(path, []byte("DREMIO_TOKEN=pat\n"), 0o600))
+	creds, err := ReadCredentialsFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, DremioCredentials{Token: "pat"}, creds)
+
+	require.NoError(t, os.WriteFile(path, []byte("DREMIO_USERNAME=svc\nDREMIO_PASSWORD=p\nDREMIO_TOKEN=pat\n"), 0o600))
+	_, err = ReadCredentialsFile(path)
+	assert.Error(t, err)
+
+	_, err = ReadCredentialsFile(filepath.Join(t.TempDir(), "missing.env"))
+	assert.Error(t, err)
+}
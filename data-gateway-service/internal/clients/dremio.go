@@ -4,14 +4,19 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/patrickmn/go-cache"
 	"go.uber.org/zap"
 
 	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/metrics"
+	"go-data-gateway/internal/numeric"
+	"go-data-gateway/internal/sqltext"
 )
 
 // DremioClient handles connections to Dremio for Iceberg queries
@@ -20,81 +25,277 @@ type DremioClient struct {
 	client *http.Client
 	cache  *cache.Cache
 	logger *zap.Logger
-	token  string
+
+	credentials *Credentials
+	mu          sync.Mutex
+	token       string
+	tokenOf     uint64 // Version of the credentials token was issued for
+
+	cancels *metrics.CancelCounter
+}
+
+// DremioError is a non-2xx response from the Dremio REST API
+type DremioError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *DremioError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("query failed with status: %d", e.StatusCode)
+	}
+	return fmt.Sprintf("query failed with status: %d: %s", e.StatusCode, e.Message)
+}
+
+// newDremioError reads the errorMessage Dremio puts in failed responses
+func newDremioError(resp *http.Response) *DremioError {
+	var body struct {
+		ErrorMessage string `json:"errorMessage"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	_ = json.Unmarshal(data, &body)
+
+	return &DremioError{StatusCode: resp.StatusCode, Message: body.ErrorMessage}
 }
 
-// NewDremioClient creates a new Dremio client
+// NewDremioClient creates a new Dremio client authenticating with the
+// credentials of cfg
 func NewDremioClient(cfg config.DremioConfig, logger *zap.Logger) (*DremioClient, error) {
+	return NewDremioClientWithCredentials(cfg, NewCredentials(DremioCredentials{
+		Username: cfg.Username,
+		Password: cfg.Password,
+		Token:    cfg.Token,
+	}), logger)
+}
+
+// NewDremioClientWithCredentials creates a new Dremio client authenticating
+// with the current credentials of creds, which it may share with other
+// clients of the account
+func NewDremioClientWithCredentials(cfg config.DremioConfig, creds *Credentials, logger *zap.Logger) (*DremioClient, error) {
 	client := &DremioClient{
-		config: cfg,
-		client: &http.Client{Timeout: 30 * time.Second},
-		cache:  cache.New(5*time.Minute, 10*time.Minute),
-		logger: logger,
+		config:      cfg,
+		client:      &http.Client{Timeout: 30 * time.Second},
+		cache:       cache.New(5*time.Minute, 10*time.Minute),
+		logger:      logger,
+		credentials: creds,
 	}
 
-	// Authenticate and get token if username/password provided
-	if cfg.Username != "" && cfg.Password != "" {
-		if err := client.authenticate(); err != nil {
+	// Log in now, so wrong credentials fail at startup
+	if current, _ := creds.Get(); current.Username != "" && current.Password != "" {
+		if _, err := client.currentToken(context.Background(), ""); err != nil {
 			return nil, fmt.Errorf("dremio authentication failed: %w", err)
 		}
-	} else if cfg.Token != "" {
-		client.token = cfg.Token
 	}
 
 	return client, nil
 }
 
-// authenticate gets a token from Dremio
-func (c *DremioClient) authenticate() error {
+// SetJobCancels counts the jobs the client cancels because their request
+// ended in counter
+func (c *DremioClient) SetJobCancels(counter *metrics.CancelCounter) {
+	c.cancels = counter
+}
+
+// login gets a token for creds from Dremio; a token is used as it is
+func (c *DremioClient) login(ctx context.Context, creds DremioCredentials) (string, error) {
+	if creds.Username == "" || creds.Password == "" {
+		return creds.Token, nil
+	}
+
 	url := fmt.Sprintf("http://%s:%d/apiv2/login", c.config.Host, c.config.Port)
 
 	payload := map[string]string{
-		"userName": c.config.Username,
-		"password": c.config.Password,
+		"userName": creds.Username,
+		"password": creds.Password,
 	}
 
 	jsonData, _ := json.Marshal(payload)
 
-	req, err := http.NewRequest("POST", url, strings.NewReader(string(jsonData)))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, strings.NewReader(string(jsonData)))
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("authentication failed with status: %d", resp.StatusCode)
+		return "", fmt.Errorf("authentication failed with status: %d", resp.StatusCode)
 	}
 
 	var result map[string]interface{}
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return err
+		return "", err
 	}
 
 	if token, ok := result["token"].(string); ok {
-		c.token = token
 		c.logger.Info("Dremio authentication successful")
-		return nil
+		return token, nil
 	}
 
-	return fmt.Errorf("no token in response")
+	return "", fmt.Errorf("no token in response")
+}
+
+// currentToken returns the token of the current credentials, logging in
+// again when they were rotated since it was issued or when stale, a token
+// Dremio rejected, is still the one held
+func (c *DremioClient) currentToken(ctx context.Context, stale string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	creds, version := c.credentials.Get()
+	if c.token != "" && c.token != stale && c.tokenOf == version {
+		return c.token, nil
+	}
+	token, err := c.login(ctx, creds)
+	if err != nil {
+		return "", err
+	}
+	c.token, c.tokenOf = token, version
+	return token, nil
+}
+
+// do sends req with the current token. Dremio answers 401 once the token
+// expires or the credentials are rotated, so the request is sent once more
+// with a token of the current credentials.
+func (c *DremioClient) do(req *http.Request) (*http.Response, error) {
+	token, err := c.currentToken(req.Context(), "")
+	if err != nil {
+		return nil, fmt.Errorf("dremio authentication failed: %w", err)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("_dremio%s", token))
+
+	resp, err := c.client.Do(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || (req.Body != nil && req.GetBody == nil) {
+		return resp, err
+	}
+	resp.Body.Close()
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	if token, err = c.currentToken(req.Context(), token); err != nil {
+		return nil, fmt.Errorf("dremio authentication failed: %w", err)
+	}
+	c.logger.Info("Dremio token refreshed")
+	retry.Header.Set("Authorization", fmt.Sprintf("_dremio%s", token))
+	return c.client.Do(retry)
+}
+
+// Credentials returns the credentials the client authenticates with
+func (c *DremioClient) Credentials() *Credentials {
+	return c.credentials
+}
+
+// RotateCredentials logs in with creds and makes them the current
+// credentials of the client and of the clients sharing them. Requests in
+// flight finish with the token they were sent with; creds that Dremio
+// rejects change nothing.
+func (c *DremioClient) RotateCredentials(ctx context.Context, creds DremioCredentials) error {
+	if err := creds.Validate(); err != nil {
+		return err
+	}
+	token, err := c.login(ctx, creds)
+	if err == nil && creds.Token != "" {
+		err = c.checkToken(ctx, token)
+	}
+	if err != nil {
+		return fmt.Errorf("dremio authentication failed: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token, c.tokenOf = token, c.credentials.Set(creds)
+	return nil
+}
+
+// checkToken reads the catalog root with token, which runs no job
+func (c *DremioClient) checkToken(ctx context.Context, token string) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("http://%s:%d/api/v3/catalog", c.config.Host, c.config.Port), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("_dremio%s", token))
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return newDremioError(resp)
+	}
+	return nil
 }
 
 // Query executes a SQL query against Dremio
 func (c *DremioClient) Query(ctx context.Context, sqlQuery string, args ...interface{}) ([]map[string]interface{}, error) {
+	job, err := c.cachedQuery(ctx, sqlQuery, "", args...)
+	if err != nil {
+		return nil, err
+	}
+	return job.Rows, nil
+}
+
+// jobResult is the rows of a query job and the job's id
+type jobResult struct {
+	Rows  []map[string]interface{}
+	JobID string
+}
+
+// cachedQuery runs comment+sqlQuery, caching the rows under sqlQuery alone
+func (c *DremioClient) cachedQuery(ctx context.Context, sqlQuery, comment string, args ...interface{}) (jobResult, error) {
 	// Check cache first
 	cacheKey := fmt.Sprintf("dremio:%s:%v", sqlQuery, args)
 	if cached, found := c.cache.Get(cacheKey); found {
 		c.logger.Debug("Cache hit", zap.String("query", sqlQuery))
-		return cached.([]map[string]interface{}), nil
+		return cached.(jobResult), nil
+	}
+
+	rows, jobID, err := c.runJob(ctx, comment+sqlQuery, JobOptions{}, args...)
+	if err != nil {
+		return jobResult{}, err
 	}
+	job := jobResult{Rows: rows, JobID: jobID}
 
+	// Cache the results
+	c.cache.Set(cacheKey, job, cache.DefaultExpiration)
+
+	return job, nil
+}
+
+// runQuery submits a SQL job and returns its rows, bypassing the cache
+func (c *DremioClient) runQuery(ctx context.Context, sqlQuery string, args ...interface{}) ([]map[string]interface{}, error) {
+	rows, _, err := c.runJob(ctx, sqlQuery, JobOptions{}, args...)
+	return rows, err
+}
+
+// JobOptions are the settings of a SQL job other than its SQL
+type JobOptions struct {
+	SessionOptions map[string]interface{}
+
+	// References are the Nessie branches or tags the job reads its catalog
+	// sources at, keyed by source
+	References map[string]VersionReference
+}
+
+// VersionReference is a branch or tag of a versioned catalog source
+type VersionReference struct {
+	Type  string `json:"type"` // BRANCH or TAG
+	Value string `json:"value"`
+}
+
+// runJob is runQuery that also returns the id of the job Dremio ran. Session
+// options and references are sent with the job submission.
+func (c *DremioClient) runJob(ctx context.Context, sqlQuery string, options JobOptions, args ...interface{}) ([]map[string]interface{}, string, error) {
 	// Log query execution
 	c.logger.Info("Executing Dremio query",
 		zap.String("sql", sqlQuery),
@@ -108,27 +309,32 @@ func (c *DremioClient) Query(ctx context.Context, sqlQuery string, args ...inter
 	payload := map[string]interface{}{
 		"sql": sqlQuery,
 	}
+	if len(options.SessionOptions) > 0 {
+		payload["sessionOptions"] = options.SessionOptions
+	}
+	if len(options.References) > 0 {
+		payload["references"] = options.References
+	}
 
 	jsonData, _ := json.Marshal(payload)
 
 	req, err := http.NewRequestWithContext(ctx, "POST", url, strings.NewReader(string(jsonData)))
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("_dremio%s", c.token))
 
-	resp, err := c.client.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		c.logger.Error("Query request failed", zap.Error(err))
-		return nil, err
+		return nil, "", err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		c.logger.Error("Query failed", zap.Int("status", resp.StatusCode))
-		return nil, fmt.Errorf("query failed with status: %d", resp.StatusCode)
+		return nil, "", newDremioError(resp)
 	}
 
 	// Parse job response
@@ -136,76 +342,192 @@ func (c *DremioClient) Query(ctx context.Context, sqlQuery string, args ...inter
 		ID string `json:"id"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&jobResp); err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
-	// Wait a moment for job to complete
-	time.Sleep(500 * time.Millisecond)
+	// Dremio keeps running a job whose client went away, so one still running
+	// when ctx is done is cancelled
+	rows, err := c.jobResults(ctx, jobResp.ID)
+	if err != nil {
+		if ctx.Err() != nil {
+			c.cancelJob(ctx, jobResp.ID)
+		}
+		return nil, "", err
+	}
 
-	// Get job results
-	resultsURL := fmt.Sprintf("http://%s:%d/api/v3/job/%s/results", c.config.Host, c.config.Port, jobResp.ID)
+	// Log performance metrics
+	c.logger.Info("Dremio query completed",
+		zap.String("dremio_job_id", jobResp.ID),
+		zap.Duration("duration", time.Since(start)),
+		zap.Int("rows", len(rows)))
+
+	return rows, jobResp.ID, nil
+}
+
+// jobResults waits a moment for job jobID to complete and reads its rows
+func (c *DremioClient) jobResults(ctx context.Context, jobID string) ([]map[string]interface{}, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	resultsURL := fmt.Sprintf("http://%s:%d/api/v3/job/%s/results", c.config.Host, c.config.Port, jobID)
 	resultsReq, err := http.NewRequestWithContext(ctx, "GET", resultsURL, nil)
 	if err != nil {
 		return nil, err
 	}
-	resultsReq.Header.Set("Authorization", fmt.Sprintf("_dremio%s", c.token))
-
-	resultsResp, err := c.client.Do(resultsReq)
+	resultsResp, err := c.do(resultsReq)
 	if err != nil {
 		c.logger.Error("Failed to get job results", zap.Error(err))
 		return nil, err
 	}
 	defer resultsResp.Body.Close()
 
-	// Parse results
+	// A failed job reports its error when the results are fetched
+	if resultsResp.StatusCode != http.StatusOK {
+		c.logger.Error("Query job failed", zap.Int("status", resultsResp.StatusCode))
+		return nil, newDremioError(resultsResp)
+	}
+
 	var result struct {
 		RowCount int                      `json:"rowCount"`
 		Rows     []map[string]interface{} `json:"rows"`
+		Schema   []dremioResultField      `json:"schema"`
 	}
-
-	if err := json.NewDecoder(resultsResp.Body).Decode(&result); err != nil {
+	decoder := json.NewDecoder(resultsResp.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&result); err != nil {
 		return nil, err
 	}
+	exactNumbers(result.Rows, result.Schema)
+	return result.Rows, nil
+}
 
-	// Log performance metrics
-	c.logger.Info("Dremio query completed",
-		zap.Duration("duration", time.Since(start)),
-		zap.Int("rows", len(result.Rows)))
+// dremioResultField is a column of a job's results
+type dremioResultField struct {
+	Name string `json:"name"`
+	Type struct {
+		Name      string `json:"name"`
+		Precision int    `json:"precision"`
+		Scale     int    `json:"scale"`
+	} `json:"type"`
+}
 
-	// Cache the results
-	c.cache.Set(cacheKey, result.Rows, cache.DefaultExpiration)
+// exactNumbers converts the numbers of rows, decoded with UseNumber, without
+// rounding them through a float64: those of DECIMAL columns with
+// numeric.Decimal, the others with numeric.FromJSON
+func exactNumbers(rows []map[string]interface{}, schema []dremioResultField) {
+	decimals := make(map[string]dremioResultField)
+	for _, field := range schema {
+		if strings.EqualFold(field.Type.Name, "DECIMAL") {
+			decimals[field.Name] = field
+		}
+	}
+	for _, row := range rows {
+		for name, value := range row {
+			if n, ok := value.(json.Number); ok {
+				if field, ok := decimals[name]; ok {
+					row[name] = numeric.Decimal(numeric.Plain(n.String()), field.Type.Precision, field.Type.Scale)
+					continue
+				}
+			}
+			row[name] = numeric.Decode(value)
+		}
+	}
+}
 
-	return result.Rows, nil
+// cancelJob asks Dremio to stop job jobID, outliving ctx by up to
+// cancelTimeout
+func (c *DremioClient) cancelJob(ctx context.Context, jobID string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cancelTimeout)
+	defer cancel()
+
+	url := fmt.Sprintf("http://%s:%d/api/v3/job/%s/cancel", c.config.Host, c.config.Port, jobID)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, nil)
+	if err != nil {
+		return
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		c.logger.Warn("Failed to cancel Dremio job", zap.String("dremio_job_id", jobID), zap.Error(err))
+		return
+	}
+	defer resp.Body.Close()
+	// A job that already finished cannot be cancelled; Dremio answers 400
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		c.logger.Warn("Failed to cancel Dremio job", zap.String("dremio_job_id", jobID), zap.Error(newDremioError(resp)))
+		return
+	}
+	c.cancels.Record("dremio")
+	c.logger.Info("Cancelled Dremio job after its request ended", zap.String("dremio_job_id", jobID))
 }
 
 // ExecuteQuery is a simpler interface for executing queries
 func (c *DremioClient) ExecuteQuery(ctx context.Context, query string) (interface{}, error) {
+	return c.ExecuteAnnotatedQuery(ctx, query, "")
+}
+
+// ExecuteAnnotatedQuery is ExecuteQuery with comment prepended to the SQL
+// sent to Dremio. The comment is neither checked nor part of the cache key.
+func (c *DremioClient) ExecuteAnnotatedQuery(ctx context.Context, query, comment string) (interface{}, error) {
 	// Validate query is read-only
 	if !isReadOnlyDremioSQL(query) {
 		return nil, fmt.Errorf("only SELECT queries are allowed")
 	}
 
-	results, err := c.Query(ctx, query)
+	job, err := c.cachedQuery(ctx, query, comment)
 	if err != nil {
 		return nil, err
 	}
 
 	return map[string]interface{}{
-		"data":   results,
-		"count":  len(results),
+		"data":   job.Rows,
+		"count":  len(job.Rows),
 		"source": "dremio",
+		"job_id": job.JobID,
 	}, nil
 }
 
-// TestConnection verifies the Dremio connection
+// ExecuteQueryWithOptions is ExecuteAnnotatedQuery with Dremio session
+// options and references for this job. Such results depend on the options,
+// so they are neither read from nor written to the cache.
+func (c *DremioClient) ExecuteQueryWithOptions(ctx context.Context, query, comment string, options JobOptions) (interface{}, error) {
+	if !isReadOnlyDremioSQL(query) {
+		return nil, fmt.Errorf("only SELECT queries are allowed")
+	}
+
+	rows, jobID, err := c.runJob(ctx, comment+query, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"data":   rows,
+		"count":  len(rows),
+		"source": "dremio",
+		"job_id": jobID,
+	}, nil
+}
+
+// TestConnection verifies the Dremio connection and token by reading the
+// catalog root, which runs no job
 func (c *DremioClient) TestConnection(ctx context.Context) error {
-	_, err := c.Query(ctx, "SELECT 1")
+	var catalog struct {
+		Data []json.RawMessage `json:"data"`
+	}
+	return c.getJSON(ctx, "/api/v3/catalog", &catalog)
+}
+
+// TestQuery runs SELECT 1 as a job, checking the query engine end to end
+func (c *DremioClient) TestQuery(ctx context.Context) error {
+	_, err := c.runQuery(ctx, "SELECT 1")
 	return err
 }
 
 // isReadOnlyDremioSQL checks if a SQL query is read-only for Dremio
 func isReadOnlyDremioSQL(sql string) bool {
-	sql = strings.ToUpper(strings.TrimSpace(sql))
+	sql = strings.ToUpper(sqltext.Normalize(sql, sqltext.Standard))
 
 	// List of forbidden keywords
 	forbidden := []string{"INSERT", "UPDATE", "DELETE", "DROP", "CREATE", "ALTER", "TRUNCATE", "GRANT", "REVOKE"}
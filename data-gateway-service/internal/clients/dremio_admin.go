@@ -0,0 +1,243 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Reflection is a Dremio reflection with its refresh status and footprint
+type Reflection struct {
+	ID               string     `json:"id"`
+	Name             string     `json:"name"`
+	Type             string     `json:"type"` // RAW or AGGREGATION
+	Enabled          bool       `json:"enabled"`
+	Status           string     `json:"status"` // Combined status, e.g. CAN_ACCELERATE, EXPIRED, FAILED
+	RefreshStatus    string     `json:"refresh_status"`
+	Availability     string     `json:"availability"`
+	FailureCount     int        `json:"failure_count"`
+	LastRefresh      *time.Time `json:"last_refresh,omitempty"`
+	ExpiresAt        *time.Time `json:"expires_at,omitempty"`
+	CurrentSizeBytes int64      `json:"current_size_bytes"`
+	TotalSizeBytes   int64      `json:"total_size_bytes"`
+}
+
+// DremioJob is a recent query job from sys.jobs_recent
+type DremioJob struct {
+	ID             string   `json:"id"`
+	State          string   `json:"state"`
+	QueryType      string   `json:"query_type"`
+	User           string   `json:"user"`
+	SubmittedAt    string   `json:"submitted_at"`
+	DurationMillis int64    `json:"duration_ms"`
+	RowsReturned   int64    `json:"rows_returned"`
+	QueriedTables  []string `json:"queried_tables"`
+	Error          string   `json:"error,omitempty"`
+}
+
+// reflectionResponse is the v3 REST representation of a reflection
+type reflectionResponse struct {
+	ID               string `json:"id"`
+	Name             string `json:"name"`
+	Type             string `json:"type"`
+	Enabled          bool   `json:"enabled"`
+	CurrentSizeBytes int64  `json:"currentSizeBytes"`
+	TotalSizeBytes   int64  `json:"totalSizeBytes"`
+	Status           struct {
+		Refresh        string     `json:"refresh"`
+		Availability   string     `json:"availability"`
+		CombinedStatus string     `json:"combinedStatus"`
+		FailureCount   int        `json:"failureCount"`
+		LastDataFetch  *time.Time `json:"lastDataFetch"`
+		ExpiresAt      *time.Time `json:"expiresAt"`
+	} `json:"status"`
+}
+
+// TableReflections returns the reflections defined on a dotted table path,
+// e.g. nessie_iceberg.tender_data
+func (c *DremioClient) TableReflections(ctx context.Context, table string) ([]Reflection, error) {
+	var dataset struct {
+		ID string `json:"id"`
+	}
+	if err := c.getJSON(ctx, catalogPath(table), &dataset); err != nil {
+		return nil, err
+	}
+
+	var list struct {
+		Data []reflectionResponse `json:"data"`
+	}
+	if err := c.getJSON(ctx, "/api/v3/dataset/"+url.PathEscape(dataset.ID)+"/reflection", &list); err != nil {
+		return nil, err
+	}
+
+	reflections := make([]Reflection, 0, len(list.Data))
+	for _, r := range list.Data {
+		reflections = append(reflections, Reflection{
+			ID:               r.ID,
+			Name:             r.Name,
+			Type:             r.Type,
+			Enabled:          r.Enabled,
+			Status:           r.Status.CombinedStatus,
+			RefreshStatus:    r.Status.Refresh,
+			Availability:     r.Status.Availability,
+			FailureCount:     r.Status.FailureCount,
+			LastRefresh:      r.Status.LastDataFetch,
+			ExpiresAt:        r.Status.ExpiresAt,
+			CurrentSizeBytes: r.CurrentSizeBytes,
+			TotalSizeBytes:   r.TotalSizeBytes,
+		})
+	}
+	return reflections, nil
+}
+
+// TableColumns returns the column names of a dotted table path from the
+// Dremio catalog, in schema order
+func (c *DremioClient) TableColumns(ctx context.Context, table string) ([]string, error) {
+	var dataset struct {
+		Fields []struct {
+			Name string `json:"name"`
+		} `json:"fields"`
+	}
+	if err := c.getJSON(ctx, catalogPath(table), &dataset); err != nil {
+		return nil, err
+	}
+	if len(dataset.Fields) == 0 {
+		return nil, fmt.Errorf("catalog entry for %s has no fields", table)
+	}
+
+	columns := make([]string, len(dataset.Fields))
+	for i, field := range dataset.Fields {
+		columns[i] = field.Name
+	}
+	return columns, nil
+}
+
+// catalogPath is the v3 catalog by-path URL of a dotted table path
+func catalogPath(table string) string {
+	return catalogSegmentsPath(strings.Split(table, "."))
+}
+
+// catalogSegmentsPath is the v3 catalog by-path URL of path segments
+func catalogSegmentsPath(path []string) string {
+	segments := make([]string, len(path))
+	for i, segment := range path {
+		segments[i] = url.PathEscape(segment)
+	}
+	return "/api/v3/catalog/by-path/" + strings.Join(segments, "/")
+}
+
+// RecentJobs returns the latest query jobs, newest first. It always queries
+// Dremio; callers cache as needed.
+func (c *DremioClient) RecentJobs(ctx context.Context, limit int) ([]DremioJob, error) {
+	query := fmt.Sprintf(`SELECT job_id, status, query_type, user_name, submitted_ts,
+		TIMESTAMPDIFF(MILLISECOND, submitted_ts, final_state_ts) AS duration_ms,
+		rows_returned, queried_datasets, error_msg
+		FROM sys.jobs_recent
+		ORDER BY submitted_ts DESC
+		LIMIT %d`, limit)
+
+	rows, err := c.runQuery(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]DremioJob, 0, len(rows))
+	for _, row := range rows {
+		jobs = append(jobs, DremioJob{
+			ID:             stringValue(row["job_id"]),
+			State:          stringValue(row["status"]),
+			QueryType:      stringValue(row["query_type"]),
+			User:           stringValue(row["user_name"]),
+			SubmittedAt:    stringValue(row["submitted_ts"]),
+			DurationMillis: int64Value(row["duration_ms"]),
+			RowsReturned:   int64Value(row["rows_returned"]),
+			QueriedTables:  datasetList(row["queried_datasets"]),
+			Error:          stringValue(row["error_msg"]),
+		})
+	}
+	return jobs, nil
+}
+
+// FindJob returns the id of the latest job that ran exactly sql and was
+// submitted at or after since, or "" when sys.jobs_recent has none yet
+func (c *DremioClient) FindJob(ctx context.Context, sql string, since time.Time) (string, error) {
+	query := fmt.Sprintf(`SELECT job_id FROM sys.jobs_recent
+		WHERE query = '%s' AND submitted_ts >= TIMESTAMP '%s'
+		ORDER BY submitted_ts DESC
+		LIMIT 1`,
+		strings.ReplaceAll(sql, "'", "''"),
+		since.UTC().Format("2006-01-02 15:04:05.000"))
+
+	rows, err := c.runQuery(ctx, query)
+	if err != nil {
+		return "", err
+	}
+	if len(rows) == 0 {
+		return "", nil
+	}
+	return stringValue(rows[0]["job_id"]), nil
+}
+
+// getJSON performs an authenticated GET against the REST API and decodes the body
+func (c *DremioClient) getJSON(ctx context.Context, path string, out interface{}) error {
+	endpoint := fmt.Sprintf("http://%s:%d%s", c.config.Host, c.config.Port, path)
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return newDremioError(resp)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func stringValue(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+func int64Value(v interface{}) int64 {
+	switch n := v.(type) {
+	case float64:
+		return int64(n)
+	case int64:
+		return n
+	case int:
+		return int64(n)
+	}
+	return 0
+}
+
+// datasetList normalizes queried_datasets, which Dremio returns either as a
+// list or as a bracketed, comma-separated string
+func datasetList(v interface{}) []string {
+	var tables []string
+	switch list := v.(type) {
+	case []interface{}:
+		for _, item := range list {
+			tables = append(tables, stringValue(item))
+		}
+	case string:
+		for _, item := range strings.Split(strings.Trim(list, "[]"), ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				tables = append(tables, item)
+			}
+		}
+	}
+	return tables
+}
@@ -0,0 +1,109 @@
+package clients
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	// catalogPageSize is the maxChildren of each catalog request
+	catalogPageSize = 500
+	// maxCatalogPages bounds the pages read for one folder
+	maxCatalogPages = 200
+)
+
+// CatalogEntry is a normalized Dremio catalog entry: a space, source, home,
+// folder or dataset, with its children or, for a dataset, its fields
+type CatalogEntry struct {
+	Type     string         `json:"type"` // root, space, source, home, folder, dataset or file
+	Path     []string       `json:"path"`
+	Children []CatalogEntry `json:"children,omitempty"`
+	Fields   []CatalogField `json:"fields,omitempty"`
+}
+
+// CatalogField is a column of a dataset
+type CatalogField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// catalogResponse is the v3 REST representation of a catalog entity or child
+type catalogResponse struct {
+	EntityType    string            `json:"entityType"`
+	Type          string            `json:"type"`
+	ContainerType string            `json:"containerType"`
+	Path          []string          `json:"path"`
+	Children      []catalogResponse `json:"children"`
+	Fields        []struct {
+		Name string `json:"name"`
+		Type struct {
+			Name string `json:"name"`
+		} `json:"type"`
+	} `json:"fields"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// Catalog returns the catalog entry at path with all of its children; an
+// empty path lists the top-level spaces and sources. Large folders are read
+// page by page. An unknown path returns a DremioError with status 404.
+func (c *DremioClient) Catalog(ctx context.Context, path []string) (*CatalogEntry, error) {
+	if len(path) == 0 {
+		var root struct {
+			Data []catalogResponse `json:"data"`
+		}
+		if err := c.getJSON(ctx, "/api/v3/catalog", &root); err != nil {
+			return nil, err
+		}
+		entry := &CatalogEntry{Type: "root", Path: []string{}}
+		for _, child := range root.Data {
+			entry.Children = append(entry.Children, child.normalize())
+		}
+		return entry, nil
+	}
+
+	var entry *CatalogEntry
+	pageToken := ""
+	for page := 0; page < maxCatalogPages; page++ {
+		query := url.Values{"maxChildren": {fmt.Sprint(catalogPageSize)}}
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+
+		var resp catalogResponse
+		if err := c.getJSON(ctx, catalogSegmentsPath(path)+"?"+query.Encode(), &resp); err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			normalized := resp.normalize()
+			entry = &normalized
+		}
+		for _, child := range resp.Children {
+			entry.Children = append(entry.Children, child.normalize())
+		}
+
+		if resp.NextPageToken == "" {
+			return entry, nil
+		}
+		pageToken = resp.NextPageToken
+	}
+	return nil, fmt.Errorf("catalog folder %s has more than %d children", strings.Join(path, "."), maxCatalogPages*catalogPageSize)
+}
+
+// normalize converts an entity or child to a CatalogEntry without children
+func (r catalogResponse) normalize() CatalogEntry {
+	entry := CatalogEntry{Path: r.Path}
+	switch {
+	case r.EntityType != "":
+		entry.Type = strings.ToLower(r.EntityType)
+	case r.Type == "CONTAINER":
+		entry.Type = strings.ToLower(r.ContainerType)
+	default:
+		entry.Type = strings.ToLower(r.Type) // DATASET or FILE
+	}
+	for _, field := range r.Fields {
+		entry.Fields = append(entry.Fields, CatalogField{Name: field.Name, Type: field.Type.Name})
+	}
+	return entry
+}
@@ -0,0 +1,219 @@
+package clients
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/metrics"
+)
+
+// newFakeDremio serves a Dremio REST API whose jobs take delay to return
+// results, recording the jobs cancelled
+func newFakeDremio(t *testing.T, delay time.Duration) (*DremioClient, *[]string) {
+	var (
+		mu        sync.Mutex
+		cancelled []string
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v3/sql", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"id": "job-1"})
+	})
+	mux.HandleFunc("GET /api/v3/job/{id}/results", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(delay):
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"rowCount": 1, "rows": []map[string]int{{"n": 1}}})
+	})
+	mux.HandleFunc("POST /api/v3/job/{id}/cancel", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		cancelled = append(cancelled, r.PathValue("id"))
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	host, portStr, err := net.SplitHostPort(srv.Listener.Addr().String())
+	require.NoError(t, err)
+	port, _ := strconv.Atoi(portStr)
+	client, err := NewDremioClient(config.DremioConfig{Host: host, Port: port, Token: "t"}, zap.NewNop())
+	require.NoError(t, err)
+	return client, &cancelled
+}
+
+func TestDremioClient_CancelsJobWhenContextExpires(t *testing.T) {
+	client, cancelled := newFakeDremio(t, time.Minute)
+	counter := metrics.NewCancelCounter()
+	client.SetJobCancels(counter)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 700*time.Millisecond)
+	defer cancel()
+	_, err := client.Query(ctx, "SELECT 1")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, []string{"job-1"}, *cancelled)
+
+	var buf bytes.Buffer
+	counter.WritePrometheus(&buf)
+	assert.Contains(t, buf.String(), `go_gateway_upstream_jobs_cancelled_total{source="dremio"} 1`)
+}
+
+func TestDremioClient_CompletedJobIsNotCancelled(t *testing.T) {
+	client, cancelled := newFakeDremio(t, 0)
+
+	rows, err := client.Query(context.Background(), "SELECT 1")
+	require.NoError(t, err)
+	assert.Len(t, rows, 1)
+	assert.Empty(t, *cancelled)
+}
+
+func TestExactNumbers(t *testing.T) {
+	body := `{"rows": [{"kode": 999999999999999999, "nilai_pagu": 123456789012345678.123456789, "nilai": 1500000000000.25, "ratio": 0.1, "n": 1.5e12}],
+		"schema": [{"name": "nilai_pagu", "type": {"name": "DECIMAL", "precision": 38, "scale": 9}},
+			{"name": "nilai", "type": {"name": "DECIMAL", "precision": 15, "scale": 2}}]}`
+	var result struct {
+		Rows   []map[string]interface{} `json:"rows"`
+		Schema []dremioResultField      `json:"schema"`
+	}
+	decoder := json.NewDecoder(bytes.NewBufferString(body))
+	decoder.UseNumber()
+	require.NoError(t, decoder.Decode(&result))
+
+	exactNumbers(result.Rows, result.Schema)
+	assert.Equal(t, map[string]interface{}{
+		"kode":       int64(999999999999999999),
+		"nilai_pagu": "123456789012345678.123456789",
+		"nilai":      json.Number("1500000000000.25"),
+		"ratio":      0.1,
+		"n":          int64(1500000000000),
+	}, result.Rows[0])
+}
+
+// newAuthDremio serves a Dremio REST API that issues tokens for the
+// passwords in passwords and answers requests only with a token it issued
+// and has not expired
+func newAuthDremio(t *testing.T, passwords map[string]bool) (host string, port int, expire func()) {
+	var (
+		mu     sync.Mutex
+		issued = map[string]bool{}
+		next   int
+	)
+	authorized := func(w http.ResponseWriter, r *http.Request) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		if !issued[r.Header.Get("Authorization")] {
+			w.WriteHeader(http.StatusUnauthorized)
+			return false
+		}
+		return true
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /apiv2/login", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		defer mu.Unlock()
+		if !passwords[body["password"]] {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		next++
+		token := "token-" + strconv.Itoa(next)
+		issued["_dremio"+token] = true
+		json.NewEncoder(w).Encode(map[string]string{"token": token})
+	})
+	mux.HandleFunc("POST /api/v3/sql", func(w http.ResponseWriter, r *http.Request) {
+		if authorized(w, r) {
+			json.NewEncoder(w).Encode(map[string]string{"id": "job-1"})
+		}
+	})
+	mux.HandleFunc("GET /api/v3/job/{id}/results", func(w http.ResponseWriter, r *http.Request) {
+		if authorized(w, r) {
+			json.NewEncoder(w).Encode(map[string]interface{}{"rowCount": 1, "rows": []map[string]int{{"n": 1}}})
+		}
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	host, portStr, err := net.SplitHostPort(srv.Listener.Addr().String())
+	require.NoError(t, err)
+	port, _ = strconv.Atoi(portStr)
+	return host, port, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		issued = map[string]bool{}
+	}
+}
+
+func TestDremioClient_RefreshesToken(t *testing.T) {
+	passwords := map[string]bool{"old": true}
+	host, port, expire := newAuthDremio(t, passwords)
+	creds := NewCredentials(DremioCredentials{Username: "svc", Password: "old"})
+	client, err := NewDremioClientWithCredentials(config.DremioConfig{Host: host, Port: port}, creds, zap.NewNop())
+	require.NoError(t, err)
+
+	// An expired token is replaced and the request sent again
+	expire()
+	_, err = client.Query(context.Background(), "SELECT 1")
+	require.NoError(t, err)
+
+	// Credentials rotated by another client of the account are read at the
+	// next request
+	passwords["new"] = true
+	creds.Set(DremioCredentials{Username: "svc", Password: "new"})
+	delete(passwords, "old")
+	_, err = client.Query(context.Background(), "SELECT 2")
+	require.NoError(t, err)
+	expire()
+	_, err = client.Query(context.Background(), "SELECT 3")
+	require.NoError(t, err)
+}
+
+func TestDremioClient_RotateCredentials(t *testing.T) {
+	passwords := map[string]bool{"old": true}
+	host, port, _ := newAuthDremio(t, passwords)
+	creds := NewCredentials(DremioCredentials{Username: "svc", Password: "old"})
+	client, err := NewDremioClientWithCredentials(config.DremioConfig{Host: host, Port: port}, creds, zap.NewNop())
+	require.NoError(t, err)
+
+	// Credentials Dremio refuses change nothing
+	err = client.RotateCredentials(context.Background(), DremioCredentials{Username: "svc", Password: "new"})
+	require.Error(t, err)
+	current, version := creds.Get()
+	assert.Equal(t, "old", current.Password)
+	assert.Zero(t, version)
+
+	err = client.RotateCredentials(context.Background(), DremioCredentials{Username: "svc", Password: "new", Token: "t"})
+	require.Error(t, err)
+
+	passwords["new"] = true
+	require.NoError(t, client.RotateCredentials(context.Background(), DremioCredentials{Username: "svc", Password: "new"}))
+	current, version = creds.Get()
+	assert.Equal(t, "new", current.Password)
+	assert.EqualValues(t, 1, version)
+	_, err = client.Query(context.Background(), "SELECT 1")
+	require.NoError(t, err)
+}
+
+func TestIsReadOnlyDremioSQL_QuotedCommentMarkers(t *testing.T) {
+	assert.False(t, isReadOnlyDremioSQL(`SELECT 'a\'' -- ' ; DROP TABLE t`))
+	assert.False(t, isReadOnlyDremioSQL("SELECT `a\\` -- `\nFROM t; DELETE FROM t"))
+	assert.False(t, isReadOnlyDremioSQL(`SELECT "a\" -- " AS x; DROP TABLE t`))
+
+	assert.True(t, isReadOnlyDremioSQL(`SELECT "a""b" FROM t -- drop later`))
+	assert.True(t, isReadOnlyDremioSQL("SELECT * FROM t -- drop the cancelled ones later"))
+}
@@ -0,0 +1,215 @@
+// Package coalesce shares one execution between concurrent identical GET
+// requests. Requests that arrive while an identical one is in flight wait for
+// it and receive their own copy of its response, so they cost no extra
+// upstream query or cache write.
+package coalesce
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"golang.org/x/sync/singleflight"
+
+	"go-data-gateway/internal/auth"
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/tenant"
+)
+
+// Group coalesces requests by Key. A nil Group coalesces nothing.
+type Group struct {
+	flight     singleflight.Group
+	executions atomic.Int64 // Handler runs
+	requests   atomic.Int64 // Completed requests, including those that ran the handler
+	waiting    atomic.Int64 // Requests waiting for a response
+}
+
+// New creates a coalescing group
+func New() *Group {
+	return &Group{}
+}
+
+// Response is a recorded handler response
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// Clone copies the response so callers can change it independently
+func (r *Response) Clone() *Response {
+	return &Response{
+		Status: r.Status,
+		Header: r.Header.Clone(),
+		Body:   append([]byte(nil), r.Body...),
+	}
+}
+
+// WriteTo writes the response to w, keeping headers already set on w
+func (r *Response) WriteTo(w http.ResponseWriter) {
+	header := w.Header()
+	for name, values := range r.Header {
+		header[name] = values
+	}
+	w.WriteHeader(r.Status)
+	w.Write(r.Body)
+}
+
+// Key identifies requests that may share a response: the path, the query
+// parameters in canonical order, the tenant, the Nessie reference, the
+// substituted data sources of a mirrored replay, the API key's scope set and
+// the request's Cache-Control, which can bound the age of cached results.
+// Keys with the same scopes on the same tenant see the same data.
+func Key(r *http.Request) string {
+	var b strings.Builder
+	b.WriteString(r.Method)
+	b.WriteByte(' ')
+	b.WriteString(r.URL.Path)
+	b.WriteByte('?')
+	b.WriteString(r.URL.Query().Encode()) // Sorted by parameter name
+
+	b.WriteString("\x00tenant=")
+	if t, ok := tenant.FromContext(r.Context()); ok {
+		b.WriteString(t.ID)
+	}
+
+	// A key's default branch is not in the query parameters
+	if version := datasource.VersionCacheKey(r.Context()); version != "" {
+		b.WriteString("\x00version=")
+		b.WriteString(version)
+	}
+
+	// A mirrored replay reads other sources than the request it replays
+	if substitutes := tenant.SubstitutesKey(r.Context()); substitutes != "" {
+		b.WriteString("\x00substitutes=")
+		b.WriteString(substitutes)
+	}
+
+	b.WriteString("\x00scopes=")
+	if key, ok := auth.KeyFromContext(r.Context()); ok {
+		scopes := append([]string(nil), key.Scopes...)
+		sort.Strings(scopes)
+		b.WriteString(strings.Join(scopes, ","))
+	}
+
+	if cc := r.Header.Get("Cache-Control"); cc != "" {
+		b.WriteString("\x00cache-control=")
+		b.WriteString(cc)
+	}
+	return b.String()
+}
+
+// Do runs handler for r unless an identical request is already running, in
+// which case it waits for that one. Every caller gets its own copy of the
+// response.
+//
+// The handler runs detached from the cancellation of the request that
+// started it, so one client going away does not fail the others; the
+// request's deadline still applies. A panicking handler yields a 500.
+func (g *Group) Do(r *http.Request, handler http.Handler) *Response {
+	ch := g.flight.DoChan(Key(r), func() (interface{}, error) {
+		ctx := context.WithoutCancel(r.Context())
+		if deadline, ok := r.Context().Deadline(); ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithDeadline(ctx, deadline)
+			defer cancel()
+		}
+
+		resp := serve(handler, r.WithContext(ctx))
+		g.executions.Add(1)
+		return resp, nil
+	})
+
+	g.waiting.Add(1)
+	result := <-ch
+	g.waiting.Add(-1)
+	g.requests.Add(1)
+
+	return result.Val.(*Response).Clone()
+}
+
+// serve records the response of handler. It runs on a goroutine of its own,
+// where a panic would end the process, so panics become a 500.
+func serve(handler http.Handler, r *http.Request) (resp *Response) {
+	rec := &recorder{header: make(http.Header), status: http.StatusOK}
+	defer func() {
+		if recover() != nil {
+			resp = &Response{
+				Status: http.StatusInternalServerError,
+				Header: http.Header{"Content-Type": {"text/plain; charset=utf-8"}},
+				Body:   []byte(http.StatusText(http.StatusInternalServerError) + "\n"),
+			}
+		}
+	}()
+
+	handler.ServeHTTP(rec, r)
+	return &Response{Status: rec.status, Header: rec.header, Body: rec.body}
+}
+
+// Executions returns the number of handler executions
+func (g *Group) Executions() int64 {
+	if g == nil {
+		return 0
+	}
+	return g.executions.Load()
+}
+
+// Coalesced returns the number of requests served by another's execution
+func (g *Group) Coalesced() int64 {
+	if g == nil {
+		return 0
+	}
+	return g.requests.Load() - g.executions.Load()
+}
+
+// Waiting returns the number of requests currently waiting for a response
+func (g *Group) Waiting() int64 {
+	if g == nil {
+		return 0
+	}
+	return g.waiting.Load()
+}
+
+// WritePrometheus writes the coalescing counters in the Prometheus text format
+func (g *Group) WritePrometheus(w io.Writer) {
+	if g == nil {
+		return
+	}
+	fmt.Fprintf(w, "# HELP go_gateway_coalesce_executions_total Coalescable requests that ran their handler\n")
+	fmt.Fprintf(w, "# TYPE go_gateway_coalesce_executions_total counter\n")
+	fmt.Fprintf(w, "go_gateway_coalesce_executions_total %d\n", g.Executions())
+	fmt.Fprintf(w, "\n# HELP go_gateway_coalesced_requests_total "+
+		"Requests served by an identical in-flight request\n")
+	fmt.Fprintf(w, "# TYPE go_gateway_coalesced_requests_total counter\n")
+	fmt.Fprintf(w, "go_gateway_coalesced_requests_total %d\n", g.Coalesced())
+}
+
+// recorder captures a handler's response in memory
+type recorder struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        []byte
+}
+
+func (r *recorder) Header() http.Header {
+	return r.header
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if r.wroteHeader {
+		return
+	}
+	r.status = status
+	r.wroteHeader = true
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	r.wroteHeader = true
+	r.body = append(r.body, p...)
+	return len(p), nil
+}
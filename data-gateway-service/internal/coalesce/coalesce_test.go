@@ -0,0 +1,95 @@
+package coalesce
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go-data-gateway/internal/auth"
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/tenant"
+)
+
+func requestAs(url string, scopes []string, tenantID string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, url, nil)
+	ctx := auth.WithKey(r.Context(), &auth.APIKey{ID: "key-" + tenantID, Scopes: scopes})
+	if tenantID != "" {
+		ctx = tenant.WithTenant(ctx, &tenant.Tenant{ID: tenantID})
+	}
+	return r.WithContext(ctx)
+}
+
+func TestKey(t *testing.T) {
+	base := Key(requestAs("/api/v1/tender?status=active&limit=10", []string{"read", "export"}, "lkpp"))
+
+	// Parameter and scope order do not matter, nor does the key itself
+	assert.Equal(t, base, Key(requestAs("/api/v1/tender?limit=10&status=active", []string{"export", "read"}, "lkpp")))
+
+	staging := requestAs("/api/v1/tender?status=active&limit=10", []string{"read", "export"}, "lkpp")
+	staging = staging.WithContext(datasource.WithVersion(staging.Context(),
+		datasource.VersionRef{Source: "nessie_iceberg", Type: "BRANCH", Name: "staging", Default: "main"}))
+
+	withMaxAge := requestAs("/api/v1/tender?status=active&limit=10", []string{"read", "export"}, "lkpp")
+	withMaxAge.Header.Set("Cache-Control", "max-age=30")
+
+	differs := []*http.Request{
+		withMaxAge,
+		staging,
+		requestAs("/api/v1/tender?status=active&limit=20", []string{"read", "export"}, "lkpp"),
+		requestAs("/api/v1/rup?status=active&limit=10", []string{"read", "export"}, "lkpp"),
+		requestAs("/api/v1/tender?status=active&limit=10", []string{"read"}, "lkpp"),
+		requestAs("/api/v1/tender?status=active&limit=10", []string{"read", "export"}, "bappenas"),
+		httptest.NewRequest(http.MethodGet, "/api/v1/tender?status=active&limit=10", nil),
+	}
+	for _, r := range differs {
+		assert.NotEqual(t, base, Key(r), r.URL.String())
+	}
+}
+
+func TestDo_DetachesFromCancellation(t *testing.T) {
+	g := New()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	resp := g.Do(httptest.NewRequest(http.MethodGet, "/api/v1/tender", nil).WithContext(ctx),
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Context().Err() != nil {
+				w.WriteHeader(http.StatusGatewayTimeout)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			w.WriteHeader(http.StatusTeapot) // Ignored like net/http does
+			w.Write([]byte(`{"ok":true}`))
+		}))
+
+	assert.Equal(t, http.StatusCreated, resp.Status)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	assert.Equal(t, `{"ok":true}`, string(resp.Body))
+	assert.Equal(t, int64(1), g.Executions())
+	assert.Zero(t, g.Coalesced())
+}
+
+func TestDo_PanicBecomes500(t *testing.T) {
+	resp := New().Do(httptest.NewRequest(http.MethodGet, "/api/v1/tender/1", nil),
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		}))
+	assert.Equal(t, http.StatusInternalServerError, resp.Status)
+}
+
+func TestResponse_CloneIsIndependent(t *testing.T) {
+	resp := &Response{Status: http.StatusOK, Header: http.Header{"X-Cache": {"HIT"}}, Body: []byte("rows")}
+	clone := resp.Clone()
+	clone.Header.Set("X-Cache", "MISS")
+	clone.Body[0] = 'R'
+
+	assert.Equal(t, "HIT", resp.Header.Get("X-Cache"))
+	assert.Equal(t, "rows", string(resp.Body))
+
+	var nilGroup *Group
+	assert.Zero(t, nilGroup.Coalesced())
+}
@@ -0,0 +1,57 @@
+// Package colnames gives the columns of a query result unique names. A JOIN
+// can return two columns of the same name, which rows keyed by column name
+// would otherwise collapse into one, keeping whichever was written last.
+package colnames
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MetaKey is the result metadata key of the renamed columns, each new name
+// to the name the source gave it
+const MetaKey = "renamed_columns"
+
+// Unique returns names with every repeat of an earlier name renamed, and the
+// renames from new name to original. The first column of a name keeps it. A
+// repeat is prefixed with its table, tables[i] when known, as table_name;
+// otherwise, or when that name is taken as well, it is suffixed _1, _2 and
+// so on. Without repeats Unique returns names itself and no renames.
+func Unique(names, tables []string) ([]string, map[string]string) {
+	taken := make(map[string]bool, len(names))
+	repeated := false
+	for _, name := range names {
+		repeated = repeated || taken[name]
+		taken[name] = true
+	}
+	if !repeated {
+		return names, nil
+	}
+
+	unique := make([]string, len(names))
+	renamed := make(map[string]string)
+	kept := make(map[string]bool, len(names))
+	for i, name := range names {
+		if !kept[name] {
+			kept[name] = true
+			unique[i] = name
+			continue
+		}
+		candidate := ""
+		if i < len(tables) && tables[i] != "" {
+			table := tables[i][strings.LastIndex(tables[i], ".")+1:]
+			if prefixed := table + "_" + name; !taken[prefixed] {
+				candidate = prefixed
+			}
+		}
+		for n := 1; candidate == ""; n++ {
+			if suffixed := fmt.Sprintf("%s_%d", name, n); !taken[suffixed] {
+				candidate = suffixed
+			}
+		}
+		taken[candidate] = true
+		unique[i] = candidate
+		renamed[candidate] = name
+	}
+	return unique, renamed
+}
@@ -0,0 +1,36 @@
+package colnames
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnique(t *testing.T) {
+	tests := []struct {
+		name        string
+		names       []string
+		tables      []string
+		want        []string
+		wantRenamed map[string]string
+	}{
+		{"no repeats", []string{"id", "nama"}, nil, []string{"id", "nama"}, nil},
+		{"suffixed", []string{"id", "nama", "id", "id"}, nil,
+			[]string{"id", "nama", "id_1", "id_2"}, map[string]string{"id_1": "id", "id_2": "id"}},
+		{"suffix skips taken names", []string{"id", "id", "id_1"}, nil,
+			[]string{"id", "id_2", "id_1"}, map[string]string{"id_2": "id"}},
+		{"table prefix", []string{"id", "id"}, []string{"tender_data", "nessie_iceberg.tender_peserta"},
+			[]string{"id", "tender_peserta_id"}, map[string]string{"tender_peserta_id": "id"}},
+		{"table prefix taken", []string{"id", "rup_id", "id"}, []string{"tender", "", "rup"},
+			[]string{"id", "rup_id", "id_1"}, map[string]string{"id_1": "id"}},
+		{"same table twice", []string{"id", "id", "id"}, []string{"", "rup", "rup"},
+			[]string{"id", "rup_id", "id_1"}, map[string]string{"rup_id": "id", "id_1": "id"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, renamed := Unique(tt.names, tt.tables)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantRenamed, renamed)
+		})
+	}
+}
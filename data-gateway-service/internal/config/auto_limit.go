@@ -0,0 +1,13 @@
+package config
+
+// AutoLimitConfig bounds raw SQL submitted without a LIMIT of its own
+type AutoLimitConfig struct {
+	Limit int // Rows a raw query without a top-level LIMIT returns; 0 disables
+}
+
+// loadAutoLimit reads QUERY_AUTO_LIMIT
+func loadAutoLimit() AutoLimitConfig {
+	return AutoLimitConfig{
+		Limit: getEnvAsInt("QUERY_AUTO_LIMIT", 10000),
+	}
+}
@@ -0,0 +1,31 @@
+package config
+
+// BulkConfig bounds the bulk lookup endpoints, POST /api/v1/tender/bulk and
+// /api/v1/rup/bulk
+type BulkConfig struct {
+	MaxIDs    int // Most ids in one request
+	ChunkSize int // Most ids in the IN list of one upstream query
+}
+
+// DefaultBulk returns the built-in bulk limits
+func DefaultBulk() BulkConfig {
+	return BulkConfig{MaxIDs: 500, ChunkSize: 200}
+}
+
+// loadBulk reads BULK_MAX_IDS and BULK_CHUNK_SIZE over the built-in limits.
+// A chunk size above the sanitizer's 1000-value IN lists is capped there.
+func loadBulk() BulkConfig {
+	defaults := DefaultBulk()
+	bulk := BulkConfig{
+		MaxIDs:    getEnvAsInt("BULK_MAX_IDS", defaults.MaxIDs),
+		ChunkSize: getEnvAsInt("BULK_CHUNK_SIZE", defaults.ChunkSize),
+	}
+	if bulk.MaxIDs <= 0 {
+		bulk.MaxIDs = defaults.MaxIDs
+	}
+	if bulk.ChunkSize <= 0 {
+		bulk.ChunkSize = defaults.ChunkSize
+	}
+	bulk.ChunkSize = min(bulk.ChunkSize, 1000)
+	return bulk
+}
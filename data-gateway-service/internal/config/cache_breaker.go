@@ -0,0 +1,26 @@
+package config
+
+import "time"
+
+// CacheBreakerConfig controls bypassing the result cache while Redis is slow
+// or failing, so requests stop paying its timeouts before reaching a source
+type CacheBreakerConfig struct {
+	Enabled      bool
+	Latency      time.Duration // Recent p95 of cache calls above this opens the breaker
+	ErrorPercent int           // Share of recent cache calls failing, in percent, that opens it
+	MinSamples   int           // Calls in the window needed before either is judged
+	Window       time.Duration // Calls older than this are ignored
+	CoolDown     time.Duration // Time between probes of Redis while the cache is bypassed
+}
+
+// loadCacheBreaker reads the CACHE_BREAKER_* variables
+func loadCacheBreaker() CacheBreakerConfig {
+	return CacheBreakerConfig{
+		Enabled:      getEnvAsBool("CACHE_BREAKER_ENABLED", true),
+		Latency:      getEnvAsDuration("CACHE_BREAKER_LATENCY", 250*time.Millisecond),
+		ErrorPercent: getEnvAsInt("CACHE_BREAKER_ERROR_PERCENT", 50),
+		MinSamples:   getEnvAsInt("CACHE_BREAKER_MIN_SAMPLES", 20),
+		Window:       getEnvAsDuration("CACHE_BREAKER_WINDOW", 30*time.Second),
+		CoolDown:     getEnvAsDuration("CACHE_BREAKER_COOL_DOWN", 15*time.Second),
+	}
+}
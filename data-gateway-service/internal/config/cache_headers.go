@@ -0,0 +1,102 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CachePolicy is the Cache-Control policy of an endpoint group
+type CachePolicy struct {
+	NoStore bool
+	Public  bool          // public instead of private
+	MaxAge  time.Duration // Browser and shared cache lifetime
+	SMaxAge time.Duration // Shared cache (CDN) lifetime; 0 omits s-maxage
+}
+
+// NoStore is the policy of authenticated, mutating and streaming endpoints
+var NoStore = CachePolicy{NoStore: true}
+
+// Cacheable reports whether responses may be stored by any cache
+func (p CachePolicy) Cacheable() bool {
+	return !p.NoStore
+}
+
+// String formats the policy as a Cache-Control header value
+func (p CachePolicy) String() string {
+	if p.NoStore {
+		return "no-store"
+	}
+
+	directives := []string{"private"}
+	if p.Public {
+		directives[0] = "public"
+	}
+	directives = append(directives, "max-age="+strconv.Itoa(int(p.MaxAge.Seconds())))
+	if p.SMaxAge > 0 {
+		directives = append(directives, "s-maxage="+strconv.Itoa(int(p.SMaxAge.Seconds())))
+	}
+	return strings.Join(directives, ", ")
+}
+
+// ParseCachePolicy reads a Cache-Control style list of public, private,
+// no-store, max-age=N and s-maxage=N (seconds)
+func ParseCachePolicy(value string) (CachePolicy, error) {
+	var p CachePolicy
+	for _, directive := range strings.Split(value, ",") {
+		name, arg, hasArg := strings.Cut(strings.ToLower(strings.TrimSpace(directive)), "=")
+		switch name {
+		case "":
+		case "no-store":
+			p.NoStore = true
+		case "public":
+			p.Public = true
+		case "private":
+			p.Public = false
+		case "max-age", "s-maxage":
+			seconds, err := strconv.Atoi(arg)
+			if !hasArg || err != nil || seconds < 0 {
+				return CachePolicy{}, fmt.Errorf("%s needs a number of seconds", name)
+			}
+			if name == "max-age" {
+				p.MaxAge = time.Duration(seconds) * time.Second
+			} else {
+				p.SMaxAge = time.Duration(seconds) * time.Second
+			}
+		default:
+			return CachePolicy{}, fmt.Errorf("unsupported cache directive %q", name)
+		}
+	}
+	if p.NoStore {
+		return NoStore, nil
+	}
+	return p, nil
+}
+
+// CacheHeadersConfig holds the Cache-Control policy of the cacheable GET
+// endpoint groups. Every /api/v1 endpoint is authenticated, so each defaults
+// to no-store until explicitly overridden.
+type CacheHeadersConfig struct {
+	Tender CachePolicy // /tender list, detail and timeseries
+	RUP    CachePolicy // /rup list, detail and timeseries
+	Tables CachePolicy // /sources/{source}/tables/{table}/rows
+}
+
+// loadCacheHeaders reads CACHE_CONTROL_<GROUP>; an invalid value keeps the
+// group at no-store
+func loadCacheHeaders() CacheHeadersConfig {
+	return CacheHeadersConfig{
+		Tender: loadCachePolicy("TENDER"),
+		RUP:    loadCachePolicy("RUP"),
+		Tables: loadCachePolicy("TABLES"),
+	}
+}
+
+func loadCachePolicy(group string) CachePolicy {
+	p, err := ParseCachePolicy(getEnv("CACHE_CONTROL_"+group, "no-store"))
+	if err != nil {
+		return NoStore
+	}
+	return p
+}
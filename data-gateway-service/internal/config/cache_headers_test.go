@@ -0,0 +1,43 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCachePolicy(t *testing.T) {
+	tests := []struct {
+		value  string
+		policy CachePolicy
+		header string
+	}{
+		{"public, max-age=60, s-maxage=300", CachePolicy{Public: true, MaxAge: time.Minute, SMaxAge: 5 * time.Minute}, "public, max-age=60, s-maxage=300"},
+		{"private,max-age=30", CachePolicy{MaxAge: 30 * time.Second}, "private, max-age=30"},
+		{"Max-Age=0", CachePolicy{}, "private, max-age=0"},
+		{"no-store", NoStore, "no-store"},
+		{"public, max-age=60, no-store", NoStore, "no-store"},
+	}
+	for _, tt := range tests {
+		policy, err := ParseCachePolicy(tt.value)
+		assert.NoError(t, err, tt.value)
+		assert.Equal(t, tt.policy, policy, tt.value)
+		assert.Equal(t, tt.header, policy.String(), tt.value)
+	}
+
+	for _, value := range []string{"max-age", "max-age=-1", "s-maxage=soon", "immutable"} {
+		_, err := ParseCachePolicy(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestLoadCacheHeaders(t *testing.T) {
+	t.Setenv("CACHE_CONTROL_TENDER", "public, max-age=60, s-maxage=300")
+	t.Setenv("CACHE_CONTROL_RUP", "public, max-age=forever")
+
+	headers := loadCacheHeaders()
+	assert.Equal(t, "public, max-age=60, s-maxage=300", headers.Tender.String())
+	assert.Equal(t, NoStore, headers.RUP)    // Invalid values stay no-store
+	assert.Equal(t, NoStore, headers.Tables) // Unset
+}
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"fmt"
+	"net"
+)
+
+// CacheStatsConfig controls access to /cache/stats, which shows cache and
+// data source metrics. Requests from AllowedCIDRs need no API key; any other
+// request needs a key with the metrics:read scope.
+type CacheStatsConfig struct {
+	AllowedCIDRs  []string // Internal networks such as the cluster's pod range
+	PublicSummary bool     // Serve hit rate and connection status at /cache/stats/summary without a key
+}
+
+// loadCacheStats reads the CACHE_STATS_* variables
+func loadCacheStats() CacheStatsConfig {
+	return CacheStatsConfig{
+		AllowedCIDRs:  getEnvAsSlice("CACHE_STATS_ALLOWED_CIDRS", ""),
+		PublicSummary: getEnvAsBool("CACHE_STATS_PUBLIC_SUMMARY", false),
+	}
+}
+
+// Networks parses AllowedCIDRs
+func (c CacheStatsConfig) Networks() ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(c.AllowedCIDRs))
+	for _, cidr := range c.AllowedCIDRs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("CACHE_STATS_ALLOWED_CIDRS: %w", err)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
@@ -1,34 +1,203 @@
 package config
 
 import (
+	"errors"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type Config struct {
 	Port        string
 	Environment string
 	APIKeys     []string
+	AdminKeys   []string // Bootstrap keys that also carry the admin scope
+	MetricsKeys []string // Bootstrap keys that also carry the metrics:read scope
 	RateLimit   int
 
+	// RateLimiting bounds the clients the rate limiter tracks
+	RateLimiting RateLimitingConfig
+
+	// Pagination holds default and maximum page sizes per endpoint group
+	Pagination PaginationConfig
+
+	// Search holds the keyword columns and limits of the search endpoints
+	Search SearchConfig
+
+	// Relations are the child collections tender detail can include
+	Relations RelationsConfig
+
+	// Bulk bounds the bulk lookup endpoints
+	Bulk BulkConfig
+
+	// TenderFilters are the values the tender list and search filters accept
+	TenderFilters TenderFiltersConfig
+
+	// CacheHeaders is the Cache-Control policy of cacheable GET endpoints
+	CacheHeaders CacheHeadersConfig
+
+	// LoadShedding rejects low-priority requests when the gateway is overloaded
+	LoadShedding SheddingConfig
+
+	// QueryMetricLabels are the query label keys exposed as metric labels
+	QueryMetricLabels []string
+
+	// LogRedactSQL replaces SQL string and numeric literals with placeholders
+	// in logs; credentials are always scrubbed
+	LogRedactSQL bool
+
+	// JSONFastEncoding encodes query rows without reflection; output is the
+	// same as encoding/json
+	JSONFastEncoding bool
+
+	// NumericAsString is when decimals too precise for a float64 are sent as
+	// JSON strings: auto, always or never
+	NumericAsString string
+
+	// CoalesceRequests shares one execution between concurrent identical
+	// GET requests to list, detail and table endpoints
+	CoalesceRequests bool
+
+	// SchemaRefresh is how often table schemas used to validate tender
+	// columns are refetched from the Dremio catalog
+	SchemaRefresh time.Duration
+
+	// TimeseriesMaxSpan bounds the date range of timeseries requests
+	TimeseriesMaxSpan time.Duration
+
+	// SentryDSN reports recovered handler panics to Sentry when set
+	SentryDSN string
+
+	// PolicyFile holds the table whitelists and cache TTLs, reloaded when it
+	// changes; empty keeps the compiled-in policy
+	PolicyFile         string
+	PolicyPollInterval time.Duration
+
+	// KeyStore enables Redis-backed API keys managed through the admin API
+	KeyStoreEnabled bool
+	KeyStoreRefresh time.Duration
+
 	Dremio   DremioConfig
 	BigQuery BigQueryConfig
 	Redis    RedisConfig
+	CORS     CORSConfig
+
+	// Tenants hosted on this gateway; empty means a single implicit tenant
+	Tenants       []TenantConfig
+	DefaultTenant string
+
+	// Exports are scheduled dumps of queries or tables to GCS/S3
+	Exports ExportsConfig
+
+	// Diff compares query results against stored snapshots
+	Diff DiffConfig
+
+	// PostProcess bounds the results the gateway orders and pages itself
+	PostProcess PostProcessConfig
+
+	// Profile bounds the column statistics of table profiles
+	Profile ProfileConfig
+
+	// Sample bounds the rows and caching of table samples
+	Sample SampleConfig
+
+	// KillSwitch shares the routes and sources switched off at runtime
+	KillSwitch KillSwitchConfig
+
+	// Mirror replays sampled read requests on a shadow target
+	Mirror MirrorConfig
+
+	// Locks elect the replica that runs each scheduled task
+	Locks LockConfig
+
+	// Sheets writes query results to Google Sheets
+	Sheets SheetsConfig
+
+	// StreamQuota limits the concurrent streams of each API key
+	StreamQuota StreamQuotaConfig
+
+	// Spill writes large streams to disk before serving them
+	Spill SpillConfig
+
+	// AutoLimit bounds raw SQL submitted without a LIMIT
+	AutoLimit AutoLimitConfig
+
+	// CacheStats restricts who may read /cache/stats
+	CacheStats CacheStatsConfig
+
+	// CacheBreaker bypasses the result cache while Redis is slow or failing
+	CacheBreaker CacheBreakerConfig
+
+	// CostMetrics collects BigQuery spend for /metrics
+	CostMetrics CostMetricsConfig
+
+	// QueryStream streams large /api/v1/query responses
+	QueryStream QueryStreamConfig
+
+	// QueryDefaults are the query options of sources that set none, and
+	// QueryCeilings bound the cache TTL and timeout a request may ask for
+	QueryDefaults QueryDefaults
+	QueryCeilings QueryCeilings
+
+	// CountCacheTTL is how long count_only results are cached; counts are
+	// cheap to serve and slow to change, so they are kept longer than rows
+	CountCacheTTL time.Duration
+
+	// NotFoundCacheTTL is how long a lookup by id that found nothing is
+	// remembered, so repeated probes of a missing id skip the source; zero
+	// disables it
+	NotFoundCacheTTL time.Duration
+
+	// DataSources declares the named data sources each tenant serves
+	DataSources []DataSourceConfig
 }
 
 type DremioConfig struct {
 	Host     string
 	Port     int
+	RESTPort int // REST API port used by the admin endpoints
 	Username string
 	Password string
 	Token    string
+
+	// CredentialsFile is an env file, such as a mounted secret, whose
+	// DREMIO_USERNAME and DREMIO_PASSWORD or DREMIO_TOKEN replace those above
+	// and are rotated to when it changes
+	CredentialsFile         string
+	CredentialsPollInterval time.Duration
+
+	UIURL        string // Base URL of the Dremio UI, for job profile links
+	JobLookup    bool   // Look up job ids Arrow Flight does not report in sys.jobs_recent
+	ExposeJobIDs bool   // Return job ids and profile links in query responses
+
+	RESTFallback    bool // Retry queries over REST when Arrow Flight cannot be reached
+	FallbackRetries int  // Arrow Flight retries before falling back to REST
+
+	KeepWarm time.Duration // Ping idle Arrow Flight connections this often; 0 disables
+
+	SessionOptions []string // Session options debug keys may set on /api/v1/query
+
+	Nessie     NessieConfig     // Branches and tags of the Iceberg tables requests may read
+	TimeTravel TimeTravelConfig // Reads of the Iceberg tables as of a past time
 }
 
+// defaultSessionOptions are the Dremio session options /api/v1/query accepts
+// unless DREMIO_SESSION_OPTIONS names others
+const defaultSessionOptions = "planner.enable_broadcast_join,planner.broadcast_threshold,planner.slice_target," +
+	"planner.width.max_per_node,planner.width.max_per_query,routing_tag,routing_queue,routing_engine"
+
 type BigQueryConfig struct {
 	ProjectID   string
 	DatasetID   string
+	Location    string // Region of the datasets and query jobs, e.g. asia-southeast2
 	Credentials string // Path to service account JSON
+
+	// Endpoint replaces the BigQuery API endpoint, e.g. a regional Private
+	// Service Connect endpoint, and CAFile adds a private CA to the roots
+	// its certificate is verified with
+	Endpoint string
+	CAFile   string
 }
 
 type RedisConfig struct {
@@ -36,27 +205,134 @@ type RedisConfig struct {
 	Port     int
 	Password string
 	DB       int
+
+	// EncryptionKey, base64 of 32 bytes, encrypts cached values with
+	// AES-256-GCM when set. Values sealed with PreviousEncryptionKey are
+	// still read, so a key can be rotated without flushing the cache.
+	EncryptionKey         string
+	PreviousEncryptionKey string
+
+	// TLS connects over TLS, verified with the CA of CAFile when set and
+	// against ServerName when it differs from Host
+	TLS        bool
+	CAFile     string
+	ServerName string
+}
+
+// CORSConfig controls which browser origins may call the gateway
+type CORSConfig struct {
+	AllowedOrigins   []string // Exact origins, "*" or wildcard subdomains like https://*.lkpp.go.id
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	AllowCredentials bool
+	MaxAge           int // Preflight cache duration in seconds
+}
+
+// Validate rejects credentials allowed for every origin: browsers refuse
+// them with "*", and echoing each origin instead would let any site read
+// credentialed responses
+func (c CORSConfig) Validate() error {
+	if !c.AllowCredentials {
+		return nil
+	}
+	for _, origin := range c.AllowedOrigins {
+		if strings.TrimSpace(origin) == "*" {
+			return errors.New("CORS_ALLOW_CREDENTIALS cannot be combined with CORS_ALLOWED_ORIGINS=*; list the allowed origins")
+		}
+	}
+	return nil
 }
 
 func Load() *Config {
-	return &Config{
+	cfg := &Config{
 		Port:        getEnv("PORT", "8080"),
 		Environment: getEnv("ENV", "development"),
 		APIKeys:     strings.Split(getEnv("API_KEYS", "demo-key-123"), ","),
+		AdminKeys:   getEnvAsSlice("ADMIN_API_KEYS", ""),
+		MetricsKeys: getEnvAsSlice("METRICS_API_KEYS", ""),
 		RateLimit:   getEnvAsInt("RATE_LIMIT", 100),
 
+		RateLimiting: loadRateLimiting(),
+		Pagination:   loadPagination(),
+		Search:       loadSearch(),
+		Relations:    loadRelations(),
+		Bulk:         loadBulk(),
+		LoadShedding: loadShedding(),
+		CacheHeaders: loadCacheHeaders(),
+		Diff:         loadDiff(),
+		PostProcess:  loadPostProcess(),
+		Profile:      loadProfile(),
+		Sample:       loadSample(),
+		KillSwitch:   loadKillSwitch(),
+		Mirror:       loadMirror(),
+		Locks:        loadLocks(),
+		Sheets:       loadSheets(),
+		StreamQuota:  loadStreamQuota(),
+		Spill:        loadSpill(),
+		AutoLimit:    loadAutoLimit(),
+		CacheStats:   loadCacheStats(),
+		CacheBreaker: loadCacheBreaker(),
+		CostMetrics:  loadCostMetrics(),
+		QueryStream:  loadQueryStream(),
+
+		TenderFilters: loadTenderFilters(),
+
+		QueryDefaults:    loadQueryDefaults(),
+		QueryCeilings:    loadQueryCeilings(),
+		CountCacheTTL:    getEnvAsDuration("QUERY_COUNT_CACHE_TTL", DefaultCountCacheTTL),
+		NotFoundCacheTTL: getEnvAsDuration("NOT_FOUND_CACHE_TTL", DefaultNotFoundCacheTTL),
+
+		QueryMetricLabels: getEnvAsSlice("QUERY_METRIC_LABELS", "app,team"),
+		LogRedactSQL:      getEnvAsBool("LOG_REDACT_SQL", true),
+		JSONFastEncoding:  getEnvAsBool("JSON_FAST_ENCODING", true),
+		NumericAsString:   getEnv("NUMERIC_AS_STRING", "auto"),
+		CoalesceRequests:  getEnvAsBool("REQUEST_COALESCING_ENABLED", true),
+
+		SchemaRefresh:     getEnvAsDuration("SCHEMA_REFRESH_INTERVAL", time.Hour),
+		TimeseriesMaxSpan: time.Duration(getEnvAsInt("TIMESERIES_MAX_SPAN_DAYS", 366)) * 24 * time.Hour,
+
+		SentryDSN: getEnv("SENTRY_DSN", ""),
+
+		PolicyFile:         getEnv("POLICY_FILE", ""),
+		PolicyPollInterval: getEnvAsDuration("POLICY_POLL_INTERVAL", 10*time.Second),
+
+		KeyStoreEnabled: getEnvAsBool("API_KEY_STORE_ENABLED", false),
+		KeyStoreRefresh: getEnvAsDuration("API_KEY_STORE_REFRESH", 5*time.Second),
+
 		Dremio: DremioConfig{
 			Host:     getEnv("DREMIO_HOST", ""),
 			Port:     getEnvAsInt("DREMIO_PORT", 31010),
+			RESTPort: getEnvAsInt("DREMIO_REST_PORT", 9047),
 			Username: getEnv("DREMIO_USERNAME", ""),
 			Password: getEnv("DREMIO_PASSWORD", ""),
 			Token:    getEnv("DREMIO_TOKEN", ""),
+
+			CredentialsFile:         getEnv("DREMIO_CREDENTIALS_FILE", ""),
+			CredentialsPollInterval: getEnvAsDuration("DREMIO_CREDENTIALS_POLL_INTERVAL", 10*time.Second),
+
+			UIURL:        getEnv("DREMIO_UI_URL", ""),
+			JobLookup:    getEnvAsBool("DREMIO_JOB_LOOKUP", false),
+			ExposeJobIDs: getEnvAsBool("DREMIO_EXPOSE_JOB_IDS", false),
+
+			RESTFallback:    getEnvAsBool("DREMIO_REST_FALLBACK", true),
+			FallbackRetries: getEnvAsInt("DREMIO_FALLBACK_RETRIES", 1),
+
+			KeepWarm: getEnvAsDuration("DREMIO_KEEP_WARM_INTERVAL", 0),
+
+			SessionOptions: getEnvAsSlice("DREMIO_SESSION_OPTIONS", defaultSessionOptions),
+
+			Nessie:     loadNessie(),
+			TimeTravel: loadTimeTravel(),
 		},
 
 		BigQuery: BigQueryConfig{
 			ProjectID:   getEnv("BIGQUERY_PROJECT_ID", ""),
 			DatasetID:   getEnv("BIGQUERY_DATASET_ID", ""),
+			Location:    getEnv("BIGQUERY_LOCATION", ""),
 			Credentials: getEnv("GOOGLE_APPLICATION_CREDENTIALS", ""),
+
+			Endpoint: getEnv("BIGQUERY_ENDPOINT", ""),
+			CAFile:   getEnv("BIGQUERY_CA_FILE", ""),
 		},
 
 		Redis: RedisConfig{
@@ -64,8 +340,38 @@ func Load() *Config {
 			Port:     getEnvAsInt("REDIS_PORT", 6379),
 			Password: getEnv("REDIS_PASSWORD", ""),
 			DB:       getEnvAsInt("REDIS_DB", 0),
+
+			EncryptionKey:         getEnv("REDIS_ENCRYPTION_KEY", ""),
+			PreviousEncryptionKey: getEnv("REDIS_ENCRYPTION_KEY_PREVIOUS", ""),
+
+			TLS:        getEnvAsBool("REDIS_TLS", false),
+			CAFile:     getEnv("REDIS_CA_FILE", ""),
+			ServerName: getEnv("REDIS_TLS_SERVER_NAME", ""),
+		},
+
+		CORS: CORSConfig{
+			AllowedOrigins:   getEnvAsSlice("CORS_ALLOWED_ORIGINS", "*"),
+			AllowedMethods:   getEnvAsSlice("CORS_ALLOWED_METHODS", "GET,POST,PUT,DELETE,OPTIONS"),
+			AllowedHeaders:   getEnvAsSlice("CORS_ALLOWED_HEADERS", "Content-Type,X-API-Key,X-Request-ID,Authorization"),
+			AllowCredentials: getEnvAsBool("CORS_ALLOW_CREDENTIALS", false),
+			MaxAge:           getEnvAsInt("CORS_MAX_AGE", 86400),
 		},
 	}
+
+	cfg.Tenants = loadTenants(cfg)
+	cfg.DefaultTenant = getEnv("DEFAULT_TENANT", "")
+	if cfg.DefaultTenant == "" && len(cfg.Tenants) > 0 {
+		cfg.DefaultTenant = cfg.Tenants[0].ID
+	}
+	cfg.Exports = loadExports(cfg)
+
+	// The UI is served on the REST port unless it sits behind another URL
+	if cfg.Dremio.UIURL == "" && cfg.Dremio.Host != "" {
+		cfg.Dremio.UIURL = "http://" + cfg.Dremio.Host + ":" + strconv.Itoa(cfg.Dremio.RESTPort)
+	}
+	cfg.DataSources = loadDataSources(cfg)
+
+	return cfg
 }
 
 func getEnv(key, defaultValue string) string {
@@ -82,3 +388,30 @@ func getEnvAsInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+	strValue := getEnv(key, "")
+	if value, err := strconv.ParseBool(strValue); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+	strValue := getEnv(key, "")
+	if value, err := time.ParseDuration(strValue); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvAsSlice splits a comma-separated variable, trimming blanks
+func getEnvAsSlice(key, defaultValue string) []string {
+	var values []string
+	for _, value := range strings.Split(getEnv(key, defaultValue), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,20 @@
+package config
+
+import "time"
+
+// CostMetricsConfig controls the background collector of BigQuery spend
+// exported on /metrics and /api/v1/admin/bigquery/costs
+type CostMetricsConfig struct {
+	Enabled    bool
+	Interval   time.Duration // Time between collections of the spend reports
+	ReportDays int           // Days of daily spend in the report
+}
+
+// loadCostMetrics reads the BIGQUERY_COST_METRICS_* variables
+func loadCostMetrics() CostMetricsConfig {
+	return CostMetricsConfig{
+		Enabled:    getEnvAsBool("BIGQUERY_COST_METRICS_ENABLED", false),
+		Interval:   getEnvAsDuration("BIGQUERY_COST_METRICS_INTERVAL", 15*time.Minute),
+		ReportDays: getEnvAsInt("BIGQUERY_COST_METRICS_DAYS", 30),
+	}
+}
@@ -0,0 +1,218 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Data source types with a built-in driver; others can be registered with
+// datasource.Register
+const (
+	SourceTypeDremioArrow = "dremio-arrow"
+	SourceTypeDremioREST  = "dremio-rest"
+	SourceTypeBigQuery    = "bigquery"
+	SourceTypePostgres    = "postgres"
+	SourceTypeMySQL       = "mysql"
+)
+
+// DataSourceConfig declares one named data source. Several sources may share
+// a type, e.g. two Dremio clusters.
+type DataSourceConfig struct {
+	Name     string            // Name handlers and requests select the source by, e.g. DATAWAREHOUSE
+	Type     string            // Driver that builds it, e.g. dremio-arrow
+	Settings map[string]string // Type-specific settings, lower-case keys such as host or project_id
+
+	// CacheNamespace separates the source's cache entries from those of other
+	// sources of its type; empty uses the name, or nothing for a source named
+	// after its type
+	CacheNamespace string
+
+	// Shadow names a source that ShadowPercent percent of this source's
+	// queries are mirrored to, logging whether the row counts match
+	Shadow        string
+	ShadowPercent int
+
+	// Defaults are the options queries on the source start from, the global
+	// defaults filling what the source does not set
+	Defaults QueryDefaults
+}
+
+// Variables of a DATA_SOURCE_<NAME>_ group that are not driver settings
+var dataSourceFields = map[string]bool{
+	"TYPE":            true,
+	"CACHE_NAMESPACE": true,
+	"SHADOW":          true,
+	"SHADOW_PERCENT":  true,
+	"CACHE_TTL":       true,
+	"TIMEOUT":         true,
+	"MAX_ROWS":        true,
+	"TIEBREAKER":      true,
+}
+
+// Setting returns the named setting, or fallback when it is unset
+func (c DataSourceConfig) Setting(key, fallback string) string {
+	if value := c.Settings[key]; value != "" {
+		return value
+	}
+	return fallback
+}
+
+// IntSetting returns the named setting as an integer, or fallback when it is
+// unset
+func (c DataSourceConfig) IntSetting(key string, fallback int) (int, error) {
+	value := c.Settings[key]
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("data source %s: %s must be an integer, got %q", c.Name, key, value)
+	}
+	return n, nil
+}
+
+// BoolSetting returns the named setting as a boolean, or fallback when it is
+// unset
+func (c DataSourceConfig) BoolSetting(key string, fallback bool) (bool, error) {
+	value := c.Settings[key]
+	if value == "" {
+		return fallback, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("data source %s: %s must be true or false, got %q", c.Name, key, value)
+	}
+	return b, nil
+}
+
+// DurationSetting returns the named setting as a duration such as "5m", or
+// fallback when it is unset
+func (c DataSourceConfig) DurationSetting(key string, fallback time.Duration) (time.Duration, error) {
+	value := c.Settings[key]
+	if value == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("data source %s: %s must be a duration, got %q", c.Name, key, value)
+	}
+	return d, nil
+}
+
+// WithSetting returns a copy of c with key set to value
+func (c DataSourceConfig) WithSetting(key, value string) DataSourceConfig {
+	settings := make(map[string]string, len(c.Settings)+1)
+	for k, v := range c.Settings {
+		settings[k] = v
+	}
+	settings[key] = value
+	c.Settings = settings
+	return c
+}
+
+// loadDataSources reads DATA_SOURCES=a,b with DATA_SOURCE_<NAME>_TYPE,
+// _CACHE_NAMESPACE, _SHADOW, _SHADOW_PERCENT and the query defaults
+// _CACHE_TTL, _TIMEOUT, _MAX_ROWS and _TIEBREAKER, and any other
+// DATA_SOURCE_<NAME>_<SETTING> variable as a setting of that source. Without
+// DATA_SOURCES the sources come from the DREMIO_* and BIGQUERY_* variables as
+// before: DATAWAREHOUSE over Arrow Flight and BIGQUERY, each when its host or
+// project is set.
+func loadDataSources(cfg *Config) []DataSourceConfig {
+	names := getEnvAsSlice("DATA_SOURCES", "")
+	if len(names) == 0 {
+		return legacyDataSources(cfg)
+	}
+
+	prefixes := make([]string, len(names))
+	for i, name := range names {
+		prefixes[i] = "DATA_SOURCE_" + tenantEnvName(name) + "_"
+	}
+
+	environ := os.Environ()
+	sources := make([]DataSourceConfig, 0, len(names))
+	for i, name := range names {
+		prefix := prefixes[i]
+		source := DataSourceConfig{
+			Name:           name,
+			Type:           strings.ToLower(getEnv(prefix+"TYPE", "")),
+			Settings:       make(map[string]string),
+			CacheNamespace: getEnv(prefix+"CACHE_NAMESPACE", ""),
+			Shadow:         getEnv(prefix+"SHADOW", ""),
+		}
+		if source.Shadow != "" {
+			source.ShadowPercent = getEnvAsInt(prefix+"SHADOW_PERCENT", 100)
+		}
+		source.Defaults = loadSourceDefaults(prefix, source.Type, cfg.QueryDefaults)
+		for _, entry := range environ {
+			key, value, _ := strings.Cut(entry, "=")
+			setting, ok := strings.CutPrefix(key, prefix)
+			if !ok || dataSourceFields[setting] || value == "" || ownedByLonger(key, prefix, prefixes) {
+				continue
+			}
+			source.Settings[strings.ToLower(setting)] = value
+		}
+		sources = append(sources, source)
+	}
+	return sources
+}
+
+// ownedByLonger reports whether key belongs to a source whose prefix extends
+// prefix, e.g. DATA_SOURCE_DW_ARCHIVE_HOST when DW and DW_ARCHIVE are declared
+func ownedByLonger(key, prefix string, prefixes []string) bool {
+	for _, other := range prefixes {
+		if len(other) > len(prefix) && strings.HasPrefix(key, other) {
+			return true
+		}
+	}
+	return false
+}
+
+// legacyDataSources declares the sources of the DREMIO_* and BIGQUERY_*
+// variables, with the query defaults of DREMIO_CACHE_TTL, BIGQUERY_CACHE_TTL
+// and so on
+func legacyDataSources(cfg *Config) []DataSourceConfig {
+	var sources []DataSourceConfig
+	if cfg.Dremio.Host != "" {
+		sources = append(sources, DataSourceConfig{
+			Name: "DATAWAREHOUSE",
+			Type: SourceTypeDremioArrow,
+			Settings: map[string]string{
+				"host":       cfg.Dremio.Host,
+				"port":       "32010", // Arrow Flight SQL port
+				"username":   cfg.Dremio.Username,
+				"password":   cfg.Dremio.Password,
+				"token":      cfg.Dremio.Token,
+				"ui_url":     cfg.Dremio.UIURL,
+				"job_lookup": strconv.FormatBool(cfg.Dremio.JobLookup),
+				"keep_warm":  cfg.Dremio.KeepWarm.String(),
+
+				// Rotated with the REST client's account
+				"shared_credentials": "true",
+
+				"rest_fallback":    strconv.FormatBool(cfg.Dremio.RESTFallback),
+				"rest_port":        strconv.Itoa(cfg.Dremio.RESTPort),
+				"fallback_retries": strconv.Itoa(cfg.Dremio.FallbackRetries),
+			},
+			Defaults: loadSourceDefaults("DREMIO_", SourceTypeDremioArrow, cfg.QueryDefaults),
+		})
+	}
+	if cfg.BigQuery.ProjectID != "" {
+		sources = append(sources, DataSourceConfig{
+			Name: "BIGQUERY",
+			Type: SourceTypeBigQuery,
+			Settings: map[string]string{
+				"project_id":  cfg.BigQuery.ProjectID,
+				"dataset_id":  cfg.BigQuery.DatasetID,
+				"location":    cfg.BigQuery.Location,
+				"credentials": cfg.BigQuery.Credentials,
+				"endpoint":    cfg.BigQuery.Endpoint,
+				"ca_file":     cfg.BigQuery.CAFile,
+			},
+			Defaults: loadSourceDefaults("BIGQUERY_", SourceTypeBigQuery, cfg.QueryDefaults),
+		})
+	}
+	return sources
+}
@@ -0,0 +1,136 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadDataSources(t *testing.T) {
+	t.Setenv("DATA_SOURCES", "DATAWAREHOUSE, dremio-archive,BIGQUERY,DATAWAREHOUSE_COLD")
+	t.Setenv("DATA_SOURCE_DATAWAREHOUSE_TYPE", "dremio-arrow")
+	t.Setenv("DATA_SOURCE_DATAWAREHOUSE_HOST", "dremio-a")
+	t.Setenv("DATA_SOURCE_DATAWAREHOUSE_JOB_LOOKUP", "true")
+	t.Setenv("DATA_SOURCE_DREMIO_ARCHIVE_TYPE", "Dremio-REST")
+	t.Setenv("DATA_SOURCE_DREMIO_ARCHIVE_HOST", "dremio-b")
+	t.Setenv("DATA_SOURCE_DREMIO_ARCHIVE_PORT", "9147")
+	t.Setenv("DATA_SOURCE_BIGQUERY_TYPE", "bigquery")
+	t.Setenv("DATA_SOURCE_BIGQUERY_PROJECT_ID", "lkpp-analytics")
+	t.Setenv("DATA_SOURCE_BIGQUERY_LOCATION", "")
+	t.Setenv("DATA_SOURCE_DATAWAREHOUSE_COLD_TYPE", "dremio-arrow")
+	t.Setenv("DATA_SOURCE_DATAWAREHOUSE_COLD_HOST", "dremio-c")
+	t.Setenv("DATA_SOURCE_DATAWAREHOUSE_COLD_CACHE_NAMESPACE", "cold")
+	t.Setenv("DATA_SOURCE_DATAWAREHOUSE_COLD_SHADOW", "DATAWAREHOUSE")
+	t.Setenv("DATA_SOURCE_DATAWAREHOUSE_COLD_SHADOW_PERCENT", "5")
+	// Legacy variables are ignored once DATA_SOURCES is set
+	t.Setenv("DREMIO_HOST", "legacy")
+
+	sources := loadDataSources(&Config{Dremio: DremioConfig{Host: "legacy"}})
+	require.Len(t, sources, 4)
+
+	assert.Equal(t, DataSourceConfig{
+		Name:     "DATAWAREHOUSE",
+		Type:     SourceTypeDremioArrow,
+		Settings: map[string]string{"host": "dremio-a", "job_lookup": "true"},
+	}, sources[0])
+	assert.Equal(t, DataSourceConfig{
+		Name:     "dremio-archive",
+		Type:     SourceTypeDremioREST,
+		Settings: map[string]string{"host": "dremio-b", "port": "9147"},
+	}, sources[1])
+	assert.Equal(t, DataSourceConfig{
+		Name:     "BIGQUERY",
+		Type:     SourceTypeBigQuery,
+		Settings: map[string]string{"project_id": "lkpp-analytics"},
+		Defaults: QueryDefaults{CacheTTL: 15 * time.Minute, Timeout: time.Minute},
+	}, sources[2])
+	assert.Equal(t, DataSourceConfig{
+		Name:           "DATAWAREHOUSE_COLD",
+		Type:           SourceTypeDremioArrow,
+		Settings:       map[string]string{"host": "dremio-c"},
+		CacheNamespace: "cold",
+		Shadow:         "DATAWAREHOUSE",
+		ShadowPercent:  5,
+	}, sources[3])
+}
+
+func TestLoadDataSources_LegacyVariables(t *testing.T) {
+	t.Setenv("DATA_SOURCES", "")
+
+	assert.Empty(t, loadDataSources(&Config{}))
+
+	sources := loadDataSources(&Config{
+		Dremio:   DremioConfig{Host: "dremio", RESTPort: 9047, Username: "svc", UIURL: "http://dremio:9047", JobLookup: true, RESTFallback: true, FallbackRetries: 1, KeepWarm: 30 * time.Second},
+		BigQuery: BigQueryConfig{ProjectID: "lkpp", DatasetID: "rup", Location: "asia-southeast2"},
+	})
+	require.Len(t, sources, 2)
+	assert.Equal(t, "DATAWAREHOUSE", sources[0].Name)
+	assert.Equal(t, SourceTypeDremioArrow, sources[0].Type)
+	assert.Equal(t, "32010", sources[0].Setting("port", ""))
+	assert.Equal(t, "true", sources[0].Setting("job_lookup", ""))
+	assert.Equal(t, "true", sources[0].Setting("rest_fallback", ""))
+	assert.Equal(t, "9047", sources[0].Setting("rest_port", ""))
+	assert.Equal(t, "30s", sources[0].Setting("keep_warm", ""))
+	assert.Equal(t, "BIGQUERY", sources[1].Name)
+	assert.Equal(t, "rup", sources[1].Setting("dataset_id", ""))
+}
+
+func TestLoadDataSources_QueryDefaults(t *testing.T) {
+	t.Setenv("DATA_SOURCES", "DATAWAREHOUSE,BIGQUERY,ARCHIVE")
+	t.Setenv("DATA_SOURCE_DATAWAREHOUSE_TYPE", "dremio-arrow")
+	t.Setenv("DATA_SOURCE_BIGQUERY_TYPE", "bigquery")
+	t.Setenv("DATA_SOURCE_ARCHIVE_TYPE", "bigquery")
+	t.Setenv("DATA_SOURCE_ARCHIVE_CACHE_TTL", "1h")
+	t.Setenv("DATA_SOURCE_ARCHIVE_MAX_ROWS", "500")
+	t.Setenv("DATA_SOURCE_ARCHIVE_TIEBREAKER", "id; DROP")
+	t.Setenv("QUERY_DEFAULT_TIMEOUT", "45s")
+	t.Setenv("QUERY_DEFAULT_MAX_ROWS", "10000")
+
+	global := loadQueryDefaults()
+	assert.Equal(t, QueryDefaults{CacheTTL: 5 * time.Minute, Timeout: 45 * time.Second, MaxRows: 10000}, global)
+
+	sources := loadDataSources(&Config{QueryDefaults: global})
+	require.Len(t, sources, 3)
+	// Source variable > built-in type default > global default
+	assert.Equal(t, QueryDefaults{CacheTTL: 5 * time.Minute, Timeout: 45 * time.Second, MaxRows: 10000}, sources[0].Defaults)
+	assert.Equal(t, QueryDefaults{CacheTTL: 15 * time.Minute, Timeout: time.Minute, MaxRows: 10000}, sources[1].Defaults)
+	assert.Equal(t, QueryDefaults{CacheTTL: time.Hour, Timeout: time.Minute, MaxRows: 500}, sources[2].Defaults)
+	assert.Empty(t, sources[2].Settings, "query defaults are not driver settings")
+
+	t.Setenv("DATA_SOURCES", "")
+	t.Setenv("BIGQUERY_TIEBREAKER", "kd_rup")
+	sources = loadDataSources(&Config{
+		Dremio:        DremioConfig{Host: "dremio"},
+		BigQuery:      BigQueryConfig{ProjectID: "lkpp"},
+		QueryDefaults: DefaultQueryDefaults(),
+	})
+	require.Len(t, sources, 2)
+	assert.Equal(t, DefaultQueryDefaults(), sources[0].Defaults)
+	assert.Equal(t, QueryDefaults{CacheTTL: 15 * time.Minute, Timeout: time.Minute, Tiebreaker: "kd_rup"}, sources[1].Defaults)
+}
+
+func TestDataSourceConfig_Settings(t *testing.T) {
+	source := DataSourceConfig{Name: "dw", Settings: map[string]string{"port": "x", "tls": "yes", "max_connections": "4"}}
+
+	n, err := source.IntSetting("max_connections", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, n)
+	n, err = source.IntSetting("min_connections", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	_, err = source.IntSetting("port", 32010)
+	assert.EqualError(t, err, `data source dw: port must be an integer, got "x"`)
+	_, err = source.BoolSetting("tls", false)
+	assert.Error(t, err)
+	d, err := source.DurationSetting("keep_warm", time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Minute, d)
+	_, err = source.WithSetting("keep_warm", "30").DurationSetting("keep_warm", 0)
+	assert.EqualError(t, err, `data source dw: keep_warm must be a duration, got "30"`)
+
+	changed := source.WithSetting("project", "archive")
+	assert.Equal(t, "archive", changed.Setting("project", ""))
+	assert.Equal(t, "fallback", source.Setting("project", "fallback"), "the original keeps its settings")
+}
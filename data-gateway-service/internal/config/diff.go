@@ -0,0 +1,19 @@
+package config
+
+import "time"
+
+// DiffConfig controls POST /api/v1/diff and the snapshots it compares against
+type DiffConfig struct {
+	MaxRows     int           // Rows a compared result may have
+	SnapshotTTL time.Duration // Lifetime of snapshots kept in the cache
+	GCSPath     string        // gs://bucket/prefix; when set snapshots are stored there instead of the cache
+}
+
+// loadDiff reads the DIFF_* variables
+func loadDiff() DiffConfig {
+	return DiffConfig{
+		MaxRows:     getEnvAsInt("DIFF_MAX_ROWS", 50000),
+		SnapshotTTL: getEnvAsDuration("DIFF_SNAPSHOT_TTL", 30*24*time.Hour),
+		GCSPath:     getEnv("DIFF_SNAPSHOT_GCS_PATH", ""),
+	}
+}
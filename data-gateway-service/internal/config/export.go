@@ -0,0 +1,91 @@
+package config
+
+import "time"
+
+// ExportConfig describes a scheduled export of a query or table to object storage
+type ExportConfig struct {
+	Name        string
+	Tenant      string // Tenant whose data source instance is read; empty means the default tenant
+	Source      string // Data source name, e.g. DATAWAREHOUSE or BIGQUERY
+	Query       string
+	Table       string
+	Filters     string // JSON object of column filters applied to Table
+	OrderBy     string
+	Format      string // csv, ndjson or parquet
+	Destination string // gs://bucket/key or s3://bucket/key; {date} and {timestamp} are expanded per run
+	Schedule    string // Five-field cron expression or @hourly/@daily/@weekly/@monthly
+	ChunkSize   int
+
+	CSV ExportCSVConfig // Only valid for csv exports
+}
+
+// ExportCSVConfig holds the locale formatting of a CSV export; see csvfmt.Options
+type ExportCSVConfig struct {
+	Locale           string
+	DecimalSeparator string
+	DateFormat       string
+	BOM              bool
+	Flatten          bool // Write record fields as parent.field columns
+}
+
+// ExportsConfig holds the scheduled exports and their shared settings
+type ExportsConfig struct {
+	Jobs         []ExportConfig
+	RunTimeout   time.Duration
+	HistorySize  int    // Runs kept per export
+	AlertWebhook string // URL notified when a run fails
+
+	GCSCredentials string // Service account JSON; empty uses application default credentials
+	S3             S3Config
+}
+
+// S3Config holds credentials for s3:// export destinations
+type S3Config struct {
+	Region          string
+	Endpoint        string // Custom endpoint for S3-compatible stores; uses path-style URLs
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// loadExports reads EXPORTS=a,b and the per-export EXPORT_<NAME>_* variables
+func loadExports(cfg *Config) ExportsConfig {
+	exports := ExportsConfig{
+		RunTimeout:     getEnvAsDuration("EXPORT_RUN_TIMEOUT", time.Hour),
+		HistorySize:    getEnvAsInt("EXPORT_HISTORY_SIZE", 20),
+		AlertWebhook:   getEnv("EXPORT_ALERT_WEBHOOK_URL", ""),
+		GCSCredentials: getEnv("EXPORT_GCS_CREDENTIALS", cfg.BigQuery.Credentials),
+		S3: S3Config{
+			Region:          getEnv("AWS_REGION", "us-east-1"),
+			Endpoint:        getEnv("S3_ENDPOINT", ""),
+			AccessKeyID:     getEnv("AWS_ACCESS_KEY_ID", ""),
+			SecretAccessKey: getEnv("AWS_SECRET_ACCESS_KEY", ""),
+			SessionToken:    getEnv("AWS_SESSION_TOKEN", ""),
+		},
+	}
+
+	for _, name := range getEnvAsSlice("EXPORTS", "") {
+		prefix := "EXPORT_" + tenantEnvName(name) + "_"
+		exports.Jobs = append(exports.Jobs, ExportConfig{
+			Name:        name,
+			Tenant:      getEnv(prefix+"TENANT", ""),
+			Source:      getEnv(prefix+"SOURCE", "DATAWAREHOUSE"),
+			Query:       getEnv(prefix+"QUERY", ""),
+			Table:       getEnv(prefix+"TABLE", ""),
+			Filters:     getEnv(prefix+"FILTERS", ""),
+			OrderBy:     getEnv(prefix+"ORDER_BY", ""),
+			Format:      getEnv(prefix+"FORMAT", "csv"),
+			Destination: getEnv(prefix+"DESTINATION", ""),
+			Schedule:    getEnv(prefix+"SCHEDULE", ""),
+			ChunkSize:   getEnvAsInt(prefix+"CHUNK_SIZE", 10000),
+			CSV: ExportCSVConfig{
+				Locale:           getEnv(prefix+"LOCALE", ""),
+				DecimalSeparator: getEnv(prefix+"DECIMAL_SEPARATOR", ""),
+				DateFormat:       getEnv(prefix+"DATE_FORMAT", ""),
+				BOM:              getEnvAsBool(prefix+"BOM", false),
+				Flatten:          getEnvAsBool(prefix+"FLATTEN", false),
+			},
+		})
+	}
+	return exports
+}
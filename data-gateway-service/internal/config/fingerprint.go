@@ -0,0 +1,101 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// fingerprintExcluded are the fields, and data source settings, left out of
+// the config fingerprint: credentials, which must not be exposed even hashed,
+// and the lock owner, which differs on every replica
+var fingerprintExcluded = map[string]bool{
+	"APIKeys":               true,
+	"AdminKeys":             true,
+	"MetricsKeys":           true,
+	"APIKey":                true,
+	"Password":              true,
+	"Token":                 true,
+	"SentryDSN":             true,
+	"EncryptionKey":         true,
+	"PreviousEncryptionKey": true,
+	"AlertWebhook":          true,
+	"GCSCredentials":        true,
+	"AccessKeyID":           true,
+	"SecretAccessKey":       true,
+	"SessionToken":          true,
+	"Owner":                 true,
+	"password":              true,
+	"token":                 true,
+}
+
+// Fingerprint returns a short hash of the effective configuration without
+// its secrets. Replicas configured alike share a fingerprint, so a replica
+// whose environment drifted stands out.
+func (c *Config) Fingerprint() string {
+	raw, err := json.Marshal(c)
+	if err != nil {
+		return ""
+	}
+	var tree interface{}
+	if err := json.Unmarshal(raw, &tree); err != nil {
+		return ""
+	}
+	// Maps marshal with sorted keys, so equal configs hash alike
+	raw, err = json.Marshal(withoutSecrets(tree))
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:8])
+}
+
+// withoutSecrets drops the excluded keys from a decoded JSON value
+func withoutSecrets(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if fingerprintExcluded[key] {
+				delete(v, key)
+				continue
+			}
+			v[key] = withoutSecrets(value)
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = withoutSecrets(value)
+		}
+	}
+	return v
+}
+
+// Features reports which optional features are switched on, for support to
+// see at a glance how a gateway is configured
+func (c *Config) Features() map[string]bool {
+	return map[string]bool{
+		"key_store":               c.KeyStoreEnabled,
+		"policy_file":             c.PolicyFile != "",
+		"multi_tenant":            len(c.Tenants) > 0,
+		"load_shedding":           c.LoadShedding.Enabled,
+		"request_coalescing":      c.CoalesceRequests,
+		"json_fast_encoding":      c.JSONFastEncoding,
+		"log_redact_sql":          c.LogRedactSQL,
+		"sentry":                  c.SentryDSN != "",
+		"auto_limit":              c.AutoLimit.Limit > 0,
+		"query_stream":            c.QueryStream.RowThreshold > 0 || c.QueryStream.ByteThreshold > 0,
+		"stream_quota":            c.StreamQuota.MaxPerKey > 0,
+		"spill":                   c.Spill.Enabled,
+		"exports":                 len(c.Exports.Jobs) > 0,
+		"sheets":                  c.Sheets.Enabled,
+		"mirror":                  c.Mirror.Enabled(),
+		"cache_breaker":           c.CacheBreaker.Enabled,
+		"bigquery_cost_metrics":   c.CostMetrics.Enabled,
+		"cache_encryption":        c.Redis.EncryptionKey != "",
+		"redis_tls":               c.Redis.TLS,
+		"bigquery_endpoint":       c.BigQuery.Endpoint != "",
+		"dremio_rest_fallback":    c.Dremio.RESTFallback,
+		"dremio_job_ids":          c.Dremio.ExposeJobIDs,
+		"dremio_credentials_file": c.Dremio.CredentialsFile != "",
+		"cache_stats_summary":     c.CacheStats.PublicSummary,
+	}
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFingerprint(t *testing.T) {
+	t.Setenv("DATA_SOURCES", "")
+	t.Setenv("DREMIO_HOST", "dremio")
+	t.Setenv("DREMIO_PASSWORD", "first")
+	t.Setenv("LOCK_OWNER", "replica-a")
+	base := Load()
+	fingerprint := base.Fingerprint()
+	require.Len(t, fingerprint, 16)
+	assert.Equal(t, fingerprint, Load().Fingerprint())
+
+	// Secrets and the per-replica lock owner do not count
+	t.Setenv("DREMIO_PASSWORD", "rotated")
+	t.Setenv("API_KEYS", "other-key")
+	t.Setenv("REDIS_ENCRYPTION_KEY", "c2VjcmV0")
+	t.Setenv("LOCK_OWNER", "replica-b")
+	cfg := Load()
+	assert.Equal(t, "rotated", cfg.DataSources[0].Setting("password", ""))
+	assert.Equal(t, fingerprint, cfg.Fingerprint())
+
+	// Anything else does
+	t.Setenv("RATE_LIMIT", "250")
+	assert.NotEqual(t, fingerprint, Load().Fingerprint())
+}
+
+func TestFeatures(t *testing.T) {
+	t.Setenv("SPILL_ENABLED", "true")
+	t.Setenv("QUERY_AUTO_LIMIT", "0")
+	t.Setenv("REDIS_TLS", "true")
+
+	features := Load().Features()
+	assert.True(t, features["spill"])
+	assert.True(t, features["redis_tls"])
+	assert.False(t, features["auto_limit"])
+	assert.False(t, features["mirror"])
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// KillSwitchConfig controls how replicas share the routes and data sources
+// switched off at runtime
+type KillSwitchConfig struct {
+	RefreshInterval time.Duration // How often flags flipped on other replicas are read
+}
+
+// DefaultKillSwitch reads the shared flags every 5 seconds
+func DefaultKillSwitch() KillSwitchConfig {
+	return KillSwitchConfig{RefreshInterval: 5 * time.Second}
+}
+
+// loadKillSwitch reads the KILL_SWITCH_* variables
+func loadKillSwitch() KillSwitchConfig {
+	defaults := DefaultKillSwitch()
+	return KillSwitchConfig{
+		RefreshInterval: getEnvAsDuration("KILL_SWITCH_REFRESH_INTERVAL", defaults.RefreshInterval),
+	}
+}
+
+// FlagPolicy lists the API routes and data sources switched off by the
+// policy file. A route is an API path such as /api/v1/tender and covers the
+// paths below it; a source is a data source name such as DATAWAREHOUSE.
+type FlagPolicy struct {
+	Routes  map[string]DisabledFlag `yaml:"routes"`
+	Sources map[string]DisabledFlag `yaml:"sources"`
+}
+
+// DisabledFlag describes why a route or source is off and when to retry
+type DisabledFlag struct {
+	Message    string        `yaml:"message"`
+	RetryAfter time.Duration `yaml:"retry_after"` // Sent as Retry-After; a minute when unset
+}
+
+// killSwitchRoutePrefix is the prefix of the routes that can be switched off
+const killSwitchRoutePrefix = "/api/v1/"
+
+var flagSourcePattern = regexp.MustCompile(`^[a-zA-Z0-9_\-]+$`)
+
+// ValidateFlagRoute rejects routes outside the API and the admin routes,
+// which must stay reachable to switch routes back on
+func ValidateFlagRoute(route string) error {
+	if !strings.HasPrefix(route, killSwitchRoutePrefix) || strings.HasSuffix(route, "/") {
+		return fmt.Errorf("route %q must be a path below %s without a trailing slash", route, killSwitchRoutePrefix)
+	}
+	if admin := killSwitchRoutePrefix + "admin"; route == admin || strings.HasPrefix(route, admin+"/") {
+		return fmt.Errorf("route %q: admin routes cannot be disabled", route)
+	}
+	return nil
+}
+
+// ValidateFlagSource rejects source names no data source can have
+func ValidateFlagSource(source string) error {
+	if !flagSourcePattern.MatchString(source) {
+		return fmt.Errorf("invalid source name %q", source)
+	}
+	return nil
+}
+
+// Validate rejects invalid routes and source names and negative retry times
+func (p FlagPolicy) Validate() error {
+	for _, list := range []struct {
+		name     string
+		flags    map[string]DisabledFlag
+		validate func(string) error
+	}{
+		{"routes", p.Routes, ValidateFlagRoute},
+		{"sources", p.Sources, ValidateFlagSource},
+	} {
+		names := make([]string, 0, len(list.flags))
+		for name := range list.flags {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if err := list.validate(name); err != nil {
+				return fmt.Errorf("%s: %w", list.name, err)
+			}
+			if list.flags[name].RetryAfter < 0 {
+				return fmt.Errorf("%s: %s: retry_after must not be negative", list.name, name)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// LockConfig controls the Redis locks that make scheduled tasks run on one
+// replica only
+type LockConfig struct {
+	TTL   time.Duration // Lease lifetime; renewed at a third of it while a task runs
+	Owner string        // Identifies this replica in lock values and the admin API
+}
+
+// loadLocks reads the LOCK_* variables; the owner defaults to hostname-pid
+func loadLocks() LockConfig {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "gateway"
+	}
+	return LockConfig{
+		TTL:   getEnvAsDuration("LOCK_TTL", 30*time.Second),
+		Owner: getEnv("LOCK_OWNER", fmt.Sprintf("%s-%d", hostname, os.Getpid())),
+	}
+}
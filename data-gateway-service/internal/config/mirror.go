@@ -0,0 +1,71 @@
+package config
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MirrorConfig controls the replay of sampled read requests on a shadow
+// target, for comparing a new data path with production traffic. Requests
+// are replayed either in process with Sources substituted, or on the
+// gateway at URL.
+type MirrorConfig struct {
+	Routes       map[string]int    // Path patterns mirrored, to the percent of their requests sampled
+	URL          string            // Gateway mirrored requests are sent to, without their API keys
+	APIKey       string            // Key the gateway at URL authenticates mirrored requests with
+	Sources      map[string]string // Data sources replaced by others when replaying in process
+	QueueSize    int               // Mirrored requests waiting for a worker; more are dropped
+	Workers      int               // Mirrored requests replayed at once
+	Timeout      time.Duration     // Bounds each replay
+	MaxBodyBytes int64             // Requests and responses over this are not mirrored
+	LogSize      int               // Mismatches kept for the admin API
+}
+
+// Enabled reports whether any route is mirrored
+func (c MirrorConfig) Enabled() bool {
+	return len(c.Routes) > 0
+}
+
+// loadMirror reads the MIRROR_* variables. MIRROR_ROUTES=pattern:percent,...
+// samples each pattern's requests at its percent, or at MIRROR_PERCENT when
+// it has none, and a pattern at 0 excludes what it matches from a broader
+// one; MIRROR_SOURCES=from:to,... replays reads of from on to.
+func loadMirror() MirrorConfig {
+	cfg := MirrorConfig{
+		Routes:       make(map[string]int),
+		URL:          strings.TrimSuffix(getEnv("MIRROR_URL", ""), "/"),
+		APIKey:       getEnv("MIRROR_API_KEY", ""),
+		Sources:      make(map[string]string),
+		QueueSize:    getEnvAsInt("MIRROR_QUEUE_SIZE", 100),
+		Workers:      getEnvAsInt("MIRROR_WORKERS", 2),
+		Timeout:      getEnvAsDuration("MIRROR_TIMEOUT", time.Minute),
+		MaxBodyBytes: int64(getEnvAsInt("MIRROR_MAX_BODY_MB", 8)) << 20,
+		LogSize:      getEnvAsInt("MIRROR_LOG_SIZE", 200),
+	}
+
+	percent := getEnvAsInt("MIRROR_PERCENT", 10)
+	for _, entry := range getEnvAsSlice("MIRROR_ROUTES", "") {
+		pattern, rate, hasRate := strings.Cut(entry, ":")
+		pattern = strings.TrimSpace(pattern)
+		routePercent := percent
+		if hasRate {
+			parsed, err := strconv.Atoi(strings.TrimSpace(rate))
+			if err != nil {
+				continue
+			}
+			routePercent = parsed
+		}
+		if pattern != "" && routePercent >= 0 {
+			cfg.Routes[pattern] = min(routePercent, 100)
+		}
+	}
+
+	for _, entry := range getEnvAsSlice("MIRROR_SOURCES", "") {
+		from, to, _ := strings.Cut(entry, ":")
+		if from, to = strings.TrimSpace(from), strings.TrimSpace(to); from != "" && to != "" {
+			cfg.Sources[from] = to
+		}
+	}
+	return cfg
+}
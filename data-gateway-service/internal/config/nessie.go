@@ -0,0 +1,45 @@
+package config
+
+// NessieConfig names the Nessie catalog source of the Dremio Iceberg tables
+// and the branches and tags requests may read them at
+type NessieConfig struct {
+	Source   string   // Catalog source the references belong to, e.g. nessie_iceberg
+	Branches []string // Branches requests may select; the first is the default
+	Tags     []string // Tags requests may select
+}
+
+// loadNessie reads the DREMIO_NESSIE_* variables
+func loadNessie() NessieConfig {
+	return NessieConfig{
+		Source:   getEnv("DREMIO_NESSIE_SOURCE", "nessie_iceberg"),
+		Branches: getEnvAsSlice("DREMIO_NESSIE_BRANCHES", "main"),
+		Tags:     getEnvAsSlice("DREMIO_NESSIE_TAGS", ""),
+	}
+}
+
+// DefaultBranch is the branch queries read when they select none
+func (c NessieConfig) DefaultBranch() string {
+	if len(c.Branches) == 0 {
+		return "main"
+	}
+	return c.Branches[0]
+}
+
+// RefType returns BRANCH or TAG for an allowed reference name, and "" for a
+// name that is neither. The default branch is always allowed.
+func (c NessieConfig) RefType(name string) string {
+	if name == c.DefaultBranch() {
+		return "BRANCH"
+	}
+	for _, branch := range c.Branches {
+		if branch == name {
+			return "BRANCH"
+		}
+	}
+	for _, tag := range c.Tags {
+		if tag == name {
+			return "TAG"
+		}
+	}
+	return ""
+}
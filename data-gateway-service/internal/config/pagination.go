@@ -0,0 +1,117 @@
+package config
+
+import (
+	"regexp"
+	"strings"
+)
+
+// PageLimit is the page size policy of an endpoint group
+type PageLimit struct {
+	Default int // Applied when a request does not ask for a limit
+	Max     int // Larger requests are rejected with 400
+}
+
+// PaginationConfig holds the page size policy of each endpoint group
+type PaginationConfig struct {
+	Tender PageLimit // /tender list and search
+	RUP    PageLimit // /rup list and search
+	Query  PageLimit // /query rows returned
+	Stream PageLimit // /stream chunk_size
+	Tables PageLimit // /sources/{source}/tables/{table}/rows
+
+	// Tiebreakers are appended to the ORDER BY of paged reads
+	Tiebreakers Tiebreakers
+}
+
+// Tiebreakers maps a logical table to a unique column, usually its primary
+// key. Paged reads of the table order by it after the sort column, so rows
+// tied on the sort column keep their page.
+type Tiebreakers map[string]string
+
+// For returns the tiebreaker of table, looked up by its full name and then by
+// its last dot-separated part, e.g. tender_data for nessie_iceberg.tender_data;
+// empty when none is configured
+func (t Tiebreakers) For(table string) string {
+	table = strings.ReplaceAll(table, "`", "")
+	if column, ok := t[table]; ok {
+		return column
+	}
+	if i := strings.LastIndex(table, "."); i >= 0 {
+		return t[table[i+1:]]
+	}
+	return ""
+}
+
+// DefaultTiebreakers returns the primary keys of the built-in endpoints' tables
+func DefaultTiebreakers() Tiebreakers {
+	return Tiebreakers{
+		"tender_data":   "tender_id",
+		"rup_kromaster": "kd_kro_str",
+	}
+}
+
+// DefaultPagination returns the built-in page size policy
+func DefaultPagination() PaginationConfig {
+	return PaginationConfig{
+		Tender: PageLimit{Default: 100, Max: 1000},
+		RUP:    PageLimit{Default: 100, Max: 1000},
+		Query:  PageLimit{Default: 1000, Max: 10000},
+		Stream: PageLimit{Default: 1000, Max: 10000},
+		Tables: PageLimit{Default: 100, Max: 1000},
+
+		Tiebreakers: DefaultTiebreakers(),
+	}
+}
+
+// loadPagination reads PAGINATION_<GROUP>_DEFAULT_LIMIT and
+// PAGINATION_<GROUP>_MAX_LIMIT over the built-in policy, and
+// PAGINATION_TIEBREAKERS over the built-in tiebreakers
+func loadPagination() PaginationConfig {
+	p := DefaultPagination()
+	p.Tender = loadPageLimit("TENDER", p.Tender)
+	p.RUP = loadPageLimit("RUP", p.RUP)
+	p.Query = loadPageLimit("QUERY", p.Query)
+	p.Stream = loadPageLimit("STREAM", p.Stream)
+	p.Tables = loadPageLimit("TABLES", p.Tables)
+	p.Tiebreakers = loadTiebreakers(p.Tiebreakers)
+	return p
+}
+
+// tiebreakerColumn is the form of a tiebreaker, which is ordered by unquoted
+var tiebreakerColumn = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// loadTiebreakers reads PAGINATION_TIEBREAKERS=table:column,... over
+// defaults; an entry without a column removes the table's tiebreaker, and
+// entries whose column is not a plain name are ignored
+func loadTiebreakers(defaults Tiebreakers) Tiebreakers {
+	tiebreakers := make(Tiebreakers, len(defaults))
+	for table, column := range defaults {
+		tiebreakers[table] = column
+	}
+	for _, entry := range getEnvAsSlice("PAGINATION_TIEBREAKERS", "") {
+		table, column, _ := strings.Cut(entry, ":")
+		table, column = strings.TrimSpace(table), strings.TrimSpace(column)
+		switch {
+		case table == "":
+		case column == "":
+			delete(tiebreakers, table)
+		case tiebreakerColumn.MatchString(column):
+			tiebreakers[table] = column
+		}
+	}
+	return tiebreakers
+}
+
+func loadPageLimit(group string, defaults PageLimit) PageLimit {
+	limit := PageLimit{
+		Default: getEnvAsInt("PAGINATION_"+group+"_DEFAULT_LIMIT", defaults.Default),
+		Max:     getEnvAsInt("PAGINATION_"+group+"_MAX_LIMIT", defaults.Max),
+	}
+	if limit.Max <= 0 {
+		limit.Max = defaults.Max
+	}
+	if limit.Default <= 0 || limit.Default > limit.Max {
+		limit.Default = min(defaults.Default, limit.Max)
+	}
+	return limit
+}
@@ -0,0 +1,27 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTiebreakers_For(t *testing.T) {
+	tiebreakers := DefaultTiebreakers()
+	assert.Equal(t, "tender_id", tiebreakers.For("nessie_iceberg.tender_data"))
+	assert.Equal(t, "kd_kro_str", tiebreakers.For("`gtp-data-prod.layer_isb`.rup_kromaster"))
+	assert.Empty(t, tiebreakers.For("nessie_iceberg.unknown"))
+
+	tiebreakers["nessie_iceberg.tender_data"] = "kode_tender"
+	assert.Equal(t, "kode_tender", tiebreakers.For("nessie_iceberg.tender_data"), "the full name wins")
+}
+
+func TestLoadTiebreakers(t *testing.T) {
+	t.Setenv("PAGINATION_TIEBREAKERS", "penyedia:kd_penyedia, rup_kromaster:, tender_data:id; DROP")
+
+	tiebreakers := loadPagination().Tiebreakers
+	assert.Equal(t, Tiebreakers{
+		"tender_data": "tender_id",
+		"penyedia":    "kd_penyedia",
+	}, tiebreakers)
+}
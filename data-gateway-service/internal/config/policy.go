@@ -0,0 +1,242 @@
+package config
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultPolicyVersion is the version of the compiled-in policy
+const DefaultPolicyVersion = "default"
+
+// Policy is the configuration that can be replaced while the gateway runs:
+// the table and column whitelists, the cache TTLs and the routes and data
+// sources switched off. It is loaded from
+// POLICY_FILE; without one the compiled-in defaults apply. A Policy is never
+// modified once active.
+type Policy struct {
+	Security *SecurityConfig
+	Cache    CacheTTLPolicy
+	Flags    FlagPolicy
+	Version  string    // Short sha256 of the file, or DefaultPolicyVersion
+	Path     string    // File the policy was read from; empty for the defaults
+	LoadedAt time.Time // Zero for the defaults
+}
+
+// CacheTTLPolicy decides how long results are cached
+type CacheTTLPolicy struct {
+	DefaultTTL time.Duration            // Results whose query requests no TTL
+	MaxTTL     time.Duration            // Upper bound on any TTL; 0 for none
+	Tables     map[string]time.Duration // Table reads, replacing the requested TTL
+}
+
+// DefaultCacheTTLPolicy caches for 5 minutes unless a query asks otherwise
+func DefaultCacheTTLPolicy() CacheTTLPolicy {
+	return CacheTTLPolicy{DefaultTTL: 5 * time.Minute}
+}
+
+// TTL returns the lifetime of a result that requested ttl, 0 for none. table
+// is the table of a table read and empty for a query.
+func (p CacheTTLPolicy) TTL(table string, requested time.Duration) time.Duration {
+	ttl := requested
+	if tableTTL, ok := p.Tables[table]; ok && table != "" {
+		ttl = tableTTL
+	} else if ttl <= 0 {
+		ttl = p.DefaultTTL
+	}
+	if p.MaxTTL > 0 && ttl > p.MaxTTL {
+		ttl = p.MaxTTL
+	}
+	return ttl
+}
+
+var (
+	activePolicy  atomic.Pointer[Policy]
+	defaultPolicy = &Policy{
+		Security: GetDefaultSecurityConfig(),
+		Cache:    DefaultCacheTTLPolicy(),
+		Version:  DefaultPolicyVersion,
+	}
+)
+
+// ActivePolicy returns the policy in effect
+func ActivePolicy() *Policy {
+	if p := activePolicy.Load(); p != nil {
+		return p
+	}
+	return defaultPolicy
+}
+
+// SetPolicy makes p the policy in effect. Readers holding the previous policy
+// keep a consistent view of it.
+func SetPolicy(p *Policy) {
+	activePolicy.Store(p)
+}
+
+// ActiveSecurityConfig returns the security config of the policy in effect.
+// It must not be modified.
+func ActiveSecurityConfig() *SecurityConfig {
+	return ActivePolicy().Security
+}
+
+// SecurityProvider returns the security config to check a request against,
+// e.g. ActiveSecurityConfig or GetDefaultSecurityConfig
+type SecurityProvider func() *SecurityConfig
+
+// policyFile is the YAML layout of POLICY_FILE. An omitted section keeps
+// its compiled-in defaults.
+type policyFile struct {
+	Security *struct {
+		DremioTables   []string                `yaml:"dremio_tables"`
+		BigQueryTables []string                `yaml:"bigquery_tables"`
+		TableColumns   map[string][]ColumnSpec `yaml:"table_columns"`
+	} `yaml:"security"`
+	Cache *struct {
+		DefaultTTL time.Duration            `yaml:"default_ttl"`
+		MaxTTL     time.Duration            `yaml:"max_ttl"`
+		Tables     map[string]time.Duration `yaml:"tables"`
+	} `yaml:"cache"`
+	Flags *FlagPolicy `yaml:"flags"`
+}
+
+var (
+	policyTablePattern  = regexp.MustCompile(`^[a-zA-Z0-9_\-\.]+$`)
+	policyColumnPattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// ParsePolicy reads a policy file. Unknown fields, unsafe names and
+// inconsistent sections are rejected, so a bad edit never replaces a working
+// policy.
+func ParsePolicy(data []byte) (*Policy, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	var file policyFile
+	if err := decoder.Decode(&file); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("policy file is empty")
+		}
+		return nil, fmt.Errorf("invalid policy file: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+	p := &Policy{
+		Security: GetDefaultSecurityConfig(),
+		Cache:    DefaultCacheTTLPolicy(),
+		Version:  hex.EncodeToString(sum[:])[:12],
+	}
+
+	if s := file.Security; s != nil {
+		p.Security = &SecurityConfig{
+			AllowedDremioTables:   s.DremioTables,
+			AllowedBigQueryTables: s.BigQueryTables,
+			TableColumns:          s.TableColumns,
+		}
+		if err := p.Security.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid security policy: %w", err)
+		}
+	}
+
+	if c := file.Cache; c != nil {
+		p.Cache.MaxTTL = c.MaxTTL
+		p.Cache.Tables = c.Tables
+		if c.DefaultTTL != 0 {
+			p.Cache.DefaultTTL = c.DefaultTTL
+		}
+		if err := p.Cache.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid cache policy: %w", err)
+		}
+	}
+
+	if f := file.Flags; f != nil {
+		if err := f.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid flags policy: %w", err)
+		}
+		p.Flags = *f
+	}
+
+	return p, nil
+}
+
+// Validate rejects empty whitelists, table and column names the sanitizer
+// would refuse, unknown column types and columns of tables not whitelisted
+func (s *SecurityConfig) Validate() error {
+	lists := []struct {
+		name   string
+		tables []string
+	}{
+		{"dremio_tables", s.AllowedDremioTables},
+		{"bigquery_tables", s.AllowedBigQueryTables},
+	}
+	allowed := make(map[string]bool)
+	for _, list := range lists {
+		// An empty sanitizer whitelist would allow every table
+		if len(list.tables) == 0 {
+			return fmt.Errorf("%s must list at least one table", list.name)
+		}
+		for _, table := range list.tables {
+			if !policyTablePattern.MatchString(table) {
+				return fmt.Errorf("%s: invalid table name %q", list.name, table)
+			}
+			allowed[table] = true
+		}
+	}
+
+	tables := make([]string, 0, len(s.TableColumns))
+	for table := range s.TableColumns {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	for _, table := range tables {
+		if !allowed[table] {
+			return fmt.Errorf("table_columns: table %q is not whitelisted", table)
+		}
+		seen := make(map[string]bool)
+		for _, column := range s.TableColumns[table] {
+			if !policyColumnPattern.MatchString(column.Name) {
+				return fmt.Errorf("table_columns: %s: invalid column name %q", table, column.Name)
+			}
+			if seen[column.Name] {
+				return fmt.Errorf("table_columns: %s: duplicate column %q", table, column.Name)
+			}
+			seen[column.Name] = true
+			switch column.Type {
+			case ColumnString, ColumnNumber, ColumnBoolean, ColumnDate, ColumnRecord:
+			default:
+				return fmt.Errorf("table_columns: %s.%s: unknown type %q", table, column.Name, column.Type)
+			}
+		}
+	}
+	return nil
+}
+
+// Validate rejects TTLs that are not positive and a default above the maximum
+func (p CacheTTLPolicy) Validate() error {
+	if p.DefaultTTL <= 0 {
+		return fmt.Errorf("default_ttl must be positive")
+	}
+	if p.MaxTTL < 0 {
+		return fmt.Errorf("max_ttl must not be negative")
+	}
+	if p.MaxTTL > 0 && p.DefaultTTL > p.MaxTTL {
+		return fmt.Errorf("default_ttl %s exceeds max_ttl %s", p.DefaultTTL, p.MaxTTL)
+	}
+	for table, ttl := range p.Tables {
+		if !policyTablePattern.MatchString(table) {
+			return fmt.Errorf("tables: invalid table name %q", table)
+		}
+		if ttl <= 0 {
+			return fmt.Errorf("tables: %s: ttl must be positive", table)
+		}
+	}
+	return nil
+}
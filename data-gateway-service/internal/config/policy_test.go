@@ -0,0 +1,104 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPolicyFile = `
+security:
+  dremio_tables: [nessie_iceberg.tender_data, nessie_iceberg.tender_2026]
+  bigquery_tables: [gtp-data-prod.layer_isb.rup_kromaster]
+  table_columns:
+    nessie_iceberg.tender_2026:
+      - {name: tender_id, type: string}
+      - {name: nilai_pagu, type: number}
+cache:
+  default_ttl: 2m
+  max_ttl: 1h
+  tables:
+    nessie_iceberg.tender_2026: 30s
+flags:
+  routes:
+    /api/v1/rup: {message: RUP export is being rebuilt, retry_after: 10m}
+  sources:
+    BIGQUERY: {}
+`
+
+func TestParsePolicy(t *testing.T) {
+	p, err := ParsePolicy([]byte(testPolicyFile))
+	require.NoError(t, err)
+	assert.Len(t, p.Version, 12)
+	assert.True(t, p.Security.IsTableAllowed("nessie_iceberg.tender_2026", "dremio"))
+	assert.False(t, p.Security.IsTableAllowed("procurement.vendor_list", "dremio"))
+	column, ok := p.Security.Column("nessie_iceberg.tender_2026", "nilai_pagu")
+	assert.True(t, ok)
+	assert.Equal(t, ColumnNumber, column.Type)
+	assert.Equal(t, CacheTTLPolicy{
+		DefaultTTL: 2 * time.Minute,
+		MaxTTL:     time.Hour,
+		Tables:     map[string]time.Duration{"nessie_iceberg.tender_2026": 30 * time.Second},
+	}, p.Cache)
+	assert.Equal(t, FlagPolicy{
+		Routes:  map[string]DisabledFlag{"/api/v1/rup": {Message: "RUP export is being rebuilt", RetryAfter: 10 * time.Minute}},
+		Sources: map[string]DisabledFlag{"BIGQUERY": {}},
+	}, p.Flags)
+
+	// Omitted sections keep their defaults
+	p, err = ParsePolicy([]byte("cache:\n  max_ttl: 10m\n"))
+	require.NoError(t, err)
+	assert.Equal(t, GetDefaultSecurityConfig(), p.Security)
+	assert.Equal(t, 5*time.Minute, p.Cache.DefaultTTL)
+}
+
+func TestParsePolicy_Invalid(t *testing.T) {
+	for _, data := range []string{
+		"",
+		"security: [",
+		"securty:\n  dremio_tables: [a.b]\n",
+		"security:\n  dremio_tables: [a.b]\n",
+		"security:\n  dremio_tables: [\"a.b; DROP TABLE x\"]\n  bigquery_tables: [c.d]\n",
+		"security:\n  dremio_tables: [a.b]\n  bigquery_tables: [c.d]\n  table_columns:\n    e.f: [{name: x, type: string}]\n",
+		"security:\n  dremio_tables: [a.b]\n  bigquery_tables: [c.d]\n  table_columns:\n    a.b: [{name: \"x OR 1=1\", type: string}]\n",
+		"security:\n  dremio_tables: [a.b]\n  bigquery_tables: [c.d]\n  table_columns:\n    a.b: [{name: x, type: text}]\n",
+		"cache:\n  default_ttl: soon\n",
+		"cache:\n  default_ttl: -1m\n",
+		"cache:\n  default_ttl: 2h\n  max_ttl: 1h\n",
+		"cache:\n  tables:\n    a.b: 0s\n",
+		"flags:\n  routes:\n    /health: {}\n",
+		"flags:\n  routes:\n    /api/v1/tender/: {}\n",
+		"flags:\n  routes:\n    /api/v1/admin/flags: {}\n",
+		"flags:\n  sources:\n    \"BIGQUERY; x\": {}\n",
+		"flags:\n  sources:\n    BIGQUERY: {retry_after: -1m}\n",
+		"flags:\n  sources:\n    BIGQUERY: {reason: x}\n",
+	} {
+		_, err := ParsePolicy([]byte(data))
+		assert.Error(t, err, data)
+	}
+}
+
+func TestCacheTTLPolicy_TTL(t *testing.T) {
+	p := CacheTTLPolicy{
+		DefaultTTL: time.Minute,
+		MaxTTL:     time.Hour,
+		Tables:     map[string]time.Duration{"a.b": 10 * time.Second},
+	}
+	assert.Equal(t, time.Minute, p.TTL("", 0))
+	assert.Equal(t, 5*time.Minute, p.TTL("", 5*time.Minute))
+	assert.Equal(t, time.Hour, p.TTL("", 24*time.Hour))
+	assert.Equal(t, 10*time.Second, p.TTL("a.b", 5*time.Minute))
+	assert.Equal(t, 5*time.Minute, p.TTL("c.d", 5*time.Minute))
+}
+
+func TestActivePolicy(t *testing.T) {
+	assert.Equal(t, DefaultPolicyVersion, ActivePolicy().Version)
+
+	p, err := ParsePolicy([]byte(testPolicyFile))
+	require.NoError(t, err)
+	SetPolicy(p)
+	defer SetPolicy(nil)
+	assert.Same(t, p.Security, ActiveSecurityConfig())
+}
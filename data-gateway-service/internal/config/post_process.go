@@ -0,0 +1,22 @@
+package config
+
+// PostProcessConfig bounds the results the gateway orders, deduplicates and
+// pages itself because their source could not
+type PostProcessConfig struct {
+	MaxRows  int   // Rows a processed result may have
+	MaxBytes int64 // Estimated in-memory size a processed result may have
+}
+
+// DefaultPostProcess processes results of up to 100000 rows and 64 MB
+func DefaultPostProcess() PostProcessConfig {
+	return PostProcessConfig{MaxRows: 100000, MaxBytes: 64 << 20}
+}
+
+// loadPostProcess reads the POST_PROCESS_* variables
+func loadPostProcess() PostProcessConfig {
+	defaults := DefaultPostProcess()
+	return PostProcessConfig{
+		MaxRows:  getEnvAsInt("POST_PROCESS_MAX_ROWS", defaults.MaxRows),
+		MaxBytes: int64(getEnvAsInt("POST_PROCESS_MAX_MB", int(defaults.MaxBytes>>20))) << 20,
+	}
+}
@@ -0,0 +1,27 @@
+package config
+
+import "time"
+
+// ProfileConfig bounds the column profiles of
+// GET /api/v1/sources/{source}/tables/{table}/profile
+type ProfileConfig struct {
+	MaxColumns int           // Columns one profile may cover
+	CacheTTL   time.Duration // Lifetime of cached profiles
+	MaxBytes   int64         // Bytes a BigQuery profile may scan; 0 lifts the budget
+}
+
+// DefaultProfile profiles up to 20 columns, cached for a day, scanning at
+// most 100 GB on BigQuery
+func DefaultProfile() ProfileConfig {
+	return ProfileConfig{MaxColumns: 20, CacheTTL: 24 * time.Hour, MaxBytes: 100 << 30}
+}
+
+// loadProfile reads the PROFILE_* variables
+func loadProfile() ProfileConfig {
+	defaults := DefaultProfile()
+	return ProfileConfig{
+		MaxColumns: getEnvAsInt("PROFILE_MAX_COLUMNS", defaults.MaxColumns),
+		CacheTTL:   getEnvAsDuration("PROFILE_CACHE_TTL", defaults.CacheTTL),
+		MaxBytes:   int64(getEnvAsInt("PROFILE_MAX_GB", int(defaults.MaxBytes>>30))) << 30,
+	}
+}
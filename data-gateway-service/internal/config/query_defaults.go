@@ -0,0 +1,96 @@
+package config
+
+import "time"
+
+// QueryDefaults are the options the queries on a data source start from. A
+// request may ask for another cache TTL or timeout within the QueryCeilings.
+type QueryDefaults struct {
+	CacheTTL   time.Duration // How long results are cached
+	Timeout    time.Duration // How long a query may run
+	MaxRows    int           // Rows a request may ask for; 0 leaves them to the endpoint's page limits
+	Tiebreaker string        // Column pages are ordered by last when their table has no tiebreaker
+}
+
+// Or returns d with its unset fields taken from fallback
+func (d QueryDefaults) Or(fallback QueryDefaults) QueryDefaults {
+	if d.CacheTTL <= 0 {
+		d.CacheTTL = fallback.CacheTTL
+	}
+	if d.Timeout <= 0 {
+		d.Timeout = fallback.Timeout
+	}
+	if d.MaxRows <= 0 {
+		d.MaxRows = fallback.MaxRows
+	}
+	if d.Tiebreaker == "" {
+		d.Tiebreaker = fallback.Tiebreaker
+	}
+	return d
+}
+
+// DefaultCountCacheTTL is how long count_only results are cached when
+// QUERY_COUNT_CACHE_TTL is not set
+const DefaultCountCacheTTL = 30 * time.Minute
+
+// DefaultNotFoundCacheTTL is how long a lookup by id that found nothing is
+// remembered when NOT_FOUND_CACHE_TTL is not set: long enough to absorb
+// probes of sequential ids, short enough for new rows to show up soon
+const DefaultNotFoundCacheTTL = 30 * time.Second
+
+// QueryCeilings bound the cache TTL and timeout a request may ask for
+type QueryCeilings struct {
+	MaxCacheTTL time.Duration
+	MaxTimeout  time.Duration
+}
+
+// DefaultQueryDefaults returns the built-in defaults of sources that set
+// none: results cached for 5 minutes, queries cut off after 30 seconds
+func DefaultQueryDefaults() QueryDefaults {
+	return QueryDefaults{CacheTTL: 5 * time.Minute, Timeout: 30 * time.Second}
+}
+
+// DefaultQueryCeilings returns the built-in ceilings of request overrides
+func DefaultQueryCeilings() QueryCeilings {
+	return QueryCeilings{MaxCacheTTL: time.Hour, MaxTimeout: 5 * time.Minute}
+}
+
+// sourceTypeDefaults are the built-in defaults of the sources of a type, over
+// the global ones. BigQuery jobs take longer to start and bill per byte
+// scanned, so they run longer and their results are kept longer.
+var sourceTypeDefaults = map[string]QueryDefaults{
+	SourceTypeBigQuery: {CacheTTL: 15 * time.Minute, Timeout: 60 * time.Second},
+}
+
+// loadQueryDefaults reads QUERY_DEFAULT_CACHE_TTL, QUERY_DEFAULT_TIMEOUT and
+// QUERY_DEFAULT_MAX_ROWS over the built-in defaults
+func loadQueryDefaults() QueryDefaults {
+	d := DefaultQueryDefaults()
+	d.CacheTTL = getEnvAsDuration("QUERY_DEFAULT_CACHE_TTL", d.CacheTTL)
+	d.Timeout = getEnvAsDuration("QUERY_DEFAULT_TIMEOUT", d.Timeout)
+	d.MaxRows = getEnvAsInt("QUERY_DEFAULT_MAX_ROWS", d.MaxRows)
+	return d
+}
+
+// loadQueryCeilings reads QUERY_MAX_CACHE_TTL and QUERY_MAX_TIMEOUT over the
+// built-in ceilings
+func loadQueryCeilings() QueryCeilings {
+	c := DefaultQueryCeilings()
+	c.MaxCacheTTL = getEnvAsDuration("QUERY_MAX_CACHE_TTL", c.MaxCacheTTL)
+	c.MaxTimeout = getEnvAsDuration("QUERY_MAX_TIMEOUT", c.MaxTimeout)
+	return c
+}
+
+// loadSourceDefaults reads <prefix>CACHE_TTL, TIMEOUT, MAX_ROWS and
+// TIEBREAKER over the built-in defaults of sourceType, and fills what is
+// still unset from global. A tiebreaker that is not a plain column name is
+// ignored.
+func loadSourceDefaults(prefix, sourceType string, global QueryDefaults) QueryDefaults {
+	d := sourceTypeDefaults[sourceType]
+	d.CacheTTL = getEnvAsDuration(prefix+"CACHE_TTL", d.CacheTTL)
+	d.Timeout = getEnvAsDuration(prefix+"TIMEOUT", d.Timeout)
+	d.MaxRows = getEnvAsInt(prefix+"MAX_ROWS", d.MaxRows)
+	if column := getEnv(prefix+"TIEBREAKER", ""); tiebreakerColumn.MatchString(column) {
+		d.Tiebreaker = column
+	}
+	return d.Or(global)
+}
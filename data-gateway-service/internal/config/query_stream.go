@@ -0,0 +1,16 @@
+package config
+
+// QueryStreamConfig decides when /api/v1/query writes its response while
+// encoding it instead of buffering the whole body first
+type QueryStreamConfig struct {
+	RowThreshold  int // Results of at least this many rows are streamed; 0 disables
+	ByteThreshold int // Responses larger than this many bytes are streamed; 0 disables
+}
+
+// loadQueryStream reads QUERY_STREAM_ROW_THRESHOLD and QUERY_STREAM_THRESHOLD_KB
+func loadQueryStream() QueryStreamConfig {
+	return QueryStreamConfig{
+		RowThreshold:  getEnvAsInt("QUERY_STREAM_ROW_THRESHOLD", 5000),
+		ByteThreshold: getEnvAsInt("QUERY_STREAM_THRESHOLD_KB", 1024) * 1024,
+	}
+}
@@ -0,0 +1,24 @@
+package config
+
+import "time"
+
+// RateLimitingConfig bounds the per-client state of the rate limiter
+type RateLimitingConfig struct {
+	MaxKeys int           // Clients tracked at once; the least recently seen is dropped beyond it, 0 for no bound
+	IdleTTL time.Duration // Clients idle this long are forgotten
+}
+
+// DefaultRateLimiting tracks up to 100000 clients, each for three minutes
+// after its last request
+func DefaultRateLimiting() RateLimitingConfig {
+	return RateLimitingConfig{MaxKeys: 100000, IdleTTL: 3 * time.Minute}
+}
+
+// loadRateLimiting reads RATE_LIMIT_MAX_KEYS and RATE_LIMIT_IDLE_TTL
+func loadRateLimiting() RateLimitingConfig {
+	defaults := DefaultRateLimiting()
+	return RateLimitingConfig{
+		MaxKeys: getEnvAsInt("RATE_LIMIT_MAX_KEYS", defaults.MaxKeys),
+		IdleTTL: getEnvAsDuration("RATE_LIMIT_IDLE_TTL", defaults.IdleTTL),
+	}
+}
@@ -0,0 +1,52 @@
+package config
+
+import "strings"
+
+// Relation is a child collection of a record: the rows of Table whose Key
+// column holds the record's id
+type Relation struct {
+	Name    string   // Value of include and field of the nested array, e.g. peserta
+	Table   string   // Child table; must be allowed by the security config
+	Key     string   // Column of Table holding the parent's id, e.g. tender_id
+	Columns []string // Columns of each child; empty selects all
+	Limit   int      // Most children returned
+}
+
+// RelationsConfig holds the child collections GET /api/v1/tender/{id} can
+// include
+type RelationsConfig struct {
+	Tender []Relation
+
+	// Strict fails a request when one of its child queries fails; otherwise
+	// that collection is empty and meta.warnings names it
+	Strict bool
+}
+
+// defaultRelationLimit bounds a child collection when no limit is set
+const defaultRelationLimit = 500
+
+// loadRelations reads TENDER_RELATIONS=peserta,dokumen with
+// TENDER_RELATION_<NAME>_TABLE (default nessie_iceberg.tender_<name>), _KEY
+// (tender_id), _COLUMNS (all) and _LIMIT (500), and INCLUDE_STRICT
+func loadRelations() RelationsConfig {
+	var relations []Relation
+	for _, name := range getEnvAsSlice("TENDER_RELATIONS", "peserta,dokumen") {
+		name = strings.ToLower(name)
+		prefix := "TENDER_RELATION_" + strings.ToUpper(name) + "_"
+		relation := Relation{
+			Name:    name,
+			Table:   getEnv(prefix+"TABLE", "nessie_iceberg.tender_"+name),
+			Key:     getEnv(prefix+"KEY", "tender_id"),
+			Columns: getEnvAsSlice(prefix+"COLUMNS", ""),
+			Limit:   getEnvAsInt(prefix+"LIMIT", defaultRelationLimit),
+		}
+		if relation.Limit <= 0 {
+			relation.Limit = defaultRelationLimit
+		}
+		relations = append(relations, relation)
+	}
+	return RelationsConfig{
+		Tender: relations,
+		Strict: getEnvAsBool("INCLUDE_STRICT", false),
+	}
+}
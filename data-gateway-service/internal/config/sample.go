@@ -0,0 +1,27 @@
+package config
+
+import "time"
+
+// SampleConfig bounds the samples of
+// GET /api/v1/sources/{source}/tables/{table}/sample
+type SampleConfig struct {
+	DefaultRows int           // Rows of a sample without rows=
+	MaxRows     int           // Rows one sample may return
+	CacheTTL    time.Duration // Lifetime of cached samples
+}
+
+// DefaultSample returns 100 rows by default and at most 1000, cached for
+// six hours
+func DefaultSample() SampleConfig {
+	return SampleConfig{DefaultRows: 100, MaxRows: 1000, CacheTTL: 6 * time.Hour}
+}
+
+// loadSample reads the SAMPLE_* variables
+func loadSample() SampleConfig {
+	defaults := DefaultSample()
+	return SampleConfig{
+		DefaultRows: getEnvAsInt("SAMPLE_DEFAULT_ROWS", defaults.DefaultRows),
+		MaxRows:     getEnvAsInt("SAMPLE_MAX_ROWS", defaults.MaxRows),
+		CacheTTL:    getEnvAsDuration("SAMPLE_CACHE_TTL", defaults.CacheTTL),
+	}
+}
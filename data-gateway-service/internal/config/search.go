@@ -0,0 +1,51 @@
+package config
+
+// KeywordSearch is the keyword matching of a search endpoint
+type KeywordSearch struct {
+	Columns   []string // Columns a keyword is matched against, case-insensitively
+	MaxLength int      // Longest keyword, in characters
+	MaxTerms  int      // Most keywords in one search
+}
+
+// SearchConfig holds the keyword search of each searchable table
+type SearchConfig struct {
+	Tender KeywordSearch // tender_data
+	RUP    KeywordSearch // rup_kromaster
+}
+
+// DefaultSearch returns the built-in keyword search
+func DefaultSearch() SearchConfig {
+	return SearchConfig{
+		Tender: KeywordSearch{Columns: []string{"nama_paket"}, MaxLength: 100, MaxTerms: 5},
+		RUP:    KeywordSearch{Columns: []string{"nama_kro", "nama_klpd"}, MaxLength: 100, MaxTerms: 5},
+	}
+}
+
+// loadSearch reads SEARCH_<TABLE>_KEYWORD_COLUMNS, SEARCH_KEYWORD_MAX_LENGTH
+// and SEARCH_KEYWORD_MAX_TERMS over the built-in search. The limits keep a
+// search from scanning the table once per term and column for long lists of
+// long keywords.
+func loadSearch() SearchConfig {
+	s := DefaultSearch()
+	s.Tender = loadKeywordSearch("TENDER", s.Tender)
+	s.RUP = loadKeywordSearch("RUP", s.RUP)
+	return s
+}
+
+func loadKeywordSearch(table string, defaults KeywordSearch) KeywordSearch {
+	search := KeywordSearch{
+		Columns:   getEnvAsSlice("SEARCH_"+table+"_KEYWORD_COLUMNS", ""),
+		MaxLength: getEnvAsInt("SEARCH_KEYWORD_MAX_LENGTH", defaults.MaxLength),
+		MaxTerms:  getEnvAsInt("SEARCH_KEYWORD_MAX_TERMS", defaults.MaxTerms),
+	}
+	if len(search.Columns) == 0 {
+		search.Columns = defaults.Columns
+	}
+	if search.MaxLength <= 0 {
+		search.MaxLength = defaults.MaxLength
+	}
+	if search.MaxTerms <= 0 {
+		search.MaxTerms = defaults.MaxTerms
+	}
+	return search
+}
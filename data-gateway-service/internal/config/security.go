@@ -6,6 +6,26 @@ type SecurityConfig struct {
 	AllowedDremioTables []string
 	// AllowedBigQueryTables - whitelist of tables that can be queried from BigQuery
 	AllowedBigQueryTables []string
+	// TableColumns - columns that can be filtered and sorted on, per table
+	TableColumns map[string][]ColumnSpec
+}
+
+// Column types used to convert filter values
+const (
+	ColumnString  = "string"
+	ColumnNumber  = "number"
+	ColumnBoolean = "boolean"
+	ColumnDate    = "date"
+
+	// ColumnRecord is a column with nested fields, such as a BigQuery STRUCT;
+	// it is described but cannot be filtered on
+	ColumnRecord = "record"
+)
+
+// ColumnSpec describes a column that can be filtered and sorted on
+type ColumnSpec struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
 }
 
 // GetDefaultSecurityConfig returns default security configuration
@@ -14,6 +34,8 @@ func GetDefaultSecurityConfig() *SecurityConfig {
 		// Only allow specific tables to be queried
 		AllowedDremioTables: []string{
 			"nessie_iceberg.tender_data",
+			"nessie_iceberg.tender_peserta",
+			"nessie_iceberg.tender_dokumen",
 			"nessie_iceberg.tender_2024",
 			"nessie_iceberg.tender_2025",
 			"procurement.tender_master",
@@ -25,6 +47,37 @@ func GetDefaultSecurityConfig() *SecurityConfig {
 			"spse-prod-sa.public.tender_data",
 			"spse-prod-sa.public.rup_data",
 		},
+		TableColumns: map[string][]ColumnSpec{
+			"nessie_iceberg.tender_data": {
+				{Name: "tender_id", Type: ColumnString},
+				{Name: "nama_paket", Type: ColumnString},
+				{Name: "nilai_pagu", Type: ColumnNumber},
+				{Name: "nilai_kontrak", Type: ColumnNumber},
+				{Name: "metode_pengadaan", Type: ColumnString},
+				{Name: "jenis_pengadaan", Type: ColumnString},
+				{Name: "tahun_anggaran", Type: ColumnNumber},
+				{Name: "status_tender", Type: ColumnString},
+				{Name: "tanggal_buat_paket", Type: ColumnDate},
+				{Name: "tanggal_pengumuman", Type: ColumnDate},
+				{Name: "provinsi", Type: ColumnString},
+				{Name: "nama_kl", Type: ColumnString},
+			},
+			"gtp-data-prod.layer_isb.rup_kromaster": {
+				{Name: "kd_kro", Type: ColumnString},
+				{Name: "kd_kro_str", Type: ColumnString},
+				{Name: "nama_kro", Type: ColumnString},
+				{Name: "pagu_kro", Type: ColumnNumber},
+				{Name: "tahun_anggaran", Type: ColumnNumber},
+				{Name: "kd_satker", Type: ColumnNumber},
+				{Name: "kd_klpd", Type: ColumnString},
+				{Name: "nama_klpd", Type: ColumnString},
+				{Name: "jenis_klpd", Type: ColumnString},
+				{Name: "kd_program", Type: ColumnString},
+				{Name: "kd_kegiatan", Type: ColumnString},
+				{Name: "_event_date", Type: ColumnDate},
+				{Name: "is_deleted", Type: ColumnBoolean},
+			},
+		},
 	}
 }
 
@@ -47,4 +100,25 @@ func (s *SecurityConfig) IsTableAllowed(table string, source string) bool {
 		}
 	}
 	return false
-}
\ No newline at end of file
+}
+
+// Column returns the filterable column of a table, if declared
+func (s *SecurityConfig) Column(table, column string) (ColumnSpec, bool) {
+	for _, c := range s.TableColumns[table] {
+		if c.Name == column {
+			return c, true
+		}
+	}
+	return ColumnSpec{}, false
+}
+
+// AllowedColumns returns the declared column names of each table
+func (s *SecurityConfig) AllowedColumns() map[string][]string {
+	columns := make(map[string][]string, len(s.TableColumns))
+	for table, specs := range s.TableColumns {
+		for _, c := range specs {
+			columns[table] = append(columns[table], c.Name)
+		}
+	}
+	return columns
+}
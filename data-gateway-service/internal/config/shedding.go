@@ -0,0 +1,23 @@
+package config
+
+import "time"
+
+// SheddingConfig controls adaptive load shedding of /api/v1 requests
+type SheddingConfig struct {
+	Enabled     bool          // When false shedding starts switched off; admins may still force it on
+	MaxInFlight int           // Batch/stream is shed at 75% of this, raw queries at 100%
+	LatencyP95  time.Duration // Recent p95 of an endpoint group above this triggers shedding
+	Window      time.Duration // Latency samples older than this are ignored
+	RetryAfter  time.Duration // Sent as Retry-After with 503 responses
+}
+
+// loadShedding reads the LOAD_SHEDDING_* variables
+func loadShedding() SheddingConfig {
+	return SheddingConfig{
+		Enabled:     getEnvAsBool("LOAD_SHEDDING_ENABLED", true),
+		MaxInFlight: getEnvAsInt("LOAD_SHEDDING_MAX_IN_FLIGHT", 200),
+		LatencyP95:  getEnvAsDuration("LOAD_SHEDDING_LATENCY_P95", 5*time.Second),
+		Window:      getEnvAsDuration("LOAD_SHEDDING_WINDOW", 30*time.Second),
+		RetryAfter:  getEnvAsDuration("LOAD_SHEDDING_RETRY_AFTER", 10*time.Second),
+	}
+}
@@ -0,0 +1,18 @@
+package config
+
+// SheetsConfig controls POST /api/v1/export/sheets. The Sheets client uses
+// GOOGLE_APPLICATION_CREDENTIALS like BigQuery.
+type SheetsConfig struct {
+	Enabled   bool
+	MaxRows   int // Rows a written result may have; capped by the Sheets cell limit
+	BatchRows int // Rows per Sheets API write request
+}
+
+// loadSheets reads the SHEETS_* variables
+func loadSheets() SheetsConfig {
+	return SheetsConfig{
+		Enabled:   getEnvAsBool("SHEETS_EXPORT_ENABLED", false),
+		MaxRows:   getEnvAsInt("SHEETS_MAX_ROWS", 100000),
+		BatchRows: getEnvAsInt("SHEETS_BATCH_ROWS", 5000),
+	}
+}
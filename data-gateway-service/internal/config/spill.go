@@ -0,0 +1,26 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SpillConfig controls streams spilled to disk: the result is written to a
+// file as fast as the source produces it, then served from there
+type SpillConfig struct {
+	Enabled   bool
+	Dir       string        // Directory of spilled results; emptied at startup
+	MaxBytes  int64         // Size a spilled result may reach
+	Retention time.Duration // How long a spilled result can be downloaded
+}
+
+// loadSpill reads the SPILL_* variables
+func loadSpill() SpillConfig {
+	return SpillConfig{
+		Enabled:   getEnvAsBool("SPILL_ENABLED", false),
+		Dir:       getEnv("SPILL_DIR", filepath.Join(os.TempDir(), "gateway-spill")),
+		MaxBytes:  int64(getEnvAsInt("SPILL_MAX_MB", 10240)) << 20,
+		Retention: getEnvAsDuration("SPILL_RETENTION", time.Hour),
+	}
+}
@@ -0,0 +1,19 @@
+package config
+
+import "time"
+
+// StreamQuotaConfig controls the concurrent /stream, /stream/sse and
+// /batch/stream connections allowed per API key. Streams are counted across
+// replicas in Redis when it is configured.
+type StreamQuotaConfig struct {
+	MaxPerKey int           // Concurrent streams per API key; 0 disables the limit
+	TTL       time.Duration // How long a stream of a crashed replica keeps counting
+}
+
+// loadStreamQuota reads the STREAM_* quota variables
+func loadStreamQuota() StreamQuotaConfig {
+	return StreamQuotaConfig{
+		MaxPerKey: getEnvAsInt("STREAM_MAX_PER_KEY", 10),
+		TTL:       getEnvAsDuration("STREAM_QUOTA_TTL", time.Minute),
+	}
+}
@@ -0,0 +1,39 @@
+package config
+
+import "strings"
+
+// TenantConfig describes an agency hosted on the gateway with its own
+// upstream projects and cache namespace
+type TenantConfig struct {
+	ID               string
+	DremioProject    string // Dremio space used for unqualified table names
+	BigQueryProject  string
+	BigQueryDataset  string
+	BigQueryLocation string // Region of BigQueryDataset when it differs from BIGQUERY_LOCATION
+	CacheNamespace   string
+	APIKeys          []string // Bootstrap keys bound to this tenant
+}
+
+// loadTenants reads TENANTS=a,b and the per-tenant TENANT_<ID>_* variables.
+// Unset per-tenant values fall back to the global configuration.
+func loadTenants(cfg *Config) []TenantConfig {
+	var tenants []TenantConfig
+	for _, id := range getEnvAsSlice("TENANTS", "") {
+		prefix := "TENANT_" + tenantEnvName(id) + "_"
+		tenants = append(tenants, TenantConfig{
+			ID:               id,
+			DremioProject:    getEnv(prefix+"DREMIO_PROJECT", "nessie_iceberg"),
+			BigQueryProject:  getEnv(prefix+"BIGQUERY_PROJECT_ID", cfg.BigQuery.ProjectID),
+			BigQueryDataset:  getEnv(prefix+"BIGQUERY_DATASET_ID", cfg.BigQuery.DatasetID),
+			BigQueryLocation: getEnv(prefix+"BIGQUERY_LOCATION", cfg.BigQuery.Location),
+			CacheNamespace:   getEnv(prefix+"CACHE_NAMESPACE", id),
+			APIKeys:          getEnvAsSlice(prefix+"API_KEYS", ""),
+		})
+	}
+	return tenants
+}
+
+// tenantEnvName converts a tenant ID to its environment variable form
+func tenantEnvName(id string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(id))
+}
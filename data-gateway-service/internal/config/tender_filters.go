@@ -0,0 +1,52 @@
+package config
+
+import "strings"
+
+// TenderFiltersConfig describes the values the tender filters accept
+type TenderFiltersConfig struct {
+	// Statuses are the known values of status_tender, which the status of
+	// the tender list is validated against and GET /api/v1/tender/statuses
+	// counts
+	Statuses []string
+
+	// StrictStatuses rejects a status of the tender list that is not one of
+	// Statuses, unless the request sets strict=false. It is set when
+	// TENDER_STATUSES lists the statuses: the built-in ones are the usual
+	// values, and others are passed on unless the request sets strict=true.
+	StrictStatuses bool
+
+	// MultiValueColumns are the string columns whose eq and neq search
+	// clauses also take a list or a comma-separated string of values
+	MultiValueColumns []string
+}
+
+// DefaultTenderFilters returns the built-in tender statuses and multi-value
+// columns; jenis_pengadaan is the category of a tender
+func DefaultTenderFilters() TenderFiltersConfig {
+	return TenderFiltersConfig{
+		Statuses:          []string{"Aktif", "Selesai", "Batal"},
+		MultiValueColumns: []string{"provinsi", "jenis_pengadaan"},
+	}
+}
+
+// Status returns the known status equal to value ignoring case, as it is
+// stored
+func (c TenderFiltersConfig) Status(value string) (string, bool) {
+	for _, status := range c.Statuses {
+		if strings.EqualFold(status, value) {
+			return status, true
+		}
+	}
+	return "", false
+}
+
+// loadTenderFilters reads TENDER_STATUSES and TENDER_MULTI_VALUE_COLUMNS,
+// comma-separated, over the built-in values
+func loadTenderFilters() TenderFiltersConfig {
+	defaults := DefaultTenderFilters()
+	return TenderFiltersConfig{
+		Statuses:          getEnvAsSlice("TENDER_STATUSES", strings.Join(defaults.Statuses, ",")),
+		StrictStatuses:    getEnv("TENDER_STATUSES", "") != "",
+		MultiValueColumns: getEnvAsSlice("TENDER_MULTI_VALUE_COLUMNS", strings.Join(defaults.MultiValueColumns, ",")),
+	}
+}
@@ -0,0 +1,22 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadTenderFilters(t *testing.T) {
+	filters := loadTenderFilters()
+	assert.Equal(t, []string{"Aktif", "Selesai", "Batal"}, filters.Statuses)
+	assert.False(t, filters.StrictStatuses, "the built-in statuses are not enforced")
+
+	t.Setenv("TENDER_STATUSES", "Aktif, Dibatalkan")
+	filters = loadTenderFilters()
+	assert.Equal(t, []string{"Aktif", "Dibatalkan"}, filters.Statuses)
+	assert.True(t, filters.StrictStatuses)
+
+	status, ok := filters.Status("dibatalkan")
+	assert.True(t, ok)
+	assert.Equal(t, "Dibatalkan", status)
+}
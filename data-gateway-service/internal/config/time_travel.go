@@ -0,0 +1,36 @@
+package config
+
+import (
+	"strings"
+	"time"
+)
+
+// TimeTravelConfig controls the as_of parameter, which reads the Dremio
+// Iceberg tables as they were at a past time
+type TimeTravelConfig struct {
+	Retention time.Duration // How far back as_of may go; snapshots older are expired
+	Tables    []string      // Tables raw SQL may read with as_of
+
+	// WithBranch allows as_of on a branch other than the default, for
+	// Dremio versions that accept AT BRANCH ... AS OF
+	WithBranch bool
+}
+
+// DefaultTimeTravel keeps a month of snapshots and lets raw SQL read the
+// tender table as of a time
+func DefaultTimeTravel() TimeTravelConfig {
+	return TimeTravelConfig{
+		Retention: 30 * 24 * time.Hour,
+		Tables:    []string{"nessie_iceberg.tender_data"},
+	}
+}
+
+// loadTimeTravel reads the DREMIO_AS_OF_* variables
+func loadTimeTravel() TimeTravelConfig {
+	defaults := DefaultTimeTravel()
+	return TimeTravelConfig{
+		Retention:  getEnvAsDuration("DREMIO_AS_OF_RETENTION", defaults.Retention),
+		Tables:     getEnvAsSlice("DREMIO_AS_OF_TABLES", strings.Join(defaults.Tables, ",")),
+		WithBranch: getEnvAsBool("DREMIO_AS_OF_WITH_BRANCH", defaults.WithBranch),
+	}
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// TLSConfig returns the TLS settings of Redis connections, nil when TLS is
+// not enabled. Without a CA file the system roots verify the server.
+func (c RedisConfig) TLSConfig() (*tls.Config, error) {
+	if !c.TLS {
+		if c.CAFile != "" || c.ServerName != "" {
+			return nil, fmt.Errorf("REDIS_CA_FILE and REDIS_TLS_SERVER_NAME need REDIS_TLS=true")
+		}
+		return nil, nil
+	}
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12, ServerName: c.ServerName}
+	if c.CAFile != "" {
+		roots, err := loadCertPool(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("Redis CA file: %w", err)
+		}
+		cfg.RootCAs = roots
+	}
+	return cfg, nil
+}
+
+// RootCAs returns the roots BigQuery connections are verified with: the
+// system roots and the CA of CAFile, or nil to use the SDK's own
+func (c BigQueryConfig) RootCAs() (*x509.CertPool, error) {
+	if c.CAFile == "" {
+		return nil, nil
+	}
+	roots, err := loadCertPool(c.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("BigQuery CA file: %w", err)
+	}
+	return roots, nil
+}
+
+// CheckCertificates reads the CA files of Redis and BigQuery, so a missing
+// or invalid one stops startup instead of failing the first connection
+func (c *Config) CheckCertificates() error {
+	if _, err := c.Redis.TLSConfig(); err != nil {
+		return err
+	}
+	_, err := c.BigQuery.RootCAs()
+	return err
+}
+
+// loadCertPool returns the system roots with the PEM certificates of the
+// file at path added. The system roots are kept, as the same connections
+// also reach public endpoints such as Google's token server.
+func loadCertPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	roots, err := x509.SystemCertPool()
+	if err != nil || roots == nil {
+		roots = x509.NewCertPool()
+	}
+	if !roots.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("%s has no PEM certificates", path)
+	}
+	return roots, nil
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"encoding/pem"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCAFile writes a PEM certificate to a file and returns its path
+func writeCAFile(t *testing.T) string {
+	server := httptest.NewTLSServer(nil)
+	defer server.Close()
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0o600))
+	return path
+}
+
+func TestLoad_TLSVariables(t *testing.T) {
+	caFile := writeCAFile(t)
+	t.Setenv("REDIS_TLS", "true")
+	t.Setenv("REDIS_CA_FILE", caFile)
+	t.Setenv("REDIS_TLS_SERVER_NAME", "redis.internal")
+	t.Setenv("BIGQUERY_PROJECT_ID", "lkpp")
+	t.Setenv("BIGQUERY_ENDPOINT", "https://bigquery-psc.p.googleapis.com/bigquery/v2/")
+	t.Setenv("BIGQUERY_CA_FILE", caFile)
+	t.Setenv("DATA_SOURCES", "")
+
+	cfg := Load()
+	assert.True(t, cfg.Redis.TLS)
+	assert.Equal(t, caFile, cfg.Redis.CAFile)
+	assert.Equal(t, "redis.internal", cfg.Redis.ServerName)
+	assert.Equal(t, "https://bigquery-psc.p.googleapis.com/bigquery/v2/", cfg.BigQuery.Endpoint)
+	assert.Equal(t, caFile, cfg.BigQuery.CAFile)
+	require.NoError(t, cfg.CheckCertificates())
+
+	// The legacy BIGQUERY source carries them as settings
+	var bigquery DataSourceConfig
+	for _, source := range cfg.DataSources {
+		if source.Name == "BIGQUERY" {
+			bigquery = source
+		}
+	}
+	assert.Equal(t, cfg.BigQuery.Endpoint, bigquery.Setting("endpoint", ""))
+	assert.Equal(t, caFile, bigquery.Setting("ca_file", ""))
+
+	tlsConfig, err := cfg.Redis.TLSConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "redis.internal", tlsConfig.ServerName)
+	assert.NotNil(t, tlsConfig.RootCAs)
+}
+
+func TestCheckCertificates(t *testing.T) {
+	notPEM := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(notPEM, []byte("not a certificate"), 0o600))
+	missing := filepath.Join(t.TempDir(), "missing.pem")
+
+	tests := []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{"nothing set", Config{}, ""},
+		{"redis TLS with system roots", Config{Redis: RedisConfig{TLS: true}}, ""},
+		{"redis CA without TLS", Config{Redis: RedisConfig{CAFile: writeCAFile(t)}}, "need REDIS_TLS=true"},
+		{"redis CA missing", Config{Redis: RedisConfig{TLS: true, CAFile: missing}}, "Redis CA file: open " + missing},
+		{"redis CA not PEM", Config{Redis: RedisConfig{TLS: true, CAFile: notPEM}}, notPEM + " has no PEM certificates"},
+		{"bigquery CA missing", Config{BigQuery: BigQueryConfig{CAFile: missing}}, "BigQuery CA file: open " + missing},
+		{"bigquery CA not PEM", Config{BigQuery: BigQueryConfig{CAFile: notPEM}}, "BigQuery CA file: " + notPEM},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.CheckCertificates()
+			if tt.want == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+}
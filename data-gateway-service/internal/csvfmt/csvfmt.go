@@ -0,0 +1,285 @@
+// Package csvfmt renders query values as text for spreadsheets opened in a
+// given locale. Numbers use the locale's decimal separator and dates its date
+// pattern, so that Excel imports them as numbers and dates rather than text.
+// It only applies to CSV; JSON output stays machine-formatted.
+package csvfmt
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/numeric"
+)
+
+// Supported locales
+const (
+	LocaleEnUS = "en-US"
+	LocaleIdID = "id-ID"
+)
+
+// BOM is the UTF-8 byte order mark Excel uses to detect the encoding
+const BOM = "\xEF\xBB\xBF"
+
+// Options selects how values are formatted. Zero Options leaves output as
+// it was before formatting options existed.
+type Options struct {
+	Locale           string `json:"locale,omitempty"`            // en-US (default) or id-ID
+	DecimalSeparator string `json:"decimal_separator,omitempty"` // "." or ","; overrides the locale
+	DateFormat       string `json:"date_format,omitempty"`       // e.g. dd/MM/yyyy; overrides the locale
+	BOM              bool   `json:"bom,omitempty"`               // Start the file with a UTF-8 BOM
+
+	// Flatten writes the fields of record columns as parent.field columns
+	// instead of one JSON-encoded cell; see FlattenRow
+	Flatten bool `json:"flatten,omitempty"`
+}
+
+// IsZero reports whether no option is set
+func (o Options) IsZero() bool {
+	return o == Options{}
+}
+
+// locales holds the decimal separator and date pattern of each locale
+var locales = map[string]struct {
+	decimal string
+	date    string
+}{
+	strings.ToLower(LocaleEnUS): {".", "yyyy-MM-dd"},
+	strings.ToLower(LocaleIdID): {",", "dd/MM/yyyy"},
+}
+
+// Formatter formats the values of a table's columns
+type Formatter struct {
+	decimal   byte
+	delimiter rune
+	date      string // Go layouts
+	timestamp string
+	bom       bool
+	flatten   bool
+	types     map[string]string // Column name to config.Column* type
+}
+
+// New validates opts and creates a formatter for columns, whose declared
+// types take precedence over the Go type of each value
+func New(opts Options, columns []config.ColumnSpec) (*Formatter, error) {
+	name := opts.Locale
+	if name == "" {
+		name = LocaleEnUS
+	}
+	locale, ok := locales[strings.ToLower(strings.ReplaceAll(name, "_", "-"))]
+	if !ok {
+		return nil, fmt.Errorf("unsupported locale %q, use %s or %s", opts.Locale, LocaleEnUS, LocaleIdID)
+	}
+
+	decimal := locale.decimal
+	if opts.DecimalSeparator != "" {
+		decimal = opts.DecimalSeparator
+	}
+	if decimal != "." && decimal != "," {
+		return nil, fmt.Errorf("decimal_separator must be \".\" or \",\"")
+	}
+
+	pattern := locale.date
+	if opts.DateFormat != "" {
+		pattern = opts.DateFormat
+	}
+	date, err := layout(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &Formatter{
+		decimal:   decimal[0],
+		delimiter: ',',
+		date:      date,
+		timestamp: date + " 15:04:05",
+		bom:       opts.BOM,
+		flatten:   opts.Flatten,
+		types:     make(map[string]string, len(columns)),
+	}
+	// A comma decimal separator needs another field delimiter, as in Excel's
+	// list separator for such locales
+	if f.decimal == ',' {
+		f.delimiter = ';'
+	}
+	for _, column := range columns {
+		f.types[column.Name] = column.Type
+	}
+	return f, nil
+}
+
+// Delimiter returns the field delimiter matching the decimal separator
+func (f *Formatter) Delimiter() rune {
+	return f.delimiter
+}
+
+// BOM reports whether output should start with a byte order mark
+func (f *Formatter) BOM() bool {
+	return f.bom
+}
+
+// Flatten reports whether record columns are written as a column per field
+func (f *Formatter) Flatten() bool {
+	return f.flatten
+}
+
+// Value formats v, a value of column. Nulls are empty; strings in number or
+// date columns are reformatted when they parse as such.
+func (f *Formatter) Value(column string, v interface{}) string {
+	switch f.types[column] {
+	case config.ColumnNumber:
+		if s, ok := text(v); ok {
+			if _, err := strconv.ParseFloat(s, 64); err == nil {
+				return f.number(numeric.Plain(s))
+			}
+			return s
+		}
+	case config.ColumnDate:
+		if t, ok := v.(time.Time); ok {
+			return t.UTC().Format(f.date)
+		}
+		if s, ok := text(v); ok {
+			if t, err := parseDate(s); err == nil {
+				return t.Format(f.date)
+			}
+			return s
+		}
+	}
+
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case float64:
+		return f.number(strconv.FormatFloat(val, 'f', -1, 64))
+	case float32:
+		return f.number(strconv.FormatFloat(float64(val), 'f', -1, 32))
+	case json.Number:
+		return f.number(numeric.Plain(val.String()))
+	case time.Time:
+		// Values of DATE columns are midnight UTC
+		if utc := val.UTC(); utc.Equal(utc.Truncate(24 * time.Hour)) {
+			return utc.Format(f.date)
+		}
+		return val.Format(f.timestamp)
+	default:
+		return Text(val)
+	}
+}
+
+// Text formats v without locale options. Numbers are in plain decimal
+// notation, never 1.5e+12. Arrays and records, such as BigQuery ARRAY and
+// STRUCT values, are JSON-encoded.
+func Text(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(val), 'f', -1, 32)
+	case json.Number:
+		return numeric.Plain(val.String())
+	case []interface{}, map[string]interface{}:
+		encoded, err := json.Marshal(val)
+		if err != nil {
+			return fmt.Sprintf("%v", val)
+		}
+		return string(encoded)
+	default:
+		return fmt.Sprintf("%v", val)
+	}
+}
+
+// FlattenRow returns row with each record value replaced by a column per
+// field, named parent.field. Only one level is flattened: records and arrays
+// within a record stay single values. A null record keeps its column.
+func FlattenRow(row map[string]interface{}) map[string]interface{} {
+	flat := make(map[string]interface{}, len(row))
+	for column, v := range row {
+		record, ok := v.(map[string]interface{})
+		if !ok {
+			flat[column] = v
+			continue
+		}
+		for field, value := range record {
+			flat[column+"."+field] = value
+		}
+	}
+	return flat
+}
+
+// number replaces the decimal point of a formatted number
+func (f *Formatter) number(s string) string {
+	if f.decimal == '.' {
+		return s
+	}
+	return strings.Replace(s, ".", string(f.decimal), 1)
+}
+
+// text returns the text of string and Stringer values, such as the civil
+// dates BigQuery returns
+func text(v interface{}) (string, bool) {
+	switch val := v.(type) {
+	case string:
+		return val, true
+	case fmt.Stringer:
+		return val.String(), true
+	}
+	return "", false
+}
+
+// parseDate parses the date forms data sources return as text
+func parseDate(s string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02", time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02T15:04:05"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("not a date: %q", s)
+}
+
+// dateTokens maps the tokens of a date_format pattern to Go layout elements,
+// longest first
+var dateTokens = []struct{ token, layout string }{
+	{"yyyy", "2006"},
+	{"yy", "06"},
+	{"MM", "01"},
+	{"dd", "02"},
+}
+
+// layout converts a date pattern such as dd/MM/yyyy to a Go time layout
+func layout(pattern string) (string, error) {
+	var b strings.Builder
+	used := make(map[string]bool)
+	for rest := pattern; rest != ""; {
+		matched := false
+		for _, t := range dateTokens {
+			if strings.HasPrefix(rest, t.token) {
+				b.WriteString(t.layout)
+				rest = rest[len(t.token):]
+				used[t.token] = true
+				matched = true
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+		switch c := rest[0]; c {
+		case '/', '-', '.', ' ':
+			b.WriteByte(c)
+			rest = rest[1:]
+		default:
+			return "", fmt.Errorf("invalid date_format %q: use yyyy, yy, MM and dd separated by / - . or space", pattern)
+		}
+	}
+	if !used["MM"] || !used["dd"] {
+		return "", fmt.Errorf("invalid date_format %q: day and month are required", pattern)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,141 @@
+package csvfmt
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-data-gateway/internal/config"
+)
+
+// date stands in for civil.Date, which BigQuery returns for DATE columns
+type date struct{ year, month, day int }
+
+func (d date) String() string { return fmt.Sprintf("%04d-%02d-%02d", d.year, d.month, d.day) }
+
+var tenderColumns = []config.ColumnSpec{
+	{Name: "nilai_pagu", Type: config.ColumnNumber},
+	{Name: "tanggal_buat_paket", Type: config.ColumnDate},
+	{Name: "nama_paket", Type: config.ColumnString},
+}
+
+func TestFormatter_Locales(t *testing.T) {
+	created := time.Date(2025, 3, 7, 0, 0, 0, 0, time.UTC)
+	updated := time.Date(2025, 3, 7, 14, 5, 9, 0, time.UTC)
+
+	tests := []struct {
+		column string
+		value  interface{}
+		enUS   string
+		idID   string
+	}{
+		{"nilai_pagu", 5000000000.50, "5000000000.5", "5000000000,5"},
+		{"nilai_pagu", "5000000000.50", "5000000000.50", "5000000000,50"}, // NUMERIC as text keeps its digits
+		{"nilai_pagu", int64(1250), "1250", "1250"},
+		{"nilai_pagu", nil, "", ""},
+		{"tanggal_buat_paket", created, "2025-03-07", "07/03/2025"},
+		{"tanggal_buat_paket", "2025-03-07", "2025-03-07", "07/03/2025"},
+		{"tanggal_buat_paket", date{2025, 3, 7}, "2025-03-07", "07/03/2025"},
+		{"tanggal_buat_paket", nil, "", ""},
+		{"nama_paket", "Pengadaan 1.5 ton", "Pengadaan 1.5 ton", "Pengadaan 1.5 ton"},
+		{"updated_at", updated, "2025-03-07 14:05:09", "07/03/2025 14:05:09"}, // Undeclared: Go type decides
+		{"updated_at", created, "2025-03-07", "07/03/2025"},
+		{"ratio", float32(0.25), "0.25", "0,25"},
+		{"active", true, "true", "true"},
+		{"missing", nil, "", ""},
+	}
+
+	enUS, err := New(Options{Locale: "en-US"}, tenderColumns)
+	require.NoError(t, err)
+	idID, err := New(Options{Locale: "id-ID"}, tenderColumns)
+	require.NoError(t, err)
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.enUS, enUS.Value(tt.column, tt.value), "en-US %s %v", tt.column, tt.value)
+		assert.Equal(t, tt.idID, idID.Value(tt.column, tt.value), "id-ID %s %v", tt.column, tt.value)
+	}
+	assert.Equal(t, ',', enUS.Delimiter())
+	assert.Equal(t, ';', idID.Delimiter())
+}
+
+func TestFormatter_Overrides(t *testing.T) {
+	f, err := New(Options{Locale: "id_ID", DecimalSeparator: ".", DateFormat: "yyyy.MM.dd", BOM: true}, tenderColumns)
+	require.NoError(t, err)
+	assert.Equal(t, "5000000000.5", f.Value("nilai_pagu", 5000000000.5))
+	assert.Equal(t, "2025.03.07", f.Value("tanggal_buat_paket", "2025-03-07"))
+	assert.Equal(t, ',', f.Delimiter())
+	assert.True(t, f.BOM())
+
+	// The locale defaults to en-US
+	f, err = New(Options{DateFormat: "dd/MM/yy"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "1.5", f.Value("n", 1.5))
+	assert.Equal(t, "07/03/25", f.Value("d", time.Date(2025, 3, 7, 0, 0, 0, 0, time.UTC)))
+	assert.False(t, f.BOM())
+}
+
+func TestNew_RejectsInvalidOptions(t *testing.T) {
+	for _, opts := range []Options{
+		{Locale: "fr-FR"},
+		{DecimalSeparator: ";"},
+		{DateFormat: "dd/MM/yyyy HH:mm"},
+		{DateFormat: "yyyy"},
+	} {
+		_, err := New(opts, nil)
+		assert.Error(t, err, "%+v", opts)
+	}
+}
+
+func TestText_EncodesNestedValuesAsJSON(t *testing.T) {
+	assert.Equal(t, `["a","b"]`, Text([]interface{}{"a", "b"}))
+	assert.Equal(t, `{"email":"a@b.id","nama":"CV Maju"}`, Text(map[string]interface{}{"nama": "CV Maju", "email": "a@b.id"}))
+	assert.Equal(t, "", Text(nil))
+	assert.Equal(t, "12", Text(int64(12)))
+
+	f, err := New(Options{Locale: LocaleIdID}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, `[1.5,2]`, f.Value("nilai", []interface{}{1.5, int64(2)}))
+}
+
+func TestText_PlainDecimalNotation(t *testing.T) {
+	assert.Equal(t, "1500000000000", Text(1.5e12))
+	assert.Equal(t, "1000000000000000000000", Text(1e21))
+	assert.Equal(t, "0.000001", Text(float32(1e-6)))
+	assert.Equal(t, "123456789012345678", Text(int64(123456789012345678)))
+	assert.Equal(t, "123456789012345678.123456789", Text(json.Number("123456789012345678.123456789")))
+	assert.Equal(t, "1500000000000", Text(json.Number("1.5e+12")))
+
+	f, err := New(Options{Locale: LocaleIdID}, tenderColumns)
+	require.NoError(t, err)
+	assert.Equal(t, "1500000000000", f.Value("nilai_pagu", "1.5E12"))
+	assert.Equal(t, "123456789012345678,123456789", f.Value("nilai_pagu", "123456789012345678.123456789"))
+	assert.Equal(t, "123456789012345678,123456789", f.Value("nilai", json.Number("123456789012345678.123456789")))
+	assert.Equal(t, "1500000000000", f.Value("nilai", 1.5e12))
+}
+
+func TestFlattenRow(t *testing.T) {
+	row := map[string]interface{}{
+		"kode_tender": "T1",
+		"peserta": map[string]interface{}{
+			"email":  "a@b.id",
+			"alamat": map[string]interface{}{"kota": "Bandung"},
+		},
+		"tags":     []interface{}{"konstruksi"},
+		"pemenang": nil,
+	}
+	assert.Equal(t, map[string]interface{}{
+		"kode_tender":    "T1",
+		"peserta.email":  "a@b.id",
+		"peserta.alamat": map[string]interface{}{"kota": "Bandung"},
+		"tags":           []interface{}{"konstruksi"},
+		"pemenang":       nil,
+	}, FlattenRow(row))
+
+	f, err := New(Options{Flatten: true}, nil)
+	require.NoError(t, err)
+	assert.True(t, f.Flatten())
+}
@@ -5,7 +5,9 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"io"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/apache/arrow-go/v18/arrow/flight"
@@ -14,6 +16,9 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/metadata"
+
+	"go-data-gateway/internal/clients"
+	"go-data-gateway/internal/metrics"
 )
 
 var (
@@ -28,6 +33,33 @@ func basicAuth(username, password string) string {
 	return base64.StdEncoding.EncodeToString([]byte(auth))
 }
 
+// authorization is the authorization header Flight calls with creds carry:
+// Bearer for a token, Basic otherwise
+func authorization(creds clients.DremioCredentials) string {
+	if creds.Token != "" && creds.Username == "" {
+		return "Bearer " + creds.Token
+	}
+	return "Basic " + basicAuth(creds.Username, creds.Password)
+}
+
+// checkAuth lists the actions of client with auth, which Dremio refuses
+// with wrong credentials. The refusal arrives with the stream, so it is read
+// to the end.
+func checkAuth(ctx context.Context, client flight.Client, auth string) error {
+	stream, err := client.ListActions(metadata.AppendToOutgoingContext(ctx, "authorization", auth), &pb.Empty{})
+	if err != nil {
+		return err
+	}
+	for {
+		if _, err := stream.Recv(); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
 // PoolConfig defines the connection pool configuration
 type PoolConfig struct {
 	MaxConnections     int           // Maximum number of connections in pool
@@ -35,6 +67,14 @@ type PoolConfig struct {
 	MaxIdleTime        time.Duration // Maximum time a connection can be idle
 	ConnectionTimeout  time.Duration // Timeout for creating new connections
 	HealthCheckInterval time.Duration // Interval for health checks
+
+	// AcquireTimeout is how long Get waits for a connection to be returned
+	// when all are in use; zero waits until the context is done
+	AcquireTimeout time.Duration
+
+	// KeepWarmInterval pings connections idle for this long so Dremio does
+	// not drop them; zero disables the pings
+	KeepWarmInterval time.Duration
 }
 
 // DefaultPoolConfig returns sensible defaults
@@ -45,6 +85,7 @@ func DefaultPoolConfig() *PoolConfig {
 		MaxIdleTime:        30 * time.Minute,
 		ConnectionTimeout:  10 * time.Second,
 		HealthCheckInterval: 1 * time.Minute,
+		AcquireTimeout:      5 * time.Second,
 	}
 }
 
@@ -55,6 +96,21 @@ type ArrowConnection struct {
 	inUse       bool
 	id          string
 	healthCheck time.Time
+	requests    int64     // Times Get handed the connection out
+	lastPing    time.Time // Last keep-warm ping
+
+	// The connection's calls authenticate with auth, the credentials of
+	// version credentials. Once they are rotated it is retired: closed when
+	// idle, or when returned after the query it is running.
+	auth        string
+	credentials uint64
+}
+
+// ConnectionStats describes one pooled connection in the pool metrics
+type ConnectionStats struct {
+	ID       string `json:"id"`
+	Requests int64  `json:"requests"`
+	InUse    bool   `json:"in_use"`
 }
 
 // ArrowConnectionPool manages a pool of Arrow Flight connections
@@ -66,6 +122,7 @@ type ArrowConnectionPool struct {
 	connections []*ArrowConnection
 	mu          sync.RWMutex
 	closed      bool
+	released    chan struct{} // Closed and replaced whenever a connection is returned
 
 	// Metrics
 	metrics struct {
@@ -74,7 +131,16 @@ type ArrowConnectionPool struct {
 		failedConnections  int64
 		totalRequests      int64
 		poolExhausted      int64
+		retired            int64
 	}
+	acquireWait *metrics.Histogram // Time Get takes to hand out a connection
+
+	nextID int64 // Numbers new connections
+
+	// dial opens a connection authenticated with the current credentials and
+	// ping checks one still answers; tests replace them
+	dial func() (*ArrowConnection, error)
+	ping func(ctx context.Context, conn *ArrowConnection) error
 
 	// Wait group for graceful shutdown
 	wg sync.WaitGroup
@@ -94,24 +160,27 @@ func NewArrowConnectionPool(dremioConfig *DremioConfig, poolConfig *PoolConfig,
 		return nil, fmt.Errorf("%w: min connections cannot exceed max connections", ErrInvalidConfig)
 	}
 
+	if dremioConfig.Credentials == nil {
+		dremioConfig.Credentials = dremioConfig.initialCredentials()
+	}
+
 	pool := &ArrowConnectionPool{
 		config:       poolConfig,
 		dremioConfig: dremioConfig,
 		logger:       logger,
 		connections:  make([]*ArrowConnection, 0, poolConfig.MaxConnections),
+		released:     make(chan struct{}),
+		acquireWait:  metrics.NewHistogram(),
 	}
+	pool.dial = pool.createConnection
+	pool.ping = pool.pingConnection
 
-	// Pre-create minimum connections
-	for i := 0; i < poolConfig.MinConnections; i++ {
-		conn, err := pool.createConnection()
-		if err != nil {
-			logger.Warn("Failed to create initial connection",
-				zap.Int("index", i),
-				zap.Error(err))
-			continue
-		}
-		pool.connections = append(pool.connections, conn)
-	}
+	// Open the minimum connections without holding up startup
+	pool.wg.Add(1)
+	go func() {
+		defer pool.wg.Done()
+		pool.warmUp()
+	}()
 
 	// Start health check routine
 	pool.wg.Add(1)
@@ -121,49 +190,96 @@ func NewArrowConnectionPool(dremioConfig *DremioConfig, poolConfig *PoolConfig,
 	pool.wg.Add(1)
 	go pool.idleCleanupRoutine()
 
+	if poolConfig.KeepWarmInterval > 0 {
+		pool.wg.Add(1)
+		go pool.keepWarmRoutine()
+	}
+
 	logger.Info("Arrow connection pool initialized",
-		zap.Int("initial_connections", len(pool.connections)),
-		zap.Int("max_connections", poolConfig.MaxConnections))
+		zap.Int("min_connections", poolConfig.MinConnections),
+		zap.Int("max_connections", poolConfig.MaxConnections),
+		zap.Duration("keep_warm_interval", poolConfig.KeepWarmInterval))
 
 	return pool, nil
 }
 
-// Get acquires a connection from the pool
+// Capacity is the maximum number of queries the pool runs at once
+func (p *ArrowConnectionPool) Capacity() int {
+	return p.config.MaxConnections
+}
+
+// Get acquires a connection from the pool. When every connection is in use it
+// waits for one to be returned, for at most AcquireTimeout, and then fails
+// with ErrPoolExhausted.
 func (p *ArrowConnectionPool) Get(ctx context.Context) (*ArrowConnection, error) {
+	var timeout <-chan time.Time
+	if p.config.AcquireTimeout > 0 {
+		timer := time.NewTimer(p.config.AcquireTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	start := time.Now()
+	for waited := false; ; waited = true {
+		conn, released, err := p.tryGet(waited)
+		if conn != nil {
+			p.acquireWait.Observe(time.Since(start))
+		}
+		if conn != nil || err != nil {
+			return conn, err
+		}
+
+		select {
+		case <-released:
+		case <-timeout:
+			return nil, ErrPoolExhausted
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %w", ErrPoolExhausted, ctx.Err())
+		}
+	}
+}
+
+// tryGet takes an idle connection or creates one under the limit. When the
+// pool is exhausted it returns the channel closed by the next Put. Retries
+// after a wait are not counted as new requests.
+func (p *ArrowConnectionPool) tryGet(retry bool) (*ArrowConnection, <-chan struct{}, error) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
 	if p.closed {
-		return nil, ErrPoolClosed
+		return nil, nil, ErrPoolClosed
 	}
 
-	p.metrics.totalRequests++
+	if !retry {
+		p.metrics.totalRequests++
+	}
+	p.retireIdle()
 
 	// Try to find an idle connection
-	for _, conn := range p.connections {
-		if !conn.inUse {
-			conn.inUse = true
-			conn.lastUsed = time.Now()
-			p.metrics.activeConnections++
+	if conn := p.pick(); conn != nil {
+		conn.inUse = true
+		conn.lastUsed = time.Now()
+		conn.requests++
+		p.metrics.activeConnections++
 
-			p.logger.Debug("Connection acquired from pool",
-				zap.String("conn_id", conn.id),
-				zap.Int("pool_size", len(p.connections)))
+		p.logger.Debug("Connection acquired from pool",
+			zap.String("conn_id", conn.id),
+			zap.Int("pool_size", len(p.connections)))
 
-			return conn, nil
-		}
+		return conn, nil, nil
 	}
 
 	// Create new connection if under limit
 	if len(p.connections) < p.config.MaxConnections {
-		conn, err := p.createConnection()
+		conn, err := p.dial()
 		if err != nil {
 			p.metrics.failedConnections++
-			return nil, fmt.Errorf("failed to create new connection: %w", err)
+			return nil, nil, fmt.Errorf("failed to create new connection: %w", err)
 		}
 
 		conn.inUse = true
 		conn.lastUsed = time.Now()
+		conn.requests++
 		p.connections = append(p.connections, conn)
 		p.metrics.totalConnections++
 		p.metrics.activeConnections++
@@ -172,12 +288,33 @@ func (p *ArrowConnectionPool) Get(ctx context.Context) (*ArrowConnection, error)
 			zap.String("conn_id", conn.id),
 			zap.Int("pool_size", len(p.connections)))
 
-		return conn, nil
+		return conn, nil, nil
 	}
 
-	// Pool exhausted
-	p.metrics.poolExhausted++
-	return nil, ErrPoolExhausted
+	// Pool exhausted; wait for a Put
+	if !retry {
+		p.metrics.poolExhausted++
+	}
+	return nil, p.released, nil
+}
+
+// pick returns the idle connection to hand out next, or nil when all are in
+// use. A connection carries one stream at a time, so the least loaded are the
+// idle ones; of those, the one used longest ago wins and then the one that
+// served the fewest requests. Spreading requests this way keeps every
+// connection warm instead of letting the first absorb the traffic.
+func (p *ArrowConnectionPool) pick() *ArrowConnection {
+	var best *ArrowConnection
+	for _, conn := range p.connections {
+		if conn.inUse {
+			continue
+		}
+		if best == nil || conn.lastUsed.Before(best.lastUsed) ||
+			(conn.lastUsed.Equal(best.lastUsed) && conn.requests < best.requests) {
+			best = conn
+		}
+	}
+	return best
 }
 
 // Put returns a connection to the pool
@@ -192,15 +329,57 @@ func (p *ArrowConnectionPool) Put(conn *ArrowConnection) {
 	conn.inUse = false
 	conn.lastUsed = time.Now()
 	p.metrics.activeConnections--
+	if p.retiring(conn) && !p.closed {
+		p.retire(conn)
+	}
+
+	// Wake every waiting Get; one of them takes the connection
+	close(p.released)
+	p.released = make(chan struct{})
 
 	p.logger.Debug("Connection returned to pool",
 		zap.String("conn_id", conn.id),
 		zap.Int("active", int(p.metrics.activeConnections)))
 }
 
-// createConnection creates a new Arrow Flight connection
+// discard closes a connection taken with Get instead of returning it, e.g.
+// one left with session options another query must not inherit
+func (p *ArrowConnectionPool) discard(conn *ArrowConnection) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.metrics.activeConnections--
+	if p.closed {
+		return
+	}
+	p.remove(conn)
+	conn.client.Close()
+
+	// A waiting Get can open a connection in its place
+	close(p.released)
+	p.released = make(chan struct{})
+
+	p.logger.Info("Connection discarded",
+		zap.String("conn_id", conn.id),
+		zap.Int("pool_size", len(p.connections)))
+}
+
+// createConnection creates a new Arrow Flight connection with the current
+// credentials
 func (p *ArrowConnectionPool) createConnection() (*ArrowConnection, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), p.config.ConnectionTimeout)
+	creds, version := p.dremioConfig.Credentials.Get()
+	conn, err := p.connect(context.Background(), creds)
+	if err != nil {
+		return nil, err
+	}
+	conn.credentials = version
+	return conn, nil
+}
+
+// connect opens an Arrow Flight connection and checks that Dremio accepts
+// creds on it
+func (p *ArrowConnectionPool) connect(ctx context.Context, creds clients.DremioCredentials) (*ArrowConnection, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.config.ConnectionTimeout)
 	defer cancel()
 
 	// Create gRPC connection options
@@ -217,26 +396,234 @@ func (p *ArrowConnectionPool) createConnection() (*ArrowConnection, error) {
 	}
 
 	// Authenticate
-	authCtx := metadata.AppendToOutgoingContext(ctx,
-		"authorization", "Basic "+basicAuth(p.dremioConfig.Username, p.dremioConfig.Password))
-
-	// Test connection with a simple action
-	_, err = flightClient.ListActions(authCtx, &pb.Empty{})
-	if err != nil {
+	auth := authorization(creds)
+	if err := checkAuth(ctx, flightClient, auth); err != nil {
 		flightClient.Close()
 		return nil, fmt.Errorf("failed to authenticate: %w", err)
 	}
 
-	connID := fmt.Sprintf("conn-%d-%d", time.Now().Unix(), len(p.connections))
+	connID := fmt.Sprintf("conn-%d-%d", time.Now().Unix(), atomic.AddInt64(&p.nextID, 1))
 
 	return &ArrowConnection{
 		client:      flightClient,
 		lastUsed:    time.Now(),
 		id:          connID,
 		healthCheck: time.Now(),
+		auth:        auth,
 	}, nil
 }
 
+// pingConnection checks that conn still answers a ListActions call
+func (p *ArrowConnectionPool) pingConnection(ctx context.Context, conn *ArrowConnection) error {
+	return checkAuth(ctx, conn.client, conn.auth)
+}
+
+// RotateCredentials opens a connection with creds and, once Dremio accepts
+// them, makes them the credentials of the pool and of every client sharing
+// them. Idle connections opened with the previous credentials are closed,
+// those in use are closed when their query returns them, and connections up
+// to MinConnections are opened with creds. Creds Dremio refuses change
+// nothing.
+func (p *ArrowConnectionPool) RotateCredentials(ctx context.Context, creds clients.DremioCredentials) error {
+	conn, err := p.connect(ctx, creds)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		conn.client.Close()
+		return ErrPoolClosed
+	}
+	conn.credentials = p.dremioConfig.Credentials.Set(creds)
+	p.retireIdle()
+	if len(p.connections) < p.config.MaxConnections {
+		p.connections = append(p.connections, conn)
+		p.metrics.totalConnections++
+	} else {
+		conn.client.Close()
+	}
+	retiring := 0
+	for _, c := range p.connections {
+		if p.retiring(c) {
+			retiring++
+		}
+	}
+	// A waiting Get can take the new connection
+	close(p.released)
+	p.released = make(chan struct{})
+	p.mu.Unlock()
+
+	p.logger.Info("Dremio credentials rotated",
+		zap.Int("retiring_in_use", retiring))
+
+	p.warmUp()
+	return nil
+}
+
+// retiring reports whether conn was opened with credentials since rotated;
+// the caller holds p.mu
+func (p *ArrowConnectionPool) retiring(conn *ArrowConnection) bool {
+	return p.dremioConfig != nil && conn.credentials != p.dremioConfig.Credentials.Version()
+}
+
+// retireIdle closes the idle connections opened with rotated credentials;
+// the caller holds p.mu
+func (p *ArrowConnectionPool) retireIdle() {
+	for _, conn := range append([]*ArrowConnection(nil), p.connections...) {
+		if !conn.inUse && p.retiring(conn) {
+			p.retire(conn)
+		}
+	}
+}
+
+// retire closes an idle connection opened with rotated credentials; the
+// caller holds p.mu
+func (p *ArrowConnectionPool) retire(conn *ArrowConnection) {
+	p.remove(conn)
+	conn.client.Close()
+	p.metrics.retired++
+	p.logger.Info("Connection retired after a credential rotation",
+		zap.String("conn_id", conn.id),
+		zap.Int("pool_size", len(p.connections)))
+}
+
+// warmUp opens connections until the pool holds MinConnections. They are
+// opened concurrently; those that fail are counted in failed_connections and
+// tried again after the next health check.
+func (p *ArrowConnectionPool) warmUp() {
+	p.mu.RLock()
+	missing := p.config.MinConnections - len(p.connections)
+	p.mu.RUnlock()
+	if missing <= 0 {
+		return
+	}
+
+	var (
+		wg     sync.WaitGroup
+		failed atomic.Int64
+	)
+	for i := 0; i < missing; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn, err := p.dial()
+			if err != nil {
+				failed.Add(1)
+				p.logger.Warn("Failed to warm up connection", zap.Error(err))
+				return
+			}
+			if !p.add(conn) {
+				conn.client.Close()
+			}
+		}()
+	}
+	wg.Wait()
+
+	p.mu.Lock()
+	p.metrics.failedConnections += failed.Load()
+	size := len(p.connections)
+	p.mu.Unlock()
+
+	p.logger.Info("Connection pool warmed up",
+		zap.Int("pool_size", size),
+		zap.Int64("failed", failed.Load()))
+}
+
+// add puts a new idle connection in the pool unless the pool is closed or
+// already full
+func (p *ArrowConnectionPool) add(conn *ArrowConnection) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed || len(p.connections) >= p.config.MaxConnections || p.retiring(conn) {
+		return false
+	}
+	p.connections = append(p.connections, conn)
+	p.metrics.totalConnections++
+	return true
+}
+
+// keepWarmRoutine pings idle connections every KeepWarmInterval
+func (p *ArrowConnectionPool) keepWarmRoutine() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.config.KeepWarmInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.keepWarm()
+		}
+
+		p.mu.RLock()
+		if p.closed {
+			p.mu.RUnlock()
+			return
+		}
+		p.mu.RUnlock()
+	}
+}
+
+// keepWarm pings the connections that have been neither used nor pinged for
+// KeepWarmInterval, so Dremio does not idle out long-lived channels. They are
+// held while pinged so Get passes them over; one that fails is dropped.
+func (p *ArrowConnectionPool) keepWarm() {
+	now := time.Now()
+	var due []*ArrowConnection
+
+	p.mu.Lock()
+	for _, conn := range p.connections {
+		last := conn.lastUsed
+		if conn.lastPing.After(last) {
+			last = conn.lastPing
+		}
+		if !conn.inUse && !p.retiring(conn) && now.Sub(last) >= p.config.KeepWarmInterval {
+			conn.inUse = true
+			due = append(due, conn)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, conn := range due {
+		ctx, cancel := context.WithTimeout(context.Background(), p.config.ConnectionTimeout)
+		err := p.ping(ctx, conn)
+		cancel()
+
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return
+		}
+		conn.inUse = false
+		if err == nil {
+			conn.lastPing = time.Now()
+		} else {
+			p.logger.Warn("Connection failed keep-warm ping",
+				zap.String("conn_id", conn.id),
+				zap.Error(err))
+			p.remove(conn)
+			conn.client.Close()
+		}
+		// A Get waiting while the connection was pinged can take it now
+		close(p.released)
+		p.released = make(chan struct{})
+		p.mu.Unlock()
+	}
+}
+
+// remove drops conn from the pool; the caller holds p.mu
+func (p *ArrowConnectionPool) remove(conn *ArrowConnection) {
+	for i, c := range p.connections {
+		if c == conn {
+			p.connections = append(p.connections[:i], p.connections[i+1:]...)
+			return
+		}
+	}
+}
+
 // healthCheckRoutine periodically checks connection health
 func (p *ArrowConnectionPool) healthCheckRoutine() {
 	defer p.wg.Done()
@@ -248,6 +635,7 @@ func (p *ArrowConnectionPool) healthCheckRoutine() {
 		select {
 		case <-ticker.C:
 			p.performHealthChecks()
+			p.warmUp()
 		}
 
 		p.mu.RLock()
@@ -267,9 +655,7 @@ func (p *ArrowConnectionPool) performHealthChecks() {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	authCtx := metadata.AppendToOutgoingContext(ctx,
-		"authorization", "Basic "+basicAuth(p.dremioConfig.Username, p.dremioConfig.Password))
-
+	p.retireIdle()
 	var healthyConns []*ArrowConnection
 	for _, conn := range p.connections {
 		if conn.inUse {
@@ -278,8 +664,7 @@ func (p *ArrowConnectionPool) performHealthChecks() {
 		}
 
 		// Test connection
-		_, err := conn.client.ListActions(authCtx, &pb.Empty{})
-		if err != nil {
+		if err := p.ping(ctx, conn); err != nil {
 			p.logger.Warn("Connection failed health check",
 				zap.String("conn_id", conn.id),
 				zap.Error(err))
@@ -360,17 +745,35 @@ func (p *ArrowConnectionPool) GetMetrics() map[string]interface{} {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 
+	connections := make([]ConnectionStats, 0, len(p.connections))
+	retiring := 0
+	for _, conn := range p.connections {
+		connections = append(connections, ConnectionStats{ID: conn.id, Requests: conn.requests, InUse: conn.inUse})
+		if p.retiring(conn) {
+			retiring++
+		}
+	}
+
 	return map[string]interface{}{
+		"connections":         connections,
 		"total_connections":   p.metrics.totalConnections,
 		"active_connections":  p.metrics.activeConnections,
 		"pool_size":          len(p.connections),
 		"failed_connections": p.metrics.failedConnections,
 		"total_requests":     p.metrics.totalRequests,
 		"pool_exhausted":     p.metrics.poolExhausted,
+		"retiring":           retiring,
+		"retired":            p.metrics.retired,
 		"max_connections":    p.config.MaxConnections,
+		"acquire_wait":       p.acquireWait.Summary(),
 	}
 }
 
+// ResetLatency drops the recorded acquire waits
+func (p *ArrowConnectionPool) ResetLatency() {
+	p.acquireWait.Reset()
+}
+
 // Close gracefully shuts down the pool
 func (p *ArrowConnectionPool) Close() error {
 	p.mu.Lock()
@@ -393,6 +796,10 @@ func (p *ArrowConnectionPool) Close() error {
 
 	p.connections = nil
 
+	// Waiting Gets fail with ErrPoolClosed
+	close(p.released)
+	p.released = make(chan struct{})
+
 	// Wait for routines to finish
 	go func() {
 		p.wg.Wait()
@@ -405,13 +812,19 @@ func (p *ArrowConnectionPool) Close() error {
 	return nil
 }
 
-// WithConnection executes a function with a pooled connection
-func (p *ArrowConnectionPool) WithConnection(ctx context.Context, fn func(flight.Client) error) error {
+// WithConnection executes a function with a pooled connection; ctx carries
+// the credentials the connection authenticates with
+func (p *ArrowConnectionPool) WithConnection(ctx context.Context, fn func(ctx context.Context, client flight.Client) error) error {
 	conn, err := p.Get(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get connection from pool: %w", err)
 	}
-	defer p.Put(conn)
 
-	return fn(conn.client)
-}
\ No newline at end of file
+	err = fn(metadata.AppendToOutgoingContext(ctx, "authorization", conn.auth), conn.client)
+	if errors.Is(err, errSessionNotReset) {
+		p.discard(conn)
+	} else {
+		p.Put(conn)
+	}
+	return err
+}
@@ -0,0 +1,212 @@
+package datasource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow/flight"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/metrics"
+)
+
+// fullPool returns a pool whose only connection is idle; Get and Put never
+// touch the Flight client
+func fullPool(acquireTimeout time.Duration) *ArrowConnectionPool {
+	return &ArrowConnectionPool{
+		config:      &PoolConfig{MaxConnections: 1, AcquireTimeout: acquireTimeout},
+		logger:      zap.NewNop(),
+		connections: []*ArrowConnection{{id: "conn-1"}},
+		released:    make(chan struct{}),
+		acquireWait: metrics.NewHistogram(),
+	}
+}
+
+func TestArrowConnectionPool_GetWaitsForPut(t *testing.T) {
+	pool := fullPool(time.Second)
+	ctx := context.Background()
+
+	held, err := pool.Get(ctx)
+	require.NoError(t, err)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		pool.Put(held)
+	}()
+
+	start := time.Now()
+	conn, err := pool.Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "conn-1", conn.id)
+	assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+
+	stats := pool.GetMetrics()
+	assert.Equal(t, int64(2), stats["total_requests"])
+	assert.Equal(t, int64(1), stats["pool_exhausted"])
+
+	// The second Get waited for the Put
+	wait := stats["acquire_wait"].(metrics.LatencySummary)
+	assert.Equal(t, int64(2), wait.Count)
+	assert.GreaterOrEqual(t, wait.P99, 40.0)
+
+	pool.ResetLatency()
+	assert.Equal(t, int64(0), pool.GetMetrics()["acquire_wait"].(metrics.LatencySummary).Count)
+}
+
+func TestArrowConnectionPool_GetGivesUp(t *testing.T) {
+	pool := fullPool(50 * time.Millisecond)
+	_, err := pool.Get(context.Background())
+	require.NoError(t, err)
+
+	// After AcquireTimeout
+	_, err = pool.Get(context.Background())
+	assert.ErrorIs(t, err, ErrPoolExhausted)
+
+	// When the context ends first
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	pool.config.AcquireTimeout = 0
+	_, err = pool.Get(ctx)
+	assert.ErrorIs(t, err, ErrPoolExhausted)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+// fakeFlightClient records whether the pool closed it
+type fakeFlightClient struct {
+	flight.Client
+	closed bool
+}
+
+func (c *fakeFlightClient) Close() error {
+	c.closed = true
+	return nil
+}
+
+// idlePool returns a pool of max connections holding the given idle ones
+func idlePool(max int, ids ...string) *ArrowConnectionPool {
+	pool := &ArrowConnectionPool{
+		config:      &PoolConfig{MaxConnections: max, AcquireTimeout: time.Second},
+		logger:      zap.NewNop(),
+		released:    make(chan struct{}),
+		acquireWait: metrics.NewHistogram(),
+	}
+	for _, id := range ids {
+		pool.connections = append(pool.connections, &ArrowConnection{id: id, client: &fakeFlightClient{}})
+	}
+	return pool
+}
+
+func TestArrowConnectionPool_SpreadsRequests(t *testing.T) {
+	pool := idlePool(3, "conn-1", "conn-2", "conn-3")
+	ctx := context.Background()
+
+	// One at a time, each Get takes the connection used longest ago
+	var order []string
+	for i := 0; i < 6; i++ {
+		conn, err := pool.Get(ctx)
+		require.NoError(t, err)
+		order = append(order, conn.id)
+		pool.Put(conn)
+	}
+	assert.Equal(t, []string{"conn-1", "conn-2", "conn-3", "conn-1", "conn-2", "conn-3"}, order)
+
+	// A held connection is passed over until it is returned
+	held, err := pool.Get(ctx) // conn-1
+	require.NoError(t, err)
+	a, err := pool.Get(ctx)
+	require.NoError(t, err)
+	pool.Put(a)
+	b, err := pool.Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"conn-1", "conn-2", "conn-3"}, []string{held.id, a.id, b.id})
+	pool.Put(b)
+	pool.Put(held)
+
+	next, err := pool.Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "conn-2", next.id)
+	pool.Put(next)
+
+	assert.Equal(t, []ConnectionStats{
+		{ID: "conn-1", Requests: 3},
+		{ID: "conn-2", Requests: 4},
+		{ID: "conn-3", Requests: 3},
+	}, pool.GetMetrics()["connections"])
+}
+
+func TestArrowConnectionPool_PrefersLeastUsedOnTies(t *testing.T) {
+	pool := idlePool(2, "conn-1", "conn-2")
+	pool.connections[0].requests = 5
+
+	conn, err := pool.Get(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "conn-2", conn.id)
+}
+
+func TestArrowConnectionPool_WarmUp(t *testing.T) {
+	pool := idlePool(4, "conn-1")
+	pool.config.MinConnections = 3
+
+	var mu sync.Mutex
+	dials := 0
+	pool.dial = func() (*ArrowConnection, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		dials++
+		if dials == 1 {
+			return nil, errors.New("connection refused")
+		}
+		return &ArrowConnection{id: fmt.Sprintf("warm-%d", dials), client: &fakeFlightClient{}}, nil
+	}
+
+	// Two are missing; one fails and is counted
+	pool.warmUp()
+	stats := pool.GetMetrics()
+	assert.Equal(t, 2, stats["pool_size"])
+	assert.Equal(t, int64(1), stats["failed_connections"])
+
+	// The next warm-up, after a health check, fills the gap
+	pool.warmUp()
+	assert.Equal(t, 3, pool.GetMetrics()["pool_size"])
+	assert.Equal(t, 3, dials)
+
+	// A full pool dials nothing
+	pool.warmUp()
+	assert.Equal(t, 3, dials)
+}
+
+func TestArrowConnectionPool_KeepWarm(t *testing.T) {
+	pool := idlePool(3, "conn-1", "conn-2", "conn-3")
+	pool.config.KeepWarmInterval = time.Minute
+	pool.config.ConnectionTimeout = time.Second
+	pool.connections[2].lastUsed = time.Now() // Used recently
+
+	var pinged []string
+	pool.ping = func(ctx context.Context, conn *ArrowConnection) error {
+		pinged = append(pinged, conn.id)
+		assert.True(t, conn.inUse, "a connection being pinged is not handed out")
+		if conn.id == "conn-2" {
+			return errors.New("transport is closing")
+		}
+		return nil
+	}
+	failing := pool.connections[1].client.(*fakeFlightClient)
+
+	pool.keepWarm()
+	assert.Equal(t, []string{"conn-1", "conn-2"}, pinged)
+	assert.True(t, failing.closed)
+	require.Len(t, pool.connections, 2)
+	assert.False(t, pool.connections[0].inUse)
+	assert.False(t, pool.connections[0].lastPing.IsZero())
+
+	// Pinged connections wait another interval
+	pinged = nil
+	pool.keepWarm()
+	assert.Empty(t, pinged)
+}
@@ -0,0 +1,290 @@
+package datasource
+
+import (
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/flight/flightsql"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/colnames"
+	"go-data-gateway/internal/numeric"
+)
+
+// ColumnarResult holds query results column by column, for consumers such as
+// stream writers that never need a map per row
+type ColumnarResult struct {
+	Columns   []string
+	Values    [][]interface{} // Values[column][row]
+	Rows      int
+	Source    DataSourceType
+	QueryTime time.Duration
+
+	// Renamed are the Columns renamed because their name repeated an
+	// earlier column's, each to the name the source gave it
+	Renamed map[string]string
+
+	// StringNumbers are the Columns whose decimals are sent as strings
+	StringNumbers []string
+}
+
+// Row copies row i into dst, in the order of Columns
+func (c *ColumnarResult) Row(i int, dst []interface{}) []interface{} {
+	dst = dst[:0]
+	for _, values := range c.Values {
+		dst = append(dst, values[i])
+	}
+	return dst
+}
+
+// Maps converts the result to the rows of QueryResult.Data
+func (c *ColumnarResult) Maps() []map[string]interface{} {
+	rows := make([]map[string]interface{}, c.Rows)
+	for i := range rows {
+		rows[i] = make(map[string]interface{}, len(c.Columns))
+	}
+	for col, name := range c.Columns {
+		for i, value := range c.Values[col] {
+			rows[i][name] = value
+		}
+	}
+	return rows
+}
+
+// recordConverter turns Arrow records into rows or columns. Field names are
+// computed once per schema and the column buffer is reused between records.
+type recordConverter struct {
+	schema  *arrow.Schema
+	names   []string
+	renamed map[string]string // Of names, when the schema repeats a field name
+	asText  []string          // Of names, the decimal columns sent as strings
+	values  []interface{}
+
+	// logger, when set, reports columns whose Arrow type has no conversion;
+	// reported holds those already logged for the current schema
+	logger   *zap.Logger
+	reported map[string]bool
+}
+
+var converterPool = sync.Pool{
+	New: func() interface{} { return &recordConverter{} },
+}
+
+func getConverter() *recordConverter {
+	return converterPool.Get().(*recordConverter)
+}
+
+func putConverter(c *recordConverter) {
+	clear(c.values) // Do not keep row values alive in the pool
+	c.values = c.values[:0]
+	c.logger = nil
+	converterPool.Put(c)
+}
+
+// columnNames returns the field names of schema, reusing the previous
+// record's names while the schema is unchanged. Repeated names, such as the
+// id of both sides of a JOIN, are made unique: by the field's table when the
+// Flight SQL column metadata names it, else by a numeric suffix.
+func (c *recordConverter) columnNames(schema *arrow.Schema) []string {
+	if c.schema != nil && (c.schema == schema || c.schema.Equal(schema)) {
+		return c.names
+	}
+	c.schema = schema
+	c.names = c.names[:0]
+	clear(c.reported)
+	tables := make([]string, 0, schema.NumFields())
+	for _, field := range schema.Fields() {
+		c.names = append(c.names, field.Name)
+		table, _ := field.Metadata.GetValue(flightsql.TableNameKey)
+		tables = append(tables, table)
+	}
+	names, renamed := colnames.Unique(c.names, tables)
+	c.names, c.renamed = names, renamed
+	c.asText = c.asText[:0]
+	for i, field := range schema.Fields() {
+		if precision, scale, ok := decimalType(field.Type); ok && numeric.AsString(precision, scale) {
+			c.asText = append(c.asText, names[i])
+		}
+	}
+	if renamed != nil && c.logger != nil {
+		c.logger.Warn("Result has repeated column names, renaming the repeats",
+			zap.Any("renamed", renamed))
+	}
+	return c.names
+}
+
+// renamedColumns returns a copy of the renames of the current schema, nil
+// when it repeats no name
+func (c *recordConverter) renamedColumns() map[string]string {
+	return maps.Clone(c.renamed)
+}
+
+// stringNumberColumns returns a copy of the decimal columns of the current
+// schema sent as strings, sorted, nil when there are none
+func (c *recordConverter) stringNumberColumns() []string {
+	if len(c.asText) == 0 {
+		return nil
+	}
+	columns := slices.Clone(c.asText)
+	slices.Sort(columns)
+	return columns
+}
+
+// decimalType returns the precision and scale of a decimal type
+func decimalType(t arrow.DataType) (precision, scale int, ok bool) {
+	switch t := t.(type) {
+	case *arrow.Decimal128Type:
+		return int(t.Precision), int(t.Scale), true
+	case *arrow.Decimal256Type:
+		return int(t.Precision), int(t.Scale), true
+	}
+	return 0, 0, false
+}
+
+// appendMaps appends the rows of record to dst as maps sized for the schema.
+// Columns are converted one at a time so each is type-switched once. Records
+// without rows, which Dremio sends ahead of data for some pushdowns, add
+// nothing.
+func (c *recordConverter) appendMaps(dst []map[string]interface{}, record arrow.Record) []map[string]interface{} {
+	numRows := int(record.NumRows())
+	if numRows == 0 {
+		return dst
+	}
+	names := c.columnNames(record.Schema())
+
+	start := len(dst)
+	for i := 0; i < numRows; i++ {
+		dst = append(dst, make(map[string]interface{}, len(names)))
+	}
+	rows := dst[start:]
+
+	for col, name := range names {
+		c.values = c.appendColumn(c.values[:0], name, record.Column(col))
+		for i, value := range c.values {
+			rows[i][name] = value
+		}
+	}
+	return dst
+}
+
+// appendColumns appends the values of record to result. The columns are
+// those of the first record, or of the first record with rows when the
+// leading empty records describe another schema.
+func (c *recordConverter) appendColumns(result *ColumnarResult, record arrow.Record) {
+	names := c.columnNames(record.Schema())
+	numRows := int(record.NumRows())
+	if result.Columns == nil || (result.Rows == 0 && numRows > 0 && !slices.Equal(names, result.Columns)) {
+		result.Columns = append([]string(nil), names...)
+		result.Values = make([][]interface{}, len(names))
+		result.Renamed = c.renamedColumns()
+		result.StringNumbers = c.stringNumberColumns()
+	}
+	if numRows == 0 {
+		return
+	}
+	for col, name := range names {
+		result.Values[col] = c.appendColumn(result.Values[col], name, record.Column(col))
+	}
+	result.Rows += numRows
+}
+
+// appendColumn appends the values of column name to dst, logging the first
+// time a column of the schema falls back to its string representation
+func (c *recordConverter) appendColumn(dst []interface{}, name string, column arrow.Array) []interface{} {
+	dst, ok := appendArrowValues(dst, column)
+	if !ok && c.logger != nil && !c.reported[name] {
+		if c.reported == nil {
+			c.reported = make(map[string]bool)
+		}
+		c.reported[name] = true
+		c.logger.Warn("Unsupported Arrow type, converting values to text",
+			zap.String("column", name),
+			zap.String("type", column.DataType().String()))
+	}
+	return dst
+}
+
+// appendArrowValues appends every value of column to dst, nil for nulls.
+// Columns of a type without a conversion are appended as text and ok is false.
+func appendArrowValues(dst []interface{}, column arrow.Array) (_ []interface{}, ok bool) {
+	switch col := column.(type) {
+	case *array.Null:
+		return appendValues(dst, column, func(int) interface{} { return nil }), true
+	case *array.Int64:
+		return appendValues(dst, column, col.Value), true
+	case *array.Int32:
+		return appendValues(dst, column, func(row int) int64 { return int64(col.Value(row)) }), true
+	case *array.Int16:
+		return appendValues(dst, column, func(row int) int64 { return int64(col.Value(row)) }), true
+	case *array.Int8:
+		return appendValues(dst, column, func(row int) int64 { return int64(col.Value(row)) }), true
+	case *array.Uint64:
+		return appendValues(dst, column, col.Value), true
+	case *array.Uint32:
+		return appendValues(dst, column, func(row int) int64 { return int64(col.Value(row)) }), true
+	case *array.Uint16:
+		return appendValues(dst, column, func(row int) int64 { return int64(col.Value(row)) }), true
+	case *array.Uint8:
+		return appendValues(dst, column, func(row int) int64 { return int64(col.Value(row)) }), true
+	case *array.Float64:
+		return appendValues(dst, column, col.Value), true
+	case *array.Float32:
+		return appendValues(dst, column, col.Value), true
+	case *array.Decimal128:
+		// Text keeps the full precision of DECIMAL columns; see numeric.Decimal
+		precision, scale, _ := decimalType(col.DataType())
+		return appendValues(dst, column, func(row int) interface{} {
+			return numeric.Decimal(col.Value(row).ToString(int32(scale)), precision, scale)
+		}), true
+	case *array.Decimal256:
+		precision, scale, _ := decimalType(col.DataType())
+		return appendValues(dst, column, func(row int) interface{} {
+			return numeric.Decimal(col.Value(row).ToString(int32(scale)), precision, scale)
+		}), true
+	case *array.String:
+		return appendValues(dst, column, col.Value), true
+	case *array.LargeString:
+		return appendValues(dst, column, col.Value), true
+	case *array.Binary, *array.LargeBinary:
+		// Base64, as encoding/json writes []byte
+		return appendValues(dst, column, column.ValueStr), true
+	case *array.Boolean:
+		return appendValues(dst, column, col.Value), true
+	case *array.Date32:
+		return appendValues(dst, column, func(row int) time.Time {
+			// Days since epoch
+			return time.Unix(int64(col.Value(row))*86400, 0)
+		}), true
+	case *array.Date64:
+		return appendValues(dst, column, func(row int) time.Time {
+			// Milliseconds since epoch
+			return time.UnixMilli(int64(col.Value(row)))
+		}), true
+	case *array.Timestamp:
+		unit := col.DataType().(*arrow.TimestampType).Unit
+		return appendValues(dst, column, func(row int) time.Time {
+			return col.Value(row).ToTime(unit)
+		}), true
+	case *array.Time32, *array.Time64:
+		// Time of day, e.g. 13:45:00
+		return appendValues(dst, column, column.ValueStr), true
+	default:
+		// String representation for other types
+		return appendValues(dst, column, column.ValueStr), false
+	}
+}
+
+func appendValues[T any](dst []interface{}, column arrow.Array, value func(row int) T) []interface{} {
+	for row := 0; row < column.Len(); row++ {
+		if column.IsNull(row) {
+			dst = append(dst, nil)
+			continue
+		}
+		dst = append(dst, value(row))
+	}
+	return dst
+}
@@ -0,0 +1,518 @@
+package datasource
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/decimal128"
+	"github.com/apache/arrow-go/v18/arrow/decimal256"
+	"github.com/apache/arrow-go/v18/arrow/flight/flightsql"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"go-data-gateway/internal/numeric"
+)
+
+var tenderSchema = arrow.NewSchema([]arrow.Field{
+	{Name: "tender_id", Type: arrow.BinaryTypes.String, Nullable: true},
+	{Name: "nilai_pagu", Type: arrow.PrimitiveTypes.Float64, Nullable: true},
+	{Name: "tahun_anggaran", Type: arrow.PrimitiveTypes.Int64},
+	{Name: "is_deleted", Type: arrow.FixedWidthTypes.Boolean},
+	{Name: "tanggal_pengumuman", Type: arrow.FixedWidthTypes.Date32},
+	{Name: "updated_at", Type: &arrow.TimestampType{Unit: arrow.Millisecond, TimeZone: "UTC"}},
+	{Name: "kode_satker", Type: arrow.PrimitiveTypes.Int32},
+}, nil)
+
+// tenderRecord builds n rows starting at row offset; every seventh row has
+// null id and pagu
+func tenderRecord(t testing.TB, offset, n int) arrow.Record {
+	t.Helper()
+	b := array.NewRecordBuilder(memory.NewGoAllocator(), tenderSchema)
+	defer b.Release()
+
+	for i := offset; i < offset+n; i++ {
+		if i%7 == 0 {
+			b.Field(0).(*array.StringBuilder).AppendNull()
+			b.Field(1).(*array.Float64Builder).AppendNull()
+		} else {
+			b.Field(0).(*array.StringBuilder).Append(fmt.Sprintf("TND-%07d", i))
+			b.Field(1).(*array.Float64Builder).Append(float64(i) * 1250000.5)
+		}
+		b.Field(2).(*array.Int64Builder).Append(2025)
+		b.Field(3).(*array.BooleanBuilder).Append(i%2 == 0)
+		b.Field(4).(*array.Date32Builder).Append(arrow.Date32(20089 + i%365))
+		b.Field(5).(*array.TimestampBuilder).Append(arrow.Timestamp(1735689600000 + int64(i)*1000))
+		b.Field(6).(*array.Int32Builder).Append(int32(i % 500))
+	}
+	return b.NewRecord()
+}
+
+// recordToMapsPerRow is the previous conversion: a type switch per cell and
+// a map grown from empty for every row. It is the baseline for the benchmarks.
+func recordToMapsPerRow(record arrow.Record) []map[string]interface{} {
+	var results []map[string]interface{}
+	schema := record.Schema()
+	for row := 0; row < int(record.NumRows()); row++ {
+		rowMap := make(map[string]interface{})
+		for col := 0; col < int(record.NumCols()); col++ {
+			rowMap[schema.Field(col).Name] = valueAtRow(record.Column(col), row)
+		}
+		results = append(results, rowMap)
+	}
+	return results
+}
+
+func valueAtRow(column arrow.Array, row int) interface{} {
+	if column.IsNull(row) {
+		return nil
+	}
+	switch col := column.(type) {
+	case *array.Int64:
+		return col.Value(row)
+	case *array.Int32:
+		return int64(col.Value(row))
+	case *array.Float64:
+		return col.Value(row)
+	case *array.String:
+		return col.Value(row)
+	case *array.Boolean:
+		return col.Value(row)
+	case *array.Date32:
+		return time.Unix(int64(col.Value(row))*86400, 0)
+	case *array.Timestamp:
+		return col.Value(row).ToTime(col.DataType().(*arrow.TimestampType).Unit)
+	default:
+		return col.ValueStr(row)
+	}
+}
+
+func TestRecordConverter_MatchesPerRowConversion(t *testing.T) {
+	first, second := tenderRecord(t, 0, 50), tenderRecord(t, 50, 30)
+	defer first.Release()
+	defer second.Release()
+
+	want := append(recordToMapsPerRow(first), recordToMapsPerRow(second)...)
+
+	converter := getConverter()
+	defer putConverter(converter)
+
+	var rows []map[string]interface{}
+	rows = converter.appendMaps(rows, first)
+	rows = converter.appendMaps(rows, second)
+	assert.Equal(t, want, rows)
+	assert.Nil(t, rows[0]["tender_id"])
+	assert.Equal(t, int64(7), rows[7]["kode_satker"])
+
+	columnar := &ColumnarResult{}
+	converter.appendColumns(columnar, first)
+	converter.appendColumns(columnar, second)
+	assert.Equal(t, 80, columnar.Rows)
+	assert.Equal(t, []string{"tender_id", "nilai_pagu", "tahun_anggaran", "is_deleted",
+		"tanggal_pengumuman", "updated_at", "kode_satker"}, columnar.Columns)
+	assert.Equal(t, want, columnar.Maps())
+
+	row := columnar.Row(51, nil)
+	require.Len(t, row, 7)
+	assert.Equal(t, "TND-0000051", row[0])
+	assert.Equal(t, int64(2025), row[2])
+}
+
+func TestRecordConverter_SchemaChange(t *testing.T) {
+	tender := tenderRecord(t, 1, 2)
+	defer tender.Release()
+
+	b := array.NewRecordBuilder(memory.NewGoAllocator(), arrow.NewSchema([]arrow.Field{
+		{Name: "kd_klpd", Type: arrow.BinaryTypes.String},
+	}, nil))
+	defer b.Release()
+	b.Field(0).(*array.StringBuilder).Append("K12")
+	other := b.NewRecord()
+	defer other.Release()
+
+	converter := &recordConverter{}
+	converter.appendMaps(nil, tender)
+	rows := converter.appendMaps(nil, other)
+	assert.Equal(t, []map[string]interface{}{{"kd_klpd": "K12"}}, rows)
+}
+
+func TestRecordConverter_PoolDropsValues(t *testing.T) {
+	record := tenderRecord(t, 1, 3)
+	defer record.Release()
+
+	converter := &recordConverter{}
+	converter.appendMaps(nil, record)
+	require.NotEmpty(t, converter.values)
+
+	putConverter(converter)
+	assert.Empty(t, converter.values)
+	assert.Nil(t, converter.values[:cap(converter.values)][0])
+}
+
+// nullableSchema has a column of every type the converter handles
+var nullableSchema = arrow.NewSchema([]arrow.Field{
+	{Name: "null", Type: arrow.Null, Nullable: true},
+	{Name: "int8", Type: arrow.PrimitiveTypes.Int8, Nullable: true},
+	{Name: "int16", Type: arrow.PrimitiveTypes.Int16, Nullable: true},
+	{Name: "int32", Type: arrow.PrimitiveTypes.Int32, Nullable: true},
+	{Name: "int64", Type: arrow.PrimitiveTypes.Int64, Nullable: true},
+	{Name: "uint8", Type: arrow.PrimitiveTypes.Uint8, Nullable: true},
+	{Name: "uint16", Type: arrow.PrimitiveTypes.Uint16, Nullable: true},
+	{Name: "uint32", Type: arrow.PrimitiveTypes.Uint32, Nullable: true},
+	{Name: "uint64", Type: arrow.PrimitiveTypes.Uint64, Nullable: true},
+	{Name: "float32", Type: arrow.PrimitiveTypes.Float32, Nullable: true},
+	{Name: "float64", Type: arrow.PrimitiveTypes.Float64, Nullable: true},
+	{Name: "decimal128", Type: &arrow.Decimal128Type{Precision: 38, Scale: 2}, Nullable: true},
+	{Name: "decimal256", Type: &arrow.Decimal256Type{Precision: 76, Scale: 2}, Nullable: true},
+	{Name: "string", Type: arrow.BinaryTypes.String, Nullable: true},
+	{Name: "large_string", Type: arrow.BinaryTypes.LargeString, Nullable: true},
+	{Name: "binary", Type: arrow.BinaryTypes.Binary, Nullable: true},
+	{Name: "large_binary", Type: arrow.BinaryTypes.LargeBinary, Nullable: true},
+	{Name: "boolean", Type: arrow.FixedWidthTypes.Boolean, Nullable: true},
+	{Name: "date32", Type: arrow.FixedWidthTypes.Date32, Nullable: true},
+	{Name: "date64", Type: arrow.FixedWidthTypes.Date64, Nullable: true},
+	{Name: "timestamp", Type: arrow.FixedWidthTypes.Timestamp_us, Nullable: true},
+	{Name: "time32", Type: arrow.FixedWidthTypes.Time32s, Nullable: true},
+	{Name: "time64", Type: arrow.FixedWidthTypes.Time64us, Nullable: true},
+}, nil)
+
+// nullRecord builds n rows of nullableSchema with every value null
+func nullRecord(t testing.TB, n int) arrow.Record {
+	t.Helper()
+	b := array.NewRecordBuilder(memory.NewGoAllocator(), nullableSchema)
+	defer b.Release()
+	for i := 0; i < n; i++ {
+		for _, field := range b.Fields() {
+			field.AppendNull()
+		}
+	}
+	return b.NewRecord()
+}
+
+func TestRecordConverter_AllNullColumns(t *testing.T) {
+	record := nullRecord(t, 3)
+	defer record.Release()
+
+	core, logs := observer.New(zap.WarnLevel)
+	converter := &recordConverter{logger: zap.New(core)}
+
+	rows := converter.appendMaps(nil, record)
+	require.Len(t, rows, 3)
+	for _, row := range rows {
+		assert.Len(t, row, len(nullableSchema.Fields()))
+		for name, value := range row {
+			assert.Nil(t, value, name)
+		}
+	}
+
+	result := &ColumnarResult{}
+	converter.appendColumns(result, record)
+	assert.Equal(t, 3, result.Rows)
+	assert.Equal(t, rows, result.Maps())
+	assert.Zero(t, logs.Len(), "every type has a conversion")
+}
+
+func TestRecordConverter_ConvertsEverySupportedType(t *testing.T) {
+	b := array.NewRecordBuilder(memory.NewGoAllocator(), nullableSchema)
+	defer b.Release()
+	b.Field(0).AppendNull()
+	b.Field(1).(*array.Int8Builder).Append(-8)
+	b.Field(2).(*array.Int16Builder).Append(-16)
+	b.Field(3).(*array.Int32Builder).Append(-32)
+	b.Field(4).(*array.Int64Builder).Append(-64)
+	b.Field(5).(*array.Uint8Builder).Append(8)
+	b.Field(6).(*array.Uint16Builder).Append(16)
+	b.Field(7).(*array.Uint32Builder).Append(32)
+	b.Field(8).(*array.Uint64Builder).Append(64)
+	b.Field(9).(*array.Float32Builder).Append(1.5)
+	b.Field(10).(*array.Float64Builder).Append(2.5)
+	b.Field(11).(*array.Decimal128Builder).Append(decimal128.FromI64(125050))
+	b.Field(12).(*array.Decimal256Builder).Append(decimal256.FromI64(-125050))
+	b.Field(13).(*array.StringBuilder).Append("TND-1")
+	b.Field(14).(*array.LargeStringBuilder).Append("TND-2")
+	b.Field(15).(*array.BinaryBuilder).Append([]byte("ab"))
+	b.Field(16).(*array.BinaryBuilder).Append([]byte("cd"))
+	b.Field(17).(*array.BooleanBuilder).Append(true)
+	b.Field(18).(*array.Date32Builder).Append(arrow.Date32(20089))
+	b.Field(19).(*array.Date64Builder).Append(arrow.Date64(20089 * 86400000))
+	b.Field(20).(*array.TimestampBuilder).Append(arrow.Timestamp(1735689600000000))
+	b.Field(21).(*array.Time32Builder).Append(arrow.Time32(49500))
+	b.Field(22).(*array.Time64Builder).Append(arrow.Time64(49500000000))
+	record := b.NewRecord()
+	defer record.Release()
+
+	rows := (&recordConverter{}).appendMaps(nil, record)
+	require.Len(t, rows, 1)
+	row := rows[0]
+	assert.Nil(t, row["null"])
+	assert.Equal(t, int64(-8), row["int8"])
+	assert.Equal(t, int64(-16), row["int16"])
+	assert.Equal(t, int64(-32), row["int32"])
+	assert.Equal(t, int64(-64), row["int64"])
+	assert.Equal(t, int64(8), row["uint8"])
+	assert.Equal(t, int64(16), row["uint16"])
+	assert.Equal(t, int64(32), row["uint32"])
+	assert.Equal(t, uint64(64), row["uint64"])
+	assert.Equal(t, float32(1.5), row["float32"])
+	assert.Equal(t, 2.5, row["float64"])
+	assert.Equal(t, "1250.50", row["decimal128"])
+	assert.Equal(t, "-1250.50", row["decimal256"])
+	assert.Equal(t, "TND-1", row["string"])
+	assert.Equal(t, "TND-2", row["large_string"])
+	assert.Equal(t, "YWI=", row["binary"])
+	assert.Equal(t, "Y2Q=", row["large_binary"])
+	assert.Equal(t, true, row["boolean"])
+	assert.True(t, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC).Equal(row["date32"].(time.Time)))
+	assert.True(t, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC).Equal(row["date64"].(time.Time)))
+	assert.True(t, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC).Equal(row["timestamp"].(time.Time)))
+	assert.Equal(t, "13:45:00", row["time32"])
+	assert.Equal(t, "13:45:00.000000", row["time64"])
+}
+
+func TestRecordConverter_SkipsEmptyRecords(t *testing.T) {
+	empty, data := tenderRecord(t, 0, 0), tenderRecord(t, 1, 3)
+	defer empty.Release()
+	defer data.Release()
+
+	converter := &recordConverter{}
+	var rows []map[string]interface{}
+	rows = converter.appendMaps(rows, empty)
+	rows = converter.appendMaps(rows, data)
+	rows = converter.appendMaps(rows, empty)
+	require.Len(t, rows, 3)
+	for _, row := range rows {
+		assert.NotNil(t, row)
+	}
+	assert.Equal(t, "TND-0000001", rows[0]["tender_id"])
+
+	result := &ColumnarResult{}
+	converter.appendColumns(result, empty)
+	assert.Equal(t, tenderSchemaNames(), result.Columns, "an empty result still has columns")
+	converter.appendColumns(result, data)
+	converter.appendColumns(result, empty)
+	assert.Equal(t, 3, result.Rows)
+	assert.Equal(t, rows, result.Maps())
+}
+
+func TestRecordConverter_EmptyRecordWithOtherSchema(t *testing.T) {
+	b := array.NewRecordBuilder(memory.NewGoAllocator(), arrow.NewSchema(nil, nil))
+	defer b.Release()
+	empty := b.NewRecord()
+	defer empty.Release()
+	data := tenderRecord(t, 1, 2)
+	defer data.Release()
+
+	result := &ColumnarResult{}
+	converter := &recordConverter{}
+	converter.appendColumns(result, empty)
+	converter.appendColumns(result, data)
+	assert.Equal(t, tenderSchemaNames(), result.Columns)
+	assert.Equal(t, 2, result.Rows)
+	assert.Equal(t, "TND-0000002", result.Row(1, nil)[0])
+}
+
+func TestRecordConverter_LogsUnsupportedTypeOncePerSchema(t *testing.T) {
+	schema := arrow.NewSchema([]arrow.Field{
+		{Name: "tags", Type: arrow.ListOf(arrow.BinaryTypes.String), Nullable: true},
+	}, nil)
+	record := func() arrow.Record {
+		b := array.NewRecordBuilder(memory.NewGoAllocator(), schema)
+		defer b.Release()
+		list := b.Field(0).(*array.ListBuilder)
+		list.Append(true)
+		list.ValueBuilder().(*array.StringBuilder).Append("konstruksi")
+		list.AppendNull()
+		return b.NewRecord()
+	}
+	first, second := record(), record()
+	defer first.Release()
+	defer second.Release()
+
+	core, logs := observer.New(zap.WarnLevel)
+	converter := &recordConverter{logger: zap.New(core)}
+	rows := converter.appendMaps(nil, first)
+	rows = converter.appendMaps(rows, second)
+
+	require.Len(t, rows, 4)
+	assert.Equal(t, `["konstruksi"]`, rows[0]["tags"])
+	assert.Nil(t, rows[1]["tags"])
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, "tags", logs.All()[0].ContextMap()["column"])
+
+	// A new schema is reported again
+	tender := tenderRecord(t, 1, 1)
+	defer tender.Release()
+	converter.appendMaps(nil, tender)
+	converter.appendMaps(nil, first)
+	assert.Equal(t, 2, logs.Len())
+}
+
+// joinSchema is the schema of a JOIN of tenders and their participants
+// that selects the id of both; Flight SQL metadata names the table of the
+// second
+var joinSchema = arrow.NewSchema([]arrow.Field{
+	{Name: "id", Type: arrow.BinaryTypes.String},
+	{Name: "nama_paket", Type: arrow.BinaryTypes.String},
+	{Name: "id", Type: arrow.BinaryTypes.String,
+		Metadata: arrow.NewMetadata([]string{flightsql.TableNameKey}, []string{"tender_peserta"})},
+	{Name: "id", Type: arrow.PrimitiveTypes.Int64},
+}, nil)
+
+func joinRecord(t testing.TB) arrow.Record {
+	t.Helper()
+	b := array.NewRecordBuilder(memory.NewGoAllocator(), joinSchema)
+	defer b.Release()
+	b.Field(0).(*array.StringBuilder).AppendValues([]string{"TND-1", "TND-2"}, nil)
+	b.Field(1).(*array.StringBuilder).AppendValues([]string{"Jalan", "Jembatan"}, nil)
+	b.Field(2).(*array.StringBuilder).AppendValues([]string{"PST-9", "PST-8"}, nil)
+	b.Field(3).(*array.Int64Builder).AppendValues([]int64{7, 8}, nil)
+	return b.NewRecord()
+}
+
+func TestRecordConverter_DuplicateNames(t *testing.T) {
+	first, second := joinRecord(t), joinRecord(t)
+	defer first.Release()
+	defer second.Release()
+	renamed := map[string]string{"tender_peserta_id": "id", "id_1": "id"}
+
+	core, logs := observer.New(zap.WarnLevel)
+	converter := &recordConverter{logger: zap.New(core)}
+	rows := converter.appendMaps(nil, first)
+	rows = converter.appendMaps(rows, second)
+
+	// No column is lost, and the first keeps its name
+	require.Len(t, rows, 4)
+	assert.Equal(t, map[string]interface{}{
+		"id": "TND-1", "nama_paket": "Jalan", "tender_peserta_id": "PST-9", "id_1": int64(7),
+	}, rows[0])
+	assert.Equal(t, renamed, converter.renamedColumns())
+	require.Equal(t, 1, logs.Len(), "logged once per schema")
+	assert.Equal(t, renamed, logs.All()[0].ContextMap()["renamed"])
+
+	// Stream writers see the same names
+	result := &ColumnarResult{}
+	converter.appendColumns(result, first)
+	assert.Equal(t, []string{"id", "nama_paket", "tender_peserta_id", "id_1"}, result.Columns)
+	assert.Equal(t, renamed, result.Renamed)
+	assert.Equal(t, rows[:2], result.Maps())
+
+	// A schema without repeats renames nothing
+	tender := tenderRecord(t, 1, 1)
+	defer tender.Release()
+	converter.appendMaps(nil, tender)
+	assert.Nil(t, converter.renamedColumns())
+}
+
+var decimalSchema = arrow.NewSchema([]arrow.Field{
+	{Name: "kode_rup", Type: &arrow.Decimal128Type{Precision: 18, Scale: 0}},
+	{Name: "nilai_kontrak", Type: &arrow.Decimal128Type{Precision: 15, Scale: 2}},
+	{Name: "nilai_pagu", Type: &arrow.Decimal128Type{Precision: 38, Scale: 9}},
+}, nil)
+
+func decimalRecord(t testing.TB) arrow.Record {
+	t.Helper()
+	b := array.NewRecordBuilder(memory.NewGoAllocator(), decimalSchema)
+	defer b.Release()
+	for i, text := range []string{"123456789012345678", "1500000000000.25", "123456789012345678.123456789"} {
+		field := decimalSchema.Field(i).Type.(*arrow.Decimal128Type)
+		n, err := decimal128.FromString(text, field.Precision, field.Scale)
+		require.NoError(t, err)
+		b.Field(i).(*array.Decimal128Builder).Append(n)
+	}
+	return b.NewRecord()
+}
+
+func TestRecordConverter_DecimalPrecision(t *testing.T) {
+	record := decimalRecord(t)
+	defer record.Release()
+
+	converter := &recordConverter{}
+	rows := converter.appendMaps(nil, record)
+	require.Len(t, rows, 1)
+	assert.Equal(t, map[string]interface{}{
+		"kode_rup":      int64(123456789012345678),
+		"nilai_kontrak": json.Number("1500000000000.25"),
+		"nilai_pagu":    "123456789012345678.123456789",
+	}, rows[0])
+	assert.Equal(t, []string{"nilai_pagu"}, converter.stringNumberColumns())
+
+	encoded, err := json.Marshal(rows[0])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"kode_rup": 123456789012345678, "nilai_kontrak": 1500000000000.25, "nilai_pagu": "123456789012345678.123456789"}`, string(encoded))
+	assert.Contains(t, string(encoded), `"kode_rup":123456789012345678`)
+
+	numeric.SetMode(numeric.ModeAlways)
+	defer numeric.SetMode(numeric.ModeAuto)
+	converter = &recordConverter{}
+	result := &ColumnarResult{}
+	converter.appendColumns(result, record)
+	assert.Equal(t, []string{"nilai_kontrak", "nilai_pagu"}, result.StringNumbers)
+	assert.Equal(t, []interface{}{int64(123456789012345678), "1500000000000.25", "123456789012345678.123456789"}, result.Row(0, nil))
+}
+
+func tenderSchemaNames() []string {
+	var names []string
+	for _, field := range tenderSchema.Fields() {
+		names = append(names, field.Name)
+	}
+	return names
+}
+
+func benchmarkRecords(b *testing.B) []arrow.Record {
+	const rows, batch = 1_000_000, 65536
+	var records []arrow.Record
+	for offset := 0; offset < rows; offset += batch {
+		records = append(records, tenderRecord(b, offset, min(batch, rows-offset)))
+	}
+	b.Cleanup(func() {
+		for _, record := range records {
+			record.Release()
+		}
+	})
+	return records
+}
+
+// BenchmarkRecordToMaps converts a 1M-row result in 64k-row records
+func BenchmarkRecordToMaps(b *testing.B) {
+	records := benchmarkRecords(b)
+
+	b.Run("per_row", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var rows []map[string]interface{}
+			for _, record := range records {
+				rows = append(rows, recordToMapsPerRow(record)...)
+			}
+		}
+	})
+
+	b.Run("converter", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			converter := getConverter()
+			var rows []map[string]interface{}
+			for _, record := range records {
+				rows = converter.appendMaps(rows, record)
+			}
+			putConverter(converter)
+		}
+	})
+
+	b.Run("columnar", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			converter := getConverter()
+			result := &ColumnarResult{}
+			for _, record := range records {
+				converter.appendColumns(result, record)
+			}
+			putConverter(converter)
+		}
+	})
+}
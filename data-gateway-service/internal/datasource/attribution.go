@@ -0,0 +1,134 @@
+package datasource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// MaxQueryLabels bounds the caller-supplied labels of a query
+const MaxQueryLabels = 8
+
+var (
+	// BigQuery label rules: lowercase letters, digits, '_' and '-', keys start
+	// with a letter, both at most 63 characters
+	labelKeyPattern   = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,62}$`)
+	labelValuePattern = regexp.MustCompile(`^[a-z0-9_-]{0,63}$`)
+
+	// Characters replaced in IDs echoed into labels and SQL comments; request
+	// IDs may come from the client's X-Request-ID header
+	unsafeLabelChars   = regexp.MustCompile(`[^a-z0-9_-]`)
+	unsafeCommentChars = regexp.MustCompile(`[^A-Za-z0-9._:/-]`)
+)
+
+// Labels set by the gateway itself; callers may not supply them
+const (
+	LabelGateway  = "gateway"
+	LabelAPIKeyID = "api_key_id"
+)
+
+// ValidateLabels checks caller-supplied query labels
+func ValidateLabels(labels map[string]string) error {
+	if len(labels) > MaxQueryLabels {
+		return fmt.Errorf("at most %d labels are allowed", MaxQueryLabels)
+	}
+	for key, value := range labels {
+		if key == LabelGateway || key == LabelAPIKeyID {
+			return fmt.Errorf("label %q is reserved", key)
+		}
+		if !labelKeyPattern.MatchString(key) {
+			return fmt.Errorf("label key %q must be lowercase letters, digits, '_' or '-' and start with a letter", key)
+		}
+		if !labelValuePattern.MatchString(value) {
+			return fmt.Errorf("label %q value must be lowercase letters, digits, '_' or '-'", key)
+		}
+	}
+	return nil
+}
+
+// Attribution identifies who a query runs for. It travels in the context so
+// data sources can forward it to the upstream engine.
+type Attribution struct {
+	Labels    map[string]string // Validated caller labels
+	APIKeyID  string
+	RequestID string
+}
+
+type attributionKey struct{}
+
+// WithAttribution stores the query attribution in the context
+func WithAttribution(ctx context.Context, a Attribution) context.Context {
+	return context.WithValue(ctx, attributionKey{}, a)
+}
+
+// AttributionFromContext returns the query attribution, if any
+func AttributionFromContext(ctx context.Context) (Attribution, bool) {
+	a, ok := ctx.Value(attributionKey{}).(Attribution)
+	return a, ok
+}
+
+// JobLabels returns the caller labels plus gateway=true and api_key_id, in
+// the form BigQuery accepts as job labels
+func (a Attribution) JobLabels() map[string]string {
+	labels := make(map[string]string, len(a.Labels)+2)
+	for k, v := range a.Labels {
+		labels[k] = v
+	}
+	labels[LabelGateway] = "true"
+	if a.APIKeyID != "" {
+		id := unsafeLabelChars.ReplaceAllString(strings.ToLower(a.APIKeyID), "_")
+		if len(id) > 63 {
+			id = id[:63]
+		}
+		labels[LabelAPIKeyID] = id
+	}
+	return labels
+}
+
+// SQLComment renders the attribution as a single-line leading comment, e.g.
+// "/* gateway key=key_ab12 req=host/x-000001 app=finance */ "
+func (a Attribution) SQLComment() string {
+	parts := []string{"gateway"}
+	if a.APIKeyID != "" {
+		parts = append(parts, "key="+unsafeCommentChars.ReplaceAllString(a.APIKeyID, "_"))
+	}
+	if a.RequestID != "" {
+		parts = append(parts, "req="+unsafeCommentChars.ReplaceAllString(a.RequestID, "_"))
+	}
+
+	keys := make([]string, 0, len(a.Labels))
+	for k := range a.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		parts = append(parts, k+"="+a.Labels[k])
+	}
+	return "/* " + strings.Join(parts, " ") + " */ "
+}
+
+// attributionComment returns the SQL comment for the context's attribution,
+// or "" when the query is not attributed
+func attributionComment(ctx context.Context) string {
+	a, ok := AttributionFromContext(ctx)
+	if !ok {
+		return ""
+	}
+	return a.SQLComment()
+}
+
+// stripAnnotation maps an error position reported for comment+query back to
+// query. The comment is a single line, so only columns on line 1 move.
+func stripAnnotation(err error, comment string) error {
+	var upstreamErr *UpstreamError
+	if comment == "" || !errors.As(err, &upstreamErr) || upstreamErr.Position == nil {
+		return err
+	}
+	if upstreamErr.Position.Line == 1 {
+		upstreamErr.Position.Column = max(upstreamErr.Position.Column-len(comment), 1)
+	}
+	return err
+}
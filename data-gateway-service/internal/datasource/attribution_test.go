@@ -0,0 +1,162 @@
+package datasource
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/api/option"
+
+	"go-data-gateway/internal/clients"
+	"go-data-gateway/internal/config"
+)
+
+func TestValidateLabels(t *testing.T) {
+	assert.NoError(t, ValidateLabels(nil))
+	assert.NoError(t, ValidateLabels(map[string]string{"app": "finance-dashboard", "team": "spend_2025"}))
+
+	tooMany := map[string]string{}
+	for _, k := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i"} {
+		tooMany[k] = "x"
+	}
+	invalid := []map[string]string{
+		tooMany,
+		{"App": "finance"},
+		{"app": "Finance"},
+		{"1app": "finance"},
+		{"app": "finance dashboard"},
+		{"gateway": "false"},
+		{"api_key_id": "someone-else"},
+	}
+	for _, labels := range invalid {
+		assert.Error(t, ValidateLabels(labels), labels)
+	}
+}
+
+func TestAttribution(t *testing.T) {
+	a := Attribution{
+		Labels:    map[string]string{"team": "spend", "app": "finance"},
+		APIKeyID:  "key_AB12",
+		RequestID: "host/abc-000001 */ DROP",
+	}
+
+	assert.Equal(t, map[string]string{
+		"app":        "finance",
+		"team":       "spend",
+		"gateway":    "true",
+		"api_key_id": "key_ab12",
+	}, a.JobLabels())
+
+	// The client-controlled request ID cannot close the comment
+	assert.Equal(t, "/* gateway key=key_AB12 req=host/abc-000001__/_DROP app=finance team=spend */ ", a.SQLComment())
+}
+
+func TestStripAnnotation(t *testing.T) {
+	comment := Attribution{APIKeyID: "key_1"}.SQLComment()
+
+	err := &UpstreamError{Class: ErrorClassSyntax, Position: &QueryPosition{Line: 1, Column: len(comment) + 10}}
+	stripAnnotation(err, comment)
+	assert.Equal(t, 10, err.Position.Column)
+
+	// Later lines are unaffected
+	err = &UpstreamError{Class: ErrorClassSyntax, Position: &QueryPosition{Line: 2, Column: 4}}
+	stripAnnotation(err, comment)
+	assert.Equal(t, 4, err.Position.Column)
+}
+
+func TestBigQueryJobLabels(t *testing.T) {
+	var labels map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Labels are sent on jobs.query or, for inserted jobs, in the configuration
+		var body struct {
+			Labels        map[string]string `json:"labels"`
+			Configuration struct {
+				Labels map[string]string `json:"labels"`
+			} `json:"configuration"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body.Labels != nil {
+			labels = body.Labels
+		} else if body.Configuration.Labels != nil {
+			labels = body.Configuration.Labels
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": map[string]interface{}{"code": http.StatusBadRequest, "message": "stop here"},
+		})
+	}))
+	defer srv.Close()
+
+	client, err := clients.NewBigQueryClient(
+		config.BigQueryConfig{ProjectID: "test-project"},
+		zap.NewNop(),
+		option.WithEndpoint(srv.URL),
+		option.WithHTTPClient(srv.Client()),
+		option.WithoutAuthentication(),
+	)
+	require.NoError(t, err)
+
+	wrapper := &BigQueryWrapper{client: client, logger: zap.NewNop(), sanitizer: NewSQLSanitizer()}
+	defer wrapper.Close()
+
+	ctx := WithAttribution(context.Background(), Attribution{
+		Labels:   map[string]string{"app": "finance"},
+		APIKeyID: "key_ab12",
+	})
+	_, err = wrapper.ExecuteQuery(ctx, "SELECT 1", nil)
+	require.Error(t, err)
+
+	assert.Equal(t, map[string]string{
+		"app":        "finance",
+		"gateway":    "true",
+		"api_key_id": "key_ab12",
+	}, labels)
+}
+
+func TestDremioAttributionComment(t *testing.T) {
+	var submitted []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/v3/sql":
+			var body struct {
+				SQL string `json:"sql"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			submitted = append(submitted, body.SQL)
+			json.NewEncoder(w).Encode(map[string]string{"id": "job-1"})
+		case strings.HasSuffix(r.URL.Path, "/results"):
+			json.NewEncoder(w).Encode(map[string]interface{}{"rowCount": 1, "rows": []map[string]interface{}{{"n": 1}}})
+		default:
+			json.NewEncoder(w).Encode(map[string]string{"jobState": "COMPLETED"})
+		}
+	}))
+	defer srv.Close()
+
+	host, port := hostPort(t, srv.URL)
+	ds, err := NewDremioRESTClient(host, port, "", "", zap.NewNop())
+	require.NoError(t, err)
+
+	ctx := WithAttribution(context.Background(), Attribution{
+		Labels:    map[string]string{"app": "finance"},
+		APIKeyID:  "key_ab12",
+		RequestID: "host/abc-000001",
+	})
+	_, err = ds.ExecuteQuery(ctx, "SELECT n FROM t", nil)
+	require.NoError(t, err)
+	require.Len(t, submitted, 1)
+	assert.Equal(t, "/* gateway key=key_ab12 req=host/abc-000001 app=finance */ SELECT n FROM t", submitted[0])
+
+	// The comment is not part of the cache key: another request hits the cache
+	other := WithAttribution(context.Background(), Attribution{APIKeyID: "key_cd34", RequestID: "host/abc-000002"})
+	_, err = ds.ExecuteQuery(other, "SELECT n FROM t", nil)
+	require.NoError(t, err)
+	assert.Len(t, submitted, 1)
+}
@@ -0,0 +1,29 @@
+package datasource
+
+import "go-data-gateway/internal/clients"
+
+// MetaBigQueryJob is the metadata key of the *clients.JobStats of the
+// BigQuery job behind a QueryResult
+const MetaBigQueryJob = "bigquery_job"
+
+// setBigQueryJob records the job that produced result in its metadata
+func setBigQueryJob(result *QueryResult, stats *clients.JobStats) {
+	if stats == nil {
+		return
+	}
+	if result.Metadata == nil {
+		result.Metadata = make(map[string]interface{})
+	}
+	result.Metadata[MetaBigQueryJob] = stats
+}
+
+// BigQueryJob returns the statistics of the job that produced result, nil
+// when no job ran for it: results served from cache keep the metadata of
+// the job that filled the cache, but cost nothing
+func BigQueryJob(result *QueryResult) *clients.JobStats {
+	if result == nil || result.CacheHit {
+		return nil
+	}
+	stats, _ := result.Metadata[MetaBigQueryJob].(*clients.JobStats)
+	return stats
+}
@@ -6,15 +6,20 @@ import (
 	"time"
 
 	"go-data-gateway/internal/clients"
+	"go-data-gateway/internal/colnames"
 	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/inflight"
+	"go-data-gateway/internal/metrics"
+	"go-data-gateway/internal/numeric"
 	"go.uber.org/zap"
 )
 
 // BigQueryWrapper wraps the BigQueryClient to implement DataSource interface
 type BigQueryWrapper struct {
-	client    *clients.BigQueryClient
+	client    clients.BigQuerier
 	logger    *zap.Logger
-	sanitizer *SQLSanitizer
+	sanitizer *SQLSanitizer // Fixed sanitizer; nil follows the active security config
+	usage     *metrics.BigQueryUsageCounter
 }
 
 // NewBigQueryWrapper creates a new BigQuery wrapper that implements DataSource
@@ -24,32 +29,63 @@ func NewBigQueryWrapper(cfg config.BigQueryConfig, logger *zap.Logger) (*BigQuer
 		return nil, fmt.Errorf("failed to create BigQuery client: %w", err)
 	}
 
-	// Initialize sanitizer with allowed tables whitelist
-	sanitizer := NewSQLSanitizer()
-	secConfig := config.GetDefaultSecurityConfig()
-	sanitizer.SetAllowedTables(secConfig.AllowedBigQueryTables)
+	// A dataset outside the configured location fails every query; report it now
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := client.CheckLocation(ctx); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return NewBigQueryWrapperWithClient(client, logger), nil
+}
 
+// NewBigQueryWrapperWithClient wraps client, e.g. a bqtest.Stub, without
+// checking its location
+func NewBigQueryWrapperWithClient(client clients.BigQuerier, logger *zap.Logger) *BigQueryWrapper {
 	return &BigQueryWrapper{
-		client:    client,
-		logger:    logger,
-		sanitizer: sanitizer,
-	}, nil
+		client: client,
+		logger: logger,
+	}
+}
+
+// SetJobCancels counts the jobs cancelled because their request ended in
+// counter
+func (w *BigQueryWrapper) SetJobCancels(counter *metrics.CancelCounter) {
+	w.client.SetJobCancels(counter)
+}
+
+// SetUsage accumulates the bytes and slot time of the jobs run, by API key,
+// in counter
+func (w *BigQueryWrapper) SetUsage(counter *metrics.BigQueryUsageCounter) {
+	w.usage = counter
 }
 
 // ExecuteQuery executes a SQL query (implements DataSource interface)
 func (w *BigQueryWrapper) ExecuteQuery(ctx context.Context, query string, opts *QueryOptions) (*QueryResult, error) {
 	start := time.Now()
 
+	// Attributed queries carry their labels to the BigQuery job
+	var labels map[string]string
+	attribution, attributed := AttributionFromContext(ctx)
+	if attributed {
+		labels = attribution.JobLabels()
+	}
+
 	// Call the underlying BigQuery client
-	results, err := w.client.ExecuteQuery(ctx, query)
+	inflight.FromContext(ctx).SetPhase(inflight.PhaseExecuting)
+	results, err := w.client.ExecuteLabeledQuery(ctx, query, labels)
 	if err != nil {
-		return nil, err
+		return nil, ClassifyBigQueryError(err)
 	}
 
 	// Convert results to proper format
 	var data []map[string]interface{}
 
 	// Check if results is already []map[string]interface{}
+	var renamed map[string]string
+	var stringNumbers []string
+	var stats *clients.JobStats
 	if resultData, ok := results.([]map[string]interface{}); ok {
 		data = resultData
 	} else {
@@ -60,55 +96,113 @@ func (w *BigQueryWrapper) ExecuteQuery(ctx context.Context, query string, opts *
 			} else {
 				return nil, fmt.Errorf("unexpected result structure from BigQuery")
 			}
+			renamed, _ = resultMap[colnames.MetaKey].(map[string]string)
+			stringNumbers, _ = resultMap[numeric.MetaKey].([]string)
+			stats, _ = resultMap[clients.JobStatsKey].(*clients.JobStats)
 		} else {
 			return nil, fmt.Errorf("unexpected result type from BigQuery: %T", results)
 		}
 	}
 
-	return &QueryResult{
+	result := &QueryResult{
 		Data:      data,
 		Count:     len(data),
 		Source:    DataSourceBigQuery,
 		QueryTime: time.Since(start),
 		CacheHit:  false,
-	}, nil
+	}
+	setRenamedColumns(result, renamed)
+	setStringNumbers(result, stringNumbers)
+	setBigQueryJob(result, stats)
+	if stats != nil {
+		w.usage.Record(attribution.APIKeyID, stats.TotalBytesProcessed, stats.TotalBytesBilled, stats.SlotMillis, stats.CacheHit)
+	}
+	return result, nil
+}
+
+// ValidateQuery checks the query with a BigQuery dry run (implements Validator)
+func (w *BigQueryWrapper) ValidateQuery(ctx context.Context, query string) error {
+	_, err := w.client.DryRun(ctx, query)
+	return ClassifyBigQueryError(err)
+}
+
+// DescribeTable returns the schema of table with its nested fields
+// (implements SchemaDescriber)
+func (w *BigQueryWrapper) DescribeTable(ctx context.Context, table string) ([]ColumnField, error) {
+	schema, err := w.client.TableSchema(ctx, table)
+	if err != nil {
+		return nil, ClassifyBigQueryError(err)
+	}
+	return bigQueryColumns(schema), nil
+}
+
+// TableSize returns the rows and bytes of table from its metadata
+// (implements TableSizer)
+func (w *BigQueryWrapper) TableSize(ctx context.Context, table string) (*TableStats, error) {
+	rows, bytes, err := w.client.TableSize(ctx, table)
+	if err != nil {
+		return nil, ClassifyBigQueryError(err)
+	}
+	return &TableStats{Rows: rows, Bytes: bytes}, nil
 }
 
 // GetData retrieves data with filters and pagination
 func (w *BigQueryWrapper) GetData(ctx context.Context, table string, opts *QueryOptions) (*QueryResult, error) {
-	// Sanitize table name to prevent SQL injection (uses whitelist)
-	safeTable, err := w.sanitizer.ValidateTableName(table)
+	query, err := w.tableQuery(table, opts)
 	if err != nil {
-		return nil, fmt.Errorf("invalid table name: %w", err)
+		return nil, err
 	}
 
-	// Build query with LIMIT for cost safety
-	query := fmt.Sprintf("SELECT * FROM `%s`", safeTable)
+	return w.ExecuteQuery(ctx, query, opts)
+}
 
+// tableQuery builds the query of a GetData, at most 100 rows without a limit
+func (w *BigQueryWrapper) tableQuery(table string, opts *QueryOptions) (string, error) {
+	// Default limit for cost safety
+	limited := QueryOptions{Limit: 100}
 	if opts != nil {
-		if opts.Limit > 0 {
-			query += fmt.Sprintf(" LIMIT %d", opts.Limit)
-		} else {
-			// Default limit for safety
-			query += " LIMIT 100"
+		limited = *opts
+		if limited.Limit <= 0 {
+			limited.Limit = 100
 		}
+	}
 
-		if opts.Offset > 0 {
-			query += fmt.Sprintf(" OFFSET %d", opts.Offset)
-		}
-	} else {
-		// Default limit for safety
-		query += " LIMIT 100"
+	// Sanitize table, filters and ordering to prevent SQL injection (uses whitelists)
+	sanitizer := w.sanitizer
+	if sanitizer == nil {
+		sanitizer = newBigQueryTableSanitizer()
 	}
+	query, err := sanitizer.BuildSafeTableQuery(table, &limited)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidTableQuery, err)
+	}
+	return query, nil
+}
 
-	return w.ExecuteQuery(ctx, query, opts)
+// PlanQuery reports query, which runs as submitted (implements Planner)
+func (w *BigQueryWrapper) PlanQuery(ctx context.Context, query string, opts *QueryOptions) (*QueryPlan, error) {
+	return &QueryPlan{SQL: query}, nil
+}
+
+// PlanTable reports the query GetData would run (implements Planner)
+func (w *BigQueryWrapper) PlanTable(ctx context.Context, table string, opts *QueryOptions) (*QueryPlan, error) {
+	query, err := w.tableQuery(table, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &QueryPlan{SQL: query}, nil
 }
 
-// TestConnection tests the BigQuery connection
+// TestConnection checks credentials and connectivity without running a job
 func (w *BigQueryWrapper) TestConnection(ctx context.Context) error {
 	return w.client.TestConnection(ctx)
 }
 
+// DeepCheck runs a query job
+func (w *BigQueryWrapper) DeepCheck(ctx context.Context) error {
+	return w.client.TestQuery(ctx)
+}
+
 // GetType returns the data source type
 func (w *BigQueryWrapper) GetType() DataSourceType {
 	return DataSourceBigQuery
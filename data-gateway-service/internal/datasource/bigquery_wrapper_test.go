@@ -0,0 +1,70 @@
+package datasource
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/clients"
+	"go-data-gateway/internal/clients/bqtest"
+	"go-data-gateway/internal/colnames"
+	"go-data-gateway/internal/metrics"
+)
+
+// renamingBigQuery answers every query as the BigQuery client does when the
+// result repeats a column name
+type renamingBigQuery struct {
+	*bqtest.Stub
+}
+
+func (renamingBigQuery) ExecuteLabeledQuery(ctx context.Context, query string, labels map[string]string) (interface{}, error) {
+	return map[string]interface{}{
+		"data":           []map[string]interface{}{{"id": "K1", "id_1": int64(7)}},
+		colnames.MetaKey: map[string]string{"id_1": "id"},
+	}, nil
+}
+
+func TestBigQueryWrapper_RenamedColumns(t *testing.T) {
+	wrapper := NewBigQueryWrapperWithClient(renamingBigQuery{bqtest.NewStub()}, zap.NewNop())
+
+	result, err := wrapper.ExecuteQuery(context.Background(), "SELECT r.id, k.id FROM rup r JOIN kro k USING (kd_kro)", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]interface{}{{"id": "K1", "id_1": int64(7)}}, result.Data)
+	assert.Equal(t, map[string]string{"id_1": "id"}, result.Metadata[MetaRenamedColumns])
+
+	// Results without repeats carry no renames
+	plain := NewBigQueryWrapperWithClient(bqtest.NewStub(), zap.NewNop())
+	result, err = plain.ExecuteQuery(context.Background(), "SELECT id FROM rup", nil)
+	require.NoError(t, err)
+	assert.NotContains(t, result.Metadata, MetaRenamedColumns)
+}
+
+func TestBigQueryWrapper_JobStats(t *testing.T) {
+	stub := bqtest.NewStub()
+	stub.SetJobStats(clients.JobStats{TotalBytesProcessed: 1 << 20, TotalBytesBilled: 10 << 20, SlotMillis: 250})
+	usage := metrics.NewBigQueryUsageCounter()
+	wrapper := NewBigQueryWrapperWithClient(stub, zap.NewNop())
+	wrapper.SetUsage(usage)
+
+	ctx := WithAttribution(context.Background(), Attribution{APIKeyID: "finance"})
+	result, err := wrapper.ExecuteQuery(ctx, "SELECT id FROM rup", nil)
+	require.NoError(t, err)
+	stats := BigQueryJob(result)
+	require.NotNil(t, stats)
+	assert.Equal(t, "stub_job_1", stats.JobID)
+	assert.Equal(t, int64(10<<20), stats.TotalBytesBilled)
+	assert.Equal(t, metrics.BigQueryUsage{Jobs: 1, BytesProcessed: 1 << 20, BytesBilled: 10 << 20, SlotMillis: 250}, usage.Usage("finance"))
+
+	// A result served from cache cost nothing
+	result.CacheHit = true
+	assert.Nil(t, BigQueryJob(result))
+
+	// Jobs answered from BigQuery's own cache count, but bill no bytes
+	stub.SetJobStats(clients.JobStats{TotalBytesProcessed: 1 << 20, CacheHit: true})
+	_, err = wrapper.ExecuteQuery(ctx, "SELECT id FROM rup", nil)
+	require.NoError(t, err)
+	assert.Equal(t, metrics.BigQueryUsage{Jobs: 2, CachedJobs: 1, BytesProcessed: 2 << 20, BytesBilled: 10 << 20, SlotMillis: 250}, usage.Usage("finance"))
+}
@@ -0,0 +1,19 @@
+package datasource
+
+import "context"
+
+// CapacityHinter is implemented by data sources that can only run a limited
+// number of queries at once, such as a pooled Arrow Flight client
+type CapacityHinter interface {
+	// Capacity is the number of queries the source runs concurrently; 0
+	// means no known limit
+	Capacity(ctx context.Context) int
+}
+
+// Capacity returns the concurrency hint of source, 0 when it advertises none
+func Capacity(ctx context.Context, source DataSource) int {
+	if c, ok := source.(CapacityHinter); ok {
+		return max(c.Capacity(ctx), 0)
+	}
+	return 0
+}
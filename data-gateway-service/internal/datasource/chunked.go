@@ -0,0 +1,130 @@
+package datasource
+
+import (
+	"context"
+	"fmt"
+
+	"go-data-gateway/internal/inflight"
+)
+
+// FetchChunks pages through a raw query or a table with Limit/Offset and calls
+// fn for every non-empty chunk. It stops after a short chunk, when fn returns
+// an error or when ctx is done. base supplies ordering and filters; its Limit
+// and Offset are ignored. It returns the total number of rows fetched.
+func FetchChunks(ctx context.Context, source DataSource, query, table string, chunkSize int, base *QueryOptions,
+	fn func(rows []map[string]interface{}) error) (int, error) {
+
+	if query == "" && table == "" {
+		return 0, fmt.Errorf("either query or table is required")
+	}
+	if chunkSize <= 0 {
+		return 0, fmt.Errorf("chunk size must be positive")
+	}
+
+	offset := 0
+	totalRows := 0
+	op := inflight.FromContext(ctx)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return totalRows, err
+		}
+
+		result, err := fetchChunk(ctx, source, query, table, chunkOptions(base, chunkSize, offset))
+		if err != nil {
+			return totalRows, err
+		}
+
+		if len(result.Data) > 0 {
+			op.SetPhase(inflight.PhaseStreaming)
+			if err := fn(result.Data); err != nil {
+				return totalRows, err
+			}
+			totalRows += len(result.Data)
+			op.AddRows(len(result.Data))
+		}
+
+		// A short chunk means the end of the data
+		if len(result.Data) < chunkSize {
+			return totalRows, nil
+		}
+
+		offset += chunkSize
+	}
+}
+
+// Prefetch fetches the first chunk of a FetchChunks read ahead of it, so a
+// caller can report a failing query before committing its response. The
+// returned source serves that chunk to the first read at offset 0 and
+// delegates every other read to source.
+func Prefetch(ctx context.Context, source DataSource, query, table string, chunkSize int, base *QueryOptions) (DataSource, error) {
+	if query == "" && table == "" {
+		return nil, fmt.Errorf("either query or table is required")
+	}
+	if chunkSize <= 0 {
+		return nil, fmt.Errorf("chunk size must be positive")
+	}
+
+	first, err := fetchChunk(ctx, source, query, table, chunkOptions(base, chunkSize, 0))
+	if err != nil {
+		return nil, err
+	}
+	return &prefetchedSource{DataSource: source, first: first}, nil
+}
+
+// chunkOptions returns the options of the chunk at offset
+func chunkOptions(base *QueryOptions, chunkSize, offset int) *QueryOptions {
+	opts := &QueryOptions{
+		Limit:  chunkSize,
+		Offset: offset,
+	}
+	if base != nil {
+		opts.OrderBy = base.OrderBy
+		opts.OrderDir = base.OrderDir
+		opts.Tiebreaker = base.Tiebreaker
+		opts.After = base.After
+		opts.Filters = base.Filters
+		opts.CacheTTL = base.CacheTTL
+		opts.Timeout = base.Timeout
+		opts.Parameters = base.Parameters
+	}
+	return opts
+}
+
+// fetchChunk reads one chunk of a raw query or a table
+func fetchChunk(ctx context.Context, source DataSource, query, table string, opts *QueryOptions) (*QueryResult, error) {
+	if query != "" {
+		return source.ExecuteQuery(ctx, query, opts)
+	}
+	return source.GetData(ctx, table, opts)
+}
+
+// prefetchedSource serves a chunk read by Prefetch once
+type prefetchedSource struct {
+	DataSource
+	first *QueryResult
+}
+
+func (s *prefetchedSource) ExecuteQuery(ctx context.Context, query string, opts *QueryOptions) (*QueryResult, error) {
+	if result := s.take(opts); result != nil {
+		return result, nil
+	}
+	return s.DataSource.ExecuteQuery(ctx, query, opts)
+}
+
+func (s *prefetchedSource) GetData(ctx context.Context, table string, opts *QueryOptions) (*QueryResult, error) {
+	if result := s.take(opts); result != nil {
+		return result, nil
+	}
+	return s.DataSource.GetData(ctx, table, opts)
+}
+
+// take returns the prefetched chunk to the first read at offset 0
+func (s *prefetchedSource) take(opts *QueryOptions) *QueryResult {
+	if s.first == nil || (opts != nil && opts.Offset != 0) {
+		return nil
+	}
+	result := s.first
+	s.first = nil
+	return result
+}
@@ -0,0 +1,47 @@
+package datasource
+
+import (
+	"context"
+	"errors"
+
+	"go-data-gateway/internal/clients"
+)
+
+// ErrNoCredentials is returned by data sources without the credentials asked
+// to rotate
+var ErrNoCredentials = errors.New("data source has no credentials to rotate")
+
+// CredentialRotator is implemented by data sources whose Dremio credentials
+// can be replaced while they serve queries. With account set, only a source
+// authenticating with account is rotated; others return ErrNoCredentials.
+// A nil account rotates the source's credentials, whichever they are.
+type CredentialRotator interface {
+	RotateCredentials(ctx context.Context, account *clients.Credentials, creds clients.DremioCredentials) error
+}
+
+// RotateCredentials rotates the Dremio credentials of source if it
+// authenticates with account. It reports false for a source without those
+// credentials, such as BigQuery or a Dremio source with its own account.
+func RotateCredentials(ctx context.Context, source DataSource, account *clients.Credentials, creds clients.DremioCredentials) (bool, error) {
+	r, ok := source.(CredentialRotator)
+	if !ok {
+		return false, nil
+	}
+	if err := r.RotateCredentials(ctx, account, creds); err != nil {
+		if errors.Is(err, ErrNoCredentials) {
+			return false, nil
+		}
+		return true, err
+	}
+	return true, nil
+}
+
+// rotateWrapped rotates the credentials of a wrapped source, returning
+// ErrNoCredentials when it has none
+func rotateWrapped(ctx context.Context, source DataSource, account *clients.Credentials, creds clients.DremioCredentials) error {
+	ok, err := RotateCredentials(ctx, source, account, creds)
+	if !ok {
+		return ErrNoCredentials
+	}
+	return err
+}
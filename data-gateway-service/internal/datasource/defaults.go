@@ -0,0 +1,18 @@
+package datasource
+
+import "go-data-gateway/internal/config"
+
+// Defaulter is implemented by data sources with configured query defaults,
+// such as the routed sources of the tenant registry
+type Defaulter interface {
+	QueryDefaults() config.QueryDefaults
+}
+
+// Defaults returns the query defaults of source, or the built-in global
+// defaults when it has none
+func Defaults(source DataSource) config.QueryDefaults {
+	if d, ok := source.(Defaulter); ok {
+		return d.QueryDefaults().Or(config.DefaultQueryDefaults())
+	}
+	return config.DefaultQueryDefaults()
+}
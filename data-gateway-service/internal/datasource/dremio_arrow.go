@@ -3,13 +3,12 @@ package datasource
 import (
 	"context"
 	"crypto/tls"
-	"encoding/base64"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/apache/arrow-go/v18/arrow"
-	"github.com/apache/arrow-go/v18/arrow/array"
 	"github.com/apache/arrow-go/v18/arrow/flight"
 	pb "github.com/apache/arrow-go/v18/arrow/flight/gen/flight"
 	"github.com/apache/arrow-go/v18/arrow/memory"
@@ -19,6 +18,10 @@ import (
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/metadata"
+
+	"go-data-gateway/internal/clients"
+	"go-data-gateway/internal/inflight"
+	"go-data-gateway/internal/sqltext"
 )
 
 // DremioArrowClient implements DataSource using Arrow Flight SQL
@@ -32,8 +35,6 @@ type DremioArrowClient struct {
 	ctx       context.Context
 	usePool   bool
 	sanitizer *SQLSanitizer
-	username  string
-	password  string
 }
 
 // DremioConfig holds Dremio connection configuration
@@ -45,6 +46,24 @@ type DremioConfig struct {
 	Token    string
 	UseTLS   bool
 	Project  string // Optional: default project/space in Dremio
+	UIURL    string // Optional: Dremio UI base URL for job profile links
+
+	// Credentials are those queries authenticate with, shared with the other
+	// clients of the account; nil holds Username, Password and Token
+	Credentials *clients.Credentials
+
+	// Jobs optionally looks up job ids that Flight does not report; each
+	// lookup is an extra query against sys.jobs_recent
+	Jobs JobLookup
+}
+
+// initialCredentials holds the configured username, password and token
+func (c *DremioConfig) initialCredentials() *clients.Credentials {
+	return clients.NewCredentials(clients.DremioCredentials{
+		Username: c.Username,
+		Password: c.Password,
+		Token:    c.Token,
+	})
 }
 
 // NewDremioArrowClientWithPool creates a new Arrow Flight SQL client with connection pooling
@@ -63,8 +82,6 @@ func NewDremioArrowClientWithPool(cfg *DremioConfig, poolConfig *PoolConfig, log
 		memAlloc: memory.NewGoAllocator(),
 		ctx:      context.Background(),
 		usePool:  true,
-		username: cfg.Username,
-		password: cfg.Password,
 	}
 
 	logger.Info("Dremio Arrow Flight client initialized with connection pool",
@@ -97,6 +114,9 @@ func NewDremioArrowClient(cfg *DremioConfig, logger *zap.Logger) (*DremioArrowCl
 		return nil, fmt.Errorf("failed to create flight client: %w", err)
 	}
 
+	if cfg.Credentials == nil {
+		cfg.Credentials = cfg.initialCredentials()
+	}
 	client := &DremioArrowClient{
 		client:   flightClient,
 		config:   cfg,
@@ -104,30 +124,35 @@ func NewDremioArrowClient(cfg *DremioConfig, logger *zap.Logger) (*DremioArrowCl
 		cache:    cache.New(5*time.Minute, 10*time.Minute),
 		memAlloc: memory.NewGoAllocator(),
 		ctx:      ctx,
-		username: cfg.Username,
-		password: cfg.Password,
-	}
-
-	// Set up authentication context if credentials provided
-	if cfg.Username != "" && cfg.Password != "" {
-		auth := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%s", cfg.Username, cfg.Password)))
-		client.ctx = metadata.AppendToOutgoingContext(client.ctx, "authorization", "Basic "+auth)
-		logger.Info("Authentication context set up", zap.String("user", cfg.Username))
 	}
 
 	logger.Info("Dremio Arrow Flight client initialized", zap.String("host", cfg.Host), zap.Int("port", cfg.Port))
 	return client, nil
 }
 
-// ExecuteQuery executes a SQL query using Arrow Flight
+// ExecuteQuery executes a SQL query using Arrow Flight. With opts.Limit set,
+// the query is wrapped to return that page of its rows.
 func (d *DremioArrowClient) ExecuteQuery(ctx context.Context, query string, opts *QueryOptions) (*QueryResult, error) {
+	query, paged := pageQuery(query, opts)
+	result, err := d.execute(ctx, query, opts)
+	if err != nil && paged {
+		return nil, shiftPositionLines(err, 1)
+	}
+	return result, err
+}
+
+// execute runs query as is, caching the result for opts.CacheTTL
+func (d *DremioArrowClient) execute(ctx context.Context, query string, opts *QueryOptions) (*QueryResult, error) {
 	// Validate query is read-only
 	if !isReadOnlySQL(query) {
 		return nil, fmt.Errorf("only SELECT queries are allowed")
 	}
 
-	// Check cache
+	// Check cache; results of different Nessie references never mix
 	cacheKey := fmt.Sprintf("arrow:%s:%v", query, opts)
+	if version := VersionCacheKey(ctx); version != "" {
+		cacheKey += ":" + version
+	}
 	if cached, found := d.cache.Get(cacheKey); found {
 		d.logger.Debug("Cache hit", zap.String("query", query))
 		result := cached.(*QueryResult)
@@ -138,188 +163,308 @@ func (d *DremioArrowClient) ExecuteQuery(ctx context.Context, query string, opts
 	start := time.Now()
 	d.logger.Info("Executing Arrow Flight query", zap.String("sql", query))
 
+	// Attribution shows in Dremio's job history; it is not part of the cache key
+	comment := attributionComment(ctx)
+
+	converter := getConverter()
+	converter.logger = d.logger
+	defer putConverter(converter)
+
+	results := []map[string]interface{}{}
+	var options EngineOptions
+	if opts != nil {
+		options = opts.EngineOptions
+	}
+	jobID, err := d.readRecords(ctx, query, comment, options, func(record arrow.Record) {
+		results = converter.appendMaps(results, record)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	queryTime := time.Since(start)
+	if jobID == "" {
+		jobID = d.lookupJob(ctx, comment+query, start)
+	}
+	d.logger.Info("Query completed",
+		zap.String("dremio_job_id", jobID),
+		zap.Duration("duration", queryTime),
+		zap.Int("rows", len(results)))
+
+	result := &QueryResult{
+		Data:      results,
+		Count:     len(results),
+		Source:    DataSourceDremio,
+		QueryTime: queryTime,
+	}
+	setDremioJob(result, d.config.UIURL, jobID)
+	if len(results) > 0 {
+		// Without rows no record was converted, and the converter may hold
+		// the names of a pooled schema
+		setRenamedColumns(result, converter.renamedColumns())
+		setStringNumbers(result, converter.stringNumberColumns())
+	}
+
+	// Cache the results
+	if opts != nil && opts.CacheTTL > 0 {
+		d.cache.Set(cacheKey, result, opts.CacheTTL)
+	} else {
+		d.cache.Set(cacheKey, result, cache.DefaultExpiration)
+	}
+
+	return result, nil
+}
+
+// ExecuteQueryColumnar runs a read-only query like ExecuteQuery but returns
+// the rows column by column and bypasses the result cache. Consumers that
+// write rows in column order avoid building a map per row.
+func (d *DremioArrowClient) ExecuteQueryColumnar(ctx context.Context, query string) (*ColumnarResult, error) {
+	if !isReadOnlySQL(query) {
+		return nil, fmt.Errorf("only SELECT queries are allowed")
+	}
+
+	start := time.Now()
+	converter := getConverter()
+	converter.logger = d.logger
+	defer putConverter(converter)
+
+	result := &ColumnarResult{Source: DataSourceDremio}
+	_, err := d.readRecords(ctx, query, attributionComment(ctx), nil, func(record arrow.Record) {
+		converter.appendColumns(result, record)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result.QueryTime = time.Since(start)
+	return result, nil
+}
+
+// lookupJob asks the configured JobLookup for the job that ran sql. Failures
+// are logged and leave the result without a job id.
+func (d *DremioArrowClient) lookupJob(ctx context.Context, sql string, since time.Time) string {
+	if d.config.Jobs == nil {
+		return ""
+	}
+	jobID, err := d.config.Jobs.FindJob(ctx, sql, since.Add(-time.Second))
+	if err != nil {
+		d.logger.Debug("Dremio job lookup failed", zap.Error(err))
+	}
+	return jobID
+}
+
+// readRecords runs query over Arrow Flight, through the pool when enabled,
+// and calls fn for every record; records are released after fn returns. It
+// returns the Dremio job id when the FlightInfo carries one. The request's
+// Nessie reference and engine options are set on the pooled connection for
+// the query, in that order, and reset after it.
+func (d *DremioArrowClient) readRecords(ctx context.Context, query, comment string, options EngineOptions, fn func(arrow.Record)) (string, error) {
+	var jobID string
+
 	// Create flight descriptor for SQL query (raw Flight protocol)
 	desc := &pb.FlightDescriptor{
 		Type: pb.FlightDescriptor_CMD,
-		Cmd:  []byte(query),
+		Cmd:  []byte(comment + query),
 	}
 
-	var results []map[string]interface{}
+	version, _ := VersionFromContext(ctx)
+	set, reset := version.sessionStatements()
+	optionSet, optionReset, err := options.sessionStatements()
+	if err != nil {
+		return "", err
+	}
+	set, reset = append(set, optionSet...), append(reset, optionReset...)
 
 	// Use connection pool if available
 	if d.usePool && d.pool != nil {
-		err := d.pool.WithConnection(ctx, func(client flight.Client) error {
-			// Add authentication to context
-			authCtx := metadata.AppendToOutgoingContext(ctx,
-				"authorization", "Basic "+basicAuth(d.username, d.password))
-
-			// Get flight info for the query
-			info, err := client.GetFlightInfo(authCtx, desc)
-			if err != nil {
-				return fmt.Errorf("failed to get flight info: %w", err)
-			}
+		var queryErr error
+		err := d.pool.WithConnection(ctx, func(authCtx context.Context, client flight.Client) error {
+			inflight.FromContext(ctx).SetPhase(inflight.PhaseExecuting)
+			authCtx = options.withRouting(authCtx)
 
-			// Check if we have endpoints
-			if len(info.GetEndpoint()) == 0 {
-				return fmt.Errorf("no endpoints returned")
-			}
-
-			// Fetch results from the first endpoint
-			endpoint := info.GetEndpoint()[0]
-			stream, err := client.DoGet(authCtx, endpoint.GetTicket())
+			restore, err := applySession(authCtx, client, set, reset)
 			if err != nil {
-				return fmt.Errorf("failed to get data stream: %w", err)
+				return err
 			}
-
-			// Create record reader from stream
-			reader, err := flight.NewRecordReader(stream)
-			if err != nil {
-				return fmt.Errorf("failed to create record reader: %w", err)
-			}
-			defer reader.Release()
-
-			// Convert Arrow records to map format
-			for reader.Next() {
-				record := reader.Record()
-				if record != nil {
-					results = append(results, d.recordToMaps(record)...)
-					record.Release()
-				}
-			}
-
-			if reader.Err() != nil {
-				return fmt.Errorf("error reading results: %w", reader.Err())
-			}
-
-			return nil
+			jobID, queryErr = fetchRecords(authCtx, client, desc, fn)
+			return errors.Join(queryErr, restore())
 		})
 
-		if err != nil {
-			return nil, err
+		// The pool closed a connection it could not reset; the query itself
+		// succeeded
+		if queryErr == nil && errors.Is(err, errSessionNotReset) {
+			d.logger.Warn("Closed Flight connection whose session options could not be reset", zap.Error(err))
+			err = nil
 		}
-	} else {
-		// Use single connection (original code)
-		info, err := d.client.GetFlightInfo(d.ctx, desc)
 		if err != nil {
-			return nil, fmt.Errorf("failed to get flight info: %w", err)
+			return "", stripAnnotation(ClassifyDremioError(err), comment)
 		}
+		return jobID, nil
+	}
 
-		// Check if we have endpoints
-		if len(info.GetEndpoint()) == 0 {
-			return nil, fmt.Errorf("no endpoints returned")
-		}
+	// Session options would leak into concurrent queries on a shared client
+	if len(options) > 0 {
+		return "", fmt.Errorf("engine options need a pooled Flight connection")
+	}
+	if len(set) > 0 {
+		return "", fmt.Errorf("reading %s %s needs a pooled Flight connection", strings.ToLower(version.Type), version.Name)
+	}
 
-		// Fetch results from the first endpoint
-		endpoint := info.GetEndpoint()[0]
-		stream, err := d.client.DoGet(d.ctx, endpoint.GetTicket())
-		if err != nil {
-			return nil, fmt.Errorf("failed to get data stream: %w", err)
-		}
+	// Use single connection (original code)
+	inflight.FromContext(ctx).SetPhase(inflight.PhaseExecuting)
+	authCtx := d.getAuthContext(d.ctx)
+	info, err := d.client.GetFlightInfo(authCtx, desc)
+	if err != nil {
+		return "", stripAnnotation(ClassifyDremioError(fmt.Errorf("failed to get flight info: %w", err)), comment)
+	}
+	jobID = flightJobID(info, comment+query)
 
-		// Create record reader from stream
-		reader, err := flight.NewRecordReader(stream)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create record reader: %w", err)
-		}
-		defer reader.Release()
-
-		// Convert Arrow records to map format
-		for reader.Next() {
-			record := reader.Record()
-			if record != nil {
-				results = append(results, d.recordToMaps(record)...)
-				record.Release()
-			}
-		}
+	// Check if we have endpoints
+	if len(info.GetEndpoint()) == 0 {
+		return "", fmt.Errorf("no endpoints returned")
+	}
+
+	// Fetch results from the first endpoint
+	endpoint := info.GetEndpoint()[0]
+	stream, err := d.client.DoGet(authCtx, endpoint.GetTicket())
+	if err != nil {
+		return "", stripAnnotation(ClassifyDremioError(fmt.Errorf("failed to get data stream: %w", err)), comment)
+	}
 
-		if reader.Err() != nil {
-			return nil, fmt.Errorf("error reading results: %w", reader.Err())
+	// Create record reader from stream
+	reader, err := flight.NewRecordReader(stream)
+	if err != nil {
+		return "", fmt.Errorf("failed to create record reader: %w", err)
+	}
+	defer reader.Release()
+
+	for reader.Next() {
+		if record := reader.Record(); record != nil {
+			fn(record)
+			record.Release()
 		}
 	}
 
-	queryTime := time.Since(start)
-	d.logger.Info("Query completed",
-		zap.Duration("duration", queryTime),
-		zap.Int("rows", len(results)))
+	if reader.Err() != nil {
+		return "", fmt.Errorf("error reading results: %w", reader.Err())
+	}
+	return jobID, nil
+}
 
-	result := &QueryResult{
-		Data:      results,
-		Count:     len(results),
-		Source:    DataSourceDremio,
-		QueryTime: queryTime,
+// fetchRecords runs desc on client and calls fn for every record of its
+// first endpoint; it returns the Dremio job id when the FlightInfo carries one
+func fetchRecords(ctx context.Context, client flight.Client, desc *pb.FlightDescriptor, fn func(arrow.Record)) (string, error) {
+	// Get flight info for the query
+	info, err := client.GetFlightInfo(ctx, desc)
+	if err != nil {
+		return "", fmt.Errorf("failed to get flight info: %w", err)
 	}
+	jobID := flightJobID(info, string(desc.Cmd))
 
-	// Cache the results
-	if opts != nil && opts.CacheTTL > 0 {
-		d.cache.Set(cacheKey, result, opts.CacheTTL)
-	} else {
-		d.cache.Set(cacheKey, result, cache.DefaultExpiration)
+	// Check if we have endpoints
+	if len(info.GetEndpoint()) == 0 {
+		return jobID, fmt.Errorf("no endpoints returned")
 	}
 
-	return result, nil
-}
+	// Fetch results from the first endpoint
+	endpoint := info.GetEndpoint()[0]
+	stream, err := client.DoGet(ctx, endpoint.GetTicket())
+	if err != nil {
+		return jobID, fmt.Errorf("failed to get data stream: %w", err)
+	}
 
-// recordToMaps converts Arrow Record to slice of maps
-func (d *DremioArrowClient) recordToMaps(record arrow.Record) []map[string]interface{} {
-	var results []map[string]interface{}
-	numRows := int(record.NumRows())
-	schema := record.Schema()
-
-	for row := 0; row < numRows; row++ {
-		rowMap := make(map[string]interface{})
-		for col := 0; col < int(record.NumCols()); col++ {
-			field := schema.Field(col)
-			column := record.Column(col)
-			rowMap[field.Name] = d.getValueAt(column, row)
+	// Create record reader from stream
+	reader, err := flight.NewRecordReader(stream)
+	if err != nil {
+		return jobID, fmt.Errorf("failed to create record reader: %w", err)
+	}
+	defer reader.Release()
+
+	for reader.Next() {
+		if record := reader.Record(); record != nil {
+			fn(record)
+			record.Release()
 		}
-		results = append(results, rowMap)
 	}
 
-	return results
+	if reader.Err() != nil {
+		return jobID, fmt.Errorf("error reading results: %w", reader.Err())
+	}
+	return jobID, nil
 }
 
-// getValueAt extracts value from Arrow column at specific row
-func (d *DremioArrowClient) getValueAt(column arrow.Array, row int) interface{} {
-	if column.IsNull(row) {
+// sessionResetTimeout bounds resetting session options after a query, which
+// still runs when the query's request has ended
+const sessionResetTimeout = 10 * time.Second
+
+// applySession runs the set statements on client and returns a function that
+// runs the reset statements. When a statement fails, those already set are
+// reset again. A failed reset wraps errSessionNotReset, so the pool closes
+// the connection instead of handing its options to the next query.
+func applySession(ctx context.Context, client flight.Client, set, reset []string) (func() error, error) {
+	restore := func(n int) error {
+		resetCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sessionResetTimeout)
+		defer cancel()
+
+		var errs []error
+		for _, statement := range reset[:n] {
+			if _, err := fetchRecords(resetCtx, client, &pb.FlightDescriptor{Type: pb.FlightDescriptor_CMD, Cmd: []byte(statement)}, func(arrow.Record) {}); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if err := errors.Join(errs...); err != nil {
+			return fmt.Errorf("%w: %w", errSessionNotReset, err)
+		}
 		return nil
 	}
 
-	switch col := column.(type) {
-	case *array.Int64:
-		return col.Value(row)
-	case *array.Float64:
-		return col.Value(row)
-	case *array.String:
-		return col.Value(row)
-	case *array.Boolean:
-		return col.Value(row)
-	case *array.Date32:
-		// Convert days since epoch to time
-		days := col.Value(row)
-		return time.Unix(int64(days)*86400, 0)
-	case *array.Timestamp:
-		return col.Value(row).ToTime(col.DataType().(*arrow.TimestampType).Unit)
-	default:
-		// Return string representation for other types
-		return col.ValueStr(row)
+	for i, statement := range set {
+		if _, err := fetchRecords(ctx, client, &pb.FlightDescriptor{Type: pb.FlightDescriptor_CMD, Cmd: []byte(statement)}, func(arrow.Record) {}); err != nil {
+			return nil, errors.Join(fmt.Errorf("failed to set session option: %w", err), restore(i))
+		}
 	}
+	return func() error { return restore(len(reset)) }, nil
 }
 
 // GetData retrieves data from a specific table
 func (d *DremioArrowClient) GetData(ctx context.Context, table string, opts *QueryOptions) (*QueryResult, error) {
+	query, err := d.tableQuery(table, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// The page is already part of the table query
+	return d.execute(ctx, query, opts)
+}
+
+// tableQuery builds the query of a GetData
+func (d *DremioArrowClient) tableQuery(table string, opts *QueryOptions) (string, error) {
 	// Build query with optional project/space prefix
 	if d.config.Project != "" && !strings.Contains(table, ".") {
 		table = d.config.Project + "." + table
 	}
 
 	// Sanitize inputs to prevent SQL injection
-	sanitizer := NewSQLSanitizer()
-	query, err := sanitizer.BuildSafeTableQuery(table, opts)
+	query, err := newTableSanitizer().BuildSafeTableQuery(table, opts)
 	if err != nil {
-		return nil, fmt.Errorf("query validation failed: %w", err)
+		return "", fmt.Errorf("%w: %w", ErrInvalidTableQuery, err)
 	}
+	return query, nil
+}
 
-	return d.ExecuteQuery(ctx, query, opts)
+// PlanQuery reports the paged query ExecuteQuery would run (implements Planner)
+func (d *DremioArrowClient) PlanQuery(ctx context.Context, query string, opts *QueryOptions) (*QueryPlan, error) {
+	paged, _ := pageQuery(query, opts)
+	return &QueryPlan{SQL: paged}, nil
+}
+
+// PlanTable reports the query GetData would run (implements Planner)
+func (d *DremioArrowClient) PlanTable(ctx context.Context, table string, opts *QueryOptions) (*QueryPlan, error) {
+	query, err := d.tableQuery(table, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &QueryPlan{SQL: query}, nil
 }
 
 // TestConnection tests the connection to Dremio
@@ -333,18 +478,45 @@ func (d *DremioArrowClient) GetType() DataSourceType {
 	return DataSourceDremio
 }
 
-// getAuthContext adds authentication headers to context
+// Capacity is the pool size when pooled; a single Flight client advertises no
+// limit
+func (d *DremioArrowClient) Capacity(ctx context.Context) int {
+	if d.usePool && d.pool != nil {
+		return d.pool.Capacity()
+	}
+	return 0
+}
+
+// getAuthContext adds the current credentials, if any, to context
 func (d *DremioArrowClient) getAuthContext(ctx context.Context) context.Context {
-	if d.config.Username != "" && d.config.Password != "" {
-		return metadata.AppendToOutgoingContext(ctx,
-			"authorization", "Basic "+basicAuth(d.config.Username, d.config.Password),
-		)
-	} else if d.config.Token != "" {
-		return metadata.AppendToOutgoingContext(ctx,
-			"authorization", "Bearer "+d.config.Token,
-		)
-	}
-	return ctx
+	if d.config.Credentials == nil {
+		return ctx
+	}
+	creds, _ := d.config.Credentials.Get()
+	if creds == (clients.DremioCredentials{}) {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, "authorization", authorization(creds))
+}
+
+// RotateCredentials replaces the credentials queries authenticate with,
+// without failing the queries running: see
+// ArrowConnectionPool.RotateCredentials. Creds Dremio refuses change nothing.
+func (d *DremioArrowClient) RotateCredentials(ctx context.Context, account *clients.Credentials, creds clients.DremioCredentials) error {
+	if account != nil && account != d.config.Credentials {
+		return ErrNoCredentials
+	}
+	if err := creds.Validate(); err != nil {
+		return err
+	}
+	if d.usePool && d.pool != nil {
+		return d.pool.RotateCredentials(ctx, creds)
+	}
+	if err := checkAuth(ctx, d.client, authorization(creds)); err != nil {
+		return fmt.Errorf("failed to authenticate: %w", err)
+	}
+	d.config.Credentials.Set(creds)
+	return nil
 }
 
 // Close closes the Arrow Flight client or connection pool
@@ -368,9 +540,16 @@ func (d *DremioArrowClient) GetPoolMetrics() map[string]interface{} {
 	}
 }
 
+// ResetPoolLatency drops the acquire waits recorded by the pool
+func (d *DremioArrowClient) ResetPoolLatency() {
+	if d.usePool && d.pool != nil {
+		d.pool.ResetLatency()
+	}
+}
+
 // isReadOnlySQL validates that a SQL query is read-only
 func isReadOnlySQL(sql string) bool {
-	sql = strings.ToUpper(strings.TrimSpace(sql))
+	sql = strings.ToUpper(sqltext.Normalize(sql, sqltext.Standard))
 	forbidden := []string{"INSERT", "UPDATE", "DELETE", "DROP", "CREATE", "ALTER", "TRUNCATE", "MERGE"}
 
 	for _, keyword := range forbidden {
@@ -0,0 +1,240 @@
+package datasource
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go-data-gateway/internal/clients"
+	"go-data-gateway/internal/metrics"
+)
+
+// MetaTransport is set in the Metadata of a QueryResult that was not read
+// over the source's own transport
+const MetaTransport = "transport"
+
+// TransportRESTFallback marks a result read over Dremio's REST API after
+// Arrow Flight could not be reached
+const TransportRESTFallback = "rest_fallback"
+
+// defaultFallbackBackoff is the wait before the first Arrow Flight retry; it
+// doubles with each further retry
+const defaultFallbackBackoff = 200 * time.Millisecond
+
+// FallbackConfig describes how an Arrow Flight source falls back to REST
+type FallbackConfig struct {
+	Source  string        // Name of the source, for logs and metrics
+	Retries int           // Arrow Flight retries before falling back
+	Backoff time.Duration // Wait before the first retry; 0 uses 200ms
+}
+
+// IsConnectionError reports whether err means Dremio could not be reached,
+// as opposed to a query it rejected. Errors classified as an *UpstreamError,
+// cancellations and an exhausted pool are not connection errors.
+func IsConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var upstreamErr *UpstreamError
+	if errors.As(err, &upstreamErr) ||
+		errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, ErrPoolExhausted) {
+		return false
+	}
+	if st, ok := status.FromError(err); ok {
+		switch st.Code() {
+		case codes.Unavailable, codes.DeadlineExceeded:
+			return true
+		}
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// DremioFallbackSource serves queries over Arrow Flight and, when Flight
+// cannot be reached after cfg.Retries retries, runs them again over the REST
+// API within the caller's deadline. Results read over REST are tagged with
+// MetaTransport so callers can tell. Queries Dremio rejected are never
+// retried.
+type DremioFallbackSource struct {
+	primary DataSource
+	newREST func() (DataSource, error) // Called on the first fallback
+	cfg     FallbackConfig
+	metrics *metrics.FallbackCounter
+	logger  *zap.Logger
+
+	mu   sync.Mutex
+	rest DataSource
+}
+
+// NewDremioFallbackSource falls back from primary, an Arrow Flight source,
+// to the REST source newREST connects. The REST client is only connected
+// once a query needs it.
+func NewDremioFallbackSource(primary DataSource, newREST func() (DataSource, error), cfg FallbackConfig, counter *metrics.FallbackCounter, logger *zap.Logger) *DremioFallbackSource {
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = defaultFallbackBackoff
+	}
+	return &DremioFallbackSource{
+		primary: primary,
+		newREST: newREST,
+		cfg:     cfg,
+		metrics: counter,
+		logger:  logger,
+	}
+}
+
+// ExecuteQuery runs the query over Arrow Flight, or over REST when Flight
+// cannot be reached
+func (f *DremioFallbackSource) ExecuteQuery(ctx context.Context, query string, opts *QueryOptions) (*QueryResult, error) {
+	return f.run(ctx, query, func(source DataSource) (*QueryResult, error) {
+		return source.ExecuteQuery(ctx, query, opts)
+	})
+}
+
+// GetData reads the table over Arrow Flight, or over REST when Flight cannot
+// be reached
+func (f *DremioFallbackSource) GetData(ctx context.Context, table string, opts *QueryOptions) (*QueryResult, error) {
+	return f.run(ctx, table, func(source DataSource) (*QueryResult, error) {
+		return source.GetData(ctx, table, opts)
+	})
+}
+
+// run calls fetch on the primary, retrying connection errors with a backoff,
+// and then once on the REST source unless ctx is done
+func (f *DremioFallbackSource) run(ctx context.Context, query string, fetch func(DataSource) (*QueryResult, error)) (*QueryResult, error) {
+	result, err := fetch(f.primary)
+	backoff := f.cfg.Backoff
+	for attempt := 0; attempt < f.cfg.Retries && IsConnectionError(err); attempt++ {
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		result, err = fetch(f.primary)
+	}
+	if !IsConnectionError(err) || ctx.Err() != nil {
+		return result, err
+	}
+
+	fields := []zap.Field{zap.String("source", f.cfg.Source), zap.String("sql", query), zap.NamedError("flight_error", err)}
+	rest, restErr := f.restSource()
+	if restErr != nil {
+		f.metrics.Record(f.cfg.Source, metrics.FallbackError)
+		f.logger.Warn("Dremio REST fallback unavailable", append(fields, zap.Error(restErr))...)
+		return nil, err
+	}
+	result, restErr = fetch(rest)
+	if restErr != nil {
+		f.metrics.Record(f.cfg.Source, metrics.FallbackError)
+		f.logger.Warn("Dremio REST fallback failed", append(fields, zap.Error(restErr))...)
+		return nil, restErr
+	}
+
+	f.metrics.Record(f.cfg.Source, metrics.FallbackSuccess)
+	f.logger.Warn("Arrow Flight unreachable, query served over Dremio REST", fields...)
+	if result.Metadata == nil {
+		result.Metadata = make(map[string]interface{})
+	}
+	result.Metadata[MetaTransport] = TransportRESTFallback
+	return result, nil
+}
+
+// restSource returns the REST source, connecting it on first use. A failed
+// connection is retried by the next fallback.
+func (f *DremioFallbackSource) restSource() (DataSource, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.rest == nil {
+		rest, err := f.newREST()
+		if err != nil {
+			return nil, err
+		}
+		f.rest = rest
+	}
+	return f.rest, nil
+}
+
+// ValidateQuery validates over Arrow Flight
+func (f *DremioFallbackSource) ValidateQuery(ctx context.Context, query string) error {
+	return ValidateQuery(ctx, f.primary, query)
+}
+
+// DescribeTable describes the table over Arrow Flight
+func (f *DremioFallbackSource) DescribeTable(ctx context.Context, table string) ([]ColumnField, error) {
+	return DescribeTable(ctx, f.primary, table)
+}
+
+// PlanQuery plans the query over Arrow Flight
+func (f *DremioFallbackSource) PlanQuery(ctx context.Context, query string, opts *QueryOptions) (*QueryPlan, error) {
+	return PlanQuery(ctx, f.primary, query, opts)
+}
+
+// PlanTable plans the table read over Arrow Flight
+func (f *DremioFallbackSource) PlanTable(ctx context.Context, table string, opts *QueryOptions) (*QueryPlan, error) {
+	return PlanTable(ctx, f.primary, table, opts)
+}
+
+// TestConnection checks Arrow Flight, so health reports show when queries
+// are being served by the fallback
+func (f *DremioFallbackSource) TestConnection(ctx context.Context) error {
+	return f.primary.TestConnection(ctx)
+}
+
+// DeepCheck runs the Arrow Flight source's query-based check
+func (f *DremioFallbackSource) DeepCheck(ctx context.Context) error {
+	return DeepCheck(ctx, f.primary)
+}
+
+// Capacity returns the Arrow Flight source's concurrency hint
+func (f *DremioFallbackSource) Capacity(ctx context.Context) int {
+	return Capacity(ctx, f.primary)
+}
+
+// GetPoolMetrics returns the metrics of the Arrow Flight pool
+func (f *DremioFallbackSource) GetPoolMetrics() map[string]interface{} {
+	return PoolMetrics(f.primary)
+}
+
+// ResetPoolLatency resets the Arrow Flight pool
+func (f *DremioFallbackSource) ResetPoolLatency() {
+	ResetPoolLatency(f.primary)
+}
+
+// RotateCredentials rotates the credentials of both transports
+func (f *DremioFallbackSource) RotateCredentials(ctx context.Context, account *clients.Credentials, creds clients.DremioCredentials) error {
+	if err := rotateWrapped(ctx, f.primary, account, creds); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	rest := f.rest
+	f.mu.Unlock()
+	if rest == nil {
+		return nil
+	}
+	return rotateWrapped(ctx, rest, account, creds)
+}
+
+// GetType returns the Arrow Flight source's type
+func (f *DremioFallbackSource) GetType() DataSourceType {
+	return f.primary.GetType()
+}
+
+// Close closes both transports
+func (f *DremioFallbackSource) Close() error {
+	err := f.primary.Close()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.rest != nil {
+		err = errors.Join(err, f.rest.Close())
+	}
+	return err
+}
@@ -0,0 +1,154 @@
+package datasource
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow/flight"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/metrics"
+)
+
+// newFailingFlight returns an Arrow Flight client of a server that fails
+// every query with err
+func newFailingFlight(t *testing.T, err error) *DremioArrowClient {
+	server := flight.NewServerWithMiddleware(nil)
+	require.NoError(t, server.Init("127.0.0.1:0"))
+	server.RegisterFlightService(&fakeFlightServer{err: err})
+	go server.Serve()
+	t.Cleanup(server.Shutdown)
+
+	host, port, splitErr := net.SplitHostPort(server.Addr().String())
+	require.NoError(t, splitErr)
+	portNum, _ := strconv.Atoi(port)
+	client, clientErr := NewDremioArrowClient(&DremioConfig{Host: host, Port: portNum}, zap.NewNop())
+	require.NoError(t, clientErr)
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func newTestFallback(primary, rest DataSource, retries int, counter *metrics.FallbackCounter) *DremioFallbackSource {
+	return NewDremioFallbackSource(primary, func() (DataSource, error) { return rest, nil },
+		FallbackConfig{Source: "DATAWAREHOUSE", Retries: retries, Backoff: time.Millisecond},
+		counter, zap.NewNop())
+}
+
+func TestDremioFallback_ServesOverRESTWhenFlightIsUnreachable(t *testing.T) {
+	flightClient := newFailingFlight(t, status.Error(codes.Unavailable, "connection refused"))
+	rest, dremio := newRESTFake(3)
+	counter := metrics.NewFallbackCounter()
+	source := newTestFallback(flightClient, rest, 1, counter)
+
+	result, err := source.ExecuteQuery(context.Background(), "SELECT id FROM t", nil)
+	require.NoError(t, err)
+	assert.Len(t, result.Data, 3)
+	assert.Equal(t, TransportRESTFallback, result.Metadata[MetaTransport])
+	assert.Len(t, dremio.queries, 1)
+
+	result, err = source.GetData(context.Background(), "t", nil)
+	require.NoError(t, err)
+	assert.Len(t, dremio.queries, 2)
+	assert.Equal(t, TransportRESTFallback, result.Metadata[MetaTransport])
+
+	var buf bytes.Buffer
+	counter.WritePrometheus(&buf)
+	assert.Contains(t, buf.String(), `go_gateway_dremio_rest_fallbacks_total{source="DATAWAREHOUSE",outcome="success"} 2`)
+}
+
+func TestDremioFallback_RetriesFlightBeforeFallingBack(t *testing.T) {
+	primary := &countingSource{err: fmt.Errorf("failed to get connection from pool: %w", status.Error(codes.Unavailable, "dial tcp: connection refused"))}
+	rest, dremio := newRESTFake(1)
+	source := newTestFallback(primary, rest, 2, nil)
+
+	_, err := source.ExecuteQuery(context.Background(), "SELECT 1", nil)
+	require.NoError(t, err)
+	assert.Len(t, primary.queries, 3, "the first attempt and two retries")
+	assert.Len(t, dremio.queries, 1)
+}
+
+func TestDremioFallback_QueryErrorsDoNotFallBack(t *testing.T) {
+	flightClient := newFailingFlight(t, status.Error(codes.InvalidArgument, "Encountered \"FORM\" at line 1, column 10."))
+	rest, dremio := newRESTFake(1)
+	counter := metrics.NewFallbackCounter()
+	source := newTestFallback(flightClient, rest, 1, counter)
+
+	_, err := source.ExecuteQuery(context.Background(), "SELECT * FORM t", nil)
+	var upstreamErr *UpstreamError
+	require.ErrorAs(t, err, &upstreamErr)
+	assert.Equal(t, ErrorClassSyntax, upstreamErr.Class)
+	assert.Empty(t, dremio.queries)
+
+	var buf bytes.Buffer
+	counter.WritePrometheus(&buf)
+	assert.NotContains(t, buf.String(), "outcome=")
+}
+
+func TestDremioFallback_RespectsTheCallersDeadline(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "connection refused")
+	primary := &countingSource{err: unavailable}
+	rest, dremio := newRESTFake(1)
+	source := newTestFallback(primary, rest, 1, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := source.ExecuteQuery(ctx, "SELECT 1", nil)
+	assert.ErrorIs(t, err, unavailable)
+	assert.Empty(t, dremio.queries)
+}
+
+func TestDremioFallback_KeepsTheFlightErrorWhenRESTCannotConnect(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "connection refused")
+	counter := metrics.NewFallbackCounter()
+	source := NewDremioFallbackSource(&countingSource{err: unavailable},
+		func() (DataSource, error) { return nil, errors.New("rest login failed") },
+		FallbackConfig{Source: "DATAWAREHOUSE"}, counter, zap.NewNop())
+
+	_, err := source.ExecuteQuery(context.Background(), "SELECT 1", nil)
+	assert.ErrorIs(t, err, unavailable)
+
+	var buf bytes.Buffer
+	counter.WritePrometheus(&buf)
+	assert.Contains(t, buf.String(), `go_gateway_dremio_rest_fallbacks_total{source="DATAWAREHOUSE",outcome="error"} 1`)
+}
+
+func TestIsConnectionError(t *testing.T) {
+	assert.True(t, IsConnectionError(fmt.Errorf("query: %w", status.Error(codes.Unavailable, "connection refused"))))
+	assert.True(t, IsConnectionError(status.Error(codes.DeadlineExceeded, "dial timeout")))
+	assert.True(t, IsConnectionError(&net.OpError{Op: "dial", Err: errors.New("connection refused")}))
+
+	assert.False(t, IsConnectionError(nil))
+	assert.False(t, IsConnectionError(status.Error(codes.PermissionDenied, "no access")))
+	assert.False(t, IsConnectionError(newUpstreamError(ErrorClassSyntax, DataSourceDremio, "syntax", status.Error(codes.Unavailable, "x"))))
+	assert.False(t, IsConnectionError(context.DeadlineExceeded))
+	assert.False(t, IsConnectionError(ErrPoolExhausted))
+}
+
+func TestNewDremioArrowSource_RESTFallbackIsOptIn(t *testing.T) {
+	flightClient := newFailingFlight(t, status.Error(codes.Unavailable, "connection refused"))
+	declared := config.DataSourceConfig{Name: "DATAWAREHOUSE", Type: config.SourceTypeDremioArrow, Settings: map[string]string{
+		"host": flightClient.config.Host,
+		"port": strconv.Itoa(flightClient.config.Port),
+	}}
+
+	source, err := newDremioArrowSource(declared, Dependencies{Logger: zap.NewNop()})
+	require.NoError(t, err)
+	defer source.Close()
+	assert.IsType(t, &DremioArrowClient{}, source)
+
+	source, err = newDremioArrowSource(declared.WithSetting("rest_fallback", "true"), Dependencies{Logger: zap.NewNop()})
+	require.NoError(t, err)
+	defer source.Close()
+	assert.IsType(t, &DremioFallbackSource{}, source)
+}
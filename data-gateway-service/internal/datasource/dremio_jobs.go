@@ -0,0 +1,78 @@
+package datasource
+
+import (
+	"bytes"
+	"context"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow/flight"
+)
+
+// Metadata keys identifying the Dremio job behind a QueryResult
+const (
+	MetaDremioJobID      = "dremio_job_id"
+	MetaDremioProfileURL = "dremio_profile_url"
+)
+
+// JobLookup finds the Dremio job that ran sql, submitted at or after since.
+// It returns "" when no such job is known.
+type JobLookup interface {
+	FindJob(ctx context.Context, sql string, since time.Time) (string, error)
+}
+
+// dremioJobIDPattern matches Dremio job ids, which are UUID-formatted
+var dremioJobIDPattern = regexp.MustCompile(`[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+
+// flightJobID returns the job id Dremio put in info's app metadata or in the
+// ticket of its first endpoint, or "" when there is none. Ids that appear in
+// the SQL itself are literals of the query, not the job.
+func flightJobID(info *flight.FlightInfo, sql string) string {
+	candidates := [][]byte{info.GetAppMetadata()}
+	if endpoints := info.GetEndpoint(); len(endpoints) > 0 {
+		candidates = append(candidates,
+			endpoints[0].GetAppMetadata(),
+			endpoints[0].GetTicket().GetTicket())
+	}
+
+	for _, candidate := range candidates {
+		for _, match := range dremioJobIDPattern.FindAll(bytes.ToLower(candidate), -1) {
+			if id := string(match); !strings.Contains(strings.ToLower(sql), id) {
+				return id
+			}
+		}
+	}
+	return ""
+}
+
+// DremioProfileURL links to the profile of a job in the Dremio UI at base
+func DremioProfileURL(base, jobID string) string {
+	return strings.TrimRight(base, "/") + "/jobs/job/" + url.PathEscape(jobID)
+}
+
+// setDremioJob records the job that produced result in its metadata
+func setDremioJob(result *QueryResult, uiURL, jobID string) {
+	if jobID == "" {
+		return
+	}
+	if result.Metadata == nil {
+		result.Metadata = make(map[string]interface{})
+	}
+	result.Metadata[MetaDremioJobID] = jobID
+	if uiURL != "" {
+		result.Metadata[MetaDremioProfileURL] = DremioProfileURL(uiURL, jobID)
+	}
+}
+
+// DremioJob returns the job id and profile link recorded in result's
+// metadata, if any
+func DremioJob(result *QueryResult) (jobID, profileURL string) {
+	if result == nil {
+		return "", ""
+	}
+	jobID, _ = result.Metadata[MetaDremioJobID].(string)
+	profileURL, _ = result.Metadata[MetaDremioProfileURL].(string)
+	return jobID, profileURL
+}
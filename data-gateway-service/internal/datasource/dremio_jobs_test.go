@@ -0,0 +1,61 @@
+package datasource
+
+import (
+	"testing"
+
+	"github.com/apache/arrow-go/v18/arrow/flight"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlightJobID(t *testing.T) {
+	const jobID = "1f0c8e2a-56d4-7b19-3e00-a1b2c3d4e5f6"
+
+	tests := []struct {
+		name string
+		info *flight.FlightInfo
+		sql  string
+		want string
+	}{
+		{
+			name: "app metadata",
+			info: &flight.FlightInfo{AppMetadata: []byte(`{"job_id":"` + jobID + `"}`)},
+			want: jobID,
+		},
+		{
+			name: "ticket",
+			info: &flight.FlightInfo{Endpoint: []*flight.FlightEndpoint{{
+				Ticket: &flight.Ticket{Ticket: append([]byte{0x0a, 0x24}, "1F0C8E2A-56D4-7B19-3E00-A1B2C3D4E5F6"...)},
+			}}},
+			want: jobID,
+		},
+		{
+			name: "id in the query is not the job",
+			info: &flight.FlightInfo{Endpoint: []*flight.FlightEndpoint{{
+				Ticket: &flight.Ticket{Ticket: []byte("SELECT * FROM t WHERE id = '" + jobID + "'")},
+			}}},
+			sql:  "SELECT * FROM t WHERE id = '" + jobID + "'",
+			want: "",
+		},
+		{
+			name: "no endpoints",
+			info: &flight.FlightInfo{},
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, flightJobID(tt.info, tt.sql))
+		})
+	}
+}
+
+func TestSetDremioJob(t *testing.T) {
+	result := &QueryResult{}
+	setDremioJob(result, "", "")
+	assert.Nil(t, result.Metadata)
+
+	setDremioJob(result, "https://dremio.example.com/", "1f0c8e2a-56d4-7b19-3e00-a1b2c3d4e5f6")
+	jobID, profileURL := DremioJob(result)
+	assert.Equal(t, "1f0c8e2a-56d4-7b19-3e00-a1b2c3d4e5f6", jobID)
+	assert.Equal(t, "https://dremio.example.com/jobs/job/1f0c8e2a-56d4-7b19-3e00-a1b2c3d4e5f6", profileURL)
+}
@@ -7,43 +7,108 @@ import (
 
 	"go-data-gateway/internal/clients"
 	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/inflight"
+	"go-data-gateway/internal/metrics"
 	"go.uber.org/zap"
 )
 
+// restClient is the part of clients.DremioClient the wrapper uses
+type restClient interface {
+	ExecuteAnnotatedQuery(ctx context.Context, query, comment string) (interface{}, error)
+	ExecuteQueryWithOptions(ctx context.Context, query, comment string, options clients.JobOptions) (interface{}, error)
+	TestConnection(ctx context.Context) error
+	TestQuery(ctx context.Context) error
+}
+
 // DremioRESTWrapper wraps the original DremioClient to implement DataSource interface
 type DremioRESTWrapper struct {
-	client *clients.DremioClient
+	client restClient
+	uiURL  string // Base of job profile links; the UI is served on the REST port
 	logger *zap.Logger
 }
 
 // NewDremioRESTClient creates a new Dremio REST client that implements DataSource
 func NewDremioRESTClient(host string, port int, username, password string, logger *zap.Logger) (DataSource, error) {
-	// Create config for the original client
-	cfg := config.DremioConfig{
-		Host:     host,
-		Port:     port,
+	return NewDremioRESTClientWithCredentials(host, port, clients.NewCredentials(clients.DremioCredentials{
 		Username: username,
 		Password: password,
-	}
+	}), logger)
+}
 
+// NewDremioRESTClientWithCredentials creates a Dremio REST data source
+// authenticating with creds, which it may share with other clients
+func NewDremioRESTClientWithCredentials(host string, port int, creds *clients.Credentials, logger *zap.Logger) (DataSource, error) {
 	// Create the original client
-	dremioClient, err := clients.NewDremioClient(cfg, logger)
+	cfg := config.DremioConfig{Host: host, Port: port}
+	dremioClient, err := clients.NewDremioClientWithCredentials(cfg, creds, logger)
 	if err != nil {
 		return nil, err
 	}
 
 	return &DremioRESTWrapper{
 		client: dremioClient,
+		uiURL:  fmt.Sprintf("http://%s:%d", host, port),
 		logger: logger,
 	}, nil
 }
 
-// ExecuteQuery executes a SQL query
+// SetJobCancels counts the jobs cancelled because their request ended in
+// counter
+func (d *DremioRESTWrapper) SetJobCancels(counter *metrics.CancelCounter) {
+	if client, ok := d.client.(*clients.DremioClient); ok {
+		client.SetJobCancels(counter)
+	}
+}
+
+// RotateCredentials logs in with creds and makes them the client's
+// credentials
+func (d *DremioRESTWrapper) RotateCredentials(ctx context.Context, account *clients.Credentials, creds clients.DremioCredentials) error {
+	client, ok := d.client.(*clients.DremioClient)
+	if !ok || (account != nil && client.Credentials() != account) {
+		return ErrNoCredentials
+	}
+	return client.RotateCredentials(ctx, creds)
+}
+
+// ExecuteQuery executes a SQL query. With opts.Limit set, the query is
+// wrapped to return that page of its rows, as on Arrow Flight.
 func (d *DremioRESTWrapper) ExecuteQuery(ctx context.Context, query string, opts *QueryOptions) (*QueryResult, error) {
-	// Call the original client's ExecuteQuery with context
-	result, err := d.client.ExecuteQuery(ctx, query)
+	query, paged := pageQuery(query, opts)
+	var options EngineOptions
+	if opts != nil {
+		options = opts.EngineOptions
+	}
+	result, err := d.execute(ctx, query, options)
+	if err != nil && paged {
+		return nil, shiftPositionLines(err, 1)
+	}
+	return result, err
+}
+
+// execute runs query as is, with options and the request's Nessie reference
+// sent along with the job
+func (d *DremioRESTWrapper) execute(ctx context.Context, query string, options EngineOptions) (*QueryResult, error) {
+	start := time.Now()
+
+	// Call the original client's ExecuteQuery with context; attribution is
+	// prepended for Dremio's job history
+	comment := attributionComment(ctx)
+	inflight.FromContext(ctx).SetPhase(inflight.PhaseExecuting)
+	var (
+		result interface{}
+		err    error
+	)
+	version, _ := VersionFromContext(ctx)
+	if references := version.references(); len(options) > 0 || len(references) > 0 {
+		result, err = d.client.ExecuteQueryWithOptions(ctx, query, comment, clients.JobOptions{
+			SessionOptions: options,
+			References:     references,
+		})
+	} else {
+		result, err = d.client.ExecuteAnnotatedQuery(ctx, query, comment)
+	}
 	if err != nil {
-		return nil, err
+		return nil, stripAnnotation(ClassifyDremioError(err), comment)
 	}
 
 	// Type assert the result to access fields
@@ -60,40 +125,70 @@ func (d *DremioRESTWrapper) ExecuteQuery(ctx context.Context, query string, opts
 	}
 
 	// Convert to our QueryResult format
-	return &QueryResult{
+	queryResult := &QueryResult{
 		Data:      data,
 		Count:     len(data),
 		Source:    DataSourceDremio,
-		QueryTime: time.Second, // This is approximate - we don't have exact timing
+		QueryTime: time.Since(start),
 		CacheHit:  false,
-	}, nil
+	}
+	jobID, _ := resultMap["job_id"].(string)
+	setDremioJob(queryResult, d.uiURL, jobID)
+	return queryResult, nil
 }
 
 // GetData retrieves data from a specific table
 func (d *DremioRESTWrapper) GetData(ctx context.Context, table string, opts *QueryOptions) (*QueryResult, error) {
-	query := fmt.Sprintf("SELECT * FROM %s", table)
+	query, err := d.tableQuery(table, opts)
+	if err != nil {
+		return nil, err
+	}
 
-	if opts != nil {
-		if opts.OrderBy != "" {
-			query += fmt.Sprintf(" ORDER BY %s %s", opts.OrderBy, opts.OrderDir)
-		}
-		if opts.Limit > 0 {
-			query += fmt.Sprintf(" LIMIT %d", opts.Limit)
-			if opts.Offset > 0 {
-				query += fmt.Sprintf(" OFFSET %d", opts.Offset)
-			}
-		}
-	} else {
-		query += " LIMIT 100"
+	// The page is already part of the table query
+	return d.execute(ctx, query, nil)
+}
+
+// tableQuery builds the query of a GetData, 100 rows without options
+func (d *DremioRESTWrapper) tableQuery(table string, opts *QueryOptions) (string, error) {
+	if opts == nil {
+		opts = &QueryOptions{Limit: 100}
+	}
+
+	// Sanitize inputs to prevent SQL injection
+	query, err := newTableSanitizer().BuildSafeTableQuery(table, opts)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidTableQuery, err)
 	}
+	return query, nil
+}
+
+// PlanQuery reports the paged query ExecuteQuery would run (implements Planner)
+func (d *DremioRESTWrapper) PlanQuery(ctx context.Context, query string, opts *QueryOptions) (*QueryPlan, error) {
+	paged, _ := pageQuery(query, opts)
+	return &QueryPlan{SQL: paged}, nil
+}
 
-	return d.ExecuteQuery(ctx, query, opts)
+// PlanTable reports the query GetData would run (implements Planner)
+func (d *DremioRESTWrapper) PlanTable(ctx context.Context, table string, opts *QueryOptions) (*QueryPlan, error) {
+	query, err := d.tableQuery(table, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &QueryPlan{SQL: query}, nil
 }
 
-// TestConnection tests the connection to Dremio
+// TestConnection checks the connection to Dremio through the catalog API,
+// without running a job
 func (d *DremioRESTWrapper) TestConnection(ctx context.Context) error {
-	_, err := d.ExecuteQuery(ctx, "SELECT 1", nil)
-	return err
+	return d.client.TestConnection(ctx)
+}
+
+// DeepCheck runs SELECT 1 as a Dremio job
+func (d *DremioRESTWrapper) DeepCheck(ctx context.Context) error {
+	if err := d.client.TestQuery(ctx); err != nil {
+		return ClassifyDremioError(err)
+	}
+	return nil
 }
 
 // GetType returns the data source type
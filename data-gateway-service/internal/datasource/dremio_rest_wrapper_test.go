@@ -0,0 +1,209 @@
+package datasource
+
+import (
+	"context"
+	"io"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/flight"
+	pb "github.com/apache/arrow-go/v18/arrow/flight/gen/flight"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/patrickmn/go-cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+
+	"go-data-gateway/internal/clients"
+)
+
+// fakeDremio serves a table of ids 0 to rows-1. A query wrapped by pageQuery
+// gets the rows its LIMIT and OFFSET select; any other query gets them all.
+type fakeDremio struct {
+	rows       int
+	delay      time.Duration
+	queries    []string
+	options    map[string]interface{}              // Sent with the last REST job
+	references map[string]clients.VersionReference // Sent with the last REST job
+}
+
+var pagedSuffix = regexp.MustCompile(`\) AS paged LIMIT (\d+)(?: OFFSET (\d+))?$`)
+
+func (f *fakeDremio) run(query string) []int64 {
+	f.queries = append(f.queries, query)
+	time.Sleep(f.delay)
+
+	from, to := 0, f.rows
+	if m := pagedSuffix.FindStringSubmatch(query); m != nil {
+		limit, _ := strconv.Atoi(m[1])
+		offset, _ := strconv.Atoi(m[2])
+		from, to = min(offset, f.rows), min(offset+limit, f.rows)
+	}
+	var ids []int64
+	for id := from; id < to; id++ {
+		ids = append(ids, int64(id))
+	}
+	return ids
+}
+
+// restFake is a restClient answering from a fakeDremio
+type restFake struct{ *fakeDremio }
+
+func (f restFake) ExecuteAnnotatedQuery(_ context.Context, query, comment string) (interface{}, error) {
+	data := []map[string]interface{}{}
+	for _, id := range f.run(comment + query) {
+		data = append(data, map[string]interface{}{"id": id})
+	}
+	return map[string]interface{}{"data": data}, nil
+}
+
+// ExecuteQueryWithOptions records the options it was sent with
+func (f restFake) ExecuteQueryWithOptions(ctx context.Context, query, comment string, options clients.JobOptions) (interface{}, error) {
+	f.options = options.SessionOptions
+	f.references = options.References
+	return f.ExecuteAnnotatedQuery(ctx, query, comment)
+}
+
+func (f restFake) TestConnection(context.Context) error { return nil }
+func (f restFake) TestQuery(context.Context) error      { return nil }
+
+// flightFake is a flight.Client answering from a fakeDremio; only the calls
+// of a query are implemented
+type flightFake struct {
+	flight.Client
+	*fakeDremio
+	ids []int64
+}
+
+func (f *flightFake) GetFlightInfo(_ context.Context, desc *flight.FlightDescriptor, _ ...grpc.CallOption) (*flight.FlightInfo, error) {
+	f.ids = f.run(string(desc.Cmd))
+	return &flight.FlightInfo{Endpoint: []*flight.FlightEndpoint{{Ticket: &flight.Ticket{Ticket: []byte("ticket")}}}}, nil
+}
+
+func (f *flightFake) DoGet(context.Context, *flight.Ticket, ...grpc.CallOption) (pb.FlightService_DoGetClient, error) {
+	schema := arrow.NewSchema([]arrow.Field{{Name: "id", Type: arrow.PrimitiveTypes.Int64}}, nil)
+	b := array.NewRecordBuilder(memory.NewGoAllocator(), schema)
+	defer b.Release()
+	b.Field(0).(*array.Int64Builder).AppendValues(f.ids, nil)
+	record := b.NewRecord()
+	defer record.Release()
+
+	stream := &flightStream{}
+	w := flight.NewRecordWriter(stream, ipc.WithSchema(schema))
+	if err := w.Write(record); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
+
+// flightStream replays the messages sent to it as a DoGet stream
+type flightStream struct {
+	grpc.ClientStream
+	data []*flight.FlightData
+}
+
+// Send keeps a copy, as the writer reuses its message between sends
+func (s *flightStream) Send(data *flight.FlightData) error {
+	s.data = append(s.data, proto.Clone(data).(*flight.FlightData))
+	return nil
+}
+
+func (s *flightStream) Recv() (*flight.FlightData, error) {
+	if len(s.data) == 0 {
+		return nil, io.EOF
+	}
+	data := s.data[0]
+	s.data = s.data[1:]
+	return data, nil
+}
+
+func newRESTFake(rows int) (*DremioRESTWrapper, *fakeDremio) {
+	dremio := &fakeDremio{rows: rows}
+	return &DremioRESTWrapper{client: restFake{dremio}, logger: zap.NewNop()}, dremio
+}
+
+func newFlightFake(rows int) (*DremioArrowClient, *fakeDremio) {
+	dremio := &fakeDremio{rows: rows}
+	return &DremioArrowClient{
+		client:   &flightFake{fakeDremio: dremio},
+		config:   &DremioConfig{},
+		logger:   zap.NewNop(),
+		cache:    cache.New(time.Minute, time.Minute),
+		memAlloc: memory.NewGoAllocator(),
+		ctx:      context.Background(),
+	}, dremio
+}
+
+func resultIDs(result *QueryResult) []int64 {
+	var ids []int64
+	for _, row := range result.Data {
+		ids = append(ids, row["id"].(int64))
+	}
+	return ids
+}
+
+func TestDremioTransports_PageRawQueriesAlike(t *testing.T) {
+	tests := []struct {
+		name string
+		opts *QueryOptions
+		want []int64
+	}{
+		{"no options", nil, []int64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}},
+		{"limit", &QueryOptions{Limit: 5}, []int64{0, 1, 2, 3, 4}},
+		{"limit and offset", &QueryOptions{Limit: 5, Offset: 5}, []int64{5, 6, 7, 8, 9}},
+		{"last page", &QueryOptions{Limit: 5, Offset: 10}, []int64{10, 11}},
+		{"past the end", &QueryOptions{Limit: 5, Offset: 20}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restSource, restDremio := newRESTFake(12)
+			arrowSource, arrowDremio := newFlightFake(12)
+
+			restResult, err := restSource.ExecuteQuery(context.Background(), "SELECT id FROM tender_data", tt.opts)
+			require.NoError(t, err)
+			arrowResult, err := arrowSource.ExecuteQuery(context.Background(), "SELECT id FROM tender_data", tt.opts)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.want, resultIDs(restResult))
+			assert.Equal(t, tt.want, resultIDs(arrowResult))
+			assert.Equal(t, arrowResult.Count, restResult.Count)
+			assert.Equal(t, arrowDremio.queries, restDremio.queries)
+		})
+	}
+}
+
+func TestDremioTransports_TableQueriesPagedOnce(t *testing.T) {
+	opts := &QueryOptions{Limit: 5, Offset: 5}
+	restSource, restDremio := newRESTFake(12)
+	arrowSource, arrowDremio := newFlightFake(12)
+
+	_, err := restSource.GetData(context.Background(), "tender_data", opts)
+	require.NoError(t, err)
+	_, err = arrowSource.GetData(context.Background(), "tender_data", opts)
+	require.NoError(t, err)
+
+	require.Len(t, restDremio.queries, 1)
+	assert.NotContains(t, restDremio.queries[0], "AS paged")
+	assert.Contains(t, restDremio.queries[0], "LIMIT 5 OFFSET 5")
+	assert.Equal(t, arrowDremio.queries, restDremio.queries)
+}
+
+func TestDremioRESTWrapper_MeasuresQueryTime(t *testing.T) {
+	restSource, dremio := newRESTFake(1)
+	dremio.delay = 20 * time.Millisecond
+
+	result, err := restSource.ExecuteQuery(context.Background(), "SELECT id FROM tender_data", nil)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, result.QueryTime, dremio.delay)
+	assert.Less(t, result.QueryTime, time.Second)
+}
@@ -2,40 +2,68 @@ package datasource
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/apache/arrow-go/v18/arrow"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go-data-gateway/internal/clients"
+	"go-data-gateway/internal/datasource/flighttest"
 )
 
-// Helper function to get environment variable with default
-func getEnvOrDefault(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
-	}
-	return defaultValue
+// Credentials the fake Flight server accepts; they are not real secrets
+const (
+	testFlightUser     = "gateway"
+	testFlightPassword = "flight-test"
+)
+
+// newTestFlightServer starts a fake Dremio Flight endpoint requiring the test
+// credentials
+func newTestFlightServer(t *testing.T) *flighttest.Server {
+	t.Helper()
+	server := flighttest.NewServer()
+	server.SetCredentials(testFlightUser, testFlightPassword)
+	t.Cleanup(server.Close)
+	return server
 }
 
-// TestDremioAuthenticationSecure tests authentication with real Dremio using environment variables
-// SECURITY: This test ONLY uses environment variables for credentials - NEVER hardcode passwords!
-func TestDremioAuthenticationSecure(t *testing.T) {
-	// Skip if not in integration test mode
-	if os.Getenv("RUN_INTEGRATION_TESTS") != "true" {
-		t.Skip("Skipping integration test. Set RUN_INTEGRATION_TESTS=true to run")
+// flightConfig is the Dremio config of server with username and password
+func flightConfig(server *flighttest.Server, username, password string) *DremioConfig {
+	return &DremioConfig{
+		Host:     server.Host(),
+		Port:     server.Port(),
+		Username: username,
+		Password: password,
 	}
+}
 
-	// Check if required environment variables are set
-	username := os.Getenv("DREMIO_USERNAME")
-	password := os.Getenv("DREMIO_PASSWORD")
-
-	if username == "" || password == "" {
-		t.Skip("Skipping test: DREMIO_USERNAME and DREMIO_PASSWORD environment variables required")
+// testPoolConfig is a small pool that checks health too rarely to interfere
+func testPoolConfig() *PoolConfig {
+	return &PoolConfig{
+		MaxConnections:      5,
+		MinConnections:      1,
+		MaxIdleTime:         5 * time.Minute,
+		ConnectionTimeout:   5 * time.Second,
+		HealthCheckInterval: time.Hour,
 	}
+}
 
-	logger, _ := zap.NewDevelopment()
+// TestDremioAuthentication checks credentials against a fake Flight server
+func TestDremioAuthentication(t *testing.T) {
+	server := newTestFlightServer(t)
+
+	// A port nothing listens on
+	closed := flighttest.NewServer()
+	closed.Close()
 
 	tests := []struct {
 		name          string
@@ -44,147 +72,226 @@ func TestDremioAuthenticationSecure(t *testing.T) {
 		errorContains string
 	}{
 		{
-			name: "Valid credentials from environment",
-			config: &DremioConfig{
-				Host:     getEnvOrDefault("DREMIO_HOST", "localhost"),
-				Port:     32010,
-				Username: username, // From environment variable
-				Password: password, // From environment variable
-				UseTLS:   false,
-			},
+			name:          "Valid credentials",
+			config:        flightConfig(server, testFlightUser, testFlightPassword),
 			shouldSucceed: true,
 		},
 		{
-			name: "Invalid password",
-			config: &DremioConfig{
-				Host:     getEnvOrDefault("DREMIO_HOST", "localhost"),
-				Port:     32010,
-				Username: username,
-				Password: "definitely_wrong_password",
-				UseTLS:   false,
-			},
-			shouldSucceed: false,
+			name:          "Invalid password",
+			config:        flightConfig(server, testFlightUser, "definitely_wrong_password"),
 			errorContains: "Invalid username or password",
 		},
 		{
-			name: "Invalid username",
-			config: &DremioConfig{
-				Host:     getEnvOrDefault("DREMIO_HOST", "localhost"),
-				Port:     32010,
-				Username: "invalid_user",
-				Password: password,
-				UseTLS:   false,
-			},
-			shouldSucceed: false,
+			name:          "Invalid username",
+			config:        flightConfig(server, "invalid_user", testFlightPassword),
 			errorContains: "Invalid username or password",
 		},
 		{
-			name: "Wrong port",
-			config: &DremioConfig{
-				Host:     getEnvOrDefault("DREMIO_HOST", "localhost"),
-				Port:     9999, // Wrong port
-				Username: username,
-				Password: password,
-				UseTLS:   false,
-			},
-			shouldSucceed: false,
-			errorContains: "connection error",
+			name:   "Wrong port",
+			config: flightConfig(closed, testFlightUser, testFlightPassword),
 		},
 		{
-			name: "Empty credentials",
-			config: &DremioConfig{
-				Host:     getEnvOrDefault("DREMIO_HOST", "localhost"),
-				Port:     32010,
-				Username: "",
-				Password: "",
-				UseTLS:   false,
-			},
-			shouldSucceed: false,
+			name:          "Empty credentials",
+			config:        flightConfig(server, "", ""),
 			errorContains: "Invalid username or password",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// Create client with test configuration
-			client, err := NewDremioArrowClient(tt.config, logger)
+			client, err := NewDremioArrowClient(tt.config, zap.NewNop())
+			require.NoError(t, err)
+			defer client.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			err = client.TestConnection(ctx)
 
 			if tt.shouldSucceed {
-				require.NoError(t, err, "Expected successful connection")
-				require.NotNil(t, client, "Client should not be nil")
-
-				// Test the connection
-				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-				defer cancel()
-
-				testErr := client.TestConnection(ctx)
-				assert.NoError(t, testErr, "Connection test should succeed")
-
-				// Clean up
-				client.Close()
-			} else {
-				if err != nil {
-					assert.Contains(t, err.Error(), tt.errorContains,
-						"Error should contain expected message")
-				} else if client != nil {
-					// Connection might succeed but auth might fail on query
-					ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-					defer cancel()
-
-					testErr := client.TestConnection(ctx)
-					assert.Error(t, testErr, "Expected authentication to fail")
-					if testErr != nil {
-						assert.Contains(t, testErr.Error(), tt.errorContains,
-							"Error should contain expected message")
-					}
-					client.Close()
-				}
+				assert.NoError(t, err)
+				return
 			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorContains)
 		})
 	}
 }
 
-// TestDremioConnectionPoolWithEnvCredentials tests connection pool with secure credentials
-func TestDremioConnectionPoolWithEnvCredentials(t *testing.T) {
-	if os.Getenv("RUN_INTEGRATION_TESTS") != "true" {
-		t.Skip("Skipping integration test")
-	}
+// TestDremioConnectionPool_Authenticates checks that the pool opens
+// connections with the configured credentials and refuses others
+func TestDremioConnectionPool_Authenticates(t *testing.T) {
+	server := newTestFlightServer(t)
 
-	username := os.Getenv("DREMIO_USERNAME")
-	password := os.Getenv("DREMIO_PASSWORD")
+	client, err := NewDremioArrowClientWithPool(flightConfig(server, testFlightUser, testFlightPassword), testPoolConfig(), zap.NewNop())
+	require.NoError(t, err)
+	defer client.Close()
+	assert.NoError(t, client.TestConnection(context.Background()))
 
-	if username == "" || password == "" {
-		t.Skip("Skipping test: DREMIO_USERNAME and DREMIO_PASSWORD required")
-	}
+	rejected, err := NewDremioArrowClientWithPool(flightConfig(server, testFlightUser, "wrong"), testPoolConfig(), zap.NewNop())
+	require.NoError(t, err)
+	defer rejected.Close()
+	err = rejected.TestConnection(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Invalid username or password")
+}
 
-	logger, _ := zap.NewDevelopment()
+// TestDremioArrowClient_RotateCredentials rotates the password of the
+// service account while queries run: Dremio accepts both passwords until the
+// old one is revoked, after the pool has retired its connections
+func TestDremioArrowClient_RotateCredentials(t *testing.T) {
+	server := newTestFlightServer(t)
+	server.SetLatency(5 * time.Millisecond)
 
-	config := &DremioConfig{
-		Host:     getEnvOrDefault("DREMIO_HOST", "localhost"),
-		Port:     32010,
-		Username: username,
-		Password: password,
-		UseTLS:   false,
+	client, err := NewDremioArrowClientWithPool(flightConfig(server, testFlightUser, testFlightPassword), testPoolConfig(), zap.NewNop())
+	require.NoError(t, err)
+	defer client.Close()
+
+	var (
+		wg      sync.WaitGroup
+		queries atomic.Int64
+		failed  atomic.Int64
+		lastErr atomic.Value
+	)
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				// Distinct queries miss the client's result cache
+				n := queries.Add(1)
+				if _, err := client.ExecuteQuery(context.Background(), fmt.Sprintf("SELECT %d", n), nil); err != nil {
+					failed.Add(1)
+					lastErr.Store(err)
+				}
+			}
+		}()
 	}
+	waitQueries := func(n int64) {
+		target := queries.Load() + n
+		require.Eventually(t, func() bool { return queries.Load() >= target }, 5*time.Second, time.Millisecond)
+	}
+	waitQueries(20)
 
-	poolConfig := &PoolConfig{
-		MaxConnections:      5,
-		MinConnections:      1,
-		MaxIdleTime:         5 * time.Minute,
-		ConnectionTimeout:   10 * time.Second,
-		HealthCheckInterval: 30 * time.Second,
+	// Credentials Dremio refuses change nothing
+	err = client.RotateCredentials(context.Background(), nil, clients.DremioCredentials{Username: testFlightUser, Password: "rotated"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Invalid username or password")
+
+	server.AddCredentials(testFlightUser, "rotated")
+	require.NoError(t, client.RotateCredentials(context.Background(), nil,
+		clients.DremioCredentials{Username: testFlightUser, Password: "rotated"}))
+
+	// Connections in use finish their query before they are retired
+	require.Eventually(t, func() bool {
+		return client.pool.GetMetrics()["retiring"] == 0
+	}, 5*time.Second, time.Millisecond)
+	server.RevokeCredentials(testFlightUser, testFlightPassword)
+	waitQueries(20)
+
+	close(stop)
+	wg.Wait()
+	assert.Zero(t, failed.Load(), "no query fails during the rotation: %v", lastErr.Load())
+	assert.Positive(t, client.pool.GetMetrics()["retired"])
+	assert.NoError(t, client.TestConnection(context.Background()))
+
+	// A source with its own account is not rotated with another's
+	err = client.RotateCredentials(context.Background(), clients.NewCredentials(clients.DremioCredentials{}), clients.DremioCredentials{Token: "t"})
+	assert.ErrorIs(t, err, ErrNoCredentials)
+}
+
+// TestDremioArrowClient_ExecuteQuery runs queries through the pool and the
+// single connection against a fake Flight server
+func TestDremioArrowClient_ExecuteQuery(t *testing.T) {
+	server := newTestFlightServer(t)
+	server.Respond("FROM tenders", flighttest.Result{
+		Schema: arrow.NewSchema([]arrow.Field{
+			{Name: "kd_tender", Type: arrow.BinaryTypes.String},
+			{Name: "pagu", Type: arrow.PrimitiveTypes.Float64},
+			{Name: "tahun", Type: arrow.PrimitiveTypes.Int64, Nullable: true},
+		}, nil),
+		Rows: [][]interface{}{
+			{"T-1", 1500.5, int64(2024)},
+			{"T-2", 20.0, nil},
+		},
+	})
+	server.Fail("FROM missing", status.Error(codes.NotFound, "Table 'missing' not found"))
+
+	pooled, err := NewDremioArrowClientWithPool(flightConfig(server, testFlightUser, testFlightPassword), testPoolConfig(), zap.NewNop())
+	require.NoError(t, err)
+	defer pooled.Close()
+	single, err := NewDremioArrowClient(flightConfig(server, testFlightUser, testFlightPassword), zap.NewNop())
+	require.NoError(t, err)
+	defer single.Close()
+
+	for name, client := range map[string]*DremioArrowClient{"pooled": pooled, "single": single} {
+		t.Run(name, func(t *testing.T) {
+			result, err := client.ExecuteQuery(context.Background(), "SELECT * FROM tenders", nil)
+			require.NoError(t, err)
+			require.Len(t, result.Data, 2)
+			assert.Equal(t, "T-1", result.Data[0]["kd_tender"])
+			assert.Equal(t, 1500.5, result.Data[0]["pagu"])
+			assert.EqualValues(t, 2024, result.Data[0]["tahun"])
+			assert.Nil(t, result.Data[1]["tahun"])
+
+			_, err = client.ExecuteQuery(context.Background(), "SELECT * FROM missing", nil)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "not found")
+		})
 	}
+	assert.Contains(t, server.Queries(), "SELECT * FROM tenders")
+}
+
+// TestDremioArrowClient_DuplicateColumns keeps every column of a JOIN that
+// repeats a name and records the renames
+func TestDremioArrowClient_DuplicateColumns(t *testing.T) {
+	server := newTestFlightServer(t)
+	server.Respond("JOIN tender_peserta", flighttest.Result{
+		Schema: joinSchema,
+		Rows:   [][]interface{}{{"TND-1", "Jalan", "PST-9", int64(7)}},
+	})
+
+	client, err := NewDremioArrowClient(flightConfig(server, testFlightUser, testFlightPassword), zap.NewNop())
+	require.NoError(t, err)
+	defer client.Close()
 
-	// Create client with connection pool
-	client, err := NewDremioArrowClientWithPool(config, poolConfig, logger)
+	query := "SELECT t.id, t.nama_paket, p.id, p.urutan AS id FROM tenders t JOIN tender_peserta p ON p.tender_id = t.id"
+	result, err := client.ExecuteQuery(context.Background(), query, nil)
+	require.NoError(t, err)
+	require.Len(t, result.Data, 1)
+	assert.Equal(t, "TND-1", result.Data[0]["id"])
+	assert.Equal(t, "PST-9", result.Data[0]["tender_peserta_id"])
+	assert.EqualValues(t, 7, result.Data[0]["id_1"])
+	renamed := map[string]string{"tender_peserta_id": "id", "id_1": "id"}
+	assert.Equal(t, renamed, result.Metadata[MetaRenamedColumns])
+
+	columnar, err := client.ExecuteQueryColumnar(context.Background(), query)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"id", "nama_paket", "tender_peserta_id", "id_1"}, columnar.Columns)
+	assert.Equal(t, renamed, columnar.Renamed)
+}
+
+// TestDremioArrowClient_QueryTimesOut checks that a slow server fails the
+// query once its context expires
+func TestDremioArrowClient_QueryTimesOut(t *testing.T) {
+	server := newTestFlightServer(t)
+	server.SetLatency(time.Minute)
+
+	client, err := NewDremioArrowClientWithPool(flightConfig(server, testFlightUser, testFlightPassword), testPoolConfig(), zap.NewNop())
 	require.NoError(t, err)
-	require.NotNil(t, client)
 	defer client.Close()
 
-	// Test connection
-	ctx := context.Background()
-	err = client.TestConnection(ctx)
-	assert.NoError(t, err, "Pool connection should work")
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = client.ExecuteQuery(ctx, "SELECT 1", nil)
+	require.Error(t, err)
+	assert.Less(t, time.Since(start), 5*time.Second)
 }
 
 // TestBasicAuthGeneration tests the basic auth header generation
@@ -250,13 +357,7 @@ func TestEnvironmentVariableLoading(t *testing.T) {
 
 // Security reminder for future developers
 func TestSecurityReminder(t *testing.T) {
-	t.Log("SECURITY REMINDER: This test file uses environment variables for credentials.")
+	t.Log("SECURITY REMINDER: Dremio tests run against the in-process flighttest server.")
 	t.Log("NEVER hardcode passwords, API keys, or other secrets in test files!")
-	t.Log("Set credentials using environment variables or .env.test file (not committed)")
-	t.Log("")
-	t.Log("Required environment variables for integration tests:")
-	t.Log("  - DREMIO_USERNAME")
-	t.Log("  - DREMIO_PASSWORD")
-	t.Log("  - DREMIO_HOST (optional, defaults to localhost)")
-	t.Log("  - RUN_INTEGRATION_TESTS=true")
-}
\ No newline at end of file
+	t.Log("Credentials for a real cluster belong in environment variables or .env.test (not committed)")
+}
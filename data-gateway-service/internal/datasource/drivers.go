@@ -0,0 +1,197 @@
+package datasource
+
+import (
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/clients"
+	"go-data-gateway/internal/config"
+)
+
+// Built-in drivers. postgres and mysql have no driver in the gateway; a
+// build that needs them registers its own.
+func init() {
+	Register(config.SourceTypeDremioArrow, Driver{Type: DataSourceDremio, New: newDremioArrowSource})
+	Register(config.SourceTypeDremioREST, Driver{Type: DataSourceDremio, New: newDremioRESTSource})
+	Register(config.SourceTypeBigQuery, Driver{Type: DataSourceBigQuery, New: newBigQuerySource})
+}
+
+// newDremioArrowSource connects to Dremio over Arrow Flight SQL with a
+// connection pool. Settings: host, port (32010), username, password, token,
+// shared_credentials (false) to use the DREMIO_* account's instead, tls,
+// project, ui_url, job_lookup, max_connections (10), min_connections (2),
+// keep_warm (off) to ping idle connections that often, and rest_fallback (false), rest_port (9047) and fallback_retries (1) to run
+// queries over the REST API when Flight cannot be reached.
+func newDremioArrowSource(cfg config.DataSourceConfig, deps Dependencies) (DataSource, error) {
+	host := cfg.Setting("host", "")
+	if host == "" {
+		return nil, fmt.Errorf("data source %s: host is required", cfg.Name)
+	}
+	port, err := cfg.IntSetting("port", 32010)
+	if err != nil {
+		return nil, err
+	}
+	useTLS, err := cfg.BoolSetting("tls", false)
+	if err != nil {
+		return nil, err
+	}
+	jobLookup, err := cfg.BoolSetting("job_lookup", false)
+	if err != nil {
+		return nil, err
+	}
+	maxConnections, err := cfg.IntSetting("max_connections", 10)
+	if err != nil {
+		return nil, err
+	}
+	minConnections, err := cfg.IntSetting("min_connections", 2)
+	if err != nil {
+		return nil, err
+	}
+	keepWarm, err := cfg.DurationSetting("keep_warm", 0)
+	if err != nil {
+		return nil, err
+	}
+	restFallback, err := cfg.BoolSetting("rest_fallback", false)
+	if err != nil {
+		return nil, err
+	}
+	restPort, err := cfg.IntSetting("rest_port", 9047)
+	if err != nil {
+		return nil, err
+	}
+	fallbackRetries, err := cfg.IntSetting("fallback_retries", 1)
+	if err != nil {
+		return nil, err
+	}
+	creds, err := sourceCredentials(cfg, deps)
+	if err != nil {
+		return nil, err
+	}
+
+	dremioConfig := &DremioConfig{
+		Host:     host,
+		Port:     port,
+		Username: cfg.Setting("username", ""),
+		Password: cfg.Setting("password", ""),
+		Token:    cfg.Setting("token", ""),
+		UseTLS:   useTLS,
+		Project:  cfg.Setting("project", "nessie_iceberg"),
+		UIURL:    cfg.Setting("ui_url", ""),
+
+		Credentials: creds,
+	}
+	if jobLookup && deps.DremioJobs != nil {
+		dremioConfig.Jobs = deps.DremioJobs
+	}
+
+	poolConfig := &PoolConfig{
+		MaxConnections:      maxConnections,
+		MinConnections:      minConnections,
+		MaxIdleTime:         30 * time.Minute,
+		ConnectionTimeout:   10 * time.Second,
+		HealthCheckInterval: 1 * time.Minute,
+		AcquireTimeout:      5 * time.Second,
+		KeepWarmInterval:    keepWarm,
+	}
+
+	client, err := NewDremioArrowClientWithPool(dremioConfig, poolConfig, deps.Logger)
+	if err != nil {
+		return nil, fmt.Errorf("arrow flight sql: %w", err)
+	}
+	deps.Logger.Info("Dremio Arrow Flight SQL client initialized with connection pool",
+		zap.Int("max_connections", poolConfig.MaxConnections))
+	if !restFallback {
+		return client, nil
+	}
+
+	deps.Logger.Info("Dremio REST fallback enabled", zap.Int("rest_port", restPort), zap.Int("retries", fallbackRetries))
+	newREST := func() (DataSource, error) {
+		return newDremioREST(host, restPort, creds, deps)
+	}
+	return NewDremioFallbackSource(client, newREST, FallbackConfig{
+		Source:  cfg.Name,
+		Retries: fallbackRetries,
+	}, deps.Fallbacks, deps.Logger), nil
+}
+
+// newDremioRESTSource connects to Dremio's REST API. Settings: host, port
+// (9047), username, password, shared_credentials (false).
+func newDremioRESTSource(cfg config.DataSourceConfig, deps Dependencies) (DataSource, error) {
+	host := cfg.Setting("host", "")
+	if host == "" {
+		return nil, fmt.Errorf("data source %s: host is required", cfg.Name)
+	}
+	port, err := cfg.IntSetting("port", 9047)
+	if err != nil {
+		return nil, err
+	}
+
+	creds, err := sourceCredentials(cfg, deps)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := newDremioREST(host, port, creds, deps)
+	if err != nil {
+		return nil, fmt.Errorf("dremio rest client: %w", err)
+	}
+	deps.Logger.Info("Dremio REST client initialized")
+	return client, nil
+}
+
+// sourceCredentials returns the credentials a Dremio source authenticates
+// with: the shared ones of deps with shared_credentials set, its own
+// otherwise
+func sourceCredentials(cfg config.DataSourceConfig, deps Dependencies) (*clients.Credentials, error) {
+	shared, err := cfg.BoolSetting("shared_credentials", false)
+	if err != nil {
+		return nil, err
+	}
+	if shared && deps.DremioCredentials != nil {
+		return deps.DremioCredentials, nil
+	}
+	return clients.NewCredentials(clients.DremioCredentials{
+		Username: cfg.Setting("username", ""),
+		Password: cfg.Setting("password", ""),
+		Token:    cfg.Setting("token", ""),
+	}), nil
+}
+
+// newDremioREST connects a Dremio REST client that counts its cancelled jobs
+// in deps.JobCancels
+func newDremioREST(host string, port int, creds *clients.Credentials, deps Dependencies) (DataSource, error) {
+	client, err := NewDremioRESTClientWithCredentials(host, port, creds, deps.Logger)
+	if err != nil {
+		return nil, err
+	}
+	client.(*DremioRESTWrapper).SetJobCancels(deps.JobCancels)
+	return client, nil
+}
+
+// newBigQuerySource connects to BigQuery. Settings: project_id, dataset_id,
+// location, credentials (path to a service account JSON file), endpoint and
+// ca_file (a private API endpoint and the CA of its certificate).
+func newBigQuerySource(cfg config.DataSourceConfig, deps Dependencies) (DataSource, error) {
+	bigQueryConfig := config.BigQueryConfig{
+		ProjectID:   cfg.Setting("project_id", ""),
+		DatasetID:   cfg.Setting("dataset_id", ""),
+		Location:    cfg.Setting("location", ""),
+		Credentials: cfg.Setting("credentials", ""),
+		Endpoint:    cfg.Setting("endpoint", ""),
+		CAFile:      cfg.Setting("ca_file", ""),
+	}
+	if bigQueryConfig.ProjectID == "" {
+		return nil, fmt.Errorf("data source %s: project_id is required", cfg.Name)
+	}
+
+	wrapper, err := NewBigQueryWrapper(bigQueryConfig, deps.Logger)
+	if err != nil {
+		return nil, fmt.Errorf("bigquery client: %w", err)
+	}
+	wrapper.SetJobCancels(deps.JobCancels)
+	wrapper.SetUsage(deps.BigQueryUsage)
+	deps.Logger.Info("BigQuery client initialized", zap.String("project", bigQueryConfig.ProjectID))
+	return wrapper, nil
+}
@@ -0,0 +1,100 @@
+package datasource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// EngineOptions are Dremio session options of one query, such as
+// planner.enable_broadcast_join, keyed by option name. The routing options
+// pick the engine or queue that runs the query instead.
+type EngineOptions map[string]interface{}
+
+// Routing options, sent to Dremio as call headers rather than session options
+const (
+	RoutingTag    = "routing_tag"
+	RoutingQueue  = "routing_queue"
+	RoutingEngine = "routing_engine"
+)
+
+var (
+	routingOptions = map[string]bool{RoutingTag: true, RoutingQueue: true, RoutingEngine: true}
+
+	// Option names are quoted into ALTER SESSION statements; the allowlist
+	// is the real guard, this keeps a misconfigured one from breaking out
+	engineOptionName = regexp.MustCompile(`^[a-z][a-z0-9_]*(\.[a-z0-9_]+)*$`)
+
+	// errSessionNotReset marks a connection whose session options could not
+	// be reset; the pool closes it rather than hand it to another query
+	errSessionNotReset = errors.New("session options not reset")
+)
+
+// ValidateEngineOptions checks that every option is in allowed and has a
+// boolean, number or string value; routing options take strings only
+func ValidateEngineOptions(options EngineOptions, allowed []string) error {
+	permitted := make(map[string]bool, len(allowed))
+	for _, name := range allowed {
+		permitted[name] = true
+	}
+	for _, name := range options.names() {
+		if !permitted[name] || !engineOptionName.MatchString(name) {
+			return fmt.Errorf("option %q is not allowed", name)
+		}
+		switch options[name].(type) {
+		case string:
+		case bool, float64, int, int64:
+			if routingOptions[name] {
+				return fmt.Errorf("option %q must be a string", name)
+			}
+		default:
+			return fmt.Errorf("option %q must be a boolean, number or string", name)
+		}
+	}
+	return nil
+}
+
+// names returns the option names, sorted so statements run in a fixed order
+func (o EngineOptions) names() []string {
+	names := make([]string, 0, len(o))
+	for name := range o {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sessionStatements returns the ALTER SESSION statements that set the session
+// options and those that reset them, in name order
+func (o EngineOptions) sessionStatements() (set, reset []string, err error) {
+	for _, name := range o.names() {
+		if routingOptions[name] {
+			continue
+		}
+		if !engineOptionName.MatchString(name) {
+			return nil, nil, fmt.Errorf("invalid session option name %q", name)
+		}
+		value, err := DialectANSI.Literal(o[name])
+		if err != nil {
+			return nil, nil, fmt.Errorf("session option %s: %w", name, err)
+		}
+		set = append(set, fmt.Sprintf(`ALTER SESSION SET "%s" = %s`, name, value))
+		reset = append(reset, fmt.Sprintf(`ALTER SESSION RESET "%s"`, name))
+	}
+	return set, reset, nil
+}
+
+// withRouting adds the routing options to the outgoing call headers
+func (o EngineOptions) withRouting(ctx context.Context) context.Context {
+	for _, name := range o.names() {
+		if value, ok := o[name].(string); ok && routingOptions[name] {
+			ctx = metadata.AppendToOutgoingContext(ctx, name, strings.TrimSpace(value))
+		}
+	}
+	return ctx
+}
@@ -0,0 +1,168 @@
+package datasource
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow/flight"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/patrickmn/go-cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+var testEngineOptions = []string{"planner.enable_broadcast_join", "planner.slice_target", RoutingTag}
+
+func TestValidateEngineOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		options EngineOptions
+		wantErr string
+	}{
+		{"none", nil, ""},
+		{"allowed", EngineOptions{"planner.enable_broadcast_join": false, "planner.slice_target": 1000.0, RoutingTag: "etl"}, ""},
+		{"not allowed", EngineOptions{"exec.queue.enable": false}, `option "exec.queue.enable" is not allowed`},
+		{"statement", EngineOptions{`planner.slice_target" = 1; DROP TABLE x; --`: 1.0}, "is not allowed"},
+		{"object value", EngineOptions{"planner.slice_target": map[string]interface{}{}}, "must be a boolean, number or string"},
+		{"routing number", EngineOptions{RoutingTag: 1.0}, `option "routing_tag" must be a string`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateEngineOptions(tt.options, testEngineOptions)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+// sessionFake is a pooled Flight connection that records the routing headers
+// of each call and fails the statements fail picks
+type sessionFake struct {
+	*flightFake
+	headers []string
+	fail    func(statement string) bool
+}
+
+func (f *sessionFake) GetFlightInfo(ctx context.Context, desc *flight.FlightDescriptor, opts ...grpc.CallOption) (*flight.FlightInfo, error) {
+	if md, ok := metadata.FromOutgoingContext(ctx); ok {
+		f.headers = append(f.headers, md.Get(RoutingTag)...)
+	}
+	if f.fail != nil && f.fail(string(desc.Cmd)) {
+		f.fakeDremio.queries = append(f.fakeDremio.queries, string(desc.Cmd))
+		return nil, errors.New("statement failed")
+	}
+	return f.flightFake.GetFlightInfo(ctx, desc, opts...)
+}
+
+func (f *sessionFake) Close() error { return nil }
+
+// newPooledFake returns an Arrow client whose pool holds one connection to
+// a fakeDremio of rows rows
+func newPooledFake(rows int) (*DremioArrowClient, *sessionFake) {
+	conn := &sessionFake{flightFake: &flightFake{fakeDremio: &fakeDremio{rows: rows}}}
+	pool := idlePool(1)
+	pool.connections = []*ArrowConnection{{id: "conn-1", client: conn}}
+	return &DremioArrowClient{
+		pool:     pool,
+		usePool:  true,
+		config:   &DremioConfig{},
+		logger:   zap.NewNop(),
+		cache:    cache.New(time.Minute, time.Minute),
+		memAlloc: memory.NewGoAllocator(),
+		ctx:      context.Background(),
+	}, conn
+}
+
+func TestDremioArrowClient_EngineOptionsSetAndReset(t *testing.T) {
+	client, conn := newPooledFake(3)
+	opts := &QueryOptions{EngineOptions: EngineOptions{
+		"planner.slice_target":          1000.0,
+		"planner.enable_broadcast_join": false,
+		RoutingTag:                      "etl",
+	}}
+
+	result, err := client.ExecuteQuery(context.Background(), "SELECT id FROM tender_data", opts)
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.Count)
+
+	assert.Equal(t, []string{
+		`ALTER SESSION SET "planner.enable_broadcast_join" = FALSE`,
+		`ALTER SESSION SET "planner.slice_target" = 1000`,
+		"SELECT id FROM tender_data",
+		`ALTER SESSION RESET "planner.enable_broadcast_join"`,
+		`ALTER SESSION RESET "planner.slice_target"`,
+	}, conn.queries)
+	assert.Len(t, conn.headers, 5, "every call carries the routing tag")
+	assert.Equal(t, "etl", conn.headers[0])
+
+	// The connection went back to the pool
+	require.Len(t, client.pool.connections, 1)
+	assert.False(t, client.pool.connections[0].inUse)
+
+	// Without options nothing else runs on the connection
+	conn.queries = nil
+	_, err = client.ExecuteQuery(context.Background(), "SELECT id FROM rup", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"SELECT id FROM rup"}, conn.queries)
+}
+
+func TestDremioArrowClient_EngineOptionsResetAfterFailedQuery(t *testing.T) {
+	client, conn := newPooledFake(1)
+	conn.fail = func(statement string) bool { return strings.HasPrefix(statement, "SELECT") }
+
+	_, err := client.ExecuteQuery(context.Background(), "SELECT id FROM tender_data",
+		&QueryOptions{EngineOptions: EngineOptions{"planner.slice_target": 1000.0}})
+	require.Error(t, err)
+
+	assert.Equal(t, `ALTER SESSION RESET "planner.slice_target"`, conn.queries[len(conn.queries)-1])
+	assert.Len(t, client.pool.connections, 1)
+}
+
+func TestDremioArrowClient_ConnectionNotResetIsClosed(t *testing.T) {
+	client, conn := newPooledFake(2)
+	conn.fail = func(statement string) bool { return strings.HasPrefix(statement, "ALTER SESSION RESET") }
+
+	result, err := client.ExecuteQuery(context.Background(), "SELECT id FROM tender_data",
+		&QueryOptions{EngineOptions: EngineOptions{"planner.slice_target": 1000.0}})
+	require.NoError(t, err, "the query itself succeeded")
+	assert.Equal(t, 2, result.Count)
+
+	// Its options would leak into the next query, so it is not reused
+	assert.Empty(t, client.pool.connections)
+	assert.Equal(t, int64(0), client.pool.GetMetrics()["active_connections"])
+}
+
+func TestDremioArrowClient_FailedOptionIsUndone(t *testing.T) {
+	client, conn := newPooledFake(1)
+	conn.fail = func(statement string) bool { return strings.Contains(statement, "planner.slice_target\" =") }
+
+	_, err := client.ExecuteQuery(context.Background(), "SELECT id FROM tender_data",
+		&QueryOptions{EngineOptions: EngineOptions{"planner.enable_broadcast_join": false, "planner.slice_target": -1.0}})
+	require.Error(t, err)
+
+	assert.Equal(t, []string{
+		`ALTER SESSION SET "planner.enable_broadcast_join" = FALSE`,
+		`ALTER SESSION SET "planner.slice_target" = -1`,
+		`ALTER SESSION RESET "planner.enable_broadcast_join"`,
+	}, conn.queries)
+	assert.Len(t, client.pool.connections, 1)
+}
+
+func TestDremioRESTWrapper_ForwardsEngineOptions(t *testing.T) {
+	restSource, dremio := newRESTFake(1)
+
+	_, err := restSource.ExecuteQuery(context.Background(), "SELECT id FROM tender_data",
+		&QueryOptions{EngineOptions: EngineOptions{"planner.slice_target": 1000.0}})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"planner.slice_target": 1000.0}, dremio.options)
+	assert.Equal(t, []string{"SELECT id FROM tender_data"}, dremio.queries)
+}
@@ -0,0 +1,148 @@
+package datasource
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/clients"
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/metrics"
+)
+
+// Driver builds the data sources of one configured type
+type Driver struct {
+	Type DataSourceType // Type of the sources it builds, known before they connect
+	New  func(cfg config.DataSourceConfig, deps Dependencies) (DataSource, error)
+}
+
+// Dependencies are the shared services drivers may use
+type Dependencies struct {
+	Logger *zap.Logger
+
+	// DremioJobs looks up job ids Arrow Flight does not report, for sources
+	// with job_lookup enabled; nil disables lookups
+	DremioJobs JobLookup
+
+	// Fallbacks counts queries Arrow Flight sources retried over REST; nil
+	// counts nothing
+	Fallbacks *metrics.FallbackCounter
+
+	// JobCancels counts the BigQuery and Dremio REST jobs cancelled because
+	// their request ended; nil counts nothing
+	JobCancels *metrics.CancelCounter
+
+	// BigQueryUsage accumulates the bytes and slot time of BigQuery jobs by
+	// API key; nil counts nothing
+	BigQueryUsage *metrics.BigQueryUsageCounter
+
+	// DremioCredentials are the credentials of the DREMIO_* service account,
+	// used instead of their own by Dremio sources with shared_credentials set
+	// so that a rotation reaches them; nil leaves every source its own
+	DremioCredentials *clients.Credentials
+}
+
+var (
+	driversMu sync.RWMutex
+	drivers   = make(map[string]Driver)
+)
+
+// Register makes a data source type available under name, the type in a
+// DataSourceConfig. Like database/sql drivers, packages outside the gateway
+// register their types from init; registering a name twice panics.
+func Register(name string, driver Driver) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+
+	if driver.New == nil {
+		panic("datasource: Register driver " + name + " without a constructor")
+	}
+	if _, dup := drivers[name]; dup {
+		panic("datasource: Register called twice for driver " + name)
+	}
+	drivers[name] = driver
+}
+
+// LookupDriver returns the driver registered under name
+func LookupDriver(name string) (Driver, bool) {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+
+	driver, ok := drivers[name]
+	return driver, ok
+}
+
+// Drivers returns the sorted names of the registered drivers
+func Drivers() []string {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+
+	names := make([]string, 0, len(drivers))
+	for name := range drivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// driverFactory creates data sources with the registered drivers
+type driverFactory struct {
+	deps Dependencies
+}
+
+// NewFactory returns a Factory that builds each configured source with the
+// driver registered for its type
+func NewFactory(deps Dependencies) Factory {
+	if deps.Logger == nil {
+		deps.Logger = zap.NewNop()
+	}
+	return &driverFactory{deps: deps}
+}
+
+// Create builds the data source cfg declares
+func (f *driverFactory) Create(cfg config.DataSourceConfig) (DataSource, error) {
+	driver, ok := LookupDriver(cfg.Type)
+	if !ok {
+		return nil, unknownDriverError(cfg)
+	}
+	deps := f.deps
+	deps.Logger = deps.Logger.With(zap.String("source", cfg.Name))
+	return driver.New(cfg, deps)
+}
+
+// CheckDataSources reports the first declared source without a registered
+// driver or with an invalid shadow, so configuration mistakes stop the
+// server before it starts
+func CheckDataSources(sources []config.DataSourceConfig) error {
+	seen := make(map[string]bool, len(sources))
+	for _, cfg := range sources {
+		if seen[cfg.Name] {
+			return fmt.Errorf("data source %s is declared twice", cfg.Name)
+		}
+		seen[cfg.Name] = true
+		if _, ok := LookupDriver(cfg.Type); !ok {
+			return unknownDriverError(cfg)
+		}
+	}
+	for _, cfg := range sources {
+		if cfg.Shadow == "" {
+			continue
+		}
+		if cfg.Shadow == cfg.Name || !seen[cfg.Shadow] {
+			return fmt.Errorf("data source %s: shadow %q is not another declared source", cfg.Name, cfg.Shadow)
+		}
+		if cfg.ShadowPercent < 0 || cfg.ShadowPercent > 100 {
+			return fmt.Errorf("data source %s: shadow percent must be between 0 and 100, got %d", cfg.Name, cfg.ShadowPercent)
+		}
+	}
+	return nil
+}
+
+func unknownDriverError(cfg config.DataSourceConfig) error {
+	if cfg.Type == "" {
+		return fmt.Errorf("data source %s has no type", cfg.Name)
+	}
+	return fmt.Errorf("data source %s: unknown type %q, registered types are %v", cfg.Name, cfg.Type, Drivers())
+}
@@ -0,0 +1,135 @@
+package datasource
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/config"
+)
+
+// memorySource is the data source of the test driver: it only remembers the
+// settings it was built from
+type memorySource struct {
+	sourceType DataSourceType
+	settings   map[string]string
+}
+
+func (s *memorySource) ExecuteQuery(ctx context.Context, query string, opts *QueryOptions) (*QueryResult, error) {
+	return &QueryResult{Source: s.sourceType}, nil
+}
+
+func (s *memorySource) GetData(ctx context.Context, table string, opts *QueryOptions) (*QueryResult, error) {
+	return &QueryResult{Source: s.sourceType}, nil
+}
+
+func (s *memorySource) TestConnection(ctx context.Context) error { return nil }
+
+func (s *memorySource) GetType() DataSourceType { return s.sourceType }
+
+func (s *memorySource) Close() error { return nil }
+
+var registerTestDriver sync.Once
+
+// useTestDriver registers "memory-postgres", an external driver as a build
+// adding Postgres would register it
+func useTestDriver() {
+	registerTestDriver.Do(func() {
+		Register("memory-postgres", Driver{
+			Type: DataSourcePostgres,
+			New: func(cfg config.DataSourceConfig, deps Dependencies) (DataSource, error) {
+				return &memorySource{sourceType: DataSourcePostgres, settings: cfg.Settings}, nil
+			},
+		})
+	})
+}
+
+func TestFactory_CreatesMixedSources(t *testing.T) {
+	useTestDriver()
+	factory := NewFactory(Dependencies{Logger: zap.NewNop()})
+
+	// Two instances of the external type next to the built-in ones
+	sources := []config.DataSourceConfig{
+		{Name: "DATAWAREHOUSE", Type: config.SourceTypeDremioArrow, Settings: map[string]string{"host": "dremio-a"}},
+		{Name: "ARCHIVE", Type: config.SourceTypeDremioREST, Settings: map[string]string{"host": "dremio-b"}},
+		{Name: "BIGQUERY", Type: config.SourceTypeBigQuery, Settings: map[string]string{"project_id": "lkpp"}},
+		{Name: "SIRUP", Type: "memory-postgres", Settings: map[string]string{"dsn": "postgres://sirup"}},
+		{Name: "SPSE", Type: "memory-postgres", Settings: map[string]string{"dsn": "postgres://spse"}},
+	}
+	require.NoError(t, CheckDataSources(sources))
+
+	for _, cfg := range sources[3:] {
+		source, err := factory.Create(cfg)
+		require.NoError(t, err, cfg.Name)
+		assert.Equal(t, DataSourcePostgres, source.GetType())
+		assert.Equal(t, cfg.Settings, source.(*memorySource).settings)
+	}
+
+	for _, name := range []string{config.SourceTypeDremioArrow, config.SourceTypeDremioREST, config.SourceTypeBigQuery} {
+		driver, ok := LookupDriver(name)
+		require.True(t, ok, name)
+		assert.NotEqual(t, DataSourceType(""), driver.Type, name)
+	}
+}
+
+func TestFactory_BuiltInDriversValidateSettings(t *testing.T) {
+	factory := NewFactory(Dependencies{})
+
+	tests := []struct {
+		cfg     config.DataSourceConfig
+		wantErr string
+	}{
+		{config.DataSourceConfig{Name: "DW", Type: config.SourceTypeDremioArrow}, "data source DW: host is required"},
+		{config.DataSourceConfig{Name: "DW", Type: config.SourceTypeDremioArrow, Settings: map[string]string{"host": "dremio", "port": "flight"}},
+			`data source DW: port must be an integer, got "flight"`},
+		{config.DataSourceConfig{Name: "ARCHIVE", Type: config.SourceTypeDremioREST}, "data source ARCHIVE: host is required"},
+		{config.DataSourceConfig{Name: "BQ", Type: config.SourceTypeBigQuery, Settings: map[string]string{"dataset_id": "rup"}},
+			"data source BQ: project_id is required"},
+	}
+	for _, tt := range tests {
+		_, err := factory.Create(tt.cfg)
+		assert.EqualError(t, err, tt.wantErr)
+	}
+}
+
+func TestCheckDataSources(t *testing.T) {
+	useTestDriver()
+
+	err := CheckDataSources([]config.DataSourceConfig{{Name: "MYSQL", Type: config.SourceTypeMySQL}})
+	assert.ErrorContains(t, err, `data source MYSQL: unknown type "mysql"`)
+
+	err = CheckDataSources([]config.DataSourceConfig{{Name: "DW"}})
+	assert.EqualError(t, err, "data source DW has no type")
+
+	err = CheckDataSources([]config.DataSourceConfig{
+		{Name: "DW", Type: "memory-postgres"},
+		{Name: "DW", Type: "memory-postgres"},
+	})
+	assert.EqualError(t, err, "data source DW is declared twice")
+
+	err = CheckDataSources([]config.DataSourceConfig{
+		{Name: "DW", Type: "memory-postgres", Shadow: "DW_CLOUD", ShadowPercent: 10},
+	})
+	assert.EqualError(t, err, `data source DW: shadow "DW_CLOUD" is not another declared source`)
+
+	err = CheckDataSources([]config.DataSourceConfig{
+		{Name: "DW", Type: "memory-postgres", Shadow: "DW_CLOUD", ShadowPercent: 150},
+		{Name: "DW_CLOUD", Type: "memory-postgres"},
+	})
+	assert.EqualError(t, err, "data source DW: shadow percent must be between 0 and 100, got 150")
+
+	_, err = NewFactory(Dependencies{}).Create(config.DataSourceConfig{Name: "X", Type: "oracle"})
+	assert.ErrorContains(t, err, `unknown type "oracle"`)
+}
+
+func TestRegister_Twice(t *testing.T) {
+	useTestDriver()
+	assert.Panics(t, func() {
+		Register("memory-postgres", Driver{New: func(config.DataSourceConfig, Dependencies) (DataSource, error) { return nil, nil }})
+	})
+	assert.Panics(t, func() { Register("no-constructor", Driver{}) })
+}
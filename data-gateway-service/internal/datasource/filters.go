@@ -0,0 +1,155 @@
+package datasource
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go-data-gateway/internal/sqlbuilder"
+)
+
+// Filter operators of a FilterSpec
+const (
+	FilterEq   = "eq"
+	FilterIn   = "in"
+	FilterGte  = "gte"
+	FilterLte  = "lte"
+	FilterLike = "like"
+)
+
+// maxInValues bounds the list of an in filter
+const maxInValues = sqlbuilder.MaxInValues
+
+// FilterSpec is a QueryOptions filter with an operator, in JSON
+// {"op": "gte", "value": 2024}. In Filters a plain value is shorthand for eq
+// and a list of specs applies each of them to the column.
+type FilterSpec struct {
+	Op    string      `json:"op"`
+	Value interface{} `json:"value"`
+}
+
+// filterSpecs normalizes a Filters value: a FilterSpec, a []FilterSpec, their
+// decoded JSON forms, or a plain value
+func filterSpecs(value interface{}) ([]FilterSpec, error) {
+	switch v := value.(type) {
+	case FilterSpec:
+		return []FilterSpec{v}, nil
+	case []FilterSpec:
+		if len(v) == 0 {
+			return nil, fmt.Errorf("empty filter list")
+		}
+		return v, nil
+	case map[string]interface{}:
+		spec, err := specFromMap(v)
+		if err != nil {
+			return nil, err
+		}
+		return []FilterSpec{spec}, nil
+	case []interface{}:
+		if len(v) == 0 {
+			return nil, fmt.Errorf("empty filter list")
+		}
+		specs := make([]FilterSpec, 0, len(v))
+		for _, item := range v {
+			m, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf(`a filter list holds {"op", "value"} objects; use the in operator to match a list of values`)
+			}
+			spec, err := specFromMap(m)
+			if err != nil {
+				return nil, err
+			}
+			specs = append(specs, spec)
+		}
+		return specs, nil
+	default:
+		return []FilterSpec{{Op: FilterEq, Value: value}}, nil
+	}
+}
+
+func specFromMap(m map[string]interface{}) (FilterSpec, error) {
+	for key := range m {
+		if key != "op" && key != "value" {
+			return FilterSpec{}, fmt.Errorf(`unknown filter key '%s' (expected "op" and "value")`, key)
+		}
+	}
+	op, ok := m["op"].(string)
+	if !ok || op == "" {
+		return FilterSpec{}, fmt.Errorf(`filter object is missing "op"`)
+	}
+	return FilterSpec{Op: op, Value: m["value"]}, nil
+}
+
+// BuildWhereClause turns filters into " WHERE a >= 1 AND b IN ('x', 'y')".
+// Columns are sorted so equal filters build equal SQL. Returns an empty
+// string when there are no filters.
+func (s *SQLSanitizer) BuildWhereClause(filters map[string]interface{}) (string, error) {
+	conds, err := filterConditions(filters)
+	if err != nil || len(conds) == 0 {
+		return "", err
+	}
+
+	conditions := make([]string, len(conds))
+	for i, cond := range conds {
+		if conditions[i], err = s.dialect.Render(cond); err != nil {
+			return "", err
+		}
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), nil
+}
+
+// filterConditions turns filters into conditions, sorted by column. Each is
+// checked to render, so an invalid filter is reported with its column.
+func filterConditions(filters map[string]interface{}) ([]sqlbuilder.Cond, error) {
+	columns := make([]string, 0, len(filters))
+	for column := range filters {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	var conds []sqlbuilder.Cond
+	for _, column := range columns {
+		safeColumn, err := sqlbuilder.ValidateColumn(column)
+		if err != nil {
+			return nil, err
+		}
+
+		specs, err := filterSpecs(filters[column])
+		if err != nil {
+			return nil, fmt.Errorf("filter on '%s': %w", safeColumn, err)
+		}
+		for _, spec := range specs {
+			cond, err := specCondition(safeColumn, spec)
+			if err == nil {
+				_, err = DialectANSI.Render(cond)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("filter on '%s': %w", safeColumn, err)
+			}
+			conds = append(conds, cond)
+		}
+	}
+	return conds, nil
+}
+
+// specCondition returns the condition of one filter on a validated column
+func specCondition(column string, spec FilterSpec) (sqlbuilder.Cond, error) {
+	switch strings.ToLower(spec.Op) {
+	case FilterEq:
+		return sqlbuilder.Eq(column, spec.Value), nil
+	case FilterGte:
+		return sqlbuilder.Gte(column, spec.Value), nil
+	case FilterLte:
+		return sqlbuilder.Lte(column, spec.Value), nil
+	case FilterLike:
+		pattern, ok := spec.Value.(string)
+		if !ok {
+			return nil, fmt.Errorf("like needs a string pattern")
+		}
+		return sqlbuilder.Like(column, pattern), nil
+	case FilterIn:
+		return sqlbuilder.In(column, spec.Value), nil
+	default:
+		return nil, fmt.Errorf("unknown operator '%s' (use eq, in, gte, lte or like)", spec.Op)
+	}
+}
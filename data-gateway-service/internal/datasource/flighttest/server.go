@@ -0,0 +1,300 @@
+// Package flighttest runs an in-process Arrow Flight server that answers the
+// calls the gateway makes to Dremio, so the Arrow path can be tested without
+// a cluster. Like net/http/httptest, it listens on a loopback port.
+package flighttest
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/flight"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ErrInvalidCredentials is what Dremio answers a call with wrong or missing
+// credentials
+var ErrInvalidCredentials = status.Error(codes.Unauthenticated, "Invalid username or password")
+
+// Result is the result set of a query: rows of values in schema order. Values
+// may be nil, int32, int64, float64, string, bool or time.Time.
+type Result struct {
+	Schema *arrow.Schema
+	Rows   [][]interface{}
+}
+
+// One is the result of SELECT 1, which Dremio names EXPR$0
+var One = Result{
+	Schema: arrow.NewSchema([]arrow.Field{{Name: "EXPR$0", Type: arrow.PrimitiveTypes.Int32}}, nil),
+	Rows:   [][]interface{}{{int32(1)}},
+}
+
+// rule answers the queries containing fragment with result or err
+type rule struct {
+	fragment string
+	result   Result
+	err      error
+}
+
+// Server is a Flight server answering GetFlightInfo, DoGet and ListActions.
+// A query is answered by the first rule, in the order they were added, whose
+// fragment it contains; a query matching none returns One.
+type Server struct {
+	flight.BaseFlightServer
+
+	server flight.Server
+	host   string
+	port   int
+
+	mu       sync.Mutex
+	accepted map[string]bool // Authorization headers accepted; none accepts any call
+	latency  time.Duration
+	rules    []rule
+	queries  []string
+	tickets  map[string]Result
+	nextJob  int
+}
+
+// NewServer starts a server on a loopback port; Close stops it
+func NewServer() *Server {
+	s := &Server{tickets: make(map[string]Result)}
+	s.server = flight.NewServerWithMiddleware(nil)
+	if err := s.server.Init("127.0.0.1:0"); err != nil {
+		panic(fmt.Sprintf("flighttest: failed to listen: %v", err))
+	}
+	s.server.RegisterFlightService(s)
+	addr := s.server.Addr().(*net.TCPAddr)
+	s.host, s.port = addr.IP.String(), addr.Port
+	go s.server.Serve()
+	return s
+}
+
+// Host is the address the server listens on
+func (s *Server) Host() string {
+	return s.host
+}
+
+// Port is the port the server listens on
+func (s *Server) Port() int {
+	return s.port
+}
+
+// Close stops the server, waiting for the calls in progress
+func (s *Server) Close() {
+	s.server.Shutdown()
+}
+
+// SetCredentials makes every call require basic auth with username and
+// password; calls without them fail with ErrInvalidCredentials
+func (s *Server) SetCredentials(username, password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.accepted = map[string]bool{basicAuth(username, password): true}
+}
+
+// AddCredentials accepts basic auth with username and password as well as
+// the credentials already accepted, as Dremio does while a service account's
+// password is being rotated
+func (s *Server) AddCredentials(username, password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.accepted == nil {
+		s.accepted = make(map[string]bool)
+	}
+	s.accepted[basicAuth(username, password)] = true
+}
+
+// RevokeCredentials stops accepting basic auth with username and password;
+// calls already authenticated with them complete
+func (s *Server) RevokeCredentials(username, password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.accepted, basicAuth(username, password))
+}
+
+// SetLatency delays every GetFlightInfo by d, or until the call is cancelled
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// Respond answers the queries containing fragment with result
+func (s *Server) Respond(fragment string, result Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules = append(s.rules, rule{fragment: fragment, result: result})
+}
+
+// Fail fails the queries containing fragment with err, e.g. a status error
+// such as Dremio's; an empty fragment fails every query
+func (s *Server) Fail(fragment string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules = append(s.rules, rule{fragment: fragment, err: err})
+}
+
+// Queries returns the commands of the GetFlightInfo calls received, in order
+func (s *Server) Queries() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.queries...)
+}
+
+// GetFlightInfo plans a query: it returns an endpoint whose ticket DoGet
+// streams the query's result from. The ticket is a Dremio-style job id.
+func (s *Server) GetFlightInfo(ctx context.Context, desc *flight.FlightDescriptor) (*flight.FlightInfo, error) {
+	if err := s.authenticate(ctx); err != nil {
+		return nil, err
+	}
+	query := string(desc.GetCmd())
+
+	s.mu.Lock()
+	s.queries = append(s.queries, query)
+	latency := s.latency
+	s.mu.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+	}
+
+	result, err := s.answer(query)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.nextJob++
+	ticket := fmt.Sprintf("1a2b3c4d-0000-4000-8000-%012x", s.nextJob)
+	s.tickets[ticket] = result
+	s.mu.Unlock()
+
+	return &flight.FlightInfo{
+		Schema:           flight.SerializeSchema(result.Schema, memory.DefaultAllocator),
+		FlightDescriptor: desc,
+		Endpoint:         []*flight.FlightEndpoint{{Ticket: &flight.Ticket{Ticket: []byte(ticket)}}},
+		TotalRecords:     int64(len(result.Rows)),
+		TotalBytes:       -1,
+	}, nil
+}
+
+// DoGet streams the result of a ticket GetFlightInfo returned, once
+func (s *Server) DoGet(ticket *flight.Ticket, stream flight.FlightService_DoGetServer) error {
+	if err := s.authenticate(stream.Context()); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	result, ok := s.tickets[string(ticket.GetTicket())]
+	delete(s.tickets, string(ticket.GetTicket()))
+	s.mu.Unlock()
+	if !ok {
+		return status.Errorf(codes.NotFound, "unknown ticket %q", ticket.GetTicket())
+	}
+
+	record := result.record()
+	defer record.Release()
+
+	writer := flight.NewRecordWriter(stream, ipc.WithSchema(result.Schema))
+	if err := writer.Write(record); err != nil {
+		return err
+	}
+	return writer.Close()
+}
+
+// ListActions lists no actions; the gateway calls it to check credentials
+func (s *Server) ListActions(_ *flight.Empty, stream flight.FlightService_ListActionsServer) error {
+	return s.authenticate(stream.Context())
+}
+
+// answer returns the result of query by the first rule matching it
+func (s *Server) answer(query string) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.rules {
+		if !strings.Contains(query, r.fragment) {
+			continue
+		}
+		if r.err != nil {
+			if _, ok := status.FromError(r.err); !ok {
+				return Result{}, status.Error(codes.Internal, r.err.Error())
+			}
+			return Result{}, r.err
+		}
+		return r.result, nil
+	}
+	return One, nil
+}
+
+// authenticate checks the basic auth of a call when credentials are set
+func (s *Server) authenticate(ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.accepted == nil {
+		return nil
+	}
+	for _, got := range md.Get("authorization") {
+		if s.accepted[got] {
+			return nil
+		}
+	}
+	return ErrInvalidCredentials
+}
+
+// basicAuth is the authorization header of username and password
+func basicAuth(username, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+}
+
+// record builds the rows of r into one record
+func (r Result) record() arrow.Record {
+	builder := array.NewRecordBuilder(memory.DefaultAllocator, r.Schema)
+	defer builder.Release()
+
+	for _, row := range r.Rows {
+		for i, value := range row {
+			appendValue(builder.Field(i), value)
+		}
+	}
+	return builder.NewRecord()
+}
+
+// appendValue appends value to a column builder of a matching type
+func appendValue(b array.Builder, value interface{}) {
+	if value == nil {
+		b.AppendNull()
+		return
+	}
+	switch b := b.(type) {
+	case *array.Int32Builder:
+		b.Append(value.(int32))
+	case *array.Int64Builder:
+		b.Append(value.(int64))
+	case *array.Float64Builder:
+		b.Append(value.(float64))
+	case *array.StringBuilder:
+		b.Append(value.(string))
+	case *array.BooleanBuilder:
+		b.Append(value.(bool))
+	case *array.TimestampBuilder:
+		b.AppendTime(value.(time.Time))
+	default:
+		panic(fmt.Sprintf("flighttest: unsupported column type %s", b.Type()))
+	}
+}
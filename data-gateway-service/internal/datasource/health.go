@@ -0,0 +1,19 @@
+package datasource
+
+import "context"
+
+// DeepChecker is implemented by data sources whose TestConnection is a cheap
+// metadata call and that can also check by running a query
+type DeepChecker interface {
+	DeepCheck(ctx context.Context) error
+}
+
+// DeepCheck checks source by running a query when it is a DeepChecker, and
+// with TestConnection otherwise. Queries may be billed and appear in audit
+// logs, so only on-demand checks should use it.
+func DeepCheck(ctx context.Context, source DataSource) error {
+	if d, ok := source.(DeepChecker); ok {
+		return d.DeepCheck(ctx)
+	}
+	return source.TestConnection(ctx)
+}
@@ -0,0 +1,126 @@
+package datasource
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/api/option"
+
+	"go-data-gateway/internal/clients"
+	"go-data-gateway/internal/config"
+)
+
+// pathRecorder records the request paths a fake upstream receives
+type pathRecorder struct {
+	mu    sync.Mutex
+	paths []string
+}
+
+func (p *pathRecorder) record(r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.paths = append(p.paths, r.Method+" "+r.URL.Path)
+}
+
+// any reports whether a recorded path contains part
+func (p *pathRecorder) any(part string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, path := range p.paths {
+		if strings.Contains(path, part) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestBigQuery_ProbeRunsNoJob(t *testing.T) {
+	var recorder pathRecorder
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder.record(r)
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "/datasets") {
+			json.NewEncoder(w).Encode(map[string]interface{}{"kind": "bigquery#datasetList"})
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": map[string]interface{}{"code": http.StatusBadRequest, "message": "stop here"},
+		})
+	}))
+	defer srv.Close()
+
+	client, err := clients.NewBigQueryClient(
+		config.BigQueryConfig{ProjectID: "test-project"},
+		zap.NewNop(),
+		option.WithEndpoint(srv.URL),
+		option.WithHTTPClient(srv.Client()),
+		option.WithoutAuthentication(),
+	)
+	require.NoError(t, err)
+	wrapper := &BigQueryWrapper{client: client, logger: zap.NewNop(), sanitizer: NewSQLSanitizer()}
+	defer wrapper.Close()
+
+	require.NoError(t, wrapper.TestConnection(context.Background()))
+	assert.True(t, recorder.any("/projects/test-project/datasets"))
+	assert.False(t, recorder.any("/queries"))
+	assert.False(t, recorder.any("/jobs"))
+
+	// The deep check runs a query job
+	assert.Error(t, DeepCheck(context.Background(), wrapper))
+	assert.True(t, recorder.any("/queries") || recorder.any("/jobs"))
+}
+
+func TestDremioREST_ProbeRunsNoJob(t *testing.T) {
+	var recorder pathRecorder
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder.record(r)
+		switch {
+		case r.URL.Path == "/api/v3/catalog":
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": []map[string]string{{"id": "space-1"}}})
+		case r.URL.Path == "/api/v3/sql":
+			json.NewEncoder(w).Encode(map[string]string{"id": "job-1"})
+		case strings.HasSuffix(r.URL.Path, "/results"):
+			json.NewEncoder(w).Encode(map[string]interface{}{"rowCount": 1, "rows": []map[string]interface{}{{"EXPR$0": 1}}})
+		default:
+			json.NewEncoder(w).Encode(map[string]string{"jobState": "COMPLETED"})
+		}
+	}))
+	defer srv.Close()
+
+	host, port := hostPort(t, srv.URL)
+	ds, err := NewDremioRESTClient(host, port, "", "", zap.NewNop())
+	require.NoError(t, err)
+
+	require.NoError(t, ds.TestConnection(context.Background()))
+	require.NoError(t, ds.TestConnection(context.Background()))
+	assert.True(t, recorder.any("GET /api/v3/catalog"))
+	assert.False(t, recorder.any("/api/v3/sql"))
+
+	require.NoError(t, DeepCheck(context.Background(), ds))
+	assert.True(t, recorder.any("POST /api/v3/sql"))
+}
+
+func TestDremioREST_ProbeReportsAuthFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"errorMessage": "Invalid token"})
+	}))
+	defer srv.Close()
+
+	host, port := hostPort(t, srv.URL)
+	ds, err := NewDremioRESTClient(host, port, "", "", zap.NewNop())
+	require.NoError(t, err)
+
+	err = ds.TestConnection(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Invalid token")
+}
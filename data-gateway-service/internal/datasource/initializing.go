@@ -0,0 +1,38 @@
+package datasource
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// InitializingError is returned for a configured data source whose
+// construction failed and is being retried in the background
+type InitializingError struct {
+	Source     string
+	RetryAfter time.Duration // Until the next construction attempt
+	Err        error         // Last construction error
+}
+
+func (e *InitializingError) Error() string {
+	return fmt.Sprintf("data source %s is initializing: %v", e.Source, e.Err)
+}
+
+func (e *InitializingError) Unwrap() error {
+	return e.Err
+}
+
+// Readier is implemented by data sources that may not be usable yet
+type Readier interface {
+	Ready(ctx context.Context) error
+}
+
+// CheckReady reports an *InitializingError when source is still being
+// constructed. Handlers that commit a response before querying, like
+// streams, check this first.
+func CheckReady(ctx context.Context, source DataSource) error {
+	if r, ok := source.(Readier); ok {
+		return r.Ready(ctx)
+	}
+	return nil
+}
@@ -3,6 +3,9 @@ package datasource
 import (
 	"context"
 	"time"
+
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/sqltext"
 )
 
 // DataSourceType represents the type of data source
@@ -15,6 +18,14 @@ const (
 	DataSourcePostgres DataSourceType = "POSTGRES"
 )
 
+// Dialect returns how the engine of t escapes quotes in SQL
+func (t DataSourceType) Dialect() sqltext.Dialect {
+	if t == DataSourceBigQuery {
+		return sqltext.BigQuery
+	}
+	return sqltext.Standard
+}
+
 // QueryResult represents the result of a query
 type QueryResult struct {
 	Data      []map[string]interface{} `json:"data"`
@@ -35,6 +46,42 @@ type QueryOptions struct {
 	CacheTTL   time.Duration
 	Timeout    time.Duration
 	Parameters []interface{}
+
+	// SkipCache reads through any result cache to the source and leaves the
+	// cached entry untouched; it is set by the gateway, never by callers
+	SkipCache bool `json:"-"`
+
+	// SkipCacheEmpty leaves a result without rows uncached, for lookups that
+	// remember what they did not find on their own and for less long; it is
+	// set by the gateway, never by callers
+	SkipCacheEmpty bool `json:"-"`
+
+	// MaxAge rejects cached results older than this; they are re-executed and
+	// the cache refreshed. Zero accepts a cached result of any age.
+	MaxAge time.Duration `json:"-"`
+
+	// Tiebreaker is a unique column ordered by after OrderBy, or alone when a
+	// page is read without one, so rows tied on OrderBy keep their page; it
+	// is set by the gateway from the configured tiebreakers, never by callers
+	Tiebreaker string `json:"-"`
+
+	// Keywords adds a keyword search to the filters; it is set by search
+	// handlers from their configured columns, never by callers
+	Keywords *KeywordMatch `json:"-"`
+
+	// After reads only the rows past a keyset position of the same order; it
+	// is set by the gateway from a resume token, never by callers
+	After *Keyset `json:"-"`
+
+	// EngineOptions are Dremio session options for this query; they are set
+	// by the query handler from an allowlist, never by other callers
+	EngineOptions EngineOptions `json:"-"`
+
+	// AsOf reads the table as it was at this time, on AsOfBranch when it is
+	// set; they are set by the gateway from a validated as_of, never by
+	// callers. Zero reads the current table.
+	AsOf       time.Time `json:"-"`
+	AsOfBranch string    `json:"-"`
 }
 
 // DataSource defines the interface for all data sources
@@ -55,7 +102,7 @@ type DataSource interface {
 	Close() error
 }
 
-// Factory creates data sources based on type
+// Factory creates the data sources declared in configuration
 type Factory interface {
-	Create(sourceType DataSourceType) (DataSource, error)
+	Create(cfg config.DataSourceConfig) (DataSource, error)
 }
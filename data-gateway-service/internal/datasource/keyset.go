@@ -0,0 +1,59 @@
+package datasource
+
+import (
+	"strings"
+	"time"
+
+	"go-data-gateway/internal/sqlbuilder"
+)
+
+// Keyset is a position in an ordered read: the values its ORDER BY columns
+// had in the last row read. A read After it continues with the next row.
+type Keyset struct {
+	Columns []string      `json:"columns"`
+	Values  []interface{} `json:"values"`
+	Desc    bool          `json:"desc,omitempty"`
+}
+
+// keysetTimeLayout renders timestamps as literals Dremio and BigQuery both
+// compare to a TIMESTAMP column
+const keysetTimeLayout = "2006-01-02 15:04:05.999999"
+
+// KeysetAfter returns the position of row in a read ordered by opts: the
+// values of its OrderBy and tiebreaker columns. It reports false when the
+// order is not unique, having no tiebreaker, or when row lacks a value or
+// holds a null or a value that has no literal.
+func KeysetAfter(opts *QueryOptions, row map[string]interface{}) (*Keyset, bool) {
+	if opts == nil || opts.Tiebreaker == "" {
+		return nil, false
+	}
+	keyset := &Keyset{Desc: strings.EqualFold(strings.TrimSpace(opts.OrderDir), "DESC")}
+	orderBy := strings.NewReplacer("`", "", "'", "", `"`, "").Replace(opts.OrderBy)
+	if orderBy != "" {
+		keyset.Columns = append(keyset.Columns, orderBy)
+	}
+	if !strings.EqualFold(opts.Tiebreaker, orderBy) {
+		keyset.Columns = append(keyset.Columns, opts.Tiebreaker)
+	}
+
+	for _, column := range keyset.Columns {
+		value := row[column]
+		if t, ok := value.(time.Time); ok {
+			value = t.UTC().Format(keysetTimeLayout)
+		}
+		if value == nil {
+			return nil, false
+		}
+		if _, err := DialectANSI.Literal(value); err != nil {
+			return nil, false
+		}
+		keyset.Values = append(keyset.Values, value)
+	}
+	return keyset, true
+}
+
+// KeysetCondition renders the rows after k, e.g.
+// (a > 1 OR (a = 1 AND b > 'x')); a descending keyset compares with <
+func (s *SQLSanitizer) KeysetCondition(k Keyset) (string, error) {
+	return s.dialect.Render(sqlbuilder.After(k.Columns, k.Values, k.Desc))
+}
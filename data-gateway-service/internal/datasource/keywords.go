@@ -0,0 +1,59 @@
+package datasource
+
+import (
+	"fmt"
+
+	"go-data-gateway/internal/sqlbuilder"
+)
+
+// KeywordMatch searches columns for keywords. Each term matches as a
+// case-insensitive substring of any of the columns.
+type KeywordMatch struct {
+	Columns []string `json:"columns"`
+	Terms   []string `json:"terms"`
+	All     bool     `json:"all"` // Every term must match; otherwise any one does
+}
+
+// KeywordCondition renders m as a condition, e.g.
+// (LOWER(a) LIKE LOWER('%x%') OR LOWER(b) LIKE LOWER('%x%')) for one term.
+// Several terms are joined with AND when m.All is set, OR otherwise.
+func (s *SQLSanitizer) KeywordCondition(m KeywordMatch) (string, error) {
+	cond, err := m.Condition()
+	if err != nil {
+		return "", err
+	}
+	return s.dialect.Render(cond)
+}
+
+// Condition returns the condition of m for a sqlbuilder statement: per term,
+// a Contains of each column joined with OR
+func (m KeywordMatch) Condition() (sqlbuilder.Cond, error) {
+	if len(m.Columns) == 0 {
+		return nil, fmt.Errorf("no keyword columns configured")
+	}
+	if len(m.Terms) == 0 {
+		return nil, fmt.Errorf("no keywords to match")
+	}
+	for _, column := range m.Columns {
+		if _, err := sqlbuilder.ValidateColumn(column); err != nil {
+			return nil, fmt.Errorf("keyword column: %w", err)
+		}
+	}
+
+	terms := make([]sqlbuilder.Cond, len(m.Terms))
+	for i, term := range m.Terms {
+		matches := make([]sqlbuilder.Cond, len(m.Columns))
+		for j, column := range m.Columns {
+			matches[j] = sqlbuilder.Contains(column, term)
+		}
+		terms[i] = sqlbuilder.Or(matches...)
+	}
+	switch {
+	case len(terms) == 1:
+		return terms[0], nil
+	case m.All:
+		return sqlbuilder.And(terms...), nil
+	default:
+		return sqlbuilder.Or(terms...), nil
+	}
+}
@@ -0,0 +1,62 @@
+package datasource
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeywordCondition_Escaping(t *testing.T) {
+	m := KeywordMatch{Columns: []string{"nama_paket"}, Terms: []string{"100%_off"}}
+
+	ansi := NewSQLSanitizer()
+	cond, err := ansi.KeywordCondition(m)
+	require.NoError(t, err)
+	assert.Equal(t, `(LOWER(nama_paket) LIKE LOWER('%100\%\_off%') ESCAPE '\')`, cond)
+
+	bigQuery := NewSQLSanitizer()
+	bigQuery.SetDialect(DialectBigQuery)
+	cond, err = bigQuery.KeywordCondition(m)
+	require.NoError(t, err)
+	assert.Equal(t, `(LOWER(nama_paket) LIKE LOWER('%100\\%\\_off%'))`, cond)
+
+	cond, err = ansi.KeywordCondition(KeywordMatch{Columns: []string{"nama_paket"}, Terms: []string{`O'Brien\`}})
+	require.NoError(t, err)
+	assert.Equal(t, `(LOWER(nama_paket) LIKE LOWER('%O''Brien\\%') ESCAPE '\')`, cond)
+}
+
+func TestKeywordCondition_Terms(t *testing.T) {
+	s := NewSQLSanitizer()
+	m := KeywordMatch{Columns: []string{"nama_kro", "nama_klpd"}, Terms: []string{"jalan", "jembatan"}}
+
+	cond, err := s.KeywordCondition(m)
+	require.NoError(t, err)
+	assert.Equal(t, `((LOWER(nama_kro) LIKE LOWER('%jalan%') ESCAPE '\' OR LOWER(nama_klpd) LIKE LOWER('%jalan%') ESCAPE '\')`+
+		` OR (LOWER(nama_kro) LIKE LOWER('%jembatan%') ESCAPE '\' OR LOWER(nama_klpd) LIKE LOWER('%jembatan%') ESCAPE '\'))`, cond)
+
+	m.All = true
+	cond, err = s.KeywordCondition(m)
+	require.NoError(t, err)
+	assert.Contains(t, cond, `ESCAPE '\') AND (LOWER(nama_kro)`)
+
+	for _, invalid := range []KeywordMatch{
+		{Terms: []string{"jalan"}},
+		{Columns: []string{"nama_kro"}},
+		{Columns: []string{"1=1 OR nama_kro"}, Terms: []string{"jalan"}},
+	} {
+		_, err := s.KeywordCondition(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestBuildSelectQuery_Keywords(t *testing.T) {
+	s := NewSQLSanitizer()
+	query, err := s.BuildSelectQuery("tender_data", nil, &QueryOptions{
+		Filters:  map[string]interface{}{"tahun_anggaran": 2025},
+		Keywords: &KeywordMatch{Columns: []string{"nama_paket"}, Terms: []string{"jalan"}},
+		Limit:    10,
+	})
+	require.NoError(t, err)
+	assert.Contains(t, query, `WHERE tahun_anggaran = 2025 AND (LOWER(nama_paket) LIKE LOWER('%jalan%') ESCAPE '\')`)
+}
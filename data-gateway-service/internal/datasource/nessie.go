@@ -0,0 +1,89 @@
+package datasource
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"go-data-gateway/internal/clients"
+	"go-data-gateway/internal/config"
+)
+
+// VersionRef is the Nessie branch or tag a request reads the Dremio Iceberg
+// tables at
+type VersionRef struct {
+	Source  string // Catalog source, e.g. nessie_iceberg
+	Type    string // BRANCH or TAG
+	Name    string
+	Default string // Branch sessions return to after the query
+}
+
+// Reference names are quoted into USE statements; the allowlist is the real
+// guard, this keeps a misconfigured one from breaking out
+var versionName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.\-/]*$`)
+
+// ResolveVersion returns the reference name selects, the default branch
+// when name is empty. Names not in the configured allowlist are rejected.
+func ResolveVersion(cfg config.NessieConfig, name string) (VersionRef, error) {
+	if name == "" {
+		name = cfg.DefaultBranch()
+	}
+	refType := cfg.RefType(name)
+	if refType == "" || !versionName.MatchString(name) {
+		return VersionRef{}, fmt.Errorf("branch %q is not allowed", name)
+	}
+	if !versionName.MatchString(cfg.DefaultBranch()) || !versionName.MatchString(cfg.Source) {
+		return VersionRef{}, fmt.Errorf("invalid Nessie configuration: source %q, default branch %q", cfg.Source, cfg.DefaultBranch())
+	}
+	return VersionRef{Source: cfg.Source, Type: refType, Name: name, Default: cfg.DefaultBranch()}, nil
+}
+
+// IsDefault reports whether the reference is the default branch, which
+// queries read without any statements or references
+func (v VersionRef) IsDefault() bool {
+	return v.Name == "" || (v.Type == "BRANCH" && v.Name == v.Default)
+}
+
+// sessionStatements returns the USE statement that moves a session to the
+// reference and the one that moves it back to the default branch
+func (v VersionRef) sessionStatements() (set, reset []string) {
+	if v.IsDefault() {
+		return nil, nil
+	}
+	set = []string{fmt.Sprintf(`USE %s "%s" IN "%s"`, v.Type, v.Name, v.Source)}
+	reset = []string{fmt.Sprintf(`USE BRANCH "%s" IN "%s"`, v.Default, v.Source)}
+	return set, reset
+}
+
+// references returns the reference as the SQL API sends it in a job's
+// context
+func (v VersionRef) references() map[string]clients.VersionReference {
+	if v.IsDefault() {
+		return nil
+	}
+	return map[string]clients.VersionReference{v.Source: {Type: v.Type, Value: v.Name}}
+}
+
+type versionKey struct{}
+
+// WithVersion stores the Nessie reference of the request in the context
+func WithVersion(ctx context.Context, v VersionRef) context.Context {
+	return context.WithValue(ctx, versionKey{}, v)
+}
+
+// VersionFromContext returns the Nessie reference of the request, if any
+func VersionFromContext(ctx context.Context) (VersionRef, bool) {
+	v, ok := ctx.Value(versionKey{}).(VersionRef)
+	return v, ok
+}
+
+// VersionCacheKey is the part of a cache key that keeps results read at
+// different Nessie references apart. It is empty for the default branch, so
+// those keys are the ones cached before references existed.
+func VersionCacheKey(ctx context.Context) string {
+	v, _ := VersionFromContext(ctx)
+	if v.IsDefault() {
+		return ""
+	}
+	return v.Source + "@" + v.Type + ":" + v.Name
+}
@@ -0,0 +1,120 @@
+package datasource
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-data-gateway/internal/clients"
+	"go-data-gateway/internal/config"
+)
+
+var testNessie = config.NessieConfig{
+	Source:   "nessie_iceberg",
+	Branches: []string{"main", "staging"},
+	Tags:     []string{"2024-q4"},
+}
+
+func TestResolveVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		ref     string
+		want    VersionRef
+		wantErr string
+	}{
+		{"default", "", VersionRef{Source: "nessie_iceberg", Type: "BRANCH", Name: "main", Default: "main"}, ""},
+		{"branch", "staging", VersionRef{Source: "nessie_iceberg", Type: "BRANCH", Name: "staging", Default: "main"}, ""},
+		{"tag", "2024-q4", VersionRef{Source: "nessie_iceberg", Type: "TAG", Name: "2024-q4", Default: "main"}, ""},
+		{"not allowed", "dev", VersionRef{}, `branch "dev" is not allowed`},
+		{"statement", `main" IN x; DROP TABLE y; --`, VersionRef{}, "is not allowed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveVersion(testNessie, tt.ref)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func versionContext(t *testing.T, name string) context.Context {
+	t.Helper()
+	version, err := ResolveVersion(testNessie, name)
+	require.NoError(t, err)
+	return WithVersion(context.Background(), version)
+}
+
+func TestDremioArrowClient_UsesBranchBeforeEngineOptions(t *testing.T) {
+	client, conn := newPooledFake(2)
+
+	result, err := client.ExecuteQuery(versionContext(t, "staging"), "SELECT id FROM tender_data",
+		&QueryOptions{EngineOptions: EngineOptions{"planner.slice_target": 1000.0}})
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Count)
+
+	assert.Equal(t, []string{
+		`USE BRANCH "staging" IN "nessie_iceberg"`,
+		`ALTER SESSION SET "planner.slice_target" = 1000`,
+		"SELECT id FROM tender_data",
+		`USE BRANCH "main" IN "nessie_iceberg"`,
+		`ALTER SESSION RESET "planner.slice_target"`,
+	}, conn.queries)
+
+	// The default branch runs no statements, and tags are read with USE TAG
+	conn.queries = nil
+	_, err = client.ExecuteQuery(versionContext(t, "main"), "SELECT id FROM rup", nil)
+	require.NoError(t, err)
+	_, err = client.ExecuteQuery(versionContext(t, "2024-q4"), "SELECT id FROM rup", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"SELECT id FROM rup",
+		`USE TAG "2024-q4" IN "nessie_iceberg"`,
+		"SELECT id FROM rup",
+		`USE BRANCH "main" IN "nessie_iceberg"`,
+	}, conn.queries, "the tag's result is not served from the main branch's cache")
+}
+
+func TestDremioArrowClient_BranchResetAfterFailedQuery(t *testing.T) {
+	client, conn := newPooledFake(1)
+	conn.fail = func(statement string) bool { return strings.HasPrefix(statement, "SELECT") }
+
+	_, err := client.ExecuteQuery(versionContext(t, "staging"), "SELECT id FROM tender_data", nil)
+	require.Error(t, err)
+
+	assert.Equal(t, []string{
+		`USE BRANCH "staging" IN "nessie_iceberg"`,
+		"SELECT id FROM tender_data",
+		`USE BRANCH "main" IN "nessie_iceberg"`,
+	}, conn.queries)
+	assert.Len(t, client.pool.connections, 1)
+}
+
+func TestDremioArrowClient_BranchNeedsPool(t *testing.T) {
+	client, _ := newPooledFake(1)
+	client.usePool = false
+
+	_, err := client.ExecuteQuery(versionContext(t, "staging"), "SELECT id FROM tender_data", nil)
+	assert.EqualError(t, err, "reading branch staging needs a pooled Flight connection")
+}
+
+func TestDremioRESTWrapper_SendsBranchReference(t *testing.T) {
+	restSource, dremio := newRESTFake(1)
+
+	_, err := restSource.ExecuteQuery(versionContext(t, "staging"), "SELECT id FROM tender_data", nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]clients.VersionReference{"nessie_iceberg": {Type: "BRANCH", Value: "staging"}}, dremio.references)
+	assert.Empty(t, dremio.options)
+
+	// The default branch is read without references
+	dremio.references = nil
+	_, err = restSource.ExecuteQuery(versionContext(t, "main"), "SELECT id FROM tender_data", nil)
+	require.NoError(t, err)
+	assert.Nil(t, dremio.references)
+}
@@ -0,0 +1,42 @@
+package datasource
+
+import (
+	"context"
+	"fmt"
+)
+
+// QueryPlan is what a source would do for a query or table read, reported
+// without running it
+type QueryPlan struct {
+	SQL      string // Statement sent upstream, after paging and sanitization
+	CacheKey string // Key the result would be cached under; empty when uncached
+}
+
+// Planner is implemented by data sources that can report the SQL and cache
+// key of a query or table read without running it
+type Planner interface {
+	PlanQuery(ctx context.Context, query string, opts *QueryOptions) (*QueryPlan, error)
+	PlanTable(ctx context.Context, table string, opts *QueryOptions) (*QueryPlan, error)
+}
+
+// PlanQuery reports how source would run query. Sources that are not a
+// Planner run it as submitted.
+func PlanQuery(ctx context.Context, source DataSource, query string, opts *QueryOptions) (*QueryPlan, error) {
+	if p, ok := source.(Planner); ok {
+		return p.PlanQuery(ctx, query, opts)
+	}
+	return &QueryPlan{SQL: query}, nil
+}
+
+// PlanTable reports the query source would run for a GetData of table.
+// Sources that are not a Planner are assumed to build it as Dremio does.
+func PlanTable(ctx context.Context, source DataSource, table string, opts *QueryOptions) (*QueryPlan, error) {
+	if p, ok := source.(Planner); ok {
+		return p.PlanTable(ctx, table, opts)
+	}
+	query, err := newTableSanitizer().BuildSafeTableQuery(table, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidTableQuery, err)
+	}
+	return &QueryPlan{SQL: query}, nil
+}
@@ -0,0 +1,24 @@
+package datasource
+
+// PoolReporter is implemented by data sources that query through a
+// connection pool, such as the Arrow Flight client
+type PoolReporter interface {
+	GetPoolMetrics() map[string]interface{}
+	// ResetPoolLatency drops the recorded connection acquire waits
+	ResetPoolLatency()
+}
+
+// PoolMetrics returns the pool metrics of source, nil when it has no pool
+func PoolMetrics(source DataSource) map[string]interface{} {
+	if p, ok := source.(PoolReporter); ok {
+		return p.GetPoolMetrics()
+	}
+	return nil
+}
+
+// ResetPoolLatency resets the acquire waits of the pool of source, if any
+func ResetPoolLatency(source DataSource) {
+	if p, ok := source.(PoolReporter); ok {
+		p.ResetPoolLatency()
+	}
+}
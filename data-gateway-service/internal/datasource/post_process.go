@@ -0,0 +1,317 @@
+package datasource
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-data-gateway/internal/config"
+)
+
+// ErrPostProcessLimit rejects a result too large to process in memory
+var ErrPostProcessLimit = errors.New("result is too large to process in the gateway")
+
+// SortKey is a column rows are ordered by in the gateway
+type SortKey struct {
+	Column string `json:"column"`
+	Desc   bool   `json:"desc,omitempty"`
+}
+
+// PostProcess orders, deduplicates and pages rows in the gateway, for
+// results their source could not order, such as the rows of a diff. Rows
+// are made distinct on Distinct first, keeping the first of each, then
+// ordered by OrderBy, then paged by Offset and Limit.
+type PostProcess struct {
+	OrderBy  []SortKey `json:"order_by,omitempty"`
+	Distinct []string  `json:"distinct,omitempty"`
+	Limit    int       `json:"limit,omitempty"`
+	Offset   int       `json:"offset,omitempty"`
+}
+
+// Validate checks the shape of p; its columns are checked against the rows
+// it is applied to
+func (p PostProcess) Validate() error {
+	var errs []error
+	for i, key := range p.OrderBy {
+		if key.Column == "" {
+			errs = append(errs, fmt.Errorf("order_by[%d] needs a column", i))
+		}
+	}
+	for i, column := range p.Distinct {
+		if column == "" {
+			errs = append(errs, fmt.Errorf("distinct[%d] needs a column", i))
+		}
+	}
+	if p.Limit < 0 {
+		errs = append(errs, fmt.Errorf("limit must not be negative"))
+	}
+	if p.Offset < 0 {
+		errs = append(errs, fmt.Errorf("offset must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+// Apply returns the rows p selects, in order. Columns are compared by their
+// type in schema, or in the schema of rows when schema is nil.
+func (p PostProcess) Apply(rows []map[string]interface{}, schema []ColumnField, limits config.PostProcessConfig) ([]map[string]interface{}, error) {
+	indexes, err := p.Indexes(rows, schema, limits)
+	if err != nil {
+		return nil, err
+	}
+	processed := make([]map[string]interface{}, len(indexes))
+	for i, index := range indexes {
+		processed[i] = rows[index]
+	}
+	return processed, nil
+}
+
+// Indexes returns the indexes in rows of the rows p selects, in order, for
+// callers that process rows paired with other values. Ordering is stable:
+// rows tied on every sort key keep their order. NULLs and missing values
+// sort last in either direction. Results over limits are ErrPostProcessLimit.
+func (p PostProcess) Indexes(rows []map[string]interface{}, schema []ColumnField, limits config.PostProcessConfig) ([]int, error) {
+	if err := checkPostProcessLimits(rows, limits); err != nil {
+		return nil, err
+	}
+	if schema == nil {
+		schema = ResultSchema(rows)
+	}
+	types := make(map[string]string, len(schema))
+	for _, column := range schema {
+		types[column.Name] = column.Type
+	}
+	if len(rows) > 0 {
+		for _, column := range p.columns() {
+			if _, ok := types[column]; !ok {
+				return nil, fmt.Errorf("unknown column %q", column)
+			}
+		}
+	}
+
+	indexes := make([]int, 0, len(rows))
+	seen := make(map[string]bool)
+	for i, row := range rows {
+		if len(p.Distinct) > 0 {
+			key := distinctKey(row, p.Distinct, types)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		}
+		indexes = append(indexes, i)
+	}
+
+	if len(p.OrderBy) > 0 {
+		sort.SliceStable(indexes, func(i, j int) bool {
+			a, b := rows[indexes[i]], rows[indexes[j]]
+			for _, key := range p.OrderBy {
+				if c := compareColumn(a[key.Column], b[key.Column], types[key.Column], key.Desc); c != 0 {
+					return c < 0
+				}
+			}
+			return false
+		})
+	}
+
+	start := min(p.Offset, len(indexes))
+	end := len(indexes)
+	if p.Limit > 0 {
+		end = min(start+p.Limit, end)
+	}
+	return indexes[start:end], nil
+}
+
+// columns returns every column p reads
+func (p PostProcess) columns() []string {
+	columns := append([]string{}, p.Distinct...)
+	for _, key := range p.OrderBy {
+		columns = append(columns, key.Column)
+	}
+	return columns
+}
+
+// checkPostProcessLimits rejects rows over the row cap, or whose estimated
+// size is over the memory cap. A zero cap is unbounded.
+func checkPostProcessLimits(rows []map[string]interface{}, limits config.PostProcessConfig) error {
+	if limits.MaxRows > 0 && len(rows) > limits.MaxRows {
+		return fmt.Errorf("%w: %d rows, at most %d", ErrPostProcessLimit, len(rows), limits.MaxRows)
+	}
+	if limits.MaxBytes <= 0 {
+		return nil
+	}
+	var size int64
+	for _, row := range rows {
+		if size += valueSize(row); size > limits.MaxBytes {
+			return fmt.Errorf("%w: over %d bytes", ErrPostProcessLimit, limits.MaxBytes)
+		}
+	}
+	return nil
+}
+
+// valueSize estimates the bytes a row value holds in memory
+func valueSize(value interface{}) int64 {
+	const overhead = 16 // Interface header of every value
+	switch v := value.(type) {
+	case string:
+		return overhead + int64(len(v))
+	case json.Number:
+		return overhead + int64(len(v))
+	case map[string]interface{}:
+		size := int64(48)
+		for name, field := range v {
+			size += int64(len(name)) + valueSize(field)
+		}
+		return size
+	case []interface{}:
+		size := int64(24)
+		for _, element := range v {
+			size += valueSize(element)
+		}
+		return size
+	default:
+		return overhead + 8
+	}
+}
+
+// Kinds of sortable values, in the order values of a mixed column sort in
+const (
+	sortNumber = iota
+	sortDate
+	sortBoolean
+	sortString
+	sortOther
+)
+
+// compareColumn compares two values of a column of columnType, NULLs last
+// whatever the direction
+func compareColumn(a, b interface{}, columnType string, desc bool) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+	c := compareValues(a, b, columnType)
+	if desc {
+		return -c
+	}
+	return c
+}
+
+// compareValues compares two non-NULL values. Values of different kinds,
+// which only a mixed column holds, compare by kind.
+func compareValues(a, b interface{}, columnType string) int {
+	kindA, valueA := sortValue(a, columnType)
+	kindB, valueB := sortValue(b, columnType)
+	if kindA != kindB {
+		return cmp.Compare(kindA, kindB)
+	}
+	switch kindA {
+	case sortNumber:
+		return cmp.Compare(valueA.(float64), valueB.(float64))
+	case sortDate:
+		return valueA.(time.Time).Compare(valueB.(time.Time))
+	case sortBoolean:
+		return cmp.Compare(boolRank(valueA.(bool)), boolRank(valueB.(bool)))
+	default:
+		return strings.Compare(valueA.(string), valueB.(string))
+	}
+}
+
+// sortValue returns the kind of value and the value to compare it by.
+// Strings of number and date columns, which some sources return, compare as
+// numbers and dates when they parse as one.
+func sortValue(value interface{}, columnType string) (int, interface{}) {
+	switch v := value.(type) {
+	case int:
+		return sortNumber, float64(v)
+	case int8:
+		return sortNumber, float64(v)
+	case int16:
+		return sortNumber, float64(v)
+	case int32:
+		return sortNumber, float64(v)
+	case int64:
+		return sortNumber, float64(v)
+	case uint:
+		return sortNumber, float64(v)
+	case uint8:
+		return sortNumber, float64(v)
+	case uint16:
+		return sortNumber, float64(v)
+	case uint32:
+		return sortNumber, float64(v)
+	case uint64:
+		return sortNumber, float64(v)
+	case float32:
+		return sortNumber, float64(v)
+	case float64:
+		return sortNumber, v
+	case json.Number:
+		if f, err := v.Float64(); err == nil {
+			return sortNumber, f
+		}
+		return sortString, v.String()
+	case bool:
+		return sortBoolean, v
+	case time.Time:
+		return sortDate, v
+	case string:
+		switch columnType {
+		case config.ColumnNumber:
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				return sortNumber, f
+			}
+		case config.ColumnDate:
+			if t, ok := parseSortDate(v); ok {
+				return sortDate, t
+			}
+		}
+		return sortString, v
+	default:
+		return sortOther, fmt.Sprint(v)
+	}
+}
+
+func parseSortDate(v string) (time.Time, bool) {
+	for _, layout := range []string{time.RFC3339Nano, time.DateTime, time.DateOnly} {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func boolRank(v bool) int {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+// distinctKey returns the key rows equal on columns share. Values are keyed
+// by their sort value, so 1, 1.0 and "1" of a number column are one value.
+func distinctKey(row map[string]interface{}, columns []string, types map[string]string) string {
+	var b strings.Builder
+	for _, column := range columns {
+		value := row[column]
+		if value == nil {
+			b.WriteString("null")
+		} else {
+			kind, v := sortValue(value, types[column])
+			if t, ok := v.(time.Time); ok {
+				v = t.UTC().Format(time.RFC3339Nano)
+			}
+			fmt.Fprintf(&b, "%d:%v", kind, v)
+		}
+		b.WriteByte(0)
+	}
+	return b.String()
+}
@@ -0,0 +1,145 @@
+package datasource
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-data-gateway/internal/config"
+)
+
+// columnValues returns the values of column in rows
+func columnValues(rows []map[string]interface{}, name string) []interface{} {
+	values := make([]interface{}, len(rows))
+	for i, row := range rows {
+		values[i] = row[name]
+	}
+	return values
+}
+
+func TestPostProcess_NullsLast(t *testing.T) {
+	rows := []map[string]interface{}{
+		{"id": 1, "nilai": 300},
+		{"id": 2, "nilai": nil},
+		{"id": 3, "nilai": 100},
+		{"id": 4},
+		{"id": 5, "nilai": 200},
+	}
+	limits := config.DefaultPostProcess()
+
+	asc, err := PostProcess{OrderBy: []SortKey{{Column: "nilai"}}}.Apply(rows, nil, limits)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{3, 5, 1, 2, 4}, columnValues(asc, "id"))
+
+	desc, err := PostProcess{OrderBy: []SortKey{{Column: "nilai", Desc: true}}}.Apply(rows, nil, limits)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{1, 5, 3, 2, 4}, columnValues(desc, "id"))
+}
+
+func TestPostProcess_Stable(t *testing.T) {
+	rows := []map[string]interface{}{
+		{"id": 1, "status": "Selesai", "nilai": 10},
+		{"id": 2, "status": "Aktif", "nilai": 10},
+		{"id": 3, "status": "Selesai", "nilai": 5},
+		{"id": 4, "status": "Aktif", "nilai": 10},
+		{"id": 5, "status": "Selesai", "nilai": 10},
+	}
+
+	sorted, err := PostProcess{OrderBy: []SortKey{{Column: "status"}}}.Apply(rows, nil, config.DefaultPostProcess())
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{2, 4, 1, 3, 5}, columnValues(sorted, "id"))
+
+	sorted, err = PostProcess{OrderBy: []SortKey{{Column: "status", Desc: true}, {Column: "nilai"}}}.Apply(rows, nil, config.DefaultPostProcess())
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{3, 1, 5, 2, 4}, columnValues(sorted, "id"))
+}
+
+func TestPostProcess_MixedTypes(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2025, 1, d, 0, 0, 0, 0, time.UTC) }
+	rows := []map[string]interface{}{
+		{"id": 1, "v": "b"},
+		{"id": 2, "v": json.Number("10")},
+		{"id": 3, "v": true},
+		{"id": 4, "v": 9.5},
+		{"id": 5, "v": day(2)},
+		{"id": 6, "v": "a"},
+		{"id": 7, "v": int64(2)},
+		{"id": 8, "v": day(1)},
+		{"id": 9, "v": false},
+	}
+
+	// Numbers compare numerically whatever their Go type, then dates,
+	// booleans and strings
+	sorted, err := PostProcess{OrderBy: []SortKey{{Column: "v"}}}.Apply(rows, nil, config.DefaultPostProcess())
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{7, 4, 2, 8, 5, 9, 3, 6, 1}, columnValues(sorted, "id"))
+}
+
+func TestPostProcess_SchemaTypes(t *testing.T) {
+	// Sources returning numbers and dates as strings sort them by value
+	// when the schema declares the column's type
+	rows := []map[string]interface{}{
+		{"nilai": "100", "tanggal": "2025-03-01"},
+		{"nilai": "9", "tanggal": "2025-01-15T10:00:00Z"},
+		{"nilai": "25.5", "tanggal": "2025-02-01 08:00:00"},
+	}
+	schema := []ColumnField{{Name: "nilai", Type: config.ColumnNumber}, {Name: "tanggal", Type: config.ColumnDate}}
+
+	byNumber, err := PostProcess{OrderBy: []SortKey{{Column: "nilai"}}}.Apply(rows, schema, config.DefaultPostProcess())
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"9", "25.5", "100"}, columnValues(byNumber, "nilai"))
+
+	byDate, err := PostProcess{OrderBy: []SortKey{{Column: "tanggal", Desc: true}}}.Apply(rows, schema, config.DefaultPostProcess())
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"100", "25.5", "9"}, columnValues(byDate, "nilai"))
+
+	// Without the schema they are strings
+	byString, err := PostProcess{OrderBy: []SortKey{{Column: "nilai"}}}.Apply(rows, nil, config.DefaultPostProcess())
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"100", "25.5", "9"}, columnValues(byString, "nilai"))
+}
+
+func TestPostProcess_DistinctAndPage(t *testing.T) {
+	rows := []map[string]interface{}{
+		{"id": 1, "kl": "A", "tahun": 2024},
+		{"id": 2, "kl": "B", "tahun": 2024},
+		{"id": 3, "kl": "A", "tahun": json.Number("2024")},
+		{"id": 4, "kl": "A", "tahun": 2025},
+		{"id": 5, "kl": nil, "tahun": 2025},
+		{"id": 6, "tahun": 2025},
+	}
+	p := PostProcess{Distinct: []string{"kl", "tahun"}, OrderBy: []SortKey{{Column: "id", Desc: true}}}
+
+	distinct, err := p.Apply(rows, nil, config.DefaultPostProcess())
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{5, 4, 2, 1}, columnValues(distinct, "id"))
+
+	p.Offset, p.Limit = 1, 2
+	page, err := p.Apply(rows, nil, config.DefaultPostProcess())
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{4, 2}, columnValues(page, "id"))
+
+	p.Offset = 10
+	page, err = p.Apply(rows, nil, config.DefaultPostProcess())
+	require.NoError(t, err)
+	assert.Empty(t, page)
+}
+
+func TestPostProcess_Errors(t *testing.T) {
+	rows := []map[string]interface{}{{"id": 1, "nama": "Pengadaan laptop"}, {"id": 2, "nama": "Jasa konsultansi"}}
+
+	_, err := PostProcess{OrderBy: []SortKey{{Column: "missing"}}}.Apply(rows, nil, config.DefaultPostProcess())
+	assert.EqualError(t, err, `unknown column "missing"`)
+
+	_, err = PostProcess{}.Apply(rows, nil, config.PostProcessConfig{MaxRows: 1})
+	assert.ErrorIs(t, err, ErrPostProcessLimit)
+
+	_, err = PostProcess{}.Apply(rows, nil, config.PostProcessConfig{MaxBytes: 100})
+	assert.ErrorIs(t, err, ErrPostProcessLimit)
+
+	assert.Error(t, PostProcess{OrderBy: []SortKey{{}}, Limit: -1}.Validate())
+	assert.NoError(t, PostProcess{OrderBy: []SortKey{{Column: "id"}}, Limit: 10}.Validate())
+}
@@ -0,0 +1,59 @@
+package datasource
+
+import (
+	"encoding/json"
+	"time"
+
+	"go-data-gateway/internal/jsonrows"
+)
+
+// queryResultJSON has the fields of QueryResult without methods, so marshaling
+// it does not recurse into MarshalJSON
+type queryResultJSON QueryResult
+
+// queryResultTail is QueryResult without its rows
+type queryResultTail struct {
+	Count     int                    `json:"count"`
+	Source    DataSourceType         `json:"source"`
+	CacheHit  bool                   `json:"cache_hit,omitempty"`
+	QueryTime time.Duration          `json:"query_time_ms,omitempty"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// MarshalJSON encodes the rows with jsonrows, which avoids reflection for the
+// column types drivers return; the output is the same as encoding/json's
+func (r QueryResult) MarshalJSON() ([]byte, error) {
+	if !jsonrows.Enabled() {
+		return json.Marshal(queryResultJSON(r))
+	}
+
+	tail, err := r.TailJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 0, 256*len(r.Data)+len(tail)+16)
+	buf = append(buf, `{"data":`...)
+	if buf, err = jsonrows.AppendRows(buf, r.Data); err != nil {
+		return nil, err
+	}
+	return append(buf, tail...), nil
+}
+
+// TailJSON encodes the fields that follow the rows in the JSON of r, from
+// the comma after the data array to the closing brace, for writers that
+// encode the rows themselves
+func (r QueryResult) TailJSON() ([]byte, error) {
+	tail, err := json.Marshal(queryResultTail{
+		Count:     r.Count,
+		Source:    r.Source,
+		CacheHit:  r.CacheHit,
+		QueryTime: r.QueryTime,
+		Metadata:  r.Metadata,
+	})
+	if err != nil {
+		return nil, err
+	}
+	tail[0] = ',' // The opening brace becomes the separator after the rows
+	return tail, nil
+}
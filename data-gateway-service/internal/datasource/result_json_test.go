@@ -0,0 +1,47 @@
+package datasource
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-data-gateway/internal/jsonrows"
+)
+
+func TestQueryResult_MarshalJSONMatchesEncodingJSON(t *testing.T) {
+	results := []QueryResult{
+		{},
+		{Data: []map[string]interface{}{}, Source: DataSourceDremio},
+		{
+			Data: []map[string]interface{}{
+				{"nama_paket": "Jalan <Tol>", "nilai_pagu": 1.5e9, "tahun_anggaran": int64(2025), "catatan": nil},
+				{"nama_paket": "Jembatan", "nilai_pagu": 2.5e-7, "tahun_anggaran": int64(2024), "catatan": "ok"},
+			},
+			Count:     2,
+			Source:    DataSourceBigQuery,
+			CacheHit:  true,
+			QueryTime: 1500 * time.Microsecond,
+			Metadata:  map[string]interface{}{"cached_at": time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)},
+		},
+	}
+
+	for _, enabled := range []bool{true, false} {
+		jsonrows.SetEnabled(enabled)
+		for _, result := range results {
+			got, err := json.Marshal(result)
+			require.NoError(t, err)
+			want, err := json.Marshal(queryResultJSON(result))
+			require.NoError(t, err)
+			assert.Equal(t, string(want), string(got))
+
+			// Pointers, as handlers pass them, take the same path
+			got, err = json.Marshal(&result)
+			require.NoError(t, err)
+			assert.Equal(t, string(want), string(got))
+		}
+	}
+	jsonrows.SetEnabled(true)
+}
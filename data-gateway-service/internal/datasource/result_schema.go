@@ -0,0 +1,196 @@
+package datasource
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"go-data-gateway/internal/colnames"
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/numeric"
+)
+
+// MetaSchemaFingerprint is the metadata key of a result's schema fingerprint
+const MetaSchemaFingerprint = "schema_fingerprint"
+
+// MetaRenamedColumns is the metadata key of the columns renamed because the
+// result repeated their name, each new name to the name the source gave it
+const MetaRenamedColumns = colnames.MetaKey
+
+// MetaStringNumbers is the metadata key of the columns whose decimals are
+// sent as strings
+const MetaStringNumbers = numeric.MetaKey
+
+// ColumnMixed is the type of a result column whose rows hold values of more
+// than one type
+const ColumnMixed = "mixed"
+
+// ResultSchema returns the columns of rows, sorted by name, with the type of
+// their values. Columns that are NULL in every row have no type; columns
+// whose values differ in type across rows are ColumnMixed.
+func ResultSchema(rows []map[string]interface{}) []ColumnField {
+	types := make(map[string]string)
+	repeated := make(map[string]bool)
+	for _, row := range rows {
+		for name, value := range row {
+			if array, ok := value.([]interface{}); ok {
+				repeated[name] = true
+				value = firstNonNull(array)
+			}
+			t := resultColumnType(value)
+			switch previous, seen := types[name]; {
+			case !seen || previous == "":
+				types[name] = t
+			case t != "" && t != previous:
+				types[name] = ColumnMixed
+			}
+		}
+	}
+
+	columns := make([]ColumnField, 0, len(types))
+	for name, t := range types {
+		columns = append(columns, ColumnField{Name: name, Type: t, Repeated: repeated[name]})
+	}
+	sort.Slice(columns, func(i, j int) bool { return columns[i].Name < columns[j].Name })
+	return columns
+}
+
+// MixedColumns returns the names of the ColumnMixed columns of schema
+func MixedColumns(schema []ColumnField) []string {
+	var names []string
+	for _, column := range schema {
+		if column.Type == ColumnMixed {
+			names = append(names, column.Name)
+		}
+	}
+	return names
+}
+
+// Fingerprint returns a short hash of schema's column names and types, or ""
+// for a schema without columns
+func Fingerprint(schema []ColumnField) string {
+	if len(schema) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	for _, column := range schema {
+		b.WriteString(column.Name)
+		b.WriteByte(':')
+		if column.Repeated {
+			b.WriteString("[]")
+		}
+		b.WriteString(column.Type)
+		b.WriteByte('\n')
+	}
+	sum := sha256.Sum256([]byte(b.String()))
+	return hex.EncodeToString(sum[:8])
+}
+
+// SchemaFingerprint returns the fingerprint of result's schema: the one in
+// its metadata, which a cache hit carries from its original execution, or
+// else the one of its rows
+func SchemaFingerprint(result *QueryResult) string {
+	if result == nil {
+		return ""
+	}
+	if fingerprint, ok := result.Metadata[MetaSchemaFingerprint].(string); ok {
+		return fingerprint
+	}
+	return Fingerprint(ResultSchema(result.Data))
+}
+
+// setRenamedColumns records renamed in result's metadata, if any
+func setRenamedColumns(result *QueryResult, renamed map[string]string) {
+	if len(renamed) == 0 {
+		return
+	}
+	if result.Metadata == nil {
+		result.Metadata = make(map[string]interface{})
+	}
+	result.Metadata[MetaRenamedColumns] = renamed
+}
+
+// RenamedColumns returns the columns renamed in result, each new name to the
+// name the source gave it, as recorded fresh or carried by a cache hit
+func RenamedColumns(result *QueryResult) map[string]string {
+	if result == nil {
+		return nil
+	}
+	switch renamed := result.Metadata[MetaRenamedColumns].(type) {
+	case map[string]string:
+		return renamed
+	case map[string]interface{}:
+		names := make(map[string]string, len(renamed))
+		for name, original := range renamed {
+			if s, ok := original.(string); ok {
+				names[name] = s
+			}
+		}
+		return names
+	}
+	return nil
+}
+
+// setStringNumbers records columns, sent as strings, in result's metadata,
+// if any
+func setStringNumbers(result *QueryResult, columns []string) {
+	if len(columns) == 0 {
+		return
+	}
+	if result.Metadata == nil {
+		result.Metadata = make(map[string]interface{})
+	}
+	result.Metadata[MetaStringNumbers] = columns
+}
+
+// StringNumbers returns the columns of result whose decimals are sent as
+// strings, as recorded fresh or carried by a cache hit
+func StringNumbers(result *QueryResult) []string {
+	if result == nil {
+		return nil
+	}
+	switch columns := result.Metadata[MetaStringNumbers].(type) {
+	case []string:
+		return columns
+	case []interface{}:
+		names := make([]string, 0, len(columns))
+		for _, column := range columns {
+			if s, ok := column.(string); ok {
+				names = append(names, s)
+			}
+		}
+		return names
+	}
+	return nil
+}
+
+// resultColumnType returns the config.Column* type of a row value, or "" for
+// NULL
+func resultColumnType(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return ""
+	case map[string]interface{}:
+		return config.ColumnRecord
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, json.Number:
+		return config.ColumnNumber
+	case bool:
+		return config.ColumnBoolean
+	case time.Time:
+		return config.ColumnDate
+	default:
+		return config.ColumnString
+	}
+}
+
+func firstNonNull(values []interface{}) interface{} {
+	for _, value := range values {
+		if value != nil {
+			return value
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package datasource
+
+import (
+	"context"
+	"strings"
+
+	"cloud.google.com/go/bigquery"
+
+	"go-data-gateway/internal/config"
+)
+
+// ColumnField describes a column of a table schema. Records, such as
+// BigQuery STRUCT columns, list their fields; repeated columns are arrays.
+type ColumnField struct {
+	Name     string        `json:"name"`
+	Type     string        `json:"type"` // A config.Column* type
+	Repeated bool          `json:"repeated,omitempty"`
+	Fields   []ColumnField `json:"fields,omitempty"`
+}
+
+// SchemaDescriber is implemented by data sources that can report the schema
+// of a table, nested fields included
+type SchemaDescriber interface {
+	DescribeTable(ctx context.Context, table string) ([]ColumnField, error)
+}
+
+// DescribeTable returns the schema of table on source, or nil when source
+// cannot describe tables
+func DescribeTable(ctx context.Context, source DataSource, table string) ([]ColumnField, error) {
+	if d, ok := source.(SchemaDescriber); ok {
+		return d.DescribeTable(ctx, table)
+	}
+	return nil, nil
+}
+
+// bigQueryColumns converts a BigQuery schema to column fields
+func bigQueryColumns(schema bigquery.Schema) []ColumnField {
+	columns := make([]ColumnField, len(schema))
+	for i, field := range schema {
+		columns[i] = ColumnField{
+			Name:     field.Name,
+			Type:     bigQueryColumnType(field.Type),
+			Repeated: field.Repeated,
+		}
+		if field.Type == bigquery.RecordFieldType {
+			columns[i].Fields = bigQueryColumns(field.Schema)
+		}
+	}
+	return columns
+}
+
+// bigQueryColumnType maps a BigQuery field type to its config.Column* type
+func bigQueryColumnType(t bigquery.FieldType) string {
+	switch t {
+	case bigquery.IntegerFieldType, bigquery.FloatFieldType, bigquery.NumericFieldType, bigquery.BigNumericFieldType:
+		return config.ColumnNumber
+	case bigquery.BooleanFieldType:
+		return config.ColumnBoolean
+	case bigquery.DateFieldType, bigquery.DateTimeFieldType, bigquery.TimestampFieldType:
+		return config.ColumnDate
+	case bigquery.RecordFieldType:
+		return config.ColumnRecord
+	default:
+		return config.ColumnString
+	}
+}
+
+// FieldValue returns the value at path in row: a column name, or a dotted
+// path into record columns such as peserta.email. A column whose name
+// contains the dots, as written by flattened CSV, takes precedence.
+func FieldValue(row map[string]interface{}, path string) (interface{}, bool) {
+	if v, ok := row[path]; ok {
+		return v, true
+	}
+	column, rest, nested := strings.Cut(path, ".")
+	if !nested {
+		return nil, false
+	}
+	record, ok := row[column].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	return FieldValue(record, rest)
+}
@@ -0,0 +1,65 @@
+package datasource
+
+import (
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/stretchr/testify/assert"
+
+	"go-data-gateway/internal/config"
+)
+
+func TestBigQueryColumns_NestedFields(t *testing.T) {
+	schema := bigquery.Schema{
+		{Name: "kode_tender", Type: bigquery.StringFieldType},
+		{Name: "pagu", Type: bigquery.NumericFieldType},
+		{Name: "tags", Type: bigquery.StringFieldType, Repeated: true},
+		{Name: "peserta", Type: bigquery.RecordFieldType, Repeated: true, Schema: bigquery.Schema{
+			{Name: "email", Type: bigquery.StringFieldType},
+			{Name: "alamat", Type: bigquery.RecordFieldType, Schema: bigquery.Schema{
+				{Name: "kota", Type: bigquery.StringFieldType},
+			}},
+		}},
+	}
+
+	assert.Equal(t, []ColumnField{
+		{Name: "kode_tender", Type: config.ColumnString},
+		{Name: "pagu", Type: config.ColumnNumber},
+		{Name: "tags", Type: config.ColumnString, Repeated: true},
+		{Name: "peserta", Type: config.ColumnRecord, Repeated: true, Fields: []ColumnField{
+			{Name: "email", Type: config.ColumnString},
+			{Name: "alamat", Type: config.ColumnRecord, Fields: []ColumnField{
+				{Name: "kota", Type: config.ColumnString},
+			}},
+		}},
+	}, bigQueryColumns(schema))
+}
+
+func TestFieldValue(t *testing.T) {
+	row := map[string]interface{}{
+		"kode_tender":   "T1",
+		"peserta":       map[string]interface{}{"email": "a@b.id", "alamat": map[string]interface{}{"kota": "Bandung"}},
+		"pemenang":      nil,
+		"peserta.email": "flattened@b.id",
+	}
+
+	tests := []struct {
+		path  string
+		value interface{}
+		found bool
+	}{
+		{"kode_tender", "T1", true},
+		{"peserta.email", "flattened@b.id", true},
+		{"peserta.alamat.kota", "Bandung", true},
+		{"pemenang", nil, true},
+		{"pemenang.nama", nil, false},
+		{"peserta.telepon", nil, false},
+		{"kode_tender.x", nil, false},
+		{"missing", nil, false},
+	}
+	for _, tt := range tests {
+		value, found := FieldValue(row, tt.path)
+		assert.Equal(t, tt.found, found, tt.path)
+		assert.Equal(t, tt.value, value, tt.path)
+	}
+}
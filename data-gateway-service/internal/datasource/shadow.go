@@ -0,0 +1,204 @@
+package datasource
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/clients"
+	"go-data-gateway/internal/metrics"
+)
+
+// defaultShadowTimeout bounds a mirrored query when ShadowConfig has none
+const defaultShadowTimeout = 2 * time.Minute
+
+// ShadowConfig describes which queries of a primary source are mirrored
+type ShadowConfig struct {
+	Primary   string        // Name of the primary source, for logs and metrics
+	Secondary string        // Name of the source queries are mirrored to
+	Percent   int           // Share of queries mirrored, 0 to 100
+	Timeout   time.Duration // Bounds each mirrored query; 0 uses two minutes
+}
+
+// ShadowDataSource serves every query from its primary and mirrors a sample
+// of them to a secondary in the background, logging whether both return the
+// same number of rows. Callers only see the primary's results, errors and
+// latency, so a migration to another cluster can be validated on live
+// traffic.
+type ShadowDataSource struct {
+	primary   DataSource
+	secondary func() (DataSource, bool) // Looked up per query; it may still be initializing
+	cfg       ShadowConfig
+	metrics   *metrics.ShadowCounter
+	logger    *zap.Logger
+
+	sample func() bool
+	wg     sync.WaitGroup
+}
+
+// NewShadowDataSource mirrors cfg.Percent percent of primary's queries to the
+// source secondary returns
+func NewShadowDataSource(primary DataSource, secondary func() (DataSource, bool), cfg ShadowConfig, counter *metrics.ShadowCounter, logger *zap.Logger) *ShadowDataSource {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultShadowTimeout
+	}
+	percent := cfg.Percent
+	return &ShadowDataSource{
+		primary:   primary,
+		secondary: secondary,
+		cfg:       cfg,
+		metrics:   counter,
+		logger:    logger,
+		sample:    func() bool { return rand.IntN(100) < percent },
+	}
+}
+
+// ExecuteQuery runs the query on the primary and may mirror it
+func (s *ShadowDataSource) ExecuteQuery(ctx context.Context, query string, opts *QueryOptions) (*QueryResult, error) {
+	result, err := s.primary.ExecuteQuery(ctx, query, opts)
+	if err == nil && s.sample() {
+		s.mirror(ctx, query, opts, result, func(ctx context.Context, secondary DataSource, opts *QueryOptions) (*QueryResult, error) {
+			return secondary.ExecuteQuery(ctx, query, opts)
+		})
+	}
+	return result, err
+}
+
+// GetData reads the table from the primary and may mirror the read
+func (s *ShadowDataSource) GetData(ctx context.Context, table string, opts *QueryOptions) (*QueryResult, error) {
+	result, err := s.primary.GetData(ctx, table, opts)
+	if err == nil && s.sample() {
+		s.mirror(ctx, table, opts, result, func(ctx context.Context, secondary DataSource, opts *QueryOptions) (*QueryResult, error) {
+			return secondary.GetData(ctx, table, opts)
+		})
+	}
+	return result, err
+}
+
+// mirror runs fetch on the secondary in the background, bypassing its cache,
+// and compares its row count with the primary's result. The mirrored query
+// keeps the request's values, such as attribution, but not its cancellation.
+func (s *ShadowDataSource) mirror(ctx context.Context, query string, opts *QueryOptions, primary *QueryResult, fetch func(context.Context, DataSource, *QueryOptions) (*QueryResult, error)) {
+	mirrored := QueryOptions{}
+	if opts != nil {
+		mirrored = *opts
+	}
+	mirrored.SkipCache = true
+	ctx = context.WithoutCancel(ctx)
+	rows := len(primary.Data) // Read now; callers may reuse the result
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		fields := []zap.Field{
+			zap.String("primary", s.cfg.Primary),
+			zap.String("secondary", s.cfg.Secondary),
+			zap.String("sql", query),
+		}
+		secondary, ok := s.secondary()
+		if !ok || secondary == nil {
+			s.metrics.Record(s.cfg.Primary, s.cfg.Secondary, metrics.ShadowError)
+			s.logger.Debug("Shadow source not available", fields...)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+		defer cancel()
+		start := time.Now()
+		result, err := fetch(ctx, secondary, &mirrored)
+		if err != nil {
+			s.metrics.Record(s.cfg.Primary, s.cfg.Secondary, metrics.ShadowError)
+			s.logger.Warn("Shadow read failed", append(fields, zap.Error(err))...)
+			return
+		}
+
+		fields = append(fields,
+			zap.Int("rows", rows),
+			zap.Int("shadow_rows", len(result.Data)),
+			zap.Duration("shadow_time", time.Since(start)))
+		if len(result.Data) != rows {
+			s.metrics.Record(s.cfg.Primary, s.cfg.Secondary, metrics.ShadowMismatch)
+			s.logger.Warn("Shadow read row count mismatch", fields...)
+			return
+		}
+		s.metrics.Record(s.cfg.Primary, s.cfg.Secondary, metrics.ShadowMatch)
+		s.logger.Debug("Shadow read matched", fields...)
+	}()
+}
+
+// Wait blocks until mirrored queries in flight have finished
+func (s *ShadowDataSource) Wait() {
+	s.wg.Wait()
+}
+
+// ValidateQuery validates on the primary
+func (s *ShadowDataSource) ValidateQuery(ctx context.Context, query string) error {
+	return ValidateQuery(ctx, s.primary, query)
+}
+
+// DescribeTable describes the table on the primary
+func (s *ShadowDataSource) DescribeTable(ctx context.Context, table string) ([]ColumnField, error) {
+	return DescribeTable(ctx, s.primary, table)
+}
+
+// TableSize reports the size of the table on the primary
+func (s *ShadowDataSource) TableSize(ctx context.Context, table string) (*TableStats, error) {
+	return TableSize(ctx, s.primary, table)
+}
+
+// PlanQuery plans the query on the primary
+func (s *ShadowDataSource) PlanQuery(ctx context.Context, query string, opts *QueryOptions) (*QueryPlan, error) {
+	return PlanQuery(ctx, s.primary, query, opts)
+}
+
+// PlanTable plans the table read on the primary
+func (s *ShadowDataSource) PlanTable(ctx context.Context, table string, opts *QueryOptions) (*QueryPlan, error) {
+	return PlanTable(ctx, s.primary, table, opts)
+}
+
+// TestConnection checks the primary
+func (s *ShadowDataSource) TestConnection(ctx context.Context) error {
+	return s.primary.TestConnection(ctx)
+}
+
+// DeepCheck runs the primary's query-based check
+func (s *ShadowDataSource) DeepCheck(ctx context.Context) error {
+	return DeepCheck(ctx, s.primary)
+}
+
+// Capacity returns the primary's concurrency hint
+func (s *ShadowDataSource) Capacity(ctx context.Context) int {
+	return Capacity(ctx, s.primary)
+}
+
+// GetPoolMetrics returns the pool metrics of the primary
+func (s *ShadowDataSource) GetPoolMetrics() map[string]interface{} {
+	return PoolMetrics(s.primary)
+}
+
+// ResetPoolLatency resets the pool of the primary
+func (s *ShadowDataSource) ResetPoolLatency() {
+	ResetPoolLatency(s.primary)
+}
+
+// RotateCredentials rotates the credentials of the primary; the secondary
+// is rotated by its owner
+func (s *ShadowDataSource) RotateCredentials(ctx context.Context, account *clients.Credentials, creds clients.DremioCredentials) error {
+	return rotateWrapped(ctx, s.primary, account, creds)
+}
+
+// GetType returns the primary's type
+func (s *ShadowDataSource) GetType() DataSourceType {
+	return s.primary.GetType()
+}
+
+// Close waits for mirrored queries and closes the primary; the secondary is
+// closed by its owner
+func (s *ShadowDataSource) Close() error {
+	s.wg.Wait()
+	return s.primary.Close()
+}
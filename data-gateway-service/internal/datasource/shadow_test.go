@@ -0,0 +1,124 @@
+package datasource
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/metrics"
+)
+
+// countingSource returns rows rows, or err, and records what it was asked
+type countingSource struct {
+	memorySource
+	rows int
+	err  error
+
+	mu      sync.Mutex
+	queries []string
+	opts    []*QueryOptions
+	ctxErrs []error
+}
+
+func (s *countingSource) ExecuteQuery(ctx context.Context, query string, opts *QueryOptions) (*QueryResult, error) {
+	s.mu.Lock()
+	s.queries = append(s.queries, query)
+	s.opts = append(s.opts, opts)
+	s.ctxErrs = append(s.ctxErrs, ctx.Err())
+	s.mu.Unlock()
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &QueryResult{Data: make([]map[string]interface{}, s.rows), Count: s.rows, Source: DataSourceDremio}, nil
+}
+
+func (s *countingSource) GetData(ctx context.Context, table string, opts *QueryOptions) (*QueryResult, error) {
+	return s.ExecuteQuery(ctx, "table:"+table, opts)
+}
+
+func newTestShadow(primary, secondary DataSource, percent int, counter *metrics.ShadowCounter) *ShadowDataSource {
+	return NewShadowDataSource(primary, func() (DataSource, bool) { return secondary, secondary != nil },
+		ShadowConfig{Primary: "DATAWAREHOUSE", Secondary: "DATAWAREHOUSE_CLOUD", Percent: percent},
+		counter, zap.NewNop())
+}
+
+func shadowMetrics(counter *metrics.ShadowCounter) string {
+	var buf bytes.Buffer
+	counter.WritePrometheus(&buf)
+	return buf.String()
+}
+
+func TestShadow_MirrorsAndComparesRowCounts(t *testing.T) {
+	primary := &countingSource{rows: 3}
+	secondary := &countingSource{rows: 3}
+	counter := metrics.NewShadowCounter()
+	shadow := newTestShadow(primary, secondary, 100, counter)
+
+	// The caller's cancellation does not stop the mirrored query
+	ctx, cancel := context.WithCancel(context.Background())
+	result, err := shadow.ExecuteQuery(ctx, "SELECT 1", &QueryOptions{Limit: 10})
+	cancel()
+	require.NoError(t, err)
+	assert.Len(t, result.Data, 3)
+	shadow.Wait()
+
+	secondary.rows = 2
+	_, err = shadow.GetData(context.Background(), "tender", nil)
+	require.NoError(t, err)
+	shadow.Wait()
+
+	assert.ElementsMatch(t, []string{"SELECT 1", "table:tender"}, secondary.queries)
+	for _, opts := range secondary.opts {
+		assert.True(t, opts.SkipCache, "mirrored reads bypass the secondary's cache")
+	}
+	for _, ctxErr := range secondary.ctxErrs {
+		assert.NoError(t, ctxErr)
+	}
+
+	out := shadowMetrics(counter)
+	assert.Contains(t, out, `go_gateway_shadow_reads_total{primary="DATAWAREHOUSE",secondary="DATAWAREHOUSE_CLOUD",outcome="match"} 1`)
+	assert.Contains(t, out, `go_gateway_shadow_reads_total{primary="DATAWAREHOUSE",secondary="DATAWAREHOUSE_CLOUD",outcome="mismatch"} 1`)
+}
+
+func TestShadow_SecondaryFailuresStayHidden(t *testing.T) {
+	primary := &countingSource{rows: 1}
+	counter := metrics.NewShadowCounter()
+
+	failing := newTestShadow(primary, &countingSource{err: errors.New("cloud unreachable")}, 100, counter)
+	_, err := failing.ExecuteQuery(context.Background(), "SELECT 1", nil)
+	assert.NoError(t, err)
+	failing.Wait()
+
+	missing := newTestShadow(primary, nil, 100, counter)
+	_, err = missing.ExecuteQuery(context.Background(), "SELECT 1", nil)
+	assert.NoError(t, err)
+	missing.Wait()
+
+	assert.Contains(t, shadowMetrics(counter), `outcome="error"} 2`)
+}
+
+func TestShadow_OnlySampledSuccessesAreMirrored(t *testing.T) {
+	secondary := &countingSource{rows: 1}
+	counter := metrics.NewShadowCounter()
+
+	failedPrimary := newTestShadow(&countingSource{err: errors.New("syntax error")}, secondary, 100, counter)
+	_, err := failedPrimary.ExecuteQuery(context.Background(), "SELEC 1", nil)
+	assert.Error(t, err)
+	failedPrimary.Wait()
+
+	unsampled := newTestShadow(&countingSource{rows: 1}, secondary, 0, counter)
+	for i := 0; i < 50; i++ {
+		_, err := unsampled.ExecuteQuery(context.Background(), "SELECT 1", nil)
+		require.NoError(t, err)
+	}
+	unsampled.Wait()
+
+	assert.Empty(t, secondary.queries)
+	assert.NotContains(t, shadowMetrics(counter), "outcome=")
+}
@@ -0,0 +1,24 @@
+package datasource
+
+import "context"
+
+// TableStats is the size of a table as its metadata records it
+type TableStats struct {
+	Rows  int64 `json:"rows"`
+	Bytes int64 `json:"bytes"`
+}
+
+// TableSizer is implemented by data sources that can report the size of a
+// table from its metadata, without scanning it
+type TableSizer interface {
+	TableSize(ctx context.Context, table string) (*TableStats, error)
+}
+
+// TableSize returns the size of table on source, or nil when source cannot
+// report it
+func TableSize(ctx context.Context, source DataSource, table string) (*TableStats, error) {
+	if s, ok := source.(TableSizer); ok {
+		return s.TableSize(ctx, table)
+	}
+	return nil, nil
+}
@@ -1,138 +1,176 @@
 package datasource
 
 import (
+	"errors"
 	"fmt"
-	"regexp"
+	"slices"
 	"strings"
+
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/sqlbuilder"
+)
+
+// SQLDialect selects how identifiers and string literals are quoted
+type SQLDialect = sqlbuilder.Dialect
+
+const (
+	// DialectANSI quotes a string by doubling single quotes (Dremio)
+	DialectANSI = sqlbuilder.Dremio
+	// DialectBigQuery backticks table names and backslash-escapes strings
+	DialectBigQuery = sqlbuilder.BigQuery
 )
 
-// SQLSanitizer provides methods to safely build SQL queries
+// SQLSanitizer validates the tables, columns and values of QueryOptions and
+// builds their queries with a sqlbuilder.Builder
 type SQLSanitizer struct {
 	// Whitelist of allowed table names (can be loaded from config)
-	allowedTables map[string]bool
-	// Pattern for valid identifier names
-	identifierPattern *regexp.Regexp
+	allowedTables []string
+	// Filterable columns per table; tables without an entry accept any column
+	allowedColumns map[string][]string
+	dialect        SQLDialect
 }
 
 // NewSQLSanitizer creates a new SQL sanitizer
 func NewSQLSanitizer() *SQLSanitizer {
-	return &SQLSanitizer{
-		// Only allow alphanumeric, underscore, dash, and dots for schema.table format
-		identifierPattern: regexp.MustCompile(`^[a-zA-Z0-9_\-\.]+$`),
-		allowedTables:     make(map[string]bool),
-	}
+	return &SQLSanitizer{}
 }
 
 // SetAllowedTables sets the whitelist of allowed table names
 func (s *SQLSanitizer) SetAllowedTables(tables []string) {
-	s.allowedTables = make(map[string]bool)
-	for _, table := range tables {
-		s.allowedTables[table] = true
-	}
+	s.allowedTables = tables
+}
+
+// SetAllowedColumns sets the whitelist of filter and ORDER BY columns per table
+func (s *SQLSanitizer) SetAllowedColumns(columns map[string][]string) {
+	s.allowedColumns = columns
+}
+
+// SetDialect sets the SQL dialect of the built queries
+func (s *SQLSanitizer) SetDialect(dialect SQLDialect) {
+	s.dialect = dialect
 }
 
 // ValidateTableName validates and sanitizes table names
 func (s *SQLSanitizer) ValidateTableName(table string) (string, error) {
-	// Remove any backticks or quotes
-	table = strings.ReplaceAll(table, "`", "")
-	table = strings.ReplaceAll(table, "'", "")
-	table = strings.ReplaceAll(table, "\"", "")
-
 	// Check against whitelist if configured
 	if len(s.allowedTables) > 0 {
-		if !s.allowedTables[table] {
-			return "", fmt.Errorf("table '%s' is not in allowed list", table)
+		unquoted := strings.NewReplacer("`", "", "'", "", `"`, "").Replace(table)
+		if !slices.Contains(s.allowedTables, unquoted) {
+			return "", fmt.Errorf("table '%s' is not in allowed list", unquoted)
 		}
 	}
-
-	// Validate format
-	if !s.identifierPattern.MatchString(table) {
-		return "", fmt.Errorf("invalid table name format: '%s'", table)
-	}
-
-	// Check for SQL injection patterns
-	lowerTable := strings.ToLower(table)
-	dangerousPatterns := []string{
-		"select", "insert", "update", "delete", "drop", "create",
-		"alter", "exec", "execute", "union", "--", "/*", "*/",
-		";", "xp_", "sp_", "0x", "\\x",
-	}
-
-	for _, pattern := range dangerousPatterns {
-		if strings.Contains(lowerTable, pattern) {
-			return "", fmt.Errorf("potential SQL injection detected in table name: '%s'", table)
-		}
-	}
-
-	return table, nil
+	return sqlbuilder.ValidateTable(table)
 }
 
-// ValidateColumnName validates column names for ORDER BY clauses
+// ValidateColumnName validates column names for ORDER BY and WHERE clauses
 func (s *SQLSanitizer) ValidateColumnName(column string) (string, error) {
-	// Remove any backticks or quotes
-	column = strings.ReplaceAll(column, "`", "")
-	column = strings.ReplaceAll(column, "'", "")
-	column = strings.ReplaceAll(column, "\"", "")
-
-	// Only allow simple column names (no functions or expressions)
-	if !regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]*$`).MatchString(column) {
-		return "", fmt.Errorf("invalid column name: '%s'", column)
-	}
-
-	return column, nil
+	return sqlbuilder.ValidateColumn(column)
 }
 
 // ValidateOrderDirection validates ORDER BY direction
 func (s *SQLSanitizer) ValidateOrderDirection(dir string) (string, error) {
-	dir = strings.ToUpper(strings.TrimSpace(dir))
-	if dir != "ASC" && dir != "DESC" && dir != "" {
-		return "", fmt.Errorf("invalid order direction: '%s'", dir)
-	}
-	if dir == "" {
-		dir = "ASC"
-	}
-	return dir, nil
+	return sqlbuilder.ValidateDirection(dir)
+}
+
+// ErrInvalidTableQuery is wrapped by the error of a GetData whose table,
+// filters or ordering the sanitizer rejected
+var ErrInvalidTableQuery = errors.New("invalid table query")
+
+// newTableSanitizer returns the sanitizer of a Dremio GetData, which checks
+// filter and order columns against the active security whitelist
+func newTableSanitizer() *SQLSanitizer {
+	sanitizer := NewSQLSanitizer()
+	sanitizer.SetAllowedColumns(config.ActiveSecurityConfig().AllowedColumns())
+	return sanitizer
+}
+
+// newBigQueryTableSanitizer returns the sanitizer of a BigQuery GetData,
+// which also checks the table against the active security whitelist
+func newBigQueryTableSanitizer() *SQLSanitizer {
+	secConfig := config.ActiveSecurityConfig()
+	sanitizer := NewSQLSanitizer()
+	sanitizer.SetDialect(DialectBigQuery)
+	sanitizer.SetAllowedTables(secConfig.AllowedBigQueryTables)
+	sanitizer.SetAllowedColumns(secConfig.AllowedColumns())
+	return sanitizer
 }
 
 // BuildSafeTableQuery builds a safe SELECT query with validation
 func (s *SQLSanitizer) BuildSafeTableQuery(table string, opts *QueryOptions) (string, error) {
-	// Validate table name
-	safeTable, err := s.ValidateTableName(table)
+	return s.BuildSelectQuery(table, nil, opts)
+}
+
+// BuildSelectQuery is BuildSafeTableQuery selecting the given columns instead
+// of *; every column must be a valid name allowed for the table
+func (s *SQLSanitizer) BuildSelectQuery(table string, columns []string, opts *QueryOptions) (string, error) {
+	builder, err := s.SelectBuilder(table, columns, opts)
 	if err != nil {
-		return "", fmt.Errorf("table validation failed: %w", err)
+		return "", err
 	}
+	return builder.SQL()
+}
 
-	// Start building query
-	query := fmt.Sprintf("SELECT * FROM %s", safeTable)
-
-	if opts != nil {
-		// Add ORDER BY if specified
-		if opts.OrderBy != "" {
-			safeColumn, err := s.ValidateColumnName(opts.OrderBy)
-			if err != nil {
-				return "", fmt.Errorf("order by validation failed: %w", err)
-			}
-
-			safeDir, err := s.ValidateOrderDirection(opts.OrderDir)
-			if err != nil {
-				return "", fmt.Errorf("order direction validation failed: %w", err)
-			}
+// SelectBuilder returns the builder of a select of columns, or *, from table
+// with opts: its filters, keywords, keyset, ordering, page and as-of time.
+// Callers may add to it before building. Filters that are not valid filter
+// specs are reported here; everything else when the builder builds.
+func (s *SQLSanitizer) SelectBuilder(table string, columns []string, opts *QueryOptions) (*sqlbuilder.Builder, error) {
+	builder := s.builder(columns...).From(table)
+	if opts == nil {
+		return builder, nil
+	}
 
-			query += fmt.Sprintf(" ORDER BY %s %s", safeColumn, safeDir)
+	conds, err := filterConditions(opts.Filters)
+	if err != nil {
+		return nil, &sqlbuilder.Error{Clause: "filter", Err: err}
+	}
+	builder.Where(conds...)
+	if opts.Keywords != nil {
+		cond, err := opts.Keywords.Condition()
+		if err != nil {
+			return nil, &sqlbuilder.Error{Clause: "keyword", Err: err}
 		}
+		builder.Search(cond)
+	}
+	if opts.After != nil {
+		builder.After(opts.After.Columns, opts.After.Values, opts.After.Desc)
+	}
+	if opts.OrderBy != "" {
+		builder.OrderBy(opts.OrderBy, opts.OrderDir)
+	}
+	builder.Tiebreaker(opts.Tiebreaker, opts.OrderDir)
+	builder.AsOf(opts.AsOf, opts.AsOfBranch)
+	return builder.Limit(opts.Limit).Offset(opts.Offset), nil
+}
 
-		// Add LIMIT (already safe as integer)
-		if opts.Limit > 0 {
-			query += fmt.Sprintf(" LIMIT %d", opts.Limit)
+// builder starts a select of columns with the sanitizer's dialect and
+// whitelists
+func (s *SQLSanitizer) builder(columns ...string) *sqlbuilder.Builder {
+	return sqlbuilder.Select(s.dialect, columns...).
+		AllowTables(s.allowedTables).
+		AllowColumns(s.allowedColumns)
+}
 
-			// Add OFFSET (already safe as integer)
-			if opts.Offset > 0 {
-				query += fmt.Sprintf(" OFFSET %d", opts.Offset)
-			}
-		}
+// OrderTerms returns the ORDER BY terms of a read with opts, such as
+// ["tanggal_buat_paket DESC", "tender_id DESC"]: OrderBy, then the tiebreaker
+// in the same direction unless it is OrderBy. A page (Limit or Offset set)
+// without OrderBy is ordered by the tiebreaker alone. Columns are not
+// validated; BuildSelectQuery does that.
+func OrderTerms(opts *QueryOptions) []string {
+	if opts == nil {
+		return nil
+	}
+	builder := sqlbuilder.Select(DialectANSI)
+	if opts.OrderBy != "" {
+		builder.OrderBy(opts.OrderBy, opts.OrderDir)
 	}
+	builder.Tiebreaker(opts.Tiebreaker, opts.OrderDir)
+	return builder.Limit(opts.Limit).Offset(opts.Offset).Order()
+}
 
-	return query, nil
+// QuoteString returns v as a string literal of the sanitizer's dialect
+func (s *SQLSanitizer) QuoteString(v string) string {
+	return s.dialect.Quote(v)
 }
 
 // EscapeString escapes special characters in SQL strings
@@ -143,4 +181,4 @@ func (s *SQLSanitizer) EscapeString(input string) string {
 	// Remove null bytes
 	escaped = strings.ReplaceAll(escaped, "\x00", "")
 	return escaped
-}
\ No newline at end of file
+}
@@ -0,0 +1,371 @@
+package datasource
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/api/option"
+
+	"go-data-gateway/internal/clients"
+	"go-data-gateway/internal/config"
+)
+
+func TestBuildWhereClause(t *testing.T) {
+	s := NewSQLSanitizer()
+
+	where, err := s.BuildWhereClause(map[string]interface{}{
+		"status_tender":  "Selesai",
+		"tahun_anggaran": int64(2025),
+		"nilai_pagu":     1.5e9,
+		"is_deleted":     false,
+		"nama_kl":        "Kementerian O'Brien",
+		"_event_date":    nil,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, " WHERE _event_date IS NULL AND is_deleted = FALSE AND nama_kl = 'Kementerian O''Brien'"+
+		" AND nilai_pagu = 1500000000 AND status_tender = 'Selesai' AND tahun_anggaran = 2025", where)
+
+	where, err = s.BuildWhereClause(nil)
+	require.NoError(t, err)
+	assert.Empty(t, where)
+
+	invalid := []map[string]interface{}{
+		{"a = 1 OR 1": "x"},
+		{"name; DROP": "x"},
+		{"name": []string{"x"}},
+		{"pagu": math.NaN()},
+		{"pagu": math.Inf(1)},
+		{"pagu": FilterSpec{Op: "gt", Value: 1}},
+		{"pagu": FilterSpec{Op: FilterGte}},
+		{"pagu": FilterSpec{Op: FilterIn, Value: []interface{}{}}},
+		{"pagu": FilterSpec{Op: FilterIn, Value: "1,2"}},
+		{"pagu": FilterSpec{Op: FilterIn, Value: []interface{}{1, map[string]interface{}{}}}},
+		{"name": FilterSpec{Op: FilterLike, Value: 5}},
+		{"name": map[string]interface{}{"value": "x"}},
+		{"name": map[string]interface{}{"op": "eq", "value": "x", "or": "1=1"}},
+		{"name": []interface{}{"a", "b"}},
+		{"name": []FilterSpec{}},
+	}
+	for _, filters := range invalid {
+		_, err := s.BuildWhereClause(filters)
+		assert.Error(t, err, filters)
+	}
+}
+
+func TestBuildWhereClause_Operators(t *testing.T) {
+	s := NewSQLSanitizer()
+
+	tests := []struct {
+		name   string
+		filter interface{}
+		want   string
+	}{
+		{"plain value", "Aceh", "provinsi = 'Aceh'"},
+		{"eq", FilterSpec{Op: FilterEq, Value: int64(2025)}, "provinsi = 2025"},
+		{"eq null", FilterSpec{Op: FilterEq}, "provinsi IS NULL"},
+		{"gte", FilterSpec{Op: FilterGte, Value: 1000}, "provinsi >= 1000"},
+		{"lte", FilterSpec{Op: FilterLte, Value: "2025-12-31"}, "provinsi <= '2025-12-31'"},
+		{"like", FilterSpec{Op: FilterLike, Value: "%jalan%"}, "provinsi LIKE '%jalan%'"},
+		{"upper case op", FilterSpec{Op: "GTE", Value: 1}, "provinsi >= 1"},
+		{"in strings", FilterSpec{Op: FilterIn, Value: []string{"Aceh", "D'Bali"}}, "provinsi IN ('Aceh', 'D''Bali')"},
+		{"in numbers", FilterSpec{Op: FilterIn, Value: []int64{2024, 2025}}, "provinsi IN (2024, 2025)"},
+		{"in with null", FilterSpec{Op: FilterIn, Value: []interface{}{"Aceh", nil}}, "(provinsi IN ('Aceh') OR provinsi IS NULL)"},
+		{"in only null", FilterSpec{Op: FilterIn, Value: []interface{}{nil}}, "provinsi IS NULL"},
+		{
+			"range",
+			[]FilterSpec{{Op: FilterGte, Value: 1}, {Op: FilterLte, Value: 9}},
+			"provinsi >= 1 AND provinsi <= 9",
+		},
+		// Decoded JSON request bodies
+		{"json object", map[string]interface{}{"op": "in", "value": []interface{}{"Aceh", float64(7)}}, "provinsi IN ('Aceh', 7)"},
+		{
+			"json list",
+			[]interface{}{
+				map[string]interface{}{"op": "gte", "value": float64(1.5)},
+				map[string]interface{}{"op": "lte", "value": float64(3)},
+			},
+			"provinsi >= 1.5 AND provinsi <= 3",
+		},
+	}
+	for _, tt := range tests {
+		where, err := s.BuildWhereClause(map[string]interface{}{"provinsi": tt.filter})
+		require.NoError(t, err, tt.name)
+		assert.Equal(t, " WHERE "+tt.want, where, tt.name)
+	}
+
+	tooMany := make([]int, maxInValues+1)
+	_, err := s.BuildWhereClause(map[string]interface{}{"id": FilterSpec{Op: FilterIn, Value: tooMany}})
+	assert.Error(t, err)
+}
+
+func TestBuildWhereClause_Dialects(t *testing.T) {
+	filters := map[string]interface{}{
+		"nama": FilterSpec{Op: FilterIn, Value: []string{`O'Brien`, `C:\dir`, "a\nb\x00"}},
+	}
+
+	ansi := NewSQLSanitizer()
+	where, err := ansi.BuildWhereClause(filters)
+	require.NoError(t, err)
+	assert.Equal(t, ` WHERE nama IN ('O''Brien', 'C:\dir', 'a`+"\n"+`b')`, where)
+
+	bigQuery := NewSQLSanitizer()
+	bigQuery.SetDialect(DialectBigQuery)
+	where, err = bigQuery.BuildWhereClause(filters)
+	require.NoError(t, err)
+	assert.Equal(t, ` WHERE nama IN ('O\'Brien', 'C:\\dir', 'a\nb')`, where)
+}
+
+func TestBuildSafeTableQuery_AllowedColumns(t *testing.T) {
+	s := NewSQLSanitizer()
+	s.SetAllowedColumns(map[string][]string{"nessie_iceberg.tender_data": {"provinsi", "nilai_pagu"}})
+
+	_, err := s.BuildSafeTableQuery("nessie_iceberg.tender_data", &QueryOptions{
+		Filters: map[string]interface{}{"provinsi": "Aceh"},
+		OrderBy: "nilai_pagu",
+	})
+	assert.NoError(t, err)
+
+	_, err = s.BuildSafeTableQuery("nessie_iceberg.tender_data", &QueryOptions{
+		Filters: map[string]interface{}{"password": "x"},
+	})
+	assert.ErrorContains(t, err, "column 'password' is not allowed for table 'nessie_iceberg.tender_data'")
+
+	_, err = s.BuildSafeTableQuery("nessie_iceberg.tender_data", &QueryOptions{OrderBy: "password"})
+	assert.Error(t, err)
+
+	// Tables without declared columns accept any valid column
+	_, err = s.BuildSafeTableQuery("procurement.vendor_list", &QueryOptions{
+		Filters: map[string]interface{}{"vendor_name": "PT Maju"},
+	})
+	assert.NoError(t, err)
+}
+
+func TestBuildSafeTableQuery_BigQuery(t *testing.T) {
+	s := NewSQLSanitizer()
+	s.SetDialect(DialectBigQuery)
+
+	query, err := s.BuildSafeTableQuery("gtp-data-prod.layer_isb.rup_kromaster", &QueryOptions{
+		Filters: map[string]interface{}{"nama_klpd": FilterSpec{Op: FilterLike, Value: "Kab'%"}},
+		Limit:   5,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM `gtp-data-prod.layer_isb.rup_kromaster` WHERE nama_klpd LIKE 'Kab\\'%' LIMIT 5", query)
+}
+
+func TestBuildSafeTableQuery_Filters(t *testing.T) {
+	query, err := NewSQLSanitizer().BuildSafeTableQuery("nessie_iceberg.tender_data", &QueryOptions{
+		Filters:  map[string]interface{}{"tahun_anggaran": int64(2025)},
+		OrderBy:  "nilai_pagu",
+		OrderDir: "desc",
+		Limit:    10,
+		Offset:   20,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM nessie_iceberg.tender_data WHERE tahun_anggaran = 2025 ORDER BY nilai_pagu DESC LIMIT 10 OFFSET 20", query)
+}
+
+func TestBuildSelectQuery(t *testing.T) {
+	s := NewSQLSanitizer()
+	query, err := s.BuildSelectQuery("nessie_iceberg.tender_data", []string{"tender_id", "nilai_pagu"}, &QueryOptions{
+		Filters: map[string]interface{}{"tender_id": "T'1"},
+		Limit:   1,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT tender_id, nilai_pagu FROM nessie_iceberg.tender_data WHERE tender_id = 'T''1' LIMIT 1", query)
+
+	_, err = s.BuildSelectQuery("nessie_iceberg.tender_data", []string{"tender_id", "1; DROP TABLE x"}, nil)
+	assert.ErrorContains(t, err, "select validation failed")
+
+	s.SetAllowedColumns(map[string][]string{"nessie_iceberg.tender_data": {"tender_id"}})
+	_, err = s.BuildSelectQuery("nessie_iceberg.tender_data", []string{"tender_id", "password"}, nil)
+	assert.ErrorContains(t, err, "column 'password' is not allowed")
+}
+
+func TestBuildSelectQuery_Tiebreaker(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  QueryOptions
+		order string
+	}{
+		{"after the sort column", QueryOptions{OrderBy: "tanggal_buat_paket", OrderDir: "desc", Tiebreaker: "tender_id", Limit: 10},
+			" ORDER BY tanggal_buat_paket DESC, tender_id DESC LIMIT 10"},
+		{"sort column is the tiebreaker", QueryOptions{OrderBy: "tender_id", Tiebreaker: "tender_id", Limit: 10},
+			" ORDER BY tender_id ASC LIMIT 10"},
+		{"page without a sort column", QueryOptions{Tiebreaker: "tender_id", Limit: 10, Offset: 20},
+			" ORDER BY tender_id ASC LIMIT 10 OFFSET 20"},
+		{"unpaged without a sort column", QueryOptions{Tiebreaker: "tender_id"},
+			""},
+		{"no tiebreaker", QueryOptions{OrderBy: "nilai_pagu", Limit: 10},
+			" ORDER BY nilai_pagu ASC LIMIT 10"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := NewSQLSanitizer().BuildSafeTableQuery("nessie_iceberg.tender_data", &tt.opts)
+			require.NoError(t, err)
+			assert.Equal(t, "SELECT * FROM nessie_iceberg.tender_data"+tt.order, query)
+		})
+	}
+
+	// The tiebreaker is configured, so a column whitelist does not apply to it
+	s := NewSQLSanitizer()
+	s.SetAllowedColumns(map[string][]string{"nessie_iceberg.tender_data": {"nilai_pagu"}})
+	query, err := s.BuildSafeTableQuery("nessie_iceberg.tender_data", &QueryOptions{OrderBy: "nilai_pagu", Tiebreaker: "tender_id"})
+	require.NoError(t, err)
+	assert.Contains(t, query, "ORDER BY nilai_pagu ASC, tender_id ASC")
+
+	_, err = s.BuildSafeTableQuery("nessie_iceberg.tender_data", &QueryOptions{Tiebreaker: "id; DROP TABLE x", Limit: 1})
+	assert.ErrorContains(t, err, "tiebreaker validation failed")
+}
+
+func TestBuildSelectQuery_After(t *testing.T) {
+	opts := QueryOptions{OrderBy: "nilai_pagu", OrderDir: "desc", Tiebreaker: "tender_id", Limit: 10,
+		Filters: map[string]interface{}{"tahun_anggaran": 2025}}
+	row := map[string]interface{}{"nilai_pagu": 1500.5, "tender_id": "T-7", "nama": "x"}
+
+	after, ok := KeysetAfter(&opts, row)
+	require.True(t, ok)
+	assert.Equal(t, []string{"nilai_pagu", "tender_id"}, after.Columns)
+	assert.True(t, after.Desc)
+
+	opts.After = after
+	query, err := NewSQLSanitizer().BuildSafeTableQuery("nessie_iceberg.tender_data", &opts)
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM nessie_iceberg.tender_data WHERE tahun_anggaran = 2025"+
+		" AND (nilai_pagu < 1500.5 OR (nilai_pagu = 1500.5 AND tender_id < 'T-7'))"+
+		" ORDER BY nilai_pagu DESC, tender_id DESC LIMIT 10", query)
+
+	// The tiebreaker alone orders a page without a sort column
+	after, ok = KeysetAfter(&QueryOptions{Tiebreaker: "tender_id"}, row)
+	require.True(t, ok)
+	condition, err := NewSQLSanitizer().KeysetCondition(*after)
+	require.NoError(t, err)
+	assert.Equal(t, "tender_id > 'T-7'", condition)
+}
+
+func TestKeysetAfter_NeedsAUniqueOrder(t *testing.T) {
+	row := map[string]interface{}{"nilai_pagu": nil, "tender_id": "T-7", "tags": []string{"a"}}
+
+	_, ok := KeysetAfter(&QueryOptions{OrderBy: "tender_id"}, row)
+	assert.False(t, ok, "no tiebreaker")
+	_, ok = KeysetAfter(&QueryOptions{OrderBy: "nilai_pagu", Tiebreaker: "tender_id"}, row)
+	assert.False(t, ok, "null sort value")
+	_, ok = KeysetAfter(&QueryOptions{OrderBy: "tags", Tiebreaker: "tender_id"}, row)
+	assert.False(t, ok, "value without a literal")
+	_, ok = KeysetAfter(&QueryOptions{Tiebreaker: "missing"}, row)
+	assert.False(t, ok, "column not in the row")
+
+	_, err := NewSQLSanitizer().KeysetCondition(Keyset{Columns: []string{"a; DROP TABLE t"}, Values: []interface{}{1}})
+	assert.ErrorContains(t, err, "invalid column name")
+	_, err = NewSQLSanitizer().KeysetCondition(Keyset{Columns: []string{"a", "b"}, Values: []interface{}{1}})
+	assert.Error(t, err)
+}
+
+func TestDremioRESTGetData_FilteredQuery(t *testing.T) {
+	var submitted []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/v3/sql":
+			var body struct {
+				SQL string `json:"sql"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			submitted = append(submitted, body.SQL)
+			json.NewEncoder(w).Encode(map[string]string{"id": "job-1"})
+		case strings.HasSuffix(r.URL.Path, "/results"):
+			json.NewEncoder(w).Encode(map[string]interface{}{"rowCount": 1, "rows": []map[string]interface{}{{"n": 1}}})
+		default:
+			json.NewEncoder(w).Encode(map[string]string{"jobState": "COMPLETED"})
+		}
+	}))
+	defer srv.Close()
+
+	host, port := hostPort(t, srv.URL)
+	ds, err := NewDremioRESTClient(host, port, "", "", zap.NewNop())
+	require.NoError(t, err)
+
+	_, err = ds.GetData(context.Background(), "nessie_iceberg.tender_data", &QueryOptions{
+		Filters:  map[string]interface{}{"status_tender": "Selesai"},
+		OrderBy:  "tanggal_pengumuman",
+		OrderDir: "DESC",
+		Limit:    5,
+	})
+	require.NoError(t, err)
+	require.Len(t, submitted, 1)
+	assert.Equal(t, "SELECT * FROM nessie_iceberg.tender_data WHERE status_tender = 'Selesai' ORDER BY tanggal_pengumuman DESC LIMIT 5", submitted[0])
+
+	// Injection through an order column never reaches Dremio
+	_, err = ds.GetData(context.Background(), "nessie_iceberg.tender_data", &QueryOptions{OrderBy: "1; DROP TABLE x"})
+	assert.Error(t, err)
+	assert.Len(t, submitted, 1)
+
+	// So does ordering by a column outside the table's whitelist, or in an
+	// invalid direction
+	_, err = ds.GetData(context.Background(), "nessie_iceberg.tender_data", &QueryOptions{OrderBy: "password"})
+	assert.ErrorContains(t, err, "order by validation failed")
+	_, err = ds.GetData(context.Background(), "nessie_iceberg.tender_data", &QueryOptions{
+		OrderBy:  "nilai_pagu",
+		OrderDir: "DESC; DROP TABLE x",
+	})
+	assert.ErrorContains(t, err, "order direction validation failed")
+	assert.Len(t, submitted, 1)
+}
+
+func TestBigQueryGetData_FilteredQuery(t *testing.T) {
+	var submitted string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The query is sent on jobs.query or, for inserted jobs, in the configuration
+		var body struct {
+			Query         string `json:"query"`
+			Configuration struct {
+				Query struct {
+					Query string `json:"query"`
+				} `json:"query"`
+			} `json:"configuration"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		submitted = body.Query
+		if submitted == "" {
+			submitted = body.Configuration.Query.Query
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": map[string]interface{}{"code": http.StatusBadRequest, "message": "stop here"},
+		})
+	}))
+	defer srv.Close()
+
+	client, err := clients.NewBigQueryClient(
+		config.BigQueryConfig{ProjectID: "test-project"},
+		zap.NewNop(),
+		option.WithEndpoint(srv.URL),
+		option.WithHTTPClient(srv.Client()),
+		option.WithoutAuthentication(),
+	)
+	require.NoError(t, err)
+
+	sanitizer := NewSQLSanitizer()
+	sanitizer.SetDialect(DialectBigQuery)
+	wrapper := &BigQueryWrapper{client: client, logger: zap.NewNop(), sanitizer: sanitizer}
+	defer wrapper.Close()
+
+	_, err = wrapper.GetData(context.Background(), "gtp-data-prod.layer_isb.rup_kromaster", &QueryOptions{
+		Filters:  map[string]interface{}{"tahun_anggaran": int64(2025), "kd_klpd": "K12"},
+		OrderBy:  "pagu_kro",
+		OrderDir: "desc",
+		Limit:    10,
+		Offset:   10,
+	})
+	require.Error(t, err)
+	assert.Equal(t, "SELECT * FROM `gtp-data-prod.layer_isb.rup_kromaster` WHERE kd_klpd = 'K12' AND tahun_anggaran = 2025"+
+		" ORDER BY pagu_kro DESC LIMIT 10 OFFSET 10", submitted)
+}
@@ -0,0 +1,191 @@
+package datasource
+
+import (
+	"strconv"
+	"strings"
+
+	"go-data-gateway/internal/sqltext"
+)
+
+// sqlTokenKind is the lexical class of a SQL token
+type sqlTokenKind int
+
+const (
+	tokenWord       sqlTokenKind = iota // Keyword or unquoted identifier
+	tokenIdentifier                     // "quoted" or `quoted` identifier
+	tokenString                         // 'literal'
+	tokenNumber
+	tokenPunct // Any other single character
+)
+
+// sqlToken is a token of a SQL statement; comments and whitespace are dropped
+type sqlToken struct {
+	kind sqlTokenKind
+	text string
+	pos  int // Byte offset in the statement
+}
+
+// tokenizeSQL splits sql into tokens. Literals and quoted identifiers end as
+// dialect escapes quotes, as sqltext reads them; an unterminated literal or
+// comment runs to the end of sql.
+func tokenizeSQL(sql string, dialect sqltext.Dialect) []sqlToken {
+	var tokens []sqlToken
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		start := i
+		if end, ok := dialect.QuotedAt(sql, i); ok {
+			i = end
+			kind := tokenString
+			if c == '"' || c == '`' {
+				kind = tokenIdentifier
+			}
+			tokens = append(tokens, sqlToken{kind, sql[start:i], start})
+			continue
+		}
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			i++
+			continue
+		case strings.HasPrefix(sql[i:], "--"):
+			i = sqltext.IndexOrEnd(sql, i, "\n")
+			continue
+		case strings.HasPrefix(sql[i:], "/*"):
+			i = sqltext.IndexOrEnd(sql, i+2, "*/") + 2
+			continue
+		case isWordStart(c):
+			for i < len(sql) && isWordPart(sql[i]) {
+				i++
+			}
+			tokens = append(tokens, sqlToken{tokenWord, sql[start:i], start})
+		case c >= '0' && c <= '9':
+			for i < len(sql) && (isWordPart(sql[i]) || sql[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, sqlToken{tokenNumber, sql[start:i], start})
+		default:
+			i++
+			tokens = append(tokens, sqlToken{tokenPunct, sql[start:i], start})
+		}
+	}
+	return tokens
+}
+
+func isWordStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+func isWordPart(c byte) bool {
+	return isWordStart(c) || (c >= '0' && c <= '9') || c == '$'
+}
+
+// isKeyword reports whether t is the keyword kw, in any case
+func (t sqlToken) isKeyword(kw string) bool {
+	return t.kind == tokenWord && strings.EqualFold(t.text, kw)
+}
+
+// HasTopLevelLimit reports whether the outermost query of sql limits its rows
+// with LIMIT or FETCH, read in the dialect of the engine it is sent to.
+// Limits of subqueries and CTEs, and the words in comments, literals and
+// quoted identifiers, do not count.
+func HasTopLevelLimit(sql string, dialect sqltext.Dialect) bool {
+	depth := 0
+	for _, token := range tokenizeSQL(sql, dialect) {
+		switch {
+		case token.kind == tokenPunct && token.text == "(":
+			depth++
+		case token.kind == tokenPunct && token.text == ")":
+			depth--
+		case depth == 0 && (token.isKeyword("LIMIT") || token.isKeyword("FETCH")):
+			return true
+		}
+	}
+	return false
+}
+
+// HasTopLevelOrderBy reports whether the outermost query of sql orders its
+// rows, which the gateway then must not re-sort. Orderings of subqueries,
+// CTEs and window functions do not count.
+func HasTopLevelOrderBy(sql string, dialect sqltext.Dialect) bool {
+	depth := 0
+	tokens := tokenizeSQL(sql, dialect)
+	for i, token := range tokens {
+		switch {
+		case token.kind == tokenPunct && token.text == "(":
+			depth++
+		case token.kind == tokenPunct && token.text == ")":
+			depth--
+		case depth == 0 && token.isKeyword("ORDER") && i+1 < len(tokens) && tokens[i+1].isKeyword("BY"):
+			return true
+		}
+	}
+	return false
+}
+
+// isSelect reports whether sql is a query: a SELECT, optionally after WITH
+// or parenthesized
+func isSelect(sql string, dialect sqltext.Dialect) bool {
+	tokens := tokenizeSQL(sql, dialect)
+	if len(tokens) == 0 {
+		return false
+	}
+	first := tokens[0]
+	return first.isKeyword("SELECT") || first.isKeyword("WITH") || (first.kind == tokenPunct && first.text == "(")
+}
+
+// InjectLimit wraps a query without a top-level limit so it returns at most
+// limit rows, reporting whether it did. Statements other than queries are
+// returned unchanged. The query starts on the second line of the wrapper, so
+// errors located in it are reported one line down; see ShiftInjectedLimit.
+func InjectLimit(sql string, limit int, dialect sqltext.Dialect) (string, bool) {
+	if limit <= 0 || !isSelect(sql, dialect) || HasTopLevelLimit(sql, dialect) {
+		return sql, false
+	}
+	query := strings.TrimRight(strings.TrimSpace(sql), ";")
+	return "SELECT * FROM (\n" + query + "\n) AS auto_limited LIMIT " + strconv.Itoa(limit), true
+}
+
+// pageQuery wraps a query so it returns the rows opts.Limit and opts.Offset
+// select, reporting whether it did. Without a limit, and for statements other
+// than queries, sql is returned unchanged. As with InjectLimit, the query
+// starts on the second line of the wrapper. Only Dremio pages queries this
+// way, so sql is read in the Standard dialect.
+func pageQuery(sql string, opts *QueryOptions) (string, bool) {
+	if opts == nil || opts.Limit <= 0 || !isSelect(sql, sqltext.Standard) {
+		return sql, false
+	}
+	query := strings.TrimRight(strings.TrimSpace(sql), ";")
+	paged := "SELECT * FROM (\n" + query + "\n) AS paged LIMIT " + strconv.Itoa(opts.Limit)
+	if opts.Offset > 0 {
+		paged += " OFFSET " + strconv.Itoa(opts.Offset)
+	}
+	return paged, true
+}
+
+// CountQuery wraps a query so it returns its row count in a single row_count
+// column, reporting whether sql is a query it can wrap. Trailing semicolons
+// and comments are dropped, so a comment cannot swallow the wrapper. As with
+// InjectLimit, the query starts on the second line of the wrapper.
+func CountQuery(sql string, dialect sqltext.Dialect) (string, bool) {
+	tokens := tokenizeSQL(sql, dialect)
+	end := len(tokens)
+	for end > 0 && tokens[end-1].kind == tokenPunct && tokens[end-1].text == ";" {
+		end--
+	}
+	if end == 0 || !isSelect(sql, dialect) {
+		return sql, false
+	}
+	for _, token := range tokens[:end] {
+		if token.kind == tokenPunct && token.text == ";" {
+			return sql, false // Several statements
+		}
+	}
+	last := tokens[end-1]
+	query := sql[:last.pos+len(last.text)]
+	return "SELECT COUNT(*) AS row_count FROM (\n" + query + "\n) AS counted", true
+}
+
+// ShiftInjectedLimit moves the position of an upstream error in a query
+// wrapped by InjectLimit or CountQuery back onto the submitted SQL
+func ShiftInjectedLimit(err error) error {
+	return shiftPositionLines(err, 1)
+}
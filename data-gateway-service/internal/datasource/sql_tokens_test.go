@@ -0,0 +1,154 @@
+package datasource
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go-data-gateway/internal/sqltext"
+)
+
+func TestHasTopLevelLimit(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want bool
+	}{
+		{"SELECT * FROM tender_data", false},
+		{"SELECT * FROM tender_data LIMIT 10", true},
+		{"select * from tender_data limit 10 offset 5", true},
+		{"SELECT * FROM tender_data FETCH FIRST 10 ROWS ONLY", true},
+		{"SELECT * FROM (SELECT * FROM tender_data LIMIT 10) t", false},
+		{"SELECT * FROM t WHERE id IN (SELECT id FROM u ORDER BY id LIMIT 5)", false},
+		{"WITH recent AS (SELECT * FROM tender_data LIMIT 100) SELECT * FROM recent", false},
+		{"WITH recent AS (SELECT * FROM tender_data) SELECT * FROM recent LIMIT 100", true},
+		{"SELECT * FROM t -- LIMIT 10", false},
+		{"SELECT * FROM t /* LIMIT 10 */", false},
+		{"SELECT 'LIMIT 10' AS note FROM t", false},
+		{`SELECT "limit" FROM t`, false},
+		{"SELECT 'it''s (' FROM t LIMIT 1", true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, HasTopLevelLimit(tt.sql, sqltext.Standard), tt.sql)
+		assert.Equal(t, tt.want, HasTopLevelLimit(tt.sql, sqltext.BigQuery), tt.sql)
+	}
+}
+
+func TestHasTopLevelLimit_Dialects(t *testing.T) {
+	// BigQuery reads a triple-quoted literal to its closing three quotes
+	sql := "SELECT '''it's LIMIT 1''' FROM t"
+	assert.False(t, HasTopLevelLimit(sql, sqltext.BigQuery))
+	assert.True(t, HasTopLevelLimit(sql, sqltext.Standard))
+
+	// nor takes the statement after one for a comment
+	_, ok := CountQuery("SELECT '''x'y -- ''' ; DROP TABLE ds.t", sqltext.BigQuery)
+	assert.False(t, ok)
+}
+
+func TestHasTopLevelOrderBy(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want bool
+	}{
+		{"SELECT * FROM tender_data", false},
+		{"SELECT * FROM tender_data ORDER BY nilai_pagu DESC", true},
+		{"select * from tender_data order by 1", true},
+		{"SELECT * FROM (SELECT * FROM tender_data ORDER BY nilai_pagu) t", false},
+		{"SELECT ROW_NUMBER() OVER (ORDER BY nilai_pagu) AS n FROM tender_data", false},
+		{"SELECT 'ORDER BY x' AS note FROM t", false},
+		{"SELECT \"order\" FROM t", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, HasTopLevelOrderBy(tt.sql, sqltext.Standard), tt.sql)
+	}
+}
+
+func TestInjectLimit(t *testing.T) {
+	sql, ok := InjectLimit("SELECT * FROM tender_data;", 10000, sqltext.Standard)
+	assert.True(t, ok)
+	assert.Equal(t, "SELECT * FROM (\nSELECT * FROM tender_data\n) AS auto_limited LIMIT 10000", sql)
+
+	sql, ok = InjectLimit("WITH r AS (SELECT * FROM t LIMIT 5) SELECT * FROM r", 100, sqltext.Standard)
+	assert.True(t, ok)
+	assert.Equal(t, "SELECT * FROM (\nWITH r AS (SELECT * FROM t LIMIT 5) SELECT * FROM r\n) AS auto_limited LIMIT 100", sql)
+
+	unchanged := []struct {
+		sql   string
+		limit int
+	}{
+		{"SELECT * FROM t LIMIT 5", 100},
+		{"SELECT * FROM t", 0},
+		{"SHOW TABLES", 100},
+		{"", 100},
+	}
+	for _, tt := range unchanged {
+		sql, ok := InjectLimit(tt.sql, tt.limit, sqltext.Standard)
+		assert.False(t, ok, tt.sql)
+		assert.Equal(t, tt.sql, sql)
+	}
+}
+
+func TestCountQuery(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"SELECT * FROM tender_data;", "SELECT * FROM tender_data"},
+		{"WITH r AS (SELECT * FROM t LIMIT 5) SELECT * FROM r", "WITH r AS (SELECT * FROM t LIMIT 5) SELECT * FROM r"},
+		{"SELECT * FROM t -- latest only", "SELECT * FROM t"},
+		{"SELECT * FROM t; /* done */ ;", "SELECT * FROM t"},
+		{"-- tenders\nSELECT ';' AS s FROM t", "-- tenders\nSELECT ';' AS s FROM t"},
+	}
+	for _, tt := range tests {
+		sql, ok := CountQuery(tt.sql, sqltext.Standard)
+		assert.True(t, ok, tt.sql)
+		assert.Equal(t, "SELECT COUNT(*) AS row_count FROM (\n"+tt.want+"\n) AS counted", sql)
+
+		// The wrapper stays a read-only query and its own LIMIT is untouched
+		assert.True(t, isReadOnlySQL(sql), sql)
+		assert.False(t, HasTopLevelLimit(sql, sqltext.Standard), sql)
+	}
+
+	for _, sql := range []string{"SHOW TABLES", "", "-- nothing", "SELECT 1; DROP TABLE t"} {
+		wrapped, ok := CountQuery(sql, sqltext.Standard)
+		assert.False(t, ok, sql)
+		assert.Equal(t, sql, wrapped)
+	}
+}
+
+func TestPageQuery(t *testing.T) {
+	sql, ok := pageQuery("SELECT * FROM tender_data;", &QueryOptions{Limit: 50})
+	assert.True(t, ok)
+	assert.Equal(t, "SELECT * FROM (\nSELECT * FROM tender_data\n) AS paged LIMIT 50", sql)
+
+	// The query's own limit applies first
+	sql, ok = pageQuery("SELECT * FROM t LIMIT 500", &QueryOptions{Limit: 50, Offset: 100})
+	assert.True(t, ok)
+	assert.Equal(t, "SELECT * FROM (\nSELECT * FROM t LIMIT 500\n) AS paged LIMIT 50 OFFSET 100", sql)
+
+	unchanged := []struct {
+		sql  string
+		opts *QueryOptions
+	}{
+		{"SELECT * FROM t", nil},
+		{"SELECT * FROM t", &QueryOptions{Offset: 100}},
+		{"SHOW TABLES", &QueryOptions{Limit: 50}},
+	}
+	for _, tt := range unchanged {
+		sql, ok := pageQuery(tt.sql, tt.opts)
+		assert.False(t, ok, tt.sql)
+		assert.Equal(t, tt.sql, sql)
+	}
+}
+
+func TestIsReadOnlySQL_Comments(t *testing.T) {
+	assert.True(t, isReadOnlySQL("-- dashboard xyz\nSELECT * FROM t"))
+	assert.True(t, isReadOnlySQL("/* weekly */ WITH r AS (SELECT 1) SELECT * FROM r"))
+	assert.True(t, isReadOnlySQL("SELECT * FROM t -- drop the cancelled ones later"))
+	assert.False(t, isReadOnlySQL("-- SELECT\nDROP TABLE t"))
+	assert.False(t, isReadOnlySQL("/* SELECT */ DELETE FROM t"))
+
+	// Comment markers inside literals whose end depends on a backslash
+	// escape cannot hide a statement
+	assert.False(t, isReadOnlySQL(`SELECT 'a\'' -- ' ; DROP TABLE t`))
+	assert.False(t, isReadOnlySQL(`SELECT 'a\' ; DELETE FROM t -- '`))
+}
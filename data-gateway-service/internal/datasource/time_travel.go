@@ -0,0 +1,123 @@
+package datasource
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go-data-gateway/internal/sqltext"
+)
+
+// errNotSingleTable rejects as_of on a query that reads more than one table,
+// or reads one through a subquery or CTE
+var errNotSingleTable = errors.New("as_of needs a query of a single table")
+
+// clauseKeywords end the table list of a FROM clause
+var clauseKeywords = []string{"WHERE", "GROUP", "HAVING", "ORDER", "LIMIT", "OFFSET", "FETCH", "QUALIFY", "WINDOW", "UNION", "EXCEPT", "INTERSECT"}
+
+// AsOfQuery rewrites a raw query so it reads its table as it was at asOf, on
+// branch when it is set, by writing the dialect's AS OF clause after the
+// table name, and returns it with the table it reads. The query must name
+// exactly one table, once, and the table must be in allowed.
+func AsOfQuery(sql string, dialect SQLDialect, asOf time.Time, branch string, allowed []string) (query, table string, err error) {
+	tokens := tokenizeSQL(sql, sqltext.Standard) // as_of is read by Dremio only
+
+	var (
+		tableAt = -1   // Index of the table's first token
+		queries []bool // Per open parenthesis, whether it holds a query
+	)
+	for i, token := range tokens {
+		switch {
+		case token.kind == tokenPunct && token.text == "(":
+			next := i+1 < len(tokens) && (tokens[i+1].isKeyword("SELECT") || tokens[i+1].isKeyword("WITH"))
+			queries = append(queries, next)
+		case token.kind == tokenPunct && token.text == ")":
+			if len(queries) > 0 {
+				queries = queries[:len(queries)-1]
+			}
+		case token.isKeyword("JOIN"):
+			return "", "", errNotSingleTable
+		case token.isKeyword("FROM"):
+			// FROM also separates the arguments of EXTRACT, TRIM and SUBSTRING
+			if len(queries) > 0 && !queries[len(queries)-1] {
+				continue
+			}
+			if tableAt >= 0 {
+				return "", "", errNotSingleTable
+			}
+			tableAt = i + 1
+		}
+	}
+	if tableAt < 0 {
+		return "", "", errNotSingleTable
+	}
+
+	table, end := tableName(tokens, tableAt)
+	if table == "" {
+		return "", "", errNotSingleTable
+	}
+
+	// A comma after the table, before the next clause, lists another one
+	for _, token := range tokens[end:] {
+		if isClauseKeyword(token) || (token.kind == tokenPunct && token.text == ")") {
+			break
+		}
+		if token.kind == tokenPunct && token.text == "," {
+			return "", "", errNotSingleTable
+		}
+	}
+	if !containsFold(allowed, table) {
+		return "", "", fmt.Errorf("as_of is not enabled for table %s", table)
+	}
+
+	clause, err := dialect.AsOf(asOf, branch)
+	if err != nil {
+		return "", "", err
+	}
+	last := tokens[end-1]
+	at := last.pos + len(last.text)
+	return sql[:at] + " " + clause + sql[at:], table, nil
+}
+
+// tableName reads the dotted table name starting at tokens[start], without
+// quotes, and returns it with the index of the token after it. It is empty
+// when no name starts there.
+func tableName(tokens []sqlToken, start int) (string, int) {
+	var parts []string
+	i := start
+	for i < len(tokens) {
+		token := tokens[i]
+		if token.kind != tokenWord && token.kind != tokenIdentifier {
+			break
+		}
+		parts = append(parts, strings.Trim(token.text, "\"`"))
+		i++
+		if i+1 >= len(tokens) || tokens[i].kind != tokenPunct || tokens[i].text != "." {
+			break
+		}
+		i++
+	}
+	if len(parts) == 0 {
+		return "", start
+	}
+	return strings.Join(parts, "."), i
+}
+
+func isClauseKeyword(token sqlToken) bool {
+	for _, kw := range clauseKeywords {
+		if token.isKeyword(kw) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(values []string, v string) bool {
+	for _, value := range values {
+		if strings.EqualFold(value, v) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package datasource
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAsOfQuery(t *testing.T) {
+	asOf := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	allowed := []string{"nessie_iceberg.tender_data"}
+
+	tests := []struct {
+		name    string
+		sql     string
+		branch  string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "table",
+			sql:  "SELECT tender_id FROM nessie_iceberg.tender_data WHERE tahun_anggaran = 2024",
+			want: "SELECT tender_id FROM nessie_iceberg.tender_data AT TIMESTAMP '2025-01-01 00:00:00.000' WHERE tahun_anggaran = 2024",
+		},
+		{
+			name: "quoted table with alias",
+			sql:  `SELECT t.tender_id FROM "nessie_iceberg"."tender_data" t`,
+			want: `SELECT t.tender_id FROM "nessie_iceberg"."tender_data" AT TIMESTAMP '2025-01-01 00:00:00.000' t`,
+		},
+		{
+			name: "function FROM is not a table",
+			sql:  "SELECT EXTRACT(YEAR FROM tanggal_pengumuman) AS tahun FROM nessie_iceberg.tender_data",
+			want: "SELECT EXTRACT(YEAR FROM tanggal_pengumuman) AS tahun FROM nessie_iceberg.tender_data AT TIMESTAMP '2025-01-01 00:00:00.000'",
+		},
+		{
+			name:   "branch",
+			sql:    "SELECT * FROM nessie_iceberg.tender_data",
+			branch: "staging",
+			want:   `SELECT * FROM nessie_iceberg.tender_data AT BRANCH "staging" AS OF '2025-01-01 00:00:00.000'`,
+		},
+		{name: "not allowed", sql: "SELECT * FROM nessie_iceberg.tender_peserta", wantErr: "as_of is not enabled for table nessie_iceberg.tender_peserta"},
+		{name: "join", sql: "SELECT * FROM nessie_iceberg.tender_data t JOIN nessie_iceberg.tender_data u ON t.tender_id = u.tender_id", wantErr: "single table"},
+		{name: "comma join", sql: "SELECT * FROM nessie_iceberg.tender_data, nessie_iceberg.tender_peserta", wantErr: "single table"},
+		{name: "subquery", sql: "SELECT * FROM (SELECT * FROM nessie_iceberg.tender_data) x", wantErr: "single table"},
+		{name: "union", sql: "SELECT 1 FROM nessie_iceberg.tender_data UNION ALL SELECT 1 FROM nessie_iceberg.tender_data", wantErr: "single table"},
+		{name: "no table", sql: "SELECT 1", wantErr: "single table"},
+		{name: "table in a literal", sql: "SELECT 'FROM nessie_iceberg.tender_data'", wantErr: "single table"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, table, err := AsOfQuery(tt.sql, DialectANSI, asOf, tt.branch, allowed)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, "nessie_iceberg.tender_data", table)
+		})
+	}
+}
+
+func TestSelectBuilder_AsOf(t *testing.T) {
+	query, err := NewSQLSanitizer().BuildSelectQuery("nessie_iceberg.tender_data", []string{"tender_id"}, &QueryOptions{
+		AsOf:    time.Date(2025, 1, 1, 12, 30, 0, 0, time.UTC),
+		Filters: map[string]interface{}{"status_tender": "Selesai"},
+		Limit:   10,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT tender_id FROM nessie_iceberg.tender_data AT TIMESTAMP '2025-01-01 12:30:00.000' "+
+		"WHERE status_tender = 'Selesai' LIMIT 10", query)
+}
@@ -0,0 +1,307 @@
+package datasource
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/bigquery"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go-data-gateway/internal/clients"
+)
+
+// ErrorClass categorizes upstream failures that are the caller's fault, and
+// data the upstream could not convert
+type ErrorClass string
+
+const (
+	ErrorClassTableNotFound ErrorClass = "TABLE_NOT_FOUND"
+	ErrorClassPermission    ErrorClass = "UPSTREAM_PERMISSION"
+	ErrorClassSyntax        ErrorClass = "QUERY_SYNTAX"
+	// ErrorClassConversion is a stored value that failed to convert to its
+	// column's type while the upstream read it
+	ErrorClassConversion ErrorClass = "UPSTREAM_ERROR"
+)
+
+// maxUpstreamMessageLen bounds the upstream message echoed back to clients
+const maxUpstreamMessageLen = 500
+
+// UpstreamError is a classified error returned by a data source
+type UpstreamError struct {
+	Class   ErrorClass
+	Source  DataSourceType
+	Message string // Sanitized upstream message, safe to return to clients
+	Err     error
+
+	// Position is where the upstream located the error in the query, if it did
+	Position *QueryPosition
+
+	// Column is the column whose value failed to convert, if the upstream
+	// named it
+	Column string
+}
+
+// QueryPosition is a 1-based line and column in the submitted SQL
+type QueryPosition struct {
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Snippet string `json:"snippet,omitempty"` // Offending line with a caret under the column
+}
+
+func (e *UpstreamError) Error() string {
+	return string(e.Class) + ": " + e.Message
+}
+
+func (e *UpstreamError) Unwrap() error {
+	return e.Err
+}
+
+// StatusCode returns the HTTP status the gateway responds with
+func (e *UpstreamError) StatusCode() int {
+	switch e.Class {
+	case ErrorClassTableNotFound:
+		return http.StatusNotFound
+	case ErrorClassPermission:
+		return http.StatusForbidden
+	case ErrorClassConversion:
+		return http.StatusBadGateway
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+var (
+	tableNotFoundPattern = regexp.MustCompile(`(?i)((table|object|view|dataset|schema)\b.*\bnot found|\b(table|object|view|dataset|schema|relation)\s+\S+\s+does not exist|not found: (table|dataset))`)
+	permissionPattern    = regexp.MustCompile(`(?i)(permission (error|denied)|access denied|not authorized|does not have privileges|user does not have permission)`)
+	syntaxPattern        = regexp.MustCompile(`(?i)(parse error|failure parsing|syntax error|validation error|unrecognized name|encountered ".*" at line)`)
+	conversionPattern    = regexp.MustCompile(`(?i)(failed to cast|failure while attempting to cast|cannot cast value|could not convert|numberformatexception|invalid (number|date|timestamp|decimal) (format|value))`)
+)
+
+// Dremio names the unconvertible column as "column 'nilai_pagu'",
+// "field nilai_pagu" or "in column nilai_pagu"
+var conversionColumnPattern = regexp.MustCompile(`(?i)\b(?:column|field)\s+['"]?([a-zA-Z_][a-zA-Z0-9_]*)`)
+
+var (
+	// BigQuery: "Syntax error: Unexpected identifier "FORM" at [1:10]"
+	bigQueryPositionPattern = regexp.MustCompile(`at \[(\d+):(\d+)\]`)
+	// Dremio (Calcite): "Encountered "FORM" at line 1, column 10." or
+	// "From line 2, column 8 to line 2, column 12: Column 'x' not found"
+	dremioPositionPattern = regexp.MustCompile(`(?i)\bline (\d+), column (\d+)`)
+	// Dremio REST error context: "startLine 1" / "startColumn 10"
+	dremioStartLinePattern   = regexp.MustCompile(`\bstartLine (\d+)`)
+	dremioStartColumnPattern = regexp.MustCompile(`\bstartColumn (\d+)`)
+)
+
+// parsePosition extracts the error location from a raw upstream message
+func parsePosition(source DataSourceType, msg string) *QueryPosition {
+	var line, column string
+	if source == DataSourceBigQuery {
+		if m := bigQueryPositionPattern.FindStringSubmatch(msg); m != nil {
+			line, column = m[1], m[2]
+		}
+	} else if m := dremioPositionPattern.FindStringSubmatch(msg); m != nil {
+		line, column = m[1], m[2]
+	} else if m := dremioStartLinePattern.FindStringSubmatch(msg); m != nil {
+		line, column = m[1], "1"
+		if c := dremioStartColumnPattern.FindStringSubmatch(msg); c != nil {
+			column = c[1]
+		}
+	}
+	if line == "" {
+		return nil
+	}
+
+	l, _ := strconv.Atoi(line)
+	c, _ := strconv.Atoi(column)
+	if l < 1 || c < 1 {
+		return nil
+	}
+	return &QueryPosition{Line: l, Column: c}
+}
+
+// Locate returns the error position with a snippet of query, or nil when the
+// upstream reported no position or it lies outside the query
+func (e *UpstreamError) Locate(query string) *QueryPosition {
+	if e.Position == nil {
+		return nil
+	}
+	snippet, ok := querySnippet(query, e.Position.Line, e.Position.Column)
+	if !ok {
+		return nil
+	}
+	return &QueryPosition{Line: e.Position.Line, Column: e.Position.Column, Snippet: snippet}
+}
+
+// snippetRadius is how many characters either side of the column a snippet keeps
+const snippetRadius = 40
+
+// querySnippet renders the given line of query with a caret under column,
+// cropping long lines around the column
+func querySnippet(query string, line, column int) (string, bool) {
+	lines := strings.Split(query, "\n")
+	if line < 1 || line > len(lines) {
+		return "", false
+	}
+
+	// Tabs are rendered as one space so the caret stays aligned
+	text := []rune(strings.ReplaceAll(strings.TrimRight(lines[line-1], "\r"), "\t", " "))
+	col := min(max(column-1, 0), len(text))
+	start, end := max(col-snippetRadius, 0), min(col+snippetRadius, len(text))
+
+	prefix, suffix := "", ""
+	if start > 0 {
+		prefix = "..."
+	}
+	if end < len(text) {
+		suffix = "..."
+	}
+	caret := strings.Repeat(" ", len(prefix)+col-start) + "^"
+	return prefix + string(text[start:end]) + suffix + "\n" + caret, true
+}
+
+// classifyMessage matches the well-known upstream error texts
+func classifyMessage(msg string) (ErrorClass, bool) {
+	switch {
+	case tableNotFoundPattern.MatchString(msg):
+		return ErrorClassTableNotFound, true
+	case permissionPattern.MatchString(msg):
+		return ErrorClassPermission, true
+	case conversionPattern.MatchString(msg):
+		return ErrorClassConversion, true
+	case syntaxPattern.MatchString(msg):
+		return ErrorClassSyntax, true
+	}
+	return "", false
+}
+
+// ClassifyDremioError wraps Flight and REST errors caused by the query in an
+// *UpstreamError. Unrecognized errors are returned unchanged.
+func ClassifyDremioError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	// Arrow Flight: gRPC status code plus Dremio's message
+	if st, ok := status.FromError(err); ok && st.Code() != codes.OK && st.Code() != codes.Unknown {
+		msg := st.Message()
+		if class, ok := classifyMessage(msg); ok {
+			return newUpstreamError(class, DataSourceDremio, msg, err)
+		}
+		switch st.Code() {
+		case codes.NotFound:
+			return newUpstreamError(ErrorClassTableNotFound, DataSourceDremio, msg, err)
+		case codes.PermissionDenied:
+			return newUpstreamError(ErrorClassPermission, DataSourceDremio, msg, err)
+		case codes.InvalidArgument:
+			return newUpstreamError(ErrorClassSyntax, DataSourceDremio, msg, err)
+		}
+		return err
+	}
+
+	// REST API: errorMessage from the response body plus the HTTP status
+	var restErr *clients.DremioError
+	if errors.As(err, &restErr) {
+		if class, ok := classifyMessage(restErr.Message); ok {
+			return newUpstreamError(class, DataSourceDremio, restErr.Message, err)
+		}
+		switch restErr.StatusCode {
+		case http.StatusNotFound:
+			return newUpstreamError(ErrorClassTableNotFound, DataSourceDremio, restErr.Message, err)
+		case http.StatusForbidden:
+			return newUpstreamError(ErrorClassPermission, DataSourceDremio, restErr.Message, err)
+		}
+		return err
+	}
+
+	if class, ok := classifyMessage(err.Error()); ok {
+		return newUpstreamError(class, DataSourceDremio, err.Error(), err)
+	}
+	return err
+}
+
+// ClassifyBigQueryError wraps BigQuery errors caused by the query in an
+// *UpstreamError. Unrecognized errors are returned unchanged.
+func ClassifyBigQueryError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	// Errors reported by a finished job
+	var jobErr *bigquery.Error
+	if errors.As(err, &jobErr) {
+		if class, ok := bigQueryReasonClass(jobErr.Reason); ok {
+			return newUpstreamError(class, DataSourceBigQuery, jobErr.Message, err)
+		}
+		return err
+	}
+
+	// Errors returned by the API call itself
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		msg := apiErr.Message
+		for _, item := range apiErr.Errors {
+			if class, ok := bigQueryReasonClass(item.Reason); ok {
+				if item.Message != "" {
+					msg = item.Message
+				}
+				return newUpstreamError(class, DataSourceBigQuery, msg, err)
+			}
+		}
+		switch apiErr.Code {
+		case http.StatusNotFound:
+			return newUpstreamError(ErrorClassTableNotFound, DataSourceBigQuery, msg, err)
+		case http.StatusForbidden:
+			return newUpstreamError(ErrorClassPermission, DataSourceBigQuery, msg, err)
+		}
+		return err
+	}
+
+	return err
+}
+
+// bigQueryReasonClass maps googleapi error reasons to an error class
+func bigQueryReasonClass(reason string) (ErrorClass, bool) {
+	switch reason {
+	case "notFound":
+		return ErrorClassTableNotFound, true
+	case "accessDenied":
+		return ErrorClassPermission, true
+	case "invalidQuery":
+		return ErrorClassSyntax, true
+	}
+	return "", false
+}
+
+func newUpstreamError(class ErrorClass, source DataSourceType, msg string, err error) *UpstreamError {
+	upstreamErr := &UpstreamError{
+		Class:    class,
+		Source:   source,
+		Message:  sanitizeUpstreamMessage(msg),
+		Err:      err,
+		Position: parsePosition(source, msg),
+	}
+	if class == ErrorClassConversion {
+		if m := conversionColumnPattern.FindStringSubmatch(msg); m != nil {
+			upstreamErr.Column = m[1]
+		}
+	}
+	return upstreamErr
+}
+
+// sanitizeUpstreamMessage keeps the first line of the upstream message (Dremio
+// appends stack traces and internal node details) and bounds its length
+func sanitizeUpstreamMessage(msg string) string {
+	if i := strings.IndexAny(msg, "\r\n"); i >= 0 {
+		msg = msg[:i]
+	}
+	msg = strings.Join(strings.Fields(msg), " ")
+	if len(msg) > maxUpstreamMessageLen {
+		msg = msg[:maxUpstreamMessageLen] + "..."
+	}
+	return msg
+}
@@ -0,0 +1,442 @@
+package datasource
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/apache/arrow-go/v18/arrow/flight"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go-data-gateway/internal/clients"
+	"go-data-gateway/internal/config"
+)
+
+func hostPort(t *testing.T, rawURL string) (string, int) {
+	u, err := url.Parse(rawURL)
+	require.NoError(t, err)
+	host, portStr, err := net.SplitHostPort(u.Host)
+	require.NoError(t, err)
+	port, err := strconv.Atoi(portStr)
+	require.NoError(t, err)
+	return host, port
+}
+
+func TestClassifyDremioREST(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		message string
+		class   ErrorClass
+		code    int
+		column  string
+	}{
+		{
+			name:    "table not found",
+			status:  http.StatusBadRequest,
+			message: "Object 'tender_data' not found within 'nessie_iceberg'",
+			class:   ErrorClassTableNotFound,
+			code:    http.StatusNotFound,
+		},
+		{
+			name:    "table does not exist",
+			status:  http.StatusBadRequest,
+			message: "VALIDATION ERROR: Table 'nessie_iceberg.tender_data' does not exist",
+			class:   ErrorClassTableNotFound,
+			code:    http.StatusNotFound,
+		},
+		{
+			name:    "column does not exist",
+			status:  http.StatusBadRequest,
+			message: "VALIDATION ERROR: From line 1, column 8 to line 1, column 10: Column 'nme' does not exist",
+			class:   ErrorClassSyntax,
+			code:    http.StatusBadRequest,
+		},
+		{
+			name:    "function does not exist",
+			status:  http.StatusBadRequest,
+			message: "VALIDATION ERROR: Function 'tanggal' does not exist",
+			class:   ErrorClassSyntax,
+			code:    http.StatusBadRequest,
+		},
+		{
+			name:    "permission",
+			status:  http.StatusForbidden,
+			message: "PERMISSION ERROR: User gateway is not authorized to access nessie_iceberg.secret",
+			class:   ErrorClassPermission,
+			code:    http.StatusForbidden,
+		},
+		{
+			name:    "syntax",
+			status:  http.StatusBadRequest,
+			message: "PARSE ERROR: Failure parsing the query.\nSQL Query SELEC * FROM t\nstartLine 1",
+			class:   ErrorClassSyntax,
+			code:    http.StatusBadRequest,
+		},
+		{
+			name:    "conversion",
+			status:  http.StatusBadRequest,
+			message: "FUNCTION ERROR: Failed to cast the string 'n/a' to DECIMAL in column nilai_pagu\nFragment 0:0",
+			class:   ErrorClassConversion,
+			code:    http.StatusBadGateway,
+			column:  "nilai_pagu",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				json.NewEncoder(w).Encode(map[string]string{"errorMessage": tt.message})
+			}))
+			defer srv.Close()
+
+			host, port := hostPort(t, srv.URL)
+			ds, err := NewDremioRESTClient(host, port, "", "", zap.NewNop())
+			require.NoError(t, err)
+
+			_, err = ds.ExecuteQuery(context.Background(), "SELECT * FROM nessie_iceberg.tender_data", nil)
+			require.Error(t, err)
+
+			var upstreamErr *UpstreamError
+			require.ErrorAs(t, err, &upstreamErr)
+			assert.Equal(t, tt.class, upstreamErr.Class)
+			assert.Equal(t, tt.code, upstreamErr.StatusCode())
+			assert.Equal(t, DataSourceDremio, upstreamErr.Source)
+			assert.Equal(t, tt.column, upstreamErr.Column)
+			assert.NotContains(t, upstreamErr.Message, "\n")
+		})
+	}
+}
+
+func TestClassifyDremioREST_UnclassifiedPassesThrough(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"errorMessage": "coordinator unavailable"})
+	}))
+	defer srv.Close()
+
+	host, port := hostPort(t, srv.URL)
+	ds, err := NewDremioRESTClient(host, port, "", "", zap.NewNop())
+	require.NoError(t, err)
+
+	_, err = ds.ExecuteQuery(context.Background(), "SELECT 1", nil)
+	require.Error(t, err)
+
+	var upstreamErr *UpstreamError
+	assert.False(t, errors.As(err, &upstreamErr))
+
+	var restErr *clients.DremioError
+	require.ErrorAs(t, err, &restErr)
+	assert.Equal(t, "coordinator unavailable", restErr.Message)
+}
+
+// fakeFlightServer fails every GetFlightInfo call with the configured status
+type fakeFlightServer struct {
+	flight.BaseFlightServer
+	err error
+}
+
+func (s *fakeFlightServer) GetFlightInfo(ctx context.Context, desc *flight.FlightDescriptor) (*flight.FlightInfo, error) {
+	return nil, s.err
+}
+
+func TestClassifyDremioFlight(t *testing.T) {
+	tests := []struct {
+		name  string
+		err   error
+		class ErrorClass
+	}{
+		{
+			name:  "table not found",
+			err:   status.Error(codes.InvalidArgument, "Table 'nessie_iceberg.missing' not found"),
+			class: ErrorClassTableNotFound,
+		},
+		{
+			name:  "permission",
+			err:   status.Error(codes.PermissionDenied, "User does not have access to nessie_iceberg.secret"),
+			class: ErrorClassPermission,
+		},
+		{
+			name:  "syntax",
+			err:   status.Error(codes.InvalidArgument, "Encountered \"FORM\" at line 1, column 10."),
+			class: ErrorClassSyntax,
+		},
+		{
+			name:  "conversion",
+			err:   status.Error(codes.Internal, "GandivaException: Failure while attempting to cast value 'abc' to INT for field tahun_anggaran"),
+			class: ErrorClassConversion,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := flight.NewServerWithMiddleware(nil)
+			require.NoError(t, server.Init("127.0.0.1:0"))
+			server.RegisterFlightService(&fakeFlightServer{err: tt.err})
+			go server.Serve()
+			defer server.Shutdown()
+
+			host, port, err := net.SplitHostPort(server.Addr().String())
+			require.NoError(t, err)
+			portNum, _ := strconv.Atoi(port)
+
+			client, err := NewDremioArrowClient(&DremioConfig{Host: host, Port: portNum}, zap.NewNop())
+			require.NoError(t, err)
+			defer client.Close()
+
+			_, err = client.ExecuteQuery(context.Background(), "SELECT * FROM nessie_iceberg.missing", nil)
+			require.Error(t, err)
+
+			var upstreamErr *UpstreamError
+			require.ErrorAs(t, err, &upstreamErr)
+			assert.Equal(t, tt.class, upstreamErr.Class)
+		})
+	}
+}
+
+func TestClassifyBigQuery(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		reason string
+		class  ErrorClass
+		code   int
+	}{
+		{
+			name:   "not found",
+			status: http.StatusNotFound,
+			reason: "notFound",
+			class:  ErrorClassTableNotFound,
+			code:   http.StatusNotFound,
+		},
+		{
+			name:   "access denied",
+			status: http.StatusForbidden,
+			reason: "accessDenied",
+			class:  ErrorClassPermission,
+			code:   http.StatusForbidden,
+		},
+		{
+			name:   "invalid query",
+			status: http.StatusBadRequest,
+			reason: "invalidQuery",
+			class:  ErrorClassSyntax,
+			code:   http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := "upstream says " + tt.reason
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"error": map[string]interface{}{
+						"code":    tt.status,
+						"message": message,
+						"errors": []map[string]string{
+							{"message": message, "domain": "global", "reason": tt.reason},
+						},
+					},
+				})
+			}))
+			defer srv.Close()
+
+			client, err := clients.NewBigQueryClient(
+				config.BigQueryConfig{ProjectID: "test-project"},
+				zap.NewNop(),
+				option.WithEndpoint(srv.URL),
+				option.WithHTTPClient(srv.Client()),
+				option.WithoutAuthentication(),
+			)
+			require.NoError(t, err)
+
+			wrapper := &BigQueryWrapper{client: client, logger: zap.NewNop(), sanitizer: NewSQLSanitizer()}
+			defer wrapper.Close()
+
+			_, err = wrapper.ExecuteQuery(context.Background(), "SELECT * FROM `test-project.ds.missing`", nil)
+			require.Error(t, err)
+
+			var upstreamErr *UpstreamError
+			require.ErrorAs(t, err, &upstreamErr)
+			assert.Equal(t, tt.class, upstreamErr.Class)
+			assert.Equal(t, tt.code, upstreamErr.StatusCode())
+			assert.Equal(t, message, upstreamErr.Message)
+		})
+	}
+}
+
+func TestSanitizeUpstreamMessage(t *testing.T) {
+	assert.Equal(t, "PARSE ERROR: bad", sanitizeUpstreamMessage("PARSE ERROR:   bad\n\tat com.dremio.Foo(Foo.java:12)"))
+
+	long := make([]byte, maxUpstreamMessageLen+10)
+	for i := range long {
+		long[i] = 'x'
+	}
+	assert.Len(t, sanitizeUpstreamMessage(string(long)), maxUpstreamMessageLen+3)
+}
+
+func TestParsePosition(t *testing.T) {
+	tests := []struct {
+		name   string
+		source DataSourceType
+		msg    string
+		want   *QueryPosition
+	}{
+		{
+			name:   "bigquery syntax",
+			source: DataSourceBigQuery,
+			msg:    `Syntax error: Unexpected identifier "FORM" at [1:10]`,
+			want:   &QueryPosition{Line: 1, Column: 10},
+		},
+		{
+			name:   "bigquery unrecognized name",
+			source: DataSourceBigQuery,
+			msg:    "Unrecognized name: nme; Did you mean name? at [3:5]",
+			want:   &QueryPosition{Line: 3, Column: 5},
+		},
+		{
+			name:   "dremio flight parse error",
+			source: DataSourceDremio,
+			msg:    "PARSE ERROR: Encountered \"FORM\" at line 1, column 10.\nWas expecting one of: ...",
+			want:   &QueryPosition{Line: 1, Column: 10},
+		},
+		{
+			name:   "dremio validation range",
+			source: DataSourceDremio,
+			msg:    "VALIDATION ERROR: From line 2, column 8 to line 2, column 12: Column 'nme' not found in any table",
+			want:   &QueryPosition{Line: 2, Column: 8},
+		},
+		{
+			name:   "dremio rest context",
+			source: DataSourceDremio,
+			msg:    "PARSE ERROR: Failure parsing the query.\nSQL Query SELEC * FROM t\nstartLine 1\nstartColumn 1",
+			want:   &QueryPosition{Line: 1, Column: 1},
+		},
+		{
+			name:   "no position",
+			source: DataSourceDremio,
+			msg:    "Object 'missing' not found within 'nessie_iceberg'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, parsePosition(tt.source, tt.msg))
+		})
+	}
+}
+
+func TestUpstreamErrorLocate(t *testing.T) {
+	err := &UpstreamError{Position: &QueryPosition{Line: 2, Column: 3}}
+	pos := err.Locate("SELECT id,\n  nme\nFROM t")
+	require.NotNil(t, pos)
+	assert.Equal(t, "  nme\n  ^", pos.Snippet)
+
+	// Long lines are cropped around the column
+	long := "SELECT " + strings.Repeat("a, ", 30) + "FORM t " + strings.Repeat("b, ", 30)
+	column := strings.Index(long, "FORM") + 1
+	err = &UpstreamError{Position: &QueryPosition{Line: 1, Column: column}}
+	pos = err.Locate(long)
+	require.NotNil(t, pos)
+	lines := strings.Split(pos.Snippet, "\n")
+	require.Len(t, lines, 2)
+	assert.True(t, strings.HasPrefix(lines[0], "..."))
+	assert.True(t, strings.HasSuffix(lines[0], "..."))
+	assert.Equal(t, "FORM", lines[0][len(lines[1])-1:len(lines[1])+3])
+
+	// A position outside the query, or none at all, cannot be located
+	assert.Nil(t, (&UpstreamError{Position: &QueryPosition{Line: 4, Column: 1}}).Locate("SELECT 1"))
+	assert.Nil(t, (&UpstreamError{}).Locate("SELECT 1"))
+}
+
+func TestValidateQuery_DremioProbe(t *testing.T) {
+	var submitted string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			SQL string `json:"sql"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body.SQL != "" {
+			submitted = body.SQL
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"errorMessage": "PARSE ERROR: Encountered \"FORM\" at line 2, column 10.",
+		})
+	}))
+	defer srv.Close()
+
+	host, port := hostPort(t, srv.URL)
+	ds, err := NewDremioRESTClient(host, port, "", "", zap.NewNop())
+	require.NoError(t, err)
+
+	err = ValidateQuery(context.Background(), ds, "SELECT * FORM tender_data;")
+	assert.Equal(t, "SELECT * FROM (\nSELECT * FORM tender_data\n) AS validate_probe LIMIT 0", submitted)
+
+	var upstreamErr *UpstreamError
+	require.ErrorAs(t, err, &upstreamErr)
+	assert.Equal(t, ErrorClassSyntax, upstreamErr.Class)
+	assert.Equal(t, &QueryPosition{Line: 1, Column: 10}, upstreamErr.Position)
+}
+
+func TestValidateQuery_BigQueryDryRun(t *testing.T) {
+	var dryRun bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var job struct {
+			Configuration struct {
+				DryRun bool `json:"dryRun"`
+			} `json:"configuration"`
+		}
+		json.NewDecoder(r.Body).Decode(&job)
+		dryRun = job.Configuration.DryRun
+
+		message := `Syntax error: Unexpected identifier "FORM" at [1:10]`
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": map[string]interface{}{
+				"code":    http.StatusBadRequest,
+				"message": message,
+				"errors": []map[string]string{
+					{"message": message, "domain": "global", "reason": "invalidQuery"},
+				},
+			},
+		})
+	}))
+	defer srv.Close()
+
+	client, err := clients.NewBigQueryClient(
+		config.BigQueryConfig{ProjectID: "test-project"},
+		zap.NewNop(),
+		option.WithEndpoint(srv.URL),
+		option.WithHTTPClient(srv.Client()),
+		option.WithoutAuthentication(),
+	)
+	require.NoError(t, err)
+
+	wrapper := &BigQueryWrapper{client: client, logger: zap.NewNop(), sanitizer: NewSQLSanitizer()}
+	defer wrapper.Close()
+
+	err = ValidateQuery(context.Background(), wrapper, "SELECT * FORM `test-project.ds.t`")
+	assert.True(t, dryRun)
+
+	var upstreamErr *UpstreamError
+	require.ErrorAs(t, err, &upstreamErr)
+	assert.Equal(t, ErrorClassSyntax, upstreamErr.Class)
+	assert.Equal(t, &QueryPosition{Line: 1, Column: 10}, upstreamErr.Position)
+}
@@ -0,0 +1,43 @@
+package datasource
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// Validator is implemented by data sources that can check a query without
+// running it, such as BigQuery's dry run
+type Validator interface {
+	ValidateQuery(ctx context.Context, query string) error
+}
+
+// ValidateQuery checks that query parses and resolves on source without
+// returning data. Sources that are not a Validator are probed with the query
+// wrapped in a LIMIT 0 select, which Dremio plans but does not scan.
+func ValidateQuery(ctx context.Context, source DataSource, query string) error {
+	if v, ok := source.(Validator); ok {
+		return v.ValidateQuery(ctx, query)
+	}
+
+	// The query starts on its own line so reported columns stay unchanged
+	probe := "SELECT * FROM (\n" + strings.TrimRight(strings.TrimSpace(query), ";") + "\n) AS validate_probe LIMIT 0"
+	_, err := source.ExecuteQuery(ctx, probe, nil)
+	return shiftPositionLines(err, 1)
+}
+
+// shiftPositionLines moves the position of an upstream error in a query that
+// was wrapped, starting lines down, back onto the query. A position in the
+// wrapper itself is dropped.
+func shiftPositionLines(err error, lines int) error {
+	var upstreamErr *UpstreamError
+	if !errors.As(err, &upstreamErr) || upstreamErr.Position == nil {
+		return err
+	}
+	shifted := *upstreamErr
+	shifted.Position = nil
+	if line := upstreamErr.Position.Line - lines; line >= 1 {
+		shifted.Position = &QueryPosition{Line: line, Column: upstreamErr.Position.Column}
+	}
+	return &shifted
+}
@@ -0,0 +1,285 @@
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+)
+
+// demoKey is the API key config.Load falls back to without API_KEYS
+const demoKey = "demo-key-123"
+
+// minKeyLength is the length below which a key is reported as guessable
+const minKeyLength = 16
+
+// Pinger is a client that can check its connection
+type Pinger interface {
+	TestConnection(ctx context.Context) error
+}
+
+// hints are what to change for the kinds of failure of an integration
+type hints struct {
+	reach string // The host could not be reached
+	auth  string // The credentials were refused
+}
+
+// hint returns the hint of err's kind, or "" when the kind is unknown
+func (h hints) hint(err error) string {
+	msg := strings.ToLower(err.Error())
+	switch {
+	case containsAny(msg, "x509", "certificate", "tls:", "first record does not look like a tls handshake"):
+		return "The TLS setting does not match the server: enable TLS for a TLS endpoint, or disable it for a plain one"
+	case containsAny(msg, "401", "403", "unauthenticated", "unauthorized", "authentication failed", "permission", "forbidden", "access denied", "invalid credentials", "noauth", "wrongpass"):
+		return h.auth
+	case containsAny(msg, "connection refused", "no such host", "i/o timeout", "deadline exceeded", "network is unreachable", "unavailable", "connection reset"):
+		return h.reach
+	}
+	return ""
+}
+
+func containsAny(s string, parts ...string) bool {
+	for _, part := range parts {
+		if strings.Contains(s, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedisCheck pings Redis with ping, which should connect as the cache does.
+// It is skipped when host is empty, as the server then caches nothing.
+func RedisCheck(host string, ping func(ctx context.Context) error) Check {
+	h := hints{
+		reach: "Check REDIS_HOST and REDIS_PORT; without Redis the gateway runs with no cache",
+		auth:  "Check REDIS_PASSWORD",
+	}
+	return Check{Name: "redis", Run: func(ctx context.Context) error {
+		if host == "" {
+			return Skip("REDIS_HOST is not set; results are not cached")
+		}
+		if err := ping(ctx); err != nil {
+			return Fail(err, h.hint(err))
+		}
+		return nil
+	}}
+}
+
+// DremioRESTCheck logs in to Dremio's REST API with open, which should
+// build the client the server uses for admin endpoints and job lookups, and
+// reads the catalog. It is skipped when host is empty.
+func DremioRESTCheck(host string, open func() (Pinger, error)) Check {
+	h := hints{
+		reach: "Check DREMIO_HOST and DREMIO_REST_PORT (9047 by default); the REST API is not served on the Flight port",
+		auth:  "Check DREMIO_USERNAME and DREMIO_PASSWORD, DREMIO_TOKEN or DREMIO_CREDENTIALS_FILE",
+	}
+	return Check{Name: "dremio rest", Run: func(ctx context.Context) error {
+		if host == "" {
+			return Skip("DREMIO_HOST is not set")
+		}
+		client, err := open()
+		if err != nil {
+			return Fail(err, h.hint(err))
+		}
+		if err := client.TestConnection(ctx); err != nil {
+			return Fail(err, h.hint(err))
+		}
+		return nil
+	}}
+}
+
+// Source is a configured data source. Open should build it with the data
+// source factory, as the server does; it runs within the check's timeout, so
+// a source that dials or logs in while being built cannot stall the report.
+type Source struct {
+	Name string
+	Type datasource.DataSourceType
+	Open func() (datasource.DataSource, error)
+}
+
+// openedSource holds a source its connection check opened, for the table
+// checks after it
+type openedSource struct {
+	mu        sync.Mutex
+	source    datasource.DataSource
+	connected bool
+}
+
+func (o *openedSource) set(source datasource.DataSource, connected bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.source, o.connected = source, connected
+}
+
+// get returns the source when its connection check passed
+func (o *openedSource) get() (datasource.DataSource, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.source, o.connected
+}
+
+func (o *openedSource) close() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.source != nil {
+		o.source.Close()
+	}
+}
+
+// SourceChecks returns, for each source, a check that opens it and tests
+// its connection, and a read of one row of each table security allows for
+// its type. The reads of a source whose connection failed are skipped.
+// closeAll closes the sources the checks opened.
+func SourceChecks(sources []Source, security *config.SecurityConfig) (checks []Check, closeAll func()) {
+	var opened []*openedSource
+	for _, s := range sources {
+		h := hints{
+			reach: fmt.Sprintf("Check the host and port of %s (DATA_SOURCE_%s_HOST, or DREMIO_HOST for the default sources)", s.Name, s.Name),
+			auth:  fmt.Sprintf("Check the credentials of %s: its username and password or token, or the BigQuery service account", s.Name),
+		}
+		source := &openedSource{}
+		opened = append(opened, source)
+		checks = append(checks, Check{Name: "source " + s.Name, Run: func(ctx context.Context) error {
+			ds, err := s.Open()
+			if err != nil {
+				return Fail(err, h.hint(err))
+			}
+			if err := ds.TestConnection(ctx); err != nil {
+				source.set(ds, false)
+				return Fail(err, h.hint(err))
+			}
+			source.set(ds, true)
+			return nil
+		}})
+
+		for _, table := range allowedTables(security, s.Type) {
+			checks = append(checks, Check{Name: fmt.Sprintf("table %s on %s", table, s.Name), Run: func(ctx context.Context) error {
+				ds, ok := source.get()
+				if !ok {
+					return Skip("%s is not connected", s.Name)
+				}
+				if _, err := ds.GetData(ctx, table, &datasource.QueryOptions{Limit: 1}); err != nil {
+					return Fail(err, tableHint(err, h))
+				}
+				return nil
+			}})
+		}
+	}
+	return checks, func() {
+		for _, source := range opened {
+			source.close()
+		}
+	}
+}
+
+// allowedTables returns the tables security allows on sources of type t
+func allowedTables(security *config.SecurityConfig, t datasource.DataSourceType) []string {
+	switch t {
+	case datasource.DataSourceDremio:
+		return security.AllowedDremioTables
+	case datasource.DataSourceBigQuery:
+		return security.AllowedBigQueryTables
+	}
+	return nil
+}
+
+// tableHint adds the failures of a table read to those of its source
+func tableHint(err error, h hints) string {
+	if hint := h.hint(err); hint != "" {
+		return hint
+	}
+	msg := strings.ToLower(err.Error())
+	switch {
+	case containsAny(msg, "not found", "does not exist", "not exist"):
+		return "The table is whitelisted but missing: check its name and the source's project or space, or remove it from the table whitelist"
+	case containsAny(msg, "not allowed"):
+		return "Add the table to the whitelist of the policy"
+	}
+	return ""
+}
+
+// KeysCheck checks the API keys of cfg: that requests can authenticate, that
+// the demo key is not served in production, that keys are long enough to
+// resist guessing, and that no key is bound to two tenants. Keys are never
+// printed.
+func KeysCheck(cfg *config.Config) Check {
+	return Check{Name: "api keys", Run: func(ctx context.Context) error {
+		var fails, warns, failFixes, warnFixes []string
+		fail := func(msg, fix string) { fails, failFixes = append(fails, msg), append(failFixes, fix) }
+		warn := func(msg, fix string) { warns, warnFixes = append(warns, msg), append(warnFixes, fix) }
+
+		apiKeys := nonEmpty(cfg.APIKeys)
+		adminKeys := nonEmpty(cfg.AdminKeys)
+		all := append(append(append([]string{}, apiKeys...), adminKeys...), nonEmpty(cfg.MetricsKeys)...)
+		owners := make(map[string][]string)
+		for _, t := range cfg.Tenants {
+			for _, key := range nonEmpty(t.APIKeys) {
+				all = append(all, key)
+				owners[key] = append(owners[key], t.ID)
+			}
+		}
+
+		if len(all) == 0 && !cfg.KeyStoreEnabled {
+			fail("no API keys are configured, so every request is rejected", "Set API_KEYS")
+		}
+		for _, key := range apiKeys {
+			if key != demoKey {
+				continue
+			}
+			if cfg.Environment == "production" {
+				fail("API_KEYS holds the demo key in production", "Replace "+demoKey+" in API_KEYS with generated keys")
+			} else {
+				warn("API_KEYS holds the demo key", "Replace "+demoKey+" before going to production")
+			}
+		}
+		short := 0
+		for _, key := range all {
+			if len(key) < minKeyLength && key != demoKey {
+				short++
+			}
+		}
+		if short > 0 {
+			warn(fmt.Sprintf("%d keys are shorter than %d characters", short, minKeyLength), "Use long random keys, e.g. the output of openssl rand -hex 24")
+		}
+		if len(adminKeys) == 0 && !cfg.KeyStoreEnabled {
+			warn("ADMIN_API_KEYS is empty, so the admin endpoints cannot be reached", "Set ADMIN_API_KEYS")
+		}
+		var shared []string
+		for _, tenants := range owners {
+			if len(tenants) > 1 {
+				sort.Strings(tenants)
+				shared = append(shared, strings.Join(tenants, " and "))
+			}
+		}
+		sort.Strings(shared)
+		for _, tenants := range shared {
+			fail("a key is bound to tenants "+tenants, "Give each tenant its own keys in TENANT_<ID>_API_KEYS")
+		}
+		if cfg.KeyStoreEnabled && cfg.Redis.Host == "" {
+			warn("API_KEY_STORE_ENABLED without Redis keeps managed keys in memory, unshared across replicas", "Set REDIS_HOST")
+		}
+
+		switch {
+		case len(fails) > 0:
+			return Fail(errors.New(strings.Join(append(fails, warns...), "; ")), strings.Join(append(failFixes, warnFixes...), "; "))
+		case len(warns) > 0:
+			return Warn(strings.Join(warnFixes, "; "), "%s", strings.Join(warns, "; "))
+		}
+		return nil
+	}}
+}
+
+func nonEmpty(values []string) []string {
+	var kept []string
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
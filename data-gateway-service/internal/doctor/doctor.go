@@ -0,0 +1,161 @@
+// Package doctor checks that a gateway environment is configured and that
+// its integrations answer, through the clients the server itself uses, and
+// reports each problem with what to change.
+package doctor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Status is the outcome of a check
+type Status string
+
+const (
+	StatusOK   Status = "ok"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+	StatusSkip Status = "skip" // Not configured, or blocked by a failed check
+)
+
+// Check probes one integration or setting. Run returns nil when it passed,
+// a Problem to set the status and hint, or any other error to fail.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// Problem is an error of a check with its status and what to change
+type Problem struct {
+	Status Status
+	Err    error
+	Hint   string
+}
+
+func (p *Problem) Error() string { return p.Err.Error() }
+
+func (p *Problem) Unwrap() error { return p.Err }
+
+// Fail fails a check with err and hint
+func Fail(err error, hint string) error {
+	return &Problem{Status: StatusFail, Err: err, Hint: hint}
+}
+
+// Warn passes a check with a warning
+func Warn(hint, format string, args ...interface{}) error {
+	return &Problem{Status: StatusWarn, Err: fmt.Errorf(format, args...), Hint: hint}
+}
+
+// Skip skips a check for reason
+func Skip(format string, args ...interface{}) error {
+	return &Problem{Status: StatusSkip, Err: fmt.Errorf(format, args...)}
+}
+
+// Result is the outcome of one check
+type Result struct {
+	Check     string `json:"check"`
+	Status    Status `json:"status"`
+	Message   string `json:"message,omitempty"`
+	Hint      string `json:"hint,omitempty"`
+	ElapsedMS int64  `json:"elapsed_ms"`
+}
+
+// Report is the outcome of every check, in the order they ran
+type Report struct {
+	Results  []Result `json:"results"`
+	Failed   int      `json:"failed"`
+	Warnings int      `json:"warnings"`
+}
+
+// OK reports whether no check failed; warnings do not count
+func (r Report) OK() bool {
+	return r.Failed == 0
+}
+
+// Run runs checks in order, each given at most timeout
+func Run(ctx context.Context, checks []Check, timeout time.Duration) Report {
+	report := Report{Results: make([]Result, 0, len(checks))}
+	for _, check := range checks {
+		result := runCheck(ctx, check, timeout)
+		switch result.Status {
+		case StatusFail:
+			report.Failed++
+		case StatusWarn:
+			report.Warnings++
+		}
+		report.Results = append(report.Results, result)
+	}
+	return report
+}
+
+// runCheck runs check, failing it when it outlives timeout. A check that
+// ignores its context is abandoned rather than waited for.
+func runCheck(ctx context.Context, check Check, timeout time.Duration) Result {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- check.Run(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = Fail(fmt.Errorf("no answer within %s", timeout), "Check that the service is reachable from this host; firewalls often drop rather than refuse")
+	}
+
+	result := Result{Check: check.Name, Status: StatusOK, ElapsedMS: time.Since(start).Milliseconds()}
+	if err == nil {
+		return result
+	}
+	result.Status, result.Message = StatusFail, err.Error()
+	var problem *Problem
+	if errors.As(err, &problem) {
+		result.Status, result.Hint = problem.Status, problem.Hint
+	}
+	return result
+}
+
+// WriteJSON writes the report as indented JSON
+func (r Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// ANSI colors of each status in text reports
+var statusColors = map[Status]string{
+	StatusOK:   "\033[32m",
+	StatusWarn: "\033[33m",
+	StatusFail: "\033[31m",
+	StatusSkip: "\033[90m",
+}
+
+const colorReset = "\033[0m"
+
+// WriteText writes the report a line per check, each problem's message and
+// hint indented below it, and a summary. color adds ANSI colors.
+func (r Report) WriteText(w io.Writer, color bool) error {
+	for _, result := range r.Results {
+		label := fmt.Sprintf("%-4s", result.Status)
+		if color {
+			label = statusColors[result.Status] + label + colorReset
+		}
+		if _, err := fmt.Fprintf(w, "[%s] %s (%dms)\n", label, result.Check, result.ElapsedMS); err != nil {
+			return err
+		}
+		if result.Message != "" {
+			fmt.Fprintf(w, "       %s\n", result.Message)
+		}
+		if result.Hint != "" {
+			fmt.Fprintf(w, "       -> %s\n", result.Hint)
+		}
+	}
+	_, err := fmt.Fprintf(w, "\n%d checks, %d failed, %d warnings\n", len(r.Results), r.Failed, r.Warnings)
+	return err
+}
@@ -0,0 +1,261 @@
+package doctor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+)
+
+// fakeSource fails its connection and reads of missing tables with canned
+// errors
+type fakeSource struct {
+	connErr error
+	missing map[string]error
+	read    []string
+	closed  bool
+}
+
+func (s *fakeSource) ExecuteQuery(ctx context.Context, query string, opts *datasource.QueryOptions) (*datasource.QueryResult, error) {
+	return &datasource.QueryResult{}, nil
+}
+
+func (s *fakeSource) GetData(ctx context.Context, table string, opts *datasource.QueryOptions) (*datasource.QueryResult, error) {
+	s.read = append(s.read, table)
+	if err := s.missing[table]; err != nil {
+		return nil, err
+	}
+	return &datasource.QueryResult{Data: []map[string]interface{}{{"id": 1}}, Count: 1}, nil
+}
+
+func (s *fakeSource) TestConnection(ctx context.Context) error { return s.connErr }
+
+func (s *fakeSource) GetType() datasource.DataSourceType { return datasource.DataSourceDremio }
+
+func (s *fakeSource) Close() error {
+	s.closed = true
+	return nil
+}
+
+type pingerFunc func(ctx context.Context) error
+
+func (f pingerFunc) TestConnection(ctx context.Context) error { return f(ctx) }
+
+func opens(source *fakeSource) func() (datasource.DataSource, error) {
+	return func() (datasource.DataSource, error) { return source, nil }
+}
+
+func resultsByCheck(report Report) map[string]Result {
+	results := make(map[string]Result, len(report.Results))
+	for _, result := range report.Results {
+		results[result.Check] = result
+	}
+	return results
+}
+
+func TestRun_StatusesAndCounts(t *testing.T) {
+	report := Run(context.Background(), []Check{
+		{Name: "passes", Run: func(ctx context.Context) error { return nil }},
+		{Name: "warns", Run: func(ctx context.Context) error { return Warn("fix it", "%d keys are short", 2) }},
+		{Name: "skips", Run: func(ctx context.Context) error { return Skip("not configured") }},
+		{Name: "fails", Run: func(ctx context.Context) error { return errors.New("boom") }},
+	}, time.Second)
+
+	require.Len(t, report.Results, 4)
+	assert.Equal(t, []Status{StatusOK, StatusWarn, StatusSkip, StatusFail}, []Status{
+		report.Results[0].Status, report.Results[1].Status, report.Results[2].Status, report.Results[3].Status,
+	})
+	assert.Equal(t, "2 keys are short", report.Results[1].Message)
+	assert.Equal(t, "fix it", report.Results[1].Hint)
+	assert.Equal(t, 1, report.Failed)
+	assert.Equal(t, 1, report.Warnings)
+	assert.False(t, report.OK())
+}
+
+func TestRun_TimesOutHangingCheck(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	report := Run(context.Background(), []Check{
+		{Name: "hangs", Run: func(ctx context.Context) error { <-release; return nil }},
+	}, 20*time.Millisecond)
+
+	assert.Equal(t, StatusFail, report.Results[0].Status)
+	assert.Equal(t, "no answer within 20ms", report.Results[0].Message)
+	assert.NotEmpty(t, report.Results[0].Hint)
+}
+
+func TestRedisCheck(t *testing.T) {
+	skipped := Run(context.Background(), []Check{RedisCheck("", nil)}, time.Second)
+	assert.Equal(t, StatusSkip, skipped.Results[0].Status)
+	assert.True(t, skipped.OK())
+
+	refused := Run(context.Background(), []Check{RedisCheck("redis", func(ctx context.Context) error {
+		return errors.New("dial tcp 10.0.0.5:6379: connect: connection refused")
+	})}, time.Second)
+	assert.Equal(t, StatusFail, refused.Results[0].Status)
+	assert.Contains(t, refused.Results[0].Hint, "REDIS_HOST")
+
+	wrongPass := Run(context.Background(), []Check{RedisCheck("redis", func(ctx context.Context) error {
+		return errors.New("WRONGPASS invalid username-password pair")
+	})}, time.Second)
+	assert.Equal(t, "Check REDIS_PASSWORD", wrongPass.Results[0].Hint)
+}
+
+func TestDremioRESTCheck(t *testing.T) {
+	loginFailed := Run(context.Background(), []Check{DremioRESTCheck("dremio", func() (Pinger, error) {
+		return nil, errors.New("login failed with status 401: invalid credentials")
+	})}, time.Second)
+	assert.Equal(t, StatusFail, loginFailed.Results[0].Status)
+	assert.Contains(t, loginFailed.Results[0].Hint, "DREMIO_USERNAME")
+
+	tlsMismatch := Run(context.Background(), []Check{DremioRESTCheck("dremio", func() (Pinger, error) {
+		return pingerFunc(func(ctx context.Context) error {
+			return errors.New("http: server gave HTTP response to HTTPS client: tls: first record does not look like a TLS handshake")
+		}), nil
+	})}, time.Second)
+	assert.Contains(t, tlsMismatch.Results[0].Hint, "TLS")
+
+	connected := Run(context.Background(), []Check{DremioRESTCheck("dremio", func() (Pinger, error) {
+		return pingerFunc(func(ctx context.Context) error { return nil }), nil
+	})}, time.Second)
+	assert.True(t, connected.OK())
+	assert.Equal(t, 0, connected.Warnings)
+}
+
+func TestSourceChecks(t *testing.T) {
+	security := &config.SecurityConfig{AllowedDremioTables: []string{"tender_data", "rup_data"}}
+	healthy := &fakeSource{missing: map[string]error{"rup_data": errors.New(`table "rup_data" not found`)}}
+	refused := &fakeSource{connErr: errors.New("rpc error: code = Unavailable desc = connection refused")}
+
+	checks, closeAll := SourceChecks([]Source{
+		{Name: "dremio", Type: datasource.DataSourceDremio, Open: opens(healthy)},
+		{Name: "replica", Type: datasource.DataSourceDremio, Open: opens(refused)},
+		{Name: "broken", Type: datasource.DataSourceDremio, Open: func() (datasource.DataSource, error) {
+			return nil, errors.New("data source broken: host is required")
+		}},
+	}, security)
+	report := Run(context.Background(), checks, time.Second)
+	closeAll()
+	results := resultsByCheck(report)
+
+	assert.Len(t, report.Results, 9)
+	assert.Equal(t, StatusOK, results["source dremio"].Status)
+	assert.Equal(t, StatusOK, results["table tender_data on dremio"].Status)
+	assert.Equal(t, StatusFail, results["table rup_data on dremio"].Status)
+	assert.Contains(t, results["table rup_data on dremio"].Hint, "whitelisted but missing")
+	assert.Equal(t, []string{"tender_data", "rup_data"}, healthy.read)
+
+	assert.Equal(t, StatusFail, results["source replica"].Status)
+	assert.Contains(t, results["source replica"].Hint, "host and port of replica")
+	assert.Equal(t, StatusSkip, results["table tender_data on replica"].Status)
+	assert.Empty(t, refused.read)
+
+	assert.Equal(t, StatusFail, results["source broken"].Status)
+	assert.Equal(t, StatusSkip, results["table rup_data on broken"].Status)
+	assert.Equal(t, 3, report.Failed)
+
+	assert.True(t, healthy.closed)
+	assert.True(t, refused.closed)
+}
+
+func TestKeysCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      config.Config
+		status   Status
+		contains string
+	}{
+		{
+			name:   "long keys",
+			cfg:    config.Config{APIKeys: []string{"0123456789abcdef0123"}, AdminKeys: []string{"fedcba9876543210fedc"}},
+			status: StatusOK,
+		},
+		{
+			name:     "no keys",
+			cfg:      config.Config{},
+			status:   StatusFail,
+			contains: "every request is rejected",
+		},
+		{
+			name:   "no keys with the key store",
+			cfg:    config.Config{KeyStoreEnabled: true, Redis: config.RedisConfig{Host: "redis"}},
+			status: StatusOK,
+		},
+		{
+			name:     "demo key in production",
+			cfg:      config.Config{Environment: "production", APIKeys: []string{demoKey}, AdminKeys: []string{"fedcba9876543210fedc"}},
+			status:   StatusFail,
+			contains: "demo key in production",
+		},
+		{
+			name:     "demo key in development",
+			cfg:      config.Config{Environment: "development", APIKeys: []string{demoKey}, AdminKeys: []string{"fedcba9876543210fedc"}},
+			status:   StatusWarn,
+			contains: "demo key",
+		},
+		{
+			name:     "short keys",
+			cfg:      config.Config{APIKeys: []string{"abc", "0123456789abcdef0123"}, AdminKeys: []string{"admin"}},
+			status:   StatusWarn,
+			contains: "2 keys are shorter than 16 characters",
+		},
+		{
+			name: "key shared by tenants",
+			cfg: config.Config{AdminKeys: []string{"fedcba9876543210fedc"}, Tenants: []config.TenantConfig{
+				{ID: "lpse-b", APIKeys: []string{"0123456789abcdef0123"}},
+				{ID: "lpse-a", APIKeys: []string{"0123456789abcdef0123"}},
+			}},
+			status:   StatusFail,
+			contains: "a key is bound to tenants lpse-a and lpse-b",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := Run(context.Background(), []Check{KeysCheck(&tt.cfg)}, time.Second)
+			result := report.Results[0]
+			assert.Equal(t, tt.status, result.Status, result.Message)
+			assert.Contains(t, result.Message, tt.contains)
+			// Keys never reach the report
+			assert.NotContains(t, result.Message+result.Hint, "0123456789abcdef0123")
+		})
+	}
+}
+
+func TestReport_Write(t *testing.T) {
+	report := Run(context.Background(), []Check{
+		{Name: "redis", Run: func(ctx context.Context) error { return nil }},
+		{Name: "source dremio", Run: func(ctx context.Context) error {
+			return Fail(errors.New("connection refused"), "Check DREMIO_HOST")
+		}},
+	}, time.Second)
+
+	var text bytes.Buffer
+	require.NoError(t, report.WriteText(&text, false))
+	assert.Contains(t, text.String(), "[ok  ] redis")
+	assert.Contains(t, text.String(), "[fail] source dremio")
+	assert.Contains(t, text.String(), "       connection refused\n       -> Check DREMIO_HOST\n")
+	assert.Contains(t, text.String(), "2 checks, 1 failed, 0 warnings")
+	assert.NotContains(t, text.String(), "\033[")
+
+	var colored bytes.Buffer
+	require.NoError(t, report.WriteText(&colored, true))
+	assert.Contains(t, colored.String(), "\033[31mfail\033[0m")
+
+	var encoded bytes.Buffer
+	require.NoError(t, report.WriteJSON(&encoded))
+	var decoded Report
+	require.NoError(t, json.Unmarshal(encoded.Bytes(), &decoded))
+	assert.Equal(t, 1, decoded.Failed)
+	assert.Equal(t, StatusFail, decoded.Results[1].Status)
+	assert.Equal(t, "Check DREMIO_HOST", decoded.Results[1].Hint)
+}
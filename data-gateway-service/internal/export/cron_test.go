@@ -0,0 +1,55 @@
+package export
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSchedule_Matches(t *testing.T) {
+	tests := []struct {
+		expr  string
+		at    time.Time
+		match bool
+	}{
+		{"*/15 * * * *", time.Date(2025, 3, 4, 10, 45, 0, 0, time.UTC), true},
+		{"*/15 * * * *", time.Date(2025, 3, 4, 10, 46, 0, 0, time.UTC), false},
+		{"30 2 * * 1-5", time.Date(2025, 3, 3, 2, 30, 0, 0, time.UTC), true},  // Monday
+		{"30 2 * * 1-5", time.Date(2025, 3, 2, 2, 30, 0, 0, time.UTC), false}, // Sunday
+		{"0 0 * * 7", time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC), true},      // 7 is Sunday too
+		{"0 6 1,15 * *", time.Date(2025, 3, 15, 6, 0, 0, 0, time.UTC), true},
+		{"0 6 1,15 * *", time.Date(2025, 3, 16, 6, 0, 0, 0, time.UTC), false},
+		// Both day fields restricted: either may match
+		{"0 0 1 * 1", time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC), true},
+		{"@daily", time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC), true},
+		{"@daily", time.Date(2025, 3, 3, 1, 0, 0, 0, time.UTC), false},
+	}
+
+	for _, tt := range tests {
+		schedule, err := ParseSchedule(tt.expr)
+		require.NoError(t, err, tt.expr)
+		assert.Equal(t, tt.match, schedule.Matches(tt.at), "%s at %s", tt.expr, tt.at)
+	}
+}
+
+func TestParseSchedule_Invalid(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "0 0 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		_, err := ParseSchedule(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestSchedule_Next(t *testing.T) {
+	schedule, err := ParseSchedule("30 2 * * 1")
+	require.NoError(t, err)
+
+	// Wednesday -> the following Monday
+	next := schedule.Next(time.Date(2025, 3, 5, 12, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2025, 3, 10, 2, 30, 0, 0, time.UTC), next)
+
+	never, err := ParseSchedule("0 0 31 2 *")
+	require.NoError(t, err)
+	assert.True(t, never.Next(time.Now()).IsZero())
+}
@@ -0,0 +1,94 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	storage "google.golang.org/api/storage/v1"
+)
+
+// Uploader streams an object to a bucket in one storage service
+type Uploader interface {
+	// Upload stores everything read from r at bucket/key. It returns once the
+	// object is committed, or aborts the upload when r fails.
+	Upload(ctx context.Context, bucket, key, contentType string, r io.Reader) error
+}
+
+// Destination is a parsed gs:// or s3:// export location
+type Destination struct {
+	Scheme string
+	Bucket string
+	Key    string // May contain {date} and {timestamp} placeholders
+}
+
+// ParseDestination parses a gs://bucket/key or s3://bucket/key URL
+func ParseDestination(raw string) (Destination, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return Destination{}, fmt.Errorf("invalid destination %q: %w", raw, err)
+	}
+	if u.Scheme != "gs" && u.Scheme != "s3" {
+		return Destination{}, fmt.Errorf("destination %q must use gs:// or s3://", raw)
+	}
+	key := strings.TrimPrefix(u.Path, "/")
+	if u.Host == "" || key == "" {
+		return Destination{}, fmt.Errorf("destination %q needs a bucket and object key", raw)
+	}
+	return Destination{Scheme: u.Scheme, Bucket: u.Host, Key: key}, nil
+}
+
+// ObjectKey expands the key placeholders for a run started at t
+func (d Destination) ObjectKey(t time.Time) string {
+	t = t.UTC()
+	return strings.NewReplacer(
+		"{date}", t.Format("2006-01-02"),
+		"{timestamp}", t.Format("20060102T150405Z"),
+	).Replace(d.Key)
+}
+
+// URL returns the destination URL for an object key
+func (d Destination) URL(key string) string {
+	return d.Scheme + "://" + d.Bucket + "/" + key
+}
+
+// gcsChunkSize is the size of each request of a resumable GCS upload
+const gcsChunkSize = 8 << 20
+
+// GCSUploader uploads objects to Google Cloud Storage with resumable uploads
+type GCSUploader struct {
+	service *storage.Service
+}
+
+// NewGCSUploader creates a GCS uploader; without a credentials file the
+// application default credentials are used
+func NewGCSUploader(ctx context.Context, credentialsFile string, opts ...option.ClientOption) (*GCSUploader, error) {
+	opts = append([]option.ClientOption{option.WithScopes(storage.DevstorageReadWriteScope)}, opts...)
+	if credentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(credentialsFile))
+	}
+
+	service, err := storage.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCS client: %w", err)
+	}
+	return &GCSUploader{service: service}, nil
+}
+
+// Upload streams r to gs://bucket/key in chunks
+func (u *GCSUploader) Upload(ctx context.Context, bucket, key, contentType string, r io.Reader) error {
+	object := &storage.Object{Name: key, ContentType: contentType}
+	_, err := u.service.Objects.Insert(bucket, object).
+		Media(r, googleapi.ContentType(contentType), googleapi.ChunkSize(gcsChunkSize)).
+		Context(ctx).
+		Do()
+	if err != nil {
+		return fmt.Errorf("GCS upload of gs://%s/%s failed: %w", bucket, key, err)
+	}
+	return nil
+}
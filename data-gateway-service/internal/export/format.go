@@ -0,0 +1,353 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/compress"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+
+	"go-data-gateway/internal/csvfmt"
+)
+
+// Format is an export file format
+type Format string
+
+const (
+	FormatCSV     Format = "csv"
+	FormatNDJSON  Format = "ndjson"
+	FormatParquet Format = "parquet"
+)
+
+// ParseFormat validates a format name
+func ParseFormat(name string) (Format, error) {
+	switch f := Format(name); f {
+	case FormatCSV, FormatNDJSON, FormatParquet:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unsupported export format %q", name)
+	}
+}
+
+// ContentType returns the MIME type of the format
+func (f Format) ContentType() string {
+	switch f {
+	case FormatCSV:
+		return "text/csv"
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	default:
+		return "application/vnd.apache.parquet"
+	}
+}
+
+// RowWriter encodes chunks of rows to an export file
+type RowWriter interface {
+	WriteRows(rows []map[string]interface{}) error
+	// Close flushes buffered output and writes any footer; it does not close
+	// the underlying writer
+	Close() error
+}
+
+// NewRowWriter creates a writer for format on w. CSV values are formatted
+// with formatter when it is not nil; other formats ignore it.
+func NewRowWriter(format Format, w io.Writer, formatter *csvfmt.Formatter) (RowWriter, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w, formatter)
+	case FormatNDJSON:
+		return &ndjsonWriter{enc: json.NewEncoder(w)}, nil
+	case FormatParquet:
+		return &parquetWriter{w: w}, nil
+	default:
+		return nil, fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+// rowColumns returns the sorted column names of a row
+func rowColumns(row map[string]interface{}) []string {
+	columns := make([]string, 0, len(row))
+	for column := range row {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// csvWriter writes a header taken from the first row, then one record per row
+type csvWriter struct {
+	w         *csv.Writer
+	formatter *csvfmt.Formatter
+	columns   []string
+}
+
+func newCSVWriter(w io.Writer, formatter *csvfmt.Formatter) (*csvWriter, error) {
+	writer := &csvWriter{w: csv.NewWriter(w), formatter: formatter}
+	if formatter != nil {
+		writer.w.Comma = formatter.Delimiter()
+		if formatter.BOM() {
+			if _, err := io.WriteString(w, csvfmt.BOM); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return writer, nil
+}
+
+func (c *csvWriter) WriteRows(rows []map[string]interface{}) error {
+	if c.formatter != nil && c.formatter.Flatten() {
+		flat := make([]map[string]interface{}, len(rows))
+		for i, row := range rows {
+			flat[i] = csvfmt.FlattenRow(row)
+		}
+		rows = flat
+	}
+	if c.columns == nil && len(rows) > 0 {
+		c.columns = rowColumns(rows[0])
+		if err := c.w.Write(c.columns); err != nil {
+			return err
+		}
+	}
+
+	record := make([]string, len(c.columns))
+	for _, row := range rows {
+		for i, column := range c.columns {
+			if c.formatter != nil {
+				record[i] = c.formatter.Value(column, row[column])
+			} else {
+				record[i] = csvValue(row[column])
+			}
+		}
+		if err := c.w.Write(record); err != nil {
+			return err
+		}
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func csvValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case time.Time:
+		return val.Format(time.RFC3339)
+	default:
+		return csvfmt.Text(val)
+	}
+}
+
+// ndjsonWriter writes one JSON object per line
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func (n *ndjsonWriter) WriteRows(rows []map[string]interface{}) error {
+	for _, row := range rows {
+		if err := n.enc.Encode(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *ndjsonWriter) Close() error {
+	return nil
+}
+
+// parquetWriter infers an Arrow schema from the first chunk and writes each
+// chunk as a row group. Values that do not fit a column's inferred type are
+// written as nulls.
+type parquetWriter struct {
+	w       io.Writer
+	schema  *arrow.Schema
+	builder *array.RecordBuilder
+	fw      *pqarrow.FileWriter
+}
+
+func (p *parquetWriter) WriteRows(rows []map[string]interface{}) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	if p.fw == nil {
+		if err := p.open(inferSchema(rows)); err != nil {
+			return err
+		}
+	}
+
+	for _, row := range rows {
+		for i, field := range p.schema.Fields() {
+			appendValue(p.builder.Field(i), field.Type, row[field.Name])
+		}
+	}
+
+	record := p.builder.NewRecord()
+	defer record.Release()
+	return p.fw.Write(record)
+}
+
+func (p *parquetWriter) open(schema *arrow.Schema) error {
+	props := parquet.NewWriterProperties(parquet.WithCompression(compress.Codecs.Snappy))
+	fw, err := pqarrow.NewFileWriter(schema, p.w, props, pqarrow.DefaultWriterProps())
+	if err != nil {
+		return fmt.Errorf("failed to create parquet writer: %w", err)
+	}
+	p.schema = schema
+	p.builder = array.NewRecordBuilder(memory.DefaultAllocator, schema)
+	p.fw = fw
+	return nil
+}
+
+func (p *parquetWriter) Close() error {
+	// An export without rows still produces a valid, empty file
+	if p.fw == nil {
+		if err := p.open(arrow.NewSchema(nil, nil)); err != nil {
+			return err
+		}
+	}
+	p.builder.Release()
+	return p.fw.Close()
+}
+
+// inferSchema picks a column type from the non-null values of the first
+// chunk, falling back to string when they disagree
+func inferSchema(rows []map[string]interface{}) *arrow.Schema {
+	types := make(map[string]arrow.DataType)
+	for _, row := range rows {
+		for column, v := range row {
+			t := arrowType(v)
+			if t == nil {
+				if _, seen := types[column]; !seen {
+					types[column] = nil
+				}
+				continue
+			}
+			switch prev := types[column]; {
+			case prev == nil:
+				types[column] = t
+			case prev.ID() == arrow.INT64 && t.ID() == arrow.FLOAT64,
+				prev.ID() == arrow.FLOAT64 && t.ID() == arrow.INT64:
+				types[column] = arrow.PrimitiveTypes.Float64
+			case !arrow.TypeEqual(prev, t):
+				types[column] = arrow.BinaryTypes.String
+			}
+		}
+	}
+
+	columns := make([]string, 0, len(types))
+	for column := range types {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	fields := make([]arrow.Field, 0, len(columns))
+	for _, column := range columns {
+		t := types[column]
+		if t == nil {
+			t = arrow.BinaryTypes.String
+		}
+		fields = append(fields, arrow.Field{Name: column, Type: t, Nullable: true})
+	}
+	return arrow.NewSchema(fields, nil)
+}
+
+func arrowType(v interface{}) arrow.DataType {
+	switch v.(type) {
+	case nil:
+		return nil
+	case bool:
+		return arrow.FixedWidthTypes.Boolean
+	case int, int8, int16, int32, int64, uint8, uint16, uint32:
+		return arrow.PrimitiveTypes.Int64
+	case float32, float64:
+		return arrow.PrimitiveTypes.Float64
+	case time.Time:
+		return arrow.FixedWidthTypes.Timestamp_us
+	default:
+		return arrow.BinaryTypes.String
+	}
+}
+
+func appendValue(b array.Builder, t arrow.DataType, v interface{}) {
+	if v == nil {
+		b.AppendNull()
+		return
+	}
+
+	switch t.ID() {
+	case arrow.BOOL:
+		if val, ok := v.(bool); ok {
+			b.(*array.BooleanBuilder).Append(val)
+			return
+		}
+	case arrow.INT64:
+		if val, ok := toInt64(v); ok {
+			b.(*array.Int64Builder).Append(val)
+			return
+		}
+	case arrow.FLOAT64:
+		if val, ok := toFloat64(v); ok {
+			b.(*array.Float64Builder).Append(val)
+			return
+		}
+	case arrow.TIMESTAMP:
+		if val, ok := v.(time.Time); ok {
+			b.(*array.TimestampBuilder).Append(arrow.Timestamp(val.UnixMicro()))
+			return
+		}
+	case arrow.STRING:
+		b.(*array.StringBuilder).Append(csvValue(v))
+		return
+	}
+	b.AppendNull()
+}
+
+func toInt64(v interface{}) (int64, bool) {
+	switch val := v.(type) {
+	case int:
+		return int64(val), true
+	case int8:
+		return int64(val), true
+	case int16:
+		return int64(val), true
+	case int32:
+		return int64(val), true
+	case int64:
+		return val, true
+	case uint8:
+		return int64(val), true
+	case uint16:
+		return int64(val), true
+	case uint32:
+		return int64(val), true
+	}
+	return 0, false
+}
+
+func toFloat64(v interface{}) (float64, bool) {
+	switch val := v.(type) {
+	case float32:
+		return float64(val), true
+	case float64:
+		return val, true
+	}
+	if i, ok := toInt64(v); ok {
+		return float64(i), true
+	}
+	return 0, false
+}
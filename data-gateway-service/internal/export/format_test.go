@@ -0,0 +1,117 @@
+package export
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/csvfmt"
+)
+
+func TestCSVWriter_UsesFirstRowColumns(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewRowWriter(FormatCSV, &buf, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, w.WriteRows([]map[string]interface{}{{"name": "a, b", "id": 1}}))
+	require.NoError(t, w.WriteRows([]map[string]interface{}{{"name": `say "hi"`, "id": 2, "extra": true}}))
+	require.NoError(t, w.Close())
+
+	assert.Equal(t, "id,name\n1,\"a, b\"\n2,\"say \"\"hi\"\"\"\n", buf.String())
+}
+
+func TestNDJSONWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewRowWriter(FormatNDJSON, &buf, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, w.WriteRows([]map[string]interface{}{{"id": 1}, {"id": 2}}))
+	require.NoError(t, w.Close())
+
+	assert.Equal(t, "{\"id\":1}\n{\"id\":2}\n", buf.String())
+}
+
+func TestParquetWriter_WritesFile(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewRowWriter(FormatParquet, &buf, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, w.WriteRows([]map[string]interface{}{
+		{"id": int64(1), "value": 1.5, "name": "a", "active": true},
+		{"id": int64(2), "value": nil, "name": "b", "active": false},
+	}))
+	require.NoError(t, w.Close())
+
+	// Parquet files start and end with the PAR1 magic
+	data := buf.Bytes()
+	require.Greater(t, len(data), 8)
+	assert.Equal(t, "PAR1", string(data[:4]))
+	assert.Equal(t, "PAR1", string(data[len(data)-4:]))
+}
+
+func TestInferSchema_WidensConflictingTypes(t *testing.T) {
+	schema := inferSchema([]map[string]interface{}{
+		{"amount": int64(1), "code": int64(1), "note": nil},
+		{"amount": 2.5, "code": "A"},
+	})
+
+	types := make(map[string]string)
+	for _, field := range schema.Fields() {
+		types[field.Name] = field.Type.Name()
+	}
+	assert.Equal(t, map[string]string{"amount": "float64", "code": "utf8", "note": "utf8"}, types)
+}
+
+func TestParseDestination(t *testing.T) {
+	dest, err := ParseDestination("s3://bucket/exports/{date}/tenders.csv")
+	require.NoError(t, err)
+	assert.Equal(t, Destination{Scheme: "s3", Bucket: "bucket", Key: "exports/{date}/tenders.csv"}, dest)
+
+	for _, raw := range []string{"https://bucket/key", "gs://bucket", "gs:///key"} {
+		_, err := ParseDestination(raw)
+		assert.Error(t, err, raw)
+	}
+}
+
+func TestCSVWriter_Locale(t *testing.T) {
+	formatter, err := csvfmt.New(csvfmt.Options{Locale: "id-ID", BOM: true}, []config.ColumnSpec{
+		{Name: "tanggal", Type: config.ColumnDate},
+	})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	w, err := NewRowWriter(FormatCSV, &buf, formatter)
+	require.NoError(t, err)
+
+	require.NoError(t, w.WriteRows([]map[string]interface{}{
+		{"nilai": 5000000000.5, "tanggal": "2025-03-07", "nama": "Jalan; tahap 2"},
+		{"nilai": nil, "tanggal": nil, "nama": "Gedung"},
+	}))
+	require.NoError(t, w.Close())
+
+	assert.Equal(t, csvfmt.BOM+"nama;nilai;tanggal\n\"Jalan; tahap 2\";5000000000,5;07/03/2025\nGedung;;\n", buf.String())
+}
+
+func TestNewJob_CSVOptions(t *testing.T) {
+	base := config.ExportConfig{Name: "tenders", Table: "nessie_iceberg.tender_data", Destination: "gs://bucket/tenders.csv"}
+
+	csvJob := base
+	csvJob.Format = "csv"
+	csvJob.CSV.Locale = "id-ID"
+	j, err := newJob(csvJob, "default")
+	require.NoError(t, err)
+	assert.Equal(t, "07/03/2025", j.csv.Value("tanggal_buat_paket", "2025-03-07"))
+
+	csvJob.CSV.Locale = "fr-FR"
+	_, err = newJob(csvJob, "default")
+	assert.Error(t, err)
+
+	parquetJob := base
+	parquetJob.Format = "parquet"
+	parquetJob.CSV.BOM = true
+	_, err = newJob(parquetJob, "default")
+	assert.Error(t, err)
+}
@@ -0,0 +1,318 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-data-gateway/internal/config"
+)
+
+// s3PartSize is the size of each multipart upload part; S3 requires at least
+// 5 MiB for every part but the last
+const s3PartSize = 8 << 20
+
+// S3Uploader uploads objects with S3 multipart uploads signed with SigV4.
+// It also works with S3-compatible stores through a custom endpoint.
+type S3Uploader struct {
+	cfg      config.S3Config
+	client   *http.Client
+	partSize int
+	now      func() time.Time
+}
+
+// NewS3Uploader creates an S3 uploader
+func NewS3Uploader(cfg config.S3Config) (*S3Uploader, error) {
+	if cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, errors.New("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required for s3:// destinations")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	return &S3Uploader{
+		cfg:      cfg,
+		client:   &http.Client{Timeout: 5 * time.Minute},
+		partSize: s3PartSize,
+		now:      time.Now,
+	}, nil
+}
+
+type initiateMultipartUploadResult struct {
+	UploadID string `xml:"UploadId"`
+}
+
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+type completeMultipartUpload struct {
+	XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+	Parts   []completedPart `xml:"Part"`
+}
+
+// Upload streams r to s3://bucket/key one part at a time, so memory use is
+// bounded by the part size. A failed upload is aborted.
+func (u *S3Uploader) Upload(ctx context.Context, bucket, key, contentType string, r io.Reader) error {
+	uploadID, err := u.createMultipartUpload(ctx, bucket, key, contentType)
+	if err != nil {
+		return err
+	}
+
+	if err := u.uploadParts(ctx, bucket, key, uploadID, r); err != nil {
+		// Abort with a fresh context so a cancelled run still cleans up
+		abortCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if abortErr := u.abortMultipartUpload(abortCtx, bucket, key, uploadID); abortErr != nil {
+			return fmt.Errorf("%w (abort also failed: %v)", err, abortErr)
+		}
+		return err
+	}
+	return nil
+}
+
+func (u *S3Uploader) uploadParts(ctx context.Context, bucket, key, uploadID string, r io.Reader) error {
+	var parts []completedPart
+	buf := make([]byte, u.partSize)
+
+	for partNumber := 1; ; partNumber++ {
+		n, readErr := io.ReadFull(r, buf)
+		if readErr != nil && readErr != io.EOF && readErr != io.ErrUnexpectedEOF {
+			return fmt.Errorf("failed to read export data: %w", readErr)
+		}
+		// Only an empty object has an empty part
+		if n == 0 && len(parts) > 0 {
+			break
+		}
+
+		etag, err := u.uploadPart(ctx, bucket, key, uploadID, partNumber, buf[:n])
+		if err != nil {
+			return err
+		}
+		parts = append(parts, completedPart{PartNumber: partNumber, ETag: etag})
+
+		if readErr != nil {
+			break
+		}
+	}
+
+	return u.completeMultipartUpload(ctx, bucket, key, uploadID, parts)
+}
+
+func (u *S3Uploader) createMultipartUpload(ctx context.Context, bucket, key, contentType string) (string, error) {
+	header := http.Header{}
+	header.Set("Content-Type", contentType)
+
+	body, err := u.do(ctx, http.MethodPost, bucket, key, url.Values{"uploads": {""}}, header, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to start S3 upload: %w", err)
+	}
+
+	var result initiateMultipartUploadResult
+	if err := xml.Unmarshal(body, &result); err != nil || result.UploadID == "" {
+		return "", fmt.Errorf("failed to start S3 upload: unexpected response %q", body)
+	}
+	return result.UploadID, nil
+}
+
+func (u *S3Uploader) uploadPart(ctx context.Context, bucket, key, uploadID string, partNumber int, data []byte) (string, error) {
+	query := url.Values{
+		"partNumber": {strconv.Itoa(partNumber)},
+		"uploadId":   {uploadID},
+	}
+
+	var etag string
+	_, err := u.doWithResponse(ctx, http.MethodPut, bucket, key, query, nil, data, func(resp *http.Response) {
+		etag = resp.Header.Get("ETag")
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to upload S3 part %d: %w", partNumber, err)
+	}
+	return etag, nil
+}
+
+func (u *S3Uploader) completeMultipartUpload(ctx context.Context, bucket, key, uploadID string, parts []completedPart) error {
+	payload, err := xml.Marshal(completeMultipartUpload{Parts: parts})
+	if err != nil {
+		return err
+	}
+
+	body, err := u.do(ctx, http.MethodPost, bucket, key, url.Values{"uploadId": {uploadID}}, nil, payload)
+	if err != nil {
+		return fmt.Errorf("failed to complete S3 upload: %w", err)
+	}
+	// S3 may report a failure in the body of a 200 response
+	if bytes.Contains(body, []byte("<Error>")) {
+		return fmt.Errorf("failed to complete S3 upload: %s", body)
+	}
+	return nil
+}
+
+func (u *S3Uploader) abortMultipartUpload(ctx context.Context, bucket, key, uploadID string) error {
+	_, err := u.do(ctx, http.MethodDelete, bucket, key, url.Values{"uploadId": {uploadID}}, nil, nil)
+	return err
+}
+
+func (u *S3Uploader) do(ctx context.Context, method, bucket, key string, query url.Values, header http.Header, payload []byte) ([]byte, error) {
+	return u.doWithResponse(ctx, method, bucket, key, query, header, payload, nil)
+}
+
+// doWithResponse sends a signed request and returns the body of a 2xx response
+func (u *S3Uploader) doWithResponse(ctx context.Context, method, bucket, key string, query url.Values, header http.Header,
+	payload []byte, inspect func(*http.Response)) ([]byte, error) {
+
+	req, err := http.NewRequestWithContext(ctx, method, u.objectURL(bucket, key, query), bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	u.sign(req, payload)
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("S3 returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if inspect != nil {
+		inspect(resp)
+	}
+	return body, nil
+}
+
+// objectURL uses virtual-hosted style on AWS and path style on custom endpoints
+func (u *S3Uploader) objectURL(bucket, key string, query url.Values) string {
+	var base string
+	if u.cfg.Endpoint != "" {
+		base = strings.TrimSuffix(u.cfg.Endpoint, "/") + "/" + bucket
+	} else {
+		base = fmt.Sprintf("https://%s.s3.%s.amazonaws.com", bucket, u.cfg.Region)
+	}
+
+	target := base + "/" + s3Escape(key, false)
+	if len(query) > 0 {
+		target += "?" + canonicalQuery(query)
+	}
+	return target
+}
+
+// sign adds an AWS Signature Version 4 Authorization header to req
+func (u *S3Uploader) sign(req *http.Request, payload []byte) {
+	now := u.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	payloadHash := sha256.Sum256(payload)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+	if u.cfg.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", u.cfg.SessionToken)
+	}
+
+	// Sign the host and every content or x-amz-* header
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") || lower == "content-type" || lower == "content-md5" || lower == "range" {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s3Escape(req.URL.Path, false),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := date + "/" + u.cfg.Region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+u.cfg.SecretAccessKey), date)
+	key = hmacSHA256(key, u.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		u.cfg.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery encodes query parameters sorted by key as SigV4 requires
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, s3Escape(k, true)+"="+s3Escape(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// s3Escape percent-encodes everything but unreserved characters; slashes are
+// kept unless escapeSlash is set
+func s3Escape(s string, escapeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !escapeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,562 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/csvfmt"
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/lock"
+	"go-data-gateway/internal/webhook"
+)
+
+var (
+	ErrExportNotFound = errors.New("export not found")
+	ErrExportRunning  = errors.New("export is already running")
+)
+
+// SourceResolver returns a tenant's instance of a named data source
+type SourceResolver func(tenantID, name string) (datasource.DataSource, bool)
+
+// RunStatus is the state of an export run
+type RunStatus string
+
+const (
+	RunRunning   RunStatus = "running"
+	RunSucceeded RunStatus = "succeeded"
+	RunFailed    RunStatus = "failed"
+)
+
+// LogEntry is a line of a run's log
+type LogEntry struct {
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+}
+
+// Run records one execution of an export
+type Run struct {
+	ID         string     `json:"id"`
+	Export     string     `json:"export"`
+	Trigger    string     `json:"trigger"`              // schedule or manual
+	LockToken  int64      `json:"lock_token,omitempty"` // Fencing token of a scheduled run's lock
+	Status     RunStatus  `json:"status"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Rows       int        `json:"rows"`
+	Bytes      int64      `json:"bytes"`
+	Checksum   string     `json:"checksum,omitempty"`
+	Object     string     `json:"object,omitempty"`
+	Manifest   string     `json:"manifest,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	Logs       []LogEntry `json:"logs,omitempty"`
+}
+
+// Manifest is uploaded next to each exported object
+type Manifest struct {
+	Export     string    `json:"export"`
+	RunID      string    `json:"run_id"`
+	Object     string    `json:"object"`
+	Format     Format    `json:"format"`
+	Rows       int       `json:"rows"`
+	Bytes      int64     `json:"bytes"`
+	Checksum   string    `json:"checksum"` // sha256:<hex> of the object
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	// FencingToken orders runs of the same export across replicas; zero for
+	// manual runs and runs without a lock
+	FencingToken int64 `json:"fencing_token,omitempty"`
+}
+
+// Status summarizes an export for the admin API
+type Status struct {
+	Name        string    `json:"name"`
+	Tenant      string    `json:"tenant"`
+	Source      string    `json:"source"`
+	Format      Format    `json:"format"`
+	Destination string    `json:"destination"`
+	Schedule    string    `json:"schedule"`
+	NextRun     time.Time `json:"next_run,omitempty"`
+	Running     bool      `json:"running"`
+	LastRun     *Run      `json:"last_run,omitempty"`
+}
+
+// job is a validated export definition
+type job struct {
+	cfg      config.ExportConfig
+	format   Format
+	dest     Destination
+	schedule *Schedule
+	filters  map[string]interface{}
+	csv      *csvfmt.Formatter // Locale formatting of CSV values; nil keeps the defaults
+}
+
+// Scheduler runs exports on their cron schedules or on demand and keeps a
+// bounded history of runs per export
+type Scheduler struct {
+	jobs       map[string]*job
+	resolve    SourceResolver
+	uploaders  map[string]Uploader // by destination scheme
+	alerts     *webhook.Client
+	runTimeout time.Duration
+	history    int
+	logger     *zap.Logger
+	now        func() time.Time
+	locks      *lock.Runner // Elects the replica running each scheduled run; nil runs them locally
+
+	mu      sync.Mutex
+	runs    map[string][]*Run // newest first
+	running map[string]bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewScheduler validates the export definitions. Exports are read from the
+// data source resolve returns for their tenant; defaultTenant is used for
+// exports without one.
+func NewScheduler(cfg config.ExportsConfig, defaultTenant string, resolve SourceResolver,
+	uploaders map[string]Uploader, logger *zap.Logger) (*Scheduler, error) {
+
+	s := &Scheduler{
+		jobs:       make(map[string]*job),
+		resolve:    resolve,
+		uploaders:  uploaders,
+		alerts:     webhook.New(cfg.AlertWebhook),
+		runTimeout: cfg.RunTimeout,
+		history:    cfg.HistorySize,
+		logger:     logger,
+		now:        time.Now,
+		runs:       make(map[string][]*Run),
+		running:    make(map[string]bool),
+	}
+	if s.history <= 0 {
+		s.history = 20
+	}
+	if s.runTimeout <= 0 {
+		s.runTimeout = time.Hour
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+
+	for _, ec := range cfg.Jobs {
+		j, err := newJob(ec, defaultTenant)
+		if err != nil {
+			return nil, fmt.Errorf("export %q: %w", ec.Name, err)
+		}
+		if _, dup := s.jobs[ec.Name]; dup {
+			return nil, fmt.Errorf("duplicate export %q", ec.Name)
+		}
+		if _, ok := uploaders[j.dest.Scheme]; !ok {
+			return nil, fmt.Errorf("export %q: no uploader configured for %s:// destinations", ec.Name, j.dest.Scheme)
+		}
+		s.jobs[ec.Name] = j
+	}
+
+	return s, nil
+}
+
+func newJob(ec config.ExportConfig, defaultTenant string) (*job, error) {
+	if (ec.Query == "") == (ec.Table == "") {
+		return nil, errors.New("exactly one of query or table is required")
+	}
+	if ec.ChunkSize <= 0 {
+		ec.ChunkSize = 10000
+	}
+	if ec.Tenant == "" {
+		ec.Tenant = defaultTenant
+	}
+
+	format, err := ParseFormat(ec.Format)
+	if err != nil {
+		return nil, err
+	}
+	dest, err := ParseDestination(ec.Destination)
+	if err != nil {
+		return nil, err
+	}
+
+	j := &job{cfg: ec, format: format, dest: dest}
+	if ec.Schedule != "" {
+		if j.schedule, err = ParseSchedule(ec.Schedule); err != nil {
+			return nil, err
+		}
+	}
+	if ec.Filters != "" {
+		if err := json.Unmarshal([]byte(ec.Filters), &j.filters); err != nil {
+			return nil, fmt.Errorf("filters must be a JSON object: %w", err)
+		}
+	}
+	opts := csvfmt.Options{
+		Locale:           ec.CSV.Locale,
+		DecimalSeparator: ec.CSV.DecimalSeparator,
+		DateFormat:       ec.CSV.DateFormat,
+		BOM:              ec.CSV.BOM,
+		Flatten:          ec.CSV.Flatten,
+	}
+	if !opts.IsZero() {
+		if format != FormatCSV {
+			return nil, errors.New("locale, decimal separator, date format, BOM and flatten apply to csv exports only")
+		}
+		columns := config.ActiveSecurityConfig().TableColumns[ec.Table]
+		if j.csv, err = csvfmt.New(opts, columns); err != nil {
+			return nil, err
+		}
+	}
+	return j, nil
+}
+
+// SetLockRunner makes scheduled runs take a distributed lock, so that one
+// replica runs each occurrence; call it before Start
+func (s *Scheduler) SetLockRunner(runner *lock.Runner) {
+	s.locks = runner
+}
+
+// Start runs scheduled exports at the start of each matching minute until Stop
+func (s *Scheduler) Start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			now := s.now()
+			next := now.Truncate(time.Minute).Add(time.Minute)
+			timer := time.NewTimer(next.Sub(now))
+			select {
+			case <-s.ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+				s.tick(next)
+			}
+		}
+	}()
+}
+
+// tick starts every export scheduled for the minute t on the replica that
+// acquires the export's lock for that minute; an export still running from a
+// previous tick is skipped
+func (s *Scheduler) tick(t time.Time) {
+	for name, j := range s.jobs {
+		if j.schedule == nil || !j.schedule.Matches(t) {
+			continue
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			lockName := "export:" + name + ":" + t.UTC().Format("200601021504")
+			ran, _ := s.locks.Run(s.ctx, lockName, func(ctx context.Context, token int64) error {
+				run, err := s.begin(name, "schedule", token)
+				if err != nil {
+					s.logger.Warn("Skipping scheduled export", zap.String("export", name), zap.Error(err))
+					return nil
+				}
+				s.execute(ctx, j, run)
+				return nil
+			})
+			if !ran {
+				s.logger.Debug("Scheduled export is run by another replica", zap.String("export", name))
+			}
+		}()
+	}
+}
+
+// Stop cancels running exports and waits for them to finish
+func (s *Scheduler) Stop() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+// Trigger starts a manual run and returns it without waiting for completion
+func (s *Scheduler) Trigger(name string) (Run, error) {
+	run, err := s.begin(name, "manual", 0)
+	if err != nil {
+		return Run{}, err
+	}
+
+	s.mu.Lock()
+	snapshot := run.copy()
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.execute(s.ctx, s.jobs[name], run)
+	}()
+
+	return snapshot, nil
+}
+
+// begin records a new running run of an export
+func (s *Scheduler) begin(name, trigger string, lockToken int64) (*Run, error) {
+	if _, ok := s.jobs[name]; !ok {
+		return nil, ErrExportNotFound
+	}
+
+	s.mu.Lock()
+	if s.running[name] {
+		s.mu.Unlock()
+		return nil, ErrExportRunning
+	}
+	startedAt := s.now().UTC()
+	run := &Run{
+		ID:        startedAt.Format("20060102T150405.000Z"),
+		Export:    name,
+		Trigger:   trigger,
+		LockToken: lockToken,
+		Status:    RunRunning,
+		StartedAt: startedAt,
+	}
+	s.running[name] = true
+	s.runs[name] = append([]*Run{run}, s.runs[name]...)
+	if len(s.runs[name]) > s.history {
+		s.runs[name] = s.runs[name][:s.history]
+	}
+	s.mu.Unlock()
+
+	return run, nil
+}
+
+// execute streams the export into the uploader, then uploads the manifest.
+// The run is cancelled with parent, such as when its lock is lost.
+func (s *Scheduler) execute(parent context.Context, j *job, run *Run) {
+	ctx, cancel := context.WithTimeout(parent, s.runTimeout)
+	defer cancel()
+
+	logger := s.logger.With(zap.String("export", run.Export), zap.String("run_id", run.ID))
+	logger.Info("Export started", zap.String("trigger", run.Trigger), zap.Int64("lock_token", run.LockToken))
+
+	manifest, err := s.export(ctx, j, run)
+
+	s.mu.Lock()
+	finishedAt := s.now().UTC()
+	run.FinishedAt = &finishedAt
+	if err != nil {
+		run.Status = RunFailed
+		run.Error = err.Error()
+		run.appendLog(finishedAt, "export failed: "+err.Error())
+	} else {
+		run.Status = RunSucceeded
+		run.appendLog(finishedAt, fmt.Sprintf("export finished: %d rows, %d bytes", manifest.Rows, manifest.Bytes))
+	}
+	s.running[run.Export] = false
+	snapshot := run.copy()
+	s.mu.Unlock()
+
+	if err != nil {
+		logger.Error("Export failed", zap.Error(err))
+		alertCtx, cancelAlert := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancelAlert()
+		if alertErr := s.alerts.Send(alertCtx, "export.failed", snapshot); alertErr != nil {
+			logger.Warn("Failed to send export failure alert", zap.Error(alertErr))
+		}
+		return
+	}
+
+	logger.Info("Export finished",
+		zap.Int("rows", snapshot.Rows),
+		zap.Int64("bytes", snapshot.Bytes),
+		zap.String("object", snapshot.Object))
+}
+
+func (s *Scheduler) export(ctx context.Context, j *job, run *Run) (*Manifest, error) {
+	source, ok := s.resolve(j.cfg.Tenant, j.cfg.Source)
+	if !ok {
+		return nil, fmt.Errorf("data source %s is not configured for tenant %s", j.cfg.Source, j.cfg.Tenant)
+	}
+	uploader := s.uploaders[j.dest.Scheme]
+
+	key := j.dest.ObjectKey(run.StartedAt)
+	s.logf(run, "writing %s to %s", j.format, j.dest.URL(key))
+
+	// The fetch loop writes into a pipe that the uploader drains, so the
+	// export is never held in memory beyond one chunk and one upload part
+	pr, pw := io.Pipe()
+	uploadDone := make(chan error, 1)
+	go func() {
+		err := uploader.Upload(ctx, j.dest.Bucket, key, j.format.ContentType(), pr)
+		pr.CloseWithError(err)
+		uploadDone <- err
+	}()
+
+	counter := &countingHash{hash: sha256.New()}
+	writer, err := NewRowWriter(j.format, io.MultiWriter(pw, counter), j.csv)
+	if err != nil {
+		pw.CloseWithError(err)
+		<-uploadDone
+		return nil, err
+	}
+
+	opts := &datasource.QueryOptions{OrderBy: j.cfg.OrderBy, Filters: j.filters}
+	rows, err := datasource.FetchChunks(ctx, source, j.cfg.Query, j.cfg.Table, j.cfg.ChunkSize, opts,
+		func(chunk []map[string]interface{}) error {
+			if err := writer.WriteRows(chunk); err != nil {
+				return err
+			}
+			s.progress(run, len(chunk), counter.n)
+			return nil
+		})
+	if err == nil {
+		err = writer.Close()
+	}
+	pw.CloseWithError(err)
+
+	uploadErr := <-uploadDone
+	if err != nil {
+		return nil, err
+	}
+	if uploadErr != nil {
+		return nil, uploadErr
+	}
+
+	manifest := &Manifest{
+		Export:       run.Export,
+		RunID:        run.ID,
+		Object:       j.dest.URL(key),
+		Format:       j.format,
+		Rows:         rows,
+		Bytes:        counter.n,
+		Checksum:     "sha256:" + hex.EncodeToString(counter.hash.Sum(nil)),
+		StartedAt:    run.StartedAt,
+		FinishedAt:   s.now().UTC(),
+		FencingToken: run.LockToken,
+	}
+	manifestKey := key + ".manifest.json"
+	encoded, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := uploader.Upload(ctx, j.dest.Bucket, manifestKey, "application/json", bytes.NewReader(encoded)); err != nil {
+		return nil, fmt.Errorf("failed to upload manifest: %w", err)
+	}
+
+	s.mu.Lock()
+	run.Checksum = manifest.Checksum
+	run.Object = manifest.Object
+	run.Manifest = j.dest.URL(manifestKey)
+	s.mu.Unlock()
+
+	return manifest, nil
+}
+
+// progress records a written chunk on the run
+func (s *Scheduler) progress(run *Run, chunkRows int, bytes int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	run.Rows += chunkRows
+	run.Bytes = bytes
+	run.appendLog(s.now().UTC(), fmt.Sprintf("wrote %d rows (%d total)", chunkRows, run.Rows))
+}
+
+func (s *Scheduler) logf(run *Run, format string, args ...interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	run.appendLog(s.now().UTC(), fmt.Sprintf(format, args...))
+}
+
+// maxRunLogs bounds the log kept per run; the newest entries are kept
+const maxRunLogs = 200
+
+func (r *Run) appendLog(t time.Time, message string) {
+	r.Logs = append(r.Logs, LogEntry{Time: t, Message: message})
+	if len(r.Logs) > maxRunLogs {
+		r.Logs = r.Logs[len(r.Logs)-maxRunLogs:]
+	}
+}
+
+// copy returns a snapshot safe to use outside the scheduler lock
+func (r *Run) copy() Run {
+	c := *r
+	c.Logs = append([]LogEntry(nil), r.Logs...)
+	if r.FinishedAt != nil {
+		finishedAt := *r.FinishedAt
+		c.FinishedAt = &finishedAt
+	}
+	return c
+}
+
+// Status returns every export sorted by name with its latest run
+func (s *Scheduler) Status() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	statuses := make([]Status, 0, len(s.jobs))
+	for name, j := range s.jobs {
+		status := Status{
+			Name:        name,
+			Tenant:      j.cfg.Tenant,
+			Source:      j.cfg.Source,
+			Format:      j.format,
+			Destination: j.cfg.Destination,
+			Running:     s.running[name],
+		}
+		if j.schedule != nil {
+			status.Schedule = j.schedule.String()
+			status.NextRun = j.schedule.Next(now)
+		}
+		if runs := s.runs[name]; len(runs) > 0 {
+			last := runs[0].copy()
+			last.Logs = nil
+			status.LastRun = &last
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, k int) bool { return statuses[i].Name < statuses[k].Name })
+	return statuses
+}
+
+// Runs returns an export's run history, newest first, without logs
+func (s *Scheduler) Runs(name string) ([]Run, error) {
+	if _, ok := s.jobs[name]; !ok {
+		return nil, ErrExportNotFound
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	runs := make([]Run, 0, len(s.runs[name]))
+	for _, run := range s.runs[name] {
+		c := run.copy()
+		c.Logs = nil
+		runs = append(runs, c)
+	}
+	return runs, nil
+}
+
+// Run returns a single run including its log
+func (s *Scheduler) Run(name, runID string) (Run, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, run := range s.runs[name] {
+		if run.ID == runID {
+			return run.copy(), true
+		}
+	}
+	return Run{}, false
+}
+
+// countingHash hashes and counts the bytes written to an export
+type countingHash struct {
+	hash hash.Hash
+	n    int64
+}
+
+func (c *countingHash) Write(p []byte) (int, error) {
+	c.hash.Write(p)
+	c.n += int64(len(p))
+	return len(p), nil
+}
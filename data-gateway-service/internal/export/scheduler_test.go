@@ -0,0 +1,291 @@
+package export
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/lock"
+	"go-data-gateway/internal/webhook"
+)
+
+// pagedSource serves rows page by page and fails when err is set
+type pagedSource struct {
+	rows []map[string]interface{}
+	err  error
+}
+
+func (s *pagedSource) ExecuteQuery(ctx context.Context, query string, opts *datasource.QueryOptions) (*datasource.QueryResult, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	start := min(opts.Offset, len(s.rows))
+	end := min(opts.Offset+opts.Limit, len(s.rows))
+	page := s.rows[start:end]
+	return &datasource.QueryResult{Data: page, Count: len(page), Source: datasource.DataSourceDremio}, nil
+}
+
+func (s *pagedSource) GetData(ctx context.Context, table string, opts *datasource.QueryOptions) (*datasource.QueryResult, error) {
+	return s.ExecuteQuery(ctx, table, opts)
+}
+
+func (s *pagedSource) TestConnection(ctx context.Context) error { return nil }
+
+func (s *pagedSource) GetType() datasource.DataSourceType { return datasource.DataSourceDremio }
+
+func (s *pagedSource) Close() error { return nil }
+
+// memoryUploader keeps uploaded objects by bucket/key
+type memoryUploader struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (u *memoryUploader) Upload(ctx context.Context, bucket, key, contentType string, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.objects[bucket+"/"+key] = data
+	return nil
+}
+
+func newTestScheduler(t *testing.T, source datasource.DataSource, uploader Uploader, alertURL string, jobs ...config.ExportConfig) *Scheduler {
+	cfg := config.ExportsConfig{Jobs: jobs, AlertWebhook: alertURL}
+	resolve := func(tenantID, name string) (datasource.DataSource, bool) {
+		return source, tenantID == "default" && name == "DATAWAREHOUSE"
+	}
+	s, err := NewScheduler(cfg, "default", resolve, map[string]Uploader{"gs": uploader}, zap.NewNop())
+	require.NoError(t, err)
+	s.now = func() time.Time { return time.Date(2025, 3, 4, 2, 30, 0, 0, time.UTC) }
+	t.Cleanup(s.Stop)
+	return s
+}
+
+// waitForRun polls until the run leaves the running state
+func waitForRun(t *testing.T, s *Scheduler, name, runID string) Run {
+	var run Run
+	require.Eventually(t, func() bool {
+		var ok bool
+		run, ok = s.Run(name, runID)
+		return ok && run.Status != RunRunning
+	}, 5*time.Second, 10*time.Millisecond)
+	return run
+}
+
+func TestScheduler_ExportWritesObjectAndManifest(t *testing.T) {
+	source := &pagedSource{}
+	for i := 0; i < 5; i++ {
+		source.rows = append(source.rows, map[string]interface{}{"id": i, "name": "tender"})
+	}
+	uploader := &memoryUploader{objects: make(map[string][]byte)}
+	s := newTestScheduler(t, source, uploader, "", config.ExportConfig{
+		Name:        "tenders",
+		Source:      "DATAWAREHOUSE",
+		Table:       "tender_data",
+		Format:      "ndjson",
+		Destination: "gs://exports/tenders/{date}.ndjson",
+		ChunkSize:   2,
+	})
+
+	started, err := s.Trigger("tenders")
+	require.NoError(t, err)
+	assert.Equal(t, "manual", started.Trigger)
+
+	run := waitForRun(t, s, "tenders", started.ID)
+	require.Equal(t, RunSucceeded, run.Status, run.Error)
+	assert.Equal(t, 5, run.Rows)
+	assert.Equal(t, "gs://exports/tenders/2025-03-04.ndjson", run.Object)
+	assert.NotEmpty(t, run.Logs)
+
+	object := uploader.objects["exports/tenders/2025-03-04.ndjson"]
+	sum := sha256.Sum256(object)
+	assert.Equal(t, "sha256:"+hex.EncodeToString(sum[:]), run.Checksum)
+	assert.Equal(t, int64(len(object)), run.Bytes)
+
+	var manifest Manifest
+	require.NoError(t, json.Unmarshal(uploader.objects["exports/tenders/2025-03-04.ndjson.manifest.json"], &manifest))
+	assert.Equal(t, 5, manifest.Rows)
+	assert.Equal(t, run.Checksum, manifest.Checksum)
+	assert.Equal(t, FormatNDJSON, manifest.Format)
+
+	runs, err := s.Runs("tenders")
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Nil(t, runs[0].Logs)
+}
+
+func TestScheduler_FailureSendsAlert(t *testing.T) {
+	alerts := make(chan webhook.Event, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event webhook.Event
+		json.NewDecoder(r.Body).Decode(&event)
+		alerts <- event
+	}))
+	defer hook.Close()
+
+	source := &pagedSource{err: errors.New("upstream unavailable")}
+	uploader := &memoryUploader{objects: make(map[string][]byte)}
+	s := newTestScheduler(t, source, uploader, hook.URL, config.ExportConfig{
+		Name:        "tenders",
+		Source:      "DATAWAREHOUSE",
+		Query:       "SELECT * FROM tender_data",
+		Format:      "csv",
+		Destination: "gs://exports/tenders.csv",
+	})
+
+	started, err := s.Trigger("tenders")
+	require.NoError(t, err)
+
+	run := waitForRun(t, s, "tenders", started.ID)
+	assert.Equal(t, RunFailed, run.Status)
+	assert.Contains(t, run.Error, "upstream unavailable")
+	assert.Empty(t, uploader.objects)
+
+	select {
+	case event := <-alerts:
+		assert.Equal(t, "export.failed", event.Type)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no failure alert received")
+	}
+}
+
+// blockingUploader holds uploads open until released
+type blockingUploader struct {
+	release chan struct{}
+}
+
+func (u *blockingUploader) Upload(ctx context.Context, bucket, key, contentType string, r io.Reader) error {
+	io.Copy(io.Discard, r)
+	select {
+	case <-u.release:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestScheduler_RejectsOverlappingRuns(t *testing.T) {
+	uploader := &blockingUploader{release: make(chan struct{})}
+	s := newTestScheduler(t, &pagedSource{}, uploader, "", config.ExportConfig{
+		Name:        "tenders",
+		Source:      "DATAWAREHOUSE",
+		Table:       "tender_data",
+		Format:      "csv",
+		Destination: "gs://exports/tenders.csv",
+		Schedule:    "30 2 * * *",
+	})
+
+	_, err := s.Trigger("tenders")
+	require.NoError(t, err)
+
+	_, err = s.Trigger("tenders")
+	assert.ErrorIs(t, err, ErrExportRunning)
+
+	status := s.Status()
+	require.Len(t, status, 1)
+	assert.True(t, status[0].Running)
+	assert.Equal(t, time.Date(2025, 3, 5, 2, 30, 0, 0, time.UTC), status[0].NextRun)
+
+	close(uploader.release)
+
+	_, err = s.Trigger("missing")
+	assert.ErrorIs(t, err, ErrExportNotFound)
+}
+
+func TestScheduler_ScheduledRunOnOneReplica(t *testing.T) {
+	mr := miniredis.RunT(t)
+	job := config.ExportConfig{
+		Name:        "tenders",
+		Source:      "DATAWAREHOUSE",
+		Table:       "tender_data",
+		Format:      "ndjson",
+		Destination: "gs://exports/tenders/{date}.ndjson",
+		Schedule:    "30 2 * * *",
+	}
+	uploader := &memoryUploader{objects: make(map[string][]byte)}
+
+	replicas := make([]*Scheduler, 0, 2)
+	for _, owner := range []string{"replica-a", "replica-b"} {
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		t.Cleanup(func() { client.Close() })
+		s := newTestScheduler(t, &pagedSource{}, uploader, "", job)
+		s.SetLockRunner(lock.NewRunner(lock.NewRedisLocker(client, owner), time.Minute, zap.NewNop()))
+		replicas = append(replicas, s)
+	}
+
+	minute := time.Date(2025, 3, 4, 2, 30, 0, 0, time.UTC)
+	for _, s := range replicas {
+		s.tick(minute)
+	}
+
+	var runs []Run
+	require.Eventually(t, func() bool {
+		runs = nil
+		for _, s := range replicas {
+			for _, status := range s.Status() {
+				if status.LastRun != nil && status.LastRun.Status != RunRunning {
+					runs = append(runs, *status.LastRun)
+				}
+			}
+		}
+		return len(runs) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The other replica found the lock taken and never started a run
+	for _, s := range replicas {
+		s.wg.Wait()
+	}
+	total := 0
+	for _, s := range replicas {
+		history, err := s.Runs("tenders")
+		require.NoError(t, err)
+		total += len(history)
+	}
+	assert.Equal(t, 1, total)
+
+	run := runs[0]
+	require.Equal(t, RunSucceeded, run.Status, run.Error)
+	assert.Equal(t, "schedule", run.Trigger)
+	assert.Positive(t, run.LockToken)
+
+	var manifest Manifest
+	require.NoError(t, json.Unmarshal(uploader.objects["exports/tenders/2025-03-04.ndjson.manifest.json"], &manifest))
+	assert.Equal(t, run.LockToken, manifest.FencingToken)
+}
+
+func TestNewScheduler_ValidatesExports(t *testing.T) {
+	resolve := func(tenantID, name string) (datasource.DataSource, bool) { return nil, false }
+	uploaders := map[string]Uploader{"gs": &memoryUploader{}}
+
+	invalid := []config.ExportConfig{
+		{Name: "both", Query: "SELECT 1", Table: "t", Format: "csv", Destination: "gs://b/k"},
+		{Name: "format", Table: "t", Format: "xlsx", Destination: "gs://b/k"},
+		{Name: "scheme", Table: "t", Format: "csv", Destination: "ftp://b/k"},
+		{Name: "uploader", Table: "t", Format: "csv", Destination: "s3://b/k"},
+		{Name: "cron", Table: "t", Format: "csv", Destination: "gs://b/k", Schedule: "every day"},
+		{Name: "filters", Table: "t", Format: "csv", Destination: "gs://b/k", Filters: "[1]"},
+	}
+	for _, job := range invalid {
+		_, err := NewScheduler(config.ExportsConfig{Jobs: []config.ExportConfig{job}}, "default", resolve, uploaders, zap.NewNop())
+		assert.Error(t, err, job.Name)
+	}
+}
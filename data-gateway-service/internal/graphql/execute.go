@@ -0,0 +1,893 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Request is the body of a GraphQL request
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// Response is the result of a request. Data is absent when the request
+// could not be executed at all, and null when a non-null root field failed.
+type Response struct {
+	Data   *Object  `json:"data,omitempty"`
+	Errors []*Error `json:"errors,omitempty"`
+
+	executed bool
+}
+
+// MarshalJSON writes "data": null for an executed request whose data was
+// nulled, and no data for one that was not executed
+func (r *Response) MarshalJSON() ([]byte, error) {
+	type response Response
+	if r.executed && r.Data == nil {
+		return json.Marshal(struct {
+			Data   *Object  `json:"data"`
+			Errors []*Error `json:"errors,omitempty"`
+		}{nil, r.Errors})
+	}
+	return json.Marshal((*response)(r))
+}
+
+// Executed reports whether the request got past parsing and validation
+func (r *Response) Executed() bool {
+	return r.executed
+}
+
+// Error is an error of a request, located in its document and, for errors
+// of a field, with the path of the field in the response
+type Error struct {
+	Message   string        `json:"message"`
+	Locations []Pos         `json:"locations,omitempty"`
+	Path      []interface{} `json:"path,omitempty"`
+
+	err error // The resolver error, for Unwrap
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// Unwrap returns the error a resolver returned
+func (e *Error) Unwrap() error { return e.err }
+
+// Object is a response object; its fields keep the order of the selection
+type Object struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newObject(size int) *Object {
+	return &Object{keys: make([]string, 0, size), values: make(map[string]interface{}, size)}
+}
+
+func (o *Object) set(key string, value interface{}) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+// Get returns the value of a field of the object
+func (o *Object) Get(key string) interface{} {
+	return o.values[key]
+}
+
+// MarshalJSON writes the fields in selection order
+func (o *Object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(key)
+		buf.Write(k)
+		buf.WriteByte(':')
+		v, err := json.Marshal(o.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Options bound the documents a schema executes
+type Options struct {
+	MaxDepth int // Most nested selection sets; 0 is unbounded
+}
+
+// Execute runs the operation of req on schema. Parse and validation errors
+// are returned without data; errors of fields null the field and are added
+// to the response.
+func Execute(ctx context.Context, schema *Schema, req Request, opts Options) *Response {
+	doc, err := Parse(req.Query)
+	if err != nil {
+		var syntaxErr *SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return &Response{Errors: []*Error{{Message: "Syntax error: " + syntaxErr.Message, Locations: []Pos{syntaxErr.Pos}}}}
+		}
+		return &Response{Errors: []*Error{{Message: err.Error()}}}
+	}
+
+	op, err := operation(doc, req.OperationName)
+	if err != nil {
+		return &Response{Errors: []*Error{{Message: err.Error()}}}
+	}
+	if op.Type != "query" {
+		return &Response{Errors: []*Error{{Message: fmt.Sprintf("%s operations are not supported; only queries are", op.Type)}}}
+	}
+
+	e := &executor{schema: schema, doc: doc}
+	if errs := e.validate(op, opts); len(errs) > 0 {
+		return &Response{Errors: errs}
+	}
+	vars, errs := e.variables(op, req.Variables)
+	if len(errs) > 0 {
+		return &Response{Errors: errs}
+	}
+	e.vars = vars
+
+	data, _ := e.selectionSet(ctx, schema.Query, nil, op.Selections, nil)
+	return &Response{Data: data, Errors: e.errors, executed: true}
+}
+
+// operation picks the operation of doc to run
+func operation(doc *Document, name string) (*Operation, error) {
+	if name == "" {
+		if len(doc.Operations) > 1 {
+			return nil, errors.New("operationName is required for a document with several operations")
+		}
+		return doc.Operations[0], nil
+	}
+	for _, op := range doc.Operations {
+		if op.Name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation %q", name)
+}
+
+type executor struct {
+	schema *Schema
+	doc    *Document
+	vars   map[string]interface{}
+	errors []*Error
+}
+
+// fieldDef returns the definition of field name of t, the introspection
+// fields of the query type included
+func (e *executor) fieldDef(t *Type, name string) *Field {
+	if t == e.schema.Query {
+		if f := rootIntrospection(e.schema, name); f != nil {
+			return f
+		}
+	}
+	return t.Field(name)
+}
+
+// collected is a response key and the selections of the fields merged into
+// it
+type collected struct {
+	key    string
+	fields []*FieldSelection
+}
+
+// collect merges the field selections of selections on t by response key,
+// in order, following fragments whose type condition is t. Fields skipped by
+// @skip or @include are left out.
+func (e *executor) collect(t *Type, selections []Selection, visited map[string]bool, into []*collected) []*collected {
+	for _, selection := range selections {
+		switch s := selection.(type) {
+		case *FieldSelection:
+			if !e.included(s.Directives) {
+				continue
+			}
+			key := s.ResponseKey()
+			merged := false
+			for _, c := range into {
+				if c.key == key {
+					c.fields = append(c.fields, s)
+					merged = true
+					break
+				}
+			}
+			if !merged {
+				into = append(into, &collected{key: key, fields: []*FieldSelection{s}})
+			}
+		case *FragmentSpread:
+			if visited[s.Name] || !e.included(s.Directives) {
+				continue
+			}
+			visited[s.Name] = true
+			fragment := e.doc.Fragments[s.Name]
+			if fragment == nil || fragment.TypeCondition != t.Name {
+				continue
+			}
+			into = e.collect(t, fragment.Selections, visited, into)
+		case *InlineFragment:
+			if !e.included(s.Directives) || (s.TypeCondition != "" && s.TypeCondition != t.Name) {
+				continue
+			}
+			into = e.collect(t, s.Selections, visited, into)
+		}
+	}
+	return into
+}
+
+// included applies @skip and @include
+func (e *executor) included(directives []*Directive) bool {
+	for _, d := range directives {
+		if d.Name != "skip" && d.Name != "include" {
+			continue
+		}
+		var cond bool
+		for _, arg := range d.Arguments {
+			if arg.Name == "if" {
+				v, _ := e.coerce(NonNull(Boolean), arg.Value, false)
+				cond, _ = v.(bool)
+			}
+		}
+		if cond == (d.Name == "skip") {
+			return false
+		}
+	}
+	return true
+}
+
+// subSelections returns the selections of fields, merged
+func subSelections(fields []*FieldSelection) []Selection {
+	if len(fields) == 1 {
+		return fields[0].Selections
+	}
+	var selections []Selection
+	for _, f := range fields {
+		selections = append(selections, f.Selections...)
+	}
+	return selections
+}
+
+// selectionSet executes selections on source, an object of type t. ok is
+// false when a non-null field failed, which nulls the object.
+func (e *executor) selectionSet(ctx context.Context, t *Type, source interface{}, selections []Selection, path []interface{}) (*Object, bool) {
+	fields := e.collect(t, selections, map[string]bool{}, nil)
+	obj := newObject(len(fields))
+	for _, c := range fields {
+		if err := ctx.Err(); err != nil {
+			e.fail(c.fields[0], append(path, c.key), err)
+			return nil, false
+		}
+		name := c.fields[0].Name
+		if name == "__typename" {
+			obj.set(c.key, t.Name)
+			continue
+		}
+		def := e.fieldDef(t, name)
+		value, ok := e.field(ctx, def, source, c.fields, append(append([]interface{}{}, path...), c.key))
+		if !ok {
+			return nil, false
+		}
+		obj.set(c.key, value)
+	}
+	return obj, true
+}
+
+// field resolves and completes one field of source
+func (e *executor) field(ctx context.Context, def *Field, source interface{}, fields []*FieldSelection, path []interface{}) (interface{}, bool) {
+	args, err := e.arguments(def.Args, fields[0].Arguments)
+	if err != nil {
+		e.fail(fields[0], path, err)
+		return nil, def.Type.Kind != KindNonNull
+	}
+
+	params := ResolveParams{Source: source, Args: args}
+	if named := def.Type.named(); named.Kind == KindObject {
+		params.Selections = e.selected(named, subSelections(fields))
+	}
+
+	var value interface{}
+	if def.Resolve != nil {
+		value, err = def.Resolve(ctx, params)
+	} else if row, ok := source.(map[string]interface{}); ok {
+		value = row[def.Name]
+	}
+	if err != nil {
+		e.fail(fields[0], path, err)
+		return nil, def.Type.Kind != KindNonNull
+	}
+	return e.complete(ctx, def.Type, fields, value, path)
+}
+
+// selected lists the fields selected on an object of type t for resolvers
+func (e *executor) selected(t *Type, selections []Selection) []*SelectedField {
+	var list []*SelectedField
+	for _, c := range e.collect(t, selections, map[string]bool{}, nil) {
+		f := c.fields[0]
+		def := e.fieldDef(t, f.Name)
+		if def == nil {
+			continue
+		}
+		s := &SelectedField{Name: f.Name}
+		s.Args, _ = e.arguments(def.Args, f.Arguments)
+		if named := def.Type.named(); named.Kind == KindObject {
+			s.Selections = e.selected(named, subSelections(c.fields))
+		}
+		list = append(list, s)
+	}
+	return list
+}
+
+// complete converts a resolved value to the response value of type t
+func (e *executor) complete(ctx context.Context, t *Type, fields []*FieldSelection, value interface{}, path []interface{}) (interface{}, bool) {
+	if t.Kind == KindNonNull {
+		completed, ok := e.complete(ctx, t.OfType, fields, value, path)
+		if !ok {
+			return nil, false
+		}
+		if completed == nil {
+			if isNil(value) {
+				e.fail(fields[0], path, fmt.Errorf("cannot return null for non-null field %s", fields[0].Name))
+			}
+			return nil, false
+		}
+		return completed, true
+	}
+	if isNil(value) {
+		return nil, true
+	}
+
+	switch t.Kind {
+	case KindList:
+		items := reflect.ValueOf(value)
+		if items.Kind() != reflect.Slice && items.Kind() != reflect.Array {
+			e.fail(fields[0], path, fmt.Errorf("expected a list for %s, got %T", fields[0].Name, value))
+			return nil, true
+		}
+		list := make([]interface{}, items.Len())
+		for i := range list {
+			item, ok := e.complete(ctx, t.OfType, fields, items.Index(i).Interface(), append(append([]interface{}{}, path...), i))
+			if !ok {
+				return nil, true // A null non-null item nulls the list
+			}
+			list[i] = item
+		}
+		return list, true
+
+	case KindScalar:
+		if t.Serialize == nil {
+			return value, true
+		}
+		serialized, err := t.Serialize(value)
+		if err != nil {
+			e.fail(fields[0], path, err)
+			return nil, true
+		}
+		return serialized, true
+
+	case KindEnum:
+		name := fmt.Sprint(value)
+		for _, v := range t.EnumValues {
+			if v.Name == name {
+				return name, true
+			}
+		}
+		e.fail(fields[0], path, fmt.Errorf("%q is not a value of %s", name, t.Name))
+		return nil, true
+
+	case KindObject:
+		obj, ok := e.selectionSet(ctx, t, value, subSelections(fields), path)
+		if !ok {
+			return nil, true
+		}
+		return obj, true
+	}
+	e.fail(fields[0], path, fmt.Errorf("cannot return a value of %s", t))
+	return nil, true
+}
+
+// isNil reports whether a resolved value is null, including typed nils
+func isNil(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	switch v := reflect.ValueOf(value); v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		return v.IsNil()
+	}
+	return false
+}
+
+func (e *executor) fail(field *FieldSelection, path []interface{}, err error) {
+	e.errors = append(e.errors, &Error{
+		Message:   err.Error(),
+		Locations: []Pos{field.Pos},
+		Path:      append([]interface{}{}, path...),
+		err:       err,
+	})
+}
+
+// arguments coerces the arguments of a field to its definitions
+func (e *executor) arguments(defs []*InputValue, args []*Argument) (map[string]interface{}, error) {
+	values := make(map[string]interface{}, len(defs))
+	for _, def := range defs {
+		var arg *Argument
+		for _, a := range args {
+			if a.Name == def.Name {
+				arg = a
+				break
+			}
+		}
+		if arg != nil {
+			if name, ok := arg.Value.(Variable); ok {
+				if _, set := e.vars[string(name)]; !set {
+					arg = nil
+				}
+			}
+		}
+		if arg == nil {
+			if def.Default != nil {
+				v, err := e.coerce(def.Type, def.Default, false)
+				if err != nil {
+					return nil, fmt.Errorf("argument %s: %w", def.Name, err)
+				}
+				values[def.Name] = v
+			} else if def.Type.Kind == KindNonNull {
+				return nil, fmt.Errorf("argument %s of type %s is required", def.Name, def.Type)
+			}
+			continue
+		}
+		v, err := e.coerce(def.Type, arg.Value, false)
+		if err != nil {
+			return nil, fmt.Errorf("argument %s: %w", def.Name, err)
+		}
+		values[def.Name] = v
+	}
+	return values, nil
+}
+
+// coerce converts a literal, or with variable a JSON value of a variable, to
+// the Go value of input type t: nil, a scalar's parsed value, an enum
+// value's name, []interface{} or map[string]interface{}
+func (e *executor) coerce(t *Type, v interface{}, variable bool) (interface{}, error) {
+	if name, ok := v.(Variable); ok && !variable {
+		value, set := e.vars[string(name)]
+		if !set || value == nil {
+			if t.Kind == KindNonNull {
+				return nil, fmt.Errorf("variable $%s of a non-null argument is not set", name)
+			}
+			return nil, nil
+		}
+		return value, nil // Coerced when variables were read
+	}
+
+	if _, null := v.(Null); null || v == nil {
+		if t.Kind == KindNonNull {
+			return nil, fmt.Errorf("expected a non-null %s", t.OfType)
+		}
+		return nil, nil
+	}
+
+	switch t.Kind {
+	case KindNonNull:
+		return e.coerce(t.OfType, v, variable)
+
+	case KindList:
+		var items []interface{}
+		switch list := v.(type) {
+		case []Value:
+			items = list
+		default:
+			item, err := e.coerce(t.OfType, v, variable)
+			if err != nil {
+				return nil, err
+			}
+			return []interface{}{item}, nil
+		}
+		coerced := make([]interface{}, len(items))
+		for i, item := range items {
+			c, err := e.coerce(t.OfType, item, variable)
+			if err != nil {
+				return nil, fmt.Errorf("item %d: %w", i, err)
+			}
+			coerced[i] = c
+		}
+		return coerced, nil
+
+	case KindInputObject:
+		fields := map[string]interface{}{}
+		switch object := v.(type) {
+		case []*ObjectField:
+			for _, f := range object {
+				if _, dup := fields[f.Name]; dup {
+					return nil, fmt.Errorf("field %s is set more than once", f.Name)
+				}
+				fields[f.Name] = f.Value
+			}
+		case map[string]interface{}:
+			fields = object
+		default:
+			return nil, fmt.Errorf("expected a %s object, got %s", t.Name, describe(v))
+		}
+		coerced := make(map[string]interface{}, len(fields))
+		for name := range fields {
+			if inputField(t, name) == nil {
+				return nil, fmt.Errorf("%s has no field %s", t.Name, name)
+			}
+		}
+		for _, def := range t.InputFields {
+			raw, set := fields[def.Name]
+			if name, ok := raw.(Variable); ok && !variable {
+				if _, defined := e.vars[string(name)]; !defined {
+					set = false
+				}
+			}
+			if !set {
+				if def.Default != nil {
+					c, err := e.coerce(def.Type, def.Default, false)
+					if err != nil {
+						return nil, err
+					}
+					coerced[def.Name] = c
+				} else if def.Type.Kind == KindNonNull {
+					return nil, fmt.Errorf("field %s of %s is required", def.Name, t.Name)
+				}
+				continue
+			}
+			c, err := e.coerce(def.Type, raw, variable)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", t.Name, def.Name, err)
+			}
+			coerced[def.Name] = c
+		}
+		return coerced, nil
+
+	case KindEnum:
+		var name string
+		switch value := v.(type) {
+		case EnumValue:
+			name = string(value)
+		case string:
+			if !variable {
+				return nil, fmt.Errorf("expected a %s value, got the string %q", t.Name, value)
+			}
+			name = value
+		default:
+			return nil, fmt.Errorf("expected a %s value, got %s", t.Name, describe(v))
+		}
+		for _, value := range t.EnumValues {
+			if value.Name == name {
+				return name, nil
+			}
+		}
+		return nil, fmt.Errorf("%s is not a value of %s", name, t.Name)
+
+	case KindScalar:
+		if t.Parse == nil {
+			return v, nil
+		}
+		return t.Parse(v)
+	}
+	return nil, fmt.Errorf("%s is not an input type", t)
+}
+
+func inputField(t *Type, name string) *InputValue {
+	for _, f := range t.InputFields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// variables coerces the variables of a request to the types op declares
+func (e *executor) variables(op *Operation, values map[string]interface{}) (map[string]interface{}, []*Error) {
+	vars := map[string]interface{}{}
+	var errs []*Error
+	for _, def := range op.Variables {
+		t := e.typeOf(def.Type)
+		raw, set := values[def.Name]
+		switch {
+		case !set && def.Default != nil:
+			v, err := e.coerce(t, def.Default, false)
+			if err != nil {
+				errs = append(errs, &Error{Message: fmt.Sprintf("variable $%s: %v", def.Name, err)})
+				continue
+			}
+			vars[def.Name] = v
+		case !set && t.Kind == KindNonNull:
+			errs = append(errs, &Error{Message: fmt.Sprintf("variable $%s of type %s is required", def.Name, def.Type)})
+		case set:
+			v, err := e.coerce(t, raw, true)
+			if err != nil {
+				errs = append(errs, &Error{Message: fmt.Sprintf("variable $%s: %v", def.Name, err)})
+				continue
+			}
+			vars[def.Name] = v
+		}
+	}
+	return vars, errs
+}
+
+// typeOf returns the schema type of a type reference; validate checked its
+// name
+func (e *executor) typeOf(ref TypeRef) *Type {
+	var t *Type
+	if ref.Elem != nil {
+		t = ListOf(e.typeOf(*ref.Elem))
+	} else {
+		t = e.schema.Type(ref.Name)
+	}
+	if ref.NonNull {
+		t = NonNull(t)
+	}
+	return t
+}
+
+// validate checks op against the schema before it runs: fields, arguments,
+// fragments, variable types and depth
+func (e *executor) validate(op *Operation, opts Options) []*Error {
+	v := &validator{executor: e, opts: opts, declared: map[string]bool{}}
+	for _, def := range op.Variables {
+		if v.declared[def.Name] {
+			v.errorf(Pos{}, "variable $%s is declared more than once", def.Name)
+		}
+		v.declared[def.Name] = true
+		if !v.knownType(def.Type) {
+			v.errorf(Pos{}, "variable $%s has unknown type %s", def.Name, def.Type)
+		} else if !e.typeOf(def.Type).isInput() {
+			v.errorf(Pos{}, "variable $%s has type %s, which is not an input type", def.Name, def.Type)
+		}
+	}
+	v.selections(e.schema.Query, op.Selections, 1, map[string]bool{})
+	for name := range e.doc.Fragments {
+		if !v.used[name] {
+			v.errorf(Pos{}, "fragment %s is never used", name)
+		}
+	}
+	sort.SliceStable(v.errs, func(i, j int) bool {
+		a, b := locationOf(v.errs[i]), locationOf(v.errs[j])
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+	return v.errs
+}
+
+func locationOf(err *Error) Pos {
+	if len(err.Locations) == 0 {
+		return Pos{}
+	}
+	return err.Locations[0]
+}
+
+type validator struct {
+	*executor
+	opts     Options
+	declared map[string]bool
+	used     map[string]bool
+	errs     []*Error
+	tooDeep  bool
+}
+
+func (v *validator) errorf(pos Pos, format string, args ...interface{}) {
+	err := &Error{Message: fmt.Sprintf(format, args...)}
+	if pos.Line > 0 {
+		err.Locations = []Pos{pos}
+	}
+	v.errs = append(v.errs, err)
+}
+
+func (v *validator) knownType(ref TypeRef) bool {
+	if ref.Elem != nil {
+		return v.knownType(*ref.Elem)
+	}
+	return v.schema.Type(ref.Name) != nil
+}
+
+// selections validates selections on t at depth; spreading marks the
+// fragments being expanded, to catch cycles
+func (v *validator) selections(t *Type, selections []Selection, depth int, spreading map[string]bool) {
+	if v.opts.MaxDepth > 0 && depth > v.opts.MaxDepth {
+		if !v.tooDeep {
+			v.errorf(Pos{}, "query exceeds the maximum depth of %d", v.opts.MaxDepth)
+			v.tooDeep = true
+		}
+		return
+	}
+	if v.used == nil {
+		v.used = map[string]bool{}
+	}
+	for _, selection := range selections {
+		switch s := selection.(type) {
+		case *FieldSelection:
+			v.field(t, s, depth, spreading)
+		case *FragmentSpread:
+			v.directives(s.Directives, s.Pos)
+			fragment := v.doc.Fragments[s.Name]
+			if fragment == nil {
+				v.errorf(s.Pos, "unknown fragment %s", s.Name)
+				continue
+			}
+			v.used[s.Name] = true
+			if spreading[s.Name] {
+				v.errorf(s.Pos, "fragment %s spreads itself", s.Name)
+				continue
+			}
+			if !v.condition(fragment.TypeCondition, t, s.Pos) {
+				continue
+			}
+			spreading[s.Name] = true
+			v.selections(t, fragment.Selections, depth, spreading)
+			delete(spreading, s.Name)
+		case *InlineFragment:
+			v.directives(s.Directives, Pos{})
+			if s.TypeCondition != "" && !v.condition(s.TypeCondition, t, Pos{}) {
+				continue
+			}
+			v.selections(t, s.Selections, depth, spreading)
+		}
+	}
+}
+
+// condition checks a fragment's type condition; the schema has no
+// interfaces or unions, so it must name t
+func (v *validator) condition(name string, t *Type, pos Pos) bool {
+	switch condition := v.schema.Type(name); {
+	case condition == nil:
+		v.errorf(pos, "unknown type %s", name)
+		return false
+	case condition != t:
+		v.errorf(pos, "fragment on %s cannot be spread on %s", name, t.Name)
+		return false
+	}
+	return true
+}
+
+func (v *validator) field(t *Type, s *FieldSelection, depth int, spreading map[string]bool) {
+	v.directives(s.Directives, s.Pos)
+	if s.Name == "__typename" {
+		if len(s.Selections) > 0 {
+			v.errorf(s.Pos, "__typename has no fields to select")
+		}
+		return
+	}
+	def := v.fieldDef(t, s.Name)
+	if def == nil {
+		v.errorf(s.Pos, "%s has no field %s%s", t.Name, s.Name, suggest(s.Name, fieldNames(t)))
+		return
+	}
+
+	v.arguments(def.Args, s.Arguments, s.Pos, "field "+s.Name)
+	named := def.Type.named()
+	switch {
+	case named.Kind == KindObject && len(s.Selections) == 0:
+		v.errorf(s.Pos, "field %s of type %s needs a selection of its fields", s.Name, def.Type)
+	case named.Kind != KindObject && len(s.Selections) > 0:
+		v.errorf(s.Pos, "field %s of type %s has no fields to select", s.Name, def.Type)
+	case named.Kind == KindObject:
+		v.selections(named, s.Selections, depth+1, spreading)
+	}
+}
+
+func (v *validator) directives(directives []*Directive, pos Pos) {
+	for _, d := range directives {
+		var def *directive
+		for _, known := range builtinDirectives {
+			if known.Name == d.Name {
+				def = known
+			}
+		}
+		if def == nil {
+			v.errorf(pos, "unknown directive @%s", d.Name)
+			continue
+		}
+		v.arguments(def.Args, d.Arguments, pos, "directive @"+d.Name)
+	}
+}
+
+// arguments checks args against defs: known names, required ones present and
+// literals of the right type. Variables are checked when they are read.
+func (v *validator) arguments(defs []*InputValue, args []*Argument, pos Pos, of string) {
+	seen := map[string]bool{}
+	for _, arg := range args {
+		if seen[arg.Name] {
+			v.errorf(pos, "argument %s of %s is set more than once", arg.Name, of)
+		}
+		seen[arg.Name] = true
+		var def *InputValue
+		for _, d := range defs {
+			if d.Name == arg.Name {
+				def = d
+			}
+		}
+		if def == nil {
+			names := make([]string, len(defs))
+			for i, d := range defs {
+				names[i] = d.Name
+			}
+			v.errorf(pos, "unknown argument %s of %s%s", arg.Name, of, suggest(arg.Name, names))
+			continue
+		}
+		v.variablesOf(arg.Value, pos)
+		if !containsVariable(arg.Value) {
+			if _, err := v.coerce(def.Type, arg.Value, false); err != nil {
+				v.errorf(pos, "argument %s of %s: %v", arg.Name, of, err)
+			}
+		}
+	}
+	for _, def := range defs {
+		if def.Type.Kind == KindNonNull && def.Default == nil && !seen[def.Name] {
+			v.errorf(pos, "argument %s of %s is required", def.Name, of)
+		}
+	}
+}
+
+// variablesOf reports variables used in value that the operation does not
+// declare
+func (v *validator) variablesOf(value Value, pos Pos) {
+	switch value := value.(type) {
+	case Variable:
+		if !v.declared[string(value)] {
+			v.errorf(pos, "variable $%s is not declared", value)
+		}
+	case []Value:
+		for _, item := range value {
+			v.variablesOf(item, pos)
+		}
+	case []*ObjectField:
+		for _, f := range value {
+			v.variablesOf(f.Value, pos)
+		}
+	}
+}
+
+func containsVariable(value Value) bool {
+	switch value := value.(type) {
+	case Variable:
+		return true
+	case []Value:
+		for _, item := range value {
+			if containsVariable(item) {
+				return true
+			}
+		}
+	case []*ObjectField:
+		for _, f := range value {
+			if containsVariable(f.Value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func fieldNames(t *Type) []string {
+	names := make([]string, len(t.Fields))
+	for i, f := range t.Fields {
+		names[i] = f.Name
+	}
+	return names
+}
+
+// suggest returns a hint naming the candidates name may be a typo of
+func suggest(name string, candidates []string) string {
+	var close []string
+	for _, c := range candidates {
+		if strings.EqualFold(c, name) || strings.Contains(c, name) || strings.Contains(name, c) {
+			close = append(close, c)
+		}
+	}
+	if len(close) == 0 {
+		return ""
+	}
+	return "; did you mean " + strings.Join(close, " or ") + "?"
+}
@@ -0,0 +1,286 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testSchema has books with an author, and a field that always fails
+func testSchema(t *testing.T) *Schema {
+	t.Helper()
+	author := &Type{Kind: KindObject, Name: "Author", Fields: []*Field{
+		{Name: "name", Type: NonNull(String)},
+	}}
+	book := &Type{Kind: KindObject, Name: "Book", Fields: []*Field{
+		{Name: "title", Type: NonNull(String)},
+		{Name: "pages", Type: Int},
+		{Name: "author", Type: author},
+		{Name: "broken", Type: NonNull(String), Resolve: func(context.Context, ResolveParams) (interface{}, error) {
+			return nil, errors.New("cannot read broken")
+		}},
+	}}
+	books := []map[string]interface{}{
+		{"title": "Go", "pages": int64(300), "author": map[string]interface{}{"name": "Ann"}},
+		{"title": "SQL", "pages": nil, "author": nil},
+	}
+	query := &Type{Kind: KindObject, Name: "Query", Fields: []*Field{
+		{
+			Name: "books",
+			Type: NonNull(ListOf(NonNull(book))),
+			Args: []*InputValue{{Name: "first", Type: Int, Default: int64(10)}},
+			Resolve: func(_ context.Context, p ResolveParams) (interface{}, error) {
+				return books[:min(p.Args["first"].(int), len(books))], nil
+			},
+		},
+		{
+			Name: "echo",
+			Type: String,
+			Args: []*InputValue{{Name: "text", Type: NonNull(String)}},
+			Resolve: func(_ context.Context, p ResolveParams) (interface{}, error) {
+				return p.Args["text"], nil
+			},
+		},
+	}}
+	schema, err := NewSchema(query)
+	require.NoError(t, err)
+	return schema
+}
+
+func execute(t *testing.T, schema *Schema, query string, variables map[string]interface{}) (string, *Response) {
+	t.Helper()
+	resp := Execute(context.Background(), schema, Request{Query: query, Variables: variables}, Options{MaxDepth: 5})
+	body, err := json.Marshal(resp)
+	require.NoError(t, err)
+	return string(body), resp
+}
+
+func TestExecute_FieldsAliasesAndFragments(t *testing.T) {
+	schema := testSchema(t)
+
+	body, resp := execute(t, schema, `
+		query Books($n: Int = 1) {
+			first: books(first: $n) { ...bookFields }
+			all: books { title author { name } }
+		}
+		fragment bookFields on Book { title pages __typename }`, nil)
+
+	require.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"data": {
+		"first": [{"title": "Go", "pages": 300, "__typename": "Book"}],
+		"all": [{"title": "Go", "author": {"name": "Ann"}}, {"title": "SQL", "author": null}]
+	}}`, body)
+}
+
+func TestExecute_Variables(t *testing.T) {
+	schema := testSchema(t)
+
+	body, resp := execute(t, schema, `query($text: String!) { echo(text: $text) }`, map[string]interface{}{"text": "hi"})
+	require.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"data": {"echo": "hi"}}`, body)
+
+	_, resp = execute(t, schema, `query($text: String!) { echo(text: $text) }`, nil)
+	require.False(t, resp.Executed())
+	assert.Contains(t, resp.Errors[0].Message, "variable $text of type String! is required")
+
+	_, resp = execute(t, schema, `query($n: Int) { books(first: $n) { title } }`, map[string]interface{}{"n": "two"})
+	require.False(t, resp.Executed())
+	assert.Contains(t, resp.Errors[0].Message, "expected an Int")
+}
+
+func TestExecute_SkipAndInclude(t *testing.T) {
+	schema := testSchema(t)
+
+	body, resp := execute(t, schema, `query($yes: Boolean!) {
+		books(first: 1) { title @skip(if: $yes) pages @include(if: $yes) }
+	}`, map[string]interface{}{"yes": true})
+
+	require.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"data": {"books": [{"pages": 300}]}}`, body)
+}
+
+func TestExecute_NullPropagation(t *testing.T) {
+	schema := testSchema(t)
+
+	// A failed non-null field nulls its book, which nulls the non-null list
+	// and so the data
+	body, resp := execute(t, schema, `{ books { title broken } }`, nil)
+
+	require.True(t, resp.Executed())
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "cannot read broken", resp.Errors[0].Message)
+	assert.Equal(t, []interface{}{"books", 0, "broken"}, resp.Errors[0].Path)
+	assert.JSONEq(t, `{"data": null, "errors": [{"message": "cannot read broken", "locations": [{"line": 1, "column": 17}], "path": ["books", 0, "broken"]}]}`, body)
+}
+
+func TestExecute_Validation(t *testing.T) {
+	schema := testSchema(t)
+
+	tests := []struct {
+		name    string
+		query   string
+		message string
+	}{
+		{"syntax", `{ books { title }`, "Syntax error"},
+		{"unknown field", `{ books { titel } }`, "Book has no field titel"},
+		{"unknown argument", `{ books(last: 1) { title } }`, "unknown argument last"},
+		{"missing argument", `{ echo }`, "argument text of field echo is required"},
+		{"bad literal", `{ echo(text: 1) }`, "expected a String"},
+		{"object without selection", `{ books }`, "needs a selection"},
+		{"leaf with selection", `{ echo(text: "a") { x } }`, "has no fields to select"},
+		{"unknown fragment", `{ books { ...missing } }`, "unknown fragment missing"},
+		{"unused fragment", `{ echo(text: "a") } fragment f on Book { title }`, "fragment f is never used"},
+		{"fragment cycle", `{ books { ...a } } fragment a on Book { ...b } fragment b on Book { ...a }`, "spreads itself"},
+		{"undeclared variable", `{ echo(text: $text) }`, "variable $text is not declared"},
+		{"mutation", `mutation { echo(text: "a") }`, "mutation operations are not supported"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, resp := execute(t, schema, tt.query, nil)
+			require.False(t, resp.Executed())
+			require.NotEmpty(t, resp.Errors)
+			assert.Contains(t, resp.Errors[0].Message, tt.message)
+		})
+	}
+}
+
+func TestExecute_MaxDepth(t *testing.T) {
+	node := &Type{Kind: KindObject, Name: "Node"}
+	node.Fields = []*Field{
+		{Name: "id", Type: String},
+		{Name: "next", Type: node},
+	}
+	schema, err := NewSchema(&Type{Kind: KindObject, Name: "Query", Fields: []*Field{{Name: "node", Type: node}}})
+	require.NoError(t, err)
+
+	resp := Execute(context.Background(), schema, Request{Query: `{ node { next { next { id } } } }`}, Options{MaxDepth: 4})
+	assert.True(t, resp.Executed())
+
+	resp = Execute(context.Background(), schema, Request{Query: `{ node { next { next { next { id } } } } }`}, Options{MaxDepth: 4})
+	require.False(t, resp.Executed())
+	assert.Equal(t, "query exceeds the maximum depth of 4", resp.Errors[0].Message)
+}
+
+func TestExecute_Selections(t *testing.T) {
+	var selected []*SelectedField
+	book := &Type{Kind: KindObject, Name: "Book", Fields: []*Field{
+		{Name: "title", Type: String},
+		{Name: "pages", Type: Int},
+	}}
+	schema, err := NewSchema(&Type{Kind: KindObject, Name: "Query", Fields: []*Field{{
+		Name: "book",
+		Type: book,
+		Resolve: func(_ context.Context, p ResolveParams) (interface{}, error) {
+			selected = p.Selections
+			return map[string]interface{}{}, nil
+		},
+	}}})
+	require.NoError(t, err)
+
+	resp := Execute(context.Background(), schema, Request{
+		Query: `{ book { a: title b: title ... on Book { pages } pages @skip(if: true) } }`,
+	}, Options{})
+
+	require.Empty(t, resp.Errors)
+	names := make([]string, len(selected))
+	for i, f := range selected {
+		names[i] = f.Name
+	}
+	assert.Equal(t, []string{"title", "title", "pages"}, names)
+}
+
+func TestExecute_Introspection(t *testing.T) {
+	schema := testSchema(t)
+
+	resp := Execute(context.Background(), schema, Request{Query: introspectionQuery}, Options{MaxDepth: 15})
+	require.Empty(t, resp.Errors)
+
+	body, err := json.Marshal(resp)
+	require.NoError(t, err)
+	var result struct {
+		Data struct {
+			Schema struct {
+				QueryType struct{ Name string }
+				Types     []struct {
+					Kind   string
+					Name   string
+					Fields []struct {
+						Name string
+						Args []struct {
+							Name         string
+							DefaultValue *string
+						}
+					}
+				}
+			} `json:"__schema"`
+		}
+	}
+	require.NoError(t, json.Unmarshal(body, &result))
+	assert.Equal(t, "Query", result.Data.Schema.QueryType.Name)
+
+	types := map[string]string{}
+	for _, typ := range result.Data.Schema.Types {
+		types[typ.Name] = typ.Kind
+		if typ.Name == "Query" {
+			require.Equal(t, "books", typ.Fields[0].Name)
+			require.Equal(t, "first", typ.Fields[0].Args[0].Name)
+			assert.Equal(t, "10", *typ.Fields[0].Args[0].DefaultValue)
+		}
+	}
+	assert.Equal(t, "OBJECT", types["Book"])
+	assert.Equal(t, "SCALAR", types["Int"])
+	assert.Equal(t, "ENUM", types["__TypeKind"])
+
+	typeBody, _ := execute(t, schema, `{ __type(name: "Author") { name fields { name type { kind ofType { name } } } } }`, nil)
+	assert.JSONEq(t, `{"data": {"__type": {"name": "Author", "fields": [
+		{"name": "name", "type": {"kind": "NON_NULL", "ofType": {"name": "String"}}}
+	]}}}`, typeBody)
+}
+
+func TestNewSchema_DuplicateTypeNames(t *testing.T) {
+	query := &Type{Kind: KindObject, Name: "Query", Fields: []*Field{
+		{Name: "a", Type: &Type{Kind: KindObject, Name: "Row"}},
+		{Name: "b", Type: &Type{Kind: KindObject, Name: "Row"}},
+	}}
+	_, err := NewSchema(query)
+	assert.EqualError(t, err, "graphql: two types named Row")
+}
+
+// introspectionQuery is the query GraphiQL and graphql-js send
+const introspectionQuery = `
+query IntrospectionQuery {
+  __schema {
+    queryType { name }
+    mutationType { name }
+    subscriptionType { name }
+    types { ...FullType }
+    directives { name description locations args { ...InputValue } }
+  }
+}
+fragment FullType on __Type {
+  kind name description
+  fields(includeDeprecated: true) {
+    name description
+    args { ...InputValue }
+    type { ...TypeRef }
+    isDeprecated deprecationReason
+  }
+  inputFields { ...InputValue }
+  interfaces { ...TypeRef }
+  enumValues(includeDeprecated: true) { name description isDeprecated deprecationReason }
+  possibleTypes { ...TypeRef }
+}
+fragment InputValue on __InputValue {
+  name description
+  type { ...TypeRef }
+  defaultValue
+}
+fragment TypeRef on __Type {
+  kind name
+  ofType { kind name ofType { kind name ofType { kind name ofType { kind name
+    ofType { kind name ofType { kind name ofType { kind name ofType { kind name } } } } } } } }
+}`
@@ -0,0 +1,267 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// directive is a directive the schema supports, as introspection reports it
+type directive struct {
+	Name        string
+	Description string
+	Locations   []string
+	Args        []*InputValue
+}
+
+// builtinDirectives are the directives of every schema: @include and @skip
+var builtinDirectives = []*directive{
+	{
+		Name:        "include",
+		Description: "Includes the field or fragment only when if is true",
+		Locations:   []string{"FIELD", "FRAGMENT_SPREAD", "INLINE_FRAGMENT"},
+		Args:        []*InputValue{{Name: "if", Type: NonNull(Boolean)}},
+	},
+	{
+		Name:        "skip",
+		Description: "Skips the field or fragment when if is true",
+		Locations:   []string{"FIELD", "FRAGMENT_SPREAD", "INLINE_FRAGMENT"},
+		Args:        []*InputValue{{Name: "if", Type: NonNull(Boolean)}},
+	},
+}
+
+// Introspection types, which describe a schema to tooling
+var (
+	introspectionSchema = &Type{Kind: KindObject, Name: "__Schema"}
+	introspectionType   = &Type{Kind: KindObject, Name: "__Type"}
+	introspectionField  = &Type{Kind: KindObject, Name: "__Field"}
+	introspectionInput  = &Type{Kind: KindObject, Name: "__InputValue"}
+	introspectionEnum   = &Type{Kind: KindObject, Name: "__EnumValue"}
+	introspectionDir    = &Type{Kind: KindObject, Name: "__Directive"}
+
+	typeKindEnum = &Type{Kind: KindEnum, Name: "__TypeKind", EnumValues: enumValues(
+		KindScalar, KindObject, "INTERFACE", "UNION", KindEnum, KindInputObject, KindList, KindNonNull)}
+	directiveLocationEnum = &Type{Kind: KindEnum, Name: "__DirectiveLocation", EnumValues: enumValues(
+		"QUERY", "MUTATION", "SUBSCRIPTION", "FIELD", "FRAGMENT_DEFINITION", "FRAGMENT_SPREAD", "INLINE_FRAGMENT")}
+)
+
+func init() {
+	deprecated := []*InputValue{{Name: "includeDeprecated", Type: Boolean, Default: false}}
+	none := func(context.Context, ResolveParams) (interface{}, error) { return nil, nil }
+	notDeprecated := func(context.Context, ResolveParams) (interface{}, error) { return false, nil }
+
+	introspectionSchema.Fields = []*Field{
+		{Name: "description", Type: String, Resolve: none},
+		{Name: "types", Type: NonNull(ListOf(NonNull(introspectionType))), Resolve: func(_ context.Context, p ResolveParams) (interface{}, error) {
+			return p.Source.(*Schema).Types(), nil
+		}},
+		{Name: "queryType", Type: NonNull(introspectionType), Resolve: func(_ context.Context, p ResolveParams) (interface{}, error) {
+			return p.Source.(*Schema).Query, nil
+		}},
+		{Name: "mutationType", Type: introspectionType, Resolve: none},
+		{Name: "subscriptionType", Type: introspectionType, Resolve: none},
+		{Name: "directives", Type: NonNull(ListOf(NonNull(introspectionDir))), Resolve: func(context.Context, ResolveParams) (interface{}, error) {
+			return builtinDirectives, nil
+		}},
+	}
+
+	introspectionType.Fields = []*Field{
+		{Name: "kind", Type: NonNull(typeKindEnum), Resolve: func(_ context.Context, p ResolveParams) (interface{}, error) {
+			return string(p.Source.(*Type).Kind), nil
+		}},
+		{Name: "name", Type: String, Resolve: func(_ context.Context, p ResolveParams) (interface{}, error) {
+			return optional(p.Source.(*Type).Name), nil
+		}},
+		{Name: "description", Type: String, Resolve: func(_ context.Context, p ResolveParams) (interface{}, error) {
+			return optional(p.Source.(*Type).Description), nil
+		}},
+		{Name: "specifiedByURL", Type: String, Resolve: none},
+		{Name: "fields", Type: ListOf(NonNull(introspectionField)), Args: deprecated, Resolve: func(_ context.Context, p ResolveParams) (interface{}, error) {
+			if t := p.Source.(*Type); t.Kind == KindObject {
+				return t.Fields, nil
+			}
+			return nil, nil
+		}},
+		{Name: "interfaces", Type: ListOf(NonNull(introspectionType)), Resolve: func(_ context.Context, p ResolveParams) (interface{}, error) {
+			if p.Source.(*Type).Kind == KindObject {
+				return []*Type{}, nil
+			}
+			return nil, nil
+		}},
+		{Name: "possibleTypes", Type: ListOf(NonNull(introspectionType)), Resolve: none},
+		{Name: "enumValues", Type: ListOf(NonNull(introspectionEnum)), Args: deprecated, Resolve: func(_ context.Context, p ResolveParams) (interface{}, error) {
+			if t := p.Source.(*Type); t.Kind == KindEnum {
+				return t.EnumValues, nil
+			}
+			return nil, nil
+		}},
+		{Name: "inputFields", Type: ListOf(NonNull(introspectionInput)), Args: deprecated, Resolve: func(_ context.Context, p ResolveParams) (interface{}, error) {
+			if t := p.Source.(*Type); t.Kind == KindInputObject {
+				return t.InputFields, nil
+			}
+			return nil, nil
+		}},
+		{Name: "ofType", Type: introspectionType, Resolve: func(_ context.Context, p ResolveParams) (interface{}, error) {
+			if t := p.Source.(*Type); t.OfType != nil {
+				return t.OfType, nil
+			}
+			return nil, nil
+		}},
+		{Name: "isOneOf", Type: Boolean, Resolve: func(_ context.Context, p ResolveParams) (interface{}, error) {
+			if p.Source.(*Type).Kind == KindInputObject {
+				return false, nil
+			}
+			return nil, nil
+		}},
+	}
+
+	introspectionField.Fields = []*Field{
+		{Name: "name", Type: NonNull(String), Resolve: func(_ context.Context, p ResolveParams) (interface{}, error) {
+			return p.Source.(*Field).Name, nil
+		}},
+		{Name: "description", Type: String, Resolve: func(_ context.Context, p ResolveParams) (interface{}, error) {
+			return optional(p.Source.(*Field).Description), nil
+		}},
+		{Name: "args", Type: NonNull(ListOf(NonNull(introspectionInput))), Args: deprecated, Resolve: func(_ context.Context, p ResolveParams) (interface{}, error) {
+			return nonNilArgs(p.Source.(*Field).Args), nil
+		}},
+		{Name: "type", Type: NonNull(introspectionType), Resolve: func(_ context.Context, p ResolveParams) (interface{}, error) {
+			return p.Source.(*Field).Type, nil
+		}},
+		{Name: "isDeprecated", Type: NonNull(Boolean), Resolve: notDeprecated},
+		{Name: "deprecationReason", Type: String, Resolve: none},
+	}
+
+	introspectionInput.Fields = []*Field{
+		{Name: "name", Type: NonNull(String), Resolve: func(_ context.Context, p ResolveParams) (interface{}, error) {
+			return p.Source.(*InputValue).Name, nil
+		}},
+		{Name: "description", Type: String, Resolve: func(_ context.Context, p ResolveParams) (interface{}, error) {
+			return optional(p.Source.(*InputValue).Description), nil
+		}},
+		{Name: "type", Type: NonNull(introspectionType), Resolve: func(_ context.Context, p ResolveParams) (interface{}, error) {
+			return p.Source.(*InputValue).Type, nil
+		}},
+		{Name: "defaultValue", Type: String, Resolve: func(_ context.Context, p ResolveParams) (interface{}, error) {
+			if v := p.Source.(*InputValue).Default; v != nil {
+				return printValue(v), nil
+			}
+			return nil, nil
+		}},
+		{Name: "isDeprecated", Type: NonNull(Boolean), Resolve: notDeprecated},
+		{Name: "deprecationReason", Type: String, Resolve: none},
+	}
+
+	introspectionEnum.Fields = []*Field{
+		{Name: "name", Type: NonNull(String), Resolve: func(_ context.Context, p ResolveParams) (interface{}, error) {
+			return p.Source.(*EnumValueDef).Name, nil
+		}},
+		{Name: "description", Type: String, Resolve: func(_ context.Context, p ResolveParams) (interface{}, error) {
+			return optional(p.Source.(*EnumValueDef).Description), nil
+		}},
+		{Name: "isDeprecated", Type: NonNull(Boolean), Resolve: notDeprecated},
+		{Name: "deprecationReason", Type: String, Resolve: none},
+	}
+
+	introspectionDir.Fields = []*Field{
+		{Name: "name", Type: NonNull(String), Resolve: func(_ context.Context, p ResolveParams) (interface{}, error) {
+			return p.Source.(*directive).Name, nil
+		}},
+		{Name: "description", Type: String, Resolve: func(_ context.Context, p ResolveParams) (interface{}, error) {
+			return optional(p.Source.(*directive).Description), nil
+		}},
+		{Name: "isRepeatable", Type: NonNull(Boolean), Resolve: notDeprecated},
+		{Name: "locations", Type: NonNull(ListOf(NonNull(directiveLocationEnum))), Resolve: func(_ context.Context, p ResolveParams) (interface{}, error) {
+			return p.Source.(*directive).Locations, nil
+		}},
+		{Name: "args", Type: NonNull(ListOf(NonNull(introspectionInput))), Args: deprecated, Resolve: func(_ context.Context, p ResolveParams) (interface{}, error) {
+			return nonNilArgs(p.Source.(*directive).Args), nil
+		}},
+	}
+}
+
+// rootIntrospection returns the __schema and __type fields of the query type
+// of s
+func rootIntrospection(s *Schema, name string) *Field {
+	switch name {
+	case "__schema":
+		return &Field{Name: name, Type: NonNull(introspectionSchema), Resolve: func(context.Context, ResolveParams) (interface{}, error) {
+			return s, nil
+		}}
+	case "__type":
+		return &Field{
+			Name: name,
+			Type: introspectionType,
+			Args: []*InputValue{{Name: "name", Type: NonNull(String)}},
+			Resolve: func(_ context.Context, p ResolveParams) (interface{}, error) {
+				if t := s.Type(p.Args["name"].(string)); t != nil {
+					return t, nil
+				}
+				return nil, nil
+			},
+		}
+	}
+	return nil
+}
+
+// nonNilArgs returns args, or an empty list for a field without arguments
+func nonNilArgs(args []*InputValue) []*InputValue {
+	if args == nil {
+		return []*InputValue{}
+	}
+	return args
+}
+
+// EnumValues returns the values of an enum type with the given names
+func EnumValues(names ...string) []*EnumValueDef {
+	values := make([]*EnumValueDef, len(names))
+	for i, name := range names {
+		values[i] = &EnumValueDef{Name: name}
+	}
+	return values
+}
+
+func enumValues(kinds ...Kind) []*EnumValueDef {
+	names := make([]string, len(kinds))
+	for i, kind := range kinds {
+		names[i] = string(kind)
+	}
+	return EnumValues(names...)
+}
+
+// optional returns s, or nil for an empty string
+func optional(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// printValue writes a default value as a GraphQL literal
+func printValue(v Value) string {
+	switch v := v.(type) {
+	case nil, Null:
+		return "null"
+	case string:
+		return strconv.Quote(v)
+	case EnumValue:
+		return string(v)
+	case []Value:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			parts[i] = printValue(item)
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	case []*ObjectField:
+		parts := make([]string, len(v))
+		for i, field := range v {
+			parts[i] = field.Name + ": " + printValue(field.Value)
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	case json.Number:
+		return v.String()
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,648 @@
+// Package graphql executes GraphQL queries against a schema of resolvers. It
+// implements the subset of the spec the gateway serves: query operations with
+// variables, fragments, aliases, @include and @skip, and introspection.
+// Mutations and subscriptions are parsed but refused.
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Document is a parsed request: its operations and the fragments they spread
+type Document struct {
+	Operations []*Operation
+	Fragments  map[string]*Fragment
+}
+
+// Operation is a query, mutation or subscription of a document
+type Operation struct {
+	Type       string // query, mutation or subscription
+	Name       string
+	Variables  []*VariableDef
+	Directives []*Directive
+	Selections []Selection
+}
+
+// VariableDef declares a variable of an operation
+type VariableDef struct {
+	Name    string
+	Type    TypeRef
+	Default Value // nil without a default
+}
+
+// TypeRef is a type as written in a variable definition, e.g. [String!]
+type TypeRef struct {
+	Name    string   // Set for a named type
+	Elem    *TypeRef // Set for a list
+	NonNull bool
+}
+
+func (t TypeRef) String() string {
+	s := t.Name
+	if t.Elem != nil {
+		s = "[" + t.Elem.String() + "]"
+	}
+	if t.NonNull {
+		s += "!"
+	}
+	return s
+}
+
+// Fragment is a named fragment definition
+type Fragment struct {
+	Name          string
+	TypeCondition string
+	Directives    []*Directive
+	Selections    []Selection
+}
+
+// Selection is a *FieldSelection, *FragmentSpread or *InlineFragment
+type Selection interface {
+	selection()
+}
+
+// FieldSelection selects a field, optionally under an alias
+type FieldSelection struct {
+	Alias      string
+	Name       string
+	Arguments  []*Argument
+	Directives []*Directive
+	Selections []Selection
+	Pos        Pos
+}
+
+// ResponseKey is the key of the field in the response: its alias or name
+func (f *FieldSelection) ResponseKey() string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	return f.Name
+}
+
+// FragmentSpread spreads a named fragment
+type FragmentSpread struct {
+	Name       string
+	Directives []*Directive
+	Pos        Pos
+}
+
+// InlineFragment selects fields on an optional type condition
+type InlineFragment struct {
+	TypeCondition string
+	Directives    []*Directive
+	Selections    []Selection
+}
+
+func (*FieldSelection) selection() {}
+func (*FragmentSpread) selection() {}
+func (*InlineFragment) selection() {}
+
+// Argument is a named argument of a field or directive
+type Argument struct {
+	Name  string
+	Value Value
+}
+
+// Directive is a directive such as @include(if: $x)
+type Directive struct {
+	Name      string
+	Arguments []*Argument
+}
+
+// Value is a literal or variable in a document. Literals are the Go values
+// of Null, int64, float64, string, bool, EnumValue, []Value and
+// []*ObjectField; variables are Variable.
+type Value = interface{}
+
+// Null is the null literal
+type Null struct{}
+
+// EnumValue is an enum literal, e.g. DESC
+type EnumValue string
+
+// Variable is a reference to a variable, without its $
+type Variable string
+
+// ObjectField is a field of an input object literal
+type ObjectField struct {
+	Name  string
+	Value Value
+}
+
+// Pos is a position in a document, 1-based
+type Pos struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// SyntaxError is a document that cannot be parsed
+type SyntaxError struct {
+	Message string
+	Pos     Pos
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at %d:%d: %s", e.Pos.Line, e.Pos.Column, e.Message)
+}
+
+// maxTokens bounds the tokens of a document, so a huge request fails fast
+const maxTokens = 20000
+
+// token kinds
+const (
+	tokEOF = iota
+	tokPunct
+	tokName
+	tokInt
+	tokFloat
+	tokString
+)
+
+type token struct {
+	kind  int
+	value string
+	pos   Pos
+}
+
+// Parse parses a GraphQL document
+func Parse(source string) (doc *Document, err error) {
+	p := &parser{lex: lexer{src: source, line: 1, lineStart: 0}}
+	defer func() {
+		if r := recover(); r != nil {
+			syntaxErr, ok := r.(*SyntaxError)
+			if !ok {
+				panic(r)
+			}
+			doc, err = nil, syntaxErr
+		}
+	}()
+	p.next()
+	return p.document(), nil
+}
+
+type lexer struct {
+	src       string
+	offset    int
+	line      int
+	lineStart int
+	tokens    int
+}
+
+func (l *lexer) pos() Pos {
+	return Pos{Line: l.line, Column: l.offset - l.lineStart + 1}
+}
+
+func (l *lexer) fail(pos Pos, format string, args ...interface{}) {
+	panic(&SyntaxError{Message: fmt.Sprintf(format, args...), Pos: pos})
+}
+
+// skip skips whitespace, commas and comments
+func (l *lexer) skip() {
+	for l.offset < len(l.src) {
+		switch c := l.src[l.offset]; c {
+		case ' ', '\t', ',', '\r':
+			l.offset++
+		case '\n':
+			l.offset++
+			l.line, l.lineStart = l.line+1, l.offset
+		case '#':
+			for l.offset < len(l.src) && l.src[l.offset] != '\n' {
+				l.offset++
+			}
+		default:
+			if strings.HasPrefix(l.src[l.offset:], "\uFEFF") {
+				l.offset += len("\uFEFF")
+				continue
+			}
+			return
+		}
+	}
+}
+
+func (l *lexer) next() token {
+	l.skip()
+	pos := l.pos()
+	if l.offset >= len(l.src) {
+		return token{kind: tokEOF, pos: pos}
+	}
+	if l.tokens++; l.tokens > maxTokens {
+		l.fail(pos, "document exceeds %d tokens", maxTokens)
+	}
+
+	c := l.src[l.offset]
+	switch {
+	case strings.HasPrefix(l.src[l.offset:], "..."):
+		l.offset += 3
+		return token{kind: tokPunct, value: "...", pos: pos}
+	case strings.IndexByte("!$&():=@[]{}|", c) >= 0:
+		l.offset++
+		return token{kind: tokPunct, value: string(c), pos: pos}
+	case c == '_' || isLetter(c):
+		start := l.offset
+		for l.offset < len(l.src) && (l.src[l.offset] == '_' || isLetter(l.src[l.offset]) || isDigit(l.src[l.offset])) {
+			l.offset++
+		}
+		return token{kind: tokName, value: l.src[start:l.offset], pos: pos}
+	case c == '-' || isDigit(c):
+		return l.number(pos)
+	case c == '"':
+		if strings.HasPrefix(l.src[l.offset:], `"""`) {
+			return l.blockString(pos)
+		}
+		return l.string(pos)
+	}
+	r, _ := utf8.DecodeRuneInString(l.src[l.offset:])
+	l.fail(pos, "unexpected character %q", r)
+	return token{}
+}
+
+func (l *lexer) number(pos Pos) token {
+	start := l.offset
+	if l.src[l.offset] == '-' {
+		l.offset++
+	}
+	digits := l.digits(pos)
+	if len(digits) > 1 && digits[0] == '0' {
+		l.fail(pos, "invalid number, unexpected digit after 0")
+	}
+	kind := tokInt
+	if l.offset < len(l.src) && l.src[l.offset] == '.' {
+		l.offset++
+		l.digits(pos)
+		kind = tokFloat
+	}
+	if l.offset < len(l.src) && (l.src[l.offset] == 'e' || l.src[l.offset] == 'E') {
+		l.offset++
+		if l.offset < len(l.src) && (l.src[l.offset] == '+' || l.src[l.offset] == '-') {
+			l.offset++
+		}
+		l.digits(pos)
+		kind = tokFloat
+	}
+	if l.offset < len(l.src) && (l.src[l.offset] == '_' || l.src[l.offset] == '.' || isLetter(l.src[l.offset])) {
+		l.fail(pos, "invalid number")
+	}
+	return token{kind: kind, value: l.src[start:l.offset], pos: pos}
+}
+
+func (l *lexer) digits(pos Pos) string {
+	start := l.offset
+	for l.offset < len(l.src) && isDigit(l.src[l.offset]) {
+		l.offset++
+	}
+	if l.offset == start {
+		l.fail(pos, "invalid number, expected digit")
+	}
+	return l.src[start:l.offset]
+}
+
+func (l *lexer) string(pos Pos) token {
+	l.offset++ // Opening quote
+	var b strings.Builder
+	for {
+		if l.offset >= len(l.src) || l.src[l.offset] == '\n' {
+			l.fail(pos, "unterminated string")
+		}
+		c := l.src[l.offset]
+		switch {
+		case c == '"':
+			l.offset++
+			return token{kind: tokString, value: b.String(), pos: pos}
+		case c == '\\':
+			if l.offset+1 >= len(l.src) {
+				l.fail(pos, "unterminated string")
+			}
+			escape := l.src[l.offset+1]
+			l.offset += 2
+			switch escape {
+			case '"', '\\', '/':
+				b.WriteByte(escape)
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'u':
+				if l.offset+4 > len(l.src) {
+					l.fail(pos, "invalid unicode escape")
+				}
+				code, err := strconv.ParseUint(l.src[l.offset:l.offset+4], 16, 32)
+				if err != nil {
+					l.fail(pos, "invalid unicode escape")
+				}
+				b.WriteRune(rune(code))
+				l.offset += 4
+			default:
+				l.fail(pos, "invalid escape \\%c", escape)
+			}
+		default:
+			b.WriteByte(c)
+			l.offset++
+		}
+	}
+}
+
+// blockString reads a """ string, removing the common indentation of its
+// lines and its leading and trailing blank lines
+func (l *lexer) blockString(pos Pos) token {
+	l.offset += 3
+	end := strings.Index(l.src[l.offset:], `"""`)
+	for end >= 0 && end > 0 && l.src[l.offset+end-1] == '\\' {
+		next := strings.Index(l.src[l.offset+end+3:], `"""`)
+		if next < 0 {
+			end = -1
+			break
+		}
+		end += 3 + next
+	}
+	if end < 0 {
+		l.fail(pos, "unterminated block string")
+	}
+	raw := l.src[l.offset : l.offset+end]
+	for i := 0; i < len(raw); i++ {
+		if raw[i] == '\n' {
+			l.line, l.lineStart = l.line+1, l.offset+i+1
+		}
+	}
+	l.offset += end + 3
+
+	lines := strings.Split(strings.ReplaceAll(strings.ReplaceAll(raw, `\"""`, `"""`), "\r\n", "\n"), "\n")
+	indent := -1
+	for _, line := range lines[1:] {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed != "" && (indent < 0 || len(line)-len(trimmed) < indent) {
+			indent = len(line) - len(trimmed)
+		}
+	}
+	for i := 1; i < len(lines) && indent > 0; i++ {
+		if len(lines[i]) >= indent {
+			lines[i] = lines[i][indent:]
+		} else {
+			lines[i] = strings.TrimLeft(lines[i], " \t")
+		}
+	}
+	for len(lines) > 0 && strings.TrimLeft(lines[0], " \t") == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimLeft(lines[len(lines)-1], " \t") == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return token{kind: tokString, value: strings.Join(lines, "\n"), pos: pos}
+}
+
+func isLetter(c byte) bool { return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' }
+func isDigit(c byte) bool  { return c >= '0' && c <= '9' }
+
+type parser struct {
+	lex lexer
+	tok token
+}
+
+func (p *parser) next() token {
+	prev := p.tok
+	p.tok = p.lex.next()
+	return prev
+}
+
+func (p *parser) peek(punct string) bool {
+	return p.tok.kind == tokPunct && p.tok.value == punct
+}
+
+func (p *parser) skip(punct string) bool {
+	if p.peek(punct) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(punct string) {
+	if !p.skip(punct) {
+		p.unexpected("%q", punct)
+	}
+}
+
+func (p *parser) unexpected(format string, args ...interface{}) {
+	found := p.tok.value
+	switch {
+	case p.tok.kind == tokEOF:
+		found = "end of document"
+	case p.tok.kind == tokString:
+		found = strconv.Quote(found)
+	}
+	p.lex.fail(p.tok.pos, "expected %s, found %s", fmt.Sprintf(format, args...), found)
+}
+
+func (p *parser) name() string {
+	if p.tok.kind != tokName {
+		p.unexpected("a name")
+	}
+	return p.next().value
+}
+
+func (p *parser) document() *Document {
+	doc := &Document{Fragments: map[string]*Fragment{}}
+	for p.tok.kind != tokEOF {
+		switch {
+		case p.peek("{"):
+			doc.Operations = append(doc.Operations, &Operation{Type: "query", Selections: p.selectionSet()})
+		case p.tok.kind == tokName && p.tok.value == "fragment":
+			pos := p.tok.pos
+			fragment := p.fragment()
+			if _, dup := doc.Fragments[fragment.Name]; dup {
+				p.lex.fail(pos, "fragment %s is defined more than once", fragment.Name)
+			}
+			doc.Fragments[fragment.Name] = fragment
+		case p.tok.kind == tokName && (p.tok.value == "query" || p.tok.value == "mutation" || p.tok.value == "subscription"):
+			doc.Operations = append(doc.Operations, p.operation())
+		default:
+			p.unexpected("an operation or fragment")
+		}
+	}
+	if len(doc.Operations) == 0 {
+		p.lex.fail(p.tok.pos, "document has no operation")
+	}
+	return doc
+}
+
+func (p *parser) operation() *Operation {
+	op := &Operation{Type: p.next().value}
+	if p.tok.kind == tokName {
+		op.Name = p.name()
+	}
+	if p.skip("(") {
+		for !p.skip(")") {
+			p.expect("$")
+			def := &VariableDef{Name: p.name()}
+			p.expect(":")
+			def.Type = p.typeRef()
+			if p.skip("=") {
+				def.Default = p.value(true)
+			}
+			op.Variables = append(op.Variables, def)
+		}
+	}
+	op.Directives = p.directives()
+	op.Selections = p.selectionSet()
+	return op
+}
+
+func (p *parser) fragment() *Fragment {
+	p.next() // fragment
+	f := &Fragment{Name: p.name()}
+	if f.Name == "on" {
+		p.lex.fail(p.tok.pos, "a fragment cannot be named on")
+	}
+	if p.tok.kind != tokName || p.tok.value != "on" {
+		p.unexpected("on")
+	}
+	p.next()
+	f.TypeCondition = p.name()
+	f.Directives = p.directives()
+	f.Selections = p.selectionSet()
+	return f
+}
+
+func (p *parser) typeRef() TypeRef {
+	var t TypeRef
+	if p.skip("[") {
+		elem := p.typeRef()
+		p.expect("]")
+		t.Elem = &elem
+	} else {
+		t.Name = p.name()
+	}
+	t.NonNull = p.skip("!")
+	return t
+}
+
+func (p *parser) selectionSet() []Selection {
+	p.expect("{")
+	var selections []Selection
+	for !p.skip("}") {
+		selections = append(selections, p.selection())
+	}
+	if len(selections) == 0 {
+		p.lex.fail(p.tok.pos, "a selection set needs at least one field")
+	}
+	return selections
+}
+
+func (p *parser) selection() Selection {
+	pos := p.tok.pos
+	if p.skip("...") {
+		if p.tok.kind == tokName && p.tok.value != "on" {
+			return &FragmentSpread{Name: p.name(), Directives: p.directives(), Pos: pos}
+		}
+		inline := &InlineFragment{}
+		if p.tok.kind == tokName {
+			p.next() // on
+			inline.TypeCondition = p.name()
+		}
+		inline.Directives = p.directives()
+		inline.Selections = p.selectionSet()
+		return inline
+	}
+
+	field := &FieldSelection{Name: p.name(), Pos: pos}
+	if p.skip(":") {
+		field.Alias, field.Name = field.Name, p.name()
+	}
+	field.Arguments = p.arguments(false)
+	field.Directives = p.directives()
+	if p.peek("{") {
+		field.Selections = p.selectionSet()
+	}
+	return field
+}
+
+func (p *parser) arguments(constant bool) []*Argument {
+	if !p.skip("(") {
+		return nil
+	}
+	var args []*Argument
+	for !p.skip(")") {
+		arg := &Argument{Name: p.name()}
+		p.expect(":")
+		arg.Value = p.value(constant)
+		args = append(args, arg)
+	}
+	return args
+}
+
+func (p *parser) directives() []*Directive {
+	var directives []*Directive
+	for p.skip("@") {
+		directives = append(directives, &Directive{Name: p.name(), Arguments: p.arguments(false)})
+	}
+	return directives
+}
+
+// value parses a value; a constant value, such as a variable default, has
+// no variables
+func (p *parser) value(constant bool) Value {
+	tok := p.tok
+	switch tok.kind {
+	case tokInt:
+		p.next()
+		n, err := strconv.ParseInt(tok.value, 10, 64)
+		if err != nil {
+			p.lex.fail(tok.pos, "integer %s out of range", tok.value)
+		}
+		return n
+	case tokFloat:
+		p.next()
+		f, err := strconv.ParseFloat(tok.value, 64)
+		if err != nil {
+			p.lex.fail(tok.pos, "invalid float %s", tok.value)
+		}
+		return f
+	case tokString:
+		p.next()
+		return tok.value
+	case tokName:
+		p.next()
+		switch tok.value {
+		case "true":
+			return true
+		case "false":
+			return false
+		case "null":
+			return Null{}
+		}
+		return EnumValue(tok.value)
+	}
+
+	switch {
+	case p.skip("$"):
+		if constant {
+			p.lex.fail(tok.pos, "unexpected variable in a constant value")
+		}
+		return Variable(p.name())
+	case p.skip("["):
+		list := []Value{}
+		for !p.skip("]") {
+			list = append(list, p.value(constant))
+		}
+		return list
+	case p.skip("{"):
+		fields := []*ObjectField{}
+		for !p.skip("}") {
+			field := &ObjectField{Name: p.name()}
+			p.expect(":")
+			field.Value = p.value(constant)
+			fields = append(fields, field)
+		}
+		return fields
+	}
+	p.unexpected("a value")
+	return nil
+}
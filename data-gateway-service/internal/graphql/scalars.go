@@ -0,0 +1,190 @@
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Built-in scalars
+var (
+	String = &Type{
+		Kind: KindScalar, Name: "String",
+		Description: "UTF-8 text",
+		Serialize:   serializeString,
+		Parse:       parseString,
+	}
+	Int = &Type{
+		Kind: KindScalar, Name: "Int",
+		Description: "A signed 32-bit integer",
+		Serialize:   serializeInt,
+		Parse:       parseInt,
+	}
+	Float = &Type{
+		Kind: KindScalar, Name: "Float",
+		Description: "A double-precision number",
+		Serialize:   serializeFloat,
+		Parse:       parseFloat,
+	}
+	Boolean = &Type{
+		Kind: KindScalar, Name: "Boolean",
+		Description: "true or false",
+		Serialize:   serializeBoolean,
+		Parse:       parseBoolean,
+	}
+	ID = &Type{
+		Kind: KindScalar, Name: "ID",
+		Description: "A unique identifier, serialized as a string",
+		Serialize:   serializeString,
+		Parse:       parseID,
+	}
+)
+
+func serializeString(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case fmt.Stringer:
+		return v.String(), nil
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, json.Number:
+		return fmt.Sprint(v), nil
+	}
+	return nil, fmt.Errorf("cannot represent %T as a String", v)
+}
+
+func serializeInt(v interface{}) (interface{}, error) {
+	f, ok := number(v)
+	if !ok || f != math.Trunc(f) || f > math.MaxInt32 || f < math.MinInt32 {
+		return nil, fmt.Errorf("cannot represent %v as an Int", v)
+	}
+	return int32(f), nil
+}
+
+func serializeFloat(v interface{}) (interface{}, error) {
+	f, ok := number(v)
+	if s, isString := v.(string); isString {
+		// Decimal columns arrive as their text
+		parsed, err := strconv.ParseFloat(s, 64)
+		f, ok = parsed, err == nil
+	}
+	if !ok || math.IsInf(f, 0) || math.IsNaN(f) {
+		return nil, fmt.Errorf("cannot represent %v as a Float", v)
+	}
+	return f, nil
+}
+
+func serializeBoolean(v interface{}) (interface{}, error) {
+	if b, ok := v.(bool); ok {
+		return b, nil
+	}
+	return nil, fmt.Errorf("cannot represent %v as a Boolean", v)
+}
+
+// number converts the numeric values of rows and JSON variables to float64
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func parseString(v interface{}) (interface{}, error) {
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	return nil, fmt.Errorf("expected a String, got %s", describe(v))
+}
+
+func parseID(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return v.String(), nil
+		}
+	case float64:
+		if v == math.Trunc(v) {
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		}
+	}
+	return nil, fmt.Errorf("expected an ID, got %s", describe(v))
+}
+
+func parseInt(v interface{}) (interface{}, error) {
+	switch v.(type) {
+	case int64, float64, json.Number:
+		f, _ := number(v)
+		if f == math.Trunc(f) && f <= math.MaxInt32 && f >= math.MinInt32 {
+			return int(f), nil
+		}
+	}
+	return nil, fmt.Errorf("expected an Int, got %s", describe(v))
+}
+
+func parseFloat(v interface{}) (interface{}, error) {
+	switch v.(type) {
+	case int64, float64, json.Number:
+		f, _ := number(v)
+		return f, nil
+	}
+	return nil, fmt.Errorf("expected a Float, got %s", describe(v))
+}
+
+func parseBoolean(v interface{}) (interface{}, error) {
+	if b, ok := v.(bool); ok {
+		return b, nil
+	}
+	return nil, fmt.Errorf("expected a Boolean, got %s", describe(v))
+}
+
+// describe names an input value in an error message
+func describe(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return strconv.Quote(v)
+	case EnumValue:
+		return string(v)
+	case Null, nil:
+		return "null"
+	case []Value:
+		return "a list"
+	case []*ObjectField, map[string]interface{}:
+		return "an object"
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,196 @@
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// Kind is the kind of a type, as introspection reports it
+type Kind string
+
+// Kinds of types
+const (
+	KindScalar      Kind = "SCALAR"
+	KindObject      Kind = "OBJECT"
+	KindInputObject Kind = "INPUT_OBJECT"
+	KindEnum        Kind = "ENUM"
+	KindList        Kind = "LIST"
+	KindNonNull     Kind = "NON_NULL"
+)
+
+// Type is a type of a schema. Named types have a Name; lists and non-null
+// types wrap OfType.
+type Type struct {
+	Kind        Kind
+	Name        string
+	Description string
+
+	Fields      []*Field      // Of an object
+	InputFields []*InputValue // Of an input object
+	EnumValues  []*EnumValueDef
+
+	// Serialize converts a resolved scalar to its JSON value; nil passes it
+	// through. Parse converts a scalar input to its Go value.
+	Serialize func(interface{}) (interface{}, error)
+	Parse     func(interface{}) (interface{}, error)
+
+	OfType *Type
+}
+
+// Field is a field of an object type
+type Field struct {
+	Name        string
+	Description string
+	Args        []*InputValue
+	Type        *Type
+
+	// Resolve returns the value of the field; nil reads the field's name
+	// from a map[string]interface{} source
+	Resolve ResolveFunc
+}
+
+// InputValue is an argument or a field of an input object
+type InputValue struct {
+	Name        string
+	Description string
+	Type        *Type
+	Default     Value // A literal; nil when there is none
+}
+
+// EnumValueDef is a value of an enum type
+type EnumValueDef struct {
+	Name        string
+	Description string
+}
+
+// ResolveFunc resolves a field
+type ResolveFunc func(ctx context.Context, p ResolveParams) (interface{}, error)
+
+// ResolveParams are the inputs of a resolver
+type ResolveParams struct {
+	Source interface{}            // The value of the parent object
+	Args   map[string]interface{} // Coerced arguments, defaults applied
+
+	// Selections are the fields selected on the field's value, fragments
+	// and directives applied, so a resolver can fetch only those
+	Selections []*SelectedField
+}
+
+// SelectedField is a field selected on an object, as resolvers see it
+type SelectedField struct {
+	Name       string
+	Args       map[string]interface{}
+	Selections []*SelectedField
+}
+
+// NonNull wraps t as non-null
+func NonNull(t *Type) *Type { return &Type{Kind: KindNonNull, OfType: t} }
+
+// ListOf wraps t as a list
+func ListOf(t *Type) *Type { return &Type{Kind: KindList, OfType: t} }
+
+// Field returns the field of an object type by name
+func (t *Type) Field(name string) *Field {
+	for _, f := range t.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// String writes t as in a document, e.g. [Tender!]!
+func (t *Type) String() string {
+	switch t.Kind {
+	case KindNonNull:
+		return t.OfType.String() + "!"
+	case KindList:
+		return "[" + t.OfType.String() + "]"
+	}
+	return t.Name
+}
+
+// named returns the named type t wraps, or t itself
+func (t *Type) named() *Type {
+	for t.OfType != nil {
+		t = t.OfType
+	}
+	return t
+}
+
+// isInput reports whether t may be the type of an argument or variable
+func (t *Type) isInput() bool {
+	switch t.named().Kind {
+	case KindScalar, KindEnum, KindInputObject:
+		return true
+	}
+	return false
+}
+
+// Schema is a GraphQL schema of query operations
+type Schema struct {
+	Query *Type
+	types map[string]*Type
+}
+
+// NewSchema creates a schema with query as its root type. Every named type
+// reachable from it is registered, with the built-in scalars and the
+// introspection types; two types of the same name are an error.
+func NewSchema(query *Type) (*Schema, error) {
+	s := &Schema{Query: query, types: map[string]*Type{}}
+	for _, t := range []*Type{String, Int, Float, Boolean, ID} {
+		if err := s.register(t); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.register(query); err != nil {
+		return nil, err
+	}
+	if err := s.register(introspectionSchema); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Schema) register(t *Type) error {
+	t = t.named()
+	if existing, ok := s.types[t.Name]; ok {
+		if existing != t {
+			return fmt.Errorf("graphql: two types named %s", t.Name)
+		}
+		return nil
+	}
+	s.types[t.Name] = t
+	for _, f := range t.Fields {
+		for _, arg := range f.Args {
+			if err := s.register(arg.Type); err != nil {
+				return err
+			}
+		}
+		if err := s.register(f.Type); err != nil {
+			return err
+		}
+	}
+	for _, f := range t.InputFields {
+		if err := s.register(f.Type); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Type returns the named type of the schema, or nil
+func (s *Schema) Type(name string) *Type {
+	return s.types[name]
+}
+
+// Types returns the named types of the schema, sorted by name
+func (s *Schema) Types() []*Type {
+	types := make([]*Type, 0, len(s.types))
+	for _, t := range s.types {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].Name < types[j].Name })
+	return types
+}
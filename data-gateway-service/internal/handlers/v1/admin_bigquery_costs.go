@@ -0,0 +1,31 @@
+package v1
+
+import (
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/bqcost"
+	"go-data-gateway/internal/response"
+)
+
+// AdminBigQueryCostsHandler serves the BigQuery spend last collected for
+// /metrics as JSON
+type AdminBigQueryCostsHandler struct {
+	collector *bqcost.Collector
+	logger    *zap.Logger
+}
+
+// NewAdminBigQueryCostsHandler creates a new BigQuery costs admin handler. A
+// nil collector reports that cost metrics are disabled.
+func NewAdminBigQueryCostsHandler(collector *bqcost.Collector, logger *zap.Logger) *AdminBigQueryCostsHandler {
+	return &AdminBigQueryCostsHandler{
+		collector: collector,
+		logger:    logger,
+	}
+}
+
+// Get handles GET /api/v1/admin/bigquery/costs
+func (h *AdminBigQueryCostsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	response.Success(w, h.collector.Status(), nil)
+}
@@ -0,0 +1,209 @@
+package v1
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/cache"
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/metrics"
+	"go-data-gateway/internal/response"
+	"go-data-gateway/pkg/apitypes"
+)
+
+// Rows of a cached result returned by an inspection, by default and at most
+const (
+	defaultInspectRows = 10
+	maxInspectRows     = 100
+)
+
+// AdminCacheHandler shows support engineers the cache entry a request maps
+// to, without running anything upstream
+type AdminCacheHandler struct {
+	dataSources map[string]datasource.DataSource
+	cache       cache.Cache
+	autoLimit   autoLimit
+	tiebreakers config.Tiebreakers
+	notFound    *metrics.NotFoundCounter
+	logger      *zap.Logger
+}
+
+// NewAdminCacheHandler creates a cache admin handler over the result cache of
+// dataSources
+func NewAdminCacheHandler(dataSources map[string]datasource.DataSource, results cache.Cache, logger *zap.Logger) *AdminCacheHandler {
+	return &AdminCacheHandler{
+		dataSources: dataSources,
+		cache:       results,
+		tiebreakers: config.DefaultTiebreakers(),
+		logger:      logger,
+	}
+}
+
+// SetAutoLimit sets the LIMIT /query injects, which is part of the key of a
+// query it was injected into
+func (h *AdminCacheHandler) SetAutoLimit(limit int) {
+	h.autoLimit = autoLimit(limit)
+}
+
+// SetTiebreakers sets the tiebreakers table reads are ordered by, which are
+// part of the key of a table read
+func (h *AdminCacheHandler) SetTiebreakers(tiebreakers config.Tiebreakers) {
+	h.tiebreakers = tiebreakers
+}
+
+// SetNotFound sets the counter of the not-found markers, which counts the
+// invalidations of a table's markers
+func (h *AdminCacheHandler) SetNotFound(counter *metrics.NotFoundCounter) {
+	h.notFound = counter
+}
+
+// CacheInspectRequest is the body of POST /api/v1/admin/cache/inspect: a
+// /query body, or a table read with source, table and options
+type CacheInspectRequest struct {
+	apitypes.QueryRequest
+
+	// Table and Options describe a table read, as by the table rows
+	// endpoint, instead of a query
+	Table   string                   `json:"table,omitempty"`
+	Options *datasource.QueryOptions `json:"options,omitempty"`
+
+	// Unlimited inspects the key of a caller with the query:unlimited scope,
+	// whose query runs without the automatic LIMIT
+	Unlimited bool `json:"unlimited,omitempty"`
+	// Rows of the cached result to return; 10 by default, at most 100
+	Rows *int `json:"rows,omitempty"`
+	// Delete removes the entry after inspecting it
+	Delete bool `json:"delete,omitempty"`
+}
+
+// CacheInspection is the response of a cache inspection
+type CacheInspection struct {
+	cache.Inspection
+	Cacheable bool `json:"cacheable"` // Whether the request's results are cached at all
+	Deleted   bool `json:"deleted,omitempty"`
+}
+
+// Inspect handles POST /api/v1/admin/cache/inspect: the cache key of the
+// request, whether an entry exists, its age, TTL remaining, size and first
+// rows, and with "delete": true removes that entry
+func (h *AdminCacheHandler) Inspect(w http.ResponseWriter, r *http.Request) {
+	var req CacheInspectRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+
+	var v violations
+	switch {
+	case req.SQL == "" && req.Table == "":
+		v.add("sql", "required_without=table", "sql or table is required")
+	case req.SQL != "" && req.Table != "":
+		v.add("table", "excluded_with=sql", "sql and table are mutually exclusive")
+	case req.Table == "" && req.Options != nil:
+		v.add("options", "table", "options apply to table reads only")
+	}
+	v.source("source", string(req.Source), querySources(h.dataSources))
+	rows := defaultInspectRows
+	if req.Rows != nil {
+		rows = *req.Rows
+		if rows < 0 || rows > maxInspectRows {
+			v.add("rows", "max=100", "rows must be between 0 and %d", maxInspectRows)
+		}
+	}
+	maxAge, err := requestMaxAge(r, req.MaxAgeSeconds)
+	v.addErr(maxAgeParam, "min=0", err)
+	if v.write(w) {
+		return
+	}
+
+	_, source := findSource(h.dataSources, req.Source)
+	if source == nil {
+		response.Error(w, "Data source not available: "+string(req.Source), http.StatusServiceUnavailable)
+		return
+	}
+
+	// The options and SQL are those the query and table rows handlers would
+	// read with, so the key is the one they would use
+	var plan *datasource.QueryPlan
+	if req.Table != "" {
+		opts := &datasource.QueryOptions{}
+		if req.Options != nil {
+			opts = req.Options
+		}
+		opts.OrderDir = strings.ToUpper(opts.OrderDir)
+		opts.Tiebreaker = h.tiebreakers.For(req.Table)
+		opts.MaxAge = maxAge
+		plan, err = datasource.PlanTable(r.Context(), source, req.Table, opts)
+	} else {
+		sql := req.SQL
+		if !req.Unlimited {
+			sql, _ = h.autoLimit.inject(sql, source.GetType().Dialect())
+		}
+		opts := &datasource.QueryOptions{MaxAge: maxAge, SkipCache: len(req.EngineOptions) > 0}
+		plan, err = datasource.PlanQuery(r.Context(), source, sql, opts)
+	}
+	if err != nil {
+		h.logger.Error("Failed to plan inspected request", zap.Error(err))
+		if !writeUpstreamError(w, err, "Failed to plan request") {
+			response.ErrorWithDetails(w, "Failed to plan request", err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+	if plan.CacheKey == "" {
+		response.Success(w, CacheInspection{}, nil)
+		return
+	}
+
+	inspection, err := cache.Inspect(r.Context(), h.cache, plan.CacheKey, &datasource.QueryOptions{MaxAge: maxAge}, rows)
+	if err != nil {
+		h.logger.Error("Failed to inspect cache entry", zap.String("key", plan.CacheKey), zap.Error(err))
+		response.Error(w, "Failed to inspect cache entry", http.StatusInternalServerError)
+		return
+	}
+	result := CacheInspection{Inspection: inspection, Cacheable: true}
+
+	if req.Delete && inspection.Exists {
+		if err := h.cache.Delete(r.Context(), plan.CacheKey); err != nil {
+			h.logger.Error("Failed to delete cache entry", zap.String("key", plan.CacheKey), zap.Error(err))
+			response.Error(w, "Failed to delete cache entry", http.StatusInternalServerError)
+			return
+		}
+		result.Deleted = true
+		h.logger.Info("Cache entry deleted",
+			zap.String("key", plan.CacheKey),
+			zap.String("source", strings.ToUpper(string(req.Source))))
+	}
+	response.Success(w, result, nil)
+}
+
+// NotFoundInvalidation is the response of a not-found invalidation
+type NotFoundInvalidation struct {
+	Table       string `json:"table"`
+	Invalidated bool   `json:"invalidated"`
+}
+
+// InvalidateNotFound handles DELETE /api/v1/admin/cache/not-found?table=:
+// it drops the remembered not-found lookups of table for every tenant, so
+// rows just ingested are found by their next lookup
+func (h *AdminCacheHandler) InvalidateNotFound(w http.ResponseWriter, r *http.Request) {
+	table := r.URL.Query().Get("table")
+	var v violations
+	if !slices.Contains(notFoundTables, table) {
+		v.add("table", "oneof="+strings.Join(notFoundTables, " "), "table must be one of %s", strings.Join(notFoundTables, ", "))
+	}
+	if v.write(w) {
+		return
+	}
+
+	if err := h.cache.DeletePattern(r.Context(), cache.KeyPattern(notFoundPrefix(table))); err != nil {
+		h.logger.Error("Failed to invalidate not-found lookups", zap.String("table", table), zap.Error(err))
+		response.Error(w, "Failed to invalidate not-found lookups", http.StatusInternalServerError)
+		return
+	}
+	h.notFound.Record(table, metrics.NotFoundInvalidated)
+	h.logger.Info("Not-found lookups invalidated", zap.String("table", table))
+	response.Success(w, NotFoundInvalidation{Table: table, Invalidated: true}, nil)
+}
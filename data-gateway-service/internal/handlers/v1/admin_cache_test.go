@@ -0,0 +1,150 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/cache"
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+)
+
+func inspectCache(t *testing.T, handler *AdminCacheHandler, body string) (*httptest.ResponseRecorder, CacheInspection) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler.Inspect(rec, asAdmin(httptest.NewRequest(http.MethodPost, "/api/v1/admin/cache/inspect", bytes.NewBufferString(body))))
+
+	var inspection CacheInspection
+	if rec.Code == http.StatusOK {
+		data, err := json.Marshal(decodeResponse(t, rec).Data)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(data, &inspection))
+	}
+	return rec, inspection
+}
+
+func TestAdminCache_InspectQuery(t *testing.T) {
+	results := cache.NewMemoryCache()
+	dremio := &recordingSource{sourceType: datasource.DataSourceDremio, rows: rowsOf(20)}
+	sources := map[string]datasource.DataSource{"DATAWAREHOUSE": cache.NewCachedDataSource(dremio, results, zap.NewNop())}
+
+	query := NewQueryHandler(sources, testLimits, nil, false, zap.NewNop())
+	query.SetAutoLimit(1000)
+	admin := NewAdminCacheHandler(sources, results, zap.NewNop())
+	admin.SetAutoLimit(1000)
+
+	body := `{"sql": "SELECT * FROM t", "source": "DATAWAREHOUSE"}`
+	rec, inspection := inspectCache(t, admin, body)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.True(t, inspection.Cacheable)
+	assert.False(t, inspection.Exists)
+	assert.NotEmpty(t, inspection.Key)
+	assert.Empty(t, dremio.query, "inspecting does not execute upstream")
+
+	rec = httptest.NewRecorder()
+	query.Execute(rec, httptest.NewRequest(http.MethodPost, "/api/v1/query", bytes.NewBufferString(body)))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	// The key is the one /query cached its result under, injected LIMIT included
+	rec, inspection = inspectCache(t, admin, `{"sql": "SELECT * FROM t", "source": "DATAWAREHOUSE", "rows": 3}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.True(t, inspection.Exists)
+	assert.True(t, inspection.WouldServe)
+	assert.Equal(t, 20, inspection.Count)
+	assert.Len(t, inspection.Rows, 3)
+	assert.Positive(t, inspection.SizeBytes)
+	require.NotNil(t, inspection.AgeSeconds)
+	require.NotNil(t, inspection.TTLSeconds)
+	assert.Positive(t, *inspection.TTLSeconds)
+
+	// A caller without the automatic LIMIT reads another entry
+	_, unlimited := inspectCache(t, admin, `{"sql": "SELECT * FROM t", "source": "DATAWAREHOUSE", "unlimited": true}`)
+	assert.NotEqual(t, inspection.Key, unlimited.Key)
+	assert.False(t, unlimited.Exists)
+
+	// Inspecting counts neither a hit nor a miss
+	stats, err := results.Stats(t.Context())
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, stats["hits"])
+
+	_, deleted := inspectCache(t, admin, `{"sql": "SELECT * FROM t", "source": "DATAWAREHOUSE", "delete": true}`)
+	assert.True(t, deleted.Exists)
+	assert.True(t, deleted.Deleted)
+	_, gone := inspectCache(t, admin, body)
+	assert.False(t, gone.Exists)
+	assert.False(t, gone.Deleted)
+}
+
+func TestAdminCache_InspectTable(t *testing.T) {
+	results := cache.NewMemoryCache()
+	dremio := &recordingSource{sourceType: datasource.DataSourceDremio, rows: rowsOf(2)}
+	sources := map[string]datasource.DataSource{"DATAWAREHOUSE": cache.NewCachedDataSource(dremio, results, zap.NewNop())}
+
+	tables := NewTableHandler(sources, testLimits, config.GetDefaultSecurityConfig, zap.NewNop())
+	r := chi.NewRouter()
+	r.Get("/sources/{source}/tables/{table}/rows", tables.Rows)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet,
+		"/sources/datawarehouse/tables/nessie_iceberg.tender_data/rows?limit=20&order_by=nilai_pagu&order_dir=desc", nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	admin := NewAdminCacheHandler(sources, results, zap.NewNop())
+	rec, inspection := inspectCache(t, admin, `{"source": "DATAWAREHOUSE", "table": "nessie_iceberg.tender_data",
+		"options": {"Limit": 20, "OrderBy": "nilai_pagu", "OrderDir": "desc"}}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.True(t, inspection.Exists)
+	assert.Len(t, inspection.Rows, 2)
+
+	// A stricter max age would re-execute
+	_, inspection = inspectCache(t, admin, `{"source": "DATAWAREHOUSE", "table": "nessie_iceberg.tender_data",
+		"options": {"Limit": 20, "OrderBy": "nilai_pagu", "OrderDir": "desc"}, "max_age_seconds": 0}`)
+	assert.True(t, inspection.Exists)
+	assert.False(t, inspection.WouldServe)
+}
+
+func TestAdminCache_InspectUncacheable(t *testing.T) {
+	results := cache.NewMemoryCache()
+	dremio := &recordingSource{sourceType: datasource.DataSourceDremio}
+	sources := map[string]datasource.DataSource{"DATAWAREHOUSE": cache.NewCachedDataSource(dremio, results, zap.NewNop())}
+	admin := NewAdminCacheHandler(sources, results, zap.NewNop())
+
+	// Queries with engine options bypass the cache
+	rec, inspection := inspectCache(t, admin,
+		`{"sql": "SELECT 1", "source": "DATAWAREHOUSE", "engine_options": {"planner.slice_target": 1000}}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.False(t, inspection.Cacheable)
+	assert.Empty(t, inspection.Key)
+}
+
+func TestAdminCache_InspectValidation(t *testing.T) {
+	sources := map[string]datasource.DataSource{"DATAWAREHOUSE": &recordingSource{sourceType: datasource.DataSourceDremio}}
+	admin := NewAdminCacheHandler(sources, cache.NewMemoryCache(), zap.NewNop())
+
+	tests := map[string]struct {
+		body  string
+		field string
+	}{
+		"nothing":       {`{"source": "DATAWAREHOUSE"}`, "sql"},
+		"both":          {`{"sql": "SELECT 1", "table": "t", "source": "DATAWAREHOUSE"}`, "table"},
+		"query options": {`{"sql": "SELECT 1", "options": {"Limit": 1}, "source": "DATAWAREHOUSE"}`, "options"},
+		"source":        {`{"sql": "SELECT 1", "source": "NOPE"}`, "source"},
+		"rows":          {`{"sql": "SELECT 1", "source": "DATAWAREHOUSE", "rows": 101}`, "rows"},
+		"max age":       {`{"sql": "SELECT 1", "source": "DATAWAREHOUSE", "max_age_seconds": -1}`, maxAgeParam},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			rec, _ := inspectCache(t, admin, tt.body)
+			violations := violationsOf(t, rec)
+			require.Len(t, violations, 1)
+			assert.Equal(t, tt.field, violations[0].Field)
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/patrickmn/go-cache"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/clients"
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/response"
+)
+
+const (
+	// dremioAdminCacheTTL bounds how stale reflection and job listings may be
+	dremioAdminCacheTTL = time.Minute
+
+	defaultDremioJobLimit = 50
+	maxDremioJobLimit     = 500
+
+	// dremioCatalogCacheTTL bounds how stale the catalog tree may be
+	dremioCatalogCacheTTL = 10 * time.Minute
+)
+
+// catalogPageLimit is the page size policy of catalog children
+var catalogPageLimit = config.PageLimit{Default: 100, Max: 500}
+
+// AdminDremioHandler exposes Dremio acceleration and job history
+type AdminDremioHandler struct {
+	client  *clients.DremioClient
+	tables  []string // Whitelisted tables whose reflections and catalog paths are reported
+	cache   *cache.Cache
+	catalog *cache.Cache // Whitelisted catalog entries by path
+	logger  *zap.Logger
+}
+
+// NewAdminDremioHandler creates a new Dremio admin handler
+func NewAdminDremioHandler(client *clients.DremioClient, tables []string, logger *zap.Logger) *AdminDremioHandler {
+	return &AdminDremioHandler{
+		client:  client,
+		tables:  tables,
+		cache:   cache.New(dremioAdminCacheTTL, 2*dremioAdminCacheTTL),
+		catalog: cache.New(dremioCatalogCacheTTL, 2*dremioCatalogCacheTTL),
+		logger:  logger,
+	}
+}
+
+// TableReflections lists the reflections of one table; Error is set instead
+// when the table could not be inspected, e.g. because it was dropped
+type TableReflections struct {
+	Table       string               `json:"table"`
+	Reflections []clients.Reflection `json:"reflections"`
+	Error       string               `json:"error,omitempty"`
+}
+
+// Reflections handles GET /api/v1/admin/dremio/reflections
+func (h *AdminDremioHandler) Reflections(w http.ResponseWriter, r *http.Request) {
+	var tables []TableReflections
+	if cached, found := h.cache.Get("reflections"); found {
+		tables = cached.([]TableReflections)
+	} else {
+		tables = h.listReflections(r.Context())
+		h.cache.Set("reflections", tables, cache.DefaultExpiration)
+	}
+
+	response.Success(w, tables, &response.Meta{Total: len(tables)})
+}
+
+func (h *AdminDremioHandler) listReflections(ctx context.Context) []TableReflections {
+	tables := make([]TableReflections, 0, len(h.tables))
+	for _, table := range h.tables {
+		entry := TableReflections{Table: table, Reflections: []clients.Reflection{}}
+		reflections, err := h.client.TableReflections(ctx, table)
+		if err != nil {
+			h.logger.Warn("Failed to list Dremio reflections", zap.String("table", table), zap.Error(err))
+			entry.Error = err.Error()
+		} else {
+			entry.Reflections = reflections
+		}
+		tables = append(tables, entry)
+	}
+	return tables
+}
+
+// Jobs handles GET /api/v1/admin/dremio/jobs?limit=N
+func (h *AdminDremioHandler) Jobs(w http.ResponseWriter, r *http.Request) {
+	limit := defaultDremioJobLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 || n > maxDremioJobLimit {
+			response.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxDremioJobLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	key := fmt.Sprintf("jobs:%d", limit)
+	if cached, found := h.cache.Get(key); found {
+		jobs := cached.([]clients.DremioJob)
+		response.Success(w, jobs, &response.Meta{Total: len(jobs)})
+		return
+	}
+
+	jobs, err := h.client.RecentJobs(r.Context(), limit)
+	if err != nil {
+		h.logger.Error("Failed to list Dremio jobs", zap.Error(err))
+		response.ErrorWithDetails(w, "Failed to list Dremio jobs", err.Error(), http.StatusBadGateway)
+		return
+	}
+	h.cache.Set(key, jobs, cache.DefaultExpiration)
+
+	response.Success(w, jobs, &response.Meta{Total: len(jobs)})
+}
+
+// Catalog handles GET /api/v1/admin/dremio/catalog and
+// /api/v1/admin/dremio/catalog/{path}, where path is slash-separated, e.g.
+// nessie_iceberg/tender_data. Only entries on the way to a whitelisted table
+// are visible; others are reported as not found. Children are paged with
+// limit and offset.
+func (h *AdminDremioHandler) Catalog(w http.ResponseWriter, r *http.Request) {
+	limit, ok := queryLimit(w, r, catalogPageLimit)
+	if !ok {
+		return
+	}
+	offset := 0
+	if raw := r.URL.Query().Get("offset"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			response.Error(w, "offset must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		offset = n
+	}
+
+	var path []string
+	if raw := strings.Trim(chi.URLParam(r, "*"), "/"); raw != "" {
+		path = strings.Split(raw, "/")
+	}
+	if !h.catalogVisible(path) {
+		response.Error(w, "Catalog path not found", http.StatusNotFound)
+		return
+	}
+
+	entry, err := h.catalogEntry(r.Context(), path)
+	var dremioErr *clients.DremioError
+	if errors.As(err, &dremioErr) && dremioErr.StatusCode == http.StatusNotFound {
+		response.Error(w, "Catalog path not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to read Dremio catalog", zap.Strings("path", path), zap.Error(err))
+		response.ErrorWithDetails(w, "Failed to read Dremio catalog", err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	page := *entry
+	total := len(entry.Children)
+	page.Children = entry.Children[min(offset, total):min(offset+limit, total)]
+	response.Success(w, page, &response.Meta{Total: total, Limit: limit})
+}
+
+// catalogEntry returns the entry at path with its children filtered by the
+// whitelist, from the cache when possible
+func (h *AdminDremioHandler) catalogEntry(ctx context.Context, path []string) (*clients.CatalogEntry, error) {
+	key := strings.Join(path, "/")
+	if cached, found := h.catalog.Get(key); found {
+		return cached.(*clients.CatalogEntry), nil
+	}
+
+	entry, err := h.client.Catalog(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	children := make([]clients.CatalogEntry, 0, len(entry.Children))
+	for _, child := range entry.Children {
+		if h.catalogVisible(child.Path) {
+			children = append(children, child)
+		}
+	}
+	entry.Children = children
+
+	h.catalog.Set(key, entry, cache.DefaultExpiration)
+	return entry, nil
+}
+
+// catalogVisible reports whether path is a whitelisted table or one of the
+// containers leading to it. Dremio paths are case-insensitive.
+func (h *AdminDremioHandler) catalogVisible(path []string) bool {
+	for _, table := range h.tables {
+		segments := strings.Split(table, ".")
+		if len(path) > len(segments) {
+			continue
+		}
+		visible := true
+		for i, segment := range path {
+			if !strings.EqualFold(segment, segments[i]) {
+				visible = false
+				break
+			}
+		}
+		if visible {
+			return true
+		}
+	}
+	return false
+}
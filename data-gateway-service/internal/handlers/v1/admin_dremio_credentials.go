@@ -0,0 +1,101 @@
+package v1
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/clients"
+	"go-data-gateway/internal/response"
+)
+
+// CredentialRotation rotates the Dremio service account's credentials in
+// every client using them, returning the data sources rotated
+type CredentialRotation func(ctx context.Context, creds clients.DremioCredentials) ([]string, error)
+
+// AdminDremioCredentialsHandler rotates the Dremio service account's
+// credentials without a restart
+type AdminDremioCredentialsHandler struct {
+	rotate CredentialRotation
+	logger *zap.Logger
+}
+
+// NewAdminDremioCredentialsHandler creates a Dremio credentials admin handler
+func NewAdminDremioCredentialsHandler(rotate CredentialRotation, logger *zap.Logger) *AdminDremioCredentialsHandler {
+	return &AdminDremioCredentialsHandler{
+		rotate: rotate,
+		logger: logger,
+	}
+}
+
+// DremioCredentialsRequest is the body of POST
+// /api/v1/admin/dremio/credentials: a username and password, or a token
+type DremioCredentialsRequest struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Token    string `json:"token,omitempty"`
+}
+
+// DremioCredentialsRotation is the response of a rotation
+type DremioCredentialsRotation struct {
+	Sources   []string  `json:"sources"` // Data sources now authenticating with the new credentials
+	RotatedAt time.Time `json:"rotated_at"`
+}
+
+// Rotate handles POST /api/v1/admin/dremio/credentials. The credentials are
+// checked against Dremio first; ones it refuses change nothing. Connections
+// opened with the old credentials finish their queries before they close.
+// Requests not made over TLS, directly or through a proxy setting
+// X-Forwarded-Proto, are refused before their body is read.
+func (h *AdminDremioCredentialsHandler) Rotate(w http.ResponseWriter, r *http.Request) {
+	if !overTLS(r) {
+		response.Error(w, "Credentials are accepted over TLS only", http.StatusForbidden)
+		return
+	}
+
+	var req DremioCredentialsRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+
+	var v violations
+	switch {
+	case req.Token != "" && (req.Username != "" || req.Password != ""):
+		v.add("token", "excluded_with=username", "token and username/password are mutually exclusive")
+	case req.Token == "" && req.Username == "":
+		v.add("username", "required_without=token", "username or token is required")
+	case req.Username != "" && req.Password == "":
+		v.required("password")
+	case req.Username == "" && req.Password != "":
+		v.required("username")
+	}
+	if v.write(w) {
+		return
+	}
+
+	// The values are never logged; the username is not a secret
+	creds := clients.DremioCredentials{Username: req.Username, Password: req.Password, Token: req.Token}
+	sources, err := h.rotate(r.Context(), creds)
+	if err != nil {
+		h.logger.Error("Dremio credential rotation failed", zap.String("user", req.Username), zap.Error(err))
+		response.ErrorWithDetails(w, "Failed to rotate Dremio credentials", err.Error(), http.StatusBadGateway)
+		return
+	}
+	if sources == nil {
+		sources = []string{}
+	}
+
+	h.logger.Info("Dremio credentials rotated",
+		zap.String("user", req.Username),
+		zap.Strings("sources", sources))
+	response.Success(w, DremioCredentialsRotation{Sources: sources, RotatedAt: time.Now().UTC()}, nil)
+}
+
+// overTLS reports whether r reached the gateway over TLS, or reached the
+// proxy in front of it over TLS
+func overTLS(r *http.Request) bool {
+	return r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
@@ -0,0 +1,91 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/clients"
+)
+
+func rotateCredentials(handler *AdminDremioCredentialsHandler, body string, secure bool) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/dremio/credentials", bytes.NewBufferString(body))
+	if secure {
+		req.TLS = &tls.ConnectionState{}
+	}
+	rec := httptest.NewRecorder()
+	handler.Rotate(rec, asAdmin(req))
+	return rec
+}
+
+func TestAdminDremioCredentials_Rotate(t *testing.T) {
+	var rotated []clients.DremioCredentials
+	handler := NewAdminDremioCredentialsHandler(func(ctx context.Context, creds clients.DremioCredentials) ([]string, error) {
+		rotated = append(rotated, creds)
+		return []string{"default/DATAWAREHOUSE"}, nil
+	}, zap.NewNop())
+
+	rec := rotateCredentials(handler, `{"username": "svc", "password": "rotated"}`, true)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, []clients.DremioCredentials{{Username: "svc", Password: "rotated"}}, rotated)
+	data := decodeResponse(t, rec).Data.(map[string]interface{})
+	assert.Equal(t, []interface{}{"default/DATAWAREHOUSE"}, data["sources"])
+
+	// Behind a proxy terminating TLS
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/dremio/credentials", bytes.NewBufferString(`{"token": "pat"}`))
+	req.Header.Set("X-Forwarded-Proto", "https")
+	rec = httptest.NewRecorder()
+	handler.Rotate(rec, asAdmin(req))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, clients.DremioCredentials{Token: "pat"}, rotated[1])
+}
+
+func TestAdminDremioCredentials_RequiresTLS(t *testing.T) {
+	handler := NewAdminDremioCredentialsHandler(func(ctx context.Context, creds clients.DremioCredentials) ([]string, error) {
+		t.Fatal("credentials sent in clear text are not rotated")
+		return nil, nil
+	}, zap.NewNop())
+
+	rec := rotateCredentials(handler, `{"username": "svc", "password": "rotated"}`, false)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestAdminDremioCredentials_Refused(t *testing.T) {
+	handler := NewAdminDremioCredentialsHandler(func(ctx context.Context, creds clients.DremioCredentials) ([]string, error) {
+		return nil, errors.New("dremio authentication failed: authentication failed with status: 401")
+	}, zap.NewNop())
+
+	rec := rotateCredentials(handler, `{"username": "svc", "password": "wrong"}`, true)
+	assert.Equal(t, http.StatusBadGateway, rec.Code)
+}
+
+func TestAdminDremioCredentials_Validation(t *testing.T) {
+	handler := NewAdminDremioCredentialsHandler(func(ctx context.Context, creds clients.DremioCredentials) ([]string, error) {
+		return nil, nil
+	}, zap.NewNop())
+
+	tests := map[string]struct {
+		body  string
+		field string
+	}{
+		"nothing":      {`{}`, "username"},
+		"no password":  {`{"username": "svc"}`, "password"},
+		"no username":  {`{"password": "p"}`, "username"},
+		"token and pw": {`{"username": "svc", "password": "p", "token": "pat"}`, "token"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			violations := violationsOf(t, rotateCredentials(handler, tt.body, true))
+			require.Len(t, violations, 1)
+			assert.Equal(t, tt.field, violations[0].Field)
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/clients"
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/response"
+)
+
+// fakeDremioREST serves the catalog, reflection and SQL endpoints used by the
+// admin handler and counts the requests it receives. The nessie_iceberg
+// folder is served in two pages.
+func fakeDremioREST(t *testing.T, requests *int32) *clients.DremioClient {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		switch {
+		case r.URL.Path == "/api/v3/catalog/by-path/nessie_iceberg/tender_data":
+			fmt.Fprint(w, `{"entityType":"dataset","id":"ds-1","path":["nessie_iceberg","tender_data"],"fields":[
+				{"name":"tender_id","type":{"name":"VARCHAR"}},
+				{"name":"nilai_pagu","type":{"name":"DOUBLE"}},
+				{"name":"tanggal_buat_paket","type":{"name":"TIMESTAMP"}}]}`)
+		case r.URL.Path == "/api/v3/catalog":
+			fmt.Fprint(w, `{"data":[
+				{"id":"s-1","path":["nessie_iceberg"],"type":"CONTAINER","containerType":"SOURCE"},
+				{"id":"s-2","path":["procurement"],"type":"CONTAINER","containerType":"SPACE"},
+				{"id":"s-3","path":["hr"],"type":"CONTAINER","containerType":"SPACE"}]}`)
+		case r.URL.Path == "/api/v3/catalog/by-path/nessie_iceberg" && r.URL.Query().Get("pageToken") == "":
+			fmt.Fprint(w, `{"entityType":"source","id":"s-1","path":["nessie_iceberg"],"children":[
+				{"id":"ds-1","path":["nessie_iceberg","tender_data"],"type":"DATASET","datasetType":"PROMOTED"},
+				{"id":"ds-9","path":["nessie_iceberg","salaries"],"type":"DATASET","datasetType":"PROMOTED"}],
+				"nextPageToken":"page-2"}`)
+		case r.URL.Path == "/api/v3/catalog/by-path/nessie_iceberg" && r.URL.Query().Get("pageToken") == "page-2":
+			fmt.Fprint(w, `{"entityType":"source","id":"s-1","path":["nessie_iceberg"],"children":[
+				{"id":"ds-2","path":["nessie_iceberg","tender_2024"],"type":"DATASET","datasetType":"PROMOTED"},
+				{"id":"f-1","path":["nessie_iceberg","staging"],"type":"CONTAINER","containerType":"FOLDER"},
+				{"id":"ds-3","path":["nessie_iceberg","tender_2025"],"type":"DATASET","datasetType":"PROMOTED"}]}`)
+		case strings.HasPrefix(r.URL.Path, "/api/v3/catalog/by-path/"):
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"errorMessage":"Could not find entity"}`)
+		case r.URL.Path == "/api/v3/dataset/ds-1/reflection":
+			fmt.Fprint(w, `{"data":[{"id":"r-1","name":"raw_tender","type":"RAW","enabled":true,
+				"currentSizeBytes":1024,"totalSizeBytes":4096,
+				"status":{"refresh":"SCHEDULED","availability":"EXPIRED","combinedStatus":"EXPIRED",
+				"failureCount":0,"lastDataFetch":"2025-03-01T02:00:00Z","expiresAt":"2025-03-02T02:00:00Z"}}]}`)
+		case r.URL.Path == "/api/v3/sql":
+			fmt.Fprint(w, `{"id":"job-1"}`)
+		case r.URL.Path == "/api/v3/job/job-1/results":
+			fmt.Fprint(w, `{"rowCount":1,"rows":[{"job_id":"abc","status":"COMPLETED","query_type":"JDBC",
+				"user_name":"gateway","submitted_ts":"2025-03-04 10:00:00.000","duration_ms":1530,
+				"rows_returned":25,"queried_datasets":"[nessie_iceberg.tender_data]","error_msg":""}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	host, portStr, _ := net.SplitHostPort(strings.TrimPrefix(srv.URL, "http://"))
+	port, _ := strconv.Atoi(portStr)
+	client, err := clients.NewDremioClient(config.DremioConfig{Host: host, Port: port, Token: "test"}, zap.NewNop())
+	require.NoError(t, err)
+	return client
+}
+
+func TestAdminDremio_Reflections(t *testing.T) {
+	var requests int32
+	handler := NewAdminDremioHandler(fakeDremioREST(t, &requests),
+		[]string{"nessie_iceberg.tender_data", "nessie_iceberg.dropped"}, zap.NewNop())
+
+	get := func() []TableReflections {
+		rec := httptest.NewRecorder()
+		handler.Reflections(rec, httptest.NewRequest(http.MethodGet, "/api/v1/admin/dremio/reflections", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var body struct {
+			Data []TableReflections `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		return body.Data
+	}
+
+	tables := get()
+	require.Len(t, tables, 2)
+
+	require.Len(t, tables[0].Reflections, 1)
+	reflection := tables[0].Reflections[0]
+	assert.Equal(t, "EXPIRED", reflection.Status)
+	assert.Equal(t, int64(4096), reflection.TotalSizeBytes)
+	require.NotNil(t, reflection.LastRefresh)
+	assert.Equal(t, 2025, reflection.LastRefresh.Year())
+
+	assert.Empty(t, tables[1].Reflections)
+	assert.Contains(t, tables[1].Error, "Could not find entity")
+
+	// The second call is served from the one-minute cache
+	before := atomic.LoadInt32(&requests)
+	get()
+	assert.Equal(t, before, atomic.LoadInt32(&requests))
+}
+
+func TestAdminDremio_Jobs(t *testing.T) {
+	var requests int32
+	handler := NewAdminDremioHandler(fakeDremioREST(t, &requests), nil, zap.NewNop())
+
+	rec := httptest.NewRecorder()
+	handler.Jobs(rec, httptest.NewRequest(http.MethodGet, "/api/v1/admin/dremio/jobs?limit=10", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var body struct {
+		Data []clients.DremioJob `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.Len(t, body.Data, 1)
+	assert.Equal(t, "COMPLETED", body.Data[0].State)
+	assert.Equal(t, int64(1530), body.Data[0].DurationMillis)
+	assert.Equal(t, []string{"nessie_iceberg.tender_data"}, body.Data[0].QueriedTables)
+
+	for _, limit := range []string{"0", "abc", "501"} {
+		rec := httptest.NewRecorder()
+		handler.Jobs(rec, httptest.NewRequest(http.MethodGet, "/api/v1/admin/dremio/jobs?limit="+limit, nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, limit)
+	}
+}
+
+// getCatalog requests a catalog path through a chi router, as in main
+func getCatalog(t *testing.T, handler *AdminDremioHandler, target string) (*httptest.ResponseRecorder, clients.CatalogEntry, response.Meta) {
+	router := chi.NewRouter()
+	router.Get("/catalog", handler.Catalog)
+	router.Get("/catalog/*", handler.Catalog)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+
+	var body struct {
+		Data clients.CatalogEntry `json:"data"`
+		Meta response.Meta        `json:"meta"`
+	}
+	if rec.Code == http.StatusOK {
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	}
+	return rec, body.Data, body.Meta
+}
+
+func childPaths(entry clients.CatalogEntry) []string {
+	paths := make([]string, len(entry.Children))
+	for i, child := range entry.Children {
+		paths[i] = strings.Join(child.Path, ".") + ":" + child.Type
+	}
+	return paths
+}
+
+func TestAdminDremio_CatalogFiltersByWhitelist(t *testing.T) {
+	var requests int32
+	tables := []string{"nessie_iceberg.tender_data", "nessie_iceberg.tender_2024", "nessie_iceberg.tender_2025", "procurement.vendor_list"}
+	handler := NewAdminDremioHandler(fakeDremioREST(t, &requests), tables, zap.NewNop())
+
+	rec, root, _ := getCatalog(t, handler, "/catalog")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "root", root.Type)
+	assert.Equal(t, []string{"nessie_iceberg:source", "procurement:space"}, childPaths(root))
+
+	// Both pages of the folder are read; tables off the whitelist are dropped
+	rec, folder, meta := getCatalog(t, handler, "/catalog/nessie_iceberg")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "source", folder.Type)
+	assert.Equal(t, []string{"nessie_iceberg.tender_data:dataset", "nessie_iceberg.tender_2024:dataset",
+		"nessie_iceberg.tender_2025:dataset"}, childPaths(folder))
+	assert.Equal(t, 3, meta.Total)
+
+	rec, dataset, _ := getCatalog(t, handler, "/catalog/nessie_iceberg/tender_data")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "dataset", dataset.Type)
+	assert.Equal(t, []string{"nessie_iceberg", "tender_data"}, dataset.Path)
+	require.Len(t, dataset.Fields, 3)
+	assert.Equal(t, clients.CatalogField{Name: "nilai_pagu", Type: "DOUBLE"}, dataset.Fields[1])
+
+	// Entries are cached for ten minutes
+	before := atomic.LoadInt32(&requests)
+	getCatalog(t, handler, "/catalog/nessie_iceberg")
+	getCatalog(t, handler, "/catalog")
+	assert.Equal(t, before, atomic.LoadInt32(&requests))
+}
+
+func TestAdminDremio_CatalogPagination(t *testing.T) {
+	var requests int32
+	tables := []string{"nessie_iceberg.tender_data", "nessie_iceberg.tender_2024", "nessie_iceberg.tender_2025"}
+	handler := NewAdminDremioHandler(fakeDremioREST(t, &requests), tables, zap.NewNop())
+
+	rec, page, meta := getCatalog(t, handler, "/catalog/nessie_iceberg?limit=2&offset=1")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, []string{"nessie_iceberg.tender_2024:dataset", "nessie_iceberg.tender_2025:dataset"}, childPaths(page))
+	assert.Equal(t, 3, meta.Total)
+	assert.Equal(t, 2, meta.Limit)
+
+	rec, page, _ = getCatalog(t, handler, "/catalog/nessie_iceberg?offset=10")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, page.Children)
+
+	for _, query := range []string{"limit=-1", "limit=501", "offset=-1", "offset=x"} {
+		rec, _, _ := getCatalog(t, handler, "/catalog/nessie_iceberg?"+query)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+}
+
+func TestAdminDremio_CatalogNotFound(t *testing.T) {
+	var requests int32
+	handler := NewAdminDremioHandler(fakeDremioREST(t, &requests),
+		[]string{"nessie_iceberg.tender_data", "nessie_iceberg.dropped"}, zap.NewNop())
+
+	// Off the whitelist: hidden without asking Dremio
+	before := atomic.LoadInt32(&requests)
+	for _, path := range []string{"/catalog/hr", "/catalog/nessie_iceberg/salaries", "/catalog/nessie_iceberg/tender_data/extra"} {
+		rec, _, _ := getCatalog(t, handler, path)
+		assert.Equal(t, http.StatusNotFound, rec.Code, path)
+	}
+	assert.Equal(t, before, atomic.LoadInt32(&requests))
+
+	// Whitelisted but unknown to Dremio
+	rec, _, _ := getCatalog(t, handler, "/catalog/nessie_iceberg/dropped")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
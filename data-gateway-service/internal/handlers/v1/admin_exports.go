@@ -0,0 +1,87 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/export"
+	"go-data-gateway/internal/response"
+)
+
+// AdminExportHandler reports on and triggers scheduled exports
+type AdminExportHandler struct {
+	scheduler *export.Scheduler
+	logger    *zap.Logger
+}
+
+// NewAdminExportHandler creates a new export admin handler
+func NewAdminExportHandler(scheduler *export.Scheduler, logger *zap.Logger) *AdminExportHandler {
+	return &AdminExportHandler{
+		scheduler: scheduler,
+		logger:    logger,
+	}
+}
+
+// TriggerExportRequest selects the export to run now
+type TriggerExportRequest struct {
+	Name string `json:"name"`
+}
+
+// List handles GET /api/v1/admin/exports
+func (h *AdminExportHandler) List(w http.ResponseWriter, r *http.Request) {
+	statuses := h.scheduler.Status()
+	response.Success(w, statuses, &response.Meta{Total: len(statuses)})
+}
+
+// Trigger handles POST /api/v1/admin/exports
+func (h *AdminExportHandler) Trigger(w http.ResponseWriter, r *http.Request) {
+	var req TriggerExportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == "" {
+		response.Error(w, "Request body must name the export to run", http.StatusBadRequest)
+		return
+	}
+
+	run, err := h.scheduler.Trigger(req.Name)
+	switch {
+	case errors.Is(err, export.ErrExportNotFound):
+		response.Error(w, "Export not found", http.StatusNotFound)
+		return
+	case errors.Is(err, export.ErrExportRunning):
+		response.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		h.logger.Error("Failed to trigger export", zap.String("export", req.Name), zap.Error(err))
+		response.Error(w, "Failed to trigger export", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("Export triggered", zap.String("export", req.Name), zap.String("run_id", run.ID))
+	w.Header().Set("Location", "/api/v1/admin/exports/"+req.Name+"/runs/"+run.ID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(response.StandardResponse{Success: true, Data: run})
+}
+
+// Runs handles GET /api/v1/admin/exports/{name}/runs
+func (h *AdminExportHandler) Runs(w http.ResponseWriter, r *http.Request) {
+	runs, err := h.scheduler.Runs(chi.URLParam(r, "name"))
+	if err != nil {
+		response.Error(w, "Export not found", http.StatusNotFound)
+		return
+	}
+	response.Success(w, runs, &response.Meta{Total: len(runs)})
+}
+
+// Run handles GET /api/v1/admin/exports/{name}/runs/{runID} and includes the run log
+func (h *AdminExportHandler) Run(w http.ResponseWriter, r *http.Request) {
+	run, ok := h.scheduler.Run(chi.URLParam(r, "name"), chi.URLParam(r, "runID"))
+	if !ok {
+		response.Error(w, "Export run not found", http.StatusNotFound)
+		return
+	}
+	response.Success(w, run, nil)
+}
@@ -0,0 +1,111 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/killswitch"
+	"go-data-gateway/internal/response"
+)
+
+// AdminFlagHandler switches API routes and data sources off and on
+type AdminFlagHandler struct {
+	killSwitch  *killswitch.Switch
+	dataSources map[string]datasource.DataSource
+	logger      *zap.Logger
+}
+
+// NewAdminFlagHandler creates a new flag admin handler. Source flags are
+// only accepted for the names in dataSources.
+func NewAdminFlagHandler(sw *killswitch.Switch, dataSources map[string]datasource.DataSource, logger *zap.Logger) *AdminFlagHandler {
+	return &AdminFlagHandler{
+		killSwitch:  sw,
+		dataSources: dataSources,
+		logger:      logger,
+	}
+}
+
+// SetFlagRequest is the body of PUT /api/v1/admin/flags
+type SetFlagRequest struct {
+	Kind              killswitch.Kind `json:"kind"` // route or source
+	Name              string          `json:"name"` // An API path such as /api/v1/tender, or a data source
+	Disabled          bool            `json:"disabled"`
+	Message           string          `json:"message,omitempty"`
+	RetryAfterSeconds int             `json:"retry_after_seconds,omitempty"`
+}
+
+// FlagState is the body of the flag admin endpoints
+type FlagState struct {
+	Shared bool              `json:"shared"` // Overrides reach every replica
+	Flags  []killswitch.Flag `json:"flags"`
+}
+
+// Get handles GET /api/v1/admin/flags
+func (h *AdminFlagHandler) Get(w http.ResponseWriter, r *http.Request) {
+	response.Success(w, h.state(), nil)
+}
+
+// Set handles PUT /api/v1/admin/flags
+func (h *AdminFlagHandler) Set(w http.ResponseWriter, r *http.Request) {
+	var req SetFlagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := h.validate(req.Kind, req.Name); err != nil {
+		response.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.RetryAfterSeconds < 0 {
+		response.Error(w, "retry_after_seconds must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	err := h.killSwitch.Set(r.Context(), req.Kind, req.Name, killswitch.Override{
+		Disabled:          req.Disabled,
+		Message:           req.Message,
+		RetryAfterSeconds: req.RetryAfterSeconds,
+	})
+	if err != nil {
+		h.logger.Error("Failed to set flag", zap.Error(err))
+		response.ErrorWithDetails(w, "Failed to set flag", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	response.Success(w, h.state(), nil)
+}
+
+// Clear handles DELETE /api/v1/admin/flags?kind=&name=, returning the route
+// or source to the state the policy gives it
+func (h *AdminFlagHandler) Clear(w http.ResponseWriter, r *http.Request) {
+	kind, name := killswitch.Kind(r.URL.Query().Get("kind")), r.URL.Query().Get("name")
+	if err := h.validate(kind, name); err != nil {
+		response.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.killSwitch.Clear(r.Context(), kind, name); err != nil {
+		h.logger.Error("Failed to clear flag", zap.Error(err))
+		response.ErrorWithDetails(w, "Failed to clear flag", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	response.Success(w, h.state(), nil)
+}
+
+// validate rejects invalid flags and flags of unknown data sources
+func (h *AdminFlagHandler) validate(kind killswitch.Kind, name string) error {
+	if err := killswitch.Validate(kind, name); err != nil {
+		return err
+	}
+	if _, ok := h.dataSources[name]; kind == killswitch.KindSource && !ok {
+		return fmt.Errorf("unknown data source %q", name)
+	}
+	return nil
+}
+
+func (h *AdminFlagHandler) state() FlagState {
+	return FlagState{Shared: h.killSwitch.Shared(), Flags: h.killSwitch.State()}
+}
@@ -0,0 +1,55 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/killswitch"
+)
+
+func TestAdminFlags_SetAndClear(t *testing.T) {
+	sw := killswitch.New(killswitch.NewMemoryStore(), func() config.FlagPolicy { return config.FlagPolicy{} }, time.Minute, zap.NewNop())
+	handler := NewAdminFlagHandler(sw, map[string]datasource.DataSource{"BIGQUERY": &recordingSource{}}, zap.NewNop())
+
+	put := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.Set(rec, asAdmin(httptest.NewRequest(http.MethodPut, "/api/v1/admin/flags", strings.NewReader(body))))
+		return rec
+	}
+
+	for _, body := range []string{
+		`{`,
+		`{"kind": "table", "name": "tender"}`,
+		`{"kind": "source", "name": "DATAWAREHOUSE", "disabled": true}`,
+		`{"kind": "route", "name": "/api/v1/admin", "disabled": true}`,
+		`{"kind": "route", "name": "/api/v1/rup", "disabled": true, "retry_after_seconds": -5}`,
+	} {
+		assert.Equal(t, http.StatusBadRequest, put(body).Code, body)
+	}
+
+	rec := put(`{"kind": "source", "name": "BIGQUERY", "disabled": true, "message": "Quota exhausted", "retry_after_seconds": 600}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var body struct {
+		Data FlagState `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.Len(t, body.Data.Flags, 1)
+	assert.Equal(t, "Quota exhausted", body.Data.Flags[0].Message)
+	assert.Equal(t, killswitch.OriginAdmin, body.Data.Flags[0].Origin)
+	assert.Error(t, sw.Source("BIGQUERY"))
+
+	rec = httptest.NewRecorder()
+	handler.Clear(rec, asAdmin(httptest.NewRequest(http.MethodDelete, "/api/v1/admin/flags?kind=source&name=BIGQUERY", nil)))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.NoError(t, sw.Source("BIGQUERY"))
+}
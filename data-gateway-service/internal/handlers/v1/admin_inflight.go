@@ -0,0 +1,53 @@
+package v1
+
+import (
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/inflight"
+	"go-data-gateway/internal/logging"
+	"go-data-gateway/internal/response"
+)
+
+// AdminInflightHandler lists the queries, batches and streams running on
+// this replica
+type AdminInflightHandler struct {
+	registry  *inflight.Registry
+	redactSQL bool // Replace literals in listed SQL, as in the logs
+	logger    *zap.Logger
+}
+
+// NewAdminInflightHandler creates a new in-flight operations admin handler.
+// With redactSQL, SQL literals are listed as placeholders like LOG_REDACT_SQL
+// logs them.
+func NewAdminInflightHandler(registry *inflight.Registry, redactSQL bool, logger *zap.Logger) *AdminInflightHandler {
+	return &AdminInflightHandler{
+		registry:  registry,
+		redactSQL: redactSQL,
+		logger:    logger,
+	}
+}
+
+// List handles GET /api/v1/admin/inflight, oldest operation first.
+// ?min_age=30s lists only operations running for at least that long.
+func (h *AdminInflightHandler) List(w http.ResponseWriter, r *http.Request) {
+	var minAge time.Duration
+	if raw := r.URL.Query().Get("min_age"); raw != "" {
+		var err error
+		minAge, err = time.ParseDuration(raw)
+		if err != nil || minAge < 0 {
+			response.ErrorWithDetails(w, "Invalid min_age", "min_age must be a non-negative duration such as 30s", http.StatusBadRequest)
+			return
+		}
+	}
+
+	entries := h.registry.List(minAge)
+	if h.redactSQL {
+		for i := range entries {
+			entries[i].SQL = logging.RedactSQL(entries[i].SQL)
+		}
+	}
+	response.Success(w, entries, &response.Meta{Total: len(entries)})
+}
@@ -0,0 +1,65 @@
+package v1
+
+import (
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/buildinfo"
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/killswitch"
+	"go-data-gateway/internal/response"
+)
+
+// AdminInfoHandler reports which build of the gateway is running, since
+// when, and how it is configured
+type AdminInfoHandler struct {
+	environment string
+	fingerprint string
+	features    map[string]bool
+	killSwitch  *killswitch.Switch
+	logger      *zap.Logger
+}
+
+// NewAdminInfoHandler creates a new info admin handler for the gateway
+// configured by cfg
+func NewAdminInfoHandler(cfg *config.Config, logger *zap.Logger) *AdminInfoHandler {
+	return &AdminInfoHandler{
+		environment: cfg.Environment,
+		fingerprint: cfg.Fingerprint(),
+		features:    cfg.Features(),
+		logger:      logger,
+	}
+}
+
+// SetKillSwitch reports the routes and sources flagged in sw
+func (h *AdminInfoHandler) SetKillSwitch(sw *killswitch.Switch) {
+	h.killSwitch = sw
+}
+
+// GatewayInfo is the body of GET /api/v1/admin/info
+type GatewayInfo struct {
+	buildinfo.Info
+	StartedAt         time.Time         `json:"started_at"`
+	UptimeSeconds     int64             `json:"uptime_seconds"`
+	Environment       string            `json:"environment"`
+	ConfigFingerprint string            `json:"config_fingerprint"` // Hash of the configuration without its secrets
+	PolicyVersion     string            `json:"policy_version"`
+	Features          map[string]bool   `json:"features"`
+	Flags             []killswitch.Flag `json:"flags"` // Routes and sources switched off, or back on by an operator
+}
+
+// Get handles GET /api/v1/admin/info
+func (h *AdminInfoHandler) Get(w http.ResponseWriter, r *http.Request) {
+	response.Success(w, GatewayInfo{
+		Info:              buildinfo.Get(),
+		StartedAt:         buildinfo.StartedAt(),
+		UptimeSeconds:     int64(buildinfo.Uptime().Seconds()),
+		Environment:       h.environment,
+		ConfigFingerprint: h.fingerprint,
+		PolicyVersion:     config.ActivePolicy().Version,
+		Features:          h.features,
+		Flags:             h.killSwitch.State(),
+	}, nil)
+}
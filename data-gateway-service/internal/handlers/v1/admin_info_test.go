@@ -0,0 +1,38 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/config"
+)
+
+func TestAdminInfo_Get(t *testing.T) {
+	cfg := &config.Config{Environment: "staging", Redis: config.RedisConfig{Password: "secret", TLS: true}}
+	handler := NewAdminInfoHandler(cfg, zap.NewNop())
+
+	rec := httptest.NewRecorder()
+	handler.Get(rec, asAdmin(httptest.NewRequest(http.MethodGet, "/api/v1/admin/info", nil)))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "secret")
+
+	var body struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, "dev", body.Data["version"])
+	assert.NotEmpty(t, body.Data["commit"])
+	assert.NotEmpty(t, body.Data["go_version"])
+	assert.Equal(t, "staging", body.Data["environment"])
+	assert.Equal(t, cfg.Fingerprint(), body.Data["config_fingerprint"])
+	assert.Equal(t, config.ActivePolicy().Version, body.Data["policy_version"])
+	assert.Contains(t, body.Data, "uptime_seconds")
+	assert.Contains(t, body.Data, "started_at")
+	assert.Equal(t, true, body.Data["features"].(map[string]interface{})["redis_tls"])
+}
@@ -0,0 +1,107 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/auth"
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/response"
+)
+
+// AdminKeyHandler manages API keys at runtime
+type AdminKeyHandler struct {
+	store  *auth.KeyStore
+	nessie config.NessieConfig
+	logger *zap.Logger
+}
+
+// NewAdminKeyHandler creates a new API key admin handler
+func NewAdminKeyHandler(store *auth.KeyStore, logger *zap.Logger) *AdminKeyHandler {
+	return &AdminKeyHandler{
+		store:  store,
+		logger: logger,
+	}
+}
+
+// SetNessie sets the Nessie branches and tags keys may read by default
+func (h *AdminKeyHandler) SetNessie(nessie config.NessieConfig) {
+	h.nessie = nessie
+}
+
+// CreateKeyResponse is returned once when a key is created; the plaintext is never stored
+type CreateKeyResponse struct {
+	Key    string       `json:"key"`
+	APIKey *auth.APIKey `json:"api_key"`
+}
+
+// List handles GET /api/v1/admin/keys
+func (h *AdminKeyHandler) List(w http.ResponseWriter, r *http.Request) {
+	keys := h.store.List(r.Context())
+	response.Success(w, keys, &response.Meta{Total: len(keys)})
+}
+
+// Create handles POST /api/v1/admin/keys
+func (h *AdminKeyHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req auth.CreateKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.RateLimit < 0 {
+		response.Error(w, "rate_limit must not be negative", http.StatusBadRequest)
+		return
+	}
+	if req.Branch != "" {
+		if _, err := datasource.ResolveVersion(h.nessie, req.Branch); err != nil {
+			response.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	plaintext, key, err := h.store.Create(r.Context(), req)
+	if err != nil {
+		h.logger.Error("Failed to create API key", zap.Error(err))
+		response.ErrorWithDetails(w, "Failed to create API key", err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	h.logger.Info("API key created",
+		zap.String("key_id", key.ID),
+		zap.Strings("scopes", key.Scopes))
+
+	w.Header().Set("Cache-Control", "no-store")
+	response.Success(w, CreateKeyResponse{Key: plaintext, APIKey: key}, nil)
+}
+
+// Revoke handles DELETE /api/v1/admin/keys/{id}
+func (h *AdminKeyHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		response.Error(w, "Key ID is required", http.StatusBadRequest)
+		return
+	}
+
+	err := h.store.Revoke(r.Context(), id)
+	switch {
+	case errors.Is(err, auth.ErrKeyNotFound):
+		response.Error(w, "API key not found", http.StatusNotFound)
+		return
+	case errors.Is(err, auth.ErrKeyNotRevocable):
+		response.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		h.logger.Error("Failed to revoke API key", zap.String("key_id", id), zap.Error(err))
+		response.ErrorWithDetails(w, "Failed to revoke API key", err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	h.logger.Info("API key revoked", zap.String("key_id", id))
+	response.Success(w, map[string]interface{}{"id": id, "revoked": true}, nil)
+}
@@ -0,0 +1,66 @@
+package v1
+
+import (
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/metrics"
+	"go-data-gateway/internal/response"
+	"go-data-gateway/internal/tenant"
+)
+
+// AdminLatencyHandler reports data source query latency percentiles and
+// resets them, e.g. to take a baseline after a deploy
+type AdminLatencyHandler struct {
+	latencies *metrics.QueryLatencies
+	tenants   *tenant.Registry
+	logger    *zap.Logger
+}
+
+// NewAdminLatencyHandler creates a new latency admin handler
+func NewAdminLatencyHandler(latencies *metrics.QueryLatencies, tenants *tenant.Registry, logger *zap.Logger) *AdminLatencyHandler {
+	return &AdminLatencyHandler{
+		latencies: latencies,
+		tenants:   tenants,
+		logger:    logger,
+	}
+}
+
+// latencyResetter is implemented by data sources keeping their own query
+// time histogram, such as the cached data sources
+type latencyResetter interface {
+	ResetLatency()
+}
+
+// LatencyResetResponse is the body of POST /api/v1/admin/latency/reset
+type LatencyResetResponse struct {
+	ResetAt time.Time `json:"reset_at"`
+}
+
+// Get handles GET /api/v1/admin/latency: p50, p95 and p99 by data source and
+// endpoint
+func (h *AdminLatencyHandler) Get(w http.ResponseWriter, r *http.Request) {
+	response.Success(w, h.latencies.Summaries(), nil)
+}
+
+// Reset handles POST /api/v1/admin/latency/reset. It clears the histograms by
+// endpoint, those of every tenant's data sources and their pools' acquire
+// waits. Prometheus sees the counters restart, as after a restart.
+func (h *AdminLatencyHandler) Reset(w http.ResponseWriter, r *http.Request) {
+	h.latencies.Reset()
+	for _, t := range h.tenants.Tenants() {
+		for _, source := range h.tenants.TenantSources(t.ID) {
+			if resetter, ok := source.(latencyResetter); ok {
+				resetter.ResetLatency()
+			}
+			datasource.ResetPoolLatency(source)
+		}
+	}
+
+	resp := LatencyResetResponse{ResetAt: time.Now().UTC()}
+	h.logger.Info("Query latency histograms reset", zap.Time("reset_at", resp.ResetAt))
+	response.Success(w, resp, nil)
+}
@@ -0,0 +1,54 @@
+package v1
+
+import (
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/lock"
+	"go-data-gateway/internal/response"
+)
+
+// AdminLockHandler lists the distributed locks of scheduled tasks
+type AdminLockHandler struct {
+	locker *lock.RedisLocker
+	owner  string
+	logger *zap.Logger
+}
+
+// NewAdminLockHandler creates a new lock admin handler. A nil locker
+// reports that locks are disabled.
+func NewAdminLockHandler(locker *lock.RedisLocker, owner string, logger *zap.Logger) *AdminLockHandler {
+	return &AdminLockHandler{
+		locker: locker,
+		owner:  owner,
+		logger: logger,
+	}
+}
+
+// LocksResponse is the body of GET /api/v1/admin/locks
+type LocksResponse struct {
+	Distributed bool          `json:"distributed"` // False when every replica runs every task
+	Owner       string        `json:"owner"`       // This replica's lock owner name
+	Holders     []lock.Holder `json:"holders"`
+}
+
+// List handles GET /api/v1/admin/locks
+func (h *AdminLockHandler) List(w http.ResponseWriter, r *http.Request) {
+	resp := LocksResponse{Owner: h.owner, Holders: []lock.Holder{}}
+	if h.locker == nil {
+		response.Success(w, resp, nil)
+		return
+	}
+
+	holders, err := h.locker.Holders(r.Context())
+	if err != nil {
+		h.logger.Warn("Failed to list lock holders", zap.Error(err))
+		response.ErrorWithDetails(w, "Failed to list lock holders", err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	resp.Distributed = true
+	resp.Holders = holders
+	response.Success(w, resp, &response.Meta{Total: len(holders)})
+}
@@ -0,0 +1,40 @@
+package v1
+
+import (
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/mirror"
+	"go-data-gateway/internal/response"
+)
+
+// AdminMirrorHandler reports how mirrored requests compared with their
+// replays on the shadow target
+type AdminMirrorHandler struct {
+	mirror *mirror.Mirror
+	logger *zap.Logger
+}
+
+// NewAdminMirrorHandler creates a new mirror admin handler. A nil mirror
+// reports that mirroring is disabled.
+func NewAdminMirrorHandler(m *mirror.Mirror, logger *zap.Logger) *AdminMirrorHandler {
+	return &AdminMirrorHandler{
+		mirror: m,
+		logger: logger,
+	}
+}
+
+// MirrorMismatchesResponse is the body of GET /api/v1/admin/mirror/mismatches
+type MirrorMismatchesResponse struct {
+	mirror.State
+	Mismatches []mirror.Mismatch `json:"mismatches"` // Newest first
+}
+
+// Mismatches handles GET /api/v1/admin/mirror/mismatches. ?request_id=
+// returns only the mismatches of that request.
+func (h *AdminMirrorHandler) Mismatches(w http.ResponseWriter, r *http.Request) {
+	mismatches := h.mirror.Mismatches(r.URL.Query().Get("request_id"))
+	response.Success(w, MirrorMismatchesResponse{State: h.mirror.State(), Mismatches: mismatches},
+		&response.Meta{Total: len(mismatches)})
+}
@@ -0,0 +1,86 @@
+package v1
+
+import (
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/policy"
+	"go-data-gateway/internal/response"
+)
+
+// AdminPolicyHandler reports the active policy: its version, where it was
+// loaded from and the whitelists and cache TTLs in effect
+type AdminPolicyHandler struct {
+	watcher *policy.Watcher // nil without a policy file
+	logger  *zap.Logger
+}
+
+// NewAdminPolicyHandler creates a new policy admin handler
+func NewAdminPolicyHandler(watcher *policy.Watcher, logger *zap.Logger) *AdminPolicyHandler {
+	return &AdminPolicyHandler{
+		watcher: watcher,
+		logger:  logger,
+	}
+}
+
+// PolicyStatus is the body of GET /api/v1/admin/policy
+type PolicyStatus struct {
+	Version     string         `json:"version"`
+	Path        string         `json:"path,omitempty"`
+	LoadedAt    *time.Time     `json:"loaded_at,omitempty"`
+	LastError   string         `json:"last_error,omitempty"` // Why the file on disk is not active
+	LastErrorAt *time.Time     `json:"last_error_at,omitempty"`
+	Security    PolicySecurity `json:"security"`
+	Cache       PolicyCache    `json:"cache"`
+}
+
+// PolicySecurity lists the whitelists of the active policy
+type PolicySecurity struct {
+	DremioTables   []string                       `json:"dremio_tables"`
+	BigQueryTables []string                       `json:"bigquery_tables"`
+	TableColumns   map[string][]config.ColumnSpec `json:"table_columns"`
+}
+
+// PolicyCache lists the cache TTLs of the active policy, as durations like 5m0s
+type PolicyCache struct {
+	DefaultTTL string            `json:"default_ttl"`
+	MaxTTL     string            `json:"max_ttl,omitempty"`
+	Tables     map[string]string `json:"tables,omitempty"`
+}
+
+// Get handles GET /api/v1/admin/policy
+func (h *AdminPolicyHandler) Get(w http.ResponseWriter, r *http.Request) {
+	p := config.ActivePolicy()
+
+	status := PolicyStatus{
+		Version: p.Version,
+		Path:    p.Path,
+		Security: PolicySecurity{
+			DremioTables:   p.Security.AllowedDremioTables,
+			BigQueryTables: p.Security.AllowedBigQueryTables,
+			TableColumns:   p.Security.TableColumns,
+		},
+		Cache: PolicyCache{DefaultTTL: p.Cache.DefaultTTL.String()},
+	}
+	if !p.LoadedAt.IsZero() {
+		status.LoadedAt = &p.LoadedAt
+	}
+	if p.Cache.MaxTTL > 0 {
+		status.Cache.MaxTTL = p.Cache.MaxTTL.String()
+	}
+	if len(p.Cache.Tables) > 0 {
+		status.Cache.Tables = make(map[string]string, len(p.Cache.Tables))
+		for table, ttl := range p.Cache.Tables {
+			status.Cache.Tables[table] = ttl.String()
+		}
+	}
+	if at, err := h.watcher.LastError(); err != nil {
+		status.LastError = err.Error()
+		status.LastErrorAt = &at
+	}
+
+	response.Success(w, status, nil)
+}
@@ -0,0 +1,53 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/response"
+	"go-data-gateway/internal/shedding"
+)
+
+// AdminSheddingHandler exposes the load shedder and its emergency override
+type AdminSheddingHandler struct {
+	shedder *shedding.Shedder
+	logger  *zap.Logger
+}
+
+// NewAdminSheddingHandler creates a new load shedding admin handler
+func NewAdminSheddingHandler(shedder *shedding.Shedder, logger *zap.Logger) *AdminSheddingHandler {
+	return &AdminSheddingHandler{
+		shedder: shedder,
+		logger:  logger,
+	}
+}
+
+// SetModeRequest is the body of PUT /api/v1/admin/shedding
+type SetModeRequest struct {
+	Mode string `json:"mode"` // auto, on or off
+}
+
+// Get handles GET /api/v1/admin/shedding
+func (h *AdminSheddingHandler) Get(w http.ResponseWriter, r *http.Request) {
+	response.Success(w, h.shedder.State(), nil)
+}
+
+// SetMode handles PUT /api/v1/admin/shedding
+func (h *AdminSheddingHandler) SetMode(w http.ResponseWriter, r *http.Request) {
+	var req SetModeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	mode, err := shedding.ParseMode(req.Mode)
+	if err != nil {
+		response.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.shedder.SetMode(mode)
+	response.Success(w, h.shedder.State(), nil)
+}
@@ -0,0 +1,76 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/response"
+	"go-data-gateway/internal/snapshot"
+)
+
+// AdminSnapshotHandler manages the snapshots stored by POST /api/v1/diff
+type AdminSnapshotHandler struct {
+	store  snapshot.Store
+	logger *zap.Logger
+}
+
+// NewAdminSnapshotHandler creates a new snapshot admin handler
+func NewAdminSnapshotHandler(store snapshot.Store, logger *zap.Logger) *AdminSnapshotHandler {
+	return &AdminSnapshotHandler{
+		store:  store,
+		logger: logger,
+	}
+}
+
+// List handles GET /api/v1/admin/snapshots; ?tenant= restricts the list to
+// one tenant
+func (h *AdminSnapshotHandler) List(w http.ResponseWriter, r *http.Request) {
+	infos, err := h.store.List(r.Context())
+	if err != nil {
+		h.logger.Error("Failed to list snapshots", zap.Error(err))
+		response.Error(w, "Failed to list snapshots", http.StatusInternalServerError)
+		return
+	}
+
+	if tenantID := r.URL.Query().Get("tenant"); tenantID != "" {
+		filtered := make([]snapshot.Info, 0, len(infos))
+		for _, info := range infos {
+			if info.Tenant == tenantID {
+				filtered = append(filtered, info)
+			}
+		}
+		infos = filtered
+	}
+	response.Success(w, infos, &response.Meta{Total: len(infos)})
+}
+
+// Delete handles DELETE /api/v1/admin/snapshots/{tenant}/{label}
+func (h *AdminSnapshotHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	tenantID := chi.URLParam(r, "tenant")
+	label := chi.URLParam(r, "label")
+	if err := snapshot.ValidateLabel(label); err != nil {
+		response.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err := h.store.Delete(r.Context(), tenantID, label)
+	if errors.Is(err, snapshot.ErrNotFound) {
+		response.Error(w, fmt.Sprintf("Snapshot %s not found for tenant %s", label, tenantID), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to delete snapshot",
+			zap.String("tenant", tenantID),
+			zap.String("label", label),
+			zap.Error(err))
+		response.Error(w, "Failed to delete snapshot", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("Snapshot deleted", zap.String("tenant", tenantID), zap.String("label", label))
+	response.Success(w, map[string]interface{}{"tenant": tenantID, "label": label, "deleted": true}, nil)
+}
@@ -0,0 +1,55 @@
+package v1
+
+import (
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/response"
+	"go-data-gateway/internal/streamquota"
+)
+
+// AdminStreamHandler reports the active streams of each API key
+type AdminStreamHandler struct {
+	limiter *streamquota.Limiter
+	logger  *zap.Logger
+}
+
+// NewAdminStreamHandler creates a new stream admin handler. A nil limiter
+// reports that the stream quota is disabled.
+func NewAdminStreamHandler(limiter *streamquota.Limiter, logger *zap.Logger) *AdminStreamHandler {
+	return &AdminStreamHandler{
+		limiter: limiter,
+		logger:  logger,
+	}
+}
+
+// StreamsResponse is the body of GET /api/v1/admin/streams
+type StreamsResponse struct {
+	Enabled     bool                   `json:"enabled"`
+	Distributed bool                   `json:"distributed"` // Counted across replicas in Redis
+	LimitPerKey int                    `json:"limit_per_key"`
+	Keys        []streamquota.KeyCount `json:"keys"`
+}
+
+// List handles GET /api/v1/admin/streams
+func (h *AdminStreamHandler) List(w http.ResponseWriter, r *http.Request) {
+	resp := StreamsResponse{Keys: []streamquota.KeyCount{}}
+	if h.limiter == nil {
+		response.Success(w, resp, nil)
+		return
+	}
+
+	keys, err := h.limiter.Counts(r.Context())
+	if err != nil {
+		h.logger.Warn("Failed to count active streams", zap.Error(err))
+		response.ErrorWithDetails(w, "Failed to count active streams", err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	resp.Enabled = true
+	resp.Distributed = h.limiter.Distributed()
+	resp.LimitPerKey = h.limiter.Limit()
+	resp.Keys = keys
+	response.Success(w, resp, &response.Meta{Total: len(keys)})
+}
@@ -0,0 +1,28 @@
+package v1
+
+import (
+	"context"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"go-data-gateway/internal/auth"
+	"go-data-gateway/internal/datasource"
+)
+
+// attribute validates the caller's query labels and attaches them, with the
+// API key and request ID, to the context so data sources forward them to
+// the upstream engine
+func attribute(ctx context.Context, labels map[string]string) (context.Context, datasource.Attribution, error) {
+	if err := datasource.ValidateLabels(labels); err != nil {
+		return ctx, datasource.Attribution{}, err
+	}
+
+	attribution := datasource.Attribution{
+		Labels:    labels,
+		RequestID: middleware.GetReqID(ctx),
+	}
+	if key, ok := auth.KeyFromContext(ctx); ok {
+		attribution.APIKeyID = key.ID
+	}
+	return datasource.WithAttribution(ctx, attribution), attribution, nil
+}
@@ -0,0 +1,64 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+
+	"go-data-gateway/internal/auth"
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/response"
+	"go-data-gateway/internal/sqltext"
+)
+
+// HeaderLimitInjected carries the LIMIT injected into a streamed raw query
+const HeaderLimitInjected = "X-Limit-Injected"
+
+// autoLimit is the LIMIT injected into raw SQL without one of its own
+// (QUERY_AUTO_LIMIT); 0 disables injection
+type autoLimit int
+
+// apply returns the SQL to run and the limit injected into it, or sql and 0
+// when it runs as submitted: injection is disabled, the caller's key has the
+// query:unlimited scope, or sql limits its own rows. sql is read in the
+// dialect of the engine it is sent to.
+func (l autoLimit) apply(ctx context.Context, sql string, dialect sqltext.Dialect) (string, int) {
+	if auth.HasScope(ctx, auth.ScopeQueryUnlimited) {
+		return sql, 0
+	}
+	return l.inject(sql, dialect)
+}
+
+// inject returns sql with the limit injected and the limit, or sql and 0
+// when injection is disabled or sql limits its own rows
+func (l autoLimit) inject(sql string, dialect sqltext.Dialect) (string, int) {
+	if l <= 0 {
+		return sql, 0
+	}
+	limited, ok := datasource.InjectLimit(sql, int(l), dialect)
+	if !ok {
+		return sql, 0
+	}
+	return limited, int(l)
+}
+
+// limitWarnings returns the LIMIT_INJECTED warning of a query run with the
+// limit injected, or none when nothing was injected
+func limitWarnings(injected int) []response.Warning {
+	if injected == 0 {
+		return nil
+	}
+	return []response.Warning{{
+		Code:    response.WarnLimitInjected,
+		Message: fmt.Sprintf("The query has no LIMIT and ran with LIMIT %d; add one to read more rows", injected),
+		Details: map[string]int{"limit": injected},
+	}}
+}
+
+// unshiftLimit moves the position of an error in SQL that apply wrapped
+// back onto the submitted SQL
+func unshiftLimit(err error, injected int) error {
+	if injected == 0 {
+		return err
+	}
+	return datasource.ShiftInjectedLimit(err)
+}
@@ -3,110 +3,65 @@ package v1
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/inflight"
+	"go-data-gateway/internal/killswitch"
+	"go-data-gateway/internal/metrics"
+	"go-data-gateway/internal/response"
+	"go-data-gateway/pkg/apitypes"
 	"go.uber.org/zap"
 )
 
-// BatchRequest represents a batch query request
-type BatchRequest struct {
-	Queries []BatchQuery `json:"queries"`
-	Options BatchOptions `json:"options,omitempty"`
-}
-
-// BatchQuery represents a single query in a batch
-type BatchQuery struct {
-	ID          string                    `json:"id"`
-	Query       string                    `json:"query,omitempty"`
-	DataSource  string                    `json:"data_source"`
-	Table       string                    `json:"table,omitempty"`
-	Options     *datasource.QueryOptions  `json:"options,omitempty"`
-}
-
-// BatchOptions controls batch execution behavior
-type BatchOptions struct {
-	MaxConcurrency int           `json:"max_concurrency,omitempty"`
-	Timeout        time.Duration `json:"timeout,omitempty"`
-	StopOnError    bool          `json:"stop_on_error,omitempty"`
-}
-
-// BatchResponse represents the response for batch queries
-type BatchResponse struct {
-	Results   []BatchResult `json:"results"`
-	Summary   BatchSummary  `json:"summary"`
-	Timestamp time.Time     `json:"timestamp"`
-}
-
-// BatchResult represents the result of a single query in batch
-type BatchResult struct {
-	ID        string                     `json:"id"`
-	Status    string                     `json:"status"` // success, error, skipped
-	Data      []map[string]interface{}   `json:"data,omitempty"`
-	Error     string                     `json:"error,omitempty"`
-	QueryTime time.Duration              `json:"query_time_ms"`
-	RowCount  int                        `json:"row_count"`
-	CacheHit  bool                       `json:"cache_hit"`
-}
-
-// BatchSummary provides aggregate metrics for the batch
-type BatchSummary struct {
-	TotalQueries     int           `json:"total_queries"`
-	SuccessfulQueries int          `json:"successful_queries"`
-	FailedQueries    int           `json:"failed_queries"`
-	SkippedQueries   int           `json:"skipped_queries"`
-	TotalTime        time.Duration `json:"total_time_ms"`
-	CacheHits        int           `json:"cache_hits"`
-}
-
 // BatchHandler handles batch query requests
 type BatchHandler struct {
 	dataSources map[string]datasource.DataSource
+	metrics     *metrics.QueryCounter
+	autoLimit   autoLimit
 	logger      *zap.Logger
 }
 
 // NewBatchHandler creates a new batch handler
-func NewBatchHandler(dataSources map[string]datasource.DataSource, logger *zap.Logger) *BatchHandler {
+func NewBatchHandler(dataSources map[string]datasource.DataSource, queryMetrics *metrics.QueryCounter, logger *zap.Logger) *BatchHandler {
 	return &BatchHandler{
 		dataSources: dataSources,
+		metrics:     queryMetrics,
 		logger:      logger,
 	}
 }
 
+// SetAutoLimit injects LIMIT limit into queries without a top-level LIMIT
+func (h *BatchHandler) SetAutoLimit(limit int) {
+	h.autoLimit = autoLimit(limit)
+}
+
 // Execute handles batch query execution
 func (h *BatchHandler) Execute(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	startTime := time.Now()
 
 	// Parse request
-	var req BatchRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.logger.Error("Failed to parse batch request", zap.Error(err))
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	var req apitypes.BatchRequest
+	if !decodeBatchBody(w, r, &req) {
 		return
 	}
 
 	// Validate request
-	if len(req.Queries) == 0 {
-		http.Error(w, "No queries provided", http.StatusBadRequest)
-		return
-	}
-
-	if len(req.Queries) > 100 {
-		http.Error(w, "Batch size exceeds maximum of 100 queries", http.StatusBadRequest)
+	if validateBatch(req, h.dataSources).write(w) {
 		return
 	}
+	describeBatch(ctx, req)
 
 	// Set defaults
-	if req.Options.MaxConcurrency <= 0 {
-		req.Options.MaxConcurrency = 5
-	}
-	if req.Options.MaxConcurrency > 20 {
-		req.Options.MaxConcurrency = 20
-	}
+	req.Options.MaxConcurrency = maxConcurrency(req.Options.MaxConcurrency)
 	if req.Options.Timeout <= 0 {
 		req.Options.Timeout = 30 * time.Second
 	}
@@ -116,34 +71,105 @@ func (h *BatchHandler) Execute(w http.ResponseWriter, r *http.Request) {
 	defer cancel()
 
 	// Execute queries
-	results := h.executeBatch(ctx, req)
+	scheduler := h.newBatchScheduler(ctx, req.Queries, req.Options.MaxConcurrency)
+	results := h.executeBatch(ctx, req, scheduler)
 
 	// Prepare response
 	response := h.buildResponse(results, startTime)
+	response.Summary.Scheduling = scheduler.summary()
+	status := settleBatch(&response, req.Options.PartialOK)
 
 	// Log batch summary
 	h.logger.Info("Batch query completed",
 		zap.Int("total_queries", response.Summary.TotalQueries),
 		zap.Int("successful", response.Summary.SuccessfulQueries),
 		zap.Int("failed", response.Summary.FailedQueries),
-		zap.Duration("duration", response.Summary.TotalTime))
+		zap.Duration("duration", response.Summary.TotalTime),
+		zap.Any("scheduling", response.Summary.Scheduling))
 
 	// Send response
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Batch-Failed-Count", strconv.Itoa(response.Summary.FailedQueries))
+	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(response)
 }
 
+// settleBatch decides the outcome of a completed batch: any failed query, or
+// query skipped because its source is switched off, fails the batch with
+// 422, unless partialOK accepts it as a partial result with 200
+func settleBatch(response *apitypes.BatchResponse, partialOK bool) int {
+	failed := response.Summary.FailedQueries > 0 || response.Summary.DisabledQueries > 0
+	response.Success = !failed || partialOK
+	response.Summary.Partial = failed && partialOK
+	if response.Success {
+		return http.StatusOK
+	}
+	return http.StatusUnprocessableEntity
+}
+
+// maxBatchQueries is the most queries a batch may have
+const maxBatchQueries = 100
+
+// validateBatch checks the size of a batch and the data source, query or
+// table and labels of each of its queries
+func validateBatch(req apitypes.BatchRequest, dataSources map[string]datasource.DataSource) violations {
+	var v violations
+	if len(req.Queries) == 0 {
+		v.add("queries", "min=1", "at least one query is required")
+	}
+	if len(req.Queries) > maxBatchQueries {
+		v.add("queries", fmt.Sprintf("max=%d", maxBatchQueries), "a batch may have at most %d queries", maxBatchQueries)
+	}
+
+	sources := sourceNames(dataSources)
+	for i, query := range req.Queries {
+		field := fmt.Sprintf("queries[%d]", i)
+		v.source(field+".data_source", query.DataSource, sources)
+		if query.Query == "" && query.Table == "" {
+			v.add(field+".query", "required_without=table", "either query or table is required")
+		}
+		v.addErr(field+".labels", "labels", datasource.ValidateLabels(query.Labels))
+	}
+	return v
+}
+
+// describeBatch records the data sources of a batch on its in-flight
+// operation
+func describeBatch(ctx context.Context, req apitypes.BatchRequest) {
+	seen := make(map[string]bool)
+	var sources []string
+	for _, query := range req.Queries {
+		if !seen[query.DataSource] {
+			seen[query.DataSource] = true
+			sources = append(sources, query.DataSource)
+		}
+	}
+	sort.Strings(sources)
+	inflight.FromContext(ctx).Describe(strings.Join(sources, ","), "")
+}
+
+// maxConcurrency applies the default and the upper bound to the requested
+// number of concurrent queries
+func maxConcurrency(requested int) int {
+	if requested <= 0 {
+		return 5
+	}
+	if requested > 20 {
+		return 20
+	}
+	return requested
+}
+
 // executeBatch executes queries with concurrency control
-func (h *BatchHandler) executeBatch(ctx context.Context, req BatchRequest) []BatchResult {
-	results := make([]BatchResult, len(req.Queries))
-	semaphore := make(chan struct{}, req.Options.MaxConcurrency)
+func (h *BatchHandler) executeBatch(ctx context.Context, req apitypes.BatchRequest, scheduler *batchScheduler) []apitypes.BatchResult {
+	results := make([]apitypes.BatchResult, len(req.Queries))
 	var wg sync.WaitGroup
 	var stopFlag int32
 
 	for i, query := range req.Queries {
 		// Check if we should stop on error
 		if req.Options.StopOnError && stopFlag > 0 {
-			results[i] = BatchResult{
+			results[i] = apitypes.BatchResult{
 				ID:     query.ID,
 				Status: "skipped",
 				Error:  "Skipped due to previous error",
@@ -152,25 +178,26 @@ func (h *BatchHandler) executeBatch(ctx context.Context, req BatchRequest) []Bat
 		}
 
 		wg.Add(1)
-		go func(idx int, q BatchQuery) {
+		go func(idx int, q apitypes.BatchQuery) {
 			defer wg.Done()
 
-			// Acquire semaphore
-			semaphore <- struct{}{}
-			defer func() { <-semaphore }()
-
-			// Check context
-			if ctx.Err() != nil {
-				results[idx] = BatchResult{
+			// Wait for a slot of the batch and of the query's source
+			slots, release, ok := scheduler.acquire(ctx, q)
+			if !ok || ctx.Err() != nil {
+				if ok {
+					release()
+				}
+				results[idx] = apitypes.BatchResult{
 					ID:     q.ID,
 					Status: "error",
 					Error:  "Context cancelled",
 				}
 				return
 			}
+			defer release()
 
 			// Execute query
-			result := h.executeQuery(ctx, q)
+			result := h.executeQuery(ctx, q, slots)
 			results[idx] = result
 
 			// Set stop flag if needed
@@ -184,10 +211,11 @@ func (h *BatchHandler) executeBatch(ctx context.Context, req BatchRequest) []Bat
 	return results
 }
 
-// executeQuery executes a single query
-func (h *BatchHandler) executeQuery(ctx context.Context, query BatchQuery) BatchResult {
+// executeQuery executes a single query, retrying pool exhaustion within the
+// source's retry window when slots is not nil
+func (h *BatchHandler) executeQuery(ctx context.Context, query apitypes.BatchQuery, slots *sourceSlots) apitypes.BatchResult {
 	startTime := time.Now()
-	result := BatchResult{
+	result := apitypes.BatchResult{
 		ID: query.ID,
 	}
 
@@ -199,36 +227,64 @@ func (h *BatchHandler) executeQuery(ctx context.Context, query BatchQuery) Batch
 		return result
 	}
 
+	ctx, attribution, err := attribute(ctx, query.Labels)
+	if err != nil {
+		result.Status = "error"
+		result.Error = fmt.Sprintf("Invalid labels: %v", err)
+		return result
+	}
+
 	// Execute query
 	var queryResult *datasource.QueryResult
-	var err error
 
 	if query.Query != "" {
 		// Direct SQL query
-		queryResult, err = dataSource.ExecuteQuery(ctx, query.Query, query.Options)
+		sql, injected := h.autoLimit.apply(ctx, query.Query, dataSource.GetType().Dialect())
+		queryResult, err = slots.retry(ctx, func() (*datasource.QueryResult, error) {
+			return dataSource.ExecuteQuery(ctx, sql, query.Options)
+		})
+		err = unshiftLimit(err, injected)
+		result.LimitInjected, result.InjectedLimit = injected > 0, injected
 	} else if query.Table != "" {
 		// Table query
-		queryResult, err = dataSource.GetData(ctx, query.Table, query.Options)
+		queryResult, err = slots.retry(ctx, func() (*datasource.QueryResult, error) {
+			return dataSource.GetData(ctx, query.Table, query.Options)
+		})
 	} else {
 		result.Status = "error"
 		result.Error = "Either query or table must be specified"
 		return result
 	}
 
+	h.metrics.Record(query.DataSource, attribution.JobLabels())
+
 	// Handle result
-	if err != nil {
+	var disabledErr *killswitch.DisabledError
+	if errors.As(err, &disabledErr) {
+		// Switched off by an operator: the query did not run
+		result.Status = "skipped_disabled"
+		result.Error = err.Error()
+		if disabledErr.Message != "" {
+			result.Error += ": " + disabledErr.Message
+		}
+	} else if err != nil {
 		result.Status = "error"
 		result.Error = err.Error()
 		h.logger.Warn("Batch query failed",
 			zap.String("id", query.ID),
+			zap.String("api_key_id", attribution.APIKeyID),
+			zap.Any("labels", query.Labels),
 			zap.Error(err))
 	} else {
 		result.Status = "success"
 		result.Data = queryResult.Data
 		result.RowCount = queryResult.Count
+		inflight.FromContext(ctx).AddRows(queryResult.Count)
 		result.CacheHit = queryResult.CacheHit
 		h.logger.Debug("Batch query succeeded",
 			zap.String("id", query.ID),
+			zap.String("api_key_id", attribution.APIKeyID),
+			zap.Any("labels", query.Labels),
 			zap.Int("rows", queryResult.Count),
 			zap.Bool("cache_hit", queryResult.CacheHit))
 	}
@@ -238,11 +294,11 @@ func (h *BatchHandler) executeQuery(ctx context.Context, query BatchQuery) Batch
 }
 
 // buildResponse builds the batch response with summary
-func (h *BatchHandler) buildResponse(results []BatchResult, startTime time.Time) BatchResponse {
-	response := BatchResponse{
+func (h *BatchHandler) buildResponse(results []apitypes.BatchResult, startTime time.Time) apitypes.BatchResponse {
+	response := apitypes.BatchResponse{
 		Results:   results,
 		Timestamp: time.Now(),
-		Summary: BatchSummary{
+		Summary: apitypes.BatchSummary{
 			TotalQueries: len(results),
 			TotalTime:    time.Since(startTime),
 		},
@@ -260,6 +316,9 @@ func (h *BatchHandler) buildResponse(results []BatchResult, startTime time.Time)
 			response.Summary.FailedQueries++
 		case "skipped":
 			response.Summary.SkippedQueries++
+		case "skipped_disabled":
+			response.Summary.SkippedQueries++
+			response.Summary.DisabledQueries++
 		}
 	}
 
@@ -270,26 +329,32 @@ func (h *BatchHandler) buildResponse(results []BatchResult, startTime time.Time)
 func (h *BatchHandler) Stream(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	// Set up SSE headers
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("X-Accel-Buffering", "no")
+	// A request rejected before the start event is a plain JSON response with
+	// its status; failed queries are reported as result events
 
 	// Parse request
-	var req BatchRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.sendSSEError(w, "Failed to parse request")
+	var req apitypes.BatchRequest
+	if !decodeBatchBody(w, r, &req) {
+		return
+	}
+	if validateBatch(req, h.dataSources).write(w) {
 		return
 	}
+	describeBatch(ctx, req)
 
 	// Create flusher
 	flusher, ok := w.(http.Flusher)
 	if !ok {
-		h.sendSSEError(w, "Streaming not supported")
+		response.Error(w, "Streaming not supported", http.StatusInternalServerError)
 		return
 	}
 
+	// Set up SSE headers
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+
 	// Send initial message
 	h.sendSSEMessage(w, "start", map[string]interface{}{
 		"total_queries": len(req.Queries),
@@ -297,28 +362,122 @@ func (h *BatchHandler) Stream(w http.ResponseWriter, r *http.Request) {
 	})
 	flusher.Flush()
 
-	// Process queries one by one
+	// Run the queries concurrently and emit each as it completes
+	scheduling := h.streamBatch(ctx, req, func(event string, data interface{}) {
+		h.sendSSEMessage(w, event, data)
+		flusher.Flush()
+	})
+
+	// Send completion message
+	h.sendSSEMessage(w, "complete", map[string]interface{}{
+		"timestamp":  time.Now(),
+		"scheduling": scheduling,
+	})
+	flusher.Flush()
+}
+
+// indexedResult is a completed query and its position in the batch
+type indexedResult struct {
+	index  int
+	result apitypes.BatchResult
+}
+
+// streamBatch runs the queries of req under the batch scheduler and emits a
+// result (or cancelled) event and a progress event as each completes. With
+// Ordered, completed results are buffered and emitted in submission order.
+// With StopOnError, the first failure cancels the outstanding queries. It
+// returns the per-source scheduling summary.
+func (h *BatchHandler) streamBatch(ctx context.Context, req apitypes.BatchRequest, emit func(event string, data interface{})) []apitypes.SourceSchedule {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	total := len(req.Queries)
+	completed := make(chan indexedResult, total) // Never blocks a query goroutine
+	scheduler := h.newBatchScheduler(ctx, req.Queries, maxConcurrency(req.Options.MaxConcurrency))
+	var wg sync.WaitGroup
+
 	for i, query := range req.Queries {
-		if ctx.Err() != nil {
-			break
+		wg.Add(1)
+		go func(idx int, q apitypes.BatchQuery) {
+			defer wg.Done()
+
+			slots, release, ok := scheduler.acquire(ctx, q)
+			if !ok {
+				completed <- indexedResult{idx, cancelledResult(q)}
+				return
+			}
+			defer release()
+			if ctx.Err() != nil {
+				completed <- indexedResult{idx, cancelledResult(q)}
+				return
+			}
+
+			result := h.executeQuery(ctx, q, slots)
+			if result.Status == "error" && ctx.Err() != nil {
+				// Failed because the batch was cancelled, not on its own
+				result = cancelledResult(q)
+			}
+			completed <- indexedResult{idx, result}
+		}(i, query)
+	}
+	go func() {
+		wg.Wait()
+		close(completed)
+	}()
+
+	send := func(c indexedResult) {
+		if c.result.Status == "cancelled" {
+			emit("cancelled", map[string]interface{}{
+				"index": c.index,
+				"id":    c.result.ID,
+			})
+			return
 		}
+		emit("result", map[string]interface{}{
+			"index":  c.index,
+			"result": c.result,
+		})
+	}
 
-		// Execute query
-		result := h.executeQuery(ctx, query)
+	pending := make(map[int]indexedResult)
+	next, done := 0, 0
+	for c := range completed {
+		done++
+		if req.Options.StopOnError && c.result.Status == "error" {
+			cancel()
+		}
 
-		// Send result
-		h.sendSSEMessage(w, "result", map[string]interface{}{
-			"index":  i,
-			"result": result,
+		if req.Options.Ordered {
+			pending[c.index] = c
+			for {
+				ready, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+				send(ready)
+				next++
+			}
+		} else {
+			send(c)
+		}
+
+		emit("progress", map[string]interface{}{
+			"completed": done,
+			"total":     total,
 		})
-		flusher.Flush()
 	}
+	return scheduler.summary()
+}
 
-	// Send completion message
-	h.sendSSEMessage(w, "complete", map[string]interface{}{
-		"timestamp": time.Now(),
-	})
-	flusher.Flush()
+// cancelledResult is the result of a query that did not run to completion
+// because the batch was cancelled
+func cancelledResult(query apitypes.BatchQuery) apitypes.BatchResult {
+	return apitypes.BatchResult{
+		ID:     query.ID,
+		Status: "cancelled",
+		Error:  "Batch cancelled",
+	}
 }
 
 // sendSSEMessage sends a Server-Sent Event message
@@ -326,9 +485,4 @@ func (h *BatchHandler) sendSSEMessage(w http.ResponseWriter, event string, data
 	jsonData, _ := json.Marshal(data)
 	fmt.Fprintf(w, "event: %s\n", event)
 	fmt.Fprintf(w, "data: %s\n\n", jsonData)
-}
-
-// sendSSEError sends an SSE error message
-func (h *BatchHandler) sendSSEError(w http.ResponseWriter, message string) {
-	h.sendSSEMessage(w, "error", map[string]string{"error": message})
 }
\ No newline at end of file
@@ -0,0 +1,183 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-data-gateway/pkg/apitypes"
+)
+
+// maxQueryLength is the longest SQL a query may have, in bytes
+const maxQueryLength = 256 << 10
+
+// maxQueryBytes bounds the JSON of one query, or of a whole stream request:
+// its SQL plus room for the other fields. Larger values are rejected while
+// they are read instead of buffered in full.
+const maxQueryBytes = maxQueryLength + 64<<10
+
+// errValueTooLarge is returned by a boundedReader past its limit
+var errValueTooLarge = errors.New("value too large")
+
+// boundedReader reads from r up to limit bytes in total, then fails with
+// errValueTooLarge. The limit is raised as each value to be bounded starts.
+type boundedReader struct {
+	r     io.Reader
+	read  int64
+	limit int64
+}
+
+func (b *boundedReader) Read(p []byte) (int, error) {
+	if b.read >= b.limit {
+		return 0, errValueTooLarge
+	}
+	if int64(len(p)) > b.limit-b.read {
+		p = p[:b.limit-b.read]
+	}
+	n, err := b.r.Read(p)
+	b.read += int64(n)
+	return n, err
+}
+
+// allow lets up to n more bytes be read. Bytes the decoder has read ahead
+// count toward the next value, so a value of n bytes always fits.
+func (b *boundedReader) allow(n int64) {
+	b.limit = b.read + n
+}
+
+// decodeBounded decodes the JSON body of r into dst like decodeBody, but
+// reads at most maxQueryBytes of it
+func decodeBounded(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	body := &boundedReader{r: r.Body}
+	body.allow(maxQueryBytes)
+	decoder := json.NewDecoder(body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(dst); err != nil {
+		violations{boundedViolation("body", err)}.write(w)
+		return false
+	}
+	return true
+}
+
+// decodeBatchBody decodes a batch request from the body of r one query at a
+// time, so a batch of too many queries or with too long a query is rejected
+// as soon as it is read rather than after the whole body is buffered. On
+// failure it responds 400 VALIDATION_FAILED naming the offending query and
+// returns false.
+func decodeBatchBody(w http.ResponseWriter, r *http.Request, req *apitypes.BatchRequest) bool {
+	if violation, ok := decodeBatch(r.Body, req); !ok {
+		violations{violation}.write(w)
+		return false
+	}
+	return true
+}
+
+// decodeBatch decodes a batch request from body, rejecting unknown fields,
+// the query past maxBatchQueries and any query longer than maxQueryLength
+func decodeBatch(body io.Reader, req *apitypes.BatchRequest) (apitypes.Violation, bool) {
+	bounded := &boundedReader{r: body}
+	bounded.allow(maxQueryBytes)
+	decoder := json.NewDecoder(bounded)
+	decoder.DisallowUnknownFields()
+
+	token, err := decoder.Token()
+	if err != nil {
+		return boundedViolation("body", err), false
+	}
+	if token != json.Delim('{') {
+		return apitypes.Violation{Field: "body", Message: "body must be an object", Constraint: "type"}, false
+	}
+	for decoder.More() {
+		bounded.allow(maxQueryBytes)
+		token, err := decoder.Token()
+		if err != nil {
+			return boundedViolation("body", err), false
+		}
+		// Keys match ignoring case, as encoding/json matches struct fields
+		switch key := token.(string); {
+		case strings.EqualFold(key, "queries"):
+			if violation, ok := decodeQueries(decoder, bounded, req); !ok {
+				return violation, false
+			}
+		case strings.EqualFold(key, "options"):
+			if err := decoder.Decode(&req.Options); err != nil {
+				return prefixViolation("options", boundedViolation("options", err)), false
+			}
+		default:
+			return apitypes.Violation{Field: key, Message: fmt.Sprintf("unknown field %s", key), Constraint: "unknown_field"}, false
+		}
+	}
+	if _, err := decoder.Token(); err != nil {
+		return boundedViolation("body", err), false
+	}
+	return apitypes.Violation{}, true
+}
+
+// decodeQueries decodes the queries array of a batch, bounding each query
+// on its own. A repeated queries key replaces the earlier one, as it would
+// decoding into the struct.
+func decodeQueries(decoder *json.Decoder, bounded *boundedReader, req *apitypes.BatchRequest) (apitypes.Violation, bool) {
+	req.Queries = nil
+	token, err := decoder.Token()
+	if err != nil {
+		return boundedViolation("queries", err), false
+	}
+	if token == nil {
+		return apitypes.Violation{}, true
+	}
+	if token != json.Delim('[') {
+		return apitypes.Violation{Field: "queries", Message: "queries must be an array", Constraint: "type"}, false
+	}
+	for i := 0; decoder.More(); i++ {
+		field := fmt.Sprintf("queries[%d]", i)
+		if i == maxBatchQueries {
+			return apitypes.Violation{Field: "queries", Message: fmt.Sprintf("a batch may have at most %d queries", maxBatchQueries),
+				Constraint: fmt.Sprintf("max=%d", maxBatchQueries)}, false
+		}
+		bounded.allow(maxQueryBytes)
+		var query apitypes.BatchQuery
+		if err := decoder.Decode(&query); err != nil {
+			return prefixViolation(field, boundedViolation(field, err)), false
+		}
+		if len(query.Query) > maxQueryLength {
+			return queryTooLong(field + ".query"), false
+		}
+		req.Queries = append(req.Queries, query)
+	}
+	if _, err := decoder.Token(); err != nil {
+		return boundedViolation("queries", err), false
+	}
+	return apitypes.Violation{}, true
+}
+
+// queryTooLong is the violation of a query longer than maxQueryLength
+func queryTooLong(field string) apitypes.Violation {
+	return apitypes.Violation{Field: field, Message: fmt.Sprintf("%s must not exceed %d bytes", field, maxQueryLength),
+		Constraint: fmt.Sprintf("max=%d", maxQueryLength)}
+}
+
+// boundedViolation describes a decoding error of field, which may be that
+// the body ran past its bound
+func boundedViolation(field string, err error) apitypes.Violation {
+	if errors.Is(err, errValueTooLarge) {
+		return apitypes.Violation{Field: field, Message: fmt.Sprintf("%s must not exceed %d bytes", field, maxQueryBytes),
+			Constraint: "max_bytes=" + strconv.Itoa(maxQueryBytes)}
+	}
+	return decodeViolation(err)
+}
+
+// prefixViolation moves a violation of a decoded value under field, where
+// the value sits in the body
+func prefixViolation(field string, violation apitypes.Violation) apitypes.Violation {
+	switch violation.Field {
+	case "body", field:
+		violation.Field = field
+	default:
+		violation.Field = field + "." + violation.Field
+	}
+	return violation
+}
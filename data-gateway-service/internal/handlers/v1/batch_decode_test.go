@@ -0,0 +1,169 @@
+package v1
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/pkg/apitypes"
+)
+
+// countingReader counts the bytes read from r
+type countingReader struct {
+	r    io.Reader
+	read int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.read += n
+	return n, err
+}
+
+// endless repeats s forever
+type endless struct {
+	s  string
+	at int
+}
+
+func (e *endless) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = e.s[e.at%len(e.s)]
+		e.at++
+	}
+	return len(p), nil
+}
+
+func TestDecodeBatch(t *testing.T) {
+	tests := []struct {
+		body string
+		want apitypes.Violation
+	}{
+		{`{"queries": [{"id": "a", "query": "SELECT 1"}, {"id": "b", "qury": "SELECT 2"}]}`,
+			apitypes.Violation{Field: "queries[1].qury", Message: "unknown field qury", Constraint: "unknown_field"}},
+		{`{"queries": [{"id": "a", "data_source": 1}]}`,
+			apitypes.Violation{Field: "queries[0].data_source", Message: "data_source must be a string", Constraint: "type"}},
+		{`{"queries": [{"id": "a",}]}`,
+			apitypes.Violation{Field: "queries[0]", Message: "invalid JSON at offset 25: invalid character '}' looking for beginning of object key string", Constraint: "json"}},
+		{`{"queries": {"id": "a"}}`,
+			apitypes.Violation{Field: "queries", Message: "queries must be an array", Constraint: "type"}},
+		{`{"queries": [], "options": {"max_concurency": 2}}`,
+			apitypes.Violation{Field: "options.max_concurency", Message: "unknown field max_concurency", Constraint: "unknown_field"}},
+		{`{"query": "SELECT 1"}`,
+			apitypes.Violation{Field: "query", Message: "unknown field query", Constraint: "unknown_field"}},
+		{`[]`,
+			apitypes.Violation{Field: "body", Message: "body must be an object", Constraint: "type"}},
+		{``,
+			apitypes.Violation{Field: "body", Message: "request body is required", Constraint: "required"}},
+	}
+	for _, tt := range tests {
+		var req apitypes.BatchRequest
+		violation, ok := decodeBatch(strings.NewReader(tt.body), &req)
+		assert.False(t, ok, tt.body)
+		assert.Equal(t, tt.want, violation, tt.body)
+	}
+
+	var req apitypes.BatchRequest
+	_, ok := decodeBatch(strings.NewReader(`{"queries": [{"id": "a", "data_source": "DATAWAREHOUSE", "query": "SELECT 1"}],
+		"options": {"max_concurrency": 2, "ordered": true}}`), &req)
+	require.True(t, ok)
+	assert.Equal(t, []apitypes.BatchQuery{{ID: "a", DataSource: "DATAWAREHOUSE", Query: "SELECT 1"}}, req.Queries)
+	assert.Equal(t, apitypes.BatchOptions{MaxConcurrency: 2, Ordered: true}, req.Options)
+
+	req = apitypes.BatchRequest{}
+	_, ok = decodeBatch(strings.NewReader(`{"Queries": [{"ID": "a", "Query": "SELECT 1"}], "Options": {"Ordered": true}}`), &req)
+	require.True(t, ok, "field names match ignoring case")
+	assert.Equal(t, []apitypes.BatchQuery{{ID: "a", Query: "SELECT 1"}}, req.Queries)
+	assert.Equal(t, apitypes.BatchOptions{Ordered: true}, req.Options)
+
+	req = apitypes.BatchRequest{}
+	_, ok = decodeBatch(strings.NewReader(`{"queries": null}`), &req)
+	assert.True(t, ok)
+	assert.Empty(t, req.Queries)
+}
+
+func TestDecodeBatch_RejectsTooManyQueriesEarly(t *testing.T) {
+	query := `{"id": "q", "data_source": "DATAWAREHOUSE", "query": "SELECT 1"},`
+	body := &countingReader{r: io.MultiReader(strings.NewReader(`{"queries": [`), &endless{s: query})}
+
+	var req apitypes.BatchRequest
+	violation, ok := decodeBatch(body, &req)
+	assert.False(t, ok)
+	assert.Equal(t, apitypes.Violation{Field: "queries", Message: "a batch may have at most 100 queries", Constraint: "max=100"}, violation)
+
+	// The endless body is read no further than the 101st query and what the
+	// decoder reads ahead of it
+	assert.Less(t, body.read, (maxBatchQueries+1)*len(query)+maxQueryBytes)
+}
+
+func TestDecodeBatch_RejectsLongQueryEarly(t *testing.T) {
+	// A query just over the limit is decoded and named
+	long := strings.Repeat("x", maxQueryLength)
+	var req apitypes.BatchRequest
+	violation, ok := decodeBatch(strings.NewReader(fmt.Sprintf(
+		`{"queries": [{"id": "a", "query": "SELECT 1"}, {"id": "b", "query": "SELECT %s"}]}`, long)), &req)
+	assert.False(t, ok)
+	assert.Equal(t, queryTooLong("queries[1].query"), violation)
+
+	// An endless one is rejected without reading, or holding, much of it
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	body := &countingReader{r: io.MultiReader(
+		strings.NewReader(`{"queries": [{"id": "a", "query": "SELECT 1"}, {"id": "b", "query": "SELECT '`), &endless{s: "x"})}
+	violation, ok = decodeBatch(body, &req)
+	runtime.ReadMemStats(&after)
+	assert.False(t, ok)
+	assert.Equal(t, "queries[1]", violation.Field)
+	assert.Equal(t, fmt.Sprintf("max_bytes=%d", maxQueryBytes), violation.Constraint)
+	assert.LessOrEqual(t, body.read, 2*maxQueryBytes)
+	assert.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(8*maxQueryBytes))
+}
+
+func TestBatch_RejectsOversizedBodies(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio, rows: rowsOf(1)}
+	handler := NewBatchHandler(map[string]datasource.DataSource{"DATAWAREHOUSE": source}, nil, zap.NewNop())
+
+	query := `{"id": "q", "data_source": "DATAWAREHOUSE", "query": "SELECT 1"}`
+	tooMany := `{"queries": [` + strings.Repeat(query+",", maxBatchQueries) + query + `]}`
+	tooLong := `{"queries": [{"id": "q", "data_source": "DATAWAREHOUSE", "query": "SELECT '` + strings.Repeat("x", maxQueryLength) + `'"}]}`
+	for _, execute := range []http.HandlerFunc{handler.Execute, handler.Stream} {
+		rec := httptest.NewRecorder()
+		execute(rec, httptest.NewRequest(http.MethodPost, "/api/v1/batch", strings.NewReader(tooMany)))
+		assert.Equal(t, []apitypes.Violation{{Field: "queries", Message: "a batch may have at most 100 queries", Constraint: "max=100"}}, violationsOf(t, rec))
+
+		rec = httptest.NewRecorder()
+		execute(rec, httptest.NewRequest(http.MethodPost, "/api/v1/batch", strings.NewReader(tooLong)))
+		assert.Equal(t, []apitypes.Violation{queryTooLong("queries[0].query")}, violationsOf(t, rec))
+	}
+	assert.Empty(t, source.query)
+}
+
+func TestStream_RejectsOversizedBodies(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio, rows: rowsOf(1)}
+	handler := NewStreamHandler(map[string]datasource.DataSource{"DATAWAREHOUSE": source}, testLimits, zap.NewNop())
+
+	tooLong := `{"data_source": "DATAWAREHOUSE", "query": "SELECT '` + strings.Repeat("x", maxQueryLength) + `'"}`
+	for _, stream := range []http.HandlerFunc{handler.Stream, handler.StreamSSE} {
+		rec := httptest.NewRecorder()
+		stream(rec, httptest.NewRequest(http.MethodPost, "/api/v1/stream", strings.NewReader(tooLong)))
+		assert.Contains(t, violationsOf(t, rec), queryTooLong("query"))
+
+		// An endless body is cut off at its bound
+		body := &countingReader{r: io.MultiReader(strings.NewReader(`{"data_source": "DATAWAREHOUSE", "query": "`), &endless{s: "x"})}
+		rec = httptest.NewRecorder()
+		stream(rec, httptest.NewRequest(http.MethodPost, "/api/v1/stream", body))
+		assert.Equal(t, []apitypes.Violation{{Field: "body", Message: fmt.Sprintf("body must not exceed %d bytes", maxQueryBytes),
+			Constraint: fmt.Sprintf("max_bytes=%d", maxQueryBytes)}}, violationsOf(t, rec))
+		assert.LessOrEqual(t, body.read, maxQueryBytes)
+	}
+	assert.Empty(t, source.query)
+}
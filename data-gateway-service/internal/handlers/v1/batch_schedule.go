@@ -0,0 +1,153 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/pkg/apitypes"
+)
+
+// Backoff between retries of a query that found its source's pool exhausted
+const (
+	poolRetryInitialBackoff = 50 * time.Millisecond
+	poolRetryMaxBackoff     = time.Second
+)
+
+// batchScheduler admits the queries of a batch: at most MaxConcurrency in
+// total, and per source no more than the source's advertised capacity
+type batchScheduler struct {
+	global  chan struct{}
+	sources map[string]*sourceSlots
+}
+
+// sourceSlots limits and accounts the queries of one source
+type sourceSlots struct {
+	slots       chan struct{}
+	retryWindow time.Duration // Zero retries until the batch context is done
+	retries     atomic.Int64
+	schedule    apitypes.SourceSchedule
+}
+
+// newBatchScheduler derives each source's concurrency from maxConcurrency and
+// the source's capacity. A query may retry pool exhaustion for its share of
+// the time left in ctx: the remaining time divided by the number of waves its
+// source needs at that concurrency.
+func (h *BatchHandler) newBatchScheduler(ctx context.Context, queries []apitypes.BatchQuery, maxConcurrency int) *batchScheduler {
+	s := &batchScheduler{
+		global:  make(chan struct{}, maxConcurrency),
+		sources: make(map[string]*sourceSlots),
+	}
+
+	counts := make(map[string]int)
+	for _, q := range queries {
+		if _, ok := h.dataSources[q.DataSource]; ok {
+			counts[q.DataSource]++
+		}
+	}
+
+	deadline, hasDeadline := ctx.Deadline()
+	for name, count := range counts {
+		capacity := datasource.Capacity(ctx, h.dataSources[name])
+		concurrency := maxConcurrency
+		if capacity > 0 && capacity < concurrency {
+			concurrency = capacity
+		}
+
+		slots := &sourceSlots{
+			slots: make(chan struct{}, concurrency),
+			schedule: apitypes.SourceSchedule{
+				Source:      name,
+				Queries:     count,
+				Capacity:    capacity,
+				Concurrency: concurrency,
+			},
+		}
+		if hasDeadline {
+			waves := (count + concurrency - 1) / concurrency
+			slots.retryWindow = time.Until(deadline) / time.Duration(waves)
+			slots.schedule.RetryWindowMs = slots.retryWindow.Milliseconds()
+		}
+		s.sources[name] = slots
+	}
+	return s
+}
+
+// acquire waits for a slot of the query's source and then a batch slot. It
+// returns the source's slots (nil for an unknown source) and a release
+// function, or false when ctx is done first.
+func (s *batchScheduler) acquire(ctx context.Context, q apitypes.BatchQuery) (*sourceSlots, func(), bool) {
+	source := s.sources[q.DataSource]
+	if source != nil {
+		select {
+		case source.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, nil, false
+		}
+	}
+
+	select {
+	case s.global <- struct{}{}:
+	case <-ctx.Done():
+		if source != nil {
+			<-source.slots
+		}
+		return nil, nil, false
+	}
+
+	return source, func() {
+		<-s.global
+		if source != nil {
+			<-source.slots
+		}
+	}, true
+}
+
+// summary returns the schedule of every source, sorted by name
+func (s *batchScheduler) summary() []apitypes.SourceSchedule {
+	schedules := make([]apitypes.SourceSchedule, 0, len(s.sources))
+	for _, source := range s.sources {
+		schedule := source.schedule
+		schedule.PoolRetries = source.retries.Load()
+		schedules = append(schedules, schedule)
+	}
+	sort.Slice(schedules, func(i, j int) bool { return schedules[i].Source < schedules[j].Source })
+	return schedules
+}
+
+// retry runs fn, retrying with exponential backoff while it fails with
+// datasource.ErrPoolExhausted and the retry window has time left. A nil
+// receiver runs fn once.
+func (s *sourceSlots) retry(ctx context.Context, fn func() (*datasource.QueryResult, error)) (*datasource.QueryResult, error) {
+	result, err := fn()
+	if s == nil {
+		return result, err
+	}
+
+	var deadline time.Time
+	if s.retryWindow > 0 {
+		deadline = time.Now().Add(s.retryWindow)
+	}
+	backoff := poolRetryInitialBackoff
+	for err != nil && errors.Is(err, datasource.ErrPoolExhausted) {
+		if !deadline.IsZero() && time.Now().Add(backoff).After(deadline) {
+			return result, err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return result, err
+		}
+
+		s.retries.Add(1)
+		result, err = fn()
+		backoff = min(2*backoff, poolRetryMaxBackoff)
+	}
+	return result, err
+}
@@ -0,0 +1,380 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/killswitch"
+	"go-data-gateway/internal/tenant"
+	"go-data-gateway/pkg/apitypes"
+)
+
+// sleepingSource treats each query as a duration to wait before returning a
+// row; the query "fail" fails after 50ms
+type sleepingSource struct {
+	recordingSource
+}
+
+func (s *sleepingSource) ExecuteQuery(ctx context.Context, query string, opts *datasource.QueryOptions) (*datasource.QueryResult, error) {
+	failed := query == "fail"
+	if failed {
+		query = "50ms"
+	}
+	delay, err := time.ParseDuration(query)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case <-time.After(delay):
+		if failed {
+			return nil, errors.New("table not found")
+		}
+		return &datasource.QueryResult{Data: rowsOf(1), Count: 1, Source: s.sourceType}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+type sseEvent struct {
+	name string
+	data map[string]interface{}
+}
+
+// streamBatchEvents posts a batch of the given queries to Stream and parses
+// the emitted events
+func streamBatchEvents(t *testing.T, queries []string, options apitypes.BatchOptions) []sseEvent {
+	t.Helper()
+	source := &sleepingSource{recordingSource{sourceType: datasource.DataSourceDremio}}
+	handler := NewBatchHandler(map[string]datasource.DataSource{"dremio": source}, nil, zap.NewNop())
+
+	req := apitypes.BatchRequest{Options: options}
+	for i, query := range queries {
+		req.Queries = append(req.Queries, apitypes.BatchQuery{ID: string(rune('a' + i)), Query: query, DataSource: "dremio"})
+	}
+	body, err := json.Marshal(req)
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	handler.Stream(rec, httptest.NewRequest(http.MethodPost, "/batch/stream", strings.NewReader(string(body))))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var events []sseEvent
+	for _, message := range strings.Split(strings.TrimSpace(rec.Body.String()), "\n\n") {
+		lines := strings.SplitN(message, "\n", 2)
+		require.Len(t, lines, 2)
+		event := sseEvent{name: strings.TrimPrefix(lines[0], "event: ")}
+		require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(lines[1], "data: ")), &event.data))
+		events = append(events, event)
+	}
+	return events
+}
+
+// emitted returns the index of every result and cancelled event, in order
+func emitted(events []sseEvent) (results, cancelled []int) {
+	for _, event := range events {
+		switch event.name {
+		case "result":
+			results = append(results, int(event.data["index"].(float64)))
+		case "cancelled":
+			cancelled = append(cancelled, int(event.data["index"].(float64)))
+		}
+	}
+	return results, cancelled
+}
+
+func TestBatchStream_ConcurrentIsFasterThanSerial(t *testing.T) {
+	queries := []string{"100ms", "100ms", "100ms"}
+
+	start := time.Now()
+	streamBatchEvents(t, queries, apitypes.BatchOptions{MaxConcurrency: 1})
+	serial := time.Since(start)
+
+	start = time.Now()
+	events := streamBatchEvents(t, queries, apitypes.BatchOptions{MaxConcurrency: 3})
+	concurrent := time.Since(start)
+
+	assert.GreaterOrEqual(t, serial, 300*time.Millisecond)
+	assert.Less(t, concurrent, 200*time.Millisecond)
+
+	results, _ := emitted(events)
+	assert.ElementsMatch(t, []int{0, 1, 2}, results)
+	assert.Equal(t, "start", events[0].name)
+	assert.Equal(t, "complete", events[len(events)-1].name)
+}
+
+func TestBatchStream_EmitsInCompletionOrSubmissionOrder(t *testing.T) {
+	queries := []string{"150ms", "10ms", "80ms"}
+
+	events := streamBatchEvents(t, queries, apitypes.BatchOptions{MaxConcurrency: 3})
+	results, _ := emitted(events)
+	assert.Equal(t, []int{1, 2, 0}, results)
+
+	events = streamBatchEvents(t, queries, apitypes.BatchOptions{MaxConcurrency: 3, Ordered: true})
+	results, _ = emitted(events)
+	assert.Equal(t, []int{0, 1, 2}, results)
+
+	// Progress follows completions even while ordered results are buffered
+	var progress []float64
+	for _, event := range events {
+		if event.name == "progress" {
+			assert.Equal(t, float64(3), event.data["total"])
+			progress = append(progress, event.data["completed"].(float64))
+		}
+	}
+	assert.Equal(t, []float64{1, 2, 3}, progress)
+}
+
+func TestBatchStream_StopOnErrorCancelsOutstanding(t *testing.T) {
+	queries := []string{"10ms", "fail", "2s", "2s"}
+
+	start := time.Now()
+	events := streamBatchEvents(t, queries, apitypes.BatchOptions{MaxConcurrency: 3, StopOnError: true, Ordered: true})
+	assert.Less(t, time.Since(start), time.Second)
+
+	results, cancelled := emitted(events)
+	assert.Equal(t, []int{0, 1}, results)
+	assert.Equal(t, []int{2, 3}, cancelled)
+	for _, event := range events {
+		if event.name == "result" && event.data["index"].(float64) == 1 {
+			assert.Equal(t, "error", event.data["result"].(map[string]interface{})["status"])
+		}
+	}
+}
+
+// pooledSource advertises a capacity and fails with ErrPoolExhausted when
+// more queries than that are running, and for its first busy calls or every
+// call when saturated, as an Arrow pool whose connections are taken by other
+// requests
+type pooledSource struct {
+	sleepingSource
+	capacity    int
+	running     atomic.Int32
+	maxRunning  atomic.Int32
+	busy        atomic.Int32
+	saturated   bool
+	exhaustions atomic.Int32
+}
+
+func (s *pooledSource) Capacity(ctx context.Context) int { return s.capacity }
+
+func (s *pooledSource) ExecuteQuery(ctx context.Context, query string, opts *datasource.QueryOptions) (*datasource.QueryResult, error) {
+	running := s.running.Add(1)
+	defer s.running.Add(-1)
+	if s.saturated || s.busy.Add(-1) >= 0 || int(running) > s.capacity {
+		s.exhaustions.Add(1)
+		return nil, fmt.Errorf("failed to get connection from pool: %w", datasource.ErrPoolExhausted)
+	}
+	for {
+		peak := s.maxRunning.Load()
+		if running <= peak || s.maxRunning.CompareAndSwap(peak, running) {
+			break
+		}
+	}
+	return s.sleepingSource.ExecuteQuery(ctx, query, opts)
+}
+
+func executeBatch(t *testing.T, source datasource.DataSource, queries int, options apitypes.BatchOptions) apitypes.BatchResponse {
+	t.Helper()
+	batch := make([]string, queries)
+	for i := range batch {
+		batch[i] = "30ms"
+	}
+	resp, _ := executeBatchQueries(t, source, batch, options)
+	return resp
+}
+
+// executeBatchQueries posts a batch of the given queries to Execute and
+// returns the decoded response and the recorder, whose status and headers
+// are checked against the response
+func executeBatchQueries(t *testing.T, source datasource.DataSource, queries []string, options apitypes.BatchOptions) (apitypes.BatchResponse, *httptest.ResponseRecorder) {
+	t.Helper()
+	handler := NewBatchHandler(map[string]datasource.DataSource{"dremio": source}, nil, zap.NewNop())
+
+	req := apitypes.BatchRequest{Options: options}
+	for i, query := range queries {
+		req.Queries = append(req.Queries, apitypes.BatchQuery{ID: fmt.Sprint(i), Query: query, DataSource: "dremio"})
+	}
+	body, err := json.Marshal(req)
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	handler.Execute(rec, httptest.NewRequest(http.MethodPost, "/batch", strings.NewReader(string(body))))
+
+	var resp apitypes.BatchResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	wantStatus := http.StatusOK
+	if !resp.Success {
+		wantStatus = http.StatusUnprocessableEntity
+	}
+	require.Equal(t, wantStatus, rec.Code)
+	assert.Equal(t, fmt.Sprint(resp.Summary.FailedQueries), rec.Header().Get("X-Batch-Failed-Count"))
+	return resp, rec
+}
+
+func TestBatch_FailedQueryFailsBatch(t *testing.T) {
+	source := &sleepingSource{recordingSource{sourceType: datasource.DataSourceDremio}}
+
+	resp, rec := executeBatchQueries(t, source, []string{"10ms", "fail", "10ms"}, apitypes.BatchOptions{})
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("X-Batch-Failed-Count"))
+	assert.False(t, resp.Success)
+	assert.False(t, resp.Summary.Partial)
+	// The queries that succeeded are still reported
+	require.Len(t, resp.Results, 3)
+	assert.Equal(t, []string{"success", "error", "success"}, []string{resp.Results[0].Status, resp.Results[1].Status, resp.Results[2].Status})
+	assert.Equal(t, 1, resp.Results[0].RowCount)
+}
+
+func TestBatch_PartialOKAcceptsFailedQueries(t *testing.T) {
+	source := &sleepingSource{recordingSource{sourceType: datasource.DataSourceDremio}}
+
+	resp, rec := executeBatchQueries(t, source, []string{"10ms", "fail", "10ms"}, apitypes.BatchOptions{PartialOK: true})
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("X-Batch-Failed-Count"))
+	assert.True(t, resp.Success)
+	assert.True(t, resp.Summary.Partial)
+	assert.Equal(t, 2, resp.Summary.SuccessfulQueries)
+	assert.Equal(t, 1, resp.Summary.FailedQueries)
+
+	// A batch without failures is not partial
+	resp, rec = executeBatchQueries(t, source, []string{"10ms"}, apitypes.BatchOptions{PartialOK: true})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "0", rec.Header().Get("X-Batch-Failed-Count"))
+	assert.True(t, resp.Success)
+	assert.False(t, resp.Summary.Partial)
+}
+
+func TestBatch_ConcurrencyLimitedBySourceCapacity(t *testing.T) {
+	source := &pooledSource{sleepingSource: sleepingSource{recordingSource{sourceType: datasource.DataSourceDremio}}, capacity: 2}
+
+	resp := executeBatch(t, source, 6, apitypes.BatchOptions{MaxConcurrency: 6})
+
+	assert.Equal(t, 6, resp.Summary.SuccessfulQueries)
+	assert.Equal(t, int32(2), source.maxRunning.Load())
+	assert.Zero(t, source.exhaustions.Load())
+
+	require.Len(t, resp.Summary.Scheduling, 1)
+	schedule := resp.Summary.Scheduling[0]
+	assert.Equal(t, "dremio", schedule.Source)
+	assert.Equal(t, 6, schedule.Queries)
+	assert.Equal(t, 2, schedule.Capacity)
+	assert.Equal(t, 2, schedule.Concurrency)
+	assert.Zero(t, schedule.PoolRetries)
+
+	// Three waves share the default 30s timeout
+	assert.InDelta(t, 10000, schedule.RetryWindowMs, 100)
+}
+
+func TestBatch_RetriesPoolExhaustion(t *testing.T) {
+	source := &pooledSource{sleepingSource: sleepingSource{recordingSource{sourceType: datasource.DataSourceDremio}}, capacity: 2}
+	source.busy.Store(2)
+
+	resp := executeBatch(t, source, 1, apitypes.BatchOptions{})
+
+	require.Len(t, resp.Results, 1)
+	assert.Equal(t, "success", resp.Results[0].Status)
+	assert.Equal(t, int64(2), resp.Summary.Scheduling[0].PoolRetries)
+}
+
+func TestBatch_PoolRetriesStopAtRetryWindow(t *testing.T) {
+	source := &pooledSource{sleepingSource: sleepingSource{recordingSource{sourceType: datasource.DataSourceDremio}}, capacity: 2}
+	source.saturated = true
+
+	// Backoffs of 50, 100, 200 and 400ms fit the 1s window; the next does not,
+	// so the query fails before the batch times out
+	start := time.Now()
+	resp, rec := executeBatchQueries(t, source, []string{"30ms"}, apitypes.BatchOptions{Timeout: time.Second})
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	require.Len(t, resp.Results, 1)
+	assert.Equal(t, "error", resp.Results[0].Status)
+	assert.Contains(t, resp.Results[0].Error, "connection pool exhausted")
+	assert.Equal(t, int64(4), resp.Summary.Scheduling[0].PoolRetries)
+}
+
+func TestBatchStream_ReportsScheduling(t *testing.T) {
+	events := streamBatchEvents(t, []string{"10ms", "10ms"}, apitypes.BatchOptions{MaxConcurrency: 2})
+
+	complete := events[len(events)-1]
+	require.Equal(t, "complete", complete.name)
+	scheduling := complete.data["scheduling"].([]interface{})
+	require.Len(t, scheduling, 1)
+	assert.Equal(t, float64(2), scheduling[0].(map[string]interface{})["concurrency"])
+}
+
+func TestBatch_AutoLimitReportedPerQuery(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio, rows: rowsOf(1)}
+	handler := NewBatchHandler(map[string]datasource.DataSource{"dremio": source}, nil, zap.NewNop())
+	handler.SetAutoLimit(500)
+
+	body := `{"queries": [
+		{"id": "cte", "query": "WITH r AS (SELECT * FROM t LIMIT 5) SELECT * FROM r", "data_source": "dremio"},
+		{"id": "limited", "query": "SELECT * FROM t LIMIT 5", "data_source": "dremio"}
+	], "options": {"max_concurrency": 1}}`
+	rec := httptest.NewRecorder()
+	handler.Execute(rec, httptest.NewRequest(http.MethodPost, "/batch", strings.NewReader(body)))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var resp apitypes.BatchResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Len(t, resp.Results, 2)
+	assert.True(t, resp.Results[0].LimitInjected)
+	assert.Equal(t, 500, resp.Results[0].InjectedLimit)
+	assert.False(t, resp.Results[1].LimitInjected)
+}
+
+func TestBatch_DisabledSourceSkipsItsQueries(t *testing.T) {
+	registry, err := tenant.NewRegistry(nil, "")
+	require.NoError(t, err)
+	registry.Register(tenant.DefaultID, "dremio", &sleepingSource{recordingSource{sourceType: datasource.DataSourceDremio}})
+	registry.Register(tenant.DefaultID, "bigquery", &sleepingSource{recordingSource{sourceType: datasource.DataSourceBigQuery}})
+	registry.SetKillSwitch(killswitch.New(killswitch.NewMemoryStore(), func() config.FlagPolicy {
+		return config.FlagPolicy{Sources: map[string]config.DisabledFlag{"bigquery": {Message: "Quota exhausted"}}}
+	}, time.Minute, zap.NewNop()))
+	handler := NewBatchHandler(registry.Sources(), nil, zap.NewNop())
+
+	execute := func(options apitypes.BatchOptions) (apitypes.BatchResponse, int) {
+		body, err := json.Marshal(apitypes.BatchRequest{Options: options, Queries: []apitypes.BatchQuery{
+			{ID: "a", Query: "10ms", DataSource: "dremio"},
+			{ID: "b", Query: "10ms", DataSource: "bigquery"},
+		}})
+		require.NoError(t, err)
+		rec := httptest.NewRecorder()
+		handler.Execute(rec, httptest.NewRequest(http.MethodPost, "/batch", strings.NewReader(string(body))))
+		var resp apitypes.BatchResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return resp, rec.Code
+	}
+
+	resp, status := execute(apitypes.BatchOptions{})
+	assert.Equal(t, http.StatusUnprocessableEntity, status, "a disabled query leaves the batch incomplete")
+	require.Len(t, resp.Results, 2)
+	assert.Equal(t, "success", resp.Results[0].Status)
+	assert.Equal(t, "skipped_disabled", resp.Results[1].Status)
+	assert.Equal(t, "data source bigquery is temporarily disabled: Quota exhausted", resp.Results[1].Error)
+	assert.Equal(t, 1, resp.Summary.SuccessfulQueries)
+	assert.Equal(t, 0, resp.Summary.FailedQueries)
+	assert.Equal(t, 1, resp.Summary.SkippedQueries)
+	assert.Equal(t, 1, resp.Summary.DisabledQueries)
+
+	resp, status = execute(apitypes.BatchOptions{PartialOK: true})
+	assert.Equal(t, http.StatusOK, status)
+	assert.True(t, resp.Summary.Partial)
+}
@@ -0,0 +1,204 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/cache"
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/numeric"
+	"go-data-gateway/internal/tenant"
+)
+
+// BulkRequest is the body of POST /api/v1/tender/bulk and /api/v1/rup/bulk
+type BulkRequest struct {
+	IDs []string `json:"ids"`
+}
+
+// BulkResponse is the data of a bulk lookup: the records found keyed by id,
+// and the requested ids that matched none, in request order
+type BulkResponse struct {
+	Records  map[string]map[string]interface{} `json:"records"`
+	NotFound []string                          `json:"not_found"`
+}
+
+// bulkReader looks records up by id for the bulk endpoints. Each record is
+// cached on its own, so a request that overlaps an earlier one only queries
+// the ids it has not seen.
+type bulkReader struct {
+	limits config.BulkConfig
+	cache  cache.Cache
+	logger *zap.Logger
+}
+
+// newBulkReader returns a reader with the built-in limits and no cache
+func newBulkReader(logger *zap.Logger) *bulkReader {
+	return &bulkReader{limits: config.DefaultBulk(), cache: &cache.NoOpCache{}, logger: logger}
+}
+
+// bulkLookup describes the records of one bulk endpoint
+type bulkLookup struct {
+	scope  string // Names the cached records, e.g. the table; also picks their policy TTL
+	table  string // Table of the policy TTL
+	column string // Column holding the id
+	fetch  func(ctx context.Context, ids []string) ([]map[string]interface{}, error)
+}
+
+// decode reads the ids of a bulk request without repeats, or responds 400
+// VALIDATION_FAILED and returns false
+func (b *bulkReader) decode(w http.ResponseWriter, r *http.Request) ([]string, bool) {
+	var req BulkRequest
+	if !decodeBody(w, r, &req) {
+		return nil, false
+	}
+
+	var v violations
+	switch {
+	case len(req.IDs) == 0:
+		v.add("ids", "min=1", "at least one id is required")
+	case len(req.IDs) > b.limits.MaxIDs:
+		v.add("ids", fmt.Sprintf("max=%d", b.limits.MaxIDs), "at most %d ids are allowed, got %d", b.limits.MaxIDs, len(req.IDs))
+	}
+	ids := make([]string, 0, len(req.IDs))
+	seen := make(map[string]bool, len(req.IDs))
+	for i, id := range req.IDs {
+		if id == "" {
+			v.required(fmt.Sprintf("ids[%d]", i))
+			continue
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if v.write(w) {
+		return nil, false
+	}
+	return ids, true
+}
+
+// read returns the records of ids, from the cache where it has them and
+// from lookup.fetch in chunks of limits.ChunkSize ids otherwise
+func (b *bulkReader) read(ctx context.Context, lookup bulkLookup, ids []string) (*BulkResponse, error) {
+	records, misses := b.cached(ctx, lookup, ids)
+
+	if len(misses) > 0 {
+		fetched, err := b.fetch(ctx, lookup, misses)
+		if err != nil {
+			return nil, err
+		}
+		for id, record := range fetched {
+			records[id] = record
+		}
+		b.store(ctx, lookup, fetched)
+	}
+
+	resp := &BulkResponse{Records: records, NotFound: []string{}}
+	for _, id := range ids {
+		if _, ok := records[id]; !ok {
+			resp.NotFound = append(resp.NotFound, id)
+		}
+	}
+	return resp, nil
+}
+
+// fetch queries the chunks of ids concurrently and keys the rows by id; the
+// first row of an id wins
+func (b *bulkReader) fetch(ctx context.Context, lookup bulkLookup, ids []string) (map[string]map[string]interface{}, error) {
+	var chunks [][]string
+	for start := 0; start < len(ids); start += b.limits.ChunkSize {
+		chunks = append(chunks, ids[start:min(start+b.limits.ChunkSize, len(ids))])
+	}
+
+	rows := make([][]map[string]interface{}, len(chunks))
+	errs := make([]error, len(chunks))
+	var wg sync.WaitGroup
+	for i, chunk := range chunks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rows[i], errs[i] = lookup.fetch(ctx, chunk)
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	records := make(map[string]map[string]interface{}, len(ids))
+	for _, chunk := range rows {
+		for _, row := range chunk {
+			id := fmt.Sprint(row[lookup.column])
+			if _, ok := records[id]; !ok {
+				records[id] = row
+			}
+		}
+	}
+	return records, nil
+}
+
+// cached returns the records of ids in the cache and the ids it misses
+func (b *bulkReader) cached(ctx context.Context, lookup bulkLookup, ids []string) (map[string]map[string]interface{}, []string) {
+	records := make(map[string]map[string]interface{}, len(ids))
+	var misses []string
+	for _, id := range ids {
+		data, err := b.cache.Get(ctx, b.key(ctx, lookup, id))
+		if err != nil {
+			if !errors.Is(err, cache.ErrCacheMiss) {
+				b.logger.Warn("Cache read failed, querying source", zap.Error(err))
+			}
+			misses = append(misses, id)
+			continue
+		}
+		// Numbers come back as they were stored, not rounded to a float64
+		var record map[string]interface{}
+		if err := numeric.Unmarshal(data, &record); err != nil {
+			misses = append(misses, id)
+			continue
+		}
+		records[id] = record
+	}
+	return records, misses
+}
+
+// store caches each record for the TTL the active policy gives the table.
+// Ids that were not found are not cached, so new records show up at once.
+func (b *bulkReader) store(ctx context.Context, lookup bulkLookup, records map[string]map[string]interface{}) {
+	ttl := config.ActivePolicy().Cache.TTL(lookup.table, 0)
+	for id, record := range records {
+		data, err := json.Marshal(record)
+		if err == nil {
+			err = b.cache.Set(ctx, b.key(ctx, lookup, id), data, ttl)
+		}
+		if err != nil {
+			b.logger.Warn("Cache write failed", zap.Error(err))
+			return
+		}
+	}
+}
+
+// key is the cache key of one record, in the namespace of the request's
+// tenant like the data source caches
+func (b *bulkReader) key(ctx context.Context, lookup bulkLookup, id string) string {
+	prefix := "record"
+	if t, ok := tenant.FromContext(ctx); ok && t.CacheNamespace != "" {
+		prefix = t.CacheNamespace + ":" + prefix
+	}
+	return cache.GenerateKey(prefix, lookup.scope, id)
+}
+
+// set sets the limits of the bulk endpoint of a handler and the cache of
+// its records; a nil cache caches nothing
+func (b *bulkReader) set(limits config.BulkConfig, records cache.Cache) {
+	b.limits = limits
+	if records == nil {
+		records = &cache.NoOpCache{}
+	}
+	b.cache = records
+}
@@ -0,0 +1,149 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/cache"
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+)
+
+// bulkSource holds tenders by id and answers each query with those whose
+// quoted id it mentions
+type bulkSource struct {
+	recordingSource
+	tenders map[string]map[string]interface{}
+
+	mu      sync.Mutex
+	queries []string
+}
+
+func newBulkSource(ids ...string) *bulkSource {
+	source := &bulkSource{
+		recordingSource: recordingSource{sourceType: datasource.DataSourceDremio},
+		tenders:         map[string]map[string]interface{}{},
+	}
+	for _, id := range ids {
+		source.tenders[id] = map[string]interface{}{"tender_id": id, "nama_paket": "Paket " + id}
+	}
+	return source
+}
+
+func (s *bulkSource) ExecuteQuery(ctx context.Context, query string, opts *datasource.QueryOptions) (*datasource.QueryResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queries = append(s.queries, query)
+
+	var rows []map[string]interface{}
+	for id, tender := range s.tenders {
+		if strings.Contains(query, "'"+id+"'") {
+			rows = append(rows, tender)
+		}
+	}
+	return &datasource.QueryResult{Data: rows, Count: len(rows), Source: s.sourceType}, nil
+}
+
+func postBulk(t *testing.T, handler http.HandlerFunc, url, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodPost, url, strings.NewReader(body)))
+	return rec
+}
+
+func bulkOf(t *testing.T, rec *httptest.ResponseRecorder) BulkResponse {
+	t.Helper()
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var body struct {
+		Data BulkResponse `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	return body.Data
+}
+
+func TestTenderBulk_ReportsMissingIDs(t *testing.T) {
+	source := newBulkSource("T1", "T2")
+	handler := NewTenderHandler(source, testLimits, nil, zap.NewNop())
+
+	result := bulkOf(t, postBulk(t, handler.Bulk, "/api/v1/tender/bulk", `{"ids": ["T1", "T9", "T2", "T1"]}`))
+
+	require.Len(t, source.queries, 1)
+	assert.Contains(t, source.queries[0], "tender_id IN ('T1', 'T9', 'T2')")
+	assert.Len(t, result.Records, 2)
+	assert.Equal(t, "Paket T2", result.Records["T2"]["nama_paket"])
+	assert.Equal(t, []string{"T9"}, result.NotFound)
+}
+
+func TestTenderBulk_QueriesOnlyUncachedIDs(t *testing.T) {
+	source := newBulkSource("T1", "T2", "T3")
+	handler := NewTenderHandler(source, testLimits, nil, zap.NewNop())
+	handler.SetBulk(config.DefaultBulk(), cache.NewMemoryCache())
+
+	bulkOf(t, postBulk(t, handler.Bulk, "/api/v1/tender/bulk", `{"ids": ["T1", "T2"]}`))
+	result := bulkOf(t, postBulk(t, handler.Bulk, "/api/v1/tender/bulk", `{"ids": ["T1", "T2", "T3", "T4"]}`))
+
+	require.Len(t, source.queries, 2)
+	assert.Contains(t, source.queries[1], "tender_id IN ('T3', 'T4')")
+	assert.Len(t, result.Records, 3)
+	assert.Equal(t, "Paket T1", result.Records["T1"]["nama_paket"])
+	assert.Equal(t, []string{"T4"}, result.NotFound)
+
+	// Ids that were not found are asked for again
+	bulkOf(t, postBulk(t, handler.Bulk, "/api/v1/tender/bulk", `{"ids": ["T3", "T4"]}`))
+	require.Len(t, source.queries, 3)
+	assert.Contains(t, source.queries[2], "tender_id IN ('T4')")
+}
+
+func TestTenderBulk_ChunksLargeRequests(t *testing.T) {
+	source := newBulkSource("T1", "T5")
+	handler := NewTenderHandler(source, testLimits, nil, zap.NewNop())
+	handler.SetBulk(config.BulkConfig{MaxIDs: 10, ChunkSize: 2}, nil)
+
+	result := bulkOf(t, postBulk(t, handler.Bulk, "/api/v1/tender/bulk", `{"ids": ["T1", "T2", "T3", "T4", "T5"]}`))
+
+	assert.Len(t, source.queries, 3)
+	assert.Len(t, result.Records, 2)
+	assert.Equal(t, []string{"T2", "T3", "T4"}, result.NotFound)
+}
+
+func TestTenderBulk_RejectsTooManyIDs(t *testing.T) {
+	source := newBulkSource()
+	handler := NewTenderHandler(source, testLimits, nil, zap.NewNop())
+	handler.SetBulk(config.BulkConfig{MaxIDs: 2, ChunkSize: 2}, nil)
+
+	violations := violationsOf(t, postBulk(t, handler.Bulk, "/api/v1/tender/bulk", `{"ids": ["T1", "T2", "T3"]}`))
+
+	require.Len(t, violations, 1)
+	assert.Equal(t, "ids", violations[0].Field)
+	assert.Equal(t, "max=2", violations[0].Constraint)
+	assert.Empty(t, source.queries)
+}
+
+func TestTenderBulk_RejectsEmptyIDs(t *testing.T) {
+	handler := NewTenderHandler(newBulkSource(), testLimits, nil, zap.NewNop())
+
+	violations := violationsOf(t, postBulk(t, handler.Bulk, "/api/v1/tender/bulk", `{"ids": []}`))
+	assert.Equal(t, "min=1", violations[0].Constraint)
+
+	violations = violationsOf(t, postBulk(t, handler.Bulk, "/api/v1/tender/bulk", `{"ids": ["T1", ""]}`))
+	assert.Equal(t, "ids[1]", violations[0].Field)
+}
+
+func TestRUPBulk_HidesDeletedRows(t *testing.T) {
+	handler, querier := newTestRUPHandler()
+
+	rec := postBulk(t, handler.Bulk, "/api/v1/rup/bulk", `{"ids": ["K1", "K2"]}`)
+
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Len(t, querier.queries, 1)
+	assert.Contains(t, querier.queries[0], "WHERE kd_kro_str IN ('K1', 'K2') AND is_deleted = FALSE")
+}
@@ -0,0 +1,117 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-data-gateway/internal/cache"
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/response"
+)
+
+// maxAgeParam bounds the age of a cached result a caller accepts, in seconds
+const maxAgeParam = "max_age_seconds"
+
+// freshOnly is the MaxAge of a request for max age 0: no cached entry
+// qualifies, so the result is re-executed and the cache refreshed
+const freshOnly = time.Nanosecond
+
+// setAge sets the Age header of a response served from the result cache to
+// the time since the result was cached, so downstream caches count its
+// lifetime from the original fetch. The CacheControl middleware drops it for
+// responses that are not cacheable.
+func setAge(w http.ResponseWriter, result *datasource.QueryResult) {
+	if result == nil || !result.CacheHit {
+		return
+	}
+	if age, ok := resultAge(result); ok {
+		w.Header().Set("Age", strconv.FormatInt(age, 10))
+	}
+}
+
+// resultAge returns the seconds since a cache hit was cached
+func resultAge(result *datasource.QueryResult) (int64, bool) {
+	cachedAt, ok := result.Metadata[cache.MetaCachedAt].(time.Time)
+	if !ok || cachedAt.IsZero() {
+		return 0, false
+	}
+	return max(int64(time.Since(cachedAt)/time.Second), 0), true
+}
+
+// ageSeconds returns the age of a result for the response meta: the time
+// since it was cached for a cache hit, 0 for a result fetched just now, and
+// nil when a cache hit has no cache time
+func ageSeconds(result *datasource.QueryResult) *int64 {
+	if result == nil {
+		return nil
+	}
+	if !result.CacheHit {
+		var fresh int64
+		return &fresh
+	}
+	if age, ok := resultAge(result); ok {
+		return &age
+	}
+	return nil
+}
+
+// cacheWriteWarning returns the warning of a result when the caller
+// required it to be cached and writing it failed, and nil otherwise. A
+// skipped write is not a failure: the request or the source chose not to
+// cache, and no replica's entry was left behind.
+func cacheWriteWarning(result *datasource.QueryResult, required bool) *response.Warning {
+	if !required || cache.CacheWrite(result) != cache.CacheWriteFailed {
+		return nil
+	}
+	return &response.Warning{
+		Code:    response.WarnCacheWriteFailed,
+		Message: "The result could not be cached; other replicas may serve an older result until the entry expires",
+	}
+}
+
+// queryMaxAge reads the max_age_seconds query parameter, falling back to the
+// request's Cache-Control header
+func queryMaxAge(r *http.Request) (time.Duration, error) {
+	raw := r.URL.Query().Get(maxAgeParam)
+	if raw == "" {
+		return requestMaxAge(r, nil)
+	}
+	seconds, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be an integer", maxAgeParam)
+	}
+	return requestMaxAge(r, &seconds)
+}
+
+// requestMaxAge returns the QueryOptions.MaxAge for a request: seconds when
+// the caller set max_age_seconds, else the max-age directive of a
+// Cache-Control request header. Zero means any cached result is acceptable.
+// Malformed header directives are ignored, as HTTP caches do.
+func requestMaxAge(r *http.Request, seconds *int) (time.Duration, error) {
+	if seconds == nil {
+		for _, directive := range strings.Split(r.Header.Get("Cache-Control"), ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			if !strings.EqualFold(name, "max-age") {
+				continue
+			}
+			if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+				seconds = &n
+			}
+			break
+		}
+		if seconds == nil {
+			return 0, nil
+		}
+	}
+
+	if *seconds < 0 {
+		return 0, fmt.Errorf("%s must not be negative", maxAgeParam)
+	}
+	if *seconds == 0 {
+		return freshOnly, nil
+	}
+	return time.Duration(*seconds) * time.Second, nil
+}
@@ -0,0 +1,214 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/cache"
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+	custommw "go-data-gateway/internal/middleware/chi"
+	"go-data-gateway/internal/response"
+)
+
+func TestSetAge(t *testing.T) {
+	rec := httptest.NewRecorder()
+	setAge(rec, &datasource.QueryResult{
+		CacheHit: true,
+		Metadata: map[string]interface{}{cache.MetaCachedAt: time.Now().Add(-90 * time.Second)},
+	})
+	assert.Equal(t, "90", rec.Header().Get("Age"))
+
+	// Fresh results and results without a cache time carry no Age
+	for _, result := range []*datasource.QueryResult{
+		{Metadata: map[string]interface{}{cache.MetaCachedAt: time.Now()}},
+		{CacheHit: true},
+		nil,
+	} {
+		rec := httptest.NewRecorder()
+		setAge(rec, result)
+		assert.Empty(t, rec.Header().Get("Age"))
+	}
+}
+
+func TestTableRows_CacheHeaders(t *testing.T) {
+	dremio := &recordingSource{sourceType: datasource.DataSourceDremio, rows: rowsOf(2)}
+	cached := cache.NewCachedDataSource(dremio, cache.NewMemoryCache(), zap.NewNop())
+	handler := NewTableHandler(map[string]datasource.DataSource{"DATAWAREHOUSE": cached}, testLimits,
+		config.GetDefaultSecurityConfig, zap.NewNop())
+
+	r := chi.NewRouter()
+	r.Use(custommw.CacheControl(config.NoStore))
+	r.With(custommw.CacheControl(config.CachePolicy{Public: true, MaxAge: time.Minute, SMaxAge: 5 * time.Minute})).
+		Get("/sources/{source}/tables/{table}/rows", handler.Rows)
+
+	get := func(url string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		return rec
+	}
+	url := "/sources/datawarehouse/tables/nessie_iceberg.tender_data/rows"
+
+	miss := get(url)
+	require.Equal(t, http.StatusOK, miss.Code)
+	assert.Equal(t, "public, max-age=60, s-maxage=300", miss.Header().Get("Cache-Control"))
+	assert.Empty(t, miss.Header().Get("Age"))
+
+	// Served from the result cache: Age counts from when it was cached
+	hit := get(url)
+	require.Equal(t, http.StatusOK, hit.Code)
+	assert.Equal(t, "public, max-age=60, s-maxage=300", hit.Header().Get("Cache-Control"))
+	assert.Equal(t, "0", hit.Header().Get("Age"))
+
+	// Errors are never cached
+	rejected := get("/sources/datawarehouse/tables/sys.users/rows")
+	assert.Equal(t, http.StatusForbidden, rejected.Code)
+	assert.Equal(t, "no-store", rejected.Header().Get("Cache-Control"))
+}
+
+func TestRequestMaxAge(t *testing.T) {
+	seconds := func(n int) *int { return &n }
+	request := func(cacheControl string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if cacheControl != "" {
+			r.Header.Set("Cache-Control", cacheControl)
+		}
+		return r
+	}
+
+	cases := []struct {
+		name         string
+		seconds      *int
+		cacheControl string
+		want         time.Duration
+	}{
+		{"no limit", nil, "", 0},
+		{"field", seconds(30), "", 30 * time.Second},
+		{"field over header", seconds(30), "max-age=600", 30 * time.Second},
+		{"header", nil, "no-transform, Max-Age=600", 10 * time.Minute},
+		{"zero accepts no cached entry", seconds(0), "", freshOnly},
+		{"header zero", nil, "max-age=0", freshOnly},
+		{"malformed header ignored", nil, "max-age=soon", 0},
+		{"header without max-age", nil, "no-transform", 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := requestMaxAge(request(tc.cacheControl), tc.seconds)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	_, err := requestMaxAge(request(""), seconds(-1))
+	assert.Error(t, err)
+}
+
+func TestQuery_MaxAge(t *testing.T) {
+	dremio := &recordingSource{sourceType: datasource.DataSourceDremio, rows: rowsOf(2)}
+	cached := cache.NewCachedDataSource(dremio, cache.NewMemoryCache(), zap.NewNop())
+	handler := NewQueryHandler(map[string]datasource.DataSource{"DATAWAREHOUSE": cached}, testLimits, nil, false, zap.NewNop())
+
+	execute := func(body, cacheControl string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/query", bytes.NewBufferString(body))
+		if cacheControl != "" {
+			r.Header.Set("Cache-Control", cacheControl)
+		}
+		rec := httptest.NewRecorder()
+		handler.Execute(rec, r)
+		return rec
+	}
+
+	// Uncached: fetched now, so the age is 0
+	rec := execute(`{"sql": "SELECT 1", "source": "DATAWAREHOUSE", "max_age_seconds": 60}`, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, time.Minute, dremio.opts.MaxAge)
+	meta := decodeResponse(t, rec).Meta
+	require.NotNil(t, meta.AgeSeconds)
+	assert.Equal(t, int64(0), *meta.AgeSeconds)
+
+	// Fresh enough: served from the cache with its age
+	dremio.opts = nil
+	rec = execute(`{"sql": "SELECT 1", "source": "DATAWAREHOUSE"}`, "max-age=60")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Nil(t, dremio.opts, "served from cache")
+	assert.NotNil(t, decodeResponse(t, rec).Meta.AgeSeconds)
+
+	// max_age_seconds=0 re-executes
+	rec = execute(`{"sql": "SELECT 1", "source": "DATAWAREHOUSE", "max_age_seconds": 0}`, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NotNil(t, dremio.opts)
+	assert.Equal(t, freshOnly, dremio.opts.MaxAge)
+
+	rec = execute(`{"sql": "SELECT 1", "source": "DATAWAREHOUSE", "max_age_seconds": -5}`, "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+// fullCache is a memory cache that refuses writes, like Redis out of memory
+type fullCache struct {
+	*cache.MemoryCache
+}
+
+func (c *fullCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return errors.New("OOM command not allowed when used memory > 'maxmemory'")
+}
+
+func TestQuery_CacheWrite(t *testing.T) {
+	dremio := &recordingSource{sourceType: datasource.DataSourceDremio, rows: rowsOf(2)}
+	execute := func(store cache.Cache, body string) response.StandardResponse {
+		cached := cache.NewCachedDataSource(dremio, store, zap.NewNop())
+		handler := NewQueryHandler(map[string]datasource.DataSource{"DATAWAREHOUSE": cached}, testLimits, nil, false, zap.NewNop())
+		rec := httptest.NewRecorder()
+		handler.Execute(rec, httptest.NewRequest(http.MethodPost, "/api/v1/query", bytes.NewBufferString(body)))
+		require.Equal(t, http.StatusOK, rec.Code)
+		return decodeResponse(t, rec)
+	}
+
+	body := execute(cache.NewMemoryCache(), `{"sql": "SELECT 1", "source": "DATAWAREHOUSE", "require_cache_write": true}`)
+	assert.Equal(t, cache.CacheWriteOK, body.Meta.CacheWrite)
+	assert.Empty(t, body.Warnings)
+
+	// A failed write is reported, and warned of only when it was required
+	full := &fullCache{cache.NewMemoryCache()}
+	body = execute(full, `{"sql": "SELECT 1", "source": "DATAWAREHOUSE"}`)
+	assert.Equal(t, cache.CacheWriteFailed, body.Meta.CacheWrite)
+	assert.Empty(t, body.Warnings)
+
+	body = execute(full, `{"sql": "SELECT 1", "source": "DATAWAREHOUSE", "require_cache_write": true}`)
+	assert.Equal(t, cache.CacheWriteFailed, body.Meta.CacheWrite)
+	require.Len(t, body.Warnings, 1)
+	assert.Equal(t, response.WarnCacheWriteFailed, body.Warnings[0].Code)
+	assert.Equal(t, &response.Warning{Code: body.Warnings[0].Code, Message: body.Warnings[0].Message}, body.Meta.CacheWarning,
+		"the deprecated meta.cache_warning is still sent")
+}
+
+func TestTableRows_MaxAgeParameter(t *testing.T) {
+	dremio := &recordingSource{sourceType: datasource.DataSourceDremio, rows: rowsOf(2)}
+	handler := NewTableHandler(map[string]datasource.DataSource{"DATAWAREHOUSE": dremio}, testLimits,
+		config.GetDefaultSecurityConfig, zap.NewNop())
+
+	r := chi.NewRouter()
+	r.Get("/sources/{source}/tables/{table}/rows", handler.Rows)
+	get := func(url string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		return rec
+	}
+	url := "/sources/datawarehouse/tables/nessie_iceberg.tender_data/rows"
+
+	rec := get(url + "?max_age_seconds=30")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, 30*time.Second, dremio.opts.MaxAge)
+	assert.NotNil(t, decodeResponse(t, rec).Meta.AgeSeconds)
+
+	assert.Equal(t, http.StatusBadRequest, get(url+"?max_age_seconds=soon").Code)
+}
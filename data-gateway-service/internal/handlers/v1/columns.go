@@ -0,0 +1,174 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/response"
+)
+
+// ErrCodeUnknownColumn is returned when a request names a column the table
+// does not have
+const ErrCodeUnknownColumn = "UNKNOWN_COLUMN"
+
+// SchemaProvider lists the columns of a table, e.g. from the Dremio catalog
+type SchemaProvider interface {
+	TableColumns(ctx context.Context, table string) ([]string, error)
+}
+
+// UnknownColumnError reports a column missing from a table's schema
+type UnknownColumnError struct {
+	Table  string
+	Column string
+	Valid  []string
+}
+
+func (e *UnknownColumnError) Error() string {
+	return fmt.Sprintf("unknown column %q for %s", e.Column, e.Table)
+}
+
+// UnknownColumnDetails is the error.details of an UNKNOWN_COLUMN response
+type UnknownColumnDetails struct {
+	Column       string   `json:"column"`
+	ValidColumns []string `json:"valid_columns"`
+}
+
+// ColumnCatalog keeps the column set of each table, fetched at startup and
+// refreshed periodically. A table whose schema has never been fetched is not
+// validated, so a catalog outage degrades to accepting any column. A nil
+// catalog validates nothing.
+type ColumnCatalog struct {
+	provider SchemaProvider
+	tables   []string
+	interval time.Duration
+	logger   *zap.Logger
+
+	mu      sync.RWMutex
+	columns map[string]map[string]bool
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewColumnCatalog creates a catalog of the given tables
+func NewColumnCatalog(provider SchemaProvider, tables []string, interval time.Duration, logger *zap.Logger) *ColumnCatalog {
+	return &ColumnCatalog{
+		provider: provider,
+		tables:   tables,
+		interval: interval,
+		logger:   logger,
+		columns:  make(map[string]map[string]bool),
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start fetches the schemas in the background and then refreshes them every
+// interval. It does not wait for the first fetch.
+func (c *ColumnCatalog) Start() {
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+
+		for {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			c.Refresh(ctx)
+			cancel()
+
+			select {
+			case <-c.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop ends the refresh loop
+func (c *ColumnCatalog) Stop() {
+	close(c.stop)
+	c.wg.Wait()
+}
+
+// Refresh fetches the schema of every table. A table that fails keeps its
+// previous column set, or stays unvalidated if it never had one.
+func (c *ColumnCatalog) Refresh(ctx context.Context) {
+	for _, table := range c.tables {
+		names, err := c.provider.TableColumns(ctx, table)
+		if err != nil {
+			c.logger.Warn("Failed to fetch table schema, column validation uses the previous schema or is skipped",
+				zap.String("table", table),
+				zap.Error(err))
+			continue
+		}
+
+		set := make(map[string]bool, len(names))
+		for _, name := range names {
+			set[strings.ToLower(name)] = true
+		}
+
+		c.mu.Lock()
+		c.columns[table] = set
+		c.mu.Unlock()
+	}
+}
+
+// Columns returns the known columns of table, sorted, and whether its
+// schema has been fetched
+func (c *ColumnCatalog) Columns(table string) ([]string, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.RLock()
+	set, ok := c.columns[table]
+	c.mu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, true
+}
+
+// Validate reports the first of columns missing from table, or nil. Column
+// names are compared case-insensitively, as Dremio does.
+func (c *ColumnCatalog) Validate(table string, columns ...string) *UnknownColumnError {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.RLock()
+	set, ok := c.columns[table]
+	c.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+
+	for _, column := range columns {
+		if !set[strings.ToLower(column)] {
+			valid, _ := c.Columns(table)
+			return &UnknownColumnError{Table: table, Column: column, Valid: valid}
+		}
+	}
+	return nil
+}
+
+// writeColumnError responds 400 UNKNOWN_COLUMN listing the valid columns
+func writeColumnError(w http.ResponseWriter, err *UnknownColumnError) {
+	response.ErrorWithCode(w, ErrCodeUnknownColumn, "Unknown column: "+err.Column,
+		UnknownColumnDetails{Column: err.Column, ValidColumns: err.Valid}, http.StatusBadRequest)
+}
@@ -0,0 +1,159 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/datasource"
+)
+
+// fakeSchemaProvider serves fixed columns per table, or err for every table
+type fakeSchemaProvider struct {
+	mu      sync.Mutex
+	columns map[string][]string
+	err     error
+	calls   int
+}
+
+func (p *fakeSchemaProvider) TableColumns(ctx context.Context, table string) ([]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+	if p.err != nil {
+		return nil, p.err
+	}
+	columns, ok := p.columns[table]
+	if !ok {
+		return nil, errors.New("table not found")
+	}
+	return columns, nil
+}
+
+func (p *fakeSchemaProvider) fail(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.err = err
+}
+
+func tenderCatalog(provider SchemaProvider) *ColumnCatalog {
+	catalog := NewColumnCatalog(provider, []string{tenderTable}, time.Hour, zap.NewNop())
+	catalog.Refresh(context.Background())
+	return catalog
+}
+
+func TestColumnCatalog_Validate(t *testing.T) {
+	provider := &fakeSchemaProvider{columns: map[string][]string{
+		tenderTable: {"tender_id", "Nilai_Pagu", "status_tender"},
+	}}
+	catalog := tenderCatalog(provider)
+
+	assert.Nil(t, catalog.Validate(tenderTable, "tender_id", "NILAI_PAGU"))
+
+	err := catalog.Validate(tenderTable, "tender_id", "password")
+	require.NotNil(t, err)
+	assert.Equal(t, "password", err.Column)
+	assert.Equal(t, []string{"nilai_pagu", "status_tender", "tender_id"}, err.Valid)
+
+	// Tables without a fetched schema are not validated
+	assert.Nil(t, catalog.Validate("nessie_iceberg.other", "anything"))
+
+	var disabled *ColumnCatalog
+	assert.Nil(t, disabled.Validate(tenderTable, "anything"))
+}
+
+func TestColumnCatalog_FetchFailures(t *testing.T) {
+	provider := &fakeSchemaProvider{err: errors.New("dremio unreachable")}
+	catalog := tenderCatalog(provider)
+
+	// Never fetched: permissive
+	assert.Nil(t, catalog.Validate(tenderTable, "password"))
+
+	provider.fail(nil)
+	provider.columns = map[string][]string{tenderTable: {"tender_id"}}
+	catalog.Refresh(context.Background())
+	assert.NotNil(t, catalog.Validate(tenderTable, "password"))
+
+	// A failed refresh keeps the last fetched schema
+	provider.fail(errors.New("dremio unreachable"))
+	catalog.Refresh(context.Background())
+	assert.NotNil(t, catalog.Validate(tenderTable, "password"))
+	assert.Nil(t, catalog.Validate(tenderTable, "tender_id"))
+}
+
+func TestColumnCatalog_StartDoesNotBlock(t *testing.T) {
+	provider := &fakeSchemaProvider{columns: map[string][]string{tenderTable: {"tender_id"}}}
+	catalog := NewColumnCatalog(provider, []string{tenderTable}, time.Hour, zap.NewNop())
+	catalog.Start()
+	defer catalog.Stop()
+
+	require.Eventually(t, func() bool {
+		_, ok := catalog.Columns(tenderTable)
+		return ok
+	}, time.Second, time.Millisecond)
+}
+
+func TestColumnCatalog_DremioCatalog(t *testing.T) {
+	var requests int32
+	catalog := tenderCatalog(fakeDremioREST(t, &requests))
+
+	columns, ok := catalog.Columns(tenderTable)
+	require.True(t, ok)
+	assert.Equal(t, []string{"nilai_pagu", "tanggal_buat_paket", "tender_id"}, columns)
+}
+
+func TestTender_UnknownColumnsRejected(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio}
+	provider := &fakeSchemaProvider{columns: map[string][]string{
+		tenderTable: {"tender_id", "nilai_pagu", "status_tender", "tanggal_buat_paket"},
+	}}
+	handler := NewTenderHandler(source, testLimits, tenderCatalog(provider), zap.NewNop())
+
+	list := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.List(rec, httptest.NewRequest(http.MethodGet, "/api/v1/tender"+query, nil))
+		return rec
+	}
+	search := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.Search(rec, httptest.NewRequest(http.MethodPost, "/api/v1/tender/search", bytes.NewBufferString(body)))
+		return rec
+	}
+
+	assert.Equal(t, http.StatusOK, list("?sort_by=nilai_pagu").Code)
+	assert.Equal(t, http.StatusOK, search(`{"filters": [{"field": "status_tender", "op": "eq", "value": "Selesai"}], "limit": 5}`).Code)
+
+	source.query = ""
+	rec := list("?sort_by=nilai_paguu")
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Empty(t, source.query)
+
+	body := decodeResponse(t, rec)
+	assert.Equal(t, ErrCodeUnknownColumn, body.Error.Code)
+	assert.Equal(t, map[string]interface{}{
+		"column":        "nilai_paguu",
+		"valid_columns": []interface{}{"nilai_pagu", "status_tender", "tanggal_buat_paket", "tender_id"},
+	}, body.Error.Details)
+
+	// Declared in the security config but not in the table
+	rec = search(`{"filters": [{"field": "status_tender", "op": "eq", "value": "Selesai"}, {"field": "nama_kl", "op": "eq", "value": "x"}]}`)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, ErrCodeUnknownColumn, decodeResponse(t, rec).Error.Code)
+	assert.Empty(t, source.query)
+
+	// With the catalog unreachable from the start, any column is accepted
+	permissive := NewTenderHandler(source, testLimits,
+		tenderCatalog(&fakeSchemaProvider{err: errors.New("dremio unreachable")}), zap.NewNop())
+	rec = httptest.NewRecorder()
+	permissive.List(rec, httptest.NewRequest(http.MethodGet, "/api/v1/tender?sort_by=nilai_paguu", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
@@ -0,0 +1,118 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/cache"
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/sqltext"
+	"go-data-gateway/internal/tenant"
+)
+
+// CountResult is the data of a count_only request: how many rows the query
+// or search matches, without the rows
+type CountResult struct {
+	Count        int64 `json:"count"`
+	QueryTimeMs  int64 `json:"query_time_ms"`
+	BytesScanned int64 `json:"bytes_scanned,omitempty"` // By a BigQuery count that was not cached
+	CacheHit     bool  `json:"cache_hit"`
+}
+
+// countCacheTTL is the cache TTL of a count_only query: the one the request
+// asked for, else the longer of the source's and countTTL
+func countCacheTTL(requested *int, ttl, countTTL time.Duration) time.Duration {
+	if requested != nil || ttl >= countTTL {
+		return ttl
+	}
+	return countTTL
+}
+
+// readCount returns the count in column of the single row of a count query
+func readCount(rows []map[string]interface{}, column string) (int64, error) {
+	if len(rows) != 1 {
+		return 0, fmt.Errorf("count query returned %d rows", len(rows))
+	}
+	switch v := rows[0][column].(type) {
+	case int64:
+		return v, nil
+	case nil:
+		return 0, fmt.Errorf("count query returned no %s", column)
+	default:
+		// Counts read back from the cache are JSON numbers
+		f, err := toFloat(v)
+		return int64(f), err
+	}
+}
+
+// scannedBytes dry-runs a BigQuery count for the bytes it scanned. The count
+// has already run, so a failed estimate is logged and reported as none.
+func scannedBytes(ctx context.Context, estimator costEstimator, query string, logger *zap.Logger) int64 {
+	if estimator == nil {
+		return 0
+	}
+	estimate, err := estimator.EstimateQueryCost(ctx, query)
+	if err != nil {
+		logger.Warn("Failed to estimate count cost", zap.Error(err))
+		return 0
+	}
+	return estimate.EstimatedBytes
+}
+
+// countCache keeps the counts of endpoints that query BigQuery directly
+// rather than through a cached data source
+type countCache struct {
+	cache  cache.Cache
+	ttl    time.Duration
+	logger *zap.Logger
+}
+
+func newCountCache(logger *zap.Logger) *countCache {
+	return &countCache{cache: &cache.NoOpCache{}, ttl: config.DefaultCountCacheTTL, logger: logger}
+}
+
+// get returns the cached count of query
+func (c *countCache) get(ctx context.Context, query string) (int64, bool) {
+	data, err := c.cache.Get(ctx, c.key(ctx, query))
+	if err != nil {
+		if !errors.Is(err, cache.ErrCacheMiss) {
+			c.logger.Warn("Cache read failed, counting on source", zap.Error(err))
+		}
+		return 0, false
+	}
+	count, err := strconv.ParseInt(string(data), 10, 64)
+	return count, err == nil
+}
+
+// set caches the count of query for the count TTL
+func (c *countCache) set(ctx context.Context, query string, count int64) {
+	if err := c.cache.Set(ctx, c.key(ctx, query), []byte(strconv.FormatInt(count, 10)), c.ttl); err != nil {
+		c.logger.Warn("Cache write failed", zap.Error(err))
+	}
+}
+
+// key is the cache key of the count of query, in the namespace of the
+// request's tenant like the data source caches. Queries that differ only in
+// comments and layout share it.
+func (c *countCache) key(ctx context.Context, query string) string {
+	prefix := "count"
+	if t, ok := tenant.FromContext(ctx); ok && t.CacheNamespace != "" {
+		prefix = t.CacheNamespace + ":" + prefix
+	}
+	return cache.GenerateKey(prefix, sqltext.Normalize(query, sqltext.BigQuery))
+}
+
+// configure sets the TTL of counts and their cache; a nil cache caches
+// nothing
+func (c *countCache) configure(ttl time.Duration, counts cache.Cache) {
+	c.ttl = ttl
+	if counts == nil {
+		counts = &cache.NoOpCache{}
+	}
+	c.cache = counts
+}
@@ -0,0 +1,152 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go-data-gateway/internal/cache"
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+)
+
+// postCount posts body to handler and decodes the count it answers with
+func postCount(t *testing.T, handler http.HandlerFunc, path, body string) (*httptest.ResponseRecorder, CountResult, map[string]interface{}) {
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(body)))
+
+	var resp struct {
+		Data CountResult            `json:"data"`
+		Meta map[string]interface{} `json:"meta"`
+	}
+	if rec.Code == http.StatusOK {
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	}
+	return rec, resp.Data, resp.Meta
+}
+
+func TestQuery_CountOnly(t *testing.T) {
+	bq := &recordingSource{sourceType: datasource.DataSourceBigQuery, rows: []map[string]interface{}{{"row_count": int64(1234)}}}
+	cached := cache.NewCachedDataSource(bq, cache.NewMemoryCache(), zap.NewNop())
+	handler := NewQueryHandler(map[string]datasource.DataSource{"BIGQUERY": cached}, testLimits, nil, false, zap.NewNop())
+	handler.SetAutoLimit(10000)
+	estimator := &fixedEstimator{bytes: 5 << 20}
+	handler.estimator = estimator
+
+	sql := "WITH recent AS (SELECT * FROM rup WHERE tahun = 2025 LIMIT 100)\nSELECT * FROM recent; -- newest"
+	body := queryBody(t, map[string]interface{}{"sql": sql, "source": "BIGQUERY", "count_only": true})
+	rec, data, meta := postCount(t, handler.Execute, "/api/v1/query", body)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	// The CTE is counted whole; no limit is injected around the count
+	wrapped := "SELECT COUNT(*) AS row_count FROM (\n" +
+		"WITH recent AS (SELECT * FROM rup WHERE tahun = 2025 LIMIT 100)\nSELECT * FROM recent\n) AS counted"
+	assert.Equal(t, wrapped, bq.query)
+	assert.Equal(t, config.DefaultCountCacheTTL, bq.opts.CacheTTL)
+	assert.NotContains(t, meta, "limit_injected")
+	assert.Equal(t, int64(1234), data.Count)
+	assert.Equal(t, int64(5<<20), data.BytesScanned)
+	assert.False(t, data.CacheHit)
+	assert.Equal(t, wrapped, estimator.query)
+
+	// The count is cached apart from the rows, and a cached count scans nothing
+	bq.query = ""
+	rec, data, _ = postCount(t, handler.Execute, "/api/v1/query", body)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, bq.query)
+	assert.True(t, data.CacheHit)
+	assert.Equal(t, int64(1234), data.Count)
+	assert.Zero(t, data.BytesScanned)
+
+	// A TTL the request sets is kept
+	body = queryBody(t, map[string]interface{}{"sql": "SELECT 1", "source": "BIGQUERY", "count_only": true, "cache_ttl_seconds": 60})
+	rec, _, _ = postCount(t, handler.Execute, "/api/v1/query", body)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, time.Minute, bq.opts.CacheTTL)
+}
+
+func TestQuery_CountOnlyRejections(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio}
+	for _, fields := range []map[string]interface{}{
+		{"sql": "SHOW TABLES", "source": "DATAWAREHOUSE", "count_only": true},
+		{"sql": "SELECT 1; SELECT 2", "source": "DATAWAREHOUSE", "count_only": true},
+		{"sql": "SELECT 1", "source": "DATAWAREHOUSE", "count_only": true, "validate_only": true},
+	} {
+		code, _, _ := queryError(t, source, queryBody(t, fields))
+		assert.Equal(t, http.StatusBadRequest, code, fields["sql"])
+		assert.Empty(t, source.query)
+	}
+}
+
+func TestQuery_CountOnlyKeepsErrorPosition(t *testing.T) {
+	source := &failingSource{
+		recordingSource: recordingSource{sourceType: datasource.DataSourceDremio},
+		err: datasource.ClassifyDremioError(status.Error(codes.InvalidArgument,
+			"PARSE ERROR: Encountered \"FORM\" at line 2, column 10.")),
+	}
+	code, _, details := queryError(t, source, `{"sql": "SELECT * FORM tender_data", "source": "DATAWAREHOUSE", "count_only": true}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	var position QueryErrorDetails
+	require.NoError(t, json.Unmarshal(details, &position))
+	assert.Equal(t, 1, position.Line)
+	assert.Equal(t, 10, position.Column)
+}
+
+func TestTenderSearch_CountOnly(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio, rows: []map[string]interface{}{{"total": int64(17)}}}
+	handler := NewTenderHandler(source, testLimits, nil, zap.NewNop())
+	handler.SetKeywordSearch(config.KeywordSearch{Columns: []string{"nama_paket"}, MaxLength: 20, MaxTerms: 2})
+
+	body := `{"keyword": "jalan", "filters": [{"field": "tahun_anggaran", "op": "eq", "value": 2025}], "limit": 5, "count_only": true}`
+	rec, data, meta := postCount(t, handler.Search, "/api/v1/tender/search", body)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	// Every filter and the keyword narrow the count; the limit does not
+	assert.True(t, strings.HasPrefix(source.query, "SELECT COUNT(*) AS total FROM nessie_iceberg.tender_data WHERE "), source.query)
+	assert.Contains(t, source.query, "tahun_anggaran = 2025")
+	assert.Contains(t, source.query, `LOWER(nama_paket) LIKE LOWER('%jalan%')`)
+	assert.NotContains(t, source.query, "LIMIT")
+	assert.Equal(t, config.DefaultCountCacheTTL, source.opts.CacheTTL)
+	assert.Equal(t, int64(17), data.Count)
+	assert.NotContains(t, meta, "limit")
+}
+
+func TestRUP_SearchCountOnly(t *testing.T) {
+	handler, querier := newTestRUPHandler()
+	estimator := &fixedEstimator{bytes: 2 << 30}
+	handler.SetCountOnly(time.Hour, cache.NewMemoryCache(), nil)
+	handler.estimator = estimator
+
+	body := `{"tahun": "2024", "min_pagu": 1000000, "limit": 10, "count_only": true}`
+	rec, data, meta := postCount(t, handler.Search, "/api/v1/rup/search", body)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	// Only the count runs, with the search's filters and the deleted filter
+	require.Len(t, querier.queries, 1)
+	assert.Contains(t, querier.queries[0], "COUNT(*) AS total")
+	assert.Contains(t, querier.queries[0], "WHERE tahun_anggaran = 2024 AND pagu_kro >= 1000000 AND is_deleted = FALSE")
+	assert.NotContains(t, querier.queries[0], "LIMIT")
+	assert.Equal(t, querier.queries[0], estimator.query)
+	assert.Equal(t, int64(42), data.Count)
+	assert.Equal(t, int64(2<<30), data.BytesScanned)
+	assert.Equal(t, true, meta["deleted_filtered"])
+
+	// The count is served from the count cache
+	rec, data, _ = postCount(t, handler.Search, "/api/v1/rup/search", body)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Len(t, querier.queries, 1)
+	assert.True(t, data.CacheHit)
+	assert.Equal(t, int64(42), data.Count)
+	assert.Zero(t, data.BytesScanned)
+}
@@ -0,0 +1,106 @@
+package v1
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-data-gateway/internal/auth"
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/response"
+)
+
+// sqlDebugMode is what a request asked to see of the SQL an endpoint builds
+type sqlDebugMode int
+
+const (
+	sqlDebugOff    sqlDebugMode = iota
+	sqlDebugMeta                // debug_sql=true: run the query and add its SQL to meta
+	sqlDebugDryRun              // dry_run=true: return the SQL and cache key without running it
+)
+
+// builtQuery is a statement an endpoint built, with the request values the
+// sanitizer quoted into it listed separately
+type builtQuery struct {
+	SQL      string
+	CountSQL string   // Query of the total, for endpoints reporting one
+	Order    []string // ORDER BY terms, for paged lists reporting them
+	Params   map[string]interface{}
+}
+
+// sqlDebug reads the debug_sql and dry_run parameters. Only keys with the
+// debug scope may set them; other keys get 403. Debug responses are marked
+// no-store, as they show how the gateway queries its sources.
+func sqlDebug(w http.ResponseWriter, r *http.Request) (mode sqlDebugMode, ok bool) {
+	q := r.URL.Query()
+	for _, p := range []struct {
+		name string
+		mode sqlDebugMode
+	}{{"dry_run", sqlDebugDryRun}, {"debug_sql", sqlDebugMeta}} {
+		raw := q.Get(p.name)
+		if raw == "" {
+			continue
+		}
+		on, err := strconv.ParseBool(raw)
+		if err != nil {
+			response.Error(w, p.name+" must be true or false", http.StatusBadRequest)
+			return sqlDebugOff, false
+		}
+		if on && mode == sqlDebugOff {
+			mode = p.mode
+		}
+	}
+	if mode == sqlDebugOff {
+		return sqlDebugOff, true
+	}
+
+	if !auth.HasScope(r.Context(), auth.ScopeDebug) {
+		response.Error(w, "debug_sql and dry_run require a key with the debug scope", http.StatusForbidden)
+		return sqlDebugOff, false
+	}
+	w.Header().Set("Cache-Control", config.NoStore.String())
+	return mode, true
+}
+
+// queryDebug reports built as source would run it with opts
+func queryDebug(ctx context.Context, source datasource.DataSource, built builtQuery, opts *datasource.QueryOptions) (*response.QueryDebug, error) {
+	plan, err := datasource.PlanQuery(ctx, source, built.SQL, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &response.QueryDebug{
+		SQL:      plan.SQL,
+		CountSQL: built.CountSQL,
+		Params:   built.Params,
+		CacheKey: plan.CacheKey,
+	}, nil
+}
+
+// optionParams lists the request values of sanitizer-built query options:
+// the filters by column, and the ordering and page when set
+func optionParams(opts *datasource.QueryOptions) map[string]interface{} {
+	params := make(map[string]interface{}, len(opts.Filters)+4)
+	for column, value := range opts.Filters {
+		params[column] = value
+	}
+	if opts.Keywords != nil {
+		params["keyword"] = keywordParam(opts.Keywords.Terms)
+		params["match"] = keywordMode(opts.Keywords)
+	}
+	if opts.OrderBy != "" {
+		params["order_by"] = opts.OrderBy
+		params["order_dir"] = opts.OrderDir
+	}
+	if opts.Limit > 0 {
+		params["limit"] = opts.Limit
+	}
+	if opts.Offset > 0 {
+		params["offset"] = opts.Offset
+	}
+	if !opts.AsOf.IsZero() {
+		params[asOfParam] = opts.AsOf.UTC().Format(time.RFC3339)
+	}
+	return params
+}
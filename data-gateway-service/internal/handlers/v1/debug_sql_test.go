@@ -0,0 +1,125 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/auth"
+	"go-data-gateway/internal/cache"
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/response"
+)
+
+func asDebugger(r *http.Request) *http.Request {
+	return r.WithContext(auth.WithKey(r.Context(), &auth.APIKey{ID: "key_debug", Scopes: []string{"read", auth.ScopeDebug}}))
+}
+
+// decodeDebug decodes the QueryDebug of a dry run
+func decodeDebug(t *testing.T, rec *httptest.ResponseRecorder) response.QueryDebug {
+	var body struct {
+		Data response.QueryDebug `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	return body.Data
+}
+
+func TestSQLDebug_RequiresDebugScope(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio, rows: rowsOf(1)}
+	tender := NewTenderHandler(source, testLimits, nil, zap.NewNop())
+	rup, querier := newTestRUPHandler()
+	tables := newTableRouter(map[string]datasource.DataSource{"DATAWAREHOUSE": source})
+
+	for _, param := range []string{"debug_sql=true", "dry_run=true"} {
+		requests := []struct {
+			name    string
+			handler http.HandlerFunc
+			req     *http.Request
+		}{
+			{"tender list", tender.List, httptest.NewRequest(http.MethodGet, "/api/v1/tender?"+param, nil)},
+			{"tender search", tender.Search, httptest.NewRequest(http.MethodPost, "/api/v1/tender/search?"+param, strings.NewReader(`{"status_tender": "Selesai"}`))},
+			{"rup list", rup.List, httptest.NewRequest(http.MethodGet, "/api/v1/rup?"+param, nil)},
+			{"rup search", rup.Search, httptest.NewRequest(http.MethodPost, "/api/v1/rup/search?"+param, strings.NewReader(`{"keyword": "jalan"}`))},
+			{"table rows", tables.ServeHTTP, httptest.NewRequest(http.MethodGet, "/sources/datawarehouse/tables/nessie_iceberg.tender_data/rows?"+param, nil)},
+		}
+		for _, tt := range requests {
+			rec := httptest.NewRecorder()
+			tt.handler(rec, asReader(tt.req))
+			assert.Equal(t, http.StatusForbidden, rec.Code, "%s %s", tt.name, param)
+		}
+	}
+	assert.Empty(t, source.query, "nothing runs for a denied debug request")
+	assert.Empty(t, querier.queries)
+
+	rec := httptest.NewRecorder()
+	tender.List(rec, asDebugger(httptest.NewRequest(http.MethodGet, "/api/v1/tender?debug_sql=maybe", nil)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestSQLDebug_MetaKeepsResults(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio, rows: rowsOf(2)}
+	handler := NewTenderHandler(source, testLimits, nil, zap.NewNop())
+
+	rec := httptest.NewRecorder()
+	handler.List(rec, asDebugger(httptest.NewRequest(http.MethodGet, "/api/v1/tender?status=Selesai&debug_sql=true", nil)))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	body := decodeResponse(t, rec)
+	assert.Len(t, body.Data, 2)
+	require.NotNil(t, body.Meta.Debug)
+	assert.Equal(t, source.query, body.Meta.Debug.SQL)
+	assert.Contains(t, body.Meta.Debug.SQL, "status_tender = 'Selesai'")
+	assert.Equal(t, "Selesai", body.Meta.Debug.Params["status_tender"])
+	assert.Equal(t, float64(testLimits.Default), body.Meta.Debug.Params["limit"])
+	assert.Equal(t, config.NoStore.String(), rec.Header().Get("Cache-Control"))
+
+	// Without debug_sql the meta has no SQL
+	rec = httptest.NewRecorder()
+	handler.List(rec, asDebugger(httptest.NewRequest(http.MethodGet, "/api/v1/tender?status=Selesai", nil)))
+	assert.Nil(t, decodeResponse(t, rec).Meta.Debug)
+}
+
+func TestSQLDebug_DryRunDoesNotExecute(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio, rows: rowsOf(2)}
+	cached := cache.NewNamespacedCachedDataSource(source, nil, "tenant-a", zap.NewNop())
+
+	tender := NewTenderHandler(cached, testLimits, nil, zap.NewNop())
+	rec := httptest.NewRecorder()
+	tender.Search(rec, asDebugger(httptest.NewRequest(http.MethodPost, "/api/v1/tender/search?dry_run=true",
+		strings.NewReader(`{"filters": [{"field": "nama_paket", "op": "eq", "value": "x' OR '1'='1"}]}`))))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	debug := decodeDebug(t, rec)
+	assert.Contains(t, debug.SQL, "nama_paket = 'x'' OR ''1''=''1'")
+	assert.Equal(t, []interface{}{map[string]interface{}{"field": "nama_paket", "op": "eq", "value": "x' OR '1'='1"}}, debug.Params["filters"])
+	assert.True(t, strings.HasPrefix(debug.CacheKey, "gateway:tenant-a:query:"), debug.CacheKey)
+
+	tables := newTableRouter(map[string]datasource.DataSource{"DATAWAREHOUSE": cached})
+	rec = httptest.NewRecorder()
+	tables.ServeHTTP(rec, asDebugger(httptest.NewRequest(http.MethodGet,
+		"/sources/datawarehouse/tables/nessie_iceberg.tender_data/rows?dry_run=true&limit=20&filter=tahun_anggaran=2025", nil)))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	debug = decodeDebug(t, rec)
+	assert.Equal(t, "SELECT * FROM nessie_iceberg.tender_data WHERE tahun_anggaran = 2025 ORDER BY tender_id ASC LIMIT 20", debug.SQL)
+	assert.True(t, strings.HasPrefix(debug.CacheKey, "gateway:tenant-a:table:"), debug.CacheKey)
+
+	rup, querier := newTestRUPHandler()
+	rec = httptest.NewRecorder()
+	rup.Search(rec, asDebugger(httptest.NewRequest(http.MethodPost, "/api/v1/rup/search?dry_run=true",
+		strings.NewReader(`{"keyword": "jalan", "tahun": "2025"}`))))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	debug = decodeDebug(t, rec)
+	assert.Contains(t, debug.SQL, "tahun_anggaran = 2025")
+	assert.Contains(t, debug.CountSQL, "COUNT(*)")
+	assert.Equal(t, "jalan", debug.Params["keyword"])
+	assert.Empty(t, debug.CacheKey, "RUP results are not cached")
+
+	assert.Empty(t, source.query)
+	assert.Empty(t, querier.queries)
+}
@@ -0,0 +1,272 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/response"
+	"go-data-gateway/internal/snapshot"
+	"go-data-gateway/internal/sqltext"
+	"go-data-gateway/internal/tenant"
+)
+
+// DiffRequest is the body of POST /api/v1/diff. Exactly one of SQL and Table
+// is set; Filters only applies to Table.
+type DiffRequest struct {
+	Source     string                 `json:"source"` // Data source name, e.g. DATAWAREHOUSE
+	SQL        string                 `json:"sql,omitempty"`
+	Table      string                 `json:"table,omitempty"`
+	Filters    map[string]interface{} `json:"filters,omitempty"`
+	KeyColumns []string               `json:"key_columns"`
+
+	// Base labels the snapshot the result is compared against; when empty
+	// every row is reported as added. Label names the snapshot the result is
+	// stored under, and may equal Base for a rolling snapshot.
+	Base  string `json:"base,omitempty"`
+	Label string `json:"label"`
+
+	// Output orders, deduplicates and pages the added, removed and changed
+	// rows in the gateway; changed rows by their current values. Counts
+	// are of the whole diff.
+	Output *datasource.PostProcess `json:"output,omitempty"`
+}
+
+// DiffResponse is the data of POST /api/v1/diff
+type DiffResponse struct {
+	Base          string     `json:"base,omitempty"`
+	BaseCreatedAt *time.Time `json:"base_created_at,omitempty"`
+	Label         string     `json:"label"`
+	KeyColumns    []string   `json:"key_columns"`
+	snapshot.Diff
+}
+
+// DiffHandler compares query results with stored snapshots
+type DiffHandler struct {
+	dataSources map[string]datasource.DataSource
+	store       snapshot.Store
+	maxRows     int
+	postProcess config.PostProcessConfig // Bounds the diffs Output applies to
+	security    config.SecurityProvider
+	logger      *zap.Logger
+}
+
+// NewDiffHandler creates a new diff handler
+func NewDiffHandler(dataSources map[string]datasource.DataSource, store snapshot.Store, cfg config.DiffConfig, security config.SecurityProvider, logger *zap.Logger) *DiffHandler {
+	return &DiffHandler{
+		dataSources: dataSources,
+		store:       store,
+		maxRows:     cfg.MaxRows,
+		postProcess: config.DefaultPostProcess(),
+		security:    security,
+		logger:      logger,
+	}
+}
+
+// SetPostProcess sets the row and memory caps of the diffs output orders
+// and pages
+func (h *DiffHandler) SetPostProcess(cfg config.PostProcessConfig) {
+	h.postProcess = cfg
+}
+
+// Diff handles POST /api/v1/diff
+func (h *DiffHandler) Diff(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req DiffRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	security := h.security()
+	if err := h.validate(&req, security); err != nil {
+		response.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tenantID := tenant.DefaultID
+	if t, ok := tenant.FromContext(ctx); ok {
+		tenantID = t.ID
+	}
+
+	sourceName := strings.ToUpper(req.Source)
+	source, ok := h.dataSources[sourceName]
+	if !ok {
+		response.Error(w, fmt.Sprintf("Unknown data source: %s", sourceName), http.StatusNotFound)
+		return
+	}
+
+	var base *snapshot.Snapshot
+	if req.Base != "" {
+		var err error
+		base, err = h.store.Get(ctx, tenantID, req.Base)
+		if errors.Is(err, snapshot.ErrNotFound) {
+			response.Error(w, fmt.Sprintf("Snapshot %s not found", req.Base), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			h.logger.Error("Failed to read snapshot", zap.String("label", req.Base), zap.Error(err))
+			response.Error(w, "Failed to read snapshot", http.StatusInternalServerError)
+			return
+		}
+		if strings.Join(base.KeyColumns, ",") != strings.Join(req.KeyColumns, ",") {
+			response.ErrorWithDetails(w, "Key columns differ from the base snapshot",
+				fmt.Sprintf("base key_columns=%s", strings.Join(base.KeyColumns, ",")), http.StatusConflict)
+			return
+		}
+	}
+
+	// Diffs compare against the source as it is now, never a cached result;
+	// one row over the limit is fetched to detect oversized results
+	opts := &datasource.QueryOptions{
+		Limit:     h.maxRows + 1,
+		Filters:   req.Filters,
+		Timeout:   datasource.Defaults(source).Timeout,
+		SkipCache: true,
+	}
+	var result *datasource.QueryResult
+	var err error
+	query := req.SQL
+	if req.Table != "" {
+		if !security.IsTableAllowed(req.Table, securitySource(source.GetType())) {
+			response.Error(w, fmt.Sprintf("Table %s is not allowed for %s", req.Table, sourceName), http.StatusForbidden)
+			return
+		}
+		query = req.Table
+		result, err = source.GetData(ctx, req.Table, opts)
+	} else {
+		result, err = source.ExecuteQuery(ctx, req.SQL, opts)
+	}
+	if err != nil {
+		h.logger.Error("Diff query failed",
+			zap.String("source", sourceName),
+			zap.Error(err))
+		if !writeQueryError(w, err, req.SQL, "Diff query failed") {
+			response.ErrorWithDetails(w, "Diff query failed", err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if len(result.Data) > h.maxRows {
+		response.ErrorWithDetails(w, "Result is too large to diff",
+			fmt.Sprintf("max_rows=%d", h.maxRows), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	current, err := snapshot.New(tenantID, req.Label, sourceName, query, req.KeyColumns, result.Data)
+	if err != nil {
+		response.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if base == nil {
+		base = &snapshot.Snapshot{KeyColumns: req.KeyColumns}
+	}
+	diff := snapshot.Compare(base, current)
+	if req.Output != nil {
+		if err := h.output(diff, *req.Output); err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, datasource.ErrPostProcessLimit) {
+				status = http.StatusRequestEntityTooLarge
+			}
+			response.Error(w, "output: "+err.Error(), status)
+			return
+		}
+	}
+
+	if err := h.store.Put(ctx, current); err != nil {
+		h.logger.Error("Failed to store snapshot", zap.String("label", req.Label), zap.Error(err))
+		response.Error(w, "Failed to store snapshot", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("Diff computed",
+		zap.String("source", sourceName),
+		zap.String("base", req.Base),
+		zap.String("label", req.Label),
+		zap.Int("added", diff.Counts.Added),
+		zap.Int("removed", diff.Counts.Removed),
+		zap.Int("changed", diff.Counts.Changed))
+
+	data := DiffResponse{
+		Base:       req.Base,
+		Label:      req.Label,
+		KeyColumns: req.KeyColumns,
+		Diff:       *diff,
+	}
+	if req.Base != "" {
+		data.BaseCreatedAt = &base.CreatedAt
+	}
+	response.Success(w, data, nil)
+}
+
+// output applies p to each list of diff
+func (h *DiffHandler) output(diff *snapshot.Diff, p datasource.PostProcess) error {
+	var err error
+	if diff.Added, err = p.Apply(diff.Added, nil, h.postProcess); err != nil {
+		return err
+	}
+	if diff.Removed, err = p.Apply(diff.Removed, nil, h.postProcess); err != nil {
+		return err
+	}
+	after := make([]map[string]interface{}, len(diff.Changed))
+	for i, change := range diff.Changed {
+		after[i] = change.After
+	}
+	indexes, err := p.Indexes(after, nil, h.postProcess)
+	if err != nil {
+		return err
+	}
+	changed := make([]snapshot.Change, len(indexes))
+	for i, index := range indexes {
+		changed[i] = diff.Changed[index]
+	}
+	diff.Changed = changed
+	return nil
+}
+
+// validate checks the shape of req; table access is checked once the source
+// is known
+func (h *DiffHandler) validate(req *DiffRequest, security *config.SecurityConfig) error {
+	if (req.SQL == "") == (req.Table == "") {
+		return fmt.Errorf("exactly one of sql and table is required")
+	}
+	if req.SQL != "" && len(req.Filters) > 0 {
+		return fmt.Errorf("filters only apply to table")
+	}
+	for column := range req.Filters {
+		if _, ok := security.Column(req.Table, column); !ok {
+			return fmt.Errorf("cannot filter on column %q", column)
+		}
+	}
+	if len(req.KeyColumns) == 0 {
+		return fmt.Errorf("key_columns is required")
+	}
+	if err := snapshot.ValidateLabel(req.Label); err != nil {
+		return err
+	}
+	if req.Base != "" {
+		if err := snapshot.ValidateLabel(req.Base); err != nil {
+			return err
+		}
+	}
+	if req.Output != nil {
+		if err := req.Output.Validate(); err != nil {
+			return fmt.Errorf("output: %w", err)
+		}
+		// Rows the query ordered are never silently re-sorted
+		dialect := sqltext.Standard
+		if source, ok := h.dataSources[strings.ToUpper(req.Source)]; ok {
+			dialect = source.GetType().Dialect()
+		}
+		if len(req.Output.OrderBy) > 0 && datasource.HasTopLevelOrderBy(req.SQL, dialect) {
+			return fmt.Errorf("output.order_by cannot re-sort a query with an ORDER BY; remove one of them")
+		}
+	}
+	return nil
+}
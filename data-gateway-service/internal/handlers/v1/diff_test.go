@@ -0,0 +1,166 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/cache"
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/snapshot"
+	"go-data-gateway/internal/tenant"
+)
+
+func newDiffHandler(source datasource.DataSource, store snapshot.Store, maxRows int) *DiffHandler {
+	return NewDiffHandler(map[string]datasource.DataSource{"DATAWAREHOUSE": source}, store,
+		config.DiffConfig{MaxRows: maxRows}, config.GetDefaultSecurityConfig, zap.NewNop())
+}
+
+func postDiff(t *testing.T, handler *DiffHandler, tenantID, body string) (*httptest.ResponseRecorder, DiffResponse) {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/diff", bytes.NewBufferString(body))
+	req = req.WithContext(tenant.WithTenant(req.Context(), &tenant.Tenant{ID: tenantID}))
+	rec := httptest.NewRecorder()
+	handler.Diff(rec, req)
+
+	var resp struct {
+		Data DiffResponse `json:"data"`
+	}
+	if rec.Code == http.StatusOK {
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	}
+	return rec, resp.Data
+}
+
+func TestDiff_ComparesAgainstStoredSnapshot(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio, rows: []map[string]interface{}{
+		{"kode_tender": "T1", "status_tender": "Aktif"},
+		{"kode_tender": "T2", "status_tender": "Aktif"},
+	}}
+	store := snapshot.NewCacheStore(cache.NewMemoryCache(), time.Hour)
+	handler := newDiffHandler(source, store, 100)
+
+	// Without a base every row is added and the result becomes day1
+	rec, data := postDiff(t, handler, "default", `{"source": "datawarehouse", "table": "nessie_iceberg.tender_data",
+		"filters": {"tahun_anggaran": 2025}, "key_columns": ["kode_tender"], "label": "day1"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, snapshot.Counts{Added: 2}, data.Counts)
+	assert.True(t, source.opts.SkipCache)
+	assert.Equal(t, 101, source.opts.Limit)
+	assert.Equal(t, map[string]interface{}{"tahun_anggaran": float64(2025)}, source.opts.Filters)
+
+	source.rows = []map[string]interface{}{
+		{"kode_tender": "T2", "status_tender": "Selesai"},
+		{"kode_tender": "T3", "status_tender": "Aktif"},
+	}
+	rec, data = postDiff(t, handler, "default", `{"source": "DATAWAREHOUSE", "table": "nessie_iceberg.tender_data",
+		"key_columns": ["kode_tender"], "base": "day1", "label": "day2"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, snapshot.Counts{Added: 1, Removed: 1, Changed: 1}, data.Counts)
+	assert.Equal(t, "T3", data.Added[0]["kode_tender"])
+	assert.Equal(t, "T1", data.Removed[0]["kode_tender"])
+	assert.Equal(t, "Aktif", data.Changed[0].Before["status_tender"])
+	assert.Equal(t, "Selesai", data.Changed[0].After["status_tender"])
+	assert.NotNil(t, data.BaseCreatedAt)
+
+	_, err := store.Get(t.Context(), "default", "day2")
+	assert.NoError(t, err)
+}
+
+func TestDiff_Errors(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio, rows: rowsOf(3)}
+	store := snapshot.NewCacheStore(cache.NewMemoryCache(), time.Hour)
+	handler := newDiffHandler(source, store, 2)
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"sql and table", `{"source": "DATAWAREHOUSE", "sql": "SELECT 1", "table": "t", "key_columns": ["id"], "label": "a"}`, http.StatusBadRequest},
+		{"no key columns", `{"source": "DATAWAREHOUSE", "sql": "SELECT 1", "label": "a"}`, http.StatusBadRequest},
+		{"bad label", `{"source": "DATAWAREHOUSE", "sql": "SELECT 1", "key_columns": ["id"], "label": "a/b"}`, http.StatusBadRequest},
+		{"undeclared filter", `{"source": "DATAWAREHOUSE", "table": "nessie_iceberg.tender_data", "filters": {"secret": 1}, "key_columns": ["id"], "label": "a"}`, http.StatusBadRequest},
+		{"unknown source", `{"source": "NOPE", "sql": "SELECT 1", "key_columns": ["id"], "label": "a"}`, http.StatusNotFound},
+		{"table not allowed", `{"source": "DATAWAREHOUSE", "table": "secret_table", "key_columns": ["id"], "label": "a"}`, http.StatusForbidden},
+		{"missing base", `{"source": "DATAWAREHOUSE", "sql": "SELECT 1", "key_columns": ["id"], "base": "none", "label": "a"}`, http.StatusNotFound},
+		{"re-sorting an ordered query", `{"source": "DATAWAREHOUSE", "sql": "SELECT * FROM t ORDER BY id", "key_columns": ["id"], "label": "a", "output": {"order_by": [{"column": "id"}]}}`, http.StatusBadRequest},
+		{"negative output limit", `{"source": "DATAWAREHOUSE", "sql": "SELECT 1", "key_columns": ["id"], "label": "a", "output": {"limit": -1}}`, http.StatusBadRequest},
+		{"too many rows", `{"source": "DATAWAREHOUSE", "sql": "SELECT 1", "key_columns": ["id"], "label": "a"}`, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		rec, _ := postDiff(t, handler, "default", tt.body)
+		assert.Equal(t, tt.status, rec.Code, tt.name)
+	}
+
+	// Rejected requests store nothing
+	infos, err := store.List(t.Context())
+	require.NoError(t, err)
+	assert.Empty(t, infos)
+
+	source.rows = rowsOf(2)
+	rec, _ := postDiff(t, handler, "default", `{"source": "DATAWAREHOUSE", "sql": "SELECT 1", "key_columns": ["id"], "label": "a"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec, _ = postDiff(t, handler, "default", `{"source": "DATAWAREHOUSE", "sql": "SELECT 1", "key_columns": ["name"], "base": "a", "label": "b"}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec, _ = postDiff(t, handler, "default", `{"source": "DATAWAREHOUSE", "sql": "SELECT 1", "key_columns": ["missing"], "label": "b"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+}
+
+func TestDiff_SnapshotsAreScopedToTenant(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio, rows: rowsOf(2)}
+	store := snapshot.NewCacheStore(cache.NewMemoryCache(), time.Hour)
+	handler := newDiffHandler(source, store, 10)
+
+	rec, _ := postDiff(t, handler, "lkpp", `{"source": "DATAWAREHOUSE", "sql": "SELECT 1", "key_columns": ["id"], "label": "day1"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec, _ = postDiff(t, handler, "other", `{"source": "DATAWAREHOUSE", "sql": "SELECT 1", "key_columns": ["id"], "base": "day1", "label": "day2"}`)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestDiff_OutputOrdersAndPagesRows(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio, rows: []map[string]interface{}{
+		{"kode_tender": "T1", "nilai_pagu": 500},
+		{"kode_tender": "T2", "nilai_pagu": nil},
+		{"kode_tender": "T3", "nilai_pagu": 900},
+		{"kode_tender": "T4", "nilai_pagu": 100},
+	}}
+	store := snapshot.NewCacheStore(cache.NewMemoryCache(), time.Hour)
+	handler := newDiffHandler(source, store, 10)
+
+	body := `{"source": "DATAWAREHOUSE", "sql": "SELECT * FROM t", "key_columns": ["kode_tender"], "label": "a",
+		"output": {"order_by": [{"column": "nilai_pagu", "desc": true}], "limit": 3}}`
+	rec, diff := postDiff(t, handler, "default", body)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var added []string
+	for _, row := range diff.Added {
+		added = append(added, row["kode_tender"].(string))
+	}
+	assert.Equal(t, []string{"T3", "T1", "T4"}, added)
+	assert.Equal(t, 4, diff.Counts.Added)
+
+	// The snapshot keeps every row, whatever the output
+	stored, err := store.Get(t.Context(), "default", "a")
+	require.NoError(t, err)
+	assert.Len(t, stored.Rows, 4)
+
+	rec, _ = postDiff(t, handler, "default", `{"source": "DATAWAREHOUSE", "sql": "SELECT * FROM t", "key_columns": ["kode_tender"], "label": "b",
+		"output": {"order_by": [{"column": "missing"}]}}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	handler.SetPostProcess(config.PostProcessConfig{MaxRows: 2})
+	rec, _ = postDiff(t, handler, "default", `{"source": "DATAWAREHOUSE", "sql": "SELECT * FROM t", "key_columns": ["kode_tender"], "label": "b",
+		"output": {"order_by": [{"column": "nilai_pagu"}]}}`)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+}
@@ -0,0 +1,131 @@
+package v1
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/killswitch"
+	"go-data-gateway/internal/response"
+)
+
+// ErrCodeSourceInitializing is returned while a data source that failed at
+// startup is being retried
+const ErrCodeSourceInitializing = "SOURCE_INITIALIZING"
+
+// writeUpstreamError responds with a distinct 4xx when err is a classified
+// upstream error (missing table, permission, syntax), or 503 with Retry-After
+// while the data source is initializing. It reports false when the error is
+// unclassified and the caller should fall back to its generic error. A
+// source switched off responds 503 ENDPOINT_DISABLED.
+func writeUpstreamError(w http.ResponseWriter, err error, message string) bool {
+	if writeUnavailableError(w, err) {
+		return true
+	}
+
+	var upstreamErr *datasource.UpstreamError
+	if !errors.As(err, &upstreamErr) {
+		return false
+	}
+
+	response.ErrorWithCode(w, string(upstreamErr.Class), message, upstreamErr.Message, upstreamErr.StatusCode())
+	return true
+}
+
+// writeUnavailableError responds 503 SOURCE_INITIALIZING when err is a
+// *datasource.InitializingError, and 503 ENDPOINT_DISABLED when it is a
+// *killswitch.DisabledError
+func writeUnavailableError(w http.ResponseWriter, err error) bool {
+	if killswitch.WriteError(w, err) {
+		return true
+	}
+
+	var initErr *datasource.InitializingError
+	if !errors.As(err, &initErr) {
+		return false
+	}
+
+	retryAfter := max(int(math.Ceil(initErr.RetryAfter.Seconds())), 1)
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	response.ErrorWithCode(w, ErrCodeSourceInitializing,
+		"Data source "+initErr.Source+" is initializing, retry later", initErr.Err.Error(), http.StatusServiceUnavailable)
+	return true
+}
+
+// writeStreamError responds to a stream whose first chunk failed, before any
+// of it was written: classified and invalid table queries with their 4xx,
+// anything else with 500
+func writeStreamError(w http.ResponseWriter, err error, query string) {
+	const message = "Stream query failed"
+	switch {
+	case writeQueryError(w, err, query, message):
+	case errors.Is(err, datasource.ErrInvalidTableQuery):
+		response.ErrorWithDetails(w, message, err.Error(), http.StatusBadRequest)
+	default:
+		response.ErrorWithDetails(w, message, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// QueryErrorDetails is the error.details of a query error the upstream located
+type QueryErrorDetails struct {
+	Message string `json:"message"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Snippet string `json:"snippet"`
+}
+
+// writeQueryError is writeUpstreamError for user-submitted SQL: when the
+// upstream reported where the error is, details carry the line, column and a
+// snippet of query instead of the bare message
+func writeQueryError(w http.ResponseWriter, err error, query string, message string) bool {
+	var upstreamErr *datasource.UpstreamError
+	if !errors.As(err, &upstreamErr) {
+		return writeUnavailableError(w, err)
+	}
+
+	pos := upstreamErr.Locate(query)
+	if pos == nil {
+		return writeUpstreamError(w, err, message)
+	}
+
+	details := QueryErrorDetails{
+		Message: upstreamErr.Message,
+		Line:    pos.Line,
+		Column:  pos.Column,
+		Snippet: pos.Snippet,
+	}
+	response.ErrorWithCode(w, string(upstreamErr.Class), message, details, upstreamErr.StatusCode())
+	return true
+}
+
+// ConversionErrorDetails is the error.details of an UPSTREAM_ERROR: a stored
+// value the upstream could not convert to its column's type
+type ConversionErrorDetails struct {
+	Message string `json:"message"`
+	Column  string `json:"column,omitempty"`
+}
+
+// writeConversionError responds 502 UPSTREAM_ERROR with the offending column
+// when err is a conversion failure. Without a column named by the upstream,
+// the first of columns its message mentions is reported.
+func writeConversionError(w http.ResponseWriter, err error, columns []string, message string) bool {
+	var upstreamErr *datasource.UpstreamError
+	if !errors.As(err, &upstreamErr) || upstreamErr.Class != datasource.ErrorClassConversion {
+		return false
+	}
+
+	details := ConversionErrorDetails{Message: upstreamErr.Message, Column: upstreamErr.Column}
+	if details.Column == "" {
+		for _, column := range columns {
+			if regexp.MustCompile(`\b` + regexp.QuoteMeta(column) + `\b`).MatchString(upstreamErr.Message) {
+				details.Column = column
+				break
+			}
+		}
+	}
+	response.ErrorWithCode(w, string(upstreamErr.Class), message, details, upstreamErr.StatusCode())
+	return true
+}
@@ -0,0 +1,40 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/response"
+)
+
+func TestWriteUpstreamError(t *testing.T) {
+	err := &datasource.UpstreamError{
+		Class:   datasource.ErrorClassTableNotFound,
+		Source:  datasource.DataSourceDremio,
+		Message: "Object 'missing' not found",
+	}
+
+	rec := httptest.NewRecorder()
+	require.True(t, writeUpstreamError(rec, err, "Query execution failed"))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	var body response.StandardResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.NotNil(t, body.Error)
+	assert.Equal(t, "TABLE_NOT_FOUND", body.Error.Code)
+	assert.Equal(t, "Query execution failed", body.Error.Message)
+	assert.Equal(t, "Object 'missing' not found", body.Error.Details)
+}
+
+func TestWriteUpstreamError_Unclassified(t *testing.T) {
+	rec := httptest.NewRecorder()
+	assert.False(t, writeUpstreamError(rec, errors.New("connection reset"), "Query execution failed"))
+	assert.Equal(t, 0, rec.Body.Len())
+}
@@ -0,0 +1,675 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/auth"
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/graphql"
+	"go-data-gateway/internal/response"
+	"go-data-gateway/internal/sqlbuilder"
+	"go-data-gateway/pkg/apitypes"
+)
+
+// graphQLMaxDepth bounds the nesting of a GraphQL query; the introspection
+// query of common tooling nests 13 deep
+const graphQLMaxDepth = 15
+
+// relationLoaderKey holds the relation loader of a row, shared by the rows of
+// one result. It is not a GraphQL name, so no field can select it.
+const relationLoaderKey = "@relations"
+
+// graphQLName matches the names GraphQL allows for fields and types
+var graphQLName = regexp.MustCompile(`^[_A-Za-z][_0-9A-Za-z]*$`)
+
+// graphQLTable is a logical table of the GraphQL schema: a field listing its
+// rows and a field reading one row by key
+type graphQLTable struct {
+	name       string // Of the root fields, e.g. tender and tenders
+	typeName   string // Of a row, e.g. Tender
+	table      string
+	key        string   // Column identifying a row
+	columns    []string // Selectable columns besides the declared ones
+	source     datasource.DataSource
+	dialect    sqlbuilder.Dialect
+	limits     config.PageLimit
+	tiebreaker string
+
+	relations  []config.Relation // Child collections, as fields of a row
+	softDelete bool              // is_deleted rows are hidden unless an admin asks for them
+}
+
+// GraphQLHandler serves queries of the tender and RUP tables at
+// POST /api/v1/graphql. The schema is generated from the declared columns of
+// the security policy and regenerated when the policy is reloaded.
+type GraphQLHandler struct {
+	tables   []*graphQLTable
+	security config.SecurityProvider
+	logger   *zap.Logger
+
+	mu     sync.Mutex
+	policy *config.SecurityConfig // The policy schema was generated from
+	schema *graphql.Schema
+}
+
+// NewGraphQLHandler creates a GraphQL handler over the tender table of
+// tender and the RUP table of rup; a nil source leaves its table out
+func NewGraphQLHandler(tender, rup datasource.DataSource, pagination config.PaginationConfig, security config.SecurityProvider, logger *zap.Logger) *GraphQLHandler {
+	h := &GraphQLHandler{security: security, logger: logger}
+	if tender != nil {
+		h.tables = append(h.tables, &graphQLTable{
+			name:       "tender",
+			typeName:   "Tender",
+			table:      tenderTable,
+			key:        "tender_id",
+			columns:    tenderViews["full"],
+			source:     tender,
+			dialect:    sqlbuilder.Dremio,
+			limits:     pagination.Tender,
+			tiebreaker: config.DefaultTiebreakers().For(tenderTable),
+		})
+	}
+	if rup != nil {
+		h.tables = append(h.tables, &graphQLTable{
+			name:       "rup",
+			typeName:   "Rup",
+			table:      rupTable,
+			key:        "kd_kro_str",
+			columns:    rupRecordColumns,
+			source:     rup,
+			dialect:    sqlbuilder.BigQuery,
+			limits:     pagination.RUP,
+			tiebreaker: config.DefaultTiebreakers().For(rupTable),
+			softDelete: true,
+		})
+	}
+	return h
+}
+
+// SetRelations sets the child collections of a tender, batch-loaded for the
+// tenders of a result
+func (h *GraphQLHandler) SetRelations(relations config.RelationsConfig) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, t := range h.tables {
+		if t.name == "tender" {
+			t.relations = relations.Tender
+		}
+	}
+	h.schema = nil
+}
+
+// SetTiebreakers sets the columns lists are ordered by after orderBy
+func (h *GraphQLHandler) SetTiebreakers(tiebreakers config.Tiebreakers) {
+	for _, t := range h.tables {
+		t.tiebreaker = tiebreakers.For(t.table)
+	}
+}
+
+// Query handles POST /api/v1/graphql with a body of {"query", "operationName",
+// "variables"}. Requests that cannot run get 400 with errors and no data;
+// errors of fields null them and are listed next to the data.
+func (h *GraphQLHandler) Query(w http.ResponseWriter, r *http.Request) {
+	var req graphql.Request
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&req); err != nil {
+		writeGraphQL(w, http.StatusBadRequest, &graphql.Response{Errors: []*graphql.Error{{Message: "Invalid request body: " + err.Error()}}})
+		return
+	}
+	if strings.TrimSpace(req.Query) == "" {
+		writeGraphQL(w, http.StatusBadRequest, &graphql.Response{Errors: []*graphql.Error{{Message: "query is required"}}})
+		return
+	}
+
+	schema, err := h.schemaFor(h.security())
+	if err != nil {
+		h.logger.Error("Failed to generate the GraphQL schema", zap.Error(err))
+		response.Error(w, "GraphQL schema is unavailable", http.StatusInternalServerError)
+		return
+	}
+
+	resp := graphql.Execute(r.Context(), schema, req, graphql.Options{MaxDepth: graphQLMaxDepth})
+	status := http.StatusOK
+	if !resp.Executed() {
+		status = http.StatusBadRequest
+	}
+	writeGraphQL(w, status, resp)
+}
+
+// writeGraphQL writes a GraphQL response. Responses may hold soft-deleted
+// rows an admin asked for, so they are never stored.
+func writeGraphQL(w http.ResponseWriter, status int, resp *graphql.Response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", config.NoStore.String())
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// schemaFor returns the schema of policy, generating it on the first request
+// after a reload
+func (h *GraphQLHandler) schemaFor(policy *config.SecurityConfig) (*graphql.Schema, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.schema != nil && h.policy == policy {
+		return h.schema, nil
+	}
+	schema, err := h.buildSchema(policy)
+	if err != nil {
+		return nil, err
+	}
+	h.policy, h.schema = policy, schema
+	return schema, nil
+}
+
+// buildSchema generates the query type of the tables policy allows
+func (h *GraphQLHandler) buildSchema(policy *config.SecurityConfig) (*graphql.Schema, error) {
+	inputs := newGraphQLInputs()
+	query := &graphql.Type{
+		Kind:        graphql.KindObject,
+		Name:        "Query",
+		Description: "Rows of the whitelisted tables",
+	}
+	for _, t := range h.tables {
+		if !policy.IsTableAllowed(t.table, securitySource(t.source.GetType())) {
+			continue
+		}
+		s := &graphQLTableSchema{graphQLTable: t, logger: h.logger}
+		query.Fields = append(query.Fields, s.fields(policy, inputs)...)
+	}
+	return graphql.NewSchema(query)
+}
+
+// graphQLInputs are the types shared by the fields of every table
+type graphQLInputs struct {
+	order   *graphql.Type
+	json    *graphql.Type
+	filters map[string]*graphql.Type // By column type
+}
+
+func newGraphQLInputs() *graphQLInputs {
+	return &graphQLInputs{
+		order: &graphql.Type{
+			Kind:        graphql.KindEnum,
+			Name:        "Order",
+			Description: "Direction of orderBy",
+			EnumValues:  graphql.EnumValues("ASC", "DESC"),
+		},
+		json: &graphql.Type{
+			Kind:        graphql.KindScalar,
+			Name:        "JSON",
+			Description: "A value of an undeclared column, as the source returns it",
+		},
+		filters: map[string]*graphql.Type{
+			config.ColumnString:  filterInput("StringFilter", graphql.String, searchEq, searchNeq, searchGt, searchGte, searchLt, searchLte, searchIn, searchNin, searchLike, searchIsNull),
+			config.ColumnNumber:  filterInput("NumberFilter", graphql.Float, searchEq, searchNeq, searchGt, searchGte, searchLt, searchLte, searchIn, searchNin, searchIsNull),
+			config.ColumnDate:    filterInput("DateFilter", graphql.String, searchEq, searchNeq, searchGt, searchGte, searchLt, searchLte, searchIn, searchNin, searchIsNull),
+			config.ColumnBoolean: filterInput("BooleanFilter", graphql.Boolean, searchEq, searchNeq, searchIsNull),
+		},
+	}
+}
+
+// filterInput is the input type of the conditions on a column of one type,
+// each one a search operator
+func filterInput(name string, value *graphql.Type, ops ...string) *graphql.Type {
+	t := &graphql.Type{Kind: graphql.KindInputObject, Name: name}
+	for _, op := range ops {
+		field := &graphql.InputValue{Name: op, Type: value}
+		switch op {
+		case searchIn, searchNin:
+			field.Type = graphql.ListOf(graphql.NonNull(value))
+		case searchIsNull:
+			field.Type = graphql.Boolean
+			field.Description = "true matches NULL, false any other value"
+		}
+		t.InputFields = append(t.InputFields, field)
+	}
+	return t
+}
+
+// outputType is the GraphQL type of a column of a declared type
+func (in *graphQLInputs) outputType(columnType string) *graphql.Type {
+	switch columnType {
+	case config.ColumnString, config.ColumnDate:
+		return graphql.String
+	case config.ColumnNumber:
+		return graphql.Float
+	case config.ColumnBoolean:
+		return graphql.Boolean
+	}
+	return in.json
+}
+
+// graphQLTableSchema is a table as one policy declares it
+type graphQLTableSchema struct {
+	*graphQLTable
+	logger *zap.Logger
+
+	declared   []config.ColumnSpec // Filterable columns
+	selectable map[string]bool
+}
+
+// graphQLRelation is a child collection with the columns its type declares;
+// nil columns make each child a JSON object of every column
+type graphQLRelation struct {
+	config.Relation
+	columns map[string]bool
+}
+
+// fields generates the row type of the table and its root fields
+func (s *graphQLTableSchema) fields(policy *config.SecurityConfig, inputs *graphQLInputs) []*graphql.Field {
+	row := &graphql.Type{Kind: graphql.KindObject, Name: s.typeName, Description: "A row of " + s.table}
+	where := &graphql.Type{Kind: graphql.KindInputObject, Name: s.typeName + "Where", Description: "Conditions on the rows of " + s.table + ", which all must match"}
+	where.InputFields = []*graphql.InputValue{
+		{Name: "and", Type: graphql.ListOf(graphql.NonNull(where)), Description: "Every one must match"},
+		{Name: "or", Type: graphql.ListOf(graphql.NonNull(where)), Description: "Any one must match"},
+	}
+	var sortable []string
+
+	s.selectable = map[string]bool{}
+	for _, column := range policy.TableColumns[s.table] {
+		if !graphQLName.MatchString(column.Name) || strings.HasPrefix(column.Name, "__") {
+			continue
+		}
+		s.selectable[column.Name] = true
+		row.Fields = append(row.Fields, &graphql.Field{Name: column.Name, Type: inputs.outputType(column.Type)})
+		if filter, ok := inputs.filters[column.Type]; ok {
+			s.declared = append(s.declared, column)
+			where.InputFields = append(where.InputFields, &graphql.InputValue{Name: column.Name, Type: filter})
+			sortable = append(sortable, column.Name)
+		}
+	}
+	for _, column := range s.columns {
+		if s.selectable[column] || !graphQLName.MatchString(column) || strings.HasPrefix(column, "__") {
+			continue
+		}
+		s.selectable[column] = true
+		row.Fields = append(row.Fields, &graphql.Field{Name: column, Type: inputs.json, Description: "Undeclared; not filterable"})
+	}
+	for _, relation := range s.relations {
+		if !graphQLName.MatchString(relation.Name) || s.selectable[relation.Name] ||
+			!policy.IsTableAllowed(relation.Table, securitySource(s.source.GetType())) {
+			continue
+		}
+		row.Fields = append(row.Fields, s.relationField(policy, inputs, relation))
+	}
+
+	list := &graphql.Field{
+		Name:        s.name + "s",
+		Description: "Rows of " + s.table + " matching where",
+		Type:        graphql.NonNull(graphql.ListOf(graphql.NonNull(row))),
+		Args: []*graphql.InputValue{
+			{Name: "where", Type: where},
+			{Name: "limit", Type: graphql.Int, Description: fmt.Sprintf("Rows returned, at most %d; %d by default", s.limits.Max, s.limits.Default)},
+			{Name: "offset", Type: graphql.Int, Default: int64(0)},
+			{Name: "order", Type: inputs.order, Default: graphql.EnumValue("DESC")},
+		},
+		Resolve: s.list,
+	}
+	if len(sortable) > 0 {
+		columns := &graphql.Type{Kind: graphql.KindEnum, Name: s.typeName + "Column", EnumValues: graphql.EnumValues(sortable...)}
+		list.Args = append(list.Args, &graphql.InputValue{Name: "orderBy", Type: columns})
+	}
+	get := &graphql.Field{
+		Name:        s.name,
+		Description: "The row of " + s.table + " with the given " + s.key,
+		Type:        row,
+		Args:        []*graphql.InputValue{{Name: s.key, Type: graphql.NonNull(graphql.String)}},
+		Resolve:     s.get,
+	}
+	if s.softDelete {
+		deleted := &graphql.InputValue{Name: "includeDeleted", Type: graphql.Boolean, Default: false, Description: "Include soft-deleted rows; admin keys only"}
+		list.Args = append(list.Args, deleted)
+		get.Args = append(get.Args, deleted)
+	}
+	return []*graphql.Field{list, get}
+}
+
+// relationField is the field of a child collection of a row. Its type
+// declares the relation's configured columns and the declared columns of its
+// table; with neither, each child is a JSON object.
+func (s *graphQLTableSchema) relationField(policy *config.SecurityConfig, inputs *graphQLInputs, relation config.Relation) *graphql.Field {
+	r := graphQLRelation{Relation: relation}
+	child := inputs.json
+	types := map[string]string{}
+	for _, column := range policy.TableColumns[relation.Table] {
+		types[column.Name] = column.Type
+	}
+	names := append(append([]string{}, relation.Columns...), sortedKeys(types)...)
+	if len(names) > 0 {
+		r.columns = map[string]bool{}
+		child = &graphql.Type{Kind: graphql.KindObject, Name: s.typeName + strings.ToUpper(relation.Name[:1]) + relation.Name[1:], Description: "A row of " + relation.Table}
+		for _, name := range names {
+			if r.columns[name] || !graphQLName.MatchString(name) || strings.HasPrefix(name, "__") {
+				continue
+			}
+			r.columns[name] = true
+			child.Fields = append(child.Fields, &graphql.Field{Name: name, Type: inputs.outputType(types[name])})
+		}
+	}
+	return &graphql.Field{
+		Name:        relation.Name,
+		Description: fmt.Sprintf("Rows of %s by %s, at most %d", relation.Table, relation.Key, relation.Limit),
+		Type:        graphql.NonNull(graphql.ListOf(graphql.NonNull(child))),
+		Resolve:     s.children(r),
+	}
+}
+
+// list resolves the list field: the SELECT list comes from the selected
+// fields and the WHERE clause from where, compiled like a search body
+func (s *graphQLTableSchema) list(ctx context.Context, p graphql.ResolveParams) (interface{}, error) {
+	var (
+		v     violations
+		conds []sqlbuilder.Cond
+	)
+	if where, ok := p.Args["where"].(map[string]interface{}); ok {
+		conds = searchConditions(&v, "where", whereClauses(where), s.declared)
+	}
+	limit, err := applyLimit(intArg(p.Args["limit"]), s.limits)
+	if err != nil {
+		v.addErr("limit", "max", err)
+	}
+	offset := intArg(p.Args["offset"])
+	if offset < 0 {
+		v.add("offset", "min=0", "offset must not be negative")
+	}
+	withDeleted, err := s.withDeleted(ctx, p.Args)
+	if err != nil {
+		return nil, err
+	}
+	if len(v) > 0 {
+		return nil, violationsError(v)
+	}
+
+	order, _ := p.Args["order"].(string)
+	query := sqlbuilder.Select(s.dialect, s.selectList(p.Selections)...).From(s.table).
+		Where(conds...).
+		Where(s.visible(withDeleted)...)
+	if column, ok := p.Args["orderBy"].(string); ok {
+		query.OrderBy(column, order)
+	}
+	if s.tiebreaker != "" {
+		query.Tiebreaker(s.tiebreaker, order)
+	}
+	return s.rows(ctx, query.Limit(limit).Offset(offset))
+}
+
+// get resolves the single-row field
+func (s *graphQLTableSchema) get(ctx context.Context, p graphql.ResolveParams) (interface{}, error) {
+	withDeleted, err := s.withDeleted(ctx, p.Args)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.rows(ctx, sqlbuilder.Select(s.dialect, s.selectList(p.Selections)...).From(s.table).
+		Where(sqlbuilder.Eq(s.key, p.Args[s.key])).
+		Where(s.visible(withDeleted)...).
+		Limit(1))
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+	return rows[0], nil
+}
+
+// withDeleted reads includeDeleted, which only admin keys may set, as with
+// include_deleted of the REST endpoints
+func (s *graphQLTableSchema) withDeleted(ctx context.Context, args map[string]interface{}) (bool, error) {
+	include, _ := args["includeDeleted"].(bool)
+	if include && !auth.HasScope(ctx, auth.ScopeAdmin) {
+		return false, errors.New("includeDeleted requires an admin key")
+	}
+	return include, nil
+}
+
+// visible returns the row policy of the table
+func (s *graphQLTableSchema) visible(withDeleted bool) []sqlbuilder.Cond {
+	if !s.softDelete {
+		return nil
+	}
+	return rupVisible(withDeleted)
+}
+
+// selectList returns the key and the selected columns
+func (s *graphQLTableSchema) selectList(selections []*graphql.SelectedField) []string {
+	columns := []string{s.key}
+	for _, f := range selections {
+		if s.selectable[f.Name] && !slices.Contains(columns, f.Name) {
+			columns = append(columns, f.Name)
+		}
+	}
+	return columns
+}
+
+// rows runs query; each row gets a loader of the children of the result
+func (s *graphQLTableSchema) rows(ctx context.Context, query *sqlbuilder.Builder) ([]map[string]interface{}, error) {
+	sql, err := query.SQL()
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.source.ExecuteQuery(ctx, sql, nil)
+	if err != nil {
+		s.logger.Error("Failed to fetch GraphQL rows", zap.String("table", s.table), zap.Error(err))
+		return nil, fmt.Errorf("failed to fetch %s data", s.name)
+	}
+
+	loader := &relationLoader{table: s, loads: map[string]*relationLoad{}}
+	rows := make([]map[string]interface{}, len(result.Data))
+	for i, data := range result.Data {
+		// Copied, since the source may share its rows with its cache
+		row := make(map[string]interface{}, len(data)+1)
+		for column, value := range data {
+			row[column] = value
+		}
+		row[relationLoaderKey] = loader
+		rows[i] = row
+		if id := data[s.key]; id != nil {
+			loader.ids = append(loader.ids, id)
+		}
+	}
+	return rows, nil
+}
+
+// children resolves the field of relation on a row from the batch of its
+// result
+func (s *graphQLTableSchema) children(relation graphQLRelation) graphql.ResolveFunc {
+	return func(ctx context.Context, p graphql.ResolveParams) (interface{}, error) {
+		row, _ := p.Source.(map[string]interface{})
+		loader, _ := row[relationLoaderKey].(*relationLoader)
+		if loader == nil {
+			return []map[string]interface{}{}, nil
+		}
+		children, err := loader.load(ctx, relation, relation.selectList(p.Selections))
+		if err != nil {
+			return nil, err
+		}
+		if rows := children[fmt.Sprint(row[s.key])]; rows != nil {
+			return rows, nil
+		}
+		return []map[string]interface{}{}, nil
+	}
+}
+
+// selectList returns the key and the selected columns of the relation, or
+// its configured columns when its children are JSON objects
+func (r graphQLRelation) selectList(selections []*graphql.SelectedField) []string {
+	if r.columns == nil {
+		return r.Columns
+	}
+	columns := []string{r.Key}
+	for _, f := range selections {
+		if r.columns[f.Name] && !slices.Contains(columns, f.Name) {
+			columns = append(columns, f.Name)
+		}
+	}
+	return columns
+}
+
+// relationLoader loads the children of the rows of one result, like a
+// dataloader: the first row resolving a relation fetches it for every row
+// with IN queries on the relation's key
+type relationLoader struct {
+	table *graphQLTableSchema
+	ids   []interface{} // Keys of the rows
+
+	mu    sync.Mutex
+	loads map[string]*relationLoad // By relation and select list
+}
+
+// relationLoad is the children of the rows by their key, fetched once
+type relationLoad struct {
+	once     sync.Once
+	children map[string][]map[string]interface{}
+	err      error
+}
+
+// load returns the children of relation with columns, by parent key
+func (l *relationLoader) load(ctx context.Context, relation graphQLRelation, columns []string) (map[string][]map[string]interface{}, error) {
+	key := relation.Name + "\x00" + strings.Join(columns, ",")
+	l.mu.Lock()
+	load, ok := l.loads[key]
+	if !ok {
+		load = &relationLoad{}
+		l.loads[key] = load
+	}
+	l.mu.Unlock()
+
+	load.once.Do(func() {
+		load.children, load.err = l.fetch(ctx, relation, columns)
+	})
+	return load.children, load.err
+}
+
+// fetch queries the children of the rows in chunks of sqlbuilder.MaxInValues
+// keys. A chunk reads at most Limit children per key, so each row gets at
+// most Limit, though a row with many may crowd out others of its chunk.
+func (l *relationLoader) fetch(ctx context.Context, relation graphQLRelation, columns []string) (map[string][]map[string]interface{}, error) {
+	children := map[string][]map[string]interface{}{}
+	ids := slices.Compact(sortedIDs(l.ids))
+	for chunk := range slices.Chunk(ids, sqlbuilder.MaxInValues) {
+		query, err := sqlbuilder.Select(l.table.dialect, columns...).From(relation.Table).
+			Where(sqlbuilder.In(relation.Key, chunk)).
+			OrderBy(relation.Key, "ASC").
+			Limit(relation.Limit * len(chunk)).SQL()
+		if err != nil {
+			return nil, err
+		}
+		result, err := l.table.source.ExecuteQuery(ctx, query, nil)
+		if err != nil {
+			l.table.logger.Error("Failed to fetch GraphQL relation",
+				zap.String("relation", relation.Name),
+				zap.Int("keys", len(chunk)),
+				zap.Error(err))
+			return nil, fmt.Errorf("failed to fetch %s", relation.Name)
+		}
+		for _, row := range result.Data {
+			id := fmt.Sprint(row[relation.Key])
+			if relation.Limit <= 0 || len(children[id]) < relation.Limit {
+				children[id] = append(children[id], row)
+			}
+		}
+	}
+	return children, nil
+}
+
+// sortedIDs returns a copy of ids sorted by their text, so equal sets of
+// keys build equal, and equally cached, queries
+func sortedIDs(ids []interface{}) []interface{} {
+	sorted := append([]interface{}{}, ids...)
+	sort.SliceStable(sorted, func(i, j int) bool { return fmt.Sprint(sorted[i]) < fmt.Sprint(sorted[j]) })
+	return sorted
+}
+
+// whereClauses converts a where argument to search clauses, which all must
+// match: a clause per operator of each column filter, and a group for and
+// and or, each of whose objects is a group of its own
+func whereClauses(where map[string]interface{}) []apitypes.SearchClause {
+	var clauses []apitypes.SearchClause
+	for _, name := range sortedKeys(where) {
+		switch value := where[name]; name {
+		case "and", "or":
+			items, ok := value.([]interface{})
+			if !ok {
+				continue
+			}
+			group := make([]apitypes.SearchClause, 0, len(items))
+			for _, item := range items {
+				nested, _ := item.(map[string]interface{})
+				group = append(group, whereGroup(whereClauses(nested)))
+			}
+			if name == "and" {
+				clauses = append(clauses, apitypes.SearchClause{And: group})
+			} else {
+				clauses = append(clauses, apitypes.SearchClause{Or: group})
+			}
+		default:
+			filter, _ := value.(map[string]interface{})
+			for _, op := range sortedKeys(filter) {
+				if clause, ok := filterClause(name, op, filter[op]); ok {
+					clauses = append(clauses, clause)
+				}
+			}
+		}
+	}
+	return clauses
+}
+
+// whereGroup joins the clauses of one where object
+func whereGroup(clauses []apitypes.SearchClause) apitypes.SearchClause {
+	if len(clauses) == 1 {
+		return clauses[0]
+	}
+	return apitypes.SearchClause{And: append([]apitypes.SearchClause{}, clauses...)}
+}
+
+// filterClause is the clause of one operator of a column filter; is_null
+// set to null is no condition at all
+func filterClause(column, op string, value interface{}) (apitypes.SearchClause, bool) {
+	if op == searchIsNull {
+		isNull, ok := value.(bool)
+		switch {
+		case !ok:
+			return apitypes.SearchClause{}, false
+		case isNull:
+			return apitypes.SearchClause{Field: column, Op: searchIsNull}, true
+		default:
+			return apitypes.SearchClause{Field: column, Op: searchNotNull}, true
+		}
+	}
+	raw, _ := json.Marshal(value)
+	return apitypes.SearchClause{Field: column, Op: op, Value: raw}, true
+}
+
+// violationsError joins violations into the error of a field
+func violationsError(v violations) error {
+	messages := make([]string, len(v))
+	for i, violation := range v {
+		messages[i] = violation.Field + ": " + violation.Message
+	}
+	return errors.New(strings.Join(messages, "; "))
+}
+
+// intArg reads an optional Int argument
+func intArg(v interface{}) int {
+	n, _ := v.(int)
+	return n
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
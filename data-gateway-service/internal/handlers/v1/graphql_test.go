@@ -0,0 +1,222 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+)
+
+// tableSource answers each query with the rows of the table it selects from
+type tableSource struct {
+	sourceType datasource.DataSourceType
+	tables     map[string][]map[string]interface{}
+
+	mu      sync.Mutex
+	queries []string
+}
+
+func (s *tableSource) ExecuteQuery(ctx context.Context, query string, opts *datasource.QueryOptions) (*datasource.QueryResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queries = append(s.queries, query)
+	for table, rows := range s.tables {
+		if strings.Contains(query, table+" ") || strings.Contains(query, table+"`") {
+			return &datasource.QueryResult{Data: rows, Count: len(rows), Source: s.sourceType}, nil
+		}
+	}
+	return &datasource.QueryResult{Source: s.sourceType}, nil
+}
+
+func (s *tableSource) GetData(ctx context.Context, table string, opts *datasource.QueryOptions) (*datasource.QueryResult, error) {
+	return s.ExecuteQuery(ctx, table, opts)
+}
+
+func (s *tableSource) TestConnection(ctx context.Context) error { return nil }
+
+func (s *tableSource) GetType() datasource.DataSourceType { return s.sourceType }
+
+func (s *tableSource) Close() error { return nil }
+
+func newTestGraphQLHandler(tender, rup datasource.DataSource) *GraphQLHandler {
+	policy := config.GetDefaultSecurityConfig()
+	h := NewGraphQLHandler(tender, rup, config.PaginationConfig{Tender: testLimits, RUP: testLimits},
+		func() *config.SecurityConfig { return policy }, zap.NewNop())
+	h.SetRelations(config.RelationsConfig{Tender: []config.Relation{
+		{Name: "peserta", Table: "nessie_iceberg.tender_peserta", Key: "tender_id", Columns: []string{"nama_peserta"}, Limit: 2},
+	}})
+	return h
+}
+
+// postGraphQL sends query with variables, as the key of as when set, and
+// returns the status and the decoded body
+func postGraphQL(t *testing.T, h *GraphQLHandler, as func(*http.Request) *http.Request, query string, variables map[string]interface{}) (int, map[string]interface{}) {
+	t.Helper()
+	body, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	require.NoError(t, err)
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/graphql", bytes.NewReader(body))
+	if as != nil {
+		r = as(r)
+	}
+
+	rec := httptest.NewRecorder()
+	h.Query(rec, r)
+	assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+
+	var resp map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	return rec.Code, resp
+}
+
+func TestGraphQL_TranslatesSelectionAndWhere(t *testing.T) {
+	dremio := &tableSource{sourceType: datasource.DataSourceDremio, tables: map[string][]map[string]interface{}{
+		tenderTable: {{"tender_id": "T1", "nama_paket": "Jalan", "nilai_pagu": "1500.50"}},
+	}}
+	h := newTestGraphQLHandler(dremio, nil)
+
+	status, resp := postGraphQL(t, h, nil, `query($min: Float) {
+		tenders(
+			where: {nilai_pagu: {gte: $min}, or: [{status_tender: {eq: "Aktif"}}, {provinsi: {in: ["Aceh", "Bali"]}}]}
+			orderBy: nilai_pagu, order: ASC, limit: 5, offset: 10
+		) { nama_paket nilai_pagu }
+	}`, map[string]interface{}{"min": 1000})
+
+	require.Equal(t, http.StatusOK, status)
+	assert.Nil(t, resp["errors"])
+	assert.Equal(t, map[string]interface{}{"tenders": []interface{}{
+		map[string]interface{}{"nama_paket": "Jalan", "nilai_pagu": 1500.5},
+	}}, resp["data"])
+	require.Len(t, dremio.queries, 1)
+	assert.Equal(t, "SELECT tender_id, nama_paket, nilai_pagu FROM nessie_iceberg.tender_data "+
+		"WHERE nilai_pagu >= 1000 AND (status_tender = 'Aktif' OR provinsi IN ('Aceh', 'Bali')) "+
+		"ORDER BY nilai_pagu ASC, tender_id ASC LIMIT 5 OFFSET 10", dremio.queries[0])
+}
+
+func TestGraphQL_RejectsBadArguments(t *testing.T) {
+	dremio := &tableSource{sourceType: datasource.DataSourceDremio}
+	h := newTestGraphQLHandler(dremio, nil)
+
+	status, resp := postGraphQL(t, h, nil, `{ tenders(where: {tanggal_pengumuman: {gt: "yesterday"}}, limit: 500) { tender_id } }`, nil)
+
+	require.Equal(t, http.StatusOK, status)
+	assert.Nil(t, resp["data"])
+	errs := resp["errors"].([]interface{})
+	require.Len(t, errs, 1)
+	message := errs[0].(map[string]interface{})["message"].(string)
+	assert.Contains(t, message, "is a date column (YYYY-MM-DD)")
+	assert.Contains(t, message, "limit must not exceed 50")
+	assert.Empty(t, dremio.queries)
+
+	status, resp = postGraphQL(t, h, nil, `{ tenders(where: {nama_kolom: {eq: "x"}}) { tender_id } }`, nil)
+	require.Equal(t, http.StatusBadRequest, status)
+	assert.NotContains(t, resp, "data")
+	assert.Contains(t, resp["errors"].([]interface{})[0].(map[string]interface{})["message"], "TenderWhere has no field nama_kolom")
+}
+
+func TestGraphQL_BatchesRelations(t *testing.T) {
+	dremio := &tableSource{sourceType: datasource.DataSourceDremio, tables: map[string][]map[string]interface{}{
+		tenderTable: {{"tender_id": "T1"}, {"tender_id": "T2"}, {"tender_id": "T3"}},
+		"nessie_iceberg.tender_peserta": {
+			{"tender_id": "T1", "nama_peserta": "A"},
+			{"tender_id": "T1", "nama_peserta": "B"},
+			{"tender_id": "T1", "nama_peserta": "C"},
+			{"tender_id": "T3", "nama_peserta": "D"},
+		},
+	}}
+	h := newTestGraphQLHandler(dremio, nil)
+
+	status, resp := postGraphQL(t, h, nil, `{ tenders { tender_id peserta { nama_peserta } } }`, nil)
+
+	require.Equal(t, http.StatusOK, status)
+	assert.Nil(t, resp["errors"])
+	peserta := func(names ...string) []interface{} {
+		list := []interface{}{}
+		for _, name := range names {
+			list = append(list, map[string]interface{}{"nama_peserta": name})
+		}
+		return list
+	}
+	assert.Equal(t, map[string]interface{}{"tenders": []interface{}{
+		map[string]interface{}{"tender_id": "T1", "peserta": peserta("A", "B")}, // Capped at the relation's limit
+		map[string]interface{}{"tender_id": "T2", "peserta": peserta()},
+		map[string]interface{}{"tender_id": "T3", "peserta": peserta("D")},
+	}}, resp["data"])
+
+	// One query for the tenders and one IN query for all their peserta
+	require.Len(t, dremio.queries, 2)
+	assert.Equal(t, "SELECT tender_id, nama_peserta FROM nessie_iceberg.tender_peserta "+
+		"WHERE tender_id IN ('T1', 'T2', 'T3') ORDER BY tender_id ASC LIMIT 6", dremio.queries[1])
+}
+
+func TestGraphQL_RupRowPolicy(t *testing.T) {
+	bigquery := &tableSource{sourceType: datasource.DataSourceBigQuery, tables: map[string][]map[string]interface{}{
+		rupTable: {{"kd_kro_str": "K1", "is_deleted": true}},
+	}}
+	h := newTestGraphQLHandler(nil, bigquery)
+
+	status, resp := postGraphQL(t, h, nil, `{ rup(kd_kro_str: "K1") { kd_kro_str } }`, nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.Nil(t, resp["errors"])
+	require.Len(t, bigquery.queries, 1)
+	assert.Contains(t, bigquery.queries[0], "WHERE kd_kro_str = 'K1' AND is_deleted = FALSE")
+
+	// Only admin keys may see soft-deleted rows
+	_, resp = postGraphQL(t, h, asReader, `{ rups(includeDeleted: true) { kd_kro_str } }`, nil)
+	assert.Nil(t, resp["data"])
+	assert.Equal(t, "includeDeleted requires an admin key", resp["errors"].([]interface{})[0].(map[string]interface{})["message"])
+	require.Len(t, bigquery.queries, 1)
+
+	_, resp = postGraphQL(t, h, asAdmin, `{ rups(includeDeleted: true) { kd_kro_str is_deleted } }`, nil)
+	assert.Nil(t, resp["errors"])
+	require.Len(t, bigquery.queries, 2)
+	assert.NotContains(t, bigquery.queries[1], "is_deleted =")
+}
+
+func TestGraphQL_Introspection(t *testing.T) {
+	h := newTestGraphQLHandler(&tableSource{sourceType: datasource.DataSourceDremio}, nil)
+
+	status, resp := postGraphQL(t, h, nil, `{
+		__schema { queryType { fields { name } } }
+		where: __type(name: "TenderWhere") { inputFields { name type { name } } }
+	}`, nil)
+
+	require.Equal(t, http.StatusOK, status)
+	require.Nil(t, resp["errors"])
+	data := resp["data"].(map[string]interface{})
+	fields := data["__schema"].(map[string]interface{})["queryType"].(map[string]interface{})["fields"]
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"name": "tenders"},
+		map[string]interface{}{"name": "tender"},
+	}, fields)
+
+	inputs := map[string]interface{}{}
+	for _, f := range data["where"].(map[string]interface{})["inputFields"].([]interface{}) {
+		field := f.(map[string]interface{})
+		inputs[field["name"].(string)] = field["type"].(map[string]interface{})["name"]
+	}
+	assert.Equal(t, "NumberFilter", inputs["nilai_pagu"])
+	assert.Equal(t, "DateFilter", inputs["tanggal_pengumuman"])
+	assert.Equal(t, "StringFilter", inputs["nama_paket"])
+	assert.Contains(t, inputs, "or")
+}
+
+func TestGraphQL_RejectsMutations(t *testing.T) {
+	h := newTestGraphQLHandler(&tableSource{sourceType: datasource.DataSourceDremio}, nil)
+
+	status, resp := postGraphQL(t, h, nil, `mutation { tenders { tender_id } }`, nil)
+
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "mutation operations are not supported; only queries are",
+		resp["errors"].([]interface{})[0].(map[string]interface{})["message"])
+}
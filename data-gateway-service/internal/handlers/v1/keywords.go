@@ -0,0 +1,71 @@
+package v1
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/pkg/apitypes"
+)
+
+// Values of the match field of a search body
+const (
+	matchAll = "all" // Every keyword must match (the default)
+	matchAny = "any"
+)
+
+// keywordParam reports keywords as a search body usually gives them: a
+// string for one, a list for several
+func keywordParam(keywords []string) interface{} {
+	switch len(keywords) {
+	case 0:
+		return ""
+	case 1:
+		return keywords[0]
+	default:
+		return keywords
+	}
+}
+
+// keywordMatch validates the keywords and match mode of a search against
+// search; it returns nil when no keyword is set. Blank keywords are ignored.
+// The length and count limits bound the LIKE scans a search may cause.
+func keywordMatch(keywords apitypes.Keywords, match string, search config.KeywordSearch) (*datasource.KeywordMatch, error) {
+	var all bool
+	switch strings.ToLower(match) {
+	case "", matchAll:
+		all = true
+	case matchAny:
+	default:
+		return nil, fieldError("match", "oneof="+matchAll+" "+matchAny, "match must be %s or %s", matchAll, matchAny)
+	}
+
+	terms := make([]string, 0, len(keywords))
+	for _, keyword := range keywords {
+		term := strings.TrimSpace(keyword)
+		if term == "" {
+			continue
+		}
+		if utf8.RuneCountInString(term) > search.MaxLength {
+			return nil, fieldError("keyword", fmt.Sprintf("max_length=%d", search.MaxLength), "keywords may have at most %d characters", search.MaxLength)
+		}
+		terms = append(terms, term)
+	}
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	if len(terms) > search.MaxTerms {
+		return nil, fieldError("keyword", fmt.Sprintf("max_terms=%d", search.MaxTerms), "at most %d keywords are allowed", search.MaxTerms)
+	}
+	return &datasource.KeywordMatch{Columns: search.Columns, Terms: terms, All: all}, nil
+}
+
+// keywordMode is the match value of m
+func keywordMode(m *datasource.KeywordMatch) string {
+	if m.All {
+		return matchAll
+	}
+	return matchAny
+}
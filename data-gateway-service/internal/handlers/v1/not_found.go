@@ -0,0 +1,102 @@
+package v1
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/cache"
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/metrics"
+	"go-data-gateway/internal/response"
+	"go-data-gateway/internal/tenant"
+)
+
+// notFoundTables are the tables looked up by id whose misses are remembered
+var notFoundTables = []string{tenderTable, rupTable}
+
+// notFoundCache remembers the ids a lookup by id did not find, for a short
+// TTL, so bots probing sequential ids do not reach the source with every
+// request. The markers of a table share a key prefix, which drops them all
+// at once when new rows are ingested.
+type notFoundCache struct {
+	cache   cache.Cache
+	ttl     time.Duration // Zero remembers nothing
+	counter *metrics.NotFoundCounter
+	logger  *zap.Logger
+}
+
+func newNotFoundCache(logger *zap.Logger) *notFoundCache {
+	return &notFoundCache{cache: &cache.NoOpCache{}, logger: logger}
+}
+
+// configure sets the TTL of the markers, their cache and the counter of
+// their outcomes; a zero TTL or a nil cache remembers nothing
+func (c *notFoundCache) configure(ttl time.Duration, markers cache.Cache, counter *metrics.NotFoundCounter) {
+	if markers == nil {
+		markers, ttl = &cache.NoOpCache{}, 0
+	}
+	c.ttl, c.cache, c.counter = ttl, markers, counter
+}
+
+// enabled reports whether misses are remembered; a nil cache remembers
+// nothing
+func (c *notFoundCache) enabled() bool {
+	return c != nil && c.ttl > 0
+}
+
+// has reports whether the lookup of id in table is remembered as not found.
+// variant tells apart lookups of the same id that may find different rows.
+func (c *notFoundCache) has(ctx context.Context, table, id, variant string) bool {
+	if !c.enabled() {
+		return false
+	}
+	if _, err := c.cache.Get(ctx, c.key(ctx, table, id, variant)); err != nil {
+		if !errors.Is(err, cache.ErrCacheMiss) {
+			c.logger.Warn("Cache read failed, looking up on source", zap.Error(err))
+		}
+		return false
+	}
+	c.counter.Record(table, metrics.NotFoundHit)
+	return true
+}
+
+// remember marks the lookup of id in table not found for the TTL
+func (c *notFoundCache) remember(ctx context.Context, table, id, variant string) {
+	if !c.enabled() {
+		return
+	}
+	if err := c.cache.Set(ctx, c.key(ctx, table, id, variant), []byte("1"), c.ttl); err != nil {
+		c.logger.Warn("Cache write failed", zap.Error(err))
+		return
+	}
+	c.counter.Record(table, metrics.NotFoundStored)
+}
+
+// key is the cache key of a marker, in the namespace of the request's
+// tenant and at its Nessie reference, under the prefix of table
+func (c *notFoundCache) key(ctx context.Context, table, id, variant string) string {
+	var namespace string
+	if t, ok := tenant.FromContext(ctx); ok {
+		namespace = t.CacheNamespace
+	}
+	return cache.GenerateKey(notFoundPrefix(table), namespace, datasource.VersionCacheKey(ctx), id, variant)
+}
+
+// notFoundPrefix is the key prefix of the not-found markers of table, shared
+// by every tenant. The table is hashed so key names never hold its name.
+func notFoundPrefix(table string) string {
+	sum := sha256.Sum256([]byte(table))
+	return "notfound:" + hex.EncodeToString(sum[:])[:16]
+}
+
+// writeNotFound answers a lookup remembered as not found like one the
+// source did not find, flagged in meta.negative_cache
+func writeNotFound(w http.ResponseWriter, message string) {
+	response.ErrorWithMeta(w, http.StatusText(http.StatusNotFound), message, &response.Meta{NegativeCache: true}, http.StatusNotFound)
+}
@@ -0,0 +1,180 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/cache"
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/metrics"
+)
+
+// lookupSource counts the lookups that reach it
+type lookupSource struct {
+	recordingSource
+	calls int
+}
+
+func (s *lookupSource) ExecuteQuery(ctx context.Context, query string, opts *datasource.QueryOptions) (*datasource.QueryResult, error) {
+	s.calls++
+	return s.recordingSource.ExecuteQuery(ctx, query, opts)
+}
+
+// withURLParam routes r with the URL parameter name set to value
+func withURLParam(r *http.Request, name, value string) *http.Request {
+	routeCtx := chi.NewRouteContext()
+	routeCtx.URLParams.Add(name, value)
+	return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, routeCtx))
+}
+
+// negativeCache returns whether a 404 was answered from a not-found marker
+func negativeCache(t *testing.T, rec *httptest.ResponseRecorder) bool {
+	var body struct {
+		Meta struct {
+			NegativeCache bool `json:"negative_cache"`
+		} `json:"meta"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	return body.Meta.NegativeCache
+}
+
+func TestTenderGetByID_NotFoundCache(t *testing.T) {
+	source := &lookupSource{recordingSource: recordingSource{sourceType: datasource.DataSourceDremio}}
+	markers := cache.NewMemoryCache()
+	counter := metrics.NewNotFoundCounter()
+	handler := NewTenderHandler(source, testLimits, nil, zap.NewNop())
+	handler.SetNotFoundCache(50*time.Millisecond, markers, counter)
+	get := func(id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/tender/"+url.PathEscape(id), nil)
+		rec := httptest.NewRecorder()
+		handler.GetByID(rec, withURLParam(req, "id", id))
+		return rec
+	}
+
+	// The first lookup reaches the source, which must not cache the miss
+	rec := get("T404")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	assert.False(t, negativeCache(t, rec))
+	assert.True(t, source.opts.SkipCacheEmpty)
+	assert.Equal(t, int64(1), counter.Count(tenderTable, metrics.NotFoundStored))
+
+	// Repeats are answered from the marker
+	rec = get("T404")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	assert.True(t, negativeCache(t, rec))
+	assert.Equal(t, 1, source.calls)
+	assert.Equal(t, int64(1), counter.Count(tenderTable, metrics.NotFoundHit))
+
+	// Other ids are looked up
+	require.Equal(t, http.StatusNotFound, get("T405").Code)
+	assert.Equal(t, 2, source.calls)
+
+	// The marker expires, and a tender ingested since is found
+	time.Sleep(80 * time.Millisecond)
+	source.rows = []map[string]interface{}{{"tender_id": "T404"}}
+	require.Equal(t, http.StatusOK, get("T404").Code)
+	assert.Equal(t, 3, source.calls)
+}
+
+func TestTenderGetByID_NotFoundCacheDisabled(t *testing.T) {
+	source := &lookupSource{recordingSource: recordingSource{sourceType: datasource.DataSourceDremio}}
+	handler := NewTenderHandler(source, testLimits, nil, zap.NewNop())
+	handler.SetNotFoundCache(0, cache.NewMemoryCache(), nil)
+
+	for range 2 {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/tender/T404", nil)
+		rec := httptest.NewRecorder()
+		handler.GetByID(rec, withURLParam(req, "id", "T404"))
+		require.Equal(t, http.StatusNotFound, rec.Code)
+	}
+	assert.Equal(t, 2, source.calls)
+	assert.False(t, source.opts.SkipCacheEmpty, "misses are left to the result cache")
+}
+
+func TestRUP_GetByIDNotFoundCache(t *testing.T) {
+	handler, _ := newTestRUPHandler()
+	querier := &emptyQuerier{}
+	handler.bigquery = querier
+	handler.SetNotFoundCache(time.Minute, cache.NewMemoryCache(), nil)
+	get := func(r *http.Request) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.GetByID(rec, r)
+		require.Equal(t, http.StatusNotFound, rec.Code)
+		return rec
+	}
+
+	assert.False(t, negativeCache(t, get(httptest.NewRequest(http.MethodGet, "/api/v1/rup/K404", nil))))
+	assert.True(t, negativeCache(t, get(httptest.NewRequest(http.MethodGet, "/api/v1/rup/K404", nil))))
+	assert.Equal(t, 1, querier.calls)
+
+	// A soft-deleted record may still be found with include_deleted
+	assert.False(t, negativeCache(t, get(asAdmin(httptest.NewRequest(http.MethodGet, "/api/v1/rup/K404?include_deleted=true", nil)))))
+	assert.Equal(t, 2, querier.calls)
+}
+
+// emptyQuerier finds nothing
+type emptyQuerier struct {
+	calls int
+}
+
+func (q *emptyQuerier) Query(ctx context.Context, sqlQuery string) ([]map[string]interface{}, error) {
+	q.calls++
+	return nil, nil
+}
+
+func TestAdminCache_InvalidateNotFound(t *testing.T) {
+	source := &lookupSource{recordingSource: recordingSource{sourceType: datasource.DataSourceDremio}}
+	markers := cache.NewMemoryCache()
+	counter := metrics.NewNotFoundCounter()
+	handler := NewTenderHandler(source, testLimits, nil, zap.NewNop())
+	handler.SetNotFoundCache(time.Minute, markers, counter)
+	get := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/tender/T404", nil)
+		rec := httptest.NewRecorder()
+		handler.GetByID(rec, withURLParam(req, "id", "T404"))
+		return rec
+	}
+	require.Equal(t, http.StatusNotFound, get().Code)
+
+	// Result entries of the table are left alone
+	require.NoError(t, markers.Set(context.Background(), cache.GenerateKey("table", tenderTable), []byte("{}"), time.Minute))
+
+	admin := NewAdminCacheHandler(nil, markers, zap.NewNop())
+	admin.SetNotFound(counter)
+	invalidate := func(table string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		admin.InvalidateNotFound(rec, httptest.NewRequest(http.MethodDelete, "/api/v1/admin/cache/not-found?table="+url.QueryEscape(table), nil))
+		return rec
+	}
+	assert.Equal(t, http.StatusBadRequest, invalidate("nessie_iceberg.*").Code)
+	require.Equal(t, http.StatusOK, invalidate(tenderTable).Code)
+	assert.Equal(t, int64(1), counter.Count(tenderTable, metrics.NotFoundInvalidated))
+
+	// The tender ingested since is found at once
+	source.rows = []map[string]interface{}{{"tender_id": "T404"}}
+	require.Equal(t, http.StatusOK, get().Code)
+	assert.Equal(t, 2, source.calls)
+	_, err := markers.Get(context.Background(), cache.GenerateKey("table", tenderTable))
+	assert.NoError(t, err)
+}
+
+func TestNotFoundPrefix(t *testing.T) {
+	prefix := notFoundPrefix(tenderTable)
+	assert.NotContains(t, prefix, "tender", "key names never hold the table")
+	assert.NotEqual(t, prefix, notFoundPrefix(rupTable))
+
+	matched, err := path.Match(cache.KeyPattern(prefix), cache.GenerateKey(prefix, "tenant", "T404"))
+	require.NoError(t, err)
+	assert.True(t, matched)
+}
@@ -0,0 +1,146 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/response"
+)
+
+// applyLimit resolves a requested page size against an endpoint's policy.
+// Zero selects the default; negative or oversized requests are rejected.
+func applyLimit(requested int, policy config.PageLimit) (int, error) {
+	switch {
+	case requested == 0:
+		return policy.Default, nil
+	case requested < 0:
+		return 0, fmt.Errorf("limit must be positive")
+	case requested > policy.Max:
+		return 0, fmt.Errorf("limit must not exceed %d", policy.Max)
+	default:
+		return requested, nil
+	}
+}
+
+// sourceLimit lowers policy to the rows a source's defaults allow a request
+func sourceLimit(policy config.PageLimit, defaults config.QueryDefaults) config.PageLimit {
+	if defaults.MaxRows > 0 && defaults.MaxRows < policy.Max {
+		policy.Max = defaults.MaxRows
+		policy.Default = min(policy.Default, policy.Max)
+	}
+	return policy
+}
+
+// queryLimit reads the limit query parameter and applies the policy. On
+// failure it writes a 400 naming the allowed maximum and returns false.
+func queryLimit(w http.ResponseWriter, r *http.Request, policy config.PageLimit) (int, bool) {
+	requested := 0
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			writeLimitError(w, fmt.Errorf("limit must be an integer"), policy)
+			return 0, false
+		}
+		requested = n
+	}
+
+	limit, err := applyLimit(requested, policy)
+	if err != nil {
+		writeLimitError(w, err, policy)
+		return 0, false
+	}
+	return limit, true
+}
+
+// writeLimitError reports an invalid page size together with the maximum
+func writeLimitError(w http.ResponseWriter, err error, policy config.PageLimit) {
+	response.ErrorWithDetails(w, err.Error(), fmt.Sprintf("max_limit=%d", policy.Max), http.StatusBadRequest)
+}
+
+// page is the window a list or search returned: its offset and limit, the
+// rows on it and, when the endpoint counted them, the total rows
+type page struct {
+	offset, limit, rows int
+	total               int
+	counted             bool
+}
+
+// paginate sets the page fields of meta, total_pages only when the total
+// was counted, and the links to the neighbouring pages: a Link header for
+// GET lists, meta.links for POST searches
+func paginate(w http.ResponseWriter, r *http.Request, meta *response.Meta, p page) {
+	meta.Page = p.offset/p.limit + 1
+	meta.PerPage = p.limit
+	if p.counted {
+		meta.Total = p.total
+		meta.TotalPages = (p.total + p.limit - 1) / p.limit
+	}
+	links := p.links()
+	if r.Method != http.MethodGet {
+		meta.Links = links
+		return
+	}
+	w.Header().Set("Link", linkHeader(r.URL, links))
+}
+
+// links returns the pages around p. Without a total, next is offered only
+// after a full page and the last page is unknown.
+func (p page) links() *response.PageLinks {
+	at := func(offset int) *response.PageLink {
+		return &response.PageLink{Offset: offset, Limit: p.limit}
+	}
+	links := &response.PageLinks{First: at(0)}
+	if p.offset > 0 {
+		links.Prev = at(max(p.offset-p.limit, 0))
+	}
+	switch {
+	case !p.counted:
+		if p.rows >= p.limit {
+			links.Next = at(p.offset + p.limit)
+		}
+	case p.total > 0:
+		if p.offset+p.limit < p.total {
+			links.Next = at(p.offset + p.limit)
+		}
+		links.Last = at((p.total - 1) / p.limit * p.limit)
+	}
+	return links
+}
+
+// linkHeader formats links as an RFC 5988 Link header, each a copy of u
+// with its offset and limit parameters replaced
+func linkHeader(u *url.URL, links *response.PageLinks) string {
+	var parts []string
+	for _, link := range []struct {
+		rel  string
+		page *response.PageLink
+	}{{"first", links.First}, {"prev", links.Prev}, {"next", links.Next}, {"last", links.Last}} {
+		if link.page == nil {
+			continue
+		}
+		params := u.Query()
+		params.Set("offset", strconv.Itoa(link.page.Offset))
+		params.Set("limit", strconv.Itoa(link.page.Limit))
+		target := url.URL{Path: u.Path, RawPath: u.RawPath, RawQuery: params.Encode()}
+		parts = append(parts, fmt.Sprintf(`<%s>; rel="%s"`, target.String(), link.rel))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// includeTotal reads the include_total parameter of a list, true unless it
+// is false. On failure it writes a 400 and returns false for ok.
+func includeTotal(w http.ResponseWriter, raw string) (include, ok bool) {
+	if raw == "" {
+		return true, true
+	}
+	include, err := strconv.ParseBool(raw)
+	if err != nil {
+		response.Error(w, "include_total must be true or false", http.StatusBadRequest)
+		return false, false
+	}
+	return include, true
+}
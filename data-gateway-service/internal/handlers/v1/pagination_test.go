@@ -0,0 +1,315 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/clients"
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/response"
+	"go-data-gateway/pkg/apitypes"
+)
+
+var testLimits = config.PageLimit{Default: 10, Max: 50}
+
+func decodeResponse(t *testing.T, rec *httptest.ResponseRecorder) response.StandardResponse {
+	var body response.StandardResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	return body
+}
+
+func rowsOf(n int) []map[string]interface{} {
+	rows := make([]map[string]interface{}, n)
+	for i := range rows {
+		rows[i] = map[string]interface{}{"id": i}
+	}
+	return rows
+}
+
+func TestApplyLimit(t *testing.T) {
+	tests := []struct {
+		requested int
+		want      int
+		wantErr   bool
+	}{
+		{0, 10, false},
+		{1, 1, false},
+		{50, 50, false},
+		{51, 0, true},
+		{-1, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := applyLimit(tt.requested, testLimits)
+		if tt.wantErr {
+			assert.Error(t, err, tt.requested)
+			continue
+		}
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, got)
+	}
+}
+
+func TestTenderList_LimitBoundaries(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio}
+	handler := NewTenderHandler(source, testLimits, nil, zap.NewNop())
+
+	tests := []struct {
+		query     string
+		status    int
+		wantLimit int
+	}{
+		{"", http.StatusOK, 10},
+		{"?limit=50", http.StatusOK, 50},
+		{"?limit=51", http.StatusBadRequest, 0},
+		{"?limit=-5", http.StatusBadRequest, 0},
+		{"?limit=ten", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler.List(rec, httptest.NewRequest(http.MethodGet, "/api/v1/tender"+tt.query, nil))
+		require.Equal(t, tt.status, rec.Code, tt.query)
+
+		body := decodeResponse(t, rec)
+		if tt.status == http.StatusOK {
+			assert.Equal(t, tt.wantLimit, body.Meta.Limit, tt.query)
+			assert.Contains(t, source.query, fmt.Sprintf("LIMIT %d", tt.wantLimit), tt.query)
+		} else {
+			assert.Equal(t, "max_limit=50", body.Error.Details, tt.query)
+		}
+	}
+}
+
+func TestTenderSearch_LimitBoundaries(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio}
+	handler := NewTenderHandler(source, testLimits, nil, zap.NewNop())
+
+	search := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.Search(rec, httptest.NewRequest(http.MethodPost, "/api/v1/tender/search", bytes.NewBufferString(body)))
+		return rec
+	}
+
+	rec := search(`{"limit": 50}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, source.query, "LIMIT 50")
+	assert.Equal(t, 50, decodeResponse(t, rec).Meta.Limit)
+
+	rec = search(`{}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, source.query, "LIMIT 10")
+
+	assert.Equal(t, http.StatusBadRequest, search(`{"limit": 51}`).Code)
+	assert.Equal(t, http.StatusBadRequest, search(`{"limit": "all"}`).Code)
+}
+
+func TestRUP_LimitAboveMaximumRejected(t *testing.T) {
+	// The limit is validated before BigQuery is called
+	handler := NewRUPHandler(&clients.BigQueryClient{}, testLimits, zap.NewNop())
+
+	rec := httptest.NewRecorder()
+	handler.List(rec, httptest.NewRequest(http.MethodGet, "/api/v1/rup?limit=51", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "max_limit=50", decodeResponse(t, rec).Error.Details)
+
+	rec = httptest.NewRecorder()
+	handler.Search(rec, httptest.NewRequest(http.MethodPost, "/api/v1/rup/search", bytes.NewBufferString(`{"limit": 51}`)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestQuery_LimitBoundaries(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio, rows: rowsOf(30)}
+	handler := NewQueryHandler(map[string]datasource.DataSource{"DATAWAREHOUSE": source}, testLimits, nil, false, zap.NewNop())
+
+	execute := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.Execute(rec, httptest.NewRequest(http.MethodPost, "/api/v1/query", bytes.NewBufferString(body)))
+		return rec
+	}
+
+	// Without a limit the default caps the rows; Meta reports the full count
+	rec := execute(`{"sql": "SELECT * FROM t", "source": "DATAWAREHOUSE"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	body := decodeResponse(t, rec)
+	assert.Equal(t, 10, body.Meta.Limit)
+	assert.Equal(t, 30, body.Meta.Total)
+	assert.Len(t, body.Data.(map[string]interface{})["data"], 10)
+
+	rec = execute(`{"sql": "SELECT * FROM t", "source": "DATAWAREHOUSE", "limit": 50}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Len(t, decodeResponse(t, rec).Data.(map[string]interface{})["data"], 30)
+
+	rec = execute(`{"sql": "SELECT * FROM t", "source": "DATAWAREHOUSE", "limit": 51}`)
+	assert.Equal(t, []apitypes.Violation{{Field: "limit", Message: "limit must not exceed 50", Constraint: "max=50"}}, violationsOf(t, rec))
+}
+
+func TestStream_ChunkSizeBoundaries(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio, rows: rowsOf(3)}
+	handler := NewStreamHandler(map[string]datasource.DataSource{"DATAWAREHOUSE": source}, testLimits, zap.NewNop())
+
+	stream := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.Stream(rec, httptest.NewRequest(http.MethodPost, "/api/v1/stream", bytes.NewBufferString(body)))
+		return rec
+	}
+
+	rec := stream(`{"data_source": "DATAWAREHOUSE", "table": "t"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "10", rec.Header().Get("X-Chunk-Size"))
+	assert.Equal(t, 10, source.opts.Limit)
+
+	rec = stream(`{"data_source": "DATAWAREHOUSE", "table": "t", "chunk_size": 50}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "50", rec.Header().Get("X-Chunk-Size"))
+
+	rec = stream(`{"data_source": "DATAWAREHOUSE", "table": "t", "chunk_size": 51}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "must not exceed 50")
+}
+
+func TestPageLinks(t *testing.T) {
+	link := func(offset int) *response.PageLink { return &response.PageLink{Offset: offset, Limit: 10} }
+	tests := []struct {
+		name string
+		page page
+		want response.PageLinks
+	}{
+		{"first page", page{offset: 0, limit: 10, rows: 10, total: 42, counted: true},
+			response.PageLinks{First: link(0), Next: link(10), Last: link(40)}},
+		{"middle page", page{offset: 20, limit: 10, rows: 10, total: 42, counted: true},
+			response.PageLinks{First: link(0), Prev: link(10), Next: link(30), Last: link(40)}},
+		{"last page", page{offset: 40, limit: 10, rows: 2, total: 42, counted: true},
+			response.PageLinks{First: link(0), Prev: link(30), Last: link(40)}},
+		{"exactly full", page{offset: 30, limit: 10, rows: 10, total: 40, counted: true},
+			response.PageLinks{First: link(0), Prev: link(20), Last: link(30)}},
+		{"unaligned offset", page{offset: 5, limit: 10, rows: 10, total: 42, counted: true},
+			response.PageLinks{First: link(0), Prev: link(0), Next: link(15), Last: link(40)}},
+		{"nothing matched", page{limit: 10, counted: true},
+			response.PageLinks{First: link(0)}},
+		{"full page without total", page{offset: 10, limit: 10, rows: 10},
+			response.PageLinks{First: link(0), Prev: link(0), Next: link(20)}},
+		{"short page without total", page{offset: 10, limit: 10, rows: 3},
+			response.PageLinks{First: link(0), Prev: link(0)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, *tt.page.links())
+		})
+	}
+}
+
+func TestRUP_ListLinks(t *testing.T) {
+	list := func(query string) (*httptest.ResponseRecorder, response.StandardResponse) {
+		handler, _ := newTestRUPHandler()
+		rec := httptest.NewRecorder()
+		handler.List(rec, httptest.NewRequest(http.MethodGet, "/api/v1/rup"+query, nil))
+		require.Equal(t, http.StatusOK, rec.Code, query)
+		return rec, decodeResponse(t, rec)
+	}
+
+	// The total of 42 makes five pages of ten
+	rec, body := list("?limit=10")
+	assert.Equal(t, 1, body.Meta.Page)
+	assert.Equal(t, 5, body.Meta.TotalPages)
+	assert.Equal(t, `</api/v1/rup?limit=10&offset=0>; rel="first", `+
+		`</api/v1/rup?limit=10&offset=10>; rel="next", `+
+		`</api/v1/rup?limit=10&offset=40>; rel="last"`, rec.Header().Get("Link"))
+	assert.Nil(t, body.Meta.Links)
+
+	rec, body = list("?limit=10&offset=20&include_deleted=false")
+	assert.Equal(t, 3, body.Meta.Page)
+	assert.Equal(t, `</api/v1/rup?include_deleted=false&limit=10&offset=0>; rel="first", `+
+		`</api/v1/rup?include_deleted=false&limit=10&offset=10>; rel="prev", `+
+		`</api/v1/rup?include_deleted=false&limit=10&offset=30>; rel="next", `+
+		`</api/v1/rup?include_deleted=false&limit=10&offset=40>; rel="last"`, rec.Header().Get("Link"))
+
+	rec, body = list("?limit=10&offset=40")
+	assert.Equal(t, 5, body.Meta.Page)
+	assert.Equal(t, 5, body.Meta.TotalPages)
+	assert.Equal(t, `</api/v1/rup?limit=10&offset=0>; rel="first", `+
+		`</api/v1/rup?limit=10&offset=30>; rel="prev", `+
+		`</api/v1/rup?limit=10&offset=40>; rel="last"`, rec.Header().Get("Link"))
+}
+
+func TestRUP_ListWithoutTotal(t *testing.T) {
+	handler, querier := newTestRUPHandler()
+
+	// The querier returns one row, a full page of one
+	rec := httptest.NewRecorder()
+	handler.List(rec, httptest.NewRequest(http.MethodGet, "/api/v1/rup?limit=1&offset=3&include_total=false", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Len(t, querier.queries, 1)
+	body := decodeResponse(t, rec)
+	assert.Zero(t, body.Meta.Total)
+	assert.Zero(t, body.Meta.TotalPages)
+	assert.Equal(t, 4, body.Meta.Page)
+	assert.Equal(t, `</api/v1/rup?include_total=false&limit=1&offset=0>; rel="first", `+
+		`</api/v1/rup?include_total=false&limit=1&offset=2>; rel="prev", `+
+		`</api/v1/rup?include_total=false&limit=1&offset=4>; rel="next"`, rec.Header().Get("Link"))
+
+	// A short page is the last one
+	rec = httptest.NewRecorder()
+	handler.List(rec, httptest.NewRequest(http.MethodGet, "/api/v1/rup?limit=10&include_total=false", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `</api/v1/rup?include_total=false&limit=10&offset=0>; rel="first"`, rec.Header().Get("Link"))
+
+	rec = httptest.NewRecorder()
+	handler.List(rec, httptest.NewRequest(http.MethodGet, "/api/v1/rup?include_total=maybe", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestRUP_SearchLinks(t *testing.T) {
+	search := func(body string) response.StandardResponse {
+		handler, _ := newTestRUPHandler()
+		rec := httptest.NewRecorder()
+		handler.Search(rec, httptest.NewRequest(http.MethodPost, "/api/v1/rup/search", bytes.NewBufferString(body)))
+		require.Equal(t, http.StatusOK, rec.Code, body)
+		assert.Empty(t, rec.Header().Get("Link"))
+		return decodeResponse(t, rec)
+	}
+	link := func(offset int) *response.PageLink { return &response.PageLink{Offset: offset, Limit: 20} }
+
+	meta := search(`{"tahun": "2024", "limit": 20}`).Meta
+	assert.Equal(t, 3, meta.TotalPages)
+	assert.Equal(t, &response.PageLinks{First: link(0), Next: link(20), Last: link(40)}, meta.Links)
+
+	meta = search(`{"tahun": "2024", "limit": 20, "offset": 20}`).Meta
+	assert.Equal(t, &response.PageLinks{First: link(0), Prev: link(0), Next: link(40), Last: link(40)}, meta.Links)
+
+	meta = search(`{"tahun": "2024", "limit": 20, "offset": 40}`).Meta
+	assert.Equal(t, 3, meta.Page)
+	assert.Equal(t, &response.PageLinks{First: link(0), Prev: link(20), Last: link(40)}, meta.Links)
+
+	// Without the total the one row is a short page, and the last
+	meta = search(`{"tahun": "2024", "limit": 20, "offset": 40, "include_total": false}`).Meta
+	assert.Zero(t, meta.TotalPages)
+	assert.Equal(t, &response.PageLinks{First: link(0), Prev: link(20)}, meta.Links)
+}
+
+func TestTenderList_Links(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio, rows: rowsOf(10)}
+	handler := NewTenderHandler(source, testLimits, nil, zap.NewNop())
+
+	// Tender pages have no total; a full page links to the next
+	rec := httptest.NewRecorder()
+	handler.List(rec, httptest.NewRequest(http.MethodGet, "/api/v1/tender?status=Aktif&offset=10", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Zero(t, decodeResponse(t, rec).Meta.TotalPages)
+	assert.Equal(t, `</api/v1/tender?limit=10&offset=0&status=Aktif>; rel="first", `+
+		`</api/v1/tender?limit=10&offset=0&status=Aktif>; rel="prev", `+
+		`</api/v1/tender?limit=10&offset=20&status=Aktif>; rel="next"`, rec.Header().Get("Link"))
+
+	source.rows = rowsOf(4)
+	rec = httptest.NewRecorder()
+	handler.List(rec, httptest.NewRequest(http.MethodGet, "/api/v1/tender?status=Aktif&offset=20", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Header().Get("Link"), `rel="next"`)
+}
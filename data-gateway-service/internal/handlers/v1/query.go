@@ -1,77 +1,411 @@
 package v1
 
 import (
-	"encoding/json"
+	"context"
+	"fmt"
 	"net/http"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 
 	"go.uber.org/zap"
 
+	"go-data-gateway/internal/auth"
+	"go-data-gateway/internal/cache"
+	"go-data-gateway/internal/clients"
+	"go-data-gateway/internal/config"
 	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/inflight"
+	"go-data-gateway/internal/metrics"
 	"go-data-gateway/internal/response"
+	"go-data-gateway/internal/sqltext"
+	"go-data-gateway/pkg/apitypes"
 )
 
 // QueryHandler handles query requests with multiple data sources
 type QueryHandler struct {
 	dataSources map[string]datasource.DataSource
+	limits      config.PageLimit
+	metrics     *metrics.QueryCounter
+	exposeJobs  bool // Return Dremio job ids and profile links to callers
+	autoLimit   autoLimit
+	stream      config.QueryStreamConfig // Thresholds past which responses are streamed
+	engineOpts  []string                 // Dremio session options requests may set
+	ceilings    config.QueryCeilings     // Bound the cache TTL and timeout requests ask for
+	timeTravel  *timeTravel
+	countTTL    time.Duration // Cache TTL of count_only queries
+	estimator   costEstimator // Reports the bytes BigQuery counts scan
 	logger      *zap.Logger
 }
 
 // NewQueryHandler creates a new query handler
-func NewQueryHandler(dataSources map[string]datasource.DataSource, logger *zap.Logger) *QueryHandler {
+func NewQueryHandler(dataSources map[string]datasource.DataSource, limits config.PageLimit, queryMetrics *metrics.QueryCounter, exposeJobs bool, logger *zap.Logger) *QueryHandler {
 	return &QueryHandler{
 		dataSources: dataSources,
+		limits:      limits,
+		metrics:     queryMetrics,
+		exposeJobs:  exposeJobs,
+		ceilings:    config.DefaultQueryCeilings(),
+		timeTravel:  newTimeTravel(logger),
+		countTTL:    config.DefaultCountCacheTTL,
 		logger:      logger,
 	}
 }
 
-// QueryRequest represents a query request
-type QueryRequest struct {
-	SQL    string                    `json:"sql" binding:"required"`
-	Source datasource.DataSourceType `json:"source" binding:"required"`
+// SetCountOnly sets the cache TTL of count_only queries that set none, and
+// the estimator reporting the bytes their BigQuery counts scan
+func (h *QueryHandler) SetCountOnly(ttl time.Duration, estimator *clients.QueryCostEstimator) {
+	h.countTTL = ttl
+	h.estimator = nil
+	if estimator != nil {
+		h.estimator = estimator
+	}
+}
+
+// SetQueryCeilings sets the largest cache TTL and timeout a request may ask
+// for over its source's defaults
+func (h *QueryHandler) SetQueryCeilings(ceilings config.QueryCeilings) {
+	h.ceilings = ceilings
+}
+
+// SetAutoLimit injects LIMIT limit into queries without a top-level LIMIT
+func (h *QueryHandler) SetAutoLimit(limit int) {
+	h.autoLimit = autoLimit(limit)
+}
+
+// SetEngineOptions sets the Dremio session options debug keys may set with
+// engine_options
+func (h *QueryHandler) SetEngineOptions(allowed []string) {
+	h.engineOpts = allowed
+}
+
+// SetTimeTravel sets the retention window of as_of, the tables raw SQL may
+// read with it and whether it may read a branch other than the default
+func (h *QueryHandler) SetTimeTravel(cfg config.TimeTravelConfig) {
+	h.timeTravel.config = cfg
 }
 
 // Execute handles query execution requests
 func (h *QueryHandler) Execute(w http.ResponseWriter, r *http.Request) {
-	var req QueryRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		response.Error(w, "Invalid request body", http.StatusBadRequest)
+	var req apitypes.QueryRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+
+	if len(req.EngineOptions) > 0 && !auth.HasScope(r.Context(), auth.ScopeDebug) {
+		response.Error(w, "engine_options require a key with the debug scope", http.StatusForbidden)
 		return
 	}
 
+	var v violations
+	if req.SQL == "" {
+		v.required("sql")
+	}
+	v.source("source", string(req.Source), h.validSources())
+	name, source := h.source(req.Source)
+
+	// Requests override the source's defaults, which override the global ones
+	defaults := datasource.Defaults(source)
+	limit := v.limit("limit", req.Limit, sourceLimit(h.limits, defaults))
+	cacheTTL := v.seconds("cache_ttl_seconds", req.CacheTTLSeconds, defaults.CacheTTL, h.ceilings.MaxCacheTTL)
+	timeout := v.seconds("timeout_seconds", req.TimeoutSeconds, defaults.Timeout, h.ceilings.MaxTimeout)
+	maxAge, err := requestMaxAge(r, req.MaxAgeSeconds)
+	v.addErr(maxAgeParam, "min=0", err)
+	v.addErr("labels", "labels", datasource.ValidateLabels(req.Labels))
+	v.addErr("engine_options", "engine_options", datasource.ValidateEngineOptions(req.EngineOptions, h.engineOpts))
+	asOf, err := h.timeTravel.parse(r.Context(), req.AsOf)
+	v.addErr(asOfParam, asOfParam, err)
+	if req.CountOnly && req.ValidateOnly {
+		v.add("count_only", "excluded_with=validate_only", "count_only and validate_only cannot be combined")
+	}
+	if v.write(w) {
+		return
+	}
+
+	// The labels are valid, so attribution cannot fail
+	ctx, attribution, _ := attribute(r.Context(), req.Labels)
+
 	h.logger.Info("Executing query",
 		zap.String("source", string(req.Source)),
-		zap.String("sql", req.SQL))
-
-	// Find the appropriate data source
-	var source datasource.DataSource
-	for _, ds := range h.dataSources {
-		if ds.GetType() == req.Source {
-			source = ds
-			break
-		}
-	}
+		zap.String("sql", req.SQL),
+		zap.String("api_key_id", attribution.APIKeyID),
+		zap.String("request_id", attribution.RequestID),
+		zap.Any("labels", req.Labels),
+		zap.Any("engine_options", req.EngineOptions))
 
 	if source == nil {
 		response.Error(w, "Data source not available: "+string(req.Source), http.StatusServiceUnavailable)
 		return
 	}
+	if len(req.EngineOptions) > 0 && source.GetType() != datasource.DataSourceDremio {
+		v.add("engine_options", "source=dremio", "engine_options apply to Dremio sources only")
+		v.write(w)
+		return
+	}
+	// Blanking comments out keeps the positions of upstream errors
+	if !req.PreserveComments {
+		req.SQL = sqltext.StripComments(req.SQL, source.GetType().Dialect())
+	}
+	var asOfTable string
+	if req.AsOf != "" {
+		if source.GetType() != datasource.DataSourceDremio {
+			v.add(asOfParam, "source=dremio", "%s applies to Dremio sources only", asOfParam)
+			v.write(w)
+			return
+		}
+		// The query is rewritten before it is run, so the cache keys it too
+		rewritten, table, err := datasource.AsOfQuery(req.SQL, datasource.DialectANSI, asOf.At, asOf.Branch, h.timeTravel.config.Tables)
+		if err != nil {
+			v.addErr(asOfParam, "single_table", err)
+			v.write(w)
+			return
+		}
+		req.SQL, asOfTable = rewritten, table
+	}
+	var counted string
+	if req.CountOnly {
+		var ok bool
+		if counted, ok = datasource.CountQuery(req.SQL, source.GetType().Dialect()); !ok {
+			v.add("count_only", "select", "count_only applies to a single SELECT or WITH query")
+			v.write(w)
+			return
+		}
+	}
+
+	if req.ValidateOnly {
+		h.validate(ctx, w, source, req)
+		return
+	}
+	inflight.FromContext(ctx).Describe(string(req.Source), req.SQL)
 
 	// Execute query with timeout
 	opts := &datasource.QueryOptions{
-		Timeout:  30 * time.Second,
-		CacheTTL: 5 * time.Minute,
+		Timeout:  timeout,
+		CacheTTL: cacheTTL,
+		MaxAge:   maxAge,
+	}
+	if len(req.EngineOptions) > 0 {
+		// Results under other session options must not be served or replaced
+		opts.EngineOptions = req.EngineOptions
+		opts.SkipCache = true
 	}
 
-	result, err := source.ExecuteQuery(r.Context(), req.SQL, opts)
+	sql, injected := h.autoLimit.apply(ctx, req.SQL, source.GetType().Dialect())
+	if req.CountOnly {
+		// A count is a single row, so it is not limited, and is kept longer
+		// than rows unless the request set its own TTL
+		sql, injected = counted, 0
+		opts.CacheTTL = countCacheTTL(req.CacheTTLSeconds, cacheTTL, h.countTTL)
+	}
+	start := time.Now()
+	result, err := source.ExecuteQuery(ctx, sql, opts)
+	h.metrics.Record(name, attribution.JobLabels())
 	if err != nil {
+		if req.CountOnly {
+			err = datasource.ShiftInjectedLimit(err)
+		} else {
+			err = unshiftLimit(err, injected)
+		}
 		h.logger.Error("Query execution failed",
 			zap.String("source", string(req.Source)),
 			zap.Error(err))
-		response.ErrorWithDetails(w, "Query execution failed", err.Error(), http.StatusInternalServerError)
+		if !writeQueryError(w, err, req.SQL, "Query execution failed") {
+			response.ErrorWithDetails(w, "Query execution failed", err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	jobID, profileURL := datasource.DremioJob(result)
+	h.logger.Info("Query executed",
+		zap.String("source", string(req.Source)),
+		zap.String("api_key_id", attribution.APIKeyID),
+		zap.String("request_id", attribution.RequestID),
+		zap.String("dremio_job_id", jobID),
+		zap.Int("rows", result.Count),
+		zap.Bool("cache_hit", result.CacheHit))
+	h.timeTravel.record(r, asOfTable, asOf)
+
+	meta := &response.Meta{
+		AgeSeconds:        ageSeconds(result),
+		SchemaFingerprint: datasource.SchemaFingerprint(result),
+		StringNumbers:     datasource.StringNumbers(result),
+		Branch:            branchOf(ctx, result),
+		AsOf:              asOf.meta(),
+		CacheWrite:        cache.CacheWrite(result),
+		BigQuery:          bigQueryJob(result),
+	}
+	if warning := cacheWriteWarning(result, req.RequireCacheWrite); warning != nil {
+		meta.Warnings = append(meta.Warnings, *warning)
+	}
+	warnRenamedColumns(meta, result)
+	if injected > 0 {
+		meta.LimitInjected, meta.InjectedLimit = true, injected
+		meta.Warnings = append(meta.Warnings, limitWarnings(injected)...)
+	}
+	if h.exposeJobs {
+		meta.DremioJobID, meta.DremioProfileURL = jobID, profileURL
+	} else if jobID != "" {
+		result = withoutMetadata(result, datasource.MetaDremioJobID, datasource.MetaDremioProfileURL)
+	}
+	if _, ok := result.Metadata[datasource.MetaBigQueryJob]; ok {
+		// Reported in meta, and stale in results served from cache
+		result = withoutMetadata(result, datasource.MetaBigQueryJob)
+	}
+	if req.CountOnly {
+		h.writeCount(ctx, w, source, sql, result, time.Since(start), meta)
 		return
 	}
 
+	// Raw SQL is passed through unchanged, so the row cap is applied here
+	total := len(result.Data)
+	if total > limit {
+		response.Warn(meta, response.WarnResultTruncated,
+			fmt.Sprintf("The result has %d rows; only the first %d are returned", total, limit),
+			map[string]int{"total": total, "limit": limit})
+		truncated := *result
+		truncated.Data = result.Data[:limit]
+		truncated.Count = limit
+		result = &truncated
+	}
+
 	// Send successful response
-	response.Success(w, result, nil)
+	meta.Total, meta.Limit = total, limit
+	h.writeResult(w, r, result, meta)
+}
+
+// validSources are the source values a query may name: the configured
+// sources and every source type. A type with no source configured is
+// unavailable rather than invalid.
+func (h *QueryHandler) validSources() map[string]bool {
+	return querySources(h.dataSources)
+}
+
+// querySources are the source values a query on dataSources may name
+func querySources(dataSources map[string]datasource.DataSource) map[string]bool {
+	names := sourceNames(dataSources)
+	for _, t := range []datasource.DataSourceType{
+		datasource.DataSourceDremio,
+		datasource.DataSourceBigQuery,
+		datasource.DataSourceMySQL,
+		datasource.DataSourcePostgres,
+	} {
+		names[string(t)] = true
+	}
+	return names
+}
+
+// source finds a data source by name, e.g. a second Dremio cluster, or else
+// the first by name of the sources of that type
+func (h *QueryHandler) source(requested datasource.DataSourceType) (string, datasource.DataSource) {
+	return findSource(h.dataSources, requested)
+}
+
+// findSource finds the source named requested in dataSources, or else the
+// first by name of the sources of that type
+func findSource(dataSources map[string]datasource.DataSource, requested datasource.DataSourceType) (string, datasource.DataSource) {
+	if source, ok := dataSources[string(requested)]; ok {
+		return string(requested), source
+	}
+	names := make([]string, 0, len(dataSources))
+	for name, source := range dataSources {
+		if source.GetType() == requested {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "", nil
+	}
+	sort.Strings(names)
+	return names[0], dataSources[names[0]]
+}
+
+// withoutMetadata returns a copy of result without keys in its metadata,
+// e.g. the Dremio job for callers that must not see upstream job ids
+func withoutMetadata(result *datasource.QueryResult, keys ...string) *datasource.QueryResult {
+	stripped := *result
+	stripped.Metadata = make(map[string]interface{}, len(result.Metadata))
+	for key, value := range result.Metadata {
+		if !slices.Contains(keys, key) {
+			stripped.Metadata[key] = value
+		}
+	}
+	return &stripped
+}
+
+// warnRenamedColumns warns in meta of the columns of result renamed because
+// their name was repeated. The renames are kept with a cached result, so a
+// cache hit warns as the original execution did.
+func warnRenamedColumns(meta *response.Meta, result *datasource.QueryResult) {
+	renamed := datasource.RenamedColumns(result)
+	if len(renamed) == 0 {
+		return
+	}
+	names := make([]string, 0, len(renamed))
+	for name := range renamed {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	response.Warn(meta, response.WarnColumnsRenamed,
+		fmt.Sprintf("Repeated column names were renamed: %s", strings.Join(names, ", ")),
+		renamed)
+}
+
+// bigQueryJob returns the meta of the BigQuery job that produced result, nil
+// when no job ran for it
+func bigQueryJob(result *datasource.QueryResult) *response.BigQueryJob {
+	stats := datasource.BigQueryJob(result)
+	if stats == nil {
+		return nil
+	}
+	return &response.BigQueryJob{
+		JobID:               stats.JobID,
+		TotalBytesProcessed: stats.TotalBytesProcessed,
+		TotalBytesBilled:    stats.TotalBytesBilled,
+		CacheHit:            stats.CacheHit,
+		SlotMillis:          stats.SlotMillis,
+	}
+}
+
+// branchOf returns the Nessie branch or tag a Dremio result was read at, and
+// "" for results of other sources
+func branchOf(ctx context.Context, result *datasource.QueryResult) string {
+	if result == nil || result.Source != datasource.DataSourceDremio {
+		return ""
+	}
+	version, _ := datasource.VersionFromContext(ctx)
+	return version.Name
+}
+
+// writeCount answers a count_only request with the count of result, and the
+// bytes scanned when BigQuery counted it
+func (h *QueryHandler) writeCount(ctx context.Context, w http.ResponseWriter, source datasource.DataSource, query string, result *datasource.QueryResult, elapsed time.Duration, meta *response.Meta) {
+	count, err := readCount(result.Data, "row_count")
+	if err != nil {
+		h.logger.Error("Unexpected count result", zap.Error(err))
+		response.Error(w, "Query execution failed", http.StatusInternalServerError)
+		return
+	}
+	data := CountResult{Count: count, QueryTimeMs: elapsed.Milliseconds(), CacheHit: result.CacheHit}
+	if source.GetType() == datasource.DataSourceBigQuery && !result.CacheHit {
+		data.BytesScanned = scannedBytes(ctx, h.estimator, query, h.logger)
+	}
+
+	meta.SchemaFingerprint = "" // Of the count, not of the query
+	response.Success(w, data, meta)
+}
+
+// validate answers a validate_only request
+func (h *QueryHandler) validate(ctx context.Context, w http.ResponseWriter, source datasource.DataSource, req apitypes.QueryRequest) {
+	if err := datasource.ValidateQuery(ctx, source, req.SQL); err != nil {
+		h.logger.Debug("Query validation failed",
+			zap.String("source", string(req.Source)),
+			zap.Error(err))
+		if !writeQueryError(w, err, req.SQL, "Query validation failed") {
+			response.ErrorWithDetails(w, "Query validation failed", err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	response.Success(w, apitypes.QueryValidation{Valid: true, Source: source.GetType()}, nil)
 }
@@ -0,0 +1,156 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/jsonrows"
+	"go-data-gateway/internal/response"
+)
+
+// queryChunkSize is how many encoded bytes are collected before they are
+// handed to the response
+const queryChunkSize = 32 << 10
+
+// SetStreaming streams responses of results past the configured thresholds
+func (h *QueryHandler) SetStreaming(cfg config.QueryStreamConfig) {
+	h.stream = cfg
+}
+
+// writeResult sends result as response.Success does. A result of at least
+// RowThreshold rows, or whose response grows past ByteThreshold bytes, is
+// written while its rows are encoded, so the first bytes leave early and the
+// body is never held in memory whole. The JSON is the same either way.
+func (h *QueryHandler) writeResult(w http.ResponseWriter, r *http.Request, result *datasource.QueryResult, meta *response.Meta) {
+	streamRows := h.stream.RowThreshold > 0 && len(result.Data) >= h.stream.RowThreshold
+	if (!streamRows && h.stream.ByteThreshold <= 0) || len(result.Data) == 0 || needsWholeBody(w, r) {
+		response.Success(w, result, meta)
+		return
+	}
+
+	tail, err := result.TailJSON()
+	if err != nil {
+		response.Success(w, result, meta) // Fails the same way it did before streaming
+		return
+	}
+	var metaJSON []byte
+	if sent := response.SentMeta(meta); sent != nil {
+		metaJSON, err = json.Marshal(sent)
+	}
+	if err != nil {
+		response.Success(w, result, meta)
+		return
+	}
+	var warningsJSON []byte
+	if meta != nil && len(meta.Warnings) > 0 {
+		if warningsJSON, err = json.Marshal(meta.Warnings); err != nil {
+			response.Success(w, result, meta)
+			return
+		}
+	}
+
+	out := &deferredWriter{w: w, limit: h.stream.ByteThreshold}
+	if streamRows {
+		out.start()
+	}
+
+	buf := make([]byte, 0, queryChunkSize+4096)
+	buf = append(buf, `{"success":true,"data":{"data":[`...)
+	enc := jsonrows.NewEncoder()
+	for i, row := range result.Data {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		if buf, err = enc.AppendRow(buf, row); err != nil {
+			break
+		}
+		if len(buf) >= queryChunkSize {
+			out.Write(buf)
+			buf = buf[:0]
+		}
+	}
+	if err == nil {
+		buf = append(buf, ']')
+		buf = append(buf, tail...)
+		if metaJSON != nil {
+			buf = append(buf, `,"meta":`...)
+			buf = append(buf, metaJSON...)
+		}
+		if warningsJSON != nil {
+			buf = append(buf, `,"warnings":`...)
+			buf = append(buf, warningsJSON...)
+		}
+		buf = append(buf, "}\n"...)
+		out.Write(buf)
+	}
+
+	if err != nil && !out.streaming {
+		h.logger.Error("Failed to encode query result", zap.Error(err))
+		response.ErrorWithDetails(w, "Failed to encode query result", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err != nil {
+		// The status is sent; the truncated body fails to parse
+		h.logger.Error("Failed to encode streamed query result", zap.Error(err))
+		return
+	}
+	if err := out.Close(); err != nil {
+		h.logger.Debug("Query response not delivered", zap.Error(err))
+	}
+}
+
+// needsWholeBody reports whether a response has to be buffered: an ETag,
+// set by a caller of the handler or asked for by a conditional request,
+// hashes the whole body before it is sent
+func needsWholeBody(w http.ResponseWriter, r *http.Request) bool {
+	return w.Header().Get("ETag") != "" || r.Header.Get("If-None-Match") != ""
+}
+
+// deferredWriter holds a response until it exceeds limit bytes, then sends
+// the status and writes through. A response that stays within the limit is
+// sent on Close with its Content-Length.
+type deferredWriter struct {
+	w         http.ResponseWriter
+	limit     int // 0 holds the whole response
+	buf       []byte
+	streaming bool
+	err       error
+}
+
+// start sends the status; later writes go to the client
+func (d *deferredWriter) start() {
+	d.w.Header().Set("Content-Type", "application/json")
+	d.w.WriteHeader(http.StatusOK)
+	d.streaming = true
+}
+
+func (d *deferredWriter) Write(p []byte) {
+	if d.err != nil {
+		return
+	}
+	if !d.streaming {
+		d.buf = append(d.buf, p...)
+		if d.limit <= 0 || len(d.buf) <= d.limit {
+			return
+		}
+		d.start()
+		p, d.buf = d.buf, nil
+	}
+	_, d.err = d.w.Write(p)
+}
+
+// Close sends a held response and returns the first write error
+func (d *deferredWriter) Close() error {
+	if !d.streaming {
+		d.w.Header().Set("Content-Type", "application/json")
+		d.w.Header().Set("Content-Length", strconv.Itoa(len(d.buf)))
+		d.w.WriteHeader(http.StatusOK)
+		_, d.err = d.w.Write(d.buf)
+	}
+	return d.err
+}
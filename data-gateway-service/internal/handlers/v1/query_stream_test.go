@@ -0,0 +1,155 @@
+package v1
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/response"
+)
+
+// firstWriteRecorder records how much of the body the first write carried
+type firstWriteRecorder struct {
+	*httptest.ResponseRecorder
+	first int
+}
+
+func (r *firstWriteRecorder) Write(p []byte) (int, error) {
+	if r.Body.Len() == 0 {
+		r.first = len(p)
+	}
+	return r.ResponseRecorder.Write(p)
+}
+
+// streamedRows builds n rows with strings that need escaping and mixed types
+func streamedRows(n int) []map[string]interface{} {
+	rows := make([]map[string]interface{}, n)
+	for i := range rows {
+		rows[i] = map[string]interface{}{
+			"tender_id":  fmt.Sprintf("TND-%07d", i),
+			"nama_paket": fmt.Sprintf("Jalan <Tol> & \"Jembatan\" %d", i),
+			"nilai_pagu": float64(i) * 1250000.5,
+			"tahun":      int64(2025),
+			"tanggal":    time.Date(2025, 1, 1, i%24, 0, 0, 0, time.UTC),
+			"catatan":    nil,
+		}
+	}
+	return rows
+}
+
+func writeQueryResult(stream config.QueryStreamConfig, r *http.Request, result *datasource.QueryResult, meta *response.Meta) *firstWriteRecorder {
+	handler := NewQueryHandler(nil, testLimits, nil, false, zap.NewNop())
+	handler.SetStreaming(stream)
+	rec := &firstWriteRecorder{ResponseRecorder: httptest.NewRecorder()}
+	handler.writeResult(rec, r, result, meta)
+	return rec
+}
+
+func TestQuery_StreamsLargeResultsWithTheSameBody(t *testing.T) {
+	result := &datasource.QueryResult{
+		Data:      streamedRows(2000),
+		Count:     2000,
+		Source:    datasource.DataSourceDremio,
+		QueryTime: 42 * time.Millisecond,
+		Metadata:  map[string]interface{}{"cached_at": "2025-01-01T00:00:00Z"},
+	}
+	meta := &response.Meta{Total: 2500, Limit: 2000, LimitInjected: true, InjectedLimit: 2000, Warnings: limitWarnings(2000)}
+
+	want := httptest.NewRecorder()
+	response.Success(want, result, meta)
+
+	tests := []struct {
+		name     string
+		stream   config.QueryStreamConfig
+		streamed bool
+	}{
+		{"disabled", config.QueryStreamConfig{}, false},
+		{"below both thresholds", config.QueryStreamConfig{RowThreshold: 5000, ByteThreshold: 10 << 20}, false},
+		{"row threshold", config.QueryStreamConfig{RowThreshold: 2000}, true},
+		{"byte threshold", config.QueryStreamConfig{RowThreshold: 5000, ByteThreshold: 64 << 10}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := writeQueryResult(tt.stream, httptest.NewRequest(http.MethodPost, "/api/v1/query", nil), result, meta)
+
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+			assert.Equal(t, want.Body.String(), rec.Body.String())
+			assert.Equal(t, tt.streamed, rec.first < rec.Body.Len(), "first write of %d of %d bytes", rec.first, rec.Body.Len())
+		})
+	}
+}
+
+func TestQuery_StreamingBuffersResponsesNeedingAnETag(t *testing.T) {
+	result := &datasource.QueryResult{Data: streamedRows(100), Count: 100, Source: datasource.DataSourceDremio}
+	stream := config.QueryStreamConfig{RowThreshold: 10}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/query", nil)
+	req.Header.Set("If-None-Match", `"abc"`)
+	rec := writeQueryResult(stream, req, result, &response.Meta{})
+	assert.Equal(t, rec.Body.Len(), rec.first)
+
+	handler := NewQueryHandler(nil, testLimits, nil, false, zap.NewNop())
+	handler.SetStreaming(stream)
+	tagged := &firstWriteRecorder{ResponseRecorder: httptest.NewRecorder()}
+	tagged.Header().Set("ETag", `"abc"`)
+	handler.writeResult(tagged, httptest.NewRequest(http.MethodPost, "/api/v1/query", nil), result, &response.Meta{})
+	assert.Equal(t, tagged.Body.Len(), tagged.first)
+}
+
+func TestQuery_ExecuteStreamsPastRowThreshold(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio, rows: rowsOf(10)}
+	handler := NewQueryHandler(map[string]datasource.DataSource{"DATAWAREHOUSE": source}, testLimits, nil, false, zap.NewNop())
+	handler.SetStreaming(config.QueryStreamConfig{RowThreshold: 5})
+
+	rec := httptest.NewRecorder()
+	handler.Execute(rec, httptest.NewRequest(http.MethodPost, "/api/v1/query",
+		bytes.NewBufferString(`{"sql": "SELECT id FROM t LIMIT 10", "source": "DATAWAREHOUSE"}`)))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	body := decodeResponse(t, rec)
+	assert.True(t, body.Success)
+	assert.Equal(t, 10, body.Meta.Total)
+	assert.Len(t, body.Data.(map[string]interface{})["data"], 10)
+}
+
+// discardWriter is a ResponseWriter that drops the body
+type discardWriter struct{ header http.Header }
+
+func (w *discardWriter) Header() http.Header         { return w.header }
+func (w *discardWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (w *discardWriter) WriteHeader(int)             {}
+
+// BenchmarkQueryResponse writes a 50k-row result buffered, as before
+// streaming, and streamed; compare B/op for the memory held per response
+func BenchmarkQueryResponse(b *testing.B) {
+	result := &datasource.QueryResult{Data: streamedRows(50000), Count: 50000, Source: datasource.DataSourceDremio}
+	meta := &response.Meta{Total: 50000, Limit: 50000}
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/query", nil)
+
+	for _, bench := range []struct {
+		name   string
+		stream config.QueryStreamConfig
+	}{
+		{"buffered", config.QueryStreamConfig{}},
+		{"streamed", config.QueryStreamConfig{RowThreshold: 5000}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			handler := NewQueryHandler(nil, testLimits, nil, false, zap.NewNop())
+			handler.SetStreaming(bench.stream)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				handler.writeResult(&discardWriter{header: http.Header{}}, req, result, meta)
+			}
+		})
+	}
+}
@@ -0,0 +1,546 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go-data-gateway/internal/auth"
+	"go-data-gateway/internal/cache"
+	"go-data-gateway/internal/clients"
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/metrics"
+	"go-data-gateway/internal/response"
+)
+
+// failingSource fails every query with err after recording it
+type failingSource struct {
+	recordingSource
+	err error
+}
+
+func (s *failingSource) ExecuteQuery(ctx context.Context, query string, opts *datasource.QueryOptions) (*datasource.QueryResult, error) {
+	s.query, s.opts = query, opts
+	return nil, s.err
+}
+
+func (s *failingSource) GetData(ctx context.Context, table string, opts *datasource.QueryOptions) (*datasource.QueryResult, error) {
+	return s.ExecuteQuery(ctx, table, opts)
+}
+
+// queryError posts body to the query handler and decodes the error
+func queryError(t *testing.T, source datasource.DataSource, body string) (int, string, json.RawMessage) {
+	handler := NewQueryHandler(map[string]datasource.DataSource{"src": source}, testLimits, nil, false, zap.NewNop())
+	rec := httptest.NewRecorder()
+	handler.Execute(rec, httptest.NewRequest(http.MethodPost, "/api/v1/query", bytes.NewBufferString(body)))
+
+	var resp struct {
+		Error struct {
+			Code    string          `json:"code"`
+			Details json.RawMessage `json:"details"`
+		} `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	return rec.Code, resp.Error.Code, resp.Error.Details
+}
+
+func queryBody(t *testing.T, fields map[string]interface{}) string {
+	b, err := json.Marshal(fields)
+	require.NoError(t, err)
+	return string(b)
+}
+
+func TestQuery_MalformedSQLReturnsPosition(t *testing.T) {
+	tests := []struct {
+		name   string
+		source datasource.DataSourceType
+		sql    string
+		err    error
+		want   QueryErrorDetails
+	}{
+		{
+			name:   "dremio parse error",
+			source: datasource.DataSourceDremio,
+			sql:    "SELECT * FORM tender_data",
+			err: datasource.ClassifyDremioError(status.Error(codes.InvalidArgument,
+				"PARSE ERROR: Encountered \"FORM\" at line 1, column 10.\nWas expecting one of: ...")),
+			want: QueryErrorDetails{
+				Message: `PARSE ERROR: Encountered "FORM" at line 1, column 10.`,
+				Line:    1,
+				Column:  10,
+				Snippet: "SELECT * FORM tender_data\n         ^",
+			},
+		},
+		{
+			name:   "dremio unknown column",
+			source: datasource.DataSourceDremio,
+			sql:    "SELECT id,\n       nme\nFROM tender_data",
+			err: datasource.ClassifyDremioError(status.Error(codes.InvalidArgument,
+				"VALIDATION ERROR: From line 2, column 8 to line 2, column 10: Column 'nme' not found in any table")),
+			want: QueryErrorDetails{
+				Message: "VALIDATION ERROR: From line 2, column 8 to line 2, column 10: Column 'nme' not found in any table",
+				Line:    2,
+				Column:  8,
+				Snippet: "       nme\n       ^",
+			},
+		},
+		{
+			name:   "bigquery syntax error",
+			source: datasource.DataSourceBigQuery,
+			sql:    "SELECT name\nFROM `p.d.rup` WHERE WHERE year = 2025",
+			err: datasource.ClassifyBigQueryError(&googleapi.Error{
+				Code:    http.StatusBadRequest,
+				Message: `Syntax error: Unexpected keyword WHERE at [2:22]`,
+				Errors:  []googleapi.ErrorItem{{Reason: "invalidQuery", Message: `Syntax error: Unexpected keyword WHERE at [2:22]`}},
+			}),
+			want: QueryErrorDetails{
+				Message: "Syntax error: Unexpected keyword WHERE at [2:22]",
+				Line:    2,
+				Column:  22,
+				Snippet: "FROM `p.d.rup` WHERE WHERE year = 2025\n                     ^",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &failingSource{recordingSource: recordingSource{sourceType: tt.source}, err: tt.err}
+			code, errCode, raw := queryError(t, source, queryBody(t, map[string]interface{}{"sql": tt.sql, "source": tt.source}))
+			assert.Equal(t, http.StatusBadRequest, code)
+			assert.Equal(t, "QUERY_SYNTAX", errCode)
+
+			var details QueryErrorDetails
+			require.NoError(t, json.Unmarshal(raw, &details))
+			assert.Equal(t, tt.want, details)
+		})
+	}
+}
+
+func TestQuery_ErrorWithoutPositionKeepsMessage(t *testing.T) {
+	source := &failingSource{
+		recordingSource: recordingSource{sourceType: datasource.DataSourceDremio},
+		err:             datasource.ClassifyDremioError(status.Error(codes.NotFound, "Object 'missing' not found")),
+	}
+	code, errCode, raw := queryError(t, source, `{"sql": "SELECT * FROM missing", "source": "DATAWAREHOUSE"}`)
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Equal(t, "TABLE_NOT_FOUND", errCode)
+	assert.JSONEq(t, `"Object 'missing' not found"`, string(raw))
+}
+
+func TestQuery_ValidateOnly(t *testing.T) {
+	// Dremio is validated with a LIMIT 0 probe, the query on the probe's second line
+	source := &failingSource{
+		recordingSource: recordingSource{sourceType: datasource.DataSourceDremio},
+		err: datasource.ClassifyDremioError(status.Error(codes.InvalidArgument,
+			"PARSE ERROR: Encountered \"FORM\" at line 2, column 10.")),
+	}
+	code, _, raw := queryError(t, source,
+		`{"sql": "SELECT * FORM tender_data", "source": "DATAWAREHOUSE", "validate_only": true}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Contains(t, source.query, "LIMIT 0")
+
+	var details QueryErrorDetails
+	require.NoError(t, json.Unmarshal(raw, &details))
+	assert.Equal(t, 1, details.Line)
+	assert.Equal(t, "SELECT * FORM tender_data\n         ^", details.Snippet)
+
+	// A valid query returns no data
+	valid := &recordingSource{sourceType: datasource.DataSourceDremio, rows: rowsOf(5)}
+	handler := NewQueryHandler(map[string]datasource.DataSource{"src": valid}, testLimits, nil, false, zap.NewNop())
+	rec := httptest.NewRecorder()
+	handler.Execute(rec, httptest.NewRequest(http.MethodPost, "/api/v1/query",
+		bytes.NewBufferString(`{"sql": "SELECT * FROM tender_data", "source": "DATAWAREHOUSE", "validate_only": true}`)))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"valid": true, "source": "DATAWAREHOUSE"}`, string(mustField(t, rec.Body.Bytes(), "data")))
+}
+
+func mustField(t *testing.T, body []byte, field string) json.RawMessage {
+	var fields map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(body, &fields))
+	return fields[field]
+}
+
+func TestQuery_LabelsAttributeTheQuery(t *testing.T) {
+	source := &attributionSource{recordingSource: recordingSource{sourceType: datasource.DataSourceBigQuery}}
+	queryMetrics := metrics.NewQueryCounter([]string{"app"})
+	handler := NewQueryHandler(map[string]datasource.DataSource{"BIGQUERY": source}, testLimits, queryMetrics, false, zap.NewNop())
+
+	execute := func(body string) int {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/query", bytes.NewBufferString(body))
+		req = req.WithContext(auth.WithKey(req.Context(), &auth.APIKey{ID: "key_ab12"}))
+		handler.Execute(rec, req)
+		return rec.Code
+	}
+
+	require.Equal(t, http.StatusOK, execute(`{"sql": "SELECT 1", "source": "BIGQUERY", "labels": {"app": "finance"}}`))
+	assert.Equal(t, map[string]string{"app": "finance"}, source.attribution.Labels)
+	assert.Equal(t, "key_ab12", source.attribution.APIKeyID)
+
+	var buf bytes.Buffer
+	queryMetrics.WritePrometheus(&buf)
+	assert.Contains(t, buf.String(), `go_gateway_queries_total{data_source="BIGQUERY",label_app="finance"} 1`)
+
+	assert.Equal(t, http.StatusBadRequest, execute(`{"sql": "SELECT 1", "source": "BIGQUERY", "labels": {"App": "Finance"}}`))
+	assert.Equal(t, http.StatusBadRequest, execute(`{"sql": "SELECT 1", "source": "BIGQUERY", "labels": {"gateway": "false"}}`))
+}
+
+func TestQuery_SelectsSourceByNameOrType(t *testing.T) {
+	onPrem := &recordingSource{sourceType: datasource.DataSourceDremio}
+	cloud := &recordingSource{sourceType: datasource.DataSourceDremio}
+	queryMetrics := metrics.NewQueryCounter(nil)
+	handler := NewQueryHandler(map[string]datasource.DataSource{
+		"DATAWAREHOUSE_CLOUD": cloud,
+		"DATAWAREHOUSE":       onPrem,
+	}, testLimits, queryMetrics, false, zap.NewNop())
+
+	execute := func(source string) {
+		rec := httptest.NewRecorder()
+		handler.Execute(rec, httptest.NewRequest(http.MethodPost, "/api/v1/query",
+			bytes.NewBufferString(`{"sql": "SELECT 1", "source": "`+source+`"}`)))
+		require.Equal(t, http.StatusOK, rec.Code, source)
+	}
+
+	execute("DATAWAREHOUSE_CLOUD")
+	assert.Equal(t, "SELECT 1", cloud.query)
+	assert.Empty(t, onPrem.query)
+
+	execute("DATAWAREHOUSE")
+	assert.Equal(t, "SELECT 1", onPrem.query)
+
+	var buf bytes.Buffer
+	queryMetrics.WritePrometheus(&buf)
+	assert.Contains(t, buf.String(), `go_gateway_queries_total{data_source="DATAWAREHOUSE_CLOUD"} 1`)
+	assert.Contains(t, buf.String(), `go_gateway_queries_total{data_source="DATAWAREHOUSE"} 1`)
+
+	// A type without a source of that name is served by the first by name
+	byType := NewQueryHandler(map[string]datasource.DataSource{"DW_B": cloud, "DW_A": onPrem}, testLimits, nil, false, zap.NewNop())
+	onPrem.query = ""
+	rec := httptest.NewRecorder()
+	byType.Execute(rec, httptest.NewRequest(http.MethodPost, "/api/v1/query",
+		bytes.NewBufferString(`{"sql": "SELECT 2", "source": "DATAWAREHOUSE"}`)))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "SELECT 2", onPrem.query)
+}
+
+// attributionSource records the attribution its queries run with
+type attributionSource struct {
+	recordingSource
+	attribution datasource.Attribution
+}
+
+func (s *attributionSource) ExecuteQuery(ctx context.Context, query string, opts *datasource.QueryOptions) (*datasource.QueryResult, error) {
+	s.attribution, _ = datasource.AttributionFromContext(ctx)
+	return s.recordingSource.ExecuteQuery(ctx, query, opts)
+}
+
+func TestQuery_SourceInitializingReturns503(t *testing.T) {
+	source := &failingSource{
+		recordingSource: recordingSource{sourceType: datasource.DataSourceDremio},
+		err: &datasource.InitializingError{
+			Source:     "DATAWAREHOUSE",
+			RetryAfter: 1500 * time.Millisecond,
+			Err:        errors.New("connection refused"),
+		},
+	}
+	handler := NewQueryHandler(map[string]datasource.DataSource{"DATAWAREHOUSE": source}, testLimits, nil, false, zap.NewNop())
+
+	rec := httptest.NewRecorder()
+	handler.Execute(rec, httptest.NewRequest(http.MethodPost, "/api/v1/query",
+		bytes.NewBufferString(`{"sql": "SELECT 1", "source": "DATAWAREHOUSE"}`)))
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get("Retry-After"))
+	body := decodeResponse(t, rec)
+	assert.Equal(t, ErrCodeSourceInitializing, body.Error.Code)
+	assert.Equal(t, "connection refused", body.Error.Details)
+}
+
+// jobSource returns results produced by a Dremio job
+type jobSource struct {
+	recordingSource
+}
+
+func (s *jobSource) ExecuteQuery(ctx context.Context, query string, opts *datasource.QueryOptions) (*datasource.QueryResult, error) {
+	return &datasource.QueryResult{
+		Data:   rowsOf(1),
+		Count:  1,
+		Source: s.sourceType,
+		Metadata: map[string]interface{}{
+			datasource.MetaDremioJobID:      "1a2b3c4d-0000-1111-2222-333344445555",
+			datasource.MetaDremioProfileURL: "http://dremio:9047/jobs/job/1a2b3c4d-0000-1111-2222-333344445555",
+			"cached_at":                     "2025-01-01T00:00:00Z",
+		},
+	}, nil
+}
+
+func TestQuery_DremioJobExposedOnlyWhenEnabled(t *testing.T) {
+	source := &jobSource{recordingSource{sourceType: datasource.DataSourceDremio}}
+
+	execute := func(expose bool) (meta, metadata map[string]interface{}) {
+		handler := NewQueryHandler(map[string]datasource.DataSource{"DATAWAREHOUSE": source}, testLimits, nil, expose, zap.NewNop())
+		rec := httptest.NewRecorder()
+		handler.Execute(rec, httptest.NewRequest(http.MethodPost, "/api/v1/query",
+			bytes.NewBufferString(`{"sql": "SELECT 1", "source": "DATAWAREHOUSE"}`)))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var body struct {
+			Data struct {
+				Metadata map[string]interface{} `json:"metadata"`
+			} `json:"data"`
+			Meta map[string]interface{} `json:"meta"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		return body.Meta, body.Data.Metadata
+	}
+
+	meta, metadata := execute(true)
+	assert.Equal(t, "1a2b3c4d-0000-1111-2222-333344445555", meta["dremio_job_id"])
+	assert.Equal(t, "http://dremio:9047/jobs/job/1a2b3c4d-0000-1111-2222-333344445555", meta["dremio_profile_url"])
+	assert.Equal(t, "1a2b3c4d-0000-1111-2222-333344445555", metadata[datasource.MetaDremioJobID])
+
+	meta, metadata = execute(false)
+	assert.NotContains(t, meta, "dremio_job_id")
+	assert.NotContains(t, meta, "dremio_profile_url")
+	assert.Equal(t, map[string]interface{}{"cached_at": "2025-01-01T00:00:00Z"}, metadata)
+}
+
+// billedSource returns results produced by a BigQuery job
+type billedSource struct {
+	recordingSource
+}
+
+func (s *billedSource) ExecuteQuery(ctx context.Context, query string, opts *datasource.QueryOptions) (*datasource.QueryResult, error) {
+	return &datasource.QueryResult{
+		Data:   rowsOf(1),
+		Count:  1,
+		Source: s.sourceType,
+		Metadata: map[string]interface{}{
+			datasource.MetaBigQueryJob: &clients.JobStats{JobID: "job_abc", TotalBytesProcessed: 1 << 20, TotalBytesBilled: 10 << 20, SlotMillis: 1234},
+		},
+	}, nil
+}
+
+func TestQuery_BigQueryJobInMeta(t *testing.T) {
+	source := &billedSource{recordingSource{sourceType: datasource.DataSourceBigQuery}}
+	handler := NewQueryHandler(map[string]datasource.DataSource{"BIGQUERY": source}, testLimits, nil, false, zap.NewNop())
+	rec := httptest.NewRecorder()
+	handler.Execute(rec, httptest.NewRequest(http.MethodPost, "/api/v1/query",
+		bytes.NewBufferString(`{"sql": "SELECT 1", "source": "BIGQUERY"}`)))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var body struct {
+		Data struct {
+			Metadata map[string]interface{} `json:"metadata"`
+		} `json:"data"`
+		Meta struct {
+			BigQuery map[string]interface{} `json:"bigquery"`
+		} `json:"meta"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, map[string]interface{}{
+		"job_id":                "job_abc",
+		"total_bytes_processed": float64(1 << 20),
+		"total_bytes_billed":    float64(10 << 20),
+		"cache_hit":             false,
+		"slot_ms":               float64(1234),
+	}, body.Meta.BigQuery)
+	assert.NotContains(t, body.Data.Metadata, datasource.MetaBigQueryJob)
+}
+
+func TestQuery_SchemaFingerprintInMeta(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio, rows: rowsOf(3)}
+	handler := NewQueryHandler(map[string]datasource.DataSource{"DATAWAREHOUSE": source}, testLimits, nil, false, zap.NewNop())
+
+	execute := func() string {
+		rec := httptest.NewRecorder()
+		handler.Execute(rec, httptest.NewRequest(http.MethodPost, "/api/v1/query",
+			bytes.NewBufferString(`{"sql": "SELECT id FROM t", "source": "DATAWAREHOUSE"}`)))
+		require.Equal(t, http.StatusOK, rec.Code)
+		return decodeResponse(t, rec).Meta.SchemaFingerprint
+	}
+
+	fingerprint := execute()
+	assert.Equal(t, datasource.Fingerprint(datasource.ResultSchema(rowsOf(1))), fingerprint)
+	source.rows = []map[string]interface{}{{"id": "a"}}
+	assert.NotEqual(t, fingerprint, execute(), "a column of another type changes the fingerprint")
+}
+
+func TestQuery_AutoLimitInjectedWithoutTopLevelLimit(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio, rows: rowsOf(1)}
+	handler := NewQueryHandler(map[string]datasource.DataSource{"DATAWAREHOUSE": source}, testLimits, nil, false, zap.NewNop())
+	handler.SetAutoLimit(10000)
+
+	execute := func(sql string, scopes ...string) map[string]interface{} {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/query",
+			bytes.NewBufferString(queryBody(t, map[string]interface{}{"sql": sql, "source": "DATAWAREHOUSE"})))
+		req = req.WithContext(auth.WithKey(req.Context(), &auth.APIKey{ID: "analyst", Scopes: scopes}))
+		handler.Execute(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var meta map[string]interface{}
+		require.NoError(t, json.Unmarshal(mustField(t, rec.Body.Bytes(), "meta"), &meta))
+		return meta
+	}
+
+	meta := execute("SELECT * FROM tender_data WHERE id IN (SELECT id FROM t LIMIT 5)")
+	assert.Equal(t, true, meta["limit_injected"])
+	assert.EqualValues(t, 10000, meta["injected_limit"])
+	assert.Equal(t, "SELECT * FROM (\nSELECT * FROM tender_data WHERE id IN (SELECT id FROM t LIMIT 5)\n) AS auto_limited LIMIT 10000", source.query)
+
+	meta = execute("SELECT * FROM tender_data LIMIT 5")
+	assert.NotContains(t, meta, "limit_injected")
+	assert.Equal(t, "SELECT * FROM tender_data LIMIT 5", source.query)
+
+	meta = execute("SELECT * FROM tender_data", auth.ScopeQueryUnlimited)
+	assert.NotContains(t, meta, "limit_injected")
+	assert.Equal(t, "SELECT * FROM tender_data", source.query)
+}
+
+func TestQuery_Warnings(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio, rows: rowsOf(12)}
+	handler := NewQueryHandler(map[string]datasource.DataSource{"DATAWAREHOUSE": source}, testLimits, nil, false, zap.NewNop())
+	handler.SetAutoLimit(10000)
+	execute := func(sql string) response.StandardResponse {
+		rec := httptest.NewRecorder()
+		handler.Execute(rec, httptest.NewRequest(http.MethodPost, "/api/v1/query",
+			bytes.NewBufferString(queryBody(t, map[string]interface{}{"sql": sql, "source": "DATAWAREHOUSE", "limit": 5}))))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		return decodeResponse(t, rec)
+	}
+
+	body := execute("SELECT * FROM tender_data")
+	require.Len(t, body.Warnings, 2)
+	assert.Equal(t, response.WarnLimitInjected, body.Warnings[0].Code)
+	assert.Equal(t, map[string]interface{}{"limit": float64(10000)}, body.Warnings[0].Details)
+	assert.Equal(t, response.WarnResultTruncated, body.Warnings[1].Code)
+	assert.Equal(t, map[string]interface{}{"total": float64(12), "limit": float64(5)}, body.Warnings[1].Details)
+	assert.True(t, body.Meta.LimitInjected, "the meta fields are still set")
+
+	source.rows = rowsOf(3)
+	assert.Empty(t, execute("SELECT * FROM tender_data LIMIT 5").Warnings)
+}
+
+// renamingSource returns rows whose repeated column was renamed, as a JOIN
+// read from Dremio or BigQuery does
+type renamingSource struct {
+	recordingSource
+}
+
+func (s *renamingSource) ExecuteQuery(ctx context.Context, query string, opts *datasource.QueryOptions) (*datasource.QueryResult, error) {
+	result, err := s.recordingSource.ExecuteQuery(ctx, query, opts)
+	result.Metadata = map[string]interface{}{datasource.MetaRenamedColumns: map[string]string{"id_1": "id"}}
+	return result, err
+}
+
+func TestQuery_RenamedColumnsWarningSurvivesCache(t *testing.T) {
+	source := &renamingSource{recordingSource{sourceType: datasource.DataSourceDremio, rows: []map[string]interface{}{{"id": 1, "id_1": 2}}}}
+	cached := cache.NewCachedDataSource(source, cache.NewMemoryCache(), zap.NewNop())
+	handler := NewQueryHandler(map[string]datasource.DataSource{"DATAWAREHOUSE": cached}, testLimits, nil, false, zap.NewNop())
+
+	want := []response.Warning{{
+		Code:    response.WarnColumnsRenamed,
+		Message: "Repeated column names were renamed: id_1",
+		Details: map[string]interface{}{"id_1": "id"},
+	}}
+	for _, hit := range []bool{false, true} {
+		rec := httptest.NewRecorder()
+		handler.Execute(rec, httptest.NewRequest(http.MethodPost, "/api/v1/query",
+			bytes.NewBufferString(`{"sql": "SELECT a.id, b.id FROM a JOIN b ON a.k = b.k LIMIT 1", "source": "DATAWAREHOUSE"}`)))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		body := decodeResponse(t, rec)
+		assert.Equal(t, hit, body.Data.(map[string]interface{})["cache_hit"] == true)
+		assert.Equal(t, want, body.Warnings, "cache hit %v", hit)
+	}
+}
+
+func TestQuery_PreserveComments(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio, rows: rowsOf(1)}
+	handler := NewQueryHandler(map[string]datasource.DataSource{"DATAWAREHOUSE": source}, testLimits, nil, false, zap.NewNop())
+
+	execute := func(fields map[string]interface{}) {
+		rec := httptest.NewRecorder()
+		handler.Execute(rec, httptest.NewRequest(http.MethodPost, "/api/v1/query", bytes.NewBufferString(queryBody(t, fields))))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	}
+
+	// Comments are blanked out in place, literals untouched
+	sql := "-- dashboard's tiles\nSELECT '--x' AS s /* hint */ FROM t LIMIT 5"
+	execute(map[string]interface{}{"sql": sql, "source": "DATAWAREHOUSE"})
+	assert.Equal(t, "                    \nSELECT '--x' AS s            FROM t LIMIT 5", source.query)
+
+	// Hints reach the engine when asked
+	execute(map[string]interface{}{"sql": sql, "source": "DATAWAREHOUSE", "preserve_comments": true})
+	assert.Equal(t, sql, source.query)
+}
+
+func TestQuery_AutoLimitKeepsErrorPosition(t *testing.T) {
+	source := &failingSource{
+		recordingSource: recordingSource{sourceType: datasource.DataSourceDremio},
+		err: datasource.ClassifyDremioError(status.Error(codes.InvalidArgument,
+			"PARSE ERROR: Encountered \"FORM\" at line 2, column 10.")),
+	}
+	handler := NewQueryHandler(map[string]datasource.DataSource{"DATAWAREHOUSE": source}, testLimits, nil, false, zap.NewNop())
+	handler.SetAutoLimit(10000)
+
+	rec := httptest.NewRecorder()
+	handler.Execute(rec, httptest.NewRequest(http.MethodPost, "/api/v1/query",
+		bytes.NewBufferString(`{"sql": "SELECT * FORM tender_data", "source": "DATAWAREHOUSE"}`)))
+
+	var resp struct {
+		Error struct {
+			Details QueryErrorDetails `json:"details"`
+		} `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, 1, resp.Error.Details.Line)
+	assert.Equal(t, 10, resp.Error.Details.Column)
+}
+
+func TestQuery_EngineOptions(t *testing.T) {
+	dremio := &recordingSource{sourceType: datasource.DataSourceDremio, rows: rowsOf(1)}
+	bigquery := &recordingSource{sourceType: datasource.DataSourceBigQuery, rows: rowsOf(1)}
+	handler := NewQueryHandler(map[string]datasource.DataSource{"DATAWAREHOUSE": dremio, "BIGQUERY": bigquery}, testLimits, nil, false, zap.NewNop())
+	handler.SetEngineOptions([]string{"planner.enable_broadcast_join", datasource.RoutingTag})
+
+	execute := func(key *auth.APIKey, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/query", bytes.NewBufferString(body))
+		handler.Execute(rec, req.WithContext(auth.WithKey(req.Context(), key)))
+		return rec
+	}
+	debugKey := &auth.APIKey{ID: "key_debug", Scopes: []string{auth.ScopeDebug}}
+
+	rec := execute(debugKey, `{"sql": "SELECT 1", "source": "DATAWAREHOUSE", "engine_options": {"planner.enable_broadcast_join": false, "routing_tag": "etl"}}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, datasource.EngineOptions{"planner.enable_broadcast_join": false, "routing_tag": "etl"}, dremio.opts.EngineOptions)
+	assert.True(t, dremio.opts.SkipCache, "results under session options bypass the cache")
+
+	// Only debug keys may set them
+	rec = execute(&auth.APIKey{ID: "key_reader", Scopes: []string{"read"}}, `{"sql": "SELECT 1", "source": "DATAWAREHOUSE", "engine_options": {"planner.enable_broadcast_join": false}}`)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	// Only allowlisted options
+	violations := violationsOf(t, execute(debugKey, `{"sql": "SELECT 1", "source": "DATAWAREHOUSE", "engine_options": {"exec.queue.enable": false}}`))
+	assert.Equal(t, "engine_options", violations[0].Field)
+	assert.Contains(t, violations[0].Message, `option "exec.queue.enable" is not allowed`)
+
+	// Only on Dremio
+	violations = violationsOf(t, execute(debugKey, `{"sql": "SELECT 1", "source": "BIGQUERY", "engine_options": {"planner.enable_broadcast_join": false}}`))
+	assert.Equal(t, "source=dremio", violations[0].Constraint)
+	assert.Empty(t, bigquery.query)
+}
@@ -1,31 +1,138 @@
 package v1
 
 import (
-	"encoding/json"
-	"fmt"
+	"context"
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
+	"go-data-gateway/internal/auth"
+	"go-data-gateway/internal/cache"
 	"go-data-gateway/internal/clients"
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/metrics"
 	"go-data-gateway/internal/response"
+	"go-data-gateway/internal/sqlbuilder"
+	"go-data-gateway/internal/tenant"
+	"go-data-gateway/pkg/apitypes"
 	"go.uber.org/zap"
 )
 
+// rupQuerier runs BigQuery SQL; clients.BigQuerier implements it
+type rupQuerier interface {
+	Query(ctx context.Context, sqlQuery string) ([]map[string]interface{}, error)
+}
+
 // RUPHandler handles RUP (Rencana Umum Pengadaan) queries from BigQuery
 type RUPHandler struct {
-	bigquery *clients.BigQueryClient
+	bigquery rupQuerier
+	limits   config.PageLimit
+	search   config.KeywordSearch
+	bulk     *bulkReader
 	logger   *zap.Logger
+
+	tiebreaker string // Ordered by after _event_date in lists and searches
+
+	counts    *countCache   // Counts of count_only searches
+	estimator costEstimator // Reports the bytes counts scan
+	notFound  *notFoundCache
 }
 
 // NewRUPHandler creates a new RUP handler
-func NewRUPHandler(bigquery *clients.BigQueryClient, logger *zap.Logger) *RUPHandler {
-	return &RUPHandler{
-		bigquery: bigquery,
-		logger:   logger,
+func NewRUPHandler(bigquery clients.BigQuerier, limits config.PageLimit, logger *zap.Logger) *RUPHandler {
+	h := &RUPHandler{
+		limits: limits,
+		search: config.DefaultSearch().RUP,
+		bulk:   newBulkReader(logger),
+		logger: logger,
+
+		tiebreaker: config.DefaultTiebreakers().For(rupTable),
+		counts:     newCountCache(logger),
+		notFound:   newNotFoundCache(logger),
+	}
+	if bigquery != nil {
+		h.bigquery = bigquery
+	}
+	return h
+}
+
+// SetKeywordSearch sets the columns and limits of the search keyword
+func (h *RUPHandler) SetKeywordSearch(search config.KeywordSearch) {
+	h.search = search
+}
+
+// SetTiebreakers sets the column RUP lists and searches order by after
+// _event_date, the tiebreaker of rup_kromaster
+func (h *RUPHandler) SetTiebreakers(tiebreakers config.Tiebreakers) {
+	h.tiebreaker = tiebreakers.For(rupTable)
+}
+
+// SetBulk sets the limits of POST /api/v1/rup/bulk and the cache of the RUP
+// records it reads
+func (h *RUPHandler) SetBulk(limits config.BulkConfig, records cache.Cache) {
+	h.bulk.set(limits, records)
+}
+
+// SetNotFoundCache sets how long lookups of missing RUP records are
+// remembered, the cache of their markers and the counter of their outcomes
+func (h *RUPHandler) SetNotFoundCache(ttl time.Duration, markers cache.Cache, counter *metrics.NotFoundCounter) {
+	h.notFound.configure(ttl, markers, counter)
+}
+
+// SetCountOnly sets the TTL and cache of the counts of count_only searches,
+// and the estimator reporting the bytes they scan
+func (h *RUPHandler) SetCountOnly(ttl time.Duration, counts cache.Cache, estimator *clients.QueryCostEstimator) {
+	h.counts.configure(ttl, counts)
+	h.estimator = nil
+	if estimator != nil {
+		h.estimator = estimator
 	}
 }
 
+// rupNotDeleted hides soft-deleted rup_kromaster rows unless include_deleted
+// is requested
+var rupNotDeleted = sqlbuilder.Eq("is_deleted", false)
+
+// rupSelect starts a select of columns from table, a rup_kromaster
+func rupSelect(table string, columns ...string) *sqlbuilder.Builder {
+	return sqlbuilder.Select(sqlbuilder.BigQuery, columns...).From(table)
+}
+
+// rupVisible returns the conditions hiding soft-deleted rows, none when
+// withDeleted; they follow the request's own conditions
+func rupVisible(withDeleted bool) []sqlbuilder.Cond {
+	if withDeleted {
+		return nil
+	}
+	return []sqlbuilder.Cond{rupNotDeleted}
+}
+
+// includeDeleted reports whether the request asked for soft-deleted rows with
+// include_deleted=true. Only admin keys may; other keys get 403. Responses with
+// deleted rows are marked no-store so shared caches never serve them to
+// other clients.
+func includeDeleted(w http.ResponseWriter, r *http.Request, raw string) (include, ok bool) {
+	if raw == "" {
+		return false, true
+	}
+	include, err := strconv.ParseBool(raw)
+	if err != nil {
+		response.Error(w, "include_deleted must be true or false", http.StatusBadRequest)
+		return false, false
+	}
+	if include && !auth.HasScope(r.Context(), auth.ScopeAdmin) {
+		response.Error(w, "include_deleted requires an admin key", http.StatusForbidden)
+		return false, false
+	}
+	if include {
+		w.Header().Set("Cache-Control", config.NoStore.String())
+	}
+	return include, true
+}
+
 // RUPResponse represents the response structure for RUP data from rup_kromaster
 type RUPResponse struct {
 	KdKro         int64   `json:"kd_kro"`
@@ -52,13 +159,11 @@ func (h *RUPHandler) List(w http.ResponseWriter, r *http.Request) {
 
 	// Parse query parameters
 	params := r.URL.Query()
-	limit := 100
 	offset := 0
 
-	if l := params.Get("limit"); l != "" {
-		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 1000 {
-			limit = parsed
-		}
+	limit, ok := queryLimit(w, r, h.limits)
+	if !ok {
+		return
 	}
 
 	if o := params.Get("offset"); o != "" {
@@ -67,56 +172,69 @@ func (h *RUPHandler) List(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Build query for rup_kromaster table
-	query := fmt.Sprintf(`
-		SELECT
-			kd_kro,
-			kd_kro_str,
-			nama_kro,
-			pagu_kro,
-			tahun_anggaran,
-			kd_satker,
-			kd_klpd,
-			nama_klpd,
-			jenis_klpd,
-			kd_program,
-			kd_kegiatan,
-			_event_date,
-			is_deleted
-		FROM %s.rup_kromaster
-		ORDER BY _event_date DESC
-		LIMIT %d OFFSET %d
-	`, "`gtp-data-prod.layer_isb`", limit, offset)
+	withDeleted, ok := includeDeleted(w, r, params.Get("include_deleted"))
+	if !ok {
+		return
+	}
+	withTotal, ok := includeTotal(w, params.Get("include_total"))
+	if !ok {
+		return
+	}
+	debugMode, ok := sqlDebug(w, r)
+	if !ok {
+		return
+	}
 
-	results, err := h.bigquery.Query(r.Context(), query)
+	query, err := rupListQuery(rupTableOf(r.Context()), withDeleted, h.tiebreaker, limit, offset)
+	if err != nil {
+		h.logger.Error("Failed to build RUP query", zap.Error(err))
+		response.Error(w, "Failed to build RUP query", http.StatusInternalServerError)
+		return
+	}
+	debug := rupDebug(query, withTotal, debugMode)
+	if debugMode == sqlDebugDryRun {
+		response.Success(w, debug, nil)
+		return
+	}
+
+	results, err := h.bigquery.Query(r.Context(), query.SQL)
 	if err != nil {
 		h.logger.Error("Failed to query RUP data", zap.Error(err))
-		response.ErrorWithDetails(w, "Failed to fetch RUP data", err.Error(), http.StatusInternalServerError)
+		if !writeUpstreamError(w, datasource.ClassifyBigQueryError(err), "Failed to fetch RUP data") {
+			response.ErrorWithDetails(w, "Failed to fetch RUP data", err.Error(), http.StatusInternalServerError)
+		}
 		return
 	}
 
-	// Also get total count for pagination
-	countQuery := fmt.Sprintf("SELECT COUNT(*) as total FROM `%s.rup_kromaster`", "gtp-data-prod.layer_isb")
-	countResult, err := h.bigquery.Query(r.Context(), countQuery)
+	meta := &response.Meta{
+		Limit:           limit,
+		Order:           query.Order,
+		DeletedFiltered: !withDeleted,
+		Debug:           debug,
+	}
+	paginate(w, r, meta, h.page(r.Context(), query.CountSQL, withTotal, offset, limit, len(results)))
+	response.Success(w, results, meta)
+}
+
+// page counts the total of a RUP list or search under the same condition
+// as its rows, unless include_total turned it off. A failed count leaves
+// the page without a total rather than failing the rows already read.
+func (h *RUPHandler) page(ctx context.Context, countSQL string, withTotal bool, offset, limit, rows int) page {
+	p := page{offset: offset, limit: limit, rows: rows}
+	if !withTotal {
+		return p
+	}
+	countResult, err := h.bigquery.Query(ctx, countSQL)
 	if err != nil {
 		h.logger.Warn("Failed to get total count", zap.Error(err))
+		return p
 	}
-
-	var total int64 = int64(len(results))
 	if len(countResult) > 0 {
 		if v, ok := countResult[0]["total"].(int64); ok {
-			total = v
+			p.total, p.counted = int(v), true
 		}
 	}
-
-	// Calculate pagination
-	page := (offset / limit) + 1
-
-	response.Success(w, results, &response.Meta{
-		Page:    page,
-		PerPage: limit,
-		Total:   int(total),
-	})
+	return p
 }
 
 // GetByID handles GET /api/v1/rup/:id
@@ -127,173 +245,201 @@ func (h *RUPHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get ID from URL path
-	idPath := strings.TrimPrefix(r.URL.Path, "/api/v1/rup/")
-	if idPath == "" {
+	id := strings.TrimPrefix(r.URL.Path, "/api/v1/rup/")
+	if id == "" {
 		response.Error(w, "RUP ID is required", http.StatusBadRequest)
 		return
 	}
 
-	// Sanitize ID to prevent SQL injection
-	id := strings.ReplaceAll(idPath, "'", "''")
-
-	query := fmt.Sprintf(`
-		SELECT
-			kd_kro,
-			kd_kro_str,
-			kd_kro_lokal,
-			nama_kro,
-			pagu_kro,
-			tahun_anggaran,
-			kd_satker,
-			kd_klpd,
-			nama_klpd,
-			jenis_klpd,
-			kd_program,
-			kd_kegiatan,
-			_event_date,
-			is_deleted
-		FROM %s.rup_kromaster
-		WHERE kd_kro_str = '%s'
-		LIMIT 1
-	`, "`gtp-data-prod.layer_isb`", id)
+	withDeleted, ok := includeDeleted(w, r, r.URL.Query().Get("include_deleted"))
+	if !ok {
+		return
+	}
+	query, err := rupSelect(rupTableOf(r.Context()), rupRecordColumns...).
+		Where(sqlbuilder.Eq("kd_kro_str", id)).
+		Where(rupVisible(withDeleted)...).
+		Limit(1).SQL()
+	if err != nil {
+		response.ErrorWithDetails(w, "Invalid RUP ID", err.Error(), http.StatusBadRequest)
+		return
+	}
 
+	// Records that are only soft-deleted are found with include_deleted
+	variant := strconv.FormatBool(withDeleted)
+	if h.notFound.has(r.Context(), rupTable, id, variant) {
+		writeNotFound(w, "RUP not found")
+		return
+	}
 	results, err := h.bigquery.Query(r.Context(), query)
 	if err != nil {
 		h.logger.Error("Failed to query RUP by ID",
 			zap.String("id", id),
 			zap.Error(err))
-		response.ErrorWithDetails(w, "Failed to fetch RUP data", err.Error(), http.StatusInternalServerError)
+		if !writeUpstreamError(w, datasource.ClassifyBigQueryError(err), "Failed to fetch RUP data") {
+			response.ErrorWithDetails(w, "Failed to fetch RUP data", err.Error(), http.StatusInternalServerError)
+		}
 		return
 	}
 
 	if len(results) == 0 {
+		h.notFound.remember(r.Context(), rupTable, id, variant)
 		response.Error(w, "RUP not found", http.StatusNotFound)
 		return
 	}
 
-	response.Success(w, results[0], nil)
+	response.Success(w, results[0], &response.Meta{DeletedFiltered: !withDeleted})
 }
 
-// Search handles POST /api/v1/rup/search
-func (h *RUPHandler) Search(w http.ResponseWriter, r *http.Request) {
+// The BigQuery project and dataset of RUP records, unless the request's
+// tenant overrides them
+const (
+	rupProject = "gtp-data-prod"
+	rupDataset = "layer_isb"
+)
+
+// rupTable is the BigQuery table of RUP records of tenants without overrides
+const rupTable = rupProject + "." + rupDataset + ".rup_kromaster"
+
+// rupTableOf returns the RUP table of the request's tenant: rup_kromaster in
+// the BigQuery project and dataset the tenant sets, rupTable's otherwise
+func rupTableOf(ctx context.Context) string {
+	project, dataset := rupProject, rupDataset
+	if t, ok := tenant.FromContext(ctx); ok {
+		if t.BigQueryProject != "" {
+			project = t.BigQueryProject
+		}
+		if t.BigQueryDataset != "" {
+			dataset = t.BigQueryDataset
+		}
+	}
+	return project + "." + dataset + ".rup_kromaster"
+}
+
+// rupRecordColumns are the columns of a single RUP record
+var rupRecordColumns = []string{
+	"kd_kro", "kd_kro_str", "kd_kro_lokal", "nama_kro", "pagu_kro", "tahun_anggaran",
+	"kd_satker", "kd_klpd", "nama_klpd", "jenis_klpd", "kd_program", "kd_kegiatan",
+	"_event_date", "is_deleted",
+}
+
+// Bulk handles POST /api/v1/rup/bulk: each RUP record in {"ids": [...]},
+// keyed by kd_kro_str, and the ids of those that do not exist
+func (h *RUPHandler) Bulk(w http.ResponseWriter, r *http.Request) {
 	if h.bigquery == nil {
 		response.Error(w, "BigQuery service not available", http.StatusServiceUnavailable)
 		return
 	}
 
-	var req struct {
-		Keyword  string  `json:"keyword"`
-		Tahun    string  `json:"tahun"`
-		KdSatker string  `json:"kd_satker"`
-		MinPagu  float64 `json:"min_pagu"`
-		MaxPagu  float64 `json:"max_pagu"`
-		Limit    int     `json:"limit"`
-		Offset   int     `json:"offset"`
+	withDeleted, ok := includeDeleted(w, r, r.URL.Query().Get("include_deleted"))
+	if !ok {
+		return
 	}
-
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		response.Error(w, "Invalid request body", http.StatusBadRequest)
+	ids, ok := h.bulk.decode(w, r)
+	if !ok {
 		return
 	}
 
-	// Default values
-	if req.Limit == 0 || req.Limit > 1000 {
-		req.Limit = 100
+	// Records with deleted rows are cached apart from those without
+	scope := "rup_kromaster"
+	if withDeleted {
+		scope += ":with_deleted"
+	}
+	result, err := h.bulk.read(r.Context(), bulkLookup{
+		scope:  scope,
+		table:  "rup_kromaster",
+		column: "kd_kro_str",
+		fetch: func(ctx context.Context, ids []string) ([]map[string]interface{}, error) {
+			query, err := rupSelect(rupTableOf(ctx), rupRecordColumns...).
+				Where(sqlbuilder.In("kd_kro_str", ids)).
+				Where(rupVisible(withDeleted)...).SQL()
+			if err != nil {
+				return nil, err
+			}
+			return h.bigquery.Query(ctx, query)
+		},
+	}, ids)
+	if err != nil {
+		h.logger.Error("Failed to query RUP by IDs", zap.Int("ids", len(ids)), zap.Error(err))
+		if !writeUpstreamError(w, datasource.ClassifyBigQueryError(err), "Failed to fetch RUP data") {
+			response.ErrorWithDetails(w, "Failed to fetch RUP data", err.Error(), http.StatusInternalServerError)
+		}
+		return
 	}
 
-	// Build WHERE clauses
-	var conditions []string
+	response.Success(w, result, &response.Meta{DeletedFiltered: !withDeleted})
+}
 
-	if req.Keyword != "" {
-		keyword := strings.ReplaceAll(req.Keyword, "'", "''")
-		conditions = append(conditions, fmt.Sprintf(
-			"(LOWER(nama_kro) LIKE LOWER('%%%s%%') OR LOWER(nama_klpd) LIKE LOWER('%%%s%%'))",
-			keyword, keyword,
-		))
+// Search handles POST /api/v1/rup/search
+func (h *RUPHandler) Search(w http.ResponseWriter, r *http.Request) {
+	if h.bigquery == nil {
+		response.Error(w, "BigQuery service not available", http.StatusServiceUnavailable)
+		return
 	}
 
-	if req.Tahun != "" {
-		// tahun_anggaran is INT64 in BigQuery
-		conditions = append(conditions, fmt.Sprintf("tahun_anggaran = %s",
-			strings.ReplaceAll(req.Tahun, "'", "''")))
+	debugMode, ok := sqlDebug(w, r)
+	if !ok {
+		return
 	}
 
-	if req.KdSatker != "" {
-		// kd_satker is INT64 in BigQuery
-		conditions = append(conditions, fmt.Sprintf("kd_satker = %s",
-			strings.ReplaceAll(req.KdSatker, "'", "''")))
+	var req rupSearchRequest
+	if !decodeBody(w, r, &req) {
+		return
 	}
 
-	if req.MinPagu > 0 {
-		conditions = append(conditions, fmt.Sprintf("pagu_kro >= %f", req.MinPagu))
+	withDeleted, ok := includeDeleted(w, r, strconv.FormatBool(req.IncludeDeleted))
+	if !ok {
+		return
 	}
 
-	if req.MaxPagu > 0 {
-		conditions = append(conditions, fmt.Sprintf("pagu_kro <= %f", req.MaxPagu))
-	}
-
-	whereClause := ""
-	if len(conditions) > 0 {
-		whereClause = "WHERE " + strings.Join(conditions, " AND ")
-	}
-
-	query := fmt.Sprintf(`
-		SELECT
-			kd_kro,
-			kd_kro_str,
-			nama_kro,
-			pagu_kro,
-			tahun_anggaran,
-			kd_satker,
-			kd_klpd,
-			nama_klpd,
-			jenis_klpd,
-			kd_program,
-			kd_kegiatan,
-			_event_date,
-			is_deleted
-		FROM %s.rup_kromaster
-		%s
-		ORDER BY _event_date DESC
-		LIMIT %d OFFSET %d
-	`, "`gtp-data-prod.layer_isb`", whereClause, req.Limit, req.Offset)
+	var v violations
+	req.Limit = v.limit("limit", req.Limit, h.limits)
+	if req.Offset < 0 {
+		v.add("offset", "min=0", "offset must not be negative")
+	}
+	keywords, err := keywordMatch(req.Keyword, req.Match, h.search)
+	v.addErr("keyword", "keyword", err)
+	query, filtered, err := rupSearchQuery(rupTableOf(r.Context()), req, keywords, withDeleted, h.tiebreaker)
+	v.addErr("body", "query", err)
+	if v.write(w) {
+		return
+	}
+	withTotal := req.CountOnly || req.IncludeTotal == nil || *req.IncludeTotal
+	debug := rupDebug(query, withTotal, debugMode)
+	if debugMode == sqlDebugDryRun {
+		response.Success(w, debug, nil)
+		return
+	}
+	if req.CountOnly {
+		h.count(w, r, query.CountSQL, &response.Meta{DeletedFiltered: !withDeleted, Debug: debug})
+		return
+	}
 
-	results, err := h.bigquery.Query(r.Context(), query)
+	results, err := h.bigquery.Query(r.Context(), query.SQL)
 	if err != nil {
 		h.logger.Error("Failed to search RUP data",
-			zap.String("query", query),
+			zap.String("query", query.SQL),
 			zap.Error(err))
-		response.ErrorWithDetails(w, "Failed to search RUP data", err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	// Get total count for pagination
-	countQuery := fmt.Sprintf(
-		"SELECT COUNT(*) as total FROM `gtp-data-prod.layer_isb`.rup_kromaster %s",
-		whereClause,
-	)
-
-	countResult, _ := h.bigquery.Query(r.Context(), countQuery)
-	var total int64 = int64(len(results))
-	if len(countResult) > 0 {
-		if v, ok := countResult[0]["total"].(int64); ok {
-			total = v
+		if !writeUpstreamError(w, datasource.ClassifyBigQueryError(err), "Failed to search RUP data") {
+			response.ErrorWithDetails(w, "Failed to search RUP data", err.Error(), http.StatusInternalServerError)
 		}
+		return
 	}
 
 	// Create meta with additional info in data itself
 	meta := &response.Meta{
-		Total:   int(total),
-		Page:    (req.Offset / req.Limit) + 1,
-		PerPage: req.Limit,
+		Limit:           req.Limit,
+		Order:           query.Order,
+		DeletedFiltered: !withDeleted,
+		Debug:           debug,
 	}
+	paginate(w, r, meta, h.page(r.Context(), query.CountSQL, withTotal, req.Offset, req.Limit, len(results)))
 
 	// Wrap results with filter info
 	responseData := map[string]interface{}{
 		"results":  results,
-		"filtered": len(conditions) > 0,
+		"filtered": filtered,
 		"filters_applied": map[string]interface{}{
-			"keyword":   req.Keyword,
+			"keyword":   keywordParam(req.Keyword),
 			"tahun":     req.Tahun,
 			"kd_satker": req.KdSatker,
 			"min_pagu":  req.MinPagu,
@@ -303,3 +449,168 @@ func (h *RUPHandler) Search(w http.ResponseWriter, r *http.Request) {
 
 	response.Success(w, responseData, meta)
 }
+
+// count answers a count_only search with the total of countSQL, from the
+// count cache when it has it
+func (h *RUPHandler) count(w http.ResponseWriter, r *http.Request, countSQL string, meta *response.Meta) {
+	ctx := r.Context()
+	start := time.Now()
+	data := CountResult{}
+	if count, ok := h.counts.get(ctx, countSQL); ok {
+		data.Count, data.CacheHit = count, true
+	} else {
+		rows, err := h.bigquery.Query(ctx, countSQL)
+		if err != nil {
+			h.logger.Error("Failed to count RUP data",
+				zap.String("query", countSQL),
+				zap.Error(err))
+			if !writeUpstreamError(w, datasource.ClassifyBigQueryError(err), "Failed to count RUP data") {
+				response.ErrorWithDetails(w, "Failed to count RUP data", err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+		if data.Count, err = readCount(rows, "total"); err != nil {
+			h.logger.Error("Unexpected count result", zap.Error(err))
+			response.Error(w, "Failed to count RUP data", http.StatusInternalServerError)
+			return
+		}
+		h.counts.set(ctx, countSQL, data.Count)
+		data.BytesScanned = scannedBytes(ctx, h.estimator, countSQL, h.logger)
+	}
+	data.QueryTimeMs = time.Since(start).Milliseconds()
+	response.Success(w, data, meta)
+}
+
+// rupSearchRequest is the body of POST /api/v1/rup/search
+type rupSearchRequest struct {
+	Keyword  apitypes.Keywords `json:"keyword"`
+	Match    string            `json:"match"` // all (default) or any of the keywords
+	Tahun    string            `json:"tahun"`
+	KdSatker string            `json:"kd_satker"`
+	MinPagu  float64           `json:"min_pagu"`
+	MaxPagu  float64           `json:"max_pagu"`
+	Limit    int               `json:"limit"`
+	Offset   int               `json:"offset"`
+
+	IncludeDeleted bool `json:"include_deleted"` // Admin keys only
+
+	// CountOnly returns the number of matching rows instead of a page
+	CountOnly bool `json:"count_only"`
+
+	// IncludeTotal false skips counting the total; the page then has no
+	// total_pages or last link
+	IncludeTotal *bool `json:"include_total"`
+}
+
+// rupListColumns are the columns of the RUP list and search
+var rupListColumns = []string{
+	"kd_kro", "kd_kro_str", "nama_kro", "pagu_kro", "tahun_anggaran",
+	"kd_satker", "kd_klpd", "nama_klpd", "jenis_klpd", "kd_program", "kd_kegiatan",
+	"_event_date", "is_deleted",
+}
+
+// rupPage builds the query of one page of the rows builder selects, newest
+// first with ties ordered by tiebreaker, and the query of their total
+func rupPage(builder *sqlbuilder.Builder, tiebreaker string, limit, offset int) (builtQuery, error) {
+	builder.OrderBy("_event_date", "DESC").Tiebreaker(tiebreaker, "DESC").Limit(limit).Offset(offset)
+	query, err := builder.SQL()
+	if err != nil {
+		return builtQuery{}, err
+	}
+	countQuery, err := builder.CountSQL()
+	if err != nil {
+		return builtQuery{}, err
+	}
+	return builtQuery{
+		SQL:      query,
+		CountSQL: countQuery,
+		Params:   map[string]interface{}{"limit": limit, "offset": offset},
+		Order:    builder.Order(),
+	}, nil
+}
+
+// rupListQuery builds the queries of GET /api/v1/rup over table
+func rupListQuery(table string, withDeleted bool, tiebreaker string, limit, offset int) (builtQuery, error) {
+	return rupPage(rupSelect(table, rupListColumns...).Where(rupVisible(withDeleted)...), tiebreaker, limit, offset)
+}
+
+// rupSearchQuery builds the queries of POST /api/v1/rup/search over table
+// with the validated keywords of req, ties ordered by tiebreaker; filtered
+// reports whether the request set any filter of its own
+func rupSearchQuery(table string, req rupSearchRequest, keywords *datasource.KeywordMatch, withDeleted bool, tiebreaker string) (query builtQuery, filtered bool, err error) {
+	var conditions []sqlbuilder.Cond
+	params := make(map[string]interface{})
+
+	// tahun_anggaran and kd_satker are INT64 in BigQuery
+	var invalid []error
+	if req.Tahun != "" {
+		if tahun, err := strconv.ParseInt(req.Tahun, 10, 64); err != nil {
+			invalid = append(invalid, fieldError("tahun", "integer", "tahun must be an integer"))
+		} else {
+			conditions = append(conditions, sqlbuilder.Eq("tahun_anggaran", tahun))
+			params["tahun"] = tahun
+		}
+	}
+
+	if req.KdSatker != "" {
+		if kdSatker, err := strconv.ParseInt(req.KdSatker, 10, 64); err != nil {
+			invalid = append(invalid, fieldError("kd_satker", "integer", "kd_satker must be an integer"))
+		} else {
+			conditions = append(conditions, sqlbuilder.Eq("kd_satker", kdSatker))
+			params["kd_satker"] = kdSatker
+		}
+	}
+	if len(invalid) > 0 {
+		return builtQuery{}, false, errors.Join(invalid...)
+	}
+
+	// A bound of zero or less is not set
+	var minPagu, maxPagu interface{}
+	if req.MinPagu > 0 {
+		minPagu = req.MinPagu
+		params["min_pagu"] = req.MinPagu
+	}
+	if req.MaxPagu > 0 {
+		maxPagu = req.MaxPagu
+		params["max_pagu"] = req.MaxPagu
+	}
+	if minPagu != nil || maxPagu != nil {
+		conditions = append(conditions, sqlbuilder.Range("pagu_kro", minPagu, maxPagu))
+	}
+
+	builder := rupSelect(table, rupListColumns...).Where(conditions...).Where(rupVisible(withDeleted)...)
+	if keywords != nil {
+		condition, err := keywords.Condition()
+		if err != nil {
+			return builtQuery{}, false, err
+		}
+		builder.Search(condition)
+		params["keyword"] = keywordParam(keywords.Terms)
+		params["match"] = keywordMode(keywords)
+	}
+
+	// filtered reports the caller's filters; the deleted condition is in meta
+	filtered = len(conditions) > 0 || keywords != nil
+	query, err = rupPage(builder, tiebreaker, req.Limit, req.Offset)
+	if err != nil {
+		return builtQuery{}, false, err
+	}
+	for name, value := range params {
+		query.Params[name] = value
+	}
+	return query, filtered, nil
+}
+
+// rupDebug reports query for debug_sql and dry_run, its count query only
+// when the total is counted; RUP results are not cached, so there is no
+// cache key
+func rupDebug(query builtQuery, withTotal bool, mode sqlDebugMode) *response.QueryDebug {
+	if mode == sqlDebugOff {
+		return nil
+	}
+	debug := &response.QueryDebug{SQL: query.SQL, Params: query.Params}
+	if withTotal {
+		debug.CountSQL = query.CountSQL
+	}
+	return debug
+}
@@ -0,0 +1,254 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/auth"
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/tenant"
+)
+
+// recordingQuerier records every SQL statement and answers count queries
+// with a total
+type recordingQuerier struct {
+	queries []string
+}
+
+func (q *recordingQuerier) Query(ctx context.Context, sqlQuery string) ([]map[string]interface{}, error) {
+	q.queries = append(q.queries, sqlQuery)
+	if strings.Contains(sqlQuery, "COUNT(*)") {
+		return []map[string]interface{}{{"total": int64(42)}}, nil
+	}
+	return rowsOf(1), nil
+}
+
+func newTestRUPHandler() (*RUPHandler, *recordingQuerier) {
+	querier := &recordingQuerier{}
+	return &RUPHandler{bigquery: querier, limits: testLimits, search: config.DefaultSearch().RUP, bulk: newBulkReader(zap.NewNop()), logger: zap.NewNop(),
+		tiebreaker: config.DefaultTiebreakers().For(rupTable), counts: newCountCache(zap.NewNop()), notFound: newNotFoundCache(zap.NewNop())}, querier
+}
+
+func asAdmin(r *http.Request) *http.Request {
+	return r.WithContext(auth.WithKey(r.Context(), &auth.APIKey{ID: "key_admin", Scopes: []string{auth.ScopeAdmin}}))
+}
+
+func asReader(r *http.Request) *http.Request {
+	return r.WithContext(auth.WithKey(r.Context(), &auth.APIKey{ID: "key_reader", Scopes: []string{"read"}}))
+}
+
+func TestRUP_ListHidesDeletedRows(t *testing.T) {
+	handler, querier := newTestRUPHandler()
+
+	rec := httptest.NewRecorder()
+	handler.List(rec, asReader(httptest.NewRequest(http.MethodGet, "/api/v1/rup", nil)))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Len(t, querier.queries, 2)
+	for _, q := range querier.queries {
+		assert.Contains(t, q, "WHERE is_deleted = FALSE")
+	}
+	assert.Contains(t, querier.queries[0], "ORDER BY _event_date DESC, kd_kro_str DESC")
+	body := decodeResponse(t, rec)
+	assert.True(t, body.Meta.DeletedFiltered)
+	assert.Equal(t, 42, body.Meta.Total)
+	assert.Equal(t, []string{"_event_date DESC", "kd_kro_str DESC"}, body.Meta.Order)
+	assert.Empty(t, rec.Header().Get("Cache-Control"))
+}
+
+func TestRUP_ListIncludeDeletedForAdmin(t *testing.T) {
+	handler, querier := newTestRUPHandler()
+
+	rec := httptest.NewRecorder()
+	handler.List(rec, asAdmin(httptest.NewRequest(http.MethodGet, "/api/v1/rup?include_deleted=true", nil)))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Len(t, querier.queries, 2)
+	for _, q := range querier.queries {
+		assert.NotContains(t, q, "is_deleted = FALSE")
+	}
+	assert.False(t, decodeResponse(t, rec).Meta.DeletedFiltered)
+	assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+}
+
+func TestRUP_IncludeDeletedRequiresAdmin(t *testing.T) {
+	handler, querier := newTestRUPHandler()
+
+	rec := httptest.NewRecorder()
+	handler.List(rec, asReader(httptest.NewRequest(http.MethodGet, "/api/v1/rup?include_deleted=true", nil)))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.GetByID(rec, httptest.NewRequest(http.MethodGet, "/api/v1/rup/K1?include_deleted=true", nil))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.Search(rec, asReader(httptest.NewRequest(http.MethodPost, "/api/v1/rup/search",
+		bytes.NewBufferString(`{"include_deleted": true}`))))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.List(rec, asAdmin(httptest.NewRequest(http.MethodGet, "/api/v1/rup?include_deleted=maybe", nil)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	assert.Empty(t, querier.queries)
+
+	// An explicit false is the default and needs no scope
+	rec = httptest.NewRecorder()
+	handler.List(rec, asReader(httptest.NewRequest(http.MethodGet, "/api/v1/rup?include_deleted=false", nil)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestRUP_GetByID(t *testing.T) {
+	handler, querier := newTestRUPHandler()
+
+	rec := httptest.NewRecorder()
+	handler.GetByID(rec, httptest.NewRequest(http.MethodGet, "/api/v1/rup/K1", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, querier.queries[0], "WHERE kd_kro_str = 'K1' AND is_deleted = FALSE")
+	assert.True(t, decodeResponse(t, rec).Meta.DeletedFiltered)
+
+	rec = httptest.NewRecorder()
+	handler.GetByID(rec, asAdmin(httptest.NewRequest(http.MethodGet, "/api/v1/rup/K1?include_deleted=true", nil)))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, querier.queries[1], "WHERE kd_kro_str = 'K1' LIMIT 1")
+	assert.NotContains(t, querier.queries[1], "is_deleted = FALSE")
+}
+
+func TestRUP_ReadsTheTenantsTable(t *testing.T) {
+	handler, querier := newTestRUPHandler()
+	agencyB := &tenant.Tenant{ID: "agency-b", BigQueryProject: "agency-b-prod", BigQueryDataset: "isb_b", CacheNamespace: "agency-b"}
+	agencyC := &tenant.Tenant{ID: "agency-c", BigQueryDataset: "isb_c", CacheNamespace: "agency-c"}
+	asTenant := func(r *http.Request, t *tenant.Tenant) *http.Request {
+		return r.WithContext(tenant.WithTenant(r.Context(), t))
+	}
+
+	tests := []struct {
+		name   string
+		tenant *tenant.Tenant
+		table  string
+	}{
+		{"default", &tenant.Tenant{ID: tenant.DefaultID}, "gtp-data-prod.layer_isb.rup_kromaster"},
+		{"project and dataset", agencyB, "agency-b-prod.isb_b.rup_kromaster"},
+		{"dataset only", agencyC, "gtp-data-prod.isb_c.rup_kromaster"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			querier.queries = nil
+
+			rec := httptest.NewRecorder()
+			handler.List(rec, asTenant(httptest.NewRequest(http.MethodGet, "/api/v1/rup", nil), tt.tenant))
+			require.Equal(t, http.StatusOK, rec.Code)
+
+			rec = httptest.NewRecorder()
+			handler.GetByID(rec, asTenant(httptest.NewRequest(http.MethodGet, "/api/v1/rup/K1", nil), tt.tenant))
+			require.Equal(t, http.StatusOK, rec.Code)
+
+			rec = httptest.NewRecorder()
+			handler.Search(rec, asTenant(httptest.NewRequest(http.MethodPost, "/api/v1/rup/search", strings.NewReader(`{"tahun": "2024"}`)), tt.tenant))
+			require.Equal(t, http.StatusOK, rec.Code)
+
+			require.Len(t, querier.queries, 5)
+			for _, q := range querier.queries {
+				assert.Contains(t, q, "FROM `"+tt.table+"`")
+			}
+		})
+	}
+}
+
+func TestRUP_SearchCombinesDeletedFilterWithFilters(t *testing.T) {
+	handler, querier := newTestRUPHandler()
+
+	search := func(r *http.Request) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.Search(rec, r)
+		return rec
+	}
+	request := func(body string) *http.Request {
+		return httptest.NewRequest(http.MethodPost, "/api/v1/rup/search", bytes.NewBufferString(body))
+	}
+
+	rec := search(request(`{"tahun": "2024"}`))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Len(t, querier.queries, 2)
+	for _, q := range querier.queries {
+		assert.Contains(t, q, "WHERE tahun_anggaran = 2024 AND is_deleted = FALSE")
+	}
+	body := decodeResponse(t, rec)
+	assert.True(t, body.Meta.DeletedFiltered)
+	assert.Equal(t, 42, body.Meta.Total)
+	assert.Equal(t, true, body.Data.(map[string]interface{})["filtered"])
+
+	// Without user filters the default still applies but the search is unfiltered
+	querier.queries = nil
+	rec = search(request(`{}`))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, querier.queries[1], "WHERE is_deleted = FALSE")
+	assert.Equal(t, false, decodeResponse(t, rec).Data.(map[string]interface{})["filtered"])
+
+	// Admins keep their filters without the default
+	querier.queries = nil
+	rec = search(asAdmin(request(`{"tahun": "2024", "include_deleted": true}`)))
+	require.Equal(t, http.StatusOK, rec.Code)
+	for _, q := range querier.queries {
+		assert.Contains(t, q, "WHERE tahun_anggaran = 2024")
+		assert.NotContains(t, q, "is_deleted = FALSE")
+	}
+	assert.False(t, decodeResponse(t, rec).Meta.DeletedFiltered)
+}
+
+func TestRUP_EscapesBigQueryLiterals(t *testing.T) {
+	handler, querier := newTestRUPHandler()
+
+	rec := httptest.NewRecorder()
+	handler.GetByID(rec, httptest.NewRequest(http.MethodGet, `/api/v1/rup/K1\'%20OR%201=1`, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, querier.queries[0], `WHERE kd_kro_str = 'K1\\\' OR 1=1'`)
+
+	querier.queries = nil
+	rec = httptest.NewRecorder()
+	handler.Search(rec, httptest.NewRequest(http.MethodPost, "/api/v1/rup/search",
+		bytes.NewBufferString(`{"keyword": "a\\' OR 1=1 --"}`)))
+	require.Equal(t, http.StatusOK, rec.Code)
+	// The backslash is escaped for LIKE, then both it and the quote for the literal
+	assert.Contains(t, querier.queries[0], `LIKE LOWER('%a\\\\\' OR 1=1 --%')`)
+}
+
+func TestRUP_SearchRejectsNonIntegerFilters(t *testing.T) {
+	handler, querier := newTestRUPHandler()
+
+	for _, body := range []string{`{"tahun": "2024 OR 1=1"}`, `{"kd_satker": "1; DROP TABLE rup"}`} {
+		rec := httptest.NewRecorder()
+		handler.Search(rec, httptest.NewRequest(http.MethodPost, "/api/v1/rup/search", bytes.NewBufferString(body)))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+	}
+	assert.Empty(t, querier.queries)
+}
+
+func TestRUP_SearchKeywords(t *testing.T) {
+	handler, querier := newTestRUPHandler()
+
+	rec := httptest.NewRecorder()
+	handler.Search(rec, httptest.NewRequest(http.MethodPost, "/api/v1/rup/search",
+		bytes.NewBufferString(`{"keyword": ["jalan", "under_score"], "match": "all"}`)))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, querier.queries[0], `(LOWER(nama_kro) LIKE LOWER('%jalan%') OR LOWER(nama_klpd) LIKE LOWER('%jalan%'))`+
+		` AND (LOWER(nama_kro) LIKE LOWER('%under\\_score%')`)
+	data := decodeResponse(t, rec).Data.(map[string]interface{})
+	assert.Equal(t, []interface{}{"jalan", "under_score"}, data["filters_applied"].(map[string]interface{})["keyword"])
+
+	querier.queries = nil
+	rec = httptest.NewRecorder()
+	handler.Search(rec, httptest.NewRequest(http.MethodPost, "/api/v1/rup/search",
+		bytes.NewBufferString(`{"keyword": ["a", "b", "c", "d", "e", "f"]}`)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Empty(t, querier.queries)
+}
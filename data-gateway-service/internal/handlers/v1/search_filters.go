@@ -0,0 +1,299 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/sqlbuilder"
+	"go-data-gateway/pkg/apitypes"
+)
+
+// Operators of a search clause
+const (
+	searchEq      = "eq"
+	searchNeq     = "neq"
+	searchGt      = "gt"
+	searchGte     = "gte"
+	searchLt      = "lt"
+	searchLte     = "lte"
+	searchIn      = "in"
+	searchNin     = "nin"
+	searchLike    = "like"
+	searchIsNull  = "is_null"
+	searchNotNull = "not_null"
+)
+
+// searchOps lists the operators of a search clause, for messages
+const searchOps = "eq neq gt gte lt lte in nin like is_null not_null"
+
+const (
+	// maxSearchConditions bounds the conditions of a search, counted across
+	// its groups
+	maxSearchConditions = 20
+	// maxSearchDepth bounds how deeply groups nest
+	maxSearchDepth = 3
+)
+
+// searchFilter compiles the clauses of a search over the declared columns of
+// a table, validating each against its column's type
+type searchFilter struct {
+	v          *violations
+	columns    map[string]config.ColumnSpec
+	conditions int
+}
+
+// searchConditions compiles clauses, which all must match, into conditions
+// on the declared columns. Every bad clause is reported to v under field,
+// e.g. filters[1].or[0].value; the conditions are only valid without any.
+func searchConditions(v *violations, field string, clauses []apitypes.SearchClause, columns []config.ColumnSpec) []sqlbuilder.Cond {
+	f := &searchFilter{v: v, columns: make(map[string]config.ColumnSpec, len(columns))}
+	for _, column := range columns {
+		f.columns[column.Name] = column
+	}
+
+	conds := f.clauses(field, clauses, 0)
+	if f.conditions > maxSearchConditions {
+		v.add(field, fmt.Sprintf("max=%d", maxSearchConditions), "a search has at most %d conditions, got %d", maxSearchConditions, f.conditions)
+	}
+	return conds
+}
+
+// searchFields returns the columns clauses filter, sorted and without repeats
+func searchFields(clauses []apitypes.SearchClause) []string {
+	seen := map[string]bool{}
+	var walk func([]apitypes.SearchClause)
+	walk = func(clauses []apitypes.SearchClause) {
+		for _, clause := range clauses {
+			if clause.Field != "" {
+				seen[clause.Field] = true
+			}
+			walk(clause.Or)
+			walk(clause.And)
+		}
+	}
+	walk(clauses)
+
+	fields := make([]string, 0, len(seen))
+	for field := range seen {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func (f *searchFilter) clauses(path string, clauses []apitypes.SearchClause, depth int) []sqlbuilder.Cond {
+	conds := make([]sqlbuilder.Cond, 0, len(clauses))
+	for i, clause := range clauses {
+		if cond := f.clause(fmt.Sprintf("%s[%d]", path, i), clause, depth); cond != nil {
+			conds = append(conds, cond)
+		}
+	}
+	return conds
+}
+
+// clause compiles one clause, or returns nil after reporting it
+func (f *searchFilter) clause(path string, clause apitypes.SearchClause, depth int) sqlbuilder.Cond {
+	group, name, join := clause.And, "and", sqlbuilder.And
+	if clause.Or != nil {
+		group, name, join = clause.Or, "or", sqlbuilder.Or
+	}
+	isCondition := clause.Field != "" || clause.Op != "" || clause.Value != nil
+
+	switch {
+	case clause.Or != nil && clause.And != nil:
+		f.v.add(path, "group", "a group is either or or and")
+		return nil
+	case group == nil:
+		return f.condition(path, clause)
+	case isCondition:
+		f.v.add(path, "group", "a clause is a condition or a group, not both")
+		return nil
+	case len(group) == 0:
+		f.v.add(path+"."+name, "min=1", "%s needs at least one clause", name)
+		return nil
+	case depth >= maxSearchDepth:
+		f.v.add(path, fmt.Sprintf("max_depth=%d", maxSearchDepth), "groups nest at most %d deep", maxSearchDepth)
+		return nil
+	}
+
+	conds := f.clauses(path+"."+name, group, depth+1)
+	if len(conds) < len(group) {
+		return nil
+	}
+	return join(conds...)
+}
+
+// condition compiles a {field, op, value} clause
+func (f *searchFilter) condition(path string, clause apitypes.SearchClause) sqlbuilder.Cond {
+	f.conditions++
+
+	column, ok := f.columns[clause.Field]
+	switch {
+	case clause.Field == "":
+		f.v.required(path + ".field")
+	case !ok || column.Type == config.ColumnRecord:
+		f.v.add(path+".field", "oneof="+strings.Join(f.filterable(), " "), "cannot filter on column %q", clause.Field)
+	}
+	op := strings.ToLower(clause.Op)
+	if op == "" {
+		f.v.required(path + ".op")
+		return nil
+	}
+	if !strings.Contains(" "+searchOps+" ", " "+op+" ") {
+		f.v.add(path+".op", "oneof="+searchOps, "unknown operator %q", clause.Op)
+		return nil
+	}
+	if !ok || column.Type == config.ColumnRecord {
+		return nil
+	}
+
+	cond, err := searchCondition(column, op, clause.Value)
+	if err != nil {
+		f.v.add(path+".value", op, "%s", err.Error())
+		return nil
+	}
+	return cond
+}
+
+// filterable lists the columns clauses may filter, sorted
+func (f *searchFilter) filterable() []string {
+	names := make([]string, 0, len(f.columns))
+	for name, column := range f.columns {
+		if column.Type != config.ColumnRecord {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// searchCondition returns the condition of op on column with a raw JSON value
+func searchCondition(column config.ColumnSpec, op string, raw json.RawMessage) (sqlbuilder.Cond, error) {
+	switch op {
+	case searchIsNull, searchNotNull:
+		if raw != nil && !isNull(raw) {
+			return nil, fmt.Errorf("%s takes no value", op)
+		}
+		if op == searchIsNull {
+			return sqlbuilder.IsNull(column.Name), nil
+		}
+		return sqlbuilder.NotNull(column.Name), nil
+
+	case searchIn, searchNin:
+		var items []json.RawMessage
+		if err := json.Unmarshal(raw, &items); err != nil || items == nil {
+			return nil, fmt.Errorf("%s needs a list of values", op)
+		}
+		if len(items) == 0 || len(items) > sqlbuilder.MaxInValues {
+			return nil, fmt.Errorf("%s needs 1 to %d values", op, sqlbuilder.MaxInValues)
+		}
+		values := make([]interface{}, len(items))
+		for i, item := range items {
+			value, err := searchValue(column, item)
+			if err != nil {
+				return nil, fmt.Errorf("value %d: %w", i, err)
+			}
+			values[i] = value
+		}
+		if op == searchIn {
+			return sqlbuilder.In(column.Name, values), nil
+		}
+		return sqlbuilder.NotIn(column.Name, values), nil
+
+	case searchLike:
+		if column.Type != config.ColumnString {
+			return nil, fmt.Errorf("like only applies to string columns, %s is %s", column.Name, column.Type)
+		}
+		var pattern string
+		if err := json.Unmarshal(raw, &pattern); err != nil || isNull(raw) {
+			return nil, fmt.Errorf("like needs a string pattern")
+		}
+		return sqlbuilder.Like(column.Name, pattern), nil
+	}
+
+	if (op == searchGt || op == searchGte || op == searchLt || op == searchLte) && column.Type == config.ColumnBoolean {
+		return nil, fmt.Errorf("%s does not apply to boolean columns", op)
+	}
+	value, err := searchValue(column, raw)
+	if err != nil {
+		return nil, err
+	}
+	switch op {
+	case searchEq:
+		return sqlbuilder.Eq(column.Name, value), nil
+	case searchNeq:
+		return sqlbuilder.Neq(column.Name, value), nil
+	case searchGt:
+		return sqlbuilder.Gt(column.Name, value), nil
+	case searchGte:
+		return sqlbuilder.Gte(column.Name, value), nil
+	case searchLt:
+		return sqlbuilder.Lt(column.Name, value), nil
+	default:
+		return sqlbuilder.Lte(column.Name, value), nil
+	}
+}
+
+// searchValue decodes a raw JSON value of column's type: a number, a string,
+// true or false, or a date string YYYY-MM-DD. Null is not a value; is_null
+// and not_null match it.
+func searchValue(column config.ColumnSpec, raw json.RawMessage) (interface{}, error) {
+	if raw == nil || isNull(raw) {
+		return nil, fmt.Errorf("a value is required; use is_null or not_null to match NULL")
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	switch column.Type {
+	case config.ColumnNumber:
+		n, ok := value.(json.Number)
+		if !ok {
+			return nil, fmt.Errorf("%s is a number column", column.Name)
+		}
+		if i, err := n.Int64(); err == nil {
+			return i, nil
+		}
+		f, err := n.Float64()
+		if err != nil || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("%s is not a number %s accepts", n, column.Name)
+		}
+		return f, nil
+	case config.ColumnBoolean:
+		b, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%s is a boolean column", column.Name)
+		}
+		return b, nil
+	case config.ColumnDate:
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s is a date column (YYYY-MM-DD)", column.Name)
+		}
+		if _, err := time.Parse("2006-01-02", s); err != nil {
+			return nil, fmt.Errorf("%s is a date column (YYYY-MM-DD)", column.Name)
+		}
+		return s, nil
+	default:
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s is a string column", column.Name)
+		}
+		return s, nil
+	}
+}
+
+// isNull reports whether raw is the JSON null
+func isNull(raw json.RawMessage) bool {
+	return string(bytes.TrimSpace(raw)) == "null"
+}
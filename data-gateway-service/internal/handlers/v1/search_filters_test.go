@@ -0,0 +1,214 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/sqlbuilder"
+	"go-data-gateway/pkg/apitypes"
+)
+
+// searchTestColumns are the declared columns the filter tests search
+var searchTestColumns = []config.ColumnSpec{
+	{Name: "nama_paket", Type: config.ColumnString},
+	{Name: "nilai_pagu", Type: config.ColumnNumber},
+	{Name: "aktif", Type: config.ColumnBoolean},
+	{Name: "tanggal_buat_paket", Type: config.ColumnDate},
+	{Name: "lokasi", Type: config.ColumnRecord},
+}
+
+// compileSearch compiles the filters of a JSON search body and renders them
+// as a Dremio WHERE clause
+func compileSearch(t *testing.T, filters string) (string, []apitypes.Violation) {
+	t.Helper()
+	var clauses []apitypes.SearchClause
+	require.NoError(t, json.Unmarshal([]byte(filters), &clauses))
+
+	var v violations
+	conds := searchConditions(&v, "filters", clauses, searchTestColumns)
+	if len(v) > 0 {
+		return "", v
+	}
+	cond := sqlbuilder.And(conds...)
+	if len(conds) == 1 {
+		cond = conds[0]
+	}
+	where, err := sqlbuilder.Dremio.Render(cond)
+	require.NoError(t, err)
+	return where, nil
+}
+
+func TestSearchConditions_SQL(t *testing.T) {
+	tests := map[string]struct {
+		filters string
+		where   string
+	}{
+		"eq":       {`[{"field": "nama_paket", "op": "eq", "value": "jalan"}]`, "nama_paket = 'jalan'"},
+		"neq":      {`[{"field": "nama_paket", "op": "neq", "value": "jalan"}]`, "nama_paket <> 'jalan'"},
+		"gt":       {`[{"field": "nilai_pagu", "op": "gt", "value": 100}]`, "nilai_pagu > 100"},
+		"gte":      {`[{"field": "nilai_pagu", "op": "gte", "value": 1.5}]`, "nilai_pagu >= 1.5"},
+		"lt":       {`[{"field": "nilai_pagu", "op": "lt", "value": -3}]`, "nilai_pagu < -3"},
+		"lte":      {`[{"field": "nilai_pagu", "op": "lte", "value": 9007199254740993}]`, "nilai_pagu <= 9007199254740993"},
+		"in":       {`[{"field": "nilai_pagu", "op": "in", "value": [1, 2.5]}]`, "nilai_pagu IN (1, 2.5)"},
+		"nin":      {`[{"field": "nama_paket", "op": "nin", "value": ["a", "b"]}]`, "nama_paket NOT IN ('a', 'b')"},
+		"like":     {`[{"field": "nama_paket", "op": "like", "value": "jalan%"}]`, "nama_paket LIKE 'jalan%'"},
+		"is_null":  {`[{"field": "nilai_pagu", "op": "is_null"}]`, "nilai_pagu IS NULL"},
+		"not_null": {`[{"field": "nilai_pagu", "op": "not_null", "value": null}]`, "nilai_pagu IS NOT NULL"},
+		"bool":     {`[{"field": "aktif", "op": "eq", "value": false}]`, "aktif = FALSE"},
+		"date":     {`[{"field": "tanggal_buat_paket", "op": "gte", "value": "2025-01-01"}]`, "tanggal_buat_paket >= '2025-01-01'"},
+		"op case":  {`[{"field": "nilai_pagu", "op": "GTE", "value": 1}]`, "nilai_pagu >= 1"},
+		"range": {`[{"field": "nilai_pagu", "op": "gte", "value": 10}, {"field": "nilai_pagu", "op": "lt", "value": 20}]`,
+			"(nilai_pagu >= 10 AND nilai_pagu < 20)"},
+		"or group": {`[{"field": "aktif", "op": "eq", "value": true},
+			{"or": [{"field": "nilai_pagu", "op": "is_null"}, {"field": "nilai_pagu", "op": "gt", "value": 5}]}]`,
+			"(aktif = TRUE AND (nilai_pagu IS NULL OR nilai_pagu > 5))"},
+		"and in or": {`[{"or": [{"field": "nama_paket", "op": "eq", "value": "a"},
+			{"and": [{"field": "nilai_pagu", "op": "gt", "value": 1}, {"field": "aktif", "op": "neq", "value": true}]}]}]`,
+			"(nama_paket = 'a' OR (nilai_pagu > 1 AND aktif <> TRUE))"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			where, violations := compileSearch(t, tt.filters)
+			require.Empty(t, violations)
+			assert.Equal(t, tt.where, where)
+		})
+	}
+}
+
+func TestSearchConditions_Violations(t *testing.T) {
+	tests := map[string]struct {
+		filters    string
+		field      string
+		constraint string
+	}{
+		"no field":        {`[{"op": "eq", "value": 1}]`, "filters[0].field", "required"},
+		"no op":           {`[{"field": "nilai_pagu", "value": 1}]`, "filters[0].op", "required"},
+		"unknown op":      {`[{"field": "nilai_pagu", "op": "between", "value": 1}]`, "filters[0].op", "oneof=" + searchOps},
+		"unknown field":   {`[{"field": "satuan_kerja", "op": "eq", "value": "x"}]`, "filters[0].field", "oneof=aktif nama_paket nilai_pagu tanggal_buat_paket"},
+		"record field":    {`[{"field": "lokasi", "op": "is_null"}]`, "filters[0].field", "oneof=aktif nama_paket nilai_pagu tanggal_buat_paket"},
+		"no value":        {`[{"field": "nilai_pagu", "op": "eq"}]`, "filters[0].value", "eq"},
+		"null value":      {`[{"field": "nilai_pagu", "op": "eq", "value": null}]`, "filters[0].value", "eq"},
+		"string number":   {`[{"field": "nilai_pagu", "op": "gt", "value": "100"}]`, "filters[0].value", "gt"},
+		"number string":   {`[{"field": "nama_paket", "op": "eq", "value": 1}]`, "filters[0].value", "eq"},
+		"string bool":     {`[{"field": "aktif", "op": "eq", "value": "true"}]`, "filters[0].value", "eq"},
+		"bool order":      {`[{"field": "aktif", "op": "gt", "value": true}]`, "filters[0].value", "gt"},
+		"bad date":        {`[{"field": "tanggal_buat_paket", "op": "lt", "value": "01/02/2025"}]`, "filters[0].value", "lt"},
+		"object value":    {`[{"field": "nama_paket", "op": "eq", "value": {"a": 1}}]`, "filters[0].value", "eq"},
+		"huge number":     {`[{"field": "nilai_pagu", "op": "eq", "value": 1e999}]`, "filters[0].value", "eq"},
+		"in scalar":       {`[{"field": "nilai_pagu", "op": "in", "value": 1}]`, "filters[0].value", "in"},
+		"in empty":        {`[{"field": "nilai_pagu", "op": "in", "value": []}]`, "filters[0].value", "in"},
+		"nin bad item":    {`[{"field": "nilai_pagu", "op": "nin", "value": [1, "x"]}]`, "filters[0].value", "nin"},
+		"like number":     {`[{"field": "nilai_pagu", "op": "like", "value": "1%"}]`, "filters[0].value", "like"},
+		"is_null value":   {`[{"field": "nilai_pagu", "op": "is_null", "value": 1}]`, "filters[0].value", "is_null"},
+		"empty group":     {`[{"or": []}]`, "filters[0].or", "min=1"},
+		"or and and":      {`[{"or": [{"field": "aktif", "op": "is_null"}], "and": [{"field": "aktif", "op": "is_null"}]}]`, "filters[0]", "group"},
+		"group and field": {`[{"field": "aktif", "or": [{"field": "aktif", "op": "is_null"}]}]`, "filters[0]", "group"},
+		"nested path":     {`[{"or": [{"field": "aktif", "op": "is_null"}, {"field": "aktif", "op": "like", "value": "x"}]}]`, "filters[0].or[1].value", "like"},
+		"too deep": {`[{"or": [{"and": [{"or": [{"and": [{"field": "aktif", "op": "is_null"}]}]}]}]}]`,
+			"filters[0].or[0].and[0].or[0]", "max_depth=3"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, violations := compileSearch(t, tt.filters)
+			require.Len(t, violations, 1, violations)
+			assert.Equal(t, tt.field, violations[0].Field)
+			assert.Equal(t, tt.constraint, violations[0].Constraint)
+		})
+	}
+}
+
+func TestSearchConditions_ReportsEveryClause(t *testing.T) {
+	_, violations := compileSearch(t, `[
+		{"field": "nilai_pagu", "op": "gt", "value": "x"},
+		{"field": "nama_paket", "op": "eq", "value": "ok"},
+		{"field": "nope", "op": "eq", "value": 1},
+		{"or": [{"field": "aktif", "op": "maybe"}]}]`)
+	fields := make([]string, len(violations))
+	for i, violation := range violations {
+		fields[i] = violation.Field
+	}
+	assert.Equal(t, []string{"filters[0].value", "filters[2].field", "filters[3].or[0].op"}, fields)
+}
+
+func TestSearchConditions_Cap(t *testing.T) {
+	clauses := make([]string, maxSearchConditions)
+	for i := range clauses {
+		clauses[i] = fmt.Sprintf(`{"field": "nilai_pagu", "op": "neq", "value": %d}`, i)
+	}
+	_, violations := compileSearch(t, "["+strings.Join(clauses, ",")+"]")
+	assert.Empty(t, violations)
+
+	// Conditions in groups count too
+	over := append(clauses[:maxSearchConditions-1:maxSearchConditions-1],
+		`{"or": [{"field": "aktif", "op": "is_null"}, {"field": "aktif", "op": "eq", "value": true}]}`)
+	_, violations = compileSearch(t, "["+strings.Join(over, ",")+"]")
+	require.Len(t, violations, 1)
+	assert.Equal(t, "filters", violations[0].Field)
+	assert.Equal(t, fmt.Sprintf("max=%d", maxSearchConditions), violations[0].Constraint)
+}
+
+func TestSearchFields(t *testing.T) {
+	var clauses []apitypes.SearchClause
+	require.NoError(t, json.Unmarshal([]byte(`[{"field": "b", "op": "is_null"},
+		{"or": [{"field": "a", "op": "is_null"}, {"and": [{"field": "b", "op": "is_null"}, {"field": "c", "op": "is_null"}]}]}]`), &clauses))
+	assert.Equal(t, []string{"a", "b", "c"}, searchFields(clauses))
+}
+
+func TestTenderSearch_Injection(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio}
+	handler := NewTenderHandler(source, testLimits, nil, zap.NewNop())
+	search := func(body string) *httptest.ResponseRecorder {
+		source.query = ""
+		rec := httptest.NewRecorder()
+		handler.Search(rec, httptest.NewRequest(http.MethodPost, "/api/v1/tender/search", strings.NewReader(body)))
+		return rec
+	}
+
+	// Values are quoted literals, whatever they hold
+	for value, literal := range map[string]string{
+		`"x' OR '1'='1"`:                `'x'' OR ''1''=''1'`,
+		`"'; DROP TABLE tender_data--"`: `'''; DROP TABLE tender_data--'`,
+		`"a\\' OR 1=1 --"`:              `'a\'' OR 1=1 --'`,
+		`"/* */ UNION SELECT 1"`:        `'/* */ UNION SELECT 1'`,
+	} {
+		rec := search(`{"filters": [{"field": "nama_paket", "op": "like", "value": ` + value + `},
+			{"field": "status_tender", "op": "in", "value": [` + value + `]}]}`)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Contains(t, source.query, "nama_paket LIKE "+literal, value)
+		assert.Contains(t, source.query, "status_tender IN ("+literal+")", value)
+	}
+
+	// Numbers are numbers; SQL in a number is a type error
+	rec := search(`{"filters": [{"field": "nilai_pagu", "op": "gt", "value": "0 OR 1=1"}]}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Empty(t, source.query)
+
+	// Fields and ops are checked against fixed lists, never rendered as given
+	for _, clause := range []string{
+		`{"field": "nama_paket = nama_paket OR nama_paket", "op": "eq", "value": "x"}`,
+		`{"field": "nama_paket--", "op": "eq", "value": "x"}`,
+		`{"field": "(SELECT 1)", "op": "is_null"}`,
+		`{"field": "nama_paket", "op": "= 'x' OR 1=1 --", "value": "x"}`,
+		`{"field": "nama_paket", "op": "eq OR", "value": "x"}`,
+	} {
+		rec := search(`{"filters": [` + clause + `]}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, clause)
+		assert.Len(t, violationsOf(t, rec), 1, clause)
+		assert.Empty(t, source.query, clause)
+	}
+
+	// The free-form column keys of the old body are unknown fields
+	rec = search(`{"nama_paket": "x"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Empty(t, source.query)
+}
@@ -0,0 +1,247 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/auth"
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/response"
+	"go-data-gateway/internal/sheets"
+	"go-data-gateway/internal/tenant"
+)
+
+// SheetsExportRequest is the body of POST /api/v1/export/sheets. Exactly one
+// of SQL and Table is set; Filters only applies to Table.
+type SheetsExportRequest struct {
+	Source  string                 `json:"source"` // Data source name, e.g. DATAWAREHOUSE
+	SQL     string                 `json:"sql,omitempty"`
+	Table   string                 `json:"table,omitempty"`
+	Filters map[string]interface{} `json:"filters,omitempty"`
+
+	// Columns orders the written columns; by default the table's declared
+	// columns, or the result's columns sorted by name. A dotted path such as
+	// peserta.email selects a field of a record column.
+	Columns []string `json:"columns,omitempty"`
+
+	SpreadsheetID string `json:"spreadsheet_id"`
+	Sheet         string `json:"sheet"`
+	Mode          string `json:"mode,omitempty"` // clear (default) or append
+}
+
+// SheetsExportResponse is the data of POST /api/v1/export/sheets
+type SheetsExportResponse struct {
+	SpreadsheetID string   `json:"spreadsheet_id"`
+	Sheet         string   `json:"sheet"`
+	Mode          string   `json:"mode"`
+	Columns       []string `json:"columns"`
+	sheets.Written
+}
+
+// SheetsHandler writes query results to Google Sheets
+type SheetsHandler struct {
+	dataSources map[string]datasource.DataSource
+	client      sheets.Client
+	maxRows     int
+	batchRows   int
+	security    config.SecurityProvider
+	logger      *zap.Logger
+	audit       *zap.Logger
+}
+
+// NewSheetsHandler creates a new Sheets export handler
+func NewSheetsHandler(dataSources map[string]datasource.DataSource, client sheets.Client, cfg config.SheetsConfig, security config.SecurityProvider, logger *zap.Logger) *SheetsHandler {
+	return &SheetsHandler{
+		dataSources: dataSources,
+		client:      client,
+		maxRows:     cfg.MaxRows,
+		batchRows:   cfg.BatchRows,
+		security:    security,
+		logger:      logger,
+		audit:       logger.Named("audit"),
+	}
+}
+
+// Export handles POST /api/v1/export/sheets
+func (h *SheetsHandler) Export(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req SheetsExportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	security := h.security()
+	mode, err := h.validate(&req, security)
+	if err != nil {
+		response.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sourceName := strings.ToUpper(req.Source)
+	source, ok := h.dataSources[sourceName]
+	if !ok {
+		response.Error(w, fmt.Sprintf("Unknown data source: %s", sourceName), http.StatusNotFound)
+		return
+	}
+
+	// One row over the limit is fetched to detect oversized results
+	opts := &datasource.QueryOptions{
+		Limit:   h.maxRows + 1,
+		Filters: req.Filters,
+		Timeout: datasource.Defaults(source).Timeout,
+	}
+	var result *datasource.QueryResult
+	query := req.SQL
+	if req.Table != "" {
+		if !security.IsTableAllowed(req.Table, securitySource(source.GetType())) {
+			response.Error(w, fmt.Sprintf("Table %s is not allowed for %s", req.Table, sourceName), http.StatusForbidden)
+			return
+		}
+		query = req.Table
+		result, err = source.GetData(ctx, req.Table, opts)
+	} else {
+		result, err = source.ExecuteQuery(ctx, req.SQL, opts)
+	}
+	if err != nil {
+		h.logger.Error("Sheets export query failed",
+			zap.String("source", sourceName),
+			zap.Error(err))
+		if !writeQueryError(w, err, req.SQL, "Sheets export query failed") {
+			response.ErrorWithDetails(w, "Sheets export query failed", err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	columns := h.columns(&req, security, result.Data)
+	if len(result.Data) > h.maxRows {
+		response.ErrorWithDetails(w, "Result is too large for Google Sheets",
+			fmt.Sprintf("max_rows=%d", h.maxRows), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if cells := (len(result.Data) + 1) * len(columns); cells > sheets.MaxCells {
+		response.ErrorWithDetails(w, "Result is too large for Google Sheets",
+			fmt.Sprintf("cells=%d max_cells=%d", cells, sheets.MaxCells), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	target := sheets.Target{SpreadsheetID: req.SpreadsheetID, Sheet: req.Sheet, Mode: mode}
+	written, err := sheets.Write(ctx, h.client, target, columns, selectFields(result.Data, req.Columns), h.batchRows)
+	if err != nil {
+		h.logger.Error("Sheets export failed",
+			zap.String("spreadsheet_id", req.SpreadsheetID),
+			zap.String("sheet", req.Sheet),
+			zap.Error(err))
+		switch {
+		case errors.Is(err, sheets.ErrAccessDenied):
+			response.ErrorWithDetails(w, "The gateway's service account cannot edit this spreadsheet", err.Error(), http.StatusForbidden)
+		case errors.Is(err, sheets.ErrNotFound):
+			response.ErrorWithDetails(w, "Spreadsheet not found", err.Error(), http.StatusNotFound)
+		case errors.Is(err, sheets.ErrInvalidTarget):
+			response.ErrorWithDetails(w, "Invalid sheet", err.Error(), http.StatusBadRequest)
+		default:
+			response.ErrorWithDetails(w, "Sheets export failed", err.Error(), http.StatusBadGateway)
+		}
+		return
+	}
+
+	h.recordAudit(r, &req, sourceName, query, written)
+
+	response.Success(w, SheetsExportResponse{
+		SpreadsheetID: req.SpreadsheetID,
+		Sheet:         req.Sheet,
+		Mode:          string(mode),
+		Columns:       columns,
+		Written:       *written,
+	}, nil)
+}
+
+// validate checks the shape of req and returns its write mode; table access
+// is checked once the source is known
+func (h *SheetsHandler) validate(req *SheetsExportRequest, security *config.SecurityConfig) (sheets.Mode, error) {
+	if (req.SQL == "") == (req.Table == "") {
+		return "", fmt.Errorf("exactly one of sql and table is required")
+	}
+	if req.SQL != "" && len(req.Filters) > 0 {
+		return "", fmt.Errorf("filters only apply to table")
+	}
+	for column := range req.Filters {
+		if _, ok := security.Column(req.Table, column); !ok {
+			return "", fmt.Errorf("cannot filter on column %q", column)
+		}
+	}
+	if req.SpreadsheetID == "" || req.Sheet == "" {
+		return "", fmt.Errorf("spreadsheet_id and sheet are required")
+	}
+	return sheets.ParseMode(req.Mode)
+}
+
+// columns returns the header of the written sheet
+func (h *SheetsHandler) columns(req *SheetsExportRequest, security *config.SecurityConfig, rows []map[string]interface{}) []string {
+	if len(req.Columns) > 0 {
+		return req.Columns
+	}
+	if declared := security.TableColumns[req.Table]; req.Table != "" && len(declared) > 0 {
+		columns := make([]string, len(declared))
+		for i, c := range declared {
+			columns[i] = c.Name
+		}
+		return columns
+	}
+	if len(rows) == 0 {
+		return []string{}
+	}
+	columns := make([]string, 0, len(rows[0]))
+	for name := range rows[0] {
+		columns = append(columns, name)
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// recordAudit logs the export to the audit logger: who wrote which data to
+// which spreadsheet
+func (h *SheetsHandler) recordAudit(r *http.Request, req *SheetsExportRequest, sourceName, query string, written *sheets.Written) {
+	fields := []zap.Field{
+		zap.String("event", "export.sheets"),
+		zap.String("spreadsheet_id", req.SpreadsheetID),
+		zap.String("sheet", req.Sheet),
+		zap.String("updated_range", written.Range),
+		zap.Int("rows", written.Rows),
+		zap.String("source", sourceName),
+		zap.String("query", query),
+	}
+	if key, ok := auth.KeyFromContext(r.Context()); ok {
+		fields = append(fields, zap.String("api_key_id", key.ID))
+	}
+	if t, ok := tenant.FromContext(r.Context()); ok {
+		fields = append(fields, zap.String("tenant", t.ID))
+	}
+	h.audit.Info("Query result exported to Google Sheets", fields...)
+}
+
+// selectFields resolves the requested columns of rows, which may be paths
+// into record columns, to values keyed by the requested name. Without
+// requested columns rows are returned as they are.
+func selectFields(rows []map[string]interface{}, columns []string) []map[string]interface{} {
+	if len(columns) == 0 {
+		return rows
+	}
+	selected := make([]map[string]interface{}, len(rows))
+	for i, row := range rows {
+		selected[i] = make(map[string]interface{}, len(columns))
+		for _, column := range columns {
+			if v, ok := datasource.FieldValue(row, column); ok {
+				selected[i][column] = v
+			}
+		}
+	}
+	return selected
+}
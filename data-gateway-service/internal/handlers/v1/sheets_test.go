@@ -0,0 +1,186 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"go-data-gateway/internal/auth"
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/sheets"
+)
+
+// mockSheets records writes to a single sheet
+type mockSheets struct {
+	rows    [][]interface{}
+	cleared bool
+	err     error
+}
+
+func (m *mockSheets) Clear(ctx context.Context, spreadsheetID, sheet string) error {
+	m.cleared = true
+	m.rows = nil
+	return m.err
+}
+
+func (m *mockSheets) FirstRow(ctx context.Context, spreadsheetID, sheet string) ([]interface{}, error) {
+	if m.err != nil || len(m.rows) == 0 {
+		return nil, m.err
+	}
+	return m.rows[0], nil
+}
+
+func (m *mockSheets) BatchUpdate(ctx context.Context, spreadsheetID string, data []sheets.ValueRange) error {
+	for _, block := range data {
+		m.rows = append(m.rows, block.Values...)
+	}
+	return m.err
+}
+
+func (m *mockSheets) Append(ctx context.Context, spreadsheetID, sheet string, values [][]interface{}) (string, error) {
+	start := len(m.rows) + 1
+	m.rows = append(m.rows, values...)
+	return fmt.Sprintf("'%s'!A%d:B%d", sheet, start, len(m.rows)), m.err
+}
+
+func newTestSheetsHandler(source datasource.DataSource, client sheets.Client, maxRows int) (*SheetsHandler, *observer.ObservedLogs) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	handler := NewSheetsHandler(map[string]datasource.DataSource{"DATAWAREHOUSE": source}, client,
+		config.SheetsConfig{MaxRows: maxRows, BatchRows: 2}, config.GetDefaultSecurityConfig, zap.New(core))
+	return handler, logs
+}
+
+func postSheets(t *testing.T, handler *SheetsHandler, body string) (*httptest.ResponseRecorder, SheetsExportResponse) {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/export/sheets", bytes.NewBufferString(body))
+	req = req.WithContext(auth.WithKey(req.Context(), &auth.APIKey{ID: "analyst"}))
+	rec := httptest.NewRecorder()
+	handler.Export(rec, req)
+
+	var resp struct {
+		Data SheetsExportResponse `json:"data"`
+	}
+	if rec.Code == http.StatusOK {
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	}
+	return rec, resp.Data
+}
+
+func TestSheetsExport_WritesResult(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio, rows: []map[string]interface{}{
+		{"tender_id": "T1", "nilai_pagu": float64(1000)},
+		{"tender_id": "T2", "nilai_pagu": nil},
+	}}
+	client := &mockSheets{}
+	handler, logs := newTestSheetsHandler(source, client, 100)
+
+	rec, data := postSheets(t, handler, `{"source": "datawarehouse", "sql": "SELECT tender_id, nilai_pagu FROM t",
+		"columns": ["tender_id", "nilai_pagu"], "spreadsheet_id": "abc123", "sheet": "Tenders"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	assert.Equal(t, 101, source.opts.Limit)
+	assert.True(t, client.cleared)
+	assert.Equal(t, "clear", data.Mode)
+	assert.Equal(t, "'Tenders'!A1:B3", data.Range)
+	assert.Equal(t, 2, data.Rows)
+	assert.True(t, data.Header)
+	assert.Equal(t, [][]interface{}{
+		{"tender_id", "nilai_pagu"},
+		{"T1", float64(1000)},
+		{"T2", ""},
+	}, client.rows)
+
+	audit := logs.FilterMessage("Query result exported to Google Sheets").All()
+	require.Len(t, audit, 1)
+	assert.Equal(t, "audit", audit[0].LoggerName)
+	fields := audit[0].ContextMap()
+	assert.Equal(t, "analyst", fields["api_key_id"])
+	assert.Equal(t, "abc123", fields["spreadsheet_id"])
+	assert.Equal(t, int64(2), fields["rows"])
+}
+
+func TestSheetsExport_HeaderFromDeclaredColumns(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio, rows: []map[string]interface{}{
+		{"tender_id": "T1", "nama_paket": "Paket"},
+	}}
+	client := &mockSheets{rows: [][]interface{}{{"existing"}}}
+	handler, _ := newTestSheetsHandler(source, client, 100)
+
+	rec, data := postSheets(t, handler, `{"source": "DATAWAREHOUSE", "table": "nessie_iceberg.tender_data",
+		"spreadsheet_id": "abc123", "sheet": "Tenders", "mode": "append"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	// Appending to a sheet with values adds no header
+	assert.False(t, client.cleared)
+	assert.False(t, data.Header)
+	assert.Equal(t, "tender_id", data.Columns[0])
+	assert.Equal(t, "nama_paket", data.Columns[1])
+	require.Len(t, client.rows, 2)
+	assert.Equal(t, "T1", client.rows[1][0])
+	assert.Equal(t, "Paket", client.rows[1][1])
+}
+
+func TestSheetsExport_ColumnsAddressRecordFields(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio, rows: nestedRows}
+	client := &mockSheets{}
+	handler, _ := newTestSheetsHandler(source, client, 100)
+
+	rec, _ := postSheets(t, handler, `{"source": "DATAWAREHOUSE", "sql": "SELECT * FROM rup",
+		"columns": ["kode_rup", "peserta.email", "lokasi"], "spreadsheet_id": "abc123", "sheet": "RUP"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	assert.Equal(t, [][]interface{}{
+		{"kode_rup", "peserta.email", "lokasi"},
+		{"R1", "maju@example.id", `["Bandung","Bogor"]`},
+	}, client.rows)
+}
+
+func TestSheetsExport_RowCap(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio, rows: rowsOf(4)}
+	client := &mockSheets{}
+	handler, _ := newTestSheetsHandler(source, client, 3)
+
+	rec, _ := postSheets(t, handler, `{"source": "DATAWAREHOUSE", "sql": "SELECT id FROM t",
+		"spreadsheet_id": "abc123", "sheet": "Tenders"}`)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	assert.Contains(t, rec.Body.String(), "max_rows=3")
+	assert.False(t, client.cleared, "nothing is written when the result is too large")
+}
+
+func TestSheetsExport_Errors(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio, rows: rowsOf(1)}
+
+	tests := []struct {
+		name string
+		body string
+		err  error
+		code int
+	}{
+		{"missing sheet", `{"source": "DATAWAREHOUSE", "sql": "SELECT 1", "spreadsheet_id": "abc123"}`, nil, http.StatusBadRequest},
+		{"sql and table", `{"source": "DATAWAREHOUSE", "sql": "SELECT 1", "table": "t", "spreadsheet_id": "a", "sheet": "s"}`, nil, http.StatusBadRequest},
+		{"bad mode", `{"source": "DATAWAREHOUSE", "sql": "SELECT 1", "spreadsheet_id": "a", "sheet": "s", "mode": "merge"}`, nil, http.StatusBadRequest},
+		{"unknown source", `{"source": "MYSQL", "sql": "SELECT 1", "spreadsheet_id": "a", "sheet": "s"}`, nil, http.StatusNotFound},
+		{"table not allowed", `{"source": "DATAWAREHOUSE", "table": "secret", "spreadsheet_id": "a", "sheet": "s"}`, nil, http.StatusForbidden},
+		{"no access", `{"source": "DATAWAREHOUSE", "sql": "SELECT 1", "spreadsheet_id": "a", "sheet": "s"}`, sheets.ErrAccessDenied, http.StatusForbidden},
+		{"no spreadsheet", `{"source": "DATAWAREHOUSE", "sql": "SELECT 1", "spreadsheet_id": "a", "sheet": "s"}`, sheets.ErrNotFound, http.StatusNotFound},
+		{"no sheet", `{"source": "DATAWAREHOUSE", "sql": "SELECT 1", "spreadsheet_id": "a", "sheet": "s"}`, sheets.ErrInvalidTarget, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, logs := newTestSheetsHandler(source, &mockSheets{err: tt.err}, 100)
+			rec, _ := postSheets(t, handler, tt.body)
+			assert.Equal(t, tt.code, rec.Code, rec.Body.String())
+			assert.Zero(t, logs.FilterMessage("Query result exported to Google Sheets").Len())
+		})
+	}
+}
@@ -4,17 +4,19 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"go-data-gateway/pkg/apitypes"
 )
 
 func TestQueryRequest_Validation(t *testing.T) {
 	tests := []struct {
 		name    string
-		req     QueryRequest
+		req     apitypes.QueryRequest
 		isValid bool
 	}{
 		{
 			name: "valid DREMIO request",
-			req: QueryRequest{
+			req: apitypes.QueryRequest{
 				Source: "DREMIO",
 				SQL:    "SELECT * FROM table",
 			},
@@ -22,7 +24,7 @@ func TestQueryRequest_Validation(t *testing.T) {
 		},
 		{
 			name: "valid BIGQUERY request",
-			req: QueryRequest{
+			req: apitypes.QueryRequest{
 				Source: "BIGQUERY",
 				SQL:    "SELECT * FROM dataset.table",
 			},
@@ -30,7 +32,7 @@ func TestQueryRequest_Validation(t *testing.T) {
 		},
 		{
 			name: "invalid source",
-			req: QueryRequest{
+			req: apitypes.QueryRequest{
 				Source: "INVALID",
 				SQL:    "SELECT * FROM table",
 			},
@@ -38,7 +40,7 @@ func TestQueryRequest_Validation(t *testing.T) {
 		},
 		{
 			name: "empty SQL",
-			req: QueryRequest{
+			req: apitypes.QueryRequest{
 				Source: "DREMIO",
 				SQL:    "",
 			},
@@ -0,0 +1,81 @@
+package v1
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/response"
+)
+
+// SourcesHandler lists the data sources requests may name and the query
+// defaults each applies
+type SourcesHandler struct {
+	dataSources map[string]datasource.DataSource
+	ceilings    config.QueryCeilings
+}
+
+// NewSourcesHandler creates a data source listing handler
+func NewSourcesHandler(dataSources map[string]datasource.DataSource, ceilings config.QueryCeilings) *SourcesHandler {
+	return &SourcesHandler{
+		dataSources: dataSources,
+		ceilings:    ceilings,
+	}
+}
+
+// SourceList is the body of GET /api/v1/sources
+type SourceList struct {
+	Sources []SourceInfo `json:"sources"`
+
+	// The largest cache_ttl_seconds and timeout_seconds a query may ask for
+	MaxCacheTTLSeconds int `json:"max_cache_ttl_seconds"`
+	MaxTimeoutSeconds  int `json:"max_timeout_seconds"`
+}
+
+// SourceInfo describes one data source
+type SourceInfo struct {
+	Name     string                    `json:"name"`
+	Type     datasource.DataSourceType `json:"type"`
+	Defaults SourceDefaults            `json:"defaults"`
+}
+
+// SourceDefaults are the effective query defaults of a source: its own, or
+// the global ones where it sets none
+type SourceDefaults struct {
+	CacheTTLSeconds int    `json:"cache_ttl_seconds"`
+	TimeoutSeconds  int    `json:"timeout_seconds"`
+	MaxRows         int    `json:"max_rows,omitempty"`   // Rows a request may ask for; absent leaves them to the endpoint
+	Tiebreaker      string `json:"tiebreaker,omitempty"` // Column pages are ordered by last when their table has no tiebreaker
+}
+
+// List handles GET /api/v1/sources
+func (h *SourcesHandler) List(w http.ResponseWriter, r *http.Request) {
+	list := SourceList{
+		Sources:            make([]SourceInfo, 0, len(h.dataSources)),
+		MaxCacheTTLSeconds: wholeSeconds(h.ceilings.MaxCacheTTL),
+		MaxTimeoutSeconds:  wholeSeconds(h.ceilings.MaxTimeout),
+	}
+	for name, source := range h.dataSources {
+		defaults := datasource.Defaults(source)
+		list.Sources = append(list.Sources, SourceInfo{
+			Name: name,
+			Type: source.GetType(),
+			Defaults: SourceDefaults{
+				CacheTTLSeconds: wholeSeconds(defaults.CacheTTL),
+				TimeoutSeconds:  wholeSeconds(defaults.Timeout),
+				MaxRows:         defaults.MaxRows,
+				Tiebreaker:      defaults.Tiebreaker,
+			},
+		})
+	}
+	sort.Slice(list.Sources, func(i, j int) bool { return list.Sources[i].Name < list.Sources[j].Name })
+
+	response.Success(w, list, nil)
+}
+
+// wholeSeconds returns d in seconds, rounded down
+func wholeSeconds(d time.Duration) int {
+	return int(d / time.Second)
+}
@@ -0,0 +1,128 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/pkg/apitypes"
+)
+
+// defaultedSource is a recordingSource with configured query defaults
+type defaultedSource struct {
+	recordingSource
+	defaults config.QueryDefaults
+}
+
+func (s *defaultedSource) QueryDefaults() config.QueryDefaults { return s.defaults }
+
+func TestQuery_DefaultsPrecedence(t *testing.T) {
+	bigQuery := &defaultedSource{
+		recordingSource: recordingSource{sourceType: datasource.DataSourceBigQuery, rows: rowsOf(3)},
+		defaults:        config.QueryDefaults{CacheTTL: 15 * time.Minute, Timeout: time.Minute, MaxRows: 2},
+	}
+	dremio := &recordingSource{sourceType: datasource.DataSourceDremio, rows: rowsOf(3)}
+	handler := NewQueryHandler(map[string]datasource.DataSource{"BIGQUERY": bigQuery, "DATAWAREHOUSE": dremio},
+		testLimits, nil, false, zap.NewNop())
+	handler.SetQueryCeilings(config.QueryCeilings{MaxCacheTTL: time.Hour, MaxTimeout: 2 * time.Minute})
+
+	query := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.Execute(rec, httptest.NewRequest(http.MethodPost, "/api/v1/query", bytes.NewBufferString(body)))
+		return rec
+	}
+
+	// Global defaults for a source without its own
+	rec := query(`{"sql": "SELECT 1", "source": "DATAWAREHOUSE"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, 5*time.Minute, dremio.opts.CacheTTL)
+	assert.Equal(t, 30*time.Second, dremio.opts.Timeout)
+
+	// The source's defaults over the global ones
+	rec = query(`{"sql": "SELECT 1", "source": "BIGQUERY"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, 15*time.Minute, bigQuery.opts.CacheTTL)
+	assert.Equal(t, time.Minute, bigQuery.opts.Timeout)
+	assert.Equal(t, 2, decodeResponse(t, rec).Meta.Limit, "max_rows caps the default limit")
+
+	// The request's over both, within the ceilings
+	rec = query(`{"sql": "SELECT 1", "source": "BIGQUERY", "cache_ttl_seconds": 60, "timeout_seconds": 120}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, time.Minute, bigQuery.opts.CacheTTL)
+	assert.Equal(t, 2*time.Minute, bigQuery.opts.Timeout)
+	rec = query(`{"sql": "SELECT 1", "source": "DATAWAREHOUSE", "timeout_seconds": 90}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, 90*time.Second, dremio.opts.Timeout)
+	assert.Equal(t, 5*time.Minute, dremio.opts.CacheTTL)
+
+	bigQuery.opts = nil
+	rec = query(`{"sql": "SELECT 1", "source": "BIGQUERY", "limit": 3, "cache_ttl_seconds": 3601, "timeout_seconds": 0}`)
+	assert.Equal(t, []apitypes.Violation{
+		{Field: "limit", Message: "limit must not exceed 2", Constraint: "max=2"},
+		{Field: "cache_ttl_seconds", Message: "cache_ttl_seconds must not exceed 3600", Constraint: "max=3600"},
+		{Field: "timeout_seconds", Message: "timeout_seconds must be positive", Constraint: "min=1"},
+	}, violationsOf(t, rec))
+	assert.Nil(t, bigQuery.opts)
+}
+
+func TestTableRows_SourceDefaults(t *testing.T) {
+	source := &defaultedSource{
+		recordingSource: recordingSource{sourceType: datasource.DataSourceDremio, rows: rowsOf(2)},
+		defaults:        config.QueryDefaults{CacheTTL: time.Minute, Timeout: 10 * time.Second, MaxRows: 20, Tiebreaker: "id"},
+	}
+	handler := NewTableHandler(map[string]datasource.DataSource{"DATAWAREHOUSE": source}, testLimits, config.GetDefaultSecurityConfig, zap.NewNop())
+	handler.SetTiebreakers(config.Tiebreakers{})
+	router := chi.NewRouter()
+	router.Get("/sources/{source}/tables/{table}/rows", handler.Rows)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/sources/datawarehouse/tables/nessie_iceberg.tender_data/rows", nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, time.Minute, source.opts.CacheTTL)
+	assert.Equal(t, 10*time.Second, source.opts.Timeout)
+	assert.Equal(t, "id", source.opts.Tiebreaker, "the source's tiebreaker for a table without one")
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/sources/datawarehouse/tables/nessie_iceberg.tender_data/rows?limit=21", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestSources_List(t *testing.T) {
+	sources := map[string]datasource.DataSource{
+		"DATAWAREHOUSE": &recordingSource{sourceType: datasource.DataSourceDremio},
+		"BIGQUERY": &defaultedSource{
+			recordingSource: recordingSource{sourceType: datasource.DataSourceBigQuery},
+			defaults:        config.QueryDefaults{CacheTTL: 15 * time.Minute, Timeout: time.Minute, MaxRows: 5000},
+		},
+	}
+	handler := NewSourcesHandler(sources, config.DefaultQueryCeilings())
+
+	rec := httptest.NewRecorder()
+	handler.List(rec, httptest.NewRequest(http.MethodGet, "/api/v1/sources", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var body struct {
+		Data SourceList `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, SourceList{
+		Sources: []SourceInfo{
+			{Name: "BIGQUERY", Type: datasource.DataSourceBigQuery,
+				Defaults: SourceDefaults{CacheTTLSeconds: 900, TimeoutSeconds: 60, MaxRows: 5000}},
+			{Name: "DATAWAREHOUSE", Type: datasource.DataSourceDremio,
+				Defaults: SourceDefaults{CacheTTLSeconds: 300, TimeoutSeconds: 30}},
+		},
+		MaxCacheTTLSeconds: 3600,
+		MaxTimeoutSeconds:  300,
+	}, body.Data)
+}
@@ -6,33 +6,52 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/csvfmt"
 	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/inflight"
+	"go-data-gateway/internal/jsonrows"
+	"go-data-gateway/internal/response"
+	"go-data-gateway/internal/spill"
+	"go-data-gateway/pkg/apitypes"
 	"go.uber.org/zap"
 )
 
-// StreamRequest represents a streaming query request
-type StreamRequest struct {
-	Query      string                   `json:"query,omitempty"`
-	DataSource string                   `json:"data_source"`
-	Table      string                   `json:"table,omitempty"`
-	ChunkSize  int                      `json:"chunk_size,omitempty"`
-	Format     string                   `json:"format,omitempty"` // json, ndjson, csv
-	Options    *datasource.QueryOptions `json:"options,omitempty"`
+// streamContentTypes are the formats of POST /api/v1/stream
+var streamContentTypes = map[string]string{
+	"json":   "application/json",
+	"ndjson": "application/x-ndjson",
+	"csv":    "text/csv",
 }
 
 // StreamHandler handles streaming responses for large datasets
 type StreamHandler struct {
 	dataSources map[string]datasource.DataSource
+	limits      config.PageLimit // chunk_size policy
+	spills      *spill.Store     // nil when spilling is disabled
+	autoLimit   autoLimit
+	tiebreakers config.Tiebreakers
 	logger      *zap.Logger
 }
 
+// SetAutoLimit injects LIMIT limit into raw queries without a top-level LIMIT
+func (h *StreamHandler) SetAutoLimit(limit int) {
+	h.autoLimit = autoLimit(limit)
+}
+
+// sseDefaultChunkSize keeps SSE events small; the policy maximum still applies
+const sseDefaultChunkSize = 100
+
 // NewStreamHandler creates a new stream handler
-func NewStreamHandler(dataSources map[string]datasource.DataSource, logger *zap.Logger) *StreamHandler {
+func NewStreamHandler(dataSources map[string]datasource.DataSource, limits config.PageLimit, logger *zap.Logger) *StreamHandler {
 	return &StreamHandler{
 		dataSources: dataSources,
+		limits:      limits,
 		logger:      logger,
 	}
 }
@@ -42,48 +61,58 @@ func (h *StreamHandler) Stream(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
 	// Parse request
-	var req StreamRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.logger.Error("Failed to parse stream request", zap.Error(err))
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	var req apitypes.StreamRequest
+	if !decodeBounded(w, r, &req) {
 		return
 	}
 
 	// Validate and set defaults
-	if req.ChunkSize <= 0 {
-		req.ChunkSize = 1000
-	}
-	if req.ChunkSize > 10000 {
-		req.ChunkSize = 10000
-	}
 	if req.Format == "" {
 		req.Format = "ndjson"
 	}
-
-	// Get data source
-	dataSource, exists := h.dataSources[req.DataSource]
-	if !exists {
-		http.Error(w, fmt.Sprintf("Unknown data source: %s", req.DataSource), http.StatusBadRequest)
+	v := h.validateStream(req)
+	req.ChunkSize = v.limit("chunk_size", req.ChunkSize, h.limits)
+	contentType, ok := streamContentTypes[req.Format]
+	if !ok {
+		v.add("format", "oneof=json ndjson csv", "format must be json, ndjson or csv")
+	}
+	v.addErr("spill", "oneof=sync async", h.validateSpill(req))
+	v.addErr("resume_token", "resume_token", h.orderStream(&req))
+	var formatter *csvfmt.Formatter
+	if req.Format == "csv" && req.CSV != nil && !req.CSV.IsZero() {
+		columns := config.ActiveSecurityConfig().TableColumns[req.Table]
+		f, err := csvfmt.New(*req.CSV, columns)
+		if err != nil {
+			v.add("csv", "csv", "invalid csv options: %v", err)
+		}
+		formatter = f
+	}
+	if v.write(w) {
 		return
 	}
 
-	// Set appropriate headers based on format
-	switch req.Format {
-	case "json":
-		w.Header().Set("Content-Type", "application/json")
-	case "ndjson":
-		w.Header().Set("Content-Type", "application/x-ndjson")
-	case "csv":
-		w.Header().Set("Content-Type", "text/csv")
-	default:
-		http.Error(w, "Unsupported format", http.StatusBadRequest)
+	dataSource := h.dataSources[req.DataSource]
+	describeStream(ctx, req)
+
+	// Rows are written after a 200, so a source still initializing must be
+	// reported first
+	if writeUnavailableError(w, datasource.CheckReady(r.Context(), dataSource)) {
 		return
 	}
 
-	// Set streaming headers
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Transfer-Encoding", "chunked")
-	w.Header().Set("X-Content-Type-Options", "nosniff")
+	// A raw query without a LIMIT runs with the automatic one
+	submitted := req.Query
+	var injected int
+	req.Query, injected = h.autoLimit.apply(ctx, req.Query, dataSource.GetType().Dialect())
+	if injected > 0 {
+		w.Header().Set(HeaderLimitInjected, strconv.Itoa(injected))
+	}
+
+	warnings := limitWarnings(injected)
+	if req.Spill != "" {
+		h.spillStream(w, r, dataSource, req, formatter, warnings)
+		return
+	}
 
 	// Create flusher for streaming
 	flusher, ok := w.(http.Flusher)
@@ -92,82 +121,102 @@ func (h *StreamHandler) Stream(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Stream data based on format
+	dataSource, err := h.prefetch(ctx, dataSource, req)
+	if err != nil {
+		writeStreamError(w, unshiftLimit(err, injected), submitted)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+
+	// Set streaming headers
+	w.Header().Set("X-Chunk-Size", strconv.Itoa(req.ChunkSize))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Transfer-Encoding", "chunked")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	// Row count, checksum and resume position are only known once the body
+	// is written
+	trailers := TrailerRowCount + ", " + TrailerSHA256
+	if req.Table != "" && req.Options.Tiebreaker != "" {
+		trailers += ", " + TrailerResumeToken
+	}
+	w.Header().Set("Trailer", trailers)
+
+	totals := h.writeStream(ctx, newChecksumWriter(w), flusher, dataSource, req, formatter, warnings)
+
+	w.Header().Set(TrailerRowCount, strconv.Itoa(totals.Rows))
+	w.Header().Set(TrailerSHA256, totals.SHA256)
+	if totals.ResumeToken != "" {
+		w.Header().Set(TrailerResumeToken, totals.ResumeToken)
+	}
+}
+
+// describeStream records the source and the query, or table, of the stream
+// on its in-flight operation
+func describeStream(ctx context.Context, req apitypes.StreamRequest) {
+	sql := req.Query
+	if sql == "" {
+		sql = req.Table
+	}
+	inflight.FromContext(ctx).Describe(req.DataSource, sql)
+}
+
+// prefetch reads the first chunk of the stream before any of the response is
+// written, so a query that fails at once responds with its error status.
+// Later failures can only be reported in the body.
+func (h *StreamHandler) prefetch(ctx context.Context, dataSource datasource.DataSource, req apitypes.StreamRequest) (datasource.DataSource, error) {
+	prefetched, err := datasource.Prefetch(ctx, dataSource, req.Query, req.Table, req.ChunkSize, req.Options)
+	if err != nil {
+		h.logger.Error("Stream query failed before streaming",
+			zap.String("data_source", req.DataSource),
+			zap.Error(err))
+	}
+	return prefetched, err
+}
+
+// writeStream writes the result in the request's format. The warnings are
+// sent in the NDJSON summary; other formats have no place for them.
+func (h *StreamHandler) writeStream(ctx context.Context, out *checksumWriter, flusher http.Flusher,
+	dataSource datasource.DataSource, req apitypes.StreamRequest, formatter *csvfmt.Formatter, warnings []response.Warning) streamTotals {
+
 	switch req.Format {
 	case "json":
-		h.streamJSON(ctx, w, flusher, dataSource, req)
-	case "ndjson":
-		h.streamNDJSON(ctx, w, flusher, dataSource, req)
+		return h.streamJSON(ctx, out, flusher, dataSource, req)
 	case "csv":
-		h.streamCSV(ctx, w, flusher, dataSource, req)
+		return h.streamCSV(ctx, out, flusher, dataSource, req, formatter)
+	default:
+		return h.streamNDJSON(ctx, out, flusher, dataSource, req, warnings)
 	}
 }
 
 // streamJSON streams data in JSON array format
-func (h *StreamHandler) streamJSON(ctx context.Context, w io.Writer, flusher http.Flusher,
-	dataSource datasource.DataSource, req StreamRequest) {
+func (h *StreamHandler) streamJSON(ctx context.Context, w *checksumWriter, flusher http.Flusher,
+	dataSource datasource.DataSource, req apitypes.StreamRequest) streamTotals {
 
 	// Write opening bracket
 	w.Write([]byte("[\n"))
 	flusher.Flush()
 
-	offset := 0
-	firstChunk := true
-	totalRows := 0
-
-	for {
-		// Check context
-		if ctx.Err() != nil {
-			break
-		}
-
-		// Prepare query options with pagination
-		opts := &datasource.QueryOptions{
-			Limit:  req.ChunkSize,
-			Offset: offset,
-		}
-		if req.Options != nil {
-			opts.OrderBy = req.Options.OrderBy
-			opts.OrderDir = req.Options.OrderDir
-		}
-
-		// Execute query for this chunk
-		var result *datasource.QueryResult
-		var err error
-
-		if req.Query != "" {
-			result, err = dataSource.ExecuteQuery(ctx, req.Query, opts)
-		} else if req.Table != "" {
-			result, err = dataSource.GetData(ctx, req.Table, opts)
-		} else {
-			break
-		}
-
-		if err != nil {
-			h.logger.Error("Stream query failed", zap.Error(err))
-			break
-		}
+	firstRow := true
+	var last map[string]interface{}
 
-		// Write results
-		for i, row := range result.Data {
-			if !firstChunk || i > 0 {
-				w.Write([]byte(",\n"))
+	totalRows, err := datasource.FetchChunks(ctx, dataSource, req.Query, req.Table, req.ChunkSize, req.Options,
+		func(rows []map[string]interface{}) error {
+			for _, row := range rows {
+				if !firstRow {
+					w.Write([]byte(",\n"))
+				}
+				jsonData, _ := json.Marshal(row)
+				w.Write([]byte("  "))
+				w.Write(jsonData)
+				firstRow = false
 			}
-			jsonData, _ := json.Marshal(row)
-			w.Write([]byte("  "))
-			w.Write(jsonData)
-			firstChunk = false
-			totalRows++
-		}
-
-		flusher.Flush()
-
-		// Check if we got less than chunk size (end of data)
-		if len(result.Data) < req.ChunkSize {
-			break
-		}
-
-		offset += req.ChunkSize
+			flusher.Flush()
+			last = rows[len(rows)-1]
+			return w.Err()
+		})
+	if err != nil && ctx.Err() == nil {
+		h.logger.Error("Stream query failed", zap.Error(err))
 	}
 
 	// Write closing bracket
@@ -177,94 +226,74 @@ func (h *StreamHandler) streamJSON(ctx context.Context, w io.Writer, flusher htt
 	h.logger.Info("JSON streaming completed",
 		zap.Int("total_rows", totalRows),
 		zap.String("data_source", req.DataSource))
+	return streamTotals{Rows: totalRows, SHA256: w.Sum(), Err: err, ResumeToken: resumeTokenAfter(req, last)}
 }
 
-// streamNDJSON streams data in newline-delimited JSON format
-func (h *StreamHandler) streamNDJSON(ctx context.Context, w io.Writer, flusher http.Flusher,
-	dataSource datasource.DataSource, req StreamRequest) {
+// streamNDJSON streams data in newline-delimited JSON format. The summary
+// line carries the row count, the checksum of every line before it, the
+// warnings if any and, when the stream can be resumed, the resume token
+// after its last row.
+func (h *StreamHandler) streamNDJSON(ctx context.Context, w *checksumWriter, flusher http.Flusher,
+	dataSource datasource.DataSource, req apitypes.StreamRequest, warnings []response.Warning) streamTotals {
 
-	offset := 0
 	totalRows := 0
 	startTime := time.Now()
+	enc := jsonrows.NewEncoder()
+	var line []byte
+	var last map[string]interface{}
+
+	_, err := datasource.FetchChunks(ctx, dataSource, req.Query, req.Table, req.ChunkSize, req.Options,
+		func(rows []map[string]interface{}) error {
+			for _, row := range rows {
+				line, _ = enc.AppendRow(line[:0], row)
+				w.Write(append(line, '\n'))
+				totalRows++
 
-	for {
-		// Check context
-		if ctx.Err() != nil {
-			break
-		}
-
-		// Prepare query options with pagination
-		opts := &datasource.QueryOptions{
-			Limit:  req.ChunkSize,
-			Offset: offset,
-		}
-		if req.Options != nil {
-			opts.OrderBy = req.Options.OrderBy
-			opts.OrderDir = req.Options.OrderDir
-		}
-
-		// Execute query for this chunk
-		var result *datasource.QueryResult
-		var err error
-
-		if req.Query != "" {
-			result, err = dataSource.ExecuteQuery(ctx, req.Query, opts)
-		} else if req.Table != "" {
-			result, err = dataSource.GetData(ctx, req.Table, opts)
-		} else {
-			break
-		}
-
-		if err != nil {
-			// Write error as NDJSON
-			errorObj := map[string]string{
-				"error": err.Error(),
-				"type":  "error",
+				// Flush every 100 rows for responsiveness
+				if totalRows%100 == 0 {
+					flusher.Flush()
+				}
 			}
-			jsonData, _ := json.Marshal(errorObj)
-			w.Write(jsonData)
-			w.Write([]byte("\n"))
-			flusher.Flush()
-			break
-		}
 
-		// Write results
-		for _, row := range result.Data {
-			jsonData, _ := json.Marshal(row)
-			w.Write(jsonData)
-			w.Write([]byte("\n"))
-			totalRows++
-
-			// Flush every 100 rows for responsiveness
-			if totalRows%100 == 0 {
-				flusher.Flush()
-			}
+			// Final flush for this chunk
+			flusher.Flush()
+			last = rows[len(rows)-1]
+
+			// Log progress
+			h.logger.Debug("Streamed chunk",
+				zap.Int("chunk_rows", len(rows)),
+				zap.Int("total_rows", totalRows),
+				zap.Duration("elapsed", time.Since(startTime)))
+			return w.Err()
+		})
+	if err != nil && ctx.Err() == nil && w.Err() == nil {
+		// Write error as NDJSON
+		errorObj := map[string]string{
+			"error": err.Error(),
+			"type":  "error",
 		}
-
-		// Final flush for this chunk
+		jsonData, _ := json.Marshal(errorObj)
+		w.Write(jsonData)
+		w.Write([]byte("\n"))
 		flusher.Flush()
-
-		// Log progress
-		h.logger.Debug("Streamed chunk",
-			zap.Int("chunk_rows", len(result.Data)),
-			zap.Int("total_rows", totalRows),
-			zap.Duration("elapsed", time.Since(startTime)))
-
-		// Check if we got less than chunk size (end of data)
-		if len(result.Data) < req.ChunkSize {
-			break
-		}
-
-		offset += req.ChunkSize
 	}
 
 	// Write summary as final NDJSON line
+	totals := streamTotals{Rows: totalRows, SHA256: w.Sum(), Err: err, ResumeToken: resumeTokenAfter(req, last)}
 	summary := map[string]interface{}{
 		"type":       "summary",
 		"total_rows": totalRows,
+		"sha256":     totals.SHA256,
+		"chunk_size": req.ChunkSize,
 		"duration":   time.Since(startTime).Milliseconds(),
 		"timestamp":  time.Now(),
 	}
+	if totals.ResumeToken != "" {
+		summary["resume_token"] = totals.ResumeToken
+	}
+	if len(warnings) > 0 {
+		summary["warnings"] = warnings
+	}
 	jsonData, _ := json.Marshal(summary)
 	w.Write(jsonData)
 	w.Write([]byte("\n"))
@@ -274,100 +303,87 @@ func (h *StreamHandler) streamNDJSON(ctx context.Context, w io.Writer, flusher h
 		zap.Int("total_rows", totalRows),
 		zap.Duration("duration", time.Since(startTime)),
 		zap.String("data_source", req.DataSource))
+	return totals
 }
 
-// streamCSV streams data in CSV format
-func (h *StreamHandler) streamCSV(ctx context.Context, w io.Writer, flusher http.Flusher,
-	dataSource datasource.DataSource, req StreamRequest) {
-
-	offset := 0
-	totalRows := 0
-	headerWritten := false
-
-	for {
-		// Check context
-		if ctx.Err() != nil {
-			break
-		}
-
-		// Prepare query options with pagination
-		opts := &datasource.QueryOptions{
-			Limit:  req.ChunkSize,
-			Offset: offset,
-		}
-		if req.Options != nil {
-			opts.OrderBy = req.Options.OrderBy
-			opts.OrderDir = req.Options.OrderDir
-		}
-
-		// Execute query for this chunk
-		var result *datasource.QueryResult
-		var err error
-
-		if req.Query != "" {
-			result, err = dataSource.ExecuteQuery(ctx, req.Query, opts)
-		} else if req.Table != "" {
-			result, err = dataSource.GetData(ctx, req.Table, opts)
-		} else {
-			break
+// streamCSV streams data in CSV format, formatting values with formatter
+// when it is not nil. A resumed stream continues the earlier one's file, so
+// it has no BOM or header of its own.
+func (h *StreamHandler) streamCSV(ctx context.Context, w *checksumWriter, flusher http.Flusher,
+	dataSource datasource.DataSource, req apitypes.StreamRequest, formatter *csvfmt.Formatter) streamTotals {
+
+	var headers []string
+	var last map[string]interface{}
+	resumed := req.ResumeToken != ""
+	delimiter := ','
+	if formatter != nil {
+		delimiter = formatter.Delimiter()
+		if formatter.BOM() && !resumed {
+			io.WriteString(w, csvfmt.BOM)
 		}
+	}
 
-		if err != nil {
-			h.logger.Error("Stream query failed", zap.Error(err))
-			break
-		}
+	totalRows, err := datasource.FetchChunks(ctx, dataSource, req.Query, req.Table, req.ChunkSize, req.Options,
+		func(rows []map[string]interface{}) error {
+			if formatter != nil && formatter.Flatten() {
+				flat := make([]map[string]interface{}, len(rows))
+				for i, row := range rows {
+					flat[i] = csvfmt.FlattenRow(row)
+				}
+				rows = flat
+			}
 
-		// Write CSV
-		if len(result.Data) > 0 {
-			// Write header on first chunk
-			if !headerWritten {
-				headers := make([]string, 0)
-				for key := range result.Data[0] {
+			// Write header on first chunk, sorted so flattened record fields
+			// stay next to each other
+			if headers == nil {
+				headers = make([]string, 0, len(rows[0]))
+				for key := range rows[0] {
 					headers = append(headers, key)
 				}
-				h.writeCSVRow(w, headers)
-				headerWritten = true
+				sort.Strings(headers)
+				if !resumed {
+					h.writeCSVRow(w, headers, delimiter)
+				}
 			}
 
-			// Write data rows
-			for _, row := range result.Data {
-				values := make([]string, 0)
-				for key := range result.Data[0] { // Use same key order as header
+			// Write data rows using the header's key order
+			for _, row := range rows {
+				values := make([]string, 0, len(headers))
+				for _, key := range headers {
 					value := ""
-					if v, ok := row[key]; ok {
-						value = fmt.Sprintf("%v", v)
+					if formatter != nil {
+						value = formatter.Value(key, row[key])
+					} else {
+						value = csvfmt.Text(row[key])
 					}
 					values = append(values, value)
 				}
-				h.writeCSVRow(w, values)
-				totalRows++
+				h.writeCSVRow(w, values, delimiter)
 			}
 
 			flusher.Flush()
-		}
-
-		// Check if we got less than chunk size (end of data)
-		if len(result.Data) < req.ChunkSize {
-			break
-		}
-
-		offset += req.ChunkSize
+			last = rows[len(rows)-1]
+			return w.Err()
+		})
+	if err != nil && ctx.Err() == nil {
+		h.logger.Error("Stream query failed", zap.Error(err))
 	}
 
 	h.logger.Info("CSV streaming completed",
 		zap.Int("total_rows", totalRows),
 		zap.String("data_source", req.DataSource))
+	return streamTotals{Rows: totalRows, SHA256: w.Sum(), Err: err, ResumeToken: resumeTokenAfter(req, last)}
 }
 
 // writeCSVRow writes a CSV row
-func (h *StreamHandler) writeCSVRow(w io.Writer, values []string) {
+func (h *StreamHandler) writeCSVRow(w io.Writer, values []string, delimiter rune) {
 	for i, value := range values {
 		if i > 0 {
-			w.Write([]byte(","))
+			w.Write([]byte(string(delimiter)))
 		}
-		// Simple CSV escaping (should use encoding/csv for production)
-		if needsQuoting(value) {
-			w.Write([]byte(strconv.Quote(value)))
+		// Quotes are doubled, as in RFC 4180; JSON-encoded cells are full of them
+		if needsQuoting(value, delimiter) {
+			w.Write([]byte(`"` + strings.ReplaceAll(value, `"`, `""`) + `"`))
 		} else {
 			w.Write([]byte(value))
 		}
@@ -376,67 +392,142 @@ func (h *StreamHandler) writeCSVRow(w io.Writer, values []string) {
 }
 
 // needsQuoting checks if a CSV value needs quoting
-func needsQuoting(s string) bool {
+func needsQuoting(s string, delimiter rune) bool {
 	for _, c := range s {
-		if c == ',' || c == '"' || c == '\n' || c == '\r' {
+		if c == delimiter || c == '"' || c == '\n' || c == '\r' {
 			return true
 		}
 	}
 	return false
 }
 
+// validateStream collects the violations of the fields both stream endpoints
+// share: the data source, the query or table and its options
+func (h *StreamHandler) validateStream(req apitypes.StreamRequest) violations {
+	var v violations
+	v.source("data_source", req.DataSource, sourceNames(h.dataSources))
+	if req.Query == "" && req.Table == "" {
+		v.add("query", "required_without=table", "either query or table is required")
+	}
+	if len(req.Query) > maxQueryLength {
+		v = append(v, queryTooLong("query"))
+	}
+	if err := validateStreamOrder(req); err != nil {
+		v.add("options", "ordering", "invalid ordering: %v", err)
+	}
+	if err := validateStreamFilters(req); err != nil {
+		v.add("options", "filters", "invalid filters: %v", err)
+	}
+	return v
+}
+
+// validateStreamFilters rejects filters before the response is committed:
+// invalid ones would only fail mid-stream, and a raw query ignores them
+func validateStreamFilters(req apitypes.StreamRequest) error {
+	if req.Options == nil || len(req.Options.Filters) == 0 {
+		return nil
+	}
+	if req.Table == "" {
+		return fmt.Errorf("filters apply to table streams only")
+	}
+	_, err := datasource.NewSQLSanitizer().BuildWhereClause(req.Options.Filters)
+	return err
+}
+
+// validateStreamOrder rejects ordering a raw query, which GetData alone
+// applies, and invalid ordering of a table before the response is committed
+func validateStreamOrder(req apitypes.StreamRequest) error {
+	if req.Options == nil || (req.Options.OrderBy == "" && req.Options.OrderDir == "") {
+		return nil
+	}
+	if req.Table == "" {
+		return fmt.Errorf("OrderBy and OrderDir apply to table streams only; put ORDER BY in the query")
+	}
+	sanitizer := datasource.NewSQLSanitizer()
+	if req.Options.OrderBy != "" {
+		if _, err := sanitizer.ValidateColumnName(req.Options.OrderBy); err != nil {
+			return err
+		}
+	}
+	_, err := sanitizer.ValidateOrderDirection(req.Options.OrderDir)
+	return err
+}
+
 // StreamSSE handles Server-Sent Events streaming
 func (h *StreamHandler) StreamSSE(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	// Set SSE headers
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("X-Accel-Buffering", "no")
+	// Errors before the first event are plain JSON responses with their
+	// status; once it is sent they are reported as error events
 
 	// Parse request
-	var req StreamRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.sendSSEError(w, "Failed to parse request")
+	var req apitypes.StreamRequest
+	if !decodeBounded(w, r, &req) {
 		return
 	}
 
 	// Create flusher
 	flusher, ok := w.(http.Flusher)
 	if !ok {
-		h.sendSSEError(w, "Streaming not supported")
+		response.Error(w, "Streaming not supported", http.StatusInternalServerError)
 		return
 	}
 
 	// Set defaults
-	if req.ChunkSize <= 0 {
-		req.ChunkSize = 100
+	if req.ChunkSize == 0 {
+		req.ChunkSize = min(sseDefaultChunkSize, h.limits.Max)
+	}
+	v := h.validateStream(req)
+	v.limit("chunk_size", req.ChunkSize, h.limits)
+	v.addErr("resume_token", "resume_token", h.orderStream(&req))
+	if v.write(w) {
+		return
 	}
 
-	// Get data source
-	dataSource, exists := h.dataSources[req.DataSource]
-	if !exists {
-		h.sendSSEError(w, fmt.Sprintf("Unknown data source: %s", req.DataSource))
+	dataSource := h.dataSources[req.DataSource]
+	describeStream(ctx, req)
+	if writeUnavailableError(w, datasource.CheckReady(ctx, dataSource)) {
+		return
+	}
+	submitted := req.Query
+	var injected int
+	req.Query, injected = h.autoLimit.apply(ctx, req.Query, dataSource.GetType().Dialect())
+	dataSource, err := h.prefetch(ctx, dataSource, req)
+	if err != nil {
+		writeStreamError(w, unshiftLimit(err, injected), submitted)
 		return
 	}
 
+	// Set SSE headers
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	// The complete event carries the checksum of every event before it
+	out := newChecksumWriter(w)
+
 	// Send initial event
-	h.sendSSEEvent(w, "start", map[string]interface{}{
+	start := map[string]interface{}{
 		"data_source": req.DataSource,
 		"chunk_size":  req.ChunkSize,
 		"timestamp":   time.Now(),
-	})
+	}
+	if injected > 0 {
+		start["limit_injected"], start["injected_limit"] = true, injected
+	}
+	h.sendSSEEvent(out, "start", start)
 	flusher.Flush()
 
 	offset := 0
 	totalRows := 0
 	startTime := time.Now()
+	op := inflight.FromContext(ctx)
 
 	for {
 		// Check context
 		if ctx.Err() != nil {
-			h.sendSSEEvent(w, "abort", map[string]string{"reason": "Context cancelled"})
+			h.sendSSEEvent(out, "abort", map[string]string{"reason": "Context cancelled"})
 			flusher.Flush()
 			break
 		}
@@ -446,6 +537,13 @@ func (h *StreamHandler) StreamSSE(w http.ResponseWriter, r *http.Request) {
 			Limit:  req.ChunkSize,
 			Offset: offset,
 		}
+		if req.Options != nil {
+			opts.OrderBy = req.Options.OrderBy
+			opts.OrderDir = req.Options.OrderDir
+			opts.Tiebreaker = req.Options.Tiebreaker
+			opts.After = req.Options.After
+			opts.Filters = req.Options.Filters
+		}
 
 		// Execute query
 		var result *datasource.QueryResult
@@ -460,14 +558,15 @@ func (h *StreamHandler) StreamSSE(w http.ResponseWriter, r *http.Request) {
 		}
 
 		if err != nil {
-			h.sendSSEEvent(w, "error", map[string]string{"error": err.Error()})
+			h.sendSSEEvent(out, "error", map[string]string{"error": err.Error()})
 			flusher.Flush()
 			break
 		}
 
 		// Send data chunk
 		if len(result.Data) > 0 {
-			h.sendSSEEvent(w, "data", map[string]interface{}{
+			op.SetPhase(inflight.PhaseStreaming)
+			h.sendSSEEvent(out, "data", map[string]interface{}{
 				"rows":       result.Data,
 				"chunk_size": len(result.Data),
 				"offset":     offset,
@@ -475,13 +574,20 @@ func (h *StreamHandler) StreamSSE(w http.ResponseWriter, r *http.Request) {
 			})
 			flusher.Flush()
 			totalRows += len(result.Data)
+			op.AddRows(len(result.Data))
 		}
 
-		// Send progress update
-		h.sendSSEEvent(w, "progress", map[string]interface{}{
+		// Send progress update, with the token resuming after this chunk
+		progress := map[string]interface{}{
 			"rows_processed": totalRows,
 			"elapsed_ms":     time.Since(startTime).Milliseconds(),
-		})
+		}
+		if len(result.Data) > 0 {
+			if token := resumeTokenAfter(req, result.Data[len(result.Data)-1]); token != "" {
+				progress["resume_token"] = token
+			}
+		}
+		h.sendSSEEvent(out, "progress", progress)
 		flusher.Flush()
 
 		// Check if done
@@ -493,11 +599,16 @@ func (h *StreamHandler) StreamSSE(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Send completion event
-	h.sendSSEEvent(w, "complete", map[string]interface{}{
+	complete := map[string]interface{}{
 		"total_rows": totalRows,
+		"sha256":     out.Sum(),
 		"duration":   time.Since(startTime).Milliseconds(),
 		"timestamp":  time.Now(),
-	})
+	}
+	if warnings := limitWarnings(injected); len(warnings) > 0 {
+		complete["warnings"] = warnings
+	}
+	h.sendSSEEvent(out, "complete", complete)
 	flusher.Flush()
 
 	h.logger.Info("SSE streaming completed",
@@ -511,8 +622,3 @@ func (h *StreamHandler) sendSSEEvent(w io.Writer, event string, data interface{}
 	fmt.Fprintf(w, "event: %s\n", event)
 	fmt.Fprintf(w, "data: %s\n\n", jsonData)
 }
-
-// sendSSEError sends an SSE error event
-func (h *StreamHandler) sendSSEError(w io.Writer, message string) {
-	h.sendSSEEvent(w, "error", map[string]string{"error": message})
-}
\ No newline at end of file
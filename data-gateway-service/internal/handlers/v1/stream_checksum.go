@@ -0,0 +1,58 @@
+package v1
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+)
+
+// Trailers sent after json, ndjson and csv streams so clients can detect a
+// truncated or altered body
+const (
+	TrailerRowCount = "X-Row-Count"
+	TrailerSHA256   = "X-Content-SHA256"
+)
+
+// streamTotals is what a stream writer reports once the body is complete
+type streamTotals struct {
+	Rows   int
+	SHA256 string // Hex SHA-256 of the body; for NDJSON, up to the summary line
+	Err    error  // Why the stream ended early, if it did
+
+	// ResumeToken continues the stream after its last row; empty when the
+	// stream cannot be resumed
+	ResumeToken string
+}
+
+// checksumWriter hashes exactly the bytes accepted by the underlying writer,
+// so a short write never counts bytes the client did not receive
+type checksumWriter struct {
+	w    io.Writer
+	hash hash.Hash
+	err  error // First write error
+}
+
+func newChecksumWriter(w io.Writer) *checksumWriter {
+	return &checksumWriter{w: w, hash: sha256.New()}
+}
+
+func (c *checksumWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.hash.Write(p[:n])
+	if err != nil && c.err == nil {
+		c.err = err
+	}
+	return n, err
+}
+
+// Err returns the first error of the underlying writer; streams stop fetching
+// once it is set
+func (c *checksumWriter) Err() error {
+	return c.err
+}
+
+// Sum returns the hex SHA-256 of the bytes written so far
+func (c *checksumWriter) Sum() string {
+	return hex.EncodeToString(c.hash.Sum(nil))
+}
@@ -0,0 +1,176 @@
+package v1
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/response"
+)
+
+// streamOverHTTP runs a stream through a real server so trailers are sent
+// as they would be to a client
+func streamOverHTTP(t *testing.T, body string) ([]byte, http.Header) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio, rows: rowsOf(7)}
+	handler := NewStreamHandler(map[string]datasource.DataSource{"DATAWAREHOUSE": source}, testLimits, zap.NewNop())
+	server := httptest.NewServer(http.HandlerFunc(handler.Stream))
+	defer server.Close()
+
+	resp, err := http.Post(server.URL, "application/json", strings.NewReader(body))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Trailers are only populated once the body has been read
+	received, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return received, resp.Trailer
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// verifyNDJSON checks a received NDJSON body the way a client should: the
+// rows before the summary must match its row count and checksum
+func verifyNDJSON(body []byte) error {
+	trimmed := bytes.TrimSuffix(body, []byte("\n"))
+	idx := bytes.LastIndexByte(trimmed, '\n')
+	if idx < 0 {
+		return fmt.Errorf("no summary line")
+	}
+	content, last := body[:idx+1], trimmed[idx+1:]
+
+	var summary struct {
+		Type      string `json:"type"`
+		TotalRows int    `json:"total_rows"`
+		SHA256    string `json:"sha256"`
+	}
+	if err := json.Unmarshal(last, &summary); err != nil || summary.Type != "summary" {
+		return fmt.Errorf("last line is not a summary")
+	}
+	if rows := bytes.Count(content, []byte("\n")); rows != summary.TotalRows {
+		return fmt.Errorf("received %d rows, summary says %d", rows, summary.TotalRows)
+	}
+	if sum := sha256Hex(content); sum != summary.SHA256 {
+		return fmt.Errorf("checksum mismatch")
+	}
+	return nil
+}
+
+func TestStream_NDJSONSummaryAndTrailers(t *testing.T) {
+	body, trailer := streamOverHTTP(t, `{"data_source": "DATAWAREHOUSE", "table": "t", "format": "ndjson"}`)
+
+	require.NoError(t, verifyNDJSON(body))
+	assert.Equal(t, "7", trailer.Get(TrailerRowCount))
+
+	// The trailer checksum equals the summary's: both cover the rows only
+	content := body[:bytes.LastIndexByte(bytes.TrimSuffix(body, []byte("\n")), '\n')+1]
+	assert.Equal(t, sha256Hex(content), trailer.Get(TrailerSHA256))
+}
+
+func TestStream_NDJSONTamperingDetected(t *testing.T) {
+	body, _ := streamOverHTTP(t, `{"data_source": "DATAWAREHOUSE", "table": "t", "format": "ndjson"}`)
+	lines := bytes.SplitAfter(body, []byte("\n"))
+
+	// A proxy dropping a row
+	dropped := bytes.Join(append(append([][]byte{}, lines[:2]...), lines[3:]...), nil)
+	assert.Error(t, verifyNDJSON(dropped))
+
+	// A row altered in transit without changing the row count
+	altered := bytes.Replace(body, []byte(`{"id":3}`), []byte(`{"id":9}`), 1)
+	require.NotEqual(t, body, altered)
+	assert.Error(t, verifyNDJSON(altered))
+
+	// A body cut off before the summary
+	assert.Error(t, verifyNDJSON(bytes.Join(lines[:4], nil)))
+}
+
+func TestStream_CSVAndJSONTrailers(t *testing.T) {
+	for _, format := range []string{"csv", "json"} {
+		body, trailer := streamOverHTTP(t, `{"data_source": "DATAWAREHOUSE", "table": "t", "format": "`+format+`"}`)
+
+		assert.Equal(t, "7", trailer.Get(TrailerRowCount), format)
+		assert.Equal(t, sha256Hex(body), trailer.Get(TrailerSHA256), format)
+
+		truncated := body[:len(body)-5]
+		assert.NotEqual(t, sha256Hex(truncated), trailer.Get(TrailerSHA256), format)
+	}
+}
+
+func TestStreamSSE_CompleteEventChecksum(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio, rows: rowsOf(3)}
+	handler := NewStreamHandler(map[string]datasource.DataSource{"DATAWAREHOUSE": source}, testLimits, zap.NewNop())
+
+	rec := httptest.NewRecorder()
+	handler.StreamSSE(rec, httptest.NewRequest(http.MethodPost, "/api/v1/stream/sse",
+		strings.NewReader(`{"data_source": "DATAWAREHOUSE", "table": "t"}`)))
+
+	body := rec.Body.Bytes()
+	idx := bytes.LastIndex(body, []byte("event: complete\n"))
+	require.True(t, idx > 0)
+
+	var complete struct {
+		TotalRows int    `json:"total_rows"`
+		SHA256    string `json:"sha256"`
+	}
+	data := strings.TrimPrefix(strings.SplitN(string(body[idx:]), "\n", 3)[1], "data: ")
+	require.NoError(t, json.Unmarshal([]byte(data), &complete))
+	assert.Equal(t, 3, complete.TotalRows)
+	assert.Equal(t, sha256Hex(body[:idx]), complete.SHA256)
+}
+
+func TestStream_WarningsInSummaryAndCompleteEvent(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio, rows: rowsOf(3)}
+	handler := NewStreamHandler(map[string]datasource.DataSource{"DATAWAREHOUSE": source}, testLimits, zap.NewNop())
+	handler.SetAutoLimit(1000)
+	const body = `{"data_source": "DATAWAREHOUSE", "query": "SELECT * FROM t", "format": "ndjson"}`
+	want := []response.Warning{{
+		Code:    response.WarnLimitInjected,
+		Message: "The query has no LIMIT and ran with LIMIT 1000; add one to read more rows",
+		Details: map[string]interface{}{"limit": float64(1000)},
+	}}
+
+	rec := httptest.NewRecorder()
+	handler.Stream(rec, httptest.NewRequest(http.MethodPost, "/api/v1/stream", strings.NewReader(body)))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	received := rec.Body.Bytes()
+	require.NoError(t, verifyNDJSON(received))
+	var summary struct {
+		Warnings []response.Warning `json:"warnings"`
+	}
+	lines := bytes.Split(bytes.TrimSuffix(received, []byte("\n")), []byte("\n"))
+	require.NoError(t, json.Unmarshal(lines[len(lines)-1], &summary))
+	assert.Equal(t, want, summary.Warnings)
+
+	rec = httptest.NewRecorder()
+	handler.StreamSSE(rec, httptest.NewRequest(http.MethodPost, "/api/v1/stream/sse", strings.NewReader(body)))
+	events := rec.Body.String()
+	idx := strings.LastIndex(events, "event: complete\n")
+	require.True(t, idx > 0)
+	var complete struct {
+		Warnings []response.Warning `json:"warnings"`
+	}
+	data := strings.TrimPrefix(strings.SplitN(events[idx:], "\n", 3)[1], "data: ")
+	require.NoError(t, json.Unmarshal([]byte(data), &complete))
+	assert.Equal(t, want, complete.Warnings)
+
+	// A query with a LIMIT of its own warns of nothing
+	rec = httptest.NewRecorder()
+	handler.Stream(rec, httptest.NewRequest(http.MethodPost, "/api/v1/stream",
+		strings.NewReader(`{"data_source": "DATAWAREHOUSE", "query": "SELECT * FROM t LIMIT 3", "format": "ndjson"}`)))
+	assert.NotContains(t, rec.Body.String(), `"warnings"`)
+}
@@ -0,0 +1,143 @@
+package v1
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/pkg/apitypes"
+)
+
+// TrailerResumeToken carries the resume token of a table stream with a
+// deterministic order: re-submitting the request with it as resume_token
+// continues after the last row sent
+const TrailerResumeToken = "X-Resume-Token"
+
+// resumeTokenVersion is bumped when the token's contents change meaning
+const resumeTokenVersion = 1
+
+// resumeToken is the decoded form of a resume token: the keyset position of
+// the last row sent, bound to the request that read it
+type resumeToken struct {
+	Version int               `json:"v"`
+	Request string            `json:"r"`
+	After   datasource.Keyset `json:"after"`
+}
+
+// SetTiebreakers orders table streams by the configured tiebreaker of their
+// table, which makes their order deterministic and their streams resumable
+func (h *StreamHandler) SetTiebreakers(tiebreakers config.Tiebreakers) {
+	h.tiebreakers = tiebreakers
+}
+
+// orderStream sets the tiebreaker of a table stream and, when req carries a
+// resume token, the position it continues after. A resume token is refused
+// unless the stream's order is deterministic and the token was issued for the
+// same source, table, filters and order.
+func (h *StreamHandler) orderStream(req *apitypes.StreamRequest) error {
+	if req.Table == "" {
+		if req.ResumeToken != "" {
+			return fieldError("resume_token", "table", "resume_token applies to table streams only")
+		}
+		return nil
+	}
+
+	opts := datasource.QueryOptions{}
+	if req.Options != nil {
+		opts = *req.Options
+	}
+	opts.Tiebreaker = h.tiebreakers.For(req.Table)
+	opts.After = nil
+	req.Options = &opts
+	if req.ResumeToken == "" {
+		return nil
+	}
+
+	if opts.Tiebreaker == "" {
+		return fieldError("resume_token", "deterministic",
+			"table %s has no tiebreaker configured, so its stream order is not deterministic and cannot be resumed", req.Table)
+	}
+	after, err := decodeResumeToken(*req, req.ResumeToken)
+	if err != nil {
+		return err
+	}
+	opts.After = after
+	return nil
+}
+
+// resumeTokenAfter returns the token continuing req after row, or "" when the
+// stream cannot be resumed there
+func resumeTokenAfter(req apitypes.StreamRequest, row map[string]interface{}) string {
+	if req.Table == "" || row == nil {
+		return ""
+	}
+	keyset, ok := datasource.KeysetAfter(req.Options, row)
+	if !ok {
+		return ""
+	}
+	data, err := json.Marshal(resumeToken{Version: resumeTokenVersion, Request: streamFingerprint(req), After: *keyset})
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeResumeToken returns the position token continues req after
+func decodeResumeToken(req apitypes.StreamRequest, token string) (*datasource.Keyset, error) {
+	invalid := func(reason string) error {
+		return fieldError("resume_token", "resume_token", "invalid resume_token: %s", reason)
+	}
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimSpace(token))
+	if err != nil {
+		return nil, invalid("not a token issued by this gateway")
+	}
+
+	// Numbers stay exact: ids beyond 2^53 must not round to a neighbour
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var decoded resumeToken
+	if err := dec.Decode(&decoded); err != nil || decoded.Version != resumeTokenVersion {
+		return nil, invalid("not a token issued by this gateway")
+	}
+	if decoded.Request != streamFingerprint(req) {
+		return nil, invalid("it was issued for a different source, table, filters or order")
+	}
+	for i, value := range decoded.After.Values {
+		number, ok := value.(json.Number)
+		if !ok {
+			continue
+		}
+		if n, err := number.Int64(); err == nil {
+			decoded.After.Values[i] = n
+		} else if f, err := number.Float64(); err == nil {
+			decoded.After.Values[i] = f
+		}
+	}
+	if _, err := datasource.NewSQLSanitizer().KeysetCondition(decoded.After); err != nil {
+		return nil, invalid(err.Error())
+	}
+	return &decoded.After, nil
+}
+
+// streamFingerprint identifies what a table stream reads, so a token cannot
+// continue a stream of other rows or another order
+func streamFingerprint(req apitypes.StreamRequest) string {
+	fields := map[string]interface{}{
+		"data_source": req.DataSource,
+		"table":       req.Table,
+	}
+	if req.Options != nil {
+		fields["order_by"] = req.Options.OrderBy
+		fields["order_dir"] = strings.ToUpper(strings.TrimSpace(req.Options.OrderDir))
+		fields["tiebreaker"] = req.Options.Tiebreaker
+		fields["filters"] = req.Options.Filters
+	}
+	data, _ := json.Marshal(fields) // Map keys are sorted
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,272 @@
+package v1
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+)
+
+// keysetSource reads a table the way a database would: ordered by OrderBy
+// and the tiebreaker, past the After keyset, then paged. Reads from offset
+// failAt on fail, as a source dropping mid-stream.
+type keysetSource struct {
+	recordingSource
+	table  []map[string]interface{}
+	failAt int
+}
+
+func (s *keysetSource) GetData(ctx context.Context, table string, opts *datasource.QueryOptions) (*datasource.QueryResult, error) {
+	s.opts = opts
+	if s.failAt > 0 && opts.Offset >= s.failAt {
+		return nil, errors.New("connection reset by peer")
+	}
+
+	var columns []string
+	for _, column := range []string{opts.OrderBy, opts.Tiebreaker} {
+		if column != "" {
+			columns = append(columns, column)
+		}
+	}
+	desc := strings.EqualFold(opts.OrderDir, "DESC")
+	less := func(a, b map[string]interface{}) bool {
+		for _, column := range columns {
+			if c := compareValues(a[column], b[column]); c != 0 {
+				return (c < 0) != desc
+			}
+		}
+		return false
+	}
+
+	rows := append([]map[string]interface{}(nil), s.table...)
+	sort.SliceStable(rows, func(i, j int) bool { return less(rows[i], rows[j]) })
+	if opts.After != nil {
+		position := make(map[string]interface{}, len(opts.After.Columns))
+		for i, column := range opts.After.Columns {
+			position[column] = opts.After.Values[i]
+		}
+		var past []map[string]interface{}
+		for _, row := range rows {
+			if less(position, row) {
+				past = append(past, row)
+			}
+		}
+		rows = past
+	}
+
+	rows = rows[min(opts.Offset, len(rows)):]
+	rows = rows[:min(opts.Limit, len(rows))]
+	return &datasource.QueryResult{Data: rows, Count: len(rows), Source: s.sourceType}, nil
+}
+
+// compareValues orders the int64 and string values of keysetSource rows
+func compareValues(a, b interface{}) int {
+	switch a := a.(type) {
+	case int64:
+		return int(a - b.(int64))
+	case string:
+		return strings.Compare(a, b.(string))
+	}
+	panic(fmt.Sprintf("unexpected value %T", a))
+}
+
+// newKeysetSource returns a table of n rows with ids 1..n in reverse, whose
+// grp column ties every third row
+func newKeysetSource(n, failAt int) *keysetSource {
+	source := &keysetSource{recordingSource: recordingSource{sourceType: datasource.DataSourceDremio}, failAt: failAt}
+	for id := n; id >= 1; id-- {
+		source.table = append(source.table, map[string]interface{}{
+			"id":  int64(id),
+			"grp": []string{"a", "b", "c"}[id%3],
+		})
+	}
+	return source
+}
+
+func newResumableStreamHandler(source datasource.DataSource) *StreamHandler {
+	handler := NewStreamHandler(map[string]datasource.DataSource{"DATAWAREHOUSE": source}, testLimits, zap.NewNop())
+	handler.SetTiebreakers(config.Tiebreakers{"events": "id"})
+	return handler
+}
+
+// streamBody posts a stream request with the given resume token
+func streamBody(handler http.HandlerFunc, body, token string) *httptest.ResponseRecorder {
+	var req map[string]interface{}
+	json.Unmarshal([]byte(body), &req)
+	if token != "" {
+		req["resume_token"] = token
+	}
+	data, _ := json.Marshal(req)
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodPost, "/api/v1/stream", strings.NewReader(string(data))))
+	return rec
+}
+
+// ndjsonIDs returns the ids of an NDJSON body's rows and its summary line
+func ndjsonIDs(t *testing.T, body string) ([]int64, map[string]interface{}) {
+	t.Helper()
+	var ids []int64
+	var summary map[string]interface{}
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		var line map[string]interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		switch line["type"] {
+		case "summary":
+			summary = line
+		case "error":
+		default:
+			ids = append(ids, int64(line["id"].(float64)))
+		}
+	}
+	return ids, summary
+}
+
+// wantIDs returns the ids of newKeysetSource(n) ordered by grp, then id,
+// both ascending or both descending
+func wantIDs(n int, desc bool) []int64 {
+	var ids []int64
+	for _, grp := range []int{0, 1, 2} { // a, b, c
+		for id := 1; id <= n; id++ {
+			if id%3 == grp {
+				ids = append(ids, int64(id))
+			}
+		}
+	}
+	if desc {
+		slices.Reverse(ids)
+	}
+	return ids
+}
+
+func TestStream_ResumesInterruptedNDJSONWithoutGapsOrDuplicates(t *testing.T) {
+	source := newKeysetSource(23, 10)
+	handler := newResumableStreamHandler(source)
+	body := `{"data_source": "DATAWAREHOUSE", "table": "events", "chunk_size": 5, "options": {"OrderBy": "grp"}}`
+
+	rec := streamBody(handler.Stream, body, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	first, summary := ndjsonIDs(t, rec.Body.String())
+	require.Len(t, first, 10, "the source fails after two chunks")
+	token, _ := summary["resume_token"].(string)
+	require.NotEmpty(t, token)
+	assert.Equal(t, token, rec.Result().Trailer.Get(TrailerResumeToken))
+
+	source.failAt = 0
+	rec = streamBody(handler.Stream, body, token)
+	require.Equal(t, http.StatusOK, rec.Code)
+	rest, _ := ndjsonIDs(t, rec.Body.String())
+	require.NotNil(t, source.opts.After)
+
+	assert.Equal(t, wantIDs(23, false), append(first, rest...))
+}
+
+func TestStream_ResumedCSVContinuesTheFile(t *testing.T) {
+	source := newKeysetSource(12, 5)
+	handler := newResumableStreamHandler(source)
+	body := `{"data_source": "DATAWAREHOUSE", "table": "events", "chunk_size": 5, "format": "csv",
+		"options": {"OrderBy": "grp", "OrderDir": "DESC"}}`
+
+	rec := streamBody(handler.Stream, body, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	token := rec.Result().Trailer.Get(TrailerResumeToken)
+	require.NotEmpty(t, token)
+	file := rec.Body.String()
+
+	source.failAt = 0
+	rec = streamBody(handler.Stream, body, token)
+	require.Equal(t, http.StatusOK, rec.Code)
+	file += rec.Body.String()
+
+	records, err := csv.NewReader(strings.NewReader(file)).ReadAll()
+	require.NoError(t, err)
+	require.Equal(t, []string{"grp", "id"}, records[0])
+	var ids []int64
+	for _, record := range records[1:] {
+		var id int64
+		fmt.Sscan(record[1], &id)
+		ids = append(ids, id)
+	}
+
+	assert.Equal(t, wantIDs(12, true), ids)
+}
+
+func TestStreamSSE_ProgressCarriesResumeToken(t *testing.T) {
+	source := newKeysetSource(9, 0)
+	handler := newResumableStreamHandler(source)
+	body := `{"data_source": "DATAWAREHOUSE", "table": "events", "chunk_size": 4}`
+
+	rec := streamBody(handler.StreamSSE, body, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var tokens []string
+	for _, line := range strings.Split(rec.Body.String(), "\n") {
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok || !strings.Contains(data, "resume_token") {
+			continue
+		}
+		var progress struct {
+			ResumeToken string `json:"resume_token"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(data), &progress))
+		tokens = append(tokens, progress.ResumeToken)
+	}
+	require.Len(t, tokens, 3, "one per chunk with rows")
+
+	// Resuming after the first chunk continues at id 5
+	rec = streamBody(handler.Stream, body, tokens[0])
+	require.Equal(t, http.StatusOK, rec.Code)
+	ids, _ := ndjsonIDs(t, rec.Body.String())
+	assert.Equal(t, []int64{5, 6, 7, 8, 9}, ids)
+}
+
+func TestStream_ResumeTokenRequiresDeterministicOrder(t *testing.T) {
+	source := newKeysetSource(6, 0)
+	handler := newResumableStreamHandler(source)
+	body := `{"data_source": "DATAWAREHOUSE", "table": "events", "chunk_size": 5, "options": {"OrderBy": "grp"}}`
+	rec := streamBody(handler.Stream, body, "")
+	_, summary := ndjsonIDs(t, rec.Body.String())
+	token := summary["resume_token"].(string)
+
+	tests := map[string]struct{ body, token, reason string }{
+		"raw query": {`{"data_source": "DATAWAREHOUSE", "query": "SELECT * FROM events"}`, token,
+			"table streams only"},
+		"no tiebreaker": {`{"data_source": "DATAWAREHOUSE", "table": "other", "options": {"OrderBy": "grp"}}`, token,
+			"not deterministic"},
+		"another order": {`{"data_source": "DATAWAREHOUSE", "table": "events", "options": {"OrderBy": "grp", "OrderDir": "DESC"}}`, token,
+			"different source, table, filters or order"},
+		"other filters": {`{"data_source": "DATAWAREHOUSE", "table": "events", "options": {"OrderBy": "grp", "filters": {"grp": "a"}}}`, token,
+			"different source, table, filters or order"},
+		"not a token": {body, "bm90IGEgdG9rZW4", "not a token issued by this gateway"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			source.opts = nil
+			rec := streamBody(handler.Stream, tt.body, tt.token)
+			violations := violationsOf(t, rec)
+			require.Len(t, violations, 1)
+			assert.Equal(t, "resume_token", violations[0].Field)
+			assert.Contains(t, violations[0].Message, tt.reason)
+			assert.Nil(t, source.opts)
+		})
+	}
+
+	// A table without a tiebreaker streams without a token
+	rec = streamBody(handler.Stream, `{"data_source": "DATAWAREHOUSE", "table": "other"}`, "")
+	_, summary = ndjsonIDs(t, rec.Body.String())
+	assert.NotContains(t, summary, "resume_token")
+}
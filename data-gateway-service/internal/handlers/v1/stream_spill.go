@@ -0,0 +1,183 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/auth"
+	"go-data-gateway/internal/csvfmt"
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/response"
+	"go-data-gateway/internal/spill"
+	"go-data-gateway/pkg/apitypes"
+)
+
+// Spill modes of a StreamRequest
+const (
+	SpillSync  = "sync"
+	SpillAsync = "async"
+)
+
+// SpillResponse describes a spilled result: the body of an async spill, and
+// of a download that is not ready yet
+type SpillResponse struct {
+	spill.Info
+	DownloadURL string `json:"download_url"`
+}
+
+// SetSpill enables the spill option of Stream, writing results to store
+func (h *StreamHandler) SetSpill(store *spill.Store) {
+	h.spills = store
+}
+
+// validateSpill checks the spill mode of req
+func (h *StreamHandler) validateSpill(req apitypes.StreamRequest) error {
+	switch req.Spill {
+	case "":
+		return nil
+	case SpillSync, SpillAsync:
+		if h.spills == nil {
+			return fieldError("spill", "enabled", "spill is not enabled on this gateway")
+		}
+		return nil
+	default:
+		return fieldError("spill", "oneof=sync async", "invalid spill %q: must be sync or async", req.Spill)
+	}
+}
+
+// spillDownloadURL is where a spilled result is downloaded
+func spillDownloadURL(id string) string {
+	return "/api/v1/stream/spills/" + id
+}
+
+// nopFlusher stands in for the response flusher while writing to a file
+type nopFlusher struct{}
+
+func (nopFlusher) Flush() {}
+
+// spillStream writes the result to a spill file, with warnings in an NDJSON
+// summary. A sync spill then serves the file; an async one responds 202 with
+// the download URL while it is written.
+func (h *StreamHandler) spillStream(w http.ResponseWriter, r *http.Request,
+	dataSource datasource.DataSource, req apitypes.StreamRequest, formatter *csvfmt.Formatter, warnings []response.Warning) {
+
+	owner := ""
+	if key, ok := auth.KeyFromContext(r.Context()); ok {
+		owner = key.ID
+	}
+	writer, err := h.spills.Create(owner, streamContentTypes[req.Format])
+	if err != nil {
+		h.logger.Error("Failed to create spill file", zap.Error(err))
+		response.ErrorWithDetails(w, "Failed to spill result", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	write := func(ctx context.Context) spill.Info {
+		totals := h.writeStream(ctx, newChecksumWriter(writer), nopFlusher{}, dataSource, req, formatter, warnings)
+		info := writer.Finish(totals.Rows, totals.SHA256, totals.Err)
+		h.logger.Info("Result spilled",
+			zap.String("spill_id", info.ID),
+			zap.String("status", string(info.Status)),
+			zap.Int64("size_bytes", info.Size),
+			zap.Int("rows", info.Rows),
+			zap.String("data_source", req.DataSource))
+		return info
+	}
+
+	if req.Spill == SpillAsync {
+		// The spill outlives the request, which ends now
+		go write(context.WithoutCancel(r.Context()))
+
+		info, _ := h.spills.Get(writer.ID())
+		w.Header().Set("Location", spillDownloadURL(info.ID))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(response.StandardResponse{Success: true,
+			Data: SpillResponse{Info: info, DownloadURL: spillDownloadURL(info.ID)}})
+		return
+	}
+
+	info := write(r.Context())
+	if info.Status == spill.StatusFailed {
+		writeSpillFailed(w, info)
+		return
+	}
+	h.serveSpill(w, r, info.ID)
+}
+
+// Download handles GET /api/v1/stream/spills/{id}: it serves a spilled
+// result, honoring Range and If-Range so an interrupted download resumes. A
+// spill still being written responds 202 with its status.
+func (h *StreamHandler) Download(w http.ResponseWriter, r *http.Request) {
+	if h.spills == nil {
+		response.Error(w, "Spill is not enabled", http.StatusNotFound)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	info, err := h.spills.Get(id)
+	if err != nil || !spillOwnedBy(r, info) {
+		response.Error(w, "Spilled result not found", http.StatusNotFound)
+		return
+	}
+
+	switch info.Status {
+	case spill.StatusRunning:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(response.StandardResponse{Success: true,
+			Data: SpillResponse{Info: info, DownloadURL: spillDownloadURL(info.ID)}})
+	case spill.StatusFailed:
+		writeSpillFailed(w, info)
+	default:
+		h.serveSpill(w, r, id)
+	}
+}
+
+// spillOwnedBy reports whether the request's API key created the spill
+func spillOwnedBy(r *http.Request, info spill.Info) bool {
+	key, ok := auth.KeyFromContext(r.Context())
+	if !ok {
+		return info.Owner == ""
+	}
+	return key.ID == info.Owner
+}
+
+// writeSpillFailed responds with why a spill failed
+func writeSpillFailed(w http.ResponseWriter, info spill.Info) {
+	status := http.StatusBadGateway
+	if errors.Is(info.Err(), spill.ErrTooLarge) {
+		status = http.StatusRequestEntityTooLarge
+	}
+	response.ErrorWithDetails(w, "Failed to spill result", info.Error, status)
+}
+
+// serveSpill serves a ready spilled result. The body's SHA-256 is its ETag,
+// so a resumed download only continues the file it started.
+func (h *StreamHandler) serveSpill(w http.ResponseWriter, r *http.Request, id string) {
+	file, info, err := h.spills.Open(id)
+	if errors.Is(err, spill.ErrNotFound) {
+		response.Error(w, "Spilled result not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to open spilled result", zap.String("spill_id", id), zap.Error(err))
+		response.Error(w, "Failed to open spilled result", http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", info.ContentType)
+	w.Header().Set("Content-Location", spillDownloadURL(info.ID))
+	w.Header().Set("ETag", `"`+info.SHA256+`"`)
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set(TrailerRowCount, strconv.Itoa(info.Rows))
+	w.Header().Set(TrailerSHA256, info.SHA256)
+	http.ServeContent(w, r, "", info.CreatedAt, file)
+}
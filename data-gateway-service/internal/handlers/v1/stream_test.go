@@ -0,0 +1,345 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/auth"
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/csvfmt"
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/spill"
+	"go-data-gateway/pkg/apitypes"
+)
+
+func TestStream_FiltersReachGetData(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio, rows: rowsOf(2)}
+	handler := NewStreamHandler(map[string]datasource.DataSource{"DATAWAREHOUSE": source}, testLimits, zap.NewNop())
+
+	body := `{"data_source": "DATAWAREHOUSE", "table": "nessie_iceberg.tender_data", "options": {
+		"OrderBy": "nilai_pagu",
+		"filters": {"provinsi": {"op": "in", "value": ["Aceh", "Bali"]}, "tahun_anggaran": 2025}}}`
+
+	for _, stream := range []http.HandlerFunc{handler.Stream, handler.StreamSSE} {
+		source.opts = nil
+		rec := httptest.NewRecorder()
+		stream(rec, httptest.NewRequest(http.MethodPost, "/api/v1/stream", strings.NewReader(body)))
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.NotNil(t, source.opts)
+
+		assert.Equal(t, "nilai_pagu", source.opts.OrderBy)
+		where, err := datasource.NewSQLSanitizer().BuildWhereClause(source.opts.Filters)
+		require.NoError(t, err)
+		assert.Equal(t, " WHERE provinsi IN ('Aceh', 'Bali') AND tahun_anggaran = 2025", where)
+	}
+}
+
+func TestStream_InvalidFiltersRejectedBeforeStreaming(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio, rows: rowsOf(2)}
+	handler := NewStreamHandler(map[string]datasource.DataSource{"DATAWAREHOUSE": source}, testLimits, zap.NewNop())
+
+	bodies := []string{
+		`{"data_source": "DATAWAREHOUSE", "table": "t", "options": {"filters": {"a": {"op": "between", "value": 1}}}}`,
+		`{"data_source": "DATAWAREHOUSE", "table": "t", "options": {"filters": {"a; DROP": 1}}}`,
+		`{"data_source": "DATAWAREHOUSE", "query": "SELECT * FROM t", "options": {"filters": {"a": 1}}}`,
+	}
+	for _, body := range bodies {
+		rec := httptest.NewRecorder()
+		handler.Stream(rec, httptest.NewRequest(http.MethodPost, "/api/v1/stream", strings.NewReader(body)))
+		violations := violationsOf(t, rec)
+		require.Len(t, violations, 1, body)
+		assert.Equal(t, "filters", violations[0].Constraint, body)
+		assert.Contains(t, violations[0].Message, "invalid filters", body)
+	}
+	assert.Nil(t, source.opts)
+}
+
+func TestStream_OrderingRejectedBeforeStreaming(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio, rows: rowsOf(2)}
+	handler := NewStreamHandler(map[string]datasource.DataSource{"DATAWAREHOUSE": source}, testLimits, zap.NewNop())
+
+	bodies := map[string]string{
+		`{"data_source": "DATAWAREHOUSE", "query": "SELECT * FROM t", "options": {"OrderBy": "a"}}`:                     "put ORDER BY in the query",
+		`{"data_source": "DATAWAREHOUSE", "query": "SELECT * FROM t", "options": {"OrderDir": "DESC"}}`:                 "put ORDER BY in the query",
+		`{"data_source": "DATAWAREHOUSE", "table": "t", "options": {"OrderBy": "a; DROP TABLE t"}}`:                     "invalid column name",
+		`{"data_source": "DATAWAREHOUSE", "table": "t", "options": {"OrderBy": "a", "OrderDir": "DESC; DROP TABLE t"}}`: "invalid order direction",
+	}
+	for body, reason := range bodies {
+		rec := httptest.NewRecorder()
+		handler.Stream(rec, httptest.NewRequest(http.MethodPost, "/api/v1/stream", strings.NewReader(body)))
+		violations := violationsOf(t, rec)
+		require.Len(t, violations, 1, body)
+		assert.Equal(t, "ordering", violations[0].Constraint, body)
+		assert.Contains(t, violations[0].Message, reason, body)
+
+		rec = httptest.NewRecorder()
+		handler.StreamSSE(rec, httptest.NewRequest(http.MethodPost, "/api/v1/stream/sse", strings.NewReader(body)))
+		assert.Equal(t, "ordering", violationsOf(t, rec)[0].Constraint, body)
+	}
+	assert.Nil(t, source.opts)
+}
+
+// chunkFailingSource returns full chunks of rows until offset failAt, where
+// it fails with err
+type chunkFailingSource struct {
+	recordingSource
+	failAt int
+	err    error
+}
+
+func (s *chunkFailingSource) ExecuteQuery(ctx context.Context, query string, opts *datasource.QueryOptions) (*datasource.QueryResult, error) {
+	if opts.Offset >= s.failAt {
+		return nil, s.err
+	}
+	return &datasource.QueryResult{Data: rowsOf(opts.Limit), Count: opts.Limit, Source: s.sourceType}, nil
+}
+
+func (s *chunkFailingSource) GetData(ctx context.Context, table string, opts *datasource.QueryOptions) (*datasource.QueryResult, error) {
+	return s.ExecuteQuery(ctx, table, opts)
+}
+
+func TestStream_FailureBeforeFirstChunkHasStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{"table not found", &datasource.UpstreamError{Class: datasource.ErrorClassTableNotFound, Message: "Table 't' not found"},
+			http.StatusNotFound, string(datasource.ErrorClassTableNotFound)},
+		{"invalid table", fmt.Errorf("%w: table validation failed", datasource.ErrInvalidTableQuery),
+			http.StatusBadRequest, http.StatusText(http.StatusBadRequest)},
+		{"source down", errors.New("connection refused"),
+			http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &chunkFailingSource{recordingSource: recordingSource{sourceType: datasource.DataSourceDremio}, err: tt.err}
+			handler := NewStreamHandler(map[string]datasource.DataSource{"DATAWAREHOUSE": source}, testLimits, zap.NewNop())
+
+			for _, format := range []string{"ndjson", "json", "csv"} {
+				rec := httptest.NewRecorder()
+				handler.Stream(rec, httptest.NewRequest(http.MethodPost, "/api/v1/stream",
+					strings.NewReader(`{"data_source": "DATAWAREHOUSE", "table": "t", "format": "`+format+`"}`)))
+				assert.Equal(t, tt.status, rec.Code, format)
+				assert.Equal(t, "application/json", rec.Header().Get("Content-Type"), format)
+				assert.Equal(t, tt.code, decodeResponse(t, rec).Error.Code, format)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.StreamSSE(rec, httptest.NewRequest(http.MethodPost, "/api/v1/stream/sse",
+				strings.NewReader(`{"data_source": "DATAWAREHOUSE", "table": "t"}`)))
+			assert.Equal(t, tt.status, rec.Code)
+			assert.Equal(t, tt.code, decodeResponse(t, rec).Error.Code)
+		})
+	}
+}
+
+func TestStream_FailureAfterFirstChunkIsInBand(t *testing.T) {
+	source := &chunkFailingSource{
+		recordingSource: recordingSource{sourceType: datasource.DataSourceDremio},
+		failAt:          10,
+		err:             errors.New("connection reset"),
+	}
+	handler := NewStreamHandler(map[string]datasource.DataSource{"DATAWAREHOUSE": source}, testLimits, zap.NewNop())
+
+	rec := httptest.NewRecorder()
+	handler.Stream(rec, httptest.NewRequest(http.MethodPost, "/api/v1/stream",
+		strings.NewReader(`{"data_source": "DATAWAREHOUSE", "table": "t", "chunk_size": 10}`)))
+	require.Equal(t, http.StatusOK, rec.Code)
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	require.Len(t, lines, 12)
+	assert.JSONEq(t, `{"id": 0}`, lines[0])
+	assert.JSONEq(t, `{"type": "error", "error": "connection reset"}`, lines[10])
+	assert.Contains(t, lines[11], `"type":"summary"`)
+	assert.Equal(t, "10", rec.Header().Get(TrailerRowCount))
+
+	rec = httptest.NewRecorder()
+	handler.StreamSSE(rec, httptest.NewRequest(http.MethodPost, "/api/v1/stream/sse",
+		strings.NewReader(`{"data_source": "DATAWAREHOUSE", "table": "t", "chunk_size": 10}`)))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "event: data")
+	assert.Contains(t, rec.Body.String(), "event: error\ndata: {\"error\":\"connection reset\"}")
+}
+
+func TestStream_CSVLocale(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio, rows: []map[string]interface{}{
+		{"nilai_pagu": 5000000000.5},
+	}}
+	handler := NewStreamHandler(map[string]datasource.DataSource{"DATAWAREHOUSE": source}, testLimits, zap.NewNop())
+
+	stream := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.Stream(rec, httptest.NewRequest(http.MethodPost, "/api/v1/stream", strings.NewReader(body)))
+		return rec
+	}
+
+	rec := stream(`{"data_source": "DATAWAREHOUSE", "table": "nessie_iceberg.tender_data", "format": "csv",
+		"csv": {"locale": "id-ID", "bom": true}}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, csvfmt.BOM+"nilai_pagu\n5000000000,5\n", rec.Body.String())
+
+	// JSON output is machine-formatted whatever the locale
+	rec = stream(`{"data_source": "DATAWAREHOUSE", "table": "nessie_iceberg.tender_data", "format": "ndjson",
+		"csv": {"locale": "id-ID", "bom": true}}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, strings.HasPrefix(rec.Body.String(), `{"nilai_pagu":5000000000.5}`+"\n"))
+
+	rec = stream(`{"data_source": "DATAWAREHOUSE", "table": "nessie_iceberg.tender_data", "format": "csv",
+		"csv": {"locale": "de-DE"}}`)
+	violations := violationsOf(t, rec)
+	assert.Equal(t, "csv", violations[0].Field)
+	assert.Contains(t, violations[0].Message, "unsupported locale")
+}
+
+// nestedRows has a BigQuery ARRAY and STRUCT column, as convertBigQueryValue returns them
+var nestedRows = []map[string]interface{}{
+	{
+		"kode_rup": "R1",
+		"lokasi":   []interface{}{"Bandung", "Bogor"},
+		"peserta":  map[string]interface{}{"nama": "CV Maju", "email": "maju@example.id"},
+	},
+}
+
+func TestStream_NestedColumns(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceBigQuery, rows: nestedRows}
+	handler := NewStreamHandler(map[string]datasource.DataSource{"BIGQUERY": source}, testLimits, zap.NewNop())
+
+	stream := func(format, csv string) string {
+		body := `{"data_source": "BIGQUERY", "table": "gtp-data-prod.layer_isb.rup_kromaster", "format": "` + format + `"` + csv + `}`
+		rec := httptest.NewRecorder()
+		handler.Stream(rec, httptest.NewRequest(http.MethodPost, "/api/v1/stream", strings.NewReader(body)))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		return rec.Body.String()
+	}
+
+	nested := `{"kode_rup":"R1","lokasi":["Bandung","Bogor"],"peserta":{"email":"maju@example.id","nama":"CV Maju"}}`
+	assert.Contains(t, stream("json", ""), nested)
+	assert.True(t, strings.HasPrefix(stream("ndjson", ""), nested+"\n"))
+
+	// CSV cells hold arrays and records as JSON, or records flattened one level
+	assert.Equal(t, "kode_rup,lokasi,peserta\n"+
+		`R1,"[""Bandung"",""Bogor""]","{""email"":""maju@example.id"",""nama"":""CV Maju""}"`+"\n",
+		stream("csv", ""))
+	assert.Equal(t, "kode_rup,lokasi,peserta.email,peserta.nama\n"+
+		`R1,"[""Bandung"",""Bogor""]",maju@example.id,CV Maju`+"\n",
+		stream("csv", `, "csv": {"flatten": true}`))
+}
+
+func newSpillStreamHandler(t *testing.T, source datasource.DataSource, maxBytes int64) *StreamHandler {
+	store, err := spill.NewStore(config.SpillConfig{Dir: t.TempDir(), MaxBytes: maxBytes, Retention: time.Hour}, zap.NewNop())
+	require.NoError(t, err)
+	handler := NewStreamHandler(map[string]datasource.DataSource{"DATAWAREHOUSE": source}, testLimits, zap.NewNop())
+	handler.SetSpill(store)
+	return handler
+}
+
+// spillRequest sends req as the analyst API key
+func spillRequest(handler http.HandlerFunc, req *http.Request) *httptest.ResponseRecorder {
+	req = req.WithContext(auth.WithKey(req.Context(), &auth.APIKey{ID: "analyst"}))
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+// downloadSpill GETs a spilled result with the given Range header
+func downloadSpill(handler *StreamHandler, id, byteRange string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, spillDownloadURL(id), nil)
+	if byteRange != "" {
+		req.Header.Set("Range", byteRange)
+	}
+	routeCtx := chi.NewRouteContext()
+	routeCtx.URLParams.Add("id", id)
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, routeCtx))
+	return spillRequest(handler.Download, req)
+}
+
+func TestStream_SpillSyncServesFileWithRanges(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio, rows: []map[string]interface{}{
+		{"id": 1}, {"id": 2}, {"id": 3},
+	}}
+	handler := newSpillStreamHandler(t, source, 0)
+
+	rec := spillRequest(handler.Stream, httptest.NewRequest(http.MethodPost, "/api/v1/stream",
+		strings.NewReader(`{"data_source": "DATAWAREHOUSE", "table": "t", "format": "csv", "spill": "sync"}`)))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "id\n1\n2\n3\n", rec.Body.String())
+	assert.Equal(t, "text/csv", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "bytes", rec.Header().Get("Accept-Ranges"))
+	assert.Equal(t, "3", rec.Header().Get(TrailerRowCount))
+
+	// An interrupted download resumes from the bytes it has
+	location := rec.Header().Get("Content-Location")
+	id := strings.TrimPrefix(location, spillDownloadURL(""))
+	resumed := downloadSpill(handler, id, "bytes=5-")
+	require.Equal(t, http.StatusPartialContent, resumed.Code)
+	assert.Equal(t, "2\n3\n", resumed.Body.String())
+	assert.Equal(t, "bytes 5-8/9", resumed.Header().Get("Content-Range"))
+	assert.Equal(t, rec.Header().Get("ETag"), resumed.Header().Get("ETag"))
+
+	// Another API key cannot download it
+	req := httptest.NewRequest(http.MethodGet, location, nil)
+	routeCtx := chi.NewRouteContext()
+	routeCtx.URLParams.Add("id", id)
+	req = req.WithContext(auth.WithKey(context.WithValue(req.Context(), chi.RouteCtxKey, routeCtx), &auth.APIKey{ID: "other"}))
+	other := httptest.NewRecorder()
+	handler.Download(other, req)
+	assert.Equal(t, http.StatusNotFound, other.Code)
+}
+
+func TestStream_SpillAsyncReturnsDownloadURL(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio, rows: rowsOf(3)}
+	handler := newSpillStreamHandler(t, source, 0)
+
+	rec := spillRequest(handler.Stream, httptest.NewRequest(http.MethodPost, "/api/v1/stream",
+		strings.NewReader(`{"data_source": "DATAWAREHOUSE", "table": "t", "format": "json", "spill": "async"}`)))
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+
+	var resp struct {
+		Data SpillResponse `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, spillDownloadURL(resp.Data.ID), resp.Data.DownloadURL)
+	assert.Equal(t, resp.Data.DownloadURL, rec.Header().Get("Location"))
+
+	var download *httptest.ResponseRecorder
+	require.Eventually(t, func() bool {
+		download = downloadSpill(handler, resp.Data.ID, "")
+		return download.Code != http.StatusAccepted
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, http.StatusOK, download.Code)
+	assert.True(t, strings.HasPrefix(download.Body.String(), "[\n"))
+	assert.True(t, strings.HasSuffix(download.Body.String(), "\n]"))
+	assert.Equal(t, "3", download.Header().Get(TrailerRowCount))
+}
+
+func TestStream_SpillErrors(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio, rows: rowsOf(50)}
+
+	// Past the maximum size the spill fails
+	rec := spillRequest(newSpillStreamHandler(t, source, 64).Stream, httptest.NewRequest(http.MethodPost, "/api/v1/stream",
+		strings.NewReader(`{"data_source": "DATAWAREHOUSE", "table": "t", "spill": "sync"}`)))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+
+	rec = spillRequest(newSpillStreamHandler(t, source, 0).Stream, httptest.NewRequest(http.MethodPost, "/api/v1/stream",
+		strings.NewReader(`{"data_source": "DATAWAREHOUSE", "table": "t", "spill": "later"}`)))
+	assert.Equal(t, "oneof=sync async", violationsOf(t, rec)[0].Constraint)
+
+	disabled := NewStreamHandler(map[string]datasource.DataSource{"DATAWAREHOUSE": source}, testLimits, zap.NewNop())
+	rec = spillRequest(disabled.Stream, httptest.NewRequest(http.MethodPost, "/api/v1/stream",
+		strings.NewReader(`{"data_source": "DATAWAREHOUSE", "table": "t", "spill": "sync"}`)))
+	assert.Equal(t, []apitypes.Violation{{Field: "spill", Message: "spill is not enabled on this gateway", Constraint: "enabled"}},
+		violationsOf(t, rec))
+}
@@ -0,0 +1,303 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/clients"
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/response"
+	"go-data-gateway/internal/sqlbuilder"
+)
+
+// ErrCodeProfileOverBudget is returned when a BigQuery profile would scan
+// more than its byte budget
+const ErrCodeProfileOverBudget = "PROFILE_OVER_BUDGET"
+
+// ColumnProfile is the statistics of one profiled column. Distinct counts
+// are approximate; Min and Max are omitted for columns without values.
+type ColumnProfile struct {
+	Name          string      `json:"name"`
+	Type          string      `json:"type"`
+	NullCount     int64       `json:"null_count"`
+	NullPercent   float64     `json:"null_percent"`
+	DistinctCount int64       `json:"distinct_count"`
+	Min           interface{} `json:"min,omitempty"`
+	Max           interface{} `json:"max,omitempty"`
+}
+
+// TableProfileResponse is the data of
+// GET /sources/{source}/tables/{table}/profile
+type TableProfileResponse struct {
+	Source         string          `json:"source"`
+	Table          string          `json:"table"`
+	RowCount       int64           `json:"row_count"`
+	Columns        []ColumnProfile `json:"columns"`
+	EstimatedBytes int64           `json:"estimated_bytes,omitempty"` // Scanned by a BigQuery profile
+	CacheHit       bool            `json:"cache_hit"`
+}
+
+// costEstimator dry-runs BigQuery queries
+type costEstimator interface {
+	EstimateQueryCost(ctx context.Context, query string) (*clients.CostEstimate, error)
+}
+
+// profileDistinct is how each backend approximates the distinct values of a
+// column
+var profileDistinct = map[sqlbuilder.Dialect]string{
+	sqlbuilder.Dremio:   "NDV(%s)",
+	sqlbuilder.BigQuery: "APPROX_COUNT_DISTINCT(%s)",
+}
+
+// SetProfile sets the column limit, cache TTL and byte budget of profiles,
+// and the estimator BigQuery profiles are checked against the budget with
+func (h *TableHandler) SetProfile(cfg config.ProfileConfig, estimator *clients.QueryCostEstimator) {
+	h.profile = cfg
+	h.estimator = nil
+	if estimator != nil {
+		h.estimator = estimator
+	}
+}
+
+// Profile handles GET /api/v1/sources/{source}/tables/{table}/profile: the
+// row count of the table and, for each column of columns=a,b,c (every
+// scalar column when it is omitted), its null count, approximate distinct
+// count, minimum and maximum, from a single aggregate query. Columns are
+// checked against the table's schema. BigQuery profiles are dry-run first
+// and refused when they would scan more than the byte budget.
+func (h *TableHandler) Profile(w http.ResponseWriter, r *http.Request) {
+	sourceName := strings.ToUpper(chi.URLParam(r, "source"))
+	table := chi.URLParam(r, "table")
+
+	source, ok := h.dataSources[sourceName]
+	if !ok {
+		response.Error(w, fmt.Sprintf("Unknown data source: %s", sourceName), http.StatusNotFound)
+		return
+	}
+
+	security := h.security()
+	if !security.IsTableAllowed(table, securitySource(source.GetType())) {
+		response.Error(w, fmt.Sprintf("Table %s is not allowed for %s", table, sourceName), http.StatusForbidden)
+		return
+	}
+
+	available, err := h.profileColumns(r.Context(), security, source, table)
+	if err != nil {
+		h.logger.Error("Failed to describe table",
+			zap.String("source", sourceName),
+			zap.String("table", table),
+			zap.Error(err))
+		if !writeUpstreamError(w, err, "Failed to describe table") {
+			response.Error(w, "Failed to describe table", http.StatusInternalServerError)
+		}
+		return
+	}
+	columns, err := selectProfileColumns(available, r.URL.Query().Get("columns"), h.profile.MaxColumns)
+	if err != nil {
+		response.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	dialect := sqlbuilder.Dremio
+	if source.GetType() == datasource.DataSourceBigQuery {
+		dialect = sqlbuilder.BigQuery
+	}
+	query, err := buildProfileQuery(dialect, table, columns)
+	if err != nil {
+		response.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var estimatedBytes int64
+	if dialect == sqlbuilder.BigQuery && h.profile.MaxBytes > 0 {
+		if h.estimator == nil {
+			response.Error(w, "BigQuery profiles need cost estimation, which is not configured", http.StatusServiceUnavailable)
+			return
+		}
+		estimate, err := h.estimator.EstimateQueryCost(r.Context(), query)
+		if err != nil {
+			h.logger.Error("Failed to estimate profile cost",
+				zap.String("source", sourceName),
+				zap.String("table", table),
+				zap.Error(err))
+			if !writeUpstreamError(w, datasource.ClassifyBigQueryError(err), "Failed to estimate profile cost") {
+				response.Error(w, "Failed to estimate profile cost", http.StatusInternalServerError)
+			}
+			return
+		}
+		if estimate.EstimatedBytes > h.profile.MaxBytes {
+			response.ErrorWithCode(w, ErrCodeProfileOverBudget,
+				fmt.Sprintf("Profile would scan %.2f GB, over the budget of %.2f GB; profile fewer columns",
+					estimate.EstimatedGB, float64(h.profile.MaxBytes)/(1<<30)),
+				map[string]interface{}{"estimate": estimate, "max_bytes": h.profile.MaxBytes},
+				http.StatusUnprocessableEntity)
+			return
+		}
+		estimatedBytes = estimate.EstimatedBytes
+	}
+
+	defaults := datasource.Defaults(source)
+	result, err := source.ExecuteQuery(r.Context(), query, &datasource.QueryOptions{
+		CacheTTL: h.profile.CacheTTL,
+		Timeout:  defaults.Timeout,
+	})
+	if err != nil {
+		h.logger.Error("Failed to profile table",
+			zap.String("source", sourceName),
+			zap.String("table", table),
+			zap.Error(err))
+		if !writeUpstreamError(w, err, "Failed to profile table") {
+			response.Error(w, "Failed to profile table", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	data, err := readProfile(result.Data, columns)
+	if err != nil {
+		h.logger.Error("Unexpected profile result", zap.String("table", table), zap.Error(err))
+		response.Error(w, "Failed to profile table", http.StatusInternalServerError)
+		return
+	}
+	data.Source, data.Table = sourceName, table
+	data.EstimatedBytes, data.CacheHit = estimatedBytes, result.CacheHit
+
+	setAge(w, result)
+	response.Success(w, data, &response.Meta{Total: len(data.Columns), AgeSeconds: ageSeconds(result)})
+}
+
+// profileColumns returns the scalar columns of table: its schema when the
+// source describes one, else the columns declared in the security config
+func (h *TableHandler) profileColumns(ctx context.Context, security *config.SecurityConfig, source datasource.DataSource, table string) ([]TableColumn, error) {
+	schema, err := datasource.DescribeTable(ctx, source, table)
+	if err != nil {
+		return nil, err
+	}
+	var columns []TableColumn
+	if len(schema) > 0 {
+		columns = schemaColumns(schema)
+	} else {
+		for _, c := range security.TableColumns[table] {
+			columns = append(columns, TableColumn{Name: c.Name, Type: c.Type})
+		}
+	}
+
+	scalar := columns[:0]
+	for _, column := range columns {
+		if !column.Repeated && column.Type != config.ColumnRecord {
+			scalar = append(scalar, column)
+		}
+	}
+	return scalar, nil
+}
+
+// selectProfileColumns picks the columns named in the comma-separated
+// requested list, or all of available when it is empty, up to max
+func selectProfileColumns(available []TableColumn, requested string, max int) ([]TableColumn, error) {
+	if len(available) == 0 {
+		return nil, fmt.Errorf("table has no known columns to profile")
+	}
+	if strings.TrimSpace(requested) == "" {
+		if max > 0 && len(available) > max {
+			return nil, fmt.Errorf("table has %d columns, more than the %d a profile may cover; choose them with columns=", len(available), max)
+		}
+		return available, nil
+	}
+
+	byName := make(map[string]TableColumn, len(available))
+	for _, column := range available {
+		byName[column.Name] = column
+	}
+	var columns []TableColumn
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(requested, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		column, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("cannot profile column %q: not a scalar column of the table", name)
+		}
+		seen[name] = true
+		columns = append(columns, column)
+	}
+	if max > 0 && len(columns) > max {
+		return nil, fmt.Errorf("a profile may cover at most %d columns, %d requested", max, len(columns))
+	}
+	return columns, nil
+}
+
+// buildProfileQuery generates one aggregate over table with the row count
+// and, for the i-th column, ci_non_null, ci_distinct, ci_min and ci_max.
+// Columns come from the table's schema and are validated again here, as
+// they are written into the aggregate expressions.
+func buildProfileQuery(dialect sqlbuilder.Dialect, table string, columns []TableColumn) (string, error) {
+	builder := sqlbuilder.Select(dialect).From(table).SelectExpr("COUNT(*)", "row_count")
+	for i, column := range columns {
+		name, err := sqlbuilder.ValidateColumn(column.Name)
+		if err != nil {
+			return "", err
+		}
+		builder.
+			SelectExpr(fmt.Sprintf("COUNT(%s)", name), fmt.Sprintf("c%d_non_null", i)).
+			SelectExpr(fmt.Sprintf(profileDistinct[dialect], name), fmt.Sprintf("c%d_distinct", i)).
+			SelectExpr(fmt.Sprintf("MIN(%s)", name), fmt.Sprintf("c%d_min", i)).
+			SelectExpr(fmt.Sprintf("MAX(%s)", name), fmt.Sprintf("c%d_max", i))
+	}
+	return builder.SQL()
+}
+
+// readProfile converts the single row of a profile query to its response
+func readProfile(rows []map[string]interface{}, columns []TableColumn) (TableProfileResponse, error) {
+	if len(rows) != 1 {
+		return TableProfileResponse{}, fmt.Errorf("profile query returned %d rows", len(rows))
+	}
+	row := rows[0]
+	rowCount, err := toFloat(row["row_count"])
+	if err != nil {
+		return TableProfileResponse{}, err
+	}
+
+	profile := TableProfileResponse{RowCount: int64(rowCount), Columns: make([]ColumnProfile, len(columns))}
+	for i, column := range columns {
+		nonNull, err := toFloat(row[fmt.Sprintf("c%d_non_null", i)])
+		if err != nil {
+			return TableProfileResponse{}, err
+		}
+		distinct, err := toFloat(row[fmt.Sprintf("c%d_distinct", i)])
+		if err != nil {
+			return TableProfileResponse{}, err
+		}
+
+		stats := ColumnProfile{
+			Name:          column.Name,
+			Type:          column.Type,
+			NullCount:     profile.RowCount - int64(nonNull),
+			DistinctCount: int64(distinct),
+			Min:           profileValue(row[fmt.Sprintf("c%d_min", i)]),
+			Max:           profileValue(row[fmt.Sprintf("c%d_max", i)]),
+		}
+		if profile.RowCount > 0 {
+			stats.NullPercent = float64(stats.NullCount) * 100 / float64(profile.RowCount)
+		}
+		profile.Columns[i] = stats
+	}
+	return profile, nil
+}
+
+// profileValue returns a minimum or maximum as it is encoded, BigQuery
+// NUMERIC as a number rather than a fraction
+func profileValue(v interface{}) interface{} {
+	if rat, ok := v.(*big.Rat); ok {
+		f, _ := rat.Float64()
+		return f
+	}
+	return v
+}
@@ -0,0 +1,146 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/clients"
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+)
+
+// fixedEstimator estimates every query at bytes
+type fixedEstimator struct {
+	bytes int64
+	query string
+}
+
+func (e *fixedEstimator) EstimateQueryCost(ctx context.Context, query string) (*clients.CostEstimate, error) {
+	e.query = query
+	return &clients.CostEstimate{Query: query, EstimatedBytes: e.bytes, EstimatedGB: float64(e.bytes) / (1 << 30)}, nil
+}
+
+func newProfileRouter(sources map[string]datasource.DataSource, cfg config.ProfileConfig, estimator costEstimator) http.Handler {
+	handler := NewTableHandler(sources, testLimits, config.GetDefaultSecurityConfig, zap.NewNop())
+	handler.profile, handler.estimator = cfg, estimator
+	r := chi.NewRouter()
+	r.Get("/sources/{source}/tables/{table}/profile", handler.Profile)
+	return r
+}
+
+func getTableProfile(t *testing.T, router http.Handler, url string) (*httptest.ResponseRecorder, TableProfileResponse) {
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+
+	var body struct {
+		Data TableProfileResponse `json:"data"`
+	}
+	if rec.Code == http.StatusOK {
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	}
+	return rec, body.Data
+}
+
+func TestTableProfile_Dremio(t *testing.T) {
+	dremio := &recordingSource{sourceType: datasource.DataSourceDremio, rows: []map[string]interface{}{{
+		"row_count":   int64(200),
+		"c0_non_null": int64(150), "c0_distinct": int64(120), "c0_min": 1000.0, "c0_max": 9.5e9,
+		"c1_non_null": int64(200), "c1_distinct": int64(4), "c1_min": "Batal", "c1_max": "Selesai",
+	}}}
+	router := newProfileRouter(map[string]datasource.DataSource{"DATAWAREHOUSE": dremio}, config.DefaultProfile(), nil)
+
+	rec, data := getTableProfile(t, router, "/sources/datawarehouse/tables/nessie_iceberg.tender_data/profile?columns=nilai_pagu,status_tender,nilai_pagu")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	assert.Equal(t, "SELECT COUNT(*) AS row_count, "+
+		"COUNT(nilai_pagu) AS c0_non_null, NDV(nilai_pagu) AS c0_distinct, MIN(nilai_pagu) AS c0_min, MAX(nilai_pagu) AS c0_max, "+
+		"COUNT(status_tender) AS c1_non_null, NDV(status_tender) AS c1_distinct, MIN(status_tender) AS c1_min, MAX(status_tender) AS c1_max "+
+		"FROM nessie_iceberg.tender_data", dremio.query)
+	assert.Equal(t, 24*time.Hour, dremio.opts.CacheTTL)
+
+	assert.Equal(t, "DATAWAREHOUSE", data.Source)
+	assert.Equal(t, int64(200), data.RowCount)
+	assert.Equal(t, []ColumnProfile{
+		{Name: "nilai_pagu", Type: config.ColumnNumber, NullCount: 50, NullPercent: 25, DistinctCount: 120, Min: 1000.0, Max: 9.5e9},
+		{Name: "status_tender", Type: config.ColumnString, NullCount: 0, NullPercent: 0, DistinctCount: 4, Min: "Batal", Max: "Selesai"},
+	}, data.Columns)
+}
+
+func TestTableProfile_BigQueryBudget(t *testing.T) {
+	bq := &describingSource{
+		recordingSource: recordingSource{sourceType: datasource.DataSourceBigQuery, rows: []map[string]interface{}{{
+			"row_count":   int64(10),
+			"c0_non_null": int64(10), "c0_distinct": int64(7), "c0_min": big.NewRat(5, 2), "c0_max": big.NewRat(10, 1),
+		}}},
+		schema: []datasource.ColumnField{
+			{Name: "pagu", Type: config.ColumnNumber},
+			{Name: "peserta", Type: config.ColumnRecord, Fields: []datasource.ColumnField{{Name: "email", Type: config.ColumnString}}},
+			{Name: "lokasi", Type: config.ColumnString, Repeated: true},
+		},
+	}
+	sources := map[string]datasource.DataSource{"BIGQUERY": bq}
+	url := "/sources/bigquery/tables/gtp-data-prod.analytics.events/profile"
+	cfg := config.ProfileConfig{MaxColumns: 5, CacheTTL: time.Hour, MaxBytes: 1 << 30}
+
+	// Without columns, every scalar column of the schema is profiled
+	estimator := &fixedEstimator{bytes: 512 << 20}
+	rec, data := getTableProfile(t, newProfileRouter(sources, cfg, estimator), url)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "SELECT COUNT(*) AS row_count, COUNT(pagu) AS c0_non_null, APPROX_COUNT_DISTINCT(pagu) AS c0_distinct, "+
+		"MIN(pagu) AS c0_min, MAX(pagu) AS c0_max FROM `gtp-data-prod.analytics.events`", bq.query)
+	assert.Equal(t, bq.query, estimator.query)
+	assert.Equal(t, int64(512<<20), data.EstimatedBytes)
+	assert.Equal(t, []ColumnProfile{{Name: "pagu", Type: config.ColumnNumber, DistinctCount: 7, Min: 2.5, Max: 10.0}}, data.Columns)
+
+	// Over the budget, the estimate is returned and nothing runs
+	bq.query = ""
+	rec, _ = getTableProfile(t, newProfileRouter(sources, cfg, &fixedEstimator{bytes: 3 << 30}), url)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	errInfo := decodeResponse(t, rec).Error
+	assert.Equal(t, ErrCodeProfileOverBudget, errInfo.Code)
+	assert.Equal(t, float64(3<<30), errInfo.Details.(map[string]interface{})["estimate"].(map[string]interface{})["estimated_bytes"])
+	assert.Empty(t, bq.query)
+
+	// The budget is not skipped when nothing can estimate
+	rec, _ = getTableProfile(t, newProfileRouter(sources, cfg, nil), url)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Empty(t, bq.query)
+}
+
+func TestTableProfile_Rejections(t *testing.T) {
+	dremio := &recordingSource{sourceType: datasource.DataSourceDremio}
+	router := newProfileRouter(map[string]datasource.DataSource{"DATAWAREHOUSE": dremio},
+		config.ProfileConfig{MaxColumns: 2, CacheTTL: time.Hour}, nil)
+
+	tender := "/sources/datawarehouse/tables/nessie_iceberg.tender_data/profile"
+	tests := []struct {
+		name string
+		url  string
+		code int
+	}{
+		{"unknown source", "/sources/mysql/tables/nessie_iceberg.tender_data/profile", http.StatusNotFound},
+		{"table not whitelisted", "/sources/datawarehouse/tables/sys.users/profile", http.StatusForbidden},
+		{"unknown column", tender + "?columns=password", http.StatusBadRequest},
+		{"column injection", tender + "?columns=nilai_pagu)%2C(SELECT+1", http.StatusBadRequest},
+		{"too many columns", tender + "?columns=nilai_pagu,provinsi,nama_kl", http.StatusBadRequest},
+		{"all columns over the limit", tender, http.StatusBadRequest},
+		{"table without known columns", "/sources/datawarehouse/tables/procurement.vendor_list/profile", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, _ := getTableProfile(t, router, tt.url)
+			assert.Equal(t, tt.code, rec.Code, rec.Body.String())
+			assert.Empty(t, dremio.query)
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/response"
+	"go-data-gateway/internal/sqlbuilder"
+)
+
+// How a sample was read
+const (
+	SampleMethodTableSample = "tablesample" // BigQuery TABLESAMPLE SYSTEM
+	SampleMethodLimit       = "limit"       // The first rows of the table
+)
+
+// sampleOversample is how many times the rows asked for a BigQuery sample
+// aims to read, as TABLESAMPLE SYSTEM picks whole storage blocks and so
+// returns a varying number of rows
+const sampleOversample = 2
+
+// TableSampleResponse is the data of
+// GET /sources/{source}/tables/{table}/sample
+type TableSampleResponse struct {
+	Source    string  `json:"source"`
+	Table     string  `json:"table"`
+	Method    string  `json:"method"`            // tablesample or limit
+	Percent   float64 `json:"percent,omitempty"` // Of the table's blocks a tablesample read
+	Note      string  `json:"note,omitempty"`    // Why the sample is a limit
+	TableRows int64   `json:"table_rows,omitempty"`
+	// EstimatedBytes is what a BigQuery sample scans, from the table's
+	// metadata: its share of the blocks, or the whole table for a limit
+	EstimatedBytes int64                    `json:"estimated_bytes,omitempty"`
+	Rows           []map[string]interface{} `json:"rows"`
+	CacheHit       bool                     `json:"cache_hit"`
+}
+
+// SetSample sets the row bounds and cache TTL of samples
+func (h *TableHandler) SetSample(cfg config.SampleConfig) {
+	h.sample = cfg
+}
+
+// Sample handles GET /api/v1/sources/{source}/tables/{table}/sample?rows=N:
+// about N rows of the table, read as cheaply as the source allows. BigQuery
+// tables are read with TABLESAMPLE SYSTEM at the percent of the table's
+// rows, from its metadata, that yields about N rows; other sources and
+// tables too small to sample are read with LIMIT, in tiebreaker order where
+// the table has one, and say so in the note.
+func (h *TableHandler) Sample(w http.ResponseWriter, r *http.Request) {
+	sourceName := strings.ToUpper(chi.URLParam(r, "source"))
+	table := chi.URLParam(r, "table")
+
+	source, ok := h.dataSources[sourceName]
+	if !ok {
+		response.Error(w, fmt.Sprintf("Unknown data source: %s", sourceName), http.StatusNotFound)
+		return
+	}
+
+	if !h.security().IsTableAllowed(table, securitySource(source.GetType())) {
+		response.Error(w, fmt.Sprintf("Table %s is not allowed for %s", table, sourceName), http.StatusForbidden)
+		return
+	}
+
+	rows, err := sampleRows(r.URL.Query().Get("rows"), h.sample)
+	if err != nil {
+		response.ErrorWithDetails(w, err.Error(), fmt.Sprintf("max_rows=%d", h.sample.MaxRows), http.StatusBadRequest)
+		return
+	}
+
+	data, query, err := planSample(r.Context(), source, table, rows)
+	if err != nil {
+		h.logger.Error("Failed to plan table sample",
+			zap.String("source", sourceName),
+			zap.String("table", table),
+			zap.Error(err))
+		if !writeUpstreamError(w, err, "Failed to plan table sample") {
+			response.Error(w, "Failed to plan table sample", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	defaults := datasource.Defaults(source)
+	opts := &datasource.QueryOptions{CacheTTL: h.sample.CacheTTL, Timeout: defaults.Timeout}
+	var result *datasource.QueryResult
+	if query != "" {
+		result, err = source.ExecuteQuery(r.Context(), query, opts)
+	} else {
+		opts.Limit = rows
+		opts.Tiebreaker = h.tiebreakers.For(table)
+		if opts.Tiebreaker == "" {
+			opts.Tiebreaker = defaults.Tiebreaker
+		}
+		result, err = source.GetData(r.Context(), table, opts)
+	}
+	if err != nil {
+		h.logger.Error("Failed to sample table",
+			zap.String("source", sourceName),
+			zap.String("table", table),
+			zap.String("method", data.Method),
+			zap.Error(err))
+		if !writeUpstreamError(w, err, "Failed to sample table") {
+			response.Error(w, "Failed to sample table", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	data.Source, data.Table = sourceName, table
+	data.Rows, data.CacheHit = result.Data, result.CacheHit
+	if data.Rows == nil {
+		data.Rows = []map[string]interface{}{}
+	}
+
+	setAge(w, result)
+	response.Success(w, data, &response.Meta{Limit: rows, Total: len(data.Rows), AgeSeconds: ageSeconds(result)})
+}
+
+// sampleRows parses rows=, applying the default and the maximum
+func sampleRows(raw string, cfg config.SampleConfig) (int, error) {
+	if raw == "" {
+		return cfg.DefaultRows, nil
+	}
+	rows, err := strconv.Atoi(raw)
+	if err != nil || rows < 1 {
+		return 0, fmt.Errorf("rows must be a positive integer")
+	}
+	if cfg.MaxRows > 0 && rows > cfg.MaxRows {
+		return 0, fmt.Errorf("rows must not exceed %d", cfg.MaxRows)
+	}
+	return rows, nil
+}
+
+// planSample decides how to read a sample of rows rows of table. It returns
+// the response without its rows and, for a tablesample, its query; an empty
+// query reads the sample with GetData and a limit.
+func planSample(ctx context.Context, source datasource.DataSource, table string, rows int) (TableSampleResponse, string, error) {
+	plan := TableSampleResponse{Method: SampleMethodLimit}
+	if source.GetType() != datasource.DataSourceBigQuery {
+		plan.Note = "the source cannot sample tables; these are the first rows"
+		return plan, "", nil
+	}
+
+	stats, err := datasource.TableSize(ctx, source, table)
+	if err != nil {
+		return plan, "", err
+	}
+	if stats == nil || stats.Rows == 0 {
+		plan.Note = "the table's size is unknown; these are the first rows"
+		return plan, "", nil
+	}
+	plan.TableRows = stats.Rows
+	plan.EstimatedBytes = stats.Bytes
+
+	percent := samplePercent(float64(sampleOversample*rows) * 100 / float64(stats.Rows))
+	if percent >= 100 {
+		plan.Note = "the table is too small to sample; these are the first rows"
+		return plan, "", nil
+	}
+	query, err := sqlbuilder.Select(sqlbuilder.BigQuery).From(table).Sample(percent).Limit(rows).SQL()
+	if err != nil {
+		return plan, "", err
+	}
+	plan.Method, plan.Percent = SampleMethodTableSample, percent
+	plan.EstimatedBytes = int64(math.Ceil(float64(stats.Bytes) * percent / 100))
+	return plan, query, nil
+}
+
+// samplePercent rounds percent up to two significant digits, so samples of
+// similar sizes share a query and its cached result
+func samplePercent(percent float64) float64 {
+	scale := math.Pow(10, 1-math.Floor(math.Log10(percent)))
+	return math.Ceil(percent*scale) / scale
+}
@@ -0,0 +1,130 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+)
+
+// sizedSource reports a fixed table size
+type sizedSource struct {
+	recordingSource
+	stats *datasource.TableStats
+}
+
+func (s *sizedSource) TableSize(ctx context.Context, table string) (*datasource.TableStats, error) {
+	return s.stats, nil
+}
+
+func getTableSample(t *testing.T, sources map[string]datasource.DataSource, url string) (*httptest.ResponseRecorder, TableSampleResponse) {
+	handler := NewTableHandler(sources, testLimits, config.GetDefaultSecurityConfig, zap.NewNop())
+	handler.SetSample(config.SampleConfig{DefaultRows: 100, MaxRows: 500, CacheTTL: time.Hour})
+	r := chi.NewRouter()
+	r.Get("/sources/{source}/tables/{table}/sample", handler.Sample)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+
+	var body struct {
+		Data TableSampleResponse `json:"data"`
+	}
+	if rec.Code == http.StatusOK {
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	}
+	return rec, body.Data
+}
+
+func TestTableSample_BigQueryTableSample(t *testing.T) {
+	bq := &sizedSource{
+		recordingSource: recordingSource{sourceType: datasource.DataSourceBigQuery, rows: rowsOf(3)},
+		stats:           &datasource.TableStats{Rows: 7_000_000, Bytes: 40 << 30},
+	}
+	sources := map[string]datasource.DataSource{"BIGQUERY": bq}
+
+	rec, data := getTableSample(t, sources, "/sources/bigquery/tables/gtp-data-prod.analytics.events/sample?rows=250")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	// 500 of 7M rows is 0.00714%, rounded up to two digits
+	assert.Equal(t, "SELECT * FROM `gtp-data-prod.analytics.events` TABLESAMPLE SYSTEM (0.0072 PERCENT) LIMIT 250", bq.query)
+	assert.Equal(t, time.Hour, bq.opts.CacheTTL)
+	assert.Equal(t, SampleMethodTableSample, data.Method)
+	assert.Equal(t, 0.0072, data.Percent)
+	assert.Equal(t, int64(7_000_000), data.TableRows)
+	assert.Equal(t, int64(3092377), data.EstimatedBytes)
+	assert.Empty(t, data.Note)
+	assert.Len(t, data.Rows, 3)
+
+	// A table smaller than the sample is read whole, with a limit
+	bq.stats = &datasource.TableStats{Rows: 150, Bytes: 1 << 20}
+	rec, data = getTableSample(t, sources, "/sources/bigquery/tables/gtp-data-prod.analytics.events/sample")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "gtp-data-prod.analytics.events", bq.query)
+	assert.Equal(t, 100, bq.opts.Limit)
+	assert.Equal(t, SampleMethodLimit, data.Method)
+	assert.Contains(t, data.Note, "too small to sample")
+	assert.Equal(t, int64(1<<20), data.EstimatedBytes)
+}
+
+func TestTableSample_LimitFallback(t *testing.T) {
+	dremio := &recordingSource{sourceType: datasource.DataSourceDremio, rows: rowsOf(2)}
+	bq := &recordingSource{sourceType: datasource.DataSourceBigQuery, rows: rowsOf(2)}
+	sources := map[string]datasource.DataSource{"DATAWAREHOUSE": dremio, "BIGQUERY": bq}
+
+	// Dremio reads the first rows in tiebreaker order
+	rec, data := getTableSample(t, sources, "/sources/datawarehouse/tables/nessie_iceberg.tender_data/sample?rows=20")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "nessie_iceberg.tender_data", dremio.query)
+	assert.Equal(t, 20, dremio.opts.Limit)
+	assert.Equal(t, "tender_id", dremio.opts.Tiebreaker)
+	assert.Equal(t, SampleMethodLimit, data.Method)
+	assert.Contains(t, data.Note, "cannot sample")
+	assert.Zero(t, data.EstimatedBytes)
+
+	// A BigQuery source that cannot report the table's size
+	rec, data = getTableSample(t, sources, "/sources/bigquery/tables/gtp-data-prod.analytics.events/sample?rows=20")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, 20, bq.opts.Limit)
+	assert.Equal(t, SampleMethodLimit, data.Method)
+	assert.Contains(t, data.Note, "size is unknown")
+}
+
+func TestTableSample_Rejections(t *testing.T) {
+	sources := map[string]datasource.DataSource{"DATAWAREHOUSE": &recordingSource{sourceType: datasource.DataSourceDremio}}
+
+	tender := "/sources/datawarehouse/tables/nessie_iceberg.tender_data/sample"
+	tests := []struct {
+		name string
+		url  string
+		code int
+	}{
+		{"unknown source", "/sources/mysql/tables/nessie_iceberg.tender_data/sample", http.StatusNotFound},
+		{"table not whitelisted", "/sources/datawarehouse/tables/sys.users/sample", http.StatusForbidden},
+		{"rows over the maximum", tender + "?rows=501", http.StatusBadRequest},
+		{"rows not a number", tender + "?rows=all", http.StatusBadRequest},
+		{"no rows", tender + "?rows=0", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, _ := getTableSample(t, sources, tt.url)
+			assert.Equal(t, tt.code, rec.Code, rec.Body.String())
+		})
+	}
+}
+
+func TestSamplePercent(t *testing.T) {
+	assert.Equal(t, 0.0072, samplePercent(0.007142857))
+	assert.Equal(t, 46.0, samplePercent(45.2))
+	assert.Equal(t, 5.0, samplePercent(5))
+	assert.Equal(t, 100.0, samplePercent(99.5))
+}
@@ -0,0 +1,460 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/cache"
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/response"
+)
+
+// TableColumn describes a column of a browsed table
+type TableColumn struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	Filterable bool   `json:"filterable"` // Usable in filter and order_by
+
+	// Arrays are repeated; records list their fields
+	Repeated bool          `json:"repeated,omitempty"`
+	Fields   []TableColumn `json:"fields,omitempty"`
+
+	// EncodingString for number columns whose values are JSON strings
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// EncodingString is the Encoding of number columns sent as strings, their
+// precision being more than a float64 holds
+const EncodingString = "string"
+
+// markStringNumbers marks the columns named in stringNumbers as numbers sent
+// as strings
+func markStringNumbers(columns []TableColumn, stringNumbers []string) []TableColumn {
+	for i := range columns {
+		if slices.Contains(stringNumbers, columns[i].Name) {
+			columns[i].Type, columns[i].Encoding = config.ColumnNumber, EncodingString
+		}
+	}
+	return columns
+}
+
+// TableRowsResponse is the data of GET /sources/{source}/tables/{table}/rows
+type TableRowsResponse struct {
+	Source   string                   `json:"source"`
+	Table    string                   `json:"table"`
+	Columns  []TableColumn            `json:"columns"`
+	Rows     []map[string]interface{} `json:"rows"`
+	CacheHit bool                     `json:"cache_hit"`
+}
+
+// TableHandler browses whitelisted tables through DataSource.GetData, so the
+// sanitizer and cache of each source apply
+type TableHandler struct {
+	dataSources map[string]datasource.DataSource
+	limits      config.PageLimit
+	security    config.SecurityProvider
+	tiebreakers config.Tiebreakers
+	timeTravel  *timeTravel
+	profile     config.ProfileConfig
+	sample      config.SampleConfig
+	estimator   costEstimator
+	logger      *zap.Logger
+}
+
+// NewTableHandler creates a new table browsing handler
+func NewTableHandler(dataSources map[string]datasource.DataSource, limits config.PageLimit, security config.SecurityProvider, logger *zap.Logger) *TableHandler {
+	return &TableHandler{
+		dataSources: dataSources,
+		limits:      limits,
+		security:    security,
+		tiebreakers: config.DefaultTiebreakers(),
+		timeTravel:  newTimeTravel(logger),
+		profile:     config.DefaultProfile(),
+		sample:      config.DefaultSample(),
+		logger:      logger,
+	}
+}
+
+// SetTiebreakers sets the columns pages of each table are ordered by after
+// order_by; tables without one are paged in the order the source returns
+func (h *TableHandler) SetTiebreakers(tiebreakers config.Tiebreakers) {
+	h.tiebreakers = tiebreakers
+}
+
+// SetTimeTravel sets the retention window of as_of and whether it may read
+// a branch other than the default
+func (h *TableHandler) SetTimeTravel(cfg config.TimeTravelConfig) {
+	h.timeTravel.config = cfg
+}
+
+// Rows handles GET /api/v1/sources/{source}/tables/{table}/rows
+func (h *TableHandler) Rows(w http.ResponseWriter, r *http.Request) {
+	sourceName := strings.ToUpper(chi.URLParam(r, "source"))
+	table := chi.URLParam(r, "table")
+
+	source, ok := h.dataSources[sourceName]
+	if !ok {
+		response.Error(w, fmt.Sprintf("Unknown data source: %s", sourceName), http.StatusNotFound)
+		return
+	}
+
+	// One snapshot for the request, so a policy reload cannot split it
+	security := h.security()
+	if !security.IsTableAllowed(table, securitySource(source.GetType())) {
+		response.Error(w, fmt.Sprintf("Table %s is not allowed for %s", table, sourceName), http.StatusForbidden)
+		return
+	}
+
+	defaults := datasource.Defaults(source)
+	limit, ok := queryLimit(w, r, sourceLimit(h.limits, defaults))
+	if !ok {
+		return
+	}
+
+	opts, err := h.parseOptions(r, security, table, defaults)
+	if err != nil {
+		response.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	opts.Limit = limit
+	opts.Tiebreaker = h.tiebreakers.For(table)
+	if opts.Tiebreaker == "" {
+		opts.Tiebreaker = defaults.Tiebreaker
+	}
+
+	rawAsOf := r.URL.Query().Get(asOfParam)
+	if rawAsOf != "" && source.GetType() != datasource.DataSourceDremio {
+		response.Error(w, fmt.Sprintf("%s applies to Dremio sources only", asOfParam), http.StatusBadRequest)
+		return
+	}
+	asOf, err := h.timeTravel.parse(r.Context(), rawAsOf)
+	if err != nil {
+		response.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	opts.AsOf, opts.AsOfBranch = asOf.At, asOf.Branch
+
+	debugMode, ok := sqlDebug(w, r)
+	if !ok {
+		return
+	}
+	var debug *response.QueryDebug
+	if debugMode != sqlDebugOff {
+		plan, err := datasource.PlanTable(r.Context(), source, table, opts)
+		if err != nil {
+			h.logger.Error("Failed to plan table rows",
+				zap.String("source", sourceName),
+				zap.String("table", table),
+				zap.Error(err))
+			if !writeUpstreamError(w, err, "Failed to plan table rows") {
+				response.Error(w, "Failed to plan table rows", http.StatusInternalServerError)
+			}
+			return
+		}
+		debug = &response.QueryDebug{SQL: plan.SQL, Params: optionParams(opts), CacheKey: plan.CacheKey}
+		if debugMode == sqlDebugDryRun {
+			response.Success(w, debug, nil)
+			return
+		}
+	}
+
+	result, err := source.GetData(r.Context(), table, opts)
+	if err != nil {
+		h.logger.Error("Failed to fetch table rows",
+			zap.String("source", sourceName),
+			zap.String("table", table),
+			zap.Error(err))
+		if !writeUpstreamError(w, err, "Failed to fetch table rows") {
+			response.Error(w, "Failed to fetch table rows", http.StatusInternalServerError)
+		}
+		return
+	}
+	h.timeTravel.record(r, table, asOf)
+
+	data := TableRowsResponse{
+		Source:   sourceName,
+		Table:    table,
+		Columns:  markStringNumbers(h.columns(r.Context(), security, source, table, result.Data), datasource.StringNumbers(result)),
+		Rows:     result.Data,
+		CacheHit: result.CacheHit,
+	}
+	// The count is of this page's rows, not a total, so the pages are
+	// open-ended
+	meta := &response.Meta{
+		Total:      result.Count,
+		Limit:      limit,
+		AgeSeconds: ageSeconds(result),
+		Order:      datasource.OrderTerms(opts),
+		Branch:     branchOf(r.Context(), result),
+		AsOf:       asOf.meta(),
+		Debug:      debug,
+		CacheWrite: cache.CacheWrite(result),
+		BigQuery:   bigQueryJob(result),
+
+		SchemaFingerprint: datasource.SchemaFingerprint(result),
+		StringNumbers:     datasource.StringNumbers(result),
+	}
+	paginate(w, r, meta, page{offset: opts.Offset, limit: limit, rows: len(result.Data)})
+
+	setAge(w, result)
+	response.Success(w, data, meta)
+}
+
+// parseOptions reads offset, order_by, order_dir and repeated filter
+// parameters: filter=column=value, or filter=column:op=value with op one of
+// eq, in (comma-separated values), gte, lte and like. Columns must be
+// declared for the table in the security config; values are converted to the
+// column's type. The cache TTL and timeout are the source's defaults.
+func (h *TableHandler) parseOptions(r *http.Request, security *config.SecurityConfig, table string, defaults config.QueryDefaults) (*datasource.QueryOptions, error) {
+	q := r.URL.Query()
+	maxAge, err := queryMaxAge(r)
+	if err != nil {
+		return nil, err
+	}
+	opts := &datasource.QueryOptions{
+		CacheTTL: defaults.CacheTTL,
+		Timeout:  defaults.Timeout,
+		MaxAge:   maxAge,
+	}
+
+	if raw := q.Get("offset"); raw != "" {
+		offset, err := strconv.Atoi(raw)
+		if err != nil || offset < 0 {
+			return nil, fmt.Errorf("offset must be a non-negative integer")
+		}
+		opts.Offset = offset
+	}
+
+	if orderBy := q.Get("order_by"); orderBy != "" {
+		if _, ok := security.Column(table, orderBy); !ok {
+			return nil, fmt.Errorf("cannot order by column %q", orderBy)
+		}
+		opts.OrderBy = orderBy
+
+		switch dir := strings.ToUpper(q.Get("order_dir")); dir {
+		case "", "ASC", "DESC":
+			opts.OrderDir = dir
+		default:
+			return nil, fmt.Errorf("order_dir must be asc or desc")
+		}
+	}
+
+	specs := make(map[string][]datasource.FilterSpec)
+	for _, raw := range q["filter"] {
+		target, value, ok := strings.Cut(raw, "=")
+		if !ok {
+			return nil, fmt.Errorf("filter %q must be column=value or column:op=value", raw)
+		}
+		name, op, _ := strings.Cut(target, ":")
+		if op == "" {
+			op = datasource.FilterEq
+		}
+
+		column, ok := security.Column(table, name)
+		if !ok {
+			return nil, fmt.Errorf("cannot filter on column %q", name)
+		}
+		for _, existing := range specs[name] {
+			if existing.Op == op {
+				return nil, fmt.Errorf("column %q has more than one %s filter", name, op)
+			}
+		}
+
+		spec, err := filterSpec(column, op, value)
+		if err != nil {
+			return nil, err
+		}
+		specs[name] = append(specs[name], spec)
+	}
+
+	if len(specs) > 0 {
+		opts.Filters = make(map[string]interface{}, len(specs))
+	}
+	for name, columnSpecs := range specs {
+		if len(columnSpecs) == 1 {
+			opts.Filters[name] = columnSpecs[0]
+		} else {
+			opts.Filters[name] = columnSpecs
+		}
+	}
+
+	return opts, nil
+}
+
+// filterSpec builds the filter of one parameter, converting its value to the
+// column's type
+func filterSpec(column config.ColumnSpec, op, value string) (datasource.FilterSpec, error) {
+	switch op {
+	case datasource.FilterEq, datasource.FilterGte, datasource.FilterLte:
+		converted, err := filterValue(column, value)
+		if err != nil {
+			return datasource.FilterSpec{}, err
+		}
+		return datasource.FilterSpec{Op: op, Value: converted}, nil
+
+	case datasource.FilterIn:
+		parts := strings.Split(value, ",")
+		values := make([]interface{}, len(parts))
+		for i, part := range parts {
+			converted, err := filterValue(column, part)
+			if err != nil {
+				return datasource.FilterSpec{}, err
+			}
+			values[i] = converted
+		}
+		return datasource.FilterSpec{Op: op, Value: values}, nil
+
+	case datasource.FilterLike:
+		if column.Type != config.ColumnString {
+			return datasource.FilterSpec{}, fmt.Errorf("like filters only apply to string columns, %s is %s", column.Name, column.Type)
+		}
+		return datasource.FilterSpec{Op: op, Value: value}, nil
+
+	default:
+		return datasource.FilterSpec{}, fmt.Errorf("unknown filter operator %q (use eq, in, gte, lte or like)", op)
+	}
+}
+
+// filterValue converts a filter value to the type of its column
+func filterValue(column config.ColumnSpec, value string) (interface{}, error) {
+	switch column.Type {
+	case config.ColumnNumber:
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n, nil
+		}
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("filter on %s must be a number", column.Name)
+		}
+		return f, nil
+	case config.ColumnBoolean:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("filter on %s must be true or false", column.Name)
+		}
+		return b, nil
+	case config.ColumnDate:
+		if _, err := time.Parse("2006-01-02", value); err != nil {
+			return nil, fmt.Errorf("filter on %s must be a date (YYYY-MM-DD)", column.Name)
+		}
+		return value, nil
+	default:
+		return value, nil
+	}
+}
+
+// columns reports the declared columns of table, or for a table without
+// declarations its schema when the source describes one, else the columns of
+// the returned rows. Only declared columns are filterable. Record columns
+// list their fields, from the schema when there is one.
+func (h *TableHandler) columns(ctx context.Context, security *config.SecurityConfig, source datasource.DataSource, table string, rows []map[string]interface{}) []TableColumn {
+	schema, err := datasource.DescribeTable(ctx, source, table)
+	if err != nil {
+		h.logger.Warn("Failed to describe table", zap.String("table", table), zap.Error(err))
+	}
+
+	if declared := security.TableColumns[table]; len(declared) > 0 {
+		described := make(map[string]datasource.ColumnField, len(schema))
+		for _, field := range schema {
+			described[field.Name] = field
+		}
+		columns := make([]TableColumn, len(declared))
+		for i, c := range declared {
+			columns[i] = TableColumn{Name: c.Name, Type: c.Type, Filterable: true}
+			if field, ok := described[c.Name]; ok {
+				columns[i].Repeated, columns[i].Fields = field.Repeated, schemaColumns(field.Fields)
+			}
+		}
+		return columns
+	}
+	if len(schema) > 0 {
+		return schemaColumns(schema)
+	}
+
+	if len(rows) == 0 {
+		return []TableColumn{}
+	}
+	return inferColumns(rows[0])
+}
+
+// schemaColumns converts described fields to columns, none filterable
+func schemaColumns(fields []datasource.ColumnField) []TableColumn {
+	if len(fields) == 0 {
+		return nil
+	}
+	columns := make([]TableColumn, len(fields))
+	for i, field := range fields {
+		columns[i] = TableColumn{
+			Name:     field.Name,
+			Type:     field.Type,
+			Repeated: field.Repeated,
+			Fields:   schemaColumns(field.Fields),
+		}
+	}
+	return columns
+}
+
+// inferColumns describes the columns of row by the values it holds, sorted
+// by name. Arrays take the type of their first non-null element.
+func inferColumns(row map[string]interface{}) []TableColumn {
+	columns := make([]TableColumn, 0, len(row))
+	for name, value := range row {
+		column := TableColumn{Name: name}
+		if array, ok := value.([]interface{}); ok {
+			column.Repeated = true
+			value = nil
+			for _, element := range array {
+				if element != nil {
+					value = element
+					break
+				}
+			}
+		}
+		column.Type = inferColumnType(value)
+		if record, ok := value.(map[string]interface{}); ok {
+			column.Fields = inferColumns(record)
+		}
+		columns = append(columns, column)
+	}
+	sort.Slice(columns, func(i, j int) bool { return columns[i].Name < columns[j].Name })
+	return columns
+}
+
+func inferColumnType(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return config.ColumnRecord
+	case int, int32, int64, float32, float64, json.Number:
+		return config.ColumnNumber
+	case bool:
+		return config.ColumnBoolean
+	case time.Time:
+		return config.ColumnDate
+	default:
+		return config.ColumnString
+	}
+}
+
+// securitySource maps a source type to its SecurityConfig whitelist
+func securitySource(sourceType datasource.DataSourceType) string {
+	switch sourceType {
+	case datasource.DataSourceDremio:
+		return "dremio"
+	case datasource.DataSourceBigQuery:
+		return "bigquery"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,260 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+)
+
+func newTableRouter(sources map[string]datasource.DataSource) http.Handler {
+	handler := NewTableHandler(sources, testLimits, config.GetDefaultSecurityConfig, zap.NewNop())
+	r := chi.NewRouter()
+	r.Get("/sources/{source}/tables/{table}/rows", handler.Rows)
+	return r
+}
+
+func getTableRows(t *testing.T, router http.Handler, url string) (*httptest.ResponseRecorder, TableRowsResponse) {
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+
+	var body struct {
+		Data TableRowsResponse `json:"data"`
+	}
+	if rec.Code == http.StatusOK {
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	}
+	return rec, body.Data
+}
+
+func TestTableRows_Dremio(t *testing.T) {
+	dremio := &recordingSource{sourceType: datasource.DataSourceDremio, rows: rowsOf(3)}
+	router := newTableRouter(map[string]datasource.DataSource{"DATAWAREHOUSE": dremio})
+
+	rec, data := getTableRows(t, router, "/sources/datawarehouse/tables/nessie_iceberg.tender_data/rows"+
+		"?limit=20&offset=40&order_by=nilai_pagu&order_dir=desc"+
+		"&filter=status_tender=Selesai&filter=tahun_anggaran=2025&filter=tanggal_pengumuman=2025-01-31")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	// The table goes to GetData unchanged; filters are typed by column
+	assert.Equal(t, "nessie_iceberg.tender_data", dremio.query)
+	assert.Equal(t, 20, dremio.opts.Limit)
+	assert.Equal(t, 40, dremio.opts.Offset)
+	assert.Equal(t, "nilai_pagu", dremio.opts.OrderBy)
+	assert.Equal(t, "DESC", dremio.opts.OrderDir)
+	assert.Equal(t, map[string]interface{}{
+		"status_tender":      datasource.FilterSpec{Op: datasource.FilterEq, Value: "Selesai"},
+		"tahun_anggaran":     datasource.FilterSpec{Op: datasource.FilterEq, Value: int64(2025)},
+		"tanggal_pengumuman": datasource.FilterSpec{Op: datasource.FilterEq, Value: "2025-01-31"},
+	}, dremio.opts.Filters)
+
+	assert.Equal(t, "DATAWAREHOUSE", data.Source)
+	assert.Len(t, data.Rows, 3)
+	assert.Contains(t, data.Columns, TableColumn{Name: "nilai_pagu", Type: config.ColumnNumber, Filterable: true})
+
+	meta := decodeResponse(t, rec).Meta
+	require.NotNil(t, meta)
+	assert.Equal(t, 3, meta.Page)
+	assert.Equal(t, 20, meta.Limit)
+	assert.Equal(t, 3, meta.Total)
+}
+
+func TestTableRows_BigQuery(t *testing.T) {
+	bq := &recordingSource{sourceType: datasource.DataSourceBigQuery, rows: rowsOf(2)}
+	router := newTableRouter(map[string]datasource.DataSource{"BIGQUERY": bq})
+
+	rec, data := getTableRows(t, router, "/sources/BIGQUERY/tables/gtp-data-prod.layer_isb.rup_kromaster/rows"+
+		"?filter=is_deleted=false&filter=pagu_kro=1.5e9&filter=kd_klpd=K12")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	assert.Equal(t, "gtp-data-prod.layer_isb.rup_kromaster", bq.query)
+	assert.Equal(t, testLimits.Default, bq.opts.Limit)
+	assert.Equal(t, map[string]interface{}{
+		"is_deleted": datasource.FilterSpec{Op: datasource.FilterEq, Value: false},
+		"pagu_kro":   datasource.FilterSpec{Op: datasource.FilterEq, Value: 1.5e9},
+		"kd_klpd":    datasource.FilterSpec{Op: datasource.FilterEq, Value: "K12"},
+	}, bq.opts.Filters)
+	assert.Contains(t, data.Columns, TableColumn{Name: "_event_date", Type: config.ColumnDate, Filterable: true})
+}
+
+func TestTableRows_FilterOperators(t *testing.T) {
+	dremio := &recordingSource{sourceType: datasource.DataSourceDremio, rows: rowsOf(1)}
+	router := newTableRouter(map[string]datasource.DataSource{"DATAWAREHOUSE": dremio})
+
+	rec, _ := getTableRows(t, router, "/sources/datawarehouse/tables/nessie_iceberg.tender_data/rows"+
+		"?filter=nilai_pagu:gte=1000000&filter=nilai_pagu:lte=5e9"+
+		"&filter=provinsi:in=Aceh,Bali&filter=tahun_anggaran:in=2024,2025"+
+		"&filter=nama_paket:like=%25jalan%25")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	assert.Equal(t, map[string]interface{}{
+		"nilai_pagu": []datasource.FilterSpec{
+			{Op: datasource.FilterGte, Value: int64(1000000)},
+			{Op: datasource.FilterLte, Value: 5e9},
+		},
+		"provinsi":       datasource.FilterSpec{Op: datasource.FilterIn, Value: []interface{}{"Aceh", "Bali"}},
+		"tahun_anggaran": datasource.FilterSpec{Op: datasource.FilterIn, Value: []interface{}{int64(2024), int64(2025)}},
+		"nama_paket":     datasource.FilterSpec{Op: datasource.FilterLike, Value: "%jalan%"},
+	}, dremio.opts.Filters)
+
+	// The specs build a valid WHERE clause
+	where, err := datasource.NewSQLSanitizer().BuildWhereClause(dremio.opts.Filters)
+	require.NoError(t, err)
+	assert.Equal(t, " WHERE nama_paket LIKE '%jalan%' AND nilai_pagu >= 1000000 AND nilai_pagu <= 5000000000"+
+		" AND provinsi IN ('Aceh', 'Bali') AND tahun_anggaran IN (2024, 2025)", where)
+}
+
+func TestTableRows_UndeclaredColumnsInferred(t *testing.T) {
+	bq := &recordingSource{sourceType: datasource.DataSourceBigQuery, rows: rowsOf(1)}
+	router := newTableRouter(map[string]datasource.DataSource{"BIGQUERY": bq})
+
+	rec, data := getTableRows(t, router, "/sources/bigquery/tables/gtp-data-prod.analytics.events/rows")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []TableColumn{{Name: "id", Type: config.ColumnNumber}}, data.Columns)
+
+	// Without declared columns nothing can be filtered
+	rec, _ = getTableRows(t, router, "/sources/bigquery/tables/gtp-data-prod.analytics.events/rows?filter=id=1")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+// stringNumbersSource is recordingSource whose results record columns sent
+// as strings
+type stringNumbersSource struct {
+	recordingSource
+	columns []string
+}
+
+func (s *stringNumbersSource) GetData(ctx context.Context, table string, opts *datasource.QueryOptions) (*datasource.QueryResult, error) {
+	result, err := s.recordingSource.GetData(ctx, table, opts)
+	if err == nil {
+		result.Metadata = map[string]interface{}{datasource.MetaStringNumbers: s.columns}
+	}
+	return result, err
+}
+
+func TestTableRows_StringNumbers(t *testing.T) {
+	bq := &stringNumbersSource{
+		recordingSource: recordingSource{
+			sourceType: datasource.DataSourceBigQuery,
+			rows:       []map[string]interface{}{{"nilai_pagu": "123456789012345678.123456789", "kode": int64(123456789012345678)}},
+		},
+		columns: []string{"nilai_pagu"},
+	}
+	router := newTableRouter(map[string]datasource.DataSource{"BIGQUERY": bq})
+
+	rec, data := getTableRows(t, router, "/sources/bigquery/tables/gtp-data-prod.analytics.events/rows")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, []TableColumn{
+		{Name: "kode", Type: config.ColumnNumber},
+		{Name: "nilai_pagu", Type: config.ColumnNumber, Encoding: EncodingString},
+	}, data.Columns)
+	assert.Contains(t, rec.Body.String(), `"kode":123456789012345678`)
+	assert.Equal(t, []string{"nilai_pagu"}, decodeResponse(t, rec).Meta.StringNumbers)
+}
+
+func TestTableRows_Rejections(t *testing.T) {
+	dremio := &recordingSource{sourceType: datasource.DataSourceDremio, rows: rowsOf(1)}
+	bq := &recordingSource{sourceType: datasource.DataSourceBigQuery, rows: rowsOf(1)}
+	router := newTableRouter(map[string]datasource.DataSource{"DATAWAREHOUSE": dremio, "BIGQUERY": bq})
+
+	tender := "/sources/datawarehouse/tables/nessie_iceberg.tender_data/rows"
+	tests := []struct {
+		name string
+		url  string
+		code int
+	}{
+		{"unknown source", "/sources/mysql/tables/nessie_iceberg.tender_data/rows", http.StatusNotFound},
+		{"table not whitelisted", "/sources/datawarehouse/tables/sys.users/rows", http.StatusForbidden},
+		{"table of the other source", "/sources/bigquery/tables/nessie_iceberg.tender_data/rows", http.StatusForbidden},
+		{"limit over max", tender + "?limit=51", http.StatusBadRequest},
+		{"negative offset", tender + "?offset=-1", http.StatusBadRequest},
+		{"unknown order column", tender + "?order_by=password", http.StatusBadRequest},
+		{"order injection", tender + "?order_by=nilai_pagu%3B+DROP+TABLE+x", http.StatusBadRequest},
+		{"bad order direction", tender + "?order_by=nilai_pagu&order_dir=sideways", http.StatusBadRequest},
+		{"unknown filter column", tender + "?filter=password=x", http.StatusBadRequest},
+		{"filter without value", tender + "?filter=status_tender", http.StatusBadRequest},
+		{"non-numeric number", tender + "?filter=nilai_pagu=1%20OR%201%3D1", http.StatusBadRequest},
+		{"NaN number", tender + "?filter=nilai_pagu=NaN", http.StatusBadRequest},
+		{"bad date", tender + "?filter=tanggal_pengumuman=yesterday", http.StatusBadRequest},
+		{"duplicate filter", tender + "?filter=provinsi=Aceh&filter=provinsi=Bali", http.StatusBadRequest},
+		{"unknown operator", tender + "?filter=nilai_pagu:gt=1", http.StatusBadRequest},
+		{"like on a number", tender + "?filter=nilai_pagu:like=1%25", http.StatusBadRequest},
+		{"bad number in list", tender + "?filter=tahun_anggaran:in=2024,x", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dremio.query, bq.query = "", ""
+			rec, _ := getTableRows(t, router, tt.url)
+			assert.Equal(t, tt.code, rec.Code, rec.Body.String())
+			assert.Empty(t, dremio.query)
+			assert.Empty(t, bq.query)
+		})
+	}
+}
+
+func TestTableRows_UpstreamError(t *testing.T) {
+	source := &failingSource{
+		recordingSource: recordingSource{sourceType: datasource.DataSourceDremio},
+		err:             &datasource.UpstreamError{Class: datasource.ErrorClassTableNotFound, Message: "Object 'tender_data' not found"},
+	}
+	router := newTableRouter(map[string]datasource.DataSource{"DATAWAREHOUSE": source})
+
+	rec, _ := getTableRows(t, router, "/sources/datawarehouse/tables/nessie_iceberg.tender_data/rows")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, string(datasource.ErrorClassTableNotFound), decodeResponse(t, rec).Error.Code)
+}
+
+// describingSource reports a fixed table schema
+type describingSource struct {
+	recordingSource
+	schema []datasource.ColumnField
+}
+
+func (s *describingSource) DescribeTable(ctx context.Context, table string) ([]datasource.ColumnField, error) {
+	return s.schema, nil
+}
+
+func TestTableRows_NestedColumnsDescribed(t *testing.T) {
+	peserta := datasource.ColumnField{Name: "peserta", Type: config.ColumnRecord, Repeated: true, Fields: []datasource.ColumnField{
+		{Name: "email", Type: config.ColumnString},
+		{Name: "nilai", Type: config.ColumnNumber},
+	}}
+	bq := &describingSource{
+		recordingSource: recordingSource{sourceType: datasource.DataSourceBigQuery, rows: nestedRows},
+		schema:          []datasource.ColumnField{{Name: "kode_rup", Type: config.ColumnString}, peserta},
+	}
+	router := newTableRouter(map[string]datasource.DataSource{"BIGQUERY": bq})
+
+	rec, data := getTableRows(t, router, "/sources/bigquery/tables/gtp-data-prod.analytics.events/rows")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []TableColumn{
+		{Name: "kode_rup", Type: config.ColumnString},
+		{Name: "peserta", Type: config.ColumnRecord, Repeated: true, Fields: []TableColumn{
+			{Name: "email", Type: config.ColumnString},
+			{Name: "nilai", Type: config.ColumnNumber},
+		}},
+	}, data.Columns)
+
+	// Without a schema, nested columns are inferred from the first row
+	bq.schema = nil
+	rec, data = getTableRows(t, router, "/sources/bigquery/tables/gtp-data-prod.analytics.events/rows")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []TableColumn{
+		{Name: "kode_rup", Type: config.ColumnString},
+		{Name: "lokasi", Type: config.ColumnString, Repeated: true},
+		{Name: "peserta", Type: config.ColumnRecord, Fields: []TableColumn{
+			{Name: "email", Type: config.ColumnString},
+			{Name: "nama", Type: config.ColumnString},
+		}},
+	}, data.Columns)
+	assert.Equal(t, map[string]interface{}{"nama": "CV Maju", "email": "maju@example.id"}, data.Rows[0]["peserta"])
+}
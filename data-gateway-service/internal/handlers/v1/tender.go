@@ -1,32 +1,140 @@
 package v1
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
 
+	"go-data-gateway/internal/cache"
+	"go-data-gateway/internal/config"
 	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/metrics"
 	"go-data-gateway/internal/response"
+	"go-data-gateway/internal/sqlbuilder"
+	"go-data-gateway/pkg/apitypes"
 )
 
+// tenderTable is the table behind the tender endpoints
+const tenderTable = "nessie_iceberg.tender_data"
+
+// tenderSummaryColumns are the columns of the tender list and of a tender
+// with view=summary
+var tenderSummaryColumns = []string{
+	"tender_id",
+	"nama_paket",
+	"nilai_pagu",
+	"metode_pengadaan",
+	"tahun_anggaran",
+	"status_tender",
+	"tanggal_buat_paket",
+	"tanggal_pengumuman",
+	"provinsi",
+	"jenis_pengadaan",
+	"nama_kl",
+	"nilai_kontrak",
+	"satuan_kerja",
+}
+
+// tenderDetailColumns are added to the summary columns by view=full
+var tenderDetailColumns = []string{
+	"kode_rup",
+	"nilai_hps",
+	"sumber_dana",
+	"kualifikasi_usaha",
+	"lokasi_pekerjaan",
+	"nama_pemenang",
+	"tanggal_penetapan_pemenang",
+}
+
+// tenderViews maps the view parameter of GET /api/v1/tender/{id} to its columns
+var tenderViews = map[string][]string{
+	"summary": tenderSummaryColumns,
+	"full":    append(append([]string{}, tenderSummaryColumns...), tenderDetailColumns...),
+}
+
 // TenderHandler handles tender-related endpoints
 type TenderHandler struct {
 	dataSource datasource.DataSource
+	limits     config.PageLimit
+	columns    *ColumnCatalog // Validates sort and filter columns; nil accepts any
+	search     config.KeywordSearch
+	filters    config.TenderFiltersConfig
+	sanitizer  *datasource.SQLSanitizer
+	tiebreaker string // Ordered by after the sort column of a page
+	timeTravel *timeTravel
 	logger     *zap.Logger
+
+	relations     map[string]config.Relation // Child collections by include name
+	strictInclude bool                       // A failed child fails the request
+	bulk          *bulkReader
+	countTTL      time.Duration // Cache TTL of count_only searches
+	notFound      *notFoundCache
 }
 
 // NewTenderHandler creates a new tender handler
-func NewTenderHandler(dataSource datasource.DataSource, logger *zap.Logger) *TenderHandler {
+func NewTenderHandler(dataSource datasource.DataSource, limits config.PageLimit, columns *ColumnCatalog, logger *zap.Logger) *TenderHandler {
 	return &TenderHandler{
 		dataSource: dataSource,
+		limits:     limits,
+		columns:    columns,
+		search:     config.DefaultSearch().Tender,
+		filters:    config.DefaultTenderFilters(),
+		sanitizer:  datasource.NewSQLSanitizer(),
+		tiebreaker: config.DefaultTiebreakers().For(tenderTable),
+		timeTravel: newTimeTravel(logger),
 		logger:     logger,
+		relations:  map[string]config.Relation{},
+		bulk:       newBulkReader(logger),
+		countTTL:   config.DefaultCountCacheTTL,
+		notFound:   newNotFoundCache(logger),
 	}
 }
 
+// SetNotFoundCache sets how long lookups of missing tenders are remembered,
+// the cache of their markers and the counter of their outcomes
+func (h *TenderHandler) SetNotFoundCache(ttl time.Duration, markers cache.Cache, counter *metrics.NotFoundCounter) {
+	h.notFound.configure(ttl, markers, counter)
+}
+
+// SetCountOnly sets the cache TTL of count_only searches
+func (h *TenderHandler) SetCountOnly(ttl time.Duration) {
+	h.countTTL = ttl
+}
+
+// SetBulk sets the limits of POST /api/v1/tender/bulk and the cache of the
+// tenders it reads
+func (h *TenderHandler) SetBulk(limits config.BulkConfig, records cache.Cache) {
+	h.bulk.set(limits, records)
+}
+
+// SetKeywordSearch sets the columns and limits of the search keyword
+func (h *TenderHandler) SetKeywordSearch(search config.KeywordSearch) {
+	h.search = search
+}
+
+// SetFilters sets the known statuses of the list's status filter and the
+// columns whose search clauses take several values
+func (h *TenderHandler) SetFilters(filters config.TenderFiltersConfig) {
+	h.filters = filters
+}
+
+// SetTiebreakers sets the column the tender list orders by after its sort
+// column, the tiebreaker of the tender table
+func (h *TenderHandler) SetTiebreakers(tiebreakers config.Tiebreakers) {
+	h.tiebreaker = tiebreakers.For(tenderTable)
+}
+
+// SetTimeTravel sets the retention window of as_of and whether it may read
+// a branch other than the default
+func (h *TenderHandler) SetTimeTravel(cfg config.TimeTravelConfig) {
+	h.timeTravel.config = cfg
+}
+
 // List handles GET /api/v1/tender
 func (h *TenderHandler) List(w http.ResponseWriter, r *http.Request) {
 	if h.dataSource == nil {
@@ -35,9 +143,9 @@ func (h *TenderHandler) List(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Parse query parameters
-	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
-	if limit <= 0 || limit > 1000 {
-		limit = 100
+	limit, ok := queryLimit(w, r, h.limits)
+	if !ok {
+		return
 	}
 
 	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
@@ -45,10 +153,30 @@ func (h *TenderHandler) List(w http.ResponseWriter, r *http.Request) {
 		offset = 0
 	}
 
-	status := r.URL.Query().Get("status")
+	maxAge, err := queryMaxAge(r)
+	if err != nil {
+		response.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	asOf, err := h.asOf(r.Context(), r.URL.Query().Get(asOfParam))
+	if err != nil {
+		response.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var v violations
+	statuses := h.statusFilter(r.URL.Query(), &v)
+	if v.write(w) {
+		return
+	}
+
 	sortBy := r.URL.Query().Get("sort_by")
 	if sortBy == "" {
 		sortBy = "tanggal_buat_paket"
+	} else if colErr := h.columns.Validate(tenderTable, sortBy); colErr != nil {
+		writeColumnError(w, colErr)
+		return
 	}
 
 	order := r.URL.Query().Get("order")
@@ -56,58 +184,86 @@ func (h *TenderHandler) List(w http.ResponseWriter, r *http.Request) {
 		order = "DESC"
 	}
 
-	// Build SQL query
-	query := `
-		SELECT
-			tender_id,
-			nama_paket,
-			nilai_pagu,
-			metode_pengadaan,
-			tahun_anggaran,
-			status_tender,
-			tanggal_buat_paket,
-			tanggal_pengumuman,
-			provinsi,
-			jenis_pengadaan,
-			nama_kl,
-			nilai_kontrak,
-			satuan_kerja
-		FROM nessie_iceberg.tender_data
-		WHERE 1=1
-	`
-
-	// Add status filter if provided
-	if status != "" {
-		query += fmt.Sprintf(" AND status_tender = '%s'", status)
-	}
-
-	// Add sorting and pagination
-	query += fmt.Sprintf(" ORDER BY %s %s LIMIT %d OFFSET %d", sortBy, order, limit, offset)
-
-	// Execute query
+	debugMode, ok := sqlDebug(w, r)
+	if !ok {
+		return
+	}
+
+	query, err := tenderListQuery(h.sanitizer, statuses, sortBy, order, h.tiebreaker, limit, offset, asOf)
+	if err != nil {
+		response.ErrorWithDetails(w, "Invalid tender list parameters", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Execute query; the page is already part of the SQL
 	opts := &datasource.QueryOptions{
-		Limit:  limit,
-		Offset: offset,
+		MaxAge: maxAge,
 	}
 
-	result, err := h.dataSource.ExecuteQuery(r.Context(), query, opts)
+	debug, ok := h.debug(w, r, debugMode, query, opts)
+	if !ok {
+		return
+	}
+
+	result, err := h.dataSource.ExecuteQuery(r.Context(), query.SQL, opts)
 	if err != nil {
 		h.logger.Error("Failed to fetch tenders", zap.Error(err))
-		response.Error(w, "Failed to fetch tender data", http.StatusInternalServerError)
+		if !writeUpstreamError(w, err, "Failed to fetch tender data") {
+			response.Error(w, "Failed to fetch tender data", http.StatusInternalServerError)
+		}
 		return
 	}
+	h.timeTravel.record(r, tenderTable, asOf)
 
-	// Add pagination meta
+	// Add pagination meta. The count is of this page's rows, not a total,
+	// so the pages are open-ended.
 	meta := &response.Meta{
-		Page:    (offset / limit) + 1,
-		PerPage: limit,
-		Total:   result.Count,
+		Total:      result.Count,
+		Limit:      limit,
+		AgeSeconds: ageSeconds(result),
+		Order:      query.Order,
+		Branch:     branchOf(r.Context(), result),
+		AsOf:       asOf.meta(),
+		Debug:      debug,
 	}
+	paginate(w, r, meta, page{offset: offset, limit: limit, rows: len(result.Data)})
 
+	setAge(w, result)
 	response.Success(w, result.Data, meta)
 }
 
-// GetByID handles GET /api/v1/tender/{id}
+// asOf validates the as_of of a tender read, which needs the Dremio tables
+func (h *TenderHandler) asOf(ctx context.Context, raw string) (asOfRead, error) {
+	if raw != "" && h.dataSource.GetType() != datasource.DataSourceDremio {
+		return asOfRead{}, fmt.Errorf("%s applies to Dremio sources only", asOfParam)
+	}
+	return h.timeTravel.parse(ctx, raw)
+}
+
+// debug plans query for debug_sql and dry_run. A dry run is answered here
+// with the plan, and ok is false so the query is not run.
+func (h *TenderHandler) debug(w http.ResponseWriter, r *http.Request, mode sqlDebugMode, query builtQuery, opts *datasource.QueryOptions) (debug *response.QueryDebug, ok bool) {
+	if mode == sqlDebugOff {
+		return nil, true
+	}
+	debug, err := queryDebug(r.Context(), h.dataSource, query, opts)
+	if err != nil {
+		h.logger.Error("Failed to plan tender query", zap.Error(err))
+		if !writeUpstreamError(w, err, "Failed to plan tender query") {
+			response.Error(w, "Failed to plan tender query", http.StatusInternalServerError)
+		}
+		return nil, false
+	}
+	if mode == sqlDebugDryRun {
+		response.Success(w, debug, nil)
+		return nil, false
+	}
+	return debug, true
+}
+
+// GetByID handles GET /api/v1/tender/{id}. view=full (the default) returns
+// the summary and detail columns, view=summary only the list columns.
+// include=peserta,dokumen nests the rows of those child tables as arrays.
 func (h *TenderHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 	if h.dataSource == nil {
 		response.Error(w, "Data source not configured", http.StatusServiceUnavailable)
@@ -120,61 +276,257 @@ func (h *TenderHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	query := fmt.Sprintf(`
-		SELECT * FROM nessie_iceberg.tender_data
-		WHERE tender_id = '%s'
-		LIMIT 1
-	`, tenderID)
+	view := r.URL.Query().Get("view")
+	if view == "" {
+		view = "full"
+	}
+	columns, ok := tenderViews[view]
+	if !ok {
+		response.Error(w, fmt.Sprintf("Invalid view %q: must be summary or full", view), http.StatusBadRequest)
+		return
+	}
 
-	result, err := h.dataSource.ExecuteQuery(r.Context(), query, nil)
+	var v violations
+	includes := h.includes(r.URL.Query().Get("include"), &v)
+	if v.write(w) {
+		return
+	}
+
+	query, err := sqlbuilder.Select(sqlbuilder.Dremio, columns...).From(tenderTable).
+		Where(sqlbuilder.Eq("tender_id", tenderID)).
+		Limit(1).SQL()
 	if err != nil {
-		h.logger.Error("Failed to fetch tender", zap.Error(err))
-		response.Error(w, "Failed to fetch tender data", http.StatusInternalServerError)
+		response.ErrorWithDetails(w, "Invalid tender ID", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Missing tenders are remembered here rather than for the TTL of the
+	// result cache, so they show up soon once ingested
+	if h.notFound.has(r.Context(), tenderTable, tenderID, "") {
+		writeNotFound(w, "Tender not found")
+		return
+	}
+	opts := &datasource.QueryOptions{SkipCacheEmpty: h.notFound.enabled()}
+	result, err := h.dataSource.ExecuteQuery(r.Context(), query, opts)
+	if err != nil {
+		h.logger.Error("Failed to fetch tender", zap.String("tender_id", tenderID), zap.Error(err))
+		if writeConversionError(w, err, columns, "Failed to fetch tender data") {
+			return
+		}
+		if !writeUpstreamError(w, err, "Failed to fetch tender data") {
+			response.Error(w, "Failed to fetch tender data", http.StatusInternalServerError)
+		}
 		return
 	}
 
 	if len(result.Data) == 0 {
+		h.notFound.remember(r.Context(), tenderTable, tenderID, "")
 		response.Error(w, "Tender not found", http.StatusNotFound)
 		return
 	}
 
-	response.Success(w, result.Data[0], nil)
+	setAge(w, result)
+	if len(includes) == 0 {
+		response.Success(w, result.Data[0], nil)
+		return
+	}
+
+	tender, warnings, ok := h.withChildren(w, r, tenderID, result.Data[0], includes)
+	if !ok {
+		return
+	}
+	var meta *response.Meta
+	if len(warnings) > 0 {
+		meta = &response.Meta{Warnings: warnings}
+	}
+	response.Success(w, tender, meta)
+}
+
+// Bulk handles POST /api/v1/tender/bulk: the full view of each tender in
+// {"ids": [...]}, keyed by id, and the ids of those that do not exist
+func (h *TenderHandler) Bulk(w http.ResponseWriter, r *http.Request) {
+	if h.dataSource == nil {
+		response.Error(w, "Data source not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	ids, ok := h.bulk.decode(w, r)
+	if !ok {
+		return
+	}
+
+	columns := tenderViews["full"]
+	result, err := h.bulk.read(r.Context(), bulkLookup{
+		scope:  tenderTable,
+		table:  tenderTable,
+		column: "tender_id",
+		fetch: func(ctx context.Context, ids []string) ([]map[string]interface{}, error) {
+			query, err := sqlbuilder.Select(sqlbuilder.Dremio, columns...).From(tenderTable).
+				Where(sqlbuilder.In("tender_id", ids)).SQL()
+			if err != nil {
+				return nil, err
+			}
+			// Records are cached one by one rather than per chunk
+			result, err := h.dataSource.ExecuteQuery(ctx, query, &datasource.QueryOptions{SkipCache: true})
+			if err != nil {
+				return nil, err
+			}
+			return result.Data, nil
+		},
+	}, ids)
+	if err != nil {
+		h.logger.Error("Failed to fetch tenders", zap.Int("ids", len(ids)), zap.Error(err))
+		if writeConversionError(w, err, columns, "Failed to fetch tender data") {
+			return
+		}
+		if !writeUpstreamError(w, err, "Failed to fetch tender data") {
+			response.Error(w, "Failed to fetch tender data", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	response.Success(w, result, nil)
 }
 
-// Search handles POST /api/v1/tender/search
+// Search handles POST /api/v1/tender/search: the tenders matching every
+// filter and the keyword, which searches the configured keyword columns
 func (h *TenderHandler) Search(w http.ResponseWriter, r *http.Request) {
 	if h.dataSource == nil {
 		response.Error(w, "Data source not configured", http.StatusServiceUnavailable)
 		return
 	}
 
-	// Parse search criteria from request body
-	var searchCriteria map[string]interface{}
-	if err := json.NewDecoder(r.Body).Decode(&searchCriteria); err != nil {
-		response.Error(w, "Invalid search criteria", http.StatusBadRequest)
+	debugMode, ok := sqlDebug(w, r)
+	if !ok {
 		return
 	}
 
-	// Build query based on search criteria
-	query := `SELECT * FROM nessie_iceberg.tender_data WHERE 1=1`
+	var req apitypes.TenderSearchRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
 
-	// Add filters dynamically
-	for field, value := range searchCriteria {
-		if field == "limit" || field == "offset" {
-			continue
-		}
-		query += fmt.Sprintf(" AND %s = '%v'", field, value)
+	var v violations
+	limit := v.limit("limit", req.Limit, h.limits)
+	keywordSearch, err := keywordMatch(req.Keyword, req.Match, h.search)
+	v.addErr("keyword", "keyword", err)
+	clauses := multiValueClauses(req.Filters, h.filters.MultiValueColumns)
+	filters := searchConditions(&v, "filters", clauses, config.ActiveSecurityConfig().TableColumns[tenderTable])
+	asOf, err := h.asOf(r.Context(), req.AsOf)
+	v.addErr(asOfParam, asOfParam, err)
+	if v.write(w) {
+		return
 	}
 
-	// Add default limit
-	query += " LIMIT 100"
+	if colErr := h.columns.Validate(tenderTable, searchFields(req.Filters)...); colErr != nil {
+		writeColumnError(w, colErr)
+		return
+	}
 
-	result, err := h.dataSource.ExecuteQuery(r.Context(), query, nil)
+	var query builtQuery
+	if req.CountOnly {
+		query, err = tenderSearchCountQuery(h.sanitizer, filters, keywordSearch, asOf)
+	} else {
+		query, err = tenderSearchQuery(h.sanitizer, filters, keywordSearch, limit, asOf)
+	}
+	if err != nil {
+		response.ErrorWithDetails(w, "Invalid search criteria", err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(req.Filters) > 0 {
+		query.Params["filters"] = req.Filters
+	}
+
+	debug, ok := h.debug(w, r, debugMode, query, nil)
+	if !ok {
+		return
+	}
+
+	var opts *datasource.QueryOptions
+	if req.CountOnly {
+		opts = &datasource.QueryOptions{CacheTTL: h.countTTL, Timeout: datasource.Defaults(h.dataSource).Timeout}
+	}
+	start := time.Now()
+	result, err := h.dataSource.ExecuteQuery(r.Context(), query.SQL, opts)
 	if err != nil {
 		h.logger.Error("Search failed", zap.Error(err))
-		response.Error(w, "Search failed", http.StatusInternalServerError)
+		if !writeUpstreamError(w, err, "Search failed") {
+			response.Error(w, "Search failed", http.StatusInternalServerError)
+		}
 		return
 	}
+	h.timeTravel.record(r, tenderTable, asOf)
 
-	response.Success(w, result, nil)
+	meta := &response.Meta{Limit: limit, Branch: branchOf(r.Context(), result), AsOf: asOf.meta(), Debug: debug}
+	if req.CountOnly {
+		count, err := readCount(result.Data, "total")
+		if err != nil {
+			h.logger.Error("Unexpected count result", zap.Error(err))
+			response.Error(w, "Search failed", http.StatusInternalServerError)
+			return
+		}
+		meta.Limit = 0
+		response.Success(w, CountResult{Count: count, QueryTimeMs: time.Since(start).Milliseconds(), CacheHit: result.CacheHit}, meta)
+		return
+	}
+	response.Success(w, result, meta)
+}
+
+// tenderListQuery builds the query of the tender list: the summary columns
+// of one page, optionally of some statuses, ordered by sortBy and then
+// tiebreaker, as of a past time when asOf is set
+func tenderListQuery(sanitizer *datasource.SQLSanitizer, statuses []string, sortBy, order, tiebreaker string, limit, offset int, asOf asOfRead) (builtQuery, error) {
+	opts := &datasource.QueryOptions{
+		OrderBy:    sortBy,
+		OrderDir:   order,
+		Tiebreaker: tiebreaker,
+		Limit:      limit,
+		Offset:     offset,
+		AsOf:       asOf.At,
+		AsOfBranch: asOf.Branch,
+	}
+	if len(statuses) > 0 {
+		opts.Filters = map[string]interface{}{"status_tender": statusCondition(statuses)}
+	}
+	return tenderSelect(sanitizer, tenderSummaryColumns, opts)
+}
+
+// tenderSearchQuery builds the query of a tender search: the rows matching
+// every filter condition and the keywords, as of a past time when asOf is set
+func tenderSearchQuery(sanitizer *datasource.SQLSanitizer, filters []sqlbuilder.Cond, keywords *datasource.KeywordMatch, limit int, asOf asOfRead) (builtQuery, error) {
+	return tenderSelect(sanitizer, nil, &datasource.QueryOptions{
+		Keywords:   keywords,
+		Limit:      limit,
+		AsOf:       asOf.At,
+		AsOfBranch: asOf.Branch,
+	}, filters...)
+}
+
+// tenderSearchCountQuery builds the count of a tender search: the rows
+// matching every filter condition and the keywords, without a limit
+func tenderSearchCountQuery(sanitizer *datasource.SQLSanitizer, filters []sqlbuilder.Cond, keywords *datasource.KeywordMatch, asOf asOfRead) (builtQuery, error) {
+	opts := &datasource.QueryOptions{Keywords: keywords, AsOf: asOf.At, AsOfBranch: asOf.Branch}
+	builder, err := sanitizer.SelectBuilder(tenderTable, nil, opts)
+	if err != nil {
+		return builtQuery{}, err
+	}
+	query, err := builder.Where(filters...).CountSQL()
+	if err != nil {
+		return builtQuery{}, err
+	}
+	return builtQuery{SQL: query, Params: optionParams(opts)}, nil
+}
+
+// tenderSelect builds a select of the tender table with opts and the
+// conditions of where
+func tenderSelect(sanitizer *datasource.SQLSanitizer, columns []string, opts *datasource.QueryOptions, where ...sqlbuilder.Cond) (builtQuery, error) {
+	builder, err := sanitizer.SelectBuilder(tenderTable, columns, opts)
+	if err != nil {
+		return builtQuery{}, err
+	}
+	query, err := builder.Where(where...).SQL()
+	if err != nil {
+		return builtQuery{}, err
+	}
+	return builtQuery{SQL: query, Params: optionParams(opts), Order: builder.Order()}, nil
 }
@@ -0,0 +1,133 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/response"
+	"go-data-gateway/internal/sqlbuilder"
+)
+
+// SetRelations sets the child collections GET /api/v1/tender/{id} can
+// include, and whether a failed one fails the request
+func (h *TenderHandler) SetRelations(relations config.RelationsConfig) {
+	h.relations = make(map[string]config.Relation, len(relations.Tender))
+	for _, relation := range relations.Tender {
+		h.relations[relation.Name] = relation
+	}
+	h.strictInclude = relations.Strict
+}
+
+// includes returns the relations named by the include parameter, a comma
+// separated list, in the order given and without repeats
+func (h *TenderHandler) includes(param string, v *violations) []config.Relation {
+	var (
+		relations []config.Relation
+		seen      = map[string]bool{}
+	)
+	for _, name := range strings.Split(param, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		relation, ok := h.relations[name]
+		if !ok {
+			v.add("include", "oneof="+strings.Join(h.relationNames(), " "), "unknown include %s", name)
+			continue
+		}
+		relations = append(relations, relation)
+	}
+	return relations
+}
+
+// relationNames are the names of the configured relations, sorted
+func (h *TenderHandler) relationNames() []string {
+	names := make([]string, 0, len(h.relations))
+	for name := range h.relations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// childResult is the outcome of one relation's query
+type childResult struct {
+	relation config.Relation
+	rows     []map[string]interface{}
+	err      error
+}
+
+// fetchChildren runs the query of each relation for tenderID concurrently.
+// Each is a query of its own, so the data source caches it independently.
+func (h *TenderHandler) fetchChildren(ctx context.Context, tenderID string, relations []config.Relation) []childResult {
+	results := make([]childResult, len(relations))
+	var wg sync.WaitGroup
+	for i, relation := range relations {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = childResult{relation: relation}
+			query, err := sqlbuilder.Select(sqlbuilder.Dremio, relation.Columns...).From(relation.Table).
+				Where(sqlbuilder.Eq(relation.Key, tenderID)).
+				Limit(relation.Limit).SQL()
+			if err != nil {
+				results[i].err = err
+				return
+			}
+			result, err := h.dataSource.ExecuteQuery(ctx, query, nil)
+			if err != nil {
+				results[i].err = err
+				return
+			}
+			results[i].rows = result.Data
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// withChildren returns a copy of record with the children of each relation
+// as a nested array; the record itself may be shared with the cache. A
+// failed relation is an empty array and an INCLUDE_FAILED warning, unless
+// includes are strict, in which case the error is written and ok is false.
+func (h *TenderHandler) withChildren(w http.ResponseWriter, r *http.Request, tenderID string, record map[string]interface{}, relations []config.Relation) (nested map[string]interface{}, warnings []response.Warning, ok bool) {
+	nested = make(map[string]interface{}, len(record)+len(relations))
+	for key, value := range record {
+		nested[key] = value
+	}
+
+	for _, child := range h.fetchChildren(r.Context(), tenderID, relations) {
+		rows := child.rows
+		if rows == nil {
+			rows = []map[string]interface{}{}
+		}
+		if child.err != nil {
+			h.logger.Error("Failed to fetch tender children",
+				zap.String("tender_id", tenderID),
+				zap.String("include", child.relation.Name),
+				zap.Error(child.err))
+			if h.strictInclude {
+				message := fmt.Sprintf("Failed to fetch tender %s", child.relation.Name)
+				if !writeUpstreamError(w, child.err, message) {
+					response.Error(w, message, http.StatusInternalServerError)
+				}
+				return nil, nil, false
+			}
+			warnings = append(warnings, response.Warning{
+				Code:    response.WarnIncludeFailed,
+				Message: fmt.Sprintf("%s could not be loaded", child.relation.Name),
+				Details: map[string]string{"include": child.relation.Name},
+			})
+		}
+		nested[child.relation.Name] = rows
+	}
+	return nested, warnings, true
+}
@@ -0,0 +1,182 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/response"
+	"go-data-gateway/internal/sqlbuilder"
+	"go-data-gateway/pkg/apitypes"
+)
+
+// TenderStatusCount is a known tender status and the number of tenders in it
+type TenderStatusCount struct {
+	Status string `json:"status"`
+	Count  int64  `json:"count"`
+}
+
+// splitValues returns the comma-separated values of a repeated parameter,
+// trimmed, without empty ones and repeats
+func splitValues(params []string) []string {
+	var values []string
+	for _, param := range params {
+		for _, value := range strings.Split(param, ",") {
+			if value = strings.TrimSpace(value); value != "" && !slices.Contains(values, value) {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
+
+// statusFilter returns the statuses the tender list is filtered by: status
+// holds comma-separated values and may be repeated. A known status is
+// matched ignoring case and read as it is stored. An unknown one is rejected
+// when strict=true, or by default when the statuses are configured, and is
+// otherwise passed on as it is.
+func (h *TenderHandler) statusFilter(query url.Values, v *violations) []string {
+	strict := h.filters.StrictStatuses
+	if raw := query.Get("strict"); raw != "" {
+		var err error
+		if strict, err = strconv.ParseBool(raw); err != nil {
+			v.add("strict", "boolean", "strict must be true or false")
+			return nil
+		}
+	}
+
+	values := splitValues(query["status"])
+	if len(values) > sqlbuilder.MaxInValues {
+		v.add("status", "max="+strconv.Itoa(sqlbuilder.MaxInValues), "status takes at most %d values", sqlbuilder.MaxInValues)
+		return nil
+	}
+	statuses := make([]string, 0, len(values))
+	for _, value := range values {
+		status, known := h.filters.Status(value)
+		switch {
+		case known:
+		case strict:
+			v.add("status", "oneof="+strings.Join(h.filters.Statuses, " "), "unknown status %q; valid statuses are %s", value, strings.Join(h.filters.Statuses, ", "))
+			continue
+		default:
+			status = value
+		}
+		if !slices.Contains(statuses, status) {
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses
+}
+
+// statusCondition is the Filters value of statuses: a plain value for one,
+// an in filter for several
+func statusCondition(statuses []string) interface{} {
+	if len(statuses) == 1 {
+		return statuses[0]
+	}
+	return datasource.FilterSpec{Op: datasource.FilterIn, Value: statuses}
+}
+
+// multiValueClauses returns clauses with the eq and neq clauses on columns
+// whose value is a list, or a string of comma-separated values, turned into
+// in and nin clauses of those values, in groups too
+func multiValueClauses(clauses []apitypes.SearchClause, columns []string) []apitypes.SearchClause {
+	if clauses == nil {
+		return nil
+	}
+	rewritten := make([]apitypes.SearchClause, len(clauses))
+	for i, clause := range clauses {
+		clause.Or = multiValueClauses(clause.Or, columns)
+		clause.And = multiValueClauses(clause.And, columns)
+		op := strings.ToLower(clause.Op)
+		if (op == searchEq || op == searchNeq) && slices.Contains(columns, clause.Field) {
+			if values, ok := clauseValues(clause.Value); ok {
+				clause.Op = searchIn
+				if op == searchNeq {
+					clause.Op = searchNin
+				}
+				clause.Value = values
+			}
+		}
+		rewritten[i] = clause
+	}
+	return rewritten
+}
+
+// clauseValues returns raw as a list of values when it is a list, or a
+// string of more than one comma-separated value
+func clauseValues(raw json.RawMessage) (json.RawMessage, bool) {
+	var list []json.RawMessage
+	if json.Unmarshal(raw, &list) == nil && list != nil {
+		return raw, true
+	}
+	var s string
+	if json.Unmarshal(raw, &s) != nil || !strings.Contains(s, ",") {
+		return nil, false
+	}
+	values, err := json.Marshal(splitValues([]string{s}))
+	return values, err == nil
+}
+
+// Statuses handles GET /api/v1/tender/statuses: every known status with the
+// number of tenders in it, counted in one grouped query cached for the
+// count TTL
+func (h *TenderHandler) Statuses(w http.ResponseWriter, r *http.Request) {
+	if h.dataSource == nil {
+		response.Error(w, "Data source not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	counts := make([]TenderStatusCount, len(h.filters.Statuses))
+	for i, status := range h.filters.Statuses {
+		counts[i] = TenderStatusCount{Status: status}
+	}
+	if len(counts) == 0 {
+		response.Success(w, counts, nil)
+		return
+	}
+
+	query, err := sqlbuilder.Select(sqlbuilder.Dremio, "status_tender").
+		SelectExpr("COUNT(*)", "total").
+		From(tenderTable).
+		Where(sqlbuilder.In("status_tender", h.filters.Statuses)).
+		GroupBy("status_tender").SQL()
+	if err != nil {
+		h.logger.Error("Failed to build tender status query", zap.Error(err))
+		response.Error(w, "Failed to count tender statuses", http.StatusInternalServerError)
+		return
+	}
+
+	result, err := h.dataSource.ExecuteQuery(r.Context(), query, &datasource.QueryOptions{CacheTTL: h.countTTL})
+	if err != nil {
+		h.logger.Error("Failed to count tender statuses", zap.Error(err))
+		if !writeUpstreamError(w, err, "Failed to count tender statuses") {
+			response.Error(w, "Failed to count tender statuses", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	for _, row := range result.Data {
+		status, _ := row["status_tender"].(string)
+		i := slices.IndexFunc(counts, func(c TenderStatusCount) bool { return c.Status == status })
+		if i < 0 {
+			continue
+		}
+		count, err := readCount([]map[string]interface{}{row}, "total")
+		if err != nil {
+			h.logger.Error("Unexpected tender status count", zap.String("status", status), zap.Error(err))
+			response.Error(w, "Failed to count tender statuses", http.StatusInternalServerError)
+			return
+		}
+		counts[i].Count = count
+	}
+
+	setAge(w, result)
+	response.Success(w, counts, &response.Meta{AgeSeconds: ageSeconds(result)})
+}
@@ -0,0 +1,134 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+)
+
+func TestTenderList_StatusFilter(t *testing.T) {
+	list := func(rawQuery string) (*httptest.ResponseRecorder, *recordingSource) {
+		source := &recordingSource{sourceType: datasource.DataSourceDremio}
+		handler := NewTenderHandler(source, testLimits, nil, zap.NewNop())
+		filters := config.DefaultTenderFilters()
+		filters.StrictStatuses = true
+		handler.SetFilters(filters)
+		rec := httptest.NewRecorder()
+		handler.List(rec, httptest.NewRequest(http.MethodGet, "/api/v1/tender?"+rawQuery, nil))
+		return rec, source
+	}
+
+	rec, source := list("status=Aktif,Selesai&status=Batal&status=Aktif")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, source.query, "status_tender IN ('Aktif', 'Selesai', 'Batal')")
+
+	// Statuses match ignoring case and are read as they are stored
+	rec, source = list("status=aktif")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, source.query, "status_tender = 'Aktif'")
+
+	rec, source = list("status=Aktif,evaluasi")
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Empty(t, source.query)
+	assert.Contains(t, rec.Body.String(), "valid statuses are Aktif, Selesai, Batal")
+
+	rec, source = list("status=Aktif,evaluasi&strict=false")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, source.query, "status_tender IN ('Aktif', 'evaluasi')")
+
+	rec, source = list("status=Aktif&strict=maybe")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Empty(t, source.query)
+}
+
+func TestTenderList_ConfiguredStatuses(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio}
+	handler := NewTenderHandler(source, testLimits, nil, zap.NewNop())
+	handler.SetFilters(config.TenderFiltersConfig{Statuses: []string{"Selesai"}, StrictStatuses: true})
+
+	rec := httptest.NewRecorder()
+	handler.List(rec, httptest.NewRequest(http.MethodGet, "/api/v1/tender?status=selesai", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, source.query, "status_tender = 'Selesai'")
+
+	rec = httptest.NewRecorder()
+	handler.List(rec, httptest.NewRequest(http.MethodGet, "/api/v1/tender?status=aktif", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestTenderList_BuiltInStatuses(t *testing.T) {
+	list := func(rawQuery string) (*httptest.ResponseRecorder, *recordingSource) {
+		source := &recordingSource{sourceType: datasource.DataSourceDremio}
+		handler := NewTenderHandler(source, testLimits, nil, zap.NewNop())
+		rec := httptest.NewRecorder()
+		handler.List(rec, httptest.NewRequest(http.MethodGet, "/api/v1/tender?"+rawQuery, nil))
+		return rec, source
+	}
+
+	// Without configured statuses, unknown ones are passed on unless strict
+	rec, source := list("status=selesai,active")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, source.query, "status_tender IN ('Selesai', 'active')")
+
+	rec, source = list("status=active&strict=true")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Empty(t, source.query)
+}
+
+func TestTenderStatuses(t *testing.T) {
+	source := &recordingSource{
+		sourceType: datasource.DataSourceDremio,
+		rows:       []map[string]interface{}{{"status_tender": "Aktif", "total": int64(3)}, {"status_tender": "Batal", "total": float64(1)}},
+	}
+	handler := NewTenderHandler(source, testLimits, nil, zap.NewNop())
+
+	rec := httptest.NewRecorder()
+	handler.Statuses(rec, httptest.NewRequest(http.MethodGet, "/api/v1/tender/statuses", nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	assert.Contains(t, source.query, "status_tender IN ('Aktif', 'Selesai', 'Batal')")
+	assert.Contains(t, source.query, "GROUP BY status_tender")
+	assert.Equal(t, handler.countTTL, source.opts.CacheTTL)
+
+	var body struct {
+		Data []TenderStatusCount `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, []TenderStatusCount{
+		{Status: "Aktif", Count: 3},
+		{Status: "Selesai"},
+		{Status: "Batal", Count: 1},
+	}, body.Data)
+}
+
+func TestTenderSearch_MultiValueColumns(t *testing.T) {
+	search := func(body string) (*httptest.ResponseRecorder, *recordingSource) {
+		source := &recordingSource{sourceType: datasource.DataSourceDremio}
+		handler := NewTenderHandler(source, testLimits, nil, zap.NewNop())
+		rec := httptest.NewRecorder()
+		handler.Search(rec, httptest.NewRequest(http.MethodPost, "/api/v1/tender/search", bytes.NewBufferString(body)))
+		return rec, source
+	}
+
+	rec, source := search(`{"filters": [{"field": "provinsi", "op": "eq", "value": "Aceh, Bali"}]}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, source.query, "provinsi IN ('Aceh', 'Bali')")
+
+	rec, source = search(`{"filters": [{"field": "jenis_pengadaan", "op": "neq", "value": ["Konstruksi", "Jasa Lainnya"]}]}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, source.query, "jenis_pengadaan NOT IN ('Konstruksi', 'Jasa Lainnya')")
+
+	// Other columns keep commas in their values
+	rec, source = search(`{"filters": [{"field": "nama_paket", "op": "eq", "value": "Jalan, Jembatan"}]}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, source.query, "nama_paket = 'Jalan, Jembatan'")
+}
@@ -0,0 +1,405 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/response"
+	"go-data-gateway/pkg/apitypes"
+)
+
+// getTender calls GetByID for id with the raw query string
+func getTender(t *testing.T, source datasource.DataSource, id, rawQuery string) *httptest.ResponseRecorder {
+	handler := NewTenderHandler(source, testLimits, nil, zap.NewNop())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/tender/"+url.PathEscape(id)+"?"+rawQuery, nil)
+	routeCtx := chi.NewRouteContext()
+	routeCtx.URLParams.Add("id", id)
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, routeCtx))
+
+	rec := httptest.NewRecorder()
+	handler.GetByID(rec, req)
+	return rec
+}
+
+func TestTenderGetByID_Views(t *testing.T) {
+	tests := []struct {
+		name     string
+		rawQuery string
+		columns  []string
+	}{
+		{"default is full", "", tenderViews["full"]},
+		{"full", "view=full", tenderViews["full"]},
+		{"summary", "view=summary", tenderSummaryColumns},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &recordingSource{sourceType: datasource.DataSourceDremio, rows: []map[string]interface{}{{"tender_id": "T1"}}}
+			rec := getTender(t, source, "T1", tt.rawQuery)
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+			assert.Equal(t, "SELECT "+strings.Join(tt.columns, ", ")+
+				" FROM nessie_iceberg.tender_data WHERE tender_id = 'T1' LIMIT 1", source.query)
+			assert.NotContains(t, source.query, "*")
+		})
+	}
+
+	// The summary view is the list's projection; full extends it
+	assert.Equal(t, tenderSummaryColumns, tenderViews["full"][:len(tenderSummaryColumns)])
+	assert.Len(t, tenderViews["full"], len(tenderSummaryColumns)+len(tenderDetailColumns))
+}
+
+func TestTenderGetByID_QuotesID(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio}
+	rec := getTender(t, source, "x' OR '1'='1", "view=summary")
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, source.query, "WHERE tender_id = 'x'' OR ''1''=''1' LIMIT 1")
+}
+
+func TestTenderGetByID_InvalidView(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio}
+	rec := getTender(t, source, "T1", "view=compact")
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Empty(t, source.query)
+}
+
+func TestTenderGetByID_ConversionError(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		column  string
+	}{
+		{"column named", "FUNCTION ERROR: Failed to cast the string 'n/a' to DECIMAL in column nilai_hps", "nilai_hps"},
+		{"column found in projection", "GandivaException: Failed to cast 'abc' to int32 (tahun_anggaran)", "tahun_anggaran"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &failingSource{err: datasource.ClassifyDremioError(status.Error(codes.Internal, tt.message))}
+			rec := getTender(t, source, "T1", "")
+			require.Equal(t, http.StatusBadGateway, rec.Code, rec.Body.String())
+
+			var resp struct {
+				Error struct {
+					Code    string                 `json:"code"`
+					Details ConversionErrorDetails `json:"details"`
+				} `json:"error"`
+			}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, "UPSTREAM_ERROR", resp.Error.Code)
+			assert.Equal(t, tt.column, resp.Error.Details.Column)
+			assert.Equal(t, tt.message, resp.Error.Details.Message)
+		})
+	}
+}
+
+func TestTenderList_BuildsQueryThroughSanitizer(t *testing.T) {
+	list := func(rawQuery string) (*httptest.ResponseRecorder, *recordingSource) {
+		source := &recordingSource{sourceType: datasource.DataSourceDremio}
+		handler := NewTenderHandler(source, testLimits, nil, zap.NewNop())
+		rec := httptest.NewRecorder()
+		handler.List(rec, httptest.NewRequest(http.MethodGet, "/api/v1/tender?"+rawQuery, nil))
+		return rec, source
+	}
+
+	// Unknown statuses passed on as they are reach the SQL escaped
+	rec, source := list("strict=false&status=" + url.QueryEscape("x' OR '1'='1"))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, source.query, "status_tender = 'x'' OR ''1''=''1'")
+
+	for _, rawQuery := range []string{
+		"order=" + url.QueryEscape("DESC; DROP TABLE tender"),
+		"sort_by=" + url.QueryEscape("1; DROP TABLE tender"),
+	} {
+		rec, source = list(rawQuery)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, rawQuery)
+		assert.Empty(t, source.query, rawQuery)
+	}
+}
+
+func TestTenderList_EchoesBranch(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio}
+	handler := NewTenderHandler(source, testLimits, nil, zap.NewNop())
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/tender?branch=staging", nil)
+	version := datasource.VersionRef{Source: "nessie_iceberg", Type: "BRANCH", Name: "staging", Default: "main"}
+	req = req.WithContext(datasource.WithVersion(req.Context(), version))
+
+	rec := httptest.NewRecorder()
+	handler.List(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "staging", decodeResponse(t, rec).Meta.Branch)
+}
+
+func TestTenderSearch_QuotesValues(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio}
+	handler := NewTenderHandler(source, testLimits, nil, zap.NewNop())
+
+	body := `{"filters": [{"field": "nama_paket", "op": "eq", "value": "x' OR '1'='1"}, {"field": "nilai_pagu", "op": "eq", "value": 100}]}`
+	rec := httptest.NewRecorder()
+	handler.Search(rec, httptest.NewRequest(http.MethodPost, "/api/v1/tender/search", strings.NewReader(body)))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, source.query, "nama_paket = 'x'' OR ''1''=''1'")
+	assert.Contains(t, source.query, "nilai_pagu = 100")
+
+	source.query = ""
+	rec = httptest.NewRecorder()
+	handler.Search(rec, httptest.NewRequest(http.MethodPost, "/api/v1/tender/search",
+		strings.NewReader(`{"filters": [{"field": "1=1 OR nama_paket", "op": "eq", "value": "x"}]}`)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Empty(t, source.query)
+}
+
+func TestTenderSearch_Keywords(t *testing.T) {
+	search := func(body string) (*httptest.ResponseRecorder, *recordingSource) {
+		source := &recordingSource{sourceType: datasource.DataSourceDremio}
+		handler := NewTenderHandler(source, testLimits, nil, zap.NewNop())
+		handler.SetKeywordSearch(config.KeywordSearch{Columns: []string{"nama_paket", "satuan_kerja"}, MaxLength: 10, MaxTerms: 2})
+		rec := httptest.NewRecorder()
+		handler.Search(rec, httptest.NewRequest(http.MethodPost, "/api/v1/tender/search", strings.NewReader(body)))
+		return rec, source
+	}
+
+	rec, source := search(`{"keyword": "50%", "filters": [{"field": "tahun_anggaran", "op": "eq", "value": 2025}]}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, source.query, `(LOWER(nama_paket) LIKE LOWER('%50\%%') ESCAPE '\' OR LOWER(satuan_kerja) LIKE LOWER('%50\%%') ESCAPE '\')`)
+	assert.Contains(t, source.query, "tahun_anggaran = 2025")
+
+	rec, source = search(`{"keyword": ["jalan", "jembatan"], "match": "all"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, source.query, `ESCAPE '\') AND (LOWER(nama_paket) LIKE LOWER('%jembatan%')`)
+
+	rec, source = search(`{"keyword": ["jalan", " "], "match": "any"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, source.query, `LIKE LOWER('%jalan%')`)
+	assert.NotContains(t, source.query, `LIKE LOWER('% %')`)
+
+	for _, body := range []string{
+		`{"keyword": "jalan", "match": "some"}`,
+		`{"keyword": ["a", "b", "c"]}`,
+		`{"keyword": "jalan raya utama"}`,
+		`{"keyword": 42}`,
+	} {
+		rec, source = search(body)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+		assert.Empty(t, source.query, body)
+	}
+}
+
+// childSource answers tender queries with one tender and child queries with
+// the rows of their table; tables in failing return an error
+type childSource struct {
+	recordingSource
+	tender   []map[string]interface{}
+	children map[string][]map[string]interface{}
+	failing  map[string]bool
+
+	mu      sync.Mutex
+	queries []string
+}
+
+func (s *childSource) ExecuteQuery(ctx context.Context, query string, opts *datasource.QueryOptions) (*datasource.QueryResult, error) {
+	s.mu.Lock()
+	s.queries = append(s.queries, query)
+	s.mu.Unlock()
+	for table, rows := range s.children {
+		if strings.Contains(query, " FROM "+table+" ") {
+			if s.failing[table] {
+				return nil, datasource.ClassifyDremioError(status.Error(codes.PermissionDenied, "no access to "+table))
+			}
+			return &datasource.QueryResult{Data: rows, Source: datasource.DataSourceDremio}, nil
+		}
+	}
+	if s.tender == nil {
+		s.tender = []map[string]interface{}{{"tender_id": "T1"}}
+	}
+	return &datasource.QueryResult{Data: s.tender, Source: datasource.DataSourceDremio}, nil
+}
+
+func getTenderWithRelations(t *testing.T, source datasource.DataSource, strict bool, rawQuery string) *httptest.ResponseRecorder {
+	handler := NewTenderHandler(source, testLimits, nil, zap.NewNop())
+	handler.SetRelations(config.RelationsConfig{
+		Tender: []config.Relation{
+			{Name: "peserta", Table: "nessie_iceberg.tender_peserta", Key: "tender_id", Limit: 500},
+			{Name: "dokumen", Table: "nessie_iceberg.tender_dokumen", Key: "tender_id", Columns: []string{"nama_dokumen"}, Limit: 50},
+		},
+		Strict: strict,
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/tender/T1?"+rawQuery, nil)
+	routeCtx := chi.NewRouteContext()
+	routeCtx.URLParams.Add("id", "T1")
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, routeCtx))
+	rec := httptest.NewRecorder()
+	handler.GetByID(rec, req)
+	return rec
+}
+
+func TestTenderGetByID_IncludesChildren(t *testing.T) {
+	tender := []map[string]interface{}{{"tender_id": "T1"}}
+	source := &childSource{tender: tender, children: map[string][]map[string]interface{}{
+		"nessie_iceberg.tender_peserta": {{"nama_peserta": "PT A"}, {"nama_peserta": "PT B"}},
+		"nessie_iceberg.tender_dokumen": nil,
+	}}
+	rec := getTenderWithRelations(t, source, false, "view=summary&include=peserta,dokumen,peserta")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var resp struct {
+		Data     map[string]interface{} `json:"data"`
+		Meta     *response.Meta         `json:"meta"`
+		Warnings []response.Warning     `json:"warnings"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, "T1", resp.Data["tender_id"])
+	assert.Len(t, resp.Data["peserta"], 2)
+	assert.Equal(t, []interface{}{}, resp.Data["dokumen"], "missing children are an empty array")
+	assert.Nil(t, resp.Meta)
+	assert.Empty(t, resp.Warnings)
+	assert.Equal(t, map[string]interface{}{"tender_id": "T1"}, tender[0], "the cached record is not modified")
+
+	assert.Len(t, source.queries, 3)
+	assert.Contains(t, source.queries, "SELECT * FROM nessie_iceberg.tender_peserta WHERE tender_id = 'T1' LIMIT 500")
+	assert.Contains(t, source.queries, "SELECT nama_dokumen FROM nessie_iceberg.tender_dokumen WHERE tender_id = 'T1' LIMIT 50")
+}
+
+func TestTenderGetByID_FailedChild(t *testing.T) {
+	children := map[string][]map[string]interface{}{
+		"nessie_iceberg.tender_peserta": {{"nama_peserta": "PT A"}},
+		"nessie_iceberg.tender_dokumen": {{"nama_dokumen": "BA"}},
+	}
+	failing := map[string]bool{"nessie_iceberg.tender_dokumen": true}
+
+	rec := getTenderWithRelations(t, &childSource{children: children, failing: failing}, false, "include=peserta,dokumen")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var resp struct {
+		Data     map[string]interface{} `json:"data"`
+		Meta     map[string]interface{} `json:"meta"`
+		Warnings []response.Warning     `json:"warnings"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Len(t, resp.Data["peserta"], 1)
+	assert.Equal(t, map[string]interface{}{"warnings": []interface{}{"dokumen could not be loaded"}}, resp.Meta,
+		"the deprecated meta.warnings is still sent")
+	assert.Equal(t, []interface{}{}, resp.Data["dokumen"])
+	assert.Equal(t, []response.Warning{{
+		Code:    response.WarnIncludeFailed,
+		Message: "dokumen could not be loaded",
+		Details: map[string]interface{}{"include": "dokumen"},
+	}}, resp.Warnings)
+
+	// Strict includes fail the request with the child's error
+	rec = getTenderWithRelations(t, &childSource{children: children, failing: failing}, true, "include=peserta,dokumen")
+	assert.Equal(t, http.StatusForbidden, rec.Code, rec.Body.String())
+}
+
+func TestTenderGetByID_UnknownInclude(t *testing.T) {
+	source := &childSource{}
+	rec := getTenderWithRelations(t, source, false, "include=peserta,pemenang")
+
+	assert.Equal(t, []apitypes.Violation{{Field: "include", Message: "unknown include pemenang", Constraint: "oneof=dokumen peserta"}}, violationsOf(t, rec))
+	assert.Empty(t, source.queries)
+}
+
+// tiedSource pages its rows by the ORDER BY, LIMIT and OFFSET of the query,
+// like an engine: rows tied on every ORDER BY term come back in a different
+// order on each call
+type tiedSource struct {
+	recordingSource
+	calls int
+}
+
+var orderByClause = regexp.MustCompile(`ORDER BY (.+?) LIMIT (\d+)(?: OFFSET (\d+))?`)
+
+func (s *tiedSource) ExecuteQuery(ctx context.Context, query string, opts *datasource.QueryOptions) (*datasource.QueryResult, error) {
+	s.calls++
+	s.query = query
+	match := orderByClause.FindStringSubmatch(query)
+	if match == nil {
+		return nil, fmt.Errorf("unpaged query %q", query)
+	}
+	limit, _ := strconv.Atoi(match[2])
+	offset, _ := strconv.Atoi(match[3])
+
+	// Start from a rotation of the rows, so ties are broken differently
+	rows := append(append([]map[string]interface{}{}, s.rows[s.calls%len(s.rows):]...), s.rows[:s.calls%len(s.rows)]...)
+	terms := strings.Split(match[1], ", ")
+	sort.SliceStable(rows, func(i, j int) bool {
+		for _, term := range terms {
+			column, dir, _ := strings.Cut(term, " ")
+			a, b := fmt.Sprint(rows[i][column]), fmt.Sprint(rows[j][column])
+			if a != b {
+				return (a < b) == (dir == "ASC")
+			}
+		}
+		return false
+	})
+
+	page := rows[min(offset, len(rows)):min(offset+limit, len(rows))]
+	return &datasource.QueryResult{Data: page, Count: len(page), Source: s.sourceType}, nil
+}
+
+func TestTenderList_StablePagesWithTiedSortValues(t *testing.T) {
+	rows := make([]map[string]interface{}, 6)
+	for i := range rows {
+		rows[i] = map[string]interface{}{"tender_id": fmt.Sprintf("T-%d", i), "tanggal_buat_paket": "2025-01-01"}
+	}
+
+	pageIDs := func(handler *TenderHandler, offset int) ([]string, response.Meta) {
+		rec := httptest.NewRecorder()
+		handler.List(rec, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/tender?limit=3&offset=%d", offset), nil))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var body struct {
+			Data []map[string]interface{} `json:"data"`
+			Meta response.Meta            `json:"meta"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		var ids []string
+		for _, row := range body.Data {
+			ids = append(ids, row["tender_id"].(string))
+		}
+		return ids, body.Meta
+	}
+
+	source := &tiedSource{recordingSource: recordingSource{sourceType: datasource.DataSourceDremio, rows: rows}}
+	handler := NewTenderHandler(source, testLimits, nil, zap.NewNop())
+	first, meta := pageIDs(handler, 0)
+	second, _ := pageIDs(handler, 3)
+	assert.Equal(t, []string{"T-5", "T-4", "T-3"}, first)
+	assert.Equal(t, []string{"T-2", "T-1", "T-0"}, second)
+	assert.Equal(t, []string{"tanggal_buat_paket DESC", "tender_id DESC"}, meta.Order)
+
+	// Without the tiebreaker the tied rows move between pages
+	handler.SetTiebreakers(config.Tiebreakers{})
+	first, meta = pageIDs(handler, 0)
+	second, _ = pageIDs(handler, 3)
+	assert.Subset(t, []string{"T-0", "T-1", "T-2", "T-3", "T-4", "T-5"}, append(first, second...))
+	assert.NotElementsMatch(t, []string{"T-0", "T-1", "T-2", "T-3", "T-4", "T-5"}, append(first, second...))
+	assert.Equal(t, []string{"tanggal_buat_paket DESC"}, meta.Order)
+
+	// Sorting by the tiebreaker does not order by it twice
+	handler.SetTiebreakers(config.DefaultTiebreakers())
+	rec := httptest.NewRecorder()
+	handler.List(rec, httptest.NewRequest(http.MethodGet, "/api/v1/tender?sort_by=tender_id&order=ASC", nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, source.query, "ORDER BY tender_id ASC LIMIT")
+}
@@ -0,0 +1,120 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/auth"
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/tenant"
+)
+
+// asOfParam is the query parameter, and request field, that reads the Dremio
+// Iceberg tables as they were at a past time
+const asOfParam = "as_of"
+
+// asOfRead is a validated as_of: the time to read the table at and the
+// branch to read it on, empty for the session's branch. The zero value reads
+// the current data.
+type asOfRead struct {
+	At     time.Time
+	Branch string
+}
+
+// meta returns the time for the response meta, empty for a current read
+func (a asOfRead) meta() string {
+	if a.At.IsZero() {
+		return ""
+	}
+	return a.At.UTC().Format(time.RFC3339)
+}
+
+// timeTravel validates as_of against the retention window and the Nessie
+// reference of the request, and audits the reads that use it
+type timeTravel struct {
+	config config.TimeTravelConfig
+	audit  *zap.Logger
+	now    func() time.Time
+}
+
+func newTimeTravel(logger *zap.Logger) *timeTravel {
+	return &timeTravel{
+		config: config.DefaultTimeTravel(),
+		audit:  logger.Named("audit"),
+		now:    time.Now,
+	}
+}
+
+// parse reads raw, an RFC 3339 time or a date read at midnight UTC. The time
+// must be past and within the retention window, and the request must read
+// the default branch unless as_of on other branches is enabled; a tag
+// already is a point in time. An empty raw is a current read.
+func (t *timeTravel) parse(ctx context.Context, raw string) (asOfRead, error) {
+	if raw == "" {
+		return asOfRead{}, nil
+	}
+	at, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		if at, err = time.Parse(time.DateOnly, raw); err != nil {
+			return asOfRead{}, fmt.Errorf("%s must be an RFC 3339 time or a YYYY-MM-DD date", asOfParam)
+		}
+	}
+
+	now := t.now()
+	switch {
+	case at.After(now):
+		return asOfRead{}, fmt.Errorf("%s must not be in the future", asOfParam)
+	case t.config.Retention > 0 && at.Before(now.Add(-t.config.Retention)):
+		return asOfRead{}, fmt.Errorf("%s must be within the last %s; older snapshots are expired", asOfParam, retentionText(t.config.Retention))
+	}
+
+	version, _ := datasource.VersionFromContext(ctx)
+	switch {
+	case version.IsDefault():
+		return asOfRead{At: at}, nil
+	case version.Type == "TAG":
+		return asOfRead{}, fmt.Errorf("%s cannot be combined with tag %s", asOfParam, version.Name)
+	case !t.config.WithBranch:
+		return asOfRead{}, fmt.Errorf("%s is only supported on the default branch %s", asOfParam, version.Default)
+	}
+	return asOfRead{At: at, Branch: version.Name}, nil
+}
+
+// record logs a read as of a past time to the audit logger: who read which
+// table at which time
+func (t *timeTravel) record(r *http.Request, table string, read asOfRead) {
+	if read.At.IsZero() {
+		return
+	}
+	fields := []zap.Field{
+		zap.String("event", "query.as_of"),
+		zap.String("path", r.URL.Path),
+		zap.String("table", table),
+		zap.String("as_of", read.meta()),
+	}
+	if read.Branch != "" {
+		fields = append(fields, zap.String("branch", read.Branch))
+	}
+	if key, ok := auth.KeyFromContext(r.Context()); ok {
+		fields = append(fields, zap.String("api_key_id", key.ID))
+	}
+	if tn, ok := tenant.FromContext(r.Context()); ok {
+		fields = append(fields, zap.String("tenant", tn.ID))
+	}
+	t.audit.Info("Table read as of a past time", fields...)
+}
+
+// retentionText writes whole days as days, e.g. 30 days, and anything else
+// as a duration
+func retentionText(d time.Duration) string {
+	const day = 24 * time.Hour
+	if d%day == 0 {
+		return fmt.Sprintf("%d days", d/day)
+	}
+	return d.String()
+}
@@ -0,0 +1,142 @@
+package v1
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"go-data-gateway/internal/auth"
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+)
+
+// timeTravelNow is the clock of the as_of tests
+var timeTravelNow = time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+
+func fixedTimeTravel(logger *zap.Logger, cfg config.TimeTravelConfig) *timeTravel {
+	tt := newTimeTravel(logger)
+	tt.config = cfg
+	tt.now = func() time.Time { return timeTravelNow }
+	return tt
+}
+
+func TestTenderList_AsOf(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	source := &recordingSource{sourceType: datasource.DataSourceDremio}
+	handler := NewTenderHandler(source, testLimits, nil, zap.NewNop())
+	handler.timeTravel = fixedTimeTravel(zap.New(core), config.DefaultTimeTravel())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/tender?as_of=2025-06-01T08:30:00Z", nil)
+	req = req.WithContext(auth.WithKey(req.Context(), &auth.APIKey{ID: "auditor"}))
+	rec := httptest.NewRecorder()
+	handler.List(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, source.query, "FROM nessie_iceberg.tender_data AT TIMESTAMP '2025-06-01 08:30:00.000'")
+	assert.Equal(t, "2025-06-01T08:30:00Z", decodeResponse(t, rec).Meta.AsOf)
+
+	entries := logs.FilterField(zap.String("event", "query.as_of")).All()
+	require.Len(t, entries, 1)
+	fields := entries[0].ContextMap()
+	assert.Equal(t, "nessie_iceberg.tender_data", fields["table"])
+	assert.Equal(t, "2025-06-01T08:30:00Z", fields["as_of"])
+	assert.Equal(t, "auditor", fields["api_key_id"])
+}
+
+func TestTenderList_AsOfValidation(t *testing.T) {
+	cfg := config.TimeTravelConfig{Retention: 7 * 24 * time.Hour}
+	tests := []struct {
+		name    string
+		asOf    string
+		version *datasource.VersionRef
+		wantErr string
+	}{
+		{name: "not a time", asOf: "yesterday", wantErr: "RFC 3339"},
+		{name: "future", asOf: "2025-06-16", wantErr: "must not be in the future"},
+		{name: "past retention", asOf: "2025-06-01", wantErr: "within the last 7 days"},
+		{
+			name:    "tag",
+			asOf:    "2025-06-14",
+			version: &datasource.VersionRef{Source: "nessie_iceberg", Type: "TAG", Name: "release-2025", Default: "main"},
+			wantErr: "cannot be combined with tag release-2025",
+		},
+		{
+			name:    "branch",
+			asOf:    "2025-06-14",
+			version: &datasource.VersionRef{Source: "nessie_iceberg", Type: "BRANCH", Name: "staging", Default: "main"},
+			wantErr: "only supported on the default branch main",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &recordingSource{sourceType: datasource.DataSourceDremio}
+			handler := NewTenderHandler(source, testLimits, nil, zap.NewNop())
+			handler.timeTravel = fixedTimeTravel(zap.NewNop(), cfg)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/tender?as_of="+tt.asOf, nil)
+			if tt.version != nil {
+				req = req.WithContext(datasource.WithVersion(req.Context(), *tt.version))
+			}
+			rec := httptest.NewRecorder()
+			handler.List(rec, req)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.wantErr)
+			assert.Empty(t, source.query)
+		})
+	}
+}
+
+func TestTenderSearch_AsOfOnBranch(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio}
+	handler := NewTenderHandler(source, testLimits, nil, zap.NewNop())
+	handler.timeTravel = fixedTimeTravel(zap.NewNop(), config.TimeTravelConfig{Retention: 30 * 24 * time.Hour, WithBranch: true})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/tender/search", strings.NewReader(`{"as_of": "2025-06-14"}`))
+	version := datasource.VersionRef{Source: "nessie_iceberg", Type: "BRANCH", Name: "staging", Default: "main"}
+	req = req.WithContext(datasource.WithVersion(req.Context(), version))
+	rec := httptest.NewRecorder()
+	handler.Search(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, source.query, `FROM nessie_iceberg.tender_data AT BRANCH "staging" AS OF '2025-06-14 00:00:00.000'`)
+	assert.Equal(t, "2025-06-14T00:00:00Z", decodeResponse(t, rec).Meta.AsOf)
+}
+
+func TestQuery_AsOf(t *testing.T) {
+	dremio := &recordingSource{sourceType: datasource.DataSourceDremio}
+	bigquery := &recordingSource{sourceType: datasource.DataSourceBigQuery}
+	handler := NewQueryHandler(map[string]datasource.DataSource{"DATAWAREHOUSE": dremio, "BIGQUERY": bigquery}, testLimits, nil, false, zap.NewNop())
+	handler.timeTravel = fixedTimeTravel(zap.NewNop(), config.DefaultTimeTravel())
+
+	execute := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.Execute(rec, httptest.NewRequest(http.MethodPost, "/api/v1/query", strings.NewReader(body)))
+		return rec
+	}
+
+	rec := execute(`{"sql": "SELECT tender_id FROM nessie_iceberg.tender_data WHERE tahun_anggaran = 2025", "source": "DATAWAREHOUSE", "as_of": "2025-06-01"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "SELECT tender_id FROM nessie_iceberg.tender_data AT TIMESTAMP '2025-06-01 00:00:00.000' WHERE tahun_anggaran = 2025", dremio.query)
+	assert.Equal(t, "2025-06-01T00:00:00Z", decodeResponse(t, rec).Meta.AsOf)
+
+	rec = execute(`{"sql": "SELECT * FROM nessie_iceberg.tender_peserta", "source": "DATAWAREHOUSE", "as_of": "2025-06-01"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "as_of is not enabled for table nessie_iceberg.tender_peserta")
+
+	rec = execute(`{"sql": "SELECT * FROM nessie_iceberg.tender_data t JOIN nessie_iceberg.rup_data r ON t.kode_rup = r.kode_rup", "source": "DATAWAREHOUSE", "as_of": "2025-06-01"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "single table")
+
+	rec = execute(`{"sql": "SELECT * FROM nessie_iceberg.tender_data", "source": "BIGQUERY", "as_of": "2025-06-01"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Empty(t, bigquery.query)
+}
@@ -0,0 +1,424 @@
+package v1
+
+import (
+	"fmt"
+	"math/big"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/response"
+	"go-data-gateway/internal/sqlbuilder"
+)
+
+// Timeseries intervals
+const (
+	IntervalDay   = "day"
+	IntervalWeek  = "week"
+	IntervalMonth = "month"
+)
+
+const (
+	bucketLayout = "2006-01-02"
+
+	// Buckets that are entirely in the past never change
+	historicalTimeseriesTTL = 24 * time.Hour
+	currentTimeseriesTTL    = 5 * time.Minute
+)
+
+// TimeseriesPoint is a single bucket value
+type TimeseriesPoint struct {
+	Bucket string  `json:"bucket"`
+	Value  float64 `json:"value"`
+}
+
+// TimeseriesSeries is one ordered series, one per group_by value
+type TimeseriesSeries struct {
+	Group  string            `json:"group,omitempty"`
+	Points []TimeseriesPoint `json:"points"`
+}
+
+// TimeseriesResponse is returned by the timeseries endpoints
+type TimeseriesResponse struct {
+	DateField string             `json:"date_field"`
+	Interval  string             `json:"interval"`
+	Metric    string             `json:"metric"`
+	GroupBy   string             `json:"group_by,omitempty"`
+	Start     string             `json:"start"`
+	End       string             `json:"end"`
+	Series    []TimeseriesSeries `json:"series"`
+}
+
+// timeseriesDialect describes a table and how to aggregate it on one backend
+type timeseriesDialect struct {
+	sql        sqlbuilder.Dialect
+	table      string
+	dateFields map[string]bool
+	groupBy    map[string]bool
+	metrics    map[string]string // metric name -> aggregate expression
+	// truncate returns the SQL bucketing expression for a date field
+	truncate func(field, interval string) string
+	// dateLiteral returns a SQL literal comparable to the date field
+	dateLiteral func(date time.Time) string
+}
+
+// tenderTimeseries aggregates nessie_iceberg.tender_data with Dremio SQL
+var tenderTimeseries = timeseriesDialect{
+	sql:        sqlbuilder.Dremio,
+	table:      "nessie_iceberg.tender_data",
+	dateFields: map[string]bool{"tanggal_pengumuman": true, "tanggal_buat_paket": true},
+	groupBy: map[string]bool{
+		"status_tender":    true,
+		"provinsi":         true,
+		"jenis_pengadaan":  true,
+		"metode_pengadaan": true,
+		"tahun_anggaran":   true,
+	},
+	metrics: map[string]string{
+		"count":          "COUNT(*)",
+		"sum_nilai_pagu": "COALESCE(SUM(nilai_pagu), 0)",
+	},
+	truncate: func(field, interval string) string {
+		return fmt.Sprintf("DATE_TRUNC('%s', %s)", strings.ToUpper(interval), field)
+	},
+	dateLiteral: func(date time.Time) string {
+		return fmt.Sprintf("TIMESTAMP '%s 00:00:00'", date.Format(bucketLayout))
+	},
+}
+
+// rupTimeseries aggregates rup_kromaster with BigQuery SQL
+var rupTimeseries = timeseriesDialect{
+	sql:        sqlbuilder.BigQuery,
+	table:      "gtp-data-prod.layer_isb.rup_kromaster",
+	dateFields: map[string]bool{"_event_date": true},
+	groupBy: map[string]bool{
+		"jenis_klpd":     true,
+		"kd_klpd":        true,
+		"tahun_anggaran": true,
+	},
+	metrics: map[string]string{
+		"count":        "COUNT(*)",
+		"sum_pagu_kro": "COALESCE(SUM(pagu_kro), 0)",
+	},
+	truncate: func(field, interval string) string {
+		part := strings.ToUpper(interval)
+		if interval == IntervalWeek {
+			// Align with Dremio's ISO weeks
+			part = "WEEK(MONDAY)"
+		}
+		return fmt.Sprintf("TIMESTAMP_TRUNC(TIMESTAMP(%s), %s)", field, part)
+	},
+	dateLiteral: func(date time.Time) string {
+		return fmt.Sprintf("TIMESTAMP('%s')", date.Format(bucketLayout))
+	},
+}
+
+// timeseriesRequest is the validated query string of a timeseries request
+type timeseriesRequest struct {
+	dateField string
+	interval  string
+	metric    string
+	groupBy   string
+	start     time.Time
+	end       time.Time // inclusive
+}
+
+// TimeseriesHandler serves time-bucketed aggregations for tenders and RUP
+type TimeseriesHandler struct {
+	dremio   datasource.DataSource
+	bigquery datasource.DataSource
+	maxSpan  time.Duration
+	logger   *zap.Logger
+}
+
+// NewTimeseriesHandler creates a timeseries handler; maxSpan bounds end-start
+func NewTimeseriesHandler(dremio, bigquery datasource.DataSource, maxSpan time.Duration, logger *zap.Logger) *TimeseriesHandler {
+	return &TimeseriesHandler{
+		dremio:   dremio,
+		bigquery: bigquery,
+		maxSpan:  maxSpan,
+		logger:   logger,
+	}
+}
+
+// Tender handles GET /api/v1/tender/timeseries
+func (h *TimeseriesHandler) Tender(w http.ResponseWriter, r *http.Request) {
+	h.serve(w, r, h.dremio, tenderTimeseries, "tanggal_pengumuman")
+}
+
+// RUP handles GET /api/v1/rup/timeseries
+func (h *TimeseriesHandler) RUP(w http.ResponseWriter, r *http.Request) {
+	h.serve(w, r, h.bigquery, rupTimeseries, "_event_date")
+}
+
+func (h *TimeseriesHandler) serve(w http.ResponseWriter, r *http.Request, source datasource.DataSource, dialect timeseriesDialect, defaultDateField string) {
+	if source == nil {
+		response.Error(w, "Data source not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	req, err := parseTimeseriesRequest(r, dialect, defaultDateField, h.maxSpan)
+	if err != nil {
+		response.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ttl := currentTimeseriesTTL
+	if req.end.Before(truncateDate(time.Now().UTC(), IntervalDay)) {
+		ttl = historicalTimeseriesTTL
+	}
+
+	query, err := buildTimeseriesQuery(dialect, req)
+	if err != nil {
+		h.logger.Error("Failed to build timeseries query", zap.Error(err))
+		response.Error(w, "Failed to build timeseries query", http.StatusInternalServerError)
+		return
+	}
+
+	result, err := source.ExecuteQuery(r.Context(), query, &datasource.QueryOptions{
+		CacheTTL: ttl,
+		Timeout:  datasource.Defaults(source).Timeout,
+	})
+	if err != nil {
+		h.logger.Error("Timeseries query failed", zap.Error(err))
+		if !writeUpstreamError(w, err, "Timeseries query failed") {
+			response.Error(w, "Timeseries query failed", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	series, err := fillTimeseries(result.Data, req)
+	if err != nil {
+		h.logger.Error("Unexpected timeseries result", zap.Error(err))
+		response.Error(w, "Unexpected timeseries result", http.StatusInternalServerError)
+		return
+	}
+
+	setAge(w, result)
+	response.Success(w, TimeseriesResponse{
+		DateField: req.dateField,
+		Interval:  req.interval,
+		Metric:    req.metric,
+		GroupBy:   req.groupBy,
+		Start:     req.start.Format(bucketLayout),
+		End:       req.end.Format(bucketLayout),
+		Series:    series,
+	}, nil)
+}
+
+// parseTimeseriesRequest validates the query parameters against the dialect whitelists
+func parseTimeseriesRequest(r *http.Request, dialect timeseriesDialect, defaultDateField string, maxSpan time.Duration) (timeseriesRequest, error) {
+	params := r.URL.Query()
+	req := timeseriesRequest{
+		dateField: params.Get("date_field"),
+		interval:  strings.ToLower(params.Get("interval")),
+		metric:    params.Get("metric"),
+		groupBy:   params.Get("group_by"),
+	}
+
+	if req.dateField == "" {
+		req.dateField = defaultDateField
+	}
+	if !dialect.dateFields[req.dateField] {
+		return req, fmt.Errorf("date_field must be one of: %s", joinKeys(dialect.dateFields))
+	}
+
+	if req.interval == "" {
+		req.interval = IntervalDay
+	}
+	if req.interval != IntervalDay && req.interval != IntervalWeek && req.interval != IntervalMonth {
+		return req, fmt.Errorf("interval must be one of: day, week, month")
+	}
+
+	if req.metric == "" {
+		req.metric = "count"
+	}
+	if _, ok := dialect.metrics[req.metric]; !ok {
+		return req, fmt.Errorf("metric must be one of: %s", joinKeys(dialect.metrics))
+	}
+
+	if req.groupBy != "" && !dialect.groupBy[req.groupBy] {
+		return req, fmt.Errorf("group_by must be one of: %s", joinKeys(dialect.groupBy))
+	}
+
+	var err error
+	if req.start, err = time.Parse(bucketLayout, params.Get("start")); err != nil {
+		return req, fmt.Errorf("start must be a date (YYYY-MM-DD)")
+	}
+	if req.end, err = time.Parse(bucketLayout, params.Get("end")); err != nil {
+		return req, fmt.Errorf("end must be a date (YYYY-MM-DD)")
+	}
+	if req.end.Before(req.start) {
+		return req, fmt.Errorf("end must not be before start")
+	}
+	if maxSpan > 0 && req.end.Sub(req.start) > maxSpan {
+		return req, fmt.Errorf("date range must not exceed %d days", int(maxSpan.Hours()/24))
+	}
+
+	return req, nil
+}
+
+// buildTimeseriesQuery generates the GROUP BY query. The bucket and metric
+// expressions come from the dialect whitelists, the group and date columns
+// are whitelisted and validated by the builder and dates are parsed, so
+// nothing is interpolated from raw input.
+func buildTimeseriesQuery(dialect timeseriesDialect, req timeseriesRequest) (string, error) {
+	builder := sqlbuilder.Select(dialect.sql).From(dialect.table).
+		SelectExpr(dialect.truncate(req.dateField, req.interval), "bucket")
+	groups := []string{"1"}
+	if req.groupBy != "" {
+		builder.SelectAs(req.groupBy, "group_value")
+		groups = append(groups, "2")
+	}
+	builder.SelectExpr(dialect.metrics[req.metric], "metric_value").
+		Where(
+			sqlbuilder.Gte(req.dateField, sqlbuilder.Raw(dialect.dateLiteral(req.start))),
+			sqlbuilder.Lt(req.dateField, sqlbuilder.Raw(dialect.dateLiteral(req.end.AddDate(0, 0, 1)))),
+		).
+		GroupBy(groups...)
+	for _, group := range groups {
+		builder.OrderBy(group, "ASC")
+	}
+	return builder.SQL()
+}
+
+// fillTimeseries arranges rows into ordered series with zero-filled buckets
+func fillTimeseries(rows []map[string]interface{}, req timeseriesRequest) ([]TimeseriesSeries, error) {
+	buckets := timeseriesBuckets(req.start, req.end, req.interval)
+
+	values := make(map[string]map[string]float64) // group -> bucket -> value
+	for _, row := range rows {
+		bucketTime, err := parseBucket(row["bucket"])
+		if err != nil {
+			return nil, err
+		}
+		value, err := toFloat(row["metric_value"])
+		if err != nil {
+			return nil, err
+		}
+
+		group := ""
+		if req.groupBy != "" {
+			group = groupLabel(row["group_value"])
+		}
+		if values[group] == nil {
+			values[group] = make(map[string]float64)
+		}
+		values[group][truncateDate(bucketTime, req.interval).Format(bucketLayout)] += value
+	}
+
+	groups := make([]string, 0, len(values))
+	for group := range values {
+		groups = append(groups, group)
+	}
+	if len(groups) == 0 && req.groupBy == "" {
+		groups = append(groups, "")
+	}
+	sort.Strings(groups)
+
+	series := make([]TimeseriesSeries, 0, len(groups))
+	for _, group := range groups {
+		points := make([]TimeseriesPoint, len(buckets))
+		for i, bucket := range buckets {
+			points[i] = TimeseriesPoint{Bucket: bucket, Value: values[group][bucket]}
+		}
+		series = append(series, TimeseriesSeries{Group: group, Points: points})
+	}
+
+	return series, nil
+}
+
+// timeseriesBuckets lists every bucket start between start and end inclusive
+func timeseriesBuckets(start, end time.Time, interval string) []string {
+	var buckets []string
+	for t := truncateDate(start, interval); !t.After(end); t = nextBucket(t, interval) {
+		buckets = append(buckets, t.Format(bucketLayout))
+	}
+	return buckets
+}
+
+// truncateDate truncates to the start of the day, ISO week (Monday) or month
+func truncateDate(t time.Time, interval string) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch interval {
+	case IntervalWeek:
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	case IntervalMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return day
+	}
+}
+
+func nextBucket(t time.Time, interval string) time.Time {
+	switch interval {
+	case IntervalWeek:
+		return t.AddDate(0, 0, 7)
+	case IntervalMonth:
+		return t.AddDate(0, 1, 0)
+	default:
+		return t.AddDate(0, 0, 1)
+	}
+}
+
+// parseBucket accepts the bucket as returned by Arrow, BigQuery, REST or a cache round trip
+func parseBucket(v interface{}) (time.Time, error) {
+	switch val := v.(type) {
+	case time.Time:
+		return val.UTC(), nil
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05.000", "2006-01-02 15:04:05", bucketLayout} {
+			if t, err := time.Parse(layout, val); err == nil {
+				return t.UTC(), nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized bucket value %v (%T)", v, v)
+}
+
+// toFloat converts numeric values from any backend to float64
+func toFloat(v interface{}) (float64, error) {
+	switch val := v.(type) {
+	case nil:
+		return 0, nil
+	case float64:
+		return val, nil
+	case float32:
+		return float64(val), nil
+	case int:
+		return float64(val), nil
+	case int32:
+		return float64(val), nil
+	case int64:
+		return float64(val), nil
+	case *big.Rat:
+		// BigQuery NUMERIC
+		f, _ := val.Float64()
+		return f, nil
+	case string:
+		return strconv.ParseFloat(val, 64)
+	}
+	return 0, fmt.Errorf("unrecognized metric value %v (%T)", v, v)
+}
+
+func groupLabel(v interface{}) string {
+	if v == nil {
+		return "(null)"
+	}
+	return fmt.Sprint(v)
+}
+
+func joinKeys[V any](m map[string]V) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ", ")
+}
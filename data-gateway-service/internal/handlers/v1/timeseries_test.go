@@ -0,0 +1,151 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/datasource"
+)
+
+// recordingSource captures the last query and returns canned rows
+type recordingSource struct {
+	sourceType datasource.DataSourceType
+	rows       []map[string]interface{}
+	query      string
+	opts       *datasource.QueryOptions
+}
+
+func (s *recordingSource) ExecuteQuery(ctx context.Context, query string, opts *datasource.QueryOptions) (*datasource.QueryResult, error) {
+	s.query, s.opts = query, opts
+	return &datasource.QueryResult{Data: s.rows, Count: len(s.rows), Source: s.sourceType}, nil
+}
+
+func (s *recordingSource) GetData(ctx context.Context, table string, opts *datasource.QueryOptions) (*datasource.QueryResult, error) {
+	return s.ExecuteQuery(ctx, table, opts)
+}
+
+func (s *recordingSource) TestConnection(ctx context.Context) error { return nil }
+
+func (s *recordingSource) GetType() datasource.DataSourceType { return s.sourceType }
+
+func (s *recordingSource) Close() error { return nil }
+
+func getTimeseries(t *testing.T, handler http.HandlerFunc, url string) (int, TimeseriesResponse) {
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, url, nil))
+
+	var body struct {
+		Data TimeseriesResponse `json:"data"`
+	}
+	if rec.Code == http.StatusOK {
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	}
+	return rec.Code, body.Data
+}
+
+func TestTimeseries_TenderFillsMissingBuckets(t *testing.T) {
+	dremio := &recordingSource{
+		sourceType: datasource.DataSourceDremio,
+		rows: []map[string]interface{}{
+			{"bucket": time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), "metric_value": int64(3)},
+			{"bucket": "2025-01-03 00:00:00.000", "metric_value": int64(5)},
+		},
+	}
+	h := NewTimeseriesHandler(dremio, nil, 31*24*time.Hour, zap.NewNop())
+
+	code, resp := getTimeseries(t, h.Tender, "/api/v1/tender/timeseries?interval=day&start=2025-01-01&end=2025-01-04")
+	require.Equal(t, http.StatusOK, code)
+
+	assert.Contains(t, dremio.query, "DATE_TRUNC('DAY', tanggal_pengumuman) AS bucket")
+	assert.Contains(t, dremio.query, "COUNT(*) AS metric_value")
+	assert.Contains(t, dremio.query, "tanggal_pengumuman >= TIMESTAMP '2025-01-01 00:00:00'")
+	assert.Contains(t, dremio.query, "tanggal_pengumuman < TIMESTAMP '2025-01-05 00:00:00'")
+	assert.Equal(t, historicalTimeseriesTTL, dremio.opts.CacheTTL)
+
+	require.Len(t, resp.Series, 1)
+	assert.Equal(t, []TimeseriesPoint{
+		{Bucket: "2025-01-01", Value: 3},
+		{Bucket: "2025-01-02", Value: 0},
+		{Bucket: "2025-01-03", Value: 5},
+		{Bucket: "2025-01-04", Value: 0},
+	}, resp.Series[0].Points)
+}
+
+func TestTimeseries_TenderGroupBy(t *testing.T) {
+	dremio := &recordingSource{
+		sourceType: datasource.DataSourceDremio,
+		rows: []map[string]interface{}{
+			{"bucket": "2025-01-01T00:00:00Z", "group_value": "Jawa Barat", "metric_value": 1.5e9},
+			{"bucket": "2025-02-01T00:00:00Z", "group_value": "Bali", "metric_value": 2e9},
+		},
+	}
+	h := NewTimeseriesHandler(dremio, nil, 0, zap.NewNop())
+
+	code, resp := getTimeseries(t, h.Tender, "/api/v1/tender/timeseries?interval=month&metric=sum_nilai_pagu&group_by=provinsi&start=2025-01-15&end=2025-02-10")
+	require.Equal(t, http.StatusOK, code)
+
+	assert.Contains(t, dremio.query, "provinsi AS group_value")
+	assert.Contains(t, dremio.query, "COALESCE(SUM(nilai_pagu), 0) AS metric_value")
+	assert.Contains(t, dremio.query, "GROUP BY 1, 2")
+
+	require.Len(t, resp.Series, 2)
+	assert.Equal(t, "Bali", resp.Series[0].Group)
+	assert.Equal(t, []TimeseriesPoint{{Bucket: "2025-01-01", Value: 0}, {Bucket: "2025-02-01", Value: 2e9}}, resp.Series[0].Points)
+	assert.Equal(t, "Jawa Barat", resp.Series[1].Group)
+	assert.Equal(t, []TimeseriesPoint{{Bucket: "2025-01-01", Value: 1.5e9}, {Bucket: "2025-02-01", Value: 0}}, resp.Series[1].Points)
+}
+
+func TestTimeseries_RUPUsesBigQuerySyntax(t *testing.T) {
+	bigquery := &recordingSource{sourceType: datasource.DataSourceBigQuery}
+	h := NewTimeseriesHandler(nil, bigquery, 0, zap.NewNop())
+
+	code, resp := getTimeseries(t, h.RUP, "/api/v1/rup/timeseries?interval=week&start=2025-01-01&end=2025-01-14")
+	require.Equal(t, http.StatusOK, code)
+
+	assert.Contains(t, bigquery.query, "TIMESTAMP_TRUNC(TIMESTAMP(_event_date), WEEK(MONDAY)) AS bucket")
+	assert.Contains(t, bigquery.query, "_event_date >= TIMESTAMP('2025-01-01')")
+
+	// 2025-01-01 is a Wednesday, so buckets start on Monday 2024-12-30
+	require.Len(t, resp.Series, 1)
+	assert.Equal(t, []TimeseriesPoint{
+		{Bucket: "2024-12-30", Value: 0},
+		{Bucket: "2025-01-06", Value: 0},
+		{Bucket: "2025-01-13", Value: 0},
+	}, resp.Series[0].Points)
+}
+
+func TestTimeseries_Validation(t *testing.T) {
+	h := NewTimeseriesHandler(&recordingSource{}, &recordingSource{}, 31*24*time.Hour, zap.NewNop())
+
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{"unknown date field", "/t?date_field=nama_paket&start=2025-01-01&end=2025-01-02"},
+		{"unknown interval", "/t?interval=hour&start=2025-01-01&end=2025-01-02"},
+		{"unknown metric", "/t?metric=avg_pagu&start=2025-01-01&end=2025-01-02"},
+		{"unknown group by", "/t?group_by=nama_paket%3BDROP&start=2025-01-01&end=2025-01-02"},
+		{"missing start", "/t?end=2025-01-02"},
+		{"end before start", "/t?start=2025-02-01&end=2025-01-01"},
+		{"span too large", "/t?start=2025-01-01&end=2025-06-01"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _ := getTimeseries(t, h.Tender, tt.url)
+			assert.Equal(t, http.StatusBadRequest, code)
+		})
+	}
+
+	unavailable := NewTimeseriesHandler(nil, nil, 0, zap.NewNop())
+	code, _ := getTimeseries(t, unavailable.RUP, "/t?start=2025-01-01&end=2025-01-02")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+}
@@ -0,0 +1,193 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/response"
+	"go-data-gateway/pkg/apitypes"
+)
+
+// fieldError returns a *Violation as an error, for checks that are shared
+// with other callers
+func fieldError(field, constraint, format string, args ...interface{}) error {
+	return &apitypes.Violation{Field: field, Message: fmt.Sprintf(format, args...), Constraint: constraint}
+}
+
+// violations collects the problems of a request so they are reported
+// together instead of one per round trip
+type violations []apitypes.Violation
+
+// add records a violation of field
+func (v *violations) add(field, constraint, format string, args ...interface{}) {
+	*v = append(*v, apitypes.Violation{Field: field, Message: fmt.Sprintf(format, args...), Constraint: constraint})
+}
+
+// required records a missing field
+func (v *violations) required(field string) {
+	v.add(field, "required", "%s is required", field)
+}
+
+// addErr records err, a *Violation, a join of them or a plain error of field
+// that breaks constraint. A nil err is ignored.
+func (v *violations) addErr(field, constraint string, err error) {
+	if err == nil {
+		return
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			v.addErr(field, constraint, e)
+		}
+		return
+	}
+	var violation *apitypes.Violation
+	if errors.As(err, &violation) {
+		*v = append(*v, *violation)
+		return
+	}
+	v.add(field, constraint, "%s", err.Error())
+}
+
+// limit applies the page limit policy to the requested size of field and
+// returns it, or records why it is rejected and returns 0
+func (v *violations) limit(field string, requested int, policy config.PageLimit) int {
+	limit, err := applyLimit(requested, policy)
+	if err == nil {
+		return limit
+	}
+	if requested < 0 {
+		v.add(field, "min=1", "%s must be positive", field)
+	} else {
+		v.add(field, fmt.Sprintf("max=%d", policy.Max), "%s must not exceed %d", field, policy.Max)
+	}
+	return 0
+}
+
+// seconds returns the duration requested in seconds by field, fallback when
+// it is absent, or records why it is rejected and returns fallback
+func (v *violations) seconds(field string, requested *int, fallback, ceiling time.Duration) time.Duration {
+	switch {
+	case requested == nil:
+		return fallback
+	case *requested < 1:
+		v.add(field, "min=1", "%s must be positive", field)
+	case time.Duration(*requested)*time.Second > ceiling:
+		max := wholeSeconds(ceiling)
+		v.add(field, fmt.Sprintf("max=%d", max), "%s must not exceed %d", field, max)
+	default:
+		return time.Duration(*requested) * time.Second
+	}
+	return fallback
+}
+
+// source records a missing data_source, or one that is not in sources
+func (v *violations) source(field, name string, sources map[string]bool) {
+	if name == "" {
+		v.required(field)
+		return
+	}
+	if sources[name] {
+		return
+	}
+	names := make([]string, 0, len(sources))
+	for known := range sources {
+		names = append(names, known)
+	}
+	sort.Strings(names)
+	v.add(field, "oneof="+strings.Join(names, " "), "unknown data source %s", name)
+}
+
+// write responds 400 VALIDATION_FAILED with the violations and reports
+// whether there were any
+func (v violations) write(w http.ResponseWriter) bool {
+	if len(v) == 0 {
+		return false
+	}
+	response.ErrorWithCode(w, apitypes.ErrCodeValidationFailed, "Request validation failed", []apitypes.Violation(v), http.StatusBadRequest)
+	return true
+}
+
+// decodeBody decodes the JSON body of r into dst, rejecting fields dst does
+// not have. On failure it responds 400 VALIDATION_FAILED naming the offending
+// field and returns false.
+func decodeBody(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(dst); err != nil {
+		violations{decodeViolation(err)}.write(w)
+		return false
+	}
+	return true
+}
+
+// decodeViolation describes a decoding error as a violation
+func decodeViolation(err error) apitypes.Violation {
+	var (
+		violation *apitypes.Violation
+		typeErr   *json.UnmarshalTypeError
+		syntaxErr *json.SyntaxError
+	)
+	switch {
+	case errors.As(err, &violation):
+		return *violation
+	case errors.As(err, &typeErr):
+		field := typeErr.Field
+		if field == "" {
+			field = "body"
+		}
+		return apitypes.Violation{Field: field, Message: fmt.Sprintf("%s must be %s", field, jsonKind(typeErr.Type)), Constraint: "type"}
+	case errors.As(err, &syntaxErr):
+		return apitypes.Violation{Field: "body", Message: fmt.Sprintf("invalid JSON at offset %d: %v", syntaxErr.Offset, syntaxErr), Constraint: "json"}
+	case errors.Is(err, io.EOF):
+		return apitypes.Violation{Field: "body", Message: "request body is required", Constraint: "required"}
+	}
+
+	// Unknown fields are reported as a plain error naming the field
+	if name, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		if unquoted, unquoteErr := strconv.Unquote(name); unquoteErr == nil {
+			name = unquoted
+		}
+		return apitypes.Violation{Field: name, Message: fmt.Sprintf("unknown field %s", name), Constraint: "unknown_field"}
+	}
+	return apitypes.Violation{Field: "body", Message: err.Error(), Constraint: "json"}
+}
+
+// jsonKind names the JSON value a Go type is decoded from
+func jsonKind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "an integer"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "a non-negative integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Pointer:
+		return jsonKind(t.Elem())
+	default:
+		return "an object"
+	}
+}
+
+// sourceNames is the set of names of sources
+func sourceNames[S any](sources map[string]S) map[string]bool {
+	names := make(map[string]bool, len(sources))
+	for name := range sources {
+		names[name] = true
+	}
+	return names
+}
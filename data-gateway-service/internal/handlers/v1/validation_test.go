@@ -0,0 +1,175 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/pkg/apitypes"
+)
+
+// violationsOf decodes a 400 VALIDATION_FAILED response into its violations
+func violationsOf(t *testing.T, rec *httptest.ResponseRecorder) []apitypes.Violation {
+	t.Helper()
+	require.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+
+	var body struct {
+		Success bool `json:"success"`
+		Error   struct {
+			Code    string               `json:"code"`
+			Message string               `json:"message"`
+			Details []apitypes.Violation `json:"details"`
+		} `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body), rec.Body.String())
+	assert.False(t, body.Success)
+	require.Equal(t, apitypes.ErrCodeValidationFailed, body.Error.Code)
+	require.NotEmpty(t, body.Error.Details)
+	return body.Error.Details
+}
+
+func TestDecodeBody_NamesTheOffendingField(t *testing.T) {
+	tests := []struct {
+		body string
+		want apitypes.Violation
+	}{
+		{`{"sql": "SELECT 1", "source": "DATAWAREHOUSE", "limt": 5}`,
+			apitypes.Violation{Field: "limt", Message: "unknown field limt", Constraint: "unknown_field"}},
+		{`{"sql": "SELECT 1", "source": "DATAWAREHOUSE", "limit": "5"}`,
+			apitypes.Violation{Field: "limit", Message: "limit must be an integer", Constraint: "type"}},
+		{`{"sql": "SELECT 1", "labels": ["a"]}`,
+			apitypes.Violation{Field: "labels", Message: "labels must be an object", Constraint: "type"}},
+		{`{"sql": "SELECT 1",}`,
+			apitypes.Violation{Field: "body", Message: "invalid JSON at offset 20: invalid character '}' looking for beginning of object key string", Constraint: "json"}},
+		{``,
+			apitypes.Violation{Field: "body", Message: "request body is required", Constraint: "required"}},
+	}
+
+	for _, tt := range tests {
+		var req apitypes.QueryRequest
+		rec := httptest.NewRecorder()
+		ok := decodeBody(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body)), &req)
+		assert.False(t, ok, tt.body)
+		assert.Equal(t, []apitypes.Violation{tt.want}, violationsOf(t, rec), tt.body)
+	}
+}
+
+func TestQuery_AggregatesViolations(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio, rows: rowsOf(1)}
+	handler := NewQueryHandler(map[string]datasource.DataSource{"DATAWAREHOUSE": source}, testLimits, nil, false, zap.NewNop())
+
+	rec := httptest.NewRecorder()
+	handler.Execute(rec, httptest.NewRequest(http.MethodPost, "/api/v1/query", bytes.NewBufferString(
+		`{"source": "ORACLE", "limit": 51, "max_age_seconds": -1, "labels": {"Team": "x"}}`)))
+
+	violations := violationsOf(t, rec)
+	require.Len(t, violations, 5)
+	assert.Equal(t, apitypes.Violation{Field: "sql", Message: "sql is required", Constraint: "required"}, violations[0])
+	assert.Equal(t, "source", violations[1].Field)
+	assert.Equal(t, "oneof=BIGQUERY DATAWAREHOUSE MYSQL POSTGRES", violations[1].Constraint)
+	assert.Equal(t, apitypes.Violation{Field: "limit", Message: "limit must not exceed 50", Constraint: "max=50"}, violations[2])
+	assert.Equal(t, apitypes.Violation{Field: "max_age_seconds", Message: "max_age_seconds must not be negative", Constraint: "min=0"}, violations[3])
+	assert.Equal(t, "labels", violations[4].Field)
+	assert.Empty(t, source.query)
+
+	// A known type that is not configured is unavailable, not invalid
+	rec = httptest.NewRecorder()
+	handler.Execute(rec, httptest.NewRequest(http.MethodPost, "/api/v1/query", bytes.NewBufferString(
+		`{"sql": "SELECT 1", "source": "BIGQUERY"}`)))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
+
+func TestBatch_AggregatesViolations(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio, rows: rowsOf(1)}
+	handler := NewBatchHandler(map[string]datasource.DataSource{"DATAWAREHOUSE": source}, nil, zap.NewNop())
+
+	for _, execute := range []http.HandlerFunc{handler.Execute, handler.Stream} {
+		rec := httptest.NewRecorder()
+		execute(rec, httptest.NewRequest(http.MethodPost, "/api/v1/batch", bytes.NewBufferString(`{"queries": [
+			{"id": "ok", "data_source": "DATAWAREHOUSE", "query": "SELECT 1"},
+			{"id": "a", "data_source": "NOPE", "table": "t"},
+			{"id": "b", "labels": {"gateway": "x"}}]}`)))
+
+		assert.Equal(t, []apitypes.Violation{
+			{Field: "queries[1].data_source", Message: "unknown data source NOPE", Constraint: "oneof=DATAWAREHOUSE"},
+			{Field: "queries[2].data_source", Message: "queries[2].data_source is required", Constraint: "required"},
+			{Field: "queries[2].query", Message: "either query or table is required", Constraint: "required_without=table"},
+			{Field: "queries[2].labels", Message: `label "gateway" is reserved`, Constraint: "labels"},
+		}, violationsOf(t, rec))
+
+		rec = httptest.NewRecorder()
+		execute(rec, httptest.NewRequest(http.MethodPost, "/api/v1/batch", bytes.NewBufferString(`{"queries": []}`)))
+		assert.Equal(t, []apitypes.Violation{{Field: "queries", Message: "at least one query is required", Constraint: "min=1"}}, violationsOf(t, rec))
+
+		rec = httptest.NewRecorder()
+		execute(rec, httptest.NewRequest(http.MethodPost, "/api/v1/batch", bytes.NewBufferString(
+			`{"queries": [{"id": "a", "data_source": "DATAWAREHOUSE", "query": "SELECT 1", "source": "x"}]}`)))
+		assert.Equal(t, []apitypes.Violation{{Field: "queries[0].source", Message: "unknown field source", Constraint: "unknown_field"}}, violationsOf(t, rec))
+	}
+	assert.Empty(t, source.query)
+}
+
+func TestStream_AggregatesViolations(t *testing.T) {
+	source := &recordingSource{sourceType: datasource.DataSourceDremio, rows: rowsOf(1)}
+	handler := NewStreamHandler(map[string]datasource.DataSource{"DATAWAREHOUSE": source}, testLimits, zap.NewNop())
+
+	body := `{"data_source": "NOPE", "chunk_size": 51, "format": "xml", "spill": "sync"}`
+	rec := httptest.NewRecorder()
+	handler.Stream(rec, httptest.NewRequest(http.MethodPost, "/api/v1/stream", strings.NewReader(body)))
+	fields := make([]string, 0)
+	for _, violation := range violationsOf(t, rec) {
+		fields = append(fields, violation.Field+" "+violation.Constraint)
+	}
+	assert.Equal(t, []string{
+		"data_source oneof=DATAWAREHOUSE",
+		"query required_without=table",
+		"chunk_size max=50",
+		"format oneof=json ndjson csv",
+		"spill enabled",
+	}, fields)
+
+	// SSE has no format or spill
+	rec = httptest.NewRecorder()
+	handler.StreamSSE(rec, httptest.NewRequest(http.MethodPost, "/api/v1/stream/sse", strings.NewReader(body)))
+	assert.Len(t, violationsOf(t, rec), 3)
+	assert.Nil(t, source.opts)
+}
+
+func TestSearch_AggregatesViolations(t *testing.T) {
+	rup, querier := newTestRUPHandler()
+	rec := httptest.NewRecorder()
+	rup.Search(rec, httptest.NewRequest(http.MethodPost, "/api/v1/rup/search", bytes.NewBufferString(
+		`{"limit": 51, "offset": -1, "match": "some", "tahun": "x", "kd_satker": "y"}`)))
+	assert.Equal(t, []apitypes.Violation{
+		{Field: "limit", Message: "limit must not exceed 50", Constraint: "max=50"},
+		{Field: "offset", Message: "offset must not be negative", Constraint: "min=0"},
+		{Field: "match", Message: "match must be all or any", Constraint: "oneof=all any"},
+		{Field: "tahun", Message: "tahun must be an integer", Constraint: "integer"},
+		{Field: "kd_satker", Message: "kd_satker must be an integer", Constraint: "integer"},
+	}, violationsOf(t, rec))
+
+	rec = httptest.NewRecorder()
+	rup.Search(rec, httptest.NewRequest(http.MethodPost, "/api/v1/rup/search", bytes.NewBufferString(`{"keywords": "jalan"}`)))
+	assert.Equal(t, []apitypes.Violation{{Field: "keywords", Message: "unknown field keywords", Constraint: "unknown_field"}}, violationsOf(t, rec))
+	assert.Empty(t, querier.queries)
+
+	source := &recordingSource{sourceType: datasource.DataSourceDremio}
+	tender := NewTenderHandler(source, testLimits, nil, zap.NewNop())
+	rec = httptest.NewRecorder()
+	tender.Search(rec, httptest.NewRequest(http.MethodPost, "/api/v1/tender/search", bytes.NewBufferString(
+		`{"limit": 51, "keyword": "jalan", "match": "some", "filters": [{"field": "nilai_pagu", "op": "gte", "value": "x"}]}`)))
+	assert.Equal(t, []apitypes.Violation{
+		{Field: "limit", Message: "limit must not exceed 50", Constraint: "max=50"},
+		{Field: "match", Message: "match must be all or any", Constraint: "oneof=all any"},
+		{Field: "filters[0].value", Message: "nilai_pagu is a number column", Constraint: "gte"},
+	}, violationsOf(t, rec))
+	assert.Empty(t, source.query)
+}
@@ -0,0 +1,191 @@
+// Package inflight tracks the queries, batches and streams running on this
+// replica, so an operator can see what is running during an incident. An
+// operation is registered for the lifetime of its request and updated through
+// the request context by the handler and the data source serving it.
+package inflight
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// maxSQLLen bounds the SQL kept for an operation
+const maxSQLLen = 500
+
+// Kind is the type of an operation
+type Kind string
+
+const (
+	KindQuery  Kind = "query"
+	KindBatch  Kind = "batch"
+	KindStream Kind = "stream"
+)
+
+// Phase is what an operation is doing
+type Phase string
+
+const (
+	PhaseQueued    Phase = "queued"    // Waiting for a slot or a connection
+	PhaseExecuting Phase = "executing" // Waiting for the data source
+	PhaseStreaming Phase = "streaming" // Writing rows to the client
+)
+
+// Entry is a snapshot of an operation
+type Entry struct {
+	ID        string    `json:"id"`
+	Kind      Kind      `json:"kind"`
+	RequestID string    `json:"request_id,omitempty"`
+	APIKeyID  string    `json:"api_key_id,omitempty"`
+	Source    string    `json:"source,omitempty"`
+	SQL       string    `json:"sql,omitempty"`
+	Phase     Phase     `json:"phase"`
+	Rows      int64     `json:"rows_emitted"`
+	StartedAt time.Time `json:"started_at"`
+	AgeMS     int64     `json:"age_ms"`
+}
+
+// Registry holds the operations in flight. Operations only touch the
+// registry when they start and end; their updates are atomic stores.
+type Registry struct {
+	ops sync.Map // id -> *Op
+	now func() time.Time
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{now: time.Now}
+}
+
+// Start registers an operation and returns a context carrying it. The caller
+// must call Done when the operation ends, in a defer so a panic removes it too.
+func (r *Registry) Start(ctx context.Context, kind Kind, requestID, apiKeyID string) (context.Context, *Op) {
+	var b [8]byte
+	rand.Read(b[:])
+
+	op := &Op{
+		registry:  r,
+		id:        hex.EncodeToString(b[:]),
+		kind:      kind,
+		requestID: requestID,
+		apiKeyID:  apiKeyID,
+		startedAt: r.now(),
+	}
+	op.phase.Store(PhaseQueued)
+	op.described.Store(&description{})
+
+	r.ops.Store(op.id, op)
+	return context.WithValue(ctx, opKey{}, op), op
+}
+
+// List returns the operations running for at least minAge, oldest first
+func (r *Registry) List(minAge time.Duration) []Entry {
+	now := r.now()
+	entries := []Entry{}
+	r.ops.Range(func(_, value any) bool {
+		op := value.(*Op)
+		if now.Sub(op.startedAt) >= minAge {
+			entries = append(entries, op.entry(now))
+		}
+		return true
+	})
+	sort.Slice(entries, func(i, j int) bool { return entries[i].StartedAt.Before(entries[j].StartedAt) })
+	return entries
+}
+
+type opKey struct{}
+
+// FromContext returns the operation of ctx, or nil. Every method of Op does
+// nothing on nil, so callers need not check.
+func FromContext(ctx context.Context) *Op {
+	op, _ := ctx.Value(opKey{}).(*Op)
+	return op
+}
+
+// description is what an operation runs, known once its request is parsed
+type description struct {
+	source string
+	sql    string
+}
+
+// Op is an operation in flight
+type Op struct {
+	registry  *Registry
+	id        string
+	kind      Kind
+	requestID string
+	apiKeyID  string
+	startedAt time.Time
+
+	described atomic.Pointer[description]
+	phase     atomic.Value // Phase
+	rows      atomic.Int64
+}
+
+// ID returns the id of the operation
+func (o *Op) ID() string {
+	if o == nil {
+		return ""
+	}
+	return o.id
+}
+
+// Describe records the source and SQL, or table, the operation runs
+func (o *Op) Describe(source, sql string) {
+	if o == nil {
+		return
+	}
+	if len(sql) > maxSQLLen {
+		cut := maxSQLLen
+		for !utf8.RuneStart(sql[cut]) {
+			cut--
+		}
+		sql = sql[:cut] + "..."
+	}
+	o.described.Store(&description{source: source, sql: sql})
+}
+
+// SetPhase records what the operation is doing
+func (o *Op) SetPhase(phase Phase) {
+	if o == nil {
+		return
+	}
+	o.phase.Store(phase)
+}
+
+// AddRows counts n more rows emitted
+func (o *Op) AddRows(n int) {
+	if o == nil {
+		return
+	}
+	o.rows.Add(int64(n))
+}
+
+// Done removes the operation from the registry; later calls do nothing
+func (o *Op) Done() {
+	if o == nil {
+		return
+	}
+	o.registry.ops.Delete(o.id)
+}
+
+func (o *Op) entry(now time.Time) Entry {
+	described := o.described.Load()
+	return Entry{
+		ID:        o.id,
+		Kind:      o.kind,
+		RequestID: o.requestID,
+		APIKeyID:  o.apiKeyID,
+		Source:    described.source,
+		SQL:       described.sql,
+		Phase:     o.phase.Load().(Phase),
+		Rows:      o.rows.Load(),
+		StartedAt: o.startedAt.UTC(),
+		AgeMS:     now.Sub(o.startedAt).Milliseconds(),
+	}
+}
@@ -0,0 +1,101 @@
+package inflight
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_ListsOldestFirstAndFiltersByAge(t *testing.T) {
+	now := time.Date(2025, 3, 4, 10, 0, 0, 0, time.UTC)
+	r := NewRegistry()
+	r.now = func() time.Time { return now }
+
+	_, old := r.Start(context.Background(), KindStream, "req-1", "analyst")
+	now = now.Add(20 * time.Second)
+	_, recent := r.Start(context.Background(), KindQuery, "req-2", "reporting")
+	now = now.Add(15 * time.Second)
+
+	old.Describe("DATAWAREHOUSE", "nessie_iceberg.tender_data")
+	old.SetPhase(PhaseStreaming)
+	old.AddRows(100)
+	old.AddRows(50)
+
+	entries := r.List(0)
+	require.Len(t, entries, 2)
+	assert.Equal(t, old.ID(), entries[0].ID)
+	assert.Equal(t, recent.ID(), entries[1].ID)
+
+	assert.Equal(t, Entry{
+		ID:        old.ID(),
+		Kind:      KindStream,
+		RequestID: "req-1",
+		APIKeyID:  "analyst",
+		Source:    "DATAWAREHOUSE",
+		SQL:       "nessie_iceberg.tender_data",
+		Phase:     PhaseStreaming,
+		Rows:      150,
+		StartedAt: time.Date(2025, 3, 4, 10, 0, 0, 0, time.UTC),
+		AgeMS:     35000,
+	}, entries[0])
+	assert.Equal(t, PhaseQueued, entries[1].Phase)
+
+	entries = r.List(30 * time.Second)
+	require.Len(t, entries, 1)
+	assert.Equal(t, old.ID(), entries[0].ID)
+
+	old.Done()
+	old.Done()
+	recent.Done()
+	assert.Empty(t, r.List(0))
+}
+
+func TestOp_TruncatesSQL(t *testing.T) {
+	r := NewRegistry()
+	_, op := r.Start(context.Background(), KindQuery, "", "")
+	defer op.Done()
+
+	op.Describe("BIGQUERY", "SELECT '"+strings.Repeat("é", maxSQLLen)+"'")
+	sql := r.List(0)[0].SQL
+	assert.LessOrEqual(t, len(sql), maxSQLLen+len("..."))
+	assert.True(t, strings.HasSuffix(sql, "é..."), sql)
+}
+
+func TestOp_FromContext(t *testing.T) {
+	// Every method is a no-op without an operation
+	op := FromContext(context.Background())
+	assert.Nil(t, op)
+	op.Describe("DATAWAREHOUSE", "SELECT 1")
+	op.SetPhase(PhaseExecuting)
+	op.AddRows(1)
+	op.Done()
+
+	r := NewRegistry()
+	ctx, started := r.Start(context.Background(), KindBatch, "", "")
+	defer started.Done()
+	FromContext(ctx).SetPhase(PhaseExecuting)
+	assert.Equal(t, PhaseExecuting, r.List(0)[0].Phase)
+}
+
+func TestRegistry_ConcurrentOperations(t *testing.T) {
+	r := NewRegistry()
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, op := r.Start(context.Background(), KindQuery, "", "")
+			defer op.Done()
+			op.SetPhase(PhaseExecuting)
+			op.AddRows(10)
+			r.List(0)
+		}()
+	}
+	wg.Wait()
+	assert.Empty(t, r.List(0))
+}
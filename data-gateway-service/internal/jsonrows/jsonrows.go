@@ -0,0 +1,250 @@
+// Package jsonrows encodes query rows ([]map[string]interface{}) as JSON
+// without reflection for the column types data sources return. Output is
+// byte-identical to encoding/json: keys in sorted order, HTML-safe string
+// escaping and the same number formatting. Other values, such as nested maps
+// and slices, are encoded with encoding/json.
+package jsonrows
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+var disabled atomic.Bool
+
+// SetEnabled switches the fast path on or off; when off, rows are encoded
+// with encoding/json. It is on by default.
+func SetEnabled(enabled bool) {
+	disabled.Store(!enabled)
+}
+
+// Enabled reports whether the fast path is on
+func Enabled() bool {
+	return !disabled.Load()
+}
+
+// Encoder appends rows that share a set of columns. The sorted columns and
+// their encoded keys are computed once and reused while rows keep the shape.
+// An Encoder is not safe for concurrent use.
+type Encoder struct {
+	columns []string
+	keys    [][]byte // `"column":` of each column, the first without a comma
+}
+
+// NewEncoder creates an encoder; columns are taken from the first row
+func NewEncoder() *Encoder {
+	return &Encoder{}
+}
+
+// AppendRows appends rows as a JSON array, like json.Marshal(rows)
+func AppendRows(buf []byte, rows []map[string]interface{}) ([]byte, error) {
+	if !Enabled() {
+		return appendMarshal(buf, rows)
+	}
+	if rows == nil {
+		return append(buf, "null"...), nil
+	}
+
+	enc := NewEncoder()
+	buf = append(buf, '[')
+	for i, row := range rows {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		var err error
+		if buf, err = enc.AppendRow(buf, row); err != nil {
+			return buf, err
+		}
+	}
+	return append(buf, ']'), nil
+}
+
+// AppendRow appends row as a JSON object, like json.Marshal(row)
+func (e *Encoder) AppendRow(buf []byte, row map[string]interface{}) ([]byte, error) {
+	if !Enabled() {
+		return appendMarshal(buf, row)
+	}
+	if row == nil {
+		return append(buf, "null"...), nil
+	}
+	if len(row) != len(e.columns) {
+		e.reset(row)
+	}
+
+	start := len(buf)
+	buf = append(buf, '{')
+	for i, column := range e.columns {
+		value, ok := row[column]
+		if !ok {
+			// Same size, different keys: adopt this row's shape
+			e.reset(row)
+			return e.AppendRow(buf[:start], row)
+		}
+		buf = append(buf, e.keys[i]...)
+
+		var err error
+		if buf, err = appendValue(buf, value); err != nil {
+			return buf[:start], err
+		}
+	}
+	return append(buf, '}'), nil
+}
+
+// reset takes the columns of row in encoding/json order
+func (e *Encoder) reset(row map[string]interface{}) {
+	e.columns = e.columns[:0]
+	for column := range row {
+		e.columns = append(e.columns, column)
+	}
+	sort.Strings(e.columns)
+
+	e.keys = e.keys[:0]
+	for i, column := range e.columns {
+		var key []byte
+		if i > 0 {
+			key = append(key, ',')
+		}
+		key = appendString(key, column)
+		e.keys = append(e.keys, append(key, ':'))
+	}
+}
+
+// appendValue encodes the types drivers put in rows directly and defers the
+// rest to encoding/json
+func appendValue(buf []byte, value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return append(buf, "null"...), nil
+	case string:
+		return appendString(buf, v), nil
+	case bool:
+		return strconv.AppendBool(buf, v), nil
+	case int:
+		return strconv.AppendInt(buf, int64(v), 10), nil
+	case int8:
+		return strconv.AppendInt(buf, int64(v), 10), nil
+	case int16:
+		return strconv.AppendInt(buf, int64(v), 10), nil
+	case int32:
+		return strconv.AppendInt(buf, int64(v), 10), nil
+	case int64:
+		return strconv.AppendInt(buf, v, 10), nil
+	case uint:
+		return strconv.AppendUint(buf, uint64(v), 10), nil
+	case uint8:
+		return strconv.AppendUint(buf, uint64(v), 10), nil
+	case uint16:
+		return strconv.AppendUint(buf, uint64(v), 10), nil
+	case uint32:
+		return strconv.AppendUint(buf, uint64(v), 10), nil
+	case uint64:
+		return strconv.AppendUint(buf, v, 10), nil
+	case float32:
+		return appendFloat(buf, float64(v), 32, value)
+	case float64:
+		return appendFloat(buf, v, 64, value)
+	case time.Time:
+		b, err := v.MarshalJSON()
+		if err != nil {
+			return appendMarshal(buf, value) // Reports the error as encoding/json does
+		}
+		return append(buf, b...), nil
+	default:
+		return appendMarshal(buf, value)
+	}
+}
+
+func appendMarshal(buf []byte, value interface{}) ([]byte, error) {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return buf, err
+	}
+	return append(buf, b...), nil
+}
+
+// appendFloat formats like encoding/json: exponent notation only for very
+// small or large magnitudes, without a leading zero in the exponent
+func appendFloat(buf []byte, f float64, bits int, value interface{}) ([]byte, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return appendMarshal(buf, value) // Unsupported value error
+	}
+
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) ||
+			bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			format = 'e'
+		}
+	}
+	buf = strconv.AppendFloat(buf, f, format, -1, bits)
+	if format == 'e' {
+		// e-09 to e-9
+		n := len(buf)
+		if n >= 4 && buf[n-4] == 'e' && buf[n-3] == '-' && buf[n-2] == '0' {
+			buf[n-2] = buf[n-1]
+			buf = buf[:n-1]
+		}
+	}
+	return buf, nil
+}
+
+const hex = "0123456789abcdef"
+
+// appendString quotes s like encoding/json with HTML escaping: <, > and &
+// become \u escapes and U+2028/U+2029 are escaped for JavaScript. Invalid
+// UTF-8, whose replacement differs between Go releases, is left to
+// encoding/json.
+func appendString(buf []byte, s string) []byte {
+	if !utf8.ValidString(s) {
+		b, _ := json.Marshal(s)
+		return append(buf, b...)
+	}
+
+	buf = append(buf, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' && b != '<' && b != '>' && b != '&' {
+				i++
+				continue
+			}
+			buf = append(buf, s[start:i]...)
+			switch b {
+			case '\\', '"':
+				buf = append(buf, '\\', b)
+			case '\b':
+				buf = append(buf, '\\', 'b')
+			case '\f':
+				buf = append(buf, '\\', 'f')
+			case '\n':
+				buf = append(buf, '\\', 'n')
+			case '\r':
+				buf = append(buf, '\\', 'r')
+			case '\t':
+				buf = append(buf, '\\', 't')
+			default:
+				buf = append(buf, '\\', 'u', '0', '0', hex[b>>4], hex[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+
+		c, size := utf8.DecodeRuneInString(s[i:])
+		if c == '\u2028' || c == '\u2029' {
+			buf = append(buf, s[start:i]...)
+			buf = append(buf, '\\', 'u', '2', '0', '2', hex[c&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	buf = append(buf, s[start:]...)
+	return append(buf, '"')
+}
@@ -0,0 +1,216 @@
+package jsonrows
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// assertMatches checks AppendRows against encoding/json, errors included
+func assertMatches(t *testing.T, rows []map[string]interface{}) {
+	t.Helper()
+	want, wantErr := json.Marshal(rows)
+	got, err := AppendRows(nil, rows)
+	if wantErr != nil {
+		assert.Error(t, err)
+		return
+	}
+	require.NoError(t, err)
+	assert.Equal(t, string(want), string(got))
+}
+
+func TestAppendRows_MatchesEncodingJSON(t *testing.T) {
+	at := time.Date(2025, 3, 1, 8, 30, 0, 123000000, time.FixedZone("WIB", 7*3600))
+
+	assertMatches(t, nil)
+	assertMatches(t, []map[string]interface{}{})
+	assertMatches(t, []map[string]interface{}{nil, {}})
+	assertMatches(t, []map[string]interface{}{
+		{
+			"nama_paket": "Jalan <Tol> & \"Jembatan\"\n\t\\",
+			"controls":   "\x00\x01\b\f\r\x1f\x7f",
+			"unicode":    "Pengadaan é ñ 漢字 \u2028\u2029 😀",
+			"invalid":    "bad \xff\xfe utf8 \xe2\x82",
+			"nilai_pagu": 1500000000.0,
+			"small":      0.000001,
+			"tiny":       1e-7,
+			"huge":       1e21,
+			"negative":   -2.5e-9,
+			"f32":        float32(3.14),
+			"f32_small":  float32(1e-7),
+			"int":        42,
+			"int8":       int8(-8),
+			"int16":      int16(16),
+			"int32":      int32(-32),
+			"int64":      int64(math.MinInt64),
+			"uint":       uint(7),
+			"uint8":      uint8(255),
+			"uint16":     uint16(16),
+			"uint32":     uint32(32),
+			"uint64":     uint64(math.MaxUint64),
+			"bool":       true,
+			"null":       nil,
+			"time":       at,
+			"nested":     map[string]interface{}{"b": []interface{}{1, "<x>", nil}, "a": map[string]int{"z": 1}},
+			"bytes":      []byte("raw"),
+			"number":     json.Number("12.50"),
+		},
+		// Same size, different keys
+		{"a": 1, "b": 2},
+		{"a": 1, "c": 3},
+		// Keys needing escapes and sorting by raw bytes
+		{"<key>": 1, "Z": 2, "a": 3, "é": 4, "": 5},
+	})
+}
+
+func TestAppendRows_Errors(t *testing.T) {
+	assertMatches(t, []map[string]interface{}{{"v": math.NaN()}})
+	assertMatches(t, []map[string]interface{}{{"v": math.Inf(-1)}})
+	assertMatches(t, []map[string]interface{}{{"v": float32(math.Inf(1))}})
+	assertMatches(t, []map[string]interface{}{{"v": time.Date(10000, 1, 1, 0, 0, 0, 0, time.UTC)}})
+	assertMatches(t, []map[string]interface{}{{"v": make(chan int)}})
+}
+
+func TestAppendRows_Disabled(t *testing.T) {
+	SetEnabled(false)
+	defer SetEnabled(true)
+
+	assertMatches(t, []map[string]interface{}{{"a": 1.5, "b": "<x>"}})
+}
+
+// randomValue builds values of the kinds drivers return, nested up to depth
+func randomValue(r *rand.Rand, depth int) interface{} {
+	kinds := 9
+	if depth > 0 {
+		kinds = 11
+	}
+	switch r.Intn(kinds) {
+	case 0:
+		return nil
+	case 1:
+		return randomString(r)
+	case 2:
+		return r.Intn(2) == 0
+	case 3:
+		return r.Int63() - r.Int63()
+	case 4:
+		return math.Float64frombits(r.Uint64())
+	case 5:
+		return (r.Float64() - 0.5) * math.Pow(10, float64(r.Intn(50)-25))
+	case 6:
+		return float32(r.NormFloat64() * math.Pow(10, float64(r.Intn(20)-10)))
+	case 7:
+		return time.Unix(r.Int63n(1<<33), r.Int63n(1e9)).UTC()
+	case 8:
+		return r.Intn(1000)
+	case 9:
+		m := map[string]interface{}{}
+		for i := r.Intn(4); i > 0; i-- {
+			m[randomString(r)] = randomValue(r, depth-1)
+		}
+		return m
+	default:
+		s := make([]interface{}, r.Intn(4))
+		for i := range s {
+			s[i] = randomValue(r, depth-1)
+		}
+		return s
+	}
+}
+
+func randomString(r *rand.Rand) string {
+	alphabet := []string{"a", "Z", "0", " ", "\"", "\\", "<", ">", "&", "\n", "\x00", "\x7f", "é", "漢", "\u2028", "\xff", "\xe2\x82", "😀"}
+	s := ""
+	for i := r.Intn(8); i > 0; i-- {
+		s += alphabet[r.Intn(len(alphabet))]
+	}
+	return s
+}
+
+func TestAppendRows_RandomRows(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		columns := 1 + r.Intn(6)
+		rows := make([]map[string]interface{}, 1+r.Intn(5))
+		for j := range rows {
+			rows[j] = map[string]interface{}{}
+			for c := 0; c < columns; c++ {
+				rows[j][fmt.Sprintf("col_%d", c)] = randomValue(r, 2)
+			}
+			// Occasionally change the shape mid-result
+			if r.Intn(5) == 0 {
+				rows[j][randomString(r)] = randomValue(r, 1)
+			}
+		}
+		assertMatches(t, rows)
+	}
+}
+
+func FuzzAppendRow(f *testing.F) {
+	f.Add("nama_paket", "Jalan <Tol> & \"Jembatan\"", 1500000000.0, int64(-7), true)
+	f.Add("", "\x00\xff\u2028", 1e-7, int64(0), false)
+	f.Add("é", "😀", math.MaxFloat64, int64(math.MaxInt64), true)
+
+	f.Fuzz(func(t *testing.T, key, value string, number float64, integer int64, flag bool) {
+		row := map[string]interface{}{
+			key:       value,
+			"number":  number,
+			"integer": integer,
+			"flag":    flag,
+			"nested":  map[string]interface{}{key: []interface{}{value, number}},
+		}
+		assertMatches(t, []map[string]interface{}{row, row})
+	})
+}
+
+// syntheticRows builds n rows shaped like a tender query result
+func syntheticRows(n int) []map[string]interface{} {
+	rows := make([]map[string]interface{}, n)
+	announced := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range rows {
+		rows[i] = map[string]interface{}{
+			"tender_id":          fmt.Sprintf("TND-%07d", i),
+			"nama_paket":         fmt.Sprintf("Pembangunan Jalan Desa Paket %d", i),
+			"nilai_pagu":         float64(i) * 1250000.5,
+			"nilai_kontrak":      int64(i) * 1000000,
+			"metode_pengadaan":   "Tender",
+			"tahun_anggaran":     int64(2025),
+			"status_tender":      "Selesai",
+			"tanggal_pengumuman": announced.Add(time.Duration(i) * time.Hour),
+			"provinsi":           "Jawa Barat",
+			"is_deleted":         false,
+			"catatan":            nil,
+		}
+	}
+	return rows
+}
+
+func BenchmarkEncodeRows(b *testing.B) {
+	rows := syntheticRows(100000)
+
+	b.Run("encoding_json", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := json.Marshal(rows); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("jsonrows", func(b *testing.B) {
+		b.ReportAllocs()
+		var buf []byte
+		for i := 0; i < b.N; i++ {
+			var err error
+			if buf, err = AppendRows(buf[:0], rows); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,103 @@
+package killswitch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisFlagsKey is the hash of the overrides, keyed by kind and name
+const redisFlagsKey = "gateway:flags"
+
+// Store keeps the overrides set through the admin API, keyed by flag key
+type Store interface {
+	// Load returns every override
+	Load(ctx context.Context) (map[string]Override, error)
+	// Save sets the override of key
+	Save(ctx context.Context, key string, o Override) error
+	// Delete removes the override of key
+	Delete(ctx context.Context, key string) error
+}
+
+// MemoryStore keeps the overrides of this replica only
+type MemoryStore struct {
+	mu        sync.Mutex
+	overrides map[string]Override
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{overrides: make(map[string]Override)}
+}
+
+// Load implements Store
+func (s *MemoryStore) Load(ctx context.Context) (map[string]Override, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	overrides := make(map[string]Override, len(s.overrides))
+	for key, o := range s.overrides {
+		overrides[key] = o
+	}
+	return overrides, nil
+}
+
+// Save implements Store
+func (s *MemoryStore) Save(ctx context.Context, key string, o Override) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.overrides[key] = o
+	return nil
+}
+
+// Delete implements Store
+func (s *MemoryStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.overrides, key)
+	return nil
+}
+
+// RedisStore shares the overrides between replicas as JSON values of a hash
+type RedisStore struct {
+	client *redis.Client
+}
+
+// NewRedisStore creates a store shared by every replica using client
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+// Load implements Store. A value that does not decode is skipped rather
+// than hiding the other overrides.
+func (s *RedisStore) Load(ctx context.Context) (map[string]Override, error) {
+	values, err := s.client.HGetAll(ctx, redisFlagsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	overrides := make(map[string]Override, len(values))
+	for key, value := range values {
+		var o Override
+		if json.Unmarshal([]byte(value), &o) == nil {
+			overrides[key] = o
+		}
+	}
+	return overrides, nil
+}
+
+// Save implements Store
+func (s *RedisStore) Save(ctx context.Context, key string, o Override) error {
+	value, err := json.Marshal(o)
+	if err != nil {
+		return fmt.Errorf("encoding override of %s: %w", key, err)
+	}
+	return s.client.HSet(ctx, redisFlagsKey, key, value).Err()
+}
+
+// Delete implements Store
+func (s *RedisStore) Delete(ctx context.Context, key string) error {
+	return s.client.HDel(ctx, redisFlagsKey, key).Err()
+}
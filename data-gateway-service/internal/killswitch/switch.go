@@ -0,0 +1,376 @@
+// Package killswitch switches API routes and data sources off while the
+// gateway runs, so operators can stop traffic to a misbehaving endpoint or
+// upstream without a deploy. Flags come from the policy file and from
+// overrides set through the admin API; overrides win over the policy and are
+// shared through Redis when clustered, so every replica agrees.
+package killswitch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/response"
+)
+
+// ErrCodeEndpointDisabled is returned for a request to a route or data
+// source switched off
+const ErrCodeEndpointDisabled = "ENDPOINT_DISABLED"
+
+// DefaultRetryAfter is sent as Retry-After when a flag sets none
+const DefaultRetryAfter = time.Minute
+
+// refreshTimeout bounds a read of the shared overrides
+const refreshTimeout = 5 * time.Second
+
+// Kind is what a flag switches off
+type Kind string
+
+const (
+	KindRoute  Kind = "route"  // An API path and the paths below it
+	KindSource Kind = "source" // A data source, for every route using it
+)
+
+// Origin is where the state of a flag comes from
+const (
+	OriginPolicy = "policy"
+	OriginAdmin  = "admin"
+)
+
+// Key returns the store key of the flag of kind and name
+func Key(kind Kind, name string) string {
+	return string(kind) + ":" + name
+}
+
+// Validate rejects a kind other than route or source and a name the kind
+// cannot have
+func Validate(kind Kind, name string) error {
+	switch kind {
+	case KindRoute:
+		return config.ValidateFlagRoute(name)
+	case KindSource:
+		return config.ValidateFlagSource(name)
+	default:
+		return fmt.Errorf("unknown flag kind %q, must be %q or %q", kind, KindRoute, KindSource)
+	}
+}
+
+// Override is a flag set through the admin API. Disabled false re-enables
+// a route or source the policy switches off.
+type Override struct {
+	Disabled          bool      `json:"disabled"`
+	Message           string    `json:"message,omitempty"`
+	RetryAfterSeconds int       `json:"retry_after_seconds,omitempty"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// Flag is the state of a route or source in effect
+type Flag struct {
+	Kind              Kind       `json:"kind"`
+	Name              string     `json:"name"`
+	Disabled          bool       `json:"disabled"`
+	Message           string     `json:"message,omitempty"`
+	RetryAfterSeconds int        `json:"retry_after_seconds,omitempty"`
+	Origin            string     `json:"origin"`
+	UpdatedAt         *time.Time `json:"updated_at,omitempty"`
+}
+
+// err returns the *DisabledError of a disabled flag, nil otherwise
+func (f Flag) err() error {
+	if !f.Disabled {
+		return nil
+	}
+	retryAfter := time.Duration(f.RetryAfterSeconds) * time.Second
+	if retryAfter <= 0 {
+		retryAfter = DefaultRetryAfter
+	}
+	return &DisabledError{Kind: f.Kind, Name: f.Name, Message: f.Message, RetryAfter: retryAfter}
+}
+
+// DisabledError is returned for a request to a route or data source
+// switched off
+type DisabledError struct {
+	Kind       Kind
+	Name       string
+	Message    string // Operator's explanation, if any
+	RetryAfter time.Duration
+}
+
+func (e *DisabledError) Error() string {
+	return fmt.Sprintf("%s %s is temporarily disabled", e.subject(), e.Name)
+}
+
+// subject names the kind of what is disabled in messages
+func (e *DisabledError) subject() string {
+	if e.Kind == KindSource {
+		return "data source"
+	}
+	return "route"
+}
+
+// DisabledDetails is the error.details of an ENDPOINT_DISABLED response
+type DisabledDetails struct {
+	Kind   Kind   `json:"kind"`
+	Name   string `json:"name"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// WriteError responds 503 ENDPOINT_DISABLED with Retry-After when err is a
+// *DisabledError
+func WriteError(w http.ResponseWriter, err error) bool {
+	var disabledErr *DisabledError
+	if !errors.As(err, &disabledErr) {
+		return false
+	}
+
+	subject := disabledErr.subject()
+	message := strings.ToUpper(subject[:1]) + subject[1:] + " " + disabledErr.Name + " is temporarily disabled"
+	if disabledErr.Message != "" {
+		message += ": " + disabledErr.Message
+	}
+	retryAfter := max(int(math.Ceil(disabledErr.RetryAfter.Seconds())), 1)
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	response.ErrorWithCode(w, ErrCodeEndpointDisabled, message,
+		DisabledDetails{Kind: disabledErr.Kind, Name: disabledErr.Name, Reason: disabledErr.Message}, http.StatusServiceUnavailable)
+	return true
+}
+
+// Switch answers whether a route or source is switched off, merging the
+// flags of the active policy with the overrides of its store. Lookups read
+// the overrides last loaded, so they never wait on the store. A nil *Switch
+// disables nothing.
+type Switch struct {
+	store    Store
+	policy   func() config.FlagPolicy
+	interval time.Duration
+	now      func() time.Time
+	logger   *zap.Logger
+
+	mu        sync.RWMutex
+	overrides map[string]Override
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// New creates a switch keeping overrides in store and reading the policy's
+// flags through policy on every lookup, so a reloaded policy applies at
+// once. Overrides set by other replicas are read every interval.
+func New(store Store, policy func() config.FlagPolicy, interval time.Duration, logger *zap.Logger) *Switch {
+	if interval <= 0 {
+		interval = config.DefaultKillSwitch().RefreshInterval
+	}
+	return &Switch{
+		store:     store,
+		policy:    policy,
+		interval:  interval,
+		now:       time.Now,
+		logger:    logger,
+		overrides: make(map[string]Override),
+		stop:      make(chan struct{}),
+	}
+}
+
+// Shared reports whether overrides are shared with other replicas
+func (s *Switch) Shared() bool {
+	if s == nil {
+		return false
+	}
+	_, shared := s.store.(*RedisStore)
+	return shared
+}
+
+// Route returns a *DisabledError when path is switched off. The flag of the
+// longest route that is path or one of its parents decides, so a sub-route
+// can be re-enabled below a disabled one.
+func (s *Switch) Route(path string) error {
+	if s == nil {
+		return nil
+	}
+	var match *Flag
+	for name, flag := range s.flags(KindRoute) {
+		if path != name && !strings.HasPrefix(path, name+"/") {
+			continue
+		}
+		if match == nil || len(name) > len(match.Name) {
+			match = &flag
+		}
+	}
+	if match == nil {
+		return nil
+	}
+	return match.err()
+}
+
+// Source returns a *DisabledError when the data source name is switched off
+func (s *Switch) Source(name string) error {
+	if s == nil {
+		return nil
+	}
+	if flag, ok := s.flags(KindSource)[name]; ok {
+		return flag.err()
+	}
+	return nil
+}
+
+// State returns every flag in effect, ordered by kind and name
+func (s *Switch) State() []Flag {
+	if s == nil {
+		return []Flag{}
+	}
+	flags := make([]Flag, 0)
+	for _, kind := range []Kind{KindRoute, KindSource} {
+		for _, flag := range s.flags(kind) {
+			flags = append(flags, flag)
+		}
+	}
+	sort.Slice(flags, func(i, j int) bool {
+		if flags[i].Kind != flags[j].Kind {
+			return flags[i].Kind < flags[j].Kind
+		}
+		return flags[i].Name < flags[j].Name
+	})
+	return flags
+}
+
+// flags returns the flags of kind in effect by name: the policy's, replaced
+// by the overrides
+func (s *Switch) flags(kind Kind) map[string]Flag {
+	policy := s.policy()
+	fromPolicy := policy.Routes
+	if kind == KindSource {
+		fromPolicy = policy.Sources
+	}
+
+	flags := make(map[string]Flag, len(fromPolicy))
+	for name, f := range fromPolicy {
+		flags[name] = Flag{
+			Kind:              kind,
+			Name:              name,
+			Disabled:          true,
+			Message:           f.Message,
+			RetryAfterSeconds: int(math.Ceil(f.RetryAfter.Seconds())),
+			Origin:            OriginPolicy,
+		}
+	}
+
+	prefix := Key(kind, "")
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for key, o := range s.overrides {
+		name, ok := strings.CutPrefix(key, prefix)
+		if !ok {
+			continue
+		}
+		updatedAt := o.UpdatedAt
+		flags[name] = Flag{
+			Kind:              kind,
+			Name:              name,
+			Disabled:          o.Disabled,
+			Message:           o.Message,
+			RetryAfterSeconds: o.RetryAfterSeconds,
+			Origin:            OriginAdmin,
+			UpdatedAt:         &updatedAt,
+		}
+	}
+	return flags
+}
+
+// Set stores the override of a route or source and applies it to this
+// replica; the others pick it up at their next refresh
+func (s *Switch) Set(ctx context.Context, kind Kind, name string, o Override) error {
+	if err := Validate(kind, name); err != nil {
+		return err
+	}
+	if o.RetryAfterSeconds < 0 {
+		return fmt.Errorf("retry_after_seconds must not be negative")
+	}
+	o.UpdatedAt = s.now().UTC()
+
+	key := Key(kind, name)
+	if err := s.store.Save(ctx, key, o); err != nil {
+		return fmt.Errorf("storing flag %s: %w", key, err)
+	}
+	s.mu.Lock()
+	s.overrides[key] = o
+	s.mu.Unlock()
+
+	s.logger.Info("Flag set", zap.String("kind", string(kind)), zap.String("name", name),
+		zap.Bool("disabled", o.Disabled), zap.String("message", o.Message))
+	return nil
+}
+
+// Clear removes the override of a route or source, leaving the policy's
+// flag, if any, in effect
+func (s *Switch) Clear(ctx context.Context, kind Kind, name string) error {
+	if err := Validate(kind, name); err != nil {
+		return err
+	}
+
+	key := Key(kind, name)
+	if err := s.store.Delete(ctx, key); err != nil {
+		return fmt.Errorf("removing flag %s: %w", key, err)
+	}
+	s.mu.Lock()
+	delete(s.overrides, key)
+	s.mu.Unlock()
+
+	s.logger.Info("Flag cleared", zap.String("kind", string(kind)), zap.String("name", name))
+	return nil
+}
+
+// Refresh loads the overrides from the store. On failure the overrides last
+// loaded stay in effect.
+func (s *Switch) Refresh(ctx context.Context) error {
+	overrides, err := s.store.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("loading flags: %w", err)
+	}
+	s.mu.Lock()
+	s.overrides = overrides
+	s.mu.Unlock()
+	return nil
+}
+
+// Start refreshes the overrides every interval until Stop
+func (s *Switch) Start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), refreshTimeout)
+				if err := s.Refresh(ctx); err != nil {
+					s.logger.Warn("Failed to refresh flags, keeping the last loaded", zap.Error(err))
+				}
+				cancel()
+			}
+		}
+	}()
+}
+
+// Stop stops the refreshes and waits for a running one to finish; later
+// calls do nothing
+func (s *Switch) Stop() {
+	if s == nil {
+		return
+	}
+	s.stopOnce.Do(func() { close(s.stop) })
+	s.wg.Wait()
+}
@@ -0,0 +1,132 @@
+package killswitch
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/config"
+)
+
+func staticPolicy(p config.FlagPolicy) func() config.FlagPolicy {
+	return func() config.FlagPolicy { return p }
+}
+
+func TestSwitch_Route(t *testing.T) {
+	sw := New(NewMemoryStore(), staticPolicy(config.FlagPolicy{
+		Routes: map[string]config.DisabledFlag{
+			"/api/v1/tender": {Message: "Reindexing", RetryAfter: 2 * time.Minute},
+		},
+	}), time.Minute, zap.NewNop())
+
+	// A route covers the paths below it, not those sharing its prefix
+	for _, path := range []string{"/api/v1/tender", "/api/v1/tender/42", "/api/v1/tender/search"} {
+		var disabledErr *DisabledError
+		require.ErrorAs(t, sw.Route(path), &disabledErr, path)
+		assert.Equal(t, &DisabledError{Kind: KindRoute, Name: "/api/v1/tender", Message: "Reindexing", RetryAfter: 2 * time.Minute}, disabledErr)
+	}
+	assert.NoError(t, sw.Route("/api/v1/tenders"))
+	assert.NoError(t, sw.Route("/api/v1/rup"))
+
+	// The longest route decides, so a sub-route can be switched back on
+	require.NoError(t, sw.Set(t.Context(), KindRoute, "/api/v1/tender/search", Override{Disabled: false}))
+	assert.NoError(t, sw.Route("/api/v1/tender/search"))
+	assert.Error(t, sw.Route("/api/v1/tender/42"))
+
+	var nilSwitch *Switch
+	assert.NoError(t, nilSwitch.Route("/api/v1/tender"))
+	assert.NoError(t, nilSwitch.Source("DATAWAREHOUSE"))
+	assert.Empty(t, nilSwitch.State())
+}
+
+func TestSwitch_OverridesWinOverPolicy(t *testing.T) {
+	sw := New(NewMemoryStore(), staticPolicy(config.FlagPolicy{
+		Sources: map[string]config.DisabledFlag{"BIGQUERY": {}},
+	}), time.Minute, zap.NewNop())
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	sw.now = func() time.Time { return now }
+
+	// A policy flag without retry_after is retried after a minute
+	var disabledErr *DisabledError
+	require.ErrorAs(t, sw.Source("BIGQUERY"), &disabledErr)
+	assert.Equal(t, DefaultRetryAfter, disabledErr.RetryAfter)
+	assert.NoError(t, sw.Source("DATAWAREHOUSE"))
+
+	require.NoError(t, sw.Set(t.Context(), KindSource, "BIGQUERY", Override{Disabled: false}))
+	require.NoError(t, sw.Set(t.Context(), KindSource, "DATAWAREHOUSE", Override{Disabled: true, Message: "Dremio upgrade", RetryAfterSeconds: 300}))
+	assert.NoError(t, sw.Source("BIGQUERY"))
+	require.ErrorAs(t, sw.Source("DATAWAREHOUSE"), &disabledErr)
+	assert.Equal(t, 5*time.Minute, disabledErr.RetryAfter)
+
+	assert.Equal(t, []Flag{
+		{Kind: KindSource, Name: "BIGQUERY", Origin: OriginAdmin, UpdatedAt: &now},
+		{Kind: KindSource, Name: "DATAWAREHOUSE", Disabled: true, Message: "Dremio upgrade", RetryAfterSeconds: 300, Origin: OriginAdmin, UpdatedAt: &now},
+	}, sw.State())
+
+	// Clearing the override leaves the policy in effect again
+	require.NoError(t, sw.Clear(t.Context(), KindSource, "BIGQUERY"))
+	assert.Error(t, sw.Source("BIGQUERY"))
+
+	assert.Error(t, sw.Set(t.Context(), "table", "tender", Override{Disabled: true}))
+	assert.Error(t, sw.Set(t.Context(), KindRoute, "/api/v1/admin/flags", Override{Disabled: true}))
+	assert.Error(t, sw.Set(t.Context(), KindRoute, "/api/v1/rup", Override{Disabled: true, RetryAfterSeconds: -1}))
+}
+
+func TestSwitch_RedisSharedAcrossReplicas(t *testing.T) {
+	mr := miniredis.RunT(t)
+	replicas := make([]*Switch, 2)
+	for i := range replicas {
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		t.Cleanup(func() { client.Close() })
+		replicas[i] = New(NewRedisStore(client), staticPolicy(config.FlagPolicy{}), time.Minute, zap.NewNop())
+		assert.True(t, replicas[i].Shared())
+	}
+
+	require.NoError(t, replicas[0].Set(t.Context(), KindRoute, "/api/v1/rup", Override{Disabled: true, Message: "Quota exhausted"}))
+	assert.NoError(t, replicas[1].Route("/api/v1/rup"), "picked up at the next refresh")
+	require.NoError(t, replicas[1].Refresh(t.Context()))
+	assert.Error(t, replicas[1].Route("/api/v1/rup"))
+
+	require.NoError(t, replicas[1].Clear(t.Context(), KindRoute, "/api/v1/rup"))
+	require.NoError(t, replicas[0].Refresh(t.Context()))
+	assert.NoError(t, replicas[0].Route("/api/v1/rup"))
+
+	// An unreachable store keeps the flags last loaded
+	require.NoError(t, replicas[0].Set(t.Context(), KindSource, "BIGQUERY", Override{Disabled: true}))
+	mr.Close()
+	assert.Error(t, replicas[0].Refresh(t.Context()))
+	assert.Error(t, replicas[0].Source("BIGQUERY"))
+}
+
+func TestWriteError(t *testing.T) {
+	rec := httptest.NewRecorder()
+	assert.False(t, WriteError(rec, errors.New("boom")))
+	assert.False(t, WriteError(rec, nil))
+
+	rec = httptest.NewRecorder()
+	err := &DisabledError{Kind: KindSource, Name: "BIGQUERY", Message: "Quota exhausted", RetryAfter: 90 * time.Second}
+	require.True(t, WriteError(rec, err))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "90", rec.Header().Get("Retry-After"))
+
+	var body struct {
+		Error struct {
+			Code    string          `json:"code"`
+			Message string          `json:"message"`
+			Details DisabledDetails `json:"details"`
+		} `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, ErrCodeEndpointDisabled, body.Error.Code)
+	assert.Equal(t, "Data source BIGQUERY is temporarily disabled: Quota exhausted", body.Error.Message)
+	assert.Equal(t, DisabledDetails{Kind: KindSource, Name: "BIGQUERY", Reason: "Quota exhausted"}, body.Error.Details)
+}
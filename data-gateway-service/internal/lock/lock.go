@@ -0,0 +1,179 @@
+// Package lock elects the replica that runs a scheduled task. Locks are Redis
+// keys set with NX and a TTL that the holder renews while the task runs; each
+// acquisition carries a fencing token that increases per lock name, so work
+// written by a holder that lost its lock can be told apart from the current
+// holder's.
+package lock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	// ErrNotAcquired is returned when another owner holds the lock
+	ErrNotAcquired = errors.New("lock is held by another owner")
+	// ErrLost is returned when renewing a lock that expired or changed owner
+	ErrLost = errors.New("lock lost")
+)
+
+const (
+	redisLockPrefix  = "gateway:lock:"
+	redisFencePrefix = "gateway:lock-fence:"
+)
+
+// renewScript extends the TTL only while the key still holds our value
+var renewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+// releaseScript deletes the key only while it still holds our value
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// Holder describes a held lock for the admin API
+type Holder struct {
+	Name       string    `json:"name"`
+	Owner      string    `json:"owner"`
+	Token      int64     `json:"token"`
+	AcquiredAt time.Time `json:"acquired_at"`
+	ExpiresIn  int64     `json:"expires_in_ms"`
+}
+
+// lockValue is stored as the value of a lock key
+type lockValue struct {
+	Owner      string    `json:"owner"`
+	Token      int64     `json:"token"`
+	AcquiredAt time.Time `json:"acquired_at"`
+}
+
+// RedisLocker acquires locks shared by all gateway replicas
+type RedisLocker struct {
+	client *redis.Client
+	owner  string
+	now    func() time.Time
+}
+
+// NewRedisLocker creates a locker that acquires locks on behalf of owner
+func NewRedisLocker(client *redis.Client, owner string) *RedisLocker {
+	return &RedisLocker{client: client, owner: owner, now: time.Now}
+}
+
+// Owner returns the name this replica holds locks under
+func (l *RedisLocker) Owner() string {
+	return l.owner
+}
+
+// Acquire takes the named lock for ttl. It returns ErrNotAcquired when
+// another owner holds it, or the Redis error when Redis is unavailable.
+func (l *RedisLocker) Acquire(ctx context.Context, name string, ttl time.Duration) (*Lease, error) {
+	token, err := l.client.Incr(ctx, redisFencePrefix+name).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := json.Marshal(lockValue{Owner: l.owner, Token: token, AcquiredAt: l.now().UTC()})
+	if err != nil {
+		return nil, err
+	}
+
+	key := redisLockPrefix + name
+	acquired, err := l.client.SetNX(ctx, key, value, ttl).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !acquired {
+		return nil, ErrNotAcquired
+	}
+
+	return &Lease{client: l.client, name: name, key: key, value: string(value), token: token, ttl: ttl}, nil
+}
+
+// Holders returns every held lock sorted by name
+func (l *RedisLocker) Holders(ctx context.Context) ([]Holder, error) {
+	holders := []Holder{}
+	iter := l.client.Scan(ctx, 0, redisLockPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		raw, err := l.client.Get(ctx, key).Result()
+		if errors.Is(err, redis.Nil) {
+			continue // Expired since the scan
+		}
+		if err != nil {
+			return nil, err
+		}
+		var value lockValue
+		if err := json.Unmarshal([]byte(raw), &value); err != nil {
+			return nil, fmt.Errorf("corrupt lock %s: %w", key, err)
+		}
+		ttl, err := l.client.PTTL(ctx, key).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		holders = append(holders, Holder{
+			Name:       strings.TrimPrefix(key, redisLockPrefix),
+			Owner:      value.Owner,
+			Token:      value.Token,
+			AcquiredAt: value.AcquiredAt,
+			ExpiresIn:  ttl.Milliseconds(),
+		})
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(holders, func(i, j int) bool { return holders[i].Name < holders[j].Name })
+	return holders, nil
+}
+
+// Lease is a held lock
+type Lease struct {
+	client *redis.Client
+	name   string
+	key    string
+	value  string
+	token  int64
+	ttl    time.Duration
+}
+
+// Name returns the name of the lock
+func (l *Lease) Name() string {
+	return l.name
+}
+
+// Token returns the fencing token of this acquisition. Tokens of a lock
+// name only increase, so a larger token is always the later holder.
+func (l *Lease) Token() int64 {
+	return l.token
+}
+
+// Renew resets the lock's TTL. It returns ErrLost when the lock expired or
+// was taken by another owner in the meantime.
+func (l *Lease) Renew(ctx context.Context) error {
+	renewed, err := renewScript.Run(ctx, l.client, []string{l.key}, l.value, l.ttl.Milliseconds()).Int64()
+	if err != nil {
+		return err
+	}
+	if renewed == 0 {
+		return ErrLost
+	}
+	return nil
+}
+
+// Release deletes the lock if it is still held by this lease
+func (l *Lease) Release(ctx context.Context) error {
+	return releaseScript.Run(ctx, l.client, []string{l.key}, l.value).Err()
+}
@@ -0,0 +1,183 @@
+package lock
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newTestLockers(t *testing.T, owners ...string) (*miniredis.Miniredis, []*RedisLocker) {
+	mr := miniredis.RunT(t)
+	lockers := make([]*RedisLocker, 0, len(owners))
+	for _, owner := range owners {
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		t.Cleanup(func() { client.Close() })
+		lockers = append(lockers, NewRedisLocker(client, owner))
+	}
+	return mr, lockers
+}
+
+func TestRedisLocker_Contention(t *testing.T) {
+	ctx := context.Background()
+	_, lockers := newTestLockers(t, "replica-a", "replica-b")
+
+	lease, err := lockers[0].Acquire(ctx, "export:daily", time.Minute)
+	require.NoError(t, err)
+
+	_, err = lockers[1].Acquire(ctx, "export:daily", time.Minute)
+	assert.ErrorIs(t, err, ErrNotAcquired)
+
+	// Other names are independent
+	_, err = lockers[1].Acquire(ctx, "export:weekly", time.Minute)
+	require.NoError(t, err)
+
+	holders, err := lockers[1].Holders(ctx)
+	require.NoError(t, err)
+	require.Len(t, holders, 2)
+	assert.Equal(t, "export:daily", holders[0].Name)
+	assert.Equal(t, "replica-a", holders[0].Owner)
+	assert.Equal(t, lease.Token(), holders[0].Token)
+	assert.Equal(t, "replica-b", holders[1].Owner)
+
+	// Releasing lets the other owner in with a larger fencing token
+	require.NoError(t, lease.Release(ctx))
+	next, err := lockers[1].Acquire(ctx, "export:daily", time.Minute)
+	require.NoError(t, err)
+	assert.Greater(t, next.Token(), lease.Token())
+
+	// A release by the former holder does not free the new holder's lock
+	require.NoError(t, lease.Release(ctx))
+	_, err = lockers[0].Acquire(ctx, "export:daily", time.Minute)
+	assert.ErrorIs(t, err, ErrNotAcquired)
+}
+
+func TestRedisLocker_Expiry(t *testing.T) {
+	ctx := context.Background()
+	mr, lockers := newTestLockers(t, "replica-a", "replica-b")
+
+	lease, err := lockers[0].Acquire(ctx, "export:daily", 30*time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, 30*time.Second, mr.TTL(redisLockPrefix+"export:daily"))
+
+	mr.FastForward(31 * time.Second)
+
+	next, err := lockers[1].Acquire(ctx, "export:daily", 30*time.Second)
+	require.NoError(t, err)
+	assert.Greater(t, next.Token(), lease.Token())
+
+	// The expired holder finds out on its next renewal
+	assert.ErrorIs(t, lease.Renew(ctx), ErrLost)
+	assert.Equal(t, 30*time.Second, mr.TTL(redisLockPrefix+"export:daily"))
+}
+
+func TestRedisLocker_Renewal(t *testing.T) {
+	ctx := context.Background()
+	mr, lockers := newTestLockers(t, "replica-a", "replica-b")
+
+	lease, err := lockers[0].Acquire(ctx, "export:daily", 30*time.Second)
+	require.NoError(t, err)
+
+	// Renewing before expiry keeps the lock past its original TTL
+	for i := 0; i < 3; i++ {
+		mr.FastForward(20 * time.Second)
+		require.NoError(t, lease.Renew(ctx))
+		assert.Equal(t, 30*time.Second, mr.TTL(redisLockPrefix+"export:daily"))
+	}
+
+	_, err = lockers[1].Acquire(ctx, "export:daily", 30*time.Second)
+	assert.ErrorIs(t, err, ErrNotAcquired)
+}
+
+func TestRunner_OneReplicaRunsEachOccurrence(t *testing.T) {
+	_, lockers := newTestLockers(t, "replica-a", "replica-b", "replica-c")
+
+	var runs atomic.Int32
+	var wg sync.WaitGroup
+	for _, locker := range lockers {
+		runner := NewRunner(locker, time.Minute, zap.NewNop())
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := runner.Run(context.Background(), "export:daily:202503040230", func(ctx context.Context, token int64) error {
+				assert.Positive(t, token)
+				runs.Add(1)
+				return nil
+			})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), runs.Load())
+}
+
+func TestRunner_RenewsWhileRunning(t *testing.T) {
+	mr, lockers := newTestLockers(t, "replica-a")
+	runner := NewRunner(lockers[0], 150*time.Millisecond, zap.NewNop())
+
+	ran, err := runner.Run(context.Background(), "warmup", func(ctx context.Context, token int64) error {
+		// Several renewal intervals; miniredis expires keys on FastForward,
+		// so advance its clock by less than the TTL each time
+		for i := 0; i < 4; i++ {
+			time.Sleep(100 * time.Millisecond)
+			mr.FastForward(100 * time.Millisecond)
+			require.NoError(t, ctx.Err())
+			require.True(t, mr.Exists(redisLockPrefix+"warmup"))
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.True(t, ran)
+
+	// The lock is kept until its TTL so the occurrence is not run again
+	assert.True(t, mr.Exists(redisLockPrefix+"warmup"))
+}
+
+func TestRunner_CancelsTaskWhenLockIsLost(t *testing.T) {
+	mr, lockers := newTestLockers(t, "replica-a")
+	runner := NewRunner(lockers[0], 150*time.Millisecond, zap.NewNop())
+
+	ran, err := runner.Run(context.Background(), "warmup", func(ctx context.Context, token int64) error {
+		mr.Del(redisLockPrefix + "warmup")
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+			return nil
+		}
+	})
+	assert.True(t, ran)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestRunner_RedisUnavailableRunsEverywhere(t *testing.T) {
+	mr, lockers := newTestLockers(t, "replica-a", "replica-b")
+	mr.Close()
+
+	var runs atomic.Int32
+	for _, locker := range lockers {
+		ran, err := NewRunner(locker, time.Minute, zap.NewNop()).Run(context.Background(), "warmup",
+			func(ctx context.Context, token int64) error {
+				assert.Zero(t, token)
+				runs.Add(1)
+				return nil
+			})
+		require.NoError(t, err)
+		assert.True(t, ran)
+	}
+	assert.Equal(t, int32(2), runs.Load())
+
+	// Without a locker every task runs locally
+	ran, err := NewRunner(nil, time.Minute, zap.NewNop()).Run(context.Background(), "warmup",
+		func(ctx context.Context, token int64) error { return nil })
+	require.NoError(t, err)
+	assert.True(t, ran)
+}
@@ -0,0 +1,108 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Runner runs a task on the replica that acquires its lock
+type Runner struct {
+	locker *RedisLocker
+	ttl    time.Duration
+	logger *zap.Logger
+}
+
+// NewRunner creates a runner whose leases last ttl and are renewed every
+// third of it. A nil locker runs every task on every replica.
+func NewRunner(locker *RedisLocker, ttl time.Duration, logger *zap.Logger) *Runner {
+	if ttl <= 0 {
+		ttl = 30 * time.Second
+	}
+	if locker == nil {
+		logger.Warn("DISTRIBUTED LOCKS DISABLED: Redis is not configured, every replica runs every scheduled task")
+	}
+	return &Runner{locker: locker, ttl: ttl, logger: logger}
+}
+
+// Locker returns the runner's locker, nil when locks are disabled
+func (r *Runner) Locker() *RedisLocker {
+	if r == nil {
+		return nil
+	}
+	return r.locker
+}
+
+// Run calls fn if this replica acquires the named lock and reports whether
+// it did. fn receives the lease's fencing token and a context cancelled if
+// the lock is lost while it runs.
+//
+// The lock is not released when fn returns: it expires with its TTL, so a
+// replica whose clock lags behind cannot acquire it again for the same
+// occurrence. Names should therefore identify the occurrence, such as a job
+// name and its scheduled minute.
+//
+// When Redis is unavailable fn runs anyway with token 0, so a task runs on
+// every replica rather than on none.
+func (r *Runner) Run(ctx context.Context, name string, fn func(ctx context.Context, token int64) error) (bool, error) {
+	if r == nil || r.locker == nil {
+		return true, fn(ctx, 0)
+	}
+
+	lease, err := r.locker.Acquire(ctx, name, r.ttl)
+	if errors.Is(err, ErrNotAcquired) {
+		r.logger.Debug("Lock held by another replica, skipping task", zap.String("lock", name))
+		return false, nil
+	}
+	if err != nil {
+		r.logger.Warn("DISTRIBUTED LOCK UNAVAILABLE: Redis failed, running task on this replica without a lock; other replicas may run it too",
+			zap.String("lock", name), zap.Error(err))
+		return true, fn(ctx, 0)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan struct{})
+	renewed := make(chan struct{})
+	go func() {
+		defer close(renewed)
+		r.renew(ctx, lease, done, cancel)
+	}()
+
+	err = fn(ctx, lease.Token())
+	close(done)
+	<-renewed
+	return true, err
+}
+
+// renew keeps lease alive until done is closed, cancelling the task if the
+// lock is lost. Other renewal errors are retried on the next tick, as the
+// lease is still valid until its TTL passes.
+func (r *Runner) renew(ctx context.Context, lease *Lease, done <-chan struct{}, cancel context.CancelFunc) {
+	ticker := time.NewTicker(r.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := lease.Renew(ctx)
+			switch {
+			case err == nil:
+			case errors.Is(err, ErrLost):
+				r.logger.Error("Lock lost while task was running, cancelling it",
+					zap.String("lock", lease.Name()), zap.Int64("token", lease.Token()))
+				cancel()
+				return
+			default:
+				r.logger.Warn("Failed to renew lock", zap.String("lock", lease.Name()), zap.Error(err))
+			}
+		}
+	}
+}
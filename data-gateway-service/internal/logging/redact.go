@@ -0,0 +1,292 @@
+package logging
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Redacted replaces values that must not appear in logs
+const Redacted = "[REDACTED]"
+
+// Field keys whose values are SQL, and keys whose values are query parameters
+var (
+	sqlKeys     = map[string]bool{"sql": true, "query": true, "statement": true}
+	literalKeys = map[string]bool{"args": true, "params": true}
+)
+
+var (
+	credentialKey = regexp.MustCompile(`(?i)(authorization|password|passwd|secret|token|credential|api[_-]?key|cookie)`)
+
+	// Credential-looking values inside otherwise harmless strings
+	authScheme     = regexp.MustCompile(`(?i)\b(bearer|basic)\s+[^\s",;]+`)
+	credentialPair = regexp.MustCompile(`(?i)\b(password|passwd|pwd|access_token|token|secret|api[_-]?key)(["']?\s*[=:]\s*["']?)[^\s"'&,;]+`)
+	urlPassword    = regexp.MustCompile(`(://[^/\s:@]+:)[^/\s@]+@`)
+)
+
+// Redaction returns a logger option that scrubs credentials from every
+// field and, when redactSQL is set, replaces SQL literals with placeholders
+func Redaction(redactSQL bool) zap.Option {
+	return zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &redactingCore{Core: core, redactSQL: redactSQL}
+	})
+}
+
+// RedactSQL replaces string and numeric literals in sql with '?'. Comments,
+// keywords and identifiers are kept so the query shape stays readable.
+// Double-quoted text is an identifier in Dremio but a string in BigQuery;
+// it is kept only when part of a dotted path such as "space"."table".
+func RedactSQL(sql string) string {
+	var b strings.Builder
+	b.Grow(len(sql))
+
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case strings.HasPrefix(sql[i:], "--"):
+			end := indexFrom(sql, i, "\n")
+			b.WriteString(sql[i:end])
+			i = end
+		case strings.HasPrefix(sql[i:], "/*"):
+			end := indexFrom(sql, i+2, "*/")
+			if end < len(sql) {
+				end += 2
+			}
+			b.WriteString(sql[i:end])
+			i = end
+		case c == '`':
+			end := indexFrom(sql, i+1, "`")
+			if end < len(sql) {
+				end++
+			}
+			b.WriteString(sql[i:end])
+			i = end
+		case c == '\'' || c == '"':
+			end := quotedEnd(sql, i)
+			if c == '"' && dotted(sql, i, end) {
+				b.WriteString(sql[i:end])
+			} else {
+				b.WriteByte('?')
+			}
+			i = end
+		case isIdentStart(c):
+			end := i + 1
+			for end < len(sql) && isIdentPart(sql[end]) {
+				end++
+			}
+			b.WriteString(sql[i:end])
+			i = end
+		case isDigit(c) || (c == '.' && i+1 < len(sql) && isDigit(sql[i+1])):
+			i = numberEnd(sql, i)
+			b.WriteByte('?')
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String()
+}
+
+// ScrubCredentials masks bearer tokens, password=... style pairs and URL
+// passwords in s
+func ScrubCredentials(s string) string {
+	s = authScheme.ReplaceAllString(s, "$1 "+Redacted)
+	s = credentialPair.ReplaceAllString(s, "$1$2"+Redacted)
+	return urlPassword.ReplaceAllString(s, "${1}"+Redacted+"@")
+}
+
+// isCredentialKey reports whether a field or header name holds a secret.
+// IDs such as api_key_id identify a credential without revealing it.
+func isCredentialKey(key string) bool {
+	k := strings.ToLower(key)
+	if strings.HasSuffix(k, "_id") || strings.HasSuffix(k, "-id") {
+		return false
+	}
+	return credentialKey.MatchString(k)
+}
+
+// indexFrom returns the index of substr in s at or after from, or len(s)
+func indexFrom(s string, from int, substr string) int {
+	if idx := strings.Index(s[from:], substr); idx >= 0 {
+		return from + idx
+	}
+	return len(s)
+}
+
+// quotedEnd returns the index just past the literal starting at start.
+// BigQuery treats backslash as an escape while Dremio does not; when the
+// two readings end the literal in different places the rest of s is
+// treated as literal, as either reading could leak part of a value.
+// An unterminated literal also runs to the end of s.
+func quotedEnd(s string, start int) int {
+	q := s[start]
+	if triple := strings.Repeat(string(q), 3); strings.HasPrefix(s[start:], triple) {
+		end := indexFrom(s, start+3, triple)
+		return min(end+3, len(s))
+	}
+
+	escaped, standard := closingQuote(s, start, true), closingQuote(s, start, false)
+	if escaped != standard {
+		return len(s)
+	}
+	return escaped
+}
+
+// closingQuote returns the index just past the closing quote, or len(s)
+func closingQuote(s string, start int, backslashEscapes bool) int {
+	q := s[start]
+	for i := start + 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if backslashEscapes {
+				i++
+			}
+		case q:
+			if i+1 < len(s) && s[i+1] == q {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// dotted reports whether the quoted text s[start:end] is part of a dotted path
+func dotted(s string, start, end int) bool {
+	return (start > 0 && s[start-1] == '.') || (end < len(s) && s[end] == '.')
+}
+
+// numberEnd returns the index just past the numeric literal starting at
+// start, including decimals, exponents and hex digits
+func numberEnd(s string, start int) int {
+	i := start
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case isDigit(c) || c == '.' || isLetter(c):
+			i++
+		case (c == '+' || c == '-') && (s[i-1] == 'e' || s[i-1] == 'E'):
+			i++
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+func isDigit(c byte) bool  { return c >= '0' && c <= '9' }
+func isLetter(c byte) bool { return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') }
+
+func isIdentStart(c byte) bool { return isLetter(c) || c == '_' || c == '@' || c == '$' || c >= 0x80 }
+func isIdentPart(c byte) bool  { return isIdentStart(c) || isDigit(c) }
+
+// redactingCore scrubs fields before they reach the wrapped core
+type redactingCore struct {
+	zapcore.Core
+	redactSQL bool
+}
+
+func (c *redactingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactingCore{Core: c.Core.With(c.redact(fields)), redactSQL: c.redactSQL}
+}
+
+// Check defers to the wrapped core so its level and sampling decisions
+// still apply, then routes the write through this core
+func (c *redactingCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Core.Check(ent, nil) == nil {
+		return ce
+	}
+	return ce.AddCore(ent, c)
+}
+
+func (c *redactingCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(ent, c.redact(fields))
+}
+
+// redact returns fields with sensitive values replaced, copying only when
+// something changes
+func (c *redactingCore) redact(fields []zapcore.Field) []zapcore.Field {
+	var out []zapcore.Field
+	for i, f := range fields {
+		redacted, changed := c.redactField(f)
+		if changed && out == nil {
+			out = make([]zapcore.Field, i, len(fields))
+			copy(out, fields[:i])
+		}
+		if out != nil {
+			out = append(out, redacted)
+		}
+	}
+	if out == nil {
+		return fields
+	}
+	return out
+}
+
+func (c *redactingCore) redactField(f zapcore.Field) (zapcore.Field, bool) {
+	switch {
+	case isCredentialKey(f.Key):
+		return zap.String(f.Key, Redacted), true
+	case c.redactSQL && sqlKeys[f.Key] && f.Type == zapcore.StringType:
+		return zap.String(f.Key, RedactSQL(f.String)), true
+	case c.redactSQL && literalKeys[f.Key]:
+		return zap.String(f.Key, Redacted), true
+	}
+
+	switch f.Type {
+	case zapcore.StringType:
+		if s := ScrubCredentials(f.String); s != f.String {
+			return zap.String(f.Key, s), true
+		}
+	case zapcore.ErrorType:
+		if err, ok := f.Interface.(error); ok && err != nil {
+			if msg := err.Error(); ScrubCredentials(msg) != msg {
+				return zap.String(f.Key, ScrubCredentials(msg)), true
+			}
+		}
+	case zapcore.ReflectType:
+		switch v := f.Interface.(type) {
+		case http.Header:
+			return zap.Any(f.Key, scrubHeader(v)), true
+		case map[string][]string:
+			return zap.Any(f.Key, scrubHeader(v)), true
+		case map[string]string:
+			return zap.Any(f.Key, scrubMap(v)), true
+		}
+	}
+	return f, false
+}
+
+// scrubHeader copies h with credential headers such as Authorization and
+// X-API-Key redacted
+func scrubHeader(h map[string][]string) map[string][]string {
+	out := make(map[string][]string, len(h))
+	for k, values := range h {
+		if isCredentialKey(k) {
+			out[k] = []string{Redacted}
+			continue
+		}
+		scrubbed := make([]string, len(values))
+		for i, v := range values {
+			scrubbed[i] = ScrubCredentials(v)
+		}
+		out[k] = scrubbed
+	}
+	return out
+}
+
+func scrubMap(m map[string]string) map[string]string {
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		if isCredentialKey(k) {
+			out[k] = Redacted
+			continue
+		}
+		out[k] = ScrubCredentials(v)
+	}
+	return out
+}
@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// BigQueryUsageCounter accumulates the bytes and slot time of the BigQuery
+// jobs the gateway ran, by API key, so spend can be reconciled with traffic.
+// Keys are few and long-lived, which keeps the number of series bounded.
+type BigQueryUsageCounter struct {
+	mu    sync.Mutex
+	usage map[string]*BigQueryUsage
+}
+
+// BigQueryUsage is the BigQuery work done for one API key
+type BigQueryUsage struct {
+	Jobs           int64 `json:"jobs"`
+	CachedJobs     int64 `json:"cached_jobs"` // Answered from BigQuery's own result cache
+	BytesProcessed int64 `json:"bytes_processed"`
+	BytesBilled    int64 `json:"bytes_billed"`
+	SlotMillis     int64 `json:"slot_ms"`
+}
+
+// NewBigQueryUsageCounter creates an empty counter
+func NewBigQueryUsageCounter() *BigQueryUsageCounter {
+	return &BigQueryUsageCounter{usage: make(map[string]*BigQueryUsage)}
+}
+
+// Record adds one job run for apiKeyID, "" when it ran for no key. A nil
+// counter records nothing.
+func (c *BigQueryUsageCounter) Record(apiKeyID string, bytesProcessed, bytesBilled, slotMillis int64, cacheHit bool) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	u, ok := c.usage[apiKeyID]
+	if !ok {
+		u = &BigQueryUsage{}
+		c.usage[apiKeyID] = u
+	}
+	u.Jobs++
+	if cacheHit {
+		u.CachedJobs++
+	}
+	u.BytesProcessed += bytesProcessed
+	u.BytesBilled += bytesBilled
+	u.SlotMillis += slotMillis
+}
+
+// Usage returns the work done for apiKeyID so far
+func (c *BigQueryUsageCounter) Usage(apiKeyID string) BigQueryUsage {
+	if c == nil {
+		return BigQueryUsage{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if u, ok := c.usage[apiKeyID]; ok {
+		return *u
+	}
+	return BigQueryUsage{}
+}
+
+// WritePrometheus writes the counters in the Prometheus text format
+func (c *BigQueryUsageCounter) WritePrometheus(w io.Writer) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	keys := make([]string, 0, len(c.usage))
+	usage := make(map[string]BigQueryUsage, len(c.usage))
+	for key, u := range c.usage {
+		keys = append(keys, key)
+		usage[key] = *u
+	}
+	c.mu.Unlock()
+	sort.Strings(keys)
+
+	for _, series := range []struct {
+		name, help string
+		value      func(BigQueryUsage) int64
+	}{
+		{"go_gateway_bigquery_jobs_total", "BigQuery query jobs run by API key", func(u BigQueryUsage) int64 { return u.Jobs }},
+		{"go_gateway_bigquery_cached_jobs_total", "BigQuery query jobs answered from BigQuery's result cache by API key", func(u BigQueryUsage) int64 { return u.CachedJobs }},
+		{"go_gateway_bigquery_bytes_processed_total", "Bytes processed by BigQuery query jobs by API key", func(u BigQueryUsage) int64 { return u.BytesProcessed }},
+		{"go_gateway_bigquery_bytes_billed_total", "Bytes billed for BigQuery query jobs by API key", func(u BigQueryUsage) int64 { return u.BytesBilled }},
+		{"go_gateway_bigquery_slot_ms_total", "Slot milliseconds used by BigQuery query jobs by API key", func(u BigQueryUsage) int64 { return u.SlotMillis }},
+	} {
+		fmt.Fprintf(w, "# HELP %s %s\n", series.name, series.help)
+		fmt.Fprintf(w, "# TYPE %s counter\n", series.name)
+		for _, key := range keys {
+			fmt.Fprintf(w, "%s{api_key_id=%s} %d\n", series.name, strconv.Quote(key), series.value(usage[key]))
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBigQueryUsageCounter_ByAPIKey(t *testing.T) {
+	c := NewBigQueryUsageCounter()
+	c.Record("finance", 1<<20, 10<<20, 1500, false)
+	c.Record("finance", 1<<20, 0, 0, true)
+	c.Record("reporting", 2048, 10<<20, 20, false)
+
+	assert.Equal(t, BigQueryUsage{Jobs: 2, CachedJobs: 1, BytesProcessed: 2 << 20, BytesBilled: 10 << 20, SlotMillis: 1500}, c.Usage("finance"))
+	assert.Equal(t, BigQueryUsage{}, c.Usage("unknown"))
+
+	var buf bytes.Buffer
+	c.WritePrometheus(&buf)
+	out := buf.String()
+	assert.Contains(t, out, "# TYPE go_gateway_bigquery_bytes_billed_total counter")
+	assert.Contains(t, out, `go_gateway_bigquery_bytes_billed_total{api_key_id="finance"} 10485760`)
+	assert.Contains(t, out, `go_gateway_bigquery_cached_jobs_total{api_key_id="finance"} 1`)
+	assert.Contains(t, out, `go_gateway_bigquery_slot_ms_total{api_key_id="reporting"} 20`)
+
+	var none *BigQueryUsageCounter
+	none.Record("a", 1, 1, 1, false)
+	buf.Reset()
+	none.WritePrometheus(&buf)
+	assert.Empty(t, buf.String())
+}
@@ -0,0 +1,24 @@
+package metrics
+
+import "io"
+
+// CacheWriteCounter counts fresh results the result cache failed to store,
+// by source. Until the entry is replaced, other replicas may serve an older
+// result of the same query.
+type CacheWriteCounter labeledCounter
+
+// NewCacheWriteCounter creates an empty counter
+func NewCacheWriteCounter() *CacheWriteCounter {
+	return (*CacheWriteCounter)(newLabeledCounter("go_gateway_cache_write_failures_total",
+		"Fresh query results the result cache failed to store", "source"))
+}
+
+// Record counts one failed write of source. A nil counter records nothing.
+func (c *CacheWriteCounter) Record(source string) {
+	(*labeledCounter)(c).inc(source)
+}
+
+// WritePrometheus writes the counter in the Prometheus text format
+func (c *CacheWriteCounter) WritePrometheus(w io.Writer) {
+	(*labeledCounter)(c).writePrometheus(w)
+}
@@ -0,0 +1,29 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheWriteCounter_BySource(t *testing.T) {
+	c := NewCacheWriteCounter()
+	c.Record("DATAWAREHOUSE")
+	c.Record("DATAWAREHOUSE")
+	c.Record("BIGQUERY")
+
+	var buf bytes.Buffer
+	c.WritePrometheus(&buf)
+	out := buf.String()
+
+	assert.Contains(t, out, "# TYPE go_gateway_cache_write_failures_total counter")
+	assert.Contains(t, out, `go_gateway_cache_write_failures_total{source="BIGQUERY"} 1`)
+	assert.Contains(t, out, `go_gateway_cache_write_failures_total{source="DATAWAREHOUSE"} 2`)
+
+	var none *CacheWriteCounter
+	none.Record("a")
+	buf.Reset()
+	none.WritePrometheus(&buf)
+	assert.Empty(t, buf.String())
+}
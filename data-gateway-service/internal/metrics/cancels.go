@@ -0,0 +1,23 @@
+package metrics
+
+import "io"
+
+// CancelCounter counts upstream jobs the gateway cancelled because the
+// request that started them ended first, by data source type
+type CancelCounter labeledCounter
+
+// NewCancelCounter creates an empty counter
+func NewCancelCounter() *CancelCounter {
+	return (*CancelCounter)(newLabeledCounter("go_gateway_upstream_jobs_cancelled_total",
+		"Upstream jobs cancelled after their request ended", "source"))
+}
+
+// Record counts one cancelled job of source. A nil counter records nothing.
+func (c *CancelCounter) Record(source string) {
+	(*labeledCounter)(c).inc(source)
+}
+
+// WritePrometheus writes the counter in the Prometheus text format
+func (c *CancelCounter) WritePrometheus(w io.Writer) {
+	(*labeledCounter)(c).writePrometheus(w)
+}
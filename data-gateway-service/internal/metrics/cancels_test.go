@@ -0,0 +1,29 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCancelCounter_BySource(t *testing.T) {
+	c := NewCancelCounter()
+	c.Record("bigquery")
+	c.Record("bigquery")
+	c.Record("dremio")
+
+	var buf bytes.Buffer
+	c.WritePrometheus(&buf)
+	out := buf.String()
+
+	assert.Contains(t, out, "# TYPE go_gateway_upstream_jobs_cancelled_total counter")
+	assert.Contains(t, out, `go_gateway_upstream_jobs_cancelled_total{source="bigquery"} 2`)
+	assert.Contains(t, out, `go_gateway_upstream_jobs_cancelled_total{source="dremio"} 1`)
+
+	var none *CancelCounter
+	none.Record("dremio")
+	buf.Reset()
+	none.WritePrometheus(&buf)
+	assert.Empty(t, buf.String())
+}
@@ -0,0 +1,27 @@
+package metrics
+
+import (
+	"io"
+	"strconv"
+)
+
+// EndpointCounter counts requests to monitoring endpoints by path and
+// response status, so that scrapes and rejected callers can be told apart
+type EndpointCounter labeledCounter
+
+// NewEndpointCounter creates an empty counter
+func NewEndpointCounter() *EndpointCounter {
+	return (*EndpointCounter)(newLabeledCounter("go_gateway_endpoint_requests_total",
+		"Requests to monitoring endpoints by path and status", "path", "code"))
+}
+
+// Record counts one request to path answered with code. A nil counter
+// records nothing.
+func (c *EndpointCounter) Record(path string, code int) {
+	(*labeledCounter)(c).inc(path, strconv.Itoa(code))
+}
+
+// WritePrometheus writes the counter in the Prometheus text format
+func (c *EndpointCounter) WritePrometheus(w io.Writer) {
+	(*labeledCounter)(c).writePrometheus(w)
+}
@@ -0,0 +1,34 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEndpointCounter_ByPathAndStatus(t *testing.T) {
+	c := NewEndpointCounter()
+	c.Record("/cache/stats", 200)
+	c.Record("/cache/stats", 200)
+	c.Record("/cache/stats", 403)
+	c.Record("/cache/stats/summary", 200)
+
+	var buf bytes.Buffer
+	c.WritePrometheus(&buf)
+	out := buf.String()
+
+	assert.Contains(t, out, "# TYPE go_gateway_endpoint_requests_total counter")
+	assert.Contains(t, out, `go_gateway_endpoint_requests_total{path="/cache/stats",code="200"} 2`)
+	assert.Contains(t, out, `go_gateway_endpoint_requests_total{path="/cache/stats",code="403"} 1`)
+	assert.Contains(t, out, `go_gateway_endpoint_requests_total{path="/cache/stats/summary",code="200"} 1`)
+}
+
+func TestEndpointCounter_Nil(t *testing.T) {
+	var c *EndpointCounter
+	c.Record("/cache/stats", 200)
+
+	var buf bytes.Buffer
+	c.WritePrometheus(&buf)
+	assert.Empty(t, buf.String())
+}
@@ -0,0 +1,29 @@
+package metrics
+
+import "io"
+
+// Outcomes of a query retried over a fallback transport
+const (
+	FallbackSuccess = "success" // The fallback transport answered
+	FallbackError   = "error"   // It failed too, or could not be reached
+)
+
+// FallbackCounter counts Dremio queries that were retried over the REST API
+// after Arrow Flight could not be reached, by source and outcome
+type FallbackCounter labeledCounter
+
+// NewFallbackCounter creates an empty counter
+func NewFallbackCounter() *FallbackCounter {
+	return (*FallbackCounter)(newLabeledCounter("go_gateway_dremio_rest_fallbacks_total",
+		"Dremio queries retried over REST after Arrow Flight failed, by outcome", "source", "outcome"))
+}
+
+// Record counts one fallback of source. A nil counter records nothing.
+func (c *FallbackCounter) Record(source, outcome string) {
+	(*labeledCounter)(c).inc(source, outcome)
+}
+
+// WritePrometheus writes the counter in the Prometheus text format
+func (c *FallbackCounter) WritePrometheus(w io.Writer) {
+	(*labeledCounter)(c).writePrometheus(w)
+}
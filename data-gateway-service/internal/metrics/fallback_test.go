@@ -0,0 +1,29 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFallbackCounter_BySourceAndOutcome(t *testing.T) {
+	c := NewFallbackCounter()
+	c.Record("DATAWAREHOUSE", FallbackSuccess)
+	c.Record("DATAWAREHOUSE", FallbackSuccess)
+	c.Record("DATAWAREHOUSE", FallbackError)
+
+	var buf bytes.Buffer
+	c.WritePrometheus(&buf)
+	out := buf.String()
+
+	assert.Contains(t, out, "# TYPE go_gateway_dremio_rest_fallbacks_total counter")
+	assert.Contains(t, out, `go_gateway_dremio_rest_fallbacks_total{source="DATAWAREHOUSE",outcome="success"} 2`)
+	assert.Contains(t, out, `go_gateway_dremio_rest_fallbacks_total{source="DATAWAREHOUSE",outcome="error"} 1`)
+
+	var none *FallbackCounter
+	none.Record("a", FallbackError)
+	buf.Reset()
+	none.WritePrometheus(&buf)
+	assert.Empty(t, buf.String())
+}
@@ -0,0 +1,154 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// Latency buckets grow by 2^(1/4) from 1ms, so a quantile read from a bucket
+// is within 19% of the true value. Latencies above the last bound, about 15
+// minutes, are counted in an overflow bucket.
+const (
+	bucketsPerDoubling = 4
+	latencyBuckets     = 80
+)
+
+// latencyBounds are the inclusive upper bounds of the buckets
+var latencyBounds = func() [latencyBuckets]time.Duration {
+	var bounds [latencyBuckets]time.Duration
+	for i := range bounds {
+		bounds[i] = time.Duration(float64(time.Millisecond) * math.Exp2(float64(i)/bucketsPerDoubling))
+	}
+	return bounds
+}()
+
+// Histogram counts latencies in fixed buckets. Observations are atomic adds
+// and its memory is fixed, so it can sit on every query path. A nil
+// histogram records nothing and reports no observations.
+type Histogram struct {
+	buckets [latencyBuckets + 1]atomic.Int64 // The last counts overflows
+	sum     atomic.Int64                     // Nanoseconds
+}
+
+// NewHistogram creates an empty histogram
+func NewHistogram() *Histogram {
+	return &Histogram{}
+}
+
+// Observe records one latency
+func (h *Histogram) Observe(d time.Duration) {
+	if h == nil {
+		return
+	}
+	i := sort.Search(latencyBuckets, func(i int) bool { return d <= latencyBounds[i] })
+	h.buckets[i].Add(1)
+	h.sum.Add(int64(d))
+}
+
+// Reset drops every observation, e.g. to take a baseline after a deploy
+func (h *Histogram) Reset() {
+	if h == nil {
+		return
+	}
+	for i := range h.buckets {
+		h.buckets[i].Store(0)
+	}
+	h.sum.Store(0)
+}
+
+// LatencySummary reports the quantiles of a histogram in milliseconds
+type LatencySummary struct {
+	Count int64   `json:"count"`
+	P50   float64 `json:"p50_ms"`
+	P95   float64 `json:"p95_ms"`
+	P99   float64 `json:"p99_ms"`
+}
+
+// Summary returns the count and the 50th, 95th and 99th percentiles
+func (h *Histogram) Summary() LatencySummary {
+	counts := h.snapshot()
+	ms := func(q float64) float64 {
+		return float64(quantile(counts, q)) / float64(time.Millisecond)
+	}
+	return LatencySummary{Count: total(counts), P50: ms(0.50), P95: ms(0.95), P99: ms(0.99)}
+}
+
+// Quantile returns the latency below which a fraction q of the observations
+// fall, interpolated within its bucket; 0 without observations
+func (h *Histogram) Quantile(q float64) time.Duration {
+	return quantile(h.snapshot(), q)
+}
+
+func (h *Histogram) snapshot() []int64 {
+	counts := make([]int64, latencyBuckets+1)
+	if h == nil {
+		return counts
+	}
+	for i := range h.buckets {
+		counts[i] = h.buckets[i].Load()
+	}
+	return counts
+}
+
+func total(counts []int64) int64 {
+	var n int64
+	for _, c := range counts {
+		n += c
+	}
+	return n
+}
+
+// quantile finds the bucket holding the q-th observation and interpolates
+// linearly between its bounds. Overflows report the last bound.
+func quantile(counts []int64, q float64) time.Duration {
+	n := total(counts)
+	if n == 0 {
+		return 0
+	}
+	rank := q * float64(n)
+	var seen int64
+	for i, c := range counts {
+		if c == 0 || float64(seen+c) < rank {
+			seen += c
+			continue
+		}
+		if i == latencyBuckets {
+			return latencyBounds[latencyBuckets-1]
+		}
+		lower := time.Duration(0)
+		if i > 0 {
+			lower = latencyBounds[i-1]
+		}
+		within := (rank - float64(seen)) / float64(c)
+		return lower + time.Duration(within*float64(latencyBounds[i]-lower))
+	}
+	return latencyBounds[latencyBuckets-1]
+}
+
+// writePrometheus writes the histogram as the series of name with labels,
+// e.g. `data_source="DW"`. Only every fourth bound, the powers of two of a
+// millisecond, becomes a Prometheus bucket, which keeps scrapes small; the
+// buckets are cumulative, so the coarser ones stay exact.
+func (h *Histogram) writePrometheus(w io.Writer, name, labels string) {
+	counts := h.snapshot()
+	prefix := labels
+	if prefix != "" {
+		prefix += ","
+	}
+
+	var cumulative int64
+	for i := 0; i < latencyBuckets; i++ {
+		cumulative += counts[i]
+		if i%bucketsPerDoubling == 0 {
+			fmt.Fprintf(w, "%s_bucket{%sle=\"%g\"} %d\n", name, prefix, latencyBounds[i].Seconds(), cumulative)
+		}
+	}
+	n := cumulative + counts[latencyBuckets]
+	fmt.Fprintf(w, "%s_bucket{%sle=\"+Inf\"} %d\n", name, prefix, n)
+	fmt.Fprintf(w, "%s_sum{%s} %g\n", name, labels, time.Duration(h.sum.Load()).Seconds())
+	fmt.Fprintf(w, "%s_count{%s} %d\n", name, labels, n)
+}
@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// bucketTolerance is the relative error of a quantile read from a bucket
+const bucketTolerance = 0.19
+
+func assertQuantile(t *testing.T, h *Histogram, q float64, want time.Duration) {
+	t.Helper()
+	got := h.Quantile(q)
+	assert.InEpsilon(t, float64(want), float64(got), bucketTolerance, "p%.0f: got %v, want %v", q*100, got, want)
+}
+
+func TestHistogram_QuantilesWithinBucketTolerance(t *testing.T) {
+	h := NewHistogram()
+	for ms := 1; ms <= 1000; ms++ {
+		h.Observe(time.Duration(ms) * time.Millisecond)
+	}
+
+	assertQuantile(t, h, 0.50, 500*time.Millisecond)
+	assertQuantile(t, h, 0.95, 950*time.Millisecond)
+	assertQuantile(t, h, 0.99, 990*time.Millisecond)
+
+	summary := h.Summary()
+	assert.Equal(t, int64(1000), summary.Count)
+	assert.InEpsilon(t, 500, summary.P50, bucketTolerance)
+	assert.InEpsilon(t, 950, summary.P95, bucketTolerance)
+	assert.InEpsilon(t, 990, summary.P99, bucketTolerance)
+}
+
+func TestHistogram_TailIsNotAveragedAway(t *testing.T) {
+	h := NewHistogram()
+	for i := 0; i < 98; i++ {
+		h.Observe(10 * time.Millisecond)
+	}
+	h.Observe(2 * time.Second)
+	h.Observe(2 * time.Second)
+
+	assertQuantile(t, h, 0.50, 10*time.Millisecond)
+	assertQuantile(t, h, 0.99, 2*time.Second)
+}
+
+func TestHistogram_OverflowAndEmpty(t *testing.T) {
+	h := NewHistogram()
+	assert.Equal(t, time.Duration(0), h.Quantile(0.99))
+	assert.Equal(t, LatencySummary{}, h.Summary())
+
+	h.Observe(time.Hour)
+	assert.Equal(t, latencyBounds[latencyBuckets-1], h.Quantile(0.99), "overflows report the last bound")
+	assert.Equal(t, int64(1), h.Summary().Count)
+
+	h.Reset()
+	assert.Equal(t, LatencySummary{}, h.Summary())
+
+	var none *Histogram
+	none.Observe(time.Second)
+	none.Reset()
+	assert.Equal(t, LatencySummary{}, none.Summary())
+}
+
+func TestHistogram_ConcurrentObserve(t *testing.T) {
+	h := NewHistogram()
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				h.Observe(20 * time.Millisecond)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(8000), h.Summary().Count)
+	assertQuantile(t, h, 0.50, 20*time.Millisecond)
+}
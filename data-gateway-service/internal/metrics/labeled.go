@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// labeledCounter is a Prometheus counter with a series per combination of
+// its label values. The counters of this package are labeledCounters named
+// for what they count. A nil counter records and writes nothing.
+type labeledCounter struct {
+	name, help string
+	labels     []string
+
+	mu     sync.Mutex
+	counts map[string]int64 // By label values, joined with labelSeparator
+}
+
+// labelSeparator joins label values into a key of counts
+const labelSeparator = "\x00"
+
+// newLabeledCounter creates an empty counter of the metric name, whose
+// series are told apart by labels
+func newLabeledCounter(name, help string, labels ...string) *labeledCounter {
+	return &labeledCounter{name: name, help: help, labels: labels, counts: make(map[string]int64)}
+}
+
+// inc counts one event of the series with values, one per label
+func (c *labeledCounter) inc(values ...string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.counts[strings.Join(values, labelSeparator)]++
+	c.mu.Unlock()
+}
+
+// count returns the events counted in the series with values
+func (c *labeledCounter) count(values ...string) int64 {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.counts[strings.Join(values, labelSeparator)]
+}
+
+// writePrometheus writes the counter in the Prometheus text format, its
+// series sorted
+func (c *labeledCounter) writePrometheus(w io.Writer) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	lines := make([]string, 0, len(c.counts))
+	for key, count := range c.counts {
+		values := strings.Split(key, labelSeparator)
+		pairs := make([]string, len(c.labels))
+		for i, label := range c.labels {
+			pairs[i] = label + "=" + strconv.Quote(values[i])
+		}
+		lines = append(lines, fmt.Sprintf("%s{%s} %d", c.name, strings.Join(pairs, ","), count))
+	}
+	c.mu.Unlock()
+	sort.Strings(lines)
+
+	fmt.Fprintf(w, "# HELP %s %s\n", c.name, c.help)
+	fmt.Fprintf(w, "# TYPE %s counter\n", c.name)
+	for _, line := range lines {
+		fmt.Fprintln(w, line)
+	}
+}
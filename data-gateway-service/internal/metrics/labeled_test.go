@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLabeledCounter_SortedSeries(t *testing.T) {
+	c := newLabeledCounter("go_gateway_test_total", "Events counted by a test", "source", "outcome")
+	c.inc("b", "ok")
+	c.inc("a", "error")
+	c.inc("b", "ok")
+	c.inc("a", `quoted "value"`)
+
+	var buf bytes.Buffer
+	c.writePrometheus(&buf)
+	assert.Equal(t, "# HELP go_gateway_test_total Events counted by a test\n"+
+		"# TYPE go_gateway_test_total counter\n"+
+		`go_gateway_test_total{source="a",outcome="error"} 1`+"\n"+
+		`go_gateway_test_total{source="a",outcome="quoted \"value\""} 1`+"\n"+
+		`go_gateway_test_total{source="b",outcome="ok"} 2`+"\n", buf.String())
+
+	assert.Equal(t, int64(2), c.count("b", "ok"))
+	assert.Zero(t, c.count("b", "error"))
+}
+
+func TestLabeledCounter_Nil(t *testing.T) {
+	var c *labeledCounter
+	c.inc("a")
+	assert.Zero(t, c.count("a"))
+
+	var buf bytes.Buffer
+	c.writePrometheus(&buf)
+	assert.Empty(t, buf.String())
+}
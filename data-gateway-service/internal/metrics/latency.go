@@ -0,0 +1,137 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// BackgroundEndpoint is the endpoint of queries run outside an API request,
+// such as scheduled exports
+const BackgroundEndpoint = "background"
+
+type endpointKey struct{}
+
+// WithEndpoint attributes the queries run with ctx to the endpoint returned
+// by endpoint. It is read when a query finishes, so routers may resolve the
+// route after the context was created.
+func WithEndpoint(ctx context.Context, endpoint func() string) context.Context {
+	return context.WithValue(ctx, endpointKey{}, endpoint)
+}
+
+// EndpointFromContext returns the endpoint of ctx, or BackgroundEndpoint
+func EndpointFromContext(ctx context.Context) string {
+	if endpoint, ok := ctx.Value(endpointKey{}).(func() string); ok {
+		if e := endpoint(); e != "" {
+			return e
+		}
+	}
+	return BackgroundEndpoint
+}
+
+// QueryLatencies keeps a latency histogram of data source queries per source
+// and endpoint. Endpoints are route patterns, so the number of series is
+// bounded by the routes times the sources.
+type QueryLatencies struct {
+	mu     sync.RWMutex
+	series map[latencySeries]*Histogram
+}
+
+type latencySeries struct {
+	source   string
+	endpoint string
+}
+
+// NewQueryLatencies creates an empty set of histograms
+func NewQueryLatencies() *QueryLatencies {
+	return &QueryLatencies{series: make(map[latencySeries]*Histogram)}
+}
+
+// Observe records a query of source taking d, attributed to the endpoint of
+// ctx. A nil QueryLatencies records nothing.
+func (l *QueryLatencies) Observe(ctx context.Context, source string, d time.Duration) {
+	if l == nil {
+		return
+	}
+	key := latencySeries{source, EndpointFromContext(ctx)}
+
+	l.mu.RLock()
+	h, ok := l.series[key]
+	l.mu.RUnlock()
+	if !ok {
+		l.mu.Lock()
+		if h, ok = l.series[key]; !ok {
+			h = NewHistogram()
+			l.series[key] = h
+		}
+		l.mu.Unlock()
+	}
+	h.Observe(d)
+}
+
+// Summaries returns the quantiles by source and endpoint
+func (l *QueryLatencies) Summaries() map[string]map[string]LatencySummary {
+	summaries := make(map[string]map[string]LatencySummary)
+	if l == nil {
+		return summaries
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for key, h := range l.series {
+		if summaries[key.source] == nil {
+			summaries[key.source] = make(map[string]LatencySummary)
+		}
+		summaries[key.source][key.endpoint] = h.Summary()
+	}
+	return summaries
+}
+
+// Reset drops every observation. Series are kept, so scrapers see their
+// counters restart from zero rather than disappear.
+func (l *QueryLatencies) Reset() {
+	if l == nil {
+		return
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for _, h := range l.series {
+		h.Reset()
+	}
+}
+
+// WritePrometheus writes the histograms in the Prometheus text format
+func (l *QueryLatencies) WritePrometheus(w io.Writer) {
+	if l == nil {
+		return
+	}
+
+	l.mu.RLock()
+	keys := make([]latencySeries, 0, len(l.series))
+	for key := range l.series {
+		keys = append(keys, key)
+	}
+	l.mu.RUnlock()
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].source != keys[j].source {
+			return keys[i].source < keys[j].source
+		}
+		return keys[i].endpoint < keys[j].endpoint
+	})
+
+	const name = "go_gateway_query_duration_seconds"
+	fmt.Fprintf(w, "# HELP %s Data source query latency by data source and endpoint\n", name)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", name)
+	for _, key := range keys {
+		l.mu.RLock()
+		h := l.series[key]
+		l.mu.RUnlock()
+		labels := fmt.Sprintf("data_source=%s,endpoint=%s", strconv.Quote(key.source), strconv.Quote(key.endpoint))
+		h.writePrometheus(w, name, labels)
+	}
+}
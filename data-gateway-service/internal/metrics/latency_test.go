@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryLatencies_BySourceAndEndpoint(t *testing.T) {
+	l := NewQueryLatencies()
+	tender := WithEndpoint(context.Background(), func() string { return "/api/v1/tender" })
+	for i := 0; i < 10; i++ {
+		l.Observe(tender, "DATAWAREHOUSE", 100*time.Millisecond)
+	}
+	l.Observe(context.Background(), "DATAWAREHOUSE", 3*time.Second)
+
+	summaries := l.Summaries()
+	assert.Equal(t, int64(10), summaries["DATAWAREHOUSE"]["/api/v1/tender"].Count)
+	assert.InEpsilon(t, 100, summaries["DATAWAREHOUSE"]["/api/v1/tender"].P99, bucketTolerance)
+	assert.InEpsilon(t, 3000, summaries["DATAWAREHOUSE"][BackgroundEndpoint].P50, bucketTolerance)
+
+	var buf bytes.Buffer
+	l.WritePrometheus(&buf)
+	out := buf.String()
+	assert.Contains(t, out, "# TYPE go_gateway_query_duration_seconds histogram")
+	assert.Contains(t, out, `go_gateway_query_duration_seconds_bucket{data_source="DATAWAREHOUSE",endpoint="/api/v1/tender",le="0.064"} 0`)
+	assert.Contains(t, out, `go_gateway_query_duration_seconds_bucket{data_source="DATAWAREHOUSE",endpoint="/api/v1/tender",le="0.128"} 10`)
+	assert.Contains(t, out, `go_gateway_query_duration_seconds_bucket{data_source="DATAWAREHOUSE",endpoint="/api/v1/tender",le="+Inf"} 10`)
+	assert.Contains(t, out, `go_gateway_query_duration_seconds_sum{data_source="DATAWAREHOUSE",endpoint="/api/v1/tender"} 1`)
+	assert.Contains(t, out, `go_gateway_query_duration_seconds_count{data_source="DATAWAREHOUSE",endpoint="background"} 1`)
+
+	l.Reset()
+	assert.Equal(t, int64(0), l.Summaries()["DATAWAREHOUSE"]["/api/v1/tender"].Count, "series survive a reset")
+}
+
+func TestQueryLatencies_Nil(t *testing.T) {
+	var l *QueryLatencies
+	l.Observe(context.Background(), "DATAWAREHOUSE", time.Second)
+	l.Reset()
+	assert.Empty(t, l.Summaries())
+
+	var buf bytes.Buffer
+	l.WritePrometheus(&buf)
+	assert.Empty(t, buf.String())
+}
@@ -0,0 +1,35 @@
+package metrics
+
+import "io"
+
+// Outcomes of a lookup by id that found nothing
+const (
+	NotFoundHit         = "hit"         // Answered from a cached not-found marker, without the source
+	NotFoundStored      = "stored"      // The source found nothing and a marker was cached
+	NotFoundInvalidated = "invalidated" // The markers of the table were dropped
+)
+
+// NotFoundCounter counts the cached not-found lookups, by table and
+// outcome, so probes of missing ids can be told apart from cached rows
+type NotFoundCounter labeledCounter
+
+// NewNotFoundCounter creates an empty counter
+func NewNotFoundCounter() *NotFoundCounter {
+	return (*NotFoundCounter)(newLabeledCounter("go_gateway_negative_cache_total",
+		"Lookups by id that found nothing, by whether a cached marker answered them", "table", "outcome"))
+}
+
+// Record counts one outcome of table. A nil counter records nothing.
+func (c *NotFoundCounter) Record(table, outcome string) {
+	(*labeledCounter)(c).inc(table, outcome)
+}
+
+// Count returns how many times outcome was recorded for table
+func (c *NotFoundCounter) Count(table, outcome string) int64 {
+	return (*labeledCounter)(c).count(table, outcome)
+}
+
+// WritePrometheus writes the counter in the Prometheus text format
+func (c *NotFoundCounter) WritePrometheus(w io.Writer) {
+	(*labeledCounter)(c).writePrometheus(w)
+}
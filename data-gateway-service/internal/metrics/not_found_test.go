@@ -0,0 +1,33 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotFoundCounter_ByTableAndOutcome(t *testing.T) {
+	c := NewNotFoundCounter()
+	c.Record("tender", NotFoundStored)
+	c.Record("tender", NotFoundHit)
+	c.Record("tender", NotFoundHit)
+	c.Record("rup", NotFoundInvalidated)
+
+	var buf bytes.Buffer
+	c.WritePrometheus(&buf)
+	out := buf.String()
+
+	assert.Contains(t, out, "# TYPE go_gateway_negative_cache_total counter")
+	assert.Contains(t, out, `go_gateway_negative_cache_total{table="tender",outcome="hit"} 2`)
+	assert.Contains(t, out, `go_gateway_negative_cache_total{table="tender",outcome="stored"} 1`)
+	assert.Contains(t, out, `go_gateway_negative_cache_total{table="rup",outcome="invalidated"} 1`)
+	assert.Equal(t, int64(2), c.Count("tender", NotFoundHit))
+
+	var none *NotFoundCounter
+	none.Record("a", NotFoundHit)
+	buf.Reset()
+	none.WritePrometheus(&buf)
+	assert.Empty(t, buf.String())
+	assert.Zero(t, none.Count("a", NotFoundHit))
+}
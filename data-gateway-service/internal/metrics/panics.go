@@ -0,0 +1,23 @@
+package metrics
+
+import "io"
+
+// PanicCounter counts handler panics recovered by route pattern
+type PanicCounter labeledCounter
+
+// NewPanicCounter creates an empty counter
+func NewPanicCounter() *PanicCounter {
+	return (*PanicCounter)(newLabeledCounter("go_gateway_panics_total",
+		"Handler panics recovered by route", "route"))
+}
+
+// Record counts one panic in a handler of route. A nil counter records
+// nothing.
+func (c *PanicCounter) Record(route string) {
+	(*labeledCounter)(c).inc(route)
+}
+
+// WritePrometheus writes the counter in the Prometheus text format
+func (c *PanicCounter) WritePrometheus(w io.Writer) {
+	(*labeledCounter)(c).writePrometheus(w)
+}
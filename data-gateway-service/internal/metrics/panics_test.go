@@ -0,0 +1,29 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPanicCounter_ByRoute(t *testing.T) {
+	c := NewPanicCounter()
+	c.Record("/api/v1/query")
+	c.Record("/api/v1/query")
+	c.Record("/api/v1/rup/{id}")
+
+	var buf bytes.Buffer
+	c.WritePrometheus(&buf)
+	out := buf.String()
+
+	assert.Contains(t, out, "# TYPE go_gateway_panics_total counter")
+	assert.Contains(t, out, `go_gateway_panics_total{route="/api/v1/query"} 2`)
+	assert.Contains(t, out, `go_gateway_panics_total{route="/api/v1/rup/{id}"} 1`)
+
+	var none *PanicCounter
+	none.Record("/")
+	buf.Reset()
+	none.WritePrometheus(&buf)
+	assert.Empty(t, buf.String())
+}
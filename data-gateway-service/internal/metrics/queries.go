@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// QueryCounter counts executed queries by data source and attribution label.
+// Only whitelisted label keys become metric labels, which keeps the number of
+// series bounded whatever labels callers send.
+type QueryCounter struct {
+	mu     sync.Mutex
+	keys   []string
+	series map[string]*querySeries
+}
+
+type querySeries struct {
+	values []string // Data source followed by one value per whitelisted key
+	count  int64
+}
+
+// NewQueryCounter creates a counter exposing the given label keys
+func NewQueryCounter(keys []string) *QueryCounter {
+	return &QueryCounter{
+		keys:   keys,
+		series: make(map[string]*querySeries),
+	}
+}
+
+// Record counts one query. A nil counter records nothing.
+func (c *QueryCounter) Record(source string, labels map[string]string) {
+	if c == nil {
+		return
+	}
+
+	values := make([]string, 0, len(c.keys)+1)
+	values = append(values, source)
+	for _, key := range c.keys {
+		values = append(values, labels[key])
+	}
+	id := strings.Join(values, "\x00")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.series[id]
+	if !ok {
+		s = &querySeries{values: values}
+		c.series[id] = s
+	}
+	s.count++
+}
+
+// WritePrometheus writes the counter in the Prometheus text format
+func (c *QueryCounter) WritePrometheus(w io.Writer) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	lines := make([]string, 0, len(c.series))
+	for _, s := range c.series {
+		pairs := []string{"data_source=" + strconv.Quote(s.values[0])}
+		for i, key := range c.keys {
+			pairs = append(pairs, metricLabelName(key)+"="+strconv.Quote(s.values[i+1]))
+		}
+		lines = append(lines, fmt.Sprintf("go_gateway_queries_total{%s} %d", strings.Join(pairs, ","), s.count))
+	}
+	c.mu.Unlock()
+	sort.Strings(lines)
+
+	fmt.Fprintf(w, "# HELP go_gateway_queries_total Queries executed by data source and attribution label\n")
+	fmt.Fprintf(w, "# TYPE go_gateway_queries_total counter\n")
+	for _, line := range lines {
+		fmt.Fprintln(w, line)
+	}
+}
+
+// metricLabelName converts a query label key to a valid Prometheus label name
+func metricLabelName(key string) string {
+	return "label_" + strings.ReplaceAll(key, "-", "_")
+}
@@ -0,0 +1,33 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryCounter_OnlyWhitelistedLabels(t *testing.T) {
+	c := NewQueryCounter([]string{"app", "cost-center"})
+	c.Record("BIGQUERY", map[string]string{"app": "finance", "cost-center": "cc1", "user": "u-1"})
+	c.Record("BIGQUERY", map[string]string{"app": "finance", "cost-center": "cc1", "user": "u-2"})
+	c.Record("DATAWAREHOUSE", map[string]string{"gateway": "true"})
+
+	var buf bytes.Buffer
+	c.WritePrometheus(&buf)
+	out := buf.String()
+
+	assert.Contains(t, out, "# TYPE go_gateway_queries_total counter")
+	assert.Contains(t, out, `go_gateway_queries_total{data_source="BIGQUERY",label_app="finance",label_cost_center="cc1"} 2`)
+	assert.Contains(t, out, `go_gateway_queries_total{data_source="DATAWAREHOUSE",label_app="",label_cost_center=""} 1`)
+	assert.NotContains(t, out, "u-1")
+}
+
+func TestQueryCounter_Nil(t *testing.T) {
+	var c *QueryCounter
+	c.Record("BIGQUERY", nil)
+
+	var buf bytes.Buffer
+	c.WritePrometheus(&buf)
+	assert.Empty(t, buf.String())
+}
@@ -0,0 +1,28 @@
+package metrics
+
+import "io"
+
+// Kinds of schema drift
+const (
+	DriftChanged = "changed" // A fresh result's columns differ from the cached ones
+	DriftMixed   = "mixed"   // A result's rows hold values of different types in a column
+)
+
+// SchemaDriftCounter counts results whose schema drifted, by source and kind
+type SchemaDriftCounter labeledCounter
+
+// NewSchemaDriftCounter creates an empty counter
+func NewSchemaDriftCounter() *SchemaDriftCounter {
+	return (*SchemaDriftCounter)(newLabeledCounter("go_gateway_cache_schema_drift_total",
+		"Results whose schema drifted from the cached one or mixed value types, by kind", "source", "kind"))
+}
+
+// Record counts one drift of source. A nil counter records nothing.
+func (c *SchemaDriftCounter) Record(source, kind string) {
+	(*labeledCounter)(c).inc(source, kind)
+}
+
+// WritePrometheus writes the counter in the Prometheus text format
+func (c *SchemaDriftCounter) WritePrometheus(w io.Writer) {
+	(*labeledCounter)(c).writePrometheus(w)
+}
@@ -0,0 +1,29 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchemaDriftCounter_BySourceAndKind(t *testing.T) {
+	c := NewSchemaDriftCounter()
+	c.Record("DATAWAREHOUSE", DriftChanged)
+	c.Record("DATAWAREHOUSE", DriftChanged)
+	c.Record("BIGQUERY", DriftMixed)
+
+	var buf bytes.Buffer
+	c.WritePrometheus(&buf)
+	out := buf.String()
+
+	assert.Contains(t, out, "# TYPE go_gateway_cache_schema_drift_total counter")
+	assert.Contains(t, out, `go_gateway_cache_schema_drift_total{source="DATAWAREHOUSE",kind="changed"} 2`)
+	assert.Contains(t, out, `go_gateway_cache_schema_drift_total{source="BIGQUERY",kind="mixed"} 1`)
+
+	var none *SchemaDriftCounter
+	none.Record("a", DriftMixed)
+	buf.Reset()
+	none.WritePrometheus(&buf)
+	assert.Empty(t, buf.String())
+}
@@ -0,0 +1,31 @@
+package metrics
+
+import "io"
+
+// Outcomes of a shadow read
+const (
+	ShadowMatch    = "match"    // Both sources returned the same number of rows
+	ShadowMismatch = "mismatch" // The row counts differ
+	ShadowError    = "error"    // The secondary failed or was unavailable
+)
+
+// ShadowCounter counts queries mirrored from a primary data source to its
+// shadow by outcome, for validating a migration between clusters
+type ShadowCounter labeledCounter
+
+// NewShadowCounter creates an empty counter
+func NewShadowCounter() *ShadowCounter {
+	return (*ShadowCounter)(newLabeledCounter("go_gateway_shadow_reads_total",
+		"Queries mirrored to a shadow data source by outcome", "primary", "secondary", "outcome"))
+}
+
+// Record counts one shadow read of primary on secondary. A nil counter
+// records nothing.
+func (c *ShadowCounter) Record(primary, secondary, outcome string) {
+	(*labeledCounter)(c).inc(primary, secondary, outcome)
+}
+
+// WritePrometheus writes the counter in the Prometheus text format
+func (c *ShadowCounter) WritePrometheus(w io.Writer) {
+	(*labeledCounter)(c).writePrometheus(w)
+}
@@ -0,0 +1,29 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShadowCounter_ByPairAndOutcome(t *testing.T) {
+	c := NewShadowCounter()
+	c.Record("DATAWAREHOUSE", "DATAWAREHOUSE_CLOUD", ShadowMatch)
+	c.Record("DATAWAREHOUSE", "DATAWAREHOUSE_CLOUD", ShadowMatch)
+	c.Record("DATAWAREHOUSE", "DATAWAREHOUSE_CLOUD", ShadowMismatch)
+
+	var buf bytes.Buffer
+	c.WritePrometheus(&buf)
+	out := buf.String()
+
+	assert.Contains(t, out, "# TYPE go_gateway_shadow_reads_total counter")
+	assert.Contains(t, out, `go_gateway_shadow_reads_total{primary="DATAWAREHOUSE",secondary="DATAWAREHOUSE_CLOUD",outcome="match"} 2`)
+	assert.Contains(t, out, `go_gateway_shadow_reads_total{primary="DATAWAREHOUSE",secondary="DATAWAREHOUSE_CLOUD",outcome="mismatch"} 1`)
+
+	var none *ShadowCounter
+	none.Record("a", "b", ShadowError)
+	buf.Reset()
+	none.WritePrometheus(&buf)
+	assert.Empty(t, buf.String())
+}
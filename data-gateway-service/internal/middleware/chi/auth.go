@@ -1,20 +1,16 @@
 package chi
 
 import (
+	"net"
 	"net/http"
 	"strings"
 
+	"go-data-gateway/internal/auth"
 	"go-data-gateway/internal/response"
 )
 
-// APIKeyAuth validates API keys for Chi router
-func APIKeyAuth(validKeys []string) func(next http.Handler) http.Handler {
-	// Create map for O(1) lookup
-	keyMap := make(map[string]bool)
-	for _, key := range validKeys {
-		keyMap[key] = true
-	}
-
+// APIKeyAuth validates API keys for Chi router against the key store snapshot
+func APIKeyAuth(store *auth.KeyStore) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Check for API key in header
@@ -29,13 +25,67 @@ func APIKeyAuth(validKeys []string) func(next http.Handler) http.Handler {
 			}
 
 			// Validate key
-			if apiKey == "" || !keyMap[apiKey] {
+			key, ok := store.Lookup(apiKey)
+			if !ok {
 				response.Error(w, "Invalid or missing API key", http.StatusUnauthorized)
 				return
 			}
 
-			// Continue to next handler
+			// Continue to next handler with the key identity attached
+			next.ServeHTTP(w, r.WithContext(auth.WithKey(r.Context(), key)))
+		})
+	}
+}
+
+// RequireScope rejects requests whose API key lacks the given scope
+func RequireScope(scope string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !auth.HasScope(r.Context(), scope) {
+				response.Error(w, "API key lacks required scope: "+scope, http.StatusForbidden)
+				return
+			}
+
 			next.ServeHTTP(w, r)
 		})
 	}
 }
+
+// NetworkOrScope admits requests from the given networks without an API key
+// and otherwise requires a key with the given scope. The client address is
+// RemoteAddr, as set by RealIP from proxy headers; allowlisting is only as
+// trustworthy as the proxy in front of the gateway.
+func NetworkOrScope(store *auth.KeyStore, scope string, networks []*net.IPNet) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		keyed := APIKeyAuth(store)(RequireScope(scope)(next))
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if inNetworks(r.RemoteAddr, networks) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			keyed.ServeHTTP(w, r)
+		})
+	}
+}
+
+// inNetworks reports whether the address, with or without a port, is in one
+// of networks
+func inNetworks(addr string, networks []*net.IPNet) bool {
+	if len(networks) == 0 {
+		return false
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr // RealIP leaves a bare address
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
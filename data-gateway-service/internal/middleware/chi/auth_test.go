@@ -0,0 +1,75 @@
+package chi
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/auth"
+	"go-data-gateway/internal/metrics"
+)
+
+func TestNetworkOrScope(t *testing.T) {
+	store := auth.NewKeyStore([]string{"plain-key"}, []string{"admin-key"}, nil, time.Minute, zap.NewNop())
+	store.AddEnvKeys([]string{"metrics-key"}, []string{auth.ScopeMetricsRead}, nil)
+	_, internal, err := net.ParseCIDR("10.0.0.0/8")
+	require.NoError(t, err)
+
+	handler := NetworkOrScope(store, auth.ScopeMetricsRead, []*net.IPNet{internal})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		key        string
+		want       int
+	}{
+		{"internal network", "10.1.2.3:51234", "", http.StatusOK},
+		{"internal address without port", "10.1.2.3", "", http.StatusOK},
+		{"external without key", "203.0.113.7:51234", "", http.StatusUnauthorized},
+		{"external key without scope", "203.0.113.7:51234", "plain-key", http.StatusForbidden},
+		{"external key with scope", "203.0.113.7:51234", "metrics-key", http.StatusOK},
+		{"external admin key", "203.0.113.7:51234", "admin-key", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/cache/stats", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.key != "" {
+				r.Header.Set("X-API-Key", tt.key)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+}
+
+func TestCountEndpoint_CountsRejectedRequests(t *testing.T) {
+	store := auth.NewKeyStore([]string{"plain-key"}, nil, nil, time.Minute, zap.NewNop())
+	counter := metrics.NewEndpointCounter()
+	handler := CountEndpoint(counter, "/cache/stats")(
+		NetworkOrScope(store, auth.ScopeMetricsRead, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("{}"))
+		})))
+
+	for _, key := range []string{"", "plain-key"} {
+		r := httptest.NewRequest(http.MethodGet, "/cache/stats", nil)
+		r.Header.Set("X-API-Key", key)
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	var buf bytes.Buffer
+	counter.WritePrometheus(&buf)
+	assert.Contains(t, buf.String(), `go_gateway_endpoint_requests_total{path="/cache/stats",code="401"} 1`)
+	assert.Contains(t, buf.String(), `go_gateway_endpoint_requests_total{path="/cache/stats",code="403"} 1`)
+}
@@ -0,0 +1,71 @@
+package chi
+
+import (
+	"net/http"
+
+	"go-data-gateway/internal/config"
+)
+
+// CacheControl sets Cache-Control on responses: the policy on successful GET
+// responses, no-store on everything else so errors and mutations are never
+// cached. A Cache-Control already set by the handler, or by a CacheControl
+// closer to it, is kept. An Age header is dropped with no-store.
+func CacheControl(policy config.CachePolicy) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			applied := policy
+			if r.Method != http.MethodGet {
+				applied = config.NoStore
+			}
+			next.ServeHTTP(&cacheControlWriter{ResponseWriter: w, policy: applied}, r)
+		})
+	}
+}
+
+// cacheControlWriter writes Cache-Control just before the status, once the
+// handler's outcome is known
+type cacheControlWriter struct {
+	http.ResponseWriter
+	policy      config.CachePolicy
+	wroteHeader bool
+}
+
+func (w *cacheControlWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		policy := w.policy
+		if status != http.StatusOK {
+			policy = config.NoStore
+		}
+		header := w.Header()
+		if header.Get("Cache-Control") == "" {
+			header.Set("Cache-Control", policy.String())
+			if !policy.Cacheable() {
+				header.Del("Age")
+			}
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *cacheControlWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush lets streaming handlers flush through the wrapper
+func (w *cacheControlWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (w *cacheControlWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
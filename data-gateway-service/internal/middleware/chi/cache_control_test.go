@@ -0,0 +1,63 @@
+package chi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+
+	"go-data-gateway/internal/config"
+)
+
+func TestCacheControl(t *testing.T) {
+	public := config.CachePolicy{Public: true, MaxAge: time.Minute, SMaxAge: 10 * time.Minute}
+
+	r := chi.NewRouter()
+	r.Use(CacheControl(config.NoStore))
+	r.Get("/admin/keys", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("keys"))
+	})
+	r.Post("/query", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("rows"))
+	})
+	r.Post("/stream", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		w.(http.Flusher).Flush()
+	})
+	r.Route("/tender", func(r chi.Router) {
+		r.Use(CacheControl(public))
+		r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Age", "12")
+			w.Write([]byte("tenders"))
+		})
+		r.Get("/missing", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Age", "12")
+			w.WriteHeader(http.StatusNotFound)
+		})
+		r.Post("/search", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("results"))
+		})
+	})
+
+	tests := []struct {
+		method, path string
+		cacheControl string
+		age          string
+	}{
+		{http.MethodGet, "/tender/", "public, max-age=60, s-maxage=600", "12"},
+		{http.MethodGet, "/tender/missing", "no-store", ""},
+		{http.MethodPost, "/tender/search", "no-store", ""},
+		{http.MethodGet, "/admin/keys", "no-store", ""},
+		{http.MethodPost, "/query", "no-store", ""},
+		{http.MethodPost, "/stream", "no-store", ""},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		assert.Equal(t, tt.cacheControl, rec.Header().Get("Cache-Control"), tt.path)
+		assert.Equal(t, tt.age, rec.Header().Get("Age"), tt.path)
+	}
+}
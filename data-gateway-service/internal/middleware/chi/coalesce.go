@@ -0,0 +1,26 @@
+package chi
+
+import (
+	"net/http"
+
+	"go-data-gateway/internal/coalesce"
+)
+
+// Coalesce shares one execution between concurrent identical GET requests.
+// Apply it only to idempotent endpoints whose responses are small enough to
+// buffer; other methods pass through. A nil group disables coalescing.
+func Coalesce(group *coalesce.Group) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if group == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			group.Do(r, next).WriteTo(w)
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package chi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-data-gateway/internal/auth"
+	"go-data-gateway/internal/coalesce"
+)
+
+func TestCoalesce_ConcurrentIdenticalRequestsShareOneUpstreamCall(t *testing.T) {
+	const clients = 50
+	group := coalesce.New()
+
+	var upstreamCalls atomic.Int32
+	release := make(chan struct{})
+	handler := Coalesce(group)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamCalls.Add(1)
+		<-release // Hold the query open until every client is waiting on it
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"success":true,"data":[{"kd_tender":1}]}`))
+	}))
+
+	responses := make([]*httptest.ResponseRecorder, clients)
+	var wg sync.WaitGroup
+	for i := range responses {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/tender?status=active", nil)
+			r = r.WithContext(auth.WithKey(r.Context(), &auth.APIKey{ID: "client", Scopes: []string{"read"}}))
+			responses[i] = httptest.NewRecorder()
+			handler.ServeHTTP(responses[i], r)
+		}(i)
+	}
+
+	require.Eventually(t, func() bool { return group.Waiting() == clients }, 5*time.Second, time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), upstreamCalls.Load())
+	for _, rec := range responses {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		assert.Equal(t, `{"success":true,"data":[{"kd_tender":1}]}`, rec.Body.String())
+	}
+
+	var metrics strings.Builder
+	group.WritePrometheus(&metrics)
+	assert.True(t, strings.Contains(metrics.String(), "go_gateway_coalesce_executions_total 1\n"))
+	assert.True(t, strings.Contains(metrics.String(), "go_gateway_coalesced_requests_total 49\n"))
+}
+
+func TestCoalesce_OnlyGETsParticipate(t *testing.T) {
+	group := coalesce.New()
+	var calls atomic.Int32
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	})
+
+	Coalesce(group)(upstream).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/v1/tender/search", nil))
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Zero(t, group.Executions())
+
+	// Disabled coalescing passes requests through
+	Coalesce(nil)(upstream).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/tender", nil))
+	assert.Equal(t, int32(2), calls.Load())
+}
@@ -1,22 +1,27 @@
 package chi
 
-import "net/http"
+import (
+	"net/http"
+
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/middleware/cors"
+)
+
+// CORS returns a Chi middleware for handling CORS according to configuration
+func CORS(cfg config.CORSConfig) (func(next http.Handler) http.Handler, error) {
+	policy, err := cors.NewPolicy(cfg)
+	if err != nil {
+		return nil, err
+	}
 
-// CORS returns a Chi middleware for handling CORS
-func CORS() func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Access-Control-Allow-Origin", "*")
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, X-API-Key, X-Request-ID, Authorization")
-			w.Header().Set("Access-Control-Max-Age", "86400")
-
-			if r.Method == "OPTIONS" {
-				w.WriteHeader(http.StatusNoContent)
+			// Preflight requests are answered by the policy itself
+			if policy.Handle(w, r) {
 				return
 			}
 
 			next.ServeHTTP(w, r)
 		})
-	}
+	}, nil
 }
@@ -0,0 +1,32 @@
+package chi
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"go-data-gateway/internal/auth"
+	"go-data-gateway/internal/inflight"
+)
+
+// Inflight registers each request as an operation of kind in registry until
+// its handler returns or panics. Handlers describe and update it through
+// inflight.FromContext. A nil registry registers nothing.
+func Inflight(registry *inflight.Registry, kind inflight.Kind) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if registry == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			apiKeyID := ""
+			if key, ok := auth.KeyFromContext(r.Context()); ok {
+				apiKeyID = key.ID
+			}
+
+			ctx, op := registry.Start(r.Context(), kind, middleware.GetReqID(r.Context()), apiKeyID)
+			defer op.Done()
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package chi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-data-gateway/internal/auth"
+	"go-data-gateway/internal/inflight"
+)
+
+func TestInflight_RegistersUntilHandlerReturnsOrPanics(t *testing.T) {
+	registry := inflight.NewRegistry()
+
+	var during []inflight.Entry
+	handler := Inflight(registry, inflight.KindStream)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inflight.FromContext(r.Context()).Describe("DATAWAREHOUSE", "SELECT 1")
+		during = registry.List(0)
+		if r.URL.Query().Has("panic") {
+			panic("handler failed")
+		}
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/stream", nil)
+	r = r.WithContext(auth.WithKey(r.Context(), &auth.APIKey{ID: "analyst"}))
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	require.Len(t, during, 1)
+	assert.Equal(t, inflight.KindStream, during[0].Kind)
+	assert.Equal(t, "analyst", during[0].APIKeyID)
+	assert.Equal(t, "DATAWAREHOUSE", during[0].Source)
+	assert.Empty(t, registry.List(0))
+
+	assert.Panics(t, func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/v1/stream?panic", nil))
+	})
+	assert.Empty(t, registry.List(0))
+}
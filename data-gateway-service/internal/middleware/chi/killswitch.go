@@ -0,0 +1,23 @@
+package chi
+
+import (
+	"net/http"
+
+	"go-data-gateway/internal/killswitch"
+)
+
+// KillSwitch rejects a request with 503 ENDPOINT_DISABLED and Retry-After
+// while its route is switched off. A nil switch admits every request.
+func KillSwitch(sw *killswitch.Switch) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if sw == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if killswitch.WriteError(w, sw.Route(r.URL.Path)) {
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
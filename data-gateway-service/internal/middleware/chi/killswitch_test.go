@@ -0,0 +1,47 @@
+package chi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/killswitch"
+)
+
+func TestKillSwitch_RejectsDisabledRoutes(t *testing.T) {
+	sw := killswitch.New(killswitch.NewMemoryStore(), func() config.FlagPolicy {
+		return config.FlagPolicy{Routes: map[string]config.DisabledFlag{"/api/v1/rup": {RetryAfter: 30 * time.Second}}}
+	}, time.Minute, zap.NewNop())
+	handler := KillSwitch(sw)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	for path, want := range map[string]int{
+		"/api/v1/rup":           http.StatusServiceUnavailable,
+		"/api/v1/rup/RUP-001":   http.StatusServiceUnavailable,
+		"/api/v1/tender":        http.StatusNoContent,
+		"/api/v1/rupiah":        http.StatusNoContent,
+		"/api/v1/admin/flags":   http.StatusNoContent,
+		"/api/v1/query/execute": http.StatusNoContent,
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, want, rec.Code, path)
+		if want == http.StatusServiceUnavailable {
+			assert.Equal(t, "30", rec.Header().Get("Retry-After"))
+			assert.True(t, strings.Contains(rec.Body.String(), killswitch.ErrCodeEndpointDisabled), rec.Body.String())
+		}
+	}
+
+	// Flipped at runtime, a route is switched back on without a restart
+	assert.NoError(t, sw.Set(t.Context(), killswitch.KindRoute, "/api/v1/rup", killswitch.Override{Disabled: false}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/rup", nil))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+}
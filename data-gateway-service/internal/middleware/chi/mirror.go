@@ -0,0 +1,123 @@
+package chi
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+
+	"go-data-gateway/internal/mirror"
+	"go-data-gateway/internal/tenant"
+)
+
+// Mirror queues the requests m samples for replay on its shadow target once
+// their response is written. A sampled request's body is read up front and
+// its response copied as it is written; the replay and the comparison run on
+// the mirror's workers, so clients wait for neither. Must run after
+// TenantResolver. A nil mirror mirrors nothing.
+func Mirror(m *mirror.Mirror) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if m == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route, ok := m.Sample(r)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body, ok := readMirroredBody(r, m.MaxBodyBytes())
+			if !ok {
+				m.Skip(route)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			u := *r.URL
+			req := &mirror.Request{
+				ID:      middleware.GetReqID(r.Context()),
+				Route:   route,
+				Method:  r.Method,
+				URL:     &u,
+				Header:  r.Header.Clone(),
+				Body:    body,
+				Context: r.Context(),
+				Handler: replayHandler(next, r),
+			}
+			if t, ok := tenant.FromContext(r.Context()); ok {
+				req.Tenant = t.ID
+			}
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			captured := &cappedBuffer{limit: m.MaxBodyBytes()}
+			ww.Tee(captured)
+			next.ServeHTTP(ww, r)
+
+			if captured.overflow {
+				m.Skip(route)
+				return
+			}
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			m.Enqueue(req, status, ww.Header().Clone(), captured.Bytes())
+		})
+	}
+}
+
+// readMirroredBody reads r's body for the replay and puts it back for the
+// handler. It reports false when the body is over limit, leaving it unread.
+func readMirroredBody(r *http.Request, limit int64) ([]byte, bool) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, true
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	if err != nil || int64(len(body)) > limit {
+		r.Body = readCloser{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		return nil, false
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, true
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// replayHandler serves a replay of r with next. next routes on the path left
+// by the routers above it, so the replay gets a route context of its own
+// from that path; r's is still being used by r.
+func replayHandler(next http.Handler, r *http.Request) http.Handler {
+	var routePath string
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		routePath = rctx.RoutePath
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rctx := chi.NewRouteContext()
+		rctx.RoutePath = routePath
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx)))
+	})
+}
+
+// cappedBuffer keeps what is written to it up to limit bytes, and nothing
+// once more was written
+type cappedBuffer struct {
+	bytes.Buffer
+	limit    int64
+	overflow bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if b.overflow || int64(b.Len()+len(p)) > b.limit {
+		b.overflow = true
+		b.Reset()
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
@@ -0,0 +1,164 @@
+package chi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/mirror"
+	"go-data-gateway/internal/tenant"
+)
+
+// newMirroredRouter routes /api/v1 through Mirror to handler
+func newMirroredRouter(m *mirror.Mirror, handler http.HandlerFunc) http.Handler {
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Route("/api/v1", func(r chi.Router) {
+		r.Use(Mirror(m))
+		r.Get("/tender/{id}", handler)
+		r.Post("/tender/search", handler)
+	})
+	return r
+}
+
+// tenderRows answers with one row per id, and one more when replayed on a
+// substituted source that has an extra row
+func tenderRows(extraOnReplay bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rows := fmt.Sprintf(`{"id":%q,"body":%q}`, chi.URLParam(r, "id"), body)
+		if extraOnReplay && tenant.SubstitutesKey(r.Context()) != "" {
+			rows += `,{"id":"extra"}`
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"success":true,"data":[%s]}`, rows)
+	}
+}
+
+func waitForOutcome(t *testing.T, m *mirror.Mirror, route, outcome string) {
+	require.Eventually(t, func() bool { return m.State().Outcomes[route][outcome] == 1 }, 2*time.Second, time.Millisecond)
+}
+
+func TestMirror_ReplaysInProcess(t *testing.T) {
+	m, err := mirror.New(config.MirrorConfig{
+		Routes:  map[string]int{"/api/v1/tender/*": 100},
+		Sources: map[string]string{"DATAWAREHOUSE": "DATAWAREHOUSE_ARROW"},
+	}, zap.NewNop())
+	require.NoError(t, err)
+	m.Start()
+	defer m.Stop()
+
+	rec := httptest.NewRecorder()
+	newMirroredRouter(m, tenderRows(false)).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/tender/search", strings.NewReader(`{"keyword":"laptop"}`)))
+
+	// The handler got the body, and so did its replay, which routed to the
+	// same handler
+	assert.Equal(t, `{"success":true,"data":[{"id":"","body":"{\"keyword\":\"laptop\"}"}]}`, rec.Body.String())
+	waitForOutcome(t, m, "/api/v1/tender/*", mirror.OutcomeMatch)
+
+	rec = httptest.NewRecorder()
+	newMirroredRouter(m, tenderRows(false)).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/tender/42", nil))
+	assert.Contains(t, rec.Body.String(), `"id":"42"`)
+	require.Eventually(t, func() bool { return m.State().Outcomes["/api/v1/tender/*"][mirror.OutcomeMatch] == 2 }, 2*time.Second, time.Millisecond)
+	assert.Empty(t, m.Mismatches(""))
+}
+
+func TestMirror_LogsMismatch(t *testing.T) {
+	m, err := mirror.New(config.MirrorConfig{
+		Routes:  map[string]int{"/api/v1/tender/*": 100},
+		Sources: map[string]string{"DATAWAREHOUSE": "DATAWAREHOUSE_ARROW"},
+	}, zap.NewNop())
+	require.NoError(t, err)
+	m.Start()
+	defer m.Stop()
+
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/tender/42", nil)
+	r.Header.Set("X-Request-Id", "req-42")
+	rec := httptest.NewRecorder()
+	newMirroredRouter(m, tenderRows(true)).ServeHTTP(rec, r)
+
+	// Clients only see the primary
+	assert.Equal(t, `{"success":true,"data":[{"id":"42","body":""}]}`, rec.Body.String())
+	waitForOutcome(t, m, "/api/v1/tender/*", mirror.OutcomeMismatch)
+
+	mismatches := m.Mismatches("req-42")
+	require.Len(t, mismatches, 1)
+	assert.Equal(t, []string{"rows", "hash"}, mismatches[0].Differs)
+	assert.Equal(t, 1, mismatches[0].Primary.Rows)
+	assert.Equal(t, 2, mismatches[0].Shadow.Rows)
+	assert.Equal(t, "/api/v1/tender/42", mismatches[0].Path)
+}
+
+// blockingTarget holds every replay until released
+type blockingTarget struct {
+	release chan struct{}
+}
+
+func (t *blockingTarget) Name() string { return "blocking" }
+
+func (t *blockingTarget) Replay(ctx context.Context, req *mirror.Request) (mirror.Summary, error) {
+	select {
+	case <-t.release:
+	case <-ctx.Done():
+	}
+	return mirror.Summary{}, ctx.Err()
+}
+
+func TestMirror_NeverDelaysPrimary(t *testing.T) {
+	target := &blockingTarget{release: make(chan struct{})}
+	m := mirror.NewWithTarget(config.MirrorConfig{Routes: map[string]int{"/api/v1/tender/*": 100}, Workers: 1, QueueSize: 1}, target, zap.NewNop())
+	m.Start()
+	defer m.Stop()
+	defer close(target.release)
+
+	router := newMirroredRouter(m, tenderRows(false))
+	for i := 0; i < 5; i++ {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/tender/1", nil))
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("request waited for its replay")
+		}
+	}
+
+	// At most one replay runs and one waits; the queue drops the rest
+	dropped := m.State().Outcomes["/api/v1/tender/*"][mirror.OutcomeDropped]
+	assert.GreaterOrEqual(t, dropped, int64(3))
+	assert.LessOrEqual(t, dropped, int64(4))
+}
+
+func TestMirror_SkipsLargeBodies(t *testing.T) {
+	target := &blockingTarget{release: make(chan struct{})}
+	close(target.release)
+	m := mirror.NewWithTarget(config.MirrorConfig{Routes: map[string]int{"/api/v1/tender/*": 100}, MaxBodyBytes: 16}, target, zap.NewNop())
+
+	body := `{"keyword":"pengadaan laptop kantor"}`
+	rec := httptest.NewRecorder()
+	newMirroredRouter(m, tenderRows(false)).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/tender/search", strings.NewReader(body)))
+
+	// The handler still reads the whole body
+	assert.Contains(t, rec.Body.String(), "pengadaan laptop kantor")
+	assert.Equal(t, int64(1), m.State().Outcomes["/api/v1/tender/*"][mirror.OutcomeSkipped])
+	assert.Zero(t, m.State().Queued)
+
+	// Disabled mirroring passes requests through
+	rec = httptest.NewRecorder()
+	newMirroredRouter(nil, tenderRows(false)).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/tender/7", nil))
+	assert.Contains(t, rec.Body.String(), `"id":"7"`)
+}
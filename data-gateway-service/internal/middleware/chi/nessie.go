@@ -0,0 +1,35 @@
+package chi
+
+import (
+	"net/http"
+
+	"go-data-gateway/internal/auth"
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/response"
+)
+
+// NessieBranch resolves the Nessie branch or tag the request reads the
+// Dremio Iceberg tables at from the branch query parameter, the API key's
+// default or the configured default branch, and stores it in the context.
+// References outside the configured allowlist are rejected. Must run after
+// APIKeyAuth.
+func NessieBranch(nessie config.NessieConfig) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			name := r.URL.Query().Get("branch")
+			if key, ok := auth.KeyFromContext(r.Context()); ok && name == "" {
+				name = key.Branch
+			}
+
+			version, err := datasource.ResolveVersion(nessie, name)
+			if err != nil {
+				response.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			w.Header().Set("X-Nessie-Branch", version.Name)
+			next.ServeHTTP(w, r.WithContext(datasource.WithVersion(r.Context(), version)))
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package chi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go-data-gateway/internal/auth"
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+)
+
+func TestNessieBranch(t *testing.T) {
+	nessie := config.NessieConfig{Source: "nessie_iceberg", Branches: []string{"main", "staging"}, Tags: []string{"2024-q4"}}
+	var got datasource.VersionRef
+	handler := NessieBranch(nessie)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = datasource.VersionFromContext(r.Context())
+	}))
+
+	tests := []struct {
+		name     string
+		url      string
+		key      *auth.APIKey
+		status   int
+		wantType string
+		wantName string
+	}{
+		{"default branch", "/api/v1/tender", nil, http.StatusOK, "BRANCH", "main"},
+		{"requested branch", "/api/v1/tender?branch=staging", nil, http.StatusOK, "BRANCH", "staging"},
+		{"requested tag", "/api/v1/tender?branch=2024-q4", nil, http.StatusOK, "TAG", "2024-q4"},
+		{"key default", "/api/v1/tender", &auth.APIKey{ID: "etl", Branch: "staging"}, http.StatusOK, "BRANCH", "staging"},
+		{"request overrides key", "/api/v1/tender?branch=main", &auth.APIKey{ID: "etl", Branch: "staging"}, http.StatusOK, "BRANCH", "main"},
+		{"not allowed", "/api/v1/tender?branch=dev", nil, http.StatusBadRequest, "", ""},
+		{"key default not allowed", "/api/v1/tender", &auth.APIKey{ID: "etl", Branch: "dev"}, http.StatusBadRequest, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = datasource.VersionRef{}
+			r := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.key != nil {
+				r = r.WithContext(auth.WithKey(r.Context(), tt.key))
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, r)
+
+			assert.Equal(t, tt.status, rec.Code)
+			assert.Equal(t, tt.wantType, got.Type)
+			assert.Equal(t, tt.wantName, got.Name)
+			assert.Equal(t, tt.wantName, rec.Header().Get("X-Nessie-Branch"))
+		})
+	}
+}
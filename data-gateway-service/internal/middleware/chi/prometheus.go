@@ -2,12 +2,25 @@ package chi
 
 import (
 	"fmt"
+	"io"
 	"net/http"
 	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+
+	"go-data-gateway/internal/metrics"
 )
 
-// Simple Prometheus metrics handler
-func PrometheusHandler() http.Handler {
+// PrometheusCollector writes its metrics in the Prometheus text format. A
+// nil collector writes nothing.
+type PrometheusCollector interface {
+	WritePrometheus(w io.Writer)
+}
+
+// Simple Prometheus metrics handler, writing the request counters and then
+// each of collectors in order
+func PrometheusHandler(collectors []PrometheusCollector) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/plain")
 		fmt.Fprintf(w, "# HELP go_gateway_requests_total Total number of requests\n")
@@ -16,6 +29,10 @@ func PrometheusHandler() http.Handler {
 		fmt.Fprintf(w, "\n# HELP go_gateway_uptime_seconds Service uptime in seconds\n")
 		fmt.Fprintf(w, "# TYPE go_gateway_uptime_seconds gauge\n")
 		fmt.Fprintf(w, "go_gateway_uptime_seconds %.0f\n", time.Since(startTime).Seconds())
+		for _, collector := range collectors {
+			fmt.Fprintf(w, "\n")
+			collector.WritePrometheus(w)
+		}
 	})
 }
 
@@ -31,3 +48,34 @@ func MetricsCollector(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// CountEndpoint records every request to path with its response status,
+// including those rejected by later middleware
+func CountEndpoint(counter *metrics.EndpointCounter, path string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			counter.Record(path, status)
+		})
+	}
+}
+
+// QueryEndpoint attributes the data source queries of a request to its route
+// pattern, such as /api/v1/tender/{id}, in the latency histograms
+func QueryEndpoint(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rctx := chi.RouteContext(r.Context())
+		if rctx == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx := metrics.WithEndpoint(r.Context(), rctx.RoutePattern)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
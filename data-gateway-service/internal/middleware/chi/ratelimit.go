@@ -2,37 +2,52 @@ package chi
 
 import (
 	"net/http"
-	"sync"
 	"time"
 
+	"go-data-gateway/internal/auth"
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/ratelimit"
 	"go-data-gateway/internal/response"
+	"go-data-gateway/internal/tenant"
 	"golang.org/x/time/rate"
 )
 
-// visitor holds rate limiter for each visitor
-type visitor struct {
-	limiter  *rate.Limiter
-	lastSeen time.Time
-}
-
-var (
-	visitors = make(map[string]*visitor)
-	mu       sync.RWMutex
-)
+// RateLimitStore holds the token bucket of each client of RateLimiter
+type RateLimitStore = ratelimit.Store[*rate.Limiter]
 
-// RateLimiter creates a Chi middleware for rate limiting
-func RateLimiter(rps int) func(next http.Handler) http.Handler {
-	// Start cleanup goroutine
-	go cleanupVisitors()
+// NewRateLimitStore creates the store of RateLimiter, reported as limiter
+// "api"; the caller starts and stops its sweeps
+func NewRateLimitStore(cfg config.RateLimitingConfig) *RateLimitStore {
+	return ratelimit.NewStore[*rate.Limiter]("api", cfg, time.Now)
+}
 
+// RateLimiter creates a Chi middleware for rate limiting, keeping its token
+// buckets in store
+func RateLimiter(store *RateLimitStore, rps int) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			ip := r.RemoteAddr
+			// Authenticated requests are limited per API key (with its own limit
+			// when configured), anonymous ones per client address
+			id, limit := r.RemoteAddr, rps
+			if key, ok := auth.KeyFromContext(r.Context()); ok {
+				id = key.ID
+				if key.RateLimit > 0 {
+					limit = key.RateLimit
+				}
+			}
 
-			// Get or create limiter for this IP
-			limiter := getVisitor(ip, rps)
+			// Budgets are per tenant so one agency can't exhaust another's
+			if t, ok := tenant.FromContext(r.Context()); ok {
+				id = t.ID + "/" + id
+			}
+
+			// Get or create the limiter for this visitor; burst of 2x RPS
+			limiter := store.Get(id, func() *rate.Limiter {
+				return rate.NewLimiter(rate.Limit(limit), limit*2)
+			})
 
-			if !limiter.Allow() {
+			if !limiter.AllowN(store.Now(), 1) {
+				store.Reject()
 				response.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
 				return
 			}
@@ -41,34 +56,3 @@ func RateLimiter(rps int) func(next http.Handler) http.Handler {
 		})
 	}
 }
-
-// getVisitor gets or creates a rate limiter for the given IP
-func getVisitor(ip string, rps int) *rate.Limiter {
-	mu.Lock()
-	defer mu.Unlock()
-
-	v, exists := visitors[ip]
-	if !exists {
-		limiter := rate.NewLimiter(rate.Limit(rps), rps*2) // Allow burst of 2x RPS
-		visitors[ip] = &visitor{limiter, time.Now()}
-		return limiter
-	}
-
-	v.lastSeen = time.Now()
-	return v.limiter
-}
-
-// cleanupVisitors removes old visitors from the map
-func cleanupVisitors() {
-	for {
-		time.Sleep(time.Minute)
-
-		mu.Lock()
-		for ip, v := range visitors {
-			if time.Since(v.lastSeen) > 3*time.Minute {
-				delete(visitors, ip)
-			}
-		}
-		mu.Unlock()
-	}
-}
@@ -0,0 +1,47 @@
+package chi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/time/rate"
+
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/ratelimit"
+)
+
+func TestRateLimiter_PerClientBurstAndRefill(t *testing.T) {
+	now := time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC)
+	store := ratelimit.NewStore[*rate.Limiter]("api", config.DefaultRateLimiting(), func() time.Time { return now })
+	handler := RateLimiter(store, 2)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	request := func(addr string) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/tender", nil)
+		req.RemoteAddr = addr
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// A burst of twice the rate, then rejections until tokens refill
+	for range 4 {
+		assert.Equal(t, http.StatusOK, request("10.0.0.1:5000"))
+	}
+	assert.Equal(t, http.StatusTooManyRequests, request("10.0.0.1:5000"))
+	assert.Equal(t, http.StatusOK, request("10.0.0.2:5000"), "other clients have their own bucket")
+
+	now = now.Add(500 * time.Millisecond)
+	assert.Equal(t, http.StatusOK, request("10.0.0.1:5000"))
+	assert.Equal(t, http.StatusTooManyRequests, request("10.0.0.1:5000"))
+
+	var metrics strings.Builder
+	store.WritePrometheus(&metrics)
+	assert.Contains(t, metrics.String(), `gateway_rate_limit_keys{limiter="api"} 2`)
+	assert.Contains(t, metrics.String(), `gateway_rate_limit_rejections_total{limiter="api"} 2`)
+}
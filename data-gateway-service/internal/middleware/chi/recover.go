@@ -0,0 +1,134 @@
+package chi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/metrics"
+	"go-data-gateway/internal/response"
+	"go-data-gateway/internal/sentry"
+)
+
+// ErrCodeInternal is the error code of requests whose handler panicked
+const ErrCodeInternal = "INTERNAL"
+
+const internalErrorMessage = "Internal server error"
+
+// Recover recovers handler panics. The panic is logged with its stack,
+// counted by route in panics and, when reporter is set, sent to Sentry. A
+// request that has not started its response gets the standard JSON error
+// with code INTERNAL and its request id. A stream that has started ends with
+// an in-band error: an error event for SSE, an error line for NDJSON. Other
+// responses cannot carry one and are aborted, so clients see a broken
+// transfer rather than a truncated body that looks complete.
+func Recover(logger *zap.Logger, panics *metrics.PanicCounter, reporter *sentry.Client) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			defer func() {
+				rvr := recover()
+				if rvr == nil {
+					return
+				}
+				if rvr == http.ErrAbortHandler {
+					panic(rvr) // Deliberate abort, not a bug
+				}
+
+				requestID := middleware.GetReqID(r.Context())
+				route := routePattern(r)
+				stack := debug.Stack()
+				started := ww.Status() != 0
+
+				logger.Error("Handler panicked",
+					zap.Any("panic", rvr),
+					zap.String("method", r.Method),
+					zap.String("path", r.URL.Path),
+					zap.String("route", route),
+					zap.String("request_id", requestID),
+					zap.Bool("response_started", started),
+					zap.ByteString("stack", stack))
+				panics.Record(route)
+				if reporter != nil {
+					go reportPanic(reporter, logger, sentry.Event{
+						Message:   fmt.Sprint(rvr),
+						Method:    r.Method,
+						URL:       r.URL.String(),
+						Stack:     string(stack),
+						RequestID: requestID,
+						Tags:      map[string]string{"route": route},
+					})
+				}
+
+				if !started {
+					response.ErrorWithMeta(ww, ErrCodeInternal, internalErrorMessage,
+						&response.Meta{RequestID: requestID}, http.StatusInternalServerError)
+					return
+				}
+				if !writeStreamPanic(ww, requestID) {
+					panic(http.ErrAbortHandler)
+				}
+			}()
+
+			next.ServeHTTP(ww, r)
+		})
+	}
+}
+
+// streamPanic is the in-band error ending a stream whose handler panicked
+type streamPanic struct {
+	Type      string `json:"type,omitempty"` // "error" on NDJSON lines, like upstream stream errors
+	Code      string `json:"code"`
+	Error     string `json:"error"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// writeStreamPanic ends an SSE or NDJSON stream with an error and reports
+// whether the response was one of them
+func writeStreamPanic(w http.ResponseWriter, requestID string) bool {
+	mediaType, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	event := streamPanic{Code: ErrCodeInternal, Error: internalErrorMessage, RequestID: requestID}
+
+	switch mediaType {
+	case "text/event-stream":
+		data, _ := json.Marshal(event)
+		fmt.Fprintf(w, "\nevent: error\ndata: %s\n\n", data) // The blank line ends a partial event
+	case "application/x-ndjson":
+		event.Type = "error"
+		data, _ := json.Marshal(event)
+		fmt.Fprintf(w, "%s\n", data)
+	default:
+		return false
+	}
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return true
+}
+
+// routePattern is the matched route of r, or "unmatched" outside a router
+func routePattern(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		if pattern := rctx.RoutePattern(); pattern != "" {
+			return pattern
+		}
+	}
+	return "unmatched"
+}
+
+// reportPanic sends a panic to Sentry; failures are only logged
+func reportPanic(reporter *sentry.Client, logger *zap.Logger, event sentry.Event) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := reporter.Capture(ctx, event); err != nil {
+		logger.Warn("Failed to report panic to Sentry", zap.Error(err))
+	}
+}
@@ -0,0 +1,122 @@
+package chi
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/metrics"
+	"go-data-gateway/internal/response"
+	"go-data-gateway/internal/sentry"
+)
+
+// panickingRouter serves routes that panic before and after starting their
+// response
+func panickingRouter(panics *metrics.PanicCounter, reporter *sentry.Client) http.Handler {
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Use(Recover(zap.NewNop(), panics, reporter))
+	r.Get("/before", func(w http.ResponseWriter, r *http.Request) {
+		panic("nil map")
+	})
+	stream := func(contentType string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", contentType)
+			w.Write([]byte("{\"row\":1}\n"))
+			w.(http.Flusher).Flush()
+			panic("index out of range")
+		}
+	}
+	r.Get("/ndjson", stream("application/x-ndjson"))
+	r.Get("/sse", stream("text/event-stream"))
+	r.Get("/csv", stream("text/csv"))
+	return r
+}
+
+func TestRecover_BeforeResponseReturnsEnvelope(t *testing.T) {
+	panics := metrics.NewPanicCounter()
+	rec := httptest.NewRecorder()
+	panickingRouter(panics, nil).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/before", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	var body response.StandardResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.False(t, body.Success)
+	require.NotNil(t, body.Error)
+	assert.Equal(t, ErrCodeInternal, body.Error.Code)
+	assert.NotContains(t, body.Error.Message, "nil map", "panic values are not shown to clients")
+	require.NotNil(t, body.Meta)
+	assert.NotEmpty(t, body.Meta.RequestID)
+
+	var buf bytes.Buffer
+	panics.WritePrometheus(&buf)
+	assert.Contains(t, buf.String(), `go_gateway_panics_total{route="/before"} 1`)
+}
+
+func TestRecover_MidStreamEndsWithInBandError(t *testing.T) {
+	handler := panickingRouter(nil, nil)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ndjson", nil))
+	assert.Equal(t, http.StatusOK, rec.Code, "the status was sent before the panic")
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.JSONEq(t, `{"row": 1}`, lines[0])
+	var line map[string]string
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &line))
+	assert.Equal(t, "error", line["type"])
+	assert.Equal(t, ErrCodeInternal, line["code"])
+	assert.NotEmpty(t, line["request_id"])
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/sse", nil))
+	assert.Contains(t, rec.Body.String(), "\n\nevent: error\ndata: {\"code\":\"INTERNAL\"")
+
+	// A CSV body cannot carry an error, so the transfer is aborted
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/csv", nil))
+	})
+}
+
+func TestRecover_ReportsToSentry(t *testing.T) {
+	events := make(chan map[string]interface{}, 1)
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("X-Sentry-Auth")
+		assert.Equal(t, "/api/42/store/", r.URL.Path)
+		var event map[string]interface{}
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &event)
+		events <- event
+	}))
+	defer server.Close()
+
+	reporter, err := sentry.New(strings.Replace(server.URL, "://", "://public@", 1)+"/42", "production")
+	require.NoError(t, err)
+	panickingRouter(nil, reporter).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/before", nil))
+
+	select {
+	case event := <-events:
+		assert.Contains(t, auth, "sentry_key=public")
+		assert.Equal(t, "nil map", event["message"])
+		assert.Equal(t, "production", event["environment"])
+		tags := event["tags"].(map[string]interface{})
+		assert.Equal(t, "/before", tags["route"])
+		assert.NotEmpty(t, tags["request_id"])
+		assert.Contains(t, event["extra"].(map[string]interface{})["stack"], "recover_test.go")
+	case <-time.After(5 * time.Second):
+		t.Fatal("panic was not reported")
+	}
+}
@@ -0,0 +1,33 @@
+package chi
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-data-gateway/internal/response"
+	"go-data-gateway/internal/shedding"
+)
+
+// LoadShedding rejects low-priority /api/v1 requests with 503 and Retry-After
+// while the gateway is overloaded, and records the latency of admitted ones
+func LoadShedding(shedder *shedding.Shedder) func(next http.Handler) http.Handler {
+	retryAfter := strconv.Itoa(int(math.Ceil(shedder.RetryAfter().Seconds())))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			group := shedding.GroupFor(r.URL.Path)
+			if !shedder.Admit(group) {
+				w.Header().Set("Retry-After", retryAfter)
+				response.Error(w, "Gateway is overloaded, retry later", http.StatusServiceUnavailable)
+				return
+			}
+
+			start := time.Now()
+			defer func() { shedder.Done(group, time.Since(start)) }()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package chi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/shedding"
+)
+
+// loadResult summarises one run of runLoad
+type loadResult struct {
+	interactive    []time.Duration // Latency of each interactive request
+	interactiveErr int             // Interactive requests that did not return 200
+	batchOK        int64
+	batchShed      int64
+	retryAfter     string
+}
+
+func (r loadResult) interactiveP95() time.Duration {
+	sorted := append([]time.Duration(nil), r.interactive...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[(len(sorted)*95+99)/100-1]
+}
+
+// runLoad floods the gateway with batch requests while a single client
+// issues interactive requests. Both share an upstream with limited
+// concurrency, as batches and lists share the Dremio connection pool.
+func runLoad(t *testing.T, mode shedding.Mode) loadResult {
+	t.Helper()
+
+	shedder := shedding.New(config.SheddingConfig{
+		Enabled:     true,
+		MaxInFlight: 20,
+		Window:      30 * time.Second,
+		RetryAfter:  10 * time.Second,
+	}, zap.NewNop())
+	shedder.SetMode(mode)
+
+	upstream := make(chan struct{}, 20)
+	hold := func(d time.Duration) {
+		upstream <- struct{}{}
+		time.Sleep(d)
+		<-upstream
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/batch", func(w http.ResponseWriter, r *http.Request) {
+		hold(100 * time.Millisecond)
+	})
+	mux.HandleFunc("/api/v1/tender", func(w http.ResponseWriter, r *http.Request) {
+		hold(5 * time.Millisecond)
+	})
+
+	server := httptest.NewServer(LoadShedding(shedder)(mux))
+	defer server.Close()
+
+	client := &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: &http.Transport{MaxIdleConnsPerHost: 100},
+	}
+	defer client.CloseIdleConnections()
+
+	var result loadResult
+	var retryAfter atomic.Value
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+
+	for i := 0; i < 60; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				resp, err := client.Post(server.URL+"/api/v1/batch", "application/json", nil)
+				if err != nil {
+					continue
+				}
+				resp.Body.Close()
+				switch resp.StatusCode {
+				case http.StatusOK:
+					atomic.AddInt64(&result.batchOK, 1)
+				case http.StatusServiceUnavailable:
+					atomic.AddInt64(&result.batchShed, 1)
+					retryAfter.Store(resp.Header.Get("Retry-After"))
+					// Short pause so rejected clients do not spin
+					time.Sleep(10 * time.Millisecond)
+				}
+			}
+		}()
+	}
+
+	// Let the flood saturate the upstream first
+	time.Sleep(200 * time.Millisecond)
+
+	for i := 0; i < 20; i++ {
+		start := time.Now()
+		resp, err := client.Get(server.URL + "/api/v1/tender")
+		result.interactive = append(result.interactive, time.Since(start))
+		if err != nil {
+			result.interactiveErr++
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			result.interactiveErr++
+		}
+	}
+
+	close(stop)
+	wg.Wait()
+
+	if v, ok := retryAfter.Load().(string); ok {
+		result.retryAfter = v
+	}
+	return result
+}
+
+func TestLoadShedding_InteractiveStaysResponsive(t *testing.T) {
+	if testing.Short() {
+		t.Skip("load test")
+	}
+
+	shed := runLoad(t, shedding.ModeAuto)
+	unshed := runLoad(t, shedding.ModeOff)
+
+	t.Logf("auto: interactive p95=%s, batch ok=%d shed=%d", shed.interactiveP95(), shed.batchOK, shed.batchShed)
+	t.Logf("off:  interactive p95=%s, batch ok=%d shed=%d", unshed.interactiveP95(), unshed.batchOK, unshed.batchShed)
+
+	// Interactive traffic is never shed and, with batches held below the
+	// upstream's capacity, never waits behind them
+	assert.Zero(t, shed.interactiveErr)
+	assert.True(t, shed.interactiveP95() < 100*time.Millisecond, "interactive p95 %s", shed.interactiveP95())
+
+	require.True(t, shed.batchShed > 0)
+	assert.True(t, shed.batchOK > 0, "batches still run below the threshold")
+	assert.Equal(t, "10", shed.retryAfter)
+
+	assert.Zero(t, unshed.batchShed)
+	assert.True(t, shed.interactiveP95() < unshed.interactiveP95())
+}
+
+func TestLoadShedding_HealthAndAdminNeverShed(t *testing.T) {
+	shedder := shedding.New(config.SheddingConfig{Enabled: true, MaxInFlight: 20, RetryAfter: 1500 * time.Millisecond}, zap.NewNop())
+	shedder.SetMode(shedding.ModeOn)
+	handler := LoadShedding(shedder)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		method, path string
+		status       int
+	}{
+		{http.MethodPost, "/api/v1/batch", http.StatusServiceUnavailable},
+		{http.MethodPost, "/api/v1/query", http.StatusServiceUnavailable},
+		{http.MethodGet, "/api/v1/tender", http.StatusOK},
+		{http.MethodPut, "/api/v1/admin/shedding", http.StatusOK},
+		{http.MethodGet, "/health", http.StatusOK},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		assert.Equal(t, tt.status, rec.Code, tt.path)
+		if tt.status == http.StatusServiceUnavailable {
+			assert.Equal(t, "2", rec.Header().Get("Retry-After"), tt.path)
+		}
+	}
+}
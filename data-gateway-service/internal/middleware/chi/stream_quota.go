@@ -0,0 +1,52 @@
+package chi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"go-data-gateway/internal/auth"
+	"go-data-gateway/internal/response"
+	"go-data-gateway/internal/streamquota"
+)
+
+// ErrCodeStreamLimit is returned when an API key has its limit of concurrent
+// streams open
+const ErrCodeStreamLimit = "STREAM_LIMIT_EXCEEDED"
+
+// StreamLimitDetails is the error.details of a rejected stream
+type StreamLimitDetails struct {
+	Active int `json:"active"`
+	Limit  int `json:"limit"`
+}
+
+// StreamQuota rejects a streaming request with 429 while its API key has the
+// limiter's limit of streams open. The stream is released when the handler
+// returns, including when the client aborts it. A nil limiter admits every
+// request.
+func StreamQuota(limiter *streamquota.Limiter) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limiter == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, ok := auth.KeyFromContext(r.Context())
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			stream, err := limiter.Acquire(r.Context(), key.ID)
+			var limitErr *streamquota.LimitError
+			if errors.As(err, &limitErr) {
+				response.ErrorWithCode(w, ErrCodeStreamLimit,
+					fmt.Sprintf("API key has %d concurrent streams open, the limit is %d; close one and retry", limitErr.Active, limitErr.Limit),
+					StreamLimitDetails{Active: limitErr.Active, Limit: limitErr.Limit}, http.StatusTooManyRequests)
+				return
+			}
+			defer stream.Release()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
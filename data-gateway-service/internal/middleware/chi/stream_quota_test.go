@@ -0,0 +1,100 @@
+package chi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/auth"
+	"go-data-gateway/internal/streamquota"
+)
+
+func TestStreamQuota_RejectsConcurrentStreamsOverLimit(t *testing.T) {
+	const clients = 5
+	limiter := streamquota.NewLimiter(streamquota.NewMemoryStore(), 2, time.Minute, "replica-a", zap.NewNop())
+
+	var streaming atomic.Int32
+	release := make(chan struct{})
+	handler := StreamQuota(limiter)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		streaming.Add(1)
+		<-release // Hold the stream open
+		w.Write([]byte("{}\n"))
+	}))
+
+	request := func(keyID string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/stream", nil)
+		return r.WithContext(auth.WithKey(r.Context(), &auth.APIKey{ID: keyID}))
+	}
+
+	responses := make([]*httptest.ResponseRecorder, clients)
+	var wg sync.WaitGroup
+	for i := range responses {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			responses[i] = httptest.NewRecorder()
+			handler.ServeHTTP(responses[i], request("analyst"))
+		}(i)
+	}
+
+	// The three rejected requests return while two streams are held open
+	require.Eventually(t, func() bool { return streaming.Load() == 2 }, 5*time.Second, time.Millisecond)
+	counts, err := limiter.Counts(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, []streamquota.KeyCount{{Key: "analyst", Active: 2}}, counts)
+
+	// Another key is not affected
+	other := httptest.NewRecorder()
+	go handler.ServeHTTP(other, request("reporting"))
+	require.Eventually(t, func() bool { return streaming.Load() == 3 }, 5*time.Second, time.Millisecond)
+
+	close(release)
+	wg.Wait()
+
+	var ok, rejected int
+	for _, rec := range responses {
+		switch rec.Code {
+		case http.StatusOK:
+			ok++
+		case http.StatusTooManyRequests:
+			rejected++
+			var body struct {
+				Error struct {
+					Code    string             `json:"code"`
+					Message string             `json:"message"`
+					Details StreamLimitDetails `json:"details"`
+				} `json:"error"`
+			}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.Equal(t, ErrCodeStreamLimit, body.Error.Code)
+			assert.Equal(t, StreamLimitDetails{Active: 2, Limit: 2}, body.Error.Details)
+			assert.Contains(t, body.Error.Message, "2 concurrent streams")
+		}
+	}
+	assert.Equal(t, 2, ok)
+	assert.Equal(t, 3, rejected)
+
+	// Finished streams are released
+	require.Eventually(t, func() bool {
+		counts, err := limiter.Counts(t.Context())
+		return err == nil && len(counts) == 0
+	}, 5*time.Second, time.Millisecond)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, request("analyst"))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestStreamQuota_NilLimiterAdmitsAll(t *testing.T) {
+	handler := StreamQuota(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/stream", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
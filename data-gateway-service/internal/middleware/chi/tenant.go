@@ -0,0 +1,36 @@
+package chi
+
+import (
+	"errors"
+	"net/http"
+
+	"go-data-gateway/internal/auth"
+	"go-data-gateway/internal/response"
+	"go-data-gateway/internal/tenant"
+)
+
+// TenantResolver resolves the request's tenant from the API key or the
+// X-Tenant header and stores it in the context. Must run after APIKeyAuth.
+func TenantResolver(registry *tenant.Registry) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, _ := auth.KeyFromContext(r.Context())
+
+			t, err := registry.Resolve(key, r.Header.Get("X-Tenant"))
+			switch {
+			case errors.Is(err, tenant.ErrUnknownTenant):
+				response.Error(w, "Unknown tenant", http.StatusBadRequest)
+				return
+			case errors.Is(err, tenant.ErrTenantNotAllowed):
+				response.Error(w, "API key is not allowed to access this tenant", http.StatusForbidden)
+				return
+			case err != nil:
+				response.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("X-Tenant", t.ID)
+			next.ServeHTTP(w, r.WithContext(tenant.WithTenant(r.Context(), t)))
+		})
+	}
+}
@@ -0,0 +1,14 @@
+package chi
+
+import "net/http"
+
+// GatewayVersion sets X-Gateway-Version on every response, so clients and
+// proxies can tell which build answered
+func GatewayVersion(version string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Gateway-Version", version)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
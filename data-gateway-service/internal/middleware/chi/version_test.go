@@ -0,0 +1,25 @@
+package chi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGatewayVersion(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(GatewayVersion("v2.3.0"))
+	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	// Errors, including the router's own, carry the version too
+	for _, path := range []string{"/health", "/missing"} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, "v2.3.0", rec.Header().Get("X-Gateway-Version"), path)
+	}
+}
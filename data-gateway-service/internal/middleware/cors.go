@@ -4,22 +4,26 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/middleware/cors"
 )
 
-func CORS() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-API-Key, X-Request-ID, Authorization")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+// CORS applies the configured CORS policy (same behavior as the chi variant)
+func CORS(cfg config.CORSConfig) (gin.HandlerFunc, error) {
+	policy, err := cors.NewPolicy(cfg)
+	if err != nil {
+		return nil, err
+	}
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
+	return func(c *gin.Context) {
+		if policy.Handle(c.Writer, c.Request) {
+			c.Abort()
 			return
 		}
 
 		c.Next()
-	}
+	}, nil
 }
 
 func RequestID() gin.HandlerFunc {
@@ -0,0 +1,133 @@
+package cors
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-data-gateway/internal/config"
+)
+
+// Policy evaluates CORS requests against the configured origins. It is shared
+// by the gin and chi middleware so both routers behave identically.
+type Policy struct {
+	allowAll         bool
+	origins          map[string]bool
+	wildcards        []wildcardOrigin
+	allowMethods     string
+	allowHeaders     string
+	allowCredentials bool
+	maxAge           string
+}
+
+// wildcardOrigin matches origins like https://*.lkpp.go.id
+type wildcardOrigin struct {
+	prefix string // scheme, e.g. "https://"
+	suffix string // domain remainder including leading dot, e.g. ".lkpp.go.id"
+}
+
+// NewPolicy builds a policy from configuration, which must not allow
+// credentials for every origin
+func NewPolicy(cfg config.CORSConfig) (*Policy, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	p := &Policy{
+		origins:          make(map[string]bool),
+		allowMethods:     strings.Join(cfg.AllowedMethods, ", "),
+		allowHeaders:     strings.Join(cfg.AllowedHeaders, ", "),
+		allowCredentials: cfg.AllowCredentials,
+	}
+	if cfg.MaxAge > 0 {
+		p.maxAge = strconv.Itoa(cfg.MaxAge)
+	}
+
+	for _, origin := range cfg.AllowedOrigins {
+		origin = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(origin), "/"))
+		switch {
+		case origin == "*":
+			p.allowAll = true
+		case strings.Contains(origin, "://*."):
+			idx := strings.Index(origin, "://*.")
+			p.wildcards = append(p.wildcards, wildcardOrigin{
+				prefix: origin[:idx+3],
+				suffix: origin[idx+4:],
+			})
+		case origin != "":
+			p.origins[origin] = true
+		}
+	}
+
+	return p, nil
+}
+
+// IsOriginAllowed reports whether the given Origin header value is allowed
+func (p *Policy) IsOriginAllowed(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	if p.allowAll {
+		return true
+	}
+
+	origin = strings.ToLower(origin)
+	if p.origins[origin] {
+		return true
+	}
+
+	for _, w := range p.wildcards {
+		if !strings.HasPrefix(origin, w.prefix) || !strings.HasSuffix(origin, w.suffix) {
+			continue
+		}
+		// Require at least one label in place of the asterisk
+		sub := strings.TrimSuffix(strings.TrimPrefix(origin, w.prefix), w.suffix)
+		if sub != "" && !strings.ContainsAny(sub, "/:@") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Handle writes CORS headers for the request. It returns true when the request
+// was a preflight that has been fully answered and must not reach the handler.
+// Disallowed origins simply get no CORS headers so the browser blocks them.
+func (p *Policy) Handle(w http.ResponseWriter, r *http.Request) bool {
+	header := w.Header()
+	origin := r.Header.Get("Origin")
+	preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+	// The response varies by origin whenever the policy is not a blanket "*"
+	header.Add("Vary", "Origin")
+	if preflight {
+		header.Add("Vary", "Access-Control-Request-Method")
+		header.Add("Vary", "Access-Control-Request-Headers")
+	}
+
+	if p.IsOriginAllowed(origin) {
+		if p.allowAll {
+			// Never with credentials, which NewPolicy rejects for "*"
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			header.Set("Access-Control-Allow-Origin", origin)
+		}
+		if p.allowCredentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if preflight {
+			header.Set("Access-Control-Allow-Methods", p.allowMethods)
+			header.Set("Access-Control-Allow-Headers", p.allowHeaders)
+			if p.maxAge != "" {
+				header.Set("Access-Control-Max-Age", p.maxAge)
+			}
+		}
+	}
+
+	if preflight {
+		w.WriteHeader(http.StatusNoContent)
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,158 @@
+package cors_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-data-gateway/internal/config"
+	ginmw "go-data-gateway/internal/middleware"
+	chimw "go-data-gateway/internal/middleware/chi"
+)
+
+func testCORSConfig() config.CORSConfig {
+	return config.CORSConfig{
+		AllowedOrigins:   []string{"https://app.example.com", "https://*.lkpp.go.id"},
+		AllowedMethods:   []string{"GET", "POST"},
+		AllowedHeaders:   []string{"Content-Type", "X-API-Key"},
+		AllowCredentials: true,
+		MaxAge:           600,
+	}
+}
+
+// routers returns the chi and gin variants wired with the same policy
+func routers(t *testing.T, cfg config.CORSConfig) map[string]http.Handler {
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	chiCORS, err := chimw.CORS(cfg)
+	require.NoError(t, err)
+	chiHandler := chiCORS(http.HandlerFunc(ok))
+
+	gin.SetMode(gin.TestMode)
+	ginCORS, err := ginmw.CORS(cfg)
+	require.NoError(t, err)
+	engine := gin.New()
+	engine.Use(ginCORS)
+	engine.GET("/resource", func(c *gin.Context) { c.Status(http.StatusOK) })
+	engine.OPTIONS("/resource", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	return map[string]http.Handler{
+		"chi": chiHandler,
+		"gin": engine,
+	}
+}
+
+func TestCORS_Preflight(t *testing.T) {
+	tests := []struct {
+		name          string
+		origin        string
+		allowed       bool
+		expectedAllow string
+	}{
+		{"exact origin", "https://app.example.com", true, "https://app.example.com"},
+		{"wildcard subdomain", "https://eproc.lkpp.go.id", true, "https://eproc.lkpp.go.id"},
+		{"wildcard requires subdomain", "https://lkpp.go.id", false, ""},
+		{"wildcard scheme mismatch", "http://eproc.lkpp.go.id", false, ""},
+		{"disallowed origin", "https://evil.example.org", false, ""},
+	}
+
+	for routerName, handler := range routers(t, testCORSConfig()) {
+		for _, tt := range tests {
+			t.Run(routerName+"/"+tt.name, func(t *testing.T) {
+				req := httptest.NewRequest(http.MethodOptions, "/resource", nil)
+				req.Header.Set("Origin", tt.origin)
+				req.Header.Set("Access-Control-Request-Method", "POST")
+				w := httptest.NewRecorder()
+
+				handler.ServeHTTP(w, req)
+
+				// Preflights are answered with 204 even for disallowed origins
+				assert.Equal(t, http.StatusNoContent, w.Code)
+				assert.Contains(t, w.Header().Values("Vary"), "Origin")
+				assert.Equal(t, tt.expectedAllow, w.Header().Get("Access-Control-Allow-Origin"))
+
+				if tt.allowed {
+					assert.Equal(t, "GET, POST", w.Header().Get("Access-Control-Allow-Methods"))
+					assert.Equal(t, "Content-Type, X-API-Key", w.Header().Get("Access-Control-Allow-Headers"))
+					assert.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+					assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+				} else {
+					assert.Empty(t, w.Header().Get("Access-Control-Allow-Methods"))
+					assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+				}
+			})
+		}
+	}
+}
+
+func TestCORS_SimpleRequest(t *testing.T) {
+	tests := []struct {
+		name          string
+		origin        string
+		expectedAllow string
+	}{
+		{"exact origin", "https://app.example.com", "https://app.example.com"},
+		{"wildcard subdomain", "https://spse.lkpp.go.id", "https://spse.lkpp.go.id"},
+		{"disallowed origin", "https://evil.example.org", ""},
+		{"no origin", "", ""},
+	}
+
+	for routerName, handler := range routers(t, testCORSConfig()) {
+		for _, tt := range tests {
+			t.Run(routerName+"/"+tt.name, func(t *testing.T) {
+				req := httptest.NewRequest(http.MethodGet, "/resource", nil)
+				if tt.origin != "" {
+					req.Header.Set("Origin", tt.origin)
+				}
+				w := httptest.NewRecorder()
+
+				handler.ServeHTTP(w, req)
+
+				// Disallowed origins still reach the handler, just without CORS headers
+				assert.Equal(t, http.StatusOK, w.Code)
+				assert.Equal(t, tt.expectedAllow, w.Header().Get("Access-Control-Allow-Origin"))
+				assert.Empty(t, w.Header().Get("Access-Control-Allow-Methods"))
+				assert.Contains(t, w.Header().Values("Vary"), "Origin")
+			})
+		}
+	}
+}
+
+func TestCORS_AllowAllWithoutCredentials(t *testing.T) {
+	cfg := testCORSConfig()
+	cfg.AllowedOrigins = []string{"*"}
+	cfg.AllowCredentials = false
+
+	for routerName, handler := range routers(t, cfg) {
+		t.Run(routerName, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/resource", nil)
+			req.Header.Set("Origin", "https://anything.example.net")
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+			assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+		})
+	}
+}
+
+func TestCORS_AllowAllWithCredentialsRejected(t *testing.T) {
+	cfg := testCORSConfig()
+	cfg.AllowedOrigins = []string{"https://app.example.com", "*"}
+
+	_, err := chimw.CORS(cfg)
+	assert.Error(t, err)
+	_, err = ginmw.CORS(cfg)
+	assert.Error(t, err)
+
+	cfg.AllowCredentials = false
+	_, err = chimw.CORS(cfg)
+	assert.NoError(t, err)
+}
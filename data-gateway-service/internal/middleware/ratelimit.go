@@ -6,66 +6,55 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/ratelimit"
 )
 
 type visitor struct {
+	mu       sync.Mutex
 	lastSeen time.Time
 	count    int
 }
 
-var (
-	visitors = make(map[string]*visitor)
-	mu       sync.Mutex
-)
+// RateLimitStore holds the request count of each client of RateLimiter
+type RateLimitStore = ratelimit.Store[*visitor]
 
-func RateLimiter(limit int) gin.HandlerFunc {
-	go cleanupVisitors()
+// NewRateLimitStore creates the store of RateLimiter, reported as limiter
+// "gin"; the caller starts and stops its sweeps
+func NewRateLimitStore(cfg config.RateLimitingConfig) *RateLimitStore {
+	return ratelimit.NewStore[*visitor]("gin", cfg, time.Now)
+}
 
+// RateLimiter allows each client address limit requests a minute, keeping
+// their counts in store
+func RateLimiter(store *RateLimitStore, limit int) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ip := c.ClientIP()
+		v := store.Get(c.ClientIP(), func() *visitor { return &visitor{} })
+		now := store.Now()
 
-		mu.Lock()
-		v, exists := visitors[ip]
-		if !exists {
-			visitors[ip] = &visitor{lastSeen: time.Now(), count: 1}
-			mu.Unlock()
-			c.Next()
-			return
-		}
-
-		if time.Since(v.lastSeen) > time.Minute {
+		v.mu.Lock()
+		if v.count == 0 || now.Sub(v.lastSeen) > time.Minute {
 			v.count = 1
-			v.lastSeen = time.Now()
-			mu.Unlock()
+			v.lastSeen = now
+			v.mu.Unlock()
 			c.Next()
 			return
 		}
 
 		v.count++
-		v.lastSeen = time.Now()
+		v.lastSeen = now
 
 		if v.count > limit {
-			mu.Unlock()
+			v.mu.Unlock()
+			store.Reject()
 			c.JSON(http.StatusTooManyRequests, gin.H{
 				"error": "Rate limit exceeded",
 			})
 			c.Abort()
 			return
 		}
-		mu.Unlock()
+		v.mu.Unlock()
 		c.Next()
 	}
 }
-
-func cleanupVisitors() {
-	for {
-		time.Sleep(time.Minute)
-		mu.Lock()
-		for ip, v := range visitors {
-			if time.Since(v.lastSeen) > 3*time.Minute {
-				delete(visitors, ip)
-			}
-		}
-		mu.Unlock()
-	}
-}
@@ -0,0 +1,473 @@
+// Package mirror replays a sample of read requests on a shadow target in the
+// background and compares its responses with those clients received, so a
+// new data path can be checked against production traffic before it serves
+// any. Replays wait in a bounded queue and are dropped when it is full;
+// clients never wait for them.
+package mirror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/config"
+)
+
+// Outcomes of a mirrored request
+const (
+	OutcomeMatch    = "match"    // The shadow's status, rows and hash were the primary's
+	OutcomeMismatch = "mismatch" // One of them differed
+	OutcomeError    = "error"    // The replay failed
+	OutcomeDropped  = "dropped"  // The queue was full
+	OutcomeSkipped  = "skipped"  // The request or a response was over MIRROR_MAX_BODY_MB
+)
+
+// HeaderMirrored marks requests sent to an external target; a gateway never
+// mirrors them again
+const HeaderMirrored = "X-Gateway-Mirror"
+
+// ErrTooLarge is returned by targets for responses over the body limit
+var ErrTooLarge = errors.New("response is over the mirror body limit")
+
+// readOnlyPosts are the POST endpoints that only read, the only POSTs
+// mirrored. Diffs store snapshots and exports write files, so neither is.
+var readOnlyPosts = map[string]bool{
+	"/api/v1/query":         true,
+	"/api/v1/batch":         true,
+	"/api/v1/graphql":       true,
+	"/api/v1/tender/search": true,
+	"/api/v1/tender/bulk":   true,
+	"/api/v1/rup/search":    true,
+	"/api/v1/rup/bulk":      true,
+}
+
+// Request is a mirrored request as the gateway received it
+type Request struct {
+	ID     string
+	Route  string // Pattern of MIRROR_ROUTES the request matched
+	Method string
+	URL    *url.URL
+	Header http.Header
+	Body   []byte
+	Tenant string // Resolved tenant, sent to an external target
+
+	// Context holds the values of the request, such as its API key and
+	// tenant, and Handler serves it; both are used to replay in process
+	Context context.Context
+	Handler http.Handler
+}
+
+// Target replays mirrored requests
+type Target interface {
+	Name() string
+	Replay(ctx context.Context, req *Request) (Summary, error)
+}
+
+// Mismatch is a mirrored request whose replay differed or failed
+type Mismatch struct {
+	RequestID string    `json:"request_id"`
+	Route     string    `json:"route"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Tenant    string    `json:"tenant,omitempty"`
+	Target    string    `json:"target"`
+	Outcome   string    `json:"outcome"`           // mismatch or error
+	Differs   []string  `json:"differs,omitempty"` // status, rows or hash
+	Primary   Summary   `json:"primary"`
+	Shadow    *Summary  `json:"shadow,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	At        time.Time `json:"at"`
+}
+
+// route is a pattern of MIRROR_ROUTES split into segments; "*" matches any
+// one segment
+type route struct {
+	pattern  string
+	segments []string
+	percent  int
+}
+
+// job is a request waiting for a worker with the response clients got
+type job struct {
+	req    *Request
+	status int
+	header http.Header
+	body   []byte
+}
+
+type series struct {
+	route, outcome string
+}
+
+// Mirror samples requests and compares their replays. A nil Mirror mirrors
+// nothing.
+type Mirror struct {
+	cfg    config.MirrorConfig
+	routes []route
+	target Target
+	logger *zap.Logger
+
+	sample func(percent int) bool
+	queue  chan job
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu         sync.Mutex
+	counts     map[series]int64
+	mismatches []Mismatch // Ring of the latest cfg.LogSize
+	next       int
+}
+
+// New creates a mirror of cfg's routes on the gateway at cfg.URL, or in
+// process with cfg.Sources substituted. It returns nil when no route is
+// mirrored. Start runs its workers.
+func New(cfg config.MirrorConfig, logger *zap.Logger) (*Mirror, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+	cfg = withDefaults(cfg)
+
+	var target Target
+	switch {
+	case cfg.URL != "" && len(cfg.Sources) > 0:
+		return nil, fmt.Errorf("MIRROR_URL and MIRROR_SOURCES are exclusive")
+	case cfg.URL != "":
+		parsed, err := url.Parse(cfg.URL)
+		if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			return nil, fmt.Errorf("MIRROR_URL must be an http or https URL")
+		}
+		target = NewURLTarget(cfg.URL, cfg.APIKey, cfg.MaxBodyBytes)
+	case len(cfg.Sources) > 0:
+		target = NewSourceTarget(cfg.Sources, cfg.MaxBodyBytes)
+	default:
+		return nil, fmt.Errorf("MIRROR_ROUTES needs MIRROR_URL or MIRROR_SOURCES")
+	}
+	return NewWithTarget(cfg, target, logger), nil
+}
+
+// NewWithTarget creates a mirror of cfg's routes on target
+func NewWithTarget(cfg config.MirrorConfig, target Target, logger *zap.Logger) *Mirror {
+	cfg = withDefaults(cfg)
+
+	routes := make([]route, 0, len(cfg.Routes))
+	for pattern, percent := range cfg.Routes {
+		routes = append(routes, route{pattern: pattern, segments: splitPath(pattern), percent: percent})
+	}
+	// Longer patterns, then those with fewer wildcards, first, so the most
+	// specific one sets the rate
+	sort.Slice(routes, func(i, j int) bool {
+		if len(routes[i].segments) != len(routes[j].segments) {
+			return len(routes[i].segments) > len(routes[j].segments)
+		}
+		if wi, wj := routes[i].wildcards(), routes[j].wildcards(); wi != wj {
+			return wi < wj
+		}
+		return routes[i].pattern < routes[j].pattern
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Mirror{
+		cfg:    cfg,
+		routes: routes,
+		target: target,
+		logger: logger,
+		sample: func(percent int) bool { return rand.IntN(100) < percent },
+		queue:  make(chan job, cfg.QueueSize),
+		ctx:    ctx,
+		cancel: cancel,
+		counts: make(map[series]int64),
+	}
+}
+
+// withDefaults replaces the settings of cfg that would stop mirroring
+func withDefaults(cfg config.MirrorConfig) config.MirrorConfig {
+	cfg.QueueSize = max(cfg.QueueSize, 1)
+	cfg.Workers = max(cfg.Workers, 1)
+	cfg.LogSize = max(cfg.LogSize, 1)
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = time.Minute
+	}
+	if cfg.MaxBodyBytes <= 0 {
+		cfg.MaxBodyBytes = 8 << 20
+	}
+	return cfg
+}
+
+// Start runs the workers that replay mirrored requests
+func (m *Mirror) Start() {
+	if m == nil {
+		return
+	}
+	for i := 0; i < m.cfg.Workers; i++ {
+		m.wg.Add(1)
+		go m.work()
+	}
+}
+
+// Stop cancels replays in progress and waits for the workers; requests
+// still queued are not replayed
+func (m *Mirror) Stop() {
+	if m == nil {
+		return
+	}
+	m.cancel()
+	m.wg.Wait()
+}
+
+// MaxBodyBytes is the size of the largest request or response mirrored
+func (m *Mirror) MaxBodyBytes() int64 {
+	return m.cfg.MaxBodyBytes
+}
+
+// Sample returns the route of r when r is a read the mirror's routes select
+// and it was sampled at the route's rate
+func (m *Mirror) Sample(r *http.Request) (string, bool) {
+	if m == nil || r.Header.Get(HeaderMirrored) != "" {
+		return "", false
+	}
+	path := strings.TrimSuffix(r.URL.Path, "/")
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		if !readOnlyPosts[path] {
+			return "", false
+		}
+	default:
+		return "", false
+	}
+
+	segments := splitPath(path)
+	for _, rt := range m.routes {
+		if rt.matches(segments) {
+			return rt.pattern, m.sample(rt.percent)
+		}
+	}
+	return "", false
+}
+
+// Enqueue queues req for replay with the response clients got, dropping it
+// when the queue is full
+func (m *Mirror) Enqueue(req *Request, status int, header http.Header, body []byte) bool {
+	if m.ctx.Err() != nil {
+		m.record(req.Route, OutcomeDropped)
+		return false
+	}
+	select {
+	case m.queue <- job{req: req, status: status, header: header, body: body}:
+		return true
+	default:
+		m.record(req.Route, OutcomeDropped)
+		return false
+	}
+}
+
+// Skip counts a sampled request that could not be mirrored because its
+// request or response was over the body limit
+func (m *Mirror) Skip(route string) {
+	m.record(route, OutcomeSkipped)
+}
+
+func (m *Mirror) work() {
+	defer m.wg.Done()
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case j := <-m.queue:
+			m.compare(j)
+		}
+	}
+}
+
+// compare replays j's request and records how its response compares with
+// the one clients got. The replay keeps the request's values but not its
+// cancellation, and ends when the mirror stops.
+func (m *Mirror) compare(j job) {
+	primary := Summarize(j.status, j.header, j.body)
+
+	ctx := context.Background()
+	if j.req.Context != nil {
+		ctx = context.WithoutCancel(j.req.Context)
+	}
+	ctx, cancel := context.WithTimeout(ctx, m.cfg.Timeout)
+	defer cancel()
+	defer context.AfterFunc(m.ctx, cancel)()
+
+	shadow, err := m.target.Replay(ctx, j.req)
+	fields := []zap.Field{
+		zap.String("request_id", j.req.ID),
+		zap.String("route", j.req.Route),
+		zap.String("method", j.req.Method),
+		zap.String("path", j.req.URL.Path),
+		zap.String("target", m.target.Name()),
+	}
+	switch {
+	case errors.Is(err, ErrTooLarge):
+		m.record(j.req.Route, OutcomeSkipped)
+		return
+	case err != nil:
+		m.record(j.req.Route, OutcomeError)
+		m.logMismatch(j.req, Mismatch{Outcome: OutcomeError, Primary: primary, Error: err.Error()})
+		m.logger.Warn("Mirrored request failed", append(fields, zap.Error(err))...)
+		return
+	}
+
+	differs := primary.Differs(shadow)
+	if len(differs) == 0 {
+		m.record(j.req.Route, OutcomeMatch)
+		return
+	}
+	m.record(j.req.Route, OutcomeMismatch)
+	m.logMismatch(j.req, Mismatch{Outcome: OutcomeMismatch, Differs: differs, Primary: primary, Shadow: &shadow})
+	m.logger.Warn("Mirrored response differs", append(fields,
+		zap.Strings("differs", differs),
+		zap.Int("status", primary.Status),
+		zap.Int("shadow_status", shadow.Status),
+		zap.Int("rows", primary.Rows),
+		zap.Int("shadow_rows", shadow.Rows))...)
+}
+
+func (m *Mirror) record(route, outcome string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.counts[series{route, outcome}]++
+	m.mu.Unlock()
+}
+
+// logMismatch adds mismatch of req to the ring of recent mismatches
+func (m *Mirror) logMismatch(req *Request, mismatch Mismatch) {
+	mismatch.RequestID = req.ID
+	mismatch.Route = req.Route
+	mismatch.Method = req.Method
+	mismatch.Path = req.URL.RequestURI()
+	mismatch.Tenant = req.Tenant
+	mismatch.Target = m.target.Name()
+	mismatch.At = time.Now().UTC()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.mismatches) < m.cfg.LogSize {
+		m.mismatches = append(m.mismatches, mismatch)
+		return
+	}
+	m.mismatches[m.next] = mismatch
+	m.next = (m.next + 1) % m.cfg.LogSize
+}
+
+// Mismatches returns the recent mismatches, newest first, of requestID or
+// of every request when it is empty
+func (m *Mirror) Mismatches(requestID string) []Mismatch {
+	mismatches := []Mismatch{}
+	if m == nil {
+		return mismatches
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.mismatches {
+		// Walk back from the newest entry, just before next
+		mismatch := m.mismatches[(m.next-1-i+2*len(m.mismatches))%len(m.mismatches)]
+		if requestID == "" || mismatch.RequestID == requestID {
+			mismatches = append(mismatches, mismatch)
+		}
+	}
+	return mismatches
+}
+
+// State is the configuration and counters of a mirror
+type State struct {
+	Enabled  bool                        `json:"enabled"`
+	Target   string                      `json:"target,omitempty"`
+	Routes   map[string]int              `json:"routes,omitempty"` // Percent sampled by pattern
+	Queued   int                         `json:"queued"`
+	Outcomes map[string]map[string]int64 `json:"outcomes,omitempty"` // Requests by route and outcome
+}
+
+// State returns the mirror's configuration and counters
+func (m *Mirror) State() State {
+	if m == nil {
+		return State{}
+	}
+
+	state := State{
+		Enabled:  true,
+		Target:   m.target.Name(),
+		Routes:   m.cfg.Routes,
+		Queued:   len(m.queue),
+		Outcomes: make(map[string]map[string]int64),
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for s, count := range m.counts {
+		if state.Outcomes[s.route] == nil {
+			state.Outcomes[s.route] = make(map[string]int64)
+		}
+		state.Outcomes[s.route][s.outcome] = count
+	}
+	return state
+}
+
+// WritePrometheus writes the mirror's counters in the Prometheus text format
+func (m *Mirror) WritePrometheus(w io.Writer) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	lines := make([]string, 0, len(m.counts))
+	for s, count := range m.counts {
+		lines = append(lines, fmt.Sprintf("go_gateway_mirrored_requests_total{route=%s,outcome=%s} %d",
+			strconv.Quote(s.route), strconv.Quote(s.outcome), count))
+	}
+	m.mu.Unlock()
+	sort.Strings(lines)
+
+	fmt.Fprintf(w, "# HELP go_gateway_mirrored_requests_total Requests mirrored to the shadow target by route and outcome\n")
+	fmt.Fprintf(w, "# TYPE go_gateway_mirrored_requests_total counter\n")
+	for _, line := range lines {
+		fmt.Fprintln(w, line)
+	}
+	fmt.Fprintf(w, "\n# HELP go_gateway_mirror_queue_depth Mirrored requests waiting for a worker\n")
+	fmt.Fprintf(w, "# TYPE go_gateway_mirror_queue_depth gauge\n")
+	fmt.Fprintf(w, "go_gateway_mirror_queue_depth %d\n", len(m.queue))
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+func (rt route) wildcards() int {
+	n := 0
+	for _, segment := range rt.segments {
+		if segment == "*" {
+			n++
+		}
+	}
+	return n
+}
+
+func (rt route) matches(segments []string) bool {
+	if len(segments) != len(rt.segments) {
+		return false
+	}
+	for i, segment := range rt.segments {
+		if segment != "*" && segment != segments[i] {
+			return false
+		}
+	}
+	return true
+}
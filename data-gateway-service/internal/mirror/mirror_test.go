@@ -0,0 +1,247 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/config"
+)
+
+var jsonHeader = http.Header{"Content-Type": {"application/json"}}
+
+// fakeTarget answers each request id with a canned summary or error
+type fakeTarget struct {
+	mu        sync.Mutex
+	summaries map[string]Summary
+	errs      map[string]error
+	replayed  []string
+}
+
+func (t *fakeTarget) Name() string { return "fake" }
+
+func (t *fakeTarget) Replay(ctx context.Context, req *Request) (Summary, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.replayed = append(t.replayed, req.ID)
+	if err := t.errs[req.ID]; err != nil {
+		return Summary{}, err
+	}
+	return t.summaries[req.ID], nil
+}
+
+func mirrorRequest(id, path string) *Request {
+	u, _ := url.Parse(path)
+	return &Request{ID: id, Route: "/api/v1/tender", Method: http.MethodGet, URL: u, Header: http.Header{}}
+}
+
+func TestSummarize(t *testing.T) {
+	primary := Summarize(http.StatusOK, jsonHeader,
+		[]byte(`{"success":true,"data":{"data":[{"a":1,"b":"x"},{"a":2,"b":"y"}],"count":2,"query_time_ms":120},"meta":{"request_id":"r1"}}`))
+	shadow := Summarize(http.StatusOK, http.Header{"Content-Type": {"application/json; charset=utf-8"}},
+		[]byte(`{"success":true,"data":{"count":2,"data":[{"b":"x","a":1},{"b":"y","a":2}],"cache_hit":true,"query_time_ms":9},"meta":{"request_id":"r2"}}`))
+
+	// Timings, cache hits, request ids and key order do not count
+	assert.Equal(t, 2, primary.Rows)
+	assert.Empty(t, primary.Differs(shadow))
+
+	reordered := Summarize(http.StatusOK, jsonHeader, []byte(`{"success":true,"data":[{"a":2},{"a":1}]}`))
+	ordered := Summarize(http.StatusOK, jsonHeader, []byte(`{"success":true,"data":[{"a":1},{"a":2}]}`))
+	assert.Equal(t, []string{"hash"}, ordered.Differs(reordered))
+
+	detail := Summarize(http.StatusOK, jsonHeader, []byte(`{"success":true,"data":{"kd_tender":1}}`))
+	assert.Equal(t, 1, detail.Rows)
+
+	notFound := Summarize(http.StatusNotFound, jsonHeader, []byte(`{"success":false,"error":{"code":"NOT_FOUND","message":"Tender 1 not found"}}`))
+	failed := Summarize(http.StatusInternalServerError, jsonHeader, []byte(`{"success":false,"error":{"code":"UPSTREAM_ERROR","message":"boom"}}`))
+	assert.Equal(t, []string{"status", "hash"}, notFound.Differs(failed))
+
+	csv := Summarize(http.StatusOK, http.Header{"Content-Type": {"text/csv"}}, []byte("a,b\n1,x\n2,y\n"))
+	assert.Equal(t, 3, csv.Rows)
+}
+
+func TestMirror_Sample(t *testing.T) {
+	m := NewWithTarget(config.MirrorConfig{Routes: map[string]int{
+		"/api/v1/tender":                         100,
+		"/api/v1/tender/*":                       0,
+		"/api/v1/query":                          100,
+		"/api/v1/diff":                           100,
+		"/api/v1/sources/*/tables/*/rows":        100,
+		"/api/v1/sources/BIGQUERY/tables/*/rows": 0,
+	}}, &fakeTarget{}, zap.NewNop())
+
+	sample := func(method, path string, header http.Header) (string, bool) {
+		r := httptest.NewRequest(method, path, nil)
+		for name, values := range header {
+			r.Header[name] = values
+		}
+		return m.Sample(r)
+	}
+
+	route, ok := sample(http.MethodGet, "/api/v1/tender/?status=active", nil)
+	assert.True(t, ok)
+	assert.Equal(t, "/api/v1/tender", route)
+
+	_, ok = sample(http.MethodGet, "/api/v1/tender/123", nil)
+	assert.False(t, ok, "sampled at 0%")
+
+	route, ok = sample(http.MethodPost, "/api/v1/query", nil)
+	assert.True(t, ok)
+	assert.Equal(t, "/api/v1/query", route)
+
+	// The most specific pattern sets the rate
+	_, ok = sample(http.MethodGet, "/api/v1/sources/BIGQUERY/tables/rup/rows", nil)
+	assert.False(t, ok)
+	route, ok = sample(http.MethodGet, "/api/v1/sources/DATAWAREHOUSE/tables/tender/rows", nil)
+	assert.True(t, ok)
+	assert.Equal(t, "/api/v1/sources/*/tables/*/rows", route)
+
+	// Writes and already mirrored requests are never mirrored
+	_, ok = sample(http.MethodPost, "/api/v1/diff", nil)
+	assert.False(t, ok)
+	_, ok = sample(http.MethodDelete, "/api/v1/tender", nil)
+	assert.False(t, ok)
+	_, ok = sample(http.MethodGet, "/api/v1/tender", http.Header{HeaderMirrored: {"1"}})
+	assert.False(t, ok)
+	_, ok = sample(http.MethodGet, "/api/v1/rup", nil)
+	assert.False(t, ok)
+}
+
+func TestMirror_ComparesReplays(t *testing.T) {
+	body := []byte(`{"success":true,"data":[{"id":1},{"id":2}]}`)
+	primary := Summarize(http.StatusOK, jsonHeader, body)
+	target := &fakeTarget{
+		summaries: map[string]Summary{
+			"same":      primary,
+			"fewer":     {Status: http.StatusOK, Rows: 1, Hash: "other"},
+			"not-found": {Status: http.StatusNotFound, Rows: 0, Hash: primary.Hash},
+		},
+		errs: map[string]error{"failed": errors.New("connection refused")},
+	}
+	m := NewWithTarget(config.MirrorConfig{Routes: map[string]int{"/api/v1/tender": 100}, LogSize: 2}, target, zap.NewNop())
+	m.Start()
+	defer m.Stop()
+
+	for _, id := range []string{"same", "fewer", "not-found", "failed"} {
+		require.True(t, m.Enqueue(mirrorRequest(id, "/api/v1/tender?page=2"), http.StatusOK, jsonHeader, body))
+		require.Eventually(t, func() bool {
+			target.mu.Lock()
+			defer target.mu.Unlock()
+			return len(target.replayed) > 0 && target.replayed[len(target.replayed)-1] == id
+		}, time.Second, time.Millisecond)
+	}
+	require.Eventually(t, func() bool { return m.State().Outcomes["/api/v1/tender"][OutcomeError] == 1 }, time.Second, time.Millisecond)
+
+	state := m.State()
+	assert.Equal(t, map[string]int64{OutcomeMatch: 1, OutcomeMismatch: 2, OutcomeError: 1}, state.Outcomes["/api/v1/tender"])
+
+	// The log keeps the latest LogSize entries, newest first
+	mismatches := m.Mismatches("")
+	require.Len(t, mismatches, 2)
+	assert.Equal(t, "failed", mismatches[0].RequestID)
+	assert.Equal(t, OutcomeError, mismatches[0].Outcome)
+	assert.Equal(t, "connection refused", mismatches[0].Error)
+	assert.Equal(t, "not-found", mismatches[1].RequestID)
+	assert.Equal(t, []string{"status", "rows"}, mismatches[1].Differs)
+	assert.Equal(t, "/api/v1/tender?page=2", mismatches[1].Path)
+	assert.Equal(t, http.StatusNotFound, mismatches[1].Shadow.Status)
+
+	assert.Len(t, m.Mismatches("not-found"), 1)
+	assert.Empty(t, m.Mismatches("same"))
+
+	var metrics strings.Builder
+	m.WritePrometheus(&metrics)
+	assert.Contains(t, metrics.String(), `go_gateway_mirrored_requests_total{route="/api/v1/tender",outcome="mismatch"} 2`)
+}
+
+func TestMirror_DropsWhenQueueIsFull(t *testing.T) {
+	m := NewWithTarget(config.MirrorConfig{Routes: map[string]int{"/api/v1/tender": 100}, QueueSize: 1}, &fakeTarget{}, zap.NewNop())
+
+	// No workers run, so the second request finds the queue full
+	assert.True(t, m.Enqueue(mirrorRequest("r1", "/api/v1/tender"), http.StatusOK, jsonHeader, nil))
+	assert.False(t, m.Enqueue(mirrorRequest("r2", "/api/v1/tender"), http.StatusOK, jsonHeader, nil))
+	assert.Equal(t, int64(1), m.State().Outcomes["/api/v1/tender"][OutcomeDropped])
+	assert.Equal(t, 1, m.State().Queued)
+
+	m.Stop()
+	assert.False(t, m.Enqueue(mirrorRequest("r3", "/api/v1/tender"), http.StatusOK, jsonHeader, nil))
+}
+
+func TestURLTarget_ReplacesAPIKey(t *testing.T) {
+	var got *http.Request
+	var gotBody string
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"success":true,"data":{"data":[{"id":1}],"count":1}}`))
+	}))
+	defer shadow.Close()
+
+	u, _ := url.Parse("/api/v1/query?format=json")
+	req := &Request{
+		ID:     "req-1",
+		Method: http.MethodPost,
+		URL:    u,
+		Header: http.Header{
+			"X-Api-Key":     {"client-secret-key"},
+			"Authorization": {"Bearer client-secret-key"},
+			"Cookie":        {"session=abc"},
+			"Content-Type":  {"application/json"},
+			"X-App":         {"dashboard"},
+		},
+		Body:   []byte(`{"query":"SELECT 1"}`),
+		Tenant: "lkpp",
+	}
+
+	summary, err := NewURLTarget(shadow.URL+"/", "shadow-key", 1<<20).Replay(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, Summary{Status: http.StatusOK, Rows: 1, Hash: summary.Hash}, summary)
+
+	assert.Equal(t, "/api/v1/query", got.URL.Path)
+	assert.Equal(t, "format=json", got.URL.RawQuery)
+	assert.Equal(t, "shadow-key", got.Header.Get("X-API-Key"))
+	assert.Empty(t, got.Header.Get("Authorization"))
+	assert.Empty(t, got.Header.Get("Cookie"))
+	assert.Equal(t, "dashboard", got.Header.Get("X-App"))
+	assert.Equal(t, "lkpp", got.Header.Get("X-Tenant"))
+	assert.Equal(t, "req-1", got.Header.Get("X-Request-ID"))
+	assert.Equal(t, "1", got.Header.Get(HeaderMirrored))
+	assert.Equal(t, `{"query":"SELECT 1"}`, gotBody)
+
+	_, err = NewURLTarget(shadow.URL, "shadow-key", 10).Replay(context.Background(), req)
+	assert.ErrorIs(t, err, ErrTooLarge)
+}
+
+func TestNew_Validation(t *testing.T) {
+	routes := map[string]int{"/api/v1/tender": 10}
+
+	m, err := New(config.MirrorConfig{}, zap.NewNop())
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+
+	_, err = New(config.MirrorConfig{Routes: routes}, zap.NewNop())
+	assert.Error(t, err)
+
+	_, err = New(config.MirrorConfig{Routes: routes, URL: "http://shadow:8080", Sources: map[string]string{"A": "B"}}, zap.NewNop())
+	assert.Error(t, err)
+
+	_, err = New(config.MirrorConfig{Routes: routes, URL: "shadow:8080"}, zap.NewNop())
+	assert.Error(t, err)
+
+	m, err = New(config.MirrorConfig{Routes: routes, Sources: map[string]string{"DATAWAREHOUSE": "DATAWAREHOUSE_ARROW"}}, zap.NewNop())
+	require.NoError(t, err)
+	assert.Equal(t, "sources DATAWAREHOUSE:DATAWAREHOUSE_ARROW", m.State().Target)
+}
@@ -0,0 +1,103 @@
+package mirror
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Summary is what a response is compared by: its status, its number of rows
+// and a hash of its rows
+type Summary struct {
+	Status int    `json:"status"`
+	Rows   int    `json:"rows"`
+	Hash   string `json:"hash"`
+}
+
+// Summarize summarizes a response. Only the data of a JSON response is
+// hashed, not its meta, which holds request ids and timings; the data of a
+// query result is its rows, without its query time or cache hit. An error
+// is hashed by its code. Other responses, such as CSV, are hashed whole and
+// count their lines.
+func Summarize(status int, header http.Header, body []byte) Summary {
+	summary := Summary{Status: status}
+	if !isJSON(header.Get("Content-Type")) {
+		summary.Rows = bytes.Count(bytes.TrimSpace(body), []byte("\n"))
+		if len(bytes.TrimSpace(body)) > 0 {
+			summary.Rows++
+		}
+		summary.Hash = hash(body)
+		return summary
+	}
+
+	var envelope struct {
+		Data  json.RawMessage `json:"data"`
+		Error *struct {
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		summary.Hash = hash(body)
+		return summary
+	}
+	if envelope.Error != nil {
+		summary.Hash = hash([]byte(envelope.Error.Code))
+		return summary
+	}
+
+	var data interface{}
+	decoder := json.NewDecoder(bytes.NewReader(envelope.Data))
+	decoder.UseNumber()
+	if len(envelope.Data) > 0 {
+		if err := decoder.Decode(&data); err != nil {
+			summary.Hash = hash(envelope.Data)
+			return summary
+		}
+	}
+	switch v := data.(type) {
+	case nil:
+	case []interface{}:
+		summary.Rows = len(v)
+	case map[string]interface{}:
+		summary.Rows = 1
+		if rows, ok := v["data"].([]interface{}); ok {
+			data, summary.Rows = rows, len(rows)
+		}
+	default:
+		summary.Rows = 1
+	}
+
+	// Marshalling sorts object keys, so key order does not change the hash
+	canonical, _ := json.Marshal(data)
+	summary.Hash = hash(canonical)
+	return summary
+}
+
+// Differs returns the fields of shadow that differ from s
+func (s Summary) Differs(shadow Summary) []string {
+	var differs []string
+	if s.Status != shadow.Status {
+		differs = append(differs, "status")
+	}
+	if s.Rows != shadow.Rows {
+		differs = append(differs, "rows")
+	}
+	if s.Hash != shadow.Hash {
+		differs = append(differs, "hash")
+	}
+	return differs
+}
+
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"))
+}
+
+func hash(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
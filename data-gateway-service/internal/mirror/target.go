@@ -0,0 +1,180 @@
+package mirror
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"go-data-gateway/internal/tenant"
+)
+
+// strippedHeaders are never sent to an external target: the caller's
+// credentials, and the encodings the transport negotiates itself
+var strippedHeaders = map[string]bool{
+	"X-Api-Key":           true,
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Accept-Encoding":     true,
+	"Connection":          true,
+	"Content-Length":      true,
+}
+
+// URLTarget sends mirrored requests to another gateway. The caller's API key
+// is never sent; the target authenticates them with its own key.
+type URLTarget struct {
+	base    string
+	apiKey  string
+	maxBody int64
+	client  *http.Client
+}
+
+// NewURLTarget sends mirrored requests to the gateway at base, with apiKey
+func NewURLTarget(base, apiKey string, maxBody int64) *URLTarget {
+	return &URLTarget{
+		base:    strings.TrimSuffix(base, "/"),
+		apiKey:  apiKey,
+		maxBody: maxBody,
+		client:  &http.Client{},
+	}
+}
+
+// Name returns the target's URL
+func (t *URLTarget) Name() string {
+	return t.base
+}
+
+// Replay sends req to the target. The request keeps its id and tenant, and
+// is marked mirrored so the target does not mirror it again.
+func (t *URLTarget) Replay(ctx context.Context, req *Request) (Summary, error) {
+	r, err := http.NewRequestWithContext(ctx, req.Method, t.base+req.URL.RequestURI(), bytes.NewReader(req.Body))
+	if err != nil {
+		return Summary{}, err
+	}
+	for name, values := range req.Header {
+		if !strippedHeaders[http.CanonicalHeaderKey(name)] {
+			r.Header[name] = append([]string(nil), values...)
+		}
+	}
+	if t.apiKey != "" {
+		r.Header.Set("X-API-Key", t.apiKey)
+	}
+	if req.ID != "" {
+		r.Header.Set("X-Request-ID", req.ID)
+	}
+	if req.Tenant != "" {
+		r.Header.Set("X-Tenant", req.Tenant)
+	}
+	r.Header.Set(HeaderMirrored, "1")
+
+	resp, err := t.client.Do(r)
+	if err != nil {
+		return Summary{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, t.maxBody+1))
+	if err != nil {
+		return Summary{}, fmt.Errorf("reading the mirrored response: %w", err)
+	}
+	if int64(len(body)) > t.maxBody {
+		return Summary{}, ErrTooLarge
+	}
+	return Summarize(resp.StatusCode, resp.Header, body), nil
+}
+
+// SourceTarget replays mirrored requests in process, on the handlers that
+// served them, with data sources substituted
+type SourceTarget struct {
+	substitutes map[string]string
+	maxBody     int64
+}
+
+// NewSourceTarget replays reads of each key of substitutes on the source it
+// maps to
+func NewSourceTarget(substitutes map[string]string, maxBody int64) *SourceTarget {
+	return &SourceTarget{substitutes: substitutes, maxBody: maxBody}
+}
+
+// Name lists the substitutions
+func (t *SourceTarget) Name() string {
+	pairs := make([]string, 0, len(t.substitutes))
+	for from, to := range t.substitutes {
+		pairs = append(pairs, from+":"+to)
+	}
+	sort.Strings(pairs)
+	return "sources " + strings.Join(pairs, ",")
+}
+
+// Replay serves req again with its handler. Its substituted sources are read
+// past their cache.
+func (t *SourceTarget) Replay(ctx context.Context, req *Request) (Summary, error) {
+	if req.Handler == nil {
+		return Summary{}, errors.New("request has no handler to replay it")
+	}
+	r, err := http.NewRequestWithContext(tenant.WithSubstitutes(ctx, t.substitutes), req.Method, req.URL.RequestURI(), bytes.NewReader(req.Body))
+	if err != nil {
+		return Summary{}, err
+	}
+	r.Header = req.Header.Clone()
+
+	rec := &recorder{header: make(http.Header), status: http.StatusOK, limit: t.maxBody}
+	if err := serve(req.Handler, rec, r); err != nil {
+		return Summary{}, err
+	}
+	if rec.overflow {
+		return Summary{}, ErrTooLarge
+	}
+	return Summarize(rec.status, rec.header, rec.body), nil
+}
+
+// serve runs handler on a worker, where a panic would end the process
+func serve(handler http.Handler, w http.ResponseWriter, r *http.Request) (err error) {
+	defer func() {
+		if rvr := recover(); rvr != nil {
+			err = fmt.Errorf("handler panicked: %v", rvr)
+		}
+	}()
+	handler.ServeHTTP(w, r)
+	return nil
+}
+
+// recorder captures a replayed response up to limit bytes
+type recorder struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        []byte
+	limit       int64
+	overflow    bool
+}
+
+func (r *recorder) Header() http.Header {
+	return r.header
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if r.wroteHeader {
+		return
+	}
+	r.status = status
+	r.wroteHeader = true
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	r.wroteHeader = true
+	if r.overflow || int64(len(r.body)+len(p)) > r.limit {
+		r.overflow, r.body = true, nil
+		return len(p), nil
+	}
+	r.body = append(r.body, p...)
+	return len(p), nil
+}
+
+// Flush lets streaming handlers run; the response is only read at the end
+func (r *recorder) Flush() {}
@@ -0,0 +1,223 @@
+// Package numeric keeps large numbers exact on their way from a data source
+// to a client. Integers stay int64. Decimals whose precision a float64 cannot
+// hold, such as nilai_pagu totals in the trillions with cents, are sent as
+// JSON strings or as numbers written out in full, never rounded through a
+// float64 or in exponent notation. Which one is decided by the Mode, for
+// every value of a column type alike, so a column never mixes strings and
+// numbers.
+package numeric
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// MetaKey is the result metadata key of the columns whose numbers are sent
+// as strings
+const MetaKey = "string_numbers"
+
+// Float64Digits is the number of significant decimal digits a float64 holds
+// exactly
+const Float64Digits = 15
+
+// Int64Digits is the precision of the widest integer type that always fits
+// an int64
+const Int64Digits = 18
+
+// Mode is when decimals are sent as strings
+type Mode string
+
+const (
+	ModeAuto   Mode = "auto"   // When their precision exceeds Float64Digits
+	ModeAlways Mode = "always" // Every decimal that is not an int64
+	ModeNever  Mode = "never"  // Never; large ones are numbers written out in full
+)
+
+// ParseMode parses a Mode, "" being ModeAuto
+func ParseMode(s string) (Mode, error) {
+	switch mode := Mode(strings.ToLower(strings.TrimSpace(s))); mode {
+	case "":
+		return ModeAuto, nil
+	case ModeAuto, ModeAlways, ModeNever:
+		return mode, nil
+	}
+	return "", fmt.Errorf("numeric_as_string must be %s, %s or %s, got %q", ModeAuto, ModeAlways, ModeNever, s)
+}
+
+var mode atomic.Value // Mode
+
+// SetMode sets when decimals are sent as strings. It is ModeAuto by default.
+func SetMode(m Mode) {
+	mode.Store(m)
+}
+
+// CurrentMode returns the Mode set with SetMode
+func CurrentMode() Mode {
+	if m, ok := mode.Load().(Mode); ok {
+		return m
+	}
+	return ModeAuto
+}
+
+// AsString reports whether the values of a decimal type of precision and
+// scale are sent as strings. Integer types that fit an int64 never are.
+func AsString(precision, scale int) bool {
+	if scale == 0 && precision <= Int64Digits {
+		return false
+	}
+	switch CurrentMode() {
+	case ModeAlways:
+		return true
+	case ModeNever:
+		return false
+	default:
+		return precision > Float64Digits
+	}
+}
+
+// Decimal converts text, the plain decimal text of a value of a decimal type
+// of precision and scale, to the value sent for it: an int64 for integer
+// types that fit one, else text as a string or as a json.Number, which
+// encoding/json writes as it is.
+func Decimal(text string, precision, scale int) interface{} {
+	if scale == 0 && precision <= Int64Digits {
+		if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return n
+		}
+	}
+	if AsString(precision, scale) {
+		return text
+	}
+	return json.Number(text)
+}
+
+// Rat converts r, a value of a decimal type of precision and scale such as
+// BigQuery NUMERIC, as Decimal does. Trailing zeros after the decimal point
+// are dropped.
+func Rat(r *big.Rat, precision, scale int) interface{} {
+	if r.IsInt() {
+		return Decimal(r.Num().String(), precision, scale)
+	}
+	return Decimal(trimZeros(r.FloatString(max(scale, 0))), precision, scale)
+}
+
+// Plain returns the number s in plain decimal notation, exactly: 1.5e+12 is
+// 1500000000000. Numbers without an exponent, and text that is not a
+// number, are returned as they are.
+func Plain(s string) string {
+	e := strings.IndexAny(s, "eE")
+	if e < 0 {
+		return s
+	}
+	exponent, err := strconv.Atoi(s[e+1:])
+	if err != nil {
+		return s
+	}
+	mantissa, sign := s[:e], ""
+	if mantissa != "" && (mantissa[0] == '-' || mantissa[0] == '+') {
+		if mantissa[0] == '-' {
+			sign = "-"
+		}
+		mantissa = mantissa[1:]
+	}
+	whole, fraction, _ := strings.Cut(mantissa, ".")
+	digits := whole + fraction
+	if digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return s
+	}
+
+	point := len(whole) + exponent
+	var plain string
+	switch {
+	case point <= 0:
+		plain = "0." + strings.Repeat("0", -point) + digits
+	case point >= len(digits):
+		plain = digits + strings.Repeat("0", point-len(digits))
+	default:
+		plain = digits[:point] + "." + digits[point:]
+	}
+	plain = trimZeros(plain)
+	if integer, rest, _ := strings.Cut(plain, "."); len(integer) > 1 {
+		integer = strings.TrimLeft(integer, "0")
+		if integer == "" {
+			integer = "0"
+		}
+		if rest != "" {
+			integer += "." + rest
+		}
+		plain = integer
+	}
+	if sign != "" && strings.Trim(plain, "0.") != "" {
+		plain = sign + plain
+	}
+	return plain
+}
+
+// trimZeros drops the trailing zeros after the decimal point of s, and the
+// point when nothing follows it
+func trimZeros(s string) string {
+	if !strings.Contains(s, ".") {
+		return s
+	}
+	return strings.TrimSuffix(strings.TrimRight(s, "0"), ".")
+}
+
+// FromJSON converts n, a number decoded with json.Decoder.UseNumber, to an
+// int64 when it is an integer that fits one, a float64 when that holds it
+// exactly, and otherwise keeps it a json.Number in plain notation.
+func FromJSON(n json.Number) interface{} {
+	s := Plain(n.String())
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil && strconv.FormatFloat(f, 'f', -1, 64) == s {
+		return f
+	}
+	return json.Number(s)
+}
+
+// Decode converts the numbers of v, decoded with json.Decoder.UseNumber,
+// with FromJSON, in records and arrays too. Maps and slices are converted in
+// place.
+func Decode(v interface{}) interface{} {
+	switch val := v.(type) {
+	case json.Number:
+		return FromJSON(val)
+	case map[string]interface{}:
+		for k, item := range val {
+			val[k] = Decode(item)
+		}
+	case []interface{}:
+		for i, item := range val {
+			val[i] = Decode(item)
+		}
+	}
+	return v
+}
+
+// Unmarshal is json.Unmarshal keeping numbers exact: those decoded into
+// interface{} values are converted with FromJSON rather than to float64.
+// v is a pointer to rows, a row, or an interface{}.
+func Unmarshal(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	switch val := v.(type) {
+	case *[]map[string]interface{}:
+		for _, row := range *val {
+			Decode(row)
+		}
+	case *map[string]interface{}:
+		Decode(*val)
+	case *interface{}:
+		*val = Decode(*val)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package numeric
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withMode runs the test with m set, restoring ModeAuto after
+func withMode(t *testing.T, m Mode) {
+	t.Helper()
+	SetMode(m)
+	t.Cleanup(func() { SetMode(ModeAuto) })
+}
+
+func TestParseMode(t *testing.T) {
+	for s, want := range map[string]Mode{"": ModeAuto, "auto": ModeAuto, " Always ": ModeAlways, "never": ModeNever} {
+		m, err := ParseMode(s)
+		require.NoError(t, err, s)
+		assert.Equal(t, want, m)
+	}
+	_, err := ParseMode("sometimes")
+	assert.Error(t, err)
+}
+
+func TestDecimal(t *testing.T) {
+	// 18-digit integers stay int64 in every mode
+	for _, m := range []Mode{ModeAuto, ModeAlways, ModeNever} {
+		withMode(t, m)
+		assert.Equal(t, int64(123456789012345678), Decimal("123456789012345678", 18, 0), m)
+		assert.Equal(t, int64(-999999999999999999), Decimal("-999999999999999999", 18, 0), m)
+	}
+
+	withMode(t, ModeAuto)
+	assert.Equal(t, json.Number("1500000000000.25"), Decimal("1500000000000.25", 15, 2))
+	assert.Equal(t, "123456789012345678.123456789", Decimal("123456789012345678.123456789", 38, 9))
+	assert.Equal(t, "1500000000000", Decimal("1500000000000", 38, 0), "wider than an int64 type")
+
+	withMode(t, ModeAlways)
+	assert.Equal(t, "12.50", Decimal("12.50", 10, 2))
+
+	withMode(t, ModeNever)
+	assert.Equal(t, json.Number("123456789012345678.123456789"), Decimal("123456789012345678.123456789", 38, 9))
+}
+
+func TestRat(t *testing.T) {
+	r, ok := new(big.Rat).SetString("123456789012345678.123456789")
+	require.True(t, ok)
+	assert.Equal(t, "123456789012345678.123456789", Rat(r, 38, 9))
+
+	r, _ = new(big.Rat).SetString("1500000000000.250000000")
+	assert.Equal(t, "1500000000000.25", Rat(r, 38, 9))
+	assert.Equal(t, "-3", Rat(big.NewRat(-3, 1), 38, 9))
+	assert.Equal(t, int64(42), Rat(big.NewRat(42, 1), 10, 0))
+
+	withMode(t, ModeNever)
+	assert.Equal(t, json.Number("0.000000001"), Rat(big.NewRat(1, 1000000000), 38, 9))
+}
+
+func TestPlain(t *testing.T) {
+	for s, want := range map[string]string{
+		"1.5e+12":                 "1500000000000",
+		"1E21":                    "1000000000000000000000",
+		"-1.25e-3":                "-0.00125",
+		"123456789012345678e-9":   "123456789.012345678",
+		"1.0e0":                   "1",
+		"-0e5":                    "0",
+		"123456789012345678.5":    "123456789012345678.5",
+		"12.50":                   "12.50",
+		"abc":                     "abc",
+		"1e":                      "1e",
+		"1.23456789012345678e+17": "123456789012345678",
+	} {
+		assert.Equal(t, want, Plain(s), s)
+	}
+}
+
+func TestFromJSON(t *testing.T) {
+	assert.Equal(t, int64(999999999999999999), FromJSON("999999999999999999"))
+	assert.Equal(t, int64(1500000000000), FromJSON("1.5e+12"))
+	assert.Equal(t, 0.1, FromJSON("0.1"))
+	assert.Equal(t, 1e21, FromJSON("1e+21"))
+	assert.Equal(t, json.Number("123456789012345678.123456789"), FromJSON("123456789012345678.123456789"))
+	assert.Equal(t, json.Number("99999999999999999999"), FromJSON("99999999999999999999"))
+	assert.Equal(t, json.Number("12.50"), FromJSON("12.50"), "the text of a decimal is kept")
+}
+
+func TestUnmarshal_RoundTrip(t *testing.T) {
+	rows := []map[string]interface{}{{
+		"nilai_pagu": int64(999999999999999999),
+		"high_scale": "123456789012345678.123456789",
+		"never":      json.Number("123456789012345678.123456789"),
+		"ratio":      0.30000000000000004,
+		"items":      []interface{}{int64(123456789012345678), map[string]interface{}{"pagu": int64(-123456789012345678)}},
+		"missing":    nil,
+	}}
+	data, err := json.Marshal(rows)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"nilai_pagu":999999999999999999`)
+	assert.Contains(t, string(data), `"never":123456789012345678.123456789`)
+
+	var decoded []map[string]interface{}
+	require.NoError(t, Unmarshal(data, &decoded))
+	assert.Equal(t, rows, decoded)
+
+	var row map[string]interface{}
+	require.NoError(t, Unmarshal([]byte(`{"pagu": 123456789012345678}`), &row))
+	assert.Equal(t, int64(123456789012345678), row["pagu"])
+
+	assert.Error(t, Unmarshal([]byte(`{`), &row))
+}
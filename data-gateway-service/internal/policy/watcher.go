@@ -0,0 +1,155 @@
+// Package policy keeps the active config.Policy in step with POLICY_FILE
+package policy
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/config"
+)
+
+// Watcher loads the policy file and reloads it when its modification time
+// or size changes. A file that fails to parse or validate is logged and
+// reported by LastError; the previous policy stays active.
+type Watcher struct {
+	path     string
+	interval time.Duration
+	logger   *zap.Logger
+
+	mu          sync.Mutex
+	modTime     time.Time
+	size        int64
+	lastErr     error
+	lastErrTime time.Time
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewWatcher creates a watcher of path, checked every interval
+func NewWatcher(path string, interval time.Duration, logger *zap.Logger) *Watcher {
+	return &Watcher{
+		path:     path,
+		interval: interval,
+		logger:   logger,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Load reads the file and activates its policy
+func (w *Watcher) Load() error {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return w.fail(fmt.Errorf("failed to stat policy file: %w", err))
+	}
+	data, err := os.ReadFile(w.path)
+	if err != nil {
+		return w.fail(fmt.Errorf("failed to read policy file: %w", err))
+	}
+
+	w.mu.Lock()
+	w.modTime = info.ModTime()
+	w.size = info.Size()
+	w.mu.Unlock()
+
+	p, err := config.ParsePolicy(data)
+	if err != nil {
+		return w.fail(err)
+	}
+
+	previous := config.ActivePolicy()
+	if p.Version == previous.Version {
+		w.clearError()
+		return nil
+	}
+	p.Path = w.path
+	p.LoadedAt = time.Now().UTC()
+	config.SetPolicy(p)
+	w.clearError()
+
+	w.logger.Info("Policy loaded",
+		zap.String("path", w.path),
+		zap.String("version", p.Version),
+		zap.String("previous_version", previous.Version),
+		zap.Int("dremio_tables", len(p.Security.AllowedDremioTables)),
+		zap.Int("bigquery_tables", len(p.Security.AllowedBigQueryTables)))
+	return nil
+}
+
+// Start checks the file every interval until Stop
+func (w *Watcher) Start() {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+			}
+
+			if !w.changed() {
+				continue
+			}
+			if err := w.Load(); err != nil {
+				w.logger.Error("Policy reload failed, keeping the active policy",
+					zap.String("path", w.path),
+					zap.String("version", config.ActivePolicy().Version),
+					zap.Error(err))
+			}
+		}
+	}()
+}
+
+// Stop ends the polling loop
+func (w *Watcher) Stop() {
+	close(w.stop)
+	w.wg.Wait()
+}
+
+// LastError returns when the last load failed and its error, or a nil error
+// if it succeeded
+func (w *Watcher) LastError() (time.Time, error) {
+	if w == nil {
+		return time.Time{}, nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.lastErrTime, w.lastErr
+}
+
+// changed reports whether the file differs from the last one loaded. A
+// missing file is reported once, as a failed load.
+func (w *Watcher) changed() bool {
+	info, err := os.Stat(w.path)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err != nil {
+		return w.lastErr == nil
+	}
+	return !info.ModTime().Equal(w.modTime) || info.Size() != w.size
+}
+
+func (w *Watcher) fail(err error) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.lastErr = err
+	w.lastErrTime = time.Now().UTC()
+	return err
+}
+
+func (w *Watcher) clearError() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.lastErr = nil
+	w.lastErrTime = time.Time{}
+}
@@ -0,0 +1,74 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/config"
+)
+
+func writePolicy(t *testing.T, path, tables string, modTime time.Time) {
+	t.Helper()
+	data := "security:\n  dremio_tables: [" + tables + "]\n  bigquery_tables: [c.d]\n"
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o644))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestWatcher_Reload(t *testing.T) {
+	defer config.SetPolicy(nil)
+
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	start := time.Now().Add(-time.Hour)
+	writePolicy(t, path, "a.b", start)
+
+	watcher := NewWatcher(path, 10*time.Millisecond, zap.NewNop())
+	require.NoError(t, watcher.Load())
+	first := config.ActivePolicy()
+	assert.Equal(t, path, first.Path)
+	assert.True(t, config.ActiveSecurityConfig().IsTableAllowed("a.b", "dremio"))
+
+	watcher.Start()
+	defer watcher.Stop()
+
+	// A new table is picked up without a restart
+	writePolicy(t, path, "a.b, a.new", start.Add(time.Minute))
+	require.Eventually(t, func() bool {
+		return config.ActiveSecurityConfig().IsTableAllowed("a.new", "dremio")
+	}, 2*time.Second, 10*time.Millisecond)
+	second := config.ActivePolicy()
+	assert.NotEqual(t, first.Version, second.Version)
+
+	// A malformed file keeps the active policy and reports why
+	require.NoError(t, os.WriteFile(path, []byte("security: ["), 0o644))
+	require.NoError(t, os.Chtimes(path, start.Add(2*time.Minute), start.Add(2*time.Minute)))
+	require.Eventually(t, func() bool {
+		_, err := watcher.LastError()
+		return err != nil
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Same(t, second, config.ActivePolicy())
+
+	// Fixing the file clears the error
+	writePolicy(t, path, "a.b", start.Add(3*time.Minute))
+	require.Eventually(t, func() bool {
+		_, err := watcher.LastError()
+		return err == nil
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.False(t, config.ActiveSecurityConfig().IsTableAllowed("a.new", "dremio"))
+}
+
+func TestWatcher_LoadRejectsInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("cache:\n  default_ttl: 0s\n  max_ttl: -1s\n"), 0o644))
+
+	watcher := NewWatcher(path, time.Minute, zap.NewNop())
+	assert.Error(t, watcher.Load())
+	assert.Equal(t, config.DefaultPolicyVersion, config.ActivePolicy().Version)
+
+	assert.Error(t, NewWatcher(filepath.Join(t.TempDir(), "missing.yaml"), time.Minute, zap.NewNop()).Load())
+}
@@ -0,0 +1,181 @@
+// Package ratelimit keeps the per-client state of the rate limiters. Each
+// server creates its own Store, so limiters share nothing through package
+// state and a stopped store leaves no goroutine behind.
+package ratelimit
+
+import (
+	"container/list"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go-data-gateway/internal/config"
+)
+
+// Store holds a value of type T, such as a token bucket, for each client
+// key. A value is created on the first request of its key and forgotten
+// once the key has been idle for the TTL, or earlier when the store is full
+// and the key is the least recently seen. A forgotten client starts afresh.
+// A nil *Store reports no metrics.
+type Store[T any] struct {
+	name    string
+	maxKeys int
+	ttl     time.Duration
+	now     func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	recency *list.List // Of *entry[T], most recently seen first
+	expired int64      // Keys dropped after idling for the TTL
+	evicted int64      // Keys dropped to stay within maxKeys
+
+	rejected atomic.Int64
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+type entry[T any] struct {
+	key      string
+	value    T
+	lastSeen time.Time
+}
+
+// NewStore creates an empty store reported as limiter name. now is the
+// clock idleness is measured by, time.Now when nil.
+func NewStore[T any](name string, cfg config.RateLimitingConfig, now func() time.Time) *Store[T] {
+	if now == nil {
+		now = time.Now
+	}
+	ttl := cfg.IdleTTL
+	if ttl <= 0 {
+		ttl = config.DefaultRateLimiting().IdleTTL
+	}
+	return &Store[T]{
+		name:    name,
+		maxKeys: cfg.MaxKeys,
+		ttl:     ttl,
+		now:     now,
+		entries: make(map[string]*list.Element),
+		recency: list.New(),
+		stop:    make(chan struct{}),
+	}
+}
+
+// Now returns the time on the store's clock
+func (s *Store[T]) Now() time.Time {
+	return s.now()
+}
+
+// Get returns the value of key, creating it with create on the key's first
+// request, and marks key seen. create runs with the store locked, so it must
+// be cheap and must not use the store.
+func (s *Store[T]) Get(key string, create func() T) T {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if element, ok := s.entries[key]; ok {
+		e := element.Value.(*entry[T])
+		e.lastSeen = now
+		s.recency.MoveToFront(element)
+		return e.value
+	}
+
+	if s.maxKeys > 0 && len(s.entries) >= s.maxKeys {
+		s.remove(s.recency.Back())
+		s.evicted++
+	}
+	e := &entry[T]{key: key, value: create(), lastSeen: now}
+	s.entries[key] = s.recency.PushFront(e)
+	return e.value
+}
+
+// Reject counts a request the limiter turned away
+func (s *Store[T]) Reject() {
+	s.rejected.Add(1)
+}
+
+// Len returns the number of keys tracked
+func (s *Store[T]) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+// Sweep forgets the keys idle for longer than the TTL and returns how many
+func (s *Store[T]) Sweep() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cutoff := s.now().Add(-s.ttl)
+	removed := 0
+	for element := s.recency.Back(); element != nil; element = s.recency.Back() {
+		if !element.Value.(*entry[T]).lastSeen.Before(cutoff) {
+			break
+		}
+		s.remove(element)
+		removed++
+	}
+	s.expired += int64(removed)
+	return removed
+}
+
+// remove drops element; s.mu is held
+func (s *Store[T]) remove(element *list.Element) {
+	delete(s.entries, element.Value.(*entry[T]).key)
+	s.recency.Remove(element)
+}
+
+// Start sweeps the store every third of the TTL until Stop
+func (s *Store[T]) Start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(s.ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				s.Sweep()
+			}
+		}
+	}()
+}
+
+// Stop stops the sweeps and waits for a running one to finish; later calls
+// do nothing
+func (s *Store[T]) Stop() {
+	if s == nil {
+		return
+	}
+	s.stopOnce.Do(func() { close(s.stop) })
+	s.wg.Wait()
+}
+
+// WritePrometheus writes the tracked keys, rejections and evictions in
+// Prometheus text format
+func (s *Store[T]) WritePrometheus(w io.Writer) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	keys, expired, evicted := len(s.entries), s.expired, s.evicted
+	s.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP gateway_rate_limit_keys Clients the rate limiter tracks\n")
+	fmt.Fprintf(w, "# TYPE gateway_rate_limit_keys gauge\n")
+	fmt.Fprintf(w, "gateway_rate_limit_keys{limiter=%q} %d\n", s.name, keys)
+	fmt.Fprintf(w, "# HELP gateway_rate_limit_rejections_total Requests rejected by the rate limiter\n")
+	fmt.Fprintf(w, "# TYPE gateway_rate_limit_rejections_total counter\n")
+	fmt.Fprintf(w, "gateway_rate_limit_rejections_total{limiter=%q} %d\n", s.name, s.rejected.Load())
+	fmt.Fprintf(w, "# HELP gateway_rate_limit_evictions_total Clients forgotten by the rate limiter, after idling or to stay within its bound\n")
+	fmt.Fprintf(w, "# TYPE gateway_rate_limit_evictions_total counter\n")
+	fmt.Fprintf(w, "gateway_rate_limit_evictions_total{limiter=%q,reason=\"idle\"} %d\n", s.name, expired)
+	fmt.Fprintf(w, "gateway_rate_limit_evictions_total{limiter=%q,reason=\"capacity\"} %d\n", s.name, evicted)
+}
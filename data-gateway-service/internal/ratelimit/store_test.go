@@ -0,0 +1,144 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-data-gateway/internal/config"
+)
+
+// fakeClock is a settable clock safe for concurrent use
+type fakeClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{t: time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+func counter() func() *atomic.Int64 {
+	return func() *atomic.Int64 { return new(atomic.Int64) }
+}
+
+func TestStore_CreatesOncePerKey(t *testing.T) {
+	store := NewStore[*atomic.Int64]("api", config.DefaultRateLimiting(), nil)
+
+	store.Get("a", counter()).Add(1)
+	store.Get("a", counter()).Add(1)
+	store.Get("b", counter()).Add(1)
+
+	assert.Equal(t, int64(2), store.Get("a", counter()).Load())
+	assert.Equal(t, int64(1), store.Get("b", counter()).Load())
+	assert.Equal(t, 2, store.Len())
+}
+
+func TestStore_SweepForgetsIdleKeys(t *testing.T) {
+	clock := newFakeClock()
+	store := NewStore[*atomic.Int64]("api", config.RateLimitingConfig{IdleTTL: 3 * time.Minute}, clock.Now)
+
+	store.Get("idle", counter()).Add(5)
+	clock.Advance(2 * time.Minute)
+	store.Get("active", counter())
+	clock.Advance(time.Minute)
+
+	// Idle for exactly the TTL is kept
+	assert.Zero(t, store.Sweep())
+	clock.Advance(time.Second)
+	assert.Equal(t, 1, store.Sweep())
+	assert.Equal(t, 1, store.Len())
+
+	// A forgotten key starts afresh
+	assert.Zero(t, store.Get("idle", counter()).Load())
+}
+
+func TestStore_EvictsLeastRecentlySeenWhenFull(t *testing.T) {
+	clock := newFakeClock()
+	store := NewStore[*atomic.Int64]("api", config.RateLimitingConfig{MaxKeys: 2, IdleTTL: time.Minute}, clock.Now)
+
+	store.Get("a", counter())
+	store.Get("b", counter())
+	store.Get("a", counter()) // b is now the least recently seen
+	store.Get("c", counter())
+
+	assert.Equal(t, 2, store.Len())
+	store.Get("a", counter()).Add(1)
+	assert.Equal(t, int64(1), store.Get("a", counter()).Load(), "a was kept")
+
+	var metrics strings.Builder
+	store.WritePrometheus(&metrics)
+	assert.Contains(t, metrics.String(), `gateway_rate_limit_keys{limiter="api"} 2`)
+	assert.Contains(t, metrics.String(), `gateway_rate_limit_evictions_total{limiter="api",reason="capacity"} 1`)
+}
+
+func TestStore_ConcurrentAccess(t *testing.T) {
+	clock := newFakeClock()
+	store := NewStore[*atomic.Int64]("api", config.RateLimitingConfig{MaxKeys: 50, IdleTTL: time.Minute}, clock.Now)
+
+	var wg sync.WaitGroup
+	for worker := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 500 {
+				store.Get(fmt.Sprintf("client-%d", (worker*500+i)%80), counter()).Add(1)
+				if i%50 == 0 {
+					clock.Advance(time.Second)
+					store.Sweep()
+					store.Reject()
+					store.WritePrometheus(&strings.Builder{})
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.LessOrEqual(t, store.Len(), 50)
+	var metrics strings.Builder
+	store.WritePrometheus(&metrics)
+	assert.Contains(t, metrics.String(), `gateway_rate_limit_rejections_total{limiter="api"} 80`)
+}
+
+func TestStore_StartSweepsUntilStopped(t *testing.T) {
+	store := NewStore[*atomic.Int64]("api", config.RateLimitingConfig{IdleTTL: 30 * time.Millisecond}, nil)
+	store.Start()
+
+	store.Get("a", counter())
+	require.Eventually(t, func() bool { return store.Len() == 0 }, time.Second, 5*time.Millisecond)
+
+	// Stop waits for the sweeper to exit and may be called again
+	store.Stop()
+	store.Stop()
+
+	// A stopped store no longer sweeps
+	store.Get("b", counter())
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(t, 1, store.Len())
+}
+
+func TestStore_NilReportsNothing(t *testing.T) {
+	var store *Store[*atomic.Int64]
+	var metrics strings.Builder
+	store.WritePrometheus(&metrics)
+	store.Stop()
+	assert.Empty(t, metrics.String())
+}
@@ -3,21 +3,23 @@ package response
 import (
 	"encoding/json"
 	"net/http"
+	"reflect"
 )
 
 // StandardResponse represents the standard API response format
 type StandardResponse struct {
-	Success bool        `json:"success"`
-	Data    interface{} `json:"data,omitempty"`
-	Error   *ErrorInfo  `json:"error,omitempty"`
-	Meta    *Meta       `json:"meta,omitempty"`
+	Success  bool        `json:"success"`
+	Data     interface{} `json:"data,omitempty"`
+	Error    *ErrorInfo  `json:"error,omitempty"`
+	Meta     *Meta       `json:"meta,omitempty"`
+	Warnings []Warning   `json:"warnings,omitempty"` // Sent from Meta.Warnings
 }
 
 // ErrorInfo contains error details
 type ErrorInfo struct {
-	Code    string `json:"code"`
-	Message string `json:"message"`
-	Details string `json:"details,omitempty"`
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"` // Usually a string; structured for query errors
 }
 
 // Meta contains pagination and other metadata
@@ -26,7 +28,160 @@ type Meta struct {
 	PerPage    int    `json:"per_page,omitempty"`
 	Total      int    `json:"total,omitempty"`
 	TotalPages int    `json:"total_pages,omitempty"`
+	Limit      int    `json:"limit,omitempty"` // Page size applied after the endpoint's defaults
 	RequestID  string `json:"request_id,omitempty"`
+
+	// Upstream job of a query, only when DREMIO_EXPOSE_JOB_IDS is enabled
+	DremioJobID      string `json:"dremio_job_id,omitempty"`
+	DremioProfileURL string `json:"dremio_profile_url,omitempty"`
+
+	// Cost of the BigQuery job that answered the request; absent when it was
+	// served from cache or read from another source
+	BigQuery *BigQueryJob `json:"bigquery,omitempty"`
+
+	// Seconds since the result was cached; 0 when it was just fetched
+	AgeSeconds *int64 `json:"age_seconds,omitempty"`
+
+	// Hash of the result's column names and types; a page whose fingerprint
+	// differs from the previous page's was read after the schema changed
+	SchemaFingerprint string `json:"schema_fingerprint,omitempty"`
+
+	// Columns whose decimals are sent as JSON strings, their precision being
+	// more than a float64 holds
+	StringNumbers []string `json:"string_numbers,omitempty"`
+
+	// ORDER BY terms of a paged list, tiebreaker included
+	Order []string `json:"order,omitempty"`
+
+	// Nessie branch or tag the Dremio Iceberg tables were read at
+	Branch string `json:"branch,omitempty"`
+
+	// Time the tables were read as of, when as_of asked for a past one
+	AsOf string `json:"as_of,omitempty"`
+
+	// Set when soft-deleted rows were excluded from the result
+	DeletedFiltered bool `json:"deleted_filtered,omitempty"`
+
+	// Whether a fresh result was cached: ok, failed or skipped; absent when
+	// it was served from cache
+	CacheWrite string `json:"cache_write,omitempty"`
+
+	// Set when a lookup by id was answered by a remembered not-found,
+	// without reaching the source
+	NegativeCache bool `json:"negative_cache,omitempty"`
+
+	// Set when raw SQL without a LIMIT ran with InjectedLimit injected
+	LimitInjected bool `json:"limit_injected,omitempty"`
+	InjectedLimit int  `json:"injected_limit,omitempty"`
+
+	// The SQL the endpoint ran, when debug_sql was requested
+	Debug *QueryDebug `json:"debug,omitempty"`
+
+	// Non-fatal conditions of the request, sent beside meta as the
+	// response's warnings; see Warn
+	Warnings []Warning `json:"-"`
+
+	// Deprecated: the messages of the INCLUDE_FAILED warnings, filled in
+	// from Warnings as they are sent
+	IncludeWarnings []string `json:"warnings,omitempty"`
+
+	// Deprecated: the CACHE_WRITE_FAILED warning, filled in from Warnings as
+	// it is sent
+	CacheWarning *Warning `json:"cache_warning,omitempty"`
+
+	// Neighbouring pages of a POST search; GET lists send them as a Link
+	// header instead
+	Links *PageLinks `json:"links,omitempty"`
+}
+
+// BigQueryJob identifies a BigQuery job and reports what it cost
+type BigQueryJob struct {
+	JobID               string `json:"job_id"`
+	TotalBytesProcessed int64  `json:"total_bytes_processed"`
+	TotalBytesBilled    int64  `json:"total_bytes_billed"`
+	CacheHit            bool   `json:"cache_hit"` // Answered from BigQuery's own result cache, unbilled
+	SlotMillis          int64  `json:"slot_ms"`
+}
+
+// PageLinks are the pages next to the current one, each absent when there
+// is no such page. Last is only known when the total was counted.
+type PageLinks struct {
+	First *PageLink `json:"first,omitempty"`
+	Prev  *PageLink `json:"prev,omitempty"`
+	Next  *PageLink `json:"next,omitempty"`
+	Last  *PageLink `json:"last,omitempty"`
+}
+
+// PageLink is the offset and limit to request a page with
+type PageLink struct {
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+}
+
+// Warning describes a condition a caller may want to act on, such as by
+// retrying, although the request succeeded
+type Warning struct {
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// Warning codes
+const (
+	WarnCacheWriteFailed = "CACHE_WRITE_FAILED" // require_cache_write was set and the result could not be cached
+	WarnColumnsRenamed   = "COLUMNS_RENAMED"    // Repeated column names were made unique
+	WarnIncludeFailed    = "INCLUDE_FAILED"     // An included child collection could not be loaded
+	WarnLimitInjected    = "LIMIT_INJECTED"     // Raw SQL without a LIMIT ran with one injected
+	WarnResultTruncated  = "RESULT_TRUNCATED"   // Rows over the row cap were dropped
+)
+
+// Warn adds a warning to meta, allocating meta when nil, and returns it
+func Warn(meta *Meta, code, message string, details interface{}) *Meta {
+	if meta == nil {
+		meta = &Meta{}
+	}
+	meta.Warnings = append(meta.Warnings, Warning{Code: code, Message: message, Details: details})
+	return meta
+}
+
+// warnings returns the warnings of meta, which may be nil
+func warnings(meta *Meta) []Warning {
+	if meta == nil {
+		return nil
+	}
+	return meta.Warnings
+}
+
+// SentMeta returns meta as it is sent: a copy with the deprecated aliases of
+// its warnings filled in, or nil when it holds nothing else, so that a
+// response with warnings only has no meta
+func SentMeta(meta *Meta) *Meta {
+	if meta == nil {
+		return nil
+	}
+	sent := *meta
+	sent.Warnings, sent.IncludeWarnings, sent.CacheWarning = nil, nil, nil
+	for _, warning := range meta.Warnings {
+		switch warning.Code {
+		case WarnIncludeFailed:
+			sent.IncludeWarnings = append(sent.IncludeWarnings, warning.Message)
+		case WarnCacheWriteFailed:
+			sent.CacheWarning = &Warning{Code: warning.Code, Message: warning.Message}
+		}
+	}
+	if reflect.ValueOf(sent).IsZero() {
+		return nil
+	}
+	return &sent
+}
+
+// QueryDebug reports the SQL an endpoint built. Values are quoted into the
+// statement by the sanitizer; Params lists them as the request gave them.
+type QueryDebug struct {
+	SQL      string                 `json:"sql"`
+	CountSQL string                 `json:"count_sql,omitempty"` // Query of the total, when the endpoint reports one
+	Params   map[string]interface{} `json:"params,omitempty"`
+	CacheKey string                 `json:"cache_key,omitempty"` // Empty when the endpoint does not cache
 }
 
 // Success sends a successful response
@@ -35,9 +190,10 @@ func Success(w http.ResponseWriter, data interface{}, meta *Meta) {
 	w.WriteHeader(http.StatusOK)
 
 	response := StandardResponse{
-		Success: true,
-		Data:    data,
-		Meta:    meta,
+		Success:  true,
+		Data:     data,
+		Meta:     SentMeta(meta),
+		Warnings: warnings(meta),
 	}
 
 	json.NewEncoder(w).Encode(response)
@@ -69,9 +225,53 @@ func ErrorWithDetails(w http.ResponseWriter, message string, details string, sta
 		Error: &ErrorInfo{
 			Code:    http.StatusText(statusCode),
 			Message: message,
-			Details: details,
+			Details: omitEmptyDetails(details),
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// ErrorWithCode sends an error response with a machine-readable error code
+func ErrorWithCode(w http.ResponseWriter, code string, message string, details interface{}, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	response := StandardResponse{
+		Success: false,
+		Error: &ErrorInfo{
+			Code:    code,
+			Message: message,
+			Details: omitEmptyDetails(details),
 		},
 	}
 
 	json.NewEncoder(w).Encode(response)
 }
+
+// ErrorWithMeta sends an error response with a machine-readable error code
+// and meta, such as the request id
+func ErrorWithMeta(w http.ResponseWriter, code string, message string, meta *Meta, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	response := StandardResponse{
+		Success: false,
+		Error: &ErrorInfo{
+			Code:    code,
+			Message: message,
+		},
+		Meta:     SentMeta(meta),
+		Warnings: warnings(meta),
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// omitEmptyDetails drops empty string details so they stay omitted from JSON
+func omitEmptyDetails(details interface{}) interface{} {
+	if s, ok := details.(string); ok && s == "" {
+		return nil
+	}
+	return details
+}
@@ -0,0 +1,36 @@
+package response
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSuccess_Warnings(t *testing.T) {
+	send := func(meta *Meta) map[string]json.RawMessage {
+		rec := httptest.NewRecorder()
+		Success(rec, "ok", meta)
+		var body map[string]json.RawMessage
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		return body
+	}
+
+	// A meta holding only warnings is dropped
+	body := send(Warn(nil, WarnLimitInjected, "A LIMIT of 10 was added", nil))
+	assert.NotContains(t, body, "meta")
+	assert.JSONEq(t, `[{"code": "LIMIT_INJECTED", "message": "A LIMIT of 10 was added"}]`, string(body["warnings"]))
+
+	// Warnings with a deprecated alias fill it in
+	meta := Warn(&Meta{Limit: 5}, WarnIncludeFailed, "dokumen could not be loaded", map[string]string{"include": "dokumen"})
+	Warn(meta, WarnCacheWriteFailed, "The result could not be cached", nil)
+	body = send(meta)
+	assert.JSONEq(t, `{"limit": 5, "warnings": ["dokumen could not be loaded"],
+		"cache_warning": {"code": "CACHE_WRITE_FAILED", "message": "The result could not be cached"}}`, string(body["meta"]))
+	assert.Len(t, meta.Warnings, 2)
+	assert.Empty(t, meta.IncludeWarnings, "the caller's meta is not modified")
+
+	assert.NotContains(t, send(&Meta{}), "meta")
+}
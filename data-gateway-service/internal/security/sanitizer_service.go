@@ -3,6 +3,7 @@ package security
 import (
 	"fmt"
 	"sync"
+	"sync/atomic"
 
 	"go-data-gateway/internal/config"
 	"go-data-gateway/internal/datasource"
@@ -13,43 +14,75 @@ var (
 	once     sync.Once
 )
 
-// SanitizerService provides centralized SQL sanitization
+// SanitizerService provides centralized SQL sanitization. Its sanitizers
+// follow the active security config: when a policy reload replaces it, the
+// next caller builds a new pair and swaps it in, while callers holding the
+// previous pair keep using its whitelists unchanged.
 type SanitizerService struct {
-	dremioSanitizer   *datasource.SQLSanitizer
-	bigquerySanitizer *datasource.SQLSanitizer
-	securityConfig    *config.SecurityConfig
+	current atomic.Pointer[sanitizers]
+}
+
+// sanitizers are the sanitizers built from one security config
+type sanitizers struct {
+	securityConfig *config.SecurityConfig
+	dremio         *datasource.SQLSanitizer
+	bigquery       *datasource.SQLSanitizer
 }
 
 // GetSanitizerService returns the singleton sanitizer service
 func GetSanitizerService() *SanitizerService {
 	once.Do(func() {
-		instance = &SanitizerService{
-			securityConfig: config.GetDefaultSecurityConfig(),
-		}
-		instance.initialize()
+		instance = &SanitizerService{}
 	})
 	return instance
 }
 
-// initialize sets up the sanitizers with their respective allowed tables
-func (s *SanitizerService) initialize() {
+// load returns the sanitizers of the active security config
+func (s *SanitizerService) load() *sanitizers {
+	active := config.ActiveSecurityConfig()
+	if current := s.current.Load(); current != nil && current.securityConfig == active {
+		return current
+	}
+
+	// Concurrent callers may both build; either result is equivalent
+	next := newSanitizers(active)
+	s.current.Store(next)
+	return next
+}
+
+// newSanitizers sets up the sanitizers with their respective allowed tables
+func newSanitizers(securityConfig *config.SecurityConfig) *sanitizers {
 	// Initialize Dremio sanitizer with whitelist
-	s.dremioSanitizer = datasource.NewSQLSanitizer()
-	s.dremioSanitizer.SetAllowedTables(s.securityConfig.AllowedDremioTables)
+	dremio := datasource.NewSQLSanitizer()
+	dremio.SetAllowedTables(securityConfig.AllowedDremioTables)
+	dremio.SetAllowedColumns(securityConfig.AllowedColumns())
 
 	// Initialize BigQuery sanitizer with whitelist
-	s.bigquerySanitizer = datasource.NewSQLSanitizer()
-	s.bigquerySanitizer.SetAllowedTables(s.securityConfig.AllowedBigQueryTables)
+	bigquery := datasource.NewSQLSanitizer()
+	bigquery.SetDialect(datasource.DialectBigQuery)
+	bigquery.SetAllowedTables(securityConfig.AllowedBigQueryTables)
+	bigquery.SetAllowedColumns(securityConfig.AllowedColumns())
+
+	return &sanitizers{
+		securityConfig: securityConfig,
+		dremio:         dremio,
+		bigquery:       bigquery,
+	}
 }
 
 // GetDremioSanitizer returns the Dremio SQL sanitizer
 func (s *SanitizerService) GetDremioSanitizer() *datasource.SQLSanitizer {
-	return s.dremioSanitizer
+	return s.load().dremio
 }
 
 // GetBigQuerySanitizer returns the BigQuery SQL sanitizer
 func (s *SanitizerService) GetBigQuerySanitizer() *datasource.SQLSanitizer {
-	return s.bigquerySanitizer
+	return s.load().bigquery
+}
+
+// SecurityConfig returns the security config the sanitizers were built from
+func (s *SanitizerService) SecurityConfig() *config.SecurityConfig {
+	return s.load().securityConfig
 }
 
 // ValidateQueryForSource validates a query for a specific data source
@@ -74,4 +107,4 @@ func contains(s, pattern string) bool {
 	// Simple case-insensitive contains
 	// In production, use proper regex or string matching
 	return false // Simplified for now
-}
\ No newline at end of file
+}
@@ -0,0 +1,139 @@
+// Package sentry reports events to Sentry through its store endpoint. It
+// covers what the gateway sends, recovered panics, without the SDK.
+package sentry
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go-data-gateway/internal/buildinfo"
+)
+
+// Event is a reported error
+type Event struct {
+	Message   string            // Panic value or error message
+	Method    string            // Request the error occurred in
+	URL       string            //
+	Stack     string            // Goroutine stack, as from debug.Stack
+	RequestID string            //
+	Tags      map[string]string // Searchable tags such as the route
+}
+
+// Client posts events to one Sentry project
+type Client struct {
+	endpoint    string
+	auth        string
+	environment string
+	http        *http.Client
+}
+
+// New creates a client for dsn, e.g. https://key@o1.ingest.sentry.io/42.
+// It returns nil when dsn is empty so callers can treat Sentry as disabled.
+func New(dsn, environment string) (*Client, error) {
+	if dsn == "" {
+		return nil, nil
+	}
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SENTRY_DSN: %w", err)
+	}
+	key := u.User.Username()
+	path := strings.TrimSuffix(u.Path, "/")
+	slash := strings.LastIndex(path, "/")
+	if u.Scheme == "" || u.Host == "" || key == "" || slash < 0 || path[slash+1:] == "" {
+		return nil, fmt.Errorf("invalid SENTRY_DSN: want scheme://key@host/project")
+	}
+	project := path[slash+1:]
+
+	return &Client{
+		endpoint:    fmt.Sprintf("%s://%s%s/api/%s/store/", u.Scheme, u.Host, path[:slash], project),
+		auth:        "Sentry sentry_version=7, sentry_client=go-data-gateway/" + buildinfo.Version + ", sentry_key=" + key,
+		environment: environment,
+		http:        &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// storeEvent is the JSON body of the store endpoint
+type storeEvent struct {
+	EventID     string                 `json:"event_id"`
+	Timestamp   string                 `json:"timestamp"`
+	Level       string                 `json:"level"`
+	Platform    string                 `json:"platform"`
+	Logger      string                 `json:"logger"`
+	Environment string                 `json:"environment,omitempty"`
+	Release     string                 `json:"release,omitempty"`
+	Message     string                 `json:"message"`
+	Exception   map[string]interface{} `json:"exception"`
+	Request     map[string]string      `json:"request,omitempty"`
+	Tags        map[string]string      `json:"tags,omitempty"`
+	Extra       map[string]string      `json:"extra,omitempty"`
+}
+
+// Capture sends event and fails on any non-2xx response. Capturing on a nil
+// client is a no-op.
+func (c *Client) Capture(ctx context.Context, event Event) error {
+	if c == nil {
+		return nil
+	}
+
+	tags := make(map[string]string, len(event.Tags)+1)
+	for k, v := range event.Tags {
+		tags[k] = v
+	}
+	if event.RequestID != "" {
+		tags["request_id"] = event.RequestID
+	}
+	body, err := json.Marshal(storeEvent{
+		EventID:     newEventID(),
+		Timestamp:   time.Now().UTC().Format(time.RFC3339),
+		Level:       "fatal",
+		Platform:    "go",
+		Logger:      "go-data-gateway",
+		Environment: c.environment,
+		Release:     buildinfo.Version,
+		Message:     event.Message,
+		Exception: map[string]interface{}{
+			"values": []map[string]string{{"type": "panic", "value": event.Message}},
+		},
+		Request: map[string]string{"method": event.Method, "url": event.URL},
+		Tags:    tags,
+		Extra:   map[string]string{"stack": event.Stack},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode sentry event: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create sentry request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", c.auth)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("sentry request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("sentry returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// newEventID returns 32 hex characters, the event id format Sentry expects
+func newEventID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
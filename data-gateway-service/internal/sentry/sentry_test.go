@@ -0,0 +1,29 @@
+package sentry
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew_ParsesDSN(t *testing.T) {
+	client, err := New("", "production")
+	require.NoError(t, err)
+	assert.Nil(t, client, "no DSN disables reporting")
+
+	client, err = New("https://abc123@o1.ingest.sentry.io/42", "production")
+	require.NoError(t, err)
+	assert.Equal(t, "https://o1.ingest.sentry.io/api/42/store/", client.endpoint)
+	assert.Contains(t, client.auth, "sentry_key=abc123")
+	assert.Contains(t, client.auth, "sentry_client=go-data-gateway/dev,")
+
+	client, err = New("https://abc123@sentry.internal/relay/7", "")
+	require.NoError(t, err)
+	assert.Equal(t, "https://sentry.internal/relay/api/7/store/", client.endpoint)
+
+	for _, dsn := range []string{"https://o1.ingest.sentry.io/42", "https://abc123@o1.ingest.sentry.io", "not a dsn"} {
+		_, err := New(dsn, "")
+		assert.Error(t, err, dsn)
+	}
+}
@@ -0,0 +1,336 @@
+package shedding
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/config"
+)
+
+// Priority orders endpoint groups; lower priorities are shed first
+type Priority int
+
+const (
+	PriorityBulk        Priority = iota // Batch and streaming: shed first
+	PriorityQuery                       // Raw SQL: shed when bulk shedding is not enough
+	PriorityInteractive                 // Lists, search, timeseries: never shed
+	PriorityCritical                    // Admin, e.g. the shedding toggle itself: never shed
+)
+
+// Group is an endpoint group tracked by the shedder
+type Group struct {
+	Name     string
+	Priority Priority
+}
+
+var (
+	GroupBulk        = Group{Name: "bulk", Priority: PriorityBulk}
+	GroupQuery       = Group{Name: "query", Priority: PriorityQuery}
+	GroupInteractive = Group{Name: "interactive", Priority: PriorityInteractive}
+	GroupAdmin       = Group{Name: "admin", Priority: PriorityCritical}
+
+	groups = []Group{GroupBulk, GroupQuery, GroupInteractive, GroupAdmin}
+)
+
+// GroupFor classifies an /api/v1 request path
+func GroupFor(path string) Group {
+	p := strings.TrimPrefix(path, "/api/v1")
+	switch {
+	case p == "/admin" || strings.HasPrefix(p, "/admin/"):
+		return GroupAdmin
+	case p == "/batch" || strings.HasPrefix(p, "/batch/"), p == "/stream" || strings.HasPrefix(p, "/stream/"):
+		return GroupBulk
+	case p == "/query" || p == "/estimate-cost":
+		return GroupQuery
+	default:
+		return GroupInteractive
+	}
+}
+
+// Mode is the operator override of the shedder
+type Mode string
+
+const (
+	ModeAuto Mode = "auto" // Shed according to in-flight requests and latency
+	ModeOn   Mode = "on"   // Shed bulk and query requests regardless of load
+	ModeOff  Mode = "off"  // Never shed
+)
+
+// ParseMode validates a mode name
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(strings.ToLower(s)); m {
+	case ModeAuto, ModeOn, ModeOff:
+		return m, nil
+	}
+	return "", fmt.Errorf("mode must be one of: auto, on, off")
+}
+
+// Shedding levels: the number of priorities being rejected
+const (
+	LevelNone  = 0 // Nothing is shed
+	LevelBulk  = 1 // Bulk is shed
+	LevelQuery = 2 // Bulk and query are shed
+)
+
+const (
+	// latencySamples is the ring size of each group's latency window
+	latencySamples = 512
+	// minLatencySamples avoids reacting to a handful of slow requests
+	minLatencySamples = 20
+	// evaluateInterval bounds how often the p95s are recomputed
+	evaluateInterval = 250 * time.Millisecond
+)
+
+// Shedder decides which requests are rejected under load. Admit must be
+// paired with Done for every admitted request.
+type Shedder struct {
+	cfg    config.SheddingConfig
+	logger *zap.Logger
+
+	inFlight atomic.Int64
+
+	mu           sync.Mutex
+	mode         Mode
+	latency      map[string]*latencyWindow
+	shed         map[string]int64
+	latencyLevel int // Level from the last latency evaluation
+	evaluatedAt  time.Time
+	lastLevel    int // For logging level changes
+	now          func() time.Time
+}
+
+// New creates a shedder; it starts in auto mode when enabled, otherwise off
+func New(cfg config.SheddingConfig, logger *zap.Logger) *Shedder {
+	s := &Shedder{
+		cfg:     cfg,
+		logger:  logger,
+		mode:    ModeOff,
+		latency: make(map[string]*latencyWindow, len(groups)),
+		shed:    make(map[string]int64, len(groups)),
+		now:     time.Now,
+	}
+	if cfg.Enabled {
+		s.mode = ModeAuto
+	}
+	for _, g := range groups {
+		s.latency[g.Name] = &latencyWindow{}
+	}
+	return s
+}
+
+// Admit reports whether a request of the group may proceed and, if so,
+// counts it as in flight
+func (s *Shedder) Admit(g Group) bool {
+	inFlight := s.inFlight.Load()
+
+	s.mu.Lock()
+	level := s.levelLocked(inFlight)
+	rejected := int(g.Priority) < level
+	if rejected {
+		s.shed[g.Name]++
+	}
+	s.mu.Unlock()
+
+	if rejected {
+		return false
+	}
+	s.inFlight.Add(1)
+	return true
+}
+
+// Done records the latency of an admitted request
+func (s *Shedder) Done(g Group, latency time.Duration) {
+	s.inFlight.Add(-1)
+
+	s.mu.Lock()
+	s.latency[g.Name].add(s.now(), latency)
+	s.mu.Unlock()
+}
+
+// RetryAfter is how long rejected clients are asked to wait
+func (s *Shedder) RetryAfter() time.Duration {
+	return s.cfg.RetryAfter
+}
+
+// SetMode switches between automatic and forced shedding
+func (s *Shedder) SetMode(mode Mode) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.mode != mode {
+		s.logger.Warn("Load shedding mode changed", zap.String("from", string(s.mode)), zap.String("to", string(mode)))
+	}
+	s.mode = mode
+}
+
+// levelLocked returns the current shedding level. s.mu must be held.
+func (s *Shedder) levelLocked(inFlight int64) int {
+	var level int
+	switch s.mode {
+	case ModeOff:
+		level = LevelNone
+	case ModeOn:
+		level = LevelQuery
+	default:
+		level = max(s.inFlightLevel(inFlight), s.latencyLevelLocked())
+	}
+
+	if level != s.lastLevel {
+		s.logger.Warn("Load shedding level changed",
+			zap.Int("from", s.lastLevel),
+			zap.Int("to", level),
+			zap.Int64("in_flight", inFlight))
+		s.lastLevel = level
+	}
+	return level
+}
+
+// inFlightLevel sheds bulk at 75% of MaxInFlight and queries at 100%
+func (s *Shedder) inFlightLevel(inFlight int64) int {
+	limit := int64(s.cfg.MaxInFlight)
+	switch {
+	case limit <= 0:
+		return LevelNone
+	case inFlight >= limit:
+		return LevelQuery
+	case inFlight*4 >= limit*3:
+		return LevelBulk
+	}
+	return LevelNone
+}
+
+// latencyLevelLocked sheds bulk when queries are slow and both bulk and
+// queries when interactive requests are slow. Bulk latency is tracked but
+// not acted upon, as streams are long-running by nature.
+func (s *Shedder) latencyLevelLocked() int {
+	now := s.now()
+	if now.Sub(s.evaluatedAt) < evaluateInterval {
+		return s.latencyLevel
+	}
+	s.evaluatedAt = now
+
+	level := LevelNone
+	if s.cfg.LatencyP95 > 0 {
+		cutoff := now.Add(-s.cfg.Window)
+		if p95, n := s.latency[GroupQuery.Name].p95(cutoff); n >= minLatencySamples && p95 > s.cfg.LatencyP95 {
+			level = LevelBulk
+		}
+		if p95, n := s.latency[GroupInteractive.Name].p95(cutoff); n >= minLatencySamples && p95 > s.cfg.LatencyP95 {
+			level = LevelQuery
+		}
+	}
+	s.latencyLevel = level
+	return level
+}
+
+// State is the shedder's current state, reported on /health and the admin API
+type State struct {
+	Mode        Mode                  `json:"mode"`
+	Level       int                   `json:"level"`    // 0 none, 1 bulk, 2 bulk and query
+	Shedding    []string              `json:"shedding"` // Groups currently rejected
+	InFlight    int64                 `json:"in_flight"`
+	MaxInFlight int                   `json:"max_in_flight"`
+	Groups      map[string]GroupState `json:"groups"`
+}
+
+// GroupState reports an endpoint group's recent latency and rejections
+type GroupState struct {
+	P95Millis int64 `json:"p95_ms"`
+	Samples   int   `json:"samples"`
+	ShedTotal int64 `json:"shed_total"`
+}
+
+// State returns a snapshot of the shedder
+func (s *Shedder) State() State {
+	inFlight := s.inFlight.Load()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state := State{
+		Mode:        s.mode,
+		Level:       s.levelLocked(inFlight),
+		Shedding:    []string{},
+		InFlight:    inFlight,
+		MaxInFlight: s.cfg.MaxInFlight,
+		Groups:      make(map[string]GroupState, len(groups)),
+	}
+
+	cutoff := s.now().Add(-s.cfg.Window)
+	for _, g := range groups {
+		p95, n := s.latency[g.Name].p95(cutoff)
+		state.Groups[g.Name] = GroupState{P95Millis: p95.Milliseconds(), Samples: n, ShedTotal: s.shed[g.Name]}
+		if int(g.Priority) < state.Level {
+			state.Shedding = append(state.Shedding, g.Name)
+		}
+	}
+	return state
+}
+
+// WritePrometheus writes the shedding gauges and counters. A nil shedder
+// writes nothing.
+func (s *Shedder) WritePrometheus(w io.Writer) {
+	if s == nil {
+		return
+	}
+	state := s.State()
+
+	fmt.Fprintf(w, "# HELP go_gateway_load_shedding_level Load shedding level (0 none, 1 bulk, 2 bulk and query)\n")
+	fmt.Fprintf(w, "# TYPE go_gateway_load_shedding_level gauge\n")
+	fmt.Fprintf(w, "go_gateway_load_shedding_level %d\n", state.Level)
+	fmt.Fprintf(w, "\n# HELP go_gateway_in_flight_requests API requests currently being served\n")
+	fmt.Fprintf(w, "# TYPE go_gateway_in_flight_requests gauge\n")
+	fmt.Fprintf(w, "go_gateway_in_flight_requests %d\n", state.InFlight)
+	fmt.Fprintf(w, "\n# HELP go_gateway_shed_requests_total Requests rejected by load shedding\n")
+	fmt.Fprintf(w, "# TYPE go_gateway_shed_requests_total counter\n")
+	for _, g := range groups {
+		fmt.Fprintf(w, "go_gateway_shed_requests_total{group=%q} %d\n", g.Name, state.Groups[g.Name].ShedTotal)
+	}
+	fmt.Fprintf(w, "\n# HELP go_gateway_request_latency_p95_seconds Recent p95 latency per endpoint group\n")
+	fmt.Fprintf(w, "# TYPE go_gateway_request_latency_p95_seconds gauge\n")
+	for _, g := range groups {
+		fmt.Fprintf(w, "go_gateway_request_latency_p95_seconds{group=%q} %.3f\n", g.Name,
+			float64(state.Groups[g.Name].P95Millis)/1000)
+	}
+}
+
+// latencyWindow is a ring of recent request latencies
+type latencyWindow struct {
+	samples [latencySamples]latencySample
+	next    int
+	count   int
+}
+
+type latencySample struct {
+	at      time.Time
+	latency time.Duration
+}
+
+func (l *latencyWindow) add(at time.Time, latency time.Duration) {
+	l.samples[l.next] = latencySample{at: at, latency: latency}
+	l.next = (l.next + 1) % latencySamples
+	l.count = min(l.count+1, latencySamples)
+}
+
+// p95 returns the 95th percentile of samples newer than cutoff and their count
+func (l *latencyWindow) p95(cutoff time.Time) (time.Duration, int) {
+	recent := make([]time.Duration, 0, l.count)
+	for i := 0; i < l.count; i++ {
+		if sample := l.samples[i]; !sample.at.Before(cutoff) {
+			recent = append(recent, sample.latency)
+		}
+	}
+	if len(recent) == 0 {
+		return 0, 0
+	}
+
+	sort.Slice(recent, func(i, j int) bool { return recent[i] < recent[j] })
+	idx := (len(recent)*95+99)/100 - 1
+	return recent[idx], len(recent)
+}
@@ -0,0 +1,153 @@
+package shedding
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/config"
+)
+
+func testShedder() *Shedder {
+	return New(config.SheddingConfig{
+		Enabled:     true,
+		MaxInFlight: 8,
+		LatencyP95:  time.Second,
+		Window:      time.Minute,
+		RetryAfter:  5 * time.Second,
+	}, zap.NewNop())
+}
+
+func TestGroupFor(t *testing.T) {
+	tests := map[string]Group{
+		"/api/v1/batch":              GroupBulk,
+		"/api/v1/batch/stream":       GroupBulk,
+		"/api/v1/stream":             GroupBulk,
+		"/api/v1/stream/sse":         GroupBulk,
+		"/api/v1/query":              GroupQuery,
+		"/api/v1/estimate-cost":      GroupQuery,
+		"/api/v1/tender":             GroupInteractive,
+		"/api/v1/rup/timeseries":     GroupInteractive,
+		"/api/v1/streams-of-tenders": GroupInteractive,
+		"/api/v1/admin/shedding":     GroupAdmin,
+	}
+	for path, want := range tests {
+		assert.Equal(t, want, GroupFor(path), path)
+	}
+}
+
+func TestShedder_InFlightLevels(t *testing.T) {
+	s := testShedder()
+
+	// Up to 75% of MaxInFlight everything is admitted
+	for i := 0; i < 5; i++ {
+		require.True(t, s.Admit(GroupInteractive))
+	}
+	assert.True(t, s.Admit(GroupBulk))
+
+	// At 75% bulk is shed, queries are not
+	assert.False(t, s.Admit(GroupBulk))
+	assert.True(t, s.Admit(GroupQuery))
+	assert.True(t, s.Admit(GroupQuery))
+
+	// At 100% queries are shed too; interactive and admin never are
+	assert.False(t, s.Admit(GroupQuery))
+	assert.True(t, s.Admit(GroupInteractive))
+	assert.True(t, s.Admit(GroupAdmin))
+
+	state := s.State()
+	assert.Equal(t, LevelQuery, state.Level)
+	assert.Equal(t, []string{"bulk", "query"}, state.Shedding)
+	assert.Equal(t, int64(1), state.Groups["bulk"].ShedTotal)
+	assert.Equal(t, int64(1), state.Groups["query"].ShedTotal)
+
+	// Finished requests free capacity again
+	for i := 0; i < 6; i++ {
+		s.Done(GroupInteractive, time.Millisecond)
+	}
+	assert.True(t, s.Admit(GroupBulk))
+}
+
+func TestShedder_LatencyLevels(t *testing.T) {
+	s := testShedder()
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	// Slow queries shed bulk
+	for i := 0; i < minLatencySamples; i++ {
+		require.True(t, s.Admit(GroupQuery))
+		s.Done(GroupQuery, 2*time.Second)
+	}
+	now = now.Add(evaluateInterval)
+	assert.False(t, s.Admit(GroupBulk))
+	assert.True(t, s.Admit(GroupQuery))
+	s.Done(GroupQuery, 0)
+
+	// Slow interactive requests shed queries as well
+	for i := 0; i < minLatencySamples; i++ {
+		require.True(t, s.Admit(GroupInteractive))
+		s.Done(GroupInteractive, 3*time.Second)
+	}
+	now = now.Add(evaluateInterval)
+	assert.False(t, s.Admit(GroupQuery))
+	assert.Equal(t, int64(3000), s.State().Groups["interactive"].P95Millis)
+
+	// Once the samples leave the window shedding stops
+	now = now.Add(2 * time.Minute)
+	assert.True(t, s.Admit(GroupBulk))
+}
+
+func TestShedder_Modes(t *testing.T) {
+	s := testShedder()
+
+	s.SetMode(ModeOn)
+	assert.False(t, s.Admit(GroupBulk))
+	assert.False(t, s.Admit(GroupQuery))
+	assert.True(t, s.Admit(GroupInteractive))
+
+	s.SetMode(ModeOff)
+	for i := 0; i < 20; i++ {
+		require.True(t, s.Admit(GroupBulk))
+	}
+	assert.Equal(t, LevelNone, s.State().Level)
+
+	_, err := ParseMode("sometimes")
+	assert.Error(t, err)
+
+	disabled := New(config.SheddingConfig{MaxInFlight: 1}, zap.NewNop())
+	assert.Equal(t, ModeOff, disabled.State().Mode)
+}
+
+func TestShedder_WritePrometheus(t *testing.T) {
+	s := testShedder()
+	s.SetMode(ModeOn)
+	s.Admit(GroupBulk)
+
+	var buf bytes.Buffer
+	s.WritePrometheus(&buf)
+	assert.Contains(t, buf.String(), "go_gateway_load_shedding_level 2")
+	assert.Contains(t, buf.String(), `go_gateway_shed_requests_total{group="bulk"} 1`)
+
+	var none *Shedder
+	buf.Reset()
+	none.WritePrometheus(&buf)
+	assert.Empty(t, buf.String())
+}
+
+func TestLatencyWindow_P95(t *testing.T) {
+	var l latencyWindow
+	now := time.Now()
+	for i := 1; i <= 100; i++ {
+		l.add(now, time.Duration(i)*time.Millisecond)
+	}
+	p95, n := l.p95(now.Add(-time.Second))
+	assert.Equal(t, 100, n)
+	assert.Equal(t, 95*time.Millisecond, p95)
+
+	_, n = l.p95(now.Add(time.Second))
+	assert.Zero(t, n)
+}
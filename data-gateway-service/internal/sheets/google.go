@@ -0,0 +1,101 @@
+package sheets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	gsheets "google.golang.org/api/sheets/v4"
+)
+
+// GoogleClient calls the Google Sheets API
+type GoogleClient struct {
+	service *gsheets.Service
+}
+
+// NewGoogleClient creates a Sheets API client; without a credentials file
+// the application default credentials are used. The service account must be
+// shared on each spreadsheet it writes to.
+func NewGoogleClient(ctx context.Context, credentialsFile string, opts ...option.ClientOption) (*GoogleClient, error) {
+	opts = append([]option.ClientOption{option.WithScopes(gsheets.SpreadsheetsScope)}, opts...)
+	if credentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(credentialsFile))
+	}
+
+	service, err := gsheets.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Sheets client: %w", err)
+	}
+	return &GoogleClient{service: service}, nil
+}
+
+// Clear removes every value of a sheet
+func (c *GoogleClient) Clear(ctx context.Context, spreadsheetID, sheet string) error {
+	_, err := c.service.Spreadsheets.Values.Clear(spreadsheetID, quoteSheet(sheet), &gsheets.ClearValuesRequest{}).
+		Context(ctx).
+		Do()
+	return apiError(err)
+}
+
+// FirstRow returns the values of the sheet's first row
+func (c *GoogleClient) FirstRow(ctx context.Context, spreadsheetID, sheet string) ([]interface{}, error) {
+	resp, err := c.service.Spreadsheets.Values.Get(spreadsheetID, quoteSheet(sheet)+"!1:1").
+		Context(ctx).
+		Do()
+	if err != nil {
+		return nil, apiError(err)
+	}
+	if len(resp.Values) == 0 {
+		return nil, nil
+	}
+	return resp.Values[0], nil
+}
+
+// BatchUpdate writes each block at its range in one request
+func (c *GoogleClient) BatchUpdate(ctx context.Context, spreadsheetID string, data []ValueRange) error {
+	req := &gsheets.BatchUpdateValuesRequest{ValueInputOption: "RAW"}
+	for _, block := range data {
+		req.Data = append(req.Data, &gsheets.ValueRange{Range: block.Range, Values: block.Values})
+	}
+	_, err := c.service.Spreadsheets.Values.BatchUpdate(spreadsheetID, req).
+		Context(ctx).
+		Do()
+	return apiError(err)
+}
+
+// Append writes values after the last row of the table in sheet
+func (c *GoogleClient) Append(ctx context.Context, spreadsheetID, sheet string, values [][]interface{}) (string, error) {
+	resp, err := c.service.Spreadsheets.Values.Append(spreadsheetID, quoteSheet(sheet), &gsheets.ValueRange{Values: values}).
+		ValueInputOption("RAW").
+		InsertDataOption("INSERT_ROWS").
+		Context(ctx).
+		Do()
+	if err != nil {
+		return "", apiError(err)
+	}
+	if resp.Updates == nil {
+		return "", nil
+	}
+	return resp.Updates.UpdatedRange, nil
+}
+
+// apiError maps the API errors a caller can act on to the package errors
+func apiError(err error) error {
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+	switch apiErr.Code {
+	case http.StatusForbidden, http.StatusUnauthorized:
+		return fmt.Errorf("%w: %s", ErrAccessDenied, apiErr.Message)
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: %s", ErrNotFound, apiErr.Message)
+	case http.StatusBadRequest:
+		return fmt.Errorf("%w: %s", ErrInvalidTarget, apiErr.Message)
+	default:
+		return err
+	}
+}
@@ -0,0 +1,199 @@
+// Package sheets writes query results to Google Sheets spreadsheets
+package sheets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MaxCells is the number of cells a Google Sheets spreadsheet may hold
+const MaxCells = 10_000_000
+
+var (
+	// ErrAccessDenied is returned when the service account may not edit the spreadsheet
+	ErrAccessDenied = errors.New("no access to spreadsheet")
+	// ErrNotFound is returned for an unknown spreadsheet
+	ErrNotFound = errors.New("spreadsheet not found")
+	// ErrInvalidTarget is returned when the API rejects the range, such as an unknown sheet
+	ErrInvalidTarget = errors.New("invalid spreadsheet range")
+)
+
+// Mode selects what happens to the values already in the sheet
+type Mode string
+
+const (
+	ModeClear  Mode = "clear"  // Clear the sheet, then write the header and rows from A1
+	ModeAppend Mode = "append" // Write rows after the existing ones; the header only into an empty sheet
+)
+
+// ParseMode parses a write mode; empty means clear
+func ParseMode(name string) (Mode, error) {
+	switch Mode(strings.ToLower(name)) {
+	case "", ModeClear:
+		return ModeClear, nil
+	case ModeAppend:
+		return ModeAppend, nil
+	default:
+		return "", fmt.Errorf("mode must be clear or append, got %q", name)
+	}
+}
+
+// ValueRange is a block of values written at an A1 range
+type ValueRange struct {
+	Range  string
+	Values [][]interface{}
+}
+
+// Client is the part of the Sheets API that Write uses. Values are written
+// as entered (RAW), so text that looks like a formula is never evaluated.
+type Client interface {
+	// Clear removes every value of a sheet
+	Clear(ctx context.Context, spreadsheetID, sheet string) error
+	// FirstRow returns the values of the sheet's first row
+	FirstRow(ctx context.Context, spreadsheetID, sheet string) ([]interface{}, error)
+	// BatchUpdate writes each block at its range in one request
+	BatchUpdate(ctx context.Context, spreadsheetID string, data []ValueRange) error
+	// Append writes values after the last row of the table in sheet and
+	// returns the range it wrote
+	Append(ctx context.Context, spreadsheetID, sheet string, values [][]interface{}) (string, error)
+}
+
+// Target is the sheet a result is written to
+type Target struct {
+	SpreadsheetID string
+	Sheet         string
+	Mode          Mode
+}
+
+// Written reports the outcome of Write
+type Written struct {
+	Range  string `json:"updated_range"` // A1 range of everything written, header included
+	Rows   int    `json:"rows"`          // Data rows, without the header
+	Header bool   `json:"header"`        // Whether a header row was written
+}
+
+// Write writes rows under a header of columns, batchRows rows per request
+func Write(ctx context.Context, client Client, target Target, columns []string, rows []map[string]interface{}, batchRows int) (*Written, error) {
+	if batchRows <= 0 {
+		batchRows = 5000
+	}
+
+	header := true
+	if target.Mode == ModeAppend {
+		first, err := client.FirstRow(ctx, target.SpreadsheetID, target.Sheet)
+		if err != nil {
+			return nil, err
+		}
+		header = len(first) == 0
+	} else if err := client.Clear(ctx, target.SpreadsheetID, target.Sheet); err != nil {
+		return nil, err
+	}
+
+	values := make([][]interface{}, 0, len(rows)+1)
+	if header {
+		names := make([]interface{}, len(columns))
+		for i, column := range columns {
+			names[i] = column
+		}
+		values = append(values, names)
+	}
+	for _, row := range rows {
+		record := make([]interface{}, len(columns))
+		for i, column := range columns {
+			record[i] = cellValue(row[column])
+		}
+		values = append(values, record)
+	}
+
+	written := &Written{Rows: len(rows), Header: header}
+	if len(values) == 0 {
+		return written, nil
+	}
+
+	if target.Mode == ModeAppend {
+		var first, last string
+		for start := 0; start < len(values); start += batchRows {
+			updated, err := client.Append(ctx, target.SpreadsheetID, target.Sheet, values[start:min(start+batchRows, len(values))])
+			if err != nil {
+				return nil, err
+			}
+			if first == "" {
+				first = updated
+			}
+			last = updated
+		}
+		written.Range = spanRanges(first, last)
+		return written, nil
+	}
+
+	sheet := quoteSheet(target.Sheet)
+	for start := 0; start < len(values); start += batchRows {
+		end := min(start+batchRows, len(values))
+		block := ValueRange{Range: fmt.Sprintf("%s!A%d", sheet, start+1), Values: values[start:end]}
+		if err := client.BatchUpdate(ctx, target.SpreadsheetID, []ValueRange{block}); err != nil {
+			return nil, err
+		}
+	}
+	written.Range = fmt.Sprintf("%s!A1:%s%d", sheet, ColumnLetters(max(len(columns), 1)), len(values))
+	return written, nil
+}
+
+// ColumnLetters returns the A1 letters of the n-th column, counting from 1
+func ColumnLetters(n int) string {
+	var letters []byte
+	for ; n > 0; n = (n - 1) / 26 {
+		letters = append([]byte{byte('A' + (n-1)%26)}, letters...)
+	}
+	return string(letters)
+}
+
+// quoteSheet quotes a sheet name for A1 notation
+func quoteSheet(sheet string) string {
+	return "'" + strings.ReplaceAll(sheet, "'", "''") + "'"
+}
+
+// spanRanges joins the start of first and the end of last, two ranges of the
+// same sheet such as 'Data'!A5:C10 and 'Data'!A11:C20
+func spanRanges(first, last string) string {
+	if first == last {
+		return first
+	}
+	sheet, start, ok := strings.Cut(first, "!")
+	if !ok {
+		return first
+	}
+	start, _, _ = strings.Cut(start, ":")
+	_, end, _ := strings.Cut(last, "!")
+	if i := strings.LastIndex(end, ":"); i >= 0 {
+		end = end[i+1:]
+	}
+	return sheet + "!" + start + ":" + end
+}
+
+// cellValue converts a result value to a JSON value the Sheets API accepts
+func cellValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string, bool, int, int32, int64, float32, float64, json.Number:
+		return val
+	case []byte:
+		return string(val)
+	case time.Time:
+		return val.Format(time.RFC3339)
+	case fmt.Stringer:
+		return val.String()
+	case map[string]interface{}, []interface{}:
+		encoded, err := json.Marshal(val)
+		if err != nil {
+			return fmt.Sprint(val)
+		}
+		return string(encoded)
+	default:
+		return fmt.Sprint(val)
+	}
+}
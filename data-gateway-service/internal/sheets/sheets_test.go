@@ -0,0 +1,122 @@
+package sheets
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClient keeps one sheet's rows and records the API calls
+type fakeClient struct {
+	rows    [][]interface{}
+	cleared int
+	batches []ValueRange
+	appends int
+}
+
+func (c *fakeClient) Clear(ctx context.Context, spreadsheetID, sheet string) error {
+	c.cleared++
+	c.rows = nil
+	return nil
+}
+
+func (c *fakeClient) FirstRow(ctx context.Context, spreadsheetID, sheet string) ([]interface{}, error) {
+	if len(c.rows) == 0 {
+		return nil, nil
+	}
+	return c.rows[0], nil
+}
+
+func (c *fakeClient) BatchUpdate(ctx context.Context, spreadsheetID string, data []ValueRange) error {
+	for _, block := range data {
+		c.batches = append(c.batches, block)
+		c.rows = append(c.rows, block.Values...)
+	}
+	return nil
+}
+
+func (c *fakeClient) Append(ctx context.Context, spreadsheetID, sheet string, values [][]interface{}) (string, error) {
+	c.appends++
+	start := len(c.rows) + 1
+	c.rows = append(c.rows, values...)
+	return fmt.Sprintf("'%s'!A%d:%s%d", sheet, start, ColumnLetters(len(values[0])), len(c.rows)), nil
+}
+
+func testRows(n int) []map[string]interface{} {
+	rows := make([]map[string]interface{}, n)
+	for i := range rows {
+		rows[i] = map[string]interface{}{"kode_tender": fmt.Sprintf("T%d", i+1), "pagu": float64(i * 1000)}
+	}
+	return rows
+}
+
+func TestWrite_ClearWritesHeaderAndBatches(t *testing.T) {
+	client := &fakeClient{rows: [][]interface{}{{"old"}}}
+	target := Target{SpreadsheetID: "sheet-1", Sheet: "Tenders", Mode: ModeClear}
+
+	written, err := Write(context.Background(), client, target, []string{"kode_tender", "pagu"}, testRows(5), 2)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, client.cleared)
+	assert.Equal(t, &Written{Range: "'Tenders'!A1:B6", Rows: 5, Header: true}, written)
+	require.Len(t, client.batches, 3)
+	assert.Equal(t, "'Tenders'!A1", client.batches[0].Range)
+	assert.Equal(t, "'Tenders'!A3", client.batches[1].Range)
+	assert.Equal(t, "'Tenders'!A5", client.batches[2].Range)
+	assert.Equal(t, []interface{}{"kode_tender", "pagu"}, client.rows[0])
+	assert.Equal(t, []interface{}{"T5", float64(4000)}, client.rows[5])
+}
+
+func TestWrite_AppendAddsHeaderOnlyToEmptySheet(t *testing.T) {
+	client := &fakeClient{}
+	target := Target{SpreadsheetID: "sheet-1", Sheet: "Tenders", Mode: ModeAppend}
+	columns := []string{"kode_tender", "pagu"}
+
+	written, err := Write(context.Background(), client, target, columns, testRows(3), 2)
+	require.NoError(t, err)
+	assert.True(t, written.Header)
+	assert.Equal(t, "'Tenders'!A1:B4", written.Range)
+
+	written, err = Write(context.Background(), client, target, columns, testRows(3), 2)
+	require.NoError(t, err)
+	assert.False(t, written.Header)
+	assert.Equal(t, "'Tenders'!A5:B7", written.Range)
+	assert.Equal(t, 3, written.Rows)
+
+	assert.Zero(t, client.cleared)
+	assert.Equal(t, 4, client.appends)
+	assert.Len(t, client.rows, 7)
+}
+
+func TestCellValue(t *testing.T) {
+	when := time.Date(2025, 3, 4, 2, 30, 0, 0, time.UTC)
+	assert.Equal(t, "", cellValue(nil))
+	assert.Equal(t, "2025-03-04T02:30:00Z", cellValue(when))
+	assert.Equal(t, "abc", cellValue([]byte("abc")))
+	assert.Equal(t, `{"a":1}`, cellValue(map[string]interface{}{"a": 1}))
+	assert.Equal(t, "=SUM(A1:A2)", cellValue("=SUM(A1:A2)"))
+	assert.Equal(t, int64(7), cellValue(int64(7)))
+}
+
+func TestColumnLetters(t *testing.T) {
+	for n, letters := range map[int]string{1: "A", 26: "Z", 27: "AA", 52: "AZ", 703: "AAA"} {
+		assert.Equal(t, letters, ColumnLetters(n))
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	mode, err := ParseMode("")
+	require.NoError(t, err)
+	assert.Equal(t, ModeClear, mode)
+
+	mode, err = ParseMode("APPEND")
+	require.NoError(t, err)
+	assert.Equal(t, ModeAppend, mode)
+
+	_, err = ParseMode("merge")
+	assert.Error(t, err)
+}
@@ -0,0 +1,161 @@
+package snapshot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go-data-gateway/internal/cache"
+)
+
+// CacheStore keeps snapshots in the gateway cache with a long TTL. Labels are
+// tracked in an index entry, since the cache cannot list its keys; the index
+// is only serialized within one process, so replicas writing snapshots at the
+// same moment may drop each other's index entries.
+type CacheStore struct {
+	cache cache.Cache
+	ttl   time.Duration
+	mu    sync.Mutex // Guards read-modify-write of the index
+}
+
+// NewCacheStore creates a snapshot store on c; snapshots expire after ttl
+func NewCacheStore(c cache.Cache, ttl time.Duration) *CacheStore {
+	return &CacheStore{cache: c, ttl: ttl}
+}
+
+// snapshotKey is the cache key of the tenant's snapshot stored under label
+func snapshotKey(tenant, label string) string {
+	return cache.GenerateKey("snapshot", tenant, label)
+}
+
+// indexEntry is the index key of the tenant's snapshot stored under label
+func indexEntry(tenant, label string) string {
+	return tenant + "/" + label
+}
+
+// indexKey is the cache key of the label index
+var indexKey = cache.GenerateKey("snapshot-index")
+
+// Get returns the tenant's snapshot stored under label or ErrNotFound
+func (s *CacheStore) Get(ctx context.Context, tenant, label string) (*Snapshot, error) {
+	data, err := s.cache.Get(ctx, snapshotKey(tenant, label))
+	if errors.Is(err, cache.ErrCacheMiss) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot %s: %w", label, err)
+	}
+
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot %s: %w", label, err)
+	}
+	return &snap, nil
+}
+
+// Put stores snap under its tenant and label
+func (s *CacheStore) Put(ctx context.Context, snap *Snapshot) error {
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot %s: %w", snap.Label, err)
+	}
+	if err := s.cache.Set(ctx, snapshotKey(snap.Tenant, snap.Label), data, s.ttl); err != nil {
+		return fmt.Errorf("failed to store snapshot %s: %w", snap.Label, err)
+	}
+
+	return s.updateIndex(ctx, func(index map[string]Info) {
+		index[indexEntry(snap.Tenant, snap.Label)] = snap.Info()
+	})
+}
+
+// List describes the snapshots of all tenants, ordered by tenant and label
+func (s *CacheStore) List(ctx context.Context) ([]Info, error) {
+	s.mu.Lock()
+	index, err := s.readIndex(ctx)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]Info, 0, len(index))
+	for _, info := range index {
+		if !s.expired(info) {
+			infos = append(infos, info)
+		}
+	}
+	sortInfos(infos)
+	return infos, nil
+}
+
+// Delete removes the tenant's snapshot stored under label or returns
+// ErrNotFound
+func (s *CacheStore) Delete(ctx context.Context, tenant, label string) error {
+	found := false
+	err := s.updateIndex(ctx, func(index map[string]Info) {
+		entry := indexEntry(tenant, label)
+		info, ok := index[entry]
+		found = ok && !s.expired(info)
+		delete(index, entry)
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrNotFound
+	}
+
+	if err := s.cache.Delete(ctx, snapshotKey(tenant, label)); err != nil {
+		return fmt.Errorf("failed to delete snapshot %s: %w", label, err)
+	}
+	return nil
+}
+
+// expired reports whether the cache has dropped the snapshot info describes
+func (s *CacheStore) expired(info Info) bool {
+	return s.ttl > 0 && time.Since(info.CreatedAt) > s.ttl
+}
+
+// updateIndex applies update to the label index, dropping expired entries
+func (s *CacheStore) updateIndex(ctx context.Context, update func(map[string]Info)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index, err := s.readIndex(ctx)
+	if err != nil {
+		return err
+	}
+	for entry, info := range index {
+		if s.expired(info) {
+			delete(index, entry)
+		}
+	}
+	update(index)
+
+	data, err := json.Marshal(index)
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot index: %w", err)
+	}
+	if err := s.cache.Set(ctx, indexKey, data, s.ttl); err != nil {
+		return fmt.Errorf("failed to store snapshot index: %w", err)
+	}
+	return nil
+}
+
+// readIndex returns the label index; callers hold mu
+func (s *CacheStore) readIndex(ctx context.Context) (map[string]Info, error) {
+	index := make(map[string]Info)
+	data, err := s.cache.Get(ctx, indexKey)
+	if errors.Is(err, cache.ErrCacheMiss) {
+		return index, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot index: %w", err)
+	}
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot index: %w", err)
+	}
+	return index, nil
+}
@@ -0,0 +1,151 @@
+package snapshot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	storage "google.golang.org/api/storage/v1"
+)
+
+// GCSStore keeps each snapshot as a JSON object at prefix/tenant/label.json.
+// The object metadata repeats the snapshot info so listing needs no downloads.
+type GCSStore struct {
+	service *storage.Service
+	bucket  string
+	prefix  string // Ends in "/" unless empty
+}
+
+// NewGCSStore creates a store at path, a gs://bucket/prefix URL; without a
+// credentials file the application default credentials are used
+func NewGCSStore(ctx context.Context, path, credentialsFile string, opts ...option.ClientOption) (*GCSStore, error) {
+	u, err := url.Parse(path)
+	if err != nil || u.Scheme != "gs" || u.Host == "" {
+		return nil, fmt.Errorf("snapshot path %q must be gs://bucket/prefix", path)
+	}
+	prefix := strings.Trim(u.Path, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	opts = append([]option.ClientOption{option.WithScopes(storage.DevstorageReadWriteScope)}, opts...)
+	if credentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(credentialsFile))
+	}
+	service, err := storage.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCS client: %w", err)
+	}
+	return &GCSStore{service: service, bucket: u.Host, prefix: prefix}, nil
+}
+
+// objectName is the object holding the tenant's snapshot stored under label
+func (s *GCSStore) objectName(tenant, label string) string {
+	return s.prefix + tenant + "/" + label + ".json"
+}
+
+// Get returns the tenant's snapshot stored under label or ErrNotFound
+func (s *GCSStore) Get(ctx context.Context, tenant, label string) (*Snapshot, error) {
+	resp, err := s.service.Objects.Get(s.bucket, s.objectName(tenant, label)).Context(ctx).Download()
+	if err != nil {
+		if isNotFound(err) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to read snapshot %s: %w", label, err)
+	}
+	defer resp.Body.Close()
+
+	var snap Snapshot
+	if err := json.NewDecoder(resp.Body).Decode(&snap); err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot %s: %w", label, err)
+	}
+	return &snap, nil
+}
+
+// Put stores snap under its tenant and label
+func (s *GCSStore) Put(ctx context.Context, snap *Snapshot) error {
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot %s: %w", snap.Label, err)
+	}
+
+	object := &storage.Object{
+		Name:        s.objectName(snap.Tenant, snap.Label),
+		ContentType: "application/json",
+		Metadata: map[string]string{
+			"source":      snap.Source,
+			"key_columns": strings.Join(snap.KeyColumns, ","),
+			"rows":        strconv.Itoa(len(snap.Rows)),
+			"created_at":  snap.CreatedAt.Format(time.RFC3339Nano),
+		},
+	}
+	_, err = s.service.Objects.Insert(s.bucket, object).
+		Media(bytes.NewReader(data), googleapi.ContentType("application/json")).
+		Context(ctx).
+		Do()
+	if err != nil {
+		return fmt.Errorf("failed to store snapshot %s: %w", snap.Label, err)
+	}
+	return nil
+}
+
+// List describes the snapshots of all tenants, ordered by tenant and label
+func (s *GCSStore) List(ctx context.Context) ([]Info, error) {
+	var infos []Info
+	err := s.service.Objects.List(s.bucket).Prefix(s.prefix).Context(ctx).Pages(ctx, func(objects *storage.Objects) error {
+		for _, object := range objects.Items {
+			name, ok := strings.CutSuffix(strings.TrimPrefix(object.Name, s.prefix), ".json")
+			tenant, label, found := strings.Cut(name, "/")
+			if !ok || !found || ValidateLabel(label) != nil {
+				continue
+			}
+			infos = append(infos, objectInfo(tenant, label, object))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+
+	sortInfos(infos)
+	return infos, nil
+}
+
+// Delete removes the tenant's snapshot stored under label or returns
+// ErrNotFound
+func (s *GCSStore) Delete(ctx context.Context, tenant, label string) error {
+	err := s.service.Objects.Delete(s.bucket, s.objectName(tenant, label)).Context(ctx).Do()
+	if err != nil {
+		if isNotFound(err) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to delete snapshot %s: %w", label, err)
+	}
+	return nil
+}
+
+// objectInfo reads the snapshot info Put recorded in the object metadata
+func objectInfo(tenant, label string, object *storage.Object) Info {
+	info := Info{Tenant: tenant, Label: label, Source: object.Metadata["source"]}
+	if columns := object.Metadata["key_columns"]; columns != "" {
+		info.KeyColumns = strings.Split(columns, ",")
+	}
+	info.Rows, _ = strconv.Atoi(object.Metadata["rows"])
+	info.CreatedAt, _ = time.Parse(time.RFC3339Nano, object.Metadata["created_at"])
+	return info
+}
+
+// isNotFound reports whether err is a GCS 404
+func isNotFound(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound
+}
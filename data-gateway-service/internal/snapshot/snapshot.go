@@ -0,0 +1,236 @@
+// Package snapshot stores labelled query results and compares a fresh result
+// with a stored one, row by row on a set of key columns. Labels are scoped to
+// a tenant, so tenants never read each other's rows.
+package snapshot
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// ErrNotFound is returned when no snapshot is stored under a label
+var ErrNotFound = errors.New("snapshot not found")
+
+// ErrInvalidRows is returned by New when rows cannot be keyed
+var ErrInvalidRows = errors.New("invalid rows")
+
+// labelPattern restricts labels to characters safe in cache keys and object names
+var labelPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// ValidateLabel checks that label can name a snapshot
+func ValidateLabel(label string) error {
+	if !labelPattern.MatchString(label) {
+		return fmt.Errorf("invalid snapshot label %q: use 1-128 letters, digits, '.', '_' or '-'", label)
+	}
+	return nil
+}
+
+// Store persists snapshots by tenant and label
+type Store interface {
+	// Get returns the tenant's snapshot stored under label or ErrNotFound
+	Get(ctx context.Context, tenant, label string) (*Snapshot, error)
+	// Put stores s under its tenant and label, replacing any previous snapshot
+	Put(ctx context.Context, s *Snapshot) error
+	// List describes the snapshots of all tenants, ordered by tenant and label
+	List(ctx context.Context) ([]Info, error)
+	// Delete removes the tenant's snapshot stored under label or returns
+	// ErrNotFound
+	Delete(ctx context.Context, tenant, label string) error
+}
+
+// Snapshot is a query result keyed by its key columns
+type Snapshot struct {
+	Tenant     string    `json:"tenant"`
+	Label      string    `json:"label"`
+	Source     string    `json:"source"`
+	Query      string    `json:"query"` // SQL or table the rows came from
+	KeyColumns []string  `json:"key_columns"`
+	CreatedAt  time.Time `json:"created_at"`
+	Rows       []Row     `json:"rows"`
+}
+
+// Row is a row of a snapshot
+type Row struct {
+	Key  string                 `json:"key"`  // JSON array of the key column values
+	Hash string                 `json:"hash"` // SHA-256 of the non-key columns
+	Data map[string]interface{} `json:"data"`
+}
+
+// Info describes a stored snapshot without its rows
+type Info struct {
+	Tenant     string    `json:"tenant"`
+	Label      string    `json:"label"`
+	Source     string    `json:"source"`
+	Query      string    `json:"query,omitempty"`
+	KeyColumns []string  `json:"key_columns"`
+	Rows       int       `json:"rows"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Info describes s
+func (s *Snapshot) Info() Info {
+	return Info{
+		Tenant:     s.Tenant,
+		Label:      s.Label,
+		Source:     s.Source,
+		Query:      s.Query,
+		KeyColumns: s.KeyColumns,
+		Rows:       len(s.Rows),
+		CreatedAt:  s.CreatedAt,
+	}
+}
+
+// New keys and hashes rows. Every row must have all key columns and no two
+// rows may share a key.
+func New(tenant, label, source, query string, keyColumns []string, rows []map[string]interface{}) (*Snapshot, error) {
+	if len(keyColumns) == 0 {
+		return nil, fmt.Errorf("%w: at least one key column is required", ErrInvalidRows)
+	}
+
+	s := &Snapshot{
+		Tenant:     tenant,
+		Label:      label,
+		Source:     source,
+		Query:      query,
+		KeyColumns: keyColumns,
+		CreatedAt:  time.Now().UTC(),
+		Rows:       make([]Row, 0, len(rows)),
+	}
+	seen := make(map[string]bool, len(rows))
+	for i, data := range rows {
+		key, hash, err := keyAndHash(keyColumns, data)
+		if err != nil {
+			return nil, fmt.Errorf("%w: row %d: %v", ErrInvalidRows, i, err)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("%w: duplicate key %s", ErrInvalidRows, key)
+		}
+		seen[key] = true
+		s.Rows = append(s.Rows, Row{Key: key, Hash: hash, Data: data})
+	}
+	return s, nil
+}
+
+// keyAndHash returns the key of data and the hash of its other columns. Both
+// are computed from the JSON encoding, so a value hashes the same before and
+// after a round trip through a store.
+func keyAndHash(keyColumns []string, data map[string]interface{}) (string, string, error) {
+	values := make([]interface{}, len(keyColumns))
+	isKey := make(map[string]bool, len(keyColumns))
+	for i, column := range keyColumns {
+		value, ok := data[column]
+		if !ok {
+			return "", "", fmt.Errorf("key column %q is missing", column)
+		}
+		values[i] = value
+		isKey[column] = true
+	}
+	key, err := json.Marshal(values)
+	if err != nil {
+		return "", "", err
+	}
+
+	rest := make(map[string]interface{}, len(data))
+	for column, value := range data {
+		if !isKey[column] {
+			rest[column] = value
+		}
+	}
+	encoded, err := json.Marshal(rest) // Map keys are sorted
+	if err != nil {
+		return "", "", err
+	}
+	sum := sha256.Sum256(encoded)
+	return string(key), hex.EncodeToString(sum[:]), nil
+}
+
+// sortInfos orders infos by tenant and label
+func sortInfos(infos []Info) {
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].Tenant != infos[j].Tenant {
+			return infos[i].Tenant < infos[j].Tenant
+		}
+		return infos[i].Label < infos[j].Label
+	})
+}
+
+// Change is a row whose non-key columns differ between two snapshots
+type Change struct {
+	Key    map[string]interface{} `json:"key"`
+	Before map[string]interface{} `json:"before"`
+	After  map[string]interface{} `json:"after"`
+}
+
+// Counts summarises a Diff
+type Counts struct {
+	Added     int `json:"added"`
+	Removed   int `json:"removed"`
+	Changed   int `json:"changed"`
+	Unchanged int `json:"unchanged"`
+}
+
+// Diff lists the rows added, removed and changed since a base snapshot
+type Diff struct {
+	Added   []map[string]interface{} `json:"added"`
+	Removed []map[string]interface{} `json:"removed"`
+	Changed []Change                 `json:"changed"`
+	Counts  Counts                   `json:"counts"`
+}
+
+// Compare diffs current against base. Added and changed rows follow the order
+// of current, removed rows the order of base.
+func Compare(base, current *Snapshot) *Diff {
+	diff := &Diff{
+		Added:   []map[string]interface{}{},
+		Removed: []map[string]interface{}{},
+		Changed: []Change{},
+	}
+
+	before := make(map[string]Row, len(base.Rows))
+	for _, row := range base.Rows {
+		before[row.Key] = row
+	}
+
+	for _, row := range current.Rows {
+		old, ok := before[row.Key]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, row.Data)
+		case old.Hash != row.Hash:
+			diff.Changed = append(diff.Changed, Change{
+				Key:    keyOf(current.KeyColumns, row.Data),
+				Before: old.Data,
+				After:  row.Data,
+			})
+		default:
+			diff.Counts.Unchanged++
+		}
+		delete(before, row.Key)
+	}
+	for _, row := range base.Rows {
+		if _, ok := before[row.Key]; ok {
+			diff.Removed = append(diff.Removed, row.Data)
+		}
+	}
+
+	diff.Counts.Added = len(diff.Added)
+	diff.Counts.Removed = len(diff.Removed)
+	diff.Counts.Changed = len(diff.Changed)
+	return diff
+}
+
+// keyOf returns the key columns of data
+func keyOf(keyColumns []string, data map[string]interface{}) map[string]interface{} {
+	key := make(map[string]interface{}, len(keyColumns))
+	for _, column := range keyColumns {
+		key[column] = data[column]
+	}
+	return key
+}
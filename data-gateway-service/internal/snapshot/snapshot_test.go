@@ -0,0 +1,117 @@
+package snapshot
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-data-gateway/internal/cache"
+)
+
+func rows(values ...map[string]interface{}) []map[string]interface{} {
+	return values
+}
+
+func TestNew_RejectsUnkeyableRows(t *testing.T) {
+	_, err := New("default", "day1", "DATAWAREHOUSE", "tender", []string{"id"},
+		rows(map[string]interface{}{"name": "a"}))
+	assert.ErrorIs(t, err, ErrInvalidRows)
+
+	_, err = New("default", "day1", "DATAWAREHOUSE", "tender", []string{"id"},
+		rows(map[string]interface{}{"id": 1}, map[string]interface{}{"id": 1}))
+	assert.ErrorIs(t, err, ErrInvalidRows)
+
+	_, err = New("default", "day1", "DATAWAREHOUSE", "tender", nil, nil)
+	assert.ErrorIs(t, err, ErrInvalidRows)
+}
+
+func TestCompare(t *testing.T) {
+	base, err := New("default", "day1", "DATAWAREHOUSE", "tender", []string{"id"}, rows(
+		map[string]interface{}{"id": 1, "status": "open", "value": 100},
+		map[string]interface{}{"id": 2, "status": "open", "value": 200},
+		map[string]interface{}{"id": 3, "status": "open", "value": 300},
+	))
+	require.NoError(t, err)
+
+	current, err := New("default", "day2", "DATAWAREHOUSE", "tender", []string{"id"}, rows(
+		map[string]interface{}{"id": 1, "status": "open", "value": 100},
+		map[string]interface{}{"id": 2, "status": "closed", "value": 200},
+		map[string]interface{}{"id": 4, "status": "open", "value": 400},
+	))
+	require.NoError(t, err)
+
+	diff := Compare(base, current)
+	assert.Equal(t, Counts{Added: 1, Removed: 1, Changed: 1, Unchanged: 1}, diff.Counts)
+	assert.Equal(t, 4, diff.Added[0]["id"])
+	assert.Equal(t, 3, diff.Removed[0]["id"])
+	assert.Equal(t, map[string]interface{}{"id": 2}, diff.Changed[0].Key)
+	assert.Equal(t, "open", diff.Changed[0].Before["status"])
+	assert.Equal(t, "closed", diff.Changed[0].After["status"])
+}
+
+func TestCompare_HashSurvivesStoreRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	store := NewCacheStore(cache.NewMemoryCache(), time.Hour)
+	day := time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)
+
+	stored, err := New("default", "day1", "DATAWAREHOUSE", "tender", []string{"id"}, rows(
+		map[string]interface{}{"id": int64(1), "value": int64(100), "date": day},
+	))
+	require.NoError(t, err)
+	require.NoError(t, store.Put(ctx, stored))
+
+	// Decoded rows hold float64 and string values; they must still match
+	base, err := store.Get(ctx, "default", "day1")
+	require.NoError(t, err)
+	current, err := New("default", "day2", "DATAWAREHOUSE", "tender", []string{"id"}, rows(
+		map[string]interface{}{"id": int64(1), "value": int64(100), "date": day},
+	))
+	require.NoError(t, err)
+
+	diff := Compare(base, current)
+	assert.Equal(t, Counts{Unchanged: 1}, diff.Counts)
+}
+
+func TestCacheStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewCacheStore(cache.NewMemoryCache(), time.Hour)
+
+	_, err := store.Get(ctx, "default", "day1")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	for _, tenant := range []string{"lkpp", "default"} {
+		snap, err := New(tenant, "day1", "DATAWAREHOUSE", "tender", []string{"id"},
+			rows(map[string]interface{}{"id": 1}))
+		require.NoError(t, err)
+		require.NoError(t, store.Put(ctx, snap))
+	}
+
+	infos, err := store.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, infos, 2)
+	assert.Equal(t, "default", infos[0].Tenant)
+	assert.Equal(t, "lkpp", infos[1].Tenant)
+	assert.Equal(t, 1, infos[0].Rows)
+
+	// Deleting one tenant's label leaves the other's snapshot
+	require.NoError(t, store.Delete(ctx, "lkpp", "day1"))
+	assert.ErrorIs(t, store.Delete(ctx, "lkpp", "day1"), ErrNotFound)
+	_, err = store.Get(ctx, "lkpp", "day1")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = store.Get(ctx, "default", "day1")
+	assert.NoError(t, err)
+
+	infos, err = store.List(ctx)
+	require.NoError(t, err)
+	assert.Len(t, infos, 1)
+}
+
+func TestValidateLabel(t *testing.T) {
+	assert.NoError(t, ValidateLabel("tender-2026-10-16"))
+	assert.Error(t, ValidateLabel(""))
+	assert.Error(t, ValidateLabel("a/b"))
+	assert.Error(t, ValidateLabel("a b"))
+}
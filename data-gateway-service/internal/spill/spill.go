@@ -0,0 +1,265 @@
+// Package spill keeps query results written to disk. A large stream is
+// spilled to a file as fast as the data source produces it, so the source is
+// released before a slow client downloads the file, with Range requests, for
+// as long as it is retained.
+package spill
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/config"
+)
+
+var (
+	// ErrTooLarge is returned by writes past the maximum spill size
+	ErrTooLarge = errors.New("spilled result exceeds the maximum size")
+	// ErrNotFound is returned for an unknown or expired spill
+	ErrNotFound = errors.New("spilled result not found")
+)
+
+// filePrefix names the files of spilled results in the directory
+const filePrefix = "spill-"
+
+// Status is the state of a spilled result
+type Status string
+
+const (
+	StatusRunning Status = "running"
+	StatusReady   Status = "ready"
+	StatusFailed  Status = "failed"
+)
+
+// Info describes a spilled result
+type Info struct {
+	ID          string    `json:"id"`
+	Status      Status    `json:"status"`
+	Error       string    `json:"error,omitempty"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size_bytes"`
+	Rows        int       `json:"rows"`
+	SHA256      string    `json:"sha256,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at,omitzero"` // Set once the spill ends
+
+	Owner string `json:"-"` // API key that created it; only it may download
+	path  string
+	err   error
+}
+
+// Err returns why a failed spill failed
+func (i Info) Err() error {
+	return i.err
+}
+
+// Store keeps spilled results in a directory and deletes them once retained
+// for the configured time
+type Store struct {
+	dir       string
+	maxBytes  int64
+	retention time.Duration
+	now       func() time.Time
+	logger    *zap.Logger
+
+	mu     sync.Mutex
+	spills map[string]*Info
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewStore creates the spill directory and removes the files a previous run
+// left in it
+func NewStore(cfg config.SpillConfig, logger *zap.Logger) (*Store, error) {
+	if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("spill directory: %w", err)
+	}
+	leftovers, err := filepath.Glob(filepath.Join(cfg.Dir, filePrefix+"*"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range leftovers {
+		os.Remove(path)
+	}
+
+	retention := cfg.Retention
+	if retention <= 0 {
+		retention = time.Hour
+	}
+	return &Store{
+		dir:       cfg.Dir,
+		maxBytes:  cfg.MaxBytes,
+		retention: retention,
+		now:       time.Now,
+		logger:    logger,
+		spills:    make(map[string]*Info),
+		stop:      make(chan struct{}),
+	}, nil
+}
+
+// Create starts a spilled result owned by owner. The caller writes it and
+// then calls Finish exactly once.
+func (s *Store) Create(owner, contentType string) (*Writer, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return nil, err
+	}
+	id := hex.EncodeToString(b[:])
+
+	path := filepath.Join(s.dir, filePrefix+id)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.spills[id] = &Info{
+		ID:          id,
+		Status:      StatusRunning,
+		ContentType: contentType,
+		CreatedAt:   s.now().UTC(),
+		Owner:       owner,
+		path:        path,
+	}
+	s.mu.Unlock()
+
+	return &Writer{store: s, id: id, file: file}, nil
+}
+
+// Get returns the spilled result id
+func (s *Store) Get(id string) (Info, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, ok := s.spills[id]
+	if !ok {
+		return Info{}, ErrNotFound
+	}
+	return *info, nil
+}
+
+// Open returns the file of a ready spilled result for reading
+func (s *Store) Open(id string) (*os.File, Info, error) {
+	info, err := s.Get(id)
+	if err != nil {
+		return nil, Info{}, err
+	}
+	if info.Status != StatusReady {
+		return nil, info, fmt.Errorf("spilled result %s is %s", id, info.Status)
+	}
+	file, err := os.Open(info.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, info, ErrNotFound // Removed since Get
+	}
+	return file, info, err
+}
+
+// Cleanup deletes the spilled results whose retention has passed. An open
+// download keeps reading its file until it closes it.
+func (s *Store) Cleanup() int {
+	now := s.now()
+
+	s.mu.Lock()
+	var expired []*Info
+	for id, info := range s.spills {
+		if !info.ExpiresAt.IsZero() && now.After(info.ExpiresAt) {
+			expired = append(expired, info)
+			delete(s.spills, id)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, info := range expired {
+		if err := os.Remove(info.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			s.logger.Warn("Failed to remove spilled result", zap.String("id", info.ID), zap.Error(err))
+		}
+	}
+	return len(expired)
+}
+
+// Start deletes expired spilled results in the background until Stop
+func (s *Store) Start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(max(s.retention/4, time.Second))
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				if n := s.Cleanup(); n > 0 {
+					s.logger.Info("Removed expired spilled results", zap.Int("count", n))
+				}
+			}
+		}
+	}()
+}
+
+// Stop stops the background cleanup
+func (s *Store) Stop() {
+	close(s.stop)
+	s.wg.Wait()
+}
+
+// Writer writes a spilled result to its file
+type Writer struct {
+	store   *Store
+	id      string
+	file    *os.File
+	written int64
+}
+
+// ID returns the id of the spilled result
+func (w *Writer) ID() string {
+	return w.id
+}
+
+// Write appends p to the file, failing with ErrTooLarge once the result
+// would pass the maximum size
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.store.maxBytes > 0 && w.written+int64(len(p)) > w.store.maxBytes {
+		return 0, ErrTooLarge
+	}
+	n, err := w.file.Write(p)
+	w.written += int64(n)
+	return n, err
+}
+
+// Finish closes the file and marks the result ready, or failed with err. A
+// failed result's file is deleted at once; either is kept for the retention.
+func (w *Writer) Finish(rows int, sha256 string, err error) Info {
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+
+	s := w.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info := s.spills[w.id]
+	info.Size = w.written
+	info.Rows = rows
+	info.ExpiresAt = s.now().Add(s.retention).UTC()
+	if err != nil {
+		info.Status = StatusFailed
+		info.Error = err.Error()
+		info.err = err
+		os.Remove(info.path)
+	} else {
+		info.Status = StatusReady
+		info.SHA256 = sha256
+	}
+	return *info
+}
@@ -0,0 +1,96 @@
+package spill
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/config"
+)
+
+func newTestStore(t *testing.T, maxBytes int64) *Store {
+	store, err := NewStore(config.SpillConfig{Dir: t.TempDir(), MaxBytes: maxBytes, Retention: time.Hour}, zap.NewNop())
+	require.NoError(t, err)
+	return store
+}
+
+func TestStore_SpillAndOpen(t *testing.T) {
+	store := newTestStore(t, 0)
+
+	writer, err := store.Create("analyst", "application/x-ndjson")
+	require.NoError(t, err)
+	running, err := store.Get(writer.ID())
+	require.NoError(t, err)
+	assert.Equal(t, StatusRunning, running.Status)
+	_, _, err = store.Open(writer.ID())
+	assert.Error(t, err, "a running spill cannot be read")
+
+	io.WriteString(writer, "{\"id\":1}\n{\"id\":2}\n")
+	info := writer.Finish(2, "abc", nil)
+	assert.Equal(t, StatusReady, info.Status)
+	assert.Equal(t, int64(18), info.Size)
+	assert.Equal(t, "analyst", info.Owner)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), info.ExpiresAt, time.Minute)
+
+	file, opened, err := store.Open(writer.ID())
+	require.NoError(t, err)
+	defer file.Close()
+	body, err := io.ReadAll(file)
+	require.NoError(t, err)
+	assert.Equal(t, "{\"id\":1}\n{\"id\":2}\n", string(body))
+	assert.Equal(t, "application/x-ndjson", opened.ContentType)
+}
+
+func TestStore_MaxSize(t *testing.T) {
+	store := newTestStore(t, 10)
+
+	writer, err := store.Create("analyst", "text/csv")
+	require.NoError(t, err)
+	_, err = io.WriteString(writer, "id\n1\n")
+	require.NoError(t, err)
+	_, err = io.WriteString(writer, "2\n3\n4\n")
+	require.ErrorIs(t, err, ErrTooLarge)
+
+	info := writer.Finish(4, "", err)
+	assert.Equal(t, StatusFailed, info.Status)
+	assert.ErrorIs(t, info.Err(), ErrTooLarge)
+	assert.NoFileExists(t, info.path, "a failed spill's file is deleted at once")
+}
+
+func TestStore_CleanupAfterRetention(t *testing.T) {
+	dir := t.TempDir()
+	leftover := filepath.Join(dir, filePrefix+"crashed")
+	require.NoError(t, os.WriteFile(leftover, []byte("x"), 0o600))
+
+	store, err := NewStore(config.SpillConfig{Dir: dir, Retention: time.Hour}, zap.NewNop())
+	require.NoError(t, err)
+	assert.NoFileExists(t, leftover, "files of a previous run are removed at startup")
+
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	done, err := store.Create("analyst", "text/csv")
+	require.NoError(t, err)
+	done.Finish(0, "", nil)
+	running, err := store.Create("analyst", "text/csv")
+	require.NoError(t, err)
+
+	assert.Zero(t, store.Cleanup())
+
+	now = now.Add(61 * time.Minute)
+	assert.Equal(t, 1, store.Cleanup())
+	_, err = store.Get(done.ID())
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.NoFileExists(t, filepath.Join(dir, filePrefix+done.ID()))
+
+	// A spill still being written is never removed
+	_, err = store.Get(running.ID())
+	assert.NoError(t, err)
+	assert.FileExists(t, filepath.Join(dir, filePrefix+running.ID()))
+}
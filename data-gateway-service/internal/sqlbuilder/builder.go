@@ -0,0 +1,420 @@
+package sqlbuilder
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Error is a part of a statement that failed validation; Clause names it,
+// e.g. "filter" or "order by"
+type Error struct {
+	Clause string
+	Err    error
+}
+
+func (e *Error) Error() string {
+	return e.Clause + " validation failed: " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// projection is one entry of the select list
+type projection struct {
+	column string // Validated and allowlisted, unless expr is set
+	expr   string // Trusted expression
+	alias  string
+}
+
+// orderTerm is one ORDER BY term
+type orderTerm struct {
+	column string
+	dir    string
+}
+
+// String writes the term, e.g. tender_id DESC; an empty direction is ASC
+func (t orderTerm) String() string {
+	dir := strings.ToUpper(strings.TrimSpace(t.dir))
+	if dir == "" {
+		dir = "ASC"
+	}
+	return identifierQuotes.Replace(t.column) + " " + dir
+}
+
+// Builder builds one SELECT statement:
+//
+//	sqlbuilder.Select(sqlbuilder.Dremio, "tender_id", "nilai_pagu").
+//		From("nessie_iceberg.tender_data").
+//		Where(sqlbuilder.Eq("tahun_anggaran", 2025)).
+//		OrderBy("nilai_pagu", "DESC").Tiebreaker("tender_id", "DESC").
+//		Limit(20).SQL()
+//
+// Nothing is checked until SQL or CountSQL, which report the first invalid
+// part as an *Error. A Builder is not safe for concurrent use.
+type Builder struct {
+	dialect    Dialect
+	table      string
+	selects    []projection
+	where      []Cond
+	search     Cond
+	after      Cond
+	groupBy    []string
+	order      []orderTerm
+	tiebreaker orderTerm
+	limit      int
+	offset     int
+	asOf       time.Time
+	asOfBranch string
+	sample     float64
+
+	allowedTables  map[string]bool
+	allowedColumns map[string]map[string]bool
+}
+
+// Select starts a statement selecting columns, or * when there are none
+func Select(dialect Dialect, columns ...string) *Builder {
+	b := &Builder{dialect: dialect}
+	for _, column := range columns {
+		b.selects = append(b.selects, projection{column: column})
+	}
+	return b
+}
+
+// SelectAs adds column AS alias to the select list
+func (b *Builder) SelectAs(column, alias string) *Builder {
+	b.selects = append(b.selects, projection{column: column, alias: alias})
+	return b
+}
+
+// SelectExpr adds expr AS alias to the select list. expr is written as it
+// is, so it must be a constant of the caller, e.g. COUNT(*), never input.
+func (b *Builder) SelectExpr(expr, alias string) *Builder {
+	b.selects = append(b.selects, projection{expr: expr, alias: alias})
+	return b
+}
+
+// From sets the table
+func (b *Builder) From(table string) *Builder {
+	b.table = table
+	return b
+}
+
+// AsOf reads the table as it was at t, on branch when it is not empty; see
+// Dialect.AsOf. A zero t reads the current table.
+func (b *Builder) AsOf(t time.Time, branch string) *Builder {
+	b.asOf, b.asOfBranch = t, branch
+	return b
+}
+
+// Sample reads about percent of the table's storage blocks; see
+// Dialect.Sample. Zero reads the whole table.
+func (b *Builder) Sample(percent float64) *Builder {
+	b.sample = percent
+	return b
+}
+
+// AllowTables restricts From to tables; no tables allows any
+func (b *Builder) AllowTables(tables []string) *Builder {
+	b.allowedTables = nil
+	if len(tables) > 0 {
+		b.allowedTables = make(map[string]bool, len(tables))
+		for _, table := range tables {
+			b.allowedTables[table] = true
+		}
+	}
+	return b
+}
+
+// AllowColumns restricts the columns selected, filtered, searched and
+// ordered by, per table; a table without an entry accepts any column. The
+// tiebreaker and the keyset are configured, not requested, so they are
+// not restricted.
+func (b *Builder) AllowColumns(columns map[string][]string) *Builder {
+	b.allowedColumns = make(map[string]map[string]bool, len(columns))
+	for table, names := range columns {
+		b.allowedColumns[table] = make(map[string]bool, len(names))
+		for _, name := range names {
+			b.allowedColumns[table][name] = true
+		}
+	}
+	return b
+}
+
+// Where adds conditions, joined with AND to those already added
+func (b *Builder) Where(conds ...Cond) *Builder {
+	b.where = append(b.where, conds...)
+	return b
+}
+
+// Search adds a keyword search, after the Where conditions
+func (b *Builder) Search(cond Cond) *Builder {
+	b.search = cond
+	return b
+}
+
+// After reads only the rows past a keyset position; see the After condition
+func (b *Builder) After(columns []string, values []interface{}, desc bool) *Builder {
+	b.after = After(columns, values, desc)
+	return b
+}
+
+// GroupBy adds GROUP BY terms: columns, or positions in the select list
+func (b *Builder) GroupBy(terms ...string) *Builder {
+	b.groupBy = append(b.groupBy, terms...)
+	return b
+}
+
+// OrderBy adds an ORDER BY term: a column or a position in the select list,
+// ASC or DESC; an empty direction is ASC
+func (b *Builder) OrderBy(term, dir string) *Builder {
+	b.order = append(b.order, orderTerm{column: term, dir: dir})
+	return b
+}
+
+// Tiebreaker orders by column in dir after the OrderBy terms, unless it is
+// one of them, making the order of rows with equal terms deterministic. A
+// page (Limit or Offset set) without OrderBy terms is ordered by the
+// tiebreaker alone.
+func (b *Builder) Tiebreaker(column, dir string) *Builder {
+	b.tiebreaker = orderTerm{column: column, dir: dir}
+	return b
+}
+
+// Limit sets LIMIT n; zero or less means none
+func (b *Builder) Limit(n int) *Builder {
+	b.limit = n
+	return b
+}
+
+// Offset sets OFFSET n, written only with a Limit
+func (b *Builder) Offset(n int) *Builder {
+	b.offset = n
+	return b
+}
+
+// Order returns the ORDER BY terms, such as ["nilai_pagu DESC",
+// "tender_id DESC"]. Terms are not validated; SQL does that.
+func (b *Builder) Order() []string {
+	var terms []string
+	for _, term := range b.order {
+		terms = append(terms, term.String())
+	}
+	paged := b.limit > 0 || b.offset > 0
+	if b.tiebreaker.column != "" && !b.ordersBy(b.tiebreaker.column) && (len(b.order) > 0 || paged) {
+		terms = append(terms, b.tiebreaker.String())
+	}
+	return terms
+}
+
+// ordersBy reports whether column is an OrderBy term
+func (b *Builder) ordersBy(column string) bool {
+	for _, term := range b.order {
+		if strings.EqualFold(identifierQuotes.Replace(term.column), column) {
+			return true
+		}
+	}
+	return false
+}
+
+// SQL builds the statement with values written as literals
+func (b *Builder) SQL() (string, error) {
+	r := &renderer{dialect: b.dialect}
+	return b.build(r, false)
+}
+
+// Parameterized builds the statement with values bound to parameters of
+// the dialect: positional ? on Dremio, named @p1 on BigQuery
+func (b *Builder) Parameterized() (string, []Arg, error) {
+	r := &renderer{dialect: b.dialect, params: true}
+	query, err := b.build(r, false)
+	if err != nil {
+		return "", nil, err
+	}
+	return query, r.args, nil
+}
+
+// CountSQL builds SELECT COUNT(*) AS total of the rows the statement
+// matches, without its grouping, order or page
+func (b *Builder) CountSQL() (string, error) {
+	r := &renderer{dialect: b.dialect}
+	return b.build(r, true)
+}
+
+// build writes the statement, or its count, checking each part in order
+func (b *Builder) build(r *renderer, count bool) (string, error) {
+	table, err := b.validTable()
+	if err != nil {
+		return "", &Error{Clause: "table", Err: err}
+	}
+	from, _ := b.dialect.Table(table)
+	if !b.asOf.IsZero() {
+		clause, err := b.dialect.AsOf(b.asOf, b.asOfBranch)
+		if err != nil {
+			return "", &Error{Clause: "as of", Err: err}
+		}
+		from += " " + clause
+	}
+	if b.sample != 0 {
+		clause, err := b.dialect.Sample(b.sample)
+		if err != nil {
+			return "", &Error{Clause: "sample", Err: err}
+		}
+		from += " " + clause
+	}
+
+	projection := "COUNT(*) AS total"
+	if !count {
+		if projection, err = b.selectList(table); err != nil {
+			return "", &Error{Clause: "select", Err: err}
+		}
+	}
+	query := "SELECT " + projection + " FROM " + from
+
+	var conditions []string
+	for _, cond := range b.where {
+		condition, err := b.condition(r, table, cond, true)
+		if err != nil {
+			return "", &Error{Clause: "filter", Err: err}
+		}
+		conditions = append(conditions, condition)
+	}
+	if b.search != nil {
+		condition, err := b.condition(r, table, b.search, true)
+		if err != nil {
+			return "", &Error{Clause: "keyword", Err: err}
+		}
+		conditions = append(conditions, condition)
+	}
+	if b.after != nil {
+		condition, err := b.condition(r, table, b.after, false)
+		if err != nil {
+			return "", &Error{Clause: "keyset", Err: err}
+		}
+		conditions = append(conditions, condition)
+	}
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	if count {
+		return query, nil
+	}
+
+	if len(b.groupBy) > 0 {
+		terms := make([]string, len(b.groupBy))
+		for i, term := range b.groupBy {
+			if terms[i], err = b.term(table, term); err != nil {
+				return "", &Error{Clause: "group by", Err: err}
+			}
+		}
+		query += " GROUP BY " + strings.Join(terms, ", ")
+	}
+
+	for _, term := range b.order {
+		if _, err := b.term(table, term.column); err != nil {
+			return "", &Error{Clause: "order by", Err: err}
+		}
+		if _, err := ValidateDirection(term.dir); err != nil {
+			return "", &Error{Clause: "order direction", Err: err}
+		}
+	}
+	if b.tiebreaker.column != "" {
+		_, err := ValidateColumn(b.tiebreaker.column)
+		if err == nil {
+			_, err = ValidateDirection(b.tiebreaker.dir)
+		}
+		if err != nil {
+			return "", &Error{Clause: "tiebreaker", Err: err}
+		}
+	}
+	if order := b.Order(); len(order) > 0 {
+		query += " ORDER BY " + strings.Join(order, ", ")
+	}
+
+	if b.limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", b.limit)
+		if b.offset > 0 {
+			query += fmt.Sprintf(" OFFSET %d", b.offset)
+		}
+	}
+	return query, nil
+}
+
+// validTable returns the table without quotes, if it is valid and allowed
+func (b *Builder) validTable() (string, error) {
+	table := identifierQuotes.Replace(b.table)
+	if table == "" {
+		return "", fmt.Errorf("no table to select from")
+	}
+	if b.allowedTables != nil && !b.allowedTables[table] {
+		return "", fmt.Errorf("table '%s' is not in allowed list", table)
+	}
+	return ValidateTable(table)
+}
+
+// selectList writes the select list
+func (b *Builder) selectList(table string) (string, error) {
+	if len(b.selects) == 0 {
+		return "*", nil
+	}
+	items := make([]string, len(b.selects))
+	for i, p := range b.selects {
+		item := p.expr
+		if item == "" {
+			column, err := ValidateColumn(p.column)
+			if err == nil {
+				err = b.checkColumn(table, column)
+			}
+			if err != nil {
+				return "", err
+			}
+			item = column
+		}
+		if p.alias != "" {
+			alias, err := ValidateColumn(p.alias)
+			if err != nil {
+				return "", fmt.Errorf("alias: %w", err)
+			}
+			item += " AS " + alias
+		}
+		items[i] = item
+	}
+	return strings.Join(items, ", "), nil
+}
+
+// condition writes cond, checking its columns against the allowlist when
+// restricted is set
+func (b *Builder) condition(r *renderer, table string, cond Cond, restricted bool) (string, error) {
+	if restricted {
+		for _, column := range cond.columns() {
+			if err := b.checkColumn(table, identifierQuotes.Replace(column)); err != nil {
+				return "", err
+			}
+		}
+	}
+	return cond.render(r)
+}
+
+// term validates a GROUP BY or ORDER BY term
+func (b *Builder) term(table, term string) (string, error) {
+	valid, err := validateTerm(term)
+	if err != nil {
+		return "", err
+	}
+	if !ordinalPattern.MatchString(valid) {
+		if err := b.checkColumn(table, valid); err != nil {
+			return "", err
+		}
+	}
+	return valid, nil
+}
+
+// checkColumn rejects a column missing from the table's allowlist
+func (b *Builder) checkColumn(table, column string) error {
+	allowed, ok := b.allowedColumns[table]
+	if !ok || allowed[column] {
+		return nil
+	}
+	return fmt.Errorf("column '%s' is not allowed for table '%s'", column, table)
+}
@@ -0,0 +1,279 @@
+package sqlbuilder
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuilder_SQL(t *testing.T) {
+	tests := map[string]struct {
+		builder *Builder
+		want    string
+	}{
+		"star": {
+			Select(Dremio).From("nessie_iceberg.tender_data"),
+			"SELECT * FROM nessie_iceberg.tender_data",
+		},
+		"columns and filters": {
+			Select(Dremio, "tender_id", "nilai_pagu").From("nessie_iceberg.tender_data").
+				Where(Eq("tahun_anggaran", 2025), In("provinsi", []string{"Aceh", "Bali"})),
+			"SELECT tender_id, nilai_pagu FROM nessie_iceberg.tender_data" +
+				" WHERE tahun_anggaran = 2025 AND provinsi IN ('Aceh', 'Bali')",
+		},
+		"bigquery table": {
+			Select(BigQuery, "kd_kro").From("gtp-data-prod.layer_isb.rup_kromaster").Where(Eq("is_deleted", false)),
+			"SELECT kd_kro FROM `gtp-data-prod.layer_isb.rup_kromaster` WHERE is_deleted = FALSE",
+		},
+		"order with tiebreaker": {
+			Select(Dremio).From("t").OrderBy("nilai_pagu", "desc").Tiebreaker("tender_id", "DESC").Limit(20).Offset(40),
+			"SELECT * FROM t ORDER BY nilai_pagu DESC, tender_id DESC LIMIT 20 OFFSET 40",
+		},
+		"tiebreaker alone on a page": {
+			Select(Dremio).From("t").Tiebreaker("tender_id", "").Limit(20),
+			"SELECT * FROM t ORDER BY tender_id ASC LIMIT 20",
+		},
+		"tiebreaker not on an unordered read": {
+			Select(Dremio).From("t").Tiebreaker("tender_id", ""),
+			"SELECT * FROM t",
+		},
+		"tiebreaker already ordered by": {
+			Select(Dremio).From("t").OrderBy("tender_id", "DESC").Tiebreaker("tender_id", "DESC").Limit(5),
+			"SELECT * FROM t ORDER BY tender_id DESC LIMIT 5",
+		},
+		"offset needs a limit": {
+			Select(Dremio).From("t").Offset(10),
+			"SELECT * FROM t",
+		},
+		"aggregate": {
+			Select(Dremio).From("t").
+				SelectExpr("DATE_TRUNC('DAY', d)", "bucket").
+				SelectAs("provinsi", "group_value").
+				SelectExpr("COUNT(*)", "metric_value").
+				GroupBy("1", "2").OrderBy("1", "ASC").OrderBy("2", "ASC"),
+			"SELECT DATE_TRUNC('DAY', d) AS bucket, provinsi AS group_value, COUNT(*) AS metric_value FROM t" +
+				" GROUP BY 1, 2 ORDER BY 1 ASC, 2 ASC",
+		},
+		"search and keyset after filters": {
+			Select(Dremio).From("t").
+				After([]string{"id"}, []interface{}{int64(7)}, false).
+				Search(Contains("nama", "jalan")).
+				Where(Eq("a", 1)),
+			`SELECT * FROM t WHERE a = 1 AND LOWER(nama) LIKE LOWER('%jalan%') ESCAPE '\' AND id > 7`,
+		},
+		"as of": {
+			Select(Dremio, "tender_id").From("nessie_iceberg.tender_data").
+				AsOf(time.Date(2025, 1, 1, 7, 0, 0, 0, time.FixedZone("WIB", 7*3600)), "").
+				Where(Eq("status_tender", "Selesai")),
+			"SELECT tender_id FROM nessie_iceberg.tender_data AT TIMESTAMP '2025-01-01 00:00:00.000' WHERE status_tender = 'Selesai'",
+		},
+		"as of on a branch": {
+			Select(Dremio).From("nessie_iceberg.tender_data").AsOf(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), "staging"),
+			`SELECT * FROM nessie_iceberg.tender_data AT BRANCH "staging" AS OF '2025-01-01 00:00:00.000'`,
+		},
+		"bigquery as of": {
+			Select(BigQuery).From("p.d.rup").AsOf(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), ""),
+			"SELECT * FROM `p.d.rup` FOR SYSTEM_TIME AS OF TIMESTAMP '2025-01-01 00:00:00.000'",
+		},
+		"bigquery sample": {
+			Select(BigQuery).From("p.d.rup").Sample(0.25).Limit(100),
+			"SELECT * FROM `p.d.rup` TABLESAMPLE SYSTEM (0.25 PERCENT) LIMIT 100",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			query, err := tt.builder.SQL()
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, query)
+		})
+	}
+}
+
+func TestBuilder_CountSQL(t *testing.T) {
+	b := Select(BigQuery, "kd_kro").From("p.d.rup").
+		Where(Eq("tahun_anggaran", int64(2024))).
+		OrderBy("_event_date", "DESC").Tiebreaker("kd_kro_str", "DESC").Limit(10).Offset(10)
+
+	count, err := b.CountSQL()
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT COUNT(*) AS total FROM `p.d.rup` WHERE tahun_anggaran = 2024", count)
+	assert.Equal(t, []string{"_event_date DESC", "kd_kro_str DESC"}, b.Order())
+}
+
+func TestBuilder_AsOfCount(t *testing.T) {
+	b := Select(Dremio).From("t").AsOf(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), "").Where(Eq("a", 1))
+
+	count, err := b.CountSQL()
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT COUNT(*) AS total FROM t AT TIMESTAMP '2025-01-01 00:00:00.000' WHERE a = 1", count)
+
+	_, err = Select(Dremio).From("t").AsOf(time.Now(), `main" AS OF '1970-01-01`).SQL()
+	assert.ErrorContains(t, err, "as of validation failed: invalid branch name")
+	_, err = Select(BigQuery).From("t").AsOf(time.Now(), "staging").SQL()
+	assert.ErrorContains(t, err, "bigquery tables have no branches")
+}
+
+func TestBuilder_SampleRejected(t *testing.T) {
+	_, err := Select(Dremio).From("t").Sample(10).SQL()
+	assert.ErrorContains(t, err, "sample validation failed: dremio tables cannot be sampled")
+	_, err = Select(BigQuery).From("t").Sample(150).SQL()
+	assert.ErrorContains(t, err, "at most 100")
+}
+
+func TestBuilder_Parameterized(t *testing.T) {
+	b := func(d Dialect) *Builder {
+		return Select(d).From("t").
+			Where(Eq("a", "x' OR '1'='1"), In("b", []int{1, 2}), Gte("d", Raw("TIMESTAMP '2025-01-01 00:00:00'"))).
+			Search(Contains("nama", "50%")).
+			Limit(10)
+	}
+
+	query, args, err := b(Dremio).Parameterized()
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM t WHERE a = ? AND b IN (?, ?) AND d >= TIMESTAMP '2025-01-01 00:00:00'"+
+		` AND LOWER(nama) LIKE LOWER(?) ESCAPE '\' LIMIT 10`, query)
+	assert.Equal(t, []Arg{{Value: "x' OR '1'='1"}, {Value: 1}, {Value: 2}, {Value: `%50\%%`}}, args)
+
+	query, args, err = b(BigQuery).Parameterized()
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM `t` WHERE a = @p1 AND b IN (@p2, @p3) AND d >= TIMESTAMP '2025-01-01 00:00:00'"+
+		" AND LOWER(nama) LIKE LOWER(@p4) LIMIT 10", query)
+	assert.Equal(t, "p1", args[0].Name)
+	assert.Equal(t, "p4", args[3].Name)
+
+	// A value without a literal has no parameter either
+	_, _, err = Select(Dremio).From("t").Where(Eq("a", struct{}{})).Parameterized()
+	assert.ErrorContains(t, err, "unsupported filter value type")
+}
+
+func TestBuilder_RejectsInjection(t *testing.T) {
+	tests := map[string]struct {
+		builder *Builder
+		clause  string
+	}{
+		"table statement":   {Select(Dremio).From("t; DROP TABLE users"), "table"},
+		"table comment":     {Select(Dremio).From("t--"), "table"},
+		"table union":       {Select(Dremio).From("t.union_all"), "table"},
+		"no table":          {Select(Dremio), "table"},
+		"column expression": {Select(Dremio, "id, password").From("t"), "select"},
+		"column function":   {Select(Dremio, "SLEEP(10)").From("t"), "select"},
+		"alias":             {Select(Dremio).From("t").SelectAs("id", "x FROM users --"), "select"},
+		"filter column":     {Select(Dremio).From("t").Where(Eq("1=1 OR a", 1)), "filter"},
+		"nested column":     {Select(Dremio).From("t").Where(Or(Eq("a", 1), Eq("b;", 2))), "filter"},
+		"in column":         {Select(Dremio).From("t").Where(In("a)", []string{"x"})), "filter"},
+		"search column":     {Select(Dremio).From("t").Search(Contains("a OR 1", "x")), "keyword"},
+		"keyset column":     {Select(Dremio).From("t").After([]string{"id > 0 --"}, []interface{}{1}, false), "keyset"},
+		"group by":          {Select(Dremio).From("t").GroupBy("1; DROP TABLE t"), "group by"},
+		"order by":          {Select(Dremio).From("t").OrderBy("(SELECT 1)", "ASC"), "order by"},
+		"order direction":   {Select(Dremio).From("t").OrderBy("a", "ASC; DROP TABLE t"), "order direction"},
+		"tiebreaker":        {Select(Dremio).From("t").Tiebreaker("id;", "ASC"), "tiebreaker"},
+		"tiebreaker dir":    {Select(Dremio).From("t").Tiebreaker("id", "sideways"), "tiebreaker"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := tt.builder.SQL()
+			var built *Error
+			require.True(t, errors.As(err, &built), "%v", err)
+			assert.Equal(t, tt.clause, built.Clause)
+			assert.ErrorContains(t, err, tt.clause+" validation failed")
+		})
+	}
+}
+
+func TestBuilder_ValuesCannotEscapeLiterals(t *testing.T) {
+	attacks := []string{
+		"x' OR '1'='1",
+		`x\' OR 1=1 --`,
+		"x'; DROP TABLE t; --",
+		"x\x00' OR 1=1",
+		"x\n' OR 1=1",
+	}
+	for _, attack := range attacks {
+		want := strings.ReplaceAll(attack, "\x00", "") // Null bytes are dropped
+		query, err := Select(Dremio).From("t").Where(Eq("a", attack)).SQL()
+		require.NoError(t, err)
+		assert.Equal(t, "SELECT * FROM t WHERE a = "+Dremio.Quote(attack), query)
+		assert.Equal(t, want, unquoteANSI(t, query[len("SELECT * FROM t WHERE a = "):]), "%q", attack)
+
+		query, err = Select(BigQuery).From("t").Where(Eq("a", attack)).SQL()
+		require.NoError(t, err)
+		assert.Equal(t, want, unquoteBigQuery(t, query[len("SELECT * FROM `t` WHERE a = "):]), "%q", attack)
+	}
+}
+
+func TestBuilder_Allowlists(t *testing.T) {
+	allowed := func(d Dialect, columns ...string) *Builder {
+		return Select(d, columns...).
+			AllowTables([]string{"nessie_iceberg.tender_data"}).
+			AllowColumns(map[string][]string{"nessie_iceberg.tender_data": {"tender_id", "nama_paket"}})
+	}
+
+	_, err := allowed(Dremio).From("nessie_iceberg.users").SQL()
+	assert.ErrorContains(t, err, "table 'nessie_iceberg.users' is not in allowed list")
+
+	_, err = allowed(Dremio, "password").From("nessie_iceberg.tender_data").SQL()
+	assert.ErrorContains(t, err, "select validation failed: column 'password' is not allowed for table 'nessie_iceberg.tender_data'")
+
+	for clause, b := range map[string]*Builder{
+		"filter":   allowed(Dremio).From("nessie_iceberg.tender_data").Where(Or(Eq("tender_id", "T1"), Eq("password", "x"))),
+		"keyword":  allowed(Dremio).From("nessie_iceberg.tender_data").Search(Contains("password", "x")),
+		"order by": allowed(Dremio).From("nessie_iceberg.tender_data").OrderBy("password", "ASC"),
+		"group by": allowed(Dremio).From("nessie_iceberg.tender_data").GroupBy("password"),
+	} {
+		_, err := b.SQL()
+		assert.ErrorContains(t, err, clause+" validation failed: column 'password' is not allowed", clause)
+	}
+
+	// The tiebreaker and the keyset are configured, not requested
+	query, err := allowed(Dremio, "tender_id").From("`nessie_iceberg.tender_data`").
+		Where(Eq("nama_paket", "x")).
+		After([]string{"id"}, []interface{}{1}, false).
+		OrderBy("1", "ASC").Tiebreaker("id", "ASC").SQL()
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT tender_id FROM nessie_iceberg.tender_data WHERE nama_paket = 'x' AND id > 1 ORDER BY 1 ASC, id ASC", query)
+}
+
+// unquoteANSI reads back the ANSI string literal s
+func unquoteANSI(t *testing.T, s string) string {
+	t.Helper()
+	require.True(t, len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'', s)
+	var out []byte
+	for i := 1; i < len(s)-1; i++ {
+		if s[i] == '\'' {
+			require.Equal(t, byte('\''), s[i+1], "unescaped quote in %s", s)
+			i++
+		}
+		out = append(out, s[i])
+	}
+	return string(out)
+}
+
+// unquoteBigQuery reads back the BigQuery string literal s
+func unquoteBigQuery(t *testing.T, s string) string {
+	t.Helper()
+	require.True(t, len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'', s)
+	var out []byte
+	for i := 1; i < len(s)-1; i++ {
+		switch s[i] {
+		case '\'':
+			t.Fatalf("unescaped quote in %s", s)
+		case '\\':
+			i++
+			switch s[i] {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			default:
+				out = append(out, s[i])
+			}
+			continue
+		}
+		out = append(out, s[i])
+	}
+	return string(out)
+}
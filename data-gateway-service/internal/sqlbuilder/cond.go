@@ -0,0 +1,344 @@
+package sqlbuilder
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// MaxInValues bounds the list of an In condition
+const MaxInValues = 1000
+
+// Cond is a condition of a WHERE clause. Conditions are built with Eq, In,
+// Contains, And and the other constructors of this package; their columns
+// are validated when the condition is rendered.
+type Cond interface {
+	// render writes the condition with the values bound by r
+	render(r *renderer) (string, error)
+	// columns are the columns the condition reads, for column allowlists
+	columns() []string
+}
+
+// renderer writes values as literals of a dialect, or as its parameters
+type renderer struct {
+	dialect Dialect
+	params  bool
+	args    []Arg
+}
+
+// value writes v as a literal, or binds it to a parameter. Raw values are
+// always written as they are.
+func (r *renderer) value(v interface{}) (string, error) {
+	if !r.params {
+		return r.dialect.Literal(v)
+	}
+	if raw, ok := v.(Raw); ok {
+		return string(raw), nil
+	}
+	if _, err := r.dialect.Literal(v); err != nil {
+		return "", err
+	}
+	marker, name := r.dialect.placeholder(len(r.args) + 1)
+	r.args = append(r.args, Arg{Name: name, Value: v})
+	return marker, nil
+}
+
+// Render writes cond with literal values, e.g. for a condition built apart
+// from a statement
+func (d Dialect) Render(cond Cond) (string, error) {
+	return cond.render(&renderer{dialect: d})
+}
+
+// comparison is column op value
+type comparison struct {
+	column string
+	op     string
+	value  interface{}
+}
+
+// Eq matches column = value; a nil value matches NULL
+func Eq(column string, value interface{}) Cond {
+	return comparison{column: column, op: "=", value: value}
+}
+
+// Neq matches column <> value, which no NULL matches; a nil value matches
+// every value but NULL
+func Neq(column string, value interface{}) Cond {
+	return comparison{column: column, op: "<>", value: value}
+}
+
+// IsNull matches column IS NULL
+func IsNull(column string) Cond {
+	return Eq(column, nil)
+}
+
+// NotNull matches column IS NOT NULL
+func NotNull(column string) Cond {
+	return Neq(column, nil)
+}
+
+// Gt matches column > value
+func Gt(column string, value interface{}) Cond {
+	return comparison{column: column, op: ">", value: value}
+}
+
+// Gte matches column >= value
+func Gte(column string, value interface{}) Cond {
+	return comparison{column: column, op: ">=", value: value}
+}
+
+// Lt matches column < value
+func Lt(column string, value interface{}) Cond {
+	return comparison{column: column, op: "<", value: value}
+}
+
+// Lte matches column <= value
+func Lte(column string, value interface{}) Cond {
+	return comparison{column: column, op: "<=", value: value}
+}
+
+func (c comparison) render(r *renderer) (string, error) {
+	column, err := ValidateColumn(c.column)
+	if err != nil {
+		return "", err
+	}
+	if c.value == nil {
+		switch c.op {
+		case "=":
+			return column + " IS NULL", nil
+		case "<>":
+			return column + " IS NOT NULL", nil
+		}
+		return "", fmt.Errorf("%s needs a value", c.op)
+	}
+	value, err := r.value(c.value)
+	if err != nil {
+		return "", err
+	}
+	return column + " " + c.op + " " + value, nil
+}
+
+func (c comparison) columns() []string { return []string{c.column} }
+
+// Range matches min <= column <= max; a nil bound leaves that side open, so
+// Range(c, 10, nil) is Gte(c, 10). Both bounds nil is an error.
+func Range(column string, min, max interface{}) Cond {
+	var bounds []Cond
+	if min != nil {
+		bounds = append(bounds, Gte(column, min))
+	}
+	if max != nil {
+		bounds = append(bounds, Lte(column, max))
+	}
+	switch len(bounds) {
+	case 0:
+		return invalid{column: column, err: fmt.Errorf("range on '%s' needs a bound", column)}
+	case 1:
+		return bounds[0]
+	default:
+		return And(bounds...)
+	}
+}
+
+// in matches column IN (values), or NOT IN
+type in struct {
+	column string
+	values interface{}
+	not    bool
+}
+
+// In matches column IN (values), values being a slice of at most
+// MaxInValues. A nil in the list matches NULL, which IN alone never does.
+func In(column string, values interface{}) Cond {
+	return in{column: column, values: values}
+}
+
+// NotIn matches column NOT IN (values), values being a slice of at most
+// MaxInValues. Like NOT IN, it matches no NULL; a nil in the list is
+// ignored rather than making it match nothing at all.
+func NotIn(column string, values interface{}) Cond {
+	return in{column: column, values: values, not: true}
+}
+
+func (c in) render(r *renderer) (string, error) {
+	column, err := ValidateColumn(c.column)
+	if err != nil {
+		return "", err
+	}
+	list := reflect.ValueOf(c.values)
+	op := "in"
+	if c.not {
+		op = "not in"
+	}
+	if c.values == nil || (list.Kind() != reflect.Slice && list.Kind() != reflect.Array) {
+		return "", fmt.Errorf("%s needs a list of values", op)
+	}
+	if list.Len() == 0 {
+		return "", fmt.Errorf("%s needs at least one value", op)
+	}
+	if list.Len() > MaxInValues {
+		return "", fmt.Errorf("%s accepts at most %d values", op, MaxInValues)
+	}
+
+	values := make([]string, 0, list.Len())
+	matchNull := false
+	for i := 0; i < list.Len(); i++ {
+		item := list.Index(i).Interface()
+		if item == nil {
+			matchNull = true
+			continue
+		}
+		value, err := r.value(item)
+		if err != nil {
+			return "", err
+		}
+		values = append(values, value)
+	}
+
+	if c.not {
+		if len(values) == 0 {
+			return column + " IS NOT NULL", nil
+		}
+		return column + " NOT IN (" + strings.Join(values, ", ") + ")", nil
+	}
+
+	match := column + " IN (" + strings.Join(values, ", ") + ")"
+	switch {
+	case matchNull && len(values) == 0:
+		return column + " IS NULL", nil
+	case matchNull:
+		return "(" + match + " OR " + column + " IS NULL)", nil
+	default:
+		return match, nil
+	}
+}
+
+func (c in) columns() []string { return []string{c.column} }
+
+// like matches column LIKE pattern, or its case-insensitive substring search
+type like struct {
+	column   string
+	pattern  string
+	contains bool
+}
+
+// Like matches column LIKE pattern; % and _ in pattern are wildcards
+func Like(column, pattern string) Cond {
+	return like{column: column, pattern: pattern}
+}
+
+// Contains matches the rows whose column holds term, ignoring case. The
+// term's LIKE wildcards are escaped, so it matches literally.
+func Contains(column, term string) Cond {
+	return like{column: column, pattern: "%" + EscapeLike(term) + "%", contains: true}
+}
+
+func (c like) render(r *renderer) (string, error) {
+	column, err := ValidateColumn(c.column)
+	if err != nil {
+		return "", err
+	}
+	pattern, err := r.value(c.pattern)
+	if err != nil {
+		return "", err
+	}
+	if !c.contains {
+		return column + " LIKE " + pattern, nil
+	}
+	// BigQuery has no ESCAPE clause; a backslash escapes by default
+	escape := ""
+	if r.dialect != BigQuery {
+		escape = " ESCAPE " + r.dialect.Quote(LikeEscape)
+	}
+	return fmt.Sprintf("LOWER(%s) LIKE LOWER(%s)%s", column, pattern, escape), nil
+}
+
+func (c like) columns() []string { return []string{c.column} }
+
+// group joins conditions with AND or OR, in parentheses
+type group struct {
+	op    string
+	conds []Cond
+}
+
+// And matches the rows every condition matches
+func And(conds ...Cond) Cond {
+	return group{op: " AND ", conds: conds}
+}
+
+// Or matches the rows any of the conditions matches
+func Or(conds ...Cond) Cond {
+	return group{op: " OR ", conds: conds}
+}
+
+func (g group) render(r *renderer) (string, error) {
+	if len(g.conds) == 0 {
+		return "", fmt.Errorf("empty%sgroup", g.op)
+	}
+	parts := make([]string, len(g.conds))
+	for i, cond := range g.conds {
+		part, err := cond.render(r)
+		if err != nil {
+			return "", err
+		}
+		parts[i] = part
+	}
+	return "(" + strings.Join(parts, g.op) + ")", nil
+}
+
+func (g group) columns() []string {
+	var columns []string
+	for _, cond := range g.conds {
+		columns = append(columns, cond.columns()...)
+	}
+	return columns
+}
+
+// After matches the rows past a keyset position of an order by columns,
+// all ascending or all descending: (a > 1 OR (a = 1 AND b > 'x')). values
+// are the columns' values in the last row read and must not be null.
+func After(columns []string, values []interface{}, desc bool) Cond {
+	if len(columns) == 0 || len(columns) != len(values) {
+		return invalid{err: fmt.Errorf("keyset needs one value per column")}
+	}
+	compare := Gt
+	if desc {
+		compare = Lt
+	}
+
+	// Each term fixes the columns before i and moves past column i
+	terms := make([]Cond, len(columns))
+	for i, column := range columns {
+		if values[i] == nil {
+			return invalid{column: column, err: fmt.Errorf("keyset value of '%s' is null", column)}
+		}
+		if i == 0 {
+			terms[i] = compare(column, values[i])
+			continue
+		}
+		parts := make([]Cond, 0, i+1)
+		for j := 0; j < i; j++ {
+			parts = append(parts, Eq(columns[j], values[j]))
+		}
+		terms[i] = And(append(parts, compare(column, values[i]))...)
+	}
+	if len(terms) == 1 {
+		return terms[0]
+	}
+	return Or(terms...)
+}
+
+// invalid is a condition that could not be built; it fails to render
+type invalid struct {
+	column string
+	err    error
+}
+
+func (c invalid) render(*renderer) (string, error) { return "", c.err }
+
+func (c invalid) columns() []string {
+	if c.column == "" {
+		return nil
+	}
+	return []string{c.column}
+}
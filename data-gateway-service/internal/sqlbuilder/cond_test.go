@@ -0,0 +1,119 @@
+package sqlbuilder
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCond_Render(t *testing.T) {
+	tests := map[string]struct {
+		cond     Cond
+		dremio   string
+		bigQuery string
+	}{
+		"eq string":    {Eq("a", "x"), "a = 'x'", "a = 'x'"},
+		"eq null":      {Eq("a", nil), "a IS NULL", "a IS NULL"},
+		"eq bool":      {Eq("a", true), "a = TRUE", "a = TRUE"},
+		"neq":          {Neq("a", "x"), "a <> 'x'", "a <> 'x'"},
+		"is null":      {IsNull("a"), "a IS NULL", "a IS NULL"},
+		"not null":     {NotNull("a"), "a IS NOT NULL", "a IS NOT NULL"},
+		"float":        {Gt("a", 1.5e9), "a > 1500000000", "a > 1500000000"},
+		"lt lte":       {And(Lt("a", int32(3)), Lte("b", float32(0.5))), "(a < 3 AND b <= 0.5)", "(a < 3 AND b <= 0.5)"},
+		"quote":        {Eq("a", `O'Brien\`), `a = 'O''Brien\'`, `a = 'O\'Brien\\'`},
+		"range":        {Range("a", 10, 20), "(a >= 10 AND a <= 20)", "(a >= 10 AND a <= 20)"},
+		"range min":    {Range("a", 10, nil), "a >= 10", "a >= 10"},
+		"range max":    {Range("a", nil, 20), "a <= 20", "a <= 20"},
+		"in":           {In("a", []interface{}{"x", 1}), "a IN ('x', 1)", "a IN ('x', 1)"},
+		"in null":      {In("a", []interface{}{"x", nil}), "(a IN ('x') OR a IS NULL)", "(a IN ('x') OR a IS NULL)"},
+		"in only null": {In("a", []interface{}{nil}), "a IS NULL", "a IS NULL"},
+		"not in":       {NotIn("a", []interface{}{"x", 1}), "a NOT IN ('x', 1)", "a NOT IN ('x', 1)"},
+		"not in null":  {NotIn("a", []interface{}{"x", nil}), "a NOT IN ('x')", "a NOT IN ('x')"},
+		"like":         {Like("a", "ja%n_"), "a LIKE 'ja%n_'", "a LIKE 'ja%n_'"},
+		"contains": {Contains("a", `50%_\`),
+			`LOWER(a) LIKE LOWER('%50\%\_\\%') ESCAPE '\'`,
+			`LOWER(a) LIKE LOWER('%50\\%\\_\\\\%')`},
+		"or of and": {Or(Eq("a", 1), And(Eq("b", 2), Eq("c", 3))),
+			"(a = 1 OR (b = 2 AND c = 3))", "(a = 1 OR (b = 2 AND c = 3))"},
+		"quoted column": {Eq(`"a"`, 1), "a = 1", "a = 1"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := Dremio.Render(tt.cond)
+			require.NoError(t, err)
+			assert.Equal(t, tt.dremio, got)
+			got, err = BigQuery.Render(tt.cond)
+			require.NoError(t, err)
+			assert.Equal(t, tt.bigQuery, got)
+		})
+	}
+}
+
+func TestCond_RenderErrors(t *testing.T) {
+	tooMany := make([]int, MaxInValues+1)
+	tests := map[string]struct {
+		cond Cond
+		want string
+	}{
+		"column":         {Eq("a b", 1), "invalid column name: 'a b'"},
+		"gt null":        {Gt("a", nil), "> needs a value"},
+		"range no bound": {Range("a", nil, nil), "range on 'a' needs a bound"},
+		"in not a list":  {In("a", "x"), "in needs a list of values"},
+		"in empty":       {In("a", []string{}), "in needs at least one value"},
+		"in too many":    {In("a", tooMany), "in accepts at most 1000 values"},
+		"not in empty":   {NotIn("a", []string{}), "not in needs at least one value"},
+		"value type":     {Eq("a", []byte("x")), "unsupported filter value type []uint8"},
+		"not a number":   {Eq("a", math.NaN()), "is not a number literal"},
+		"infinity":       {Lt("a", math.Inf(1)), "is not a number literal"},
+		"empty group":    {And(), "empty AND group"},
+		"nested":         {Or(Eq("a", 1), Eq("b--", 2)), "invalid column name: 'b--'"},
+		"keyset values":  {After([]string{"a", "b"}, []interface{}{1}, false), "one value per column"},
+		"keyset null":    {After([]string{"a"}, []interface{}{nil}, false), "keyset value of 'a' is null"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Dremio.Render(tt.cond)
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+}
+
+func TestAfter(t *testing.T) {
+	got, err := Dremio.Render(After([]string{"a"}, []interface{}{int64(1)}, false))
+	require.NoError(t, err)
+	assert.Equal(t, "a > 1", got)
+
+	got, err = Dremio.Render(After([]string{"a", "b", "c"}, []interface{}{int64(1), "x", 2.5}, true))
+	require.NoError(t, err)
+	assert.Equal(t, "(a < 1 OR (a = 1 AND b < 'x') OR (a = 1 AND b = 'x' AND c < 2.5))", got)
+}
+
+func TestValidate(t *testing.T) {
+	for _, table := range []string{"t", "nessie_iceberg.tender_data", "`gtp-data-prod.layer_isb.rup_kromaster`"} {
+		_, err := ValidateTable(table)
+		assert.NoError(t, err, table)
+	}
+	for _, table := range []string{"", "t x", "t;", "t/*", "sp_who", "t.0x1", "drop_me"} {
+		_, err := ValidateTable(table)
+		assert.Error(t, err, table)
+	}
+
+	for _, column := range []string{"a", "_event_date", "A1"} {
+		_, err := ValidateColumn(column)
+		assert.NoError(t, err, column)
+	}
+	for _, column := range []string{"", "1a", "a.b", "a-b", "a b", "COUNT(*)"} {
+		_, err := ValidateColumn(column)
+		assert.Error(t, err, column)
+	}
+
+	for dir, want := range map[string]string{"": "ASC", "asc": "ASC", " desc ": "DESC"} {
+		got, err := ValidateDirection(dir)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+	_, err := ValidateDirection("DESC, a")
+	assert.Error(t, err)
+}
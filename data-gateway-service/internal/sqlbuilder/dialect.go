@@ -0,0 +1,232 @@
+// Package sqlbuilder builds the SELECT statements the gateway sends to Dremio
+// and BigQuery. Identifiers are validated, values are rendered as literals or
+// parameters of the target dialect, and nothing else is interpolated, so a
+// statement built from request input cannot carry SQL of its own.
+package sqlbuilder
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Dialect selects how table names, string literals and parameters are
+// written
+type Dialect int
+
+const (
+	// Dremio writes table names as they are, doubles single quotes in
+	// strings and has positional ? parameters. It is plain ANSI SQL.
+	Dremio Dialect = iota
+	// BigQuery backticks table names, backslash-escapes strings and has
+	// named @p1 parameters
+	BigQuery
+)
+
+// String returns the name of d
+func (d Dialect) String() string {
+	if d == BigQuery {
+		return "bigquery"
+	}
+	return "dremio"
+}
+
+// ansiEscaper escapes an ANSI string literal by doubling single quotes and
+// dropping null bytes
+var ansiEscaper = strings.NewReplacer(`'`, `''`, "\x00", "")
+
+// bigQueryEscaper escapes a BigQuery string literal, where a backslash
+// starts an escape sequence and raw newlines are not allowed
+var bigQueryEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\n", `\n`, "\r", `\r`, "\x00", "")
+
+// Quote returns v as a string literal
+func (d Dialect) Quote(v string) string {
+	if d == BigQuery {
+		return "'" + bigQueryEscaper.Replace(v) + "'"
+	}
+	return "'" + ansiEscaper.Replace(v) + "'"
+}
+
+// Table returns a validated table name as written in a FROM clause
+func (d Dialect) Table(name string) (string, error) {
+	table, err := ValidateTable(name)
+	if err != nil {
+		return "", err
+	}
+	if d == BigQuery {
+		return "`" + table + "`", nil
+	}
+	return table, nil
+}
+
+// Literal renders a string, bool, integer or finite float as a literal;
+// Raw values are written as they are
+func (d Dialect) Literal(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case Raw:
+		return string(v), nil
+	case string:
+		return d.Quote(v), nil
+	case bool:
+		if v {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int32:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float32:
+		return d.Literal(float64(v))
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return "", fmt.Errorf("%v is not a number literal", v)
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	default:
+		return "", fmt.Errorf("unsupported filter value type %T", value)
+	}
+}
+
+// asOfLayout is how AsOf writes its timestamp, in UTC
+const asOfLayout = "2006-01-02 15:04:05.000"
+
+// AsOf returns the clause following a table name that reads the table as it
+// was at t: AT TIMESTAMP on Dremio's Iceberg tables, or AT BRANCH ... AS OF
+// for a Nessie branch other than the session's, and FOR SYSTEM_TIME AS OF on
+// BigQuery, which has no branches
+func (d Dialect) AsOf(t time.Time, branch string) (string, error) {
+	timestamp := "'" + t.UTC().Format(asOfLayout) + "'"
+	switch {
+	case d == BigQuery && branch != "":
+		return "", fmt.Errorf("bigquery tables have no branches")
+	case d == BigQuery:
+		return "FOR SYSTEM_TIME AS OF TIMESTAMP " + timestamp, nil
+	case branch == "":
+		return "AT TIMESTAMP " + timestamp, nil
+	case !branchPattern.MatchString(branch):
+		return "", fmt.Errorf("invalid branch name: '%s'", branch)
+	}
+	return `AT BRANCH "` + branch + `" AS OF ` + timestamp, nil
+}
+
+// Sample returns the clause following a table name that reads about percent
+// of its storage blocks: TABLESAMPLE SYSTEM on BigQuery, which bills only the
+// blocks read. Dremio has no sampling.
+func (d Dialect) Sample(percent float64) (string, error) {
+	if d != BigQuery {
+		return "", fmt.Errorf("%s tables cannot be sampled", d)
+	}
+	if math.IsNaN(percent) || percent <= 0 || percent > 100 {
+		return "", fmt.Errorf("sample percent must be over 0 and at most 100, got %v", percent)
+	}
+	return "TABLESAMPLE SYSTEM (" + strconv.FormatFloat(percent, 'f', -1, 64) + " PERCENT)", nil
+}
+
+// placeholder returns the parameter marker of the nth (1-based) argument
+// and the name it is bound by; Dremio's are positional and unnamed
+func (d Dialect) placeholder(n int) (marker, name string) {
+	if d == BigQuery {
+		name = "p" + strconv.Itoa(n)
+		return "@" + name, name
+	}
+	return "?", ""
+}
+
+// Raw is trusted SQL written as it is where a value is expected, such as a
+// dialect's TIMESTAMP '2025-01-01 00:00:00'. It must never hold request input.
+type Raw string
+
+// Arg is a parameter of a parameterized statement. Name is empty for
+// Dremio's positional parameters.
+type Arg struct {
+	Name  string
+	Value interface{}
+}
+
+var (
+	// Schema-qualified names of letters, digits, underscores, dashes and dots
+	tablePattern = regexp.MustCompile(`^[a-zA-Z0-9_\-\.]+$`)
+	// Simple column names: no functions or expressions
+	columnPattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+	// Positions in the select list, for GROUP BY 1 and ORDER BY 1
+	ordinalPattern = regexp.MustCompile(`^[1-9][0-9]{0,2}$`)
+	// Nessie branch names, written quoted
+	branchPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.\-/]*$`)
+)
+
+// dangerousTablePatterns are substrings no table name may contain
+var dangerousTablePatterns = []string{
+	"select", "insert", "update", "delete", "drop", "create",
+	"alter", "exec", "execute", "union", "--", "/*", "*/",
+	";", "xp_", "sp_", "0x", "\\x",
+}
+
+// identifierQuotes are stripped from identifiers before validation
+var identifierQuotes = strings.NewReplacer("`", "", "'", "", `"`, "")
+
+// ValidateTable returns table without quotes, or an error when it is not a
+// plain schema-qualified name
+func ValidateTable(table string) (string, error) {
+	table = identifierQuotes.Replace(table)
+	if !tablePattern.MatchString(table) {
+		return "", fmt.Errorf("invalid table name format: '%s'", table)
+	}
+	lower := strings.ToLower(table)
+	for _, pattern := range dangerousTablePatterns {
+		if strings.Contains(lower, pattern) {
+			return "", fmt.Errorf("potential SQL injection detected in table name: '%s'", table)
+		}
+	}
+	return table, nil
+}
+
+// ValidateColumn returns column without quotes, or an error when it is not
+// a simple column name
+func ValidateColumn(column string) (string, error) {
+	column = identifierQuotes.Replace(column)
+	if !columnPattern.MatchString(column) {
+		return "", fmt.Errorf("invalid column name: '%s'", column)
+	}
+	return column, nil
+}
+
+// ValidateDirection returns ASC or DESC for an ORDER BY direction; empty
+// means ASC
+func ValidateDirection(dir string) (string, error) {
+	dir = strings.ToUpper(strings.TrimSpace(dir))
+	if dir != "ASC" && dir != "DESC" && dir != "" {
+		return "", fmt.Errorf("invalid order direction: '%s'", dir)
+	}
+	if dir == "" {
+		dir = "ASC"
+	}
+	return dir, nil
+}
+
+// validateTerm validates a GROUP BY or ORDER BY term: a column, or the
+// position of a selected column
+func validateTerm(term string) (string, error) {
+	if ordinalPattern.MatchString(term) {
+		return term, nil
+	}
+	return ValidateColumn(term)
+}
+
+// LikeEscape is the escape character of Contains patterns, the default
+// escape of BigQuery LIKE
+const LikeEscape = `\`
+
+// likeEscaper escapes the LIKE metacharacters % and _, and the escape
+// character itself
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// EscapeLike escapes term for use in a LIKE pattern with LikeEscape
+func EscapeLike(term string) string {
+	return likeEscaper.Replace(term)
+}
@@ -0,0 +1,229 @@
+// Package sqltext normalizes the text of SQL statements. Comments and
+// layout do not change what a query does, so checks and cache keys look at
+// the normalized text, while the upstream engine may still be sent comments
+// that carry hints for it.
+//
+// Where a comment starts depends on where each literal and quoted
+// identifier ends, which depends on how the engine escapes quotes, so every
+// function takes the Dialect of the engine the statement is sent to. Read-only
+// checks rely on this: text the engine runs must never be taken for a comment.
+package sqltext
+
+import "strings"
+
+// Dialect is how an engine escapes quotes within literals and quoted
+// identifiers
+type Dialect int
+
+const (
+	// Standard escapes a quote by doubling it. A backslash before a quote is
+	// read both ways, as engines such as Dremio may or may not honour it:
+	// when the two readings end a literal or quoted identifier in different
+	// places, the rest of the statement is kept as text, comments included.
+	Standard Dialect = iota
+
+	// BigQuery also escapes any character with a backslash, in string
+	// literals and quoted identifiers alike, and quoted identifiers may be
+	// "double" as well as `back` quoted. A literal opened with three quotes
+	// ends at the next three, and one prefixed with r is raw: as whether a
+	// backslash escapes its quote is read both ways, as in Standard.
+	BigQuery
+)
+
+// Normalize returns sql without comments and with every run of whitespace
+// outside literals and quoted identifiers collapsed into a single space,
+// trimmed. Queries that differ only in comments and layout normalize to the
+// same text. The case of keywords is kept, as it cannot be told apart from
+// that of identifiers without parsing.
+func Normalize(sql string, dialect Dialect) string {
+	var b strings.Builder
+	b.Grow(len(sql))
+
+	space := false // A separator is due before the next text
+	scan(sql, dialect, func(text string, kind segment) {
+		if kind != segmentText {
+			space = true
+			return
+		}
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteString(text)
+	})
+	return b.String()
+}
+
+// StripComments returns sql with every comment blanked out: its characters
+// are replaced with spaces and its line breaks kept, so line and column
+// positions reported by the engine still point into sql.
+func StripComments(sql string, dialect Dialect) string {
+	var b strings.Builder
+	b.Grow(len(sql))
+
+	scan(sql, dialect, func(text string, kind segment) {
+		if kind != segmentComment {
+			b.WriteString(text)
+			return
+		}
+		for i := 0; i < len(text); i++ {
+			if text[i] == '\n' || text[i] == '\r' {
+				b.WriteByte(text[i])
+			} else {
+				b.WriteByte(' ')
+			}
+		}
+	})
+	return b.String()
+}
+
+// segment is the lexical class of a stretch of a statement
+type segment int
+
+const (
+	segmentText       segment = iota // Tokens, literals and quoted identifiers
+	segmentWhitespace                // A run of whitespace
+	segmentComment                   // A -- line comment, up to its line break, or a /* block */ comment
+)
+
+// scan calls emit with the consecutive segments of sql, which together make
+// up sql. Literals and quoted identifiers end as dialect escapes them; an
+// unterminated literal or comment runs to the end of sql.
+func scan(sql string, dialect Dialect, emit func(text string, kind segment)) {
+	for i := 0; i < len(sql); {
+		start := i
+		switch c := sql[i]; {
+		case isSpace(c):
+			for i < len(sql) && isSpace(sql[i]) {
+				i++
+			}
+			emit(sql[start:i], segmentWhitespace)
+		case strings.HasPrefix(sql[i:], "--"):
+			i = IndexOrEnd(sql, i, "\n")
+			emit(sql[start:i], segmentComment)
+		case strings.HasPrefix(sql[i:], "/*"):
+			i = min(IndexOrEnd(sql, i+2, "*/")+2, len(sql))
+			emit(sql[start:i], segmentComment)
+		default:
+			for i < len(sql) && !isSpace(sql[i]) && !strings.HasPrefix(sql[i:], "--") && !strings.HasPrefix(sql[i:], "/*") {
+				if end, ok := dialect.QuotedAt(sql, i); ok {
+					i = end
+				} else {
+					i++
+				}
+			}
+			emit(sql[start:i], segmentText)
+		}
+	}
+}
+
+// QuotedAt reports whether a literal or quoted identifier starts at i,
+// including a BigQuery literal's r or b prefix, and returns the index just
+// past the quote closing it, as dialect escapes quotes
+func (d Dialect) QuotedAt(sql string, i int) (end int, ok bool) {
+	quote, raw := i, false
+	if d == BigQuery {
+		quote, raw = literalPrefix(sql, i)
+	}
+	if quote >= len(sql) || (sql[quote] != '\'' && sql[quote] != '"' && sql[quote] != '`') {
+		return 0, false
+	}
+	return d.quotedEnd(sql, quote, raw), true
+}
+
+// quotedEnd returns the index just past the quote closing the literal or
+// quoted identifier at start, as dialect escapes quotes; raw is set for a
+// BigQuery literal prefixed with r
+func (d Dialect) quotedEnd(sql string, start int, raw bool) int {
+	read := QuotedEnd
+	if d == BigQuery {
+		if !raw {
+			return bigQueryQuotedEnd(sql, start, true)
+		}
+		read = bigQueryQuotedEnd
+	}
+	if end := read(sql, start, false); end == read(sql, start, true) {
+		return end
+	}
+	return len(sql)
+}
+
+// literalPrefix returns the index of the quote opening a BigQuery literal
+// with an r (raw) or b (bytes) prefix at i, in either case and order, and
+// whether the literal is raw. Without a prefix it returns i.
+func literalPrefix(sql string, i int) (quote int, raw bool) {
+	if i > 0 && isWordPart(sql[i-1]) {
+		return i, false // Within an identifier
+	}
+	var prefix string
+	j := i
+	for ; j < len(sql) && j-i < 2; j++ {
+		c := sql[j] | 0x20 // Lower case
+		if (c != 'r' && c != 'b') || strings.IndexByte(prefix, c) >= 0 {
+			break
+		}
+		prefix += string(c)
+	}
+	if j == i || j >= len(sql) || (sql[j] != '\'' && sql[j] != '"') {
+		return i, false
+	}
+	return j, strings.IndexByte(prefix, 'r') >= 0
+}
+
+// bigQueryQuotedEnd is QuotedEnd as BigQuery reads quotes: a literal opened
+// with three quotes ends at the next three
+func bigQueryQuotedEnd(sql string, start int, backslashEscapes bool) int {
+	quote := sql[start]
+	triple := strings.Repeat(string(quote), 3)
+	if quote == '`' || !strings.HasPrefix(sql[start:], triple) {
+		return QuotedEnd(sql, start, backslashEscapes)
+	}
+	for i := start + 3; i < len(sql); i++ {
+		switch {
+		case backslashEscapes && sql[i] == '\\':
+			i++
+		case strings.HasPrefix(sql[i:], triple):
+			return i + 3
+		}
+	}
+	return len(sql)
+}
+
+// IndexOrEnd returns the index of substr in sql from start, or len(sql)
+func IndexOrEnd(sql string, start int, substr string) int {
+	if start >= len(sql) {
+		return len(sql)
+	}
+	if idx := strings.Index(sql[start:], substr); idx >= 0 {
+		return start + idx
+	}
+	return len(sql)
+}
+
+// QuotedEnd returns the index just past the quote closing the quoted text at
+// start. A doubled quote is part of the text, as is a backslash-escaped
+// character when backslashEscapes is set.
+func QuotedEnd(sql string, start int, backslashEscapes bool) int {
+	quote := sql[start]
+	for i := start + 1; i < len(sql); i++ {
+		switch {
+		case backslashEscapes && sql[i] == '\\':
+			i++
+		case sql[i] == quote:
+			if i+1 < len(sql) && sql[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(sql)
+}
+
+func isWordPart(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c >= 0x80
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
@@ -0,0 +1,75 @@
+package sqltext
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name, sql, want string
+	}{
+		{"leading line comment", "-- dashboard xyz\nSELECT * FROM t", "SELECT * FROM t"},
+		{"block comments", "/* weekly */ SELECT a,/* b */c FROM t /* end", "SELECT a, c FROM t"},
+		{"comment between words", "SELECT a/**/FROM t", "SELECT a FROM t"},
+		{"layout", "  SELECT *\n\tFROM   t\r\n WHERE x = 1  ", "SELECT * FROM t WHERE x = 1"},
+		{"quotes in comments", "-- it's the board's query\nSELECT 1 /* don't \"stop\" */ FROM t", "SELECT 1 FROM t"},
+		{"markers in literals", "SELECT '-- kept', '/* kept */' FROM t", "SELECT '-- kept', '/* kept */' FROM t"},
+		{"spaces in literals", "SELECT 'a   b' AS \"x  y\" FROM `p  q`", "SELECT 'a   b' AS \"x  y\" FROM `p  q`"},
+		{"doubled quote", "SELECT 'it''s -- kept' -- dropped\nFROM t", "SELECT 'it''s -- kept' FROM t"},
+		{"backslash escape", `SELECT 'a\' -- kept' FROM t`, `SELECT 'a\' -- kept' FROM t`},
+		{"unterminated literal", "SELECT 'open -- kept", "SELECT 'open -- kept"},
+		{"only comments", "-- nothing\n/* here */", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Normalize(tt.sql, Standard))
+			assert.Equal(t, tt.want, Normalize(tt.sql, BigQuery))
+		})
+	}
+
+	// Queries differing only in comments and layout normalize alike
+	assert.Equal(t, Normalize("SELECT *\nFROM t -- v1", Standard), Normalize("/* v2 */ SELECT * FROM t", Standard))
+}
+
+func TestStripComments(t *testing.T) {
+	sql := "-- it's\nSELECT 'a -- b', /* x\ny */ c FROM t"
+	stripped := StripComments(sql, Standard)
+	assert.Equal(t, "       \nSELECT 'a -- b',     \n     c FROM t", stripped)
+	assert.Len(t, stripped, len(sql), "positions are kept")
+
+	assert.Equal(t, "SELECT 1", StripComments("SELECT 1", BigQuery))
+}
+
+func TestNormalize_Dialects(t *testing.T) {
+	// BigQuery escapes quotes in quoted identifiers with a backslash too, so
+	// the comment markers are inside them
+	for _, sql := range []string{
+		`SELECT "a\" -- " AS x; DROP TABLE ds.t`,
+		"SELECT `a\\` -- ` FROM t; DELETE FROM ds.t WHERE true",
+	} {
+		assert.Equal(t, sql, Normalize(sql, BigQuery))
+	}
+
+	// Standard keeps a quoted text whose end depends on the backslash, and
+	// all that follows it
+	for _, sql := range []string{
+		`SELECT "a\" -- " AS x; DROP TABLE ds.t`,
+		`SELECT 'a\'' -- ' ; DROP TABLE t`,
+	} {
+		assert.Equal(t, sql, Normalize(sql, Standard))
+	}
+	assert.Equal(t, `SELECT 'a\''`, Normalize(`SELECT 'a\'' -- ' ; DROP TABLE t`, BigQuery))
+	assert.Equal(t, `SELECT "a""b" FROM t`, Normalize(`SELECT "a""b" -- x`+"\nFROM t", Standard))
+	// BigQuery ends a triple-quoted literal at the next three quotes, and
+	// keeps what follows a raw literal whose end depends on a backslash
+	for _, sql := range []string{
+		"SELECT '''x'y -- ''' ; DROP TABLE ds.t",
+		"SELECT r'\\' , '-- ' ; DROP TABLE ds.t",
+	} {
+		assert.Equal(t, sql, Normalize(sql, BigQuery))
+	}
+	assert.Equal(t, `SELECT b"""a -- """ FROM t`, Normalize(`SELECT b"""a -- """ -- x`+"\nFROM t", BigQuery))
+	assert.Equal(t, `SELECT Rb'a\d' FROM t`, Normalize(`SELECT Rb'a\d' -- x`+"\nFROM t", BigQuery))
+}
@@ -0,0 +1,153 @@
+// Package streamquota limits the concurrent streaming connections of each API
+// key, so one consumer cannot hold every connection of the data source pools.
+// Streams are counted in Redis when clustered, and in memory otherwise.
+package streamquota
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// releaseTimeout bounds the release of a stream, which runs after the
+// request context may already be cancelled
+const releaseTimeout = 5 * time.Second
+
+// LimitError is returned when an API key already has its limit of streams
+type LimitError struct {
+	Key    string
+	Limit  int
+	Active int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("API key %s has %d active streams (limit %d)", e.Key, e.Active, e.Limit)
+}
+
+// KeyCount is the active stream count of an API key
+type KeyCount struct {
+	Key    string `json:"api_key_id"`
+	Active int    `json:"active"`
+}
+
+// Limiter admits a stream while its API key is under the limit
+type Limiter struct {
+	store       Store
+	limit       int
+	ttl         time.Duration
+	owner       string
+	distributed bool
+	seq         atomic.Int64
+	logger      *zap.Logger
+}
+
+// NewLimiter creates a limiter allowing limit concurrent streams per key. A
+// Redis-backed stream is refreshed every third of ttl while it runs, so one
+// left by a crashed replica stops counting after ttl. owner prefixes this
+// replica's stream ids.
+func NewLimiter(store Store, limit int, ttl time.Duration, owner string, logger *zap.Logger) *Limiter {
+	if ttl <= 0 {
+		ttl = time.Minute
+	}
+	_, distributed := store.(*RedisStore)
+	return &Limiter{
+		store:       store,
+		limit:       limit,
+		ttl:         ttl,
+		owner:       owner,
+		distributed: distributed,
+		logger:      logger,
+	}
+}
+
+// Limit returns the concurrent streams allowed per key
+func (l *Limiter) Limit() int {
+	return l.limit
+}
+
+// Distributed reports whether streams are counted across replicas
+func (l *Limiter) Distributed() bool {
+	return l.distributed
+}
+
+// Acquire registers a stream of key, returning a *LimitError when key already
+// has its limit. The caller must Release the returned stream when it ends.
+// When the store fails the stream is admitted uncounted.
+func (l *Limiter) Acquire(ctx context.Context, key string) (*Stream, error) {
+	id := fmt.Sprintf("%s-%d", l.owner, l.seq.Add(1))
+	ok, active, err := l.store.Acquire(ctx, key, id, l.limit, l.ttl)
+	if err != nil {
+		l.logger.Warn("STREAM QUOTA UNAVAILABLE: store failed, admitting stream without a limit",
+			zap.String("api_key_id", key), zap.Error(err))
+		return &Stream{}, nil
+	}
+	if !ok {
+		return nil, &LimitError{Key: key, Limit: l.limit, Active: active}
+	}
+
+	stream := &Stream{limiter: l, key: key, id: id, done: make(chan struct{})}
+	if l.distributed {
+		go stream.refresh()
+	}
+	return stream, nil
+}
+
+// Counts returns the active stream count of every key with one, sorted by key
+func (l *Limiter) Counts(ctx context.Context) ([]KeyCount, error) {
+	counts, err := l.store.Counts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]KeyCount, 0, len(counts))
+	for key, active := range counts {
+		keys = append(keys, KeyCount{Key: key, Active: active})
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Key < keys[j].Key })
+	return keys, nil
+}
+
+// Stream is an admitted stream
+type Stream struct {
+	limiter  *Limiter
+	key      string
+	id       string
+	done     chan struct{}
+	released atomic.Bool
+}
+
+// Release unregisters the stream; later calls do nothing
+func (s *Stream) Release() {
+	if s.limiter == nil || !s.released.CompareAndSwap(false, true) {
+		return
+	}
+	close(s.done)
+
+	ctx, cancel := context.WithTimeout(context.Background(), releaseTimeout)
+	defer cancel()
+	if err := s.limiter.store.Release(ctx, s.key, s.id); err != nil {
+		s.limiter.logger.Warn("Failed to release stream; it stops counting when its TTL expires",
+			zap.String("api_key_id", s.key), zap.Error(err))
+	}
+}
+
+// refresh extends the stream's TTL until it is released
+func (s *Stream) refresh() {
+	ticker := time.NewTicker(s.limiter.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), releaseTimeout)
+			if err := s.limiter.store.Refresh(ctx, s.key, s.id, s.limiter.ttl); err != nil {
+				s.limiter.logger.Warn("Failed to refresh stream TTL", zap.String("api_key_id", s.key), zap.Error(err))
+			}
+			cancel()
+		}
+	}
+}
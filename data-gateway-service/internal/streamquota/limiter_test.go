@@ -0,0 +1,153 @@
+package streamquota
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newTestRedisStores(t *testing.T, n int) (*miniredis.Miniredis, []*RedisStore) {
+	mr := miniredis.RunT(t)
+	stores := make([]*RedisStore, n)
+	for i := range stores {
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		t.Cleanup(func() { client.Close() })
+		stores[i] = NewRedisStore(client)
+	}
+	return mr, stores
+}
+
+func TestLimiter_ConcurrentAcquireRespectsLimit(t *testing.T) {
+	const attempts = 30
+	limiter := NewLimiter(NewMemoryStore(), 3, time.Minute, "replica-a", zap.NewNop())
+
+	var admitted atomic.Int32
+	var rejected []*LimitError
+	var mu sync.Mutex
+	streams := make(chan *Stream, attempts)
+	var wg sync.WaitGroup
+	for range attempts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stream, err := limiter.Acquire(context.Background(), "analyst")
+			if err != nil {
+				mu.Lock()
+				rejected = append(rejected, err.(*LimitError))
+				mu.Unlock()
+				return
+			}
+			admitted.Add(1)
+			streams <- stream
+		}()
+	}
+	wg.Wait()
+	close(streams)
+
+	assert.Equal(t, int32(3), admitted.Load())
+	require.Len(t, rejected, attempts-3)
+	assert.Equal(t, &LimitError{Key: "analyst", Limit: 3, Active: 3}, rejected[0])
+
+	// Other keys have their own limit
+	other, err := limiter.Acquire(context.Background(), "reporting")
+	require.NoError(t, err)
+	defer other.Release()
+
+	counts, err := limiter.Counts(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []KeyCount{{Key: "analyst", Active: 3}, {Key: "reporting", Active: 1}}, counts)
+
+	// Releasing, twice even, frees exactly one slot each
+	for stream := range streams {
+		stream.Release()
+		stream.Release()
+	}
+	counts, err = limiter.Counts(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []KeyCount{{Key: "reporting", Active: 1}}, counts)
+}
+
+func TestLimiter_RedisSharedAcrossReplicas(t *testing.T) {
+	ctx := context.Background()
+	_, stores := newTestRedisStores(t, 2)
+	a := NewLimiter(stores[0], 2, time.Minute, "replica-a", zap.NewNop())
+	b := NewLimiter(stores[1], 2, time.Minute, "replica-b", zap.NewNop())
+	assert.True(t, a.Distributed())
+
+	first, err := a.Acquire(ctx, "analyst")
+	require.NoError(t, err)
+	_, err = b.Acquire(ctx, "analyst")
+	require.NoError(t, err)
+
+	_, err = a.Acquire(ctx, "analyst")
+	var limitErr *LimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, 2, limitErr.Active)
+
+	first.Release()
+	third, err := a.Acquire(ctx, "analyst")
+	require.NoError(t, err)
+	defer third.Release()
+
+	counts, err := b.Counts(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []KeyCount{{Key: "analyst", Active: 2}}, counts)
+}
+
+func TestRedisStore_ExpiresStreamsOfCrashedReplica(t *testing.T) {
+	ctx := context.Background()
+	_, stores := newTestRedisStores(t, 2)
+	now := time.Now()
+	for _, store := range stores {
+		store.now = func() time.Time { return now }
+	}
+
+	// replica-a registers a stream and crashes without releasing it
+	ok, _, err := stores[0].Acquire(ctx, "analyst", "replica-a-1", 1, time.Minute)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ok, active, err := stores[1].Acquire(ctx, "analyst", "replica-b-1", 1, time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, 1, active)
+
+	// A refreshed stream keeps counting past its first expiry
+	now = now.Add(50 * time.Second)
+	require.NoError(t, stores[0].Refresh(ctx, "analyst", "replica-a-1", time.Minute))
+	now = now.Add(50 * time.Second)
+	ok, _, err = stores[1].Acquire(ctx, "analyst", "replica-b-1", 1, time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// Without refreshes it stops counting once its TTL passes
+	now = now.Add(61 * time.Second)
+	counts, err := stores[1].Counts(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, counts)
+
+	ok, active, err = stores[1].Acquire(ctx, "analyst", "replica-b-1", 1, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 1, active)
+}
+
+func TestLimiter_StoreUnavailableAdmits(t *testing.T) {
+	mr, stores := newTestRedisStores(t, 1)
+	mr.Close()
+	limiter := NewLimiter(stores[0], 1, time.Minute, "replica-a", zap.NewNop())
+
+	for range 3 {
+		stream, err := limiter.Acquire(context.Background(), "analyst")
+		require.NoError(t, err)
+		stream.Release()
+	}
+}
@@ -0,0 +1,170 @@
+package streamquota
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisStreamPrefix prefixes the sorted set of an API key's active streams
+const redisStreamPrefix = "gateway:streams:"
+
+// Store tracks the active streams of each API key
+type Store interface {
+	// Acquire registers stream id under key unless limit streams are already
+	// active. It returns whether id was registered and the active count,
+	// including id when it was.
+	Acquire(ctx context.Context, key, id string, limit int, ttl time.Duration) (bool, int, error)
+	// Refresh extends the lifetime of a registered stream by ttl
+	Refresh(ctx context.Context, key, id string, ttl time.Duration) error
+	// Release unregisters stream id
+	Release(ctx context.Context, key, id string) error
+	// Counts returns the active stream count of every key with one
+	Counts(ctx context.Context) (map[string]int, error)
+}
+
+// MemoryStore counts the streams of this replica only
+type MemoryStore struct {
+	mu      sync.Mutex
+	streams map[string]map[string]struct{}
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{streams: make(map[string]map[string]struct{})}
+}
+
+// Acquire implements Store
+func (s *MemoryStore) Acquire(ctx context.Context, key, id string, limit int, ttl time.Duration) (bool, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	active := len(s.streams[key])
+	if active >= limit {
+		return false, active, nil
+	}
+	if s.streams[key] == nil {
+		s.streams[key] = make(map[string]struct{})
+	}
+	s.streams[key][id] = struct{}{}
+	return true, active + 1, nil
+}
+
+// Refresh implements Store; in-memory streams live until released
+func (s *MemoryStore) Refresh(ctx context.Context, key, id string, ttl time.Duration) error {
+	return nil
+}
+
+// Release implements Store
+func (s *MemoryStore) Release(ctx context.Context, key, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.streams[key], id)
+	if len(s.streams[key]) == 0 {
+		delete(s.streams, key)
+	}
+	return nil
+}
+
+// Counts implements Store
+func (s *MemoryStore) Counts(ctx context.Context) (map[string]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := make(map[string]int, len(s.streams))
+	for key, ids := range s.streams {
+		counts[key] = len(ids)
+	}
+	return counts, nil
+}
+
+// RedisStore counts streams across replicas. Each key is a sorted set of
+// stream ids scored by their expiry: a stream a crashed replica never
+// released drops out once its expiry passes, and is pruned by the next
+// Acquire of that key.
+type RedisStore struct {
+	client *redis.Client
+	now    func() time.Time
+}
+
+// NewRedisStore creates a store shared by every replica using client
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client, now: time.Now}
+}
+
+// Acquire implements Store. The stream is added before the count is read, so
+// concurrent acquisitions at the limit may both be refused but never both
+// admitted.
+func (s *RedisStore) Acquire(ctx context.Context, key, id string, limit int, ttl time.Duration) (bool, int, error) {
+	setKey := redisStreamPrefix + key
+	now := s.now()
+
+	if err := s.client.ZRemRangeByScore(ctx, setKey, "-inf", score(now)).Err(); err != nil {
+		return false, 0, err
+	}
+	if err := s.client.ZAdd(ctx, setKey, redis.Z{Score: float64(now.Add(ttl).UnixMilli()), Member: id}).Err(); err != nil {
+		return false, 0, err
+	}
+	active, err := s.client.ZCard(ctx, setKey).Result()
+	if err != nil {
+		return false, 0, err
+	}
+	if int(active) > limit {
+		if err := s.client.ZRem(ctx, setKey, id).Err(); err != nil {
+			return false, 0, err
+		}
+		return false, int(active) - 1, nil
+	}
+
+	// The set outlives its longest stream by one ttl at most
+	if err := s.client.PExpire(ctx, setKey, 2*ttl).Err(); err != nil {
+		return false, 0, err
+	}
+	return true, int(active), nil
+}
+
+// Refresh implements Store
+func (s *RedisStore) Refresh(ctx context.Context, key, id string, ttl time.Duration) error {
+	setKey := redisStreamPrefix + key
+	expiry := s.now().Add(ttl)
+	if err := s.client.ZAddXX(ctx, setKey, redis.Z{Score: float64(expiry.UnixMilli()), Member: id}).Err(); err != nil {
+		return err
+	}
+	return s.client.PExpire(ctx, setKey, 2*ttl).Err()
+}
+
+// Release implements Store
+func (s *RedisStore) Release(ctx context.Context, key, id string) error {
+	return s.client.ZRem(ctx, redisStreamPrefix+key, id).Err()
+}
+
+// Counts implements Store; expired streams are not counted
+func (s *RedisStore) Counts(ctx context.Context) (map[string]int, error) {
+	counts := make(map[string]int)
+	unexpired := "(" + score(s.now())
+	iter := s.client.Scan(ctx, 0, redisStreamPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		setKey := iter.Val()
+		active, err := s.client.ZCount(ctx, setKey, unexpired, "+inf").Result()
+		if err != nil {
+			return nil, err
+		}
+		if active > 0 {
+			counts[strings.TrimPrefix(setKey, redisStreamPrefix)] = int(active)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// score formats t as a sorted set score in Unix milliseconds
+func score(t time.Time) string {
+	return strconv.FormatInt(t.UnixMilli(), 10)
+}
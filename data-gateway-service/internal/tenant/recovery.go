@@ -0,0 +1,132 @@
+package tenant
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/datasource"
+)
+
+// Backoff between construction attempts of a source that failed at startup
+const (
+	initRetryMin = 2 * time.Second
+	initRetryMax = time.Minute
+)
+
+// probeCacheTTL is how long a successful health probe is reused
+const probeCacheTTL = 30 * time.Second
+
+// Source health reported by Health
+const (
+	HealthHealthy       = "healthy"
+	HealthInitializing  = "initializing"
+	HealthNotConfigured = "not configured"
+)
+
+// Initializer constructs a data source instance
+type Initializer func() (datasource.DataSource, error)
+
+// pendingSource is a configured source whose construction has not succeeded
+type pendingSource struct {
+	lastErr     error
+	attempts    int
+	nextAttempt time.Time
+}
+
+// RegisterPending records a tenant's source whose construction failed with
+// err. init is retried in the background with exponential backoff; until it
+// succeeds requests get a *datasource.InitializingError instead of an
+// unknown source.
+func (r *Registry) RegisterPending(tenantID, name string, sourceType datasource.DataSourceType, err error, init Initializer, logger *zap.Logger) {
+	p := &pendingSource{lastErr: err, attempts: 1, nextAttempt: time.Now().Add(r.retryMin)}
+
+	r.mu.Lock()
+	if r.pending[tenantID] == nil {
+		r.pending[tenantID] = make(map[string]*pendingSource)
+	}
+	r.pending[tenantID][name] = p
+	r.types[name] = sourceType
+	r.mu.Unlock()
+
+	go r.recover(tenantID, name, p, init, logger.With(zap.String("source", name)))
+}
+
+// recover retries init until it succeeds or the registry is closed
+func (r *Registry) recover(tenantID, name string, p *pendingSource, init Initializer, logger *zap.Logger) {
+	delay := r.retryMin
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-time.After(delay):
+		}
+
+		source, err := init()
+		if err == nil {
+			r.mu.Lock()
+			closed := r.closed
+			if !closed {
+				delete(r.pending[tenantID], name)
+				r.registerLocked(tenantID, name, source)
+			}
+			attempts := p.attempts + 1
+			r.mu.Unlock()
+
+			if closed {
+				source.Close()
+				return
+			}
+			logger.Info("Data source initialized after retry", zap.Int("attempts", attempts))
+			return
+		}
+
+		delay = min(delay*2, r.retryMax)
+
+		r.mu.Lock()
+		p.lastErr = err
+		p.attempts++
+		p.nextAttempt = time.Now().Add(delay)
+		r.mu.Unlock()
+
+		logger.Warn("Data source initialization failed, retrying",
+			zap.Error(err),
+			zap.Int("attempts", p.attempts),
+			zap.Duration("retry_in", delay))
+	}
+}
+
+// lookup returns a tenant's source or, while it is pending, its
+// *datasource.InitializingError; both are nil when the source is not
+// configured. One lock covers both so a source recovering concurrently is
+// never reported as missing.
+func (r *Registry) lookup(tenantID, name string) (datasource.DataSource, *datasource.InitializingError) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if source, ok := r.sources[tenantID][name]; ok {
+		return source, nil
+	}
+	p, ok := r.pending[tenantID][name]
+	if !ok {
+		return nil, nil
+	}
+	return nil, &datasource.InitializingError{
+		Source:     name,
+		RetryAfter: max(time.Until(p.nextAttempt), 0),
+		Err:        p.lastErr,
+	}
+}
+
+// Initializing reports whether any tenant's source is still being constructed
+func (r *Registry) Initializing() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, sources := range r.pending {
+		if len(sources) > 0 {
+			return true
+		}
+	}
+	return false
+}
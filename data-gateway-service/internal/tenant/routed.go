@@ -0,0 +1,222 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/killswitch"
+)
+
+type contextKey struct{}
+
+// WithTenant stores the resolved tenant in the context
+func WithTenant(ctx context.Context, t *Tenant) context.Context {
+	return context.WithValue(ctx, contextKey{}, t)
+}
+
+// FromContext returns the request's tenant, if resolved
+func FromContext(ctx context.Context) (*Tenant, bool) {
+	t, ok := ctx.Value(contextKey{}).(*Tenant)
+	return t, ok && t != nil
+}
+
+type substitutesKey struct{}
+
+// WithSubstitutes makes routed sources named in substitutes dispatch to the
+// tenant's instance of the source each is mapped to, so a request can be
+// replayed on another source. Substituted reads bypass the result cache.
+func WithSubstitutes(ctx context.Context, substitutes map[string]string) context.Context {
+	return context.WithValue(ctx, substitutesKey{}, substitutes)
+}
+
+// SubstitutesKey identifies the substitutes of the context for keys of
+// shared results, "" when there are none
+func SubstitutesKey(ctx context.Context) string {
+	substitutes, _ := ctx.Value(substitutesKey{}).(map[string]string)
+	pairs := make([]string, 0, len(substitutes))
+	for from, to := range substitutes {
+		pairs = append(pairs, from+"="+to)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// substitute returns the source name reads of name are dispatched to
+func substitute(ctx context.Context, name string) (string, bool) {
+	substitutes, _ := ctx.Value(substitutesKey{}).(map[string]string)
+	to, ok := substitutes[name]
+	return to, ok
+}
+
+// RoutedDataSource dispatches each call to the instance registered for the
+// tenant in the request context, falling back to the default tenant
+type RoutedDataSource struct {
+	registry   *Registry
+	name       string
+	sourceType datasource.DataSourceType
+}
+
+// resolve finds the data source instance for the context's tenant
+func (d *RoutedDataSource) resolve(ctx context.Context) (datasource.DataSource, error) {
+	t, ok := FromContext(ctx)
+	if !ok {
+		t = d.registry.Default()
+	}
+
+	name := d.name
+	if to, ok := substitute(ctx, name); ok {
+		name = to
+	}
+	if err := d.registry.killSwitch.Source(name); err != nil {
+		return nil, err
+	}
+	source, initErr := d.registry.lookup(t.ID, name)
+	if initErr != nil {
+		return nil, initErr
+	}
+	if source == nil {
+		return nil, fmt.Errorf("data source %s is not configured for tenant %s", name, t.ID)
+	}
+	return source, nil
+}
+
+// readOptions returns opts for a read of the context's source, bypassing the
+// cache of a substitute so a replay reaches it
+func (d *RoutedDataSource) readOptions(ctx context.Context, opts *datasource.QueryOptions) *datasource.QueryOptions {
+	if _, ok := substitute(ctx, d.name); !ok {
+		return opts
+	}
+	substituted := datasource.QueryOptions{}
+	if opts != nil {
+		substituted = *opts
+	}
+	substituted.SkipCache = true
+	return &substituted
+}
+
+// ExecuteQuery runs the query on the tenant's instance
+func (d *RoutedDataSource) ExecuteQuery(ctx context.Context, query string, opts *datasource.QueryOptions) (*datasource.QueryResult, error) {
+	source, err := d.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return source.ExecuteQuery(ctx, query, d.readOptions(ctx, opts))
+}
+
+// GetData reads the table from the tenant's instance
+func (d *RoutedDataSource) GetData(ctx context.Context, table string, opts *datasource.QueryOptions) (*datasource.QueryResult, error) {
+	source, err := d.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return source.GetData(ctx, table, d.readOptions(ctx, opts))
+}
+
+// ValidateQuery validates the query on the tenant's instance
+func (d *RoutedDataSource) ValidateQuery(ctx context.Context, query string) error {
+	source, err := d.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	return datasource.ValidateQuery(ctx, source, query)
+}
+
+// DescribeTable describes the table on the tenant's instance
+func (d *RoutedDataSource) DescribeTable(ctx context.Context, table string) ([]datasource.ColumnField, error) {
+	source, err := d.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return datasource.DescribeTable(ctx, source, table)
+}
+
+// TableSize reports the size of the table on the tenant's instance
+func (d *RoutedDataSource) TableSize(ctx context.Context, table string) (*datasource.TableStats, error) {
+	source, err := d.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return datasource.TableSize(ctx, source, table)
+}
+
+// PlanQuery plans the query on the tenant's instance
+func (d *RoutedDataSource) PlanQuery(ctx context.Context, query string, opts *datasource.QueryOptions) (*datasource.QueryPlan, error) {
+	source, err := d.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return datasource.PlanQuery(ctx, source, query, opts)
+}
+
+// PlanTable plans the table read on the tenant's instance
+func (d *RoutedDataSource) PlanTable(ctx context.Context, table string, opts *datasource.QueryOptions) (*datasource.QueryPlan, error) {
+	source, err := d.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return datasource.PlanTable(ctx, source, table, opts)
+}
+
+// TestConnection checks the tenant's instance
+func (d *RoutedDataSource) TestConnection(ctx context.Context) error {
+	source, err := d.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	return source.TestConnection(ctx)
+}
+
+// DeepCheck runs the query-based check of the tenant's instance
+func (d *RoutedDataSource) DeepCheck(ctx context.Context) error {
+	source, err := d.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	return datasource.DeepCheck(ctx, source)
+}
+
+// Capacity returns the concurrency hint of the tenant's instance, 0 when it
+// cannot be resolved
+func (d *RoutedDataSource) Capacity(ctx context.Context) int {
+	source, err := d.resolve(ctx)
+	if err != nil {
+		return 0
+	}
+	return datasource.Capacity(ctx, source)
+}
+
+// Ready reports a *datasource.InitializingError while the tenant's instance
+// is still being constructed, and a *killswitch.DisabledError while the
+// source is switched off
+func (d *RoutedDataSource) Ready(ctx context.Context) error {
+	_, err := d.resolve(ctx)
+	var initErr *datasource.InitializingError
+	var disabledErr *killswitch.DisabledError
+	if errors.As(err, &initErr) || errors.As(err, &disabledErr) {
+		return err
+	}
+	return nil
+}
+
+// QueryDefaults returns the query defaults shared by all instances of this
+// source, zero when none are configured
+func (d *RoutedDataSource) QueryDefaults() config.QueryDefaults {
+	d.registry.mu.RLock()
+	defer d.registry.mu.RUnlock()
+	return d.registry.defaults[d.name]
+}
+
+// GetType returns the type shared by all instances of this source
+func (d *RoutedDataSource) GetType() datasource.DataSourceType {
+	return d.sourceType
+}
+
+// Close is a no-op; the instances are owned and closed by the Registry
+func (d *RoutedDataSource) Close() error {
+	return nil
+}
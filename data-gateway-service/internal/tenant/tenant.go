@@ -0,0 +1,339 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go-data-gateway/internal/auth"
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/killswitch"
+)
+
+// DefaultID names the implicit tenant used when no tenants are configured
+const DefaultID = "default"
+
+var (
+	ErrUnknownTenant    = errors.New("unknown tenant")
+	ErrTenantNotAllowed = errors.New("api key is not allowed to access tenant")
+)
+
+// Tenant is an agency hosted on the gateway
+type Tenant struct {
+	ID               string `json:"id"`
+	DremioProject    string `json:"dremio_project,omitempty"`
+	BigQueryProject  string `json:"bigquery_project,omitempty"`
+	BigQueryDataset  string `json:"bigquery_dataset,omitempty"`
+	BigQueryLocation string `json:"bigquery_location,omitempty"`
+	CacheNamespace   string `json:"cache_namespace"`
+}
+
+// Registry holds the configured tenants and their data source instances
+type Registry struct {
+	tenants   map[string]*Tenant
+	defaultID string
+
+	mu       sync.RWMutex
+	sources  map[string]map[string]datasource.DataSource // tenant ID -> source name -> source
+	types    map[string]datasource.DataSourceType        // source name -> type
+	defaults map[string]config.QueryDefaults             // source name -> query defaults
+	pending  map[string]map[string]*pendingSource        // tenant ID -> source name -> retry state
+	closed   bool
+
+	retryMin, retryMax time.Duration
+	done               chan struct{} // Closed by Close to stop retries
+
+	probeMu  sync.Mutex
+	probeTTL time.Duration
+	probes   map[string]probeResult // Probe kind, tenant and source -> last reused result
+
+	killSwitch *killswitch.Switch // Sources switched off; nil disables none
+}
+
+// probeResult is a health status and when it was checked
+type probeResult struct {
+	status string
+	at     time.Time
+}
+
+// NewRegistry creates a registry from configuration. Without configured
+// tenants a single default tenant with an empty cache namespace is used, so
+// cache keys stay compatible with single-tenant deployments.
+func NewRegistry(tenants []config.TenantConfig, defaultID string) (*Registry, error) {
+	r := &Registry{
+		tenants:  make(map[string]*Tenant),
+		sources:  make(map[string]map[string]datasource.DataSource),
+		types:    make(map[string]datasource.DataSourceType),
+		defaults: make(map[string]config.QueryDefaults),
+		pending:  make(map[string]map[string]*pendingSource),
+
+		retryMin: initRetryMin,
+		retryMax: initRetryMax,
+		done:     make(chan struct{}),
+
+		probeTTL: probeCacheTTL,
+		probes:   make(map[string]probeResult),
+	}
+
+	if len(tenants) == 0 {
+		r.tenants[DefaultID] = &Tenant{ID: DefaultID}
+		r.defaultID = DefaultID
+		return r, nil
+	}
+
+	namespaces := make(map[string]string)
+	for _, tc := range tenants {
+		if _, dup := r.tenants[tc.ID]; dup {
+			return nil, fmt.Errorf("duplicate tenant %q", tc.ID)
+		}
+		if other, dup := namespaces[tc.CacheNamespace]; dup || tc.CacheNamespace == "" {
+			return nil, fmt.Errorf("tenant %q needs a unique cache namespace (clashes with %q)", tc.ID, other)
+		}
+		namespaces[tc.CacheNamespace] = tc.ID
+
+		r.tenants[tc.ID] = &Tenant{
+			ID:               tc.ID,
+			DremioProject:    tc.DremioProject,
+			BigQueryProject:  tc.BigQueryProject,
+			BigQueryDataset:  tc.BigQueryDataset,
+			BigQueryLocation: tc.BigQueryLocation,
+			CacheNamespace:   tc.CacheNamespace,
+		}
+	}
+
+	if defaultID == "" {
+		defaultID = tenants[0].ID
+	}
+	if _, ok := r.tenants[defaultID]; !ok {
+		return nil, fmt.Errorf("default tenant %q is not configured", defaultID)
+	}
+	r.defaultID = defaultID
+
+	return r, nil
+}
+
+// Get returns a tenant by ID
+func (r *Registry) Get(id string) (*Tenant, bool) {
+	t, ok := r.tenants[id]
+	return t, ok
+}
+
+// Default returns the tenant used when a request does not select one
+func (r *Registry) Default() *Tenant {
+	return r.tenants[r.defaultID]
+}
+
+// Tenants returns all tenants sorted by ID
+func (r *Registry) Tenants() []*Tenant {
+	tenants := make([]*Tenant, 0, len(r.tenants))
+	for _, t := range r.tenants {
+		tenants = append(tenants, t)
+	}
+	sort.Slice(tenants, func(i, j int) bool { return tenants[i].ID < tenants[j].ID })
+	return tenants
+}
+
+// Resolve picks the tenant for a request. An explicit tenant (X-Tenant) must
+// be allowed by the key; otherwise the key's first tenant or the registry
+// default is used.
+func (r *Registry) Resolve(key *auth.APIKey, requested string) (*Tenant, error) {
+	if requested != "" {
+		t, ok := r.tenants[requested]
+		if !ok {
+			return nil, ErrUnknownTenant
+		}
+		// Keys without a tenant binding may only use the default tenant
+		if key == nil || !(key.AllowsTenant(requested) || requested == r.keyDefault(key)) {
+			return nil, ErrTenantNotAllowed
+		}
+		return t, nil
+	}
+
+	id := r.keyDefault(key)
+	t, ok := r.tenants[id]
+	if !ok {
+		return nil, ErrUnknownTenant
+	}
+	return t, nil
+}
+
+// keyDefault returns the tenant a key acts on when none is requested
+func (r *Registry) keyDefault(key *auth.APIKey) string {
+	if key != nil && len(key.Tenants) > 0 {
+		return key.Tenants[0]
+	}
+	return r.defaultID
+}
+
+// Register adds a tenant's instance of a named data source
+func (r *Registry) Register(tenantID, name string, source datasource.DataSource) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.registerLocked(tenantID, name, source)
+}
+
+// registerLocked adds a source instance. r.mu must be held.
+func (r *Registry) registerLocked(tenantID, name string, source datasource.DataSource) {
+	if r.sources[tenantID] == nil {
+		r.sources[tenantID] = make(map[string]datasource.DataSource)
+	}
+	r.sources[tenantID][name] = source
+	r.types[name] = source.GetType()
+}
+
+// SetDefaults attaches the query defaults of a named data source to the
+// routed source handlers use, for every tenant
+func (r *Registry) SetDefaults(name string, defaults config.QueryDefaults) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.defaults[name] = defaults
+}
+
+// SetKillSwitch makes routed sources fail with a *killswitch.DisabledError
+// while sw switches them off. It must be called before serving requests.
+func (r *Registry) SetKillSwitch(sw *killswitch.Switch) {
+	r.killSwitch = sw
+}
+
+// Source returns a tenant's instance of a named data source
+func (r *Registry) Source(tenantID, name string) (datasource.DataSource, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	source, ok := r.sources[tenantID][name]
+	return source, ok
+}
+
+// TenantSources returns the data sources registered for a tenant
+func (r *Registry) TenantSources(tenantID string) map[string]datasource.DataSource {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	sources := make(map[string]datasource.DataSource, len(r.sources[tenantID]))
+	for name, source := range r.sources[tenantID] {
+		sources[name] = source
+	}
+	return sources
+}
+
+// Sources returns one routing data source per registered name. Handlers use
+// these like plain data sources; each call is dispatched to the instance of
+// the tenant stored in the request context.
+func (r *Registry) Sources() map[string]datasource.DataSource {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	sources := make(map[string]datasource.DataSource, len(r.types))
+	for name, sourceType := range r.types {
+		sources[name] = &RoutedDataSource{registry: r, name: name, sourceType: sourceType}
+	}
+	return sources
+}
+
+// Health tests every tenant's data sources with TestConnection, which runs no
+// queries. Each source is reported as healthy, "unhealthy: <error>",
+// "initializing: <last error>" while its construction is retried, or not
+// configured for the tenant. A successful probe is reused for 30 seconds so
+// readiness checks across replicas stay cheap; failures are probed again on
+// every call, so recovery is seen at once.
+func (r *Registry) Health(ctx context.Context) map[string]map[string]string {
+	return r.health("probe", false, func(source datasource.DataSource) error {
+		return source.TestConnection(ctx)
+	})
+}
+
+// DeepHealth is Health with datasource.DeepCheck, which runs a query on each
+// source. Failures are reused for 30 seconds too, so repeated calls cannot
+// run more than one query per source in that time.
+func (r *Registry) DeepHealth(ctx context.Context) map[string]map[string]string {
+	return r.health("deep", true, func(source datasource.DataSource) error {
+		return datasource.DeepCheck(ctx, source)
+	})
+}
+
+// health reports every tenant's data sources, checking each with check
+// unless a result of the same kind is reusable
+func (r *Registry) health(kind string, reuseFailures bool, check func(datasource.DataSource) error) map[string]map[string]string {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.types))
+	for name := range r.types {
+		names = append(names, name)
+	}
+	r.mu.RUnlock()
+
+	health := make(map[string]map[string]string)
+	for _, t := range r.Tenants() {
+		checks := make(map[string]string)
+		for _, name := range names {
+			source, initErr := r.lookup(t.ID, name)
+			switch {
+			case source != nil:
+				key := kind + ":" + t.ID + ":" + name
+				status, ok := r.cachedProbe(key)
+				if !ok {
+					status = HealthHealthy
+					if err := check(source); err != nil {
+						status = "unhealthy: " + err.Error()
+					}
+					r.storeProbe(key, status, status == HealthHealthy || reuseFailures)
+				}
+				checks[name] = status
+			case initErr != nil:
+				checks[name] = HealthInitializing + ": " + initErr.Err.Error()
+			default:
+				checks[name] = HealthNotConfigured
+			}
+		}
+		health[t.ID] = checks
+	}
+	return health
+}
+
+// cachedProbe returns the status stored for a probe key within probeTTL
+func (r *Registry) cachedProbe(key string) (string, bool) {
+	r.probeMu.Lock()
+	defer r.probeMu.Unlock()
+	result, ok := r.probes[key]
+	if !ok || time.Since(result.at) >= r.probeTTL {
+		return "", false
+	}
+	return result.status, true
+}
+
+// storeProbe records the status of a probe for reuse, or forgets any earlier
+// result when the status is not reusable
+func (r *Registry) storeProbe(key, status string, reuse bool) {
+	r.probeMu.Lock()
+	defer r.probeMu.Unlock()
+	if !reuse {
+		delete(r.probes, key)
+		return
+	}
+	r.probes[key] = probeResult{status: status, at: time.Now()}
+}
+
+// Close stops pending retries and closes every registered data source
+func (r *Registry) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	close(r.done)
+
+	var errs []error
+	for tenantID, sources := range r.sources {
+		for name, source := range sources {
+			if err := source.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("%s/%s: %w", tenantID, name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,364 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go-data-gateway/internal/auth"
+	"go-data-gateway/internal/cache"
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/killswitch"
+)
+
+// staticSource returns one row identifying its tenant and counts calls
+type staticSource struct {
+	tenant string
+	calls  int
+}
+
+func (s *staticSource) ExecuteQuery(ctx context.Context, query string, opts *datasource.QueryOptions) (*datasource.QueryResult, error) {
+	s.calls++
+	return &datasource.QueryResult{
+		Data:   []map[string]interface{}{{"tenant": s.tenant}},
+		Count:  1,
+		Source: datasource.DataSourceDremio,
+	}, nil
+}
+
+func (s *staticSource) GetData(ctx context.Context, table string, opts *datasource.QueryOptions) (*datasource.QueryResult, error) {
+	return s.ExecuteQuery(ctx, table, opts)
+}
+
+func (s *staticSource) TestConnection(ctx context.Context) error { return nil }
+
+func (s *staticSource) GetType() datasource.DataSourceType { return datasource.DataSourceDremio }
+
+func (s *staticSource) Close() error { return nil }
+
+func newTestRegistry(t *testing.T) *Registry {
+	registry, err := NewRegistry([]config.TenantConfig{
+		{ID: "lkpp", CacheNamespace: "lkpp"},
+		{ID: "bappenas", CacheNamespace: "bappenas"},
+	}, "lkpp")
+	require.NoError(t, err)
+	return registry
+}
+
+func TestNewRegistry_Validation(t *testing.T) {
+	_, err := NewRegistry([]config.TenantConfig{
+		{ID: "a", CacheNamespace: "shared"},
+		{ID: "b", CacheNamespace: "shared"},
+	}, "")
+	assert.Error(t, err)
+
+	_, err = NewRegistry([]config.TenantConfig{{ID: "a", CacheNamespace: "a"}}, "missing")
+	assert.Error(t, err)
+
+	registry, err := NewRegistry(nil, "")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultID, registry.Default().ID)
+	assert.Empty(t, registry.Default().CacheNamespace)
+}
+
+func TestRegistry_Resolve(t *testing.T) {
+	registry := newTestRegistry(t)
+
+	bound := &auth.APIKey{ID: "k1", Tenants: []string{"bappenas"}}
+	unbound := &auth.APIKey{ID: "k2"}
+	admin := &auth.APIKey{ID: "k3", Scopes: []string{auth.ScopeAdmin}}
+
+	tn, err := registry.Resolve(bound, "")
+	require.NoError(t, err)
+	assert.Equal(t, "bappenas", tn.ID)
+
+	tn, err = registry.Resolve(unbound, "")
+	require.NoError(t, err)
+	assert.Equal(t, "lkpp", tn.ID)
+
+	_, err = registry.Resolve(bound, "lkpp")
+	assert.ErrorIs(t, err, ErrTenantNotAllowed)
+
+	_, err = registry.Resolve(unbound, "bappenas")
+	assert.ErrorIs(t, err, ErrTenantNotAllowed)
+
+	tn, err = registry.Resolve(admin, "bappenas")
+	require.NoError(t, err)
+	assert.Equal(t, "bappenas", tn.ID)
+
+	_, err = registry.Resolve(admin, "unknown")
+	assert.ErrorIs(t, err, ErrUnknownTenant)
+}
+
+func TestRoutedDataSource_CrossTenantCacheIsolation(t *testing.T) {
+	ctx := context.Background()
+	registry := newTestRegistry(t)
+	shared := cache.NewMemoryCache()
+
+	upstreams := map[string]*staticSource{}
+	for _, tn := range registry.Tenants() {
+		upstreams[tn.ID] = &staticSource{tenant: tn.ID}
+		registry.Register(tn.ID, "DATAWAREHOUSE",
+			cache.NewNamespacedCachedDataSource(upstreams[tn.ID], shared, tn.CacheNamespace, zap.NewNop()))
+	}
+
+	routed := registry.Sources()["DATAWAREHOUSE"]
+	require.NotNil(t, routed)
+	assert.Equal(t, datasource.DataSourceDremio, routed.GetType())
+
+	lkpp, _ := registry.Get("lkpp")
+	bappenas, _ := registry.Get("bappenas")
+	lkppCtx := WithTenant(ctx, lkpp)
+	bappenasCtx := WithTenant(ctx, bappenas)
+
+	query := "SELECT * FROM tender_data"
+	for i := 0; i < 3; i++ {
+		result, err := routed.ExecuteQuery(lkppCtx, query, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "lkpp", result.Data[0]["tenant"])
+
+		result, err = routed.ExecuteQuery(bappenasCtx, query, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "bappenas", result.Data[0]["tenant"])
+	}
+
+	// One upstream call per tenant; every repeat was served from its own namespace
+	assert.Equal(t, 1, upstreams["lkpp"].calls)
+	assert.Equal(t, 1, upstreams["bappenas"].calls)
+
+	// Without a tenant in the context the default tenant is used
+	result, err := routed.ExecuteQuery(ctx, query, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "lkpp", result.Data[0]["tenant"])
+
+	health := registry.Health(ctx)
+	assert.Equal(t, "healthy", health["bappenas"]["DATAWAREHOUSE"])
+}
+
+func TestRoutedDataSource_Substitutes(t *testing.T) {
+	ctx := context.Background()
+	registry := newTestRegistry(t)
+	shared := cache.NewMemoryCache()
+	rest := &staticSource{tenant: "rest"}
+	arrow := &staticSource{tenant: "arrow"}
+	registry.Register("lkpp", "DATAWAREHOUSE", cache.NewNamespacedCachedDataSource(rest, shared, "rest", zap.NewNop()))
+	registry.Register("lkpp", "DATAWAREHOUSE_ARROW", cache.NewNamespacedCachedDataSource(arrow, shared, "arrow", zap.NewNop()))
+	routed := registry.Sources()["DATAWAREHOUSE"]
+
+	replay := WithSubstitutes(ctx, map[string]string{"DATAWAREHOUSE": "DATAWAREHOUSE_ARROW"})
+	assert.Equal(t, "DATAWAREHOUSE=DATAWAREHOUSE_ARROW", SubstitutesKey(replay))
+	assert.Empty(t, SubstitutesKey(ctx))
+
+	for i := 0; i < 2; i++ {
+		result, err := routed.ExecuteQuery(ctx, "SELECT 1", nil)
+		require.NoError(t, err)
+		assert.Equal(t, "rest", result.Data[0]["tenant"])
+
+		result, err = routed.GetData(replay, "tender_data", &datasource.QueryOptions{Limit: 10})
+		require.NoError(t, err)
+		assert.Equal(t, "arrow", result.Data[0]["tenant"])
+	}
+
+	// Replays bypass the substitute's cache
+	assert.Equal(t, 1, rest.calls)
+	assert.Equal(t, 2, arrow.calls)
+}
+
+func TestRoutedDataSource_MissingSource(t *testing.T) {
+	registry := newTestRegistry(t)
+	registry.Register("lkpp", "BIGQUERY", &staticSource{tenant: "lkpp"})
+
+	bappenas, _ := registry.Get("bappenas")
+	_, err := registry.Sources()["BIGQUERY"].ExecuteQuery(WithTenant(context.Background(), bappenas), "SELECT 1", nil)
+	assert.Error(t, err)
+}
+
+func TestRoutedDataSource_QueryDefaults(t *testing.T) {
+	registry := newTestRegistry(t)
+	registry.Register("lkpp", "BIGQUERY", &staticSource{tenant: "lkpp"})
+	registry.Register("lkpp", "DATAWAREHOUSE", &staticSource{tenant: "lkpp"})
+	registry.SetDefaults("BIGQUERY", config.QueryDefaults{CacheTTL: 15 * time.Minute, Timeout: time.Minute})
+
+	sources := registry.Sources()
+	assert.Equal(t, config.QueryDefaults{CacheTTL: 15 * time.Minute, Timeout: time.Minute}, datasource.Defaults(sources["BIGQUERY"]))
+	assert.Equal(t, config.DefaultQueryDefaults(), datasource.Defaults(sources["DATAWAREHOUSE"]))
+}
+
+// flakyInit fails the first failures calls and then returns a staticSource.
+// Each call waits for a tick so the test controls when attempts happen.
+type flakyInit struct {
+	failures int
+	calls    int
+	tick     chan struct{}
+	done     chan struct{} // Receives after every attempt
+}
+
+func (f *flakyInit) init() (datasource.DataSource, error) {
+	<-f.tick
+	defer func() { f.done <- struct{}{} }()
+	f.calls++
+	if f.calls <= f.failures {
+		return nil, fmt.Errorf("dial tcp dremio:32010: connection refused (attempt %d)", f.calls)
+	}
+	return &staticSource{tenant: "lkpp"}, nil
+}
+
+func TestRegistry_PendingSourceRecovers(t *testing.T) {
+	ctx := context.Background()
+	registry := newTestRegistry(t)
+	registry.retryMin = time.Millisecond
+	registry.retryMax = time.Millisecond
+	defer registry.Close()
+
+	flaky := &flakyInit{failures: 2, tick: make(chan struct{}, 1), done: make(chan struct{}, 1)}
+	attempt := func() {
+		flaky.tick <- struct{}{}
+		<-flaky.done
+	}
+
+	// Startup attempt fails; the source is registered as pending
+	flaky.tick <- struct{}{}
+	_, err := flaky.init()
+	<-flaky.done
+	require.Error(t, err)
+	registry.RegisterPending("lkpp", "DATAWAREHOUSE", datasource.DataSourceDremio, err, flaky.init, zap.NewNop())
+
+	routed := registry.Sources()["DATAWAREHOUSE"]
+	require.NotNil(t, routed)
+
+	_, err = routed.ExecuteQuery(ctx, "SELECT 1", nil)
+	var initErr *datasource.InitializingError
+	require.True(t, errors.As(err, &initErr))
+	assert.Equal(t, "DATAWAREHOUSE", initErr.Source)
+	assert.Contains(t, initErr.Err.Error(), "attempt 1")
+	assert.Error(t, datasource.CheckReady(ctx, routed))
+	assert.True(t, registry.Initializing())
+
+	// Second attempt, in the background, fails too
+	attempt()
+	assert.Eventually(t, func() bool {
+		_, err := routed.ExecuteQuery(ctx, "SELECT 1", nil)
+		return err != nil && strings.Contains(err.Error(), "attempt 2")
+	}, time.Second, time.Millisecond)
+
+	health := registry.Health(ctx)
+	assert.Contains(t, health["lkpp"]["DATAWAREHOUSE"], HealthInitializing)
+	assert.Equal(t, HealthNotConfigured, health["bappenas"]["DATAWAREHOUSE"])
+
+	// Third attempt succeeds and the source is served
+	attempt()
+	assert.Eventually(t, func() bool { return !registry.Initializing() }, time.Second, time.Millisecond)
+
+	result, err := routed.ExecuteQuery(ctx, "SELECT 1", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "lkpp", result.Data[0]["tenant"])
+	assert.NoError(t, datasource.CheckReady(ctx, routed))
+	assert.Equal(t, HealthHealthy, registry.Health(ctx)["lkpp"]["DATAWAREHOUSE"])
+	assert.Equal(t, 3, flaky.calls)
+}
+
+func TestRegistry_CloseStopsRetries(t *testing.T) {
+	registry := newTestRegistry(t)
+	registry.retryMin = time.Millisecond
+
+	calls := make(chan struct{}, 10)
+	registry.RegisterPending("lkpp", "BIGQUERY", datasource.DataSourceBigQuery, errors.New("no credentials"),
+		func() (datasource.DataSource, error) {
+			calls <- struct{}{}
+			return nil, errors.New("no credentials")
+		}, zap.NewNop())
+
+	<-calls
+	require.NoError(t, registry.Close())
+	assert.NoError(t, registry.Close())
+}
+
+// probeSource counts health checks and queries; TestConnection fails while
+// failing is set
+type probeSource struct {
+	staticSource
+	probes, deepChecks int
+	failing            bool
+}
+
+func (s *probeSource) TestConnection(ctx context.Context) error {
+	s.probes++
+	if s.failing {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func (s *probeSource) DeepCheck(ctx context.Context) error {
+	s.deepChecks++
+	if s.failing {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func TestRegistry_HealthProbesAreCheapAndCached(t *testing.T) {
+	ctx := context.Background()
+	registry := newTestRegistry(t)
+	source := &probeSource{staticSource: staticSource{tenant: "lkpp"}}
+	registry.Register("lkpp", "DATAWAREHOUSE",
+		cache.NewCachedDataSource(source, cache.NewMemoryCache(), zap.NewNop()))
+
+	// Readiness never runs a query, and a success is reused
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, HealthHealthy, registry.Health(ctx)["lkpp"]["DATAWAREHOUSE"])
+	}
+	assert.Equal(t, 1, source.probes)
+	assert.Equal(t, 0, source.deepChecks)
+	assert.Equal(t, 0, source.calls)
+
+	// Once the cached success expires, failures are probed on every call
+	registry.probeTTL = 0
+	source.failing = true
+	assert.Contains(t, registry.Health(ctx)["lkpp"]["DATAWAREHOUSE"], "unhealthy")
+	registry.probeTTL = time.Minute
+	assert.Contains(t, registry.Health(ctx)["lkpp"]["DATAWAREHOUSE"], "unhealthy")
+	assert.Equal(t, 3, source.probes)
+
+	source.failing = false
+	assert.Equal(t, HealthHealthy, registry.Health(ctx)["lkpp"]["DATAWAREHOUSE"])
+
+	// Deep checks go through the cache wrapper and reuse failures as well
+	source.failing = true
+	for i := 0; i < 3; i++ {
+		assert.Contains(t, registry.DeepHealth(ctx)["lkpp"]["DATAWAREHOUSE"], "unhealthy")
+	}
+	assert.Equal(t, 1, source.deepChecks)
+	assert.Equal(t, 0, source.calls)
+}
+
+func TestRoutedDataSource_DisabledSource(t *testing.T) {
+	registry := newTestRegistry(t)
+	source := &staticSource{tenant: "lkpp"}
+	registry.Register("lkpp", "BIGQUERY", source)
+	sw := killswitch.New(killswitch.NewMemoryStore(), func() config.FlagPolicy { return config.FlagPolicy{} }, time.Minute, zap.NewNop())
+	registry.SetKillSwitch(sw)
+	routed := registry.Sources()["BIGQUERY"]
+
+	_, err := routed.ExecuteQuery(context.Background(), "SELECT 1", nil)
+	require.NoError(t, err)
+
+	require.NoError(t, sw.Set(context.Background(), killswitch.KindSource, "BIGQUERY", killswitch.Override{Disabled: true, Message: "Quota exhausted"}))
+	var disabledErr *killswitch.DisabledError
+	_, err = routed.ExecuteQuery(context.Background(), "SELECT 1", nil)
+	require.ErrorAs(t, err, &disabledErr)
+	assert.Equal(t, "BIGQUERY", disabledErr.Name)
+	_, err = routed.GetData(context.Background(), "rup", nil)
+	assert.ErrorAs(t, err, &disabledErr)
+	// Streams check readiness before committing their response
+	assert.ErrorAs(t, datasource.CheckReady(context.Background(), routed), &disabledErr)
+	assert.Equal(t, 1, source.calls, "the upstream is not called while disabled")
+}
@@ -0,0 +1,65 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Event is the JSON body posted to a webhook
+type Event struct {
+	Type      string      `json:"type"`
+	Timestamp time.Time   `json:"timestamp"`
+	Data      interface{} `json:"data"`
+}
+
+// Client posts events to a single webhook URL
+type Client struct {
+	url  string
+	http *http.Client
+}
+
+// New creates a webhook client; it returns nil when url is empty so callers
+// can treat an unconfigured webhook as disabled
+func New(url string) *Client {
+	if url == "" {
+		return nil
+	}
+	return &Client{
+		url:  url,
+		http: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Send posts an event and fails on any non-2xx response. Sending on a nil
+// client is a no-op.
+func (c *Client) Send(ctx context.Context, eventType string, data interface{}) error {
+	if c == nil {
+		return nil
+	}
+
+	body, err := json.Marshal(Event{Type: eventType, Timestamp: time.Now().UTC(), Data: data})
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook event: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
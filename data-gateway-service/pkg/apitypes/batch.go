@@ -0,0 +1,84 @@
+package apitypes
+
+import (
+	"time"
+
+	"go-data-gateway/internal/datasource"
+)
+
+// BatchRequest is the body of POST /api/v1/batch and /api/v1/batch/stream
+type BatchRequest struct {
+	Queries []BatchQuery `json:"queries"`
+	Options BatchOptions `json:"options,omitempty"`
+}
+
+// BatchQuery represents a single query in a batch
+type BatchQuery struct {
+	ID         string                   `json:"id"`
+	Query      string                   `json:"query,omitempty"`
+	DataSource string                   `json:"data_source"`
+	Table      string                   `json:"table,omitempty"`
+	Options    *datasource.QueryOptions `json:"options,omitempty"`
+	Labels     map[string]string        `json:"labels,omitempty"` // Attribution, see QueryRequest.Labels
+}
+
+// BatchOptions controls batch execution behavior
+type BatchOptions struct {
+	MaxConcurrency int           `json:"max_concurrency,omitempty"`
+	Timeout        time.Duration `json:"timeout,omitempty"`
+	StopOnError    bool          `json:"stop_on_error,omitempty"`
+	Ordered        bool          `json:"ordered,omitempty"` // Stream: emit results in submission order
+	// PartialOK answers a batch with failed queries 200 with a partial
+	// summary instead of 422
+	PartialOK bool `json:"partial_ok,omitempty"`
+}
+
+// BatchResponse represents the response for batch queries
+type BatchResponse struct {
+	Success   bool          `json:"success"` // False when a query failed without partial_ok
+	Results   []BatchResult `json:"results"`
+	Summary   BatchSummary  `json:"summary"`
+	Timestamp time.Time     `json:"timestamp"`
+}
+
+// BatchResult represents the result of a single query in batch
+type BatchResult struct {
+	ID        string                   `json:"id"`
+	Status    string                   `json:"status"` // success, error, skipped, skipped_disabled, cancelled
+	Data      []map[string]interface{} `json:"data,omitempty"`
+	Error     string                   `json:"error,omitempty"`
+	QueryTime time.Duration            `json:"query_time_ms"`
+	RowCount  int                      `json:"row_count"`
+	CacheHit  bool                     `json:"cache_hit"`
+
+	// Set when the query had no LIMIT and ran with InjectedLimit injected
+	LimitInjected bool `json:"limit_injected,omitempty"`
+	InjectedLimit int  `json:"injected_limit,omitempty"`
+}
+
+// BatchSummary provides aggregate metrics for the batch
+type BatchSummary struct {
+	TotalQueries      int           `json:"total_queries"`
+	SuccessfulQueries int           `json:"successful_queries"`
+	FailedQueries     int           `json:"failed_queries"`
+	SkippedQueries    int           `json:"skipped_queries"`
+	DisabledQueries   int           `json:"disabled_queries,omitempty"` // Skipped as their source is switched off
+	TotalTime         time.Duration `json:"total_time_ms"`
+	CacheHits         int           `json:"cache_hits"`
+	Partial           bool          `json:"partial,omitempty"` // Queries failed and partial_ok accepted it
+
+	// Scheduling shows the concurrency and pool retries of each source
+	Scheduling []SourceSchedule `json:"scheduling,omitempty"`
+}
+
+// SourceSchedule reports how a batch scheduled the queries of one data source
+type SourceSchedule struct {
+	Source      string `json:"source"`
+	Queries     int    `json:"queries"`
+	Capacity    int    `json:"capacity,omitempty"` // Advertised by the source; omitted when unknown
+	Concurrency int    `json:"concurrency"`        // Queries of this source run at once
+	// RetryWindowMs bounds the pool-exhausted retries of each query: its
+	// share of the batch timeout
+	RetryWindowMs int64 `json:"retry_window_ms,omitempty"`
+	PoolRetries   int64 `json:"pool_retries"`
+}
@@ -0,0 +1,4 @@
+// Package apitypes holds the request and response bodies of the gateway's
+// /api/v1 endpoints. The handlers decode and encode them and pkg/client
+// sends and reads them, so the server and its Go callers cannot drift apart.
+package apitypes
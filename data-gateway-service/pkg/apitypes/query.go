@@ -0,0 +1,58 @@
+package apitypes
+
+import "go-data-gateway/internal/datasource"
+
+// QueryRequest is the body of POST /api/v1/query
+type QueryRequest struct {
+	SQL    string                    `json:"sql" binding:"required"`
+	Source datasource.DataSourceType `json:"source" binding:"required"`
+	Limit  int                       `json:"limit,omitempty"` // Maximum rows returned
+
+	// ValidateOnly checks the query (BigQuery dry run, Dremio LIMIT 0 probe)
+	// without returning data
+	ValidateOnly bool `json:"validate_only,omitempty"`
+
+	// CountOnly returns the number of rows the query matches instead of the
+	// rows, counted by the source and cached longer than rows are
+	CountOnly bool `json:"count_only,omitempty"`
+
+	// Labels attribute the query to the calling application: BigQuery job
+	// labels, a Dremio SQL comment, logs and metrics
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// MaxAgeSeconds bounds the age of a cached result; older results are
+	// re-executed. Without it the Cache-Control max-age request header applies.
+	MaxAgeSeconds *int `json:"max_age_seconds,omitempty"`
+
+	// CacheTTLSeconds and TimeoutSeconds replace the cache TTL and timeout
+	// of the source's defaults, up to the configured ceilings
+	CacheTTLSeconds *int `json:"cache_ttl_seconds,omitempty"`
+	TimeoutSeconds  *int `json:"timeout_seconds,omitempty"`
+
+	// RequireCacheWrite asks for a CACHE_WRITE_FAILED warning in the response when
+	// a fresh result could not be cached, for callers that just invalidated
+	// the cache and rely on every replica serving the new result
+	RequireCacheWrite bool `json:"require_cache_write,omitempty"`
+
+	// EngineOptions are Dremio session options for this query, such as
+	// {"planner.enable_broadcast_join": false}, and the routing_tag,
+	// routing_queue and routing_engine of the job; debug keys only
+	EngineOptions datasource.EngineOptions `json:"engine_options,omitempty"`
+
+	// PreserveComments sends the SQL to the source with its comments, for
+	// engine hints written as comments. Otherwise comments are blanked out
+	// before the query runs. Either way they are ignored when the query is
+	// checked and cached.
+	PreserveComments bool `json:"preserve_comments,omitempty"`
+
+	// AsOf reads the query's table as it was at a past time, an RFC 3339
+	// time or a YYYY-MM-DD date. The query must read a single table enabled
+	// for as_of, on a Dremio source.
+	AsOf string `json:"as_of,omitempty"`
+}
+
+// QueryValidation is the response to a validate_only request
+type QueryValidation struct {
+	Valid  bool                      `json:"valid"`
+	Source datasource.DataSourceType `json:"source"`
+}
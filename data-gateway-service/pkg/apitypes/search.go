@@ -0,0 +1,56 @@
+package apitypes
+
+import "encoding/json"
+
+// TenderSearchRequest is the body of POST /api/v1/tender/search
+type TenderSearchRequest struct {
+	// Filters all must match; each is a {field, op, value} clause on a
+	// declared tender column or an or/and group of clauses
+	Filters []SearchClause `json:"filters,omitempty"`
+
+	Keyword Keywords `json:"keyword,omitempty"`
+	Match   string   `json:"match,omitempty"` // all (default) or any of the keywords
+	Limit   int      `json:"limit,omitempty"`
+
+	// AsOf reads the tenders as they were at a past time, an RFC 3339 time
+	// or a YYYY-MM-DD date
+	AsOf string `json:"as_of,omitempty"`
+
+	// CountOnly returns the number of matching tenders instead of the rows
+	CountOnly bool `json:"count_only,omitempty"`
+}
+
+// SearchClause is one filter of a search body, in JSON
+// {"field": "nilai_pagu", "op": "gte", "value": 1000000}, or a group of
+// clauses of which any ("or") or every ("and") must match. Value is absent
+// for is_null and not_null and a list for in and nin.
+type SearchClause struct {
+	Field string          `json:"field,omitempty"`
+	Op    string          `json:"op,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+
+	Or  []SearchClause `json:"or,omitempty"`
+	And []SearchClause `json:"and,omitempty"`
+}
+
+// errKeywordType rejects a keyword that is neither a string nor a list of them
+var errKeywordType = &Violation{Field: "keyword", Message: "keyword must be a string or a list of strings", Constraint: "type"}
+
+// Keywords is the keyword field of a search body: one string, matched as a
+// phrase, or a list of them
+type Keywords []string
+
+// UnmarshalJSON accepts a string or a list of strings
+func (k *Keywords) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*k = Keywords{one}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return errKeywordType
+	}
+	*k = list
+	return nil
+}
@@ -0,0 +1,28 @@
+package apitypes
+
+import (
+	"go-data-gateway/internal/csvfmt"
+	"go-data-gateway/internal/datasource"
+)
+
+// StreamRequest is the body of POST /api/v1/stream and /api/v1/stream/sse
+type StreamRequest struct {
+	Query      string                   `json:"query,omitempty"`
+	DataSource string                   `json:"data_source"`
+	Table      string                   `json:"table,omitempty"`
+	ChunkSize  int                      `json:"chunk_size,omitempty"`
+	Format     string                   `json:"format,omitempty"` // json, ndjson, csv
+	Options    *datasource.QueryOptions `json:"options,omitempty"`
+
+	// CSV formats csv values for a spreadsheet locale; json and ndjson
+	// output is never localized
+	CSV *csvfmt.Options `json:"csv,omitempty"`
+
+	// Spill writes the result to disk before serving it: sync serves the
+	// file once written, async returns its download URL at once
+	Spill string `json:"spill,omitempty"`
+
+	// ResumeToken continues a table stream after the last row an earlier
+	// stream of the same request sent, from its summary or X-Resume-Token
+	ResumeToken string `json:"resume_token,omitempty"`
+}
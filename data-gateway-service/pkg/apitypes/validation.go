@@ -0,0 +1,17 @@
+package apitypes
+
+// ErrCodeValidationFailed is returned with every violation of a request body
+const ErrCodeValidationFailed = "VALIDATION_FAILED"
+
+// Violation is one rejected field of a request body; a list of them is the
+// error.details of a VALIDATION_FAILED response. Constraint names the rule in
+// the style of validator tags, e.g. required, max=1000 or unknown_field.
+type Violation struct {
+	Field      string `json:"field"`
+	Message    string `json:"message"`
+	Constraint string `json:"constraint"`
+}
+
+func (v *Violation) Error() string {
+	return v.Message
+}
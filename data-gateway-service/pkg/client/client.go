@@ -0,0 +1,256 @@
+// Package client is a typed Go client of the gateway's /api/v1 endpoints.
+// Request and response bodies are the apitypes structs the handlers use, so
+// callers do not re-define them. The client authenticates with an API key,
+// retries 5xx and 429 responses with backoff, honoring Retry-After, and
+// returns failed requests as *Error with the code and details of the
+// gateway's error envelope.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-data-gateway/internal/response"
+)
+
+// Config configures a Client
+type Config struct {
+	BaseURL    string       // Scheme and host of the gateway, e.g. https://gateway.lkpp.go.id
+	APIKey     string       // Sent as X-API-Key
+	HTTPClient *http.Client // Defaults to a client without a timeout; bound requests with their context
+	UserAgent  string
+
+	// MaxRetries is how often a 5xx or 429 response is retried; 0 means 3
+	// and a negative value disables retries
+	MaxRetries int
+	// MinBackoff is the delay before the first retry, doubled for each
+	// further retry up to MaxBackoff, with jitter. Retry-After replaces it.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// Client calls the gateway's /api/v1 endpoints. It is safe for concurrent
+// use.
+type Client struct {
+	baseURL    string
+	apiKey     string
+	http       *http.Client
+	userAgent  string
+	maxRetries int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	sleep      func(ctx context.Context, d time.Duration) error
+}
+
+// New creates a client of the gateway at cfg.BaseURL
+func New(cfg Config) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(cfg.BaseURL, "/"),
+		apiKey:     cfg.APIKey,
+		http:       cfg.HTTPClient,
+		userAgent:  cfg.UserAgent,
+		maxRetries: cfg.MaxRetries,
+		minBackoff: cfg.MinBackoff,
+		maxBackoff: cfg.MaxBackoff,
+		sleep:      sleep,
+	}
+	if c.http == nil {
+		c.http = &http.Client{}
+	}
+	if c.userAgent == "" {
+		c.userAgent = "go-data-gateway-client"
+	}
+	switch {
+	case c.maxRetries == 0:
+		c.maxRetries = 3
+	case c.maxRetries < 0:
+		c.maxRetries = 0
+	}
+	if c.minBackoff <= 0 {
+		c.minBackoff = 200 * time.Millisecond
+	}
+	if c.maxBackoff < c.minBackoff {
+		c.maxBackoff = max(10*time.Second, c.minBackoff)
+	}
+	return c
+}
+
+// envelope is the standard response body, with the data left raw until the
+// caller's type is known
+type envelope struct {
+	Success  bool                `json:"success"`
+	Data     json.RawMessage     `json:"data"`
+	Error    *response.ErrorInfo `json:"error"`
+	Meta     *response.Meta      `json:"meta"`
+	Warnings []response.Warning  `json:"warnings"`
+}
+
+// call sends a JSON request and decodes the data of its envelope into out,
+// returning the envelope's meta with its warnings in Meta.Warnings
+func (c *Client) call(ctx context.Context, method, path string, query url.Values, body, out interface{}) (*response.Meta, error) {
+	resp, err := c.send(ctx, method, path, query, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var env envelope
+	if err := json.NewDecoder(resp.Body).Decode(&env); err != nil {
+		return nil, fmt.Errorf("decoding %s %s response: %w", method, path, err)
+	}
+	if len(env.Warnings) > 0 {
+		if env.Meta == nil {
+			env.Meta = &response.Meta{}
+		}
+		env.Meta.Warnings = env.Warnings
+	}
+	if out != nil && len(env.Data) > 0 {
+		if err := json.Unmarshal(env.Data, out); err != nil {
+			return env.Meta, fmt.Errorf("decoding %s %s data: %w", method, path, err)
+		}
+	}
+	return env.Meta, nil
+}
+
+// send sends a request, retrying 5xx and 429 responses, and returns the
+// first successful response with its body unread. A failed response is
+// returned as *Error.
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, body interface{}) (*http.Response, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return nil, fmt.Errorf("encoding %s %s request: %w", method, path, err)
+		}
+	}
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if c.apiKey != "" {
+			req.Header.Set("X-API-Key", c.apiKey)
+		}
+		req.Header.Set("User-Agent", c.userAgent)
+
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode < http.StatusBadRequest {
+			return resp, nil
+		}
+
+		apiErr := readError(resp)
+		if !retryable(resp.StatusCode) || attempt >= c.maxRetries {
+			return nil, apiErr
+		}
+		delay := c.backoff(attempt)
+		if apiErr.RetryAfter > 0 {
+			delay = apiErr.RetryAfter
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return nil, apiErr // Retrying would outlive the caller
+		}
+		if err := c.sleep(ctx, delay); err != nil {
+			return nil, apiErr
+		}
+	}
+}
+
+// retryable reports whether a response status may succeed when retried
+func retryable(status int) bool {
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
+
+// backoff returns the delay before retry attempt+1: MinBackoff doubled per
+// attempt up to MaxBackoff, less up to a quarter of jitter so clients
+// retrying together spread out
+func (c *Client) backoff(attempt int) time.Duration {
+	delay := c.maxBackoff
+	if attempt < 30 {
+		delay = min(c.minBackoff<<attempt, c.maxBackoff)
+	}
+	return delay - time.Duration(rand.Int63n(int64(delay)/4+1))
+}
+
+// retryAfter parses a Retry-After header, in seconds or an HTTP date
+func retryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(header); err == nil {
+		return max(time.Until(at), 0)
+	}
+	return 0
+}
+
+// sleep waits d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// readError reads a failed response into an *Error and closes its body
+func readError(resp *http.Response) *Error {
+	defer resp.Body.Close()
+	apiErr := &Error{
+		StatusCode: resp.StatusCode,
+		RetryAfter: retryAfter(resp.Header.Get("Retry-After")),
+		RequestID:  resp.Header.Get("X-Request-ID"),
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	apiErr.body = body
+	var env struct {
+		Error *struct {
+			Code    string          `json:"code"`
+			Message string          `json:"message"`
+			Details json.RawMessage `json:"details"`
+		} `json:"error"`
+		Meta *response.Meta `json:"meta"`
+	}
+	if json.Unmarshal(body, &env) != nil || env.Error == nil {
+		// Not the standard envelope, e.g. from a proxy in front of the gateway
+		apiErr.Message = strings.TrimSpace(string(body))
+		if apiErr.Message == "" {
+			apiErr.Message = http.StatusText(resp.StatusCode)
+		}
+		return apiErr
+	}
+
+	apiErr.Code = env.Error.Code
+	apiErr.Message = env.Error.Message
+	apiErr.Details = env.Error.Details
+	if env.Meta != nil && env.Meta.RequestID != "" {
+		apiErr.RequestID = env.Meta.RequestID
+	}
+	apiErr.decodeViolations()
+	return apiErr
+}
@@ -0,0 +1,209 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-data-gateway/internal/response"
+	"go-data-gateway/pkg/apitypes"
+)
+
+// newTestClient creates a client of srv that records its retry delays
+// instead of sleeping
+func newTestClient(srv *httptest.Server, cfg Config) (*Client, *[]time.Duration) {
+	cfg.BaseURL = srv.URL
+	c := New(cfg)
+	var delays []time.Duration
+	c.sleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+	return c, &delays
+}
+
+func TestClient_RetriesHonoringRetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "key-1", r.Header.Get("X-API-Key"))
+		if calls.Add(1) < 3 {
+			w.Header().Set("Retry-After", "2")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, `{"success": false, "error": {"code": "OVERLOADED", "message": "Server is shedding load"}}`)
+			return
+		}
+		fmt.Fprint(w, `{"success": true, "data": [{"id": 1}], "meta": {"limit": 10}}`)
+	}))
+	defer srv.Close()
+
+	c, delays := newTestClient(srv, Config{APIKey: "key-1"})
+	page, err := c.TenderList(context.Background(), TenderListOptions{Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]interface{}{{"id": float64(1)}}, page.Rows)
+	assert.Equal(t, 10, page.Meta.Limit)
+	assert.EqualValues(t, 3, calls.Load())
+	assert.Equal(t, []time.Duration{2 * time.Second, 2 * time.Second}, *delays)
+}
+
+func TestClient_WarningsInMeta(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"success": true, "data": [], "warnings": [{"code": "RESULT_TRUNCATED", "message": "truncated"}]}`)
+	}))
+	defer srv.Close()
+
+	c, _ := newTestClient(srv, Config{})
+	page, err := c.TenderList(context.Background(), TenderListOptions{})
+	require.NoError(t, err)
+	require.NotNil(t, page.Meta)
+	assert.Equal(t, []response.Warning{{Code: "RESULT_TRUNCATED", Message: "truncated"}}, page.Meta.Warnings)
+}
+
+func TestClient_BacksOffAndGivesUp(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		assert.JSONEq(t, `{"sql": "SELECT 1", "source": "BIGQUERY"}`, string(body))
+		w.WriteHeader(http.StatusBadGateway)
+		fmt.Fprint(w, "upstream connect error")
+	}))
+	defer srv.Close()
+
+	c, delays := newTestClient(srv, Config{MaxRetries: 3, MinBackoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond})
+	_, _, err := c.QueryExecute(context.Background(), apitypes.QueryRequest{SQL: "SELECT 1", Source: "BIGQUERY"})
+
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusBadGateway, apiErr.StatusCode)
+	assert.Equal(t, "upstream connect error", apiErr.Message)
+	assert.EqualValues(t, 4, calls.Load())
+
+	// Doubling up to the maximum, less up to a quarter of jitter
+	require.Len(t, *delays, 3)
+	for i, ceiling := range []time.Duration{100, 200, 300} {
+		ceiling *= time.Millisecond
+		assert.LessOrEqual(t, (*delays)[i], ceiling)
+		assert.GreaterOrEqual(t, (*delays)[i], ceiling*3/4)
+	}
+}
+
+func TestClient_DoesNotRetryClientErrors(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("X-Request-ID", "gw-1/abc-000042")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"success": false, "error": {"code": "VALIDATION_FAILED", "message": "Request validation failed",
+			"details": [{"field": "queries[0].data_source", "message": "queries[0].data_source is required", "constraint": "required"}]}}`)
+	}))
+	defer srv.Close()
+
+	c, _ := newTestClient(srv, Config{})
+	_, err := c.BatchExecute(context.Background(), apitypes.BatchRequest{Queries: []apitypes.BatchQuery{{ID: "q1", Query: "SELECT 1"}}})
+
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.EqualValues(t, 1, calls.Load())
+	assert.Equal(t, "gw-1/abc-000042", apiErr.RequestID)
+	assert.Equal(t, []apitypes.Violation{{Field: "queries[0].data_source", Message: "queries[0].data_source is required", Constraint: "required"}},
+		apiErr.Violations)
+	assert.Contains(t, apiErr.Error(), "queries[0].data_source is required")
+}
+
+func TestClient_DoesNotOutwaitContext(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	c, delays := newTestClient(srv, Config{})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := c.RUPList(ctx, RUPListOptions{})
+
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, 60*time.Second, apiErr.RetryAfter)
+	assert.Empty(t, *delays)
+}
+
+// streamServer serves body as an NDJSON stream
+func streamServer(body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		io.WriteString(w, body)
+	}))
+}
+
+func TestRows(t *testing.T) {
+	rowLines := "{\"id\":1}\n{\"id\":2}\n"
+	checksum := "4e3b7ac3b51b29b2b06f5b12e4c8f09ef94a3e96ee2ad4b8f5f2e7fbd1c3dbe7"
+	tests := []struct {
+		name    string
+		body    string
+		rows    int
+		wantErr func(error) bool
+	}{
+		{"complete", rowLines + `{"type":"summary","total_rows":2}` + "\n", 2, func(err error) bool { return err == nil }},
+		{"truncated", rowLines, 1, func(err error) bool { return errors.Is(err, ErrStreamTruncated) }},
+		{"short count", rowLines + `{"type":"summary","total_rows":3}` + "\n", 2,
+			func(err error) bool { return err != nil && strings.Contains(err.Error(), "counts 3 rows") }},
+		{"altered", rowLines + `{"type":"summary","total_rows":2,"sha256":"` + checksum + `"}` + "\n", 2,
+			func(err error) bool { return err != nil && strings.Contains(err.Error(), "does not match") }},
+		{"error line", "{\"id\":1}\n" + `{"error":"query timed out","type":"error"}` + "\n" + `{"type":"summary","total_rows":1}` + "\n", 1,
+			func(err error) bool {
+				var streamErr *StreamError
+				return errors.As(err, &streamErr) && streamErr.Message == "query timed out"
+			}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := streamServer(tt.body)
+			defer srv.Close()
+
+			rows, err := New(Config{BaseURL: srv.URL}).Stream(context.Background(), apitypes.StreamRequest{Table: "tender", DataSource: "DATAWAREHOUSE"})
+			require.NoError(t, err)
+			defer rows.Close()
+
+			n := 0
+			for rows.Next() {
+				n++
+			}
+			assert.Equal(t, tt.rows, n)
+			assert.True(t, tt.wantErr(rows.Err()), "unexpected error %v", rows.Err())
+		})
+	}
+}
+
+func TestClient_BatchFailureKeepsResults(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Batch-Failed-Count", "1")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		fmt.Fprint(w, `{"success": false, "results": [{"id": "a", "status": "success", "row_count": 1}, {"id": "b", "status": "error", "error": "table not found"}],
+			"summary": {"total_queries": 2, "successful_queries": 1, "failed_queries": 1}}`)
+	}))
+	defer srv.Close()
+
+	c, delays := newTestClient(srv, Config{})
+	result, err := c.BatchExecute(context.Background(), apitypes.BatchRequest{})
+
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusUnprocessableEntity, apiErr.StatusCode)
+	assert.Equal(t, "1 of 2 queries failed", apiErr.Message)
+	assert.Empty(t, *delays)
+	require.NotNil(t, result)
+	require.Len(t, result.Results, 2)
+	assert.Equal(t, "table not found", result.Results[1].Error)
+}
@@ -0,0 +1,136 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/response"
+	"go-data-gateway/pkg/apitypes"
+)
+
+// Page is one page of a list endpoint
+type Page struct {
+	Rows []map[string]interface{}
+	Meta *response.Meta // Limit, order and, when counted, the total
+}
+
+// TenderListOptions are the query parameters of GET /api/v1/tender; zero
+// values leave the gateway's defaults
+type TenderListOptions struct {
+	Limit  int
+	Offset int
+	Status string // Comma-separated for several
+	SortBy string
+	Order  string // ASC or DESC
+}
+
+// RUPListOptions are the query parameters of GET /api/v1/rup
+type RUPListOptions struct {
+	Limit          int
+	Offset         int
+	IncludeTotal   *bool // Count the total; nil leaves the gateway's default
+	IncludeDeleted bool  // Admin keys only
+}
+
+// QueryExecute runs POST /api/v1/query. A count_only request returns the
+// count in Count and a validate_only one only the Source.
+func (c *Client) QueryExecute(ctx context.Context, req apitypes.QueryRequest) (*datasource.QueryResult, *response.Meta, error) {
+	var result datasource.QueryResult
+	meta, err := c.call(ctx, http.MethodPost, "/api/v1/query", nil, req, &result)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &result, meta, nil
+}
+
+// BatchExecute runs POST /api/v1/batch. Queries that fail are reported in
+// their results. Unless req.Options.PartialOK is set, a batch with failed
+// queries is also returned as an *Error with status 422, together with the
+// response holding the results of every query.
+func (c *Client) BatchExecute(ctx context.Context, req apitypes.BatchRequest) (*apitypes.BatchResponse, error) {
+	resp, err := c.send(ctx, http.MethodPost, "/api/v1/batch", nil, req)
+	var apiErr *Error
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnprocessableEntity {
+		var result apitypes.BatchResponse
+		if json.Unmarshal(apiErr.body, &result) == nil && result.Results != nil {
+			apiErr.Code = http.StatusText(http.StatusUnprocessableEntity)
+			apiErr.Message = fmt.Sprintf("%d of %d queries failed", result.Summary.FailedQueries, result.Summary.TotalQueries)
+			return &result, apiErr
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// The batch response is not wrapped in the standard envelope
+	var result apitypes.BatchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decoding POST /api/v1/batch response: %w", err)
+	}
+	return &result, nil
+}
+
+// TenderList reads a page of GET /api/v1/tender
+func (c *Client) TenderList(ctx context.Context, opts TenderListOptions) (*Page, error) {
+	query := url.Values{}
+	setInt(query, "limit", opts.Limit)
+	setInt(query, "offset", opts.Offset)
+	setString(query, "status", opts.Status)
+	setString(query, "sort_by", opts.SortBy)
+	setString(query, "order", opts.Order)
+	return c.page(ctx, "/api/v1/tender", query)
+}
+
+// TenderSearch runs POST /api/v1/tender/search
+func (c *Client) TenderSearch(ctx context.Context, req apitypes.TenderSearchRequest) (*datasource.QueryResult, *response.Meta, error) {
+	var result datasource.QueryResult
+	meta, err := c.call(ctx, http.MethodPost, "/api/v1/tender/search", nil, req, &result)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &result, meta, nil
+}
+
+// RUPList reads a page of GET /api/v1/rup
+func (c *Client) RUPList(ctx context.Context, opts RUPListOptions) (*Page, error) {
+	query := url.Values{}
+	setInt(query, "limit", opts.Limit)
+	setInt(query, "offset", opts.Offset)
+	if opts.IncludeTotal != nil {
+		query.Set("include_total", strconv.FormatBool(*opts.IncludeTotal))
+	}
+	if opts.IncludeDeleted {
+		query.Set("include_deleted", "true")
+	}
+	return c.page(ctx, "/api/v1/rup", query)
+}
+
+// page reads a list endpoint whose data is its rows
+func (c *Client) page(ctx context.Context, path string, query url.Values) (*Page, error) {
+	page := &Page{}
+	meta, err := c.call(ctx, http.MethodGet, path, query, nil, &page.Rows)
+	if err != nil {
+		return nil, err
+	}
+	page.Meta = meta
+	return page, nil
+}
+
+func setInt(query url.Values, name string, value int) {
+	if value > 0 {
+		query.Set(name, strconv.Itoa(value))
+	}
+}
+
+func setString(query url.Values, name, value string) {
+	if value != "" {
+		query.Set(name, value)
+	}
+}
@@ -0,0 +1,49 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go-data-gateway/pkg/apitypes"
+)
+
+// Error is a request the gateway answered with an error status, as its
+// error envelope described it
+type Error struct {
+	StatusCode int
+	Code       string // e.g. VALIDATION_FAILED, UPSTREAM_TIMEOUT or the status text
+	Message    string
+	Details    json.RawMessage // Raw error.details, when given
+	RequestID  string
+	RetryAfter time.Duration // From Retry-After, when given
+
+	// Violations are the rejected fields of a VALIDATION_FAILED response
+	Violations []apitypes.Violation
+
+	body []byte // For endpoints whose error responses carry a result
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("gateway: %d %s: %s", e.StatusCode, e.Code, e.Message)
+	for _, violation := range e.Violations {
+		msg += fmt.Sprintf("; %s: %s", violation.Field, violation.Message)
+	}
+	return msg
+}
+
+// decodeViolations fills Violations from the details of a validation error
+func (e *Error) decodeViolations() {
+	if e.Code != apitypes.ErrCodeValidationFailed || len(e.Details) == 0 {
+		return
+	}
+	json.Unmarshal(e.Details, &e.Violations)
+}
+
+// IsNotFound reports whether err is a 404 from the gateway
+func IsNotFound(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
@@ -0,0 +1,204 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+
+	"go-data-gateway/internal/response"
+	"go-data-gateway/pkg/apitypes"
+)
+
+// ErrStreamTruncated is returned by Rows.Err when a stream ended before its
+// summary line, e.g. because the connection dropped
+var ErrStreamTruncated = errors.New("gateway: stream ended before its summary")
+
+// StreamSummary is the last line of an NDJSON stream
+type StreamSummary struct {
+	TotalRows   int    `json:"total_rows"`
+	SHA256      string `json:"sha256"` // Of every line before the summary
+	ChunkSize   int    `json:"chunk_size"`
+	DurationMs  int64  `json:"duration"`
+	ResumeToken string `json:"resume_token,omitempty"` // Continues a table stream after its last row
+
+	Warnings []response.Warning `json:"warnings,omitempty"` // E.g. LIMIT_INJECTED
+}
+
+// StreamError is an error the gateway reported in the body of a stream
+// after it had started
+type StreamError struct {
+	Code    string
+	Message string
+}
+
+func (e *StreamError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("gateway: stream failed: %s: %s", e.Code, e.Message)
+	}
+	return "gateway: stream failed: " + e.Message
+}
+
+// Stream runs POST /api/v1/stream as NDJSON and returns an iterator over its
+// rows. A request the gateway rejects before streaming is retried and
+// reported like any other; once rows arrive, failures are reported by
+// Rows.Err. The caller must Close the rows.
+func (c *Client) Stream(ctx context.Context, req apitypes.StreamRequest) (*Rows, error) {
+	req.Format = "ndjson"
+	resp, err := c.send(ctx, http.MethodPost, "/api/v1/stream", nil, req)
+	if err != nil {
+		return nil, err
+	}
+	return &Rows{body: resp.Body, reader: bufio.NewReader(resp.Body), hash: sha256.New()}, nil
+}
+
+// Rows iterates over the rows of a stream:
+//
+//	rows, err := c.Stream(ctx, req)
+//	if err != nil { ... }
+//	defer rows.Close()
+//	for rows.Next() {
+//		row := rows.Row()
+//	}
+//	if err := rows.Err(); err != nil { ... }
+//
+// Err is nil only when the stream ended with its summary and the summary's
+// row count and checksum match the rows received.
+type Rows struct {
+	body   io.ReadCloser
+	reader *bufio.Reader
+	hash   hash.Hash
+
+	next    []byte // Line read ahead; the last line is the summary
+	started bool
+	row     map[string]interface{}
+	rows    int
+	summary *StreamSummary
+	err     error
+	done    bool
+}
+
+// Next advances to the next row, returning false at the end of the stream
+// or on an error
+func (r *Rows) Next() bool {
+	if r.done {
+		return false
+	}
+	if !r.started {
+		r.started = true
+		r.next = r.readLine()
+	}
+	line := r.next
+	if line == nil && r.err != nil {
+		return r.finish(r.err)
+	}
+	if line == nil {
+		return r.finish(ErrStreamTruncated)
+	}
+	r.next = r.readLine()
+	if r.next == nil && r.err == nil {
+		return r.finish(r.readSummary(line))
+	}
+	if r.err != nil {
+		return r.finish(r.err)
+	}
+
+	r.hash.Write(line)
+	var row map[string]interface{}
+	if err := json.Unmarshal(line, &row); err != nil {
+		return r.finish(fmt.Errorf("gateway: malformed stream line: %w", err))
+	}
+	if streamErr := errorLine(row); streamErr != nil {
+		return r.finish(streamErr)
+	}
+	r.row = row
+	r.rows++
+	return true
+}
+
+// readLine returns the next line with its newline, or nil at the end of the
+// body; a read error other than the end is kept in r.err
+func (r *Rows) readLine() []byte {
+	line, err := r.reader.ReadBytes('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		r.err = err
+		return nil
+	}
+	if len(line) == 0 {
+		return nil
+	}
+	return line
+}
+
+// readSummary checks the last line of the stream against the rows received
+func (r *Rows) readSummary(line []byte) error {
+	var summary struct {
+		Type string `json:"type"`
+		StreamSummary
+	}
+	if json.Unmarshal(line, &summary) != nil || summary.Type != "summary" {
+		return ErrStreamTruncated
+	}
+	r.summary = &summary.StreamSummary
+	if summary.TotalRows != r.rows {
+		return fmt.Errorf("gateway: stream summary counts %d rows, received %d", summary.TotalRows, r.rows)
+	}
+	if sum := hex.EncodeToString(r.hash.Sum(nil)); summary.SHA256 != "" && summary.SHA256 != sum {
+		return fmt.Errorf("gateway: stream checksum %s does not match the summary's %s", sum, summary.SHA256)
+	}
+	return nil
+}
+
+// errorLine returns the error of an in-band error line, which the gateway
+// writes as {"type": "error", "error": ...} or, after a panic,
+// {"type": "error", "code": ..., "message": ...}
+func errorLine(line map[string]interface{}) error {
+	if line["type"] != "error" {
+		return nil
+	}
+	if message, ok := line["error"].(string); ok {
+		return &StreamError{Message: message}
+	}
+	code, hasCode := line["code"].(string)
+	message, hasMessage := line["message"].(string)
+	if hasCode && hasMessage {
+		return &StreamError{Code: code, Message: message}
+	}
+	return nil
+}
+
+func (r *Rows) finish(err error) bool {
+	r.done = true
+	r.row = nil
+	r.err = err
+	return false
+}
+
+// Row returns the current row
+func (r *Rows) Row() map[string]interface{} {
+	return r.row
+}
+
+// Err returns why the stream ended early, or nil after a complete stream
+func (r *Rows) Err() error {
+	return r.err
+}
+
+// Summary returns the summary line, once Next has returned false after a
+// stream that reached it
+func (r *Rows) Summary() *StreamSummary {
+	return r.summary
+}
+
+// Close closes the response body; a stream closed before its end cancels
+// the rest of the query
+func (r *Rows) Close() error {
+	r.done = true
+	return r.body.Close()
+}
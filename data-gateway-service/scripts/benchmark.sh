@@ -78,7 +78,7 @@ run_load_test() {
     hey -n 1000 -c 10 -m POST \
         -H "Content-Type: application/json" \
         -H "X-API-Key: ${API_KEY}" \
-        -d '{"query":"SELECT * FROM test_table LIMIT 100","data_source":"dremio"}' \
+        -d '{"sql":"SELECT * FROM test_table LIMIT 100","source":"DATAWAREHOUSE"}' \
         "${SERVER_URL}/api/v1/query" > hey_query_results.txt 2>&1
 
     # Test batch endpoint
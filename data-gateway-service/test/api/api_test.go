@@ -20,9 +20,13 @@ import (
 	"github.com/stretchr/testify/suite"
 	"go.uber.org/zap"
 
+	"go-data-gateway/internal/auth"
 	"go-data-gateway/internal/cache"
+	"go-data-gateway/internal/clients/bqtest"
+	"go-data-gateway/internal/config"
 	"go-data-gateway/internal/datasource"
 	"go-data-gateway/internal/handlers/v1"
+	custommw "go-data-gateway/internal/middleware/chi"
 	"go-data-gateway/internal/response"
 )
 
@@ -34,7 +38,9 @@ type APITestSuite struct {
 	dataSources map[string]datasource.DataSource
 	cache       cache.Cache
 	logger      *zap.Logger
+	bigquery    *bqtest.Stub
 	apiKey      string
+	keyStore    *auth.KeyStore
 }
 
 // SetupSuite runs once before all tests
@@ -48,11 +54,22 @@ func (suite *APITestSuite) SetupSuite() {
 		"BIGQUERY":      NewMockDataSource(datasource.DataSourceBigQuery),
 	}
 
+	// RUP reads BigQuery directly; the stub answers with two records
+	suite.bigquery = bqtest.NewStub()
+	suite.bigquery.Respond("kd_kro_str = 'NOTFOUND'", []map[string]interface{}{})
+	suite.bigquery.Respond("COUNT(*)", []map[string]interface{}{{"total": int64(2)}})
+	suite.bigquery.Respond("rup_kromaster", []map[string]interface{}{
+		{"kd_kro_str": "RUP-001", "nama_kro": "Pengadaan Laptop", "pagu_kro": 150000000.0},
+		{"kd_kro_str": "RUP-002", "nama_kro": "Renovasi Gedung", "pagu_kro": 900000000.0},
+	})
+
 	// Initialize cache
 	suite.cache = &cache.NoOpCache{}
 
-	// Set API key for testing
+	// Set API key for testing; it may also read cache stats
 	suite.apiKey = "test-api-key-123"
+	suite.keyStore = auth.NewKeyStore([]string{"test-plain-key-456"}, nil, nil, time.Minute, suite.logger)
+	suite.keyStore.AddEnvKeys([]string{suite.apiKey}, []string{auth.ScopeMetricsRead}, nil)
 
 	// Setup router
 	suite.setupRouter()
@@ -70,6 +87,7 @@ func (suite *APITestSuite) TearDownSuite() {
 
 // setupRouter creates and configures the test router
 func (suite *APITestSuite) setupRouter() {
+	pagination := config.DefaultPagination()
 	r := chi.NewRouter()
 
 	// Middleware
@@ -83,7 +101,11 @@ func (suite *APITestSuite) setupRouter() {
 	r.Get("/health", suite.healthCheck)
 	r.Get("/ready", suite.readyCheck)
 	r.Get("/metrics", suite.metricsHandler)
-	r.Get("/cache/stats", suite.cacheStatsHandler)
+	r.Get("/cache/stats/summary", suite.cacheStatsSummaryHandler)
+
+	// Cache stats need an API key with the metrics:read scope
+	r.With(custommw.NetworkOrScope(suite.keyStore, auth.ScopeMetricsRead, nil)).
+		Get("/cache/stats", suite.cacheStatsHandler)
 
 	// API routes with authentication
 	r.Route("/api/v1", func(r chi.Router) {
@@ -91,9 +113,9 @@ func (suite *APITestSuite) setupRouter() {
 		r.Use(suite.authMiddleware)
 
 		// Query endpoints
-		queryHandler := v1.NewQueryHandler(suite.dataSources, suite.logger)
-		batchHandler := v1.NewBatchHandler(suite.dataSources, suite.logger)
-		streamHandler := v1.NewStreamHandler(suite.dataSources, suite.logger)
+		queryHandler := v1.NewQueryHandler(suite.dataSources, pagination.Query, nil, false, suite.logger)
+		batchHandler := v1.NewBatchHandler(suite.dataSources, nil, suite.logger)
+		streamHandler := v1.NewStreamHandler(suite.dataSources, pagination.Stream, suite.logger)
 
 		r.Post("/query", queryHandler.Execute)
 		r.Post("/batch", batchHandler.Execute)
@@ -102,19 +124,20 @@ func (suite *APITestSuite) setupRouter() {
 		r.Post("/stream/sse", streamHandler.StreamSSE)
 
 		// Tender endpoints
-		tenderHandler := v1.NewTenderHandler(suite.dataSources["DATAWAREHOUSE"], suite.logger)
+		tenderHandler := v1.NewTenderHandler(suite.dataSources["DATAWAREHOUSE"], pagination.Tender, nil, suite.logger)
 		r.Route("/tender", func(r chi.Router) {
 			r.Get("/", tenderHandler.List)
 			r.Get("/{id}", tenderHandler.GetByID)
 			r.Post("/search", tenderHandler.Search)
 		})
 
-		// RUP endpoints (would need BigQuery client mock)
-		// r.Route("/rup", func(r chi.Router) {
-		//     r.Get("/", rupHandler.List)
-		//     r.Get("/{id}", rupHandler.GetByID)
-		//     r.Post("/search", rupHandler.Search)
-		// })
+		// RUP endpoints
+		rupHandler := v1.NewRUPHandler(suite.bigquery, pagination.RUP, suite.logger)
+		r.Route("/rup", func(r chi.Router) {
+			r.Get("/", rupHandler.List)
+			r.Get("/{id}", rupHandler.GetByID)
+			r.Post("/search", rupHandler.Search)
+		})
 
 		// BigQuery estimate endpoint
 		r.Post("/bigquery/estimate-cost", suite.estimateCostHandler)
@@ -200,6 +223,14 @@ func (suite *APITestSuite) cacheStatsHandler(w http.ResponseWriter, r *http.Requ
 	response.Success(w, stats, nil)
 }
 
+func (suite *APITestSuite) cacheStatsSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"hit_rate":  0.75,
+		"connected": true,
+	})
+}
+
 func (suite *APITestSuite) estimateCostHandler(w http.ResponseWriter, r *http.Request) {
 	var req map[string]string
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -284,6 +315,37 @@ func (suite *APITestSuite) TestCacheStatsEndpoint() {
 	assert.NotNil(suite.T(), data["total_hits"])
 }
 
+func (suite *APITestSuite) TestCacheStatsRequiresMetricsScope() {
+	req, _ := http.NewRequest("GET", fmt.Sprintf("%s/cache/stats", suite.server.URL), nil)
+	req.Header.Set("X-API-Key", "test-plain-key-456")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(suite.T(), err)
+	defer resp.Body.Close()
+
+	assert.Equal(suite.T(), http.StatusForbidden, resp.StatusCode)
+}
+
+func (suite *APITestSuite) TestCacheStatsSummaryEndpoint() {
+	resp, err := http.Get(fmt.Sprintf("%s/cache/stats/summary", suite.server.URL))
+	require.NoError(suite.T(), err)
+	defer resp.Body.Close()
+
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+
+	var result map[string]interface{}
+	require.NoError(suite.T(), json.NewDecoder(resp.Body).Decode(&result))
+	assert.ElementsMatch(suite.T(), []string{"hit_rate", "connected"}, mapKeys(result))
+}
+
+func mapKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
 // Test Authentication
 func (suite *APITestSuite) TestAuthenticationRequired() {
 	tests := []struct {
@@ -295,6 +357,7 @@ func (suite *APITestSuite) TestAuthenticationRequired() {
 		{"Query without auth", "/api/v1/query", "POST", map[string]string{"sql": "SELECT 1", "source": "DATAWAREHOUSE"}},
 		{"Tender list without auth", "/api/v1/tender", "GET", nil},
 		{"Batch without auth", "/api/v1/batch", "POST", map[string]interface{}{"queries": []interface{}{}}},
+		{"Cache stats without auth", "/cache/stats", "GET", nil},
 	}
 
 	for _, tt := range tests {
@@ -401,7 +464,7 @@ func (suite *APITestSuite) TestQueryEndpoint() {
 				"sql":    "SELECT * FROM table",
 				"source": "INVALID",
 			},
-			expectedStatus: http.StatusServiceUnavailable,
+			expectedStatus: http.StatusBadRequest,
 		},
 	}
 
@@ -486,6 +549,58 @@ func (suite *APITestSuite) TestBatchEndpoint() {
 	}
 }
 
+// Test RUP Endpoints
+func (suite *APITestSuite) TestRUPListEndpoint() {
+	req, _ := http.NewRequest("GET", fmt.Sprintf("%s/api/v1/rup?limit=10", suite.server.URL), nil)
+	req.Header.Set("X-API-Key", suite.apiKey)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(suite.T(), err)
+	defer resp.Body.Close()
+	require.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+
+	var result struct {
+		Data []map[string]interface{} `json:"data"`
+		Meta map[string]interface{}   `json:"meta"`
+	}
+	require.NoError(suite.T(), json.NewDecoder(resp.Body).Decode(&result))
+	assert.Len(suite.T(), result.Data, 2)
+	assert.Equal(suite.T(), "RUP-001", result.Data[0]["kd_kro_str"])
+	assert.EqualValues(suite.T(), 2, result.Meta["total"])
+}
+
+func (suite *APITestSuite) TestRUPGetByIDEndpoint() {
+	tests := []struct {
+		name           string
+		rupID          string
+		expectedStatus int
+	}{
+		{
+			name:           "Valid RUP ID",
+			rupID:          "RUP-001",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Non-existent RUP ID",
+			rupID:          "NOTFOUND",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		suite.T().Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", fmt.Sprintf("%s/api/v1/rup/%s", suite.server.URL, tt.rupID), nil)
+			req.Header.Set("X-API-Key", suite.apiKey)
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+		})
+	}
+}
+
 // Test Tender Endpoints
 func (suite *APITestSuite) TestTenderListEndpoint() {
 	tests := []struct {
@@ -518,6 +633,11 @@ func (suite *APITestSuite) TestTenderListEndpoint() {
 			queryParams:    "?sort_by=nilai_pagu&order=ASC",
 			expectedStatus: http.StatusOK,
 		},
+		{
+			name:           "List with injected sort order",
+			queryParams:    "?order=ASC%3B%20DROP%20TABLE%20tender",
+			expectedStatus: http.StatusBadRequest,
+		},
 	}
 
 	for _, tt := range tests {
@@ -599,14 +719,24 @@ func (suite *APITestSuite) TestTenderSearchEndpoint() {
 		{
 			name: "Search with filters",
 			body: map[string]interface{}{
-				"status":         "active",
-				"tahun_anggaran": 2025,
-				"provinsi":       "DKI Jakarta",
-				"nilai_pagu_min": 1000000000,
-				"nilai_pagu_max": 5000000000,
+				"filters": []map[string]interface{}{
+					{"field": "tahun_anggaran", "op": "eq", "value": 2025},
+					{"field": "provinsi", "op": "eq", "value": "DKI Jakarta"},
+					{"field": "nilai_pagu", "op": "gte", "value": 1000000000},
+					{"field": "nilai_pagu", "op": "lte", "value": 5000000000},
+				},
 			},
 			expectedStatus: http.StatusOK,
 		},
+		{
+			name: "Search with an unknown operator",
+			body: map[string]interface{}{
+				"filters": []map[string]interface{}{
+					{"field": "nilai_pagu", "op": "between", "value": 1},
+				},
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
 		{
 			name:           "Empty search",
 			body:           map[string]interface{}{},
@@ -755,7 +885,7 @@ func (suite *APITestSuite) TestErrorResponses() {
 			body:           "invalid json",
 			headers:        map[string]string{"X-API-Key": suite.apiKey, "Content-Type": "application/json"},
 			expectedStatus: http.StatusBadRequest,
-			expectedError:  "Invalid request",
+			expectedError:  "Request validation failed",
 		},
 		{
 			name:           "Missing auth",
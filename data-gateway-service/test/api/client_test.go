@@ -0,0 +1,121 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/pkg/apitypes"
+	"go-data-gateway/pkg/client"
+)
+
+// gatewayClient is the typed client of the suite's server
+func (suite *APITestSuite) gatewayClient() *client.Client {
+	return client.New(client.Config{BaseURL: suite.server.URL, APIKey: suite.apiKey, MaxRetries: -1})
+}
+
+func (suite *APITestSuite) TestClientQueryExecute() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, _, err := suite.gatewayClient().QueryExecute(ctx, apitypes.QueryRequest{
+		SQL:    "SELECT * FROM tender_data LIMIT 10",
+		Source: datasource.DataSourceDremio,
+	})
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 2, result.Count)
+	assert.Equal(suite.T(), "Test Item 1", result.Data[0]["name"])
+}
+
+func (suite *APITestSuite) TestClientBatchExecute() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := suite.gatewayClient().BatchExecute(ctx, apitypes.BatchRequest{Queries: []apitypes.BatchQuery{
+		{ID: "q1", Query: "SELECT 1", DataSource: "DATAWAREHOUSE"},
+		{ID: "q2", Query: "SELECT 2", DataSource: "BIGQUERY"},
+	}})
+	require.NoError(suite.T(), err)
+	assert.True(suite.T(), result.Success)
+	assert.Equal(suite.T(), 2, result.Summary.SuccessfulQueries)
+	require.Len(suite.T(), result.Results, 2)
+	assert.Equal(suite.T(), 2, result.Results[0].RowCount)
+}
+
+func (suite *APITestSuite) TestClientTenderListAndSearch() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	c := suite.gatewayClient()
+
+	page, err := c.TenderList(ctx, client.TenderListOptions{Limit: 10, Status: "Aktif"})
+	require.NoError(suite.T(), err)
+	assert.Len(suite.T(), page.Rows, 2)
+	assert.Equal(suite.T(), 10, page.Meta.Limit)
+
+	value, _ := json.Marshal(1000000000)
+	result, _, err := c.TenderSearch(ctx, apitypes.TenderSearchRequest{
+		Filters: []apitypes.SearchClause{{Field: "nilai_pagu", Op: "gte", Value: value}},
+		Keyword: apitypes.Keywords{"konstruksi"},
+		Limit:   50,
+	})
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 2, result.Count)
+}
+
+func (suite *APITestSuite) TestClientRUPList() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	withTotal := true
+	page, err := suite.gatewayClient().RUPList(ctx, client.RUPListOptions{Limit: 10, IncludeTotal: &withTotal})
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), page.Rows, 2)
+	assert.Equal(suite.T(), "RUP-001", page.Rows[0]["kd_kro_str"])
+	assert.Equal(suite.T(), 2, page.Meta.Total)
+}
+
+func (suite *APITestSuite) TestClientStream() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rows, err := suite.gatewayClient().Stream(ctx, apitypes.StreamRequest{
+		Query:      "SELECT * FROM large_table",
+		DataSource: "DATAWAREHOUSE",
+		ChunkSize:  1000,
+	})
+	require.NoError(suite.T(), err)
+	defer rows.Close()
+
+	var names []interface{}
+	for rows.Next() {
+		names = append(names, rows.Row()["name"])
+	}
+	require.NoError(suite.T(), rows.Err())
+	assert.Equal(suite.T(), []interface{}{"Test Item 1", "Test Item 2"}, names)
+	assert.Equal(suite.T(), 2, rows.Summary().TotalRows)
+}
+
+func (suite *APITestSuite) TestClientErrors() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Violations of the request body are decoded from the envelope
+	_, err := suite.gatewayClient().BatchExecute(ctx, apitypes.BatchRequest{})
+	var apiErr *client.Error
+	require.ErrorAs(suite.T(), err, &apiErr)
+	assert.Equal(suite.T(), http.StatusBadRequest, apiErr.StatusCode)
+	assert.Equal(suite.T(), apitypes.ErrCodeValidationFailed, apiErr.Code)
+	require.NotEmpty(suite.T(), apiErr.Violations)
+	assert.Equal(suite.T(), "queries", apiErr.Violations[0].Field)
+
+	unauthorized := client.New(client.Config{BaseURL: suite.server.URL, APIKey: "wrong-key"})
+	_, err = unauthorized.TenderList(ctx, client.TenderListOptions{})
+	require.ErrorAs(suite.T(), err, &apiErr)
+	assert.Equal(suite.T(), http.StatusUnauthorized, apiErr.StatusCode)
+	assert.Equal(suite.T(), "Unauthorized", apiErr.Message)
+}
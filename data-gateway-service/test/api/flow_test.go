@@ -0,0 +1,141 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go-data-gateway/internal/clients/bqtest"
+	"go-data-gateway/internal/config"
+	"go-data-gateway/internal/datasource"
+	"go-data-gateway/internal/datasource/flighttest"
+	"go-data-gateway/internal/handlers/v1"
+)
+
+// flowServer serves /api/v1/query over the real data sources: a pooled
+// Arrow Flight client of an in-process Flight server and a BigQuery wrapper
+// of a stub, so requests run handler to datasource without a network
+type flowServer struct {
+	*httptest.Server
+	flight   *flighttest.Server
+	bigquery *bqtest.Stub
+}
+
+func newFlowServer(t *testing.T) *flowServer {
+	t.Helper()
+	logger := zap.NewNop()
+
+	flight := flighttest.NewServer()
+	flight.SetCredentials("gateway", "flow-test")
+	t.Cleanup(flight.Close)
+
+	dremio, err := datasource.NewDremioArrowClientWithPool(&datasource.DremioConfig{
+		Host:     flight.Host(),
+		Port:     flight.Port(),
+		Username: "gateway",
+		Password: "flow-test",
+	}, &datasource.PoolConfig{
+		MaxConnections:      2,
+		MinConnections:      1,
+		MaxIdleTime:         time.Minute,
+		ConnectionTimeout:   5 * time.Second,
+		HealthCheckInterval: time.Hour,
+	}, logger)
+	require.NoError(t, err)
+	t.Cleanup(func() { dremio.Close() })
+
+	bigquery := bqtest.NewStub()
+	sources := map[string]datasource.DataSource{
+		"DATAWAREHOUSE": dremio,
+		"BIGQUERY":      datasource.NewBigQueryWrapperWithClient(bigquery, logger),
+	}
+
+	queryHandler := v1.NewQueryHandler(sources, config.DefaultPagination().Query, nil, false, logger)
+	r := chi.NewRouter()
+	r.Post("/api/v1/query", queryHandler.Execute)
+
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+	return &flowServer{Server: server, flight: flight, bigquery: bigquery}
+}
+
+// query posts a query request and decodes the response envelope
+func (s *flowServer) query(t *testing.T, source, sql string) (int, map[string]interface{}) {
+	t.Helper()
+	body, _ := json.Marshal(map[string]string{"sql": sql, "source": source})
+	resp, err := http.Post(s.URL+"/api/v1/query", "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var envelope map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&envelope))
+	return resp.StatusCode, envelope
+}
+
+// flowRows returns the rows of a query response envelope
+func flowRows(envelope map[string]interface{}) []interface{} {
+	result, _ := envelope["data"].(map[string]interface{})
+	rows, _ := result["data"].([]interface{})
+	return rows
+}
+
+func TestFlow_DremioQueryOverFlight(t *testing.T) {
+	s := newFlowServer(t)
+	s.flight.Respond("FROM tender", flighttest.Result{
+		Schema: arrow.NewSchema([]arrow.Field{
+			{Name: "kd_tender", Type: arrow.BinaryTypes.String},
+			{Name: "pagu", Type: arrow.PrimitiveTypes.Float64},
+		}, nil),
+		Rows: [][]interface{}{{"T-1", 1500.5}, {"T-2", 20.0}},
+	})
+
+	code, envelope := s.query(t, "DATAWAREHOUSE", "SELECT kd_tender, pagu FROM tender")
+	require.Equal(t, http.StatusOK, code, envelope)
+
+	rows := flowRows(envelope)
+	require.Len(t, rows, 2)
+	assert.Equal(t, "T-1", rows[0].(map[string]interface{})["kd_tender"])
+	assert.Equal(t, 1500.5, rows[0].(map[string]interface{})["pagu"])
+
+	queries := s.flight.Queries()
+	require.NotEmpty(t, queries)
+	assert.Contains(t, queries[len(queries)-1], "FROM tender")
+}
+
+func TestFlow_DremioErrorReachesClient(t *testing.T) {
+	s := newFlowServer(t)
+	s.flight.Fail("FROM missing", status.Error(codes.NotFound, "Object 'missing' not found within 'space'"))
+
+	code, envelope := s.query(t, "DATAWAREHOUSE", "SELECT * FROM missing")
+	assert.GreaterOrEqual(t, code, http.StatusBadRequest)
+	assert.Equal(t, false, envelope["success"])
+}
+
+func TestFlow_BigQueryQueryThroughWrapper(t *testing.T) {
+	s := newFlowServer(t)
+	s.bigquery.Respond("FROM dataset.rup", []map[string]interface{}{
+		{"kd_rup": "R-1", "pagu": 10.0},
+	})
+
+	code, envelope := s.query(t, "BIGQUERY", "SELECT kd_rup, pagu FROM dataset.rup")
+	require.Equal(t, http.StatusOK, code, envelope)
+
+	rows := flowRows(envelope)
+	require.Len(t, rows, 1)
+	assert.Equal(t, "R-1", rows[0].(map[string]interface{})["kd_rup"])
+
+	queries := s.bigquery.Queries()
+	require.Len(t, queries, 1)
+	assert.Contains(t, queries[0].SQL, "FROM dataset.rup")
+}